@@ -2,9 +2,12 @@ package nexagent
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -32,6 +35,10 @@ const (
 	runloopTickInterval                 = 2500 * time.Millisecond
 	workloadExecutionSleepTimeoutMillis = 1000
 	workloadCacheFileKey                = "workload"
+
+	// Pings on the internal NATS connection also reveal a dead connection promptly, e.g. once the
+	// agent's machine has been restored from a checkpoint on another node
+	agentNATSPingInterval = 5 * time.Second
 )
 
 // Agent facilitates communication between the nex agent running in the firecracker VM
@@ -48,12 +55,27 @@ type Agent struct {
 
 	provider providers.ExecutionProvider
 
+	// Closed once the deployed workload exits; nil for providers that do not report an exit
+	workloadExited chan struct{}
+
 	cacheBucket nats.ObjectStore
 	md          *agentapi.MachineMetadata
 	nc          *nats.Conn
 	started     time.Time
 
+	// User keypair with which the internal NATS connection signs the server nonce; replaced
+	// whenever the node rotates the agent's credentials
+	natsKeyPair atomic.Value
+
+	// Curve keypair offered to the node during the handshake, and the cipher sealing payloads
+	// for the node once it accepts; the cipher remains nil for nodes predating payload encryption
+	xkp    nkeys.KeyPair
+	cipher *agentapi.PayloadCipher
+
 	sandboxed bool
+
+	// Path at which the workload's persistent volume is mounted, if it has one
+	volumePath string
 }
 
 // Initialize a new agent to facilitate communications with the host
@@ -130,11 +152,34 @@ func (a *Agent) Start() {
 // NOTE: the agent process will request a VM shutdown if this fails
 func (a *Agent) requestHandshake() error {
 	a.LogInfo("Requesting handshake from host")
+
+	memoryTotal, memoryAvailable, err := readMemory()
+	if err != nil {
+		a.LogInfo(fmt.Sprintf("Failed to read available memory; it will not be reported to the node: %s", err))
+	}
+
 	msg := agentapi.HandshakeRequest{
 		ID:        a.md.VmID,
 		StartTime: a.started,
 		Message:   a.md.Message,
+
+		AgentVersion:    VERSION,
+		ProtocolVersion: agentapi.AgentProtocolVersion,
+
+		Capabilities: &controlapi.AgentCapabilities{
+			Providers:            providers.SupportedProviders(VERSION),
+			MemoryTotalBytes:     memoryTotal,
+			MemoryAvailableBytes: memoryAvailable,
+		},
+	}
+
+	a.xkp, err = nkeys.CreateCurveKeys()
+	if err != nil {
+		a.LogError(fmt.Sprintf("Failed to create xkey for payload encryption: %s", err))
+		return err
 	}
+	msg.XKey, _ = a.xkp.PublicKey()
+
 	raw, _ := json.Marshal(msg)
 
 	resp, err := a.nc.Request(fmt.Sprintf("hostint.%s.handshake", *a.md.VmID), raw, time.Millisecond*defaultAgentHandshakeTimeoutMillis)
@@ -157,6 +202,23 @@ func (a *Agent) requestHandshake() error {
 		return err
 	}
 
+	// nodes predating protocol versioning don't report one and accept any agent
+	if handshakeResponse.ProtocolVersion > 0 && !handshakeResponse.Compatible {
+		reason := "unknown reason"
+		if handshakeResponse.Message != nil {
+			reason = *handshakeResponse.Message
+		}
+		a.LogError(fmt.Sprintf("Node speaking agent protocol version %d rejected this agent (protocol version %d): %s; no workloads will be deployed",
+			handshakeResponse.ProtocolVersion, agentapi.AgentProtocolVersion, reason,
+		))
+	}
+
+	if handshakeResponse.XKey != "" {
+		a.cipher = agentapi.NewPayloadCipher(a.xkp, handshakeResponse.XKey)
+	} else {
+		a.LogInfo("Node does not support payload encryption; deploy and trigger payloads will be received in the clear")
+	}
+
 	a.LogInfo("Agent is up")
 	return nil
 }
@@ -173,13 +235,18 @@ func (a *Agent) cacheExecutableArtifact(req *agentapi.DeployRequest) (*string, e
 	fileName := fmt.Sprintf("workload-%s", *a.md.VmID)
 	tempFile := path.Join(os.TempDir(), fileName)
 
-	if strings.EqualFold(runtime.GOOS, "windows") && req.WorkloadType == controlapi.NexWorkloadNative {
+	if strings.EqualFold(runtime.GOOS, "windows") && isNativeBinary(req.WorkloadType) {
 		tempFile = fmt.Sprintf("%s.exe", tempFile)
 	}
 
-	err := a.cacheBucket.GetFile(workloadCacheFileKey, tempFile)
+	artifact, err := a.fetchWorkloadArtifact()
 	if err != nil {
-		msg := fmt.Sprintf("Failed to get and write workload artifact to temp dir: %s", err)
+		return nil, err
+	}
+
+	err = os.WriteFile(tempFile, artifact, 0777)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to write workload artifact to temp dir: %s", err)
 		a.LogError(msg)
 		return nil, errors.New(msg)
 	}
@@ -194,6 +261,25 @@ func (a *Agent) cacheExecutableArtifact(req *agentapi.DeployRequest) (*string, e
 	return &tempFile, nil
 }
 
+// Reads the workload artifact the node placed in the cache bucket
+func (a *Agent) fetchWorkloadArtifact() ([]byte, error) {
+	sealed, err := a.cacheBucket.GetBytes(workloadCacheFileKey)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to get workload artifact from cache: %s", err)
+		a.LogError(msg)
+		return nil, errors.New(msg)
+	}
+
+	artifact, err := a.cipher.Open(sealed)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to open workload artifact: %s", err)
+		a.LogError(msg)
+		return nil, errors.New(msg)
+	}
+
+	return artifact, nil
+}
+
 // Run inside a goroutine to pull event entries and publish them to the node host.
 func (a *Agent) dispatchEvents() {
 	for !a.shuttingDown() {
@@ -239,44 +325,74 @@ func (a *Agent) dispatchLogs() {
 // bucket, write it to tmp, initialize the execution provider per the
 // request, and then validate and deploy a workload
 func (a *Agent) handleDeploy(m *nats.Msg) {
+	data, err := a.cipher.Open(m.Data)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to open deploy request: %s", err)
+		a.LogError(msg)
+		_ = a.workReject(m, controlapi.DeployRejectionValidationFailed, msg)
+		return
+	}
+
 	var request agentapi.DeployRequest
-	err := json.Unmarshal(m.Data, &request)
+	err = json.Unmarshal(data, &request)
 	if err != nil {
 		msg := fmt.Sprintf("Failed to unmarshal deploy request: %s", err)
 		a.LogError(msg)
-		_ = a.workAck(m, false, msg)
+		_ = a.workReject(m, controlapi.DeployRejectionValidationFailed, msg)
 		return
 	}
 
 	err = request.Validate()
 	if err != nil {
-		_ = a.workAck(m, false, fmt.Sprintf("%v", err)) // FIXME-- this message can be formatted prettier
+		_ = a.workReject(m, controlapi.DeployRejectionValidationFailed, fmt.Sprintf("%v", err)) // FIXME-- this message can be formatted prettier
 		return
 	}
 
 	tmpFile, err := a.cacheExecutableArtifact(&request)
 	if err != nil {
-		_ = a.workAck(m, false, err.Error())
+		_ = a.workReject(m, controlapi.DeployRejectionFailed, err.Error())
+		return
+	}
+
+	err = a.materializeMounts(&request)
+	if err != nil {
+		a.LogError(err.Error())
+		_ = a.workReject(m, controlapi.DeployRejectionFailed, err.Error())
+		return
+	}
+
+	err = a.mountVolume(&request)
+	if err != nil {
+		a.LogError(err.Error())
+		_ = a.workReject(m, controlapi.DeployRejectionFailed, err.Error())
+		return
+	}
+
+	stdin, err := a.deliverWorkloadInput(&request)
+	if err != nil {
+		a.LogError(err.Error())
+		_ = a.workReject(m, controlapi.DeployRejectionFailed, err.Error())
 		return
 	}
 
 	params, err := a.newExecutionProviderParams(&request, *tmpFile)
 	if err != nil {
-		_ = a.workAck(m, false, err.Error())
+		_ = a.workReject(m, controlapi.DeployRejectionFailed, err.Error())
 		return
 	}
+	params.Stdin = stdin
 
 	provider, err := providers.NewExecutionProvider(params)
 	if err != nil {
 		msg := fmt.Sprintf("Failed to initialize workload execution provider; %s", err)
 		a.LogError(msg)
-		_ = a.workAck(m, false, msg)
+		_ = a.workReject(m, controlapi.DeployRejectionUnsupportedType, msg)
 		return
 	}
 	a.provider = provider
 
 	shouldValidate := true
-	if !a.sandboxed && request.WorkloadType == controlapi.NexWorkloadNative {
+	if !a.sandboxed && isNativeBinary(request.WorkloadType) {
 		shouldValidate = false
 	}
 
@@ -285,7 +401,7 @@ func (a *Agent) handleDeploy(m *nats.Msg) {
 		if err != nil {
 			msg := fmt.Sprintf("Failed to validate workload: %s", err)
 			a.LogError(msg)
-			_ = a.workAck(m, false, msg)
+			_ = a.workReject(m, controlapi.DeployRejectionValidationFailed, msg)
 			return
 		}
 	}
@@ -305,6 +421,14 @@ func (a *Agent) handleUndeploy(m *nats.Msg) {
 		return
 	}
 
+	var request agentapi.UndeployRequest
+	if len(m.Data) > 0 {
+		err := json.Unmarshal(m.Data, &request)
+		if err != nil {
+			a.LogError(fmt.Sprintf("Failed to unmarshal undeploy request: %s", err))
+		}
+	}
+
 	err := a.provider.Undeploy()
 	if err != nil {
 		// don't return an error here so worst-case scenario is an ungraceful shutdown,
@@ -312,7 +436,28 @@ func (a *Agent) handleUndeploy(m *nats.Msg) {
 		a.LogError(fmt.Sprintf("Failed to undeploy workload: %s", err))
 	}
 
-	_ = m.Respond([]byte{})
+	exited := a.awaitWorkloadExit(time.Duration(request.GracePeriodMillis) * time.Millisecond)
+	if exited {
+		a.unmountVolume()
+	}
+
+	raw, _ := json.Marshal(&agentapi.UndeployResponse{Exited: exited})
+	_ = m.Respond(raw)
+}
+
+// Waits up to the given grace period for the deployed workload to exit, returning true if it has
+func (a *Agent) awaitWorkloadExit(gracePeriod time.Duration) bool {
+	if a.workloadExited == nil {
+		// this provider is done with its workload once undeploy returns
+		return true
+	}
+
+	select {
+	case <-a.workloadExited:
+		return true
+	case <-time.After(gracePeriod):
+		return false
+	}
 }
 
 func (a *Agent) handlePing(m *nats.Msg) {
@@ -320,6 +465,107 @@ func (a *Agent) handlePing(m *nats.Msg) {
 	_ = m.Respond([]byte("OK"))
 }
 
+// Switches the internal NATS connection to credentials issued by the node. The node revokes
+// the previous credentials once this request is acknowledged, after which the server closes the
+// connection and the client reconnects with the new key
+func (a *Agent) handleRotateCredentials(m *nats.Msg) {
+	data, err := a.cipher.Open(m.Data)
+	if err != nil {
+		a.LogError(fmt.Sprintf("Failed to open credentials rotation request: %s", err))
+		_ = m.Respond([]byte{})
+		return
+	}
+
+	var request agentapi.CredentialsRotationRequest
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		a.LogError(fmt.Sprintf("Failed to unmarshal credentials rotation request: %s", err))
+		_ = m.Respond([]byte{})
+		return
+	}
+
+	pair, err := nkeys.FromSeed([]byte(request.NkeySeed))
+	if err != nil {
+		a.LogError(fmt.Sprintf("Received invalid nkey seed in credentials rotation request: %s", err))
+		_ = m.Respond([]byte{})
+		return
+	}
+
+	pk, err := pair.PublicKey()
+	if err != nil {
+		a.LogError(fmt.Sprintf("Failed to derive public key from rotated credentials: %s", err))
+		_ = m.Respond([]byte{})
+		return
+	}
+
+	// the client only reads the nkey when (re)connecting, which cannot happen before the node
+	// has seen the acknowledgement below and revoked the previous key
+	a.natsKeyPair.Store(pair)
+	a.nc.Opts.Nkey = pk
+
+	a.LogInfo("Rotated internal NATS credentials")
+	raw, _ := json.Marshal(&agentapi.CredentialsRotationResponse{Rotated: true})
+	_ = m.Respond(raw)
+}
+
+// Swaps the deployed workload's artifact for the one the node has just placed in the cache
+// bucket, for providers that can do so while the workload runs
+func (a *Agent) handleHotReload(m *nats.Msg) {
+	respond := func(reloaded bool, msg string) {
+		response := agentapi.HotReloadResponse{Reloaded: reloaded}
+		if msg != "" {
+			response.Message = &msg
+		}
+		raw, _ := json.Marshal(&response)
+		_ = m.Respond(raw)
+	}
+
+	data, err := a.cipher.Open(m.Data)
+	if err != nil {
+		a.LogError(fmt.Sprintf("Failed to open hot reload request: %s", err))
+		respond(false, "failed to open hot reload request")
+		return
+	}
+
+	var request agentapi.HotReloadRequest
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		a.LogError(fmt.Sprintf("Failed to unmarshal hot reload request: %s", err))
+		respond(false, "failed to unmarshal hot reload request")
+		return
+	}
+
+	reloadable, ok := a.provider.(providers.HotReloadable)
+	if !ok {
+		respond(false, "deployed workload does not support hot reload")
+		return
+	}
+
+	artifact, err := a.fetchWorkloadArtifact()
+	if err != nil {
+		respond(false, err.Error())
+		return
+	}
+
+	digest := sha256.Sum256(artifact)
+	if !strings.EqualFold(hex.EncodeToString(digest[:]), request.Hash) {
+		a.LogError("Hot reload artifact does not match the requested hash")
+		respond(false, "artifact does not match the requested hash")
+		return
+	}
+
+	err = reloadable.Reload(artifact)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to hot reload workload: %s", err)
+		a.LogError(msg)
+		respond(false, msg)
+		return
+	}
+
+	a.LogInfo(fmt.Sprintf("Hot reloaded workload artifact %s", request.Hash))
+	respond(true, "")
+}
+
 // Agent instances subscribe to the following `agentint.>` subjects,
 // which are exported dynamically by each `<agent_id>` account on the
 // configured internal NATS connection for consumption by the nex node:
@@ -327,6 +573,8 @@ func (a *Agent) handlePing(m *nats.Msg) {
 // - agentint.<agent_id>.deploy
 // - agentint.<agent_id>.undeploy
 // - agentint.<agent_id>.ping
+// - agentint.<agent_id>.rotatecreds
+// - agentint.<agent_id>.hotreload
 func (a *Agent) init() error {
 	a.installSignalHandlers()
 
@@ -337,6 +585,17 @@ func (a *Agent) init() error {
 
 	var err error
 
+	extensionsPath := os.Getenv(providers.ExtensionsConfigPathEnv)
+	if extensionsPath == "" {
+		extensionsPath = providers.DefaultExtensionsConfigPath
+	}
+
+	err = providers.LoadExtensions(extensionsPath)
+	if err != nil {
+		a.LogError(fmt.Sprintf("Failed to load execution provider extensions: %s", err))
+		return err
+	}
+
 	if a.sandboxed {
 		err = a.setNameservers()
 		if err != nil {
@@ -377,6 +636,18 @@ func (a *Agent) init() error {
 		a.LogError(fmt.Sprintf("failed to subscribe to ping subject: %s", err))
 	}
 
+	rotateSubject := fmt.Sprintf("agentint.%s.rotatecreds", *a.md.VmID)
+	_, err = a.nc.Subscribe(rotateSubject, a.handleRotateCredentials)
+	if err != nil {
+		a.LogError(fmt.Sprintf("failed to subscribe to credentials rotation subject: %s", err))
+	}
+
+	hotReloadSubject := fmt.Sprintf("agentint.%s.hotreload", *a.md.VmID)
+	_, err = a.nc.Subscribe(hotReloadSubject, a.handleHotReload)
+	if err != nil {
+		a.LogError(fmt.Sprintf("failed to subscribe to hot reload subject: %s", err))
+	}
+
 	go a.dispatchEvents()
 	go a.dispatchLogs()
 
@@ -392,10 +663,18 @@ func (a *Agent) initNATS() error {
 	}
 
 	pk, _ := pair.PublicKey()
+	a.natsKeyPair.Store(pair)
 	a.nc, err = nats.Connect(url, nats.Nkey(pk, func(b []byte) ([]byte, error) {
-		fmt.Fprintf(os.Stdout, "Attempting to sign NATS server nonce for internal NATS connection; public key: %s", pk)
-		return pair.Sign(b)
-	}))
+		current := a.natsKeyPair.Load().(nkeys.KeyPair)
+		currentPk, _ := current.PublicKey()
+		fmt.Fprintf(os.Stdout, "Attempting to sign NATS server nonce for internal NATS connection; public key: %s", currentPk)
+		return current.Sign(b)
+	}),
+		// a machine restored from a checkpoint finds its connection gone and must reconnect, this
+		// time to the node that restored it, however long its network takes to come back
+		nats.MaxReconnects(-1),
+		nats.PingInterval(agentNATSPingInterval),
+	)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to connect to shared NATS: %s", err)
 		return err
@@ -435,10 +714,23 @@ func (a *Agent) newExecutionProviderParams(req *agentapi.DeployRequest, tmpFile
 		return nil, errors.New("workload name is required to initialize execution provider params")
 	}
 
+	stderr := newLogEmitter(*req.WorkloadName, true, a.agentLogs)
+	stdout := newLogEmitter(*req.WorkloadName, false, a.agentLogs)
+
+	var workloadStderr, workloadStdout io.Writer = stderr, stdout
+
+	// jobs report the tail of their output once they complete
+	var output *outputTail
+	if req.WorkloadType == controlapi.NexWorkloadJob {
+		output = newOutputTail(jobOutputTailBytes)
+		workloadStderr = io.MultiWriter(stderr, output)
+		workloadStdout = io.MultiWriter(stdout, output)
+	}
+
 	params := &agentapi.ExecutionProviderParams{
 		DeployRequest: *req,
-		Stderr:        &logEmitter{stderr: true, name: *req.WorkloadName, logs: a.agentLogs},
-		Stdout:        &logEmitter{stderr: false, name: *req.WorkloadName, logs: a.agentLogs},
+		Stderr:        workloadStderr,
+		Stdout:        workloadStdout,
 		TmpFilename:   &tmpFile,
 		VmID:          *a.md.VmID,
 
@@ -447,15 +739,28 @@ func (a *Agent) newExecutionProviderParams(req *agentapi.DeployRequest, tmpFile
 		Exit: make(chan int),
 
 		NATSConn:        a.nc,
+		TriggerCipher:   a.cipher,
 		TriggerSubjects: req.TriggerSubjects,
 	}
 
+	exited := make(chan struct{})
+	if reportsWorkloadExit(req.WorkloadType) {
+		a.workloadExited = exited
+	}
+
 	go func() {
+		defer close(exited)
+
 		sleepMillis := agentapi.DefaultRunloopSleepTimeoutMillis
+		startedAt := time.Now().UTC()
 
 		for {
 			select {
 			case <-params.Fail:
+				if output != nil {
+					a.PublishJobCompleted(params.VmID, *params.WorkloadName, -1, output.String(), startedAt)
+				}
+
 				msg := fmt.Sprintf("Failed to start workload: %s; vm: %s", *params.WorkloadName, params.VmID)
 				a.PublishWorkloadExited(params.VmID, *params.WorkloadName, msg, true, -1)
 				return
@@ -463,8 +768,16 @@ func (a *Agent) newExecutionProviderParams(req *agentapi.DeployRequest, tmpFile
 			case <-params.Run:
 				a.PublishWorkloadDeployed(params.VmID, *params.WorkloadName, params.TotalBytes)
 				sleepMillis = workloadExecutionSleepTimeoutMillis
+				startedAt = time.Now().UTC()
 
 			case exit := <-params.Exit:
+				stdout.Flush()
+				stderr.Flush()
+
+				if output != nil {
+					a.PublishJobCompleted(params.VmID, *params.WorkloadName, exit, output.String(), startedAt)
+				}
+
 				msg := fmt.Sprintf("Exited workload: %s; vm: %s; status: %d", *params.WorkloadName, params.VmID, exit)
 				a.PublishWorkloadExited(params.VmID, *params.WorkloadName, msg, exit != 0, exit)
 				return
@@ -479,6 +792,13 @@ func (a *Agent) newExecutionProviderParams(req *agentapi.DeployRequest, tmpFile
 	return params, nil
 }
 
+// Function providers never report an exit; every other provider runs its workload as a process
+func reportsWorkloadExit(workloadType controlapi.NexWorkload) bool {
+	return workloadType != controlapi.NexWorkloadV8 &&
+		workloadType != controlapi.NexWorkloadWasm &&
+		workloadType != controlapi.NexWorkloadOCI
+}
+
 func (a *Agent) setNameservers() error {
 	if a.md.Nameserver == nil {
 		return errors.New("no nameserver included in metadata")
@@ -529,7 +849,7 @@ func (a *Agent) shuttingDown() bool {
 
 func (a *Agent) submitLog(msg string, lvl agentapi.LogLevel) {
 	a.agentLogs <- &agentapi.LogEntry{
-		Source: NexEventSourceNexAgent,
+		Source: agentapi.LogSourceRuntime,
 		Level:  lvl,
 		Text:   msg,
 	}
@@ -538,11 +858,23 @@ func (a *Agent) submitLog(msg string, lvl agentapi.LogLevel) {
 // workAck ACKs the provided NATS message by responding with the
 // accepted status of the attempted work request and associated message
 func (a *Agent) workAck(m *nats.Msg, accepted bool, msg string) error {
-	ack := agentapi.DeployResponse{
+	return a.respondDeploy(m, agentapi.DeployResponse{
 		Accepted: accepted,
 		Message:  models.StringOrNil(msg),
-	}
+	})
+}
+
+// workReject rejects the attempted work request, giving the reason and an
+// associated message
+func (a *Agent) workReject(m *nats.Msg, reason controlapi.DeployRejectionReason, msg string) error {
+	return a.respondDeploy(m, agentapi.DeployResponse{
+		Accepted: false,
+		Message:  models.StringOrNil(msg),
+		Reason:   reason,
+	})
+}
 
+func (a *Agent) respondDeploy(m *nats.Msg, ack agentapi.DeployResponse) error {
 	bytes, err := json.Marshal(&ack)
 	if err != nil {
 		return err
@@ -1,9 +1,12 @@
 package nexagent
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
@@ -24,6 +27,52 @@ func HaltVM(err error) {
 	}
 }
 
+// Mounts the ext4 filesystem on the given block device at the given path
+func mountBlockDevice(device string, path string) error {
+	return syscall.Mount(device, path, "ext4", 0, "")
+}
+
+// Syncs and unmounts the filesystem mounted at the given path
+func unmountBlockDevice(path string) error {
+	syscall.Sync()
+	return syscall.Unmount(path, 0)
+}
+
 func resetSIGUSR() {
 	signal.Reset(syscall.SIGUSR1, syscall.SIGUSR2)
 }
+
+// Returns the total and available memory of the machine in bytes, as reported by /proc/meminfo
+func readMemory() (uint64, uint64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var total, available uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		// values are reported in kB
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch fields[0] {
+		case "MemTotal:":
+			total = value * 1024
+		case "MemAvailable:":
+			available = value * 1024
+		}
+	}
+
+	return total, available, scanner.Err()
+}
@@ -3,6 +3,7 @@
 package nexagent
 
 import (
+	"errors"
 	"fmt"
 	"os"
 )
@@ -17,4 +18,16 @@ func HaltVM(err error) {
 	os.Exit(code)
 }
 
+func mountBlockDevice(string, string) error {
+	return errors.New("volumes are only supported on linux")
+}
+
+func unmountBlockDevice(string) error {
+	return errors.New("volumes are only supported on linux")
+}
+
 func resetSIGUSR() {}
+
+func readMemory() (uint64, uint64, error) {
+	return 0, 0, errors.New("memory reporting is only supported on linux")
+}
@@ -0,0 +1,337 @@
+// Package agenttest provides an in-process stand-in for the nex agent. It speaks the agent
+// protocol to a node over the node's internal NATS server, completing the handshake and answering
+// deploy, undeploy, ping, credentials rotation and trigger requests, but runs no workloads. Nodes
+// configured with in-memory agents use it in place of nex-agent processes or Firecracker VMs, so
+// that the node, and control API clients talking to it, can be tested without root privileges or
+// agent binaries
+package agenttest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+// Version the agent reports in its handshake
+const Version = "0.0.0-agenttest"
+
+const handshakeTimeout = 5 * time.Second
+
+// Decides how an agent treats the workloads deployed to it
+type Options struct {
+	// Accepts or rejects the deployment of the named workload of the given type; nil accepts
+	// every deployment
+	Deploy func(name string, workloadType string) error
+
+	// Executes a function trigger received on the given subject. Returning an error leaves the
+	// trigger unanswered, as a failed function would; nil echoes each payload
+	Trigger func(subject string, payload []byte) ([]byte, error)
+}
+
+// An in-process agent serving a single workload, like the agent in a nex-agent process or VM
+type Agent struct {
+	id   string
+	opts Options
+
+	nc      *nats.Conn
+	keyPair atomic.Value
+	xkp     nkeys.KeyPair
+	cipher  *agentapi.PayloadCipher
+	started time.Time
+
+	mutex    sync.Mutex
+	stopped  bool
+	deployed *agentapi.DeployRequest
+	trigger  *nats.Subscription
+}
+
+// Creates an agent that will serve the workload with the given ID once started
+func New(id string, opts Options) *Agent {
+	return &Agent{
+		id:   id,
+		opts: opts,
+	}
+}
+
+func (a *Agent) ID() string {
+	return a.id
+}
+
+// Connects to the node's internal NATS server at the given URL with the nkey seed the node
+// issued for this agent, subscribes to the agent's subjects and completes the handshake
+func (a *Agent) Start(url string, seed string) error {
+	pair, err := nkeys.FromSeed([]byte(seed))
+	if err != nil {
+		return fmt.Errorf("invalid nkey seed: %w", err)
+	}
+	a.keyPair.Store(pair)
+
+	pk, err := pair.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	a.started = time.Now().UTC()
+	nc, err := nats.Connect(url, nats.Nkey(pk, func(nonce []byte) ([]byte, error) {
+		return a.keyPair.Load().(nkeys.KeyPair).Sign(nonce)
+	}), nats.MaxReconnects(-1))
+	if err != nil {
+		return fmt.Errorf("failed to connect to internal NATS: %w", err)
+	}
+
+	a.mutex.Lock()
+	if a.stopped {
+		a.mutex.Unlock()
+		nc.Close()
+		return errors.New("agent was stopped before it started")
+	}
+	a.nc = nc
+	a.mutex.Unlock()
+
+	handlers := map[string]nats.MsgHandler{
+		"deploy":      a.handleDeploy,
+		"undeploy":    a.handleUndeploy,
+		"ping":        a.handlePing,
+		"rotatecreds": a.handleRotateCredentials,
+		"hotreload":   a.handleHotReload,
+	}
+	for name, handler := range handlers {
+		_, err = a.nc.Subscribe(fmt.Sprintf("agentint.%s.%s", a.id, name), handler)
+		if err != nil {
+			a.nc.Close()
+			return fmt.Errorf("failed to subscribe to %s requests: %w", name, err)
+		}
+	}
+
+	err = a.requestHandshake()
+	if err != nil {
+		a.nc.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Disconnects the agent, which the node sees as the agent going away. An agent stopped before it
+// starts never connects
+func (a *Agent) Stop() error {
+	a.mutex.Lock()
+	a.stopped = true
+	nc := a.nc
+	a.mutex.Unlock()
+
+	if nc == nil {
+		return nil
+	}
+
+	err := nc.Drain()
+	if err != nil {
+		nc.Close()
+	}
+
+	return err
+}
+
+// Returns the name and type of the deployed workload, if any
+func (a *Agent) Deployed() (string, string, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.deployed == nil {
+		return "", "", false
+	}
+
+	return *a.deployed.WorkloadName, string(a.deployed.WorkloadType), true
+}
+
+func (a *Agent) requestHandshake() error {
+	var err error
+	a.xkp, err = nkeys.CreateCurveKeys()
+	if err != nil {
+		return fmt.Errorf("failed to create xkey: %w", err)
+	}
+	xkey, _ := a.xkp.PublicKey()
+
+	msg := "In-memory agent started"
+	raw, _ := json.Marshal(agentapi.HandshakeRequest{
+		ID:              &a.id,
+		StartTime:       a.started,
+		Message:         &msg,
+		AgentVersion:    Version,
+		ProtocolVersion: agentapi.AgentProtocolVersion,
+		XKey:            xkey,
+	})
+
+	subject := fmt.Sprintf("hostint.%s.handshake", a.id)
+	resp, err := a.nc.Request(subject, raw, handshakeTimeout)
+	if errors.Is(err, nats.ErrNoResponders) {
+		time.Sleep(50 * time.Millisecond)
+		resp, err = a.nc.Request(subject, raw, handshakeTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to handshake with node: %w", err)
+	}
+
+	var response agentapi.HandshakeResponse
+	err = json.Unmarshal(resp.Data, &response)
+	if err != nil {
+		return fmt.Errorf("failed to parse handshake response: %w", err)
+	}
+
+	if response.ProtocolVersion > 0 && !response.Compatible {
+		return errors.New("node rejected the agent's protocol version")
+	}
+
+	if response.XKey != "" {
+		a.cipher = agentapi.NewPayloadCipher(a.xkp, response.XKey)
+	}
+
+	return nil
+}
+
+func (a *Agent) handleDeploy(m *nats.Msg) {
+	data, err := a.cipher.Open(m.Data)
+	if err != nil {
+		a.reject(m, controlapi.DeployRejectionValidationFailed, fmt.Sprintf("Failed to open deploy request: %s", err))
+		return
+	}
+
+	var request agentapi.DeployRequest
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		a.reject(m, controlapi.DeployRejectionValidationFailed, fmt.Sprintf("Failed to unmarshal deploy request: %s", err))
+		return
+	}
+
+	err = request.Validate()
+	if err != nil {
+		a.reject(m, controlapi.DeployRejectionValidationFailed, err.Error())
+		return
+	}
+
+	if a.opts.Deploy != nil {
+		err = a.opts.Deploy(*request.WorkloadName, string(request.WorkloadType))
+		if err != nil {
+			a.reject(m, controlapi.DeployRejectionFailed, err.Error())
+			return
+		}
+	}
+
+	sub, err := a.nc.Subscribe(fmt.Sprintf("agentint.%s.trigger", a.id), a.handleTrigger)
+	if err != nil {
+		a.reject(m, controlapi.DeployRejectionFailed, fmt.Sprintf("Failed to subscribe to triggers: %s", err))
+		return
+	}
+
+	a.mutex.Lock()
+	a.deployed = &request
+	a.trigger = sub
+	a.mutex.Unlock()
+
+	msg := "Workload deployed"
+	a.respond(m, agentapi.DeployResponse{Accepted: true, Message: &msg})
+}
+
+func (a *Agent) handleUndeploy(m *nats.Msg) {
+	a.mutex.Lock()
+	deployed := a.deployed != nil
+	if a.trigger != nil {
+		_ = a.trigger.Unsubscribe()
+		a.trigger = nil
+	}
+	a.deployed = nil
+	a.mutex.Unlock()
+
+	if !deployed {
+		_ = m.Respond([]byte{})
+		return
+	}
+
+	raw, _ := json.Marshal(&agentapi.UndeployResponse{Exited: true})
+	_ = m.Respond(raw)
+}
+
+func (a *Agent) handlePing(m *nats.Msg) {
+	_ = m.Respond([]byte("OK"))
+}
+
+func (a *Agent) handleRotateCredentials(m *nats.Msg) {
+	data, err := a.cipher.Open(m.Data)
+	if err != nil {
+		_ = m.Respond([]byte{})
+		return
+	}
+
+	var request agentapi.CredentialsRotationRequest
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		_ = m.Respond([]byte{})
+		return
+	}
+
+	pair, err := nkeys.FromSeed([]byte(request.NkeySeed))
+	if err != nil {
+		_ = m.Respond([]byte{})
+		return
+	}
+	pk, _ := pair.PublicKey()
+
+	// the new key is only used once the node revokes the previous one and the client reconnects
+	a.keyPair.Store(pair)
+	a.nc.Opts.Nkey = pk
+
+	raw, _ := json.Marshal(&agentapi.CredentialsRotationResponse{Rotated: true})
+	_ = m.Respond(raw)
+}
+
+func (a *Agent) handleHotReload(m *nats.Msg) {
+	msg := "in-memory agents do not support hot reload"
+	raw, _ := json.Marshal(&agentapi.HotReloadResponse{Reloaded: false, Message: &msg})
+	_ = m.Respond(raw)
+}
+
+func (a *Agent) handleTrigger(m *nats.Msg) {
+	md := agentapi.TriggerMetadataFromMsg(m)
+
+	payload, err := a.cipher.Open(m.Data)
+	if err != nil {
+		return
+	}
+
+	started := time.Now()
+	result := payload
+	if a.opts.Trigger != nil {
+		result, err = a.opts.Trigger(md.Subject, payload)
+		if err != nil {
+			return
+		}
+	}
+	elapsed := time.Since(started)
+
+	sealed, err := a.cipher.Seal(result)
+	if err != nil {
+		return
+	}
+
+	header := nats.Header{agentapi.NexRuntimeNs: []string{strconv.FormatInt(elapsed.Nanoseconds(), 10)}}
+	agentapi.EncodeResponseHeaders(md, header)
+	_ = m.RespondMsg(&nats.Msg{Data: sealed, Header: header})
+}
+
+func (a *Agent) reject(m *nats.Msg, reason controlapi.DeployRejectionReason, msg string) {
+	a.respond(m, agentapi.DeployResponse{Accepted: false, Message: &msg, Reason: reason})
+}
+
+func (a *Agent) respond(m *nats.Msg, response agentapi.DeployResponse) {
+	raw, _ := json.Marshal(&response)
+	_ = m.Respond(raw)
+}
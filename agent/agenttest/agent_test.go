@@ -0,0 +1,177 @@
+package agenttest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	internalnats "github.com/synadia-io/nex/internal/node/internal-nats"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Starts an agent against an internal NATS server, returning the node's client for it once the
+// handshake completes
+func startTestAgent(t *testing.T, opts Options) (*Agent, *agentapi.AgentClient) {
+	server, err := internalnats.NewInternalNatsServer(slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to create internal nats server: %s", err)
+	}
+	t.Cleanup(server.Shutdown)
+
+	id := nuid.Next()
+	kp, err := server.CreateCredentials(id)
+	if err != nil {
+		t.Fatalf("Failed to create agent credentials: %s", err)
+	}
+	seed, _ := kp.Seed()
+
+	nc, err := server.ConnectionWithID(id)
+	if err != nil {
+		t.Fatalf("Failed to connect as the node: %s", err)
+	}
+	t.Cleanup(nc.Close)
+
+	handshook := make(chan string, 1)
+	client := agentapi.NewAgentClient(nc, slog.Default(), 5*time.Second, time.Second,
+		func(string) {},
+		func(id string) { handshook <- id },
+		func(string) {},
+		nil, nil,
+	)
+	err = client.Start(id)
+	if err != nil {
+		t.Fatalf("Failed to start agent client: %s", err)
+	}
+	t.Cleanup(func() { _ = client.Stop() })
+
+	agent := New(id, opts)
+	err = agent.Start(server.ClientURL(), string(seed))
+	if err != nil {
+		t.Fatalf("Failed to start agent: %s", err)
+	}
+	t.Cleanup(func() { _ = agent.Stop() })
+
+	select {
+	case <-handshook:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the agent to complete its handshake")
+	}
+
+	return agent, client
+}
+
+func testDeployRequest(name string) *agentapi.DeployRequest {
+	namespace := "default"
+	return &agentapi.DeployRequest{
+		Namespace:       &namespace,
+		WorkloadName:    &name,
+		WorkloadType:    controlapi.NexWorkloadV8,
+		Hash:            "abc123",
+		TotalBytes:      1024,
+		TriggerSubjects: []string{"orders.*"},
+	}
+}
+
+func TestAgentDeploysAndServesTriggers(t *testing.T) {
+	agent, client := startTestAgent(t, Options{
+		Trigger: func(subject string, payload []byte) ([]byte, error) {
+			return bytes.ToUpper(payload), nil
+		},
+	})
+
+	if client.Cipher() == nil {
+		t.Fatal("Expected the agent to agree on payload encryption")
+	}
+
+	response, err := client.DeployWorkload(testDeployRequest("echo"))
+	if err != nil {
+		t.Fatalf("Failed to deploy workload: %s", err)
+	}
+	if !response.Accepted {
+		t.Fatalf("Expected the deployment to be accepted: %s", *response.Message)
+	}
+
+	name, workloadType, ok := agent.Deployed()
+	if !ok || name != "echo" || workloadType != string(controlapi.NexWorkloadV8) {
+		t.Fatalf("Expected the agent to report the deployed workload, got %q %q %v", name, workloadType, ok)
+	}
+
+	msg := nats.NewMsg("orders.created")
+	msg.Data = []byte("hello")
+	reply, err := client.RunTrigger(context.Background(), noop.NewTracerProvider().Tracer("test"), msg)
+	if err != nil {
+		t.Fatalf("Failed to run trigger: %s", err)
+	}
+	if string(reply.Data) != "HELLO" {
+		t.Fatalf("Expected the trigger reply to be HELLO, got %q", reply.Data)
+	}
+	if reply.Header.Get(agentapi.NexRuntimeNs) == "" {
+		t.Fatal("Expected the trigger reply to carry its runtime")
+	}
+
+	exited, err := client.UndeployWithGracePeriod(time.Second)
+	if err != nil || !exited {
+		t.Fatalf("Expected the workload to be undeployed, got %v: %v", exited, err)
+	}
+	if _, _, ok := agent.Deployed(); ok {
+		t.Fatal("Expected no workload to be deployed after undeploying")
+	}
+}
+
+func TestAgentRejectsDeployments(t *testing.T) {
+	_, client := startTestAgent(t, Options{
+		Deploy: func(name string, workloadType string) error {
+			return errors.New("no capacity")
+		},
+	})
+
+	response, err := client.DeployWorkload(testDeployRequest("echo"))
+	if err != nil {
+		t.Fatalf("Failed to deploy workload: %s", err)
+	}
+	if response.Accepted || response.Reason != controlapi.DeployRejectionFailed {
+		t.Fatalf("Expected the deployment to be rejected as failed, got %+v", response)
+	}
+
+	invalid := testDeployRequest("echo")
+	invalid.Hash = ""
+	response, err = client.DeployWorkload(invalid)
+	if err != nil {
+		t.Fatalf("Failed to deploy workload: %s", err)
+	}
+	if response.Accepted || response.Reason != controlapi.DeployRejectionValidationFailed {
+		t.Fatalf("Expected an invalid deployment to fail validation, got %+v", response)
+	}
+}
+
+func TestAgentLeavesFailedTriggersUnansweredUntilDeadline(t *testing.T) {
+	_, client := startTestAgent(t, Options{
+		Trigger: func(subject string, payload []byte) ([]byte, error) {
+			return nil, errors.New("model not found")
+		},
+	})
+
+	response, err := client.DeployWorkload(testDeployRequest("echo"))
+	if err != nil || !response.Accepted {
+		t.Fatalf("Expected the deployment to be accepted, got %+v: %v", response, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err = client.RunTrigger(ctx, noop.NewTracerProvider().Tracer("test"), nats.NewMsg(controlapi.DefaultWorkloadInitSubject))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the failed trigger to time out at the context's deadline, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("Expected the context's deadline to replace the default trigger timeout, waited %s", elapsed)
+	}
+}
@@ -0,0 +1,51 @@
+package nexagent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+// Retrieves the input payload the node cached for the workload, writing it to the requested path
+// or otherwise returning it as a reader to be piped to the workload's stdin
+func (a *Agent) deliverWorkloadInput(req *agentapi.DeployRequest) (io.Reader, error) {
+	if req.Input == nil {
+		return nil, nil
+	}
+
+	data, err := a.cacheBucket.GetBytes(agentapi.WorkloadInputCacheKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workload input from cache: %s", err)
+	}
+
+	data, err = a.cipher.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workload input: %s", err)
+	}
+
+	if req.Input.Path == "" {
+		return bytes.NewReader(data), nil
+	}
+
+	if !a.sandboxed {
+		return nil, errors.New("input paths are only supported in sandboxed agents")
+	}
+
+	err = os.MkdirAll(filepath.Dir(req.Input.Path), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory for workload input: %s", err)
+	}
+
+	err = os.WriteFile(req.Input.Path, data, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write workload input: %s", err)
+	}
+
+	a.LogDebug(fmt.Sprintf("Wrote %d byte(s) of workload input to %s", len(data), req.Input.Path))
+	return nil, nil
+}
@@ -0,0 +1,49 @@
+package nexagent
+
+import (
+	"sync"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+// Bytes of a job's combined output reported when it completes
+const jobOutputTailBytes = 4096
+
+// Job workloads are native binaries run once to completion
+func isNativeBinary(workloadType controlapi.NexWorkload) bool {
+	return workloadType == controlapi.NexWorkloadNative || workloadType == controlapi.NexWorkloadJob
+}
+
+// Retains the last bytes written to it, discarding the oldest once full
+type outputTail struct {
+	mutex sync.Mutex
+	size  int
+	buf   []byte
+}
+
+func newOutputTail(size int) *outputTail {
+	return &outputTail{size: size, buf: make([]byte, 0, size)}
+}
+
+func (t *outputTail) Write(p []byte) (int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	written := len(p)
+	if len(p) >= t.size {
+		p = p[len(p)-t.size:]
+		t.buf = t.buf[:0]
+	} else if overflow := len(t.buf) + len(p) - t.size; overflow > 0 {
+		t.buf = append(t.buf[:0], t.buf[overflow:]...)
+	}
+	t.buf = append(t.buf, p...)
+
+	return written, nil
+}
+
+func (t *outputTail) String() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return string(t.buf)
+}
@@ -1,40 +1,183 @@
 package nexagent
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
+	"sync"
+	"time"
 
 	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/events"
 )
 
 const NexEventSourceNexAgent = "nex-agent"
 
+const (
+	// maximum sustained rate and burst, in lines per second, at which a single workload
+	// output stream is forwarded to the host node
+	workloadLogLinesPerSecond = 100
+	workloadLogLineBurst      = 500
+
+	// lines longer than this are emitted in chunks rather than buffered indefinitely
+	workloadLogMaxLineBytes = 16 * 1024
+)
+
 // logEmitter implements the writer interface that allows us to capture a workload's
-// stdout and stderr so that we can then publish those logs to the host node
+// stdout and stderr so that we can then publish those logs to the host node. Output is
+// split into lines and forwarded subject to a per-stream rate limit
 type logEmitter struct {
 	name   string
 	stderr bool
 
 	logs chan *agentapi.LogEntry
+
+	mutex   sync.Mutex
+	buf     []byte
+	tokens  float64
+	last    time.Time
+	dropped int
+}
+
+func newLogEmitter(name string, stderr bool, logs chan *agentapi.LogEntry) *logEmitter {
+	return &logEmitter{
+		name:   name,
+		stderr: stderr,
+		logs:   logs,
+		tokens: workloadLogLineBurst,
+		last:   time.Now(),
+	}
 }
 
 // Write arbitrary bytes to the underlying log emitter
-func (l *logEmitter) Write(bytes []byte) (int, error) {
-	var lvl agentapi.LogLevel
+func (l *logEmitter) Write(raw []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.buf = append(l.buf, raw...)
+	for {
+		idx := bytes.IndexByte(l.buf, '\n')
+		if idx == -1 {
+			break
+		}
+
+		l.emit(bytes.TrimSuffix(l.buf[:idx], []byte("\r")))
+		l.buf = l.buf[idx+1:]
+	}
+
+	for len(l.buf) >= workloadLogMaxLineBytes {
+		l.emit(l.buf[:workloadLogMaxLineBytes])
+		l.buf = l.buf[workloadLogMaxLineBytes:]
+	}
+
+	// FIXME-- this never returns an error
+	return len(raw), nil
+}
+
+// Flush emits any buffered partial line along with a notice of lines dropped due to
+// rate limiting. Called once the workload process has exited
+func (l *logEmitter) Flush() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.buf) > 0 {
+		l.emit(l.buf)
+		l.buf = nil
+	}
+
+	l.reportDropped()
+}
+
+func (l *logEmitter) emit(line []byte) {
+	if !l.allow() {
+		l.dropped++
+		return
+	}
+	l.reportDropped()
+
+	entry := &agentapi.LogEntry{
+		Level:  agentapi.LogLevelInfo,
+		Source: agentapi.LogSourceStdout,
+		Text:   string(line),
+	}
+
 	if l.stderr {
-		lvl = agentapi.LogLevelError
-	} else {
-		lvl = agentapi.LogLevelInfo
+		entry.Level = agentapi.LogLevelError
+		entry.Source = agentapi.LogSourceStderr
+	}
+
+	parseStructuredLog(line, entry)
+	l.logs <- entry
+}
+
+// Refills the token bucket based on elapsed time and consumes a token if one is available
+func (l *logEmitter) allow() bool {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * workloadLogLinesPerSecond
+	if l.tokens > workloadLogLineBurst {
+		l.tokens = workloadLogLineBurst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+	return true
+}
+
+func (l *logEmitter) reportDropped() {
+	if l.dropped == 0 {
+		return
+	}
+
+	source := agentapi.LogSourceStdout
+	if l.stderr {
+		source = agentapi.LogSourceStderr
 	}
 
 	l.logs <- &agentapi.LogEntry{
-		Level:  lvl,
-		Source: l.name,
-		Text:   string(bytes),
+		Source: agentapi.LogSourceRuntime,
+		Level:  agentapi.LogLevelWarn,
+		Text:   fmt.Sprintf("Dropped %d lines of %s output from workload %s due to rate limiting", l.dropped, source, l.name),
+		Fields: map[string]interface{}{"workload_name": l.name, "stream": source, "dropped": l.dropped},
 	}
+	l.dropped = 0
+}
 
-	// FIXME-- this never returns an error
-	return len(bytes), nil
+// If the workload wrote a single JSON object (as emitted by most structured loggers), lift its
+// message and level onto the log entry and carry the remaining keys along as fields
+func parseStructuredLog(raw []byte, entry *agentapi.LogEntry) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(trimmed, &fields); err != nil {
+		return
+	}
+
+	for _, key := range []string{"msg", "message"} {
+		if msg, ok := fields[key].(string); ok {
+			entry.Text = msg
+			delete(fields, key)
+			break
+		}
+	}
+
+	if lvl, ok := fields["level"].(string); ok {
+		if level, ok := agentapi.ParseLogLevel(lvl); ok {
+			entry.Level = level
+			delete(fields, "level")
+		}
+	}
+
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
 }
 
 func (a *Agent) LogDebug(msg string) {
@@ -61,12 +204,13 @@ func (a *Agent) LogInfo(msg string) {
 // FIXME-- revisit error handling
 func (a *Agent) PublishWorkloadDeployed(vmID, workloadName string, totalBytes int64) {
 	a.agentLogs <- &agentapi.LogEntry{
-		Source: NexEventSourceNexAgent,
+		Source: agentapi.LogSourceRuntime,
 		Level:  agentapi.LogLevelInfo,
 		Text:   fmt.Sprintf("Workload %s deployed", workloadName),
+		Fields: map[string]interface{}{"workload_name": workloadName, "total_bytes": totalBytes},
 	}
 
-	evt := agentapi.NewAgentEvent(vmID, agentapi.WorkloadDeployedEventType, agentapi.WorkloadStatusEvent{WorkloadName: workloadName})
+	evt := events.WorkloadDeployed(vmID, agentapi.WorkloadStatusEvent{WorkloadName: workloadName})
 	a.eventLogs <- &evt
 }
 
@@ -85,11 +229,24 @@ func (a *Agent) PublishWorkloadExited(vmID, workloadName, message string, err bo
 	}
 
 	a.agentLogs <- &agentapi.LogEntry{
-		Source: NexEventSourceNexAgent,
+		Source: agentapi.LogSourceRuntime,
 		Level:  agentapi.LogLevel(level),
 		Text:   txt,
+		Fields: map[string]interface{}{"workload_name": workloadName, "code": code},
 	}
 
-	evt := agentapi.NewAgentEvent(vmID, agentapi.WorkloadUndeployedEventType, agentapi.WorkloadStatusEvent{WorkloadName: workloadName, Code: code, Message: message})
+	evt := events.WorkloadUndeployed(vmID, agentapi.WorkloadStatusEvent{WorkloadName: workloadName, Code: code, Message: message})
+	a.eventLogs <- &evt
+}
+
+// PublishJobCompleted publishes the exit status and final output of a job workload
+func (a *Agent) PublishJobCompleted(vmID, workloadName string, code int, output string, startedAt time.Time) {
+	evt := events.JobCompleted(vmID, agentapi.JobCompletedEvent{
+		WorkloadName: workloadName,
+		ExitCode:     code,
+		Output:       output,
+		StartedAt:    startedAt,
+		CompletedAt:  time.Now().UTC(),
+	})
 	a.eventLogs <- &evt
 }
@@ -0,0 +1,98 @@
+package nexagent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+// Writes each object the node cached for the workload's mounts to its path, read-only, after
+// verifying it against the digest computed by the node
+func (a *Agent) materializeMounts(req *agentapi.DeployRequest) error {
+	if len(req.Mounts) == 0 {
+		return nil
+	}
+
+	if !a.sandboxed {
+		return errors.New("workload mounts are only supported in sandboxed agents")
+	}
+
+	for _, mount := range req.Mounts {
+		data, err := a.cacheBucket.GetBytes(mount.Key)
+		if err != nil {
+			return fmt.Errorf("failed to get mount %s from cache: %s", mount.Path, err)
+		}
+
+		data, err = a.cipher.Open(data)
+		if err != nil {
+			return fmt.Errorf("failed to open mount %s: %s", mount.Path, err)
+		}
+
+		digest := sha256.Sum256(data)
+		if !strings.EqualFold(hex.EncodeToString(digest[:]), mount.Sha256) {
+			return fmt.Errorf("mount %s failed hash verification", mount.Path)
+		}
+
+		err = os.MkdirAll(filepath.Dir(mount.Path), 0755)
+		if err != nil {
+			return fmt.Errorf("failed to create directory for mount %s: %s", mount.Path, err)
+		}
+
+		_ = os.Remove(mount.Path)
+		err = os.WriteFile(mount.Path, data, 0444)
+		if err != nil {
+			return fmt.Errorf("failed to write mount %s: %s", mount.Path, err)
+		}
+
+		a.LogDebug(fmt.Sprintf("Mounted %d byte(s) at %s", len(data), mount.Path))
+	}
+
+	return nil
+}
+
+// Mounts the persistent volume attached to the machine at the path requested for it
+func (a *Agent) mountVolume(req *agentapi.DeployRequest) error {
+	if req.Volume == nil {
+		return nil
+	}
+
+	if !a.sandboxed {
+		return errors.New("workload volumes are only mounted in sandboxed agents")
+	}
+
+	err := os.MkdirAll(req.Volume.Path, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create directory for volume %s: %s", req.Volume.Name, err)
+	}
+
+	err = mountBlockDevice(req.Volume.Device, req.Volume.Path)
+	if err != nil {
+		return fmt.Errorf("failed to mount volume %s at %s: %s", req.Volume.Name, req.Volume.Path, err)
+	}
+
+	a.volumePath = req.Volume.Path
+	a.LogDebug(fmt.Sprintf("Mounted volume %s at %s", req.Volume.Name, req.Volume.Path))
+	return nil
+}
+
+// Unmounts the workload's volume, if it has one, so that its writes reach the node before the
+// machine is stopped
+func (a *Agent) unmountVolume() {
+	if a.volumePath == "" {
+		return
+	}
+
+	err := unmountBlockDevice(a.volumePath)
+	if err != nil {
+		a.LogError(fmt.Sprintf("Failed to unmount volume at %s: %s", a.volumePath, err))
+		return
+	}
+
+	a.volumePath = ""
+}
@@ -9,6 +9,34 @@ import (
 	agentapi "github.com/synadia-io/nex/internal/agent-api"
 )
 
+var builtinWorkloadTypes = []controlapi.NexWorkload{
+	controlapi.NexWorkloadNative,
+	controlapi.NexWorkloadV8,
+	controlapi.NexWorkloadOCI,
+	controlapi.NexWorkloadWasm,
+	controlapi.NexWorkloadJVM,
+	controlapi.NexWorkloadJob,
+}
+
+// Returns the workload types this agent can run. Built-in providers are versioned with the agent
+// itself; extensions do not report a version
+func SupportedProviders(agentVersion string) []controlapi.ProviderCapability {
+	supported := make([]controlapi.ProviderCapability, 0, len(builtinWorkloadTypes))
+	for _, workloadType := range builtinWorkloadTypes {
+		// not yet implemented
+		if workloadType == controlapi.NexWorkloadOCI {
+			continue
+		}
+		supported = append(supported, controlapi.ProviderCapability{WorkloadType: workloadType, Version: agentVersion})
+	}
+
+	for _, workloadType := range RegisteredProviders() {
+		supported = append(supported, controlapi.ProviderCapability{WorkloadType: workloadType})
+	}
+
+	return supported
+}
+
 // ExecutionProvider implementations provide support for a specific
 // execution environment pattern -- e.g., statically-linked ELF
 // binaries, serverless JavaScript functions, OCI images, Wasm, etc.
@@ -27,6 +55,14 @@ type ExecutionProvider interface {
 	Validate() error
 }
 
+// HotReloadable is implemented by execution providers that can swap the artifact of a deployed
+// workload while it runs (e.g., "v8" and "wasm" types), without redeploying it
+type HotReloadable interface {
+	// Reload the workload from the given artifact. The previous artifact remains in use if
+	// the new one is rejected
+	Reload(artifact []byte) error
+}
+
 // NewExecutionProvider initializes and returns an execution provider for a given work request
 func NewExecutionProvider(params *agentapi.ExecutionProviderParams) (ExecutionProvider, error) {
 	// if params.WorkloadType == nil {
@@ -34,7 +70,7 @@ func NewExecutionProvider(params *agentapi.ExecutionProviderParams) (ExecutionPr
 	// }
 
 	switch params.WorkloadType {
-	case controlapi.NexWorkloadNative:
+	case controlapi.NexWorkloadNative, controlapi.NexWorkloadJob:
 		return lib.InitNexExecutionProviderNative(params)
 	case controlapi.NexWorkloadV8:
 		return lib.InitNexExecutionProviderV8(params)
@@ -43,8 +79,12 @@ func NewExecutionProvider(params *agentapi.ExecutionProviderParams) (ExecutionPr
 		return nil, errors.New("oci execution provider not yet implemented")
 	case controlapi.NexWorkloadWasm:
 		return lib.InitNexExecutionProviderWasm(params)
+	case controlapi.NexWorkloadJVM:
+		return lib.InitNexExecutionProviderJVM(params)
 	default:
-		break
+		if factory, ok := lookupExtension(params.WorkloadType); ok {
+			return factory(params)
+		}
 	}
 
 	return nil, errors.New("invalid execution provider specified")
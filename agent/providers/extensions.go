@@ -0,0 +1,146 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"plugin"
+	"slices"
+	"sync"
+
+	"github.com/synadia-io/nex/agent/providers/lib"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+const (
+	// Location of the provider extensions configuration within the rootfs
+	DefaultExtensionsConfigPath = "/etc/nex/providers.json"
+
+	// Environment variable overriding the provider extensions configuration path
+	ExtensionsConfigPathEnv = "NEX_PROVIDER_EXTENSIONS"
+
+	pluginWorkloadTypeSymbol = "WorkloadType"
+	pluginFactorySymbol      = "NewExecutionProvider"
+)
+
+// ProviderFactory constructs an execution provider for a given work request
+type ProviderFactory func(params *agentapi.ExecutionProviderParams) (ExecutionProvider, error)
+
+var (
+	extensionsMutex sync.RWMutex
+	extensions      = make(map[controlapi.NexWorkload]ProviderFactory)
+)
+
+// Configures the provider extensions loaded by the agent. Go plugins must export a
+// `WorkloadType` string and a `NewExecutionProvider` ProviderFactory; external providers
+// are executables speaking the line-delimited JSON protocol described in lib/external.go
+type ExtensionsConfig struct {
+	Plugins  []string                                              `json:"plugins,omitempty"`
+	External map[controlapi.NexWorkload]lib.ExternalProviderConfig `json:"external,omitempty"`
+}
+
+// RegisterProvider adds an execution provider for a workload type that is not built into
+// the agent. Built-in workload types cannot be overridden
+func RegisterProvider(workloadType controlapi.NexWorkload, factory ProviderFactory) error {
+	if slices.Contains(builtinWorkloadTypes, workloadType) {
+		return fmt.Errorf("cannot override built-in workload type: %s", workloadType)
+	}
+
+	extensionsMutex.Lock()
+	defer extensionsMutex.Unlock()
+
+	if _, ok := extensions[workloadType]; ok {
+		return fmt.Errorf("provider already registered for workload type: %s", workloadType)
+	}
+
+	extensions[workloadType] = factory
+	return nil
+}
+
+// Returns the workload types provided by registered extensions
+func RegisteredProviders() []controlapi.NexWorkload {
+	extensionsMutex.RLock()
+	defer extensionsMutex.RUnlock()
+
+	workloadTypes := make([]controlapi.NexWorkload, 0, len(extensions))
+	for workloadType := range extensions {
+		workloadTypes = append(workloadTypes, workloadType)
+	}
+	slices.Sort(workloadTypes)
+
+	return workloadTypes
+}
+
+// Loads the provider extensions named in the configuration file at the given path. A missing
+// configuration file is not an error, as most agents run without extensions
+func LoadExtensions(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read provider extensions configuration: %s", err)
+	}
+
+	var config ExtensionsConfig
+	err = json.Unmarshal(raw, &config)
+	if err != nil {
+		return fmt.Errorf("failed to parse provider extensions configuration: %s", err)
+	}
+
+	for _, path := range config.Plugins {
+		err = loadPlugin(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	for workloadType, external := range config.External {
+		external := external
+		err = RegisterProvider(workloadType, func(params *agentapi.ExecutionProviderParams) (ExecutionProvider, error) {
+			return lib.InitNexExecutionProviderExternal(params, external)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func loadPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open provider plugin %s: %s", path, err)
+	}
+
+	sym, err := p.Lookup(pluginWorkloadTypeSymbol)
+	if err != nil {
+		return fmt.Errorf("provider plugin %s does not export %s: %s", path, pluginWorkloadTypeSymbol, err)
+	}
+	workloadType, ok := sym.(*string)
+	if !ok {
+		return fmt.Errorf("provider plugin %s exports %s with unexpected type %T", path, pluginWorkloadTypeSymbol, sym)
+	}
+
+	sym, err = p.Lookup(pluginFactorySymbol)
+	if err != nil {
+		return fmt.Errorf("provider plugin %s does not export %s: %s", path, pluginFactorySymbol, err)
+	}
+	factory, ok := sym.(func(*agentapi.ExecutionProviderParams) (ExecutionProvider, error))
+	if !ok {
+		return fmt.Errorf("provider plugin %s exports %s with unexpected type %T", path, pluginFactorySymbol, sym)
+	}
+
+	return RegisterProvider(controlapi.NexWorkload(*workloadType), factory)
+}
+
+func lookupExtension(workloadType controlapi.NexWorkload) (ProviderFactory, bool) {
+	extensionsMutex.RLock()
+	defer extensionsMutex.RUnlock()
+
+	factory, ok := extensions[workloadType]
+	return factory, ok
+}
@@ -0,0 +1,101 @@
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/synadia-io/nex/agent/providers/lib"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+func TestRegisterProviderRejectsBuiltinsAndDuplicates(t *testing.T) {
+	factory := func(*agentapi.ExecutionProviderParams) (ExecutionProvider, error) {
+		return nil, nil
+	}
+
+	err := RegisterProvider(controlapi.NexWorkloadNative, factory)
+	if err == nil {
+		t.Fatal("Expected a built-in workload type not to be overridden")
+	}
+
+	err = RegisterProvider("test-duplicate", factory)
+	if err != nil {
+		t.Fatalf("Failed to register provider: %s", err)
+	}
+
+	err = RegisterProvider("test-duplicate", factory)
+	if err == nil {
+		t.Fatal("Expected a second provider for the same workload type to be rejected")
+	}
+
+	if !slices.Contains(RegisteredProviders(), "test-duplicate") {
+		t.Fatalf("Expected the registered provider to be listed, got %v", RegisteredProviders())
+	}
+
+	capabilities := SupportedProviders("0.0.1")
+	for _, capability := range capabilities {
+		if capability.WorkloadType == controlapi.NexWorkloadOCI {
+			t.Fatal("Expected the unimplemented OCI provider not to be advertised")
+		}
+		if capability.WorkloadType == "test-duplicate" && capability.Version != "" {
+			t.Fatalf("Expected extensions not to report a version, got %s", capability.Version)
+		}
+	}
+}
+
+func TestLoadExtensionsRegistersExternalProviders(t *testing.T) {
+	err := LoadExtensions(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("Expected a missing configuration not to be an error: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "providers.json")
+	_ = os.WriteFile(path, []byte(`{"external": {"test-external": {"path": "/usr/bin/provider", "args": ["--stdio"]}}}`), 0600)
+
+	err = LoadExtensions(path)
+	if err != nil {
+		t.Fatalf("Failed to load extensions: %s", err)
+	}
+
+	name := "echo"
+	tmpFilename := "/tmp/echo"
+	provider, err := NewExecutionProvider(&agentapi.ExecutionProviderParams{
+		DeployRequest: agentapi.DeployRequest{
+			WorkloadName: &name,
+			WorkloadType: "test-external",
+		},
+		TmpFilename: &tmpFilename,
+	})
+	if err != nil {
+		t.Fatalf("Expected the external provider to be constructed: %s", err)
+	}
+	if _, ok := provider.(*lib.External); !ok {
+		t.Fatalf("Expected an external provider, got %T", provider)
+	}
+
+	_, err = NewExecutionProvider(&agentapi.ExecutionProviderParams{
+		DeployRequest: agentapi.DeployRequest{WorkloadType: "test-unknown"},
+	})
+	if err == nil {
+		t.Fatal("Expected an unregistered workload type to be rejected")
+	}
+}
+
+func TestLoadExtensionsRejectsInvalidConfiguration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.json")
+	_ = os.WriteFile(path, []byte(`{"plugins": [`), 0600)
+
+	err := LoadExtensions(path)
+	if err == nil {
+		t.Fatal("Expected malformed configuration to be rejected")
+	}
+
+	_ = os.WriteFile(path, []byte(`{"external": {"wasm": {"path": "/usr/bin/provider"}}}`), 0600)
+	err = LoadExtensions(path)
+	if err == nil {
+		t.Fatal("Expected an external provider for a built-in workload type to be rejected")
+	}
+}
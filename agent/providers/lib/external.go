@@ -0,0 +1,308 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const externalUndeployTimeout = 5 * time.Second
+
+// Configures an out-of-process execution provider
+type ExternalProviderConfig struct {
+	Path string   `json:"path"`
+	Args []string `json:"args,omitempty"`
+}
+
+// Message sent to an external provider process on its stdin, one JSON object per line. The
+// process answers every request with exactly one externalResponse line on its stdout, and
+// anything it writes to stderr is captured as workload output. Operations are:
+//
+//   - deploy: start the workload found at artifact_path; services keep running until undeploy
+//   - execute: run a deployed function with the given payload for the given trigger subject,
+//     along with the trigger's reply subject, message ID and headers; the response may carry
+//     headers to set on the function's reply
+//   - undeploy: stop the workload, after which the process should exit
+type externalRequest struct {
+	ID        uint64            `json:"id"`
+	Operation string            `json:"op"`
+	Workload  *externalWorkload `json:"workload,omitempty"`
+	Subject   string            `json:"subject,omitempty"`
+	Payload   []byte            `json:"payload,omitempty"`
+
+	Reply   string              `json:"reply,omitempty"`
+	MsgID   string              `json:"msg_id,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+}
+
+type externalWorkload struct {
+	Name         string            `json:"name"`
+	ArtifactPath string            `json:"artifact_path"`
+	Argv         []string          `json:"argv,omitempty"`
+	Environment  map[string]string `json:"environment,omitempty"`
+	Triggers     bool              `json:"triggers"`
+}
+
+type externalResponse struct {
+	ID      uint64              `json:"id"`
+	OK      bool                `json:"ok"`
+	Error   string              `json:"error,omitempty"`
+	Payload []byte              `json:"payload,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+}
+
+// External execution provider implementation, delegating to a provider process
+type External struct {
+	config   ExternalProviderConfig
+	workload externalWorkload
+	vmID     string
+
+	fail     chan bool
+	run      chan bool
+	exit     chan int
+	undeploy sync.Once
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+
+	mutex  sync.Mutex
+	nextID uint64
+
+	stderr io.Writer
+
+	nc     *nats.Conn // agent NATS connection
+	cipher *agentapi.PayloadCipher
+}
+
+// Deploy the workload by starting the provider process and handing it the artifact
+func (e *External) Deploy() (err error) {
+	cmd := exec.Command(e.config.Path, e.config.Args...)
+	cmd.Stderr = e.stderr
+
+	e.stdin, err = cmd.StdinPipe()
+	if err != nil {
+		e.fail <- true
+		return err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		e.fail <- true
+		return err
+	}
+	e.stdout = bufio.NewReader(stdout)
+
+	err = cmd.Start()
+	if err != nil {
+		e.fail <- true
+		return fmt.Errorf("failed to start external provider: %s", err)
+	}
+	e.cmd = cmd
+
+	_, err = e.request(&externalRequest{Operation: "deploy", Workload: &e.workload})
+	if err != nil {
+		_ = cmd.Process.Kill()
+		e.fail <- true
+		return err
+	}
+
+	if e.workload.Triggers {
+		subject := fmt.Sprintf("agentint.%s.trigger", e.vmID)
+		_, err = e.nc.Subscribe(subject, func(msg *nats.Msg) {
+			md := agentapi.TriggerMetadataFromMsg(msg)
+			ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(msg.Header))
+			ctx = agentapi.WithTriggerMetadata(ctx, md)
+
+			payload, err := e.cipher.Open(msg.Data)
+			if err != nil {
+				return
+			}
+
+			val, err := e.Execute(ctx, payload)
+			if err != nil {
+				// TODO-- propagate this error to agent logs
+				return
+			}
+
+			if len(val) > 0 {
+				val, err = e.cipher.Seal(val)
+				if err != nil {
+					return
+				}
+
+				header := nats.Header{}
+				agentapi.EncodeResponseHeaders(md, header)
+				_ = msg.RespondMsg(&nats.Msg{Data: val, Header: header})
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to trigger: %s", err)
+		}
+	}
+
+	e.run <- true
+
+	go func() {
+		_ = cmd.Wait()
+		e.exit <- cmd.ProcessState.ExitCode()
+	}()
+
+	return nil
+}
+
+func (e *External) Execute(ctx context.Context, payload []byte) ([]byte, error) {
+	subject, ok := ctx.Value(agentapi.NexTriggerSubject).(string)
+	if !ok {
+		return nil, errors.New("failed to execute external function; no trigger subject provided in context")
+	}
+
+	md := agentapi.TriggerMetadataFromContext(ctx)
+	resp, err := e.request(&externalRequest{
+		Operation: "execute",
+		Subject:   subject,
+		Payload:   payload,
+		Reply:     md.Reply,
+		MsgID:     md.MsgID,
+		Headers:   md.Headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range resp.Headers {
+		md.SetResponseHeader(name, values...)
+	}
+
+	return resp.Payload, nil
+}
+
+// Undeploy asks the provider process to stop the workload, killing it if it fails to exit
+func (e *External) Undeploy() error {
+	e.undeploy.Do(func() {
+		if e.cmd == nil || e.cmd.Process == nil {
+			return
+		}
+
+		done := make(chan struct{})
+		go func() {
+			_, _ = e.request(&externalRequest{Operation: "undeploy"})
+			_ = e.stdin.Close()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(externalUndeployTimeout):
+		}
+
+		_ = e.cmd.Process.Kill()
+		_ = os.Remove(e.workload.ArtifactPath)
+	})
+
+	return nil
+}
+
+// Validate that the provider executable is present; validating the artifact itself is left
+// to the provider process
+func (e *External) Validate() error {
+	info, err := os.Stat(e.config.Path)
+	if err != nil {
+		return fmt.Errorf("external provider unavailable: %s", err)
+	}
+
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return fmt.Errorf("external provider %s is not executable", e.config.Path)
+	}
+
+	return nil
+}
+
+// Sends a request to the provider process and waits for its response. Requests are
+// serialized, so the provider only ever handles one at a time
+func (e *External) request(req *externalRequest) (*externalResponse, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.nextID++
+	req.ID = e.nextID
+
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = e.stdin.Write(append(raw, '\n'))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s request to external provider: %s", req.Operation, err)
+	}
+
+	line, err := e.stdout.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response from external provider: %s", req.Operation, err)
+	}
+
+	var resp externalResponse
+	err = json.Unmarshal(line, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s response from external provider: %s", req.Operation, err)
+	}
+
+	if resp.ID != req.ID {
+		return nil, fmt.Errorf("external provider answered request %d out of order (expected %d)", resp.ID, req.ID)
+	}
+
+	if !resp.OK {
+		return nil, fmt.Errorf("external provider failed to %s workload: %s", req.Operation, resp.Error)
+	}
+
+	return &resp, nil
+}
+
+// convenience method to initialize an external execution provider
+func InitNexExecutionProviderExternal(params *agentapi.ExecutionProviderParams, config ExternalProviderConfig) (*External, error) {
+	if params.WorkloadName == nil {
+		return nil, errors.New("external execution provider requires a workload name parameter")
+	}
+
+	if params.TmpFilename == nil {
+		return nil, errors.New("external execution provider requires a temporary filename parameter")
+	}
+
+	if config.Path == "" {
+		return nil, errors.New("external execution provider requires a provider executable path")
+	}
+
+	return &External{
+		config: config,
+		workload: externalWorkload{
+			Name:         *params.WorkloadName,
+			ArtifactPath: *params.TmpFilename,
+			Argv:         params.Argv,
+			Environment:  params.Environment,
+			Triggers:     len(params.TriggerSubjects) > 0,
+		},
+		vmID: params.VmID,
+
+		stderr: params.Stderr,
+
+		fail: params.Fail,
+		run:  params.Run,
+		exit: params.Exit,
+
+		nc:     params.NATSConn,
+		cipher: params.TriggerCipher,
+	}, nil
+}
@@ -0,0 +1,160 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+const externalHelperEnv = "NEX_TEST_EXTERNAL_PROVIDER"
+
+// Not a real test: when re-executed by TestExternalProvider* with externalHelperEnv set, the
+// test binary acts as an external provider process, echoing payloads in upper case
+func TestExternalProviderHelperProcess(t *testing.T) {
+	if os.Getenv(externalHelperEnv) != "1" {
+		return
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req externalRequest
+		_ = json.Unmarshal(scanner.Bytes(), &req)
+
+		resp := externalResponse{ID: req.ID, OK: true}
+		switch req.Operation {
+		case "deploy":
+			if req.Workload == nil || req.Workload.Name != "echo" {
+				resp = externalResponse{ID: req.ID, Error: "unexpected workload"}
+			}
+		case "execute":
+			if req.Subject == "fail" {
+				resp = externalResponse{ID: req.ID, Error: "boom"}
+			} else {
+				resp.Payload = []byte(strings.ToUpper(string(req.Payload)))
+				resp.Headers = map[string][]string{"Reply": {req.Reply}, "Msg-Id": {req.MsgID}}
+			}
+		}
+
+		raw, _ := json.Marshal(resp)
+		_, _ = os.Stdout.Write(append(raw, '\n'))
+
+		if req.Operation == "undeploy" {
+			os.Exit(0)
+		}
+	}
+	os.Exit(0)
+}
+
+func newTestExternal(t *testing.T) *External {
+	t.Setenv(externalHelperEnv, "1")
+
+	name := "echo"
+	tmpFilename := filepath.Join(t.TempDir(), "echo")
+	_ = os.WriteFile(tmpFilename, []byte{}, 0600)
+
+	e, err := InitNexExecutionProviderExternal(&agentapi.ExecutionProviderParams{
+		DeployRequest: agentapi.DeployRequest{WorkloadName: &name},
+		TmpFilename:   &tmpFilename,
+		Fail:          make(chan bool, 1),
+		Run:           make(chan bool, 1),
+		Exit:          make(chan int, 1),
+		Stderr:        os.Stderr,
+	}, ExternalProviderConfig{Path: os.Args[0], Args: []string{"-test.run=^TestExternalProviderHelperProcess$"}})
+	if err != nil {
+		t.Fatalf("Failed to initialize external provider: %s", err)
+	}
+
+	return e
+}
+
+func TestExternalProviderExecutesThroughProcess(t *testing.T) {
+	e := newTestExternal(t)
+
+	err := e.Validate()
+	if err != nil {
+		t.Fatalf("Expected the provider executable to be valid: %s", err)
+	}
+
+	err = e.Deploy()
+	if err != nil {
+		t.Fatalf("Failed to deploy: %s", err)
+	}
+	if !<-e.run {
+		t.Fatal("Expected the workload to be reported as running")
+	}
+
+	ctx := context.WithValue(context.Background(), agentapi.NexTriggerSubject, "echo") //nolint:all
+	result, err := e.Execute(ctx, []byte("hello"))
+	if err != nil {
+		t.Fatalf("Failed to execute: %s", err)
+	}
+	if string(result) != "HELLO" {
+		t.Fatalf("Expected HELLO, got %q", result)
+	}
+
+	md := &agentapi.TriggerMetadata{Subject: "echo", Reply: "_INBOX.abc", MsgID: "42"}
+	_, err = e.Execute(agentapi.WithTriggerMetadata(context.Background(), md), []byte("hello"))
+	if err != nil {
+		t.Fatalf("Failed to execute: %s", err)
+	}
+	if !reflect.DeepEqual(md.ResponseHeaders, map[string][]string{"Reply": {"_INBOX.abc"}, "Msg-Id": {"42"}}) {
+		t.Fatalf("Expected the trigger's metadata to reach the provider, got %v", md.ResponseHeaders)
+	}
+
+	ctx = context.WithValue(context.Background(), agentapi.NexTriggerSubject, "fail") //nolint:all
+	_, err = e.Execute(ctx, []byte("hello"))
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Expected the provider's failure to be reported, got %v", err)
+	}
+
+	_, err = e.Execute(context.Background(), []byte("hello"))
+	if err == nil {
+		t.Fatal("Expected a trigger without a subject to be rejected")
+	}
+
+	_ = e.Undeploy()
+	select {
+	case <-e.exit:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the provider process to exit once undeployed")
+	}
+
+	if _, err := os.Stat(e.workload.ArtifactPath); !os.IsNotExist(err) {
+		t.Fatal("Expected the artifact to be removed on undeploy")
+	}
+}
+
+func TestExternalProviderRequiresExecutable(t *testing.T) {
+	name := "echo"
+	tmpFilename := "/tmp/echo"
+	params := &agentapi.ExecutionProviderParams{
+		DeployRequest: agentapi.DeployRequest{WorkloadName: &name},
+		TmpFilename:   &tmpFilename,
+	}
+
+	_, err := InitNexExecutionProviderExternal(params, ExternalProviderConfig{})
+	if err == nil {
+		t.Fatal("Expected a provider without an executable path to be rejected")
+	}
+
+	notExecutable := filepath.Join(t.TempDir(), "provider")
+	_ = os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0600)
+
+	e, _ := InitNexExecutionProviderExternal(params, ExternalProviderConfig{Path: notExecutable})
+	if err := e.Validate(); err == nil {
+		t.Fatal("Expected a provider that is not executable to be rejected")
+	}
+
+	e, _ = InitNexExecutionProviderExternal(params, ExternalProviderConfig{Path: filepath.Join(t.TempDir(), "missing")})
+	if err := e.Validate(); err == nil {
+		t.Fatal("Expected a missing provider to be rejected")
+	}
+}
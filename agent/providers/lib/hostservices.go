@@ -0,0 +1,81 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	hostservices "github.com/synadia-io/nex/host-services"
+)
+
+const (
+	// codes host service calls relayed for workloads fail with when the host service did not
+	// answer them
+	hostServiceCodeFailed   = 500
+	hostServiceCodeTimedOut = 504
+)
+
+// Outcome of a host service call relayed on behalf of a workload, with failures to reach the
+// host service folded into its code so that the workload's SDK can tell them apart
+type hostServiceOutcome struct {
+	code       uint
+	message    string
+	retryAfter time.Duration
+	data       []byte
+}
+
+func (o *hostServiceOutcome) ok() bool {
+	return o.code == 200
+}
+
+// Performs a host service call on behalf of a workload, within the given timeout if any
+func performHostService(ctx context.Context, client *hostservices.HostServicesClient, service, method string, payload []byte, metadata map[string]string, timeout time.Duration) *hostServiceOutcome {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	resp, err := client.PerformRPC(ctx, service, method, payload, metadata)
+
+	var throttle *hostservices.ThrottleError
+	switch {
+	case errors.As(err, &throttle):
+		return &hostServiceOutcome{code: resp.Code, message: throttle.Error(), retryAfter: throttle.RetryAfter}
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout):
+		return &hostServiceOutcome{code: hostServiceCodeTimedOut, message: fmt.Sprintf("host service %s.%s timed out", service, method)}
+	case err != nil:
+		return &hostServiceOutcome{code: hostServiceCodeFailed, message: err.Error()}
+	}
+
+	return &hostServiceOutcome{code: resp.Code, message: resp.Message, data: resp.Data}
+}
+
+// Host services that change state answer with whether they succeeded and why not; fails the
+// outcome when they did not
+func (o *hostServiceOutcome) checkStatus() {
+	if !o.ok() || len(o.data) == 0 {
+		return
+	}
+
+	var status struct {
+		Success *bool    `json:"success,omitempty"`
+		Errors  []string `json:"errors,omitempty"`
+	}
+	if json.Unmarshal(o.data, &status) != nil {
+		return
+	}
+
+	if len(status.Errors) > 0 || (status.Success != nil && !*status.Success) {
+		o.code = hostServiceCodeFailed
+		o.message = strings.Join(status.Errors, "; ")
+		if o.message == "" {
+			o.message = "host service call did not succeed"
+		}
+		o.data = nil
+	}
+}
@@ -0,0 +1,387 @@
+package lib
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	// Environment variable naming the Class::method handler of a JVM function; when omitted,
+	// the jar manifest's Main-Class is used with a method named "handle"
+	JVMHandlerEnvironmentKey = "NEX_JVM_HANDLER"
+
+	// Fraction of the workload's memory limit given to the heap, leaving headroom for
+	// metaspace, thread stacks and other off-heap allocations
+	jvmHeapRatio = 0.75
+
+	jvmFunctionHostFilename = "NexFunctionHost.java"
+)
+
+//go:embed jvm/NexFunctionHost.java
+var jvmFunctionHostSource []byte
+
+// JVM execution provider implementation. Jars deployed without trigger subjects run as
+// services via `java -jar`; jars deployed with trigger subjects are hosted as functions,
+// with each trigger dispatched to the configured handler method
+type JVM struct {
+	argv        []string
+	environment map[string]string
+	handler     string
+	heapMib     int
+	name        string
+	tmpFilename string
+	triggers    bool
+	vmID        string
+
+	fail     chan bool
+	run      chan bool
+	exit     chan int
+	undeploy sync.Once
+
+	cmd *exec.Cmd
+
+	// function host framing, present only when hosting a function
+	mutex   sync.Mutex
+	hostIn  io.WriteCloser
+	hostOut *bufio.Reader
+
+	stderr io.Writer
+	stdout io.Writer
+
+	nc     *nats.Conn // agent NATS connection
+	cipher *agentapi.PayloadCipher
+}
+
+// Deploy the jar by starting a JVM process
+func (e *JVM) Deploy() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("deploy recovered from panic")
+		}
+	}()
+
+	args := make([]string, 0)
+	if e.heapMib > 0 {
+		args = append(args, fmt.Sprintf("-Xmx%dm", e.heapMib))
+	}
+
+	if e.triggers {
+		hostSource := filepath.Join(filepath.Dir(e.tmpFilename), jvmFunctionHostFilename)
+		err = os.WriteFile(hostSource, jvmFunctionHostSource, 0644)
+		if err != nil {
+			e.fail <- true
+			return fmt.Errorf("failed to write JVM function host: %s", err)
+		}
+
+		args = append(args, "-cp", e.tmpFilename, hostSource, e.handler)
+	} else {
+		args = append(args, "-jar", e.tmpFilename)
+		args = append(args, e.argv...)
+	}
+
+	cmd := exec.Command("java", args...)
+	cmd.Stderr = e.stderr
+
+	cmd.Env = make([]string, 0, len(e.environment))
+	for k, v := range e.environment {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", strings.ToUpper(k), v))
+	}
+
+	if e.triggers {
+		e.hostIn, err = cmd.StdinPipe()
+		if err != nil {
+			e.fail <- true
+			return err
+		}
+
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			e.fail <- true
+			return err
+		}
+		e.hostOut = bufio.NewReader(stdout)
+	} else {
+		cmd.Stdout = e.stdout
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		e.fail <- true
+		return fmt.Errorf("failed to start JVM: %s", err)
+	}
+	e.cmd = cmd
+
+	if e.triggers {
+		err = e.subscribeTrigger()
+		if err != nil {
+			_ = cmd.Process.Kill()
+			e.fail <- true
+			return err
+		}
+	}
+
+	e.run <- true
+
+	// This has to be backgrounded because the workload could be a long-running process/service
+	go func() {
+		_ = cmd.Wait()
+		e.exit <- cmd.ProcessState.ExitCode()
+	}()
+
+	return nil
+}
+
+func (e *JVM) subscribeTrigger() error {
+	subject := fmt.Sprintf("agentint.%s.trigger", e.vmID)
+	_, err := e.nc.Subscribe(subject, func(msg *nats.Msg) {
+		md := agentapi.TriggerMetadataFromMsg(msg)
+		ctx := agentapi.WithTriggerMetadata(context.Background(), md)
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(msg.Header))
+
+		payload, err := e.cipher.Open(msg.Data)
+		if err != nil {
+			_, _ = e.stderr.Write([]byte(fmt.Sprintf("failed to open trigger payload on subject %s: %s", subject, err.Error())))
+			return
+		}
+
+		startTime := time.Now()
+		val, err := e.Execute(ctx, payload)
+		if err != nil {
+			_, _ = e.stderr.Write([]byte(fmt.Sprintf("failed to execute function on trigger subject %s: %s", subject, err.Error())))
+			return
+		}
+
+		header := nats.Header{
+			agentapi.NexRuntimeNs: []string{strconv.FormatInt(time.Since(startTime).Nanoseconds(), 10)},
+		}
+		agentapi.EncodeResponseHeaders(md, header)
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+
+		sealed, err := e.cipher.Seal(val)
+		if err != nil {
+			_, _ = e.stderr.Write([]byte(fmt.Sprintf("failed to seal %d-byte response: %s", len(val), err.Error())))
+			return
+		}
+
+		err = msg.RespondMsg(&nats.Msg{
+			Data:   sealed,
+			Header: header,
+		})
+		if err != nil {
+			_, _ = e.stderr.Write([]byte(fmt.Sprintf("failed to write %d-byte response: %s", len(val), err.Error())))
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to trigger: %s", err)
+	}
+
+	return nil
+}
+
+// Dispatches a trigger to the function host's handler method. Invocations are serialized. Frames
+// carry the trigger's subject, payload, reply subject and headers; results carry the handler's
+// status, result and response headers
+func (e *JVM) Execute(ctx context.Context, payload []byte) ([]byte, error) {
+	if e.hostIn == nil {
+		return nil, errors.New("JVM workload was not deployed as a function")
+	}
+
+	subject, ok := ctx.Value(agentapi.NexTriggerSubject).(string)
+	if !ok {
+		return nil, errors.New("failed to execute JVM function; no trigger subject provided in context")
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	md := agentapi.TriggerMetadataFromContext(ctx)
+
+	frame := make([]byte, 0, 16+len(subject)+len(payload)+len(md.Reply))
+	frame = appendJVMBytes(frame, []byte(subject))
+	frame = appendJVMBytes(frame, payload)
+	frame = appendJVMBytes(frame, []byte(md.Reply))
+
+	pairs := 0
+	for _, values := range md.Headers {
+		pairs += len(values)
+	}
+	frame = binary.BigEndian.AppendUint32(frame, uint32(pairs))
+	for name, values := range md.Headers {
+		for _, value := range values {
+			frame = appendJVMBytes(frame, []byte(name))
+			frame = appendJVMBytes(frame, []byte(value))
+		}
+	}
+
+	_, err := e.hostIn.Write(frame)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dispatch trigger to JVM: %s", err)
+	}
+
+	var header [8]byte
+	_, err = io.ReadFull(e.hostOut, header[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JVM function result: %s", err)
+	}
+
+	result := make([]byte, binary.BigEndian.Uint32(header[4:]))
+	_, err = io.ReadFull(e.hostOut, result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JVM function result: %s", err)
+	}
+
+	var count [4]byte
+	_, err = io.ReadFull(e.hostOut, count[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JVM function result: %s", err)
+	}
+	for i := binary.BigEndian.Uint32(count[:]); i > 0; i-- {
+		name, err := readJVMBytes(e.hostOut)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JVM function response headers: %s", err)
+		}
+		value, err := readJVMBytes(e.hostOut)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JVM function response headers: %s", err)
+		}
+		md.SetResponseHeader(string(name), append(md.ResponseHeaders[string(name)], string(value))...)
+	}
+
+	if binary.BigEndian.Uint32(header[:4]) != 0 {
+		return nil, fmt.Errorf("JVM function handler failed: %s", string(result))
+	}
+
+	return result, nil
+}
+
+// Undeploy the jar, giving the JVM a chance to run its shutdown hooks
+func (e *JVM) Undeploy() error {
+	e.undeploy.Do(func() {
+		defer func() {
+			_ = os.Remove(e.tmpFilename)
+		}()
+
+		if e.cmd == nil || e.cmd.Process == nil {
+			return
+		}
+
+		if e.hostIn != nil {
+			_ = e.hostIn.Close()
+		}
+
+		err := e.cmd.Process.Signal(os.Interrupt)
+		if err != nil {
+			_, _ = fmt.Fprintf(e.stderr, "failed to terminate JVM process: %s\n", err)
+			e.fail <- true
+		}
+	})
+
+	return nil
+}
+
+// Validate that the artifact is a jar and that a JVM is available
+func (e *JVM) Validate() error {
+	jar, err := zip.OpenReader(e.tmpFilename)
+	if err != nil {
+		return fmt.Errorf("artifact is not a valid jar: %s", err)
+	}
+	defer jar.Close()
+
+	hasManifest := false
+	for _, f := range jar.File {
+		if f.Name == "META-INF/MANIFEST.MF" {
+			hasManifest = true
+			break
+		}
+	}
+
+	if !hasManifest {
+		return errors.New("jar does not contain a manifest")
+	}
+
+	_, err = exec.LookPath("java")
+	if err != nil {
+		return fmt.Errorf("no JVM available to run jar: %s", err)
+	}
+
+	return nil
+}
+
+// convenience method to initialize a JVM execution provider
+func InitNexExecutionProviderJVM(params *agentapi.ExecutionProviderParams) (*JVM, error) {
+	if params.WorkloadName == nil {
+		return nil, errors.New("JVM execution provider requires a workload name parameter")
+	}
+
+	if params.TmpFilename == nil {
+		return nil, errors.New("JVM execution provider requires a temporary filename parameter")
+	}
+
+	heapMib := 0
+	if params.Resources != nil && params.Resources.MemoryMib > 0 {
+		heapMib = int(float64(params.Resources.MemoryMib) * jvmHeapRatio)
+	}
+
+	handler := ""
+	for k, v := range params.Environment {
+		if strings.EqualFold(k, JVMHandlerEnvironmentKey) {
+			handler = v
+		}
+	}
+
+	return &JVM{
+		argv:        params.Argv,
+		environment: params.Environment,
+		handler:     handler,
+		heapMib:     heapMib,
+		name:        *params.WorkloadName,
+		tmpFilename: *params.TmpFilename,
+		triggers:    len(params.TriggerSubjects) > 0,
+		vmID:        params.VmID,
+
+		stderr: params.Stderr,
+		stdout: params.Stdout,
+
+		fail: params.Fail,
+		run:  params.Run,
+		exit: params.Exit,
+
+		nc:     params.NATSConn,
+		cipher: params.TriggerCipher,
+	}, nil
+}
+
+func appendJVMBytes(frame, value []byte) []byte {
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(value)))
+	return append(frame, value...)
+}
+
+func readJVMBytes(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	_, err := io.ReadFull(r, size[:])
+	if err != nil {
+		return nil, err
+	}
+
+	value := make([]byte, binary.BigEndian.Uint32(size[:]))
+	_, err = io.ReadFull(r, value)
+	return value, err
+}
@@ -0,0 +1,120 @@
+import java.io.BufferedInputStream;
+import java.io.BufferedOutputStream;
+import java.io.DataInputStream;
+import java.io.DataOutputStream;
+import java.io.EOFException;
+import java.io.FileDescriptor;
+import java.io.FileOutputStream;
+import java.lang.reflect.InvocationTargetException;
+import java.lang.reflect.Method;
+import java.lang.reflect.Modifier;
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.jar.JarFile;
+
+// Hosts a JVM function on behalf of the nex agent. The handler is given as Class::method and
+// must accept (String subject, byte[] payload) and return byte[]; instance methods require a
+// public no-arg constructor. Handlers that also accept (String reply, Map<String, List<String>>
+// headers, Map<String, List<String>> responseHeaders) are given the trigger's reply subject and
+// headers, and may add headers to their response. Invocations are read from stdin and results
+// written to stdout as big-endian length-prefixed frames, so anything the handler prints is
+// redirected to stderr.
+public class NexFunctionHost {
+    public static void main(String[] args) throws Exception {
+        String handler = args.length > 0 ? args[0] : "";
+        if (handler.isEmpty()) {
+            try (JarFile jar = new JarFile(System.getProperty("java.class.path"))) {
+                handler = jar.getManifest().getMainAttributes().getValue("Main-Class") + "::handle";
+            }
+        }
+
+        int sep = handler.indexOf("::");
+        if (sep < 1) {
+            throw new IllegalArgumentException("handler must take the form Class::method: " + handler);
+        }
+
+        Class<?> cls = Class.forName(handler.substring(0, sep));
+        String name = handler.substring(sep + 2);
+        Method method;
+        boolean withMetadata = true;
+        try {
+            method = cls.getMethod(name, String.class, byte[].class, String.class, Map.class, Map.class);
+        } catch (NoSuchMethodException e) {
+            method = cls.getMethod(name, String.class, byte[].class);
+            withMetadata = false;
+        }
+        if (method.getReturnType() != byte[].class) {
+            throw new IllegalArgumentException("handler must return byte[]: " + handler);
+        }
+        Object target = Modifier.isStatic(method.getModifiers()) ? null : cls.getDeclaredConstructor().newInstance();
+
+        DataInputStream in = new DataInputStream(new BufferedInputStream(System.in));
+        DataOutputStream out = new DataOutputStream(new BufferedOutputStream(new FileOutputStream(FileDescriptor.out)));
+        System.setOut(System.err);
+
+        while (true) {
+            byte[] subject;
+            try {
+                subject = new byte[in.readInt()];
+            } catch (EOFException e) {
+                return;
+            }
+            in.readFully(subject);
+
+            byte[] payload = new byte[in.readInt()];
+            in.readFully(payload);
+
+            String reply = readString(in);
+            Map<String, List<String>> headers = new LinkedHashMap<>();
+            for (int i = in.readInt(); i > 0; i--) {
+                String header = readString(in);
+                headers.computeIfAbsent(header, k -> new ArrayList<>()).add(readString(in));
+            }
+            Map<String, List<String>> responseHeaders = new LinkedHashMap<>();
+
+            int status = 0;
+            byte[] result;
+            try {
+                String subjectStr = new String(subject, StandardCharsets.UTF_8);
+                Object value = withMetadata
+                        ? method.invoke(target, subjectStr, payload, reply, headers, responseHeaders)
+                        : method.invoke(target, subjectStr, payload);
+                result = value == null ? new byte[0] : (byte[]) value;
+            } catch (InvocationTargetException e) {
+                e.getCause().printStackTrace();
+                status = 1;
+                result = String.valueOf(e.getCause()).getBytes(StandardCharsets.UTF_8);
+            }
+
+            List<String[]> pairs = new ArrayList<>();
+            if (status == 0) {
+                responseHeaders.forEach((header, values) -> values.forEach(v -> pairs.add(new String[]{header, v})));
+            }
+
+            out.writeInt(status);
+            out.writeInt(result.length);
+            out.write(result);
+            out.writeInt(pairs.size());
+            for (String[] pair : pairs) {
+                writeString(out, pair[0]);
+                writeString(out, pair[1]);
+            }
+            out.flush();
+        }
+    }
+
+    private static String readString(DataInputStream in) throws Exception {
+        byte[] value = new byte[in.readInt()];
+        in.readFully(value);
+        return new String(value, StandardCharsets.UTF_8);
+    }
+
+    private static void writeString(DataOutputStream out, String value) throws Exception {
+        byte[] raw = value.getBytes(StandardCharsets.UTF_8);
+        out.writeInt(raw.length);
+        out.write(raw);
+    }
+}
@@ -0,0 +1,223 @@
+package lib
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+func writeJar(t *testing.T, files map[string]string) string {
+	filename := filepath.Join(t.TempDir(), "workload.jar")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatalf("Failed to create jar: %s", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to write jar: %s", err)
+	}
+
+	return filename
+}
+
+func TestInitJVMDerivesHeapAndHandler(t *testing.T) {
+	name := "echo"
+	tmpFilename := "/tmp/echo.jar"
+
+	params := &agentapi.ExecutionProviderParams{
+		DeployRequest: agentapi.DeployRequest{
+			Environment:  map[string]string{"nex_jvm_handler": "com.example.Echo::handle"},
+			Resources:    &controlapi.WorkloadResources{MemoryMib: 1024},
+			WorkloadName: &name,
+		},
+		TriggerSubjects: []string{"echo"},
+		TmpFilename:     &tmpFilename,
+	}
+
+	jvm, err := InitNexExecutionProviderJVM(params)
+	if err != nil {
+		t.Fatalf("Failed to initialize JVM provider: %s", err)
+	}
+	if jvm.heapMib != 768 {
+		t.Fatalf("Expected a 768 MiB heap for a 1024 MiB limit, got %d", jvm.heapMib)
+	}
+	if jvm.handler != "com.example.Echo::handle" || !jvm.triggers {
+		t.Fatalf("Expected a function hosting com.example.Echo::handle, got %q (triggers %v)", jvm.handler, jvm.triggers)
+	}
+
+	params.WorkloadName = nil
+	_, err = InitNexExecutionProviderJVM(params)
+	if err == nil {
+		t.Fatal("Expected a missing workload name to be rejected")
+	}
+}
+
+func TestJVMValidateRequiresJarWithManifest(t *testing.T) {
+	notJar := filepath.Join(t.TempDir(), "workload.jar")
+	_ = os.WriteFile(notJar, []byte("not a zip"), 0600)
+
+	err := (&JVM{tmpFilename: notJar}).Validate()
+	if err == nil || !strings.Contains(err.Error(), "not a valid jar") {
+		t.Fatalf("Expected a non-zip artifact to be rejected, got %v", err)
+	}
+
+	err = (&JVM{tmpFilename: writeJar(t, map[string]string{"Echo.class": ""})}).Validate()
+	if err == nil || !strings.Contains(err.Error(), "manifest") {
+		t.Fatalf("Expected a jar without a manifest to be rejected, got %v", err)
+	}
+
+	t.Setenv("PATH", "")
+	err = (&JVM{tmpFilename: writeJar(t, map[string]string{"META-INF/MANIFEST.MF": "Main-Class: Echo\n"})}).Validate()
+	if err == nil || !strings.Contains(err.Error(), "no JVM available") {
+		t.Fatalf("Expected a missing JVM to be reported, got %v", err)
+	}
+}
+
+// Stands in for the function host, answering each frame with the given status and result, and
+// echoing the trigger's reply subject and headers as response headers
+func fakeJVMFunctionHost(t *testing.T, e *JVM, status uint32, respond func(subject string, payload []byte) []byte) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	e.hostIn = inW
+	e.hostOut = bufio.NewReader(outR)
+	t.Cleanup(func() {
+		_ = inW.Close()
+		_ = outW.Close()
+	})
+
+	go func() {
+		for {
+			subject, err := readJVMBytes(inR)
+			if err != nil {
+				return
+			}
+			payload, _ := readJVMBytes(inR)
+			reply, _ := readJVMBytes(inR)
+
+			var count [4]byte
+			_, _ = io.ReadFull(inR, count[:])
+			headers := [][]byte{[]byte("reply"), reply}
+			for i := 2 * binary.BigEndian.Uint32(count[:]); i > 0; i-- {
+				value, _ := readJVMBytes(inR)
+				headers = append(headers, value)
+			}
+
+			result := respond(string(subject), payload)
+			frame := binary.BigEndian.AppendUint32(nil, status)
+			frame = appendJVMBytes(frame, result)
+			frame = binary.BigEndian.AppendUint32(frame, uint32(len(headers)/2))
+			for _, value := range headers {
+				frame = appendJVMBytes(frame, value)
+			}
+			_, _ = outW.Write(frame)
+		}
+	}()
+}
+
+func TestJVMExecuteFramesTriggers(t *testing.T) {
+	e := &JVM{}
+	fakeJVMFunctionHost(t, e, 0, func(subject string, payload []byte) []byte {
+		return []byte(subject + ":" + strings.ToUpper(string(payload)))
+	})
+
+	ctx := context.WithValue(context.Background(), agentapi.NexTriggerSubject, "echo") //nolint:all
+	for _, payload := range []string{"hello", "", "again"} {
+		result, err := e.Execute(ctx, []byte(payload))
+		if err != nil {
+			t.Fatalf("Failed to execute function: %s", err)
+		}
+		if expected := "echo:" + strings.ToUpper(payload); string(result) != expected {
+			t.Fatalf("Expected %q, got %q", expected, result)
+		}
+	}
+
+	_, err := e.Execute(context.Background(), []byte("hello"))
+	if err == nil {
+		t.Fatal("Expected a trigger without a subject to be rejected")
+	}
+}
+
+func TestJVMExecutePassesTriggerMetadata(t *testing.T) {
+	e := &JVM{}
+	fakeJVMFunctionHost(t, e, 0, func(_ string, payload []byte) []byte {
+		return payload
+	})
+
+	md := &agentapi.TriggerMetadata{
+		Subject: "echo",
+		Reply:   "_INBOX.abc",
+		Headers: map[string][]string{"X-Correlation-Id": {"42"}},
+	}
+	_, err := e.Execute(agentapi.WithTriggerMetadata(context.Background(), md), []byte("hello"))
+	if err != nil {
+		t.Fatalf("Failed to execute function: %s", err)
+	}
+
+	if got := md.ResponseHeaders["reply"]; len(got) != 1 || got[0] != "_INBOX.abc" {
+		t.Fatalf("Expected the reply subject to reach the handler, got %v", got)
+	}
+	if got := md.ResponseHeaders["X-Correlation-Id"]; len(got) != 1 || got[0] != "42" {
+		t.Fatalf("Expected the trigger's headers to reach the handler, got %v", got)
+	}
+}
+
+func TestJVMExecuteReportsHandlerFailures(t *testing.T) {
+	e := &JVM{}
+	fakeJVMFunctionHost(t, e, 1, func(string, []byte) []byte {
+		return []byte("java.lang.IllegalStateException: boom")
+	})
+
+	ctx := context.WithValue(context.Background(), agentapi.NexTriggerSubject, "echo") //nolint:all
+	_, err := e.Execute(ctx, []byte("hello"))
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Expected the handler's failure to be reported, got %v", err)
+	}
+
+	_, err = (&JVM{}).Execute(ctx, nil)
+	if err == nil {
+		t.Fatal("Expected a JVM deployed as a service to refuse triggers")
+	}
+}
+
+func TestJVMUndeployReportsTerminationFailureOnStderr(t *testing.T) {
+	tmpFilename := filepath.Join(t.TempDir(), "workload.jar")
+	_ = os.WriteFile(tmpFilename, []byte{}, 0600)
+
+	// a process that has already exited cannot be signalled
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to run helper process: %s", err)
+	}
+
+	stderr := &bytes.Buffer{}
+	e := &JVM{cmd: cmd, tmpFilename: tmpFilename, stderr: stderr, fail: make(chan bool, 1)}
+
+	_ = e.Undeploy()
+
+	if !strings.Contains(stderr.String(), "failed to terminate JVM process") {
+		t.Fatalf("Expected the failure to be written to the workload's stderr, got %q", stderr.String())
+	}
+	if !<-e.fail {
+		t.Fatal("Expected the failure to be signalled")
+	}
+	if _, err := os.Stat(tmpFilename); !os.IsNotExist(err) {
+		t.Fatal("Expected the jar to be removed on undeploy")
+	}
+}
@@ -12,17 +12,24 @@ import (
 	"sync"
 	"time"
 
+	"github.com/nats-io/nats.go"
+	hostservices "github.com/synadia-io/nex/host-services"
 	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/sdk"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // NativeExecutable execution provider implementation
 type NativeExecutable struct {
-	argv        []string
-	environment map[string]string
-	name        string
-	tmpFilename string
-	totalBytes  int64
-	vmID        string
+	argv            []string
+	environment     map[string]string
+	name            string
+	namespace       string
+	tmpFilename     string
+	totalBytes      int64
+	triggerSubjects []string
+	vmID            string
 
 	fail     chan bool
 	run      chan bool
@@ -32,7 +39,15 @@ type NativeExecutable struct {
 	cmd *exec.Cmd
 
 	stderr io.Writer
+	stdin  io.Reader
 	stdout io.Writer
+
+	// Channel to workloads built with the SDK; nil where workloads cannot be handed one
+	channel      *sdkChannel
+	hostServices *hostservices.HostServicesClient
+
+	nc     *nats.Conn // agent NATS connection
+	cipher *agentapi.PayloadCipher
 }
 
 // Deploy the ELF binary
@@ -44,6 +59,7 @@ func (e *NativeExecutable) Deploy() (err error) {
 	}()
 
 	cmd := exec.Command(e.tmpFilename, e.argv...)
+	cmd.Stdin = e.stdin
 	cmd.Stdout = e.stdout
 	cmd.Stderr = e.stderr
 	cmd.SysProcAttr = e.sysProcAttr()
@@ -53,8 +69,31 @@ func (e *NativeExecutable) Deploy() (err error) {
 		item := fmt.Sprintf("%s=%s", strings.ToUpper(k), v)
 		cmd.Env = append(cmd.Env, item)
 	}
+	cmd.Env = append(cmd.Env,
+		fmt.Sprintf("%s=%s", sdk.EnvWorkloadID, e.vmID),
+		fmt.Sprintf("%s=%s", sdk.EnvWorkloadName, e.name),
+		fmt.Sprintf("%s=%s", sdk.EnvNamespace, e.namespace),
+	)
+	if len(e.triggerSubjects) > 0 {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", sdk.EnvTriggerSubjects, strings.Join(e.triggerSubjects, ",")))
+	}
+
+	var closeWorkloadEnds func()
+	e.channel, closeWorkloadEnds, err = e.attachChannel(cmd)
+	if err != nil {
+		e.fail <- true
+		return
+	}
+
+	if e.channel == nil && len(e.triggerSubjects) > 0 {
+		e.fail <- true
+		return errors.New("native functions require a channel to the workload, which this agent cannot provide")
+	}
 
 	err = cmd.Start()
+	if closeWorkloadEnds != nil {
+		closeWorkloadEnds()
+	}
 	if err != nil {
 		e.fail <- true
 		return
@@ -62,6 +101,19 @@ func (e *NativeExecutable) Deploy() (err error) {
 
 	e.cmd = cmd
 
+	if e.channel != nil {
+		go e.channel.run()
+	}
+
+	if len(e.triggerSubjects) > 0 {
+		err = e.subscribeTrigger()
+		if err != nil {
+			_ = cmd.Process.Kill()
+			e.fail <- true
+			return
+		}
+	}
+
 	go func() {
 		go func() {
 			for {
@@ -94,11 +146,62 @@ func (e *NativeExecutable) Deploy() (err error) {
 }
 
 func (e *NativeExecutable) removeWorkload() {
+	if e.channel != nil {
+		e.channel.close()
+	}
 	_ = os.Remove(e.tmpFilename)
 }
 
+// Subscribes to the workload's trigger subject, dispatching each trigger to the workload over its
+// channel
+func (e *NativeExecutable) subscribeTrigger() error {
+	subject := fmt.Sprintf("agentint.%s.trigger", e.vmID)
+	_, err := e.nc.Subscribe(subject, func(msg *nats.Msg) {
+		md := agentapi.TriggerMetadataFromMsg(msg)
+		ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(msg.Header))
+		ctx = agentapi.WithTriggerMetadata(ctx, md)
+
+		payload, err := e.cipher.Open(msg.Data)
+		if err != nil {
+			_, _ = e.stderr.Write([]byte(fmt.Sprintf("failed to open trigger payload on subject %s: %s", subject, err.Error())))
+			return
+		}
+
+		val, err := e.Execute(ctx, payload)
+		if err != nil {
+			_, _ = e.stderr.Write([]byte(fmt.Sprintf("failed to execute function on trigger subject %s: %s", subject, err.Error())))
+			return
+		}
+
+		if len(val) > 0 {
+			val, err = e.cipher.Seal(val)
+			if err != nil {
+				return
+			}
+
+			header := nats.Header{}
+			agentapi.EncodeResponseHeaders(md, header)
+			_ = msg.RespondMsg(&nats.Msg{Data: val, Header: header})
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to trigger: %s", err)
+	}
+
+	return nil
+}
+
+// Execute dispatches a trigger to a native function built with the SDK
 func (e *NativeExecutable) Execute(ctx context.Context, payload []byte) ([]byte, error) {
-	return nil, errors.New("Native execution provider does not support execution via trigger subjects")
+	if e.channel == nil {
+		return nil, errors.New("Native execution provider does not support execution via trigger subjects")
+	}
+
+	if _, ok := ctx.Value(agentapi.NexTriggerSubject).(string); !ok {
+		return nil, errors.New("failed to execute native function; no trigger subject provided in context")
+	}
+
+	return e.channel.trigger(ctx, agentapi.TriggerMetadataFromContext(ctx), payload)
 }
 
 // Validate the underlying artifact to be a 64-bit linux native ELF
@@ -117,15 +220,34 @@ func InitNexExecutionProviderNative(params *agentapi.ExecutionProviderParams) (*
 		return nil, errors.New("Native execution provider requires a temporary filename parameter")
 	}
 
+	namespace := ""
+	if params.Namespace != nil {
+		namespace = *params.Namespace
+	}
+
 	return &NativeExecutable{
-		argv:        params.Argv,
-		environment: params.Environment,
-		name:        *params.WorkloadName,
-		tmpFilename: *params.TmpFilename,
-		totalBytes:  params.TotalBytes,
-		vmID:        params.VmID,
+		argv:            params.Argv,
+		environment:     params.Environment,
+		name:            *params.WorkloadName,
+		namespace:       namespace,
+		tmpFilename:     *params.TmpFilename,
+		totalBytes:      params.TotalBytes,
+		triggerSubjects: params.TriggerSubjects,
+		vmID:            params.VmID,
+
+		hostServices: hostservices.NewHostServicesClient(
+			params.NATSConn,
+			time.Second*5, // FIXME-- make configurable
+			namespace,
+			*params.WorkloadName,
+			params.VmID,
+		),
+
+		nc:     params.NATSConn,
+		cipher: params.TriggerCipher,
 
 		stderr: params.Stderr,
+		stdin:  params.Stdin,
 		stdout: params.Stdout,
 
 		fail: params.Fail,
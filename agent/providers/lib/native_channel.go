@@ -0,0 +1,171 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	hostservices "github.com/synadia-io/nex/host-services"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/sdk"
+)
+
+// Channel between the agent and a native workload built with the SDK, over which the agent
+// dispatches triggers and relays the workload's host service calls. See the sdk package for the
+// protocol
+type sdkChannel struct {
+	source io.ReadCloser
+	in     *bufio.Reader
+	out    io.WriteCloser
+
+	writeMutex sync.Mutex
+
+	mutex   sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *sdk.Message
+
+	hostServices *hostservices.HostServicesClient
+	stderr       io.Writer
+}
+
+func newSdkChannel(in io.ReadCloser, out io.WriteCloser, hostServices *hostservices.HostServicesClient, stderr io.Writer) *sdkChannel {
+	return &sdkChannel{
+		source:       in,
+		in:           bufio.NewReader(in),
+		out:          out,
+		pending:      make(map[uint64]chan *sdk.Message),
+		hostServices: hostServices,
+		stderr:       stderr,
+	}
+}
+
+// Sends a trigger payload to the workload and waits for its reply
+func (c *sdkChannel) trigger(ctx context.Context, md *agentapi.TriggerMetadata, payload []byte) ([]byte, error) {
+	result := make(chan *sdk.Message, 1)
+
+	c.mutex.Lock()
+	if c.pending == nil {
+		c.mutex.Unlock()
+		return nil, errors.New("native workload closed its channel")
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = result
+	c.mutex.Unlock()
+
+	err := c.write(&sdk.Message{
+		ID:      id,
+		Kind:    sdk.KindTrigger,
+		Subject: md.Subject,
+		Reply:   md.Reply,
+		MsgID:   md.MsgID,
+		Headers: md.Headers,
+		Payload: payload,
+	})
+	if err != nil {
+		c.mutex.Lock()
+		delete(c.pending, id)
+		c.mutex.Unlock()
+		return nil, fmt.Errorf("failed to send trigger to native workload: %s", err)
+	}
+
+	select {
+	case resp, ok := <-result:
+		if !ok {
+			return nil, errors.New("native workload closed its channel")
+		}
+		if resp.Error != "" {
+			return nil, errors.New(resp.Error)
+		}
+		for name, values := range resp.Headers {
+			md.SetResponseHeader(name, values...)
+		}
+		return resp.Payload, nil
+	case <-ctx.Done():
+		c.mutex.Lock()
+		delete(c.pending, id)
+		c.mutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// Reads the workload's messages until it closes its end of the channel
+func (c *sdkChannel) run() {
+	defer func() {
+		c.mutex.Lock()
+		for _, result := range c.pending {
+			close(result)
+		}
+		c.pending = nil
+		c.mutex.Unlock()
+	}()
+
+	for {
+		line, err := c.in.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+
+		var msg sdk.Message
+		err = json.Unmarshal(line, &msg)
+		if err != nil {
+			_, _ = c.stderr.Write([]byte(fmt.Sprintf("ignoring invalid message from native workload: %s", err)))
+			continue
+		}
+
+		switch msg.Kind {
+		case sdk.KindTriggerResult:
+			c.mutex.Lock()
+			result, ok := c.pending[msg.ID]
+			delete(c.pending, msg.ID)
+			c.mutex.Unlock()
+
+			if ok {
+				result <- &msg
+			}
+		case sdk.KindHostService:
+			go c.handleHostService(&msg)
+		default:
+			_, _ = c.stderr.Write([]byte(fmt.Sprintf("ignoring unexpected %q message from native workload", msg.Kind)))
+		}
+	}
+}
+
+// Relays a host service call to the node on the workload's behalf
+func (c *sdkChannel) handleHostService(msg *sdk.Message) {
+	outcome := performHostService(context.Background(), c.hostServices, msg.Service, msg.Method, msg.Payload, msg.Metadata, 0)
+
+	err := c.write(&sdk.Message{
+		ID:               msg.ID,
+		Kind:             sdk.KindHostServiceResult,
+		Code:             outcome.code,
+		Error:            outcome.message,
+		RetryAfterMillis: outcome.retryAfter.Milliseconds(),
+		Payload:          outcome.data,
+	})
+	if err != nil {
+		_, _ = c.stderr.Write([]byte(fmt.Sprintf("failed to send host service result to native workload: %s", err)))
+	}
+}
+
+func (c *sdkChannel) write(msg *sdk.Message) error {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	_, err = c.out.Write(append(raw, '\n'))
+	return err
+}
+
+func (c *sdkChannel) close() {
+	_ = c.out.Close()
+	_ = c.source.Close()
+}
@@ -5,7 +5,10 @@ package lib
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"syscall"
+
+	"github.com/synadia-io/nex/sdk"
 )
 
 // Undeploy the ELF binary
@@ -28,3 +31,30 @@ func (e *NativeExecutable) Undeploy() error {
 func (e *NativeExecutable) sysProcAttr() *syscall.SysProcAttr {
 	return &syscall.SysProcAttr{}
 }
+
+// Hands the workload its end of the SDK channel as file descriptors 3 and 4, returning the
+// agent's end and a function closing the workload's end once it has started
+func (e *NativeExecutable) attachChannel(cmd *exec.Cmd) (*sdkChannel, func(), error) {
+	workloadIn, agentOut, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	agentIn, workloadOut, err := os.Pipe()
+	if err != nil {
+		_ = workloadIn.Close()
+		_ = agentOut.Close()
+		return nil, nil, err
+	}
+
+	// extra files are numbered from 3, after stdin, stdout and stderr
+	cmd.ExtraFiles = []*os.File{workloadIn, workloadOut}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=3,4", sdk.EnvChannel))
+
+	closeWorkloadEnds := func() {
+		_ = workloadIn.Close()
+		_ = workloadOut.Close()
+	}
+
+	return newSdkChannel(agentIn, agentOut, e.hostServices, e.stderr), closeWorkloadEnds, nil
+}
@@ -4,6 +4,7 @@ package lib
 
 import (
 	"fmt"
+	"os/exec"
 	"syscall"
 
 	"golang.org/x/sys/windows"
@@ -46,3 +47,8 @@ func (e *NativeExecutable) sysProcAttr() *syscall.SysProcAttr {
 		CreationFlags: windows.CREATE_NEW_PROCESS_GROUP,
 	}
 }
+
+// Workloads cannot be handed extra file descriptors on windows, so they have no SDK channel
+func (e *NativeExecutable) attachChannel(*exec.Cmd) (*sdkChannel, func(), error) {
+	return nil, nil, nil
+}
@@ -27,6 +27,8 @@ import (
 const (
 	hostServicesObjectName = "hostServices"
 
+	nexObjectName = "nex"
+
 	hostServicesHTTPObjectName         = "http"
 	hostServicesHTTPGetFunctionName    = "get"
 	hostServicesHTTPPostFunctionName   = "post"
@@ -65,6 +67,12 @@ const (
 	v8MaxFileSizeBytes       = int64(12288) // arbitrarily ~12K, for now
 )
 
+// JSON encoding of the built-in host service definitions the nex global is built from
+var v8NexDefinitions = func() string {
+	raw, _ := json.Marshal(builtins.Definitions())
+	return string(raw)
+}()
+
 // V8 execution provider implementation
 type V8 struct {
 	environment map[string]string
@@ -83,27 +91,33 @@ type V8 struct {
 
 	builtins *builtins.BuiltinServicesClient
 
-	nc *nats.Conn // agent NATS connection
+	nc     *nats.Conn // agent NATS connection
+	cipher *agentapi.PayloadCipher
 
-	ctx   *v8.Context // default context for internal use only
-	iso   *v8.Isolate
-	ubs   *v8.UnboundScript
-	utils map[string]*v8.Function //v8.UnboundScript
+	pool      *v8IsolatePool
+	validated bool
 }
 
-// Deploy expects a `Validate` to have succeeded and `ubs` to be non-nil
+// Deploy expects a `Validate` to have succeeded, leaving compiled code in the isolate pool
 func (v *V8) Deploy() error {
-	if v.ubs == nil {
+	if !v.validated {
 		return fmt.Errorf("invalid state for execution; no compiled code available for vm: %s", v.name)
 	}
 
 	subject := fmt.Sprintf("agentint.%s.trigger", v.vmID)
 	_, err := v.nc.Subscribe(subject, func(msg *nats.Msg) {
-		ctx := context.WithValue(context.Background(), agentapi.NexTriggerSubject, msg.Header.Get(agentapi.NexTriggerSubject)) //nolint:all
+		md := agentapi.TriggerMetadataFromMsg(msg)
+		ctx := agentapi.WithTriggerMetadata(context.Background(), md)
 		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(msg.Header))
 
+		payload, err := v.cipher.Open(msg.Data)
+		if err != nil {
+			_, _ = v.stderr.Write([]byte(fmt.Sprintf("failed to open trigger payload on subject %s: %s", subject, err.Error())))
+			return
+		}
+
 		startTime := time.Now()
-		val, err := v.Execute(ctx, msg.Data)
+		val, err := v.Execute(ctx, payload)
 		if err != nil {
 			_, _ = v.stderr.Write([]byte(fmt.Sprintf("failed to execute function on trigger subject %s: %s", subject, err.Error())))
 			return
@@ -114,10 +128,17 @@ func (v *V8) Deploy() error {
 		header := nats.Header{
 			agentapi.NexRuntimeNs: []string{strconv.FormatInt(runtimeNanos, 10)},
 		}
+		agentapi.EncodeResponseHeaders(md, header)
 		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
 
+		sealed, err := v.cipher.Seal(val)
+		if err != nil {
+			_, _ = v.stderr.Write([]byte(fmt.Sprintf("failed to seal %d-byte response: %s", len(val), err.Error())))
+			return
+		}
+
 		err = msg.RespondMsg(&nats.Msg{
-			Data:   val,
+			Data:   sealed,
 			Header: header,
 		})
 		if err != nil {
@@ -129,15 +150,18 @@ func (v *V8) Deploy() error {
 		return fmt.Errorf("failed to subscribe to trigger: %s", err)
 	}
 
+	v.pool.warm()
+
 	v.run <- true
 	return nil
 }
 
-// Trigger execution of the deployed function; expects a `Validate` to have succeeded and `ubs` to be non-nil.
+// Trigger execution of the deployed function in an isolate from the pool; expects a `Validate` to have succeeded.
 // The executed function can optionally return a value, in which case it will be deemed a reply and returned
 // to the caller. In the case of a nil or empty value returned by the function, no reply will be sent.
+// The function is passed the trigger's metadata as its third argument; see v8Trigger
 func (v *V8) Execute(ctx context.Context, payload []byte) ([]byte, error) {
-	if v.ubs == nil {
+	if !v.validated {
 		return nil, fmt.Errorf("invalid state for execution; no compiled code available for vm: %s", v.name)
 	}
 
@@ -149,16 +173,28 @@ func (v *V8) Execute(ctx context.Context, payload []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to initialize context in vm; no trigger subject provided in context: %s", v.name)
 	}
 
-	v8ctx, err := v.newV8Context(ctx)
+	isolate, err := v.pool.acquire()
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize context in vm: %s", err.Error())
+		return nil, fmt.Errorf("failed to acquire isolate: %s", err)
 	}
 
-	vals := make(chan *v8.Value, 1)
+	vals := make(chan []byte, 1)
 	errs := make(chan error, 1)
 
+	isolate.start()
 	go func() {
-		val, err := v.ubs.Run(v8ctx)
+		// the isolate is only returned to the pool once the script has stopped running
+		defer v.pool.release(isolate)
+		defer isolate.stop()
+
+		v8ctx, err := isolate.newV8Context(ctx)
+		if err != nil {
+			errs <- fmt.Errorf("failed to initialize context in vm: %s", err.Error())
+			return
+		}
+		defer v8ctx.Close()
+
+		val, err := isolate.ubs.Run(v8ctx)
 		if err != nil {
 			errs <- err
 			return
@@ -176,92 +212,238 @@ func (v *V8) Execute(ctx context.Context, payload []byte) ([]byte, error) {
 			return
 		}
 
-		argv2, err := v.toUInt8ArrayValue(payload)
+		argv2, err := isolate.toUInt8ArrayValue(payload)
 		if err != nil {
 			_, _ = v.stdout.Write([]byte(fmt.Sprintf("failed to convert raw %d-length []byte to Uint8[]: %s", len(payload), err.Error())))
 			errs <- err
 			return
 		}
 
+		md := agentapi.TriggerMetadataFromContext(ctx)
+		argv3, err := newV8Trigger(v8ctx, md)
+		if err != nil {
+			errs <- err
+			return
+		}
+
 		_, _ = v.stdout.Write([]byte(fmt.Sprintf("calling js function via trigger subject: %s", subject)))
-		val, err = fn.Call(v8ctx.Global(), argv1, argv2)
+		val, err = fn.Call(v8ctx.Global(), argv1, argv2, argv3)
 		if err != nil {
 			errs <- err
 			return
 		}
 
-		vals <- val
-	}()
+		if val.IsPromise() {
+			val, err = settlePromise(v8ctx, val)
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
 
-	select {
-	case val := <-vals:
 		// FIXME-- switch on val type or are we ok with forcing a JSON response?
-
 		retval, err := val.MarshalJSON()
 		if err != nil {
+			errs <- err
+			return
+		}
+
+		err = readV8ResponseHeaders(v8ctx, argv3, md)
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		vals <- retval
+	}()
+
+	timeout := time.After(time.Millisecond * v8ExecutionTimeoutMillis)
+	heapCheck := time.NewTicker(time.Millisecond * v8IsolateHeapCheckIntervalMillis)
+	defer heapCheck.Stop()
+
+	for {
+		select {
+		case retval := <-vals:
+			return retval, nil
+		case err := <-errs:
+			_, _ = v.stderr.Write([]byte(fmt.Sprintf("v8 execution failed with error: %s", err.Error())))
 			return nil, err
+		case <-heapCheck.C:
+			if isolate.enforceHeapLimit(v.pool.heapLimit) {
+				return nil, fmt.Errorf("v8 execution terminated after exceeding heap limit of %d bytes", v.pool.heapLimit)
+			}
+		case <-timeout:
+			isolate.terminate()
+			return nil, fmt.Errorf("v8 execution timed out after %dms", v8ExecutionTimeoutMillis)
 		}
-		return retval, nil
-	case err := <-errs:
-		_, _ = v.stderr.Write([]byte(fmt.Sprintf("v8 execution failed with error: %s", err.Error())))
+	}
+}
+
+// JavaScript view of a trigger's metadata, passed to functions as their third argument. Functions
+// set headers on their response by adding them to responseHeaders, as strings or arrays of strings
+type v8Trigger struct {
+	Subject         string              `json:"subject"`
+	Reply           string              `json:"reply,omitempty"`
+	MsgID           string              `json:"msgId,omitempty"`
+	Headers         map[string][]string `json:"headers"`
+	ResponseHeaders map[string][]string `json:"responseHeaders"`
+}
+
+func newV8Trigger(v8ctx *v8.Context, md *agentapi.TriggerMetadata) (*v8.Value, error) {
+	trigger := v8Trigger{
+		Subject:         md.Subject,
+		Reply:           md.Reply,
+		MsgID:           md.MsgID,
+		Headers:         md.Headers,
+		ResponseHeaders: map[string][]string{},
+	}
+	if trigger.Headers == nil {
+		trigger.Headers = map[string][]string{}
+	}
+
+	raw, err := json.Marshal(trigger)
+	if err != nil {
 		return nil, err
-	case <-time.After(time.Millisecond * v8ExecutionTimeoutMillis):
-		// if err != nil {
-		// }
+	}
+
+	return v8.JSONParse(v8ctx, string(raw))
+}
 
-		return nil, fmt.Errorf("v8 execution timed out after %dms", v8ExecutionTimeoutMillis)
+func readV8ResponseHeaders(v8ctx *v8.Context, trigger *v8.Value, md *agentapi.TriggerMetadata) error {
+	obj, err := trigger.AsObject()
+	if err != nil {
+		return err
+	}
+
+	headers, err := obj.Get("responseHeaders")
+	if err != nil || !headers.IsObject() {
+		return err
+	}
+
+	raw, err := v8.JSONStringify(v8ctx, headers)
+	if err != nil {
+		return err
 	}
+
+	var values map[string]interface{}
+	err = json.Unmarshal([]byte(raw), &values)
+	if err != nil {
+		return fmt.Errorf("invalid response headers: %s", err)
+	}
+
+	for name, value := range values {
+		switch value := value.(type) {
+		case []interface{}:
+			strs := make([]string, 0, len(value))
+			for _, v := range value {
+				strs = append(strs, fmt.Sprint(v))
+			}
+			md.SetResponseHeader(name, strs...)
+		default:
+			md.SetResponseHeader(name, fmt.Sprint(value))
+		}
+	}
+
+	return nil
 }
 
 func (v *V8) Undeploy() error {
-	// We shouldn't have to do anything here since the script "owns" no resources
+	v.pool.drain()
 	return nil
 }
 
 // Validate has the side effect of compiling the executable javascript source
-// code and setting `ubs` on the underlying V8 execution provider instance.
+// code into the first isolate of the underlying V8 execution provider's pool.
+// Zip and eszip artifacts are treated as module bundles and linked before compilation.
 func (v *V8) Validate() error {
-	if v.iso == nil {
-		return fmt.Errorf("invalid state for validation; v8 isolate not initialized for vm: %s", v.name)
+	if v.pool == nil {
+		return fmt.Errorf("invalid state for validation; v8 isolate pool not initialized for vm: %s", v.name)
 	}
 
-	if v.ctx != nil {
-		return fmt.Errorf("invalid state for validation; default v8 context already initialized for vm: %s", v.name)
+	if v.validated {
+		return fmt.Errorf("invalid state for validation; source already compiled for vm: %s", v.name)
 	}
 
-	v.ctx = v8.NewContext(v.iso) // default context for internal use only
-
-	f, err := os.Open(v.tmpFilename)
+	src, err := readV8Source(v.tmpFilename)
 	if err != nil {
-		return fmt.Errorf("failed to open source: %s", err)
+		return err
 	}
-	defer f.Close()
 
-	fi, err := f.Stat()
+	err = v.pool.init(src, v.tmpFilename)
 	if err != nil {
-		return fmt.Errorf("failed to stat open source file: %s", err)
+		return err
 	}
 
-	if fi.Size() > v8MaxFileSizeBytes {
-		return fmt.Errorf("source file (%d bytes) exceeds maximum of %d bytes", fi.Size(), v8MaxFileSizeBytes)
+	v.validated = true
+	return nil
+}
+
+// Reload replaces the deployed source with the given artifact, which is linked and compiled
+// before it is swapped in; invocations already running complete against the previous source
+func (v *V8) Reload(artifact []byte) error {
+	if !v.validated {
+		return fmt.Errorf("invalid state for reload; source not yet compiled for vm: %s", v.name)
+	}
+
+	staged := v.tmpFilename + ".reload"
+	err := os.WriteFile(staged, artifact, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write reloaded source: %s", err)
 	}
+	defer os.Remove(staged)
 
-	src, err := os.ReadFile(v.tmpFilename)
+	src, err := readV8Source(staged)
 	if err != nil {
-		return fmt.Errorf("failed to open source for validation: %s", err)
+		return err
 	}
 
-	v.ubs, err = v.iso.CompileUnboundScript(string(src), v.tmpFilename, v8.CompileOptions{})
+	err = v.pool.reload(src, v.tmpFilename)
 	if err != nil {
-		return fmt.Errorf("failed to compile source for execution: %s", err)
+		return err
 	}
 
-	v.initUtils()
+	// keeps the artifact on disk in step with the compiled source
+	_ = os.Rename(staged, v.tmpFilename)
 
+	v.pool.warm()
 	return nil
 }
 
-func (v *V8) initUtils() {
+// Reads the source to compile from the given artifact, linking zip and eszip module bundles
+func readV8Source(filename string) (string, error) {
+	if isV8Bundle(filename) {
+		src, err := linkV8Bundle(filename)
+		if err != nil {
+			return "", fmt.Errorf("failed to link module bundle: %s", err)
+		}
+
+		return src, nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to open source: %s", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat open source file: %s", err)
+	}
+
+	if fi.Size() > v8MaxFileSizeBytes {
+		return "", fmt.Errorf("source file (%d bytes) exceeds maximum of %d bytes", fi.Size(), v8MaxFileSizeBytes)
+	}
+
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to open source for validation: %s", err)
+	}
+
+	return string(src), nil
+}
+
+func (v *v8Isolate) initUtils() {
 	append, _ := v.iso.CompileUnboundScript("(arr, value) => { arr.push(value); return arr; };", "array-append.js", v8.CompileOptions{})
 	appendval, _ := append.Run(v.ctx)
 	appendfn, _ := appendval.AsFunction()
@@ -286,9 +468,11 @@ func (v *V8) initUtils() {
 	uint8arrtostrval, _ := uint8arrtostr.Run(v.ctx)
 	uint8arrtostrfn, _ := uint8arrtostrval.AsFunction()
 	v.utils[v8FunctionUInt8ArrayToString] = uint8arrtostrfn
+
+	v.nex, _ = v.iso.CompileUnboundScript(v8NexSource, "nex.js", v8.CompileOptions{})
 }
 
-func (v *V8) newV8Context(ctx context.Context) (*v8.Context, error) {
+func (v *v8Isolate) newV8Context(ctx context.Context) (*v8.Context, error) {
 	global := v8.NewObjectTemplate(v.iso)
 
 	hostServices, err := v.newHostServicesTemplate(ctx)
@@ -301,10 +485,137 @@ func (v *V8) newV8Context(ctx context.Context) (*v8.Context, error) {
 		return nil, err
 	}
 
-	return v8.NewContext(v.iso, global), nil
+	v8ctx := v8.NewContext(v.iso, global)
+
+	err = v.installNex(ctx, v8ctx)
+	if err != nil {
+		v8ctx.Close()
+		return nil, err
+	}
+
+	return v8ctx, nil
 }
 
-func (v *V8) newHostServicesTemplate(ctx context.Context) (*v8.ObjectTemplate, error) {
+// Installs the nex global, which offers the built-in host services to the workload as promises
+// with per-call deadlines and typed errors
+func (v *v8Isolate) installNex(ctx context.Context, v8ctx *v8.Context) error {
+	if v.nex == nil {
+		return errors.New("nex global failed to compile")
+	}
+
+	build, err := v.nex.Run(v8ctx)
+	if err != nil {
+		return err
+	}
+
+	buildfn, err := build.AsFunction()
+	if err != nil {
+		return err
+	}
+
+	definitions, err := v8.JSONParse(v8ctx, v8NexDefinitions)
+	if err != nil {
+		return err
+	}
+
+	call := v8.NewFunctionTemplate(v.iso, v.genNexCallFunc(ctx)).GetFunction(v8ctx)
+
+	nex, err := buildfn.Call(v8ctx.Global(), call, definitions)
+	if err != nil {
+		return fmt.Errorf("failed to build nex global: %s", err)
+	}
+
+	return v8ctx.Global().Set(nexObjectName, nex)
+}
+
+// Performs a host service call for the nex global. Rather than throwing when the call fails, it
+// answers with the code and message of the failure, leaving the nex global to reject the call's
+// promise with the matching error type
+func (v *v8Isolate) genNexCallFunc(ctx context.Context) func(info *v8.FunctionCallbackInfo) *v8.Value {
+	return func(info *v8.FunctionCallbackInfo) *v8.Value {
+		args := info.Args()
+		if len(args) != 6 {
+			val, _ := v8.NewValue(v.iso, "service, method, payload, metadata, timeout and decoding are required")
+			return v.iso.ThrowException(val)
+		}
+
+		service := args[0].String()
+		method := args[1].String()
+		decode := args[5].String()
+
+		payload := []byte{}
+		if args[2].IsString() {
+			payload = []byte(args[2].String())
+		} else if !args[2].IsNullOrUndefined() {
+			var err error
+			payload, err = v.marshalValue(args[2])
+			if err != nil {
+				val, _ := v8.NewValue(v.iso, err.Error())
+				return v.iso.ThrowException(val)
+			}
+		}
+
+		var metadata map[string]string
+		err := json.Unmarshal([]byte(args[3].String()), &metadata)
+		if err != nil {
+			val, _ := v8.NewValue(v.iso, fmt.Sprintf("invalid metadata: %s", err))
+			return v.iso.ThrowException(val)
+		}
+
+		result, err := v8.NewObjectTemplate(v.iso).NewInstance(info.Context())
+		if err != nil {
+			val, _ := v8.NewValue(v.iso, err.Error())
+			return v.iso.ThrowException(val)
+		}
+
+		timeout := time.Duration(args[4].Integer()) * time.Millisecond
+		outcome := performHostService(ctx, v.builtins.RawClient(), service, method, payload, metadata, timeout)
+
+		_ = result.Set("code", int32(outcome.code))
+		_ = result.Set("message", outcome.message)
+		_ = result.Set("retryAfterMs", int32(outcome.retryAfter.Milliseconds()))
+
+		if outcome.ok() {
+			var data *v8.Value
+			if decode == builtins.DecodeBytes {
+				data, err = v.toUInt8ArrayValue(outcome.data)
+			} else if len(outcome.data) > 0 {
+				data, err = v8.JSONParse(info.Context(), string(outcome.data))
+			}
+			if err != nil {
+				val, _ := v8.NewValue(v.iso, fmt.Sprintf("failed to decode result of host service %s.%s: %s", service, method, err))
+				return v.iso.ThrowException(val)
+			}
+			if data != nil {
+				_ = result.Set("data", data)
+			}
+		}
+
+		return result.Value
+	}
+}
+
+// Returns the value a promise returned by the workload's function settled with. Host service
+// calls complete synchronously, so the promise settles once pending microtasks have run
+func settlePromise(v8ctx *v8.Context, val *v8.Value) (*v8.Value, error) {
+	promise, err := val.AsPromise()
+	if err != nil {
+		return nil, err
+	}
+
+	v8ctx.PerformMicrotaskCheckpoint()
+
+	switch promise.State() {
+	case v8.Fulfilled:
+		return promise.Result(), nil
+	case v8.Rejected:
+		return nil, fmt.Errorf("function rejected: %s", promise.Result().String())
+	default:
+		return nil, errors.New("function returned a promise which never settled")
+	}
+}
+
+func (v *v8Isolate) newHostServicesTemplate(ctx context.Context) (*v8.ObjectTemplate, error) {
 	hostServices := v8.NewObjectTemplate(v.iso)
 
 	err := hostServices.Set(hostServicesHTTPObjectName, v.newHTTPObjectTemplate(ctx))
@@ -330,7 +641,7 @@ func (v *V8) newHostServicesTemplate(ctx context.Context) (*v8.ObjectTemplate, e
 	return hostServices, nil
 }
 
-func (v *V8) newHTTPObjectTemplate(ctx context.Context) *v8.ObjectTemplate {
+func (v *v8Isolate) newHTTPObjectTemplate(ctx context.Context) *v8.ObjectTemplate {
 	http := v8.NewObjectTemplate(v.iso)
 
 	_ = http.Set(hostServicesHTTPGetFunctionName, v8.NewFunctionTemplate(
@@ -360,7 +671,7 @@ func (v *V8) newHTTPObjectTemplate(ctx context.Context) *v8.ObjectTemplate {
 	return http
 }
 
-func (v *V8) genHttpClientFunc(ctx context.Context, method string) func(info *v8.FunctionCallbackInfo) *v8.Value {
+func (v *v8Isolate) genHttpClientFunc(ctx context.Context, method string) func(info *v8.FunctionCallbackInfo) *v8.Value {
 	return func(info *v8.FunctionCallbackInfo) *v8.Value {
 		args := info.Args()
 		if len(args) == 0 {
@@ -437,7 +748,7 @@ func (v *V8) genHttpClientFunc(ctx context.Context, method string) func(info *v8
 	}
 }
 
-func (v *V8) newKeyValueObjectTemplate(ctx context.Context) *v8.ObjectTemplate {
+func (v *v8Isolate) newKeyValueObjectTemplate(ctx context.Context) *v8.ObjectTemplate {
 	kv := v8.NewObjectTemplate(v.iso)
 
 	_ = kv.Set(hostServicesKVGetFunctionName, v8.NewFunctionTemplate(v.iso, func(info *v8.FunctionCallbackInfo) *v8.Value {
@@ -540,7 +851,7 @@ func (v *V8) newKeyValueObjectTemplate(ctx context.Context) *v8.ObjectTemplate {
 	return kv
 }
 
-func (v *V8) newMessagingObjectTemplate(ctx context.Context) *v8.ObjectTemplate {
+func (v *v8Isolate) newMessagingObjectTemplate(ctx context.Context) *v8.ObjectTemplate {
 	messaging := v8.NewObjectTemplate(v.iso)
 
 	_ = messaging.Set(hostServicesMessagingPublishFunctionName, v8.NewFunctionTemplate(v.iso, func(info *v8.FunctionCallbackInfo) *v8.Value {
@@ -684,7 +995,7 @@ func (v *V8) newMessagingObjectTemplate(ctx context.Context) *v8.ObjectTemplate
 	return messaging
 }
 
-func (v *V8) newObjectStoreObjectTemplate(ctx context.Context) *v8.ObjectTemplate {
+func (v *v8Isolate) newObjectStoreObjectTemplate(ctx context.Context) *v8.ObjectTemplate {
 	objectStore := v8.NewObjectTemplate(v.iso)
 
 	_ = objectStore.Set(hostServicesObjectStoreGetFunctionName, v8.NewFunctionTemplate(v.iso, func(info *v8.FunctionCallbackInfo) *v8.Value {
@@ -785,7 +1096,7 @@ func (v *V8) newObjectStoreObjectTemplate(ctx context.Context) *v8.ObjectTemplat
 }
 
 // marshal the given v8 value to an array of bytes that can be sent over the wire
-func (v *V8) marshalValue(val *v8.Value) ([]byte, error) {
+func (v *v8Isolate) marshalValue(val *v8.Value) ([]byte, error) {
 	if val.IsUint8Array() {
 		v, err := v.utils[v8FunctionUInt8ArrayToString].Call(v.ctx.Global(), val)
 		if err != nil {
@@ -799,7 +1110,7 @@ func (v *V8) marshalValue(val *v8.Value) ([]byte, error) {
 }
 
 // unmarshal the given []byte value into a native Uint8Array which can be handed back into v8
-func (v *V8) toUInt8ArrayValue(val []byte) (*v8.Value, error) {
+func (v *v8Isolate) toUInt8ArrayValue(val []byte) (*v8.Value, error) {
 	// initialize a v8 value representing the size in bytes of the native Uint8Array to be allocated
 	len, err := v8.NewValue(v.iso, uint64(len(val)))
 	if err != nil {
@@ -858,7 +1169,7 @@ func InitNexExecutionProviderV8(params *agentapi.ExecutionProviderParams) (*V8,
 
 	builtins := builtins.NewBuiltinServicesClient(hsclient)
 
-	return &V8{
+	v := &V8{
 		environment: params.Environment,
 		name:        *params.WorkloadName,
 		namespace:   *params.Namespace,
@@ -875,10 +1186,11 @@ func InitNexExecutionProviderV8(params *agentapi.ExecutionProviderParams) (*V8,
 
 		builtins: builtins,
 
-		nc:  params.NATSConn,
-		ctx: nil,
-		iso: v8.NewIsolate(),
+		nc:     params.NATSConn,
+		cipher: params.TriggerCipher,
+	}
+
+	v.pool = newV8IsolatePool(v, params.Resources)
 
-		utils: make(map[string]*v8.Function),
-	}, nil
+	return v, nil
 }
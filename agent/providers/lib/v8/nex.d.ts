@@ -0,0 +1,103 @@
+// Code generated from the built-in host service definitions. DO NOT EDIT.
+
+// The nex global offered to JavaScript workloads run by the v8 execution provider. Every call
+// returns a promise, which is rejected with a NexError when the host service fails
+declare namespace nex {
+  interface CallOptions {
+    /** Deadline of the call in milliseconds, overriding the agent's default */
+    timeoutMs?: number;
+  }
+
+  /** Strings are sent as their UTF-8 bytes */
+  type Payload = Uint8Array | string;
+
+  class NexError extends Error {
+    readonly service: string;
+    readonly method: string;
+    readonly code: number;
+  }
+
+  /** The call's deadline passed before the host service answered */
+  class TimeoutError extends NexError {}
+
+  /** The workload exhausted its host services budget */
+  class ThrottledError extends NexError {
+    readonly retryAfterMs: number;
+  }
+
+  interface HTTPResponse {
+    status: number;
+    headers?: unknown;
+    body: string;
+    error?: string;
+  }
+
+  interface ObjectInfo {
+    name: string;
+    description?: string;
+    headers?: Record<string, string[]>;
+    metadata?: Record<string, string>;
+    options?: ObjectMetaOptions;
+    bucket: string;
+    nuid: string;
+    size: number;
+    mtime: string;
+    chunks: number;
+    digest?: string;
+    deleted?: boolean;
+  }
+
+  interface ObjectLink {
+    bucket: string;
+    name?: string;
+  }
+
+  interface ObjectMetaOptions {
+    link?: ObjectLink;
+    max_chunk_size?: number;
+  }
+
+  const kv: {
+    /** Reads the value of a key in the workload's bucket */
+    get(key: string, options?: CallOptions): Promise<Uint8Array>;
+    /** Sets the value of a key in the workload's bucket */
+    set(key: string, payload: Payload, options?: CallOptions): Promise<void>;
+    /** Deletes a key from the workload's bucket */
+    delete(key: string, options?: CallOptions): Promise<void>;
+    /** Lists the keys of the workload's bucket */
+    keys(options?: CallOptions): Promise<string[]>;
+  };
+
+  const messaging: {
+    /** Publishes a message */
+    publish(subject: string, payload: Payload, options?: CallOptions): Promise<void>;
+    /** Sends a request and returns the reply */
+    request(subject: string, payload: Payload, options?: CallOptions): Promise<Uint8Array>;
+  };
+
+  const objectStore: {
+    /** Reads an object from the workload's object store */
+    get(name: string, options?: CallOptions): Promise<Uint8Array>;
+    /** Writes an object to the workload's object store */
+    put(name: string, payload: Payload, options?: CallOptions): Promise<ObjectInfo>;
+    /** Deletes an object from the workload's object store */
+    delete(name: string, options?: CallOptions): Promise<void>;
+    /** Lists the objects in the workload's object store */
+    list(options?: CallOptions): Promise<ObjectInfo[]>;
+  };
+
+  const http: {
+    /** Sends an HTTP get request */
+    get(url: string, payload?: Payload, options?: CallOptions): Promise<HTTPResponse>;
+    /** Sends an HTTP post request */
+    post(url: string, payload?: Payload, options?: CallOptions): Promise<HTTPResponse>;
+    /** Sends an HTTP put request */
+    put(url: string, payload?: Payload, options?: CallOptions): Promise<HTTPResponse>;
+    /** Sends an HTTP patch request */
+    patch(url: string, payload?: Payload, options?: CallOptions): Promise<HTTPResponse>;
+    /** Sends an HTTP delete request */
+    delete(url: string, payload?: Payload, options?: CallOptions): Promise<HTTPResponse>;
+    /** Sends an HTTP head request */
+    head(url: string, payload?: Payload, options?: CallOptions): Promise<HTTPResponse>;
+  };
+}
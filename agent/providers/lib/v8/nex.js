@@ -0,0 +1,93 @@
+// Builds the nex global of a v8 context from the built-in host service definitions. Calls are
+// performed by the agent through `call`, which answers with the code, message and data of the
+// host service's result; see nex.d.ts for the API offered to workloads
+((call, definitions) => {
+  class NexError extends Error {
+    constructor(message, service, method, code) {
+      super(message);
+      this.name = 'NexError';
+      this.service = service;
+      this.method = method;
+      this.code = code;
+    }
+  }
+
+  class TimeoutError extends NexError {
+    constructor(message, service, method, code) {
+      super(message, service, method, code);
+      this.name = 'TimeoutError';
+    }
+  }
+
+  class ThrottledError extends NexError {
+    constructor(message, service, method, code, retryAfterMs) {
+      super(message, service, method, code);
+      this.name = 'ThrottledError';
+      this.retryAfterMs = retryAfterMs;
+    }
+  }
+
+  const invoke = (def, args) => {
+    const params = def.params || [];
+    const options = args[params.length] || {};
+
+    const metadata = {};
+    let payload;
+    params.forEach((param, i) => {
+      const value = args[i];
+      if (value === undefined || value === null) {
+        if (!param.optional) {
+          throw new TypeError(`${param.name} is required`);
+        }
+        return;
+      }
+
+      if (param.header) {
+        metadata[param.header] = String(value);
+      } else {
+        payload = value;
+      }
+    });
+
+    const result = call(def.service, def.method, payload, JSON.stringify(metadata), options.timeoutMs || 0, def.decode);
+    const message = result.message || `host service ${def.service}.${def.method} failed`;
+
+    switch (result.code) {
+      case 200:
+        break;
+      case 429:
+        throw new ThrottledError(message, def.service, def.method, result.code, result.retryAfterMs);
+      case 504:
+        throw new TimeoutError(message, def.service, def.method, result.code);
+      default:
+        throw new NexError(message, def.service, def.method, result.code);
+    }
+
+    const data = result.data;
+    switch (def.decode) {
+      case 'status':
+        if (data && (data.success === false || (data.errors && data.errors.length > 0))) {
+          throw new NexError((data.errors || []).join('; ') || message, def.service, def.method, result.code);
+        }
+        return undefined;
+      case 'http':
+        if (data && data.error) {
+          throw new NexError(data.error, def.service, def.method, result.code);
+        }
+        return data;
+      default:
+        return data;
+    }
+  };
+
+  const nex = { NexError, TimeoutError, ThrottledError };
+  for (const def of definitions) {
+    nex[def.binding] = nex[def.binding] || {};
+    nex[def.binding][def.function] = (...args) => new Promise((resolve) => resolve(invoke(def, args)));
+  }
+
+  for (const binding of Object.keys(nex)) {
+    Object.freeze(nex[binding]);
+  }
+  return Object.freeze(nex);
+})
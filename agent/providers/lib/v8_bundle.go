@@ -0,0 +1,551 @@
+package lib
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	v8BundleManifestFilename = "nex.json"
+	v8BundleMaxBytes         = int64(4 * 1024 * 1024)
+)
+
+var (
+	v8DefaultEntrypoints = []string{"index.js", "index.mjs", "main.js", "main.mjs"}
+
+	esImportRe        = regexp.MustCompile(`(?m)^[ \t]*import\s+([\w$*{][\s\S]*?)\s+from\s+['"]([^'"]+)['"][ \t]*;?`)
+	esSideEffectRe    = regexp.MustCompile(`(?m)^[ \t]*import\s+['"]([^'"]+)['"][ \t]*;?`)
+	esExportFromRe    = regexp.MustCompile(`(?m)^[ \t]*export\s+(\*|\*\s+as\s+[\w$]+|\{[\s\S]*?\})\s+from\s+['"]([^'"]+)['"][ \t]*;?`)
+	esExportListRe    = regexp.MustCompile(`(?m)^[ \t]*export\s*\{([\s\S]*?)\}[ \t]*;?`)
+	esExportDefaultRe = regexp.MustCompile(`(?m)^([ \t]*)export\s+default\s+`)
+	esDefaultDeclRe   = regexp.MustCompile(`(?m)^([ \t]*)export\s+default\s+((?:async\s+)?function\s*\*?|class)\s*([A-Za-z_$][\w$]*)`)
+	esExportDeclRe    = regexp.MustCompile(`(?m)^([ \t]*)export\s+((?:async\s+)?function\s*\*?|class|const|let|var)\s*([A-Za-z_$][\w$]*)`)
+)
+
+// Optional manifest at the root of a bundle declaring the entrypoint module, the export
+// invoked for each trigger, and an import map for resolving bare specifiers
+type v8BundleManifest struct {
+	Entrypoint string            `json:"entrypoint,omitempty"`
+	Export     string            `json:"export,omitempty"`
+	Imports    map[string]string `json:"imports,omitempty"`
+}
+
+// Indicates whether the file at the given path is a zip or eszip archive rather than a single script
+func isV8Bundle(filename string) bool {
+	return v8BundleFormat(filename) != ""
+}
+
+// Returns "zip" or "eszip" according to the magic bytes at the start of the file, or an empty
+// string for anything else
+func v8BundleFormat(filename string) string {
+	f, err := os.Open(filename)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(eszipMagicPrefix))
+	_, err = io.ReadFull(f, magic)
+	switch {
+	case err != nil:
+		return ""
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		return "zip"
+	case bytes.Equal(magic, []byte(eszipMagicPrefix)):
+		return "eszip"
+	default:
+		return ""
+	}
+}
+
+// Links the ES module graph in a zip or eszip bundle into a single classic script whose
+// completion value is the entrypoint's declared export, since the embedded V8 bindings cannot
+// compile modules directly. Static import and export declarations are rewritten against a
+// module registry, with every specifier resolved within the bundle at link time.
+//
+// The linker does not parse JavaScript; it recognizes declarations that start a line, outside
+// of comments and string, template and regular expression literals. Declarations spread across
+// lines are supported, but dynamic import(), top-level await, destructured export declarations
+// and more than one declaration per line are not
+func linkV8Bundle(filename string) (string, error) {
+	var sources map[string]string
+	var manifest *v8BundleManifest
+	var err error
+
+	switch v8BundleFormat(filename) {
+	case "zip":
+		sources, manifest, err = readV8ZipBundle(filename)
+	case "eszip":
+		sources, manifest, err = readEszipBundle(filename)
+	default:
+		err = errors.New("not a zip or eszip bundle")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return linkV8Sources(sources, manifest)
+}
+
+// Reads the scripts and the optional manifest of a zip bundle. The bundle's size is checked
+// against what the archive declares and again while it is read, as the declared sizes can lie
+func readV8ZipBundle(filename string) (map[string]string, *v8BundleManifest, error) {
+	archive, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open bundle: %s", err)
+	}
+	defer archive.Close()
+
+	sources := make(map[string]string)
+	manifest := &v8BundleManifest{}
+	var total int64
+
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		if total+int64(f.UncompressedSize64) > v8BundleMaxBytes {
+			return nil, nil, fmt.Errorf("bundle exceeds maximum of %d bytes", v8BundleMaxBytes)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		remaining := v8BundleMaxBytes - total
+		raw, err := io.ReadAll(io.LimitReader(rc, remaining+1))
+		rc.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if int64(len(raw)) > remaining {
+			return nil, nil, fmt.Errorf("bundle exceeds maximum of %d bytes", v8BundleMaxBytes)
+		}
+		total += int64(len(raw))
+
+		name := path.Clean(strings.TrimPrefix(f.Name, "/"))
+		if name == v8BundleManifestFilename {
+			err = json.Unmarshal(raw, manifest)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid bundle manifest: %s", err)
+			}
+			continue
+		}
+
+		if strings.HasSuffix(name, ".js") || strings.HasSuffix(name, ".mjs") {
+			sources[name] = string(raw)
+		}
+	}
+
+	return sources, manifest, nil
+}
+
+func linkV8Sources(sources map[string]string, manifest *v8BundleManifest) (string, error) {
+	entrypoint := manifest.Entrypoint
+	if entrypoint == "" {
+		for _, candidate := range v8DefaultEntrypoints {
+			if _, ok := sources[candidate]; ok {
+				entrypoint = candidate
+				break
+			}
+		}
+	}
+	entrypoint = path.Clean(entrypoint)
+	if _, ok := sources[entrypoint]; !ok {
+		return "", fmt.Errorf("bundle entrypoint %q not found", entrypoint)
+	}
+
+	export := manifest.Export
+	if export == "" {
+		export = "default"
+	}
+
+	linker := &v8BundleLinker{sources: sources, imports: manifest.Imports}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var script strings.Builder
+	script.WriteString("(function() {\nconst __modules = {};\n")
+
+	for _, name := range names {
+		body, err := linker.rewrite(name, sources[name])
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&script, "__modules[%q] = function(__exports, __require) {\n%s\n};\n", name, body)
+	}
+
+	fmt.Fprintf(&script, `const __cache = {};
+function __require(name) {
+  if (!__cache[name]) {
+    __cache[name] = {};
+    __modules[name](__cache[name], __require);
+  }
+  return __cache[name];
+}
+const __entry = __require(%q)[%q];
+if (typeof __entry !== "function") {
+  throw new TypeError("bundle export %s of %s is not a function");
+}
+return __entry;
+})()
+`, entrypoint, export, export, entrypoint)
+
+	return script.String(), nil
+}
+
+type v8BundleLinker struct {
+	sources map[string]string
+	imports map[string]string
+}
+
+// Resolves an import specifier relative to the importing module, the bundle root, or the
+// bundle's import map, trying common extensions and index files
+func (l *v8BundleLinker) resolve(importer, specifier string) (string, error) {
+	var base string
+	switch {
+	case strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../"):
+		base = path.Join(path.Dir(importer), specifier)
+	case strings.HasPrefix(specifier, "/"):
+		base = path.Clean(strings.TrimPrefix(specifier, "/"))
+	default:
+		mapped, ok := l.imports[specifier]
+		if !ok {
+			return "", fmt.Errorf("%s imports %q, which is not in the bundle's import map", importer, specifier)
+		}
+		base = path.Clean(strings.TrimPrefix(mapped, "/"))
+	}
+
+	for _, candidate := range []string{base, base + ".js", base + ".mjs", path.Join(base, "index.js"), path.Join(base, "index.mjs")} {
+		if _, ok := l.sources[candidate]; ok {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s imports %q, which does not resolve to a module in the bundle", importer, specifier)
+}
+
+// Rewrites the static import and export declarations of a module against the registry
+func (l *v8BundleLinker) rewrite(name, src string) (string, error) {
+	var errs []error
+	counter := 0
+	exports := make([]string, 0)
+
+	require := func(specifier string) string {
+		resolved, err := l.resolve(name, specifier)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return fmt.Sprintf("__require(%q)", resolved)
+	}
+
+	src = replaceCode(esExportFromRe, src, func(groups []string) string {
+		counter++
+		module := fmt.Sprintf("__m%d", counter)
+		out := fmt.Sprintf("const %s = %s;", module, require(groups[2]))
+
+		switch {
+		case groups[1] == "*":
+			out += fmt.Sprintf(" for (const __k in %s) { if (__k !== \"default\") Object.defineProperty(__exports, __k, { enumerable: true, get: () => %s[__k] }); }", module, module)
+		case strings.HasPrefix(groups[1], "*"):
+			alias := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(groups[1], "*")), "as"))
+			exports = append(exports, fmt.Sprintf("%q: () => %s", alias, module))
+		default:
+			for _, spec := range splitSpecifiers(groups[1]) {
+				exports = append(exports, fmt.Sprintf("%q: () => %s[%q]", spec[1], module, spec[0]))
+			}
+		}
+		return out
+	})
+
+	src = replaceCode(esImportRe, src, func(groups []string) string {
+		counter++
+		module := fmt.Sprintf("__m%d", counter)
+		out := fmt.Sprintf("const %s = %s;", module, require(groups[2]))
+
+		clause := strings.TrimSpace(groups[1])
+		if !strings.HasPrefix(clause, "{") && !strings.HasPrefix(clause, "*") {
+			defaultName, rest, _ := strings.Cut(clause, ",")
+			out += fmt.Sprintf(" const %s = %s.default;", strings.TrimSpace(defaultName), module)
+			clause = strings.TrimSpace(rest)
+		}
+
+		switch {
+		case strings.HasPrefix(clause, "*"):
+			alias := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(clause, "*")), "as"))
+			out += fmt.Sprintf(" const %s = %s;", alias, module)
+		case strings.HasPrefix(clause, "{"):
+			bindings := make([]string, 0)
+			for _, spec := range splitSpecifiers(clause) {
+				bindings = append(bindings, fmt.Sprintf("%s: %s", spec[0], spec[1]))
+			}
+			out += fmt.Sprintf(" const { %s } = %s;", strings.Join(bindings, ", "), module)
+		}
+		return out
+	})
+
+	src = replaceCode(esSideEffectRe, src, func(groups []string) string {
+		return require(groups[1]) + ";"
+	})
+
+	src = replaceCode(esExportListRe, src, func(groups []string) string {
+		for _, spec := range splitSpecifiers(groups[1]) {
+			exports = append(exports, fmt.Sprintf("%q: () => %s", spec[1], spec[0]))
+		}
+		return ""
+	})
+
+	src = replaceCode(esExportDeclRe, src, func(groups []string) string {
+		exports = append(exports, fmt.Sprintf("%q: () => %s", groups[3], groups[3]))
+		return fmt.Sprintf("%s%s %s", groups[1], strings.TrimSpace(groups[2]), groups[3])
+	})
+
+	// a named default function or class also declares its name within the module
+	src = replaceCode(esDefaultDeclRe, src, func(groups []string) string {
+		exports = append(exports, fmt.Sprintf("%q: () => %s", "default", groups[3]))
+		return fmt.Sprintf("%s%s %s", groups[1], strings.TrimSpace(groups[2]), groups[3])
+	})
+
+	src = replaceCode(esExportDefaultRe, src, func(groups []string) string {
+		return groups[1] + "__exports.default = "
+	})
+
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+
+	// getters give importers live bindings and tolerate exports declared later in the module
+	var prologue strings.Builder
+	for _, export := range exports {
+		name, getter, _ := strings.Cut(export, ": ")
+		fmt.Fprintf(&prologue, "Object.defineProperty(__exports, %s, { enumerable: true, get: %s });\n", name, getter)
+	}
+
+	return prologue.String() + src, nil
+}
+
+// Replaces the matches of the expression that lie in code, i.e. not in comments or literals.
+// The expression is matched against the masked source and the groups handed to the replacement
+// are taken from the original, so specifiers keep their text
+func replaceCode(re *regexp.Regexp, src string, replace func(groups []string) string) string {
+	matches := re.FindAllStringSubmatchIndex(maskJsSource(src), -1)
+	if len(matches) == 0 {
+		return src
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, loc := range matches {
+		groups := make([]string, len(loc)/2)
+		for i := range groups {
+			if loc[2*i] >= 0 {
+				groups[i] = src[loc[2*i]:loc[2*i+1]]
+			}
+		}
+
+		out.WriteString(src[last:loc[0]])
+		out.WriteString(replace(groups))
+		last = loc[1]
+	}
+	out.WriteString(src[last:])
+
+	return out.String()
+}
+
+// Returns a copy of the source in which the contents of comments and of string, template and
+// regular expression literals are blanked out. Delimiters, line breaks and offsets are kept
+func maskJsSource(src string) string {
+	out := []byte(src)
+	blank := func(from, to int) {
+		for i := from; i < to && i < len(out); i++ {
+			if out[i] != '\n' && out[i] != '\r' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	lastCode := -1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			blank(i, i+end)
+			i += end
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			stop := len(src)
+			if end >= 0 {
+				stop = i + 2 + end + 2
+			}
+			blank(i, stop)
+			i = stop
+			continue
+		case c == '\'' || c == '"' || c == '`' || (c == '/' && jsRegexAllowed(src, lastCode)):
+			var end int
+			switch c {
+			case '`':
+				end = skipJsTemplate(src, i)
+			case '/':
+				end = skipJsRegex(src, i)
+			default:
+				end = skipJsString(src, i)
+			}
+
+			// keep the closing delimiter of terminated strings and templates
+			blank(i+1, end)
+			if c != '/' && end-1 > i && src[end-1] == c {
+				out[end-1] = c
+			}
+			lastCode, i = end-1, end
+			continue
+		}
+
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			lastCode = i
+		}
+		i++
+	}
+
+	return string(out)
+}
+
+// Returns the offset just past the string literal opening at the given offset. Unterminated
+// strings end at the line break
+func skipJsString(src string, start int) int {
+	quote := src[start]
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			return i
+		}
+	}
+	return len(src)
+}
+
+// Returns the offset just past the template literal opening at the given offset, skipping over
+// the strings, templates and braces within its substitutions
+func skipJsTemplate(src string, start int) int {
+	for i := start + 1; i < len(src); i++ {
+		switch {
+		case src[i] == '\\':
+			i++
+		case src[i] == '`':
+			return i + 1
+		case src[i] == '$' && i+1 < len(src) && src[i+1] == '{':
+			depth := 1
+			for i += 2; i < len(src) && depth > 0; i++ {
+				switch src[i] {
+				case '{':
+					depth++
+				case '}':
+					depth--
+				case '\'', '"':
+					i = skipJsString(src, i) - 1
+				case '`':
+					i = skipJsTemplate(src, i) - 1
+				}
+			}
+			i--
+		}
+	}
+	return len(src)
+}
+
+// Returns the offset just past the regular expression literal opening at the given offset,
+// including its flags
+func skipJsRegex(src string, start int) int {
+	inClass := false
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '\n':
+			return i
+		case '/':
+			if !inClass {
+				for i++; i < len(src) && isJsIdentChar(src[i]); i++ {
+				}
+				return i
+			}
+		}
+	}
+	return len(src)
+}
+
+// Indicates whether a slash following the given code character starts a regular expression
+// literal rather than a division: it does after punctuators and keywords such as return, but
+// not after identifiers, literals or closing brackets
+func jsRegexAllowed(src string, lastCode int) bool {
+	if lastCode < 0 {
+		return true
+	}
+
+	c := src[lastCode]
+	if isJsIdentChar(c) {
+		start := lastCode
+		for start > 0 && isJsIdentChar(src[start-1]) {
+			start--
+		}
+		switch src[start : lastCode+1] {
+		case "return", "typeof", "instanceof", "in", "of", "new", "delete", "void", "throw", "case", "do", "else", "yield", "await":
+			return true
+		}
+		return false
+	}
+
+	return strings.IndexByte("(,=:[!&|?{};+-*%<>~^", c) >= 0
+}
+
+func isJsIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// Splits an import or export specifier list such as `{ a, b as c }` into pairs of
+// (source name, bound name)
+func splitSpecifiers(list string) [][2]string {
+	specifiers := make([][2]string, 0)
+
+	list = strings.Trim(strings.TrimSpace(list), "{}")
+	for _, specifier := range strings.Split(list, ",") {
+		specifier = strings.TrimSpace(specifier)
+		if specifier == "" {
+			continue
+		}
+
+		source, bound, ok := strings.Cut(specifier, " as ")
+		if !ok {
+			bound = source
+		}
+
+		specifiers = append(specifiers, [2]string{strings.TrimSpace(source), strings.TrimSpace(bound)})
+	}
+
+	return specifiers
+}
@@ -0,0 +1,256 @@
+package lib
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeZipBundle(t *testing.T, files map[string]string) string {
+	filename := filepath.Join(t.TempDir(), "bundle.zip")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatalf("Failed to create bundle: %s", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to write bundle: %s", err)
+	}
+
+	return filename
+}
+
+func TestLinkV8BundleRewritesDeclarations(t *testing.T) {
+	filename := writeZipBundle(t, map[string]string{
+		"nex.json": `{"entrypoint": "main.js", "export": "handler"}`,
+		"main.js": `import greet, { shout as loud } from "./lib/greet.js";
+import * as util from "./lib/util.js";
+
+export async function handler(subject, payload) {
+  return loud(greet(util.name(subject)));
+}
+`,
+		"lib/greet.js": `export default function greet(name) {
+  return "hello " + name;
+}
+export function shout(s) { return greet(s).toUpperCase(); }
+`,
+		"lib/util.js": `export const name = (s) => s;
+`,
+	})
+
+	script, err := linkV8Bundle(filename)
+	if err != nil {
+		t.Fatalf("Failed to link bundle: %s", err)
+	}
+
+	for _, expected := range []string{
+		`const __m1 = __require("lib/greet.js"); const greet = __m1.default; const { shout: loud } = __m1;`,
+		`const __m2 = __require("lib/util.js"); const util = __m2;`,
+		`Object.defineProperty(__exports, "handler", { enumerable: true, get: () => handler });`,
+		`Object.defineProperty(__exports, "default", { enumerable: true, get: () => greet });`,
+		"function greet(name) {",
+		`const __entry = __require("main.js")["handler"];`,
+	} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("Expected linked script to contain %q:\n%s", expected, script)
+		}
+	}
+
+	if strings.Contains(script, "\nexport ") || strings.Contains(script, "\nimport ") {
+		t.Errorf("Expected every declaration to be rewritten:\n%s", script)
+	}
+}
+
+func TestLinkV8BundleLeavesLiteralsAndCommentsAlone(t *testing.T) {
+	source := `const doc = ` + "`" + `
+import x from "./missing.js";
+export default ${"value"}
+` + "`" + `;
+// import y from "./missing.js";
+/*
+export default 42;
+*/
+const re = /['"]/g;
+const quoted = 'export default "nope"';
+export default function handler() { return doc + quoted + re; }
+`
+	filename := writeZipBundle(t, map[string]string{"index.js": source})
+
+	script, err := linkV8Bundle(filename)
+	if err != nil {
+		t.Fatalf("Expected declarations inside literals and comments to be ignored: %s", err)
+	}
+
+	for _, kept := range []string{
+		"import x from \"./missing.js\";\nexport default ${\"value\"}",
+		`// import y from "./missing.js";`,
+		"export default 42;",
+		`'export default "nope"'`,
+		"function handler() {",
+	} {
+		if !strings.Contains(script, kept) {
+			t.Errorf("Expected linked script to keep %q:\n%s", kept, script)
+		}
+	}
+}
+
+func TestLinkV8BundleRejectsUnresolvedImports(t *testing.T) {
+	filename := writeZipBundle(t, map[string]string{
+		"index.js": `import { missing } from "./nowhere.js";
+import lodash from "lodash";
+export default function handler() {}
+`,
+	})
+
+	_, err := linkV8Bundle(filename)
+	if err == nil || !strings.Contains(err.Error(), "nowhere.js") || !strings.Contains(err.Error(), "lodash") {
+		t.Fatalf("Expected both unresolved imports to be reported, got %v", err)
+	}
+}
+
+func TestLinkV8BundleBoundsDecompressedSize(t *testing.T) {
+	// the entry claims to be tiny but inflates past the bundle limit
+	payload := bytes.Repeat([]byte{' '}, int(v8BundleMaxBytes)+1)
+	var compressed bytes.Buffer
+	fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	_, _ = fw.Write(payload)
+	_ = fw.Close()
+
+	filename := filepath.Join(t.TempDir(), "bomb.zip")
+	f, _ := os.Create(filename)
+	zw := zip.NewWriter(f)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "index.js",
+		Method:             zip.Deflate,
+		CRC32:              crc32.ChecksumIEEE(payload),
+		CompressedSize64:   uint64(compressed.Len()),
+		UncompressedSize64: 16,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create raw entry: %s", err)
+	}
+	_, _ = w.Write(compressed.Bytes())
+	_ = zw.Close()
+	f.Close()
+
+	_, err = linkV8Bundle(filename)
+	if err == nil {
+		t.Fatal("Expected an entry inflating past the bundle limit to be rejected")
+	}
+}
+
+// Builds an eszip v2 archive holding the given JavaScript modules, in order
+func writeEszipBundle(t *testing.T, modules [][2]string, redirects map[string]string) string {
+	var header, sources bytes.Buffer
+	putString := func(b *bytes.Buffer, s string) {
+		_ = binary.Write(b, binary.BigEndian, uint32(len(s)))
+		b.WriteString(s)
+	}
+
+	for _, module := range modules {
+		putString(&header, module[0])
+		header.WriteByte(eszipEntryModule)
+		_ = binary.Write(&header, binary.BigEndian, uint32(sources.Len()))
+		_ = binary.Write(&header, binary.BigEndian, uint32(len(module[1])))
+		_ = binary.Write(&header, binary.BigEndian, uint32(0))
+		_ = binary.Write(&header, binary.BigEndian, uint32(0))
+		header.WriteByte(eszipModuleJavaScript)
+
+		digest := sha256.Sum256([]byte(module[1]))
+		sources.WriteString(module[1])
+		sources.Write(digest[:])
+	}
+	for specifier, target := range redirects {
+		putString(&header, specifier)
+		header.WriteByte(eszipEntryRedirect)
+		putString(&header, target)
+	}
+
+	var archive bytes.Buffer
+	archive.WriteString(eszipV2Magic)
+	_ = binary.Write(&archive, binary.BigEndian, uint32(header.Len()))
+	archive.Write(header.Bytes())
+	digest := sha256.Sum256(header.Bytes())
+	archive.Write(digest[:])
+	_ = binary.Write(&archive, binary.BigEndian, uint32(sources.Len()))
+	archive.Write(sources.Bytes())
+	_ = binary.Write(&archive, binary.BigEndian, uint32(0))
+
+	filename := filepath.Join(t.TempDir(), "bundle.eszip")
+	if err := os.WriteFile(filename, archive.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to write eszip: %s", err)
+	}
+
+	return filename
+}
+
+func TestLinkV8BundleReadsEszip(t *testing.T) {
+	filename := writeEszipBundle(t, [][2]string{
+		{"file:///app/main.js", `import { add } from "./math.js";
+import { VERSION } from "https://deno.land/x/mod.js";
+export default function handler(a, b) { return add(a, b) + VERSION; }
+`},
+		{"file:///app/math.js", "export const add = (a, b) => a + b;\n"},
+		{"https://deno.land/x/mod@1.0.0/mod.js", "export const VERSION = 1;\n"},
+	}, map[string]string{"https://deno.land/x/mod.js": "https://deno.land/x/mod@1.0.0/mod.js"})
+
+	if !isV8Bundle(filename) {
+		t.Fatal("Expected an eszip archive to be recognized as a bundle")
+	}
+
+	script, err := linkV8Bundle(filename)
+	if err != nil {
+		t.Fatalf("Failed to link eszip bundle: %s", err)
+	}
+
+	for _, expected := range []string{
+		`__require("app/math.js")`,
+		`__require("deno.land/x/mod@1.0.0/mod.js")`,
+		`const __entry = __require("app/main.js")["default"];`,
+	} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("Expected linked script to contain %q:\n%s", expected, script)
+		}
+	}
+}
+
+func TestLinkV8BundleRejectsCorruptEszip(t *testing.T) {
+	filename := writeEszipBundle(t, [][2]string{{"file:///main.js", "export default function handler() {}\n"}}, nil)
+
+	raw, _ := os.ReadFile(filename)
+	raw[len(raw)-sha256.Size-8] ^= 0xff // a byte of the module's source
+	_ = os.WriteFile(filename, raw, 0600)
+
+	_, err := linkV8Bundle(filename)
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("Expected a corrupted source to fail its checksum, got %v", err)
+	}
+}
+
+func TestMaskJsSourceKeepsOffsets(t *testing.T) {
+	src := "a = b / c; d = /x'y/g.test(e) ? `t${'}'}` : \"q\"; // c\n"
+	masked := maskJsSource(src)
+
+	if len(masked) != len(src) {
+		t.Fatalf("Expected masking to keep the length, got %d for %d", len(masked), len(src))
+	}
+
+	expected := "a = b / c; d = /     .test(e) ? `       ` : \" \";     \n"
+	if masked != expected {
+		t.Fatalf("Unexpected masked source:\n%q\n%q", masked, expected)
+	}
+}
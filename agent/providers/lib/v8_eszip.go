@@ -0,0 +1,248 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	eszipMagicPrefix = "ESZIP"
+
+	eszipV2Magic  = "ESZIP_V2"
+	eszipV21Magic = "ESZIP2.1"
+
+	eszipEntryModule   = 0
+	eszipEntryRedirect = 1
+	eszipEntryNpm      = 2
+
+	eszipModuleJavaScript = 0
+	eszipModuleJson       = 1
+)
+
+// A module listed in the header of an eszip archive
+type eszipModule struct {
+	specifier string
+	kind      byte
+	offset    uint32
+	length    uint32
+}
+
+// Reads the modules of an eszip archive, as produced by Deno, keyed by names derived from their
+// specifiers: file:///app/main.js becomes app/main.js and https://host/mod.js becomes host/mod.js.
+// Every specifier and redirect is added to the import map, so absolute imports resolve to the
+// bundled module. The first JavaScript module in the archive is the entrypoint.
+//
+// Version 2 and 2.1 archives are supported, checking the SHA-256 digest of the header and of
+// every source. npm packages, and the configurable checksums of later versions, are not
+func readEszipBundle(filename string) (map[string]string, *v8BundleManifest, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open bundle: %s", err)
+	}
+	defer f.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(f, v8BundleMaxBytes+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(len(raw)) > v8BundleMaxBytes {
+		return nil, nil, fmt.Errorf("bundle exceeds maximum of %d bytes", v8BundleMaxBytes)
+	}
+
+	r := &eszipReader{raw: raw}
+
+	magic := string(r.next(len(eszipV2Magic)))
+	if magic != eszipV2Magic && magic != eszipV21Magic {
+		return nil, nil, fmt.Errorf("unsupported eszip version %q; only %s and %s archives are supported", magic, eszipV2Magic, eszipV21Magic)
+	}
+
+	header := r.section(true)
+	if magic == eszipV21Magic {
+		if npm := r.section(true); len(npm) > 0 {
+			r.fail(errors.New("eszip bundles with npm packages are not supported"))
+		}
+	}
+	sources := r.section(false)
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+
+	modules, redirects, err := parseEszipHeader(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bundle := make(map[string]string)
+	manifest := &v8BundleManifest{Imports: make(map[string]string)}
+
+	for _, module := range modules {
+		end := uint64(module.offset) + uint64(module.length)
+		if end+sha256.Size > uint64(len(sources)) {
+			return nil, nil, fmt.Errorf("eszip source of %s is out of bounds", module.specifier)
+		}
+
+		src := sources[module.offset:end]
+		digest := sha256.Sum256(src)
+		if !bytes.Equal(digest[:], sources[end:end+sha256.Size]) {
+			return nil, nil, fmt.Errorf("eszip source of %s does not match its checksum", module.specifier)
+		}
+
+		name, err := eszipModuleName(module.specifier)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch module.kind {
+		case eszipModuleJavaScript:
+			bundle[name] = string(src)
+			if manifest.Entrypoint == "" {
+				manifest.Entrypoint = name
+			}
+		case eszipModuleJson:
+			if !json.Valid(src) {
+				return nil, nil, fmt.Errorf("eszip JSON module %s is not valid JSON", module.specifier)
+			}
+			bundle[name] = fmt.Sprintf("export default %s;", src)
+		default:
+			continue
+		}
+
+		manifest.Imports[module.specifier] = name
+	}
+
+	for specifier, target := range redirects {
+		name, err := eszipModuleName(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		manifest.Imports[specifier] = name
+	}
+
+	if manifest.Entrypoint == "" {
+		return nil, nil, errors.New("eszip bundle contains no JavaScript modules")
+	}
+
+	return bundle, manifest, nil
+}
+
+// Lists the modules and the redirects, from specifier to target, in an eszip header
+func parseEszipHeader(header []byte) ([]eszipModule, map[string]string, error) {
+	r := &eszipReader{raw: header}
+	modules := make([]eszipModule, 0)
+	redirects := make(map[string]string)
+
+	for r.err == nil && r.pos < len(r.raw) {
+		specifier := r.string()
+		kind := r.byte()
+
+		switch kind {
+		case eszipEntryModule:
+			module := eszipModule{specifier: specifier}
+			module.offset = r.uint32()
+			module.length = r.uint32()
+			_ = r.uint32() // source map offset
+			_ = r.uint32() // source map length
+			module.kind = r.byte()
+			modules = append(modules, module)
+		case eszipEntryRedirect:
+			redirects[specifier] = r.string()
+		case eszipEntryNpm:
+			r.fail(fmt.Errorf("eszip module %s is an npm package, which is not supported", specifier))
+		default:
+			r.fail(fmt.Errorf("eszip module %s has unknown entry kind %d", specifier, kind))
+		}
+	}
+
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+
+	return modules, redirects, nil
+}
+
+// Derives a bundle name from a module specifier, so that relative imports resolve by path
+func eszipModuleName(specifier string) (string, error) {
+	u, err := url.Parse(specifier)
+	if err != nil || u.Scheme == "" {
+		return "", fmt.Errorf("eszip module specifier %q is not a URL", specifier)
+	}
+
+	name := path.Clean(strings.TrimPrefix(path.Join(u.Host, u.Path), "/"))
+	if name == "." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("eszip module specifier %q does not name a module", specifier)
+	}
+
+	return name, nil
+}
+
+// Reads the big-endian fields of an eszip archive, remembering the first error so that callers
+// can check once after a series of reads
+type eszipReader struct {
+	raw []byte
+	pos int
+	err error
+}
+
+func (r *eszipReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *eszipReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.raw)-r.pos {
+		r.fail(errors.New("eszip bundle is truncated"))
+		return nil
+	}
+
+	b := r.raw[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *eszipReader) byte() byte {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *eszipReader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *eszipReader) string() string {
+	return string(r.next(int(r.uint32())))
+}
+
+// Reads a length-prefixed section, followed by its SHA-256 digest when checksummed
+func (r *eszipReader) section(checksummed bool) []byte {
+	content := r.next(int(r.uint32()))
+	if !checksummed || r.err != nil {
+		return content
+	}
+
+	digest := sha256.Sum256(content)
+	if !bytes.Equal(digest[:], r.next(sha256.Size)) && r.err == nil {
+		r.fail(errors.New("eszip section does not match its checksum"))
+	}
+
+	return content
+}
@@ -0,0 +1,11 @@
+package lib
+
+import _ "embed"
+
+//go:generate go run ../../../host-services/builtins/tsgen v8/nex.d.ts
+
+// Builds the nex global offered to JavaScript workloads. Its TypeScript definitions in v8/nex.d.ts
+// are generated from the built-in host service definitions
+//
+//go:embed v8/nex.js
+var v8NexSource string
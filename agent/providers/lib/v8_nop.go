@@ -19,6 +19,8 @@ func (V8) Undeploy() error { return nil }
 
 func (V8) Validate() error { return nil }
 
+func (V8) Reload(artifact []byte) error { return nil }
+
 func InitNexExecutionProviderV8(params *agentapi.ExecutionProviderParams) (*V8, error) {
 	return nil, errors.New("V8 is not supported on this platform")
 }
@@ -0,0 +1,310 @@
+//go:build linux && amd64
+
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	v8 "rogchap.com/v8go"
+)
+
+const (
+	v8DefaultIsolatePoolSize         = 4
+	v8DefaultIsolateHeapLimitMib     = 128
+	v8DefaultIsolateInvocations      = 1000
+	v8DefaultIsolateAllocatedMib     = 64
+	v8IsolateHeapCheckIntervalMillis = 10
+)
+
+// Isolate holding its own compiled copy of the workload script. Host services and the
+// Uint8Array helpers are bound per isolate, since v8 values cannot cross isolates
+type v8Isolate struct {
+	*V8
+
+	ctx   *v8.Context // default context for internal use only
+	iso   *v8.Isolate
+	ubs   *v8.UnboundScript
+	utils map[string]*v8.Function //v8.UnboundScript
+
+	// builds the nex global of each context the isolate runs the workload in
+	nex *v8.UnboundScript
+
+	invocations int
+	terminated  atomic.Bool
+
+	// source generation the isolate was compiled from; stale isolates are not returned to the pool
+	generation int
+
+	// guards the isolate against being inspected or terminated once an invocation completes
+	mutex   sync.Mutex
+	running bool
+}
+
+// Returns the combined size of the isolate's heap and its malloc'd memory, in bytes
+func (i *v8Isolate) allocatedBytes() uint64 {
+	stats := i.iso.GetHeapStatistics()
+	return stats.TotalHeapSize + stats.MallocedMemory
+}
+
+func (i *v8Isolate) start() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.running = true
+}
+
+func (i *v8Isolate) stop() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.running = false
+}
+
+// Terminates the running script, if any, and marks the isolate so it is disposed on release
+func (i *v8Isolate) terminate() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.running {
+		i.terminated.Store(true)
+		i.iso.TerminateExecution()
+	}
+}
+
+// Terminates the running script if the isolate's used heap exceeds the given limit
+func (i *v8Isolate) enforceHeapLimit(limit uint64) bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if !i.running || i.iso.GetHeapStatistics().UsedHeapSize <= limit {
+		return false
+	}
+
+	i.terminated.Store(true)
+	i.iso.TerminateExecution()
+	return true
+}
+
+func (i *v8Isolate) dispose() {
+	i.ctx.Close()
+	i.iso.Dispose()
+}
+
+// Pool of isolates for a single v8 workload. Isolates are compiled ahead of use so
+// invocations do not pay the cold-start cost, and are recycled once they have served
+// too many invocations or grown too large, bounding the memory any one function can hold
+type v8IsolatePool struct {
+	owner      *V8
+	src        string
+	origin     string
+	generation int
+
+	size           int
+	heapLimit      uint64
+	maxInvocations int
+	maxAllocated   uint64
+
+	mutex sync.Mutex
+	idle  []*v8Isolate
+	slots chan struct{}
+}
+
+func newV8IsolatePool(owner *V8, resources *controlapi.WorkloadResources) *v8IsolatePool {
+	config := controlapi.IsolatePoolConfig{}
+	if resources != nil && resources.Isolates != nil {
+		config = *resources.Isolates
+	}
+
+	if config.PoolSize <= 0 {
+		config.PoolSize = v8DefaultIsolatePoolSize
+	}
+	if config.HeapLimitMib <= 0 {
+		config.HeapLimitMib = v8DefaultIsolateHeapLimitMib
+		if resources != nil && resources.MemoryMib > 0 {
+			config.HeapLimitMib = resources.MemoryMib
+		}
+	}
+	if config.MaxInvocations <= 0 {
+		config.MaxInvocations = v8DefaultIsolateInvocations
+	}
+	if config.MaxAllocatedMib <= 0 {
+		config.MaxAllocatedMib = min(v8DefaultIsolateAllocatedMib, config.HeapLimitMib)
+	}
+
+	return &v8IsolatePool{
+		owner:          owner,
+		size:           config.PoolSize,
+		heapLimit:      uint64(config.HeapLimitMib) * 1024 * 1024,
+		maxInvocations: config.MaxInvocations,
+		maxAllocated:   uint64(config.MaxAllocatedMib) * 1024 * 1024,
+		idle:           make([]*v8Isolate, 0, config.PoolSize),
+		slots:          make(chan struct{}, config.PoolSize),
+	}
+}
+
+// Compiles the given source into a new isolate, which is kept warm for the first invocation
+func (p *v8IsolatePool) init(src, origin string) error {
+	p.src = src
+	p.origin = origin
+
+	isolate, err := p.newIsolate()
+	if err != nil {
+		return err
+	}
+
+	p.idle = append(p.idle, isolate)
+	return nil
+}
+
+// Fills the pool with warm isolates in the background
+func (p *v8IsolatePool) warm() {
+	go func() {
+		for {
+			p.mutex.Lock()
+			full := len(p.idle)+len(p.slots) >= p.size
+			p.mutex.Unlock()
+			if full {
+				return
+			}
+
+			isolate, err := p.newIsolate()
+			if err != nil {
+				_, _ = p.owner.stderr.Write([]byte(fmt.Sprintf("failed to warm v8 isolate: %s", err.Error())))
+				return
+			}
+
+			if !p.put(isolate) {
+				isolate.dispose()
+				return
+			}
+		}
+	}()
+}
+
+// Waits for a free slot and returns an idle isolate, compiling a new one if none is warm
+func (p *v8IsolatePool) acquire() (*v8Isolate, error) {
+	p.slots <- struct{}{}
+
+	p.mutex.Lock()
+	if len(p.idle) > 0 {
+		isolate := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mutex.Unlock()
+		return isolate, nil
+	}
+	p.mutex.Unlock()
+
+	isolate, err := p.newIsolate()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	return isolate, nil
+}
+
+// Returns an isolate to the pool after an invocation, disposing of it and warming a
+// replacement if it was terminated or has reached its recycling thresholds
+func (p *v8IsolatePool) release(isolate *v8Isolate) {
+	defer func() { <-p.slots }()
+
+	isolate.invocations++
+	if isolate.terminated.Load() || isolate.invocations >= p.maxInvocations || isolate.allocatedBytes() >= p.maxAllocated {
+		isolate.dispose()
+		p.warm()
+		return
+	}
+
+	if !p.put(isolate) {
+		isolate.dispose()
+	}
+}
+
+func (p *v8IsolatePool) put(isolate *v8Isolate) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.idle == nil || len(p.idle) >= p.size || isolate.generation != p.generation {
+		return false
+	}
+
+	p.idle = append(p.idle, isolate)
+	return true
+}
+
+// Disposes of every idle isolate; isolates still executing are disposed on release
+func (p *v8IsolatePool) drain() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, isolate := range p.idle {
+		isolate.dispose()
+	}
+	p.idle = nil
+}
+
+// Replaces the source isolates are compiled from. The new source is compiled before anything is
+// swapped, so a source that fails to compile leaves the pool untouched. Idle isolates compiled from
+// the previous source are disposed, and those still executing are disposed on release
+func (p *v8IsolatePool) reload(src, origin string) error {
+	p.mutex.Lock()
+	generation := p.generation + 1
+	p.mutex.Unlock()
+
+	isolate, err := p.compile(src, origin, generation)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.idle == nil {
+		isolate.dispose()
+		return errors.New("isolate pool has been drained")
+	}
+
+	p.src = src
+	p.origin = origin
+	p.generation = generation
+
+	for _, stale := range p.idle {
+		stale.dispose()
+	}
+	p.idle = append(p.idle[:0], isolate)
+
+	return nil
+}
+
+func (p *v8IsolatePool) newIsolate() (*v8Isolate, error) {
+	p.mutex.Lock()
+	src, origin, generation := p.src, p.origin, p.generation
+	p.mutex.Unlock()
+
+	return p.compile(src, origin, generation)
+}
+
+func (p *v8IsolatePool) compile(src, origin string, generation int) (*v8Isolate, error) {
+	iso := v8.NewIsolate()
+
+	ubs, err := iso.CompileUnboundScript(src, origin, v8.CompileOptions{})
+	if err != nil {
+		iso.Dispose()
+		return nil, fmt.Errorf("failed to compile source for execution: %s", err)
+	}
+
+	isolate := &v8Isolate{
+		V8:    p.owner,
+		ctx:   v8.NewContext(iso),
+		iso:   iso,
+		ubs:   ubs,
+		utils: make(map[string]*v8.Function),
+
+		generation: generation,
+	}
+	isolate.initUtils()
+
+	return isolate, nil
+}
@@ -1,14 +1,25 @@
 package lib
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go"
+	hostservices "github.com/synadia-io/nex/host-services"
 	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/events"
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
 	"github.com/tetratelabs/wazero/sys"
@@ -16,36 +27,65 @@ import (
 	"go.opentelemetry.io/otel/propagation"
 )
 
-// Wasm execution provider implementation
+const wasmCompilationCacheDirName = "nex-wasm-cache"
+
+// Wasm execution provider implementation. Modules are compiled ahead of time during
+// validation, and the compiled code is cached in the workload cache bucket keyed by the
+// module's hash so the node can hand it to later deployments of the same module
 type Wasm struct {
 	vmID          string
+	name          string
+	hash          string
 	wasmFile      []byte
 	env           map[string]string
 	runtime       wazero.Runtime
 	runtimeConfig wazero.ModuleConfig
 	module        wazero.CompiledModule
 
+	// guards the compiled module, which is swapped when the workload is hot reloaded
+	mutex sync.RWMutex
+
+	cacheBucket nats.ObjectStore
+	cacheDir    string
+	cacheHit    bool
+
 	fail chan bool
 	run  chan bool
 	exit chan int
 
-	nc *nats.Conn // agent NATS connection
+	hostServices *hostservices.HostServicesClient
+
+	nc     *nats.Conn // agent NATS connection
+	cipher *agentapi.PayloadCipher
 }
 
 func (e *Wasm) Deploy() error {
 	subject := fmt.Sprintf("agentint.%s.trigger", e.vmID)
 	_, err := e.nc.Subscribe(subject, func(msg *nats.Msg) {
+		md := agentapi.TriggerMetadataFromMsg(msg)
 		ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(msg.Header))
-		ctx = context.WithValue(ctx, agentapi.NexTriggerSubject, subject) //nolint:all
+		ctx = agentapi.WithTriggerMetadata(ctx, md)
+
+		payload, err := e.cipher.Open(msg.Data)
+		if err != nil {
+			return
+		}
 
-		val, err := e.Execute(ctx, msg.Data)
+		val, err := e.Execute(ctx, payload)
 		if err != nil {
 			// TODO-- propagate this error to agent logs
 			return
 		}
 
 		if len(val) > 0 {
-			_ = msg.Respond(val)
+			val, err = e.cipher.Seal(val)
+			if err != nil {
+				return
+			}
+
+			header := nats.Header{}
+			agentapi.EncodeResponseHeaders(md, header)
+			_ = msg.RespondMsg(&nats.Msg{Data: val, Header: header})
 		}
 	})
 	if err != nil {
@@ -75,7 +115,11 @@ func (e *Wasm) Execute(ctx context.Context, payload []byte) ([]byte, error) {
 		WithStdout(out).
 		WithArgs("nexfunction", subject)
 
-	_, err := e.runtime.InstantiateModule(ctx, e.module, cfg)
+	e.mutex.RLock()
+	module := e.module
+	e.mutex.RUnlock()
+
+	_, err := e.runtime.InstantiateModule(withWasmCallState(ctx), module, cfg)
 	if err != nil {
 		if exitErr, ok := err.(*sys.ExitError); ok && exitErr.ExitCode() != 0 {
 			// TODO: log error
@@ -98,7 +142,25 @@ func (e *Wasm) Undeploy() error {
 
 func (e *Wasm) Validate() error {
 	ctx := context.Background()
-	e.runtime = wazero.NewRuntime(ctx)
+
+	runtimeConfig := wazero.NewRuntimeConfig()
+
+	e.cacheDir = filepath.Join(os.TempDir(), wasmCompilationCacheDirName, e.hash)
+	err := os.MkdirAll(e.cacheDir, 0755)
+	if err == nil {
+		e.cacheHit = e.restoreCompilationCache()
+
+		cache, err := wazero.NewCompilationCacheWithDir(e.cacheDir)
+		if err == nil {
+			runtimeConfig = runtimeConfig.WithCompilationCache(cache)
+		} else {
+			e.cacheDir = ""
+		}
+	} else {
+		e.cacheDir = ""
+	}
+
+	e.runtime = wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
 	e.runtimeConfig = wazero.NewModuleConfig().
 		WithStderr(os.Stderr)
 
@@ -106,8 +168,6 @@ func (e *Wasm) Validate() error {
 		e.runtimeConfig = e.runtimeConfig.WithEnv(key, val)
 	}
 
-	var err error
-
 	// Instantiate WASI, which implements system I/O such as console output.
 	wasimod, err := wasi_snapshot_preview1.NewBuilder(e.runtime).Compile(ctx)
 	if err != nil {
@@ -119,14 +179,100 @@ func (e *Wasm) Validate() error {
 		return fmt.Errorf("failed to compile wasi_snapshot_preview1 module: %s", err)
 	}
 
+	err = e.instantiateHostServices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to instantiate host services module: %s", err)
+	}
+
+	startTime := time.Now()
 	e.module, err = e.runtime.CompileModule(ctx, e.wasmFile)
 	if err != nil {
 		return fmt.Errorf("failed to compile module: %s", err)
 	}
 
+	e.publishCompiled(time.Since(startTime))
+
 	return nil
 }
 
+// Reload compiles the given module and swaps it in for subsequent executions; executions already
+// running complete against the previous module
+func (e *Wasm) Reload(artifact []byte) error {
+	if e.runtime == nil {
+		return fmt.Errorf("invalid state for reload; module not yet compiled for vm: %s", e.vmID)
+	}
+
+	ctx := context.Background()
+	module, err := e.runtime.CompileModule(ctx, artifact)
+	if err != nil {
+		return fmt.Errorf("failed to compile module: %s", err)
+	}
+
+	sum := sha256.Sum256(artifact)
+
+	e.mutex.Lock()
+	previous := e.module
+	e.module = module
+	e.wasmFile = artifact
+	e.hash = hex.EncodeToString(sum[:])
+	e.mutex.Unlock()
+
+	// instances of the previous module are unaffected by closing it
+	_ = previous.Close(ctx)
+	return nil
+}
+
+// Restores any compiled artifacts for this module from the workload cache bucket into a
+// local compilation cache, returning true on a cache hit
+func (e *Wasm) restoreCompilationCache() bool {
+	if e.cacheBucket == nil {
+		return false
+	}
+
+	compiled, err := e.cacheBucket.GetBytes(agentapi.CompiledWorkloadCacheKey(e.hash))
+	if err != nil {
+		return false
+	}
+
+	err = extractTar(compiled, e.cacheDir)
+	if err != nil {
+		_, _ = os.Stderr.Write([]byte(fmt.Sprintf("failed to restore compiled wasm module: %s", err.Error())))
+		return false
+	}
+
+	return true
+}
+
+// Stores freshly compiled artifacts in the workload cache bucket and reports the compile time
+// and whether the compiled module was served from the cache
+func (e *Wasm) publishCompiled(compileTime time.Duration) {
+	key := agentapi.CompiledWorkloadCacheKey(e.hash)
+
+	if !e.cacheHit && e.cacheDir != "" && e.cacheBucket != nil {
+		compiled, err := archiveDir(e.cacheDir)
+		if err == nil {
+			_, err = e.cacheBucket.PutBytes(key, compiled)
+		}
+		if err != nil {
+			_, _ = os.Stderr.Write([]byte(fmt.Sprintf("failed to cache compiled wasm module: %s", err.Error())))
+		}
+	}
+
+	evt := events.WorkloadCompiled(e.vmID, agentapi.WorkloadCompiledEvent{
+		WorkloadName:     e.name,
+		CacheKey:         key,
+		CacheHit:         e.cacheHit,
+		CompileTimeNanos: compileTime.Nanoseconds(),
+	})
+
+	raw, err := json.Marshal(evt)
+	if err != nil || e.nc == nil {
+		return
+	}
+
+	_ = e.nc.Publish(fmt.Sprintf("hostint.%s.events.%s", e.vmID, agentapi.WorkloadCompiledEventType), raw)
+}
+
 // InitNexExecutionProviderWasm convenience method to initialize a Wasm execution provider
 func InitNexExecutionProviderWasm(params *agentapi.ExecutionProviderParams) (*Wasm, error) {
 	if params.WorkloadName == nil {
@@ -143,21 +289,53 @@ func InitNexExecutionProviderWasm(params *agentapi.ExecutionProviderParams) (*Wa
 	}
 	defer file.Close()
 
-	bytes, err := io.ReadAll(file)
+	wasmFile, err := io.ReadAll(file)
 	if err != nil {
 		return nil, err
 	}
 
+	hash := params.Hash
+	if hash == "" {
+		sum := sha256.Sum256(wasmFile)
+		hash = hex.EncodeToString(sum[:])
+	}
+
+	// the compilation cache is best-effort; without the bucket modules are simply compiled
+	var cacheBucket nats.ObjectStore
+	if params.NATSConn != nil {
+		if js, err := params.NATSConn.JetStream(); err == nil {
+			cacheBucket, _ = js.ObjectStore(agentapi.WorkloadCacheBucket)
+		}
+	}
+
+	namespace := ""
+	if params.Namespace != nil {
+		namespace = *params.Namespace
+	}
+
 	return &Wasm{
 		vmID:     params.VmID,
-		wasmFile: bytes,
+		name:     *params.WorkloadName,
+		hash:     hash,
+		wasmFile: wasmFile,
 		env:      params.Environment,
 
+		cacheBucket: cacheBucket,
+
+		hostServices: hostservices.NewHostServicesClient(
+			params.NATSConn,
+			time.Second*5, // FIXME-- make configurable
+			namespace,
+			*params.WorkloadName,
+			params.VmID,
+		),
+
 		fail: params.Fail,
 		run:  params.Run,
 		exit: params.Exit,
 
-		nc: params.NATSConn,
+		nc:     params.NATSConn,
+		cipher: params.TriggerCipher,
 	}, nil
 }
 
@@ -207,3 +385,78 @@ func (r *stdInBuf) Read(p []byte) (n int, err error) {
 	r.readIndex += int64(n)
 	return
 }
+
+// Packs the regular files beneath the given directory into a tar archive
+func archiveDir(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		err = tw.WriteHeader(&tar.Header{Name: filepath.ToSlash(name), Mode: 0644, Size: int64(len(contents))})
+		if err != nil {
+			return err
+		}
+
+		_, err = tw.Write(contents)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unpacks a tar archive produced by archiveDir into the given directory
+func extractTar(archive []byte, dir string) error {
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in compiled module archive: %s", hdr.Name)
+		}
+
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(f, tr)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
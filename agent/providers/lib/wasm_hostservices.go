@@ -0,0 +1,302 @@
+package lib
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	nexwasm "github.com/synadia-io/nex/sdk/wasm"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// code host service calls from wasm workloads fail with when the guest passed invalid arguments
+const wasmHostServiceCodeInvalid = 400
+
+// Outcome of the last host service call of a single execution, read back by the guest through
+// the result functions of the host services module
+type wasmCallState struct {
+	result       []byte
+	retryAfterMs uint32
+}
+
+type wasmCallStateKey struct{}
+
+func withWasmCallState(ctx context.Context) context.Context {
+	return context.WithValue(ctx, wasmCallStateKey{}, &wasmCallState{})
+}
+
+func wasmCallStateFrom(ctx context.Context) *wasmCallState {
+	state, ok := ctx.Value(wasmCallStateKey{}).(*wasmCallState)
+	if !ok {
+		// calls made outside of an execution, e.g. from a module's start function during
+		// validation, have nowhere to keep their outcome
+		return &wasmCallState{}
+	}
+	return state
+}
+
+// Instantiates the host services module imported by wasm workloads; see
+// sdk/wasm/nex-hostservices.wit for the functions and their ABI
+func (e *Wasm) instantiateHostServices(ctx context.Context) error {
+	builder := e.runtime.NewHostModuleBuilder(nexwasm.ImportModule)
+	for name, fn := range e.hostServicesFunctions() {
+		builder = builder.NewFunctionBuilder().WithFunc(fn).Export(name)
+	}
+
+	_, err := builder.Instantiate(ctx)
+	return err
+}
+
+// Host functions of the host services module, keyed by their import name
+func (e *Wasm) hostServicesFunctions() map[string]interface{} {
+	return map[string]interface{}{
+		"result.len": func(ctx context.Context) uint32 {
+			return uint32(len(wasmCallStateFrom(ctx).result))
+		},
+		"result.read": func(ctx context.Context, mod api.Module, ptr, size uint32) uint32 {
+			result := wasmCallStateFrom(ctx).result
+			if int(size) < len(result) {
+				result = result[:size]
+			}
+			if !mod.Memory().Write(ptr, result) {
+				return 0
+			}
+			return uint32(len(result))
+		},
+		"result.retry-after-ms": func(ctx context.Context) uint32 {
+			return wasmCallStateFrom(ctx).retryAfterMs
+		},
+
+		"trigger.get-metadata": func(ctx context.Context, _ api.Module) uint32 {
+			md := agentapi.TriggerMetadataFromContext(ctx)
+
+			var data []byte
+			data = wasmAppendBytes(data, []byte(md.Subject))
+			data = wasmAppendBytes(data, []byte(md.Reply))
+			data = wasmAppendBytes(data, []byte(md.MsgID))
+			data = wasmAppendStrings(data, wasmHeaderLines(md.Headers))
+
+			state := wasmCallStateFrom(ctx)
+			state.result = data
+			state.retryAfterMs = 0
+			return 200
+		},
+		"trigger.set-response-header": func(ctx context.Context, mod api.Module, namePtr, nameLen, valuePtr, valueLen uint32) uint32 {
+			name, ok := mod.Memory().Read(namePtr, nameLen)
+			if !ok {
+				return wasmFail(ctx, wasmHostServiceCodeInvalid, "name is out of range of the module's memory")
+			}
+			value, ok := mod.Memory().Read(valuePtr, valueLen)
+			if !ok {
+				return wasmFail(ctx, wasmHostServiceCodeInvalid, "value is out of range of the module's memory")
+			}
+
+			agentapi.TriggerMetadataFromContext(ctx).SetResponseHeader(string(name), string(value))
+
+			state := wasmCallStateFrom(ctx)
+			state.result = nil
+			state.retryAfterMs = 0
+			return 200
+		},
+
+		"key-value.get": func(ctx context.Context, mod api.Module, keyPtr, keyLen, timeoutMs uint32) uint32 {
+			return e.callHostService(ctx, mod, "kv", "get", timeoutMs, nil,
+				map[string]wasmSlice{agentapi.KeyValueKeyHeader: {keyPtr, keyLen}}, nil)
+		},
+		"key-value.set": func(ctx context.Context, mod api.Module, keyPtr, keyLen, valuePtr, valueLen, timeoutMs uint32) uint32 {
+			return e.callHostService(ctx, mod, "kv", "set", timeoutMs, &wasmSlice{valuePtr, valueLen},
+				map[string]wasmSlice{agentapi.KeyValueKeyHeader: {keyPtr, keyLen}}, wasmEncodeStatus)
+		},
+		"key-value.delete": func(ctx context.Context, mod api.Module, keyPtr, keyLen, timeoutMs uint32) uint32 {
+			return e.callHostService(ctx, mod, "kv", "delete", timeoutMs, nil,
+				map[string]wasmSlice{agentapi.KeyValueKeyHeader: {keyPtr, keyLen}}, wasmEncodeStatus)
+		},
+		"key-value.keys": func(ctx context.Context, mod api.Module, timeoutMs uint32) uint32 {
+			return e.callHostService(ctx, mod, "kv", "keys", timeoutMs, nil, nil, wasmEncodeKeys)
+		},
+
+		"messaging.publish": func(ctx context.Context, mod api.Module, subjectPtr, subjectLen, payloadPtr, payloadLen, timeoutMs uint32) uint32 {
+			return e.callHostService(ctx, mod, "messaging", "publish", timeoutMs, &wasmSlice{payloadPtr, payloadLen},
+				map[string]wasmSlice{agentapi.MessagingSubjectHeader: {subjectPtr, subjectLen}}, wasmEncodeStatus)
+		},
+		"messaging.request": func(ctx context.Context, mod api.Module, subjectPtr, subjectLen, payloadPtr, payloadLen, timeoutMs uint32) uint32 {
+			return e.callHostService(ctx, mod, "messaging", "request", timeoutMs, &wasmSlice{payloadPtr, payloadLen},
+				map[string]wasmSlice{agentapi.MessagingSubjectHeader: {subjectPtr, subjectLen}}, nil)
+		},
+
+		"object-store.get": func(ctx context.Context, mod api.Module, namePtr, nameLen, timeoutMs uint32) uint32 {
+			return e.callHostService(ctx, mod, "objectstore", "get", timeoutMs, nil,
+				map[string]wasmSlice{agentapi.ObjectStoreObjectNameHeader: {namePtr, nameLen}}, nil)
+		},
+		"object-store.put": func(ctx context.Context, mod api.Module, namePtr, nameLen, dataPtr, dataLen, timeoutMs uint32) uint32 {
+			return e.callHostService(ctx, mod, "objectstore", "put", timeoutMs, &wasmSlice{dataPtr, dataLen},
+				map[string]wasmSlice{agentapi.ObjectStoreObjectNameHeader: {namePtr, nameLen}}, wasmEncodeNothing)
+		},
+		"object-store.delete": func(ctx context.Context, mod api.Module, namePtr, nameLen, timeoutMs uint32) uint32 {
+			return e.callHostService(ctx, mod, "objectstore", "delete", timeoutMs, nil,
+				map[string]wasmSlice{agentapi.ObjectStoreObjectNameHeader: {namePtr, nameLen}}, wasmEncodeStatus)
+		},
+		"object-store.list": func(ctx context.Context, mod api.Module, timeoutMs uint32) uint32 {
+			return e.callHostService(ctx, mod, "objectstore", "list", timeoutMs, nil, nil, wasmEncodeObjectNames)
+		},
+
+		"http.request": func(ctx context.Context, mod api.Module, methodPtr, methodLen, urlPtr, urlLen, bodyPtr, bodyLen, timeoutMs uint32) uint32 {
+			method, ok := mod.Memory().Read(methodPtr, methodLen)
+			if !ok {
+				return wasmFail(ctx, wasmHostServiceCodeInvalid, "method is out of range of the module's memory")
+			}
+
+			switch m := strings.ToLower(string(method)); m {
+			case "get", "post", "put", "patch", "delete", "head":
+				return e.callHostService(ctx, mod, "http", m, timeoutMs, &wasmSlice{bodyPtr, bodyLen},
+					map[string]wasmSlice{agentapi.HttpURLHeader: {urlPtr, urlLen}}, wasmEncodeHTTP)
+			default:
+				return wasmFail(ctx, wasmHostServiceCodeInvalid, fmt.Sprintf("unsupported http method %q", method))
+			}
+		},
+	}
+}
+
+// Pointer and length of a string or list<u8> argument in the guest's memory
+type wasmSlice struct {
+	ptr  uint32
+	size uint32
+}
+
+// Performs a host service call with arguments read from the guest's memory, keeping its outcome,
+// with its data encoded as given, for the guest to read
+func (e *Wasm) callHostService(ctx context.Context, mod api.Module, service, method string, timeoutMs uint32, payload *wasmSlice, headers map[string]wasmSlice, encode func(*hostServiceOutcome)) uint32 {
+	var data []byte
+	if payload != nil {
+		raw, ok := mod.Memory().Read(payload.ptr, payload.size)
+		if !ok {
+			return wasmFail(ctx, wasmHostServiceCodeInvalid, "payload is out of range of the module's memory")
+		}
+		data = append([]byte(nil), raw...)
+	}
+
+	metadata := make(map[string]string, len(headers))
+	for header, arg := range headers {
+		raw, ok := mod.Memory().Read(arg.ptr, arg.size)
+		if !ok {
+			return wasmFail(ctx, wasmHostServiceCodeInvalid, fmt.Sprintf("%s is out of range of the module's memory", header))
+		}
+		metadata[header] = string(raw)
+	}
+
+	outcome := performHostService(ctx, e.hostServices, service, method, data, metadata, time.Duration(timeoutMs)*time.Millisecond)
+	if encode != nil && outcome.ok() {
+		encode(outcome)
+	}
+
+	state := wasmCallStateFrom(ctx)
+	state.retryAfterMs = uint32(outcome.retryAfter.Milliseconds())
+	if outcome.ok() {
+		state.result = outcome.data
+	} else {
+		state.result = []byte(outcome.message)
+	}
+
+	return uint32(outcome.code)
+}
+
+func wasmFail(ctx context.Context, code uint32, message string) uint32 {
+	state := wasmCallStateFrom(ctx)
+	state.result = []byte(message)
+	state.retryAfterMs = 0
+	return code
+}
+
+// Drops the data of calls whose result is unit
+func wasmEncodeNothing(o *hostServiceOutcome) {
+	o.data = nil
+}
+
+// Fails calls to host services answering with an unsuccessful status, dropping their data
+func wasmEncodeStatus(o *hostServiceOutcome) {
+	o.checkStatus()
+	o.data = nil
+}
+
+func wasmEncodeKeys(o *hostServiceOutcome) {
+	var keys []string
+	if len(o.data) > 0 && json.Unmarshal(o.data, &keys) != nil {
+		wasmEncodeFailed(o, "keys")
+		return
+	}
+	o.data = wasmAppendStrings(nil, keys)
+}
+
+func wasmEncodeObjectNames(o *hostServiceOutcome) {
+	var objects []nats.ObjectInfo
+	if len(o.data) > 0 && json.Unmarshal(o.data, &objects) != nil {
+		wasmEncodeFailed(o, "objects")
+		return
+	}
+
+	names := make([]string, 0, len(objects))
+	for _, object := range objects {
+		names = append(names, object.Name)
+	}
+	o.data = wasmAppendStrings(nil, names)
+}
+
+// Encodes the http-response record: status, headers formatted as "name: value" and body
+func wasmEncodeHTTP(o *hostServiceOutcome) {
+	var resp agentapi.HostServicesHTTPResponse
+	if json.Unmarshal(o.data, &resp) != nil {
+		wasmEncodeFailed(o, "http response")
+		return
+	}
+	if resp.Error != nil {
+		o.code = hostServiceCodeFailed
+		o.message = *resp.Error
+		o.data = nil
+		return
+	}
+
+	var headers map[string][]string
+	if resp.Headers != nil {
+		_ = json.Unmarshal(*resp.Headers, &headers)
+	}
+
+	data := binary.LittleEndian.AppendUint32(nil, uint32(resp.Status))
+	data = wasmAppendStrings(data, wasmHeaderLines(headers))
+	data = wasmAppendBytes(data, []byte(resp.Body))
+	o.data = data
+}
+
+// Formats headers as sorted "name: value" lines, joining the values of repeated headers
+func wasmHeaderLines(headers map[string][]string) []string {
+	lines := make([]string, 0, len(headers))
+	for name, values := range headers {
+		lines = append(lines, fmt.Sprintf("%s: %s", name, strings.Join(values, ", ")))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func wasmEncodeFailed(o *hostServiceOutcome, what string) {
+	o.code = hostServiceCodeFailed
+	o.message = fmt.Sprintf("failed to decode %s returned by the host service", what)
+	o.data = nil
+}
+
+func wasmAppendBytes(data, value []byte) []byte {
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(value)))
+	return append(data, value...)
+}
+
+func wasmAppendStrings(data []byte, values []string) []byte {
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(values)))
+	for _, value := range values {
+		data = wasmAppendBytes(data, []byte(value))
+	}
+	return data
+}
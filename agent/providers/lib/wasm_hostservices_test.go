@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	nexwasm "github.com/synadia-io/nex/sdk/wasm"
+	"github.com/synadia-io/nex/sdk/wasm/wit"
+)
+
+func TestWasmHostServicesMatchWIT(t *testing.T) {
+	pkg, err := wit.Parse(nexwasm.WIT)
+	if err != nil {
+		t.Fatalf("failed to parse WIT: %s", err)
+	}
+
+	functions := (&Wasm{}).hostServicesFunctions()
+
+	expected := map[string]bool{"result.len": true, "result.read": true, "result.retry-after-ms": true}
+	for _, world := range pkg.Worlds {
+		for _, name := range world.Imports {
+			for _, iface := range pkg.Interfaces {
+				if iface.Name != name {
+					continue
+				}
+
+				for _, fn := range iface.Funcs {
+					importName := iface.Name + "." + fn.Name
+					expected[importName] = true
+
+					lowered := 0
+					for _, param := range fn.Params {
+						if param.Type.Name == "u32" {
+							lowered++
+						} else {
+							lowered += 2
+						}
+					}
+
+					host, ok := functions[importName]
+					if !ok {
+						t.Errorf("no host function for %s", importName)
+						continue
+					}
+
+					// host functions take the context and module ahead of the lowered parameters
+					if got := reflect.TypeOf(host).NumIn() - 2; got != lowered {
+						t.Errorf("expected %s to take %d lowered parameters, got %d", importName, lowered, got)
+					}
+				}
+			}
+		}
+	}
+
+	for name := range functions {
+		if !expected[name] {
+			t.Errorf("host function %s is not defined by the WIT", name)
+		}
+	}
+}
+
+func TestWasmEncodeHTTP(t *testing.T) {
+	headers := json.RawMessage(`{"X-B":["2"],"Content-Type":["text/plain","charset=utf-8"]}`)
+	raw, _ := json.Marshal(agentapi.HostServicesHTTPResponse{Status: 201, Headers: &headers, Body: "hi"})
+
+	outcome := &hostServiceOutcome{code: 200, data: raw}
+	wasmEncodeHTTP(outcome)
+
+	expected := binary.LittleEndian.AppendUint32(nil, 201)
+	expected = wasmAppendStrings(expected, []string{"Content-Type: text/plain, charset=utf-8", "X-B: 2"})
+	expected = wasmAppendBytes(expected, []byte("hi"))
+
+	if !outcome.ok() || !reflect.DeepEqual(outcome.data, expected) {
+		t.Fatalf("unexpected encoding: %d %q", outcome.code, outcome.data)
+	}
+
+	failure := "connection refused"
+	raw, _ = json.Marshal(agentapi.HostServicesHTTPResponse{Error: &failure})
+
+	outcome = &hostServiceOutcome{code: 200, data: raw}
+	wasmEncodeHTTP(outcome)
+	if outcome.code != hostServiceCodeFailed || outcome.message != failure {
+		t.Fatalf("expected the response error to fail the call, got %d %q", outcome.code, outcome.message)
+	}
+}
@@ -0,0 +1,54 @@
+package controlapi
+
+// Describes running workloads that a node must not already host in order to take part in an
+// auction. A term matches workloads by name, by labels, or both; an empty namespace matches
+// workloads in any namespace. A term with neither a name nor labels matches every workload
+type AntiAffinityTerm struct {
+	WorkloadName string            `json:"workload_name,omitempty"`
+	Namespace    string            `json:"namespace,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+}
+
+// Reports whether the given running workload matches this term
+func (term AntiAffinityTerm) Matches(machine MachineSummary) bool {
+	if term.WorkloadName != "" && machine.Workload.Name != term.WorkloadName {
+		return false
+	}
+
+	if term.Namespace != "" && machine.Namespace != term.Namespace {
+		return false
+	}
+
+	for key, value := range term.Labels {
+		if machine.Labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Spreads the replicas of a workload across nodes by limiting how many matching workloads a
+// node may already be running when it bids. Without a limit, nodes running any matching
+// workload decline, placing each replica on a distinct node
+type SpreadConstraint struct {
+	AntiAffinityTerm
+	MaxPerNode int `json:"max_per_node,omitempty"`
+}
+
+// Reports whether a node running the given workloads satisfies the constraint
+func (spread SpreadConstraint) Allows(machines []MachineSummary) bool {
+	limit := spread.MaxPerNode
+	if limit <= 0 {
+		limit = 1
+	}
+
+	count := 0
+	for _, machine := range machines {
+		if spread.Matches(machine) {
+			count++
+		}
+	}
+
+	return count < limit
+}
@@ -0,0 +1,33 @@
+package controlapi
+
+const (
+	// Object metadata marking an object store object as the manifest of an artifact uploaded in
+	// chunks. Nodes deploying such an object download its chunks and reassemble the artifact
+	ArtifactManifestMetadata = "nex-artifact-manifest"
+
+	// Object metadata carrying the hex-encoded SHA-256 digest of an uploaded chunk
+	ArtifactChunkDigestMetadata = "nex-sha256"
+)
+
+// An artifact uploaded as a series of object store objects, so that an interrupted upload can
+// resume from the first missing chunk. Chunk keys are relative to the manifest's bucket
+type ArtifactManifest struct {
+	Size   int64           `json:"size"`
+	Sha256 string          `json:"sha256"`
+	Chunks []ArtifactChunk `json:"chunks"`
+}
+
+type ArtifactChunk struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Progress of a node downloading a workload's artifact
+type ArtifactTransferEvent struct {
+	WorkloadId       string `json:"workload_id"`
+	Namespace        string `json:"namespace"`
+	Location         string `json:"location"`
+	BytesTransferred int64  `json:"bytes_transferred"`
+	TotalBytes       int64  `json:"total_bytes"`
+}
@@ -0,0 +1,67 @@
+package controlapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Outcome of a control API request as recorded by the node's audit
+type AuditOutcome string
+
+const (
+	AuditOutcomeSucceeded AuditOutcome = "succeeded"
+	AuditOutcomeFailed    AuditOutcome = "failed"
+	AuditOutcomeDenied    AuditOutcome = "denied"
+	AuditOutcomeQueued    AuditOutcome = "queued"
+)
+
+// Published by a node on $NEX.audit.{namespace}.{node_id}.{request} for every control API
+// request it handles. Requests outside of any namespace are recorded in the system namespace.
+// The issuer is the one the node attributed the request to, from its signed claims or identity
+// token, and is empty when the request carried neither
+type AuditRecord struct {
+	NodeId    string       `json:"node_id"`
+	Request   string       `json:"request"`
+	Subject   string       `json:"subject"`
+	Operation Operation    `json:"operation,omitempty"`
+	Issuer    string       `json:"issuer,omitempty"`
+	Namespace string       `json:"namespace"`
+	Outcome   AuditOutcome `json:"outcome"`
+	Error     string       `json:"error,omitempty"`
+
+	ReceivedAt    time.Time `json:"received_at"`
+	LatencyMillis int64     `json:"latency_ms"`
+}
+
+// Streams the audit records matching the filter until the context is done, at which point the
+// returned channel is closed. Only the namespace and node ID of the filter apply
+func (api *Client) WatchAudit(ctx context.Context, filter WatchFilter) (<-chan AuditRecord, error) {
+	subject := fmt.Sprintf("%s.audit.%s.%s.*", APIPrefix, watchToken(filter.Namespace), watchToken(filter.NodeId))
+
+	records := make(chan AuditRecord)
+	err := api.watch(ctx, []string{subject}, func(m *nats.Msg) {
+		var record AuditRecord
+		err := json.Unmarshal(m.Data, &record)
+		if err != nil {
+			api.log.Debug("Failed to decode watched audit record", slog.String("subject", m.Subject), slog.Any("err", err))
+			return
+		}
+
+		select {
+		case records <- record:
+		case <-ctx.Done():
+		}
+	}, func() {
+		close(records)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
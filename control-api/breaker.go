@@ -0,0 +1,32 @@
+package controlapi
+
+import "time"
+
+const (
+	// Set on the reply to a trigger message that a node rejected instead of forwarding it to its
+	// workload, naming the reason it was rejected
+	TriggerErrorHeader = "Nex-Trigger-Error"
+
+	// The trigger subject's circuit breaker is open
+	TriggerErrorCircuitOpen = "circuit_open"
+)
+
+type BreakerState string
+
+const (
+	BreakerStateClosed   BreakerState = "closed"
+	BreakerStateOpen     BreakerState = "open"
+	BreakerStateHalfOpen BreakerState = "half_open"
+)
+
+// State of the circuit breaker guarding one of a workload's trigger subjects. An open breaker
+// rejects triggers until RetryAt, after which it half-opens and lets one trigger through to probe
+// whether the workload has recovered
+type TriggerBreakerStatus struct {
+	Subject             string       `json:"subject"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	RetryAt             *time.Time   `json:"retry_at,omitempty"`
+	Rejected            int64        `json:"rejected"`
+}
@@ -0,0 +1,72 @@
+package controlapi
+
+import (
+	"fmt"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// Request to stop every workload in a namespace whose labels include all of the selector's
+// labels. An empty selector matches every workload in the namespace. Only workloads started by
+// the issuer that signed the request are stopped
+type BulkStopRequest struct {
+	Selector    map[string]string `json:"selector,omitempty"`
+	WorkloadJwt string            `json:"workload_jwt"`
+}
+
+// Reply from a single node with the outcome for each matching workload, keyed by workload ID
+type BulkStopResponse struct {
+	NodeId  string                    `json:"node_id"`
+	Results map[string]BulkStopResult `json:"results"`
+}
+
+type BulkStopResult struct {
+	Name    string `json:"name"`
+	Stopped bool   `json:"stopped"`
+	Error   string `json:"error,omitempty"`
+}
+
+func NewBulkStopRequest(namespace string, selector map[string]string, issuer nkeys.KeyPair) (*BulkStopRequest, error) {
+	claims := jwt.NewGenericClaims(namespace)
+	jwtText, err := claims.Encode(issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BulkStopRequest{
+		Selector:    selector,
+		WorkloadJwt: jwtText,
+	}, nil
+}
+
+// Reports whether a workload with the given labels is selected by this request
+func (request *BulkStopRequest) Matches(labels map[string]string) bool {
+	for key, value := range request.Selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Validates the request for the given namespace against the claims a matching workload was
+// originally started with
+func (request *BulkStopRequest) Validate(namespace string, originalClaims *jwt.GenericClaims) error {
+	claims, err := jwt.DecodeGeneric(request.WorkloadJwt)
+	if err != nil {
+		return fmt.Errorf("could not decode workload JWT: %s", err)
+	}
+	if claims.ID == originalClaims.ID {
+		return fmt.Errorf("stop claims appear to be cloned or captured from the original start claims. Rejecting for security reasons")
+	}
+	if claims.Subject != namespace {
+		return fmt.Errorf("bulk stop claims subject does not match the requested namespace")
+	}
+	if claims.Issuer != originalClaims.Issuer {
+		return fmt.Errorf("the only entity allowed to terminate a workload is the issuer that originally started it")
+	}
+
+	return nil
+}
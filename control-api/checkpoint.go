@@ -0,0 +1,113 @@
+package controlapi
+
+import (
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+const (
+	// Object store bucket holding workload checkpoints, unless the node is configured with another
+	DefaultCheckpointBucket = "NEXCHECKPOINTS"
+
+	restoreClaimsCheckpointIdField = "checkpoint_id"
+)
+
+// Asks a node to checkpoint a running service workload: its machine is snapshotted, memory and
+// disk, and the snapshot is stored in the node's checkpoint bucket, from which any node sharing
+// that bucket can restore it. With Stop, the workload is stopped once its checkpoint is stored,
+// so that restoring it elsewhere migrates it
+type CheckpointRequest struct {
+	WorkloadId  string `json:"workload_id"`
+	Stop        bool   `json:"stop,omitempty"`
+	WorkloadJwt string `json:"workload_jwt"`
+}
+
+// Creates a checkpoint request signed by the issuer that originally started the workload, or by
+// one of the node's admin keys
+func NewCheckpointRequest(workloadId string, stop bool, issuer nkeys.KeyPair) (*CheckpointRequest, error) {
+	jwtText, err := newWorkloadClaims(checkpointAction(stop), workloadId, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CheckpointRequest{
+		WorkloadId:  workloadId,
+		Stop:        stop,
+		WorkloadJwt: jwtText,
+	}, nil
+}
+
+// Verifies that the request is signed by the issuer that originally started the workload, or by
+// one of the given admin keys. Every failure is reported as an *AuthorizationError
+func (request *CheckpointRequest) Validate(originalClaims *jwt.GenericClaims, adminKeys ...string) error {
+	return validateWorkloadClaims(request.WorkloadJwt, checkpointAction(request.Stop), request.WorkloadId, originalClaims, adminKeys)
+}
+
+// Claims for a checkpoint that stops the workload are kept apart from those of one that does not
+func checkpointAction(stop bool) string {
+	if stop {
+		return "checkpoint and stop"
+	}
+	return "checkpoint"
+}
+
+// Lists the checkpoints of the namespace's workloads stored in the node's checkpoint bucket
+type CheckpointListRequest struct {
+	// Only lists the checkpoints of the given workload when set
+	WorkloadId string `json:"workload_id,omitempty"`
+}
+
+// Asks a node to restore a workload from a checkpoint. The workload keeps its ID, and resumes
+// where it was when the checkpoint was taken
+type RestoreRequest struct {
+	CheckpointId string `json:"checkpoint_id"`
+	WorkloadJwt  string `json:"workload_jwt"`
+}
+
+// Creates a restore request signed by the issuer that originally started the checkpointed
+// workload, or by one of the node's admin keys
+func NewRestoreRequest(checkpointId string, issuer nkeys.KeyPair) (*RestoreRequest, error) {
+	jwtText, err := newActionClaims("restore", restoreClaimsCheckpointIdField, checkpointId, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RestoreRequest{
+		CheckpointId: checkpointId,
+		WorkloadJwt:  jwtText,
+	}, nil
+}
+
+// Verifies that the request is signed by the issuer that originally started the checkpointed
+// workload, or by one of the given admin keys. Every failure is reported as an *AuthorizationError
+func (request *RestoreRequest) Validate(originalClaims *jwt.GenericClaims, adminKeys ...string) error {
+	return validateActionClaims(request.WorkloadJwt, "restore", restoreClaimsCheckpointIdField, request.CheckpointId, originalClaims, adminKeys)
+}
+
+// A snapshot of a workload's machine stored in a checkpoint bucket
+type CheckpointInfo struct {
+	Id           string      `json:"id"`
+	WorkloadId   string      `json:"workload_id"`
+	Name         string      `json:"workload_name"`
+	Namespace    string      `json:"namespace"`
+	WorkloadType NexWorkload `json:"workload_type"`
+	NodeId       string      `json:"node_id"`
+	Bucket       string      `json:"bucket"`
+	SizeBytes    uint64      `json:"size_bytes"`
+	CreatedAt    time.Time   `json:"created_at"`
+
+	// Whether the workload was stopped once the checkpoint was stored
+	Stopped bool `json:"stopped,omitempty"`
+}
+
+type CheckpointResponse struct {
+	NodeId      string           `json:"node_id"`
+	Checkpoints []CheckpointInfo `json:"checkpoints"`
+}
+
+type RestoreResponse struct {
+	NodeId     string         `json:"node_id"`
+	Checkpoint CheckpointInfo `json:"checkpoint"`
+}
@@ -0,0 +1,69 @@
+package controlapi
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+const (
+	// How long the claims authorizing an action on a running workload remain valid after being issued
+	WorkloadClaimsLifetime = 5 * time.Minute
+
+	workloadClaimsActionField = "action"
+)
+
+// Signs claims authorizing a single action on a single workload. Nodes accept them from the issuer
+// that originally started the workload and from their admin keys
+func newWorkloadClaims(action string, workloadId string, issuer nkeys.KeyPair) (string, error) {
+	return newActionClaims(action, stopClaimsWorkloadIdField, workloadId, issuer)
+}
+
+// Verifies that the claims authorize the action on the workload and are signed by the issuer that
+// originally started it, or by one of the given admin keys. Every failure is reported as an
+// *AuthorizationError
+func validateWorkloadClaims(token string, action string, workloadId string, originalClaims *jwt.GenericClaims, adminKeys []string) error {
+	return validateActionClaims(token, action, stopClaimsWorkloadIdField, workloadId, originalClaims, adminKeys)
+}
+
+// Signs claims authorizing a single action on the object whose ID is kept in the given field
+func newActionClaims(action string, targetField string, target string, issuer nkeys.KeyPair) (string, error) {
+	claims := jwt.NewGenericClaims(target)
+	claims.Expires = time.Now().Add(WorkloadClaimsLifetime).Unix()
+	claims.Data[targetField] = target
+	claims.Data[workloadClaimsActionField] = action
+
+	return claims.Encode(issuer)
+}
+
+func validateActionClaims(token string, action string, targetField string, target string, originalClaims *jwt.GenericClaims, adminKeys []string) error {
+	claims, err := jwt.DecodeGeneric(token)
+	if err != nil {
+		return NewAuthorizationError(fmt.Sprintf("could not verify %s claims: %s", action, err))
+	}
+	if claims.Expires == 0 {
+		return NewAuthorizationError(fmt.Sprintf("%s claims must carry an expiry", action))
+	}
+	if time.Now().Unix() > claims.Expires {
+		return NewAuthorizationError(fmt.Sprintf("%s claims have expired", action))
+	}
+	if claims.ID == originalClaims.ID ||
+		claims.IssuedAt == originalClaims.IssuedAt {
+		return NewAuthorizationError(fmt.Sprintf("%s claims appear to be cloned or captured from the original start claims. Rejecting for security reasons", action))
+	}
+	if claimed, ok := claims.Data[workloadClaimsActionField]; !ok || claimed != action {
+		return NewAuthorizationError(fmt.Sprintf("%s claims were issued for a different action", action))
+	}
+	if claimed, ok := claims.Data[targetField]; !ok || claimed != target {
+		return NewAuthorizationError(fmt.Sprintf("%s claims were issued for a different %s", action, strings.TrimSuffix(targetField, "_id")))
+	}
+	if claims.Issuer != originalClaims.Issuer && !slices.Contains(adminKeys, claims.Issuer) {
+		return NewAuthorizationError(fmt.Sprintf("the only entities allowed to make %s requests for a workload are the issuer that originally started it and the node's admin keys", action))
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package controlapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+func TestPauseAndResumeRequestValidation(t *testing.T) {
+	owner, _ := nkeys.CreateAccount()
+	other, _ := nkeys.CreateAccount()
+	admin, _ := nkeys.CreateOperator()
+	ownerPub, _ := owner.PublicKey()
+	adminPub, _ := admin.PublicKey()
+
+	original := jwt.NewGenericClaims("echo")
+	original.Issuer = ownerPub
+	original.ID = "original"
+	original.IssuedAt = time.Now().Add(-time.Minute).Unix()
+
+	var authErr *AuthorizationError
+
+	request, _ := NewPauseRequest("w1", true, owner)
+	if err := request.Validate(original); err != nil {
+		t.Fatalf("Expected the original issuer to be allowed to pause the workload: %s", err)
+	}
+
+	foreign, _ := NewPauseRequest("w1", true, other)
+	if err := foreign.Validate(original, adminPub); !errors.As(err, &authErr) {
+		t.Fatalf("Expected an authorization error for another issuer, got %v", err)
+	}
+
+	administered, _ := NewPauseRequest("w1", false, admin)
+	if err := administered.Validate(original, adminPub); err != nil {
+		t.Fatalf("Expected an admin key to be allowed to pause the workload: %s", err)
+	}
+
+	retargeted := *request
+	retargeted.WorkloadId = "w2"
+	if err := retargeted.Validate(original); !errors.As(err, &authErr) {
+		t.Fatalf("Expected claims issued for another workload to be rejected, got %v", err)
+	}
+
+	resume, _ := NewResumeRequest("w1", owner)
+	if err := resume.Validate(original); err != nil {
+		t.Fatalf("Expected the original issuer to be allowed to resume the workload: %s", err)
+	}
+
+	replayed := *request
+	replayed.WorkloadJwt = resume.WorkloadJwt
+	if err := replayed.Validate(original); !errors.As(err, &authErr) {
+		t.Fatalf("Expected resume claims to be rejected for a pause, got %v", err)
+	}
+
+	stop, _ := NewStopRequest("w1", "echo", "node", owner)
+	replayed.WorkloadJwt = stop.WorkloadJwt
+	if err := replayed.Validate(original); !errors.As(err, &authErr) {
+		t.Fatalf("Expected stop claims to be rejected for a pause, got %v", err)
+	}
+}
+
+func TestQuarantineRequestValidation(t *testing.T) {
+	owner, _ := nkeys.CreateAccount()
+	other, _ := nkeys.CreateAccount()
+	ownerPub, _ := owner.PublicKey()
+
+	original := jwt.NewGenericClaims("echo")
+	original.Issuer = ownerPub
+	original.ID = "original"
+	original.IssuedAt = time.Now().Add(-time.Minute).Unix()
+
+	var authErr *AuthorizationError
+
+	resume, _ := NewQuarantineRequest(QuarantineActionResume, "w1", owner)
+	if err := resume.Validate(original); err != nil {
+		t.Fatalf("Expected the original issuer to be allowed to resume the quarantined workload: %s", err)
+	}
+
+	foreign, _ := NewQuarantineRequest(QuarantineActionStop, "w1", other)
+	if err := foreign.Validate(original); !errors.As(err, &authErr) {
+		t.Fatalf("Expected an authorization error for another issuer, got %v", err)
+	}
+
+	unsigned := QuarantineRequest{Action: QuarantineActionStop, WorkloadId: "w1"}
+	if err := unsigned.Validate(original); !errors.As(err, &authErr) {
+		t.Fatalf("Expected a request without claims to be rejected, got %v", err)
+	}
+
+	swapped := *resume
+	swapped.Action = QuarantineActionStop
+	if err := swapped.Validate(original); !errors.As(err, &authErr) {
+		t.Fatalf("Expected resume claims to be rejected for a stop, got %v", err)
+	}
+}
+
+func TestCheckpointAndRestoreRequestValidation(t *testing.T) {
+	owner, _ := nkeys.CreateAccount()
+	other, _ := nkeys.CreateAccount()
+	admin, _ := nkeys.CreateOperator()
+	ownerPub, _ := owner.PublicKey()
+	adminPub, _ := admin.PublicKey()
+
+	original := jwt.NewGenericClaims("echo")
+	original.Issuer = ownerPub
+	original.ID = "original"
+	original.IssuedAt = time.Now().Add(-time.Minute).Unix()
+
+	var authErr *AuthorizationError
+
+	checkpoint, _ := NewCheckpointRequest("w1", true, owner)
+	if err := checkpoint.Validate(original); err != nil {
+		t.Fatalf("Expected the original issuer to be allowed to checkpoint and stop the workload: %s", err)
+	}
+
+	escalated, _ := NewCheckpointRequest("w1", false, owner)
+	escalated.Stop = true
+	if err := escalated.Validate(original); !errors.As(err, &authErr) {
+		t.Fatalf("Expected claims for a checkpoint to be rejected for a checkpoint that stops, got %v", err)
+	}
+
+	foreign, _ := NewCheckpointRequest("w1", false, other)
+	if err := foreign.Validate(original, adminPub); !errors.As(err, &authErr) {
+		t.Fatalf("Expected an authorization error for another issuer, got %v", err)
+	}
+
+	restore, _ := NewRestoreRequest("c1", admin)
+	if err := restore.Validate(original, adminPub); err != nil {
+		t.Fatalf("Expected an admin key to be allowed to restore the workload: %s", err)
+	}
+
+	retargeted := *restore
+	retargeted.CheckpointId = "c2"
+	if err := retargeted.Validate(original, adminPub); !errors.As(err, &authErr) {
+		t.Fatalf("Expected claims issued for another checkpoint to be rejected, got %v", err)
+	}
+
+	unsigned := RestoreRequest{CheckpointId: "c1"}
+	if err := unsigned.Validate(original); !errors.As(err, &authErr) {
+		t.Fatalf("Expected a restore request without claims to be rejected, got %v", err)
+	}
+}
+
+func TestVolumeDeleteRequestValidation(t *testing.T) {
+	owner, _ := nkeys.CreateAccount()
+	other, _ := nkeys.CreateAccount()
+	admin, _ := nkeys.CreateOperator()
+	ownerPub, _ := owner.PublicKey()
+	adminPub, _ := admin.PublicKey()
+
+	var authErr *AuthorizationError
+
+	request, _ := NewVolumeDeleteRequest("data", owner)
+	if err := request.Validate(ownerPub); err != nil {
+		t.Fatalf("Expected the owning issuer to be allowed to delete the volume: %s", err)
+	}
+	if err := request.Validate(""); !errors.As(err, &authErr) {
+		t.Fatalf("Expected a volume without an owner to be deleted only by admin keys, got %v", err)
+	}
+
+	foreign, _ := NewVolumeDeleteRequest("data", other)
+	if err := foreign.Validate(ownerPub, adminPub); !errors.As(err, &authErr) {
+		t.Fatalf("Expected an authorization error for another issuer, got %v", err)
+	}
+
+	administered, _ := NewVolumeDeleteRequest("data", admin)
+	if err := administered.Validate("", adminPub); err != nil {
+		t.Fatalf("Expected an admin key to be allowed to delete any volume: %s", err)
+	}
+
+	renamed := *request
+	renamed.Name = "other"
+	if err := renamed.Validate(ownerPub); !errors.As(err, &authErr) {
+		t.Fatalf("Expected claims issued for another volume to be rejected, got %v", err)
+	}
+}
@@ -6,21 +6,56 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
 )
 
 // API subjects:
 // $NEX.AUCTION
+// $NEX.DISCOVER
 // $NEX.PING
 // $NEX.PING.{node}
+// $NEX.GPING.{namespace}.{group}
+// $NEX.GSTOP.{namespace}.{group}
+// $NEX.GRESTART.{namespace}.{group}
 // $NEX.INFO.{namespace}.{node}
+// $NEX.NEXUS.INFO.{namespace}
 // $NEX.RUN.{namespace}.{node}
+// $NEX.CANCELDEPLOY.{namespace}.{node}
 // $NEX.STOP.{namespace}.{node}
+// $NEX.CUTOVER.{namespace}.{node}
+// $NEX.HOTRELOAD.{namespace}.{node}
+// $NEX.SUBZ.{namespace}.{node}
+// $NEX.QUARANTINE.{namespace}.{node}
+// $NEX.PAUSE.{namespace}.{node}
+// $NEX.RESUME.{namespace}.{node}
+// $NEX.VOLUMES.{namespace}.{node}
+// $NEX.USAGE.{namespace}.{node}
+// $NEX.CHECKPOINT.{namespace}.{node}
+// $NEX.CHECKPOINTS.{namespace}.{node}
+// $NEX.RESTORE.{namespace}.{node}
+// $NEX.BULKSTOP.{namespace}
+// $NEX.NAMESPACE.{namespace}
+// $NEX.RESOURCES.{namespace}
+// $NEX.STATE.{namespace}
 // $NEX.LAMEDUCK.{node}
+// $NEX.CORDON.{node}
+// $NEX.UNCORDON.{node}
+// $NEX.EVACUATE.{node}
+// $NEX.ROTATEKEYS.{node}
+// $NEX.DIAGNOSTICS.{node}
+// $NEX.FAULTS.{node}
+// $NEX.TAGS.{node}
+// $NEX.UPDATE.{node}
+// $NEX.ROOTFS.{node}
+// $NEX.ROOTFSSTATUS.{node}
+// $NEX.ROOTFSABORT.{node}
+// $NEX.SCHEMAS.{node}
 
 // A control API client communicates with a "Nexus" of nodes by virtue of the $NEX.> subject space. This
 // client should be used to communicate with Nex nodes whenever possible, and its patterns should be copied
@@ -45,67 +80,919 @@ func NewApiClientWithNamespace(nc *nats.Conn, timeout time.Duration, namespace s
 	return &Client{nc: nc, timeout: timeout, namespace: namespace, log: log}
 }
 
-// Attempts to stop a running workload. This can fail for a wide variety of reasons, the most common
-// is likely to be security validation that prevents one issuer from submitting a stop request for
-// another issuer's workload
-func (api *Client) StopWorkload(stopRequest *StopRequest) (*StopResponse, error) {
+// Options applied to an individual control API request
+type CallOption func(o callOptions) callOptions
+
+type callOptions struct {
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+	quiet   time.Duration
+
+	identity      nkeys.KeyPair
+	identityToken string
+}
+
+// Overrides the client's default timeout for a single request. For requests that gather
+// responses from many nodes, this is how long responses are collected
+func WithRequestTimeout(timeout time.Duration) CallOption {
+	return func(o callOptions) callOptions {
+		o.timeout = timeout
+		return o
+	}
+}
+
+// Retries a request up to the given number of additional attempts, waiting for the given
+// backoff between attempts. Requests are retried when no node responded; requests that
+// are safe to repeat are also retried when an attempt times out. Deploy requests are never
+// retried after a timeout, since the node may have acted on the original
+func WithRetries(retries int, backoff time.Duration) CallOption {
+	return func(o callOptions) callOptions {
+		o.retries = retries
+		o.backoff = backoff
+		return o
+	}
+}
+
+// Ends a streaming request once no response has arrived for the given period, rather than
+// waiting out the full request timeout
+func WithQuietPeriod(quiet time.Duration) CallOption {
+	return func(o callOptions) callOptions {
+		o.quiet = quiet
+		return o
+	}
+}
+
+func (api *Client) callOptions(opts []CallOption) callOptions {
+	o := callOptions{timeout: api.timeout}
+	for _, opt := range opts {
+		o = opt(o)
+	}
+
+	return o
+}
+
+// Attempts to stop a running workload, giving up when the context is done. This can fail for a wide
+// variety of reasons, the most common is likely to be security validation that prevents one issuer
+// from submitting a stop request for another issuer's workload
+func (api *Client) StopWorkload(ctx context.Context, stopRequest *StopRequest, opts ...CallOption) (*StopResponse, error) {
 	subject := fmt.Sprintf("%s.STOP.%s.%s", APIPrefix, api.namespace, stopRequest.TargetNode)
-	bytes, err := api.performRequest(subject, stopRequest)
+	bytes, err := api.performRequest(ctx, subject, stopRequest, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response StopResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+
+}
+
+// Stops every workload in the client's namespace matching the request's label selector, across all
+// nodes. Each node hosting a matching workload replies with the outcome for each of them
+func (api *Client) StopWorkloads(ctx context.Context, request *BulkStopRequest, opts ...CallOption) ([]BulkStopResponse, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]BulkStopResponse, 0)
+	err = api.gather(ctx, fmt.Sprintf("%s.BULKSTOP.%s", APIPrefix, api.namespace), payload, opts, func(env *Envelope) {
+		if env.Error != nil {
+			api.log.Warn("node failed bulk stop request", slog.Any("err", env.Error))
+			return
+		}
+
+		var resp BulkStopResponse
+		bytes, err := json.Marshal(env.Data)
+		if err != nil {
+			api.log.Error("failed to marshal envelope data", slog.Any("err", err))
+			return
+		}
+
+		err = json.Unmarshal(bytes, &resp)
+		if err != nil {
+			api.log.Error("failed to unmarshal bulk stop response", slog.Any("err", err))
+			return
+		}
+		responses = append(responses, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// Moves trigger subject subscriptions from one deployed workload to another on the same node,
+// e.g. to release a new version of a function without dropping in-flight messages. Cutovers are
+// never retried after a timeout, since the node may have completed the original
+func (api *Client) CutoverTriggers(ctx context.Context, request *CutoverRequest, opts ...CallOption) (*CutoverResponse, error) {
+	subject := fmt.Sprintf("%s.CUTOVER.%s.%s", APIPrefix, api.namespace, request.TargetNode)
+	bytes, err := api.performRequest(ctx, subject, request, false, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response CutoverResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Swaps the script or module of a running v8 or wasm workload that was deployed with hot reload
+// enabled, giving up when the context is done. The new artifact must already be in the object store
+func (api *Client) HotReloadWorkload(ctx context.Context, request *HotReloadRequest, opts ...CallOption) (*HotReloadResponse, error) {
+	subject := fmt.Sprintf("%s.HOTRELOAD.%s.%s", APIPrefix, api.namespace, request.TargetNode)
+	bytes, err := api.performRequest(ctx, subject, request, false, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response HotReloadResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Attempts to start a workload. The workload URI, at the moment, must always point to a NATS object store
+// bucket in the form of `nats://{bucket}/{key}`. Note that JetStream domains can be supplied on the workload
+// request and aren't part of the bucket+key URL. Gives up when the context is done
+func (api *Client) StartWorkload(ctx context.Context, request *DeployRequest, opts ...CallOption) (*RunResponse, error) {
+	subject := fmt.Sprintf("%s.DEPLOY.%s.%s", APIPrefix, api.namespace, *request.TargetNode)
+	bytes, err := api.performRequest(ctx, subject, request, false, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response RunResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Attempts to start a workload, allowing the target node to queue the request if it has no
+// available agents. The supplied callback is invoked for each interim queue position update.
+// If the context is cancelled or the wait times out while the request is queued, a cancellation
+// is sent to the node so the request doesn't get deployed after the caller has given up. Only
+// the request's issuer may cancel it, so callers relying on this pass WithIdentity with its key
+func (api *Client) StartWorkloadQueued(ctx context.Context, request *DeployRequest, onQueued func(DeployQueuedResponse), opts ...CallOption) (*RunResponse, error) {
+	o := api.callOptions(opts)
+
+	queueable := true
+	request.Queueable = &queueable
+
+	subject := fmt.Sprintf("%s.DEPLOY.%s.%s", APIPrefix, api.namespace, *request.TargetNode)
+	bytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	inbox := api.nc.NewRespInbox()
+	sub, err := api.nc.SubscribeSync(inbox)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = sub.Unsubscribe()
+	}()
+
+	err = api.nc.PublishRequest(subject, inbox, bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	queueID := ""
+	timeout := o.timeout
+	for {
+		msgCtx, cancel := context.WithTimeout(ctx, timeout)
+		m, err := sub.NextMsgWithContext(msgCtx)
+		cancel()
+		if err != nil {
+			if queueID != "" {
+				// the caller's context may already be done, so the cancellation is sent without it
+				_, _ = api.CancelQueuedDeploy(context.Background(), *request.TargetNode, queueID, opts...)
+			}
+			return nil, err
+		}
+
+		env, err := extractEnvelope(m.Data)
+		if err != nil {
+			return nil, err
+		}
+		if env.Error != nil {
+			return nil, envelopeError(env.Error)
+		}
+
+		raw, err := json.Marshal(env.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		if env.PayloadType == DeployQueuedResponseType {
+			var queued DeployQueuedResponse
+			err = json.Unmarshal(raw, &queued)
+			if err != nil {
+				return nil, err
+			}
+
+			queueID = queued.QueueID
+			timeout = time.Until(queued.ExpiresAt) + o.timeout
+			if onQueued != nil {
+				onQueued(queued)
+			}
+			continue
+		}
+
+		var response RunResponse
+		err = json.Unmarshal(raw, &response)
+		if err != nil {
+			return nil, err
+		}
+		return &response, nil
+	}
+}
+
+// Cancels a deploy request that is waiting in the given node's deploy queue, giving up when the
+// context is done. The node only accepts cancellations identifying the request's issuer, or an
+// issuer granted update in the system namespace, through WithIdentity
+func (api *Client) CancelQueuedDeploy(ctx context.Context, nodeId string, queueID string, opts ...CallOption) (*CancelDeployResponse, error) {
+	subject := fmt.Sprintf("%s.CANCELDEPLOY.%s.%s", APIPrefix, api.namespace, nodeId)
+	bytes, err := api.performRequest(ctx, subject, CancelDeployRequest{QueueID: queueID}, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response CancelDeployResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Requests information for a given node within the client's namespace, giving up when the
+// context is done
+func (api *Client) NodeInfo(ctx context.Context, nodeId string, opts ...CallOption) (*InfoResponse, error) {
+	return api.NodeInfoOwnedBy(ctx, nodeId, "", opts...)
+}
+
+// Requests information for a given node, reporting only the workloads deployed by the given
+// issuer. An empty issuer reports every workload
+func (api *Client) NodeInfoOwnedBy(ctx context.Context, nodeId string, issuer string, opts ...CallOption) (*InfoResponse, error) {
+	var filter interface{}
+	if issuer != "" {
+		filter = WorkloadFilter{Issuer: issuer}
+	}
+
+	subject := fmt.Sprintf("%s.INFO.%s.%s", APIPrefix, api.namespace, nodeId)
+	bytes, err := api.performRequest(ctx, subject, filter, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response InfoResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Places the given node into lame duck mode, giving up when the context is done
+func (api *Client) EnterLameDuck(ctx context.Context, nodeId string, opts ...CallOption) (*LameDuckResponse, error) {
+	subject := fmt.Sprintf("%s.LAMEDUCK.%s", APIPrefix, nodeId)
+	bytes, err := api.performRequest(ctx, subject, nil, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response LameDuckResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Cordons the given node so it declines auctions and deploys while continuing to run its
+// existing workloads. Cordoning an already cordoned node replaces its reason and window
+func (api *Client) CordonNode(ctx context.Context, nodeId string, request *CordonRequest, opts ...CallOption) (*CordonResponse, error) {
+	if request == nil {
+		request = &CordonRequest{}
+	}
+
+	subject := fmt.Sprintf("%s.CORDON.%s", APIPrefix, nodeId)
+	return api.cordonRequest(ctx, subject, request, opts)
+}
+
+// Lifts the cordon from the given node so it once again takes part in auctions and accepts deploys
+func (api *Client) UncordonNode(ctx context.Context, nodeId string, opts ...CallOption) (*CordonResponse, error) {
+	subject := fmt.Sprintf("%s.UNCORDON.%s", APIPrefix, nodeId)
+	return api.cordonRequest(ctx, subject, nil, opts)
+}
+
+// Asks the given node to move its workloads to its peers and then enter lame duck mode, e.g.
+// ahead of a spot instance interruption. Returns the progress of the evacuation, which is also
+// returned when the node is already evacuating
+func (api *Client) EvacuateNode(ctx context.Context, nodeId string, request *EvacuateRequest, opts ...CallOption) (*EvacuationStatus, error) {
+	if request == nil {
+		request = &EvacuateRequest{}
+	}
+
+	subject := fmt.Sprintf("%s.EVACUATE.%s", APIPrefix, nodeId)
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response EvacuationStatus
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Asks the given node to rotate its xkey. Clients holding the previous xkey should fetch the new
+// one, e.g. from the node's info, before the grace period ends
+func (api *Client) RotateNodeKeys(ctx context.Context, nodeId string, request *RotateKeysRequest, opts ...CallOption) (*RotateKeysResponse, error) {
+	if request == nil {
+		request = &RotateKeysRequest{}
+	}
+
+	subject := fmt.Sprintf("%s.ROTATEKEYS.%s", APIPrefix, nodeId)
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response RotateKeysResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Retrieves a snapshot of the given node's internal state, along with the requested profiles.
+// Nodes only answer when their diagnostics are enabled
+func (api *Client) Diagnostics(ctx context.Context, nodeId string, request *DiagnosticsRequest, opts ...CallOption) (*DiagnosticsResponse, error) {
+	if request == nil {
+		request = &DiagnosticsRequest{}
+	}
+
+	subject := fmt.Sprintf("%s.DIAGNOSTICS.%s", APIPrefix, nodeId)
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response DiagnosticsResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Replaces the faults injected by the given node, which must have been built with the faults
+// tag; other nodes don't answer
+func (api *Client) InjectFaults(ctx context.Context, nodeId string, request *FaultInjectionRequest, opts ...CallOption) (*FaultInjectionResponse, error) {
+	if request == nil {
+		request = &FaultInjectionRequest{}
+	}
+
+	subject := fmt.Sprintf("%s.FAULTS.%s", APIPrefix, nodeId)
+	bytes, err := api.performRequest(ctx, subject, request, false, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response FaultInjectionResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Retrieves the recent trigger executions of a function workload running on the given node
+func (api *Client) ExecutionHistory(ctx context.Context, nodeId string, request *ExecutionHistoryRequest, opts ...CallOption) (*ExecutionHistoryResponse, error) {
+	subject := fmt.Sprintf("%s.HISTORY.%s.%s", APIPrefix, api.namespace, nodeId)
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response ExecutionHistoryResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Lists the trigger subject subscriptions held on behalf of each workload in the client's
+// namespace on the given node
+func (api *Client) TriggerSubscriptions(ctx context.Context, nodeId string, opts ...CallOption) (*TriggerSubscriptionsResponse, error) {
+	subject := fmt.Sprintf("%s.SUBZ.%s.%s", APIPrefix, api.namespace, nodeId)
+	bytes, err := api.performRequest(ctx, subject, nil, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response TriggerSubscriptionsResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Pauses a workload without undeploying it, draining its trigger subscriptions and, when the
+// request says so, pausing its VM
+func (api *Client) PauseWorkload(ctx context.Context, nodeId string, request *PauseRequest, opts ...CallOption) (*PauseResponse, error) {
+	subject := fmt.Sprintf("%s.PAUSE.%s.%s", APIPrefix, api.namespace, nodeId)
+	return api.pauseRequest(ctx, subject, request, opts)
+}
+
+// Resumes a paused workload
+func (api *Client) ResumeWorkload(ctx context.Context, nodeId string, request *ResumeRequest, opts ...CallOption) (*PauseResponse, error) {
+	subject := fmt.Sprintf("%s.RESUME.%s.%s", APIPrefix, api.namespace, nodeId)
+	return api.pauseRequest(ctx, subject, request, opts)
+}
+
+func (api *Client) pauseRequest(ctx context.Context, subject string, request interface{}, opts []CallOption) (*PauseResponse, error) {
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response PauseResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Lists the workloads quarantined on the given node
+func (api *Client) QuarantinedWorkloads(ctx context.Context, nodeId string, opts ...CallOption) (*QuarantineResponse, error) {
+	return api.quarantineRequest(ctx, nodeId, QuarantineRequest{Action: QuarantineActionList}, opts)
+}
+
+// Lifts the quarantine of a workload on the given node, resuming its trigger subscriptions or
+// redeploying it if it was quarantined after crashing. The claims are signed by the workload's
+// issuer or one of the node's admin keys
+func (api *Client) ResumeQuarantined(ctx context.Context, nodeId string, workloadId string, issuer nkeys.KeyPair, opts ...CallOption) (*QuarantineResponse, error) {
+	request, err := NewQuarantineRequest(QuarantineActionResume, workloadId, issuer)
+	if err != nil {
+		return nil, err
+	}
+	return api.quarantineRequest(ctx, nodeId, *request, opts)
+}
+
+// Stops a quarantined workload on the given node. The claims are signed by the workload's issuer
+// or one of the node's admin keys
+func (api *Client) StopQuarantined(ctx context.Context, nodeId string, workloadId string, issuer nkeys.KeyPair, opts ...CallOption) (*QuarantineResponse, error) {
+	request, err := NewQuarantineRequest(QuarantineActionStop, workloadId, issuer)
+	if err != nil {
+		return nil, err
+	}
+	return api.quarantineRequest(ctx, nodeId, *request, opts)
+}
+
+func (api *Client) quarantineRequest(ctx context.Context, nodeId string, request QuarantineRequest, opts []CallOption) (*QuarantineResponse, error) {
+	subject := fmt.Sprintf("%s.QUARANTINE.%s.%s", APIPrefix, api.namespace, nodeId)
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response QuarantineResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Lists the volumes the given node keeps for the client's namespace, with their usage
+func (api *Client) Volumes(ctx context.Context, nodeId string, opts ...CallOption) (*VolumeResponse, error) {
+	return api.volumeRequest(ctx, nodeId, VolumeRequest{Action: VolumeActionList}, opts)
+}
+
+// Deletes a volume, and its contents, from the given node. Volumes attached to a running
+// workload are not deleted. The request is created with NewVolumeDeleteRequest
+func (api *Client) DeleteVolume(ctx context.Context, nodeId string, request *VolumeRequest, opts ...CallOption) (*VolumeResponse, error) {
+	return api.volumeRequest(ctx, nodeId, *request, opts)
+}
+
+func (api *Client) volumeRequest(ctx context.Context, nodeId string, request VolumeRequest, opts []CallOption) (*VolumeResponse, error) {
+	subject := fmt.Sprintf("%s.VOLUMES.%s.%s", APIPrefix, api.namespace, nodeId)
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response VolumeResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Returns the usage the client's namespace has accumulated on the given node since it started
+func (api *Client) Usage(ctx context.Context, nodeId string, opts ...CallOption) (*UsageResponse, error) {
+	subject := fmt.Sprintf("%s.USAGE.%s.%s", APIPrefix, api.namespace, nodeId)
+	bytes, err := api.performRequest(ctx, subject, UsageRequest{}, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response UsageResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Checkpoints a running service workload on the given node, returning the stored checkpoint
+func (api *Client) CheckpointWorkload(ctx context.Context, nodeId string, request *CheckpointRequest, opts ...CallOption) (*CheckpointInfo, error) {
+	subject := fmt.Sprintf("%s.CHECKPOINT.%s.%s", APIPrefix, api.namespace, nodeId)
+	response, err := api.checkpointRequest(ctx, subject, request, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Checkpoints) == 0 {
+		return nil, errors.New("node did not return the stored checkpoint")
+	}
+
+	return &response.Checkpoints[0], nil
+}
+
+// Lists the checkpoints of the client's namespace stored in the given node's checkpoint bucket,
+// newest first
+func (api *Client) ListCheckpoints(ctx context.Context, nodeId string, request *CheckpointListRequest, opts ...CallOption) (*CheckpointResponse, error) {
+	if request == nil {
+		request = &CheckpointListRequest{}
+	}
+
+	subject := fmt.Sprintf("%s.CHECKPOINTS.%s.%s", APIPrefix, api.namespace, nodeId)
+	return api.checkpointRequest(ctx, subject, request, opts)
+}
+
+func (api *Client) checkpointRequest(ctx context.Context, subject string, request interface{}, opts []CallOption) (*CheckpointResponse, error) {
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response CheckpointResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Restores a workload from a checkpoint on the given node, which must share the bucket the
+// checkpoint is stored in
+func (api *Client) RestoreWorkload(ctx context.Context, nodeId string, request *RestoreRequest, opts ...CallOption) (*RestoreResponse, error) {
+	subject := fmt.Sprintf("%s.RESTORE.%s.%s", APIPrefix, api.namespace, nodeId)
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response RestoreResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Creates the client's namespace. Nodes apply the namespace's defaults and quotas to workloads
+// deployed into it
+func (api *Client) CreateNamespace(ctx context.Context, namespace *Namespace, opts ...CallOption) (*Namespace, error) {
+	return api.namespaceRequest(ctx, NamespaceRequest{Action: NamespaceActionCreate, Namespace: namespace}, opts)
+}
+
+// Retrieves the client's namespace
+func (api *Client) GetNamespace(ctx context.Context, opts ...CallOption) (*Namespace, error) {
+	return api.namespaceRequest(ctx, NamespaceRequest{Action: NamespaceActionGet}, opts)
+}
+
+// Lists every namespace. Access is authorized against the client's namespace
+func (api *Client) ListNamespaces(ctx context.Context, opts ...CallOption) ([]Namespace, error) {
+	response, err := api.namespaceResponse(ctx, NamespaceRequest{Action: NamespaceActionList}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Namespaces, nil
+}
+
+// Deletes the client's namespace, stopping or orphaning its workloads according to the given
+// policy, or the namespace's own policy when none is given
+func (api *Client) DeleteNamespace(ctx context.Context, policy NamespaceDeletionPolicy, opts ...CallOption) (*Namespace, error) {
+	return api.namespaceRequest(ctx, NamespaceRequest{Action: NamespaceActionDelete, DeletionPolicy: policy}, opts)
+}
+
+func (api *Client) namespaceRequest(ctx context.Context, request NamespaceRequest, opts []CallOption) (*Namespace, error) {
+	response, err := api.namespaceResponse(ctx, request, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Namespaces) == 0 {
+		return nil, errors.New("namespace response did not include the namespace")
+	}
+
+	return &response.Namespaces[0], nil
+}
+
+func (api *Client) namespaceResponse(ctx context.Context, request NamespaceRequest, opts []CallOption) (*NamespaceResponse, error) {
+	// creating or deleting a namespace twice fails, so only reads are retried
+	idempotent := request.Action == NamespaceActionGet || request.Action == NamespaceActionList
+
+	subject := fmt.Sprintf("%s.NAMESPACE.%s", APIPrefix, api.namespace)
+	bytes, err := api.performRequest(ctx, subject, request, idempotent, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response NamespaceResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Stores a declared resource in the client's namespace, returning it at its new version. A
+// resource with a zero version is created, failing if it already exists; otherwise the stored
+// resource is replaced, failing unless it is still at the given version
+func (api *Client) ApplyResource(ctx context.Context, resource *Resource, opts ...CallOption) (*Resource, error) {
+	return api.resourceRequest(ctx, ResourceRequest{Action: ResourceActionApply, Resource: resource}, opts)
+}
+
+// Retrieves a declared resource of the client's namespace
+func (api *Client) GetResource(ctx context.Context, kind ResourceKind, id string, opts ...CallOption) (*Resource, error) {
+	return api.resourceRequest(ctx, ResourceRequest{Action: ResourceActionGet, Kind: kind, ID: id}, opts)
+}
+
+// Lists the declared resources of the client's namespace, only those of the given kind unless
+// the kind is empty
+func (api *Client) ListResources(ctx context.Context, kind ResourceKind, opts ...CallOption) ([]Resource, error) {
+	response, err := api.resourceResponse(ctx, ResourceRequest{Action: ResourceActionList, Kind: kind}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Resources, nil
+}
+
+// Deletes a declared resource of the client's namespace, failing unless it is at the given
+// version. A zero version deletes the resource whatever its version
+func (api *Client) DeleteResource(ctx context.Context, kind ResourceKind, id string, version uint64, opts ...CallOption) (*Resource, error) {
+	return api.resourceRequest(ctx, ResourceRequest{Action: ResourceActionDelete, Kind: kind, ID: id, Version: version}, opts)
+}
+
+func (api *Client) resourceRequest(ctx context.Context, request ResourceRequest, opts []CallOption) (*Resource, error) {
+	response, err := api.resourceResponse(ctx, request, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Resources) == 0 {
+		return nil, errors.New("resource response did not include the resource")
+	}
+
+	return &response.Resources[0], nil
+}
+
+func (api *Client) resourceResponse(ctx context.Context, request ResourceRequest, opts []CallOption) (*ResourceResponse, error) {
+	// versions make applying and deleting safe to repeat only when they are given, so only reads
+	// are retried
+	idempotent := request.Action == ResourceActionGet || request.Action == ResourceActionList
+
+	subject := fmt.Sprintf("%s.RESOURCES.%s", APIPrefix, api.namespace)
+	bytes, err := api.performRequest(ctx, subject, request, idempotent, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response ResourceResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Collects the state every node reports of itself and of the workloads it runs in the client's
+// namespace, until the request timeout elapses
+func (api *Client) NodeStates(ctx context.Context, opts ...CallOption) ([]NodeState, error) {
+	subject := fmt.Sprintf("%s.STATE.%s", APIPrefix, api.namespace)
+
+	states := make([]NodeState, 0)
+	err := api.gather(ctx, subject, nil, opts, func(env *Envelope) {
+		var state NodeState
+		bytes, err := json.Marshal(env.Data)
+		if err != nil {
+			return
+		}
+		err = json.Unmarshal(bytes, &state)
+		if err != nil {
+			return
+		}
+		states = append(states, state)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var response StopResponse
+	return states, nil
+}
+
+// Compares a declared resource of the client's namespace with the state reported by every node
+// in the nexus, describing how the nexus differs from the declaration
+func (api *Client) ResourceDrift(ctx context.Context, kind ResourceKind, id string, opts ...CallOption) (*ResourceDrift, error) {
+	resource, err := api.GetResource(ctx, kind, id, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := api.NodeStates(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := DetectDrift(*resource, states)
+	return &drift, nil
+}
+
+// Retrieves the status of a job workload deployed to the given node, including jobs that have
+// already completed
+func (api *Client) JobStatus(ctx context.Context, nodeId string, workloadId string, opts ...CallOption) (*JobStatus, error) {
+	subject := fmt.Sprintf("%s.JOBSTATUS.%s.%s", APIPrefix, api.namespace, nodeId)
+	bytes, err := api.performRequest(ctx, subject, JobStatusRequest{WorkloadId: workloadId}, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response JobStatus
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Adds, replaces and removes tags of the given node at runtime. The node persists the changes so
+// that they survive restarts
+func (api *Client) UpdateNodeTags(ctx context.Context, nodeId string, request *NodeTagsRequest, opts ...CallOption) (*NodeTagsResponse, error) {
+	subject := fmt.Sprintf("%s.TAGS.%s", APIPrefix, nodeId)
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response NodeTagsResponse
 	err = json.Unmarshal(bytes, &response)
 	if err != nil {
 		return nil, err
 	}
+
 	return &response, nil
+}
+
+// Asks the given node to update itself to the signed binary described by the request. The node
+// responds once the binary is staged and verified, then restarts into it and redeploys its
+// workloads. Fetching the binary can take a while, so a generous request timeout is advised
+func (api *Client) UpdateNode(ctx context.Context, nodeId string, request *NodeUpdateRequest, opts ...CallOption) (*NodeUpdateResponse, error) {
+	subject := fmt.Sprintf("%s.UPDATE.%s", APIPrefix, nodeId)
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response NodeUpdateResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
 
+	return &response, nil
 }
 
-// Attempts to start a workload. The workload URI, at the moment, must always point to a NATS object store
-// bucket in the form of `nats://{bucket}/{key}`. Note that JetStream domains can be supplied on the workload
-// request and aren't part of the bucket+key URL.
-func (api *Client) StartWorkload(request *DeployRequest) (*RunResponse, error) {
-	subject := fmt.Sprintf("%s.DEPLOY.%s.%s", APIPrefix, api.namespace, *request.TargetNode)
-	bytes, err := api.performRequest(subject, request)
+// Starts rolling the given node's agents onto a new rootfs image. The node responds once the image
+// has been fetched and verified; progress is reported through rootfs rollout events and the
+// rollout's status
+func (api *Client) RolloutRootfs(ctx context.Context, nodeId string, request *RootfsRolloutRequest, opts ...CallOption) (*RootfsRolloutStatus, error) {
+	subject := fmt.Sprintf("%s.ROOTFS.%s", APIPrefix, nodeId)
+	return api.rootfsRolloutRequest(ctx, subject, request, opts)
+}
+
+// Retrieves the status of the given node's current or most recent rootfs rollout
+func (api *Client) RootfsRolloutStatus(ctx context.Context, nodeId string, opts ...CallOption) (*RootfsRolloutStatus, error) {
+	subject := fmt.Sprintf("%s.ROOTFSSTATUS.%s", APIPrefix, nodeId)
+	return api.rootfsRolloutRequest(ctx, subject, nil, opts)
+}
+
+// Aborts the given node's rootfs rollout. Workloads that have already been recycled keep running
+// on the new image, while agents started from then on use the previous one
+func (api *Client) AbortRootfsRollout(ctx context.Context, nodeId string, opts ...CallOption) (*RootfsRolloutStatus, error) {
+	subject := fmt.Sprintf("%s.ROOTFSABORT.%s", APIPrefix, nodeId)
+	return api.rootfsRolloutRequest(ctx, subject, nil, opts)
+}
+
+func (api *Client) rootfsRolloutRequest(ctx context.Context, subject string, request *RootfsRolloutRequest, opts []CallOption) (*RootfsRolloutStatus, error) {
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	var response RunResponse
+	var response RootfsRolloutStatus
 	err = json.Unmarshal(bytes, &response)
 	if err != nil {
 		return nil, err
 	}
+
 	return &response, nil
 }
 
-// Requests information for a given node within the client's namespace
-func (api *Client) NodeInfo(nodeId string) (*InfoResponse, error) {
-	subject := fmt.Sprintf("%s.INFO.%s.%s", APIPrefix, api.namespace, nodeId)
-	bytes, err := api.performRequest(subject, nil)
+// Retrieves the JSON schemas of the events emitted by the given node and its agents, so tooling
+// can validate and decode them
+func (api *Client) EventSchemas(ctx context.Context, nodeId string, opts ...CallOption) (*EventSchemasResponse, error) {
+	subject := fmt.Sprintf("%s.SCHEMAS.%s", APIPrefix, nodeId)
+	bytes, err := api.performRequest(ctx, subject, nil, true, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	var response InfoResponse
+	var response EventSchemasResponse
 	err = json.Unmarshal(bytes, &response)
 	if err != nil {
 		return nil, err
 	}
+
 	return &response, nil
 }
 
-func (api *Client) EnterLameDuck(nodeId string) (*LameDuckResponse, error) {
-	subject := fmt.Sprintf("%s.LAMEDUCK.%s", APIPrefix, nodeId)
-	bytes, err := api.performRequest(subject, nil)
+// Asks the given node to reload its configuration file, applying the settings that can be
+// changed without a restart
+func (api *Client) ReloadNodeConfig(ctx context.Context, nodeId string, opts ...CallOption) (*ReloadResponse, error) {
+	subject := fmt.Sprintf("%s.RELOAD.%s", APIPrefix, nodeId)
+	bytes, err := api.performRequest(ctx, subject, nil, true, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	var response LameDuckResponse
+	var response ReloadResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func (api *Client) cordonRequest(ctx context.Context, subject string, request *CordonRequest, opts []CallOption) (*CordonResponse, error) {
+	bytes, err := api.performRequest(ctx, subject, request, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response CordonResponse
 	err = json.Unmarshal(bytes, &response)
 	if err != nil {
 		return nil, err
@@ -118,20 +1005,34 @@ func (api *Client) EnterLameDuck(nodeId string) (*LameDuckResponse, error) {
 // A workloadId of "" will not filter by workload, and only
 // filter by the client's namespace. If a workload ID/name is supplied, the filter
 // will be for both namespace and workload. If you don't want these filters
-// then use PingNodes
-func (api *Client) PingWorkloads(workloadID string) ([]WorkloadPingResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), api.timeout)
-	defer cancel()
+// then use PingNodes. Responses are collected until the request timeout elapses
+func (api *Client) PingWorkloads(ctx context.Context, workloadID string, opts ...CallOption) ([]WorkloadPingResponse, error) {
+	return api.PingWorkloadsOwnedBy(ctx, workloadID, "", opts...)
+}
 
+// Filtered workload ping that only collects the workloads deployed by the given issuer. An
+// empty issuer matches every workload
+func (api *Client) PingWorkloadsOwnedBy(ctx context.Context, workloadID string, issuer string, opts ...CallOption) ([]WorkloadPingResponse, error) {
 	workloadID = strings.TrimSpace(workloadID)
 
-	responses := make([]WorkloadPingResponse, 0)
-
-	sub, err := api.nc.Subscribe(api.nc.NewRespInbox(), func(m *nats.Msg) {
-		env, err := extractEnvelope(m.Data)
+	var payload []byte
+	if issuer != "" {
+		var err error
+		payload, err = json.Marshal(WorkloadFilter{Issuer: issuer})
 		if err != nil {
-			return
+			return nil, err
 		}
+	}
+
+	var subject string
+	if len(workloadID) == 0 {
+		subject = fmt.Sprintf("%s.WPING.%s", APIPrefix, api.namespace)
+	} else {
+		subject = fmt.Sprintf("%s.WPING.%s.%s", APIPrefix, api.namespace, workloadID)
+	}
+
+	responses := make([]WorkloadPingResponse, 0)
+	err := api.gather(ctx, subject, payload, opts, func(env *Envelope) {
 		var resp WorkloadPingResponse
 		bytes, err := json.Marshal(env.Data)
 		if err != nil {
@@ -147,37 +1048,49 @@ func (api *Client) PingWorkloads(workloadID string) ([]WorkloadPingResponse, err
 		return nil, err
 	}
 
-	var subject string
-	if len(workloadID) == 0 {
-		subject = fmt.Sprintf("%s.WPING.%s", APIPrefix, api.namespace)
-	} else {
-		subject = fmt.Sprintf("%s.WPING.%s.%s", APIPrefix, api.namespace, workloadID)
-	}
-	msg := nats.NewMsg(subject)
-	msg.Reply = sub.Subject
-	err = api.nc.PublishMsg(msg)
+	return responses, nil
+}
+
+// Finds the workloads with the given name in the client's namespace on every node that answers a
+// workload ping, limited to those deployed by the given issuer unless it is empty. Nodes that do
+// not answer before the request timeout, e.g. across a network partition, are left out
+func (api *Client) FindWorkloadsByName(ctx context.Context, name string, issuer string, opts ...CallOption) ([]WorkloadLocation, error) {
+	responses, err := api.PingWorkloadsOwnedBy(ctx, "", issuer, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	<-ctx.Done()
-	return responses, nil
-}
+	found := make([]WorkloadLocation, 0)
+	for _, resp := range responses {
+		for _, machine := range resp.RunningMachines {
+			if machine.Name != name || machine.Namespace != api.namespace {
+				continue
+			}
+			found = append(found, WorkloadLocation{NodeId: resp.NodeId, WorkloadPingMachineSummary: machine})
+		}
+	}
 
-// Attempts to resolve viable candidate nodes where a proposed workload can be deployed
-func (api *Client) Auction(req *AuctionRequest) ([]AuctionResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), api.timeout)
-	defer cancel()
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].NodeId != found[j].NodeId {
+			return found[i].NodeId < found[j].NodeId
+		}
+		return found[i].Id < found[j].Id
+	})
 
-	responses := make([]AuctionResponse, 0)
+	return found, nil
+}
 
-	sub, err := api.nc.Subscribe(api.nc.NewRespInbox(), func(m *nats.Msg) {
-		env, err := extractEnvelope(m.Data)
-		if err != nil {
-			api.log.Error("failed to extract envelope", slog.Any("err", err), slog.Any("nats_msg.Data", m.Data))
-			return
-		}
+// Attempts to resolve viable candidate nodes where a proposed workload can be deployed, collecting
+// candidates until the request timeout elapses
+func (api *Client) Auction(ctx context.Context, req *AuctionRequest, opts ...CallOption) ([]AuctionResponse, error) {
+	var payload []byte
+	if req != nil {
+		payload, _ = json.Marshal(req)
+	}
 
+	responses := make([]AuctionResponse, 0)
+	sent := time.Now()
+	err := api.gather(ctx, fmt.Sprintf("%s.AUCTION", APIPrefix), payload, opts, func(env *Envelope) {
 		var resp AuctionResponse
 		bytes, err := json.Marshal(env.Data)
 		if err != nil {
@@ -190,47 +1103,75 @@ func (api *Client) Auction(req *AuctionRequest) ([]AuctionResponse, error) {
 			api.log.Error("failed to unmarshal auction response", slog.Any("err", err))
 			return
 		}
+		resp.RoundTrip = roundTrip(sent, resp.ReplyDelayMillis)
 		responses = append(responses, resp)
 	})
 	if err != nil {
-		api.log.Error("failed to subscribe", slog.Any("err", err))
 		return nil, err
 	}
 
-	var payload []byte
-	if req != nil {
-		payload, _ = json.Marshal(req)
-	}
+	return responses, nil
+}
 
-	msg := nats.NewMsg(fmt.Sprintf("%s.AUCTION", APIPrefix))
-	msg.Reply = sub.Subject
-	msg.Data = payload
+// Attempts to list all nodes. Note that any node within the Nexus will respond to this ping, regardless
+// of the namespaces of their running workloads. Nodes are collected until the request timeout elapses
+func (api *Client) PingNodes(ctx context.Context, opts ...CallOption) ([]PingResponse, error) {
+	responses := make([]PingResponse, 0)
+	sent := time.Now()
+	err := api.gather(ctx, fmt.Sprintf("%s.PING", APIPrefix), nil, opts, func(env *Envelope) {
+		var resp PingResponse
+		bytes, err := json.Marshal(env.Data)
+		if err != nil {
+			api.log.Error("failed to marshal envelope data", slog.Any("err", err))
+			return
+		}
 
-	err = api.nc.PublishMsg(msg)
+		err = json.Unmarshal(bytes, &resp)
+		if err != nil {
+			api.log.Error("failed to unmarshal PingResponse", slog.Any("err", err))
+			return
+		}
+		resp.RoundTrip = roundTrip(sent, resp.ReplyDelayMillis)
+		responses = append(responses, resp)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	<-ctx.Done()
 	return responses, nil
 }
 
-// Attempts to list all nodes. Note that any node within the Nexus will respond to this ping, regardless
-// of the namespaces of their running workloads
-func (api *Client) PingNodes() ([]PingResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), api.timeout)
-	defer cancel()
+// Queries the members of a workload group in the client's namespace, gathering a response from
+// every node hosting at least one member
+func (api *Client) GroupStatus(ctx context.Context, group string, opts ...CallOption) ([]GroupResponse, error) {
+	return api.groupOperation(ctx, "GPING", group, nil, opts)
+}
 
-	responses := make([]PingResponse, 0)
+// Stops every member of a workload group in the client's namespace, across all nodes
+func (api *Client) StopGroup(ctx context.Context, request *GroupRequest, opts ...CallOption) ([]GroupResponse, error) {
+	return api.groupOperation(ctx, "GSTOP", request.Group, request, opts)
+}
 
-	sub, err := api.nc.Subscribe(api.nc.NewRespInbox(), func(m *nats.Msg) {
-		env, err := extractEnvelope(m.Data)
-		if err != nil {
-			api.log.Error("failed to extract envelope", slog.Any("err", err), slog.Any("nats_msg.Data", m.Data))
+// Restarts every member of a workload group in the client's namespace. Each node redeploys
+// the members it hosts, so restarted workloads are assigned new IDs
+func (api *Client) RestartGroup(ctx context.Context, request *GroupRequest, opts ...CallOption) ([]GroupResponse, error) {
+	return api.groupOperation(ctx, "GRESTART", request.Group, request, opts)
+}
+
+func (api *Client) groupOperation(ctx context.Context, op string, group string, request *GroupRequest, opts []CallOption) ([]GroupResponse, error) {
+	var payload []byte
+	if request != nil {
+		payload, _ = json.Marshal(request)
+	}
+
+	responses := make([]GroupResponse, 0)
+	err := api.gather(ctx, fmt.Sprintf("%s.%s.%s.%s", APIPrefix, op, api.namespace, group), payload, opts, func(env *Envelope) {
+		if env.Error != nil {
+			api.log.Warn("node failed group request", slog.String("group", group), slog.Any("err", env.Error))
 			return
 		}
 
-		var resp PingResponse
+		var resp GroupResponse
 		bytes, err := json.Marshal(env.Data)
 		if err != nil {
 			api.log.Error("failed to marshal envelope data", slog.Any("err", err))
@@ -239,27 +1180,163 @@ func (api *Client) PingNodes() ([]PingResponse, error) {
 
 		err = json.Unmarshal(bytes, &resp)
 		if err != nil {
-			api.log.Error("failed to unmarshal PingResponse", slog.Any("err", err))
+			api.log.Error("failed to unmarshal group response", slog.Any("err", err))
 			return
 		}
 		responses = append(responses, resp)
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// Discovers nodes matching the request's filters, streaming each node's response on the returned
+// channel as it arrives. The channel is closed once the request timeout elapses, the context is
+// done, or, when a quiet period is given, no node has responded within that period after the
+// request's jitter window
+func (api *Client) DiscoverNodes(ctx context.Context, req *DiscoverRequest, opts ...CallOption) (<-chan PingResponse, error) {
+	if req == nil {
+		req = &DiscoverRequest{}
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	o := api.callOptions(opts)
 
+	sub, err := api.nc.SubscribeSync(api.nc.NewRespInbox())
 	if err != nil {
 		api.log.Error("failed to subscribe", slog.Any("err", err))
 		return nil, err
 	}
-	msg := nats.NewMsg(fmt.Sprintf("%s.PING", APIPrefix))
+
+	msg := nats.NewMsg(fmt.Sprintf("%s.DISCOVER", APIPrefix))
 	msg.Reply = sub.Subject
+	msg.Data = payload
+
+	sent := time.Now()
 	err = api.nc.PublishMsg(msg)
 	if err != nil {
+		_ = sub.Unsubscribe()
 		return nil, err
 	}
 
-	<-ctx.Done()
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	responses := make(chan PingResponse)
+
+	go func() {
+		defer close(responses)
+		defer cancel()
+		defer func() {
+			_ = sub.Unsubscribe()
+		}()
+
+		// no node replies later than its jitter, so the quiet period starts once that has passed
+		wait := o.quiet
+		if wait > 0 {
+			wait += time.Duration(req.MaxJitterMillis) * time.Millisecond
+		}
+
+		for {
+			next, cancelNext := ctx, context.CancelFunc(func() {})
+			if wait > 0 {
+				next, cancelNext = context.WithTimeout(ctx, wait)
+			}
+
+			m, err := sub.NextMsgWithContext(next)
+			cancelNext()
+			if err != nil {
+				return
+			}
+			wait = o.quiet
+
+			env, err := extractEnvelope(m.Data)
+			if err != nil {
+				api.log.Error("failed to extract envelope", slog.Any("err", err), slog.Any("nats_msg.Data", m.Data))
+				continue
+			}
+
+			var resp PingResponse
+			bytes, err := json.Marshal(env.Data)
+			if err != nil {
+				api.log.Error("failed to marshal envelope data", slog.Any("err", err))
+				continue
+			}
+
+			err = json.Unmarshal(bytes, &resp)
+			if err != nil {
+				api.log.Error("failed to unmarshal discover response", slog.Any("err", err))
+				continue
+			}
+			resp.RoundTrip = roundTrip(sent, resp.ReplyDelayMillis)
+
+			select {
+			case responses <- resp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
 	return responses, nil
 }
 
+// Publishes a request to the given subject and hands every enveloped response to the
+// handler until the request timeout elapses. If the context is done first, its error is
+// returned. Responses are handled on the calling goroutine, so the handler needs no synchronization
+func (api *Client) gather(ctx context.Context, subject string, payload []byte, opts []CallOption, handle func(*Envelope)) error {
+	o := api.callOptions(opts)
+
+	window, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+
+	sub, err := api.nc.SubscribeSync(api.nc.NewRespInbox())
+	if err != nil {
+		api.log.Error("failed to subscribe", slog.Any("err", err))
+		return err
+	}
+	defer func() {
+		_ = sub.Unsubscribe()
+	}()
+
+	msg, err := api.newRequestMsg(subject, payload, o)
+	if err != nil {
+		return err
+	}
+	msg.Reply = sub.Subject
+
+	err = api.nc.PublishMsg(msg)
+	if err != nil {
+		return err
+	}
+
+	for {
+		m, err := sub.NextMsgWithContext(window)
+		if err != nil {
+			// the window closing is how a gather ends, not a failure, unless the caller gave up first
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil
+			}
+			return err
+		}
+
+		env, err := extractEnvelope(m.Data)
+		if err != nil {
+			api.log.Error("failed to extract envelope", slog.Any("err", err), slog.Any("nats_msg.Data", m.Data))
+			continue
+		}
+
+		handle(env)
+	}
+}
+
 // A convenience function that subscribes to all available logs and returns
 // an unbuffered, blocking channel
 func (api *Client) MonitorAllLogs() (chan EmittedLog, error) {
@@ -327,6 +1404,38 @@ func (api *Client) MonitorEvents(
 	return eventChannel, nil
 }
 
+// Creates a NATS subscription to the usage reports published on the given metering subject,
+// or on the default one when empty, by the node given or, with '*', by every node. Reports
+// whose signature does not verify are dropped
+func (api *Client) MonitorUsage(meteringSubject string, nodeFilter string, bufferLength int) (chan UsageReport, error) {
+	if meteringSubject == "" {
+		meteringSubject = DefaultMeteringSubject
+	}
+
+	reportChannel := make(chan UsageReport, bufferLength)
+	_, err := api.nc.Subscribe(fmt.Sprintf("%s.%s", meteringSubject, nodeFilter), func(m *nats.Msg) {
+		var signed SignedUsageReport
+		err := json.Unmarshal(m.Data, &signed)
+		if err != nil {
+			api.log.Error("Usage report deserialization failure", slog.Any("err", err))
+			return
+		}
+
+		report, err := signed.Verify()
+		if err != nil {
+			api.log.Warn("Dropping unverified usage report", slog.String("subject", m.Subject), slog.Any("err", err))
+			return
+		}
+
+		reportChannel <- *report
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reportChannel, nil
+}
+
 func handleEventEntry(ch chan EmittedEvent) func(m *nats.Msg) {
 	return func(m *nats.Msg) {
 		tokens := strings.Split(m.Subject, ".")
@@ -381,8 +1490,10 @@ func handleLogEntry(api *Client, ch chan EmittedLog) func(m *nats.Msg) {
 }
 
 // Helper that submits data, gets a standard envelope back, and returns the inner data
-// payload as JSON
-func (api *Client) performRequest(subject string, raw interface{}) ([]byte, error) {
+// payload as JSON. Each attempt is bounded by the request timeout as well as the context
+func (api *Client) performRequest(ctx context.Context, subject string, raw interface{}, idempotent bool, opts []CallOption) ([]byte, error) {
+	o := api.callOptions(opts)
+
 	var bytes []byte
 	var err error
 	if raw == nil {
@@ -394,20 +1505,77 @@ func (api *Client) performRequest(subject string, raw interface{}) ([]byte, erro
 		}
 	}
 
-	resp, err := api.nc.Request(subject, bytes, api.timeout)
+	msg, err := api.newRequestMsg(subject, bytes, o)
 	if err != nil {
 		return nil, err
 	}
+
+	var resp *nats.Msg
+	for attempt := 0; ; attempt++ {
+		reqCtx, cancel := context.WithTimeout(ctx, o.timeout)
+		resp, err = api.nc.RequestMsgWithContext(reqCtx, msg)
+		cancel()
+		if err == nil {
+			break
+		}
+
+		retryable := errors.Is(err, nats.ErrNoResponders) ||
+			(idempotent && ctx.Err() == nil && (errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout)))
+		if !retryable || attempt >= o.retries {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(o.backoff):
+		}
+	}
+
 	env, err := extractEnvelope(resp.Data)
 	if err != nil {
 		return nil, err
 	}
 	if env.Error != nil {
-		return nil, fmt.Errorf("%v", env.Error)
+		return nil, envelopeError(env.Error)
 	}
 	return json.Marshal(env.Data)
 }
 
+// Converts the error carried by an envelope into the typed error it describes, if any
+func envelopeError(raw interface{}) error {
+	if fields, ok := raw.(map[string]interface{}); ok && fields["code"] == ErrorCodeUnauthorized {
+		reason, _ := fields["reason"].(string)
+		return NewAuthorizationError(reason)
+	}
+
+	if fields, ok := raw.(map[string]interface{}); ok && fields["code"] == ErrorCodeDeployRejected {
+		reason, _ := fields["reason"].(string)
+		message, _ := fields["message"].(string)
+		return NewDeployRejection(DeployRejectionReason(reason), message)
+	}
+
+	return fmt.Errorf("%v", raw)
+}
+
+// Builds a request message, attaching an identity token when the request identifies its issuer
+func (api *Client) newRequestMsg(subject string, payload []byte, o callOptions) (*nats.Msg, error) {
+	msg := nats.NewMsg(subject)
+	msg.Data = payload
+
+	if o.identity != nil {
+		token, err := NewIdentityToken(api.namespace, o.identity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create identity token: %s", err)
+		}
+		msg.Header.Set(IdentityHeader, token)
+	} else if o.identityToken != "" {
+		msg.Header.Set(IdentityHeader, o.identityToken)
+	}
+
+	return msg, nil
+}
+
 func extractEnvelope(data []byte) (*Envelope, error) {
 	if len(data) == 0 {
 		return nil, errors.New("no data for envelope")
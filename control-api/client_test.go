@@ -0,0 +1,140 @@
+package controlapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func startTestNats(t *testing.T) *nats.Conn {
+	ns, err := server.NewServer(&server.Options{Port: -1})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %s", err)
+	}
+	ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not become ready")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect to NATS: %s", err)
+	}
+	t.Cleanup(nc.Close)
+
+	return nc
+}
+
+func TestGatherEndsQuietlyWhenWindowCloses(t *testing.T) {
+	_, client := startFakeNexus(t, map[string]string{"node": "workload"})
+
+	responses, err := client.PingWorkloads(context.Background(), "workload")
+	if err != nil {
+		t.Fatalf("Expected the gather window closing not to be an error: %s", err)
+	}
+	if len(responses) != 1 || responses[0].NodeId != "node" {
+		t.Fatalf("Expected the node's response to be gathered, got %+v", responses)
+	}
+}
+
+func TestGatherReturnsContextErrorWhenCallerGivesUp(t *testing.T) {
+	_, client := startFakeNexus(t, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err := client.PingWorkloads(ctx, "", WithRequestTimeout(5*time.Second))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the caller's cancellation to be returned, got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = client.PingNodes(ctx, WithRequestTimeout(5*time.Second))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the caller's deadline to be returned, got %v", err)
+	}
+}
+
+// Answers workload pings in the default namespace on behalf of the given nodes, applying the
+// issuer filter as nodes do
+func startFakeWorkloadPings(t *testing.T, nodes map[string][]WorkloadPingMachineSummary) *Client {
+	nc := startTestNats(t)
+
+	_, err := nc.Subscribe("$NEX.WPING.default", func(m *nats.Msg) {
+		var filter WorkloadFilter
+		if len(m.Data) > 0 {
+			_ = json.Unmarshal(m.Data, &filter)
+		}
+
+		for node, machines := range nodes {
+			matching := make([]WorkloadPingMachineSummary, 0)
+			for _, machine := range machines {
+				if filter.Matches(machine.Issuer) {
+					matching = append(matching, machine)
+				}
+			}
+
+			raw, _ := json.Marshal(NewEnvelope(PingResponseType, WorkloadPingResponse{NodeId: node, RunningMachines: matching}, nil))
+			_ = m.Respond(raw)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe to workload pings: %s", err)
+	}
+
+	return NewApiClientWithNamespace(nc, 100*time.Millisecond, "default", slog.Default())
+}
+
+func TestFindWorkloadsByName(t *testing.T) {
+	client := startFakeWorkloadPings(t, map[string][]WorkloadPingMachineSummary{
+		"node-b": {
+			{Id: "b1", Namespace: "default", Name: "echo", Issuer: "ALICE"},
+			{Id: "b2", Namespace: "default", Name: "other", Issuer: "ALICE"},
+		},
+		"node-a": {
+			{Id: "a2", Namespace: "default", Name: "echo", Issuer: "BOB"},
+			{Id: "a1", Namespace: "default", Name: "echo", Issuer: "ALICE"},
+			{Id: "a3", Namespace: "staging", Name: "echo", Issuer: "ALICE"},
+		},
+	})
+
+	for _, tc := range []struct {
+		name     string
+		workload string
+		issuer   string
+		want     []string
+	}{
+		{name: "every issuer, sorted by node and id", workload: "echo", want: []string{"node-a/a1", "node-a/a2", "node-b/b1"}},
+		{name: "only the issuer's workloads", workload: "echo", issuer: "ALICE", want: []string{"node-a/a1", "node-b/b1"}},
+		{name: "other names left out", workload: "other", want: []string{"node-b/b2"}},
+		{name: "no matches", workload: "missing", want: []string{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			found, err := client.FindWorkloadsByName(context.Background(), tc.workload, tc.issuer)
+			if err != nil {
+				t.Fatalf("Expected workloads to be found: %s", err)
+			}
+
+			got := make([]string, 0, len(found))
+			for _, workload := range found {
+				if workload.Namespace != "default" {
+					t.Fatalf("Expected only workloads in the client's namespace, got %+v", workload)
+				}
+				got = append(got, workload.NodeId+"/"+workload.Id)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package controlapi
+
+import "time"
+
+// Request to cordon a node. A cordoned node keeps running its existing workloads and answering
+// pings, but declines auctions and deploy requests. When Until is set, the node uncordons itself
+// once that time has passed, e.g. at the end of a maintenance window
+type CordonRequest struct {
+	Reason string     `json:"reason,omitempty"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+type CordonResponse struct {
+	NodeId   string        `json:"node_id"`
+	Cordoned bool          `json:"cordoned"`
+	Cordon   *CordonStatus `json:"cordon,omitempty"`
+}
+
+type CordonStatus struct {
+	Reason string     `json:"reason,omitempty"`
+	Since  time.Time  `json:"since"`
+	Until  *time.Time `json:"until,omitempty"`
+}
@@ -0,0 +1,62 @@
+package controlapi
+
+import (
+	"fmt"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// Request to move trigger subject subscriptions from one deployed workload to another deployed
+// workload on the same node. When no trigger subjects are given, every trigger subject of the
+// source workload is moved
+type CutoverRequest struct {
+	FromWorkloadId  string   `json:"from_workload_id"`
+	ToWorkloadId    string   `json:"to_workload_id"`
+	TriggerSubjects []string `json:"trigger_subjects,omitempty"`
+	WorkloadJwt     string   `json:"workload_jwt"`
+	TargetNode      string   `json:"target_node"`
+}
+
+type CutoverResponse struct {
+	FromWorkloadId  string   `json:"from_workload_id"`
+	ToWorkloadId    string   `json:"to_workload_id"`
+	TriggerSubjects []string `json:"trigger_subjects"`
+}
+
+// Creates a cutover request signed by the issuer of both workloads. The name must be the name
+// of the workload receiving the trigger subjects
+func NewCutoverRequest(fromWorkloadId string, toWorkloadId string, name string, triggerSubjects []string, targetNode string, issuer nkeys.KeyPair) (*CutoverRequest, error) {
+	claims := jwt.NewGenericClaims(name)
+	jwtText, err := claims.Encode(issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CutoverRequest{
+		FromWorkloadId:  fromWorkloadId,
+		ToWorkloadId:    toWorkloadId,
+		TriggerSubjects: triggerSubjects,
+		WorkloadJwt:     jwtText,
+		TargetNode:      targetNode,
+	}, nil
+}
+
+// Validates the request against the claims both workloads were originally started with
+func (request *CutoverRequest) Validate(fromClaims *jwt.GenericClaims, toClaims *jwt.GenericClaims) error {
+	claims, err := jwt.DecodeGeneric(request.WorkloadJwt)
+	if err != nil {
+		return fmt.Errorf("could not decode workload JWT: %s", err)
+	}
+	if claims.ID == fromClaims.ID || claims.ID == toClaims.ID {
+		return fmt.Errorf("cutover claims appear to be cloned or captured from the original start claims. Rejecting for security reasons")
+	}
+	if claims.Subject != toClaims.Subject {
+		return fmt.Errorf("cutover claims subject does not match the name of the workload receiving the trigger subjects")
+	}
+	if claims.Issuer != fromClaims.Issuer || claims.Issuer != toClaims.Issuer {
+		return fmt.Errorf("the only entity allowed to cut over trigger subjects is the issuer that originally started both workloads")
+	}
+
+	return nil
+}
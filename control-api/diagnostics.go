@@ -0,0 +1,54 @@
+package controlapi
+
+import "time"
+
+// Asks a node for a snapshot of its internal state, along with any of the runtime's pprof
+// profiles, e.g. heap, allocs, goroutine, block, mutex or threadcreate
+type DiagnosticsRequest struct {
+	Profiles []string `json:"profiles,omitempty"`
+
+	// Includes the stack of every goroutine in the response
+	Goroutines bool `json:"goroutines,omitempty"`
+}
+
+type DiagnosticsResponse struct {
+	NodeId  string          `json:"node_id"`
+	TakenAt time.Time       `json:"taken_at"`
+	State   NodeDiagnostics `json:"state"`
+
+	// Requested profiles keyed by name, in the gzipped protobuf format read by go tool pprof
+	Profiles map[string][]byte `json:"profiles,omitempty"`
+
+	GoroutineDump string `json:"goroutine_dump,omitempty"`
+}
+
+// Snapshot of a node's runtime and of the agents and workloads it tracks
+type NodeDiagnostics struct {
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	HeapObjects    uint64 `json:"heap_objects"`
+	GCCycles       uint32 `json:"gc_cycles"`
+
+	// Agents that have completed their handshake and await a deployment, by pool
+	PendingAgents map[NexWorkload]int `json:"pending_agents"`
+
+	Workloads []WorkloadDiagnostics `json:"workloads"`
+
+	// Subscriptions held by the node's NATS connection, and those held on behalf of functions
+	NATSSubscriptions    int `json:"nats_subscriptions"`
+	TriggerSubscriptions int `json:"trigger_subscriptions"`
+}
+
+// Entry of a node's workload table. Agents awaiting a deployment are listed as pending workloads
+type WorkloadDiagnostics struct {
+	Id           string      `json:"id"`
+	Name         string      `json:"name,omitempty"`
+	Namespace    string      `json:"namespace,omitempty"`
+	WorkloadType NexWorkload `json:"type,omitempty"`
+	State        string      `json:"state"`
+
+	// Whether a deployment in progress has claimed the workload's agent
+	Claimed bool `json:"claimed,omitempty"`
+
+	TriggerSubscriptions int `json:"trigger_subscriptions,omitempty"`
+}
@@ -0,0 +1,48 @@
+package controlapi
+
+import "time"
+
+// States of a node evacuation
+const (
+	EvacuationStateRunning   = "running"
+	EvacuationStateCompleted = "completed"
+	// The deadline passed before every workload had been moved
+	EvacuationStateExpired = "expired"
+)
+
+// Asks a node that is about to go away, e.g. a spot instance that received an interruption
+// notice, to move its workloads to its peers. The node cordons itself, re-auctions each of its
+// workloads, waits for the replacement to pass a health probe on its new node and only then
+// stops the original. Once every workload has been moved, or the deadline has passed, the node
+// enters lame duck mode. Evacuating a node that is already evacuating returns its progress
+type EvacuateRequest struct {
+	Reason string `json:"reason,omitempty"`
+
+	// Time allowed to move the workloads; zero uses the node's configured deadline
+	Deadline time.Duration `json:"deadline,omitempty"`
+}
+
+// Progress of a node evacuation. Each of the Total workloads ends up evacuated or failed; failed
+// workloads keep running on the node until it goes away
+type EvacuationStatus struct {
+	NodeId      string              `json:"node_id"`
+	State       string              `json:"state"`
+	Reason      string              `json:"reason,omitempty"`
+	Total       int                 `json:"total"`
+	Evacuated   int                 `json:"evacuated"`
+	Failed      int                 `json:"failed"`
+	Workloads   []EvacuatedWorkload `json:"workloads,omitempty"`
+	StartedAt   time.Time           `json:"started_at"`
+	Deadline    time.Time           `json:"deadline"`
+	CompletedAt *time.Time          `json:"completed_at,omitempty"`
+}
+
+// A workload moved off an evacuating node, or the reason it could not be
+type EvacuatedWorkload struct {
+	WorkloadId    string `json:"workload_id"`
+	Name          string `json:"name"`
+	Namespace     string `json:"namespace"`
+	TargetNode    string `json:"target_node,omitempty"`
+	ReplacementId string `json:"replacement_id,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
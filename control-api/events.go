@@ -1,14 +1,54 @@
 package controlapi
 
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AgentStartedEventType         = "agent_started"
+	AgentStoppedEventType         = "agent_stopped"
+	NodeStartedEventType          = "node_started"
+	NodeStoppedEventType          = "node_stopped"
+	LameDuckEnteredEventType      = "node_entered_lameduck"
+	NodeCordonedEventType         = "node_cordoned"
+	NodeUncordonedEventType       = "node_uncordoned"
+	NodePressureEventType         = "node_pressure_changed"
+	NodeConfigReloadedEventType   = "node_config_reloaded"
+	NodeTagsChangedEventType      = "node_tags_changed"
+	NodeUpdatingEventType         = "node_updating"
+	NodeEvacuationEventType       = "node_evacuation_progress"
+	NodeKeysRotatedEventType      = "node_keys_rotated"
+	NodeJoinedNexusEventType      = "node_joined_nexus"
+	NodeLeftNexusEventType        = "node_left_nexus"
+	RootfsRolloutEventType        = "rootfs_rollout_progress"
+	ArtifactScannedEventType      = "artifact_scanned"
+	ArtifactTransferEventType     = "artifact_transfer_progress"
+	WorkloadQuarantinedEventType  = "workload_quarantined"
+	WorkloadPausedEventType       = "workload_paused"
+	WorkloadResumedEventType      = "workload_resumed"
+	WorkloadCheckpointedEventType = "workload_checkpointed"
+	WorkloadRestoredEventType     = "workload_restored"
+	NamespaceCreatedEventType     = "namespace_created"
+	NamespaceDeletedEventType     = "namespace_deleted"
+	HeartbeatEventType            = "heartbeat"
+	WorkloadDeployedEventType     = "workload_deployed"
+	WorkloadUndeployedEventType   = "workload_undeployed"
+	WorkloadStoppingEventType     = "workload_stopping"
+	WorkloadStateChangedEventType = "workload_state_changed"
+	WorkloadExpiredEventType      = "workload_expired"
+	JobCompletedEventType         = "job_completed"
+	PolicyDecisionEventType       = "policy_decision"
+)
+
+// Phases of stopping a workload, each reported by a workload stopping event
 const (
-	AgentStartedEventType       = "agent_started"
-	AgentStoppedEventType       = "agent_stopped"
-	NodeStartedEventType        = "node_started"
-	NodeStoppedEventType        = "node_stopped"
-	LameDuckEnteredEventType    = "node_entered_lameduck"
-	HeartbeatEventType          = "heartbeat"
-	WorkloadDeployedEventType   = "workload_deployed"
-	WorkloadUndeployedEventType = "workload_undeployed"
+	// The workload has been asked to undeploy and given its grace period to exit
+	WorkloadStopPhaseSignaled = "signaled"
+	// The workload exited within its grace period
+	WorkloadStopPhaseExited = "exited"
+	// The workload did not exit within its grace period and was forcibly terminated
+	WorkloadStopPhaseKilled = "killed"
 )
 
 type AgentStartedEvent struct {
@@ -26,6 +66,54 @@ type WorkloadUndeployedEvent struct {
 	Message string `json:"message"`
 }
 
+type WorkloadStoppingEvent struct {
+	Name              string `json:"workload_name"`
+	VmId              string `json:"vmid"`
+	Phase             string `json:"phase"`
+	GracePeriodMillis int64  `json:"grace_period_ms,omitempty"`
+}
+
+// Emitted when a workload moves between the lifecycle states of the node's workload manager:
+// pending, deploying, running, stopping and stopped
+type WorkloadStateChangedEvent struct {
+	Name string `json:"workload_name"`
+	VmId string `json:"vmid"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Emitted when a workload's TTL elapses, just before the node stops it
+type WorkloadExpiredEvent struct {
+	Name      string    `json:"workload_name"`
+	VmId      string    `json:"vmid"`
+	TTLMillis int64     `json:"ttl_ms"`
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+// Published by the node when it stops a workload at a client's request, using the workload
+// undeployed event type
+type WorkloadStoppedEvent struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason,omitempty"`
+	VmId   string `json:"vmid"`
+}
+
+// Emitted by the node when a function's trigger execution succeeds
+type FunctionExecSucceededEvent struct {
+	Name      string `json:"workload_name"`
+	Subject   string `json:"trigger_subject"`
+	Elapsed   int64  `json:"elapsed_nanos"`
+	Namespace string `json:"namespace"`
+}
+
+// Emitted by the node when a function's trigger execution fails
+type FunctionExecFailedEvent struct {
+	Name      string `json:"workload_name"`
+	Subject   string `json:"trigger_subject"`
+	Namespace string `json:"namespace"`
+	Error     string `json:"error"`
+}
+
 type AgentStoppedEvent struct {
 	Message string `json:"message"`
 	Code    int    `json:"code"`
@@ -42,6 +130,80 @@ type LameDuckEnteredEvent struct {
 	Id      string `json:"id"`
 }
 
+type NodeCordonEvent struct {
+	Id     string        `json:"id"`
+	Cordon *CordonStatus `json:"cordon,omitempty"`
+}
+
+type NodeConfigReloadedEvent struct {
+	Id              string         `json:"id"`
+	Changes         []ConfigChange `json:"changes"`
+	RestartRequired []string       `json:"restart_required,omitempty"`
+}
+
+// A node staged a new binary and is about to stop its workloads and exec it
+type NodeUpdatingEvent struct {
+	Id              string `json:"id"`
+	Version         string `json:"version"`
+	TargetVersion   string `json:"target_version,omitempty"`
+	Sha256          string `json:"sha256"`
+	SignedBy        string `json:"signed_by"`
+	PendingRedeploy int    `json:"pending_redeploy"`
+}
+
+// Progress of a node evacuation, published when it starts, as each workload is moved or fails
+// to be, and when it ends
+type NodeEvacuationEvent struct {
+	EvacuationStatus
+
+	// The workload whose move produced this event
+	WorkloadId string `json:"workload_id,omitempty"`
+}
+
+// Announces a node's new xkey. The previous xkey is accepted until the grace period ends
+type NodeKeysRotatedEvent struct {
+	RotateKeysResponse
+}
+
+// Progress of a node's rootfs rollout, published as pending agents are drained, as each running
+// workload is recycled and when the rollout ends
+type RootfsRolloutEvent struct {
+	RootfsRolloutStatus
+
+	// The workload whose recycle produced this event, and the workload replacing it
+	WorkloadId    string `json:"workload_id,omitempty"`
+	ReplacementId string `json:"replacement_id,omitempty"`
+}
+
+// Outcome of scanning a workload's artifact before deploying it. Blocked deployments are
+// rejected; when the scanner failed, Error holds the reason and Blocked reflects whether the
+// node fails closed
+type ArtifactScannedEvent struct {
+	Namespace    string            `json:"namespace"`
+	WorkloadName string            `json:"workload_name"`
+	Sha256       string            `json:"sha256"`
+	Scanner      string            `json:"scanner,omitempty"`
+	Threshold    string            `json:"threshold"`
+	Blocked      bool              `json:"blocked"`
+	Findings     []ArtifactFinding `json:"findings"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// Audit record of a node's access policy allowing or denying a request
+type PolicyDecisionEvent struct {
+	NodeId    string    `json:"node_id"`
+	Issuer    string    `json:"issuer,omitempty"`
+	Namespace string    `json:"namespace"`
+	Operation Operation `json:"operation"`
+	Allowed   bool      `json:"allowed"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+type NodeTagsChangedEvent struct {
+	Id   string            `json:"id"`
+	Tags map[string]string `json:"tags"`
+}
+
 type NodeStoppedEvent struct {
 	Id       string `json:"id"`
 	Graceful bool   `json:"graceful"`
@@ -56,3 +218,33 @@ type HeartbeatEvent struct {
 	Tags            map[string]string `json:"tags,omitempty"`
 	RunningMachines int               `json:"running_machines"`
 }
+
+// Emitted when a node registers in the nexus registry, and when it removes its entry as it shuts
+// down. A node that stops without leaving emits no event; its entry expires instead
+type NexusMembershipEvent struct {
+	NexusMember
+}
+
+// Emitted when a job workload runs to completion. Output holds the tail of the job's combined
+// stdout and stderr
+type JobCompletedEvent struct {
+	Name        string    `json:"workload_name"`
+	ExitCode    int       `json:"exit_code"`
+	Output      string    `json:"output,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// Describes the payload of an event type as a JSON schema. Event types with more than one
+// producer describe each payload shape as an alternative
+type EventSchema struct {
+	EventType   string          `json:"event_type"`
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+}
+
+type EventSchemasResponse struct {
+	NodeId  string        `json:"node_id"`
+	Version string        `json:"version"`
+	Schemas []EventSchema `json:"schemas"`
+}
@@ -0,0 +1,33 @@
+package controlapi
+
+// Replaces the faults a node injects, for validating restart policies, reapers and failover in
+// tests. Only nodes built with the faults tag answer; sending zero faults clears them
+type FaultInjectionRequest struct {
+	Faults InjectedFaults `json:"faults"`
+
+	// Workloads whose agent processes are killed at once, without being undeployed, as if they
+	// had crashed
+	Crash []string `json:"crash,omitempty"`
+}
+
+// Faults a node injects until they are replaced or, for counted faults, used up
+type InjectedFaults struct {
+	// Delay before each deploy request is submitted to its agent
+	DeployDelayMillis int64 `json:"deploy_delay_ms,omitempty"`
+
+	// Number of upcoming agent handshakes treated as failed, which reaps the agents
+	HandshakeFailures int `json:"handshake_failures,omitempty"`
+
+	// Number of upcoming deploys rejected in place of the agent, for the given reason, which
+	// defaults to deploy_failed
+	DeployRejections int                   `json:"deploy_rejections,omitempty"`
+	RejectionReason  DeployRejectionReason `json:"rejection_reason,omitempty"`
+}
+
+type FaultInjectionResponse struct {
+	NodeId string         `json:"node_id"`
+	Faults InjectedFaults `json:"faults"`
+
+	// Workloads whose agent processes were killed
+	Crashed []string `json:"crashed,omitempty"`
+}
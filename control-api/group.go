@@ -0,0 +1,58 @@
+package controlapi
+
+import (
+	"fmt"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// Request to stop or restart every workload in a group. The JWT must be signed by the issuer
+// that started the group's workloads, with the group name as its subject
+type GroupRequest struct {
+	Group       string `json:"group"`
+	WorkloadJwt string `json:"workload_jwt"`
+}
+
+// Reply from a single node describing the members of a group it hosts. For stop requests these
+// are the workloads that were stopped, and for restart requests the workloads that replaced them
+type GroupResponse struct {
+	NodeId    string           `json:"node_id"`
+	Group     string           `json:"group"`
+	Workloads []MachineSummary `json:"workloads"`
+
+	// Reasons members of the group could not be stopped or restarted, keyed by workload ID
+	Failures map[string]string `json:"failures,omitempty"`
+}
+
+func NewGroupRequest(group string, issuer nkeys.KeyPair) (*GroupRequest, error) {
+	claims := jwt.NewGenericClaims(group)
+	jwtText, err := claims.Encode(issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GroupRequest{
+		Group:       group,
+		WorkloadJwt: jwtText,
+	}, nil
+}
+
+// Validates the request against the claims a member of the group was originally started with
+func (request *GroupRequest) Validate(originalClaims *jwt.GenericClaims) error {
+	claims, err := jwt.DecodeGeneric(request.WorkloadJwt)
+	if err != nil {
+		return fmt.Errorf("could not decode workload JWT: %s", err)
+	}
+	if claims.ID == originalClaims.ID {
+		return fmt.Errorf("group claims appear to be cloned or captured from the original start claims. Rejecting for security reasons")
+	}
+	if claims.Subject != request.Group {
+		return fmt.Errorf("group claims subject does not match requested group")
+	}
+	if claims.Issuer != originalClaims.Issuer {
+		return fmt.Errorf("the only entity allowed to manage a workload group is the issuer that originally started it")
+	}
+
+	return nil
+}
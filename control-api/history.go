@@ -0,0 +1,33 @@
+package controlapi
+
+import "time"
+
+// Requests the recent trigger executions of a function workload. Limit caps the number of
+// records returned, most recent last; zero returns every retained record
+type ExecutionHistoryRequest struct {
+	WorkloadId string `json:"workload_id"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+type ExecutionHistoryResponse struct {
+	NodeId     string            `json:"node_id"`
+	WorkloadId string            `json:"workload_id"`
+	Executions []ExecutionRecord `json:"executions"`
+}
+
+// A single trigger execution. Duration is measured by the node from receipt of the trigger
+// message until the agent's reply, while RuntimeNanos is the time reported by the agent for the
+// function itself, so the difference is the overhead of dispatching the trigger
+type ExecutionRecord struct {
+	StartedAt      time.Time `json:"started_at"`
+	DurationNanos  int64     `json:"duration_ns"`
+	RuntimeNanos   int64     `json:"runtime_ns,omitempty"`
+	TriggerSubject string    `json:"trigger_subject"`
+	Success        bool      `json:"success"`
+	Error          string    `json:"error,omitempty"`
+	PayloadBytes   int       `json:"payload_bytes"`
+	ResponseBytes  int       `json:"response_bytes"`
+
+	// Whether the response was served from the result cache rather than by running the function
+	Cached bool `json:"cached,omitempty"`
+}
@@ -0,0 +1,100 @@
+package controlapi
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+const (
+	// How long the claims of a hot reload request remain valid after being issued
+	HotReloadClaimsLifetime = 5 * time.Minute
+
+	hotReloadClaimsHashField = "hash"
+)
+
+// Asks a node to swap the script or module of a running v8 or wasm workload for a new artifact,
+// without undeploying it. Only workloads deployed with hot reload enabled, which is meant for
+// development, accept these requests
+type HotReloadRequest struct {
+	WorkloadId  string   `json:"workload_id"`
+	TargetNode  string   `json:"target_node"`
+	Location    *url.URL `json:"location"`
+	JsDomain    *string  `json:"jsdomain,omitempty"`
+	Hash        string   `json:"hash"`
+	WorkloadJwt string   `json:"workload_jwt"`
+}
+
+type HotReloadResponse struct {
+	Reloaded bool   `json:"reloaded"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Hash     string `json:"hash"`
+}
+
+// Creates a hot reload request for the artifact at the given nats://{bucket}/{key} location, signed
+// by the issuer that originally started the workload. The hash is the hex encoded SHA-256 digest of
+// the new artifact, which the node verifies before handing it to the workload
+func NewHotReloadRequest(workloadId string, name string, targetNode string, location string, hash string, issuer nkeys.KeyPair) (*HotReloadRequest, error) {
+	nurl, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if !validSha256.MatchString(hash) {
+		return nil, fmt.Errorf("hot reload hash ('%s') must be a hex encoded SHA-256 digest", hash)
+	}
+
+	claims := jwt.NewGenericClaims(name)
+	claims.Expires = time.Now().Add(HotReloadClaimsLifetime).Unix()
+	claims.Data[stopClaimsWorkloadIdField] = workloadId
+	claims.Data[hotReloadClaimsHashField] = hash
+	jwtText, err := claims.Encode(issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HotReloadRequest{
+		WorkloadId:  workloadId,
+		TargetNode:  targetNode,
+		Location:    nurl,
+		Hash:        hash,
+		WorkloadJwt: jwtText,
+	}, nil
+}
+
+// Verifies that the request is signed by the issuer that originally started the workload, and
+// that its claims cover both the workload and the artifact. Every failure is reported as an
+// *AuthorizationError
+func (request *HotReloadRequest) Validate(originalClaims *jwt.GenericClaims) error {
+	claims, err := jwt.DecodeGeneric(request.WorkloadJwt)
+	if err != nil {
+		return NewAuthorizationError(fmt.Sprintf("could not verify hot reload claims: %s", err))
+	}
+	if claims.Expires == 0 {
+		return NewAuthorizationError("hot reload claims must carry an expiry")
+	}
+	if time.Now().Unix() > claims.Expires {
+		return NewAuthorizationError("hot reload claims have expired")
+	}
+	if claims.ID == originalClaims.ID ||
+		claims.IssuedAt == originalClaims.IssuedAt {
+		return NewAuthorizationError("hot reload claims appear to be cloned or captured from the original start claims. Rejecting for security reasons")
+	}
+	if claims.Subject != originalClaims.Subject {
+		return NewAuthorizationError("hot reload claims subject does not match original start claims subject")
+	}
+	if workloadId, ok := claims.Data[stopClaimsWorkloadIdField]; !ok || workloadId != request.WorkloadId {
+		return NewAuthorizationError("hot reload claims were issued for a different workload")
+	}
+	if hash, ok := claims.Data[hotReloadClaimsHashField]; !ok || hash != request.Hash {
+		return NewAuthorizationError("hot reload claims were issued for a different artifact")
+	}
+	if claims.Issuer != originalClaims.Issuer {
+		return NewAuthorizationError("the only entity allowed to hot reload a workload is the issuer that originally started it")
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package controlapi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+func TestHotReloadRequestValidation(t *testing.T) {
+	issuer, _ := nkeys.CreateAccount()
+	other, _ := nkeys.CreateAccount()
+	hash := strings.Repeat("ab", 32)
+
+	deployJwt, err := CreateWorkloadJwt(hash, "echo", issuer)
+	if err != nil {
+		t.Fatalf("Failed to create workload JWT: %s", err)
+	}
+	original, _ := jwt.DecodeGeneric(deployJwt)
+	// the reload claims are issued within the same second as the original in this test
+	original.IssuedAt--
+
+	request, err := NewHotReloadRequest("workload", "echo", "node", "nats://NEXCLIFILES/echojs", hash, issuer)
+	if err != nil {
+		t.Fatalf("Failed to create hot reload request: %s", err)
+	}
+	if err := request.Validate(original); err != nil {
+		t.Fatalf("Expected the original issuer's request to be valid: %s", err)
+	}
+
+	foreign, _ := NewHotReloadRequest("workload", "echo", "node", "nats://NEXCLIFILES/echojs", hash, other)
+	if err := foreign.Validate(original); err == nil {
+		t.Fatal("Expected a request from another issuer to be rejected")
+	}
+
+	swapped := *request
+	swapped.Hash = strings.Repeat("cd", 32)
+	if err := swapped.Validate(original); err == nil {
+		t.Fatal("Expected a request for a different artifact than its claims to be rejected")
+	}
+
+	retargeted := *request
+	retargeted.WorkloadId = "another"
+	if err := retargeted.Validate(original); err == nil {
+		t.Fatal("Expected a request for a different workload than its claims to be rejected")
+	}
+
+	cloned := *request
+	cloned.WorkloadJwt = deployJwt
+	if err := cloned.Validate(original); err == nil {
+		t.Fatal("Expected the original start claims to be rejected")
+	}
+
+	_, err = NewHotReloadRequest("workload", "echo", "node", "nats://NEXCLIFILES/echojs", "abc12345", issuer)
+	if err == nil {
+		t.Fatal("Expected a hash that is not a SHA-256 digest to be rejected")
+	}
+}
@@ -0,0 +1,22 @@
+package controlapi
+
+import "time"
+
+// Asks a node to replace its xkey, the key deploy requests encrypt workload environments for.
+// The node keeps its ID. Until the grace period ends, payloads encrypted for the previous xkey
+// are still accepted, so that requests built from cached auction or info responses succeed
+type RotateKeysRequest struct {
+	// Time the previous xkey remains accepted; zero uses the node's configured grace period
+	Grace time.Duration `json:"grace,omitempty"`
+}
+
+// The keys of a node after a rotation
+type RotateKeysResponse struct {
+	NodeId             string    `json:"node_id"`
+	PublicXKey         string    `json:"public_xkey"`
+	PreviousPublicXKey string    `json:"previous_public_xkey"`
+	GraceUntil         time.Time `json:"grace_until"`
+
+	// Whether the new keys were written to the node's key file, and so survive a restart
+	Persisted bool `json:"persisted"`
+}
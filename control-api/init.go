@@ -0,0 +1,36 @@
+package controlapi
+
+import (
+	"errors"
+	"time"
+)
+
+// Trigger subject on which functions are initialized unless their init names another
+const DefaultWorkloadInitSubject = "$NEX.init"
+
+// Runs a function once right after it is deployed and before it is subscribed to any trigger, e.g.
+// to load a model or its configuration. The function is triggered on Subject with Payload and must
+// reply within the timeout, 30 seconds unless set, for the workload to start running; otherwise it
+// is undeployed and the deployment is rejected
+type WorkloadInit struct {
+	Subject       string `json:"subject,omitempty"`
+	Payload       []byte `json:"payload,omitempty"`
+	TimeoutMillis int64  `json:"timeout_ms,omitempty"`
+}
+
+func (workloadInit *WorkloadInit) validate() error {
+	if workloadInit.TimeoutMillis < 0 {
+		return errors.New("workload init timeout must not be negative")
+	}
+
+	return nil
+}
+
+// Initializes the workload by triggering it once with the given payload before it receives any
+// other trigger. A zero timeout leaves the node's default in place
+func Init(payload []byte, timeout time.Duration) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.workloadInit = &WorkloadInit{Payload: payload, TimeoutMillis: timeout.Milliseconds()}
+		return o
+	}
+}
@@ -0,0 +1,29 @@
+package controlapi
+
+import "time"
+
+// States of a job workload as reported by its status
+const (
+	JobStateRunning   = "running"
+	JobStateSucceeded = "succeeded"
+	JobStateFailed    = "failed"
+	// The job was stopped before it ran to completion
+	JobStateStopped = "stopped"
+)
+
+type JobStatusRequest struct {
+	WorkloadId string `json:"workload_id"`
+}
+
+// Status of a job workload. The node retains the status of completed jobs after their agents
+// have been recycled, so it remains queryable once the job is no longer running
+type JobStatus struct {
+	NodeId      string     `json:"node_id"`
+	WorkloadId  string     `json:"workload_id"`
+	Name        string     `json:"workload_name"`
+	State       string     `json:"state"`
+	ExitCode    *int       `json:"exit_code,omitempty"`
+	Output      string     `json:"output,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
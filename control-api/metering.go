@@ -0,0 +1,92 @@
+package controlapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nkeys"
+)
+
+// Subject prefix on which nodes publish their usage reports, followed by the node's public key,
+// unless the node is configured with another
+const DefaultMeteringSubject = APIPrefix + ".metering"
+
+// Resources consumed by the workloads of a namespace. CPU time and memory are measured on the
+// machine running each workload; egress counts the bytes sent by its VM's network interface and
+// the responses of its function triggers
+type NamespaceUsage struct {
+	Namespace         string  `json:"namespace"`
+	CPUSeconds        float64 `json:"cpu_seconds"`
+	MemoryByteSeconds float64 `json:"memory_byte_seconds"`
+	Invocations       int64   `json:"invocations"`
+	EgressBytes       int64   `json:"egress_bytes"`
+}
+
+// Usage accumulated by a node's namespaces over a reporting period. Sequence numbers increase
+// by one per report for as long as the node runs, so that a gap reveals a lost report
+type UsageReport struct {
+	NodeId      string           `json:"node_id"`
+	Sequence    uint64           `json:"sequence"`
+	PeriodStart time.Time        `json:"period_start"`
+	PeriodEnd   time.Time        `json:"period_end"`
+	Namespaces  []NamespaceUsage `json:"namespaces"`
+}
+
+// A usage report as published on the metering subject, signed by the node that produced it.
+// The signature covers the raw report exactly as published
+type SignedUsageReport struct {
+	Report    json.RawMessage `json:"report"`
+	Signature string          `json:"signature"`
+}
+
+// Asks a node for the usage its namespace has accumulated since the node started
+type UsageRequest struct{}
+
+type UsageResponse struct {
+	NodeId string         `json:"node_id"`
+	Since  time.Time      `json:"since"`
+	Usage  NamespaceUsage `json:"usage"`
+}
+
+// Signs a usage report with the key of the node that produced it
+func SignUsageReport(kp nkeys.KeyPair, report *UsageReport) (*SignedUsageReport, error) {
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := kp.Sign(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignedUsageReport{Report: raw, Signature: base64.StdEncoding.EncodeToString(sig)}, nil
+}
+
+// Verifies that the report was signed by the node it names, returning the report
+func (s *SignedUsageReport) Verify() (*UsageReport, error) {
+	var report UsageReport
+	err := json.Unmarshal(s.Report, &report)
+	if err != nil {
+		return nil, fmt.Errorf("invalid usage report: %s", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %s", err)
+	}
+
+	kp, err := nkeys.FromPublicKey(report.NodeId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node id in usage report: %s", err)
+	}
+
+	if kp.Verify(s.Report, sig) != nil {
+		return nil, errors.New("usage report is not signed by the node it names")
+	}
+
+	return &report, nil
+}
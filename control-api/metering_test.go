@@ -0,0 +1,36 @@
+package controlapi
+
+import (
+	"testing"
+
+	"github.com/nats-io/nkeys"
+)
+
+func TestUsageReportSignature(t *testing.T) {
+	node, _ := nkeys.CreateServer()
+	other, _ := nkeys.CreateServer()
+	nodeID, _ := node.PublicKey()
+
+	signed, err := SignUsageReport(node, &UsageReport{NodeId: nodeID, Sequence: 1})
+	if err != nil {
+		t.Fatalf("Failed to sign usage report: %s", err)
+	}
+
+	report, err := signed.Verify()
+	if err != nil || report.Sequence != 1 {
+		t.Fatalf("Expected the signed report to verify, got %v", err)
+	}
+
+	tampered := *signed
+	tampered.Report = []byte(`{"node_id":"` + nodeID + `","sequence":2}`)
+	_, err = tampered.Verify()
+	if err == nil {
+		t.Fatalf("Expected a tampered report not to verify")
+	}
+
+	forged, _ := SignUsageReport(other, &UsageReport{NodeId: nodeID, Sequence: 1})
+	_, err = forged.Verify()
+	if err == nil {
+		t.Fatalf("Expected a report signed by another node not to verify")
+	}
+}
@@ -0,0 +1,150 @@
+package controlapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Actions of a namespace request
+const (
+	NamespaceActionCreate = "create"
+	NamespaceActionGet    = "get"
+	NamespaceActionList   = "list"
+	NamespaceActionDelete = "delete"
+)
+
+// What happens to the workloads of a namespace when it is deleted
+type NamespaceDeletionPolicy string
+
+const (
+	// Every node stops the workloads it runs in the namespace
+	NamespaceDeletionStop NamespaceDeletionPolicy = "stop"
+	// Workloads keep running until stopped, but no new ones may be deployed
+	NamespaceDeletionOrphan NamespaceDeletionPolicy = "orphan"
+)
+
+// Environment variable through which workloads receive the config of their namespace, a JSON object
+const NamespaceConfigEnvVar = "NEX_NAMESPACE_CONFIG"
+
+var validNamespaceName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// A namespace and the defaults applied to workloads deployed into it. Namespaces are stored in a
+// key-value bucket shared by every node configured to manage them
+type Namespace struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+
+	// Limits on the workloads each node runs in the namespace
+	Quota *NamespaceQuota `json:"quota,omitempty"`
+
+	// Applied to deploy requests that do not specify their own resources or budgets
+	DefaultResources           *WorkloadResources           `json:"default_resources,omitempty"`
+	DefaultHostServicesBudgets map[string]HostServiceBudget `json:"default_host_services_budgets,omitempty"`
+
+	// Merged into the environment of every workload deployed into the namespace, which wins
+	// where both set a variable. Stored in the clear, so not meant for secrets
+	DefaultEnvironment map[string]string `json:"default_environment,omitempty"`
+
+	// A JSON object given to every workload deployed into the namespace in NamespaceConfigEnvVar.
+	// Workloads setting that variable to an object of their own keep their values for its keys
+	DefaultConfig json.RawMessage `json:"default_config,omitempty"`
+
+	// Applied when a delete request does not specify a policy; defaults to stopping workloads
+	DeletionPolicy NamespaceDeletionPolicy `json:"deletion_policy,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+
+	// Set, along with the policy in effect, while the namespace is being deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Limits enforced by each node on the workloads it runs in a namespace. Zero values are unlimited
+type NamespaceQuota struct {
+	MaxWorkloads int `json:"max_workloads,omitempty"`
+	MaxMemoryMib int `json:"max_memory_mib,omitempty"`
+}
+
+func (ns *Namespace) Validate() error {
+	var err error
+
+	if !validNamespaceName.MatchString(ns.Name) {
+		err = errors.Join(err, fmt.Errorf("invalid namespace name %q; names may only contain letters, digits, '-' and '_'", ns.Name))
+	}
+
+	if ns.Quota != nil && (ns.Quota.MaxWorkloads < 0 || ns.Quota.MaxMemoryMib < 0) {
+		err = errors.Join(err, errors.New("namespace quotas cannot be negative"))
+	}
+
+	if ns.DeletionPolicy != "" && !ns.DeletionPolicy.valid() {
+		err = errors.Join(err, fmt.Errorf("invalid namespace deletion policy %q", ns.DeletionPolicy))
+	}
+
+	for name := range ns.DefaultEnvironment {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			err = errors.Join(err, fmt.Errorf("invalid default environment variable name %q", name))
+		}
+	}
+
+	if len(ns.DefaultConfig) > 0 {
+		var config map[string]json.RawMessage
+		if json.Unmarshal(ns.DefaultConfig, &config) != nil || config == nil {
+			err = errors.Join(err, errors.New("namespace default config must be a JSON object"))
+		}
+	}
+
+	return err
+}
+
+func (p NamespaceDeletionPolicy) valid() bool {
+	return p == NamespaceDeletionStop || p == NamespaceDeletionOrphan
+}
+
+// Creates, reads, lists or deletes namespaces. Every action but listing applies to the namespace
+// the request is made in; listing returns every namespace
+type NamespaceRequest struct {
+	Action string `json:"action"`
+
+	// The namespace to create; its name must match the namespace of the request
+	Namespace *Namespace `json:"namespace,omitempty"`
+
+	// Overrides the namespace's deletion policy when deleting it
+	DeletionPolicy NamespaceDeletionPolicy `json:"deletion_policy,omitempty"`
+}
+
+func (request *NamespaceRequest) Validate(namespace string) error {
+	switch request.Action {
+	case NamespaceActionCreate:
+		if request.Namespace == nil {
+			return errors.New("namespace creation requires a namespace")
+		}
+		if request.Namespace.Name != namespace {
+			return errors.New("namespace name does not match the namespace of the request")
+		}
+		return request.Namespace.Validate()
+	case NamespaceActionDelete:
+		if request.DeletionPolicy != "" && !request.DeletionPolicy.valid() {
+			return fmt.Errorf("invalid namespace deletion policy %q", request.DeletionPolicy)
+		}
+	case NamespaceActionGet, NamespaceActionList:
+	default:
+		return fmt.Errorf("unknown namespace action %q", request.Action)
+	}
+
+	return nil
+}
+
+type NamespaceResponse struct {
+	NodeId     string      `json:"node_id"`
+	Namespaces []Namespace `json:"namespaces"`
+}
+
+// Published when a namespace is created or deleted
+type NamespaceEvent struct {
+	NodeId    string    `json:"node_id"`
+	Namespace Namespace `json:"namespace"`
+}
@@ -0,0 +1,75 @@
+package controlapi
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Default queue group of a NATS service's endpoints, shared by every instance of the service so
+// that each request is served once
+const DefaultNatsServiceQueueGroup = "q"
+
+var (
+	validNatsServiceName    = regexp.MustCompile(`^[A-Za-z0-9\-_]+$`)
+	validNatsServiceVersion = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.\-]+)?(\+[0-9A-Za-z.\-]+)?$`)
+)
+
+// Exposes a v8 or wasm function as a NATS service. The node registers the service on the
+// workload's behalf, discoverable through the $SRV subjects, and runs the function once for each
+// request received on one of its endpoints, replying with the function's response. Instances of
+// the service deployed on any node share a queue group, so that each request is served once
+type NatsService struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+
+	// Queue group of the endpoints that do not name their own; defaults to DefaultNatsServiceQueueGroup
+	QueueGroup string `json:"queue_group,omitempty"`
+
+	Endpoints []NatsServiceEndpoint `json:"endpoints"`
+}
+
+// An endpoint of a NATS service, receiving requests on Subject, or on its name when no subject
+// is given
+type NatsServiceEndpoint struct {
+	Name       string            `json:"name"`
+	Subject    string            `json:"subject,omitempty"`
+	QueueGroup string            `json:"queue_group,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+func (service *NatsService) validate() error {
+	if !validNatsServiceName.MatchString(service.Name) {
+		return fmt.Errorf("nats service name ('%s') must contain only letters, digits, dashes and underscores", service.Name)
+	}
+
+	if service.Version != "" && !validNatsServiceVersion.MatchString(service.Version) {
+		return fmt.Errorf("nats service version ('%s') must be a semantic version", service.Version)
+	}
+
+	if len(service.Endpoints) == 0 {
+		return errors.New("nats service must declare at least one endpoint")
+	}
+
+	names := make(map[string]bool)
+	for _, endpoint := range service.Endpoints {
+		if !validNatsServiceName.MatchString(endpoint.Name) {
+			return fmt.Errorf("nats service endpoint name ('%s') must contain only letters, digits, dashes and underscores", endpoint.Name)
+		}
+
+		if names[endpoint.Name] {
+			return fmt.Errorf("nats service endpoint name ('%s') must not be used more than once", endpoint.Name)
+		}
+		names[endpoint.Name] = true
+
+		if endpoint.Subject != "" && (strings.ContainsAny(endpoint.Subject, " \t") || strings.HasPrefix(endpoint.Subject, ".") ||
+			strings.HasSuffix(endpoint.Subject, ".") || strings.Contains(endpoint.Subject, "..")) {
+			return fmt.Errorf("nats service endpoint subject ('%s') is not a valid subject", endpoint.Subject)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package controlapi
+
+import "testing"
+
+func TestNatsServiceValidation(t *testing.T) {
+	service := NatsService{
+		Name:    "orders",
+		Version: "1.2.0",
+		Endpoints: []NatsServiceEndpoint{
+			{Name: "create", Subject: "orders.create"},
+			{Name: "list"},
+		},
+	}
+	if err := service.validate(); err != nil {
+		t.Fatalf("Expected the service to be valid: %s", err)
+	}
+
+	invalid := map[string]NatsService{
+		"name with spaces":    {Name: "order service", Endpoints: service.Endpoints},
+		"non-semver version":  {Name: "orders", Version: "v1", Endpoints: service.Endpoints},
+		"no endpoints":        {Name: "orders"},
+		"duplicate endpoints": {Name: "orders", Endpoints: []NatsServiceEndpoint{{Name: "list"}, {Name: "list", Subject: "orders.all"}}},
+		"invalid subject":     {Name: "orders", Endpoints: []NatsServiceEndpoint{{Name: "list", Subject: "orders..list"}}},
+	}
+	for name, service := range invalid {
+		if err := service.validate(); err == nil {
+			t.Errorf("Expected a service with %s to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package controlapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Nexus info requests are answered by one member of a queue group of aggregators, which may be
+// nodes running in aggregation mode or dedicated aggregator processes:
+// $NEX.NEXUS.INFO.{namespace}
+
+const (
+	nexusInfoQueue = "nex-nexus-info"
+
+	// How long an aggregator waits for nodes to answer its ping before querying them
+	DefaultNexusDiscoveryWindow = time.Second
+
+	// Key-value bucket in which nodes register as members of the nexus
+	DefaultNexusRegistryBucket = "NEX_NEXUS"
+)
+
+// A node's entry in the nexus registry, keyed by the node's public key. Members refresh their
+// entry with every heartbeat, and entries that are not refreshed expire with the bucket's TTL
+type NexusMember struct {
+	NodeId          string            `json:"node_id"`
+	Nexus           string            `json:"nexus,omitempty"`
+	Version         string            `json:"version"`
+	PublicXKey      string            `json:"public_xkey"`
+	Tags            map[string]string `json:"tags,omitempty"`
+	RunningMachines int               `json:"running_machines"`
+	JoinedAt        time.Time         `json:"joined_at"`
+	LastSeen        time.Time         `json:"last_seen"`
+}
+
+// A merged view of every node in the nexus that answered an aggregator, with the workloads
+// they run in the requested namespace
+type NexusInfoResponse struct {
+	Namespace    string    `json:"namespace"`
+	AggregatedBy string    `json:"aggregated_by"`
+	GatheredAt   time.Time `json:"gathered_at"`
+
+	Nodes []NexusNodeInfo `json:"nodes"`
+
+	// Number of nodes running each version of nex
+	Versions map[string]int `json:"versions"`
+
+	// Sum of the capacities reported by the nodes
+	Capacity NodeCapacity `json:"capacity"`
+
+	TotalWorkloads int `json:"total_workloads"`
+
+	// Nodes that answered the aggregator's ping but failed to return their info, with the reason
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type NexusNodeInfo struct {
+	NodeId string `json:"node_id"`
+	Nexus  string `json:"nexus,omitempty"`
+	InfoResponse
+}
+
+// Requests the merged view of all nodes from whichever aggregator answers first. The request
+// timeout must leave the aggregator time to discover and query the nodes
+func (api *Client) NexusInfo(ctx context.Context, opts ...CallOption) (*NexusInfoResponse, error) {
+	subject := fmt.Sprintf("%s.NEXUS.INFO.%s", APIPrefix, api.namespace)
+	bytes, err := api.performRequest(ctx, subject, nil, true, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response NexusInfoResponse
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Discovers the nodes of the nexus and queries each for its info in the client's namespace,
+// merging the responses. Nodes are given the discovery window to answer the ping and the
+// request timeout to answer the info request
+func (api *Client) GatherNexusInfo(ctx context.Context, discoveryWindow time.Duration, opts ...CallOption) (*NexusInfoResponse, error) {
+	pings, err := api.PingNodes(ctx, WithRequestTimeout(discoveryWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*InfoResponse, len(pings))
+	errs := make([]error, len(pings))
+
+	var wg sync.WaitGroup
+	for i, ping := range pings {
+		wg.Add(1)
+		go func(i int, nodeId string) {
+			defer wg.Done()
+			infos[i], errs[i] = api.NodeInfo(ctx, nodeId, opts...)
+		}(i, ping.NodeId)
+	}
+	wg.Wait()
+
+	response := &NexusInfoResponse{
+		Namespace:  api.namespace,
+		GatheredAt: time.Now().UTC(),
+		Nodes:      make([]NexusNodeInfo, 0, len(pings)),
+		Versions:   make(map[string]int),
+	}
+
+	for i, ping := range pings {
+		if errs[i] != nil {
+			if response.Errors == nil {
+				response.Errors = make(map[string]string)
+			}
+			response.Errors[ping.NodeId] = errs[i].Error()
+			continue
+		}
+
+		info := infos[i]
+		response.Nodes = append(response.Nodes, NexusNodeInfo{
+			NodeId:       ping.NodeId,
+			Nexus:        ping.Nexus,
+			InfoResponse: *info,
+		})
+		response.Versions[info.Version]++
+		response.TotalWorkloads += len(info.Machines)
+
+		if info.Capacity != nil {
+			response.Capacity.MemoryMib += info.Capacity.MemoryMib
+			response.Capacity.VcpuCount += info.Capacity.VcpuCount
+			response.Capacity.AllocatedMemoryMib += info.Capacity.AllocatedMemoryMib
+			response.Capacity.AllocatedVcpuCount += info.Capacity.AllocatedVcpuCount
+			response.Capacity.AvailableMemoryMib += info.Capacity.AvailableMemoryMib
+			response.Capacity.AvailableVcpuCount += info.Capacity.AvailableVcpuCount
+			response.Capacity.GPUCount += info.Capacity.GPUCount
+			response.Capacity.AvailableGPUCount += info.Capacity.AvailableGPUCount
+		}
+	}
+
+	sort.Slice(response.Nodes, func(i, j int) bool {
+		return response.Nodes[i].NodeId < response.Nodes[j].NodeId
+	})
+
+	return response, nil
+}
+
+// Lists the nodes registered in the given nexus registry bucket, the default bucket if empty,
+// ordered by node ID. Unlike pinging the nodes, this lists nodes that are slow to answer, but
+// a node that stopped without leaving is listed until its entry expires
+func (api *Client) ListNexusNodes(ctx context.Context, bucket string) ([]NexusMember, error) {
+	if bucket == "" {
+		bucket = DefaultNexusRegistryBucket
+	}
+
+	js, err := api.nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	kv, err := js.KeyValue(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind to nexus registry bucket %s: %s", bucket, err)
+	}
+
+	watcher, err := kv.WatchAll(nats.IgnoreDeletes(), nats.Context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = watcher.Stop() }()
+
+	members := make([]NexusMember, 0)
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			// marks the end of the current values
+			break
+		}
+
+		var member NexusMember
+		err := json.Unmarshal(entry.Value(), &member)
+		if err != nil {
+			api.log.Warn("Ignoring invalid nexus registry entry", slog.String("key", entry.Key()), slog.Any("err", err))
+			continue
+		}
+		members = append(members, member)
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].NodeId < members[j].NodeId
+	})
+
+	return members, nil
+}
+
+// Answers nexus info requests on behalf of the given aggregator, joining the queue group of
+// aggregators so each request is answered once. The requester's identity token is forwarded to
+// every node queried, so nodes enforcing an access policy authorize the original requester
+func ServeNexusInfo(nc *nats.Conn, aggregatorId string, timeout time.Duration, discoveryWindow time.Duration, log *slog.Logger) (*nats.Subscription, error) {
+	return nc.QueueSubscribe(fmt.Sprintf("%s.NEXUS.INFO.*", APIPrefix), nexusInfoQueue, func(m *nats.Msg) {
+		namespace := strings.TrimPrefix(m.Subject, fmt.Sprintf("%s.NEXUS.INFO.", APIPrefix))
+
+		opts := make([]CallOption, 0)
+		if token := m.Header.Get(IdentityHeader); token != "" {
+			opts = append(opts, WithIdentityToken(token))
+		}
+
+		client := NewApiClientWithNamespace(nc, timeout, namespace, log)
+		response, err := client.GatherNexusInfo(context.Background(), discoveryWindow, opts...)
+
+		var env Envelope
+		if err != nil {
+			log.Error("Failed to gather nexus info", slog.String("namespace", namespace), slog.Any("err", err))
+			reason := fmt.Sprintf("Failed to gather nexus info: %s", err)
+			env = NewEnvelope(NexusInfoResponseType, []byte{}, &reason)
+		} else {
+			response.AggregatedBy = aggregatorId
+			env = NewEnvelope(NexusInfoResponseType, response, nil)
+		}
+
+		raw, err := json.Marshal(env)
+		if err != nil {
+			log.Error("Failed to serialize nexus info response", slog.Any("err", err))
+			return
+		}
+
+		_ = m.Respond(raw)
+	})
+}
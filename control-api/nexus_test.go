@@ -0,0 +1,53 @@
+package controlapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestListNexusNodesReadsRegistry(t *testing.T) {
+	ns, err := server.NewServer(&server.Options{Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %s", err)
+	}
+	ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not become ready")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect to NATS: %s", err)
+	}
+	t.Cleanup(nc.Close)
+
+	js, _ := nc.JetStream()
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: DefaultNexusRegistryBucket})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"NBBB", "NAAA", "NCCC"} {
+		raw, _ := json.Marshal(NexusMember{NodeId: id, Nexus: "east", Version: "0.0.1"})
+		_, _ = kv.Put(id, raw)
+	}
+	_ = kv.Delete("NCCC")
+
+	client := NewApiClient(nc, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	members, err := client.ListNexusNodes(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Expected to list the registry, got %s", err)
+	}
+
+	if len(members) != 2 || members[0].NodeId != "NAAA" || members[1].NodeId != "NBBB" {
+		t.Fatalf("Expected the registered nodes that did not leave, ordered by ID, got %+v", members)
+	}
+}
@@ -0,0 +1,76 @@
+package controlapi
+
+import (
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// Suspends a workload without undeploying it. Its trigger subscriptions are drained, and when
+// PauseMachine is set its VM is paused too, preserving the agent's state until it is resumed
+type PauseRequest struct {
+	WorkloadId   string `json:"workload_id"`
+	PauseMachine bool   `json:"pause_machine,omitempty"`
+	WorkloadJwt  string `json:"workload_jwt"`
+}
+
+type ResumeRequest struct {
+	WorkloadId  string `json:"workload_id"`
+	WorkloadJwt string `json:"workload_jwt"`
+}
+
+// Creates a pause request signed by the issuer that originally started the workload, or by one of
+// the node's admin keys
+func NewPauseRequest(workloadId string, pauseMachine bool, issuer nkeys.KeyPair) (*PauseRequest, error) {
+	jwtText, err := newWorkloadClaims("pause", workloadId, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PauseRequest{
+		WorkloadId:   workloadId,
+		PauseMachine: pauseMachine,
+		WorkloadJwt:  jwtText,
+	}, nil
+}
+
+// Verifies that the request is signed by the issuer that originally started the workload, or by
+// one of the given admin keys. Every failure is reported as an *AuthorizationError
+func (request *PauseRequest) Validate(originalClaims *jwt.GenericClaims, adminKeys ...string) error {
+	return validateWorkloadClaims(request.WorkloadJwt, "pause", request.WorkloadId, originalClaims, adminKeys)
+}
+
+// Creates a resume request signed by the issuer that originally started the workload, or by one of
+// the node's admin keys
+func NewResumeRequest(workloadId string, issuer nkeys.KeyPair) (*ResumeRequest, error) {
+	jwtText, err := newWorkloadClaims("resume", workloadId, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResumeRequest{
+		WorkloadId:  workloadId,
+		WorkloadJwt: jwtText,
+	}, nil
+}
+
+// Verifies that the request is signed by the issuer that originally started the workload, or by
+// one of the given admin keys. Every failure is reported as an *AuthorizationError
+func (request *ResumeRequest) Validate(originalClaims *jwt.GenericClaims, adminKeys ...string) error {
+	return validateWorkloadClaims(request.WorkloadJwt, "resume", request.WorkloadId, originalClaims, adminKeys)
+}
+
+type PauseResponse struct {
+	WorkloadId      string   `json:"workload_id"`
+	Paused          bool     `json:"paused"`
+	MachinePaused   bool     `json:"machine_paused"`
+	TriggerSubjects []string `json:"trigger_subjects,omitempty"`
+}
+
+// Emitted when a workload is paused or resumed
+type WorkloadPauseEvent struct {
+	WorkloadId      string   `json:"workload_id"`
+	Name            string   `json:"workload_name"`
+	Namespace       string   `json:"namespace"`
+	MachinePaused   bool     `json:"machine_paused"`
+	TriggerSubjects []string `json:"trigger_subjects,omitempty"`
+}
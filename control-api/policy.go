@@ -0,0 +1,78 @@
+package controlapi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// An operation that a node's access policy grants to issuers within a namespace
+type Operation string
+
+const (
+	// Deploying, redeploying, cutting over and cancelling queued deploys of workloads
+	OperationDeploy Operation = "deploy"
+	// Stopping workloads individually, in bulk or by group
+	OperationStop Operation = "stop"
+	// Reading workload output, such as execution history and job results
+	OperationLogs Operation = "logs"
+	// Reading node and workload information, such as node info and workload pings
+	OperationInfo Operation = "info"
+	// Replacing a node's binary or rotating its keys, which is granted in the system namespace
+	OperationUpdate Operation = "update"
+)
+
+var Operations = []Operation{OperationDeploy, OperationStop, OperationLogs, OperationInfo, OperationUpdate}
+
+const (
+	// Header carrying a token that identifies the issuer sending a request which does not
+	// otherwise carry signed claims
+	IdentityHeader = "Nex-Identity"
+
+	// How long an identity token remains valid after being issued
+	IdentityTokenLifetime = 5 * time.Minute
+)
+
+// Creates a token, signed by the given issuer, identifying it for requests in the given namespace
+func NewIdentityToken(namespace string, issuer nkeys.KeyPair) (string, error) {
+	claims := jwt.NewGenericClaims(namespace)
+	claims.Expires = time.Now().Add(IdentityTokenLifetime).Unix()
+
+	return claims.Encode(issuer)
+}
+
+// Verifies an identity token for the given namespace and returns the issuer that signed it.
+// Every failure is reported as an *AuthorizationError
+func VerifyIdentityToken(token string, namespace string) (string, error) {
+	claims, err := jwt.DecodeGeneric(token)
+	if err != nil {
+		return "", NewAuthorizationError(fmt.Sprintf("could not verify identity token: %s", err))
+	}
+	if claims.Expires == 0 || time.Now().Unix() > claims.Expires {
+		return "", NewAuthorizationError("identity token has expired")
+	}
+	if claims.Subject != namespace {
+		return "", NewAuthorizationError("identity token was issued for a different namespace")
+	}
+
+	return claims.Issuer, nil
+}
+
+// Identifies requests as coming from the given issuer, for nodes that enforce an access policy
+func WithIdentity(issuer nkeys.KeyPair) CallOption {
+	return func(o callOptions) callOptions {
+		o.identity = issuer
+		return o
+	}
+}
+
+// Forwards an identity token issued to another requester, for services that make requests
+// on that requester's behalf
+func WithIdentityToken(token string) CallOption {
+	return func(o callOptions) callOptions {
+		o.identityToken = token
+		return o
+	}
+}
@@ -0,0 +1,26 @@
+package controlapi
+
+import "time"
+
+type PreflightStatus string
+
+const (
+	PreflightPass PreflightStatus = "pass"
+	PreflightWarn PreflightStatus = "warn"
+	PreflightFail PreflightStatus = "fail"
+	PreflightSkip PreflightStatus = "skip"
+)
+
+// Outcome of the checks a node runs against its host before accepting workloads. The report
+// passes unless at least one check failed; warnings do not prevent a node from starting
+type PreflightReport struct {
+	Passed    bool             `json:"passed"`
+	CheckedAt time.Time        `json:"checked_at"`
+	Checks    []PreflightCheck `json:"checks"`
+}
+
+type PreflightCheck struct {
+	Name   string          `json:"name"`
+	Status PreflightStatus `json:"status"`
+	Detail string          `json:"detail,omitempty"`
+}
@@ -0,0 +1,36 @@
+package controlapi
+
+import "time"
+
+const (
+	// Set on a node while it is under pressure
+	TagUnderPressure = "nex.pressure"
+
+	// Workload label marking workloads that a node under pressure may pause
+	LabelPriority = "nex.priority"
+	PriorityLow   = "low"
+)
+
+// Host resource pressure sampled by a node. Stall percentages are the share of the last 10
+// seconds in which some tasks were stalled waiting on the resource, as reported by Linux PSI
+type PressureSample struct {
+	CpuSomeAvg10           float64 `json:"cpu_some_avg10"`
+	MemorySomeAvg10        float64 `json:"memory_some_avg10"`
+	MemoryAvailablePercent float64 `json:"memory_available_percent"`
+}
+
+// A node under pressure declines auctions, queues or rejects deploy requests and may pause its
+// low-priority workloads until the pressure subsides
+type PressureStatus struct {
+	Since           time.Time      `json:"since"`
+	Reasons         []string       `json:"reasons"`
+	Sample          PressureSample `json:"sample"`
+	PausedWorkloads []string       `json:"paused_workloads,omitempty"`
+}
+
+// Emitted when a node enters or leaves the pressure state; Pressure is nil once it has recovered
+type NodePressureEvent struct {
+	Id       string          `json:"id"`
+	Pressure *PressureStatus `json:"pressure,omitempty"`
+	Sample   PressureSample  `json:"sample"`
+}
@@ -0,0 +1,69 @@
+package controlapi
+
+import (
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// Actions of a quarantine request
+const (
+	QuarantineActionList   = "list"
+	QuarantineActionResume = "resume"
+	QuarantineActionStop   = "stop"
+)
+
+// Lists the quarantined workloads of a namespace on a node, or lifts the quarantine of one of
+// them. Resuming a workload whose triggers were paused resubscribes them; resuming a workload
+// quarantined for crashing redeploys it. Stopping discards the workload. Lifting a quarantine
+// requires claims signed by the workload's issuer or one of the node's admin keys
+type QuarantineRequest struct {
+	Action      string `json:"action"`
+	WorkloadId  string `json:"workload_id,omitempty"`
+	WorkloadJwt string `json:"workload_jwt,omitempty"`
+}
+
+// Creates a request resuming or stopping a quarantined workload, signed by the issuer that
+// originally started the workload or by one of the node's admin keys
+func NewQuarantineRequest(action string, workloadId string, issuer nkeys.KeyPair) (*QuarantineRequest, error) {
+	jwtText, err := newWorkloadClaims("quarantine "+action, workloadId, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuarantineRequest{
+		Action:      action,
+		WorkloadId:  workloadId,
+		WorkloadJwt: jwtText,
+	}, nil
+}
+
+// Verifies that the request is signed by the issuer that originally started the workload, or by
+// one of the given admin keys. Every failure is reported as an *AuthorizationError
+func (request *QuarantineRequest) Validate(originalClaims *jwt.GenericClaims, adminKeys ...string) error {
+	return validateWorkloadClaims(request.WorkloadJwt, "quarantine "+request.Action, request.WorkloadId, originalClaims, adminKeys)
+}
+
+type QuarantineResponse struct {
+	NodeId    string                `json:"node_id"`
+	Workloads []QuarantinedWorkload `json:"workloads"`
+}
+
+// A workload quarantined by its node. A workload quarantined for failing trigger executions keeps
+// running with its trigger subscriptions paused; one quarantined for crashing is not restarted
+type QuarantinedWorkload struct {
+	WorkloadId    string    `json:"workload_id"`
+	Name          string    `json:"name"`
+	Namespace     string    `json:"namespace"`
+	Reason        string    `json:"reason"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+	Running       bool      `json:"running"`
+
+	// Trigger executions and failures within the window that tripped the quarantine
+	Executions int `json:"executions,omitempty"`
+	Failures   int `json:"failures,omitempty"`
+
+	Crashes        uint     `json:"crashes,omitempty"`
+	PausedSubjects []string `json:"paused_subjects,omitempty"`
+}
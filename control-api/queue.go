@@ -0,0 +1,22 @@
+package controlapi
+
+import "time"
+
+// Interim response sent to the requester of a queueable deploy while it waits
+// for an agent to become available. One of these is sent when the request is
+// queued and again whenever its position in the queue changes
+type DeployQueuedResponse struct {
+	QueueID             string    `json:"queue_id"`
+	Position            int       `json:"position"`
+	EstimatedWaitMillis int64     `json:"estimated_wait_ms,omitempty"`
+	ExpiresAt           time.Time `json:"expires_at"`
+}
+
+type CancelDeployRequest struct {
+	QueueID string `json:"queue_id"`
+}
+
+type CancelDeployResponse struct {
+	Cancelled bool   `json:"cancelled"`
+	QueueID   string `json:"queue_id"`
+}
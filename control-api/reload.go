@@ -0,0 +1,16 @@
+package controlapi
+
+// A node configuration setting changed by a reload, identified by its JSON field name
+type ConfigChange struct {
+	Field    string `json:"field"`
+	Previous any    `json:"previous"`
+	Current  any    `json:"current"`
+}
+
+type ReloadResponse struct {
+	NodeId  string         `json:"node_id"`
+	Changes []ConfigChange `json:"changes"`
+
+	// Settings that differ from the running configuration but only take effect on restart
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
@@ -0,0 +1,278 @@
+package controlapi
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of declared resource
+type ResourceKind string
+
+const (
+	// Desired configuration of a single node, identified by its public key
+	ResourceKindNode ResourceKind = "node"
+	// Desired set of workloads running in a namespace
+	ResourceKindWorkloadSet ResourceKind = "workload_set"
+)
+
+// Actions of a resource request
+const (
+	ResourceActionApply  = "apply"
+	ResourceActionGet    = "get"
+	ResourceActionList   = "list"
+	ResourceActionDelete = "delete"
+)
+
+var validResourceId = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// A declared resource describing the desired state of part of a nexus, in a form suited to
+// infrastructure as code tools such as Terraform or Pulumi. Resources are stored per namespace in a
+// key-value bucket shared by every node configured to manage them. Nodes do not act on resources
+// themselves; drift reports compare them with what the nexus is actually running, and the tool
+// managing them makes the changes
+type Resource struct {
+	Kind ResourceKind `json:"kind"`
+	ID   string       `json:"id"`
+
+	// Increases with every change to the resource. Applying a resource with a non-zero version
+	// fails unless it matches the stored version, so that concurrent changes are not lost, while a
+	// zero version only creates resources that do not yet exist
+	Version uint64 `json:"version"`
+
+	Node        *NodeSpec        `json:"node,omitempty"`
+	WorkloadSet *WorkloadSetSpec `json:"workload_set,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Desired configuration of a node. Tags the node has but the spec does not declare are not drift,
+// since nodes set some tags themselves
+type NodeSpec struct {
+	NodeId   string            `json:"node_id"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Cordoned bool              `json:"cordoned"`
+}
+
+// Workloads that should be running in the namespace of the resource
+type WorkloadSetSpec struct {
+	Workloads []DeclaredWorkload `json:"workloads"`
+}
+
+// A workload expected to run with the given number of instances across the nexus. When a SHA-256
+// digest is given, instances running any other artifact count as drift
+type DeclaredWorkload struct {
+	Name         string      `json:"name"`
+	WorkloadType NexWorkload `json:"type"`
+	Sha256       string      `json:"sha256,omitempty"`
+	Replicas     int         `json:"replicas"`
+}
+
+func (r *Resource) Validate() error {
+	var err error
+
+	if !validResourceId.MatchString(r.ID) {
+		err = errors.Join(err, fmt.Errorf("invalid resource id %q; ids may only contain letters, digits, '-' and '_'", r.ID))
+	}
+
+	switch r.Kind {
+	case ResourceKindNode:
+		if r.Node == nil || r.WorkloadSet != nil {
+			return errors.Join(err, errors.New("node resources must only declare a node spec"))
+		}
+		if r.Node.NodeId == "" {
+			err = errors.Join(err, errors.New("node resources must name the node they configure"))
+		}
+	case ResourceKindWorkloadSet:
+		if r.WorkloadSet == nil || r.Node != nil {
+			return errors.Join(err, errors.New("workload set resources must only declare a workload set spec"))
+		}
+
+		names := make(map[string]bool)
+		for _, workload := range r.WorkloadSet.Workloads {
+			if !validWorkloadName.MatchString(workload.Name) {
+				err = errors.Join(err, fmt.Errorf("declared workload name ('%s') must be all lowercase letters", workload.Name))
+			}
+			if names[workload.Name] {
+				err = errors.Join(err, fmt.Errorf("workload %s is declared more than once", workload.Name))
+			}
+			names[workload.Name] = true
+
+			if workload.Replicas < 0 {
+				err = errors.Join(err, fmt.Errorf("workload %s must not declare negative replicas", workload.Name))
+			}
+			if workload.Sha256 != "" && !validSha256.MatchString(workload.Sha256) {
+				err = errors.Join(err, fmt.Errorf("workload %s sha256 must be a hex encoded SHA-256 digest", workload.Name))
+			}
+		}
+	default:
+		err = errors.Join(err, fmt.Errorf("unknown resource kind %q", r.Kind))
+	}
+
+	return err
+}
+
+func (k ResourceKind) valid() bool {
+	return k == ResourceKindNode || k == ResourceKindWorkloadSet
+}
+
+// Applies, reads, lists or deletes the declared resources of the namespace the request is made in
+type ResourceRequest struct {
+	Action string `json:"action"`
+
+	// The resource to apply
+	Resource *Resource `json:"resource,omitempty"`
+
+	// Identify the resource to get or delete. When listing, a kind limits the resources listed
+	Kind ResourceKind `json:"kind,omitempty"`
+	ID   string       `json:"id,omitempty"`
+
+	// When deleting, the delete fails unless the resource is at this version; zero deletes any version
+	Version uint64 `json:"version,omitempty"`
+}
+
+func (request *ResourceRequest) Validate() error {
+	switch request.Action {
+	case ResourceActionApply:
+		if request.Resource == nil {
+			return errors.New("applying a resource requires a resource")
+		}
+		return request.Resource.Validate()
+	case ResourceActionGet, ResourceActionDelete:
+		if !request.Kind.valid() {
+			return fmt.Errorf("unknown resource kind %q", request.Kind)
+		}
+		if !validResourceId.MatchString(request.ID) {
+			return fmt.Errorf("invalid resource id %q", request.ID)
+		}
+	case ResourceActionList:
+		if request.Kind != "" && !request.Kind.valid() {
+			return fmt.Errorf("unknown resource kind %q", request.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown resource action %q", request.Action)
+	}
+
+	return nil
+}
+
+type ResourceResponse struct {
+	NodeId    string     `json:"node_id"`
+	Resources []Resource `json:"resources"`
+}
+
+// What a node reports of itself and of the workloads it runs in a namespace, against which
+// declared resources are compared
+type NodeState struct {
+	NodeId    string            `json:"node_id"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Cordoned  bool              `json:"cordoned"`
+	Workloads []WorkloadState   `json:"workloads"`
+}
+
+type WorkloadState struct {
+	Id           string      `json:"id"`
+	Name         string      `json:"name"`
+	WorkloadType NexWorkload `json:"type"`
+	Hash         string      `json:"hash"`
+}
+
+// Outcome of comparing a declared resource with the state reported by the nexus
+type ResourceDrift struct {
+	Kind    ResourceKind `json:"kind"`
+	ID      string       `json:"id"`
+	Version uint64       `json:"version"`
+	InSync  bool         `json:"in_sync"`
+
+	// Describes each difference between the declared and the actual state
+	Differences []string `json:"differences,omitempty"`
+}
+
+// Compares a declared resource with the states reported by the nodes of a nexus. The states are
+// expected to cover every node, as a node that did not report is indistinguishable from one that
+// does not exist
+func DetectDrift(resource Resource, states []NodeState) ResourceDrift {
+	drift := ResourceDrift{Kind: resource.Kind, ID: resource.ID, Version: resource.Version}
+
+	switch resource.Kind {
+	case ResourceKindNode:
+		if resource.Node != nil {
+			drift.Differences = nodeDrift(*resource.Node, states)
+		}
+	case ResourceKindWorkloadSet:
+		if resource.WorkloadSet != nil {
+			drift.Differences = workloadSetDrift(*resource.WorkloadSet, states)
+		}
+	}
+
+	drift.InSync = len(drift.Differences) == 0
+	return drift
+}
+
+func nodeDrift(spec NodeSpec, states []NodeState) []string {
+	index := slices.IndexFunc(states, func(state NodeState) bool {
+		return state.NodeId == spec.NodeId
+	})
+	if index < 0 {
+		return []string{fmt.Sprintf("node %s did not report its state", spec.NodeId)}
+	}
+	state := states[index]
+
+	differences := make([]string, 0)
+
+	keys := make([]string, 0, len(spec.Tags))
+	for key := range spec.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		actual, ok := state.Tags[key]
+		switch {
+		case !ok:
+			differences = append(differences, fmt.Sprintf("tag %s is not set; declared %q", key, spec.Tags[key]))
+		case actual != spec.Tags[key]:
+			differences = append(differences, fmt.Sprintf("tag %s is %q; declared %q", key, actual, spec.Tags[key]))
+		}
+	}
+
+	if state.Cordoned != spec.Cordoned {
+		differences = append(differences, fmt.Sprintf("node cordoned is %v; declared %v", state.Cordoned, spec.Cordoned))
+	}
+
+	return differences
+}
+
+func workloadSetDrift(spec WorkloadSetSpec, states []NodeState) []string {
+	differences := make([]string, 0)
+
+	for _, declared := range spec.Workloads {
+		replicas := 0
+		for _, state := range states {
+			for _, workload := range state.Workloads {
+				if workload.Name != declared.Name {
+					continue
+				}
+				replicas++
+
+				if declared.WorkloadType != "" && workload.WorkloadType != declared.WorkloadType {
+					differences = append(differences, fmt.Sprintf("workload %s (%s) on node %s is of type %s; declared %s", declared.Name, workload.Id, state.NodeId, workload.WorkloadType, declared.WorkloadType))
+				}
+				if declared.Sha256 != "" && !strings.EqualFold(workload.Hash, declared.Sha256) {
+					differences = append(differences, fmt.Sprintf("workload %s (%s) on node %s runs artifact %s; declared %s", declared.Name, workload.Id, state.NodeId, workload.Hash, declared.Sha256))
+				}
+			}
+		}
+
+		if replicas != declared.Replicas {
+			differences = append(differences, fmt.Sprintf("workload %s has %d replicas; declared %d", declared.Name, replicas, declared.Replicas))
+		}
+	}
+
+	return differences
+}
@@ -0,0 +1,105 @@
+package controlapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResourceValidation(t *testing.T) {
+	node := Resource{Kind: ResourceKindNode, ID: "edge-1", Node: &NodeSpec{NodeId: "NABC"}}
+	if err := node.Validate(); err != nil {
+		t.Fatalf("Expected node resource to be valid: %s", err)
+	}
+
+	set := Resource{
+		Kind: ResourceKindWorkloadSet,
+		ID:   "echo",
+		WorkloadSet: &WorkloadSetSpec{Workloads: []DeclaredWorkload{
+			{Name: "echo", WorkloadType: NexWorkloadNative, Sha256: strings.Repeat("ab", 32), Replicas: 2},
+		}},
+	}
+	if err := set.Validate(); err != nil {
+		t.Fatalf("Expected workload set resource to be valid: %s", err)
+	}
+
+	invalid := []Resource{
+		{Kind: ResourceKindNode, ID: "edge 1", Node: &NodeSpec{NodeId: "NABC"}},
+		{Kind: ResourceKindNode, ID: "edge", Node: &NodeSpec{}},
+		{Kind: ResourceKindNode, ID: "edge", WorkloadSet: &WorkloadSetSpec{}},
+		{Kind: "cluster", ID: "edge"},
+		{Kind: ResourceKindWorkloadSet, ID: "echo", WorkloadSet: &WorkloadSetSpec{Workloads: []DeclaredWorkload{
+			{Name: "echo", Replicas: 1},
+			{Name: "echo", Replicas: 1},
+		}}},
+		{Kind: ResourceKindWorkloadSet, ID: "echo", WorkloadSet: &WorkloadSetSpec{Workloads: []DeclaredWorkload{
+			{Name: "echo", Replicas: -1},
+		}}},
+		{Kind: ResourceKindWorkloadSet, ID: "echo", WorkloadSet: &WorkloadSetSpec{Workloads: []DeclaredWorkload{
+			{Name: "echo", Sha256: "abc123", Replicas: 1},
+		}}},
+	}
+	for i, resource := range invalid {
+		if err := resource.Validate(); err == nil {
+			t.Fatalf("Expected resource %d to be invalid", i)
+		}
+	}
+
+	request := ResourceRequest{Action: ResourceActionGet, Kind: ResourceKindNode}
+	if err := request.Validate(); err == nil {
+		t.Fatal("Expected get request without an id to be invalid")
+	}
+
+	request = ResourceRequest{Action: ResourceActionList}
+	if err := request.Validate(); err != nil {
+		t.Fatalf("Expected list request without a kind to be valid: %s", err)
+	}
+}
+
+func TestDetectDrift(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	states := []NodeState{
+		{
+			NodeId:    "NONE",
+			Tags:      map[string]string{"region": "eu", "nex.arch": "amd64"},
+			Workloads: []WorkloadState{{Id: "w1", Name: "echo", WorkloadType: NexWorkloadNative, Hash: hash}},
+		},
+		{
+			NodeId:    "NTWO",
+			Cordoned:  true,
+			Workloads: []WorkloadState{{Id: "w2", Name: "echo", WorkloadType: NexWorkloadNative, Hash: strings.Repeat("cd", 32)}},
+		},
+	}
+
+	node := Resource{Kind: ResourceKindNode, ID: "one", Version: 3, Node: &NodeSpec{NodeId: "NONE", Tags: map[string]string{"region": "eu"}}}
+	drift := DetectDrift(node, states)
+	if !drift.InSync || drift.Version != 3 {
+		t.Fatalf("Expected node one to be in sync at version 3, got %+v", drift)
+	}
+
+	node.Node = &NodeSpec{NodeId: "NTWO", Tags: map[string]string{"region": "us"}}
+	drift = DetectDrift(node, states)
+	if drift.InSync || len(drift.Differences) != 2 {
+		t.Fatalf("Expected a missing tag and cordon drift on node two, got %v", drift.Differences)
+	}
+
+	node.Node = &NodeSpec{NodeId: "NTHREE"}
+	drift = DetectDrift(node, states)
+	if drift.InSync {
+		t.Fatal("Expected a node that did not report to be drift")
+	}
+
+	set := Resource{Kind: ResourceKindWorkloadSet, ID: "echo", WorkloadSet: &WorkloadSetSpec{Workloads: []DeclaredWorkload{
+		{Name: "echo", WorkloadType: NexWorkloadNative, Replicas: 2},
+	}}}
+	drift = DetectDrift(set, states)
+	if !drift.InSync {
+		t.Fatalf("Expected workload set to be in sync, got %v", drift.Differences)
+	}
+
+	set.WorkloadSet.Workloads[0].Sha256 = strings.ToUpper(hash)
+	set.WorkloadSet.Workloads[0].Replicas = 3
+	drift = DetectDrift(set, states)
+	if drift.InSync || len(drift.Differences) != 2 {
+		t.Fatalf("Expected artifact and replica drift, got %v", drift.Differences)
+	}
+}
@@ -0,0 +1,46 @@
+package controlapi
+
+import (
+	"errors"
+	"time"
+)
+
+// Bucket in which nodes cache function results unless configured otherwise
+const DefaultResultCacheBucket = "NEX_RESULTS"
+
+// Lets nodes answer a function's trigger messages with its earlier responses to the same subject
+// and payload, without running the function. Responses are cached in a key-value bucket shared by
+// every node using it, so a response computed on one node is served by the others, and are keyed
+// by the function's artifact, so redeploying a changed function starts with an empty cache. Only
+// functions whose response depends on nothing but the subject and payload should be cached
+type ResultCachePolicy struct {
+	// How long a response is served from the cache, bounded by the max age of the node's bucket
+	TTLMillis int64 `json:"ttl_ms"`
+
+	// Trigger subjects whose responses are cached, which may contain wildcards; every subject
+	// when empty
+	Subjects []string `json:"subjects,omitempty"`
+}
+
+func (policy *ResultCachePolicy) validate() error {
+	if policy.TTLMillis <= 0 {
+		return errors.New("result cache ttl must be positive")
+	}
+
+	for _, subject := range policy.Subjects {
+		if subject == "" {
+			return errors.New("result cache subjects must not be empty")
+		}
+	}
+
+	return nil
+}
+
+// Serves the workload's trigger messages from the node's result cache for the given time. Limit
+// caching to some of its subjects by naming them
+func CacheResults(ttl time.Duration, subjects ...string) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.resultCache = &ResultCachePolicy{TTLMillis: ttl.Milliseconds(), Subjects: subjects}
+		return o
+	}
+}
@@ -0,0 +1,466 @@
+package controlapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/xid"
+)
+
+// Rollout control subjects, served by the client coordinating the rollout:
+// $NEX.ROLLOUT.{rollout}.STATUS
+// $NEX.ROLLOUT.{rollout}.PAUSE
+// $NEX.ROLLOUT.{rollout}.RESUME
+// $NEX.ROLLOUT.{rollout}.ABORT
+
+type RolloutState string
+
+const (
+	RolloutStatePending   RolloutState = "pending"
+	RolloutStateRunning   RolloutState = "running"
+	RolloutStatePaused    RolloutState = "paused"
+	RolloutStateAborted   RolloutState = "aborted"
+	RolloutStateCompleted RolloutState = "completed"
+
+	defaultRolloutReadyTimeout = 30 * time.Second
+	rolloutProbeInterval       = 500 * time.Millisecond
+)
+
+// Governs how many nodes may be updated at once. MaxSurge is the number of nodes that may
+// temporarily run both the old and the new workload, MaxUnavailable is the number of nodes
+// that may have their old workload stopped before the replacement is ready. Once FailureThreshold
+// node updates have failed (0 disables the check), the rollout pauses until resumed or aborted
+type RolloutStrategy struct {
+	MaxUnavailable   int           `json:"max_unavailable"`
+	MaxSurge         int           `json:"max_surge"`
+	FailureThreshold int           `json:"failure_threshold,omitempty"`
+	ReadyTimeout     time.Duration `json:"ready_timeout,omitempty"`
+}
+
+// A single node participating in a rollout. If PreviousWorkloadId is set, that workload is
+// stopped as part of updating the node
+type RolloutTarget struct {
+	NodeId             string `json:"node_id"`
+	PreviousWorkloadId string `json:"previous_workload_id,omitempty"`
+}
+
+type RolloutStatus struct {
+	ID         string       `json:"id"`
+	State      RolloutState `json:"state"`
+	Total      int          `json:"total"`
+	Completed  int          `json:"completed"`
+	Failed     int          `json:"failed"`
+	InProgress int          `json:"in_progress"`
+	LastError  string       `json:"last_error,omitempty"`
+}
+
+// Produces the deploy request for the new workload on the given node
+type RolloutDeployFactory func(nodeId string) (*DeployRequest, error)
+
+// Produces the stop request for the workload PreviousWorkloadId on the target's node. It is used
+// both to stop the workload being replaced and to roll back a new workload that never became ready
+type RolloutStopFactory func(target RolloutTarget) (*StopRequest, error)
+
+// A rollout replaces a workload across a set of nodes while honoring the limits of its
+// strategy. The client running the rollout acts as its controller and answers status,
+// pause, resume and abort requests on the rollout's control subjects
+type Rollout struct {
+	api      *Client
+	id       string
+	strategy RolloutStrategy
+	targets  []RolloutTarget
+
+	deployFactory RolloutDeployFactory
+	stopFactory   RolloutStopFactory
+
+	mutex   sync.Mutex
+	status  RolloutStatus
+	surging int
+	resumed chan struct{}
+	aborted chan struct{}
+}
+
+// Creates a rollout of a workload across the given targets. The rollout does not begin until Run is called
+func (api *Client) NewRollout(targets []RolloutTarget, strategy RolloutStrategy, deployFactory RolloutDeployFactory, stopFactory RolloutStopFactory) (*Rollout, error) {
+	if strategy.MaxSurge < 0 || strategy.MaxUnavailable < 0 {
+		return nil, errors.New("max surge and max unavailable must not be negative")
+	}
+	if strategy.MaxSurge+strategy.MaxUnavailable == 0 {
+		return nil, errors.New("at least one of max surge or max unavailable must be greater than zero")
+	}
+	if deployFactory == nil {
+		return nil, errors.New("a deploy request factory is required")
+	}
+	if stopFactory == nil {
+		return nil, errors.New("a stop request factory is required")
+	}
+	if strategy.ReadyTimeout == 0 {
+		strategy.ReadyTimeout = defaultRolloutReadyTimeout
+	}
+
+	id := xid.New().String()
+	return &Rollout{
+		api:           api,
+		id:            id,
+		strategy:      strategy,
+		targets:       targets,
+		deployFactory: deployFactory,
+		stopFactory:   stopFactory,
+		status: RolloutStatus{
+			ID:    id,
+			State: RolloutStatePending,
+			Total: len(targets),
+		},
+		resumed: make(chan struct{}, 1),
+		aborted: make(chan struct{}),
+	}, nil
+}
+
+func (r *Rollout) ID() string {
+	return r.id
+}
+
+func (r *Rollout) Status() RolloutStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.status
+}
+
+// Pauses the rollout. Node updates already in progress are allowed to finish
+func (r *Rollout) Pause() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status.State != RolloutStateRunning {
+		return fmt.Errorf("cannot pause a rollout that is %s", r.status.State)
+	}
+	r.status.State = RolloutStatePaused
+	return nil
+}
+
+// Resumes a paused rollout
+func (r *Rollout) Resume() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status.State != RolloutStatePaused {
+		return fmt.Errorf("cannot resume a rollout that is %s", r.status.State)
+	}
+	r.status.State = RolloutStateRunning
+
+	select {
+	case r.resumed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Aborts the rollout. No further node updates are started, but updates in progress are not reverted
+func (r *Rollout) Abort() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.status.State == RolloutStateAborted || r.status.State == RolloutStateCompleted {
+		return fmt.Errorf("cannot abort a rollout that is %s", r.status.State)
+	}
+	r.status.State = RolloutStateAborted
+	close(r.aborted)
+	return nil
+}
+
+// Runs the rollout to completion, returning early if it is aborted or the context is cancelled
+func (r *Rollout) Run(ctx context.Context) (*RolloutStatus, error) {
+	r.mutex.Lock()
+	if r.status.State != RolloutStatePending {
+		r.mutex.Unlock()
+		return nil, fmt.Errorf("cannot run a rollout that is %s", r.status.State)
+	}
+	r.status.State = RolloutStateRunning
+	r.mutex.Unlock()
+
+	sub, err := r.api.nc.Subscribe(fmt.Sprintf("%s.ROLLOUT.%s.*", APIPrefix, r.id), r.handleControl)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = sub.Unsubscribe()
+	}()
+
+	r.api.log.Info("Starting rollout",
+		slog.String("rollout_id", r.id),
+		slog.Int("targets", len(r.targets)),
+		slog.Int("max_surge", r.strategy.MaxSurge),
+		slog.Int("max_unavailable", r.strategy.MaxUnavailable),
+	)
+
+	slots := make(chan struct{}, r.strategy.MaxSurge+r.strategy.MaxUnavailable)
+	wg := sync.WaitGroup{}
+
+	var runErr error
+	for _, target := range r.targets {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			runErr = ctx.Err()
+		case <-r.aborted:
+			runErr = errors.New("rollout aborted")
+		}
+		if runErr != nil {
+			break
+		}
+
+		// checked once a slot is free, so that a failure in the update holding it can pause the rollout
+		runErr = r.awaitRunnable(ctx)
+		if runErr != nil {
+			<-slots
+			break
+		}
+
+		surge := r.claimSurge()
+
+		wg.Add(1)
+		go func(target RolloutTarget, surge bool) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			err := r.updateTarget(ctx, target, surge)
+			r.recordResult(target, surge, err)
+		}(target, surge)
+	}
+
+	wg.Wait()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if runErr == nil && r.status.State == RolloutStateRunning {
+		r.status.State = RolloutStateCompleted
+	}
+	status := r.status
+
+	return &status, runErr
+}
+
+// Blocks while the rollout is paused
+func (r *Rollout) awaitRunnable(ctx context.Context) error {
+	for {
+		r.mutex.Lock()
+		state := r.status.State
+		r.mutex.Unlock()
+
+		switch state {
+		case RolloutStateRunning:
+			return nil
+		case RolloutStateAborted:
+			return errors.New("rollout aborted")
+		}
+
+		select {
+		case <-r.resumed:
+		case <-r.aborted:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Surge slots are used first, so that capacity is only reduced once the surge budget is exhausted
+func (r *Rollout) claimSurge() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.status.InProgress++
+	if r.surging < r.strategy.MaxSurge {
+		r.surging++
+		return true
+	}
+	return false
+}
+
+func (r *Rollout) recordResult(target RolloutTarget, surge bool, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.status.InProgress--
+	if surge {
+		r.surging--
+	}
+
+	if err != nil {
+		r.status.Failed++
+		r.status.LastError = fmt.Sprintf("%s: %s", target.NodeId, err)
+
+		r.api.log.Warn("Rollout failed to update node",
+			slog.String("rollout_id", r.id),
+			slog.String("node_id", target.NodeId),
+			slog.Any("err", err),
+		)
+
+		if r.strategy.FailureThreshold > 0 && r.status.Failed >= r.strategy.FailureThreshold && r.status.State == RolloutStateRunning {
+			r.api.log.Warn("Rollout failure threshold exceeded, pausing", slog.String("rollout_id", r.id))
+			r.status.State = RolloutStatePaused
+		}
+		return
+	}
+
+	r.status.Completed++
+}
+
+// Replaces the workload on a single node. In surge mode the new workload is started and confirmed
+// ready before the previous one is stopped; otherwise the previous workload is stopped first. A new
+// workload that does not become ready is stopped, so in surge mode the previous one keeps serving
+func (r *Rollout) updateTarget(ctx context.Context, target RolloutTarget, surge bool) error {
+	if !surge {
+		err := r.stopTargetWorkload(ctx, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	request, err := r.deployFactory(target.NodeId)
+	if err != nil {
+		return err
+	}
+
+	response, err := r.api.StartWorkload(ctx, request)
+	if err != nil {
+		return err
+	}
+	if !response.Started {
+		return errors.New("workload was not started")
+	}
+
+	err = r.awaitReady(ctx, response.ID)
+	if err != nil {
+		stopErr := r.stopTargetWorkload(ctx, RolloutTarget{NodeId: target.NodeId, PreviousWorkloadId: response.ID})
+		if stopErr != nil {
+			return fmt.Errorf("%s; failed to stop it: %s", err, stopErr)
+		}
+		return err
+	}
+
+	if surge {
+		return r.stopTargetWorkload(ctx, target)
+	}
+
+	return nil
+}
+
+// Stops the workload named by the target's PreviousWorkloadId, if any
+func (r *Rollout) stopTargetWorkload(ctx context.Context, target RolloutTarget) error {
+	if target.PreviousWorkloadId == "" {
+		return nil
+	}
+
+	request, err := r.stopFactory(target)
+	if err != nil {
+		return err
+	}
+
+	response, err := r.api.StopWorkload(ctx, request)
+	if err != nil {
+		return err
+	}
+	if !response.Stopped {
+		return fmt.Errorf("workload %s was not stopped", target.PreviousWorkloadId)
+	}
+
+	return nil
+}
+
+// Waits for the given workload to respond to a workload ping
+func (r *Rollout) awaitReady(ctx context.Context, workloadID string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.strategy.ReadyTimeout)
+	defer cancel()
+
+	for {
+		responses, err := r.api.PingWorkloads(ctx, workloadID)
+		if err == nil {
+			for _, response := range responses {
+				for _, machine := range response.RunningMachines {
+					if machine.Id == workloadID {
+						return nil
+					}
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("workload %s did not become ready", workloadID)
+		case <-time.After(rolloutProbeInterval):
+		}
+	}
+}
+
+func (r *Rollout) handleControl(m *nats.Msg) {
+	tokens := strings.Split(m.Subject, ".")
+	op := tokens[len(tokens)-1]
+
+	var err error
+	switch op {
+	case "STATUS":
+	case "PAUSE":
+		err = r.Pause()
+	case "RESUME":
+		err = r.Resume()
+	case "ABORT":
+		err = r.Abort()
+	default:
+		err = fmt.Errorf("unknown rollout operation: %s", op)
+	}
+
+	var env Envelope
+	if err != nil {
+		reason := err.Error()
+		env = NewEnvelope(RolloutResponseType, []byte{}, &reason)
+	} else {
+		env = NewEnvelope(RolloutResponseType, r.Status(), nil)
+	}
+
+	raw, _ := json.Marshal(env)
+	_ = m.Respond(raw)
+}
+
+// Requests the status of a rollout coordinated by any client connected to the nexus
+func (api *Client) RolloutStatus(ctx context.Context, rolloutID string, opts ...CallOption) (*RolloutStatus, error) {
+	return api.controlRollout(ctx, rolloutID, "STATUS", opts)
+}
+
+// Pauses a rollout coordinated by any client connected to the nexus
+func (api *Client) PauseRollout(ctx context.Context, rolloutID string, opts ...CallOption) (*RolloutStatus, error) {
+	return api.controlRollout(ctx, rolloutID, "PAUSE", opts)
+}
+
+// Resumes a paused rollout coordinated by any client connected to the nexus
+func (api *Client) ResumeRollout(ctx context.Context, rolloutID string, opts ...CallOption) (*RolloutStatus, error) {
+	return api.controlRollout(ctx, rolloutID, "RESUME", opts)
+}
+
+// Aborts a rollout coordinated by any client connected to the nexus
+func (api *Client) AbortRollout(ctx context.Context, rolloutID string, opts ...CallOption) (*RolloutStatus, error) {
+	return api.controlRollout(ctx, rolloutID, "ABORT", opts)
+}
+
+func (api *Client) controlRollout(ctx context.Context, rolloutID string, op string, opts []CallOption) (*RolloutStatus, error) {
+	subject := fmt.Sprintf("%s.ROLLOUT.%s.%s", APIPrefix, rolloutID, op)
+	bytes, err := api.performRequest(ctx, subject, nil, op == "STATUS", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response RolloutStatus
+	err = json.Unmarshal(bytes, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
@@ -0,0 +1,311 @@
+package controlapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/xid"
+)
+
+// Answers deploy, stop and workload ping requests in the default namespace on behalf of a set
+// of nodes. Workloads deployed to a broken node start but never answer pings
+type fakeNexus struct {
+	mutex    sync.Mutex
+	running  map[string][]string
+	stopped  []string
+	broken   map[string]bool
+	updating int
+	peak     int
+}
+
+func startFakeNexus(t *testing.T, nodes map[string]string, broken ...string) (*fakeNexus, *Client) {
+	nc := startTestNats(t)
+
+	f := &fakeNexus{running: make(map[string][]string), broken: make(map[string]bool)}
+	for node, previous := range nodes {
+		f.running[node] = []string{previous}
+	}
+	for _, node := range broken {
+		f.broken[node] = true
+	}
+
+	for _, sub := range []struct {
+		subject string
+		handler nats.MsgHandler
+	}{
+		{"$NEX.DEPLOY.default.*", f.handleDeploy},
+		{"$NEX.STOP.default.*", f.handleStop},
+		{"$NEX.WPING.default.*", f.handlePing},
+	} {
+		_, err := nc.Subscribe(sub.subject, sub.handler)
+		if err != nil {
+			t.Fatalf("Failed to subscribe to %s: %s", sub.subject, err)
+		}
+	}
+
+	return f, NewApiClientWithNamespace(nc, 100*time.Millisecond, "default", slog.Default())
+}
+
+func (f *fakeNexus) respond(m *nats.Msg, dataType string, data interface{}) {
+	raw, _ := json.Marshal(NewEnvelope(dataType, data, nil))
+	_ = m.Respond(raw)
+}
+
+func (f *fakeNexus) handleDeploy(m *nats.Msg) {
+	node := m.Subject[strings.LastIndex(m.Subject, ".")+1:]
+	id := xid.New().String()
+
+	f.mutex.Lock()
+	f.updating++
+	if f.updating > f.peak {
+		f.peak = f.updating
+	}
+	f.mutex.Unlock()
+
+	// holds the deploy open for a while, so that overlapping updates are observed
+	time.Sleep(50 * time.Millisecond)
+
+	f.mutex.Lock()
+	f.updating--
+	f.running[node] = append(f.running[node], id)
+	f.mutex.Unlock()
+
+	f.respond(m, RunResponseType, RunResponse{Started: true, ID: id, Name: "echo"})
+}
+
+func (f *fakeNexus) handleStop(m *nats.Msg) {
+	var request StopRequest
+	_ = json.Unmarshal(m.Data, &request)
+
+	f.mutex.Lock()
+	f.stopped = append(f.stopped, request.WorkloadId)
+	ids := f.running[request.TargetNode]
+	for i, id := range ids {
+		if id == request.WorkloadId {
+			f.running[request.TargetNode] = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+	f.mutex.Unlock()
+
+	f.respond(m, StopResponseType, StopResponse{Stopped: true, ID: request.WorkloadId, Name: "echo"})
+}
+
+func (f *fakeNexus) handlePing(m *nats.Msg) {
+	workloadID := m.Subject[strings.LastIndex(m.Subject, ".")+1:]
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for node, ids := range f.running {
+		if f.broken[node] {
+			continue
+		}
+		for _, id := range ids {
+			if id == workloadID {
+				f.respond(m, PingResponseType, WorkloadPingResponse{
+					NodeId:          node,
+					RunningMachines: []WorkloadPingMachineSummary{{Id: id, Namespace: "default", Name: "echo"}},
+				})
+				return
+			}
+		}
+	}
+}
+
+func (f *fakeNexus) workloads(node string) []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string{}, f.running[node]...)
+}
+
+func (f *fakeNexus) wasStopped(workloadID string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, id := range f.stopped {
+		if id == workloadID {
+			return true
+		}
+	}
+	return false
+}
+
+func testRolloutFactories() (RolloutDeployFactory, RolloutStopFactory) {
+	deploy := func(nodeId string) (*DeployRequest, error) {
+		return &DeployRequest{TargetNode: &nodeId}, nil
+	}
+	stop := func(target RolloutTarget) (*StopRequest, error) {
+		return &StopRequest{WorkloadId: target.PreviousWorkloadId, TargetNode: target.NodeId}, nil
+	}
+	return deploy, stop
+}
+
+func rolloutTargetsFor(nodes map[string]string, order ...string) []RolloutTarget {
+	targets := make([]RolloutTarget, 0, len(order))
+	for _, node := range order {
+		targets = append(targets, RolloutTarget{NodeId: node, PreviousWorkloadId: nodes[node]})
+	}
+	return targets
+}
+
+func TestRolloutUpdatesNodesInBatches(t *testing.T) {
+	nodes := make(map[string]string)
+	order := make([]string, 0)
+	for i := 0; i < 6; i++ {
+		node := fmt.Sprintf("node%d", i)
+		nodes[node] = fmt.Sprintf("old%d", i)
+		order = append(order, node)
+	}
+
+	nexus, client := startFakeNexus(t, nodes)
+	deploy, stop := testRolloutFactories()
+
+	rollout, err := client.NewRollout(rolloutTargetsFor(nodes, order...), RolloutStrategy{MaxSurge: 1, MaxUnavailable: 1}, deploy, stop)
+	if err != nil {
+		t.Fatalf("Failed to create rollout: %s", err)
+	}
+
+	status, err := rollout.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the rollout to succeed: %s", err)
+	}
+	if status.State != RolloutStateCompleted || status.Completed != 6 || status.Failed != 0 {
+		t.Fatalf("Expected all 6 nodes to be updated, got %+v", status)
+	}
+
+	nexus.mutex.Lock()
+	peak := nexus.peak
+	nexus.mutex.Unlock()
+
+	if peak > 2 {
+		t.Fatalf("Expected at most 2 nodes to update at once, saw %d", peak)
+	}
+	if peak < 2 {
+		t.Fatalf("Expected nodes to update in batches of 2, saw at most %d at once", peak)
+	}
+
+	for node, previous := range nodes {
+		workloads := nexus.workloads(node)
+		if len(workloads) != 1 || workloads[0] == previous {
+			t.Fatalf("Expected %s to run only its new workload, got %v", node, workloads)
+		}
+	}
+}
+
+func TestRolloutStopsWorkloadsThatDoNotBecomeReady(t *testing.T) {
+	nodes := map[string]string{"good": "old-good", "bad": "old-bad"}
+	nexus, client := startFakeNexus(t, nodes, "bad")
+	deploy, stop := testRolloutFactories()
+
+	rollout, err := client.NewRollout(rolloutTargetsFor(nodes, "bad", "good"), RolloutStrategy{MaxSurge: 1, ReadyTimeout: 300 * time.Millisecond}, deploy, stop)
+	if err != nil {
+		t.Fatalf("Failed to create rollout: %s", err)
+	}
+
+	status, err := rollout.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected the rollout to run to completion: %s", err)
+	}
+	if status.Completed != 1 || status.Failed != 1 || !strings.HasPrefix(status.LastError, "bad:") {
+		t.Fatalf("Expected one update to fail on the bad node, got %+v", status)
+	}
+
+	// in surge mode the previous workload keeps serving while the failed one is stopped
+	workloads := nexus.workloads("bad")
+	if len(workloads) != 1 || workloads[0] != "old-bad" {
+		t.Fatalf("Expected only the previous workload to remain on the bad node, got %v", workloads)
+	}
+	if nexus.wasStopped("old-bad") {
+		t.Fatal("Expected the previous workload on the bad node not to be stopped")
+	}
+
+	workloads = nexus.workloads("good")
+	if len(workloads) != 1 || workloads[0] == "old-good" {
+		t.Fatalf("Expected the good node to run only its new workload, got %v", workloads)
+	}
+}
+
+func TestRolloutPausesAtFailureThreshold(t *testing.T) {
+	nodes := map[string]string{"bad": "old-bad", "next": "old-next"}
+	nexus, client := startFakeNexus(t, nodes, "bad")
+	deploy, stop := testRolloutFactories()
+
+	rollout, err := client.NewRollout(rolloutTargetsFor(nodes, "bad", "next"), RolloutStrategy{
+		MaxSurge:         1,
+		FailureThreshold: 1,
+		ReadyTimeout:     300 * time.Millisecond,
+	}, deploy, stop)
+	if err != nil {
+		t.Fatalf("Failed to create rollout: %s", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := rollout.Run(context.Background())
+		done <- err
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for rollout.Status().State != RolloutStatePaused {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the rollout to pause, got %+v", rollout.Status())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// the status is also served to other clients
+	status, err := client.RolloutStatus(context.Background(), rollout.ID())
+	if err != nil {
+		t.Fatalf("Failed to request rollout status: %s", err)
+	}
+	if status.State != RolloutStatePaused || status.Failed != 1 {
+		t.Fatalf("Expected a paused rollout with one failure, got %+v", status)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if workloads := nexus.workloads("next"); len(workloads) != 1 || workloads[0] != "old-next" {
+		t.Fatalf("Expected no further nodes to be updated while paused, got %v", workloads)
+	}
+
+	_, err = client.AbortRollout(context.Background(), rollout.ID())
+	if err != nil {
+		t.Fatalf("Failed to abort rollout: %s", err)
+	}
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Expected an aborted rollout to return an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the rollout to return once aborted")
+	}
+
+	if rollout.Status().State != RolloutStateAborted {
+		t.Fatalf("Expected the rollout to be aborted, got %s", rollout.Status().State)
+	}
+}
+
+func TestNewRolloutValidatesStrategy(t *testing.T) {
+	client := &Client{}
+	deploy, stop := testRolloutFactories()
+
+	_, err := client.NewRollout(nil, RolloutStrategy{}, deploy, stop)
+	if err == nil {
+		t.Fatal("Expected a strategy without surge or unavailability to be rejected")
+	}
+
+	_, err = client.NewRollout(nil, RolloutStrategy{MaxSurge: 1}, deploy, nil)
+	if err == nil {
+		t.Fatal("Expected a rollout without a stop request factory to be rejected")
+	}
+}
@@ -0,0 +1,36 @@
+package controlapi
+
+import "time"
+
+// Asks a node to move its agents onto a new rootfs image. The image is fetched from the location,
+// either oci://<registry>/<repository>(:<tag>|@<digest>) or nats://<bucket>/<object>, and verified
+// against its digest. The node first recycles its pending agents onto the new image, then replaces
+// its running workloads one at a time, pausing RecycleInterval between each. Essential workloads
+// are always recycled, other long-running workloads only if RecycleNonEssential is set; jobs are
+// left to run to completion on the image they started on
+type RootfsRolloutRequest struct {
+	Location string `json:"location"`
+	Sha256   string `json:"sha256"`
+	Version  string `json:"version,omitempty"`
+
+	RecycleInterval     time.Duration `json:"recycle_interval,omitempty"`
+	RecycleNonEssential bool          `json:"recycle_non_essential,omitempty"`
+}
+
+// Progress of a node's rootfs rollout. Total counts the running workloads considered for recycling
+// when the pending agents had been drained; each of them ends up recycled, failed or retained
+type RootfsRolloutStatus struct {
+	Id             string       `json:"id"`
+	NodeId         string       `json:"node_id"`
+	State          RolloutState `json:"state"`
+	Version        string       `json:"version,omitempty"`
+	Sha256         string       `json:"sha256"`
+	AgentsRecycled int          `json:"agents_recycled"`
+	Total          int          `json:"total"`
+	Recycled       int          `json:"recycled"`
+	Failed         int          `json:"failed"`
+	Retained       int          `json:"retained"`
+	LastError      string       `json:"last_error,omitempty"`
+	StartedAt      time.Time    `json:"started_at"`
+	CompletedAt    *time.Time   `json:"completed_at,omitempty"`
+}
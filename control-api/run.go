@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"path"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/nats-io/jwt/v2"
@@ -20,6 +22,10 @@ type DeployRequest struct {
 	Location     *url.URL    `json:"location"`
 	Essential    *bool       `json:"essential,omitempty"`
 
+	// Per-architecture artifacts keyed by GOARCH, e.g. amd64 or arm64. The node deploys the
+	// artifact matching its nex.arch tag, falling back to Location when there is none
+	ArchLocations map[string]*url.URL `json:"arch_locations,omitempty"`
+
 	// Contains claims for the workload: name, hash
 	WorkloadJwt *string `json:"workload_jwt"`
 
@@ -38,10 +44,127 @@ type DeployRequest struct {
 
 	HostServicesConfig *HostServicesConfiguration `json:"host_services,omitempty"`
 
+	// Resource limits applied to the workload, where supported by its workload type
+	Resources *WorkloadResources `json:"resources,omitempty"`
+
+	// Budgets limiting the workload's use of host services, keyed by service name (e.g. "kv"),
+	// or by AllHostServices for a budget shared across every service
+	HostServicesBudgets map[string]HostServiceBudget `json:"host_services_budgets,omitempty"`
+
+	// When true, the node may hold this request in its deploy queue until an agent
+	// becomes available rather than rejecting it outright
+	Queueable *bool `json:"queueable,omitempty"`
+
+	// Name of the group this workload belongs to. Workloads deployed by the same issuer into the
+	// same group can be stopped, restarted and queried together
+	Group *string `json:"group,omitempty"`
+
+	// Arbitrary key/value pairs identifying the workload, e.g. for selecting workloads to stop
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// How long the workload is given to exit after being asked to stop, before it is killed
+	StopGracePeriodMillis int64 `json:"stop_grace_period_ms,omitempty"`
+
+	// Overrides the node's defaults for the pool of workers executing the workload's triggers
+	TriggerWorkers *TriggerWorkerPool `json:"trigger_workers,omitempty"`
+
+	// When set, the node undeploys the workload once it has been running for this long
+	TTLMillis int64 `json:"ttl_ms,omitempty"`
+
+	// Payload delivered to a native or job workload when it starts
+	Input *WorkloadInput `json:"input,omitempty"`
+
+	// Objects materialized read-only inside the sandbox before the workload starts
+	Mounts []WorkloadMount `json:"mounts,omitempty"`
+
+	// Number of GPUs assigned exclusively to the workload. Only no sandbox nodes can expose GPUs,
+	// as Firecracker does not support device passthrough
+	GPUs int `json:"gpus,omitempty"`
+
+	// Lets the issuer swap the script or module of a running v8 or wasm workload through hot
+	// reload requests. Meant for development only
+	HotReload bool `json:"hot_reload,omitempty"`
+
+	// Persistent volume attached to a native or OCI workload, kept by the node across restarts
+	Volume *WorkloadVolume `json:"volume,omitempty"`
+
+	// Serves a v8 or wasm function as a NATS service, in addition to or instead of its triggers
+	Service *NatsService `json:"service,omitempty"`
+
+	// Answers the function's repeated triggers from the node's result cache
+	ResultCache *ResultCachePolicy `json:"result_cache,omitempty"`
+
+	// Triggers the function once before it starts running, rolling the deployment back if it fails
+	Init *WorkloadInit `json:"init,omitempty"`
+
 	WorkloadEnvironment map[string]string `json:"-"`
 	DecodedClaims       jwt.GenericClaims `json:"-"`
 }
 
+// Initial payload for a workload, given either inline or as a reference to an object store, e.g.
+// nats://BUCKET/key. The payload is piped to the workload's stdin unless a path is given, in which
+// case it is written to that path inside the sandbox before the workload starts
+type WorkloadInput struct {
+	Data     []byte   `json:"data,omitempty"`
+	Location *url.URL `json:"location,omitempty"`
+	Path     string   `json:"path,omitempty"`
+}
+
+// An object store object, referenced as nats://BUCKET/key, written read-only to an absolute path
+// inside the sandbox. When a SHA-256 digest is given, the deployment fails unless the object
+// matches it
+type WorkloadMount struct {
+	Location *url.URL `json:"location"`
+	Path     string   `json:"path"`
+	Sha256   string   `json:"sha256,omitempty"`
+}
+
+type WorkloadResources struct {
+	// Upper bound on the memory the workload may use; e.g., the JVM heap is derived from this
+	MemoryMib int `json:"memory_mib,omitempty"`
+
+	// Isolate pooling and recycling for v8 functions; defaults apply when omitted
+	Isolates *IsolatePoolConfig `json:"isolates,omitempty"`
+}
+
+type IsolatePoolConfig struct {
+	// Maximum number of isolates kept warm, which also bounds concurrent invocations
+	PoolSize int `json:"pool_size,omitempty"`
+	// Heap limit enforced on each isolate; defaults to the workload's memory limit when set
+	HeapLimitMib int `json:"heap_limit_mib,omitempty"`
+	// Isolates are recycled after serving this many invocations
+	MaxInvocations int `json:"max_invocations,omitempty"`
+	// Isolates are recycled once their heap has grown to this size
+	MaxAllocatedMib int `json:"max_allocated_mib,omitempty"`
+}
+
+// Bounds the node-side execution of a function's trigger messages. Zero values fall back to
+// the node's defaults
+type TriggerWorkerPool struct {
+	// Number of trigger messages executed concurrently
+	Workers int `json:"workers,omitempty"`
+	// Number of trigger messages held while every worker is busy
+	QueueSize int `json:"queue_size,omitempty"`
+	// Drop trigger messages that arrive while the queue is full rather than waiting for room,
+	// which would otherwise hold up delivery of the workload's later messages
+	ShedWhenFull *bool `json:"shed_when_full,omitempty"`
+}
+
+// Key of a host services budget that applies across every host service
+const AllHostServices = "*"
+
+// Budget enforced by the node on a workload's host service calls. Calls are admitted at a
+// sustained rate with room for bursts, and optionally draw down a fixed number of credits
+// over the lifetime of the workload. Zero values leave the corresponding limit unenforced
+type HostServiceBudget struct {
+	// Sustained number of calls admitted per second
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	// Number of calls that may be made in a burst above the sustained rate
+	Burst int `json:"burst,omitempty"`
+	// Total number of calls the workload may make; each call costs one credit
+	Credits int64 `json:"credits,omitempty"`
+}
+
 type HostServicesConfiguration struct {
 	NatsUrl      string `json:"nats_url"`
 	NatsUserJwt  string `json:"nats_user_jwt"`
@@ -50,6 +173,8 @@ type HostServicesConfiguration struct {
 
 var (
 	validWorkloadName = regexp.MustCompile(`^[a-z]+$`)
+	validGroupName    = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	validSha256       = regexp.MustCompile(`^[a-fA-F0-9]{64}$`)
 )
 
 // Creates a new deploy request based on the supplied options. Note that there is a fluent API function
@@ -79,6 +204,7 @@ func NewDeployRequest(opts ...RequestOption) (*DeployRequest, error) {
 		Description:        &reqOpts.workloadDescription,
 		WorkloadType:       reqOpts.workloadType,
 		Location:           &reqOpts.location,
+		ArchLocations:      reqOpts.archLocations,
 		WorkloadJwt:        &workloadJwt,
 		Environment:        &encryptedEnv,
 		Essential:          &reqOpts.essential,
@@ -87,6 +213,26 @@ func NewDeployRequest(opts ...RequestOption) (*DeployRequest, error) {
 		TriggerSubjects:    reqOpts.triggerSubjects,
 		JsDomain:           &reqOpts.jsDomain,
 		HostServicesConfig: reqOpts.hostServicesConfiguration,
+		Queueable:          &reqOpts.queueable,
+		Resources:          reqOpts.resources,
+
+		HostServicesBudgets:   reqOpts.hostServicesBudgets,
+		StopGracePeriodMillis: reqOpts.stopGracePeriod.Milliseconds(),
+		Labels:                reqOpts.labels,
+		TriggerWorkers:        reqOpts.triggerWorkers,
+		TTLMillis:             reqOpts.ttl.Milliseconds(),
+		Input:                 reqOpts.input,
+		Mounts:                reqOpts.mounts,
+		GPUs:                  reqOpts.gpus,
+		HotReload:             reqOpts.hotReload,
+		Volume:                reqOpts.volume,
+		Service:               reqOpts.service,
+		ResultCache:           reqOpts.resultCache,
+		Init:                  reqOpts.workloadInit,
+	}
+
+	if reqOpts.group != "" {
+		req.Group = &reqOpts.group
 	}
 
 	return req, nil
@@ -111,9 +257,166 @@ func (request *DeployRequest) Validate() (*jwt.GenericClaims, error) {
 		return nil, errors.New("standard claims within JWT are not valid")
 	}
 
+	for key := range request.Labels {
+		if key == "" {
+			return nil, fmt.Errorf("workload labels must not have empty keys")
+		}
+	}
+
+	if request.StopGracePeriodMillis < 0 {
+		return nil, fmt.Errorf("stop grace period must not be negative")
+	}
+
+	if request.TTLMillis < 0 {
+		return nil, fmt.Errorf("workload ttl must not be negative")
+	}
+
+	if request.GPUs < 0 {
+		return nil, fmt.Errorf("workload gpu count must not be negative")
+	}
+
+	if request.HotReload && request.WorkloadType != NexWorkloadV8 && request.WorkloadType != NexWorkloadWasm {
+		return nil, fmt.Errorf("hot reload is only supported for %s and %s workloads", NexWorkloadV8, NexWorkloadWasm)
+	}
+
+	if request.Volume != nil {
+		if request.WorkloadType != NexWorkloadNative && request.WorkloadType != NexWorkloadOCI {
+			return nil, fmt.Errorf("volumes are only supported for %s and %s workloads", NexWorkloadNative, NexWorkloadOCI)
+		}
+
+		err = request.Volume.validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Service != nil {
+		if request.WorkloadType != NexWorkloadV8 && request.WorkloadType != NexWorkloadWasm {
+			return nil, fmt.Errorf("nats services are only supported for %s and %s workloads", NexWorkloadV8, NexWorkloadWasm)
+		}
+
+		err = request.Service.validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if request.ResultCache != nil {
+		if len(request.TriggerSubjects) == 0 && request.Service == nil {
+			return nil, errors.New("result caching requires trigger subjects or a nats service")
+		}
+
+		err = request.ResultCache.validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Init != nil {
+		if len(request.TriggerSubjects) == 0 && request.Service == nil {
+			return nil, errors.New("workload init requires trigger subjects or a nats service")
+		}
+
+		err = request.Init.validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Input != nil {
+		err = request.Input.validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for arch, location := range request.ArchLocations {
+		if arch == "" {
+			return nil, errors.New("workload architecture artifacts must not have empty architectures")
+		}
+		if location == nil || location.Scheme != "nats" {
+			return nil, fmt.Errorf("workload artifact location for %s ('%s') must be a nats://BUCKET/key object store reference", arch, location)
+		}
+	}
+
+	mountPaths := make(map[string]bool)
+	for _, mount := range request.Mounts {
+		err = mount.validate()
+		if err != nil {
+			return nil, err
+		}
+
+		if mountPaths[path.Clean(mount.Path)] {
+			return nil, fmt.Errorf("workload mount path ('%s') must not be used more than once", mount.Path)
+		}
+		mountPaths[path.Clean(mount.Path)] = true
+	}
+
+	if request.TriggerWorkers != nil && (request.TriggerWorkers.Workers < 0 || request.TriggerWorkers.QueueSize < 0) {
+		return nil, fmt.Errorf("trigger worker pool must not contain negative sizes")
+	}
+
+	if request.Group != nil && !validGroupName.MatchString(*request.Group) {
+		return nil, fmt.Errorf("workload group ('%s') must contain only letters, digits, dashes and underscores", *request.Group)
+	}
+
+	for service, budget := range request.HostServicesBudgets {
+		if budget.RequestsPerSecond < 0 || budget.Burst < 0 || budget.Credits < 0 {
+			return nil, fmt.Errorf("host services budget for %s must not contain negative limits", service)
+		}
+	}
+
 	return claims, nil
 }
 
+// Returns the location of the artifact to deploy on a node of the given architecture, which is nil
+// if the request provides no artifact suitable for it
+func (request *DeployRequest) LocationForArch(arch string) *url.URL {
+	for candidate, location := range request.ArchLocations {
+		if strings.EqualFold(candidate, arch) {
+			return location
+		}
+	}
+
+	if request.Location == nil || request.Location.Host == "" {
+		return nil
+	}
+
+	return request.Location
+}
+
+func (input *WorkloadInput) validate() error {
+	if input.Location != nil && len(input.Data) > 0 {
+		return errors.New("workload input must not contain both inline data and a location")
+	}
+
+	if input.Location != nil && input.Location.Scheme != "nats" {
+		return fmt.Errorf("workload input location ('%s') must be a nats://BUCKET/key object store reference", input.Location)
+	}
+
+	if input.Path != "" && !path.IsAbs(input.Path) {
+		return fmt.Errorf("workload input path ('%s') must be absolute", input.Path)
+	}
+
+	return nil
+}
+
+func (mount *WorkloadMount) validate() error {
+	if mount.Location == nil || mount.Location.Scheme != "nats" {
+		return fmt.Errorf("workload mount location ('%s') must be a nats://BUCKET/key object store reference", mount.Location)
+	}
+
+	if !path.IsAbs(mount.Path) {
+		return fmt.Errorf("workload mount path ('%s') must be absolute", mount.Path)
+	}
+
+	if mount.Sha256 != "" && !validSha256.MatchString(mount.Sha256) {
+		return fmt.Errorf("workload mount sha256 ('%s') must be a hex-encoded SHA-256 digest", mount.Sha256)
+	}
+
+	return nil
+}
+
 func CreateWorkloadJwt(hash string, name string, issuer nkeys.KeyPair) (string, error) {
 	genericClaims := jwt.NewGenericClaims(name)
 	genericClaims.Data["hash"] = hash
@@ -157,6 +460,7 @@ type requestOptions struct {
 	workloadType              NexWorkload
 	workloadDescription       string
 	location                  url.URL
+	archLocations             map[string]*url.URL
 	env                       map[string]string
 	essential                 bool
 	senderXkey                nkeys.KeyPair
@@ -167,6 +471,22 @@ type requestOptions struct {
 	targetNode                string
 	triggerSubjects           []string
 	hostServicesConfiguration *HostServicesConfiguration
+	queueable                 bool
+	resources                 *WorkloadResources
+	hostServicesBudgets       map[string]HostServiceBudget
+	group                     string
+	stopGracePeriod           time.Duration
+	labels                    map[string]string
+	triggerWorkers            *TriggerWorkerPool
+	ttl                       time.Duration
+	input                     *WorkloadInput
+	mounts                    []WorkloadMount
+	gpus                      int
+	hotReload                 bool
+	volume                    *WorkloadVolume
+	service                   *NatsService
+	resultCache               *ResultCachePolicy
+	workloadInit              *WorkloadInit
 }
 
 type RequestOption func(o requestOptions) requestOptions
@@ -186,6 +506,18 @@ func HostServicesConfig(config HostServicesConfiguration) RequestOption {
 	}
 }
 
+// Budget limiting the workload's use of the named host service; use AllHostServices for a
+// budget shared across every service
+func HostServiceBudgetFor(service string, budget HostServiceBudget) RequestOption {
+	return func(o requestOptions) requestOptions {
+		if o.hostServicesBudgets == nil {
+			o.hostServicesBudgets = make(map[string]HostServiceBudget)
+		}
+		o.hostServicesBudgets[service] = budget
+		return o
+	}
+}
+
 // Name of the workload. Conforms to the same name rules as the services API
 func WorkloadName(name string) RequestOption {
 	return func(o requestOptions) requestOptions {
@@ -194,7 +526,15 @@ func WorkloadName(name string) RequestOption {
 	}
 }
 
-// Type of the workload, e.g., one of "native", "v8", "oci", "wasm" for this request
+// Resource limits to apply to the workload
+func Resources(resources WorkloadResources) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.resources = &resources
+		return o
+	}
+}
+
+// Type of the workload, e.g., one of "native", "v8", "oci", "wasm", "jvm" for this request
 func WorkloadType(workloadType NexWorkload) RequestOption {
 	return func(o requestOptions) requestOptions {
 		o.workloadType = workloadType
@@ -231,6 +571,22 @@ func Location(workloadUrl string) RequestOption {
 	}
 }
 
+// Location of the workload artifact for nodes of the given architecture, e.g. arm64. A node
+// without a matching artifact deploys the one given by Location
+func ArchLocation(arch string, workloadUrl string) RequestOption {
+	return func(o requestOptions) requestOptions {
+		nurl, err := url.Parse(workloadUrl)
+		if err != nil {
+			nurl = &url.URL{}
+		}
+		if o.archLocations == nil {
+			o.archLocations = make(map[string]*url.URL)
+		}
+		o.archLocations[arch] = nurl
+		return o
+	}
+}
+
 // Description of the workload to run
 func WorkloadDescription(name string) RequestOption {
 	return func(o requestOptions) requestOptions {
@@ -239,7 +595,8 @@ func WorkloadDescription(name string) RequestOption {
 	}
 }
 
-// Set the map of environment variables to be used by the workload
+// Set the map of environment variables to be used by the workload. Values may reference
+// {{node.id}}, {{workload.id}}, {{namespace}} and {{nexus}}, which the node resolves at deploy time
 func Environment(env map[string]string) RequestOption {
 	return func(o requestOptions) requestOptions {
 		o.env = env
@@ -255,6 +612,123 @@ func Essential(essential bool) RequestOption {
 	}
 }
 
+// Group the workload belongs to, so it can be managed together with related workloads
+func Group(group string) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.group = group
+		return o
+	}
+}
+
+// Labels identifying the workload, which can be used to select workloads in bulk operations
+func Labels(labels map[string]string) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.labels = labels
+		return o
+	}
+}
+
+// How long the workload is given to exit cleanly when stopped before it is forcibly terminated
+func StopGracePeriod(gracePeriod time.Duration) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.stopGracePeriod = gracePeriod
+		return o
+	}
+}
+
+// Automatically undeploys the workload once it has been running for the given duration
+func TTL(ttl time.Duration) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.ttl = ttl
+		return o
+	}
+}
+
+// Payload piped to the workload's stdin, or written to the given path when it is not empty
+func InputData(data []byte, path string) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.input = &WorkloadInput{Data: data, Path: path}
+		return o
+	}
+}
+
+// Object store reference, nats://BUCKET/key, to a payload piped to the workload's stdin, or
+// written to the given path when it is not empty
+func InputLocation(inputUrl string, path string) RequestOption {
+	return func(o requestOptions) requestOptions {
+		nurl, err := url.Parse(inputUrl)
+		if err != nil {
+			nurl = &url.URL{}
+		}
+		o.input = &WorkloadInput{Location: nurl, Path: path}
+		return o
+	}
+}
+
+// Materializes the object store object at mountUrl, nats://BUCKET/key, read-only at the given path
+// inside the sandbox. Pass an empty digest to skip verifying the object's SHA-256 hash
+func Mount(mountUrl string, path string, sha256 string) RequestOption {
+	return func(o requestOptions) requestOptions {
+		nurl, err := url.Parse(mountUrl)
+		if err != nil {
+			nurl = &url.URL{}
+		}
+		o.mounts = append(o.mounts, WorkloadMount{Location: nurl, Path: path, Sha256: sha256})
+		return o
+	}
+}
+
+// Number of GPUs to assign exclusively to the workload
+func GPUs(count int) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.gpus = count
+		return o
+	}
+}
+
+// Allows the running workload's script or module to be swapped through hot reload requests. Only
+// v8 and wasm workloads support this, and it is meant for development
+func HotReload(enabled bool) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.hotReload = enabled
+		return o
+	}
+}
+
+// Attaches the named persistent volume to the workload, mounted at the given path inside the
+// sandbox and created with the given size if the node does not have it yet
+func Volume(name string, path string, sizeMib int) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.volume = &WorkloadVolume{Name: name, Path: path, SizeMib: sizeMib}
+		return o
+	}
+}
+
+// Serves the workload as a NATS service, running the function for each request received on the
+// service's endpoints. Only v8 and wasm workloads support this
+func Service(service NatsService) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.service = &service
+		return o
+	}
+}
+
+// Sizes the pool of workers the node uses to execute the workload's trigger messages
+func TriggerWorkers(pool TriggerWorkerPool) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.triggerWorkers = &pool
+		return o
+	}
+}
+
+// Allow the target node to queue this request when no agent is immediately available
+func Queueable(queueable bool) RequestOption {
+	return func(o requestOptions) requestOptions {
+		o.queueable = queueable
+		return o
+	}
+}
+
 // This is the sender's xkey. The public key will be placed on the request while the private key will be used
 // to encrypt the environment variables
 func SenderXKey(xkey nkeys.KeyPair) RequestOption {
@@ -307,3 +781,43 @@ func Checksum(hash string) RequestOption {
 		return o
 	}
 }
+
+// Reason a node or agent gave for rejecting a workload deployment
+type DeployRejectionReason string
+
+const (
+	// Error code carried by responses to deploy requests that were rejected
+	ErrorCodeDeployRejected = "deploy_rejected"
+
+	// The deploy request or the workload itself is invalid; deploying it elsewhere fails too
+	DeployRejectionValidationFailed DeployRejectionReason = "validation_failed"
+	// The node does not run workloads of the requested type, or with the requested features
+	DeployRejectionUnsupportedType DeployRejectionReason = "unsupported_type"
+	// The node lacks the capacity, agents or devices to run the workload right now
+	DeployRejectionResourceExhausted DeployRejectionReason = "resource_exhausted"
+	// The node is cordoned, in lame duck mode or shutting down
+	DeployRejectionNodeUnavailable DeployRejectionReason = "node_unavailable"
+	// Preparing the workload failed, e.g. while fetching its artifact or starting its provider
+	DeployRejectionFailed DeployRejectionReason = "deploy_failed"
+)
+
+// Returned when a node or its agent rejects a workload deployment, describing why so that
+// callers can decide whether to deploy the workload elsewhere
+type DeployRejection struct {
+	Code    string                `json:"code"`
+	Reason  DeployRejectionReason `json:"reason"`
+	Message string                `json:"message"`
+}
+
+func NewDeployRejection(reason DeployRejectionReason, message string) *DeployRejection {
+	return &DeployRejection{Code: ErrorCodeDeployRejected, Reason: reason, Message: message}
+}
+
+func (e *DeployRejection) Error() string {
+	return e.Message
+}
+
+// Reports whether the workload might be deployed successfully on another node
+func (e *DeployRejection) RetryElsewhere() bool {
+	return e.Reason != DeployRejectionValidationFailed
+}
@@ -0,0 +1,50 @@
+package controlapi
+
+import "strings"
+
+// Severities of artifact scan findings, from least to most severe
+const (
+	SeverityInfo     = "info"
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+var severities = []string{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// Returns the rank of a severity, higher being more severe, or -1 for an unknown severity
+func SeverityRank(severity string) int {
+	for i, s := range severities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Sent to an artifact scanner before a workload is deployed. Scanners running as NATS services
+// receive the location of the artifact in its object store, scanner commands additionally
+// receive the path of a local copy of the artifact
+type ArtifactScanRequest struct {
+	Namespace    string      `json:"namespace"`
+	WorkloadName string      `json:"workload_name"`
+	WorkloadType NexWorkload `json:"workload_type"`
+	Sha256       string      `json:"sha256"`
+	Location     string      `json:"location"`
+	JsDomain     string      `json:"js_domain,omitempty"`
+	Path         string      `json:"path,omitempty"`
+}
+
+// Returned by an artifact scanner. A scanner that could not scan the artifact sets Error
+type ArtifactScanResponse struct {
+	Scanner  string            `json:"scanner,omitempty"`
+	Findings []ArtifactFinding `json:"findings"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type ArtifactFinding struct {
+	Id          string `json:"id"`
+	Severity    string `json:"severity"`
+	Description string `json:"description,omitempty"`
+}
@@ -0,0 +1,208 @@
+package controlapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// A node that bid in an auction, along with how long its bid took to arrive
+type Candidate struct {
+	AuctionResponse
+	Latency time.Duration `json:"latency"`
+}
+
+// Scores an auction candidate; candidates with higher total scores are tried first
+type ScoringFunc func(candidate Candidate) float64
+
+// Prefers candidates whose bids arrived sooner, scoring up to weight for an instant reply
+func ScoreLatency(weight float64) ScoringFunc {
+	return func(candidate Candidate) float64 {
+		return weight / (1 + float64(candidate.Latency.Milliseconds()))
+	}
+}
+
+// Prefers candidates with more unallocated memory, scoring weight for every GiB available
+func ScoreFreeMemory(weight float64) ScoringFunc {
+	return func(candidate Candidate) float64 {
+		if candidate.Capacity == nil {
+			return 0
+		}
+
+		return weight * float64(candidate.Capacity.AvailableMemoryMib) / 1024
+	}
+}
+
+// Prefers candidates carrying the given tags, scoring weight for each matching tag
+func ScoreTags(weight float64, preferred map[string]string) ScoringFunc {
+	return func(candidate Candidate) float64 {
+		score := 0.0
+		for tag, value := range preferred {
+			if candidate.Tags[tag] == value {
+				score += weight
+			}
+		}
+
+		return score
+	}
+}
+
+// Produces the deploy request for the given candidate, which must target its node and public xkey
+type ScheduleDeployFactory func(candidate Candidate) (*DeployRequest, error)
+
+// The outcome of scheduling a workload: the node it was placed on and the reasons earlier
+// candidates rejected it, keyed by node ID
+type Placement struct {
+	Candidate Candidate
+	Response  *RunResponse
+	Rejected  map[string]string
+}
+
+// A scheduler places a workload by running an auction, ranking the bids with its scoring
+// functions and deploying to the best candidate, falling back to the next candidate whenever
+// a node rejects the deploy
+type Scheduler struct {
+	api         *Client
+	scorers     []ScoringFunc
+	maxAttempts int
+}
+
+type SchedulerOption func(s *Scheduler)
+
+// Adds scoring functions used to rank auction candidates. Without any, candidates are tried
+// in the order their bids arrived
+func WithScoring(scorers ...ScoringFunc) SchedulerOption {
+	return func(s *Scheduler) {
+		s.scorers = append(s.scorers, scorers...)
+	}
+}
+
+// Limits how many candidates are tried before scheduling fails; 0 tries every candidate
+func WithMaxAttempts(attempts int) SchedulerOption {
+	return func(s *Scheduler) {
+		s.maxAttempts = attempts
+	}
+}
+
+// Creates a scheduler that runs auctions and deploys through this client
+func (api *Client) NewScheduler(opts ...SchedulerOption) *Scheduler {
+	s := &Scheduler{api: api}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Runs an auction and returns its candidates, best first
+func (s *Scheduler) Rank(ctx context.Context, req *AuctionRequest, opts ...CallOption) ([]Candidate, error) {
+	var payload []byte
+	if req != nil {
+		payload, _ = json.Marshal(req)
+	}
+
+	candidates := make([]Candidate, 0)
+	started := time.Now()
+	err := s.api.gather(ctx, fmt.Sprintf("%s.AUCTION", APIPrefix), payload, opts, func(env *Envelope) {
+		latency := time.Since(started)
+
+		var resp AuctionResponse
+		bytes, err := json.Marshal(env.Data)
+		if err != nil {
+			s.api.log.Error("failed to marshal envelope data", slog.Any("err", err))
+			return
+		}
+
+		err = json.Unmarshal(bytes, &resp)
+		if err != nil {
+			s.api.log.Error("failed to unmarshal auction response", slog.Any("err", err))
+			return
+		}
+		candidates = append(candidates, Candidate{AuctionResponse: resp, Latency: latency})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]float64, len(candidates))
+	for _, candidate := range candidates {
+		for _, score := range s.scorers {
+			scores[candidate.NodeId] += score(candidate)
+		}
+	}
+
+	slices.SortStableFunc(candidates, func(a, b Candidate) int {
+		switch {
+		case scores[a.NodeId] > scores[b.NodeId]:
+			return -1
+		case scores[a.NodeId] < scores[b.NodeId]:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return candidates, nil
+}
+
+// Places a workload on the best candidate from an auction. Candidates that reject the deploy
+// are skipped in favor of the next best, unless they rejected the workload itself as invalid.
+// A deploy that times out is not retried elsewhere, since the node may have started the
+// workload. When every candidate rejects the workload, the returned placement still records
+// their reasons
+func (s *Scheduler) Schedule(ctx context.Context, req *AuctionRequest, factory ScheduleDeployFactory, opts ...CallOption) (*Placement, error) {
+	if factory == nil {
+		return nil, errors.New("a deploy request factory is required")
+	}
+
+	candidates, err := s.Rank(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("no nodes bid in the auction")
+	}
+
+	placement := &Placement{Rejected: make(map[string]string)}
+	for i, candidate := range candidates {
+		if s.maxAttempts > 0 && i >= s.maxAttempts {
+			break
+		}
+
+		request, err := factory(candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := s.api.StartWorkload(ctx, request, opts...)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) || ctx.Err() != nil {
+				return nil, fmt.Errorf("deploy to node %s did not complete: %s", candidate.NodeId, err)
+			}
+
+			var rejection *DeployRejection
+			if errors.As(err, &rejection) && !rejection.RetryElsewhere() {
+				return nil, fmt.Errorf("node %s rejected the workload: %w", candidate.NodeId, rejection)
+			}
+
+			placement.Rejected[candidate.NodeId] = err.Error()
+			continue
+		}
+		if !response.Started {
+			placement.Rejected[candidate.NodeId] = "workload was not started"
+			continue
+		}
+
+		placement.Candidate = candidate
+		placement.Response = response
+		return placement, nil
+	}
+
+	return placement, fmt.Errorf("all %d candidates rejected the workload", len(placement.Rejected))
+}
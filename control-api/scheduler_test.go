@@ -0,0 +1,204 @@
+package controlapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Bids in every auction on behalf of each node, then answers deploys: nodes with a rejection
+// reason refuse the workload and silent nodes never reply. Reasons starting with "invalid"
+// reject the workload as invalid, the others for lack of capacity
+type fakeAuction struct {
+	mutex    sync.Mutex
+	deployed []string
+}
+
+func startFakeAuction(t *testing.T, bids []AuctionResponse, rejections map[string]string, silent ...string) (*fakeAuction, *Client) {
+	nc := startTestNats(t)
+	f := &fakeAuction{}
+
+	for _, bid := range bids {
+		bid := bid
+		_, err := nc.Subscribe("$NEX.AUCTION", func(m *nats.Msg) {
+			raw, _ := json.Marshal(NewEnvelope(AuctionResponseType, bid, nil))
+			_ = m.Respond(raw)
+		})
+		if err != nil {
+			t.Fatalf("Failed to subscribe to auctions: %s", err)
+		}
+	}
+
+	_, err := nc.Subscribe("$NEX.DEPLOY.default.*", func(m *nats.Msg) {
+		node := m.Subject[strings.LastIndex(m.Subject, ".")+1:]
+
+		f.mutex.Lock()
+		f.deployed = append(f.deployed, node)
+		f.mutex.Unlock()
+
+		for _, s := range silent {
+			if s == node {
+				return
+			}
+		}
+
+		var raw []byte
+		if reason, ok := rejections[node]; ok {
+			rejection := NewDeployRejection(DeployRejectionResourceExhausted, reason)
+			if strings.HasPrefix(reason, "invalid") {
+				rejection.Reason = DeployRejectionValidationFailed
+			}
+			raw, _ = json.Marshal(Envelope{PayloadType: RunResponseType, Data: RunResponse{Rejection: rejection}, Error: rejection})
+		} else {
+			raw, _ = json.Marshal(NewEnvelope(RunResponseType, RunResponse{Started: true, ID: "w-" + node, Name: "echo"}, nil))
+		}
+		_ = m.Respond(raw)
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe to deploys: %s", err)
+	}
+
+	return f, NewApiClientWithNamespace(nc, 100*time.Millisecond, "default", slog.Default())
+}
+
+func (f *fakeAuction) attempts() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string{}, f.deployed...)
+}
+
+func testScheduleFactory(candidate Candidate) (*DeployRequest, error) {
+	return &DeployRequest{TargetNode: &candidate.NodeId}, nil
+}
+
+func testBids() []AuctionResponse {
+	return []AuctionResponse{
+		{NodeId: "small", Capacity: &NodeCapacity{AvailableMemoryMib: 1024}},
+		{NodeId: "large", Capacity: &NodeCapacity{AvailableMemoryMib: 8192}},
+		{NodeId: "tagged", Capacity: &NodeCapacity{AvailableMemoryMib: 2048}, Tags: map[string]string{"zone": "a"}},
+	}
+}
+
+func TestSchedulerRanksCandidatesByScore(t *testing.T) {
+	_, client := startFakeAuction(t, testBids(), nil)
+
+	candidates, err := client.NewScheduler(WithScoring(ScoreFreeMemory(1))).Rank(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to rank candidates: %s", err)
+	}
+
+	order := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		order = append(order, candidate.NodeId)
+	}
+	if strings.Join(order, ",") != "large,tagged,small" {
+		t.Fatalf("Expected candidates ranked by free memory, got %v", order)
+	}
+
+	candidates, err = client.NewScheduler(
+		WithScoring(ScoreFreeMemory(1), ScoreTags(10, map[string]string{"zone": "a"})),
+	).Rank(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to rank candidates: %s", err)
+	}
+	if candidates[0].NodeId != "tagged" {
+		t.Fatalf("Expected the preferred tag to outweigh free memory, got %s first", candidates[0].NodeId)
+	}
+}
+
+func TestScoringFunctions(t *testing.T) {
+	if ScoreFreeMemory(1)(Candidate{}) != 0 {
+		t.Fatal("Expected a candidate without capacity to score nothing for free memory")
+	}
+	if fast, slow := ScoreLatency(1)(Candidate{Latency: time.Millisecond}), ScoreLatency(1)(Candidate{Latency: time.Second}); fast <= slow {
+		t.Fatalf("Expected a faster bid to score higher, got %f and %f", fast, slow)
+	}
+	if score := ScoreTags(2, map[string]string{"zone": "a", "gpu": "true"})(Candidate{AuctionResponse: AuctionResponse{Tags: map[string]string{"zone": "a"}}}); score != 2 {
+		t.Fatalf("Expected one matching tag to score 2, got %f", score)
+	}
+}
+
+func TestSchedulerFallsBackWhenCandidateRejects(t *testing.T) {
+	auction, client := startFakeAuction(t, testBids(), map[string]string{"large": "no agents available"})
+
+	placement, err := client.NewScheduler(WithScoring(ScoreFreeMemory(1))).Schedule(context.Background(), nil, testScheduleFactory)
+	if err != nil {
+		t.Fatalf("Expected the workload to be placed on the next candidate: %s", err)
+	}
+	if placement.Candidate.NodeId != "tagged" || placement.Response.ID != "w-tagged" {
+		t.Fatalf("Expected the workload on the tagged node, got %+v", placement)
+	}
+	if !strings.Contains(placement.Rejected["large"], "no agents available") {
+		t.Fatalf("Expected the rejection to be recorded, got %v", placement.Rejected)
+	}
+	if attempts := auction.attempts(); len(attempts) != 2 {
+		t.Fatalf("Expected two deploy attempts, got %v", attempts)
+	}
+}
+
+func TestSchedulerReportsEveryRejection(t *testing.T) {
+	auction, client := startFakeAuction(t, testBids(), map[string]string{
+		"small":  "full",
+		"large":  "full",
+		"tagged": "full",
+	})
+
+	placement, err := client.NewScheduler(WithMaxAttempts(2)).Schedule(context.Background(), nil, testScheduleFactory)
+	if err == nil {
+		t.Fatal("Expected scheduling to fail when candidates reject the workload")
+	}
+	if placement == nil || len(placement.Rejected) != 2 {
+		t.Fatalf("Expected the placement to record both rejections, got %+v", placement)
+	}
+	if attempts := auction.attempts(); len(attempts) != 2 {
+		t.Fatalf("Expected the attempts to be capped at 2, got %v", attempts)
+	}
+}
+
+func TestSchedulerDoesNotRetryInvalidWorkload(t *testing.T) {
+	auction, client := startFakeAuction(t, testBids(), map[string]string{"large": "invalid workload jwt"})
+
+	_, err := client.NewScheduler(WithScoring(ScoreFreeMemory(1))).Schedule(context.Background(), nil, testScheduleFactory)
+
+	var rejection *DeployRejection
+	if !errors.As(err, &rejection) || rejection.Reason != DeployRejectionValidationFailed {
+		t.Fatalf("Expected the validation failure to be returned, got %v", err)
+	}
+	if attempts := auction.attempts(); len(attempts) != 1 {
+		t.Fatalf("Expected no other candidate to be tried for an invalid workload, got %v", attempts)
+	}
+}
+
+func TestSchedulerDoesNotRetryTimedOutDeploy(t *testing.T) {
+	auction, client := startFakeAuction(t, testBids(), nil, "large")
+
+	_, err := client.NewScheduler(WithScoring(ScoreFreeMemory(1))).Schedule(context.Background(), nil, testScheduleFactory)
+	if err == nil || !strings.Contains(err.Error(), "large") {
+		t.Fatalf("Expected the timed out deploy to be reported, got %v", err)
+	}
+	if attempts := auction.attempts(); len(attempts) != 1 {
+		t.Fatalf("Expected no other candidate to be tried after a timeout, got %v", attempts)
+	}
+}
+
+func TestSchedulerRequiresBids(t *testing.T) {
+	_, client := startFakeAuction(t, nil, nil)
+
+	_, err := client.NewScheduler().Schedule(context.Background(), nil, testScheduleFactory)
+	if err == nil {
+		t.Fatal("Expected scheduling to fail without any bids")
+	}
+
+	_, err = client.NewScheduler().Schedule(context.Background(), nil, nil)
+	if err == nil {
+		t.Fatal("Expected scheduling to fail without a deploy request factory")
+	}
+}
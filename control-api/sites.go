@@ -0,0 +1,53 @@
+package controlapi
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Round trip of a response received now to a request sent at the given time, less the time the
+// node held its reply
+func roundTrip(sent time.Time, replyDelayMillis int64) time.Duration {
+	elapsed := time.Since(sent) - time.Duration(replyDelayMillis)*time.Millisecond
+	if elapsed < 0 {
+		return 0
+	}
+	return elapsed
+}
+
+// Orders nodes nearest first, by the round trip measured when they were discovered. Nodes in a
+// site reached through leaf node connections come after those closer to the client
+func SortByRoundTrip(nodes []PingResponse) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].RoundTrip < nodes[j].RoundTrip
+	})
+}
+
+// Reports whether the site is one of the given sites, which are compared case insensitively
+func InSites(site string, sites []string) bool {
+	return slices.ContainsFunc(sites, func(s string) bool {
+		return strings.EqualFold(s, site)
+	})
+}
+
+// Discovers the nodes in any of the given sites, nearest first
+func (api *Client) SiteNodes(ctx context.Context, sites []string, opts ...CallOption) ([]PingResponse, error) {
+	discovered, err := api.DiscoverNodes(ctx, &DiscoverRequest{AuctionRequest: AuctionRequest{Sites: sites}}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]PingResponse, 0)
+	for node := range discovered {
+		// older nodes ignore the site filter
+		if InSites(node.Site(), sites) {
+			nodes = append(nodes, node)
+		}
+	}
+	SortByRoundTrip(nodes)
+
+	return nodes, nil
+}
@@ -0,0 +1,35 @@
+package controlapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoundTripExcludesReplyDelay(t *testing.T) {
+	sent := time.Now().Add(-300 * time.Millisecond)
+
+	measured := roundTrip(sent, 200)
+	if measured < 100*time.Millisecond || measured > 200*time.Millisecond {
+		t.Fatalf("Expected the reply delay to be subtracted, got %s", measured)
+	}
+
+	if roundTrip(time.Now(), 1000) != 0 {
+		t.Fatal("Expected a delay longer than the elapsed time not to give a negative round trip")
+	}
+}
+
+func TestSortByRoundTrip(t *testing.T) {
+	nodes := []PingResponse{
+		{NodeId: "edge", RoundTrip: 80 * time.Millisecond, Tags: map[string]string{TagSite: "edge-1"}},
+		{NodeId: "hub", RoundTrip: 2 * time.Millisecond, Tags: map[string]string{TagSite: "hub"}},
+	}
+
+	SortByRoundTrip(nodes)
+	if nodes[0].NodeId != "hub" || nodes[1].Site() != "edge-1" {
+		t.Fatalf("Expected the nearest node first, got %+v", nodes)
+	}
+
+	if !InSites("Edge-1", []string{"hub", "edge-1"}) || InSites("", []string{"hub"}) {
+		t.Fatal("Expected sites to be compared case insensitively")
+	}
+}
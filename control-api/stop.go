@@ -2,11 +2,37 @@ package controlapi
 
 import (
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/nats-io/jwt/v2"
 	"github.com/nats-io/nkeys"
 )
 
+const (
+	// Error code carried by responses to requests whose sender is not allowed to make them
+	ErrorCodeUnauthorized = "unauthorized"
+
+	// How long the claims of a stop request remain valid after being issued
+	StopClaimsLifetime = 5 * time.Minute
+
+	stopClaimsWorkloadIdField = "workload_id"
+)
+
+// Returned when a request is not signed by an entity allowed to act on the target workload
+type AuthorizationError struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+func NewAuthorizationError(reason string) *AuthorizationError {
+	return &AuthorizationError{Code: ErrorCodeUnauthorized, Reason: reason}
+}
+
+func (e *AuthorizationError) Error() string {
+	return fmt.Sprintf("not authorized: %s", e.Reason)
+}
+
 type StopRequest struct {
 	WorkloadId  string `json:"workload_id"`
 	WorkloadJwt string `json:"workload_jwt"`
@@ -22,6 +48,8 @@ type StopResponse struct {
 
 func NewStopRequest(workloadId string, name string, targetNode string, issuer nkeys.KeyPair) (*StopRequest, error) {
 	claims := jwt.NewGenericClaims(name)
+	claims.Expires = time.Now().Add(StopClaimsLifetime).Unix()
+	claims.Data[stopClaimsWorkloadIdField] = workloadId
 	jwtText, err := claims.Encode(issuer)
 	if err != nil {
 		return nil, err
@@ -34,20 +62,35 @@ func NewStopRequest(workloadId string, name string, targetNode string, issuer nk
 	}, nil
 }
 
-func (request *StopRequest) Validate(originalClaims *jwt.GenericClaims) error {
+// Verifies that the request is signed by the issuer that originally started the workload, or by
+// one of the given admin keys. Every failure is reported as an *AuthorizationError
+func (request *StopRequest) Validate(originalClaims *jwt.GenericClaims, adminKeys ...string) error {
 	claims, err := jwt.DecodeGeneric(request.WorkloadJwt)
 	if err != nil {
-		return fmt.Errorf("could not decode workload JWT: %s", err)
+		return NewAuthorizationError(fmt.Sprintf("could not verify stop claims: %s", err))
+	}
+	if claims.Expires == 0 {
+		return NewAuthorizationError("stop claims must carry an expiry")
+	}
+	if time.Now().Unix() > claims.Expires {
+		return NewAuthorizationError("stop claims have expired")
 	}
 	if claims.ID == originalClaims.ID ||
 		claims.IssuedAt == originalClaims.IssuedAt {
-		return fmt.Errorf("stop claims appear to be cloned or captured from the original start claims. Rejecting for security reasons")
+		return NewAuthorizationError("stop claims appear to be cloned or captured from the original start claims. Rejecting for security reasons")
 	}
 	if claims.Subject != originalClaims.Subject {
-		return fmt.Errorf("stop claims subject does not match original start claims subject")
+		return NewAuthorizationError("stop claims subject does not match original start claims subject")
+	}
+	workloadId, ok := claims.Data[stopClaimsWorkloadIdField]
+	if !ok {
+		return NewAuthorizationError("stop claims do not name the workload to stop")
+	}
+	if workloadId != request.WorkloadId {
+		return NewAuthorizationError("stop claims were issued for a different workload")
 	}
-	if claims.Issuer != originalClaims.Issuer {
-		return fmt.Errorf("the only entity allowed to terminate a workload is the issuer that originally started it")
+	if claims.Issuer != originalClaims.Issuer && !slices.Contains(adminKeys, claims.Issuer) {
+		return NewAuthorizationError("the only entities allowed to terminate a workload are the issuer that originally started it and the node's admin keys")
 	}
 
 	return nil
@@ -0,0 +1,25 @@
+package controlapi
+
+type TriggerSubscriptionsResponse struct {
+	NodeId    string                         `json:"node_id"`
+	Workloads []WorkloadTriggerSubscriptions `json:"workloads"`
+}
+
+// The trigger subject subscriptions a node holds on behalf of a single workload
+type WorkloadTriggerSubscriptions struct {
+	WorkloadId    string                `json:"workload_id"`
+	WorkloadName  string                `json:"workload_name"`
+	Subscriptions []TriggerSubscription `json:"subscriptions"`
+}
+
+// A trigger subject subscription as seen by the node's NATS client. A subscription that is no
+// longer valid has been closed by the server or lost with its connection, and is removed by the
+// node's subscription janitor
+type TriggerSubscription struct {
+	Subject         string `json:"subject"`
+	Queue           string `json:"queue,omitempty"`
+	Valid           bool   `json:"valid"`
+	PendingMessages int    `json:"pending_messages"`
+	Delivered       int64  `json:"delivered"`
+	Dropped         int    `json:"dropped"`
+}
@@ -0,0 +1,14 @@
+package controlapi
+
+// Changes a node's tags at runtime. Tags in Set are added or replaced, then those in Remove are
+// removed. The node persists the changes so they survive restarts; tags with the nex. prefix are
+// managed by the node and cannot be changed
+type NodeTagsRequest struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+type NodeTagsResponse struct {
+	NodeId string            `json:"node_id"`
+	Tags   map[string]string `json:"tags"`
+}
@@ -1,7 +1,10 @@
 package controlapi
 
 import (
+	"fmt"
 	"log/slog"
+	"strings"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go"
 )
@@ -11,18 +14,54 @@ const (
 )
 
 const (
-	AuctionResponseType  = "io.nats.nex.v1.auction_response"
-	InfoResponseType     = "io.nats.nex.v1.info_response"
-	PingResponseType     = "io.nats.nex.v1.ping_response"
-	RunResponseType      = "io.nats.nex.v1.run_response"
-	StopResponseType     = "io.nats.nex.v1.stop_response"
-	LameDuckResponseType = "io.nats.nex.v1.lameduck_response"
+	AuctionResponseType       = "io.nats.nex.v1.auction_response"
+	BulkStopResponseType      = "io.nats.nex.v1.bulk_stop_response"
+	CancelDeployResponseType  = "io.nats.nex.v1.cancel_deploy_response"
+	CheckpointResponseType    = "io.nats.nex.v1.checkpoint_response"
+	CordonResponseType        = "io.nats.nex.v1.cordon_response"
+	CutoverResponseType       = "io.nats.nex.v1.cutover_response"
+	EvacuateResponseType      = "io.nats.nex.v1.evacuate_response"
+	DeployQueuedResponseType  = "io.nats.nex.v1.deploy_queued_response"
+	DiagnosticsResponseType   = "io.nats.nex.v1.diagnostics_response"
+	ExecHistoryResponseType   = "io.nats.nex.v1.exec_history_response"
+	FaultsResponseType        = "io.nats.nex.v1.faults_response"
+	GroupResponseType         = "io.nats.nex.v1.group_response"
+	HotReloadResponseType     = "io.nats.nex.v1.hot_reload_response"
+	InfoResponseType          = "io.nats.nex.v1.info_response"
+	NexusInfoResponseType     = "io.nats.nex.v1.nexus_info_response"
+	JobStatusResponseType     = "io.nats.nex.v1.job_status_response"
+	QuarantineResponseType    = "io.nats.nex.v1.quarantine_response"
+	NamespaceResponseType     = "io.nats.nex.v1.namespace_response"
+	NodeStateResponseType     = "io.nats.nex.v1.node_state_response"
+	PauseResponseType         = "io.nats.nex.v1.pause_response"
+	PingResponseType          = "io.nats.nex.v1.ping_response"
+	ReloadResponseType        = "io.nats.nex.v1.reload_response"
+	ResourceResponseType      = "io.nats.nex.v1.resource_response"
+	RestoreResponseType       = "io.nats.nex.v1.restore_response"
+	RolloutResponseType       = "io.nats.nex.v1.rollout_response"
+	RotateKeysResponseType    = "io.nats.nex.v1.rotate_keys_response"
+	RunResponseType           = "io.nats.nex.v1.run_response"
+	SchemasResponseType       = "io.nats.nex.v1.schemas_response"
+	StopResponseType          = "io.nats.nex.v1.stop_response"
+	SubzResponseType          = "io.nats.nex.v1.subz_response"
+	UsageResponseType         = "io.nats.nex.v1.usage_response"
+	VolumeResponseType        = "io.nats.nex.v1.volume_response"
+	LameDuckResponseType      = "io.nats.nex.v1.lameduck_response"
+	NodeTagsResponseType      = "io.nats.nex.v1.node_tags_response"
+	NodeUpdateResponseType    = "io.nats.nex.v1.node_update_response"
+	RootfsRolloutResponseType = "io.nats.nex.v1.rootfs_rollout_response"
 
 	TagOS       = "nex.os"
 	TagArch     = "nex.arch"
 	TagCPUs     = "nex.cpucount"
+	TagGPUs     = "nex.gpucount"
+	TagGPUModel = "nex.gpumodel"
 	TagUnsafe   = "nex.unsafe"
 	TagLameDuck = "nex.lameduck"
+	TagCordoned = "nex.cordoned"
+
+	// Site a node runs in, such as an edge location reached through a leaf node connection
+	TagSite = "nex.site"
 )
 
 type RunResponse struct {
@@ -30,6 +69,9 @@ type RunResponse struct {
 	ID      string `json:"id"`
 	Issuer  string `json:"issuer"`
 	Name    string `json:"name"`
+
+	// Why the workload was not started, when it was rejected
+	Rejection *DeployRejection `json:"rejection,omitempty"`
 }
 
 type NexWorkload string
@@ -39,6 +81,8 @@ const (
 	NexWorkloadV8     NexWorkload = "v8"
 	NexWorkloadOCI    NexWorkload = "oci"
 	NexWorkloadWasm   NexWorkload = "wasm"
+	NexWorkloadJVM    NexWorkload = "jvm"
+	NexWorkloadJob    NexWorkload = "job"
 
 	// cloud events can't have - in extensions
 	EventExtensionNamespace = "namespace"
@@ -56,10 +100,29 @@ type AuctionRequest struct {
 	Sandboxed     *bool             `json:"sandboxed,omitempty"`
 	Tags          map[string]string `json:"tags,omitempty"`
 	WorkloadTypes []NexWorkload     `json:"workload_types,omitempty"`
+
+	// Architectures of which the node must match one, e.g. those a workload has artifacts for
+	Archs []string `json:"archs,omitempty"`
+	// Minimum number of unassigned GPUs a node must have to take part
+	GPUs int `json:"gpus,omitempty"`
+	// Nodes running a workload matching any of these terms decline to take part
+	AntiAffinity []AntiAffinityTerm `json:"anti_affinity,omitempty"`
+	// Nodes already running as many matching workloads as the constraint allows decline to take part
+	Spread *SpreadConstraint `json:"spread,omitempty"`
+	// Sites of which the node must be in one, as given by its nex.site tag
+	Sites []string `json:"sites,omitempty"`
 }
 
 type AuctionResponse PingResponse
 
+// Requests that every node matching the optional filters reply to the request's inbox. Nodes
+// delay their reply by a random amount up to MaxJitterMillis so that a large nexus does not
+// answer all at once
+type DiscoverRequest struct {
+	AuctionRequest
+	MaxJitterMillis int `json:"max_jitter_ms,omitempty"`
+}
+
 // TODO: remove omitempty in next version bump
 type PingResponse struct {
 	NodeId          string            `json:"node_id"`
@@ -69,6 +132,20 @@ type PingResponse struct {
 	TargetXkey      string            `json:"target_xkey"`
 	Tags            map[string]string `json:"tags,omitempty"`
 	RunningMachines int               `json:"running_machines"`
+	Capacity        *NodeCapacity     `json:"capacity,omitempty"`
+
+	// How long the node deliberately held its reply, e.g. to spread discovery replies
+	ReplyDelayMillis int64 `json:"reply_delay_ms,omitempty"`
+
+	// Measured by the client from sending the request to receiving this response, less the node's
+	// reply delay, so that it covers every hop between the two, including leaf node connections.
+	// Nodes never set it
+	RoundTrip time.Duration `json:"round_trip,omitempty"`
+}
+
+// The site of the node, as given by its nex.site tag
+func (r PingResponse) Site() string {
+	return r.Tags[TagSite]
 }
 
 type WorkloadPingResponse struct {
@@ -85,6 +162,24 @@ type WorkloadPingMachineSummary struct {
 	Namespace    string      `json:"namespace"`
 	Name         string      `json:"name"`
 	WorkloadType NexWorkload `json:"type"`
+	Issuer       string      `json:"issuer,omitempty"`
+}
+
+// A workload found on a node by its name
+type WorkloadLocation struct {
+	NodeId string `json:"node_id"`
+	WorkloadPingMachineSummary
+}
+
+// Narrows the workloads reported by workload pings and node info requests. An empty filter
+// matches every workload
+type WorkloadFilter struct {
+	// Public key of the issuer that deployed the workload
+	Issuer string `json:"issuer,omitempty"`
+}
+
+func (f *WorkloadFilter) Matches(issuer string) bool {
+	return f == nil || f.Issuer == "" || f.Issuer == issuer
 }
 
 type LameDuckResponse struct {
@@ -98,22 +193,115 @@ type MemoryStat struct {
 	MemAvailable int `json:"available"`
 }
 
+// Workload capacity of a node after host reservations and overcommit are applied. Memory and
+// vCPU totals of zero indicate the node could not determine that resource
+type NodeCapacity struct {
+	MemoryMib          int     `json:"memory_mib"`
+	VcpuCount          int     `json:"vcpu_count"`
+	AllocatedMemoryMib int     `json:"allocated_memory_mib"`
+	AllocatedVcpuCount int     `json:"allocated_vcpu_count"`
+	AvailableMemoryMib int     `json:"available_memory_mib"`
+	AvailableVcpuCount int     `json:"available_vcpu_count"`
+	OvercommitRatio    float64 `json:"overcommit_ratio"`
+	GPUCount           int     `json:"gpu_count,omitempty"`
+	AvailableGPUCount  int     `json:"available_gpu_count,omitempty"`
+}
+
 type InfoResponse struct {
 	Version                string            `json:"version"`
 	Uptime                 string            `json:"uptime"`
 	PublicXKey             string            `json:"public_xkey"`
 	Tags                   map[string]string `json:"tags,omitempty"`
 	Memory                 *MemoryStat       `json:"memory,omitempty"`
+	Capacity               *NodeCapacity     `json:"capacity,omitempty"`
 	Machines               []MachineSummary  `json:"machines"`
+	Agents                 []AgentSummary    `json:"agents,omitempty"`
 	SupportedWorkloadTypes []NexWorkload     `json:"supported_workload_types,omitempty"`
+	Cordon                 *CordonStatus     `json:"cordon,omitempty"`
+	Pressure               *PressureStatus   `json:"pressure,omitempty"`
+	Preflight              *PreflightReport  `json:"preflight,omitempty"`
+}
+
+// Version information reported by an agent during its handshake with the node
+type AgentSummary struct {
+	Id              string `json:"id"`
+	Version         string `json:"version,omitempty"`
+	ProtocolVersion int    `json:"protocol_version"`
+	Compatible      bool   `json:"compatible"`
+	Deployed        bool   `json:"deployed"`
+
+	// Absent for agents predating capability reports
+	Capabilities *AgentCapabilities `json:"capabilities,omitempty"`
+}
+
+// Runtime capabilities an agent reports during its handshake, from which the node decides
+// which deployments the agent can receive
+type AgentCapabilities struct {
+	Providers []ProviderCapability `json:"providers"`
+
+	// Memory of the agent's machine as seen when the agent started
+	MemoryTotalBytes     uint64 `json:"memory_total_bytes,omitempty"`
+	MemoryAvailableBytes uint64 `json:"memory_available_bytes,omitempty"`
+}
+
+type ProviderCapability struct {
+	WorkloadType NexWorkload `json:"workload_type"`
+	Version      string      `json:"version,omitempty"`
+}
+
+// Returns an error describing why the agent cannot run a workload of the given type and
+// resources, or nil if it can. Memory is only checked when the agent reported it
+func (c *AgentCapabilities) Supports(workloadType NexWorkload, resources *WorkloadResources) error {
+	supported := false
+	for _, provider := range c.Providers {
+		if strings.EqualFold(string(provider.WorkloadType), string(workloadType)) {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("no provider for workload type %s", workloadType)
+	}
+
+	if resources != nil && resources.MemoryMib > 0 && c.MemoryAvailableBytes > 0 {
+		required := uint64(resources.MemoryMib) * 1024 * 1024
+		if required > c.MemoryAvailableBytes {
+			return fmt.Errorf("workload requires %d MiB of memory but only %d MiB is available", resources.MemoryMib, c.MemoryAvailableBytes/1024/1024)
+		}
+	}
+
+	return nil
 }
 
 type MachineSummary struct {
-	Id        string          `json:"id"`
-	Healthy   bool            `json:"healthy"`
-	Uptime    string          `json:"uptime"`
-	Namespace string          `json:"namespace,omitempty"`
-	Workload  WorkloadSummary `json:"workload,omitempty"`
+	Id        string            `json:"id"`
+	Healthy   bool              `json:"healthy"`
+	Uptime    string            `json:"uptime"`
+	Namespace string            `json:"namespace,omitempty"`
+	Group     string            `json:"group,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Workload  WorkloadSummary   `json:"workload,omitempty"`
+
+	// Public key of the issuer that signed the workload's deploy request, i.e. its owner
+	Issuer string `json:"issuer,omitempty"`
+
+	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+	Resources *MachineResources `json:"resources,omitempty"`
+	Paused    bool              `json:"paused,omitempty"`
+
+	// Circuit breakers of the workload's trigger subjects, if the node enables them
+	TriggerBreakers []TriggerBreakerStatus `json:"trigger_breakers,omitempty"`
+}
+
+// Resource usage of the machine running a workload. Counters are totals since the machine
+// started; block and network I/O are only reported for firecracker VMs
+type MachineResources struct {
+	CPUTimeMillis   int64 `json:"cpu_time_ms"`
+	MemoryRSSBytes  int64 `json:"memory_rss_bytes"`
+	BlockReadBytes  int64 `json:"block_read_bytes,omitempty"`
+	BlockWriteBytes int64 `json:"block_write_bytes,omitempty"`
+	NetRxBytes      int64 `json:"net_rx_bytes,omitempty"`
+	NetTxBytes      int64 `json:"net_tx_bytes,omitempty"`
 }
 
 type WorkloadSummary struct {
@@ -143,6 +331,12 @@ type RawLog struct {
 	Text  string     `json:"text"`
 	Level slog.Level `json:"level"`
 	ID    string     `json:"id"`
+
+	// Origin of the log entry, one of stdout, stderr or runtime, when known
+	Source string `json:"source,omitempty"`
+
+	// Structured key/value pairs attached to the log entry, if any
+	Fields map[string]interface{} `json:"fields,omitempty"`
 }
 
 // Note this a wrapper to add context to a cloud event
@@ -0,0 +1,86 @@
+package controlapi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nats-io/nkeys"
+)
+
+// Asks a node to replace its own binary. The binary is fetched from the location, which is either
+// an http(s) URL or nats://{bucket}/{object} naming an object in a JetStream object store, and must
+// be signed by one of the keys the node trusts for updates. Once the binary is staged the node
+// enters lame duck mode, stops its workloads and execs the new binary, which redeploys them
+type NodeUpdateRequest struct {
+	Location  string `json:"location"`
+	Signature string `json:"signature"`
+
+	// Expected SHA-256 digest of the binary, hex encoded; when omitted only the signature is checked
+	Sha256 string `json:"sha256,omitempty"`
+	// Semantic version of the new binary, which is covered by the signature and must be newer than
+	// the version the node is running
+	Version string `json:"version"`
+}
+
+type NodeUpdateResponse struct {
+	NodeId   string `json:"node_id"`
+	Version  string `json:"version,omitempty"`
+	Sha256   string `json:"sha256"`
+	SignedBy string `json:"signed_by"`
+
+	// Number of running workloads the node will redeploy once the new binary has started
+	Workloads int `json:"workloads"`
+}
+
+// Signs the version and SHA-256 digest of a nex binary, returning the hex encoded digest and the
+// signature expected in a node update request. Covering the version keeps a signed binary from
+// being offered to nodes under a different version than the one it was released as
+func SignNodeBinary(kp nkeys.KeyPair, version string, binary io.Reader) (string, string, error) {
+	hash := sha256.New()
+	_, err := io.Copy(hash, binary)
+	if err != nil {
+		return "", "", err
+	}
+	digest := hash.Sum(nil)
+
+	sig, err := kp.Sign(nodeBinarySigningPayload(version, digest))
+	if err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(digest), base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// Verifies a signature made by SignNodeBinary over the given version and digest, returning the
+// trusted public key that made it
+func VerifyNodeBinarySignature(version string, digest []byte, signature string, trustedKeys []string) (string, error) {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature encoding: %s", err)
+	}
+
+	payload := nodeBinarySigningPayload(version, digest)
+	for _, key := range trustedKeys {
+		kp, err := nkeys.FromPublicKey(key)
+		if err != nil {
+			continue
+		}
+
+		if kp.Verify(payload, sig) == nil {
+			return key, nil
+		}
+	}
+
+	return "", errors.New("binary is not signed by a trusted key")
+}
+
+// The digest has a fixed length, so the version followed by the digest is unambiguous
+func nodeBinarySigningPayload(version string, digest []byte) []byte {
+	payload := make([]byte, 0, len(version)+len(digest))
+	payload = append(payload, version...)
+	return append(payload, digest...)
+}
@@ -0,0 +1,100 @@
+package controlapi
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+const volumeClaimsNameField = "volume"
+
+// Actions of a volume request
+const (
+	VolumeActionList   = "list"
+	VolumeActionDelete = "delete"
+)
+
+// A persistent volume attached to a service workload. The node keeps the volume, by name within
+// the workload's namespace, across restarts and redeploys of workloads on that node, until it is
+// deleted. Inside a sandbox the volume is a block device mounted at Path; without a sandbox it is
+// a host directory whose path is given to the workload in the NEX_VOLUME_PATH environment variable
+type WorkloadVolume struct {
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+
+	// Size of the volume when it is created; zero uses the node's default
+	SizeMib int `json:"size_mib,omitempty"`
+}
+
+// Lists the volumes of a namespace on a node, or deletes one of them. Volumes in use by a
+// running workload cannot be deleted, and deleting one requires claims signed by the issuer whose
+// workload created it or by one of the node's admin keys
+type VolumeRequest struct {
+	Action      string `json:"action"`
+	Name        string `json:"name,omitempty"`
+	WorkloadJwt string `json:"workload_jwt,omitempty"`
+}
+
+// Creates a request deleting the named volume, signed by the issuer whose workload created it or
+// by one of the node's admin keys
+func NewVolumeDeleteRequest(name string, issuer nkeys.KeyPair) (*VolumeRequest, error) {
+	jwtText, err := newActionClaims("delete volume", volumeClaimsNameField, name, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VolumeRequest{
+		Action:      VolumeActionDelete,
+		Name:        name,
+		WorkloadJwt: jwtText,
+	}, nil
+}
+
+// Verifies that the request is signed by the issuer owning the volume, or by one of the given
+// admin keys. Volumes without a recorded owner can only be deleted by admin keys. Every failure is
+// reported as an *AuthorizationError
+func (request *VolumeRequest) Validate(owner string, adminKeys ...string) error {
+	original := &jwt.GenericClaims{ClaimsData: jwt.ClaimsData{Issuer: owner}}
+	return validateActionClaims(request.WorkloadJwt, "delete volume", volumeClaimsNameField, request.Name, original, adminKeys)
+}
+
+type VolumeResponse struct {
+	NodeId  string       `json:"node_id"`
+	Volumes []VolumeInfo `json:"volumes"`
+}
+
+// A volume kept by a node, with the space its contents use
+type VolumeInfo struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	SizeMib   int       `json:"size_mib"`
+	UsedBytes int64     `json:"used_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Workload the volume is attached to, if any
+	WorkloadId string `json:"workload_id,omitempty"`
+
+	// Issuer of the workload that created the volume, who may delete it
+	Issuer string `json:"issuer,omitempty"`
+}
+
+func (volume *WorkloadVolume) validate() error {
+	if !validGroupName.MatchString(volume.Name) {
+		return fmt.Errorf("workload volume name ('%s') must contain only letters, digits, dashes and underscores", volume.Name)
+	}
+
+	if volume.SizeMib < 0 {
+		return errors.New("workload volume size must not be negative")
+	}
+
+	// the path is only required by sandboxed nodes, which mount the volume there
+	if volume.Path != "" && !path.IsAbs(volume.Path) {
+		return fmt.Errorf("workload volume path ('%s') must be absolute", volume.Path)
+	}
+
+	return nil
+}
@@ -0,0 +1,280 @@
+package controlapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+	"github.com/nats-io/nats.go"
+)
+
+// How long a watch waits before resubscribing after its subscription was terminated
+const watchResubscribeBackoff = time.Second
+
+// Narrows what a watch delivers. Empty fields match everything
+type WatchFilter struct {
+	Namespace  string
+	NodeId     string
+	WorkloadId string
+
+	// Restricts the watch to these nodes, e.g. those of a site discovered with SiteNodes
+	NodeIds []string
+
+	// Only applies to logs
+	WorkloadName string
+
+	// Only applies to events
+	EventTypes []string
+}
+
+// An event whose data has been decoded into the payload type of its event type
+type TypedEvent[T any] struct {
+	EmittedEvent
+	Payload T
+}
+
+// Streams the events matching the filter until the context is done, at which point the returned
+// channel is closed. Events of the system namespace are delivered along with those of the
+// filtered namespace. A subscription terminated by the server is re-established; the channel
+// is only closed early if the connection itself is closed
+func (api *Client) WatchEvents(ctx context.Context, filter WatchFilter) (<-chan EmittedEvent, error) {
+	namespace := watchToken(filter.Namespace)
+
+	eventType := "*"
+	if len(filter.EventTypes) == 1 {
+		eventType = filter.EventTypes[0]
+	}
+
+	subjects := []string{fmt.Sprintf("%s.events.%s.%s", APIPrefix, namespace, eventType)}
+	if namespace != "*" && namespace != "system" {
+		subjects = append(subjects, fmt.Sprintf("%s.events.system.%s", APIPrefix, eventType))
+	}
+
+	events := make(chan EmittedEvent)
+	err := api.watch(ctx, subjects, func(m *nats.Msg) {
+		tokens := strings.Split(m.Subject, ".")
+		if len(tokens) != 4 {
+			return
+		}
+
+		event := cloudevents.NewEvent()
+		err := json.Unmarshal(m.Data, &event)
+		if err != nil {
+			api.log.Debug("Failed to decode watched event", slog.String("subject", m.Subject), slog.Any("err", err))
+			return
+		}
+
+		emitted := EmittedEvent{
+			Event:     event,
+			Namespace: tokens[2],
+			EventType: tokens[3],
+		}
+		if !filter.matchesEvent(emitted) {
+			return
+		}
+
+		select {
+		case events <- emitted:
+		case <-ctx.Done():
+		}
+	}, func() {
+		close(events)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Streams the events of a single event type, decoding each event's data into the payload type
+// T. Events whose data does not decode are skipped
+func WatchEventsOf[T any](ctx context.Context, api *Client, eventType string, filter WatchFilter) (<-chan TypedEvent[T], error) {
+	filter.EventTypes = []string{eventType}
+
+	events, err := api.WatchEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := make(chan TypedEvent[T])
+	go func() {
+		defer close(typed)
+
+		for event := range events {
+			var payload T
+			err := event.Event.DataAs(&payload)
+			if err != nil {
+				api.log.Debug("Failed to decode watched event data", slog.String("event_type", eventType), slog.Any("err", err))
+				continue
+			}
+
+			select {
+			case typed <- TypedEvent[T]{EmittedEvent: event, Payload: payload}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return typed, nil
+}
+
+// Streams the workload logs matching the filter until the context is done, at which point the
+// returned channel is closed. Like WatchEvents, terminated subscriptions are re-established
+func (api *Client) WatchLogs(ctx context.Context, filter WatchFilter) (<-chan EmittedLog, error) {
+	subject := fmt.Sprintf("%s.logs.%s.%s.%s.%s", APIPrefix,
+		watchToken(filter.Namespace),
+		watchToken(filter.NodeId),
+		watchToken(filter.WorkloadName),
+		watchToken(filter.WorkloadId),
+	)
+
+	logs := make(chan EmittedLog)
+	err := api.watch(ctx, []string{subject}, func(m *nats.Msg) {
+		tokens := strings.Split(m.Subject, ".")
+		if len(tokens) != 6 {
+			return
+		}
+
+		if len(filter.NodeIds) > 0 && !slices.Contains(filter.NodeIds, tokens[3]) {
+			return
+		}
+
+		var entry RawLog
+		err := json.Unmarshal(m.Data, &entry)
+		if err != nil {
+			api.log.Debug("Failed to decode watched log entry", slog.String("subject", m.Subject), slog.Any("err", err))
+			return
+		}
+
+		select {
+		case logs <- EmittedLog{
+			Namespace: tokens[2],
+			NodeId:    tokens[3],
+			Workload:  tokens[4],
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			RawLog:    entry,
+		}:
+		case <-ctx.Done():
+		}
+	}, func() {
+		close(logs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
+
+// Subscribes to each subject, handing every message to the handler until the context is done,
+// then calls done once all subscriptions have ended. The initial subscriptions are made before
+// returning so that their errors reach the caller
+func (api *Client) watch(ctx context.Context, subjects []string, handle func(*nats.Msg), done func()) error {
+	subs := make([]*nats.Subscription, 0, len(subjects))
+	for _, subject := range subjects {
+		sub, err := api.nc.SubscribeSync(subject)
+		if err != nil {
+			for _, s := range subs {
+				_ = s.Unsubscribe()
+			}
+			return err
+		}
+		subs = append(subs, sub)
+	}
+
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		wg.Add(1)
+		go func(sub *nats.Subscription) {
+			defer wg.Done()
+			api.watchSubscription(ctx, sub, handle)
+		}(sub)
+	}
+
+	go func() {
+		wg.Wait()
+		done()
+	}()
+
+	return nil
+}
+
+func (api *Client) watchSubscription(ctx context.Context, sub *nats.Subscription, handle func(*nats.Msg)) {
+	subject := sub.Subject
+	defer func() {
+		_ = sub.Unsubscribe()
+	}()
+
+	for {
+		m, err := sub.NextMsgWithContext(ctx)
+		switch {
+		case err == nil:
+			handle(m)
+			continue
+		case ctx.Err() != nil, api.nc.IsClosed():
+			return
+		case errors.Is(err, nats.ErrSlowConsumer):
+			api.log.Warn("Watch fell behind; messages were dropped", slog.String("subject", subject))
+			continue
+		}
+
+		api.log.Warn("Watch subscription ended; resubscribing", slog.String("subject", subject), slog.Any("err", err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchResubscribeBackoff):
+		}
+
+		next, err := api.nc.SubscribeSync(subject)
+		if err != nil {
+			api.log.Error("Failed to resubscribe watch", slog.String("subject", subject), slog.Any("err", err))
+			continue
+		}
+		_ = sub.Unsubscribe()
+		sub = next
+	}
+}
+
+func (f WatchFilter) matchesEvent(event EmittedEvent) bool {
+	if len(f.EventTypes) > 1 && !slices.Contains(f.EventTypes, event.EventType) {
+		return false
+	}
+
+	if f.NodeId != "" && event.Event.Source() != f.NodeId {
+		return false
+	}
+
+	if len(f.NodeIds) > 0 && !slices.Contains(f.NodeIds, event.Event.Source()) {
+		return false
+	}
+
+	if f.WorkloadId != "" {
+		var data map[string]interface{}
+		if event.Event.DataAs(&data) != nil {
+			return false
+		}
+
+		// workload events name their workload either way
+		if data["workload_id"] != f.WorkloadId && data["id"] != f.WorkloadId {
+			return false
+		}
+	}
+
+	return true
+}
+
+func watchToken(value string) string {
+	if value == "" {
+		return "*"
+	}
+	return value
+}
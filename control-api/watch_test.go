@@ -0,0 +1,209 @@
+package controlapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+	"github.com/nats-io/nats.go"
+)
+
+func publishTestEvent(t *testing.T, nc *nats.Conn, namespace string, source string, eventType string, data interface{}) {
+	event := cloudevents.NewEvent()
+	event.SetSource(source)
+	event.SetID(fmt.Sprintf("%s-%d", eventType, time.Now().UnixNano()))
+	event.SetType(eventType)
+	event.SetDataContentType(cloudevents.ApplicationJSON)
+	_ = event.SetData(data)
+
+	raw, err := event.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal event: %s", err)
+	}
+	_ = nc.Publish(fmt.Sprintf("%s.events.%s.%s", APIPrefix, namespace, eventType), raw)
+	_ = nc.Flush()
+}
+
+func nextWatched[T any](t *testing.T, ch <-chan T) T {
+	select {
+	case v, ok := <-ch:
+		if !ok {
+			t.Fatal("Expected the watch to deliver, but it was closed")
+		}
+		return v
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the watch to deliver")
+	}
+
+	var zero T
+	return zero
+}
+
+func TestWatchEventsFiltersByNodeAndWorkload(t *testing.T) {
+	nc := startTestNats(t)
+	client := NewApiClientWithNamespace(nc, time.Second, "default", slog.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := client.WatchEvents(ctx, WatchFilter{
+		Namespace:  "default",
+		NodeId:     "node1",
+		WorkloadId: "w1",
+		EventTypes: []string{WorkloadDeployedEventType, WorkloadStoppingEventType},
+	})
+	if err != nil {
+		t.Fatalf("Failed to watch events: %s", err)
+	}
+
+	publishTestEvent(t, nc, "other", "node1", WorkloadDeployedEventType, map[string]string{"id": "w1"})
+	publishTestEvent(t, nc, "default", "node2", WorkloadDeployedEventType, map[string]string{"id": "w1"})
+	publishTestEvent(t, nc, "default", "node1", WorkloadDeployedEventType, map[string]string{"id": "w2"})
+	publishTestEvent(t, nc, "default", "node1", WorkloadUndeployedEventType, map[string]string{"id": "w1"})
+	publishTestEvent(t, nc, "default", "node1", WorkloadStoppingEventType, map[string]string{"workload_id": "w1"})
+
+	event := nextWatched(t, events)
+	if event.EventType != WorkloadStoppingEventType || event.Namespace != "default" {
+		t.Fatalf("Expected only the matching stopping event to be delivered, got %s in %s", event.EventType, event.Namespace)
+	}
+}
+
+func TestWatchEventsIncludesSystemNamespace(t *testing.T) {
+	nc := startTestNats(t)
+	client := NewApiClientWithNamespace(nc, time.Second, "default", slog.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := client.WatchEvents(ctx, WatchFilter{Namespace: "default"})
+	if err != nil {
+		t.Fatalf("Failed to watch events: %s", err)
+	}
+
+	publishTestEvent(t, nc, "system", "node1", NodeStartedEventType, NodeStartedEvent{Id: "node1"})
+	if event := nextWatched(t, events); event.Namespace != "system" || event.EventType != NodeStartedEventType {
+		t.Fatalf("Expected the system namespace's event to be delivered, got %s in %s", event.EventType, event.Namespace)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("Expected no further events once the context is done")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the watch to close once the context is done")
+	}
+}
+
+func TestWatchEventsOfDecodesPayload(t *testing.T) {
+	nc := startTestNats(t)
+	client := NewApiClientWithNamespace(nc, time.Second, "default", slog.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	expired, err := WatchEventsOf[WorkloadExpiredEvent](ctx, client, WorkloadExpiredEventType, WatchFilter{Namespace: "default"})
+	if err != nil {
+		t.Fatalf("Failed to watch events: %s", err)
+	}
+
+	publishTestEvent(t, nc, "default", "node1", WorkloadExpiredEventType, WorkloadExpiredEvent{Name: "echo", VmId: "w1", TTLMillis: 500})
+
+	event := nextWatched(t, expired)
+	if event.Payload.Name != "echo" || event.Payload.TTLMillis != 500 {
+		t.Fatalf("Expected the expiry to be decoded, got %+v", event.Payload)
+	}
+}
+
+func TestWatchLogsRoutesBySubject(t *testing.T) {
+	nc := startTestNats(t)
+	client := NewApiClientWithNamespace(nc, time.Second, "default", slog.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logs, err := client.WatchLogs(ctx, WatchFilter{Namespace: "default", WorkloadName: "echo"})
+	if err != nil {
+		t.Fatalf("Failed to watch logs: %s", err)
+	}
+
+	raw, _ := json.Marshal(RawLog{Text: "ignored", Level: slog.LevelInfo, ID: "w2"})
+	_ = nc.Publish(fmt.Sprintf("%s.logs.default.node1.other.w2", APIPrefix), raw)
+	raw, _ = json.Marshal(RawLog{Text: "hello", Level: slog.LevelInfo, ID: "w1"})
+	_ = nc.Publish(fmt.Sprintf("%s.logs.default.node1.echo.w1", APIPrefix), raw)
+	_ = nc.Flush()
+
+	entry := nextWatched(t, logs)
+	if entry.Text != "hello" || entry.NodeId != "node1" || entry.Workload != "echo" {
+		t.Fatalf("Expected the echo workload's log, got %+v", entry)
+	}
+}
+
+func TestWatchResubscribesWhenSubscriptionEnds(t *testing.T) {
+	nc := startTestNats(t)
+	client := NewApiClientWithNamespace(nc, time.Second, "default", slog.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub, err := nc.SubscribeSync("watched")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %s", err)
+	}
+	// the subscription ends after its first message, as if the server had terminated it
+	_ = sub.AutoUnsubscribe(1)
+
+	received := make(chan string, 16)
+	go client.watchSubscription(ctx, sub, func(m *nats.Msg) {
+		received <- string(m.Data)
+	})
+
+	_ = nc.Publish("watched", []byte("first"))
+	if data := nextWatched(t, received); data != "first" {
+		t.Fatalf("Expected the first message, got %s", data)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		_ = nc.Publish("watched", []byte("after"))
+		select {
+		case data := <-received:
+			if data != "after" {
+				t.Fatalf("Expected a message after resubscribing, got %s", data)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("Expected the watch to resubscribe")
+		}
+	}
+}
+
+func TestWatchLogsRestrictedToNodes(t *testing.T) {
+	nc := startTestNats(t)
+	client := NewApiClientWithNamespace(nc, time.Second, "default", slog.Default())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logs, err := client.WatchLogs(ctx, WatchFilter{Namespace: "default", NodeIds: []string{"edge1", "edge2"}})
+	if err != nil {
+		t.Fatalf("Failed to watch logs: %s", err)
+	}
+
+	raw, _ := json.Marshal(RawLog{Text: "ignored", Level: slog.LevelInfo, ID: "w1"})
+	_ = nc.Publish(fmt.Sprintf("%s.logs.default.hub1.echo.w1", APIPrefix), raw)
+	raw, _ = json.Marshal(RawLog{Text: "from the edge", Level: slog.LevelInfo, ID: "w2"})
+	_ = nc.Publish(fmt.Sprintf("%s.logs.default.edge2.echo.w2", APIPrefix), raw)
+	_ = nc.Flush()
+
+	entry := nextWatched(t, logs)
+	if entry.Text != "from the edge" || entry.NodeId != "edge2" {
+		t.Fatalf("Expected only the edge node's log, got %+v", entry)
+	}
+}
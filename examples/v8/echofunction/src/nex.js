@@ -0,0 +1,20 @@
+async (subject, payload) => {
+  await nex.kv.set('hello', payload);
+
+  let hello;
+  try {
+    hello = await nex.kv.get('hello', { timeoutMs: 500 });
+  } catch (err) {
+    if (err instanceof nex.ThrottledError) {
+      return { throttled: true, retryAfterMs: err.retryAfterMs };
+    }
+    throw err;
+  }
+
+  await nex.messaging.publish('hello.world', hello);
+
+  return {
+    keys: await nex.kv.keys(),
+    hello: String.fromCharCode(...hello)
+  };
+};
@@ -0,0 +1,162 @@
+package hostservices
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+const (
+	ThrottleReasonRate    = "rate"
+	ThrottleReasonCredits = "credits"
+
+	headerThrottleReason = "x-nex-hs-throttle"
+	headerRetryAfter     = "x-nex-hs-retry-after-ms"
+
+	codeThrottled = 429
+)
+
+// Returned to workloads when a host service call is rejected because it exceeds the
+// workload's budget. Calls throttled for rate may be retried after RetryAfter; calls
+// throttled for credits will not succeed again for the lifetime of the workload
+type ThrottleError struct {
+	Service    string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	if e.Reason == ThrottleReasonCredits {
+		return fmt.Sprintf("host service %s throttled; workload has exhausted its credits", e.Service)
+	}
+
+	return fmt.Sprintf("host service %s throttled; retry after %s", e.Service, e.RetryAfter)
+}
+
+// Token bucket with an optional lifetime credit allowance
+type budget struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	credits        int64
+	creditsLimited bool
+}
+
+func newBudget(config controlapi.HostServiceBudget, now time.Time) *budget {
+	burst := float64(config.Burst)
+	if burst < 1 {
+		burst = math.Max(1, config.RequestsPerSecond)
+	}
+
+	return &budget{
+		rate:           config.RequestsPerSecond,
+		burst:          burst,
+		tokens:         burst,
+		last:           now,
+		credits:        config.Credits,
+		creditsLimited: config.Credits > 0,
+	}
+}
+
+// Refills the bucket and reports whether a call could be admitted now, without spending
+func (b *budget) check(now time.Time) (string, time.Duration) {
+	if b.creditsLimited && b.credits <= 0 {
+		return ThrottleReasonCredits, 0
+	}
+
+	if b.rate <= 0 {
+		return "", 0
+	}
+
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return ThrottleReasonRate, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+
+	return "", 0
+}
+
+func (b *budget) spend() {
+	if b.rate > 0 {
+		b.tokens--
+	}
+	if b.creditsLimited {
+		b.credits--
+	}
+}
+
+// Budgets of every workload that declared one at deploy time
+type budgets struct {
+	mutex     sync.Mutex
+	workloads map[string]map[string]*budget
+}
+
+func newBudgets() *budgets {
+	return &budgets{
+		workloads: make(map[string]map[string]*budget),
+	}
+}
+
+func (b *budgets) set(workloadId string, config map[string]controlapi.HostServiceBudget) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if len(config) == 0 {
+		delete(b.workloads, workloadId)
+		return
+	}
+
+	now := time.Now()
+	workload := make(map[string]*budget, len(config))
+	for service, cfg := range config {
+		workload[service] = newBudget(cfg, now)
+	}
+	b.workloads[workloadId] = workload
+}
+
+func (b *budgets) remove(workloadId string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.workloads, workloadId)
+}
+
+// Admits a call to the given service against both the service's budget and the budget
+// shared across all services, spending from each only if both admit it
+func (b *budgets) consume(workloadId, service string) *ThrottleError {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	workload, ok := b.workloads[workloadId]
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	applicable := make([]*budget, 0, 2)
+	for _, key := range []string{service, controlapi.AllHostServices} {
+		bgt, ok := workload[key]
+		if !ok {
+			continue
+		}
+
+		reason, retryAfter := bgt.check(now)
+		if reason != "" {
+			return &ThrottleError{Service: service, Reason: reason, RetryAfter: retryAfter}
+		}
+		applicable = append(applicable, bgt)
+	}
+
+	for _, bgt := range applicable {
+		bgt.spend()
+	}
+
+	return nil
+}
@@ -19,6 +19,7 @@ const (
 	builtinServiceNameHttpClient  = "http"
 	builtinServiceNameMessaging   = "messaging"
 	builtinServiceNameObjectStore = "objectstore"
+	builtinServiceNameCredentials = "credentials"
 )
 
 func NewBuiltinServicesClient(hsClient *hostservices.HostServicesClient) *BuiltinServicesClient {
@@ -250,6 +251,31 @@ func (c *BuiltinServicesClient) SimpleHttpRequest(ctx context.Context, method st
 	return &hResp, nil
 }
 
+// Requests short-lived NATS credentials scoped to the given JetStream assets, for use
+// with the workload's own NATS connection
+func (c *BuiltinServicesClient) MintCredentials(ctx context.Context, request *agentapi.HostServicesCredentialsRequest) (*agentapi.HostServicesCredentialsResponse, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.hsClient.PerformRPC(ctx, builtinServiceNameCredentials, credentialsServiceMethodMint, payload, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, resp.Error()
+	}
+
+	var credsResponse agentapi.HostServicesCredentialsResponse
+	err = json.Unmarshal(resp.Data, &credsResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credsResponse, nil
+}
+
 func (c *BuiltinServicesClient) RawClient() *hostservices.HostServicesClient {
 	return c.hsClient
 }
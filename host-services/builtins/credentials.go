@@ -0,0 +1,294 @@
+package builtins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	hostservices "github.com/synadia-io/nex/host-services"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+const (
+	credentialsServiceMethodMint = "mint"
+
+	defaultCredentialsPrefix     = "hs_${namespace}_"
+	defaultCredentialsTTLSeconds = 900
+
+	claimsUpdateSubject = "$SYS.REQ.CLAIMS.UPDATE"
+)
+
+var namespacePlaceholder = regexp.MustCompile(`(?i)\$\{namespace\}`)
+
+// Mints short-lived NATS user credentials, signed by a configured account signing key, that
+// only grant access to the JetStream streams, key/value buckets and object stores a workload
+// names in its request. All asset names must start with the configured namespace prefix.
+//
+// Credentials minted for a workload are revoked by pushing an updated account JWT when that
+// workload stops. The service refuses to start without a revocation configuration, unless it
+// is explicitly allowed to leave credentials to lapse at expiry.
+//
+// Each user may only subscribe to replies under its own inbox prefix, returned with the
+// credentials, so that no two users minted by the service can read each other's replies
+type CredentialsService struct {
+	log    *slog.Logger
+	config credentialsConfig
+
+	signingKey nkeys.KeyPair
+
+	// the account JWT and system account connection used to push revocations, if configured
+	accountClaims *jwt.AccountClaims
+	operatorKey   nkeys.KeyPair
+	ncSystem      *nats.Conn
+
+	mutex  sync.Mutex
+	issued map[string][]string
+}
+
+type credentialsConfig struct {
+	AccountSigningSeed string `json:"account_signing_seed"`
+	IssuerAccount      string `json:"issuer_account,omitempty"`
+	NatsUrl            string `json:"nats_url,omitempty"`
+	Prefix             string `json:"prefix"`
+	TTLSeconds         int    `json:"ttl_seconds"`
+
+	Revocation *credentialsRevocationConfig `json:"revocation,omitempty"`
+
+	// Mints credentials without a revocation configuration; they remain valid after the
+	// workload stops until they expire
+	AllowUnrevoked bool `json:"allow_unrevoked,omitempty"`
+}
+
+type credentialsRevocationConfig struct {
+	AccountJwt          string `json:"account_jwt"`
+	OperatorSigningSeed string `json:"operator_signing_seed"`
+	SystemNatsUrl       string `json:"system_nats_url"`
+	SystemCredsFile     string `json:"system_creds_file"`
+}
+
+func NewCredentialsService(log *slog.Logger) (*CredentialsService, error) {
+	credentials := &CredentialsService{
+		log:    log,
+		issued: make(map[string][]string),
+	}
+
+	return credentials, nil
+}
+
+func (c *CredentialsService) Initialize(config json.RawMessage) error {
+	c.config.Prefix = defaultCredentialsPrefix
+	c.config.TTLSeconds = defaultCredentialsTTLSeconds
+
+	if len(config) > 0 {
+		err := json.Unmarshal(config, &c.config)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.config.AccountSigningSeed == "" {
+		return errors.New("credentials host service requires an account signing seed")
+	}
+
+	var err error
+	c.signingKey, err = nkeys.FromSeed([]byte(c.config.AccountSigningSeed))
+	if err != nil {
+		return fmt.Errorf("invalid account signing seed: %s", err)
+	}
+
+	if c.config.Revocation == nil && !c.config.AllowUnrevoked {
+		return errors.New("credentials host service requires a revocation configuration, unless allow_unrevoked is set")
+	}
+
+	if c.config.Revocation != nil {
+		c.accountClaims, err = jwt.DecodeAccountClaims(c.config.Revocation.AccountJwt)
+		if err != nil {
+			return fmt.Errorf("invalid account JWT for credential revocation: %s", err)
+		}
+
+		c.operatorKey, err = nkeys.FromSeed([]byte(c.config.Revocation.OperatorSigningSeed))
+		if err != nil {
+			return fmt.Errorf("invalid operator signing seed for credential revocation: %s", err)
+		}
+
+		c.ncSystem, err = nats.Connect(c.config.Revocation.SystemNatsUrl,
+			nats.Name("nex-hostservices-credentials"),
+			nats.UserCredentials(c.config.Revocation.SystemCredsFile),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to establish system account connection for credential revocation: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *CredentialsService) HandleRequest(
+	_ *nats.Conn,
+	namespace string,
+	workloadId string,
+	method string,
+	workloadName string,
+	_ map[string]string,
+	request []byte,
+) (hostservices.ServiceResult, error) {
+	switch method {
+	case credentialsServiceMethodMint:
+		return c.handleMint(namespace, workloadId, workloadName, request)
+	default:
+		c.log.Warn("Received invalid host services RPC request",
+			slog.String("service", "credentials"),
+			slog.String("method", method),
+		)
+		return hostservices.ServiceResultFail(400, "Received invalid host services RPC request"), nil
+	}
+}
+
+// Revokes every credential minted for the given workload
+func (c *CredentialsService) RemoveWorkload(workloadId string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	users, ok := c.issued[workloadId]
+	if !ok {
+		return
+	}
+	delete(c.issued, workloadId)
+
+	if c.ncSystem == nil {
+		c.log.Debug("No revocation connection configured; workload credentials will lapse at expiry",
+			slog.String("workload_id", workloadId),
+			slog.Int("credentials", len(users)),
+		)
+		return
+	}
+
+	for _, user := range users {
+		c.accountClaims.Revoke(user)
+	}
+
+	accountJwt, err := c.accountClaims.Encode(c.operatorKey)
+	if err != nil {
+		c.log.Error("Failed to encode account JWT with workload credential revocations", slog.Any("err", err))
+		return
+	}
+
+	_, err = c.ncSystem.Request(claimsUpdateSubject, []byte(accountJwt), 2*time.Second)
+	if err != nil {
+		c.log.Error("Failed to push account JWT with workload credential revocations",
+			slog.String("workload_id", workloadId),
+			slog.Any("err", err),
+		)
+		return
+	}
+
+	c.log.Info("Revoked workload credentials", slog.String("workload_id", workloadId), slog.Int("credentials", len(users)))
+}
+
+func (c *CredentialsService) handleMint(namespace, workloadId, workloadName string, data []byte) (hostservices.ServiceResult, error) {
+	var request agentapi.HostServicesCredentialsRequest
+	err := json.Unmarshal(data, &request)
+	if err != nil {
+		return hostservices.ServiceResultFail(400, "unable to parse credentials request"), nil
+	}
+
+	if len(request.Streams)+len(request.Buckets)+len(request.ObjectStores) == 0 {
+		return hostservices.ServiceResultFail(400, "at least one stream, bucket or object store is required"), nil
+	}
+
+	prefix := namespacePlaceholder.ReplaceAllString(c.config.Prefix, namespace)
+	for _, name := range append(append(append([]string{}, request.Streams...), request.Buckets...), request.ObjectStores...) {
+		if !strings.HasPrefix(name, prefix) || strings.ContainsAny(name, ".*> ") {
+			return hostservices.ServiceResultFail(403, fmt.Sprintf("asset %s is outside of the namespace prefix %s", name, prefix)), nil
+		}
+	}
+
+	userKey, err := nkeys.CreateUser()
+	if err != nil {
+		c.log.Error("Failed to create user key for workload credentials", slog.Any("err", err))
+		return hostservices.ServiceResultFail(500, "failed to mint credentials"), nil
+	}
+	userPublicKey, _ := userKey.PublicKey()
+	userSeed, _ := userKey.Seed()
+
+	expiresAt := time.Now().UTC().Add(time.Duration(c.config.TTLSeconds) * time.Second)
+
+	claims := jwt.NewUserClaims(userPublicKey)
+	claims.Name = fmt.Sprintf("nex-%s-%s", namespace, workloadName)
+	claims.IssuerAccount = c.config.IssuerAccount
+	claims.Expires = expiresAt.Unix()
+	claims.Tags.Add(fmt.Sprintf("nex-namespace:%s", namespace), fmt.Sprintf("nex-workload:%s", workloadId))
+
+	inboxPrefix := fmt.Sprintf("_INBOX_%s", userPublicKey)
+	claims.Pub.Allow.Add("$JS.API.INFO")
+	claims.Sub.Allow.Add(fmt.Sprintf("%s.>", inboxPrefix))
+
+	for _, stream := range request.Streams {
+		allowStreamAccess(claims, stream)
+	}
+	for _, bucket := range request.Buckets {
+		allowStreamAccess(claims, fmt.Sprintf("KV_%s", bucket))
+		claims.Pub.Allow.Add(fmt.Sprintf("$KV.%s.>", bucket))
+	}
+	for _, store := range request.ObjectStores {
+		allowStreamAccess(claims, fmt.Sprintf("OBJ_%s", store))
+		claims.Pub.Allow.Add(fmt.Sprintf("$O.%s.>", store))
+	}
+
+	userJwt, err := claims.Encode(c.signingKey)
+	if err != nil {
+		c.log.Error("Failed to sign workload credentials", slog.Any("err", err))
+		return hostservices.ServiceResultFail(500, "failed to mint credentials"), nil
+	}
+
+	creds, err := jwt.FormatUserConfig(userJwt, userSeed)
+	if err != nil {
+		return hostservices.ServiceResultFail(500, "failed to mint credentials"), nil
+	}
+
+	c.mutex.Lock()
+	c.issued[workloadId] = append(c.issued[workloadId], userPublicKey)
+	c.mutex.Unlock()
+
+	c.log.Info("Minted scoped credentials for workload",
+		slog.String("workload_id", workloadId),
+		slog.String("namespace", namespace),
+		slog.String("user", userPublicKey),
+		slog.Time("expires_at", expiresAt),
+	)
+
+	resp, _ := json.Marshal(&agentapi.HostServicesCredentialsResponse{
+		Jwt:         userJwt,
+		Seed:        string(userSeed),
+		Creds:       string(creds),
+		NatsUrl:     c.config.NatsUrl,
+		InboxPrefix: inboxPrefix,
+		ExpiresAt:   expiresAt,
+	})
+	return hostservices.ServiceResultPass(200, "", resp), nil
+}
+
+// Grants the JetStream API access needed to read from and consume the given stream
+func allowStreamAccess(claims *jwt.UserClaims, stream string) {
+	claims.Pub.Allow.Add(
+		fmt.Sprintf("$JS.API.STREAM.INFO.%s", stream),
+		fmt.Sprintf("$JS.API.STREAM.MSG.GET.%s", stream),
+		fmt.Sprintf("$JS.API.DIRECT.GET.%s", stream),
+		fmt.Sprintf("$JS.API.DIRECT.GET.%s.>", stream),
+		fmt.Sprintf("$JS.API.CONSUMER.CREATE.%s", stream),
+		fmt.Sprintf("$JS.API.CONSUMER.CREATE.%s.>", stream),
+		fmt.Sprintf("$JS.API.CONSUMER.INFO.%s.*", stream),
+		fmt.Sprintf("$JS.API.CONSUMER.DELETE.%s.*", stream),
+		fmt.Sprintf("$JS.API.CONSUMER.MSG.NEXT.%s.*", stream),
+		fmt.Sprintf("$JS.ACK.%s.>", stream),
+		fmt.Sprintf("$JS.FC.%s.>", stream),
+	)
+}
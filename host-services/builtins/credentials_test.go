@@ -0,0 +1,90 @@
+package builtins
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+func newTestCredentialsService(t *testing.T) *CredentialsService {
+	account, _ := nkeys.CreateAccount()
+	seed, _ := account.Seed()
+
+	credentials, _ := NewCredentialsService(slog.Default())
+	config, _ := json.Marshal(map[string]any{
+		"account_signing_seed": string(seed),
+		"allow_unrevoked":      true,
+	})
+
+	err := credentials.Initialize(config)
+	if err != nil {
+		t.Fatalf("Failed to initialize credentials service: %s", err)
+	}
+
+	return credentials
+}
+
+func TestCredentialsRequireRevocation(t *testing.T) {
+	account, _ := nkeys.CreateAccount()
+	seed, _ := account.Seed()
+
+	credentials, _ := NewCredentialsService(slog.Default())
+	config, _ := json.Marshal(map[string]any{"account_signing_seed": string(seed)})
+
+	err := credentials.Initialize(config)
+	if err == nil {
+		t.Fatal("Expected the credentials service to refuse to start without a revocation configuration")
+	}
+}
+
+func TestCredentialsScopeInboxToUser(t *testing.T) {
+	credentials := newTestCredentialsService(t)
+
+	request, _ := json.Marshal(&agentapi.HostServicesCredentialsRequest{
+		Buckets: []string{"hs_" + testNamespace + "_settings"},
+	})
+
+	result, err := credentials.handleMint(testNamespace, testWorkloadId, testWorkload, request)
+	if err != nil || result.IsError() {
+		t.Fatalf("Expected credentials to be minted: %v %+v", err, result)
+	}
+
+	var response agentapi.HostServicesCredentialsResponse
+	_ = json.Unmarshal(result.Data, &response)
+
+	claims, err := jwt.DecodeUserClaims(response.Jwt)
+	if err != nil {
+		t.Fatalf("Failed to decode minted credentials: %s", err)
+	}
+
+	if response.InboxPrefix != "_INBOX_"+claims.Subject {
+		t.Fatalf("Expected the inbox prefix to name the user, got %s", response.InboxPrefix)
+	}
+
+	for _, subject := range claims.Sub.Allow {
+		if strings.HasPrefix(subject, "_INBOX.") {
+			t.Fatalf("Expected no access to the shared inbox, got %s", subject)
+		}
+	}
+	if !claims.Sub.Allow.Contains(response.InboxPrefix + ".>") {
+		t.Fatalf("Expected replies to be allowed under %s, got %v", response.InboxPrefix, claims.Sub.Allow)
+	}
+}
+
+func TestCredentialsRejectAssetsOutsideNamespace(t *testing.T) {
+	credentials := newTestCredentialsService(t)
+
+	request, _ := json.Marshal(&agentapi.HostServicesCredentialsRequest{
+		Streams: []string{"hs_otherspace_orders"},
+	})
+
+	result, _ := credentials.handleMint(testNamespace, testWorkloadId, testWorkload, request)
+	if !result.IsError() {
+		t.Fatal("Expected credentials for another namespace's stream to be refused")
+	}
+}
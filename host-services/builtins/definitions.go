@@ -0,0 +1,133 @@
+package builtins
+
+import (
+	"reflect"
+
+	"github.com/nats-io/nats.go"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+// How the data of a built-in host service's result is handed back to a JavaScript workload
+const (
+	// the raw bytes of the result, as a Uint8Array
+	DecodeBytes = "bytes"
+
+	// the result parsed as JSON
+	DecodeJSON = "json"
+
+	// nothing, once the result's success flag and errors have been checked
+	DecodeStatus = "status"
+
+	// the result parsed as an HTTP response, once its error has been checked
+	DecodeHTTP = "http"
+)
+
+// Describes a method of a built-in host service as offered to JavaScript workloads. The bindings
+// installed in the v8 runtime and the TypeScript definitions shipped to workload authors are both
+// generated from these definitions, so they cannot drift from the services themselves
+type MethodDefinition struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+
+	// Object and function through which the method is called, e.g. nex.kv.get
+	Binding  string `json:"binding"`
+	Function string `json:"function"`
+
+	Params []ParamDefinition `json:"params"`
+	Decode string            `json:"decode"`
+
+	// Type of the parsed result when decoded as JSON or as an HTTP response
+	Result reflect.Type `json:"-"`
+
+	Description string `json:"-"`
+}
+
+// Describes a parameter of a built-in host service method. Parameters are passed as the metadata
+// header they name, or as the payload of the call when they name none
+type ParamDefinition struct {
+	Name     string `json:"name"`
+	Header   string `json:"header,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+}
+
+var (
+	keyParam         = ParamDefinition{Name: "key", Header: agentapi.KeyValueKeyHeader}
+	subjectParam     = ParamDefinition{Name: "subject", Header: agentapi.MessagingSubjectHeader}
+	objectNameParam  = ParamDefinition{Name: "name", Header: agentapi.ObjectStoreObjectNameHeader}
+	urlParam         = ParamDefinition{Name: "url", Header: agentapi.HttpURLHeader}
+	payloadParam     = ParamDefinition{Name: "payload"}
+	httpPayloadParam = ParamDefinition{Name: "payload", Optional: true}
+)
+
+// Returns the methods of the built-in host services offered to JavaScript workloads
+func Definitions() []MethodDefinition {
+	definitions := []MethodDefinition{
+		{
+			Service: builtinServiceNameKeyValue, Method: kvServiceMethodGet, Binding: "kv", Function: "get",
+			Params: []ParamDefinition{keyParam}, Decode: DecodeBytes,
+			Description: "Reads the value of a key in the workload's bucket",
+		},
+		{
+			Service: builtinServiceNameKeyValue, Method: kvServiceMethodSet, Binding: "kv", Function: "set",
+			Params: []ParamDefinition{keyParam, payloadParam}, Decode: DecodeStatus,
+			Description: "Sets the value of a key in the workload's bucket",
+		},
+		{
+			Service: builtinServiceNameKeyValue, Method: kvServiceMethodDelete, Binding: "kv", Function: "delete",
+			Params: []ParamDefinition{keyParam}, Decode: DecodeStatus,
+			Description: "Deletes a key from the workload's bucket",
+		},
+		{
+			Service: builtinServiceNameKeyValue, Method: kvServiceMethodKeys, Binding: "kv", Function: "keys",
+			Decode: DecodeJSON, Result: reflect.TypeOf([]string{}),
+			Description: "Lists the keys of the workload's bucket",
+		},
+		{
+			Service: builtinServiceNameMessaging, Method: messagingServiceMethodPublish, Binding: "messaging", Function: "publish",
+			Params: []ParamDefinition{subjectParam, payloadParam}, Decode: DecodeStatus,
+			Description: "Publishes a message",
+		},
+		{
+			Service: builtinServiceNameMessaging, Method: messagingServiceMethodRequest, Binding: "messaging", Function: "request",
+			Params: []ParamDefinition{subjectParam, payloadParam}, Decode: DecodeBytes,
+			Description: "Sends a request and returns the reply",
+		},
+		{
+			Service: builtinServiceNameObjectStore, Method: objectStoreServiceMethodGet, Binding: "objectStore", Function: "get",
+			Params: []ParamDefinition{objectNameParam}, Decode: DecodeBytes,
+			Description: "Reads an object from the workload's object store",
+		},
+		{
+			Service: builtinServiceNameObjectStore, Method: objectStoreServiceMethodPut, Binding: "objectStore", Function: "put",
+			Params: []ParamDefinition{objectNameParam, payloadParam}, Decode: DecodeJSON, Result: reflect.TypeOf(nats.ObjectInfo{}),
+			Description: "Writes an object to the workload's object store",
+		},
+		{
+			Service: builtinServiceNameObjectStore, Method: objectStoreServiceMethodDelete, Binding: "objectStore", Function: "delete",
+			Params: []ParamDefinition{objectNameParam}, Decode: DecodeStatus,
+			Description: "Deletes an object from the workload's object store",
+		},
+		{
+			Service: builtinServiceNameObjectStore, Method: objectStoreServiceMethodList, Binding: "objectStore", Function: "list",
+			Decode: DecodeJSON, Result: reflect.TypeOf([]nats.ObjectInfo{}),
+			Description: "Lists the objects in the workload's object store",
+		},
+	}
+
+	for _, method := range []string{
+		httpServiceMethodGet,
+		httpServiceMethodPost,
+		httpServiceMethodPut,
+		httpServiceMethodPatch,
+		httpServiceMethodDelete,
+		httpServiceMethodHead,
+	} {
+		definitions = append(definitions, MethodDefinition{
+			Service: builtinServiceNameHttpClient, Method: method, Binding: "http", Function: method,
+			Params: []ParamDefinition{urlParam, httpPayloadParam}, Decode: DecodeHTTP, Result: reflect.TypeOf(agentapi.HostServicesHTTPResponse{}),
+			Description: "Sends an HTTP " + method + " request",
+		})
+	}
+
+	return definitions
+}
@@ -24,6 +24,12 @@ type MessagingService struct {
 	log *slog.Logger
 
 	config messagingConfig
+
+	// Connection to the node's internal NATS server, through which messages are sent to the
+	// node's own function workloads under the local prefix while a workload's host services
+	// connection is down
+	local       *nats.Conn
+	localPrefix string
 }
 
 type messagingConfig struct {
@@ -39,6 +45,23 @@ func NewMessagingService(log *slog.Logger) (*MessagingService, error) {
 	return messaging, nil
 }
 
+// Sets the internal connection and subject prefix used while a workload's host services
+// connection is down, so that the functions deployed on the same node can still be triggered
+func (m *MessagingService) SetLocalConnection(nc *nats.Conn, subjectPrefix string) {
+	m.local = nc
+	m.localPrefix = subjectPrefix
+}
+
+// Returns the connection on which to send a message to the given subject, and the subject to
+// send it to, falling back to the local connection while the workload's own is down
+func (m *MessagingService) route(nc *nats.Conn, subject string) (*nats.Conn, string) {
+	if m.local == nil || nc.IsConnected() {
+		return nc, subject
+	}
+
+	return m.local, fmt.Sprintf("%s.%s", m.localPrefix, subject)
+}
+
 func (m *MessagingService) Initialize(config json.RawMessage) error {
 
 	m.config.RequestManyTimeoutMs = defaultMessagingRequestManyTimeout
@@ -90,7 +113,8 @@ func (m *MessagingService) handlePublish(
 		return hostservices.ServiceResultFail(500, "subject is required"), nil
 	}
 
-	err := nc.Publish(subject, data)
+	nc, routed := m.route(nc, subject)
+	err := nc.Publish(routed, data)
 	if err != nil {
 		m.log.Warn(fmt.Sprintf("failed to publish %d-byte message on subject %s: %s", len(data), subject, err.Error()))
 		return hostservices.ServiceResultFail(500, "failed to publish message"), nil
@@ -113,7 +137,8 @@ func (m *MessagingService) handleRequest(
 		return hostservices.ServiceResultFail(400, "subject is required"), nil
 	}
 
-	resp, err := nc.Request(subject, data, time.Duration(m.config.RequestTimeoutMs*int64(time.Millisecond)))
+	nc, routed := m.route(nc, subject)
+	resp, err := nc.Request(routed, data, time.Duration(m.config.RequestTimeoutMs*int64(time.Millisecond)))
 	if err != nil {
 		m.log.Debug(fmt.Sprintf("failed to send %d-byte request on subject %s: %s", len(data), subject, err.Error()))
 		return hostservices.ServiceResultFail(500, "failed to send request"), nil
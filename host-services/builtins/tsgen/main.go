@@ -0,0 +1,23 @@
+// Writes the TypeScript definitions of the nex global offered to JavaScript workloads, which are
+// generated from the built-in host service definitions
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/synadia-io/nex/host-services/builtins"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: tsgen <output file>")
+		os.Exit(1)
+	}
+
+	err := os.WriteFile(os.Args[1], []byte(builtins.TypeScriptDefinitions()), 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write TypeScript definitions: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,212 @@
+package builtins
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const typeScriptHeader = `// Code generated from the built-in host service definitions. DO NOT EDIT.
+
+// The nex global offered to JavaScript workloads run by the v8 execution provider. Every call
+// returns a promise, which is rejected with a NexError when the host service fails
+declare namespace nex {
+  interface CallOptions {
+    /** Deadline of the call in milliseconds, overriding the agent's default */
+    timeoutMs?: number;
+  }
+
+  /** Strings are sent as their UTF-8 bytes */
+  type Payload = Uint8Array | string;
+
+  class NexError extends Error {
+    readonly service: string;
+    readonly method: string;
+    readonly code: number;
+  }
+
+  /** The call's deadline passed before the host service answered */
+  class TimeoutError extends NexError {}
+
+  /** The workload exhausted its host services budget */
+  class ThrottledError extends NexError {
+    readonly retryAfterMs: number;
+  }
+`
+
+// Returns the TypeScript definitions of the nex global offered to JavaScript workloads
+func TypeScriptDefinitions() string {
+	definitions := Definitions()
+
+	gen := &typeScriptGenerator{interfaces: make(map[string]reflect.Type)}
+
+	bindings := make(map[string][]string)
+	order := []string{}
+	for _, def := range definitions {
+		if _, ok := bindings[def.Binding]; !ok {
+			order = append(order, def.Binding)
+		}
+
+		params := make([]string, 0, len(def.Params)+1)
+		for _, param := range def.Params {
+			typ := "Payload"
+			if param.Header != "" {
+				typ = "string"
+			}
+
+			optional := ""
+			if param.Optional {
+				optional = "?"
+			}
+			params = append(params, fmt.Sprintf("%s%s: %s", param.Name, optional, typ))
+		}
+		params = append(params, "options?: CallOptions")
+
+		var result string
+		switch def.Decode {
+		case DecodeBytes:
+			result = "Uint8Array"
+		case DecodeStatus:
+			result = "void"
+		default:
+			result = gen.typeOf(def.Result)
+		}
+
+		bindings[def.Binding] = append(bindings[def.Binding],
+			fmt.Sprintf("    /** %s */\n    %s(%s): Promise<%s>;", def.Description, def.Function, strings.Join(params, ", "), result),
+		)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(typeScriptHeader)
+
+	for _, name := range gen.sortedInterfaces() {
+		sb.WriteString("\n")
+		sb.WriteString(gen.interfaceOf(name, gen.interfaces[name]))
+	}
+
+	for _, binding := range order {
+		sb.WriteString(fmt.Sprintf("\n  const %s: {\n", binding))
+		sb.WriteString(strings.Join(bindings[binding], "\n"))
+		sb.WriteString("\n  };\n")
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+type typeScriptGenerator struct {
+	interfaces map[string]reflect.Type
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Returns the TypeScript type of the JSON encoding of the given Go type, collecting the structs
+// it refers to as interfaces
+func (g *typeScriptGenerator) typeOf(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return "string"
+	case t == rawMessageType:
+		return "unknown"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string" // base64
+		}
+		return g.typeOf(t.Elem()) + "[]"
+	case reflect.Map:
+		return fmt.Sprintf("Record<string, %s>", g.typeOf(t.Elem()))
+	case reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "HostServices")
+		if _, ok := g.interfaces[name]; !ok {
+			g.interfaces[name] = t
+			g.fieldsOf(t) // collect the structs the fields refer to
+		}
+		return name
+	default:
+		return "unknown"
+	}
+}
+
+func (g *typeScriptGenerator) sortedInterfaces() []string {
+	names := make([]string, 0, len(g.interfaces))
+	for name := range g.interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (g *typeScriptGenerator) interfaceOf(name string, t reflect.Type) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("  interface %s {\n", name))
+	for _, field := range g.fieldsOf(t) {
+		sb.WriteString(fmt.Sprintf("    %s;\n", field))
+	}
+	sb.WriteString("  }\n")
+	return sb.String()
+}
+
+// Returns the fields of the JSON encoding of the given struct, flattening embedded structs as
+// encoding/json does
+func (g *typeScriptGenerator) fieldsOf(t reflect.Type) []string {
+	fields := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields = append(fields, g.fieldsOf(embedded)...)
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		optional := ""
+		if field.Type.Kind() == reflect.Pointer || strings.Contains(opts, "omitempty") {
+			optional = "?"
+		}
+
+		fields = append(fields, fmt.Sprintf("%s%s: %s", name, optional, g.typeOf(field.Type)))
+	}
+
+	return fields
+}
@@ -0,0 +1,32 @@
+package builtins
+
+import (
+	"os"
+	"testing"
+)
+
+func TestTypeScriptDefinitionsAreCurrent(t *testing.T) {
+	committed, err := os.ReadFile("../../agent/providers/lib/v8/nex.d.ts")
+	if err != nil {
+		t.Fatalf("Failed to read TypeScript definitions: %s", err)
+	}
+
+	if string(committed) != TypeScriptDefinitions() {
+		t.Fatal("TypeScript definitions are out of date; run go generate ./agent/providers/lib")
+	}
+}
+
+func TestDefinitionsAreUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, def := range Definitions() {
+		binding := def.Binding + "." + def.Function
+		if seen[binding] {
+			t.Fatalf("Binding %s is defined more than once", binding)
+		}
+		seen[binding] = true
+
+		if def.Decode != DecodeBytes && def.Decode != DecodeStatus && def.Result == nil {
+			t.Fatalf("Binding %s decodes its result without a result type", binding)
+		}
+	}
+}
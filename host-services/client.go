@@ -47,7 +47,14 @@ func (c *HostServicesClient) PerformRPC(ctx context.Context, service string, met
 
 	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(msg.Header))
 
-	result, err := c.ncInternal.RequestMsg(msg, c.timeout)
+	// calls with a deadline of their own wait until it rather than for the client's timeout
+	var result *nats.Msg
+	var err error
+	if _, ok := ctx.Deadline(); ok {
+		result, err = c.ncInternal.RequestMsgWithContext(ctx, msg)
+	} else {
+		result, err = c.ncInternal.RequestMsg(msg, c.timeout)
+	}
 	if err != nil {
 		return ServiceResult{}, err
 	}
@@ -61,5 +68,14 @@ func (c *HostServicesClient) PerformRPC(ctx context.Context, service string, met
 		Code:    uint(iCode),
 	}
 
+	if iCode == codeThrottled {
+		retryAfter, _ := strconv.ParseInt(result.Header.Get(headerRetryAfter), 10, 64)
+		return serviceResult, &ThrottleError{
+			Service:    service,
+			Reason:     result.Header.Get(headerThrottleReason),
+			RetryAfter: time.Duration(retryAfter) * time.Millisecond,
+		}
+	}
+
 	return serviceResult, nil
 }
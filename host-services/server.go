@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
 
 	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -21,6 +24,10 @@ type HostServicesServer struct {
 	// Every single workload gets its own private host services connection,
 	// even if it's reusing defaults for config
 	hsClientConnections map[string]*nats.Conn
+	connMutex           sync.RWMutex
+
+	budgets   *budgets
+	throttled metric.Int64Counter
 
 	tracer trace.Tracer
 }
@@ -31,22 +38,62 @@ func NewHostServicesServer(ncInternal *nats.Conn, log *slog.Logger, tracer trace
 		ncInternal:          ncInternal,
 		services:            make(map[string]HostService),
 		hsClientConnections: make(map[string]*nats.Conn),
+		budgets:             newBudgets(),
 		tracer:              tracer,
 	}
 }
 
 func (h *HostServicesServer) SetHostServicesConnection(workloadId string, nc *nats.Conn) {
 	h.RemoveHostServicesConnection(workloadId)
+
+	h.connMutex.Lock()
+	defer h.connMutex.Unlock()
 	h.hsClientConnections[workloadId] = nc
 }
 
+// Returns the private host services connection of the given workload, if it has one
+func (h *HostServicesServer) HostServicesConnection(workloadId string) (*nats.Conn, bool) {
+	h.connMutex.RLock()
+	defer h.connMutex.RUnlock()
+
+	nc, ok := h.hsClientConnections[workloadId]
+	return nc, ok
+}
+
 func (h *HostServicesServer) RemoveHostServicesConnection(workloadId string) {
+	h.connMutex.Lock()
+	defer h.connMutex.Unlock()
+
 	if c, ok := h.hsClientConnections[workloadId]; ok {
 		_ = c.Drain()
 		delete(h.hsClientConnections, workloadId)
 	}
 }
 
+// Sets the budgets enforced on the given workload's host service calls, replacing any
+// previously set for it
+func (h *HostServicesServer) SetWorkloadBudgets(workloadId string, budgets map[string]controlapi.HostServiceBudget) {
+	h.budgets.set(workloadId, budgets)
+}
+
+// Sets the counter incremented each time a host service call is throttled
+func (h *HostServicesServer) SetThrottleCounter(counter metric.Int64Counter) {
+	h.throttled = counter
+}
+
+// Releases everything held on behalf of a stopped workload, including its host services
+// connection, its budgets and any per-workload state kept by individual services
+func (h *HostServicesServer) RemoveWorkload(workloadId string) {
+	h.RemoveHostServicesConnection(workloadId)
+	h.budgets.remove(workloadId)
+
+	for _, svc := range h.services {
+		if remover, ok := svc.(WorkloadRemover); ok {
+			remover.RemoveWorkload(workloadId)
+		}
+	}
+}
+
 func (h *HostServicesServer) Services() []string {
 	result := make([]string, 0)
 	for k := range h.services {
@@ -106,6 +153,27 @@ func (h *HostServicesServer) handleRPC(msg *nats.Msg) {
 		return
 	}
 
+	if throttle := h.budgets.consume(vmID, serviceName); throttle != nil {
+		h.log.Debug("Throttled host service RPC request",
+			slog.String("workload_id", vmID),
+			slog.String("workload_name", workloadName),
+			slog.String("service_name", serviceName),
+			slog.String("reason", throttle.Reason),
+		)
+
+		if h.throttled != nil {
+			h.throttled.Add(context.Background(), 1, metric.WithAttributes(
+				attribute.String("namespace", namespace),
+				attribute.String("workload_name", workloadName),
+				attribute.String("service", serviceName),
+				attribute.String("reason", throttle.Reason),
+			))
+		}
+
+		_ = msg.RespondMsg(serverThrottleMessage(msg.Reply, throttle))
+		return
+	}
+
 	metadata := make(map[string]string, 0)
 	for k, v := range msg.Header {
 		metadata[k] = v[0]
@@ -124,7 +192,7 @@ func (h *HostServicesServer) handleRPC(msg *nats.Msg) {
 
 	span.AddEvent("RPC Request Began")
 
-	requestConnection := h.hsClientConnections[vmID]
+	requestConnection, _ := h.HostServicesConnection(vmID)
 
 	result, err := service.HandleRequest(requestConnection, namespace, vmID, method, workloadName, metadata, msg.Data)
 	if err != nil {
@@ -156,6 +224,14 @@ func serverFailMessage(reply string, code uint, message string) *nats.Msg {
 	return msg
 }
 
+func serverThrottleMessage(reply string, throttle *ThrottleError) *nats.Msg {
+	msg := serverFailMessage(reply, codeThrottled, throttle.Error())
+	msg.Header.Set(headerThrottleReason, throttle.Reason)
+	msg.Header.Set(headerRetryAfter, fmt.Sprintf("%d", throttle.RetryAfter.Milliseconds()))
+
+	return msg
+}
+
 func serverSuccessMessage(reply string, code uint, data []byte, message string) *nats.Msg {
 	msg := nats.NewMsg(reply)
 	msg.Header.Set(headerCode, fmt.Sprintf("%d", code))
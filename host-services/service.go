@@ -56,3 +56,9 @@ type HostService interface {
 		request []byte,
 	) (ServiceResult, error)
 }
+
+// Host services that hold state on behalf of individual workloads can implement this
+// interface to be notified when a workload is stopped
+type WorkloadRemover interface {
+	RemoveWorkload(workloadId string)
+}
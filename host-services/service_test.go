@@ -11,6 +11,7 @@ import (
 
 	"github.com/nats-io/nats-server/v2/server"
 	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
 	"go.opentelemetry.io/otel/trace/noop"
 )
 
@@ -112,6 +113,40 @@ func TestServiceError(t *testing.T) {
 	}
 }
 
+func TestServiceBudgetThrottles(t *testing.T) {
+	nc, teardownSuite := setupSuite(t, 4449)
+	defer teardownSuite(t)
+
+	server := NewHostServicesServer(nc, slog.Default(), noop.NewTracerProvider().Tracer("nex-node"))
+	client := NewHostServicesClient(nc, 2*time.Second, testNamespace, testWorkload, testWorkloadId)
+
+	_ = server.AddService("boguss", &bogusService{code: 200}, []byte{})
+	server.SetWorkloadBudgets(testWorkloadId, map[string]controlapi.HostServiceBudget{
+		"boguss": {Credits: 1},
+	})
+
+	err := server.Start()
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = client.PerformRPC(context.Background(), "boguss", "test", []byte{}, make(map[string]string))
+	if err != nil {
+		t.Fatalf("Expected first call to be admitted by budget: %s", err)
+	}
+
+	result, err := client.PerformRPC(context.Background(), "boguss", "test", []byte{}, make(map[string]string))
+
+	var throttle *ThrottleError
+	if !errors.As(err, &throttle) || throttle.Reason != ThrottleReasonCredits {
+		t.Fatalf("Expected credits throttle error, got %v", err)
+	}
+
+	if result.Code != codeThrottled {
+		t.Fatalf("Was supposed to get a %d, got %d", codeThrottled, result.Code)
+	}
+}
+
 type bogusService struct {
 	config  json.RawMessage
 	code    uint
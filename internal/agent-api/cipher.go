@@ -0,0 +1,40 @@
+package agentapi
+
+import (
+	"github.com/nats-io/nkeys"
+)
+
+// Seals and opens payloads exchanged between a node and an agent over the internal NATS
+// connection, using the curve (xkey) keys the two exchanged during the handshake. A nil cipher,
+// as used with peers that predate payload encryption, passes payloads through unchanged
+type PayloadCipher struct {
+	kp   nkeys.KeyPair
+	peer string
+}
+
+// Creates a cipher sealing payloads from the owner of the given curve keypair to the peer with
+// the given public xkey
+func NewPayloadCipher(kp nkeys.KeyPair, peer string) *PayloadCipher {
+	return &PayloadCipher{
+		kp:   kp,
+		peer: peer,
+	}
+}
+
+// Encrypts the payload for the peer
+func (c *PayloadCipher) Seal(data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+
+	return c.kp.Seal(data, c.peer)
+}
+
+// Decrypts a payload sealed by the peer
+func (c *PayloadCipher) Open(data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+
+	return c.kp.Open(data, c.peer)
+}
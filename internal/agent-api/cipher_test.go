@@ -0,0 +1,102 @@
+package agentapi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nats-io/nkeys"
+)
+
+func newCipherPair(t *testing.T) (*PayloadCipher, *PayloadCipher) {
+	node, err := nkeys.CreateCurveKeys()
+	if err != nil {
+		t.Fatalf("Failed to create node xkey: %s", err)
+	}
+	agent, err := nkeys.CreateCurveKeys()
+	if err != nil {
+		t.Fatalf("Failed to create agent xkey: %s", err)
+	}
+
+	nodePub, _ := node.PublicKey()
+	agentPub, _ := agent.PublicKey()
+
+	return NewPayloadCipher(node, agentPub), NewPayloadCipher(agent, nodePub)
+}
+
+func TestPayloadCipherRoundTrip(t *testing.T) {
+	node, agent := newCipherPair(t)
+	payload := []byte(`{"workload_name":"echo"}`)
+
+	sealed, err := node.Seal(payload)
+	if err != nil {
+		t.Fatalf("Failed to seal payload: %s", err)
+	}
+	if bytes.Contains(sealed, payload) {
+		t.Fatal("Expected the sealed payload not to contain the plaintext")
+	}
+
+	opened, err := agent.Open(sealed)
+	if err != nil {
+		t.Fatalf("Failed to open payload: %s", err)
+	}
+	if !bytes.Equal(opened, payload) {
+		t.Fatalf("Expected %q, got %q", payload, opened)
+	}
+
+	reply, err := agent.Seal([]byte("ok"))
+	if err != nil {
+		t.Fatalf("Failed to seal reply: %s", err)
+	}
+	opened, err = node.Open(reply)
+	if err != nil || string(opened) != "ok" {
+		t.Fatalf("Expected the node to open the agent's reply, got %q (%v)", opened, err)
+	}
+}
+
+func TestPayloadCipherRejectsTamperedPayload(t *testing.T) {
+	node, agent := newCipherPair(t)
+
+	sealed, err := node.Seal([]byte("trigger payload"))
+	if err != nil {
+		t.Fatalf("Failed to seal payload: %s", err)
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 0xff
+	if _, err := agent.Open(tampered); err == nil {
+		t.Fatal("Expected a tampered payload not to open")
+	}
+
+	if _, err := agent.Open(sealed[:len(sealed)-1]); err == nil {
+		t.Fatal("Expected a truncated payload not to open")
+	}
+}
+
+func TestPayloadCipherRejectsOtherPeers(t *testing.T) {
+	node, _ := newCipherPair(t)
+	_, stranger := newCipherPair(t)
+
+	sealed, err := node.Seal([]byte("deploy request"))
+	if err != nil {
+		t.Fatalf("Failed to seal payload: %s", err)
+	}
+
+	if _, err := stranger.Open(sealed); err == nil {
+		t.Fatal("Expected a payload sealed for another peer not to open")
+	}
+}
+
+func TestNilPayloadCipherPassesPayloadsThrough(t *testing.T) {
+	var cipher *PayloadCipher
+	payload := []byte("plaintext")
+
+	sealed, err := cipher.Seal(payload)
+	if err != nil || !bytes.Equal(sealed, payload) {
+		t.Fatalf("Expected a nil cipher to leave the payload unchanged, got %q (%v)", sealed, err)
+	}
+
+	opened, err := cipher.Open(payload)
+	if err != nil || !bytes.Equal(opened, payload) {
+		t.Fatalf("Expected a nil cipher to leave the payload unchanged, got %q (%v)", opened, err)
+	}
+}
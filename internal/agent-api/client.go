@@ -8,11 +8,14 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
@@ -34,6 +37,13 @@ const (
 	MessagingSubjectHeader = "x-subject"
 
 	ObjectStoreObjectNameHeader = "x-object-name"
+
+	// Swapping in a new artifact can involve compiling it, so the agent is given longer to
+	// acknowledge a hot reload than a ping
+	hotReloadTimeout = 10 * time.Second
+
+	// How often an agent restored from a checkpoint is pinged until it has reconnected
+	resumePingInterval = 500 * time.Millisecond
 )
 
 type AgentClient struct {
@@ -41,10 +51,13 @@ type AgentClient struct {
 	log               *slog.Logger
 	agentID           string
 	handshakeTimeout  time.Duration
-	handshakeReceived *atomic.Bool
+	handshakeReceived atomic.Bool
 	pingTimeout       time.Duration
 	stopping          uint32
 
+	// Set while the agent's machine is paused, so that its unanswered pings are not taken as lost contact
+	paused atomic.Bool
+
 	handshakeTimedOut  HandshakeCallback
 	handshakeSucceeded HandshakeCallback
 	eventReceived      EventCallback
@@ -54,9 +67,36 @@ type AgentClient struct {
 	execTotalNanos    int64
 	workloadStartedAt time.Time
 
+	// When the agent last answered a health probe, in Unix nanoseconds
+	lastHealthyProbe atomic.Int64
+
+	// Guards what the agent reported during its handshake, which arrives on a NATS callback
+	// while the node may already be reading it
+	handshakeMutex  sync.RWMutex
+	agentVersion    string
+	protocolVersion int
+	capabilities    *controlapi.AgentCapabilities
+
+	// Established during the handshake when the agent supports payload encryption
+	cipher *PayloadCipher
+
 	subz []*nats.Subscription
 }
 
+// What a node and an agent agreed during the agent's handshake, kept in a checkpoint of the
+// agent's machine so that the node restoring the machine can talk to the agent without a new
+// handshake, which a restored agent does not repeat
+type AgentSession struct {
+	AgentVersion    string                        `json:"agent_version"`
+	ProtocolVersion int                           `json:"protocol_version"`
+	Capabilities    *controlapi.AgentCapabilities `json:"capabilities,omitempty"`
+
+	// Seed of the node's curve key sealing payloads for the agent and the agent's public xkey;
+	// empty when the agent does not support payload encryption
+	CipherSeed string `json:"cipher_seed,omitempty"`
+	AgentXKey  string `json:"agent_xkey,omitempty"`
+}
+
 func NewAgentClient(
 	nc *nats.Conn,
 	log *slog.Logger,
@@ -70,7 +110,6 @@ func NewAgentClient(
 	return &AgentClient{
 		contactLost:        onContactLost,
 		eventReceived:      onEvent,
-		handshakeReceived:  &atomic.Bool{},
 		handshakeTimeout:   handshakeTimeout,
 		handshakeTimedOut:  onTimedOut,
 		handshakeSucceeded: onSuccess,
@@ -87,6 +126,67 @@ func (a *AgentClient) ID() string {
 	return a.agentID
 }
 
+// Returns the version the agent reported during its handshake
+func (a *AgentClient) AgentVersion() string {
+	a.handshakeMutex.RLock()
+	defer a.handshakeMutex.RUnlock()
+
+	return a.agentVersion
+}
+
+// Returns the protocol version the agent reported during its handshake, or zero for agents
+// that predate protocol versioning
+func (a *AgentClient) ProtocolVersion() int {
+	a.handshakeMutex.RLock()
+	defer a.handshakeMutex.RUnlock()
+
+	return a.protocolVersion
+}
+
+// Returns the capabilities the agent reported during its handshake, or nil if it did not report any
+func (a *AgentClient) Capabilities() *controlapi.AgentCapabilities {
+	a.handshakeMutex.RLock()
+	defer a.handshakeMutex.RUnlock()
+
+	return a.capabilities
+}
+
+// Indicates whether the agent has completed its handshake
+func (a *AgentClient) Handshook() bool {
+	return a.handshakeReceived.Load()
+}
+
+// Returns an error describing why the agent cannot run a workload of the given type and
+// resources. Agents that have not completed their handshake cannot run any workload; those
+// that completed it without reporting capabilities predate them and are assumed able to run any
+func (a *AgentClient) Supports(workloadType controlapi.NexWorkload, resources *controlapi.WorkloadResources) error {
+	if !a.Handshook() {
+		return errors.New("agent has not completed its handshake")
+	}
+
+	capabilities := a.Capabilities()
+	if capabilities == nil {
+		return nil
+	}
+
+	return capabilities.Supports(workloadType, resources)
+}
+
+// Returns the cipher sealing payloads for the agent, or nil if the agent does not support
+// payload encryption
+func (a *AgentClient) Cipher() *PayloadCipher {
+	a.handshakeMutex.RLock()
+	defer a.handshakeMutex.RUnlock()
+
+	return a.cipher
+}
+
+// Indicates whether this node can deploy workloads to the agent, which requires a completed
+// handshake reporting a supported protocol version
+func (a *AgentClient) Compatible() bool {
+	return a.Handshook() && ProtocolVersionCompatible(a.ProtocolVersion())
+}
+
 // Agent client instances subscribe to the following `hostint.>` subjects,
 // which are exported by the `nexnode` account on the configured internal
 // NATS connection for consumption by agents:
@@ -98,16 +198,102 @@ func (a *AgentClient) Start(agentID string) error {
 	a.log.Info("Agent client starting", slog.String("agent_id", agentID))
 	a.agentID = agentID
 
-	var sub *nats.Subscription
-	var err error
-
-	sub, err = a.nc.Subscribe(fmt.Sprintf("hostint.%s.handshake", agentID), a.handleHandshake)
+	sub, err := a.nc.Subscribe(fmt.Sprintf("hostint.%s.handshake", agentID), a.handleHandshake)
 	if err != nil {
 		return err
 	}
 	a.subz = append(a.subz, sub)
 
-	sub, err = a.nc.Subscribe(fmt.Sprintf("hostint.%s.events.*", agentID), a.handleAgentEvent)
+	err = a.subscribeAgentOutput(agentID)
+	if err != nil {
+		return err
+	}
+
+	go a.awaitHandshake(agentID)
+
+	return nil
+}
+
+// Attaches the client to an agent whose machine was restored from a checkpoint, using the session
+// agreed during the agent's original handshake. The agent keeps running its workload, so the client
+// treats the workload as freshly deployed once the agent has reconnected and answered a ping,
+// which it must do within the given timeout
+func (a *AgentClient) Resume(agentID string, session *AgentSession, timeout time.Duration) error {
+	a.log.Info("Agent client resuming", slog.String("agent_id", agentID))
+	a.agentID = agentID
+
+	var cipher *PayloadCipher
+	if session.CipherSeed != "" {
+		kp, err := nkeys.FromCurveSeed([]byte(session.CipherSeed))
+		if err != nil {
+			return fmt.Errorf("invalid agent session cipher: %s", err)
+		}
+		cipher = NewPayloadCipher(kp, session.AgentXKey)
+	}
+
+	err := a.subscribeAgentOutput(agentID)
+	if err != nil {
+		return err
+	}
+
+	a.handshakeMutex.Lock()
+	a.agentVersion = session.AgentVersion
+	a.protocolVersion = session.ProtocolVersion
+	a.capabilities = session.Capabilities
+	a.cipher = cipher
+	a.handshakeMutex.Unlock()
+	a.handshakeReceived.Store(true)
+
+	// the agent only notices that its connection to the previous node is gone, and reconnects,
+	// once the restored machine's network is up again
+	subject := fmt.Sprintf("agentint.%s.ping", agentID)
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err = a.nc.Request(subject, []byte{}, a.pingTimeout)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("restored agent did not reconnect: %s", err)
+		}
+		time.Sleep(resumePingInterval)
+	}
+
+	a.workloadStartedAt = time.Now().UTC()
+	a.lastHealthyProbe.Store(time.Now().UnixNano())
+	go a.monitorAgent()
+
+	return nil
+}
+
+// Returns the session agreed during the agent's handshake
+func (a *AgentClient) Session() (*AgentSession, error) {
+	if !a.Handshook() {
+		return nil, errors.New("agent has not completed its handshake")
+	}
+
+	a.handshakeMutex.RLock()
+	defer a.handshakeMutex.RUnlock()
+
+	session := &AgentSession{
+		AgentVersion:    a.agentVersion,
+		ProtocolVersion: a.protocolVersion,
+		Capabilities:    a.capabilities,
+	}
+	if a.cipher != nil {
+		seed, err := a.cipher.kp.Seed()
+		if err != nil {
+			return nil, err
+		}
+		session.CipherSeed = string(seed)
+		session.AgentXKey = a.cipher.peer
+	}
+
+	return session, nil
+}
+
+func (a *AgentClient) subscribeAgentOutput(agentID string) error {
+	sub, err := a.nc.Subscribe(fmt.Sprintf("hostint.%s.events.*", agentID), a.handleAgentEvent)
 	if err != nil {
 		return err
 	}
@@ -119,8 +305,6 @@ func (a *AgentClient) Start(agentID string) error {
 	}
 	a.subz = append(a.subz, sub)
 
-	go a.awaitHandshake(agentID)
-
 	return nil
 }
 
@@ -130,6 +314,11 @@ func (a *AgentClient) DeployWorkload(request *DeployRequest) (*DeployResponse, e
 		return nil, err
 	}
 
+	bytes, err = a.Cipher().Seal(bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal workload deployment request: %s", err)
+	}
+
 	status := a.nc.Status()
 	a.log.Debug("NATS internal connection status",
 		slog.String("agent_id", a.agentID),
@@ -153,6 +342,12 @@ func (a *AgentClient) DeployWorkload(request *DeployRequest) (*DeployResponse, e
 	}
 
 	a.workloadStartedAt = time.Now().UTC()
+
+	// probe right away rather than on the next tick, so that readiness is known promptly
+	go func() {
+		_ = a.probeHealth()
+	}()
+
 	return &deployResponse, nil
 }
 
@@ -191,6 +386,13 @@ func (a *AgentClient) Stop() error {
 }
 
 func (a *AgentClient) Undeploy() error {
+	_, err := a.UndeployWithGracePeriod(0)
+	return err
+}
+
+// Asks the agent to undeploy its workload, allowing the workload up to the given grace period to
+// exit. Returns true if the agent confirmed the workload exited within the grace period
+func (a *AgentClient) UndeployWithGracePeriod(gracePeriod time.Duration) (bool, error) {
 	_ = a.Stop()
 
 	subject := fmt.Sprintf("agentint.%s.undeploy", a.agentID)
@@ -198,15 +400,29 @@ func (a *AgentClient) Undeploy() error {
 	a.log.Debug("sending undeploy request to agent via internal NATS connection",
 		slog.String("subject", subject),
 		slog.String("agent_id", a.agentID),
+		slog.Duration("grace_period", gracePeriod),
 	)
 
-	_, err := a.nc.Request(subject, []byte{}, 500*time.Millisecond) // FIXME-- allow this timeout to be configurable... 500ms is likely not enough
+	req, _ := json.Marshal(&UndeployRequest{GracePeriodMillis: gracePeriod.Milliseconds()})
+
+	// FIXME-- allow this timeout to be configurable... 500ms is likely not enough
+	resp, err := a.nc.Request(subject, req, 500*time.Millisecond+gracePeriod)
 	if err != nil {
 		a.log.Warn("request to undeploy workload via internal NATS connection failed", slog.String("agent_id", a.agentID), slog.String("error", err.Error()))
-		return err
+		return false, err
 	}
 
-	return nil
+	if len(resp.Data) == 0 {
+		return false, nil
+	}
+
+	var response UndeployResponse
+	err = json.Unmarshal(resp.Data, &response)
+	if err != nil {
+		return false, err
+	}
+
+	return response.Exited, nil
 }
 
 func (a *AgentClient) Ping() error {
@@ -222,6 +438,62 @@ func (a *AgentClient) Ping() error {
 	return nil
 }
 
+// Hands the agent new credentials for the internal NATS server, returning once the agent has
+// acknowledged that it will use them
+func (a *AgentClient) RotateCredentials(seed []byte) error {
+	subject := fmt.Sprintf("agentint.%s.rotatecreds", a.agentID)
+
+	req, _ := json.Marshal(&CredentialsRotationRequest{NkeySeed: string(seed)})
+	req, err := a.Cipher().Seal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.nc.Request(subject, req, a.pingTimeout)
+	if err != nil {
+		return err
+	}
+
+	var response CredentialsRotationResponse
+	err = json.Unmarshal(resp.Data, &response)
+	if err != nil || !response.Rotated {
+		return errors.New("agent did not accept rotated credentials")
+	}
+
+	return nil
+}
+
+// Asks the agent to swap its running workload's artifact for the one now cached under the
+// workload key, returning once the agent has verified and loaded it
+func (a *AgentClient) HotReload(hash string) error {
+	subject := fmt.Sprintf("agentint.%s.hotreload", a.agentID)
+
+	req, _ := json.Marshal(&HotReloadRequest{Hash: hash})
+	req, err := a.Cipher().Seal(req)
+	if err != nil {
+		return err
+	}
+
+	resp, err := a.nc.Request(subject, req, hotReloadTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to submit hot reload request: %s", err)
+	}
+
+	var response HotReloadResponse
+	err = json.Unmarshal(resp.Data, &response)
+	if err != nil {
+		return err
+	}
+	if !response.Reloaded {
+		if response.Message != nil {
+			return fmt.Errorf("agent did not hot reload workload: %s", *response.Message)
+		}
+		return errors.New("agent did not hot reload workload")
+	}
+
+	return nil
+}
+
 func (a *AgentClient) RecordExecTime(elapsedNanos int64) {
 	atomic.AddInt64(&a.execTotalNanos, elapsedNanos)
 }
@@ -235,10 +507,18 @@ func (a *AgentClient) UptimeMillis() time.Duration {
 	return time.Since(a.workloadStartedAt)
 }
 
-func (a *AgentClient) RunTrigger(ctx context.Context, tracer trace.Tracer, subject string, data []byte) (*nats.Msg, error) {
+// Runs a message received on one of the workload's trigger subjects, forwarding its subject,
+// reply subject and headers to the function. The reply is awaited until the context's deadline,
+// or for 10 seconds if it has none
+func (a *AgentClient) RunTrigger(ctx context.Context, tracer trace.Tracer, msg *nats.Msg) (*nats.Msg, error) {
+	sealed, err := a.Cipher().Seal(msg.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal trigger payload: %s", err)
+	}
+
 	intmsg := nats.NewMsg(fmt.Sprintf("agentint.%s.trigger", a.agentID))
-	intmsg.Header.Add(NexTriggerSubject, subject)
-	intmsg.Data = data
+	encodeTriggerMetadata(msg, intmsg.Header)
+	intmsg.Data = sealed
 
 	cctx, childSpan := tracer.Start(
 		ctx,
@@ -248,10 +528,23 @@ func (a *AgentClient) RunTrigger(ctx context.Context, tracer trace.Tracer, subje
 
 	otel.GetTextMapPropagator().Inject(cctx, propagation.HeaderCarrier(intmsg.Header))
 
-	resp, err := a.nc.RequestMsg(intmsg, time.Millisecond*10000) // FIXME-- make timeout configurable
+	var resp *nats.Msg
+	if _, ok := ctx.Deadline(); ok {
+		resp, err = a.nc.RequestMsgWithContext(ctx, intmsg)
+	} else {
+		resp, err = a.nc.RequestMsg(intmsg, time.Millisecond*10000) // FIXME-- make timeout configurable
+	}
 	childSpan.End()
+	if err != nil {
+		return resp, err
+	}
 
-	return resp, err
+	resp.Data, err = a.Cipher().Open(resp.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trigger reply: %s", err)
+	}
+
+	return resp, nil
 }
 
 func (a *AgentClient) awaitHandshake(agentID string) {
@@ -277,9 +570,54 @@ func (a *AgentClient) handleHandshake(msg *nats.Msg) {
 		return
 	}
 
-	a.log.Info("Received agent handshake", slog.String("agent_id", *req.ID), slog.String("message", *req.Message))
+	a.log.Info("Received agent handshake",
+		slog.String("agent_id", *req.ID),
+		slog.String("message", *req.Message),
+		slog.String("agent_version", req.AgentVersion),
+		slog.Int("protocol_version", req.ProtocolVersion),
+		slog.Any("capabilities", req.Capabilities),
+	)
+
+	handshakeResponse := &HandshakeResponse{
+		ProtocolVersion: AgentProtocolVersion,
+		Compatible:      ProtocolVersionCompatible(req.ProtocolVersion),
+	}
+
+	var cipher *PayloadCipher
+	if req.XKey != "" {
+		kp, err := nkeys.CreateCurveKeys()
+		if err != nil {
+			a.log.Error("Failed to create xkey for agent payload encryption", slog.String("agent_id", *req.ID), slog.Any("err", err))
+			return
+		}
+
+		handshakeResponse.XKey, _ = kp.PublicKey()
+		cipher = NewPayloadCipher(kp, req.XKey)
+	} else {
+		a.log.Warn("Agent does not support payload encryption; deploy and trigger payloads will be sent in the clear",
+			slog.String("agent_id", *req.ID),
+			slog.String("agent_version", req.AgentVersion),
+		)
+	}
+
+	if !handshakeResponse.Compatible {
+		msg := fmt.Sprintf("agent protocol version %d is not supported by this node, which requires a version between %d and %d",
+			req.ProtocolVersion, MinAgentProtocolVersion, AgentProtocolVersion,
+		)
+		if req.ProtocolVersion == 0 {
+			msg = "agent predates protocol versioning; it was likely built into an outdated rootfs image"
+		}
+		handshakeResponse.Message = &msg
+
+		a.log.Error("Incompatible agent will not receive workload deployments; update the rootfs image to match this node",
+			slog.String("agent_id", *req.ID),
+			slog.String("agent_version", req.AgentVersion),
+			slog.Int("protocol_version", req.ProtocolVersion),
+			slog.String("reason", msg),
+		)
+	}
 
-	resp, _ := json.Marshal(&HandshakeResponse{})
+	resp, _ := json.Marshal(handshakeResponse)
 
 	err = msg.Respond(resp)
 	if err != nil {
@@ -287,6 +625,13 @@ func (a *AgentClient) handleHandshake(msg *nats.Msg) {
 		return
 	}
 
+	a.handshakeMutex.Lock()
+	a.agentVersion = req.AgentVersion
+	a.protocolVersion = req.ProtocolVersion
+	a.capabilities = req.Capabilities
+	a.cipher = cipher
+	a.handshakeMutex.Unlock()
+
 	a.handshakeReceived.Store(true)
 	a.handshakeSucceeded(*req.ID)
 	go a.monitorAgent()
@@ -298,7 +643,11 @@ func (a *AgentClient) monitorAgent() {
 
 	for !a.shuttingDown() {
 		<-ticker.C
-		err := a.Ping()
+		if a.paused.Load() {
+			continue
+		}
+
+		err := a.probeHealth()
 		if err != nil {
 			if a.contactLost != nil {
 				a.contactLost(a.agentID)
@@ -308,6 +657,26 @@ func (a *AgentClient) monitorAgent() {
 	}
 }
 
+// Pings the agent, recording when it last answered
+func (a *AgentClient) probeHealth() error {
+	err := a.Ping()
+	if err != nil {
+		return err
+	}
+
+	a.lastHealthyProbe.Store(time.Now().UnixNano())
+	return nil
+}
+
+// Whether the agent has answered a health probe since its workload was deployed
+func (a *AgentClient) Healthy() bool {
+	if a.workloadStartedAt.IsZero() {
+		return false
+	}
+
+	return a.lastHealthyProbe.Load() >= a.workloadStartedAt.UnixNano()
+}
+
 func (a *AgentClient) handleAgentEvent(msg *nats.Msg) {
 	tokens := strings.Split(msg.Subject, ".")
 	agentID := tokens[1]
@@ -338,6 +707,11 @@ func (a *AgentClient) handleAgentLog(msg *nats.Msg) {
 	a.logReceived(agentID, logentry)
 }
 
+// Suspends or resumes the agent's health checks while its machine is paused
+func (a *AgentClient) SetPaused(paused bool) {
+	a.paused.Store(paused)
+}
+
 func (a *AgentClient) shuttingDown() bool {
 	return (atomic.LoadUint32(&a.stopping) > 0)
 }
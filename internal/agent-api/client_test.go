@@ -0,0 +1,89 @@
+package agentapi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestAgentHealthyOnlyAfterProbeFollowingDeploy(t *testing.T) {
+	a := &AgentClient{}
+	a.lastHealthyProbe.Store(time.Now().UnixNano())
+	if a.Healthy() {
+		t.Fatal("Expected an agent without a deployed workload not to be healthy")
+	}
+
+	a.workloadStartedAt = time.Now().UTC()
+	a.lastHealthyProbe.Store(a.workloadStartedAt.Add(-time.Millisecond).UnixNano())
+	if a.Healthy() {
+		t.Fatal("Expected a probe from before the deploy not to count")
+	}
+
+	a.lastHealthyProbe.Store(a.workloadStartedAt.Add(time.Millisecond).UnixNano())
+	if !a.Healthy() {
+		t.Fatal("Expected the agent to be healthy once probed after the deploy")
+	}
+}
+
+func TestUndeployWithGracePeriodReportsExit(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Port: -1})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %s", err)
+	}
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err)
+	}
+	t.Cleanup(nc.Close)
+
+	// the exiting agent echoes whether it was given a grace period; the legacy agent replies
+	// with an empty body, as agents predating grace periods do
+	_, _ = nc.Subscribe("agentint.exiting.undeploy", func(m *nats.Msg) {
+		var request UndeployRequest
+		_ = json.Unmarshal(m.Data, &request)
+
+		raw, _ := json.Marshal(&UndeployResponse{Exited: request.GracePeriodMillis == 250})
+		_ = m.Respond(raw)
+	})
+	_, _ = nc.Subscribe("agentint.legacy.undeploy", func(m *nats.Msg) {
+		_ = m.Respond([]byte{})
+	})
+
+	for _, tc := range []struct {
+		agentID     string
+		gracePeriod time.Duration
+		exited      bool
+	}{
+		{"exiting", 250 * time.Millisecond, true},
+		{"exiting", 0, false},
+		{"legacy", 250 * time.Millisecond, false},
+	} {
+		a := NewAgentClient(nc, slog.Default(), time.Minute, time.Second, nil, nil, nil, nil, nil)
+		a.agentID = tc.agentID
+
+		exited, err := a.UndeployWithGracePeriod(tc.gracePeriod)
+		if err != nil {
+			t.Fatalf("Failed to undeploy %s agent: %s", tc.agentID, err)
+		}
+		if exited != tc.exited {
+			t.Fatalf("Expected %s agent given %s to report exited=%v, got %v", tc.agentID, tc.gracePeriod, tc.exited, exited)
+		}
+	}
+
+	a := NewAgentClient(nc, slog.Default(), time.Minute, time.Second, nil, nil, nil, nil, nil)
+	a.agentID = "absent"
+	_, err = a.UndeployWithGracePeriod(0)
+	if err == nil {
+		t.Fatal("Expected undeploying an absent agent to fail")
+	}
+}
@@ -1,10 +1,9 @@
 package agentapi
 
 import (
+	"fmt"
+	"runtime"
 	"time"
-
-	cloudevents "github.com/cloudevents/sdk-go"
-	"github.com/google/uuid"
 )
 
 const (
@@ -12,6 +11,8 @@ const (
 	AgentStoppedEventType          = "agent_stopped"
 	FunctionExecutionFailedType    = "function_exec_failed"
 	FunctionExecutionSucceededType = "function_exec_succeeded"
+	JobCompletedEventType          = "job_completed"
+	WorkloadCompiledEventType      = "workload_compiled"
 	WorkloadDeployedEventType      = "workload_deployed"
 	WorkloadUndeployedEventType    = "workload_undeployed"
 )
@@ -26,20 +27,31 @@ type WorkloadStatusEvent struct {
 	Message      string `json:"message,omitempty"`
 }
 
+// Emitted once a workload has been compiled ahead of its first execution
+type WorkloadCompiledEvent struct {
+	WorkloadName     string `json:"workload_name"`
+	CacheKey         string `json:"cache_key"`
+	CacheHit         bool   `json:"cache_hit"`
+	CompileTimeNanos int64  `json:"compile_time_nanos"`
+}
+
+// Emitted by the agent when a job workload runs to completion, ahead of its undeployed event
+type JobCompletedEvent struct {
+	WorkloadName string    `json:"workload_name"`
+	ExitCode     int       `json:"exit_code"`
+	Output       string    `json:"output,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	CompletedAt  time.Time `json:"completed_at"`
+}
+
 type AgentStoppedEvent struct {
 	Message string `json:"message"`
 	Code    int    `json:"code"`
 }
 
-func NewAgentEvent(sourceId string, eventType string, event interface{}) cloudevents.Event {
-	cloudevent := cloudevents.NewEvent()
-
-	cloudevent.SetSource(sourceId)
-	cloudevent.SetID(uuid.NewString())
-	cloudevent.SetTime(time.Now().UTC())
-	cloudevent.SetType(eventType)
-	cloudevent.SetDataContentType(cloudevents.ApplicationJSON)
-	_ = cloudevent.SetData(event)
-
-	return cloudevent
+// Returns the key under which compiled artifacts for the workload with the given hash are
+// stored in the workload cache bucket. Compiled code is platform-specific, so the key
+// includes the platform it was compiled for
+func CompiledWorkloadCacheKey(workloadHash string) string {
+	return fmt.Sprintf("compiled-%s-%s-%s", runtime.GOOS, runtime.GOARCH, workloadHash)
 }
@@ -1,5 +1,10 @@
 package agentapi
 
+import (
+	"log/slog"
+	"strings"
+)
+
 const (
 	LogLevelPanic = 0
 	LogLevelFatal = 1
@@ -9,3 +14,46 @@ const (
 	LogLevelDebug = 5
 	LogLevelTrace = 6
 )
+
+// Sources of log entries emitted by an agent
+const (
+	LogSourceStdout  = "stdout"
+	LogSourceStderr  = "stderr"
+	LogSourceRuntime = "runtime"
+)
+
+// Converts an agent log level into its closest slog equivalent
+func (l LogLevel) SlogLevel() slog.Level {
+	switch {
+	case l <= LogLevelError:
+		return slog.LevelError
+	case l == LogLevelWarn:
+		return slog.LevelWarn
+	case l == LogLevelInfo:
+		return slog.LevelInfo
+	default:
+		return slog.LevelDebug
+	}
+}
+
+// Parses a level name such as those written by structured loggers, e.g. "info", "WARN" or "error"
+func ParseLogLevel(level string) (LogLevel, bool) {
+	switch strings.ToLower(level) {
+	case "panic":
+		return LogLevelPanic, true
+	case "fatal":
+		return LogLevelFatal, true
+	case "error", "err":
+		return LogLevelError, true
+	case "warn", "warning":
+		return LogLevelWarn, true
+	case "info":
+		return LogLevelInfo, true
+	case "debug":
+		return LogLevelDebug, true
+	case "trace":
+		return LogLevelTrace, true
+	}
+
+	return LogLevelInfo, false
+}
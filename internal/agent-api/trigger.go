@@ -0,0 +1,122 @@
+package agentapi
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// Reply subject of the message that triggered a function
+	NexTriggerReply = "x-nex-trigger-reply"
+
+	// Headers of the message that triggered a function, encoded as JSON
+	NexTriggerHeaders = "x-nex-trigger-headers"
+
+	// Headers a function set on its response, encoded as JSON
+	NexResponseHeaders = "x-nex-response-headers"
+)
+
+type triggerMetadataKey struct{}
+
+// Metadata of the message that triggered a function, handed to the function alongside its
+// payload. Functions set headers on their response through SetResponseHeader
+type TriggerMetadata struct {
+	Subject string              `json:"subject"`
+	Reply   string              `json:"reply,omitempty"`
+	MsgID   string              `json:"msg_id,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+
+	ResponseHeaders map[string][]string `json:"-"`
+}
+
+// Adds a header to the function's response, replacing any previous values of the header
+func (t *TriggerMetadata) SetResponseHeader(name string, values ...string) {
+	if t.ResponseHeaders == nil {
+		t.ResponseHeaders = make(map[string][]string)
+	}
+	t.ResponseHeaders[name] = values
+}
+
+// Returns a context carrying the trigger's metadata, along with its subject as providers have
+// always read it
+func WithTriggerMetadata(ctx context.Context, md *TriggerMetadata) context.Context {
+	ctx = context.WithValue(ctx, NexTriggerSubject, md.Subject) //nolint:all
+	return context.WithValue(ctx, triggerMetadataKey{}, md)
+}
+
+// Returns the metadata of the trigger being executed, which is empty but for the subject when the
+// context carries only a trigger subject
+func TriggerMetadataFromContext(ctx context.Context) *TriggerMetadata {
+	if md, ok := ctx.Value(triggerMetadataKey{}).(*TriggerMetadata); ok {
+		return md
+	}
+
+	subject, _ := ctx.Value(NexTriggerSubject).(string)
+	return &TriggerMetadata{Subject: subject}
+}
+
+// Encodes the metadata of a message received on a trigger subject into the header of the
+// internal trigger request sent to the agent
+func encodeTriggerMetadata(msg *nats.Msg, header nats.Header) {
+	header.Set(NexTriggerSubject, msg.Subject)
+	if msg.Reply != "" {
+		header.Set(NexTriggerReply, msg.Reply)
+	}
+	if len(msg.Header) > 0 {
+		raw, _ := json.Marshal(msg.Header)
+		header.Set(NexTriggerHeaders, string(raw))
+	}
+}
+
+// Reads the metadata the node forwarded with an internal trigger request
+func TriggerMetadataFromMsg(msg *nats.Msg) *TriggerMetadata {
+	md := &TriggerMetadata{
+		Subject: msg.Header.Get(NexTriggerSubject),
+		Reply:   msg.Header.Get(NexTriggerReply),
+	}
+
+	if raw := msg.Header.Get(NexTriggerHeaders); raw != "" {
+		_ = json.Unmarshal([]byte(raw), &md.Headers)
+	}
+	md.MsgID = nats.Header(md.Headers).Get(nats.MsgIdHdr)
+
+	return md
+}
+
+// Encodes the headers the function set into the header of its internal response
+func EncodeResponseHeaders(md *TriggerMetadata, header nats.Header) {
+	if len(md.ResponseHeaders) == 0 {
+		return
+	}
+
+	raw, _ := json.Marshal(md.ResponseHeaders)
+	header.Set(NexResponseHeaders, string(raw))
+}
+
+// Returns the headers a function set on its response, to be sent to the trigger's requester.
+// Headers reserved by nex are dropped
+func ResponseHeaders(resp *nats.Msg) nats.Header {
+	raw := resp.Header.Get(NexResponseHeaders)
+	if raw == "" {
+		return nil
+	}
+
+	var headers nats.Header
+	if json.Unmarshal([]byte(raw), &headers) != nil {
+		return nil
+	}
+
+	for name := range headers {
+		if strings.HasPrefix(strings.ToLower(name), "x-nex-") {
+			delete(headers, name)
+		}
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+
+	return headers
+}
@@ -0,0 +1,53 @@
+package agentapi
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestTriggerMetadataRoundTrip(t *testing.T) {
+	msg := nats.NewMsg("orders.created")
+	msg.Reply = "_INBOX.abc"
+	msg.Header.Set(nats.MsgIdHdr, "42")
+	msg.Header.Add("Accept", "application/json")
+
+	intmsg := nats.NewMsg("agentint.abc.trigger")
+	encodeTriggerMetadata(msg, intmsg.Header)
+
+	md := TriggerMetadataFromMsg(intmsg)
+	if md.Subject != "orders.created" || md.Reply != "_INBOX.abc" || md.MsgID != "42" {
+		t.Fatalf("Unexpected trigger metadata: %+v", md)
+	}
+	if !reflect.DeepEqual(md.Headers["Accept"], []string{"application/json"}) {
+		t.Fatalf("Expected the trigger's headers to be forwarded, got %v", md.Headers)
+	}
+
+	ctx := WithTriggerMetadata(context.Background(), md)
+	if subject, _ := ctx.Value(NexTriggerSubject).(string); subject != "orders.created" {
+		t.Fatalf("Expected the trigger subject in the context, got %q", subject)
+	}
+	if TriggerMetadataFromContext(ctx) != md {
+		t.Fatal("Expected the trigger metadata in the context")
+	}
+}
+
+func TestResponseHeadersDropReservedHeaders(t *testing.T) {
+	md := &TriggerMetadata{}
+	md.SetResponseHeader("Content-Type", "application/json")
+	md.SetResponseHeader(NexRuntimeNs, "1")
+
+	resp := nats.NewMsg("")
+	EncodeResponseHeaders(md, resp.Header)
+
+	headers := ResponseHeaders(resp)
+	if len(headers) != 1 || headers.Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected only the function's own headers, got %v", headers)
+	}
+
+	if ResponseHeaders(nats.NewMsg("")) != nil {
+		t.Fatal("Expected no headers for a response without any")
+	}
+}
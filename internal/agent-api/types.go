@@ -3,6 +3,7 @@ package agentapi
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/url"
 	"time"
@@ -15,6 +16,31 @@ import (
 // Name of the internal, non-public bucket for sharing files between host and agent
 const WorkloadCacheBucket = "NEXCACHE"
 
+// Key under which the node caches a workload's input payload in the agent's cache bucket
+const WorkloadInputCacheKey = "input"
+
+// Returns the key under which the node caches the workload mount at the given index in the
+// agent's cache bucket
+func WorkloadMountCacheKey(index int) string {
+	return fmt.Sprintf("mount-%d", index)
+}
+
+// AgentProtocolVersion is the version of the protocol spoken between node and agent over the
+// internal NATS connection. Bump it whenever a change would break a node or agent built from an
+// earlier version; nodes refuse to deploy to agents outside of [MinAgentProtocolVersion, AgentProtocolVersion]
+const (
+	AgentProtocolVersion    = 2
+	MinAgentProtocolVersion = 1
+
+	// First protocol version in which agents accept rotated internal NATS credentials
+	CredentialRotationProtocolVersion = 2
+)
+
+// Indicates whether a node can deploy workloads to an agent speaking the given protocol version
+func ProtocolVersionCompatible(version int) bool {
+	return version >= MinAgentProtocolVersion && version <= AgentProtocolVersion
+}
+
 // DefaultRunloopSleepTimeoutMillis default number of milliseconds to sleep during execution runloops
 const DefaultRunloopSleepTimeoutMillis = 25
 
@@ -35,11 +61,18 @@ type ExecutionProviderParams struct {
 	Stderr io.Writer `json:"-"`
 	Stdout io.Writer `json:"-"`
 
+	// Input payload piped to the workload, if any
+	Stdin io.Reader `json:"-"`
+
 	TmpFilename *string `json:"-"`
 	VmID        string  `json:"-"`
 
 	// NATS connections which be injected into the execution provider
 	NATSConn *nats.Conn `json:"-"`
+
+	// Opens trigger payloads received from the node and seals the replies; nil when the node
+	// did not agree to payload encryption
+	TriggerCipher *PayloadCipher `json:"-"`
 }
 
 // DeployRequest processed by the agent
@@ -58,6 +91,51 @@ type DeployRequest struct {
 	WorkloadName       *string                               `json:"workload_name,omitempty"`
 	WorkloadType       controlapi.NexWorkload                `json:"workload_type,omitempty"`
 	HostServicesConfig *controlapi.HostServicesConfiguration `json:"host_services,omitempty"`
+	Resources          *controlapi.WorkloadResources         `json:"resources,omitempty"`
+
+	HostServicesBudgets map[string]controlapi.HostServiceBudget `json:"host_services_budgets,omitempty"`
+
+	Group *string `json:"group,omitempty"`
+
+	StopGracePeriodMillis int64                         `json:"stop_grace_period_ms,omitempty"`
+	Labels                map[string]string             `json:"labels,omitempty"`
+	TriggerWorkers        *controlapi.TriggerWorkerPool `json:"trigger_workers,omitempty"`
+	TTLMillis             int64                         `json:"ttl_ms,omitempty"`
+	ExpiresAt             *time.Time                    `json:"expires_at,omitempty"`
+
+	// Describes the input payload cached for the workload under WorkloadInputCacheKey
+	Input *WorkloadInput `json:"input,omitempty"`
+
+	// Input as originally requested, retained by the node for redeployment
+	SourceInput *controlapi.WorkloadInput `json:"-"`
+
+	// Objects cached for the workload that the agent writes read-only into its filesystem
+	Mounts []WorkloadMount `json:"mounts,omitempty"`
+
+	// Mounts as originally requested, retained by the node for redeployment
+	SourceMounts []controlapi.WorkloadMount `json:"-"`
+
+	// Indexes of the host GPUs assigned to the workload
+	GPUDevices []int `json:"gpu_devices,omitempty"`
+
+	// Whether the workload's artifact may be swapped while it runs
+	HotReload bool `json:"hot_reload,omitempty"`
+
+	// Persistent volume attached to the workload's machine, which the agent mounts
+	Volume *WorkloadVolume `json:"volume,omitempty"`
+
+	// Volume as originally requested, retained by the node for redeployment
+	SourceVolume *controlapi.WorkloadVolume `json:"-"`
+
+	// NATS service registered by the node on behalf of the function, whose requests reach the
+	// agent as triggers
+	Service *controlapi.NatsService `json:"service,omitempty"`
+
+	// Lets the node answer the function's triggers from its result cache
+	ResultCache *controlapi.ResultCachePolicy `json:"result_cache,omitempty"`
+
+	// Trigger run by the node once the workload is deployed, before it starts running
+	Init *controlapi.WorkloadInit `json:"init,omitempty"`
 
 	Stderr      io.Writer `json:"-"`
 	Stdout      io.Writer `json:"-"`
@@ -83,10 +161,19 @@ func (request *DeployRequest) SupportsEssential() bool {
 		request.WorkloadType == controlapi.NexWorkloadOCI
 }
 
+// Returns true if the run request supports an input payload
+func (request *DeployRequest) SupportsInput() bool {
+	return request.WorkloadType == controlapi.NexWorkloadNative ||
+		request.WorkloadType == controlapi.NexWorkloadJob
+}
+
 // Returns true if the run request supports trigger subjects
+// Service-style workload types never support trigger subjects; native workloads receive theirs
+// through the SDK, and the node only accepts trigger subjects for extension workload types that
+// declare support for them
 func (request *DeployRequest) SupportsTriggerSubjects() bool {
-	return (request.WorkloadType == controlapi.NexWorkloadV8 ||
-		request.WorkloadType == controlapi.NexWorkloadWasm) &&
+	return request.WorkloadType != controlapi.NexWorkloadOCI &&
+		request.WorkloadType != controlapi.NexWorkloadJob &&
 		len(request.TriggerSubjects) > 0
 }
 
@@ -105,6 +192,10 @@ func (r *DeployRequest) Validate() error {
 		err = errors.Join(err, errors.New("essential flag is not supported for workload type"))
 	}
 
+	if r.Input != nil && !r.SupportsInput() {
+		err = errors.Join(err, errors.New("input is not supported for workload type"))
+	}
+
 	if r.Hash == "" { // FIXME--- this should probably be checked against *string
 		err = errors.Join(err, errors.New("hash is required"))
 	}
@@ -117,25 +208,103 @@ func (r *DeployRequest) Validate() error {
 		err = errors.Join(err, errors.New("workload type is required"))
 	} else if (r.WorkloadType == controlapi.NexWorkloadV8 ||
 		r.WorkloadType == controlapi.NexWorkloadWasm) &&
-		len(r.TriggerSubjects) == 0 {
-		err = errors.Join(err, errors.New("at least one trigger subject is required for this workload type"))
+		len(r.TriggerSubjects) == 0 && r.Service == nil {
+		err = errors.Join(err, errors.New("at least one trigger subject or a nats service is required for this workload type"))
 	}
 
 	return err
 }
 
+// Input payload the agent delivers to its workload; piped to stdin unless a path is given
+type WorkloadInput struct {
+	Path  string `json:"path,omitempty"`
+	Bytes int64  `json:"bytes"`
+}
+
+// An object cached under Key, written read-only to Path. Sha256 is the digest computed by the
+// node, which the agent verifies before writing the file
+type WorkloadMount struct {
+	Key    string `json:"key"`
+	Path   string `json:"path"`
+	Sha256 string `json:"sha256"`
+}
+
+// Block device holding a persistent volume, which the agent mounts at Path. HostPath is the
+// image or directory in which the node keeps the volume
+type WorkloadVolume struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Device   string `json:"device"`
+	HostPath string `json:"-"`
+}
+
 type DeployResponse struct {
 	Accepted bool    `json:"accepted"`
 	Message  *string `json:"message"`
+
+	// Why the agent rejected the workload; agents predating rejection reasons leave it empty
+	Reason controlapi.DeployRejectionReason `json:"reason,omitempty"`
+}
+
+// Sent by the node to ask an agent to undeploy its workload. When a grace period is given, the
+// agent waits up to that long for the workload to exit before replying
+type UndeployRequest struct {
+	GracePeriodMillis int64 `json:"grace_period_ms,omitempty"`
+}
+
+// Agents predating stop grace periods reply to undeploy requests with an empty body
+type UndeployResponse struct {
+	Exited bool `json:"exited"`
+}
+
+// Sent by the node to hand an agent new credentials for the internal NATS server. The node
+// revokes the agent's previous credentials once the agent acknowledges the rotation
+type CredentialsRotationRequest struct {
+	NkeySeed string `json:"nkey_seed"`
+}
+
+type CredentialsRotationResponse struct {
+	Rotated bool `json:"rotated"`
+}
+
+// Sent by the node once a new artifact for a hot reloadable workload is in the cache bucket. The
+// agent verifies the artifact against the hash before swapping it in
+type HotReloadRequest struct {
+	Hash string `json:"hash"`
+}
+
+type HotReloadResponse struct {
+	Reloaded bool    `json:"reloaded"`
+	Message  *string `json:"message,omitempty"`
 }
 
 type HandshakeRequest struct {
 	ID        *string   `json:"id"`
 	StartTime time.Time `json:"start_time"`
 	Message   *string   `json:"message,omitempty"`
+
+	// Agents predating protocol versioning omit these
+	AgentVersion    string `json:"agent_version,omitempty"`
+	ProtocolVersion int    `json:"protocol_version,omitempty"`
+
+	// Public xkey of the agent; agents predating payload encryption omit it
+	XKey string `json:"xkey,omitempty"`
+
+	// Providers and resources available to the agent; agents predating capability reports omit
+	// it and are assumed to run any workload the node supports
+	Capabilities *controlapi.AgentCapabilities `json:"capabilities,omitempty"`
 }
 
+// When both the handshake request and response carry an xkey, deploy and credentials rotation
+// requests, the cached workload artifact, input and mounts, and trigger payloads and replies are
+// sealed for the peer
 type HandshakeResponse struct {
+	ProtocolVersion int     `json:"protocol_version"`
+	Compatible      bool    `json:"compatible"`
+	Message         *string `json:"message,omitempty"`
+
+	// Public xkey of the node; only present when the agent offered one
+	XKey string `json:"xkey,omitempty"`
 }
 
 type HostServicesHTTPRequest struct {
@@ -180,6 +349,27 @@ type HostServicesMessagingResponse struct {
 	Success bool     `json:"success,omitempty"`
 }
 
+// Names the JetStream assets a workload wants scoped credentials for. Every name must
+// begin with the credentials service's configured prefix for the workload's namespace
+type HostServicesCredentialsRequest struct {
+	Streams      []string `json:"streams,omitempty"`
+	Buckets      []string `json:"buckets,omitempty"`
+	ObjectStores []string `json:"object_stores,omitempty"`
+}
+
+type HostServicesCredentialsResponse struct {
+	Jwt     string `json:"jwt"`
+	Seed    string `json:"seed"`
+	Creds   string `json:"creds"`
+	NatsUrl string `json:"nats_url,omitempty"`
+
+	// The credentials may only receive replies under this prefix, so the workload's connection
+	// must use it as its custom inbox prefix, e.g. with nats.CustomInboxPrefix
+	InboxPrefix string `json:"inbox_prefix"`
+
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 type MachineMetadata struct {
 	Nameserver       *string `json:"nameserver"`
 	NodeNatsHost     *string `json:"node_nats_host"`
@@ -210,9 +400,10 @@ func (m *MachineMetadata) Validate() bool {
 }
 
 type LogEntry struct {
-	Source string   `json:"source,omitempty"`
-	Level  LogLevel `json:"level,omitempty"`
-	Text   string   `json:"text,omitempty"`
+	Source string                 `json:"source,omitempty"`
+	Level  LogLevel               `json:"level,omitempty"`
+	Text   string                 `json:"text,omitempty"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
 }
 
 type LogLevel int32
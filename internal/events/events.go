@@ -0,0 +1,170 @@
+// Package events constructs the cloudevents emitted by nodes and agents. Each constructor binds
+// an event type to its payload type, and every event type is described by a JSON schema in the
+// registry so tooling can validate and decode what it receives
+package events
+
+import (
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+	"github.com/google/uuid"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+func newEvent(source string, eventType string, data interface{}) cloudevents.Event {
+	cloudevent := cloudevents.NewEvent()
+	cloudevent.SetSource(source)
+	cloudevent.SetID(uuid.NewString())
+	cloudevent.SetTime(time.Now().UTC())
+	cloudevent.SetType(eventType)
+	cloudevent.SetDataContentType(cloudevents.ApplicationJSON)
+	_ = cloudevent.SetData(data)
+
+	return cloudevent
+}
+
+func NodeStarted(source string, evt controlapi.NodeStartedEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodeStartedEventType, evt)
+}
+
+func NodeStopped(source string, evt controlapi.NodeStoppedEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodeStoppedEventType, evt)
+}
+
+func LameDuckEntered(source string, evt controlapi.LameDuckEnteredEvent) cloudevents.Event {
+	return newEvent(source, controlapi.LameDuckEnteredEventType, evt)
+}
+
+func NodeEvacuation(source string, evt controlapi.NodeEvacuationEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodeEvacuationEventType, evt)
+}
+
+func NodeKeysRotated(source string, evt controlapi.NodeKeysRotatedEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodeKeysRotatedEventType, evt)
+}
+
+func NodeCordoned(source string, evt controlapi.NodeCordonEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodeCordonedEventType, evt)
+}
+
+func NodeUncordoned(source string, evt controlapi.NodeCordonEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodeUncordonedEventType, evt)
+}
+
+func NodePressureChanged(source string, evt controlapi.NodePressureEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodePressureEventType, evt)
+}
+
+func NodeConfigReloaded(source string, evt controlapi.NodeConfigReloadedEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodeConfigReloadedEventType, evt)
+}
+
+func NodeTagsChanged(source string, evt controlapi.NodeTagsChangedEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodeTagsChangedEventType, evt)
+}
+
+func NodeUpdating(source string, evt controlapi.NodeUpdatingEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodeUpdatingEventType, evt)
+}
+
+func RootfsRollout(source string, evt controlapi.RootfsRolloutEvent) cloudevents.Event {
+	return newEvent(source, controlapi.RootfsRolloutEventType, evt)
+}
+
+func ArtifactScanned(source string, evt controlapi.ArtifactScannedEvent) cloudevents.Event {
+	return newEvent(source, controlapi.ArtifactScannedEventType, evt)
+}
+
+func ArtifactTransfer(source string, evt controlapi.ArtifactTransferEvent) cloudevents.Event {
+	return newEvent(source, controlapi.ArtifactTransferEventType, evt)
+}
+
+func WorkloadQuarantined(source string, evt controlapi.QuarantinedWorkload) cloudevents.Event {
+	return newEvent(source, controlapi.WorkloadQuarantinedEventType, evt)
+}
+
+func WorkloadPaused(source string, evt controlapi.WorkloadPauseEvent) cloudevents.Event {
+	return newEvent(source, controlapi.WorkloadPausedEventType, evt)
+}
+
+func WorkloadResumed(source string, evt controlapi.WorkloadPauseEvent) cloudevents.Event {
+	return newEvent(source, controlapi.WorkloadResumedEventType, evt)
+}
+
+func WorkloadCheckpointed(source string, evt controlapi.CheckpointInfo) cloudevents.Event {
+	return newEvent(source, controlapi.WorkloadCheckpointedEventType, evt)
+}
+
+func WorkloadRestored(source string, evt controlapi.CheckpointInfo) cloudevents.Event {
+	return newEvent(source, controlapi.WorkloadRestoredEventType, evt)
+}
+
+func NamespaceCreated(source string, evt controlapi.NamespaceEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NamespaceCreatedEventType, evt)
+}
+
+func NamespaceDeleted(source string, evt controlapi.NamespaceEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NamespaceDeletedEventType, evt)
+}
+
+func NodeJoinedNexus(source string, evt controlapi.NexusMembershipEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodeJoinedNexusEventType, evt)
+}
+
+func NodeLeftNexus(source string, evt controlapi.NexusMembershipEvent) cloudevents.Event {
+	return newEvent(source, controlapi.NodeLeftNexusEventType, evt)
+}
+
+func Heartbeat(source string, evt controlapi.HeartbeatEvent) cloudevents.Event {
+	return newEvent(source, controlapi.HeartbeatEventType, evt)
+}
+
+func PolicyDecision(source string, evt controlapi.PolicyDecisionEvent) cloudevents.Event {
+	return newEvent(source, controlapi.PolicyDecisionEventType, evt)
+}
+
+func WorkloadStopping(source string, evt controlapi.WorkloadStoppingEvent) cloudevents.Event {
+	return newEvent(source, controlapi.WorkloadStoppingEventType, evt)
+}
+
+func WorkloadStateChanged(source string, evt controlapi.WorkloadStateChangedEvent) cloudevents.Event {
+	return newEvent(source, controlapi.WorkloadStateChangedEventType, evt)
+}
+
+// A workload stopped by the node at a client's request, reported as undeployed
+func WorkloadStopped(source string, evt controlapi.WorkloadStoppedEvent) cloudevents.Event {
+	return newEvent(source, controlapi.WorkloadUndeployedEventType, evt)
+}
+
+func WorkloadExpired(source string, evt controlapi.WorkloadExpiredEvent) cloudevents.Event {
+	cloudevent := newEvent(source, controlapi.WorkloadExpiredEventType, evt)
+	cloudevent.SetTime(evt.ExpiredAt)
+	return cloudevent
+}
+
+func FunctionExecSucceeded(source string, evt controlapi.FunctionExecSucceededEvent) cloudevents.Event {
+	return newEvent(source, agentapi.FunctionExecutionSucceededType, evt)
+}
+
+func FunctionExecFailed(source string, evt controlapi.FunctionExecFailedEvent) cloudevents.Event {
+	return newEvent(source, agentapi.FunctionExecutionFailedType, evt)
+}
+
+// Emitted by an agent once its workload is running
+func WorkloadDeployed(source string, evt agentapi.WorkloadStatusEvent) cloudevents.Event {
+	return newEvent(source, agentapi.WorkloadDeployedEventType, evt)
+}
+
+// Emitted by an agent when its workload exits or fails to deploy
+func WorkloadUndeployed(source string, evt agentapi.WorkloadStatusEvent) cloudevents.Event {
+	return newEvent(source, agentapi.WorkloadUndeployedEventType, evt)
+}
+
+func WorkloadCompiled(source string, evt agentapi.WorkloadCompiledEvent) cloudevents.Event {
+	return newEvent(source, agentapi.WorkloadCompiledEventType, evt)
+}
+
+func JobCompleted(source string, evt agentapi.JobCompletedEvent) cloudevents.Event {
+	return newEvent(source, agentapi.JobCompletedEventType, evt)
+}
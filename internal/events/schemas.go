@@ -0,0 +1,173 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// An emitted event type and the payloads its producers attach to it
+type definition struct {
+	eventType   string
+	description string
+	payloads    []interface{}
+}
+
+// Every event type emitted by nodes and agents. New event types must be registered here so
+// their schemas are published to tooling
+var registry = []definition{
+	{controlapi.NodeStartedEventType, "A node started", []interface{}{controlapi.NodeStartedEvent{}}},
+	{controlapi.NodeStoppedEventType, "A node stopped", []interface{}{controlapi.NodeStoppedEvent{}}},
+	{controlapi.LameDuckEnteredEventType, "A node entered lame duck mode", []interface{}{controlapi.LameDuckEnteredEvent{}}},
+	{controlapi.NodeCordonedEventType, "A node was cordoned and stopped accepting deployments", []interface{}{controlapi.NodeCordonEvent{}}},
+	{controlapi.NodeUncordonedEventType, "A node was uncordoned", []interface{}{controlapi.NodeCordonEvent{}}},
+	{controlapi.NodePressureEventType, "A node entered or recovered from the resource pressure state", []interface{}{controlapi.NodePressureEvent{}}},
+	{controlapi.NodeConfigReloadedEventType, "A node reloaded its configuration", []interface{}{controlapi.NodeConfigReloadedEvent{}}},
+	{controlapi.NodeTagsChangedEventType, "A node's tags changed", []interface{}{controlapi.NodeTagsChangedEvent{}}},
+	{controlapi.NodeUpdatingEventType, "A node staged a signed binary and is restarting into it", []interface{}{controlapi.NodeUpdatingEvent{}}},
+	{controlapi.NodeEvacuationEventType, "Progress of a node moving its workloads to its peers before it goes away", []interface{}{controlapi.NodeEvacuationEvent{}}},
+	{controlapi.NodeKeysRotatedEventType, "A node replaced its xkey; the previous one is accepted until the grace period ends", []interface{}{controlapi.NodeKeysRotatedEvent{}}},
+	{controlapi.RootfsRolloutEventType, "Progress of a node moving its agents onto a new rootfs image", []interface{}{controlapi.RootfsRolloutEvent{}}},
+	{controlapi.NodeJoinedNexusEventType, "A node registered as a member of the nexus", []interface{}{controlapi.NexusMembershipEvent{}}},
+	{controlapi.NodeLeftNexusEventType, "A node removed its entry from the nexus registry as it shut down", []interface{}{controlapi.NexusMembershipEvent{}}},
+	{controlapi.HeartbeatEventType, "Periodic liveness report of a node", []interface{}{controlapi.HeartbeatEvent{}}},
+	{controlapi.PolicyDecisionEventType, "A node's access policy allowed or denied a request", []interface{}{controlapi.PolicyDecisionEvent{}}},
+	{agentapi.WorkloadDeployedEventType, "An agent started its workload", []interface{}{agentapi.WorkloadStatusEvent{}}},
+	{agentapi.WorkloadUndeployedEventType, "A workload exited or failed to deploy, as reported by its agent, or was stopped by the node", []interface{}{agentapi.WorkloadStatusEvent{}, controlapi.WorkloadStoppedEvent{}}},
+	{controlapi.ArtifactScannedEventType, "A workload's artifact was scanned before deployment", []interface{}{controlapi.ArtifactScannedEvent{}}},
+	{controlapi.ArtifactTransferEventType, "Progress of a node downloading a workload's artifact", []interface{}{controlapi.ArtifactTransferEvent{}}},
+	{controlapi.WorkloadQuarantinedEventType, "A node quarantined a workload that exceeded its failure thresholds", []interface{}{controlapi.QuarantinedWorkload{}}},
+	{controlapi.WorkloadPausedEventType, "A workload's trigger subscriptions, and optionally its machine, were paused", []interface{}{controlapi.WorkloadPauseEvent{}}},
+	{controlapi.WorkloadResumedEventType, "A paused workload was resumed", []interface{}{controlapi.WorkloadPauseEvent{}}},
+	{controlapi.WorkloadCheckpointedEventType, "A snapshot of a workload's machine was stored in a checkpoint bucket", []interface{}{controlapi.CheckpointInfo{}}},
+	{controlapi.WorkloadRestoredEventType, "A workload was restored from a checkpoint", []interface{}{controlapi.CheckpointInfo{}}},
+	{controlapi.NamespaceCreatedEventType, "A namespace was created", []interface{}{controlapi.NamespaceEvent{}}},
+	{controlapi.NamespaceDeletedEventType, "A namespace was deleted, stopping or orphaning its workloads", []interface{}{controlapi.NamespaceEvent{}}},
+	{controlapi.WorkloadStoppingEventType, "A phase of stopping a workload", []interface{}{controlapi.WorkloadStoppingEvent{}}},
+	{controlapi.WorkloadStateChangedEventType, "A workload moved to another lifecycle state", []interface{}{controlapi.WorkloadStateChangedEvent{}}},
+	{controlapi.WorkloadExpiredEventType, "A workload's TTL elapsed", []interface{}{controlapi.WorkloadExpiredEvent{}}},
+	{agentapi.WorkloadCompiledEventType, "A workload was compiled ahead of its first execution", []interface{}{agentapi.WorkloadCompiledEvent{}}},
+	{agentapi.JobCompletedEventType, "A job workload ran to completion", []interface{}{agentapi.JobCompletedEvent{}}},
+	{agentapi.FunctionExecutionSucceededType, "A function's trigger execution succeeded", []interface{}{controlapi.FunctionExecSucceededEvent{}}},
+	{agentapi.FunctionExecutionFailedType, "A function's trigger execution failed", []interface{}{controlapi.FunctionExecFailedEvent{}}},
+}
+
+// Returns the JSON schema of every registered event type, ordered by event type
+func Schemas() []controlapi.EventSchema {
+	schemas := make([]controlapi.EventSchema, 0, len(registry))
+	for _, def := range registry {
+		var schema map[string]interface{}
+		if len(def.payloads) == 1 {
+			schema = schemaOf(reflect.TypeOf(def.payloads[0]))
+		} else {
+			alternatives := make([]interface{}, 0, len(def.payloads))
+			for _, payload := range def.payloads {
+				alternatives = append(alternatives, schemaOf(reflect.TypeOf(payload)))
+			}
+			schema = map[string]interface{}{"oneOf": alternatives}
+		}
+		schema["$schema"] = jsonSchemaDialect
+		schema["title"] = def.eventType
+		schema["description"] = def.description
+
+		raw, _ := json.Marshal(schema)
+		schemas = append(schemas, controlapi.EventSchema{
+			EventType:   def.eventType,
+			Description: def.description,
+			Schema:      raw,
+		})
+	}
+
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].EventType < schemas[j].EventType
+	})
+
+	return schemas
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Derives a JSON schema from a payload type according to how encoding/json marshals it. Fields
+// without omitempty are required
+func schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := make([]string, 0)
+		addProperties(t, properties, &required)
+
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+
+	// interfaces may hold any value
+	return map[string]interface{}{}
+}
+
+func addProperties(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addProperties(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = schemaOf(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -17,10 +17,36 @@ type UiOptions struct {
 
 type DevRunOptions struct {
 	Filename string
+	// Per-architecture builds of the workload, keyed by GOARCH
+	ArchFiles map[string]string
 	// Stop a workload with the same name on a target
 	AutoStop bool
 	// Max bytes override for when we create the NEXCLIFILES bucket
 	DevBucketMaxBytes uint
+	// Files larger than this are uploaded in chunks of this size, so interrupted uploads resume
+	ChunkBytes uint
+	// Names of workloads the target node must not already be running
+	Avoid []string
+	// Swap the artifact of an already running v8 or wasm workload of the same name rather than
+	// redeploying it, and deploy new workloads so that they can be swapped later
+	HotReload bool
+	// Sites of which the target node must be in one
+	Sites []string
+	// Pick the candidate node with the shortest round trip rather than one at random
+	Nearest bool
+}
+
+type DevboxOptions struct {
+	// Directory watched for changes, by default the one holding the workload file
+	WatchDir string
+	// Command run in the watched directory before each deploy, e.g. to rebuild the workload file
+	BuildCommand string
+	// Port of the embedded NATS server; a random free port when zero
+	Port int
+	// How often the watched directory is checked for changes
+	PollInterval time.Duration
+	// Type of the workload; inferred from the workload file's extension when empty
+	WorkloadType string
 }
 
 // Options configure the CLI
@@ -66,12 +92,17 @@ type Options struct {
 	LogJSON bool
 	// Name or path to a configuration context
 	ConfigurationContext string
+	// Name of the nex CLI context to use in place of the selected one
+	CliContext string
+	// Only nodes in this nexus are targeted by discovery, if set
+	Nexus string
 }
 
 type RunOptions struct {
 	Argv              string
 	TargetNode        string
 	WorkloadUrl       *url.URL
+	ArchUrls          map[string]string
 	Name              string
 	WorkloadType      controlapi.NexWorkload
 	Description       string
@@ -81,17 +112,72 @@ type RunOptions struct {
 	Essential         bool
 	DevMode           bool
 	TriggerSubjects   []string
+	MemoryMib         int
+	GPUs              int
+	Group             string
+	StopGracePeriod   time.Duration
+	TTL               time.Duration
+	InputFile         string
+	InputUrl          string
+	InputPath         string
+	Mounts            map[string]string
+	MountDigests      map[string]string
+	VolumeName        string
+	VolumePath        string
+	VolumeSizeMib     int
+	Labels            map[string]string
+	TriggerWorkers    int
+	TriggerQueueSize  int
+	TriggerShed       bool
+	ServiceFile       string
+	CacheTTL          time.Duration
+	CacheSubjects     []string
+	Init              bool
+	InitPayloadFile   string
+	InitTimeout       time.Duration
+
+	WaitForReady      bool
+	WaitTimeout       time.Duration
+	RollbackOnFailure bool
 
 	HsUrl      string
 	HsUserJwt  string
 	HsUserSeed string
 }
 
+// Limits of a rollout replacing a workload across the nodes running it
+type RolloutOptions struct {
+	Nodes            []string
+	MaxSurge         int
+	MaxUnavailable   int
+	FailureThreshold int
+	ReadyTimeout     time.Duration
+}
+
 type StopOptions struct {
 	TargetNode       string
 	WorkloadName     string
 	WorkloadId       string
 	ClaimsIssuerFile string
+	All              bool
+	Force            bool
+}
+
+// Selects the nodes a command is applied to when it fans out across the nexus instead of
+// targeting a single node
+type FanOutOptions struct {
+	AllNodes bool
+	Selector map[string]string
+}
+
+// Whether the command should fan out rather than target a single node
+func (o *FanOutOptions) Enabled() bool {
+	return o.AllNodes || len(o.Selector) > 0
+}
+
+// Format in which listing and info commands print their results
+type OutputOptions struct {
+	Format string
 }
 
 type WatchOptions struct {
@@ -99,6 +185,7 @@ type WatchOptions struct {
 	WorkloadId   string
 	WorkloadName string
 	LogLevel     string
+	Sites        []string
 }
 
 type RootfsOptions struct {
@@ -115,15 +202,26 @@ type NodeOptions struct {
 	ConfigFilepath  string `json:"-"`
 	ForceDepInstall bool   `json:"-"`
 
+	OtelLogs            bool   `json:"-"`
+	OtelLogsExporter    string `json:"-"`
 	OtelMetrics         bool   `json:"-"`
 	OtelMetricsPort     int    `json:"-"`
 	OtelMetricsExporter string `json:"-"`
 	OtelTraces          bool   `json:"-"`
 	OtelTracesExporter  string `json:"-"`
 
-	PreflightInit string `json:"-"`
-	ListFull      bool   `json:"-"`
-	NexusName     string `json:"-"`
+	PreflightInit string        `json:"-"`
+	PreflightJSON bool          `json:"-"`
+	ListFull      bool          `json:"-"`
+	ListQuiet     time.Duration `json:"-"`
+	ListSites     []string      `json:"-"`
+	CordonReason  string        `json:"-"`
+	CordonFor     time.Duration `json:"-"`
+	NexusName     string        `json:"-"`
+	Issuer        string        `json:"-"`
+
+	// Level of the node's logger, adjusted when a configuration reload changes log_level
+	LogLevel *slog.LevelVar `json:"-"`
 
 	Errors []error `json:"errors,omitempty"`
 }
@@ -3,11 +3,17 @@ package models
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/netip"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nkeys"
 	"github.com/splode/fname"
 	controlapi "github.com/synadia-io/nex/control-api"
 )
@@ -23,44 +29,179 @@ const (
 	DefaultOtelExporterUrl                  = "127.0.0.1:14532"
 	DefaultAgentHandshakeTimeoutMillisecond = 5000
 	DefaultAgentPingTimeoutMillisecond      = 750
+	DefaultAgentReapIntervalMillisecond     = 15000
+	DefaultSubscriptionJanitorMillisecond   = 60000
+	DefaultTriggerWorkers                   = 4
+	DefaultTriggerQueueSize                 = 256
+	DefaultExecutionHistorySize             = 100
+	DefaultDeployQueueTimeoutMillisecond    = 30000
+	DefaultOvercommitRatio                  = 1.0
+	DefaultJailerChrootBaseDir              = "/srv/jailer"
+	DefaultJailerCgroupVersion              = "2"
+	DefaultJailerIdRangeStart               = 100000
+	DefaultCgroupParent                     = "/sys/fs/cgroup/nex.slice"
 )
 
+// Identifies the shared machine pool serving workload types without a dedicated pool
+const DefaultAgentPool controlapi.NexWorkload = ""
+
 var (
 	DefaultBinPath       = append([]string{"/usr/local/bin"}, filepath.SplitList(os.Getenv("PATH"))...)
 	DefaultCNIBinPath    = []string{"/opt/cni/bin"}
 	DefaultWorkloadTypes = []controlapi.NexWorkload{controlapi.NexWorkloadNative}
+	BuiltinWorkloadTypes = []controlapi.NexWorkload{
+		controlapi.NexWorkloadNative,
+		controlapi.NexWorkloadV8,
+		controlapi.NexWorkloadOCI,
+		controlapi.NexWorkloadWasm,
+		controlapi.NexWorkloadJVM,
+		controlapi.NexWorkloadJob,
+	}
 )
 
 // Node configuration is used to configure the node process as well
 // as the virtual machines it produces
 type NodeConfiguration struct {
+	AdminKeys                        []string                 `json:"admin_keys,omitempty"`
 	AgentHandshakeTimeoutMillisecond int                      `json:"agent_handshake_timeout_ms,omitempty"`
 	AgentPingTimeoutMillisecond      int                      `json:"agent_ping_timeout_ms,omitempty"`
+	AgentReapIntervalMillisecond     int                      `json:"agent_reap_interval_ms,omitempty"`
+	Artifacts                        *ArtifactsConfig         `json:"artifacts,omitempty"`
 	AutostartConfiguration           *AutostartConfig         `json:"autostart,omitempty"`
 	BinPath                          []string                 `json:"bin_path"`
 	CNI                              CNIDefinition            `json:"cni"`
+	CgroupParent                     string                   `json:"cgroup_parent,omitempty"`
 	DefaultResourceDir               string                   `json:"default_resource_dir"`
+	DeployQueue                      *DeployQueueConfig       `json:"deploy_queue,omitempty"`
+	ExecutionHistorySize             int                      `json:"execution_history_size"`
 	ForceDepInstall                  bool                     `json:"-"`
 	HostServicesConfiguration        *HostServicesConfig      `json:"host_services,omitempty"`
+	IntentLogFilepath                string                   `json:"intent_log_filepath,omitempty"`
 	InternalNodeHost                 *string                  `json:"internal_node_host,omitempty"`
 	InternalNodePort                 *int                     `json:"internal_node_port"`
+	Jailer                           *JailerConfig            `json:"jailer,omitempty"`
 	KernelFilepath                   string                   `json:"kernel_filepath"`
+	LogLevel                         string                   `json:"log_level,omitempty"`
+	LogSinks                         []LogSinkConfig          `json:"log_sinks,omitempty"`
 	MachinePoolSize                  int                      `json:"machine_pool_size"`
 	MachineTemplate                  MachineTemplate          `json:"machine_template"`
 	NoSandbox                        bool                     `json:"no_sandbox,omitempty"`
 	OtlpExporterUrl                  string                   `json:"otlp_exporter_url,omitempty"`
+	OtelLogs                         bool                     `json:"otel_logs"`
+	OtelLogsExporter                 string                   `json:"otel_logs_exporter"`
 	OtelMetrics                      bool                     `json:"otel_metrics"`
 	OtelMetricsPort                  int                      `json:"otel_metrics_port"`
 	OtelMetricsExporter              string                   `json:"otel_metrics_exporter"`
 	OtelTraces                       bool                     `json:"otel_traces"`
 	OtelTracesExporter               string                   `json:"otel_traces_exporter"`
 	PreserveNetwork                  bool                     `json:"preserve_network,omitempty"`
+	ProviderExtensions               []ProviderExtension      `json:"provider_extensions,omitempty"`
 	RateLimiters                     *Limiters                `json:"rate_limiters,omitempty"`
+	Resources                        *ResourceConfig          `json:"resources,omitempty"`
 	RootFsFilepath                   string                   `json:"rootfs_filepath"`
+	Site                             string                   `json:"site,omitempty"`
+	SubscriptionJanitorMillisecond   int                      `json:"subscription_janitor_interval_ms,omitempty"`
 	Tags                             map[string]string        `json:"tags,omitempty"`
+	TagsFilepath                     string                   `json:"tags_filepath,omitempty"`
 	ValidIssuers                     []string                 `json:"valid_issuers,omitempty"`
 	WorkloadTypes                    []controlapi.NexWorkload `json:"workload_types,omitempty"`
 
+	// Limits enforced by the internal NATS server shared by the node and its agents
+	InternalNATS *InternalNATSConfig `json:"internal_nats,omitempty"`
+
+	// Grants issuers control API operations per namespace
+	AccessPolicy *AccessPolicyConfig `json:"access_policy,omitempty"`
+
+	// Persists the audit records of control API requests; nil only publishes them
+	Audit *AuditConfig `json:"audit,omitempty"`
+
+	// Largest workload artifact the node accepts, checked before the artifact is downloaded; zero
+	// accepts artifacts of any size
+	MaxArtifactBytes int64 `json:"max_artifact_bytes,omitempty"`
+
+	// Scans workload artifacts before they are deployed; nil deploys without scanning
+	ArtifactScan *ArtifactScanConfig `json:"artifact_scan,omitempty"`
+
+	// Refuses to deploy to agents that did not negotiate payload encryption during their handshake,
+	// rather than sending them deploy and trigger payloads in the clear
+	RequirePayloadEncryption bool `json:"require_payload_encryption,omitempty"`
+
+	// Quarantines workloads that fail too many trigger executions or crash too often; nil disables it
+	Quarantine *QuarantineConfig `json:"quarantine,omitempty"`
+
+	// Declines new work while the host is under CPU or memory pressure; nil disables monitoring
+	Pressure *PressureConfig `json:"pressure,omitempty"`
+
+	// Manages namespace objects stored in a key-value bucket; nil leaves namespaces as plain strings
+	Namespaces *NamespacesConfig `json:"namespaces,omitempty"`
+
+	// Serves declared resources stored in a key-value bucket, e.g. for infrastructure as code
+	// tools; nil leaves resource requests to other nodes
+	DeclaredResources *DeclaredResourcesConfig `json:"declared_resources,omitempty"`
+
+	// Buffers events and logs on disk while the node is disconnected from NATS and replays them
+	// once it reconnects; nil leaves them to the NATS client's in-memory reconnect buffer
+	Outbox *OutboxConfig `json:"outbox,omitempty"`
+
+	// Meters the resources used by each namespace's workloads and publishes signed usage reports;
+	// nil disables metering
+	Metering *MeteringConfig `json:"metering,omitempty"`
+
+	// Keeps persistent volumes for the service workloads requesting them; nil rejects such deploys
+	Volumes *VolumesConfig `json:"volumes,omitempty"`
+
+	// Reclaims memory from idle workloads through the Firecracker balloon device; nil disables it
+	Balloon *BalloonConfig `json:"balloon,omitempty"`
+
+	// Guards each trigger subject of a function workload with a circuit breaker; nil disables them
+	TriggerBreaker *TriggerBreakerConfig `json:"trigger_breaker,omitempty"`
+
+	// Moves the node's workloads to its peers when the node receives a shutdown notice, e.g. a spot
+	// instance interruption; nil only evacuates at the request of the control API
+	Evacuation *EvacuationConfig `json:"evacuation,omitempty"`
+
+	// Snapshots service workloads' machines into an object store bucket, from which they can be
+	// restored on any node sharing it; nil rejects checkpoint and restore requests
+	Checkpoints *CheckpointConfig `json:"checkpoints,omitempty"`
+
+	// Persists the node's keypair and xkey across restarts; nil generates new keys on every start
+	Identity *IdentityConfig `json:"identity,omitempty"`
+
+	// Enables self-updates through the control API; nil rejects every update request
+	Update *UpdateConfig `json:"update,omitempty"`
+
+	// Serves pprof profiles and snapshots of the node's internal state to holders of a bearer
+	// token; nil disables the diagnostics listener and rejects diagnostics requests
+	Diagnostics *DiagnosticsConfig `json:"diagnostics,omitempty"`
+
+	// Runs agents in the node's own process instead of nex-agent processes or VMs. They accept every
+	// deployment and echo trigger payloads without running workloads, for testing nodes and control
+	// API clients without agent binaries or root privileges. Requires no_sandbox
+	InMemoryAgents bool `json:"in_memory_agents,omitempty"`
+
+	// Answers nexus info requests by querying every node in the nexus and merging their info
+	NexusAggregator bool `json:"nexus_aggregator,omitempty"`
+
+	// Registers the node as a member of the nexus in a key-value bucket; nil leaves the node to be
+	// discovered by pinging
+	NexusRegistry *NexusRegistryConfig `json:"nexus_registry,omitempty"`
+
+	// Caches the responses of functions deployed with a result cache policy in a key-value bucket
+	// shared by the nodes using it; nil runs every trigger, whatever the policy
+	ResultCache *ResultCacheConfig `json:"result_cache,omitempty"`
+
+	// Default pool of workers executing each deployed function's trigger messages, which deploy
+	// requests may override
+	TriggerWorkers *controlapi.TriggerWorkerPool `json:"trigger_workers,omitempty"`
+
+	// Warm agent pools dedicated to individual workload types, each with its own size and
+	// optionally its own rootfs. Workload types without a dedicated pool share the machine pool
+	WorkloadPools map[controlapi.NexWorkload]WorkloadPoolConfig `json:"workload_pools,omitempty"`
+
+	// Tunes the OTLP exporters, trace sampling and resource attributes of the node's telemetry,
+	// overridden by the standard OTEL_* environment variables; nil keeps the defaults
+	Telemetry *TelemetryConfig `json:"telemetry,omitempty"`
+
 	// Public NATS server options; when non-nil, a public "userland" NATS server is started during node init
 	PublicNATSServer *server.Options `json:"public_nats_server,omitempty"`
 
@@ -80,6 +221,448 @@ type ServiceConfig struct {
 	Configuration json.RawMessage `json:"config"`
 }
 
+// Limits enforced by the node's internal NATS server; zero values leave the server's defaults
+// in place. The per-agent limits apply to the account each agent logs in to, which the node
+// also uses for its own connection to the agent
+type InternalNATSConfig struct {
+	MaxPayloadBytes          int32 `json:"max_payload_bytes,omitempty"`
+	MaxConnections           int   `json:"max_connections,omitempty"`
+	WriteDeadlineMillisecond int   `json:"write_deadline_ms,omitempty"`
+	MaxConnectionsPerAgent   int   `json:"max_connections_per_agent,omitempty"`
+	MaxSubscriptionsPerAgent int   `json:"max_subscriptions_per_agent,omitempty"`
+
+	// How often each agent's credentials are rotated; zero disables rotation
+	CredentialRotationIntervalMillisecond int `json:"credential_rotation_interval_ms,omitempty"`
+}
+
+// When present, namespaced control API requests are only served for issuers that a rule grants
+// the requested operation in the request's namespace. Rules are read from the configuration
+// file or, when a bucket is given, from a key in a JetStream key-value bucket, which is watched
+// so that policy changes take effect without a restart. Every decision is published as an
+// audit event
+type AccessPolicyConfig struct {
+	Rules  []AccessRule `json:"rules,omitempty"`
+	Bucket string       `json:"bucket,omitempty"`
+	Key    string       `json:"key,omitempty"`
+}
+
+// Audit records are always published; when configured, they are also captured by a JetStream
+// stream, which is created if it does not exist. Records older than the max age are discarded
+type AuditConfig struct {
+	Stream            string `json:"stream,omitempty"`
+	MaxAgeMillisecond int    `json:"max_age_ms,omitempty"`
+}
+
+// Namespace objects are shared by every node using the same bucket, which is created if it does
+// not exist. When required, deploys into namespaces that were not created are rejected
+type NamespacesConfig struct {
+	Bucket  string `json:"bucket,omitempty"`
+	Require bool   `json:"require,omitempty"`
+}
+
+// Nodes register in the bucket, NEX_NEXUS unless set, which is created if it does not exist.
+// Each node refreshes its entry with every heartbeat; entries that are not refreshed within the
+// TTL, 90 seconds unless set, expire. The TTL is fixed by the node creating the bucket
+type NexusRegistryConfig struct {
+	Bucket    string `json:"bucket,omitempty"`
+	TTLMillis int    `json:"ttl_ms,omitempty"`
+}
+
+// Function results are cached in the bucket, NEX_RESULTS unless set, which is created if it does
+// not exist. Cached results expire after the max age, an hour unless set, whatever the TTL of a
+// workload's policy, and the bucket holds at most MaxBytes, which is unlimited unless set. Both
+// are fixed by the node creating the bucket
+type ResultCacheConfig struct {
+	Bucket       string `json:"bucket,omitempty"`
+	MaxAgeMillis int64  `json:"max_age_ms,omitempty"`
+	MaxBytes     int64  `json:"max_bytes,omitempty"`
+}
+
+// Declared resources are shared by every node using the same bucket, which is created if it does
+// not exist
+type DeclaredResourcesConfig struct {
+	Bucket string `json:"bucket,omitempty"`
+}
+
+// Events and logs published while the node is disconnected are appended to a file in the outbox
+// directory, which defaults to a directory under the default resource directory. Once the file
+// holds MaxBytes, 64 MiB unless set, further messages are dropped and counted until the node
+// reconnects
+type OutboxConfig struct {
+	Directory string `json:"directory,omitempty"`
+	MaxBytes  int64  `json:"max_bytes,omitempty"`
+}
+
+// Usage is sampled from each workload's machine every sample interval, 5 seconds unless set, and
+// a signed report of the usage accumulated by each namespace is published every report interval,
+// a minute unless set, on the metering subject followed by the node's public key
+type MeteringConfig struct {
+	Subject              string `json:"subject,omitempty"`
+	SampleIntervalMillis int    `json:"sample_interval_ms,omitempty"`
+	ReportIntervalMillis int    `json:"report_interval_ms,omitempty"`
+}
+
+// An evacuation moves the node's workloads to its peers within the deadline, 90 seconds unless
+// set, and then puts the node in lame duck mode. Besides control API requests, it is started by a
+// SIGTERM when OnSigterm is set, in which case the node shuts down once it is done, or by a notice
+// at the notice URL, which is polled every notice poll interval, 5 seconds unless set. Any 200
+// response other than FALSE is a notice, matching the spot interruption endpoints of the cloud
+// instance metadata services, e.g. http://169.254.169.254/latest/meta-data/spot/instance-action
+type EvacuationConfig struct {
+	DeadlineMillis           int               `json:"deadline_ms,omitempty"`
+	OnSigterm                bool              `json:"on_sigterm,omitempty"`
+	NoticeUrl                string            `json:"notice_url,omitempty"`
+	NoticeHeaders            map[string]string `json:"notice_headers,omitempty"`
+	NoticePollIntervalMillis int               `json:"notice_poll_interval_ms,omitempty"`
+}
+
+// Checkpoints are stored in the bucket, NEXCHECKPOINTS unless set, which is created when it does
+// not exist. Besides those requested through the control API, every running service workload is
+// checkpointed each interval when one is set. Only the most recent checkpoints of each workload
+// are kept, 3 unless set. Checkpoints hold the workload's memory, so the bucket must be protected
+// like the workloads' secrets
+type CheckpointConfig struct {
+	Bucket         string `json:"bucket,omitempty"`
+	IntervalMillis int    `json:"interval_ms,omitempty"`
+	Retain         int    `json:"retain,omitempty"`
+}
+
+// The node's keypair, which gives the node its ID, and its xkey are kept in the key file, which is
+// created on first start and must not be accessible to other users. When the xkey is rotated, the
+// key it replaced still decrypts payloads for the rotation grace period, an hour unless set
+type IdentityConfig struct {
+	KeyFile             string `json:"key_file"`
+	RotationGraceMillis int    `json:"rotation_grace_ms,omitempty"`
+}
+
+// Persistent workload volumes are kept in the volumes directory, which defaults to a directory
+// under the default resource directory. Sandboxed nodes keep each volume as an ext4 image that is
+// attached to the workload's VM, and so require mkfs.ext4; nodes without a sandbox keep a
+// directory. Volumes requested without a size get the default size, 1 GiB unless set, and none
+// may be larger than the max size, when one is set
+type VolumesConfig struct {
+	Directory      string `json:"directory,omitempty"`
+	DefaultSizeMib int    `json:"default_size_mib,omitempty"`
+	MaxSizeMib     int    `json:"max_size_mib,omitempty"`
+}
+
+// Self-update settings. Update requests must carry a signature, by one of the trusted keys,
+// over the SHA-256 digest of the new binary. Staged binaries are kept in the staging directory,
+// which defaults to a directory under the default resource directory
+type UpdateConfig struct {
+	TrustedKeys []string `json:"trusted_keys"`
+	StagingDir  string   `json:"staging_dir,omitempty"`
+
+	// File through which a node hands its identity and workloads to the binary it execs
+	HandoffFilepath string `json:"handoff_filepath,omitempty"`
+}
+
+// Deploy-time artifact scanning. The scanner is either a command, run with the path of a copy
+// of the artifact as its last argument and the scan request as JSON on stdin, or a NATS service
+// requested on a subject. Either answers with a scan response as JSON. Deployments are blocked
+// when a finding is at least as severe as the threshold, which defaults to high, and, unless
+// FailOpen is set, when the scanner fails
+type ArtifactScanConfig struct {
+	Command       []string `json:"command,omitempty"`
+	Subject       string   `json:"subject,omitempty"`
+	Threshold     string   `json:"severity_threshold,omitempty"`
+	TimeoutMillis int      `json:"timeout_ms,omitempty"`
+	FailOpen      bool     `json:"fail_open,omitempty"`
+}
+
+// Thresholds at which a workload is quarantined. A function workload is quarantined, with its
+// trigger subscriptions paused, once at least FailureRateThreshold (0 to 1) of the trigger
+// executions within the window have failed, provided there were at least MinExecutions. An
+// essential workload is quarantined instead of being restarted once it has crashed more than
+// MaxCrashes times. A zero threshold disables the corresponding check
+type QuarantineConfig struct {
+	FailureRateThreshold float64 `json:"failure_rate_threshold,omitempty"`
+	MinExecutions        int     `json:"min_executions,omitempty"`
+	WindowMillis         int     `json:"window_ms,omitempty"`
+	MaxCrashes           uint    `json:"max_crashes,omitempty"`
+}
+
+// Thresholds at which the node enters the pressure state. The CPU and memory thresholds are PSI
+// "some" avg10 stall percentages; a zero threshold is not checked. The node recovers once
+// RecoverySamples consecutive samples are below every threshold. Workloads labelled
+// nex.priority=low are paused while under pressure when PauseLowPriority is set
+type PressureConfig struct {
+	IntervalMillis            int     `json:"interval_ms,omitempty"`
+	CpuSomeAvg10              float64 `json:"cpu_some_avg10,omitempty"`
+	MemorySomeAvg10           float64 `json:"memory_some_avg10,omitempty"`
+	MinMemoryAvailablePercent float64 `json:"min_memory_available_percent,omitempty"`
+	RecoverySamples           int     `json:"recovery_samples,omitempty"`
+	PauseLowPriority          bool    `json:"pause_low_priority,omitempty"`
+
+	// Also pauses the VMs of the low-priority workloads, not just their triggers
+	PauseMachines bool `json:"pause_machines,omitempty"`
+}
+
+// Warm pool of agents reserved for a single workload type
+type WorkloadPoolConfig struct {
+	PoolSize int `json:"pool_size"`
+
+	// Root filesystem image booted by the pool's VMs; defaults to the node's rootfs and is
+	// ignored when running without a sandbox
+	RootFsFilepath string `json:"rootfs_filepath,omitempty"`
+}
+
+// Every VM is created with a deflated balloon device. A workload whose type has a policy has its
+// balloon inflated, returning memory to the host, once it has been idle for the policy's idle
+// period; trigger activity deflates it again before the trigger executes
+type BalloonConfig struct {
+	// How often the reclaimer looks for idle workloads
+	IntervalMillis int `json:"interval_ms,omitempty"`
+
+	// Lets the guest deflate the balloon itself when it runs out of memory
+	DeflateOnOom bool `json:"deflate_on_oom,omitempty"`
+
+	// Seconds between the balloon statistics updates reported by the guest; 0 disables them
+	StatsIntervalSeconds int `json:"stats_interval_s,omitempty"`
+
+	// Reclaim policies keyed by workload type (e.g. v8, wasm)
+	Policies map[string]BalloonPolicy `json:"policies"`
+}
+
+type BalloonPolicy struct {
+	IdleMillis int `json:"idle_ms"`
+
+	// Share of the VM's memory to reclaim, from 1 to 90 percent
+	ReclaimPercent int `json:"reclaim_percent"`
+}
+
+// A trigger subject's breaker opens after ConsecutiveFailures failed executions in a row and stops
+// forwarding its triggers to the workload for the cool-down period, then half-opens to let a
+// single trigger through. Rejected triggers are dropped, or answered with an empty reply carrying
+// the Nex-Trigger-Error header when RejectWithError is set
+type TriggerBreakerConfig struct {
+	ConsecutiveFailures int  `json:"consecutive_failures"`
+	CooldownMillis      int  `json:"cooldown_ms,omitempty"`
+	RejectWithError     bool `json:"reject_with_error,omitempty"`
+}
+
+// The diagnostics listener serves the runtime's pprof profiles under /debug/pprof/ and a snapshot
+// of the node's agent pools, workloads and subscriptions at /debug/state, on the listen address,
+// 127.0.0.1:6061 unless set. Every request must carry the token as a bearer token; reading it
+// from the token file keeps it out of the configuration. The same snapshot and profiles can be
+// requested through the control API by issuers granted info in the system namespace
+type DiagnosticsConfig struct {
+	Listen    string `json:"listen,omitempty"`
+	Token     string `json:"token,omitempty"`
+	TokenFile string `json:"token_file,omitempty"`
+}
+
+// Settings of the telemetry exported to the OTLP endpoint, the otlp_exporter_url unless set. The
+// endpoint is reached in plaintext unless TLS is set, and every export carries the given headers,
+// e.g. an API key. Root spans are sampled at the trace sample ratio, 1 unless set, and children
+// follow their parent's decision. Periodically read metrics are exported every metric export
+// interval, 3 seconds unless set. Resource attributes, e.g. deployment.environment or
+// cloud.region, are added to every span, metric and log record
+type TelemetryConfig struct {
+	Endpoint                   string            `json:"endpoint,omitempty"`
+	TLS                        bool              `json:"tls,omitempty"`
+	Headers                    map[string]string `json:"headers,omitempty"`
+	TraceSampleRatio           *float64          `json:"trace_sample_ratio,omitempty"`
+	MetricExportIntervalMillis int               `json:"metric_export_interval_ms,omitempty"`
+	ResourceAttributes         map[string]string `json:"resource_attributes,omitempty"`
+}
+
+// Grants an issuer (a public key, or * for any issuer) operations in a namespace (or * for
+// every namespace)
+type AccessRule struct {
+	Issuer     string                 `json:"issuer"`
+	Namespace  string                 `json:"namespace"`
+	Operations []controlapi.Operation `json:"operations"`
+}
+
+func (r AccessRule) Validate() error {
+	if r.Issuer != "*" && !nkeys.IsValidPublicKey(r.Issuer) {
+		return fmt.Errorf("access rule issuer %s is neither * nor a valid public key", r.Issuer)
+	}
+
+	if r.Namespace == "" {
+		return errors.New("access rules require a namespace")
+	}
+
+	for _, op := range r.Operations {
+		if !slices.Contains(controlapi.Operations, op) {
+			return fmt.Errorf("unknown access rule operation: %s", op)
+		}
+	}
+
+	return nil
+}
+
+const (
+	LogSinkTypeFile   = "file"
+	LogSinkTypeSyslog = "syslog"
+	LogSinkTypeLoki   = "loki"
+	LogSinkTypeS3     = "s3"
+)
+
+// Forwards workload logs, in addition to publishing them on $NEX.logs, to a rotating file,
+// syslog, a Loki push endpoint or batches of S3 objects. A sink receives the logs of the listed
+// namespaces (every namespace when none or * is listed) at or above its minimum level, written
+// in batches of at most the batch size at least once per flush interval
+type LogSinkConfig struct {
+	Name                     string   `json:"name"`
+	Type                     string   `json:"type"`
+	Namespaces               []string `json:"namespaces,omitempty"`
+	MinLevel                 string   `json:"min_level,omitempty"`
+	BatchSize                int      `json:"batch_size,omitempty"`
+	FlushIntervalMillisecond int      `json:"flush_interval_ms,omitempty"`
+
+	File   *FileLogSinkConfig   `json:"file,omitempty"`
+	Syslog *SyslogLogSinkConfig `json:"syslog,omitempty"`
+	Loki   *LokiLogSinkConfig   `json:"loki,omitempty"`
+	S3     *S3LogSinkConfig     `json:"s3,omitempty"`
+}
+
+// Writes JSON lines to a file, rotated once it reaches the maximum size
+type FileLogSinkConfig struct {
+	Path       string `json:"path"`
+	MaxSizeMib int    `json:"max_size_mib,omitempty"`
+	MaxBackups int    `json:"max_backups,omitempty"`
+}
+
+// Writes to the local syslog daemon, or to a remote one when a network and address are given
+type SyslogLogSinkConfig struct {
+	Network string `json:"network,omitempty"`
+	Address string `json:"address,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+}
+
+// Pushes to Loki's push API. The basic auth password is read from the named environment
+// variable so that it stays out of the configuration file
+type LokiLogSinkConfig struct {
+	Url         string            `json:"url"`
+	TenantId    string            `json:"tenant_id,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	PasswordEnv string            `json:"password_env,omitempty"`
+}
+
+// Uploads each batch as a JSON lines object. Credentials are read from the standard AWS
+// environment variables; a custom endpoint (e.g. MinIO) is addressed path-style
+type S3LogSinkConfig struct {
+	Bucket   string `json:"bucket"`
+	Region   string `json:"region"`
+	Prefix   string `json:"prefix,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+func (s LogSinkConfig) Validate() error {
+	if s.Name == "" {
+		return errors.New("log sinks require a name")
+	}
+
+	if s.BatchSize < 0 || s.FlushIntervalMillisecond < 0 {
+		return fmt.Errorf("log sink %s batch size and flush interval must be >= 0", s.Name)
+	}
+
+	if s.MinLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(s.MinLevel)); err != nil {
+			return fmt.Errorf("log sink %s has an invalid minimum level: %s", s.Name, err)
+		}
+	}
+
+	switch s.Type {
+	case LogSinkTypeFile:
+		if s.File == nil || s.File.Path == "" {
+			return fmt.Errorf("file log sink %s requires a path", s.Name)
+		}
+		if s.File.MaxSizeMib < 0 || s.File.MaxBackups < 0 {
+			return fmt.Errorf("file log sink %s max size and backups must be >= 0", s.Name)
+		}
+	case LogSinkTypeSyslog:
+		if s.Syslog != nil && (s.Syslog.Network == "") != (s.Syslog.Address == "") {
+			return fmt.Errorf("syslog log sink %s requires both a network and an address, or neither", s.Name)
+		}
+	case LogSinkTypeLoki:
+		if s.Loki == nil || s.Loki.Url == "" {
+			return fmt.Errorf("loki log sink %s requires a url", s.Name)
+		}
+	case LogSinkTypeS3:
+		if s.S3 == nil || s.S3.Bucket == "" || s.S3.Region == "" {
+			return fmt.Errorf("s3 log sink %s requires a bucket and region", s.Name)
+		}
+	default:
+		return fmt.Errorf("log sink %s has unknown type %q", s.Name, s.Type)
+	}
+
+	return nil
+}
+
+// When present, deploy requests that opt into queueing are held (up to max size and
+// for at most the given timeout) until a warm agent is available instead of being rejected
+type DeployQueueConfig struct {
+	MaxSize            int `json:"max_size"`
+	TimeoutMillisecond int `json:"timeout_ms,omitempty"`
+}
+
+// When present, the kernel and rootfs images are fetched from an OCI registry or NATS object
+// store at node startup, verified against their pinned checksums and cached locally, replacing
+// the configured kernel and rootfs file paths. A rootfs variant replaces the default rootfs on
+// nodes that serve only that workload type
+type ArtifactsConfig struct {
+	CacheDir       string                                  `json:"cache_dir,omitempty"`
+	Kernel         *ArtifactSpec                           `json:"kernel,omitempty"`
+	RootFs         *ArtifactSpec                           `json:"rootfs,omitempty"`
+	RootFsVariants map[controlapi.NexWorkload]ArtifactSpec `json:"rootfs_variants,omitempty"`
+}
+
+// A pinned artifact; location is either oci://<registry>/<repository>(:<tag>|@<digest>) or
+// nats://<bucket>/<object>, and sha256 is the hex digest of its content (after decompressing a
+// gzipped OCI layer)
+type ArtifactSpec struct {
+	Location string `json:"location"`
+	Sha256   string `json:"sha256"`
+	Version  string `json:"version,omitempty"`
+}
+
+func (a ArtifactSpec) Validate() error {
+	if !strings.HasPrefix(a.Location, "oci://") && !strings.HasPrefix(a.Location, "nats://") {
+		return fmt.Errorf("artifact location %q must use the oci:// or nats:// scheme", a.Location)
+	}
+
+	if len(a.Sha256) != 64 || strings.Trim(strings.ToLower(a.Sha256), "0123456789abcdef") != "" {
+		return fmt.Errorf("artifact %s requires a hex encoded sha256 checksum", a.Location)
+	}
+
+	return nil
+}
+
+// When present, firecracker is launched through the jailer so that each VM runs in its own
+// chroot under a dedicated UID/GID, allocated sequentially from the given range starts, and
+// is placed in a cgroup with the given settings (e.g. "cpu.max=50000 100000")
+type JailerConfig struct {
+	JailerBinary  string   `json:"jailer_binary,omitempty"`
+	ChrootBaseDir string   `json:"chroot_base_dir,omitempty"`
+	UidRangeStart int      `json:"uid_range_start,omitempty"`
+	GidRangeStart int      `json:"gid_range_start,omitempty"`
+	CgroupVersion string   `json:"cgroup_version,omitempty"`
+	Cgroups       []string `json:"cgroups,omitempty"`
+	NumaNode      *int     `json:"numa_node,omitempty"`
+}
+
+// Declares a workload type provided by an agent extension (a Go plugin or external provider
+// process configured within the rootfs) rather than built into the agent. Extension types must
+// also be listed in the node's workload types to be accepted
+type ProviderExtension struct {
+	WorkloadType     controlapi.NexWorkload `json:"type"`
+	Description      string                 `json:"description,omitempty"`
+	SupportsTriggers bool                   `json:"supports_triggers,omitempty"`
+}
+
+// Host memory and CPU withheld from workloads, and the ratio by which the remaining capacity
+// may be overcommitted. Deployments that would exceed the resulting capacity are rejected or queued
+type ResourceConfig struct {
+	ReservedMemoryMib int     `json:"reserved_memory_mib,omitempty"`
+	ReservedVcpuCount int     `json:"reserved_vcpu_count,omitempty"`
+	OvercommitRatio   float64 `json:"overcommit_ratio,omitempty"`
+}
+
 type AutostartConfig struct {
 	Workloads []AutostartDeployRequest `json:"workloads"`
 }
@@ -94,6 +677,7 @@ type AutostartDeployRequest struct {
 	JsDomain        *string                `json:"jsdomain,omitempty"`
 	Environment     map[string]string      `json:"environment"`
 	TriggerSubjects []string               `json:"trigger_subjects,omitempty"`
+	Group           string                 `json:"group,omitempty"`
 }
 
 func (c *NodeConfiguration) Validate() bool {
@@ -103,6 +687,345 @@ func (c *NodeConfiguration) Validate() bool {
 		c.Errors = append(c.Errors, errors.New("machine pool size must be >= 1"))
 	}
 
+	if c.LogLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+			c.Errors = append(c.Errors, fmt.Errorf("invalid log level: %s", err))
+		}
+	}
+
+	if c.ExecutionHistorySize < 0 {
+		c.Errors = append(c.Errors, errors.New("execution history size must be >= 0"))
+	}
+
+	if c.InMemoryAgents && !c.NoSandbox {
+		c.Errors = append(c.Errors, errors.New("in-memory agents require no sandbox mode"))
+	}
+
+	if c.AgentReapIntervalMillisecond < 0 {
+		c.Errors = append(c.Errors, errors.New("agent reap interval must be >= 0"))
+	}
+
+	if c.SubscriptionJanitorMillisecond < 0 {
+		c.Errors = append(c.Errors, errors.New("subscription janitor interval must be >= 0"))
+	}
+
+	if c.TriggerWorkers != nil && (c.TriggerWorkers.Workers < 0 || c.TriggerWorkers.QueueSize < 0) {
+		c.Errors = append(c.Errors, errors.New("trigger worker pool sizes must be >= 0"))
+	}
+
+	if c.InternalNATS != nil {
+		if c.InternalNATS.MaxPayloadBytes < 0 || c.InternalNATS.MaxConnections < 0 || c.InternalNATS.WriteDeadlineMillisecond < 0 ||
+			c.InternalNATS.MaxConnectionsPerAgent < 0 || c.InternalNATS.MaxSubscriptionsPerAgent < 0 {
+			c.Errors = append(c.Errors, errors.New("internal NATS limits must be >= 0"))
+		}
+
+		if c.InternalNATS.CredentialRotationIntervalMillisecond < 0 {
+			c.Errors = append(c.Errors, errors.New("internal NATS credential rotation interval must be >= 0"))
+		}
+
+		if c.InternalNATS.MaxConnectionsPerAgent == 1 {
+			c.Errors = append(c.Errors, errors.New("internal NATS max connections per agent must leave room for the node's own connection to the agent"))
+		}
+	}
+
+	sinkNames := make(map[string]struct{})
+	for _, sink := range c.LogSinks {
+		if err := sink.Validate(); err != nil {
+			c.Errors = append(c.Errors, err)
+		}
+
+		if _, ok := sinkNames[sink.Name]; ok {
+			c.Errors = append(c.Errors, fmt.Errorf("log sink name %s is not unique", sink.Name))
+		}
+		sinkNames[sink.Name] = struct{}{}
+	}
+
+	if c.DeployQueue != nil {
+		if c.DeployQueue.MaxSize < 1 {
+			c.Errors = append(c.Errors, errors.New("deploy queue max size must be >= 1"))
+		}
+
+		if c.DeployQueue.TimeoutMillisecond < 0 {
+			c.Errors = append(c.Errors, errors.New("deploy queue timeout must be >= 0"))
+		}
+	}
+
+	if c.AccessPolicy != nil {
+		for _, rule := range c.AccessPolicy.Rules {
+			if err := rule.Validate(); err != nil {
+				c.Errors = append(c.Errors, err)
+			}
+		}
+	}
+
+	if c.Audit != nil && c.Audit.MaxAgeMillisecond < 0 {
+		c.Errors = append(c.Errors, errors.New("audit max age cannot be negative"))
+	}
+
+	if c.MaxArtifactBytes < 0 {
+		c.Errors = append(c.Errors, errors.New("max artifact bytes cannot be negative"))
+	}
+
+	if c.ArtifactScan != nil {
+		if (len(c.ArtifactScan.Command) == 0) == (c.ArtifactScan.Subject == "") {
+			c.Errors = append(c.Errors, errors.New("artifact scanning requires either a command or a subject"))
+		}
+
+		if c.ArtifactScan.Threshold != "" && controlapi.SeverityRank(c.ArtifactScan.Threshold) < 0 {
+			c.Errors = append(c.Errors, fmt.Errorf("unknown artifact scan severity threshold: %s", c.ArtifactScan.Threshold))
+		}
+
+		if c.ArtifactScan.TimeoutMillis < 0 {
+			c.Errors = append(c.Errors, errors.New("artifact scan timeout must be >= 0"))
+		}
+	}
+
+	if c.Quarantine != nil {
+		if c.Quarantine.FailureRateThreshold < 0 || c.Quarantine.FailureRateThreshold > 1 {
+			c.Errors = append(c.Errors, errors.New("quarantine failure rate threshold must be between 0 and 1"))
+		}
+
+		if c.Quarantine.MinExecutions < 0 || c.Quarantine.WindowMillis < 0 {
+			c.Errors = append(c.Errors, errors.New("quarantine minimum executions and window must be >= 0"))
+		}
+	}
+
+	if c.Pressure != nil {
+		if c.Pressure.CpuSomeAvg10 < 0 || c.Pressure.MemorySomeAvg10 < 0 || c.Pressure.MinMemoryAvailablePercent < 0 {
+			c.Errors = append(c.Errors, errors.New("pressure thresholds must be >= 0"))
+		}
+
+		if c.Pressure.CpuSomeAvg10 == 0 && c.Pressure.MemorySomeAvg10 == 0 && c.Pressure.MinMemoryAvailablePercent == 0 {
+			c.Errors = append(c.Errors, errors.New("pressure monitoring requires at least one threshold"))
+		}
+
+		if c.Pressure.IntervalMillis < 0 || c.Pressure.RecoverySamples < 0 {
+			c.Errors = append(c.Errors, errors.New("pressure interval and recovery samples must be >= 0"))
+		}
+	}
+
+	for workloadType, pool := range c.WorkloadPools {
+		if !slices.Contains(c.WorkloadTypes, workloadType) {
+			c.Errors = append(c.Errors, fmt.Errorf("workload pool configured for unsupported workload type %s", workloadType))
+		}
+
+		if pool.PoolSize < 1 {
+			c.Errors = append(c.Errors, fmt.Errorf("pool size of the %s workload pool must be >= 1", workloadType))
+		}
+
+		if pool.RootFsFilepath != "" && !c.NoSandbox {
+			if _, err := os.Stat(pool.RootFsFilepath); errors.Is(err, os.ErrNotExist) {
+				c.Errors = append(c.Errors, err)
+			}
+		}
+	}
+
+	if c.Balloon != nil {
+		if c.NoSandbox {
+			c.Errors = append(c.Errors, errors.New("balloon memory reclaim requires a sandboxed node"))
+		}
+
+		if c.Balloon.IntervalMillis < 0 || c.Balloon.StatsIntervalSeconds < 0 {
+			c.Errors = append(c.Errors, errors.New("balloon intervals must be >= 0"))
+		}
+
+		for workloadType, policy := range c.Balloon.Policies {
+			if policy.IdleMillis <= 0 || policy.ReclaimPercent < 1 || policy.ReclaimPercent > 90 {
+				c.Errors = append(c.Errors, fmt.Errorf("invalid balloon policy for workload type %s; idle period must be > 0 and reclaim between 1 and 90 percent", workloadType))
+			}
+		}
+	}
+
+	if c.TriggerBreaker != nil {
+		if c.TriggerBreaker.ConsecutiveFailures < 1 {
+			c.Errors = append(c.Errors, errors.New("trigger breaker consecutive failures must be >= 1"))
+		}
+
+		if c.TriggerBreaker.CooldownMillis < 0 {
+			c.Errors = append(c.Errors, errors.New("trigger breaker cool-down must be >= 0"))
+		}
+	}
+
+	if c.Outbox != nil && c.Outbox.MaxBytes < 0 {
+		c.Errors = append(c.Errors, errors.New("outbox max bytes must be >= 0"))
+	}
+
+	if c.Metering != nil {
+		if c.Metering.SampleIntervalMillis < 0 || c.Metering.ReportIntervalMillis < 0 {
+			c.Errors = append(c.Errors, errors.New("metering intervals must be >= 0"))
+		}
+
+		if c.Metering.Subject != "" && strings.ContainsAny(c.Metering.Subject, "*> ") {
+			c.Errors = append(c.Errors, errors.New("metering subject must not contain wildcards or spaces"))
+		}
+	}
+
+	if c.Evacuation != nil {
+		if c.Evacuation.DeadlineMillis < 0 || c.Evacuation.NoticePollIntervalMillis < 0 {
+			c.Errors = append(c.Errors, errors.New("evacuation deadline and notice poll interval must be >= 0"))
+		}
+
+		if c.Evacuation.NoticeUrl != "" {
+			u, err := url.Parse(c.Evacuation.NoticeUrl)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				c.Errors = append(c.Errors, errors.New("evacuation notice url must be an http(s) URL"))
+			}
+		}
+	}
+
+	if c.Checkpoints != nil {
+		// only Firecracker machines can be snapshotted
+		if c.NoSandbox {
+			c.Errors = append(c.Errors, errors.New("checkpoints require a sandboxed node"))
+		}
+
+		// snapshots would have to be written and loaded inside each VM's chroot
+		if c.Jailer != nil {
+			c.Errors = append(c.Errors, errors.New("checkpoints cannot be used with the jailer"))
+		}
+
+		if c.Checkpoints.IntervalMillis < 0 || c.Checkpoints.Retain < 0 {
+			c.Errors = append(c.Errors, errors.New("checkpoint interval and retention must be >= 0"))
+		}
+	}
+
+	if c.ResultCache != nil && (c.ResultCache.MaxAgeMillis < 0 || c.ResultCache.MaxBytes < 0) {
+		c.Errors = append(c.Errors, errors.New("result cache max age and max bytes must be >= 0"))
+	}
+
+	if c.NexusRegistry != nil && c.NexusRegistry.TTLMillis < 0 {
+		c.Errors = append(c.Errors, errors.New("nexus registry ttl must be >= 0"))
+	}
+
+	if c.Identity != nil {
+		if c.Identity.KeyFile == "" {
+			c.Errors = append(c.Errors, errors.New("identity key file is required"))
+		}
+
+		if c.Identity.RotationGraceMillis < 0 {
+			c.Errors = append(c.Errors, errors.New("identity rotation grace period must be >= 0"))
+		}
+	}
+
+	if c.Volumes != nil {
+		if c.Volumes.DefaultSizeMib < 0 || c.Volumes.MaxSizeMib < 0 {
+			c.Errors = append(c.Errors, errors.New("volume sizes must be >= 0"))
+		}
+
+		if c.Volumes.MaxSizeMib > 0 && c.Volumes.DefaultSizeMib > c.Volumes.MaxSizeMib {
+			c.Errors = append(c.Errors, errors.New("default volume size must not exceed the max volume size"))
+		}
+
+		// volume images would have to be linked into each VM's chroot
+		if c.Jailer != nil {
+			c.Errors = append(c.Errors, errors.New("volumes cannot be used with the jailer"))
+		}
+	}
+
+	if c.Update != nil {
+		if len(c.Update.TrustedKeys) == 0 {
+			c.Errors = append(c.Errors, errors.New("updates require at least one trusted key"))
+		}
+
+		for _, key := range c.Update.TrustedKeys {
+			if !nkeys.IsValidPublicKey(key) {
+				c.Errors = append(c.Errors, fmt.Errorf("update trusted key %s is not a valid public key", key))
+			}
+		}
+	}
+
+	for _, key := range c.AdminKeys {
+		if !nkeys.IsValidPublicKey(key) {
+			c.Errors = append(c.Errors, fmt.Errorf("admin key %s is not a valid public key", key))
+		}
+	}
+
+	if c.Resources != nil {
+		if c.Resources.ReservedMemoryMib < 0 {
+			c.Errors = append(c.Errors, errors.New("reserved memory must be >= 0"))
+		}
+
+		if c.Resources.ReservedVcpuCount < 0 {
+			c.Errors = append(c.Errors, errors.New("reserved vcpu count must be >= 0"))
+		}
+
+		if c.Resources.OvercommitRatio < 0 {
+			c.Errors = append(c.Errors, errors.New("overcommit ratio must be >= 0"))
+		}
+	}
+
+	if c.Artifacts != nil {
+		specs := make([]ArtifactSpec, 0)
+		if c.Artifacts.Kernel != nil {
+			specs = append(specs, *c.Artifacts.Kernel)
+		}
+		if c.Artifacts.RootFs != nil {
+			specs = append(specs, *c.Artifacts.RootFs)
+		}
+		for _, variant := range c.Artifacts.RootFsVariants {
+			specs = append(specs, variant)
+		}
+
+		for _, spec := range specs {
+			if err := spec.Validate(); err != nil {
+				c.Errors = append(c.Errors, err)
+			}
+		}
+	}
+
+	for _, workloadType := range c.WorkloadTypes {
+		if !slices.Contains(BuiltinWorkloadTypes, workloadType) && c.ProviderExtension(workloadType) == nil {
+			c.Errors = append(c.Errors, fmt.Errorf("workload type %s is neither built in nor declared as a provider extension", workloadType))
+		}
+	}
+
+	for _, extension := range c.ProviderExtensions {
+		if slices.Contains(BuiltinWorkloadTypes, extension.WorkloadType) {
+			c.Errors = append(c.Errors, fmt.Errorf("provider extension cannot override built-in workload type %s", extension.WorkloadType))
+		}
+	}
+
+	if c.Jailer != nil {
+		if c.NoSandbox {
+			c.Errors = append(c.Errors, errors.New("jailer cannot be used when running without a sandbox"))
+		}
+
+		if c.Jailer.UidRangeStart < 0 || c.Jailer.GidRangeStart < 0 {
+			c.Errors = append(c.Errors, errors.New("jailer uid and gid range starts must be >= 0"))
+		}
+
+		if c.Jailer.CgroupVersion != "" && c.Jailer.CgroupVersion != "1" && c.Jailer.CgroupVersion != "2" {
+			c.Errors = append(c.Errors, errors.New("jailer cgroup version must be 1 or 2"))
+		}
+
+		for _, cgroup := range c.Jailer.Cgroups {
+			if !strings.Contains(cgroup, "=") {
+				c.Errors = append(c.Errors, fmt.Errorf("invalid jailer cgroup setting %q; expected <file>=<value>", cgroup))
+			}
+		}
+	}
+
+	if c.Diagnostics != nil {
+		if (c.Diagnostics.Token == "") == (c.Diagnostics.TokenFile == "") {
+			c.Errors = append(c.Errors, errors.New("diagnostics require exactly one of token and token file"))
+		}
+	}
+
+	if c.Telemetry != nil {
+		if c.Telemetry.TraceSampleRatio != nil && (*c.Telemetry.TraceSampleRatio < 0 || *c.Telemetry.TraceSampleRatio > 1) {
+			c.Errors = append(c.Errors, errors.New("telemetry trace sample ratio must be between 0 and 1"))
+		}
+
+		if c.Telemetry.MetricExportIntervalMillis < 0 {
+			c.Errors = append(c.Errors, errors.New("telemetry metric export interval must be >= 0"))
+		}
+
+		if strings.Contains(c.Telemetry.Endpoint, "://") {
+			c.Errors = append(c.Errors, errors.New("telemetry endpoint must be a host:port without a scheme"))
+		}
+	}
+
 	if !c.NoSandbox {
 		if _, err := os.Stat(c.KernelFilepath); errors.Is(err, os.ErrNotExist) {
 			c.Errors = append(c.Errors, err)
@@ -131,6 +1054,63 @@ func (c *NodeConfiguration) Validate() bool {
 	return len(c.Errors) == 0
 }
 
+// Returns the provider extension declared for the given workload type, if any
+func (c *NodeConfiguration) ProviderExtension(workloadType controlapi.NexWorkload) *ProviderExtension {
+	for i := range c.ProviderExtensions {
+		if c.ProviderExtensions[i].WorkloadType == workloadType {
+			return &c.ProviderExtensions[i]
+		}
+	}
+
+	return nil
+}
+
+// Returns the warm pool from which agents for the given workload type are taken: the type's
+// dedicated pool if it has one, otherwise the shared machine pool, identified by DefaultAgentPool
+func (c *NodeConfiguration) AgentPool(workloadType controlapi.NexWorkload) controlapi.NexWorkload {
+	if _, ok := c.WorkloadPools[workloadType]; ok {
+		return workloadType
+	}
+
+	return DefaultAgentPool
+}
+
+// Returns every warm pool the node maintains, the shared machine pool first
+func (c *NodeConfiguration) AgentPools() []controlapi.NexWorkload {
+	pools := make([]controlapi.NexWorkload, 0, len(c.WorkloadPools)+1)
+	for workloadType := range c.WorkloadPools {
+		pools = append(pools, workloadType)
+	}
+	slices.Sort(pools)
+
+	return append([]controlapi.NexWorkload{DefaultAgentPool}, pools...)
+}
+
+// Returns the number of warm agents kept in the given pool. The shared machine pool is left
+// empty when every supported workload type has a dedicated pool
+func (c *NodeConfiguration) AgentPoolSize(pool controlapi.NexWorkload) int {
+	if pool != DefaultAgentPool {
+		return c.WorkloadPools[pool].PoolSize
+	}
+
+	for _, workloadType := range c.WorkloadTypes {
+		if _, ok := c.WorkloadPools[workloadType]; !ok {
+			return c.MachinePoolSize
+		}
+	}
+
+	return 0
+}
+
+// Returns the rootfs booted by the VMs of the given pool
+func (c *NodeConfiguration) AgentPoolRootFs(pool controlapi.NexWorkload) string {
+	if rootfs := c.WorkloadPools[pool].RootFsFilepath; rootfs != "" {
+		return rootfs
+	}
+
+	return c.RootFsFilepath
+}
+
 func DefaultNodeConfiguration() NodeConfiguration {
 	defaultNodePort := DefaultInternalNodePort
 	defaultVcpuCount := DefaultNodeVcpuCount
@@ -146,7 +1126,10 @@ func DefaultNodeConfiguration() NodeConfiguration {
 	config := NodeConfiguration{
 		AgentHandshakeTimeoutMillisecond: DefaultAgentHandshakeTimeoutMillisecond,
 		AgentPingTimeoutMillisecond:      DefaultAgentPingTimeoutMillisecond,
+		AgentReapIntervalMillisecond:     DefaultAgentReapIntervalMillisecond,
+		SubscriptionJanitorMillisecond:   DefaultSubscriptionJanitorMillisecond,
 		BinPath:                          DefaultBinPath,
+		ExecutionHistorySize:             DefaultExecutionHistorySize,
 		// CAUTION: This needs to be the IP of the node server's internal NATS --as visible to the agent.
 		// This is not necessarily the address on which the internal NATS server is actually listening inside the node.
 		InternalNodeHost: StringOrNil(DefaultInternalNodeHost),
@@ -160,6 +1143,10 @@ func DefaultNodeConfiguration() NodeConfiguration {
 		RateLimiters:    nil,
 		Tags:            tags,
 		WorkloadTypes:   DefaultWorkloadTypes,
+		TriggerWorkers: &controlapi.TriggerWorkerPool{
+			Workers:   DefaultTriggerWorkers,
+			QueueSize: DefaultTriggerQueueSize,
+		},
 		HostServicesConfiguration: &HostServicesConfig{
 			NatsUrl:      "", // this will trigger logic to re-use the main connection
 			NatsUserJwt:  "",
@@ -7,8 +7,10 @@ func GetNodeCapabilities(tags map[string]string) *controlapi.NodeCapabilities {
 		Sandboxable: false,
 		SupportedProviders: []controlapi.NexWorkload{
 			controlapi.NexWorkloadNative,
+			controlapi.NexWorkloadJob,
 			controlapi.NexWorkloadOCI,
 			controlapi.NexWorkloadWasm,
+			controlapi.NexWorkloadJVM,
 		},
 		NodeTags: tags,
 	}
@@ -7,6 +7,7 @@ func GetNodeCapabilities(tags map[string]string) *controlapi.NodeCapabilities {
 		Sandboxable: false,
 		SupportedProviders: []controlapi.NexWorkload{
 			controlapi.NexWorkloadNative,
+			controlapi.NexWorkloadJob,
 		},
 		NodeTags: tags,
 	}
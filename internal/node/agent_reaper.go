@@ -0,0 +1,138 @@
+package nexnode
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// Number of consecutive validation pings a pending agent may fail before it is reaped
+	agentReapFailureThreshold = 2
+
+	agentReapReasonHandshakeTimeout = "handshake_timeout"
+	agentReapReasonUnresponsive     = "unresponsive"
+	agentReapReasonInjectedFault    = "injected_fault"
+)
+
+// Periodically validates the agents in the pending pool until the workload manager stops
+func (w *WorkloadManager) runAgentReaper() {
+	interval := time.Duration(w.config.AgentReapIntervalMillisecond) * time.Millisecond
+	if interval <= 0 {
+		w.log.Debug("Pending agent reaper disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failures := make(map[string]int)
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			if atomic.LoadUint32(&w.closing) > 0 {
+				return
+			}
+
+			w.reapPendingAgents(failures)
+		}
+	}
+}
+
+// Pings every pending agent that has completed its handshake, reaping those that have failed
+// to respond to consecutive pings. Failure counts are carried between passes in the given map.
+// Returns the IDs of the reaped agents
+func (w *WorkloadManager) reapPendingAgents(failures map[string]int) []string {
+	w.poolMutex.Lock()
+	candidates := make(map[string]func() error)
+	for id, agentClient := range w.pendingAgents {
+		if _, handshook := w.handshakes[id]; handshook {
+			candidates[id] = agentClient.Ping
+		}
+	}
+	w.poolMutex.Unlock()
+
+	for id := range failures {
+		if _, ok := candidates[id]; !ok {
+			delete(failures, id)
+		}
+	}
+
+	reaped := make([]string, 0)
+	for id, ping := range candidates {
+		if ping() == nil {
+			delete(failures, id)
+			continue
+		}
+
+		failures[id]++
+		if failures[id] < agentReapFailureThreshold {
+			continue
+		}
+
+		delete(failures, id)
+
+		w.poolMutex.Lock()
+		ok := w.reapAgent(id, agentReapReasonUnresponsive)
+		w.poolMutex.Unlock()
+
+		if ok {
+			reaped = append(reaped, id)
+		}
+	}
+
+	return reaped
+}
+
+// Terminates the process of a pending agent and removes it from the pool, which causes the
+// process manager to replace it. Agents that have been claimed by a deployment in the meantime
+// are left alone. The caller must hold the pool mutex
+func (w *WorkloadManager) reapAgent(id string, reason string) bool {
+	agentClient, ok := w.pendingAgents[id]
+	if !ok {
+		return false
+	}
+
+	if w.states.claimed(id) {
+		// a deployment in progress surfaces its own failure if the agent is wedged
+		return false
+	}
+
+	// an agent that is already being stopped is left to that stop
+	if w.transitionWorkload(id, workloadStateStopping, nil) != nil {
+		return false
+	}
+	defer func() {
+		_ = w.transitionWorkload(id, workloadStateStopped, nil)
+	}()
+
+	delete(w.pendingAgents, id)
+	delete(w.agentPools, id)
+
+	_ = agentClient.Stop()
+
+	w.teardownMutex.Lock()
+	_ = w.natsint.DestroyCredentials(id)
+	err := w.procMan.StopProcess(id)
+	w.teardownMutex.Unlock()
+	if err != nil {
+		w.log.Warn("Failed to stop process of reaped agent",
+			slog.String("workload_id", id),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	w.t.AgentsReaped.Add(w.ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+
+	w.log.Warn("Reaped pending agent",
+		slog.String("workload_id", id),
+		slog.String("reason", reason),
+	)
+
+	return true
+}
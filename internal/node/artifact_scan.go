@@ -0,0 +1,207 @@
+package nexnode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/events"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const defaultArtifactScanTimeout = time.Minute
+
+// Runs the configured scanner against workload artifacts before they are deployed
+type artifactScanner struct {
+	config *models.ArtifactScanConfig
+	nc     *nats.Conn
+	log    *slog.Logger
+}
+
+func newArtifactScanner(config *models.ArtifactScanConfig, nc *nats.Conn, log *slog.Logger) *artifactScanner {
+	if config == nil {
+		return nil
+	}
+
+	return &artifactScanner{
+		config: config,
+		nc:     nc,
+		log:    log,
+	}
+}
+
+func (s *artifactScanner) threshold() string {
+	if s.config.Threshold == "" {
+		return controlapi.SeverityHigh
+	}
+	return strings.ToLower(s.config.Threshold)
+}
+
+func (s *artifactScanner) timeout() time.Duration {
+	if s.config.TimeoutMillis > 0 {
+		return time.Duration(s.config.TimeoutMillis) * time.Millisecond
+	}
+	return defaultArtifactScanTimeout
+}
+
+// Scans an artifact, returning the outcome to record and whether the deployment may proceed
+func (s *artifactScanner) scan(request *controlapi.ArtifactScanRequest, artifact []byte) (controlapi.ArtifactScannedEvent, bool) {
+	outcome := controlapi.ArtifactScannedEvent{
+		Namespace:    request.Namespace,
+		WorkloadName: request.WorkloadName,
+		Sha256:       request.Sha256,
+		Threshold:    s.threshold(),
+		Findings:     make([]controlapi.ArtifactFinding, 0),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout())
+	defer cancel()
+
+	var response *controlapi.ArtifactScanResponse
+	var err error
+	if len(s.config.Command) > 0 {
+		response, err = s.scanWithCommand(ctx, request, artifact)
+	} else {
+		response, err = s.scanWithService(ctx, request)
+	}
+	if err == nil && response.Error != "" {
+		err = fmt.Errorf("scanner failed: %s", response.Error)
+	}
+
+	if err != nil {
+		outcome.Error = err.Error()
+		outcome.Blocked = !s.config.FailOpen
+		return outcome, !outcome.Blocked
+	}
+
+	outcome.Scanner = response.Scanner
+	if response.Findings != nil {
+		outcome.Findings = response.Findings
+	}
+	outcome.Blocked = blockingFindings(outcome.Findings, outcome.Threshold) > 0
+
+	return outcome, !outcome.Blocked
+}
+
+// Writes the artifact to a private temporary file and runs the scanner command against it
+func (s *artifactScanner) scanWithCommand(ctx context.Context, request *controlapi.ArtifactScanRequest, artifact []byte) (*controlapi.ArtifactScanResponse, error) {
+	tmp, err := os.CreateTemp("", "nex-scan-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(artifact)
+	tmp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	scanRequest := *request
+	scanRequest.Path = tmp.Name()
+	input, _ := json.Marshal(scanRequest)
+
+	args := append(append([]string{}, s.config.Command[1:]...), tmp.Name())
+	cmd := exec.CommandContext(ctx, s.config.Command[0], args...)
+	cmd.Stdin = bytes.NewReader(input)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("scanner command failed: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var response controlapi.ArtifactScanResponse
+	err = json.Unmarshal(output, &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scanner output: %s", err)
+	}
+
+	return &response, nil
+}
+
+func (s *artifactScanner) scanWithService(ctx context.Context, request *controlapi.ArtifactScanRequest) (*controlapi.ArtifactScanResponse, error) {
+	raw, _ := json.Marshal(request)
+
+	msg, err := s.nc.RequestWithContext(ctx, s.config.Subject, raw)
+	if err != nil {
+		return nil, fmt.Errorf("scanner service request failed: %s", err)
+	}
+
+	var response controlapi.ArtifactScanResponse
+	err = json.Unmarshal(msg.Data, &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scanner response: %s", err)
+	}
+
+	return &response, nil
+}
+
+// Counts the findings at least as severe as the threshold. Findings of unknown severity are
+// treated as blocking, so a scanner using its own vocabulary cannot slip findings through
+func blockingFindings(findings []controlapi.ArtifactFinding, threshold string) int {
+	limit := controlapi.SeverityRank(threshold)
+
+	blocking := 0
+	for _, finding := range findings {
+		rank := controlapi.SeverityRank(finding.Severity)
+		if rank < 0 || rank >= limit {
+			blocking++
+		}
+	}
+
+	return blocking
+}
+
+// Scans a workload's artifact when a scanner is configured, recording the outcome in an event
+// and returning an error if the deployment must not proceed
+func (w *WorkloadManager) scanWorkload(namespace string, request *controlapi.DeployRequest, artifact []byte, hash string) error {
+	if w.scanner == nil {
+		return nil
+	}
+
+	scanRequest := &controlapi.ArtifactScanRequest{
+		Namespace:    namespace,
+		WorkloadName: request.DecodedClaims.Subject,
+		WorkloadType: request.WorkloadType,
+		Sha256:       hash,
+		Location:     request.Location.String(),
+	}
+	if request.JsDomain != nil {
+		scanRequest.JsDomain = *request.JsDomain
+	}
+
+	outcome, allowed := w.scanner.scan(scanRequest, artifact)
+
+	w.log.Info("Scanned workload artifact",
+		slog.String("namespace", namespace),
+		slog.String("workload_name", outcome.WorkloadName),
+		slog.String("sha256", hash),
+		slog.Int("findings", len(outcome.Findings)),
+		slog.Bool("blocked", outcome.Blocked),
+		slog.String("error", outcome.Error),
+	)
+
+	cloudevent := events.ArtifactScanned(w.publicKey, outcome)
+	_ = PublishCloudEvent(w.events(), namespace, cloudevent, w.log)
+
+	if allowed {
+		return nil
+	}
+
+	if outcome.Error != "" {
+		return fmt.Errorf("artifact scan failed: %s", outcome.Error)
+	}
+
+	return fmt.Errorf("artifact scan found %d finding(s) at or above %s severity", blockingFindings(outcome.Findings, outcome.Threshold), outcome.Threshold)
+}
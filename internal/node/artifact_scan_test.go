@@ -0,0 +1,54 @@
+package nexnode
+
+import (
+	"log/slog"
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestArtifactScanBlocksFindingsAtThreshold(t *testing.T) {
+	findings := []controlapi.ArtifactFinding{
+		{Id: "CVE-1", Severity: controlapi.SeverityLow},
+		{Id: "CVE-2", Severity: controlapi.SeverityHigh},
+		{Id: "CUSTOM-1", Severity: "catastrophic"},
+	}
+
+	if n := blockingFindings(findings, controlapi.SeverityHigh); n != 2 {
+		t.Fatalf("Expected the high and unknown severity findings to block, got %d", n)
+	}
+
+	if n := blockingFindings(findings, controlapi.SeverityCritical); n != 1 {
+		t.Fatalf("Expected only the unknown severity finding to block, got %d", n)
+	}
+}
+
+func TestArtifactScanCommand(t *testing.T) {
+	scanner := newArtifactScanner(&models.ArtifactScanConfig{
+		// reports a critical finding only when handed a copy of the artifact
+		Command: []string{"sh", "-c", `cat > /dev/null; if grep -q evil "$1"; then echo '{"scanner":"test","findings":[{"id":"X","severity":"critical"}]}'; else echo '{"scanner":"test","findings":[]}'; fi`, "scan"},
+	}, nil, slog.Default())
+
+	request := &controlapi.ArtifactScanRequest{WorkloadName: "echo", Sha256: "abc"}
+
+	outcome, allowed := scanner.scan(request, []byte("benign"))
+	if !allowed || outcome.Blocked || outcome.Scanner != "test" {
+		t.Fatalf("Expected a clean artifact to be allowed, got %+v", outcome)
+	}
+
+	outcome, allowed = scanner.scan(request, []byte("evil"))
+	if allowed || !outcome.Blocked || len(outcome.Findings) != 1 {
+		t.Fatalf("Expected a critical finding to block the deployment, got %+v", outcome)
+	}
+
+	failing := newArtifactScanner(&models.ArtifactScanConfig{Command: []string{"false"}}, nil, slog.Default())
+	if _, allowed := failing.scan(request, []byte("benign")); allowed {
+		t.Fatal("Expected a failing scanner to block deployments unless failing open")
+	}
+
+	failing.config.FailOpen = true
+	if outcome, allowed := failing.scan(request, []byte("benign")); !allowed || outcome.Error == "" {
+		t.Fatalf("Expected a failing scanner to allow deployments when failing open, got %+v", outcome)
+	}
+}
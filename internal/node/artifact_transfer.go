@@ -0,0 +1,252 @@
+package nexnode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/events"
+)
+
+// Artifact downloads report their progress each time another this many bytes have arrived
+const artifactProgressInterval = 8 * 1024 * 1024
+
+type transferProgress func(transferred int64, total int64)
+
+// Passes a download through to its destination, reporting progress as it goes
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	total    int64
+	reported int64
+	progress transferProgress
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	if p.progress != nil && p.written-p.reported >= artifactProgressInterval {
+		p.reported = p.written
+		p.progress(p.reported, p.total)
+	}
+	return n, err
+}
+
+func (p *progressWriter) done() {
+	if p.progress != nil && p.written > p.reported {
+		p.reported = p.written
+		p.progress(p.reported, p.total)
+	}
+}
+
+// A downloaded object, spooled to a temporary file rather than held in memory
+type artifactFile struct {
+	*os.File
+	size   int64
+	sha256 string
+}
+
+// Reads the whole artifact into memory, for the consumers that need it in one piece
+func (a *artifactFile) bytes() ([]byte, error) {
+	_, err := a.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(io.LimitReader(a.File, a.size))
+}
+
+func (a *artifactFile) remove() {
+	_ = a.Close()
+	_ = os.Remove(a.Name())
+}
+
+// Downloads an object store object, streaming it to a temporary file. An object carrying an
+// artifact manifest is reassembled from its chunks. Objects larger than maxBytes are refused
+// before any of their content is downloaded; zero allows any size. The caller removes the file
+func (m *WorkloadManager) fetchObject(location *url.URL, jsDomain *string, maxBytes int64, progress transferProgress) (*artifactFile, error) {
+	bucket := location.Host
+	key := strings.Trim(location.Path, "/")
+
+	m.log.Info("Attempting object store download", slog.String("bucket", bucket), slog.String("key", key))
+
+	opts := []nats.JSOpt{}
+	if jsDomain != nil {
+		opts = append(opts, nats.Domain(*jsDomain))
+		opts = append(opts, nats.APIPrefix(*jsDomain))
+	}
+
+	js, err := m.nc.JetStream(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := js.ObjectStore(bucket)
+	if err != nil {
+		m.log.Error("Failed to bind to source object store", slog.Any("err", err), slog.String("bucket", bucket))
+		return nil, err
+	}
+
+	info, err := store.GetInfo(key)
+	if err != nil {
+		m.log.Error("Failed to locate object in source object store", slog.Any("err", err), slog.String("key", key), slog.String("bucket", bucket))
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "nex-artifact-")
+	if err != nil {
+		return nil, err
+	}
+	artifact := &artifactFile{File: file}
+
+	if info.Metadata[controlapi.ArtifactManifestMetadata] != "" {
+		err = fetchChunkedArtifact(store, key, maxBytes, artifact, progress)
+	} else {
+		err = fetchWholeObject(store, key, int64(info.Size), maxBytes, artifact, progress)
+	}
+	if err != nil {
+		m.log.Error("Failed to download bytes from source object store", slog.Any("err", err), slog.String("key", key))
+		artifact.remove()
+		return nil, err
+	}
+
+	return artifact, nil
+}
+
+func fetchWholeObject(store nats.ObjectStore, key string, size int64, maxBytes int64, artifact *artifactFile, progress transferProgress) error {
+	if maxBytes > 0 && size > maxBytes {
+		return fmt.Errorf("object %s is %d bytes, exceeding the limit of %d bytes", key, size, maxBytes)
+	}
+
+	digest := sha256.New()
+	dest := &progressWriter{w: io.MultiWriter(artifact.File, digest), total: size, progress: progress}
+
+	err := readObject(store, key, size, dest)
+	if err != nil {
+		return err
+	}
+	dest.done()
+
+	artifact.size = size
+	artifact.sha256 = hex.EncodeToString(digest.Sum(nil))
+	return nil
+}
+
+// Reassembles an artifact from the chunks listed in its manifest, verifying each chunk and the
+// artifact as a whole against their digests as they are written
+func fetchChunkedArtifact(store nats.ObjectStore, key string, maxBytes int64, artifact *artifactFile, progress transferProgress) error {
+	raw, err := store.GetBytes(key)
+	if err != nil {
+		return fmt.Errorf("failed to download artifact manifest %s: %s", key, err)
+	}
+
+	var manifest controlapi.ArtifactManifest
+	err = json.Unmarshal(raw, &manifest)
+	if err != nil {
+		return fmt.Errorf("failed to decode artifact manifest %s: %s", key, err)
+	}
+
+	err = validateArtifactManifest(key, &manifest, maxBytes)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.New()
+	dest := &progressWriter{w: io.MultiWriter(artifact.File, digest), total: manifest.Size, progress: progress}
+
+	for _, chunk := range manifest.Chunks {
+		chunkDigest := sha256.New()
+
+		err = readObject(store, chunk.Key, chunk.Size, io.MultiWriter(dest, chunkDigest))
+		if err != nil {
+			return fmt.Errorf("failed to download artifact chunk %s: %s", chunk.Key, err)
+		}
+
+		if !strings.EqualFold(hex.EncodeToString(chunkDigest.Sum(nil)), chunk.Sha256) {
+			return fmt.Errorf("artifact chunk %s does not match its digest", chunk.Key)
+		}
+	}
+	dest.done()
+
+	artifact.size = manifest.Size
+	artifact.sha256 = hex.EncodeToString(digest.Sum(nil))
+	if !strings.EqualFold(artifact.sha256, manifest.Sha256) {
+		return fmt.Errorf("artifact %s does not match its digest", key)
+	}
+
+	return nil
+}
+
+// Checks that the manifest describes an artifact within the size limit whose chunks add up to it
+func validateArtifactManifest(key string, manifest *controlapi.ArtifactManifest, maxBytes int64) error {
+	if manifest.Size <= 0 {
+		return fmt.Errorf("artifact manifest %s has invalid size %d", key, manifest.Size)
+	}
+	if maxBytes > 0 && manifest.Size > maxBytes {
+		return fmt.Errorf("artifact %s is %d bytes, exceeding the limit of %d bytes", key, manifest.Size, maxBytes)
+	}
+
+	var size int64
+	for _, chunk := range manifest.Chunks {
+		if chunk.Size <= 0 {
+			return fmt.Errorf("artifact manifest %s lists chunk %s with invalid size %d", key, chunk.Key, chunk.Size)
+		}
+		size += chunk.Size
+		if size > manifest.Size {
+			break
+		}
+	}
+	if size != manifest.Size {
+		return fmt.Errorf("artifact manifest %s lists chunks that do not add up to an artifact of %d bytes", key, manifest.Size)
+	}
+
+	return nil
+}
+
+// Copies exactly size bytes of the object to w, failing if the object is shorter or longer
+func readObject(store nats.ObjectStore, key string, size int64, w io.Writer) error {
+	result, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = result.Close()
+	}()
+
+	n, err := io.Copy(w, io.LimitReader(result, size+1))
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("object %s holds more or fewer than the expected %d bytes", key, size)
+	}
+
+	return nil
+}
+
+func (m *WorkloadManager) publishArtifactTransfer(workloadID string, namespace string, location *url.URL, transferred int64, total int64) {
+	m.log.Debug("Downloading workload artifact",
+		slog.String("workload_id", workloadID),
+		slog.Int64("bytes_transferred", transferred),
+		slog.Int64("total_bytes", total),
+	)
+
+	evt := controlapi.ArtifactTransferEvent{
+		WorkloadId:       workloadID,
+		Namespace:        namespace,
+		Location:         location.String(),
+		BytesTransferred: transferred,
+		TotalBytes:       total,
+	}
+
+	cloudevent := events.ArtifactTransfer(m.publicKey, evt)
+	_ = PublishCloudEvent(m.events(), namespace, cloudevent, m.log)
+}
@@ -0,0 +1,53 @@
+package nexnode
+
+import (
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+func TestArtifactManifestRejectsNonPositiveSizes(t *testing.T) {
+	manifests := map[string]controlapi.ArtifactManifest{
+		"negative size balanced by negative chunks": {
+			Size:   -10,
+			Chunks: []controlapi.ArtifactChunk{{Key: "a", Size: -4}, {Key: "b", Size: -6}},
+		},
+		"empty artifact": {
+			Size: 0,
+		},
+		"negative chunk": {
+			Size:   10,
+			Chunks: []controlapi.ArtifactChunk{{Key: "a", Size: 20}, {Key: "b", Size: -10}},
+		},
+	}
+
+	for name, manifest := range manifests {
+		err := validateArtifactManifest("artifact", &manifest, 0)
+		if err == nil {
+			t.Fatalf("Expected the manifest with %s to be rejected", name)
+		}
+	}
+}
+
+func TestArtifactManifestMustAddUpWithinLimit(t *testing.T) {
+	manifest := controlapi.ArtifactManifest{
+		Size:   10,
+		Chunks: []controlapi.ArtifactChunk{{Key: "a", Size: 4}, {Key: "b", Size: 6}},
+	}
+
+	err := validateArtifactManifest("artifact", &manifest, 0)
+	if err != nil {
+		t.Fatalf("Expected a consistent manifest to be accepted, got %s", err)
+	}
+
+	err = validateArtifactManifest("artifact", &manifest, 8)
+	if err == nil {
+		t.Fatal("Expected an artifact over the size limit to be rejected")
+	}
+
+	manifest.Chunks = manifest.Chunks[:1]
+	err = validateArtifactManifest("artifact", &manifest, 0)
+	if err == nil {
+		t.Fatal("Expected chunks that do not add up to the artifact size to be rejected")
+	}
+}
@@ -0,0 +1,373 @@
+package nexnode
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const (
+	artifactFetchTimeout = 10 * time.Minute
+
+	ociManifestMediaTypes = "application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// Fetches pinned kernel and rootfs artifacts into a local cache, verifying each against its
+// checksum. Cached artifacts are keyed by checksum, so a pinned version is only fetched once
+type artifactManager struct {
+	cacheDir string
+	log      *slog.Logger
+
+	// lazily established when an artifact is located in a NATS object store
+	nc      *nats.Conn
+	connect func() (*nats.Conn, error)
+}
+
+func newArtifactManager(config *models.NodeConfiguration, log *slog.Logger, connect func() (*nats.Conn, error)) *artifactManager {
+	cacheDir := ""
+	if config.Artifacts != nil {
+		cacheDir = config.Artifacts.CacheDir
+	}
+	if cacheDir == "" {
+		if config.DefaultResourceDir != "" {
+			cacheDir = filepath.Join(config.DefaultResourceDir, "artifacts")
+		} else {
+			cacheDir = filepath.Join(os.TempDir(), "nex-artifacts")
+		}
+	}
+
+	return &artifactManager{
+		cacheDir: cacheDir,
+		connect:  connect,
+		log:      log,
+	}
+}
+
+// Fetches every configured artifact and points the node configuration at the cached copies
+func (a *artifactManager) resolve(config *models.NodeConfiguration) error {
+	defer func() {
+		if a.nc != nil {
+			a.nc.Close()
+		}
+	}()
+
+	err := os.MkdirAll(a.cacheDir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create artifact cache directory: %s", err)
+	}
+
+	if config.Artifacts.Kernel != nil {
+		config.KernelFilepath, err = a.fetch(*config.Artifacts.Kernel)
+		if err != nil {
+			return fmt.Errorf("failed to fetch kernel: %s", err)
+		}
+	}
+
+	rootfs := config.Artifacts.RootFs
+	if len(config.WorkloadTypes) == 1 {
+		if variant, ok := config.Artifacts.RootFsVariants[config.WorkloadTypes[0]]; ok {
+			rootfs = &variant
+		}
+	}
+
+	if rootfs != nil {
+		config.RootFsFilepath, err = a.fetch(*rootfs)
+		if err != nil {
+			return fmt.Errorf("failed to fetch rootfs: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// Returns the path to a verified local copy of the given artifact, fetching it if necessary
+func (a *artifactManager) fetch(spec models.ArtifactSpec) (string, error) {
+	checksum := strings.ToLower(spec.Sha256)
+	path := filepath.Join(a.cacheDir, checksum)
+
+	if existing, err := fileSha256(path); err == nil {
+		if existing == checksum {
+			a.log.Debug("Using cached artifact", slog.String("location", spec.Location), slog.String("version", spec.Version), slog.String("path", path))
+			return path, nil
+		}
+
+		a.log.Warn("Cached artifact failed verification; fetching again", slog.String("path", path))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), artifactFetchTimeout)
+	defer cancel()
+
+	var body io.ReadCloser
+	var err error
+
+	switch {
+	case strings.HasPrefix(spec.Location, "oci://"):
+		body, err = a.openOCI(ctx, strings.TrimPrefix(spec.Location, "oci://"))
+	case strings.HasPrefix(spec.Location, "nats://"):
+		body, err = a.openObject(strings.TrimPrefix(spec.Location, "nats://"))
+	default:
+		err = fmt.Errorf("unsupported artifact location: %s", spec.Location)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(a.cacheDir, ".fetch-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), body)
+	tmp.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %s", spec.Location, err)
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if actual != checksum {
+		return "", fmt.Errorf("checksum mismatch for %s; expected %s, got %s", spec.Location, checksum, actual)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return "", err
+	}
+
+	a.log.Info("Fetched and verified artifact",
+		slog.String("location", spec.Location),
+		slog.String("version", spec.Version),
+		slog.String("path", path),
+	)
+
+	return path, nil
+}
+
+// Opens the single layer of an OCI artifact, decompressing it if the layer is gzipped. The
+// reference takes the form <registry>/<repository>(:<tag>|@<digest>)
+func (a *artifactManager) openOCI(ctx context.Context, reference string) (io.ReadCloser, error) {
+	registry, repository, ok := strings.Cut(reference, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid OCI reference: %s", reference)
+	}
+
+	ref := "latest"
+	if idx := strings.LastIndex(repository, "@"); idx != -1 {
+		repository, ref = repository[:idx], repository[idx+1:]
+	} else if idx := strings.LastIndex(repository, ":"); idx != -1 {
+		repository, ref = repository[:idx], repository[idx+1:]
+	}
+
+	client := &ociClient{registry: registry, repository: repository}
+
+	resp, err := client.get(ctx, fmt.Sprintf("manifests/%s", ref), ociManifestMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var manifest struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+		} `json:"layers"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OCI manifest: %s", err)
+	}
+
+	if len(manifest.Layers) != 1 {
+		return nil, fmt.Errorf("expected OCI artifact %s to contain a single layer, found %d", reference, len(manifest.Layers))
+	}
+	layer := manifest.Layers[0]
+
+	blob, err := client.get(ctx, fmt.Sprintf("blobs/%s", layer.Digest), "")
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(layer.MediaType, "gzip") {
+		gz, err := gzip.NewReader(blob.Body)
+		if err != nil {
+			blob.Body.Close()
+			return nil, err
+		}
+
+		return struct {
+			io.Reader
+			io.Closer
+		}{gz, blob.Body}, nil
+	}
+
+	return blob.Body, nil
+}
+
+// Opens an object from a NATS object store; the reference takes the form <bucket>/<object>
+func (a *artifactManager) openObject(reference string) (io.ReadCloser, error) {
+	bucket, object, ok := strings.Cut(reference, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid object store reference: %s", reference)
+	}
+
+	if a.nc == nil {
+		var err error
+		a.nc, err = a.connect()
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to NATS to fetch artifact: %s", err)
+		}
+	}
+
+	js, err := a.nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := js.ObjectStore(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind to object store %s: %s", bucket, err)
+	}
+
+	return store.Get(object)
+}
+
+// Minimal client for the OCI distribution API, supporting anonymous bearer token challenges
+type ociClient struct {
+	registry   string
+	repository string
+	token      string
+}
+
+func (c *ociClient) get(ctx context.Context, path string, accept string) (*http.Response, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://%s/v2/%s/%s", c.registry, c.repository, path), nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && c.token == "" {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+
+			err = c.authenticate(ctx, challenge)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("registry %s responded to %s with %s", c.registry, path, resp.Status)
+		}
+
+		return resp, nil
+	}
+
+	return nil, fmt.Errorf("registry %s rejected credentials for %s", c.registry, c.repository)
+}
+
+// Requests an anonymous pull token from the realm named in a bearer challenge
+func (c *ociClient) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, ok := strings.Cut(challenge, " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return fmt.Errorf("unsupported registry authentication challenge: %q", challenge)
+	}
+
+	values := make(map[string]string)
+	for _, param := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok {
+			values[key] = strings.Trim(value, `"`)
+		}
+	}
+
+	realm, ok := values["realm"]
+	if !ok {
+		return errors.New("registry authentication challenge did not include a realm")
+	}
+
+	query := url.Values{}
+	if service, ok := values["service"]; ok {
+		query.Set("service", service)
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", c.repository))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?%s", realm, query.Encode()), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to obtain registry token: %s", resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return err
+	}
+
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+	if c.token == "" {
+		return errors.New("registry token response did not include a token")
+	}
+
+	return nil
+}
+
+func fileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
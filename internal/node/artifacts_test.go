@@ -0,0 +1,52 @@
+package nexnode
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestArtifactManagerVerifiesCachedArtifacts(t *testing.T) {
+	cacheDir := t.TempDir()
+	config := &models.NodeConfiguration{
+		Artifacts: &models.ArtifactsConfig{CacheDir: cacheDir},
+	}
+
+	connected := false
+	artifacts := newArtifactManager(config, slog.Default(), func() (*nats.Conn, error) {
+		connected = true
+		return nil, errors.New("no NATS server available")
+	})
+
+	// sha256 of "rootfs"
+	spec := models.ArtifactSpec{
+		Location: "nats://artifacts/rootfs",
+		Sha256:   "3c47ef972d531d524daa15fa33dd885dd23de6221bbd10a29eb42ecfcf2ef422",
+	}
+	cached := filepath.Join(cacheDir, spec.Sha256)
+
+	err := os.WriteFile(cached, []byte("rootfs"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := artifacts.fetch(spec)
+	if err != nil || path != cached || connected {
+		t.Fatalf("Expected verified cached artifact to be used without fetching: %s", err)
+	}
+
+	err = os.WriteFile(cached, []byte("tampered"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = artifacts.fetch(spec)
+	if err == nil || !connected {
+		t.Fatal("Expected a cached artifact failing verification to be fetched again")
+	}
+}
@@ -0,0 +1,192 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+const defaultAuditStream = "NEX_AUDIT"
+
+// A control API request being handled. Handlers receive the request in place of its bare message
+// so that the issuer it is attributed to and its outcome, recorded by the authorize and respond
+// helpers as they are determined, land on the request's own audit record
+type apiRequest struct {
+	*nats.Msg
+	audit *requestAudit
+}
+
+// The audit record of a request, published once every part of handling it has finished
+type requestAudit struct {
+	mu      sync.Mutex
+	pending int
+	record  controlapi.AuditRecord
+
+	api *ApiListener
+}
+
+// Wraps a control API handler so that every request it handles is audited
+func (api *ApiListener) audited(handler func(m *apiRequest)) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		m := &apiRequest{
+			Msg: msg,
+			audit: &requestAudit{
+				pending: 1,
+				api:     api,
+				record: controlapi.AuditRecord{
+					NodeId:     api.PublicKey(),
+					Request:    auditRequestName(msg.Subject),
+					Subject:    msg.Subject,
+					Outcome:    controlapi.AuditOutcomeSucceeded,
+					ReceivedAt: time.Now().UTC(),
+				},
+			},
+		}
+		defer m.audit.release()
+
+		handler(m)
+	}
+}
+
+// Keeps the audit record open for work that continues after the request's handler returns, such
+// as a deployment submitted to an agent. Each hold must be released with release
+func (a *requestAudit) hold() {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	a.pending++
+	a.mu.Unlock()
+}
+
+// Publishes the audit record once the request's handler and every hold have been released
+func (a *requestAudit) release() {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	a.pending--
+	done := a.pending == 0
+	record := a.record
+	a.mu.Unlock()
+
+	if !done {
+		return
+	}
+
+	record.LatencyMillis = time.Since(record.ReceivedAt).Milliseconds()
+	a.api.publishAuditRecord(record)
+}
+
+// Records the issuer, namespace and operation the request was attributed to
+func (a *requestAudit) attribute(issuer string, namespace string, op controlapi.Operation) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	a.record.Issuer = issuer
+	a.record.Namespace = namespace
+	a.record.Operation = op
+	a.mu.Unlock()
+}
+
+// Records the outcome of the request. The first unsuccessful outcome is kept
+func (a *requestAudit) recordOutcome(outcome controlapi.AuditOutcome, reason string) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	if a.record.Outcome == controlapi.AuditOutcomeSucceeded {
+		a.record.Outcome = outcome
+		a.record.Error = reason
+	}
+	a.mu.Unlock()
+}
+
+// Publishes the record on $NEX.audit.{namespace}.{node_id}.{request}
+func (api *ApiListener) publishAuditRecord(record controlapi.AuditRecord) {
+	if record.Namespace == "" {
+		record.Namespace = systemNamespace
+	}
+
+	attrs := []any{
+		slog.String("request", record.Request),
+		slog.String("namespace", record.Namespace),
+		slog.String("issuer", record.Issuer),
+		slog.String("outcome", string(record.Outcome)),
+		slog.Int64("latency_ms", record.LatencyMillis),
+	}
+	if record.Error != "" {
+		attrs = append(attrs, slog.String("error", record.Error))
+	}
+
+	// requests that change workloads are what operators look for; reads are only logged when debugging
+	if record.Operation == controlapi.OperationDeploy || record.Operation == controlapi.OperationStop {
+		api.log.Info("Audited control API request", attrs...)
+	} else {
+		api.log.Debug("Audited control API request", attrs...)
+	}
+
+	raw, err := json.Marshal(record)
+	if err != nil {
+		api.log.Error("Failed to serialize audit record", slog.Any("err", err))
+		return
+	}
+
+	subject := fmt.Sprintf("%s.%s.%s.%s", AuditSubjectPrefix, record.Namespace, record.NodeId, record.Request)
+	err = api.node.events().Publish(subject, raw)
+	if err != nil {
+		api.log.Warn("Failed to publish audit record", slog.String("subject", subject), slog.Any("err", err))
+	}
+}
+
+// Binds the audit stream, creating it if needed, so that published audit records are persisted
+func (api *ApiListener) ensureAuditStream() error {
+	config := api.node.config.Audit
+
+	js, err := api.node.nc.JetStream()
+	if err != nil {
+		return err
+	}
+
+	stream := config.Stream
+	if stream == "" {
+		stream = defaultAuditStream
+	}
+
+	_, err = js.StreamInfo(stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:        stream,
+			Description: "Nex control API audit records",
+			Subjects:    []string{AuditSubjectPrefix + ".>"},
+			MaxAge:      time.Duration(config.MaxAgeMillisecond) * time.Millisecond,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to bind to audit stream %s: %s", stream, err)
+	}
+
+	return nil
+}
+
+// Names the request by the operation token of its subject, e.g. "stop" for $NEX.STOP.{namespace}.{node}
+func auditRequestName(subject string) string {
+	tokens := strings.Split(subject, ".")
+	if len(tokens) < 2 {
+		return subject
+	}
+
+	return strings.ToLower(tokens[1])
+}
@@ -0,0 +1,74 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+func TestAuditKeepsFirstUnsuccessfulOutcome(t *testing.T) {
+	m := &apiRequest{
+		Msg:   &nats.Msg{Subject: "$NEX.STOP.default.NODE"},
+		audit: &requestAudit{pending: 1, record: controlapi.AuditRecord{Outcome: controlapi.AuditOutcomeSucceeded}},
+	}
+
+	m.audit.attribute("ALICE", "default", controlapi.OperationStop)
+	m.audit.recordOutcome(controlapi.AuditOutcomeDenied, "issuer is not allowed to stop in namespace default")
+	m.audit.recordOutcome(controlapi.AuditOutcomeFailed, "No such workload")
+
+	if m.audit.record.Issuer != "ALICE" || m.audit.record.Operation != controlapi.OperationStop {
+		t.Fatalf("Expected the request to be attributed to ALICE stopping, got %+v", m.audit.record)
+	}
+	if m.audit.record.Outcome != controlapi.AuditOutcomeDenied {
+		t.Fatalf("Expected the denial to be kept, got %s", m.audit.record.Outcome)
+	}
+}
+
+func TestAuditPublishesOnceEveryHoldIsReleased(t *testing.T) {
+	nc := startTestNats(t)
+
+	records, err := nc.SubscribeSync(AuditSubjectPrefix + ".>")
+	if err != nil {
+		t.Fatalf("Failed to subscribe to audit records: %s", err)
+	}
+
+	api := &ApiListener{node: &Node{nc: nc}, log: slog.Default()}
+	release := make(chan struct{})
+
+	handler := api.audited(func(m *apiRequest) {
+		m.audit.hold()
+		go func() {
+			defer m.audit.release()
+			<-release
+			respondFail(controlapi.RunResponseType, m, "agent failed")
+		}()
+	})
+	handler(&nats.Msg{Subject: "$NEX.DEPLOY.default.NODE"})
+
+	_, err = records.NextMsg(100 * time.Millisecond)
+	if err == nil {
+		t.Fatal("Expected the audit record to stay open while the deployment is held")
+	}
+
+	close(release)
+	msg, err := records.NextMsg(time.Second)
+	if err != nil {
+		t.Fatalf("Expected the audit record once the hold was released: %s", err)
+	}
+
+	var record controlapi.AuditRecord
+	_ = json.Unmarshal(msg.Data, &record)
+	if record.Request != "deploy" || record.Outcome != controlapi.AuditOutcomeFailed || record.Error != "agent failed" {
+		t.Fatalf("Expected a failed deploy to be audited, got %+v", record)
+	}
+}
+
+func TestAuditRequestName(t *testing.T) {
+	if name := auditRequestName("$NEX.STOP.default.NODE"); name != "stop" {
+		t.Fatalf("Expected stop, got %s", name)
+	}
+}
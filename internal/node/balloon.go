@@ -0,0 +1,208 @@
+package nexnode
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/synadia-io/nex/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const defaultBalloonInterval = 10 * time.Second
+
+// Balloon of a deployed workload whose type has a reclaim policy. The mutex is held while the
+// balloon is resized, so that an inflation and a deflation never race each other
+type balloonState struct {
+	mutex        sync.Mutex
+	workloadType string
+	policy       models.BalloonPolicy
+	lastActive   time.Time
+	inflatedMib  int64
+}
+
+// Tracks the balloons of deployed workloads. A nil reclaimer disables memory reclaim
+type balloonReclaimer struct {
+	mutex      sync.Mutex
+	config     *models.BalloonConfig
+	memSizeMib int64
+	balloons   map[string]*balloonState
+}
+
+func newBalloonReclaimer(config *models.NodeConfiguration) *balloonReclaimer {
+	if config.Balloon == nil || config.MachineTemplate.MemSizeMib == nil {
+		return nil
+	}
+
+	return &balloonReclaimer{
+		config:     config.Balloon,
+		memSizeMib: int64(*config.MachineTemplate.MemSizeMib),
+		balloons:   make(map[string]*balloonState),
+	}
+}
+
+// Starts tracking a deployed workload, provided its type has a reclaim policy
+func (b *balloonReclaimer) track(workloadID string, workloadType string, now time.Time) {
+	if b == nil {
+		return
+	}
+
+	policy, ok := b.config.Policies[workloadType]
+	if !ok {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.balloons[workloadID] = &balloonState{
+		workloadType: workloadType,
+		policy:       policy,
+		lastActive:   now,
+	}
+}
+
+func (b *balloonReclaimer) get(workloadID string) *balloonState {
+	if b == nil {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.balloons[workloadID]
+}
+
+func (b *balloonReclaimer) all() map[string]*balloonState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	balloons := make(map[string]*balloonState, len(b.balloons))
+	for id, state := range b.balloons {
+		balloons[id] = state
+	}
+	return balloons
+}
+
+func (b *balloonReclaimer) remove(workloadID string) *balloonState {
+	if b == nil {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	state := b.balloons[workloadID]
+	delete(b.balloons, workloadID)
+	return state
+}
+
+// Returns the amount of memory to reclaim from an idle workload, or 0 if it is not yet idle or
+// its balloon is already inflated. The caller must hold the state's mutex
+func (b *balloonReclaimer) reclaimable(state *balloonState, now time.Time) int64 {
+	if state.inflatedMib > 0 || now.Sub(state.lastActive) < time.Duration(state.policy.IdleMillis)*time.Millisecond {
+		return 0
+	}
+
+	return b.memSizeMib * int64(state.policy.ReclaimPercent) / 100
+}
+
+// Periodically inflates the balloons of idle workloads until the workload manager stops
+func (w *WorkloadManager) runBalloonReclaimer() {
+	if w.balloons == nil {
+		return
+	}
+
+	interval := time.Duration(w.balloons.config.IntervalMillis) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultBalloonInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			if atomic.LoadUint32(&w.closing) > 0 {
+				return
+			}
+
+			w.inflateIdleBalloons(time.Now())
+		}
+	}
+}
+
+func (w *WorkloadManager) inflateIdleBalloons(now time.Time) {
+	for id, state := range w.balloons.all() {
+		state.mutex.Lock()
+
+		amount := w.balloons.reclaimable(state, now)
+		if amount > 0 {
+			err := w.procMan.ResizeBalloon(id, amount)
+			if err != nil {
+				w.log.Warn("Failed to inflate balloon of idle workload", slog.String("workload_id", id), slog.Any("err", err))
+			} else {
+				state.inflatedMib = amount
+
+				attrs := metric.WithAttributes(attribute.String("workload_type", state.workloadType))
+				w.t.BalloonReclaimedMib.Add(w.ctx, amount, attrs)
+				w.t.BalloonInflations.Add(w.ctx, 1, attrs)
+
+				w.log.Debug("Reclaimed memory from idle workload",
+					slog.String("workload_id", id),
+					slog.Int64("reclaimed_mib", amount),
+				)
+			}
+		}
+
+		state.mutex.Unlock()
+	}
+}
+
+// Records trigger activity for a workload, deflating its balloon before the trigger executes
+func (w *WorkloadManager) wakeBalloon(workloadID string) {
+	state := w.balloons.get(workloadID)
+	if state == nil {
+		return
+	}
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	state.lastActive = time.Now()
+	if state.inflatedMib == 0 {
+		return
+	}
+
+	err := w.procMan.ResizeBalloon(workloadID, 0)
+	if err != nil {
+		w.log.Warn("Failed to deflate balloon of triggered workload", slog.String("workload_id", workloadID), slog.Any("err", err))
+		return
+	}
+
+	attrs := metric.WithAttributes(attribute.String("workload_type", state.workloadType))
+	w.t.BalloonReclaimedMib.Add(w.ctx, -state.inflatedMib, attrs)
+	w.t.BalloonDeflations.Add(w.ctx, 1, attrs)
+	state.inflatedMib = 0
+}
+
+// Stops tracking a stopped workload, releasing any memory it had reclaimed from the metrics
+func (w *WorkloadManager) releaseBalloon(workloadID string) {
+	state := w.balloons.remove(workloadID)
+	if state == nil {
+		return
+	}
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+
+	if state.inflatedMib > 0 {
+		w.t.BalloonReclaimedMib.Add(w.ctx, -state.inflatedMib, metric.WithAttributes(attribute.String("workload_type", state.workloadType)))
+		state.inflatedMib = 0
+	}
+}
@@ -0,0 +1,42 @@
+package nexnode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestBalloonReclaimsOnlyIdleWorkloadsWithPolicy(t *testing.T) {
+	memSize := 256
+	reclaimer := newBalloonReclaimer(&models.NodeConfiguration{
+		MachineTemplate: models.MachineTemplate{MemSizeMib: &memSize},
+		Balloon: &models.BalloonConfig{
+			Policies: map[string]models.BalloonPolicy{
+				"v8": {IdleMillis: 1000, ReclaimPercent: 50},
+			},
+		},
+	})
+
+	now := time.Now()
+	reclaimer.track("fn", "v8", now)
+	reclaimer.track("svc", "native", now)
+
+	if reclaimer.get("svc") != nil {
+		t.Fatal("Expected workloads without a policy not to be tracked")
+	}
+
+	state := reclaimer.get("fn")
+	if amount := reclaimer.reclaimable(state, now.Add(500*time.Millisecond)); amount != 0 {
+		t.Fatalf("Expected nothing reclaimable before the idle period, got %d", amount)
+	}
+
+	if amount := reclaimer.reclaimable(state, now.Add(time.Second)); amount != 128 {
+		t.Fatalf("Expected half of the VM's memory to be reclaimable, got %d", amount)
+	}
+
+	state.inflatedMib = 128
+	if amount := reclaimer.reclaimable(state, now.Add(time.Hour)); amount != 0 {
+		t.Fatalf("Expected an inflated balloon not to be inflated again, got %d", amount)
+	}
+}
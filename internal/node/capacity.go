@@ -0,0 +1,93 @@
+package nexnode
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+// Computes the node's current workload capacity. Every deployed workload, and every deployment
+// still in progress, is charged the memory and vCPU count of the machine template, regardless
+// of sandboxing
+func (w *WorkloadManager) Capacity() *controlapi.NodeCapacity {
+	hostMemoryMib := 0
+	if stats, err := ReadMemoryStats(); err == nil {
+		hostMemoryMib = stats.MemTotal / 1024
+	}
+
+	memSizeMib, vcpuCount := w.workloadFootprint()
+
+	w.poolMutex.Lock()
+	allocated := len(w.activeAgents) + w.states.claimedCount()
+	w.poolMutex.Unlock()
+
+	capacity := computeCapacity(hostMemoryMib, runtime.NumCPU(), w.config.Resources, memSizeMib*allocated, vcpuCount*allocated)
+	capacity.GPUCount, capacity.AvailableGPUCount = w.gpus.counts()
+
+	return capacity
+}
+
+// Returns an error if deploying another workload would exceed the node's capacity
+func (w *WorkloadManager) EnsureCapacity() error {
+	memSizeMib, vcpuCount := w.workloadFootprint()
+	capacity := w.Capacity()
+
+	if capacity.MemoryMib > 0 && capacity.AvailableMemoryMib < memSizeMib {
+		return fmt.Errorf("insufficient memory capacity; %d MiB required, %d MiB available", memSizeMib, capacity.AvailableMemoryMib)
+	}
+
+	if capacity.VcpuCount > 0 && capacity.AvailableVcpuCount < vcpuCount {
+		return fmt.Errorf("insufficient vcpu capacity; %d required, %d available", vcpuCount, capacity.AvailableVcpuCount)
+	}
+
+	return nil
+}
+
+func (w *WorkloadManager) workloadFootprint() (int, int) {
+	memSizeMib := models.DefaultNodeMemSizeMib
+	if w.config.MachineTemplate.MemSizeMib != nil {
+		memSizeMib = *w.config.MachineTemplate.MemSizeMib
+	}
+
+	vcpuCount := models.DefaultNodeVcpuCount
+	if w.config.MachineTemplate.VcpuCount != nil {
+		vcpuCount = *w.config.MachineTemplate.VcpuCount
+	}
+
+	return memSizeMib, vcpuCount
+}
+
+func computeCapacity(hostMemoryMib, hostVcpuCount int, resources *models.ResourceConfig, allocatedMemoryMib, allocatedVcpuCount int) *controlapi.NodeCapacity {
+	ratio := models.DefaultOvercommitRatio
+	reservedMemoryMib := 0
+	reservedVcpuCount := 0
+
+	if resources != nil {
+		reservedMemoryMib = resources.ReservedMemoryMib
+		reservedVcpuCount = resources.ReservedVcpuCount
+		if resources.OvercommitRatio > 0 {
+			ratio = resources.OvercommitRatio
+		}
+	}
+
+	capacity := &controlapi.NodeCapacity{
+		AllocatedMemoryMib: allocatedMemoryMib,
+		AllocatedVcpuCount: allocatedVcpuCount,
+		OvercommitRatio:    ratio,
+	}
+
+	if hostMemoryMib > 0 {
+		capacity.MemoryMib = int(math.Floor(float64(max(hostMemoryMib-reservedMemoryMib, 0)) * ratio))
+		capacity.AvailableMemoryMib = max(capacity.MemoryMib-allocatedMemoryMib, 0)
+	}
+
+	if hostVcpuCount > 0 {
+		capacity.VcpuCount = int(math.Floor(float64(max(hostVcpuCount-reservedVcpuCount, 0)) * ratio))
+		capacity.AvailableVcpuCount = max(capacity.VcpuCount-allocatedVcpuCount, 0)
+	}
+
+	return capacity
+}
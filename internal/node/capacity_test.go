@@ -0,0 +1,31 @@
+package nexnode
+
+import (
+	"testing"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestComputeCapacityAppliesReservationAndOvercommit(t *testing.T) {
+	resources := &models.ResourceConfig{
+		ReservedMemoryMib: 1024,
+		ReservedVcpuCount: 2,
+		OvercommitRatio:   1.5,
+	}
+
+	capacity := computeCapacity(4096, 8, resources, 1024, 3)
+	if capacity.MemoryMib != 4608 || capacity.VcpuCount != 9 {
+		t.Fatalf("Unexpected capacity totals: %+v", capacity)
+	}
+	if capacity.AvailableMemoryMib != 3584 || capacity.AvailableVcpuCount != 6 {
+		t.Fatalf("Unexpected available capacity: %+v", capacity)
+	}
+
+	capacity = computeCapacity(4096, 8, nil, 8192, 16)
+	if capacity.OvercommitRatio != models.DefaultOvercommitRatio {
+		t.Fatalf("Expected default overcommit ratio, got %f", capacity.OvercommitRatio)
+	}
+	if capacity.AvailableMemoryMib != 0 || capacity.AvailableVcpuCount != 0 {
+		t.Fatalf("Available capacity should not go negative: %+v", capacity)
+	}
+}
@@ -0,0 +1,666 @@
+package nexnode
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats.go"
+	"github.com/rs/xid"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/events"
+	"github.com/synadia-io/nex/internal/node/processmanager"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	defaultCheckpointRetain = 3
+
+	// Time allowed for the agent of a restored machine to answer again
+	checkpointResumeTimeout = 30 * time.Second
+
+	checkpointManifestFile = "manifest.json"
+)
+
+// Object metadata keys describing a stored checkpoint
+const (
+	checkpointMetaNamespace    = "namespace"
+	checkpointMetaWorkloadID   = "workload_id"
+	checkpointMetaWorkloadName = "workload_name"
+	checkpointMetaWorkloadType = "workload_type"
+	checkpointMetaNodeID       = "node_id"
+	checkpointMetaCreatedAt    = "created_at"
+	checkpointMetaStopped      = "stopped"
+)
+
+// Everything besides the machine snapshot needed to bring a checkpointed workload back: its
+// deploy request, the credentials its agent connects to the internal NATS server with, and the
+// agent session the node had established with it
+type checkpointManifest struct {
+	Machine  processmanager.MachineCheckpoint `json:"machine"`
+	Workload *agentapi.DeployRequest          `json:"workload"`
+
+	// The request the workload was deployed with, whose environment is kept in Environment
+	// rather than sealed for the node that took the checkpoint
+	Source      *controlapi.DeployRequest `json:"source"`
+	Environment map[string]string         `json:"environment,omitempty"`
+
+	NkeySeed string                 `json:"nkey_seed"`
+	Agent    *agentapi.AgentSession `json:"agent"`
+
+	// The restored agent keeps talking to the internal NATS server at the port it was given
+	InternalNodePort int    `json:"internal_node_port"`
+	Arch             string `json:"arch"`
+}
+
+// Snapshots a running service workload's machine and stores it in the checkpoint bucket. The
+// machine is paused only while it is snapshotted, unless stop is set, in which case the workload
+// is stopped once its checkpoint is stored
+func (n *Node) CheckpointWorkload(namespace string, workloadID string, stop bool) (*controlapi.CheckpointInfo, error) {
+	n.checkpointMutex.Lock()
+	defer n.checkpointMutex.Unlock()
+
+	w := n.manager
+
+	request, err := w.LookupWorkload(workloadID)
+	if err != nil || request == nil || request.Namespace == nil || *request.Namespace != namespace {
+		return nil, fmt.Errorf("no such workload: %s", workloadID)
+	}
+
+	if !request.SupportsEssential() {
+		return nil, fmt.Errorf("%s workloads cannot be checkpointed", request.WorkloadType)
+	}
+	if request.Volume != nil {
+		return nil, errors.New("workloads with a volume cannot be checkpointed")
+	}
+	if w.isPaused(workloadID) {
+		return nil, errors.New("paused workloads cannot be checkpointed")
+	}
+
+	w.poolMutex.Lock()
+	agentClient, ok := w.activeAgents[workloadID]
+	w.poolMutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no such workload: %s", workloadID)
+	}
+
+	manifest, err := n.checkpointManifest(workloadID, request, agentClient)
+	if err != nil {
+		return nil, err
+	}
+
+	store, bucket, err := n.checkpointStore()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "nex-checkpoint-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	resume := func() {
+		err := w.procMan.ResumeProcess(workloadID)
+		if err != nil {
+			n.log.Warn("Failed to resume checkpointed workload", slog.String("workload_id", workloadID), slog.Any("err", err))
+		}
+		agentClient.SetPaused(false)
+	}
+
+	// the agent cannot answer health checks while its machine is paused
+	agentClient.SetPaused(true)
+	machine, err := w.procMan.CheckpointProcess(workloadID, dir)
+	if err != nil {
+		resume()
+		return nil, fmt.Errorf("failed to checkpoint workload: %s", err)
+	}
+	manifest.Machine = *machine
+
+	if !stop {
+		resume()
+	}
+
+	info := controlapi.CheckpointInfo{
+		Id:           xid.New().String(),
+		WorkloadId:   workloadID,
+		Name:         *request.WorkloadName,
+		Namespace:    namespace,
+		WorkloadType: request.WorkloadType,
+		NodeId:       n.publicKey,
+		Bucket:       bucket,
+		CreatedAt:    time.Now().UTC(),
+		Stopped:      stop,
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(writeCheckpointArchive(pw, dir, manifest))
+	}()
+
+	obj, err := store.Put(&nats.ObjectMeta{
+		Name:        info.Id,
+		Description: fmt.Sprintf("Checkpoint of workload %s", workloadID),
+		Metadata:    checkpointMetadata(info),
+	}, pr)
+	_ = pr.Close()
+	if err != nil {
+		if stop {
+			resume()
+		}
+		return nil, fmt.Errorf("failed to store checkpoint: %s", err)
+	}
+	info.SizeBytes = obj.Size
+
+	n.log.Info("Checkpointed workload",
+		slog.String("workload_id", workloadID),
+		slog.String("checkpoint_id", info.Id),
+		slog.Uint64("size_bytes", info.SizeBytes),
+		slog.Bool("stopped", stop),
+	)
+
+	if stop {
+		err = w.StopWorkload(workloadID, false)
+		if err != nil {
+			n.log.Warn("Failed to stop checkpointed workload", slog.String("workload_id", workloadID), slog.Any("err", err))
+		}
+	}
+
+	n.pruneCheckpoints(store, bucket, namespace, workloadID)
+
+	_ = PublishCloudEvent(n.events(), namespace, events.WorkloadCheckpointed(n.publicKey, info), n.log)
+
+	return &info, nil
+}
+
+// Collects what restoring the workload requires besides its machine snapshot
+func (n *Node) checkpointManifest(workloadID string, request *agentapi.DeployRequest, agentClient *agentapi.AgentClient) (*checkpointManifest, error) {
+	session, err := agentClient.Session()
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := n.manager.natsint.FindCredentials(workloadID)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := redeployRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// the environment was sealed for this node, while the checkpoint may be restored on any
+	if source.Environment != nil && source.SenderPublicKey != nil {
+		err = n.api.xkeys.decrypt(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt workload environment: %s", err)
+		}
+	}
+	environment := source.WorkloadEnvironment
+	source.Environment = nil
+	source.SenderPublicKey = nil
+
+	return &checkpointManifest{
+		Workload:         request,
+		Source:           source,
+		Environment:      environment,
+		NkeySeed:         creds.NkeySeed,
+		Agent:            session,
+		InternalNodePort: *n.config.InternalNodePort,
+		Arch:             runtime.GOARCH,
+	}, nil
+}
+
+// Lists the checkpoints of the namespace's workloads, optionally those of a single workload,
+// newest first
+func (n *Node) ListCheckpoints(namespace string, workloadID string) ([]controlapi.CheckpointInfo, error) {
+	store, bucket, err := n.checkpointStore()
+	if err != nil {
+		return nil, err
+	}
+
+	return listCheckpoints(store, bucket, namespace, workloadID)
+}
+
+// Restores a workload from a checkpoint onto this node. The workload keeps the ID it had, and
+// so cannot be restored while it still runs. The claims the workload was deployed with are
+// validated as a deploy's would be, then handed to authorize before anything is restored
+func (n *Node) RestoreWorkload(namespace string, checkpointID string, authorize func(*jwt.GenericClaims) error) (*controlapi.CheckpointInfo, error) {
+	store, bucket, err := n.checkpointStore()
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := store.GetInfo(checkpointID)
+	if err != nil {
+		return nil, fmt.Errorf("no such checkpoint: %s", checkpointID)
+	}
+
+	info, ok := checkpointFromObject(obj, bucket)
+	if !ok || info.Namespace != namespace {
+		return nil, fmt.Errorf("no such checkpoint: %s", checkpointID)
+	}
+
+	if existing, _ := n.manager.LookupWorkload(info.WorkloadId); existing != nil {
+		return nil, fmt.Errorf("workload %s is already running on this node", info.WorkloadId)
+	}
+
+	err = n.manager.EnsureCapacity()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "nex-restore-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	result, err := store.Get(checkpointID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch checkpoint: %s", err)
+	}
+	manifest, err := readCheckpointArchive(result, dir)
+	_ = result.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %s", err)
+	}
+
+	if manifest.Arch != runtime.GOARCH {
+		return nil, fmt.Errorf("checkpoint was taken on %s, not %s", manifest.Arch, runtime.GOARCH)
+	}
+	if manifest.InternalNodePort != *n.config.InternalNodePort {
+		return nil, fmt.Errorf("checkpoint requires the internal NATS server on port %d", manifest.InternalNodePort)
+	}
+
+	if manifest.Source == nil || manifest.Source.WorkloadJwt == nil {
+		return nil, errors.New("checkpoint does not carry the workload's claims")
+	}
+	claims, err := manifest.Source.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint carries an invalid deploy request: %s", err)
+	}
+	if !validateIssuer(claims.Issuer, n.config.ValidIssuers) {
+		return nil, fmt.Errorf("invalid workload issuer: %s", claims.Issuer)
+	}
+	err = authorize(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	request := manifest.Workload
+	request.DecodedClaims = *claims
+	request.Location = manifest.Source.Location
+	request.WorkloadJwt = manifest.Source.WorkloadJwt
+	request.JsDomain = manifest.Source.JsDomain
+	request.SourceInput = manifest.Source.Input
+	request.SourceMounts = manifest.Source.Mounts
+	request.TargetNode = &n.publicKey
+
+	// the environment is sealed for this node, so that the workload can be redeployed from here
+	if len(manifest.Environment) > 0 {
+		senderXkey := n.api.PublicXKey()
+		env, err := controlapi.EncryptRequestEnvironment(n.api.xkeys.key(), senderXkey, manifest.Environment)
+		if err != nil {
+			return nil, err
+		}
+		request.EncryptedEnvironment = &env
+		request.SenderPublicKey = &senderXkey
+	}
+
+	err = n.manager.restoreWorkload(info.WorkloadId, request, dir, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	n.log.Info("Restored workload",
+		slog.String("workload_id", info.WorkloadId),
+		slog.String("checkpoint_id", info.Id),
+		slog.String("checkpoint_node", info.NodeId),
+	)
+
+	_ = PublishCloudEvent(n.events(), namespace, events.WorkloadRestored(n.publicKey, info), n.log)
+
+	return &info, nil
+}
+
+// Starts the workload's machine from the snapshot in the given directory, reconnects to its
+// agent and activates the workload as if it had just been deployed
+func (w *WorkloadManager) restoreWorkload(workloadID string, request *agentapi.DeployRequest, dir string, manifest *checkpointManifest) error {
+	_, err := w.natsint.RestoreCredentials(workloadID, []byte(manifest.NkeySeed))
+	if err != nil {
+		return err
+	}
+
+	err = w.procMan.RestoreProcess(workloadID, request, dir, &manifest.Machine)
+	if err != nil {
+		_ = w.natsint.DestroyCredentials(workloadID)
+		return fmt.Errorf("failed to restore machine: %s", err)
+	}
+	w.states.track(workloadID)
+	_ = w.transitionWorkload(workloadID, workloadStateDeploying, request)
+
+	clientConn, err := w.natsint.ConnectionWithID(workloadID)
+	if err != nil {
+		_ = w.StopWorkload(workloadID, false)
+		return err
+	}
+
+	agentClient := agentapi.NewAgentClient(
+		clientConn,
+		w.log,
+		w.handshakeTimeout,
+		w.pingTimeout,
+		w.agentHandshakeTimedOut,
+		w.agentHandshakeSucceeded,
+		w.agentContactLost,
+		w.agentEvent,
+		w.agentLog,
+	)
+
+	err = agentClient.Resume(workloadID, manifest.Agent, checkpointResumeTimeout)
+	if err != nil {
+		_ = w.StopWorkload(workloadID, false)
+		return fmt.Errorf("restored agent did not answer: %s", err)
+	}
+
+	w.poolMutex.Lock()
+	w.handshakes[workloadID] = time.Now().UTC().Format(time.RFC3339)
+	w.poolMutex.Unlock()
+
+	w.recordIntent(intentRecord{
+		Operation:  intentDeployStarted,
+		WorkloadID: workloadID,
+		Namespace:  *request.Namespace,
+		Name:       *request.WorkloadName,
+		Pid:        w.agentPid(workloadID),
+	})
+
+	w.sampleWorkloadUsage(workloadID, *request.Namespace, time.Now())
+
+	err = w.activateWorkload(workloadID, agentClient, request)
+	if err != nil {
+		return err
+	}
+
+	w.recordIntent(intentRecord{Operation: intentDeployCompleted, WorkloadID: workloadID})
+
+	w.t.WorkloadCounter.Add(w.ctx, 1, metric.WithAttributes(attribute.String("workload_type", string(request.WorkloadType))))
+	w.t.WorkloadCounter.Add(w.ctx, 1, metric.WithAttributes(attribute.String("namespace", *request.Namespace)), metric.WithAttributes(attribute.String("workload_type", string(request.WorkloadType))))
+	w.t.DeployedByteCounter.Add(w.ctx, request.TotalBytes)
+	w.t.DeployedByteCounter.Add(w.ctx, request.TotalBytes, metric.WithAttributes(attribute.String("namespace", *request.Namespace)))
+
+	return nil
+}
+
+// Checkpoints every running service workload each interval until the node stops
+func (n *Node) runCheckpoints() {
+	ticker := time.NewTicker(time.Duration(n.config.Checkpoints.IntervalMillis) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+			procs, err := n.manager.procMan.ListProcesses()
+			if err != nil {
+				continue
+			}
+
+			for _, proc := range procs {
+				request := proc.DeployRequest
+				if request == nil || !request.SupportsEssential() || request.Volume != nil || n.manager.isPaused(proc.ID) {
+					continue
+				}
+
+				_, err := n.CheckpointWorkload(proc.Namespace, proc.ID, false)
+				if err != nil {
+					n.log.Warn("Failed to take scheduled checkpoint", slog.String("workload_id", proc.ID), slog.Any("err", err))
+				}
+			}
+		}
+	}
+}
+
+// Binds the checkpoint bucket, creating it if needed
+func (n *Node) checkpointStore() (nats.ObjectStore, string, error) {
+	bucket := n.config.Checkpoints.Bucket
+	if bucket == "" {
+		bucket = controlapi.DefaultCheckpointBucket
+	}
+
+	js, err := n.nc.JetStream()
+	if err != nil {
+		return nil, bucket, err
+	}
+
+	store, err := js.ObjectStore(bucket)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		store, err = js.CreateObjectStore(&nats.ObjectStoreConfig{
+			Bucket:      bucket,
+			Description: "Nex workload checkpoints",
+		})
+	}
+	if err != nil {
+		return nil, bucket, fmt.Errorf("failed to bind to checkpoint bucket %s: %s", bucket, err)
+	}
+
+	return store, bucket, nil
+}
+
+// Deletes the workload's checkpoints beyond the most recent ones that are retained
+func (n *Node) pruneCheckpoints(store nats.ObjectStore, bucket string, namespace string, workloadID string) {
+	retain := n.config.Checkpoints.Retain
+	if retain <= 0 {
+		retain = defaultCheckpointRetain
+	}
+
+	checkpoints, err := listCheckpoints(store, bucket, namespace, workloadID)
+	if err != nil || len(checkpoints) <= retain {
+		return
+	}
+
+	for _, checkpoint := range checkpoints[retain:] {
+		err := store.Delete(checkpoint.Id)
+		if err != nil {
+			n.log.Warn("Failed to delete expired checkpoint", slog.String("checkpoint_id", checkpoint.Id), slog.Any("err", err))
+		}
+	}
+}
+
+func listCheckpoints(store nats.ObjectStore, bucket string, namespace string, workloadID string) ([]controlapi.CheckpointInfo, error) {
+	checkpoints := make([]controlapi.CheckpointInfo, 0)
+
+	objects, err := store.List()
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, obj := range objects {
+		info, ok := checkpointFromObject(obj, bucket)
+		if !ok || info.Namespace != namespace || (workloadID != "" && info.WorkloadId != workloadID) {
+			continue
+		}
+		checkpoints = append(checkpoints, info)
+	}
+
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].CreatedAt.After(checkpoints[j].CreatedAt)
+	})
+
+	return checkpoints, nil
+}
+
+func checkpointMetadata(info controlapi.CheckpointInfo) map[string]string {
+	return map[string]string{
+		checkpointMetaNamespace:    info.Namespace,
+		checkpointMetaWorkloadID:   info.WorkloadId,
+		checkpointMetaWorkloadName: info.Name,
+		checkpointMetaWorkloadType: string(info.WorkloadType),
+		checkpointMetaNodeID:       info.NodeId,
+		checkpointMetaCreatedAt:    info.CreatedAt.Format(time.RFC3339Nano),
+		checkpointMetaStopped:      strconv.FormatBool(info.Stopped),
+	}
+}
+
+// Describes the checkpoint stored in the given object, if it is one
+func checkpointFromObject(obj *nats.ObjectInfo, bucket string) (controlapi.CheckpointInfo, bool) {
+	if obj.Deleted || obj.Metadata[checkpointMetaWorkloadID] == "" {
+		return controlapi.CheckpointInfo{}, false
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, obj.Metadata[checkpointMetaCreatedAt])
+	if err != nil {
+		return controlapi.CheckpointInfo{}, false
+	}
+	stopped, _ := strconv.ParseBool(obj.Metadata[checkpointMetaStopped])
+
+	return controlapi.CheckpointInfo{
+		Id:           obj.Name,
+		WorkloadId:   obj.Metadata[checkpointMetaWorkloadID],
+		Name:         obj.Metadata[checkpointMetaWorkloadName],
+		Namespace:    obj.Metadata[checkpointMetaNamespace],
+		WorkloadType: controlapi.NexWorkload(obj.Metadata[checkpointMetaWorkloadType]),
+		NodeId:       obj.Metadata[checkpointMetaNodeID],
+		Bucket:       bucket,
+		SizeBytes:    obj.Size,
+		CreatedAt:    createdAt,
+		Stopped:      stopped,
+	}, true
+}
+
+// Writes the manifest and the machine snapshot files in the given directory as a gzipped tarball
+func writeCheckpointArchive(w io.Writer, dir string, manifest *checkpointManifest) error {
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	gz, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(gz)
+
+	err = tw.WriteHeader(&tar.Header{Name: checkpointManifestFile, Mode: 0600, Size: int64(len(raw))})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(raw)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range processmanager.CheckpointFiles {
+		err = writeCheckpointFile(tw, filepath.Join(dir, name), name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(tw.Close(), gz.Close())
+}
+
+func writeCheckpointFile(tw *tar.Writer, path string, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	finfo, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: finfo.Size()})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Unpacks a checkpoint written by writeCheckpointArchive into the given directory, returning
+// its manifest. Entries other than the manifest and the machine snapshot files are refused
+func readCheckpointArchive(r io.Reader, dir string) (*checkpointManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var manifest *checkpointManifest
+	found := make(map[string]bool)
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Name == checkpointManifestFile {
+			manifest = &checkpointManifest{}
+			err = json.NewDecoder(tr).Decode(manifest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid checkpoint manifest: %s", err)
+			}
+			continue
+		}
+
+		if !slices.Contains(processmanager.CheckpointFiles, header.Name) {
+			return nil, fmt.Errorf("unexpected file in checkpoint: %s", header.Name)
+		}
+
+		err = readCheckpointFile(tr, filepath.Join(dir, header.Name))
+		if err != nil {
+			return nil, err
+		}
+		found[header.Name] = true
+	}
+
+	if manifest == nil || manifest.Workload == nil || manifest.Source == nil || manifest.Agent == nil {
+		return nil, errors.New("checkpoint has no manifest")
+	}
+	for _, name := range processmanager.CheckpointFiles {
+		if !found[name] {
+			return nil, fmt.Errorf("checkpoint is missing %s", name)
+		}
+	}
+
+	return manifest, nil
+}
+
+func readCheckpointFile(r io.Reader, path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	return errors.Join(err, f.Close())
+}
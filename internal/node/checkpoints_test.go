@@ -0,0 +1,83 @@
+package nexnode
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/node/processmanager"
+)
+
+func TestCheckpointArchiveRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range processmanager.CheckpointFiles {
+		err := os.WriteFile(filepath.Join(src, name), []byte("contents of "+name), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	name := "echoservice"
+	namespace := "default"
+	manifest := &checkpointManifest{
+		Machine:          processmanager.MachineCheckpoint{GuestIP: "192.168.127.2", VcpuCount: 1, MemSizeMib: 256},
+		Workload:         &agentapi.DeployRequest{WorkloadName: &name, Namespace: &namespace, WorkloadType: controlapi.NexWorkloadNative},
+		Source:           &controlapi.DeployRequest{WorkloadType: controlapi.NexWorkloadNative},
+		Environment:      map[string]string{"NATS_URL": "nats://127.0.0.1:4222"},
+		NkeySeed:         "SUAseed",
+		Agent:            &agentapi.AgentSession{AgentVersion: "0.0.1", ProtocolVersion: 1},
+		InternalNodePort: 9222,
+		Arch:             "amd64",
+	}
+
+	var buf bytes.Buffer
+	err := writeCheckpointArchive(&buf, src, manifest)
+	if err != nil {
+		t.Fatalf("Expected to write checkpoint archive, got %s", err)
+	}
+
+	dst := t.TempDir()
+	got, err := readCheckpointArchive(&buf, dst)
+	if err != nil {
+		t.Fatalf("Expected to read checkpoint archive, got %s", err)
+	}
+
+	if got.Machine != manifest.Machine || *got.Workload.WorkloadName != name || got.Environment["NATS_URL"] != "nats://127.0.0.1:4222" || got.NkeySeed != manifest.NkeySeed || got.InternalNodePort != 9222 {
+		t.Fatalf("Expected manifest to round trip, got %+v", got)
+	}
+
+	for _, name := range processmanager.CheckpointFiles {
+		raw, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil || string(raw) != "contents of "+name {
+			t.Fatalf("Expected %s to be unpacked, got %q, %v", name, raw, err)
+		}
+	}
+}
+
+func TestCheckpointArchiveRejectsUnknownFiles(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	contents := []byte("escape")
+	_ = tw.WriteHeader(&tar.Header{Name: "../escape", Mode: 0600, Size: int64(len(contents))})
+	_, _ = tw.Write(contents)
+	_ = tw.Close()
+	_ = gz.Close()
+
+	dir := t.TempDir()
+	_, err := readCheckpointArchive(&buf, dir)
+	if err == nil || !strings.Contains(err.Error(), "unexpected file") {
+		t.Fatalf("Expected unknown file to be refused, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape")); !os.IsNotExist(err) {
+		t.Fatal("Expected unknown file not to be written")
+	}
+}
@@ -0,0 +1,63 @@
+package nexnode
+
+import (
+	"sync"
+)
+
+const compiledArtifactCacheMaxEntries = 32
+
+// Retains compiled workload artifacts reported by agents, keyed by the compiled workload
+// cache key, so they can be seeded into the cache bucket of later deployments of the same
+// workload. The least recently used artifact is evicted once the cache is full
+type compiledArtifactCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	artifacts  map[string][]byte
+	order      []string
+}
+
+func newCompiledArtifactCache(maxEntries int) *compiledArtifactCache {
+	return &compiledArtifactCache{
+		maxEntries: maxEntries,
+		artifacts:  make(map[string][]byte),
+		order:      make([]string, 0, maxEntries),
+	}
+}
+
+func (c *compiledArtifactCache) get(key string) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	artifact, ok := c.artifacts[key]
+	if ok {
+		c.touch(key)
+	}
+
+	return artifact, ok
+}
+
+func (c *compiledArtifactCache) put(key string, artifact []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.artifacts[key]; !ok && len(c.order) >= c.maxEntries {
+		evicted := c.order[0]
+		c.order = c.order[1:]
+		delete(c.artifacts, evicted)
+	}
+
+	c.artifacts[key] = artifact
+	c.touch(key)
+}
+
+// moves the given key to the most recently used position; callers must hold the mutex
+func (c *compiledArtifactCache) touch(key string) {
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+
+	c.order = append(c.order, key)
+}
@@ -0,0 +1,28 @@
+package nexnode
+
+import (
+	"testing"
+)
+
+func TestCompiledArtifactCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newCompiledArtifactCache(2)
+
+	cache.put("a", []byte("a"))
+	cache.put("b", []byte("b"))
+
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("Expected artifact a to be cached")
+	}
+
+	cache.put("c", []byte("c"))
+
+	if _, ok := cache.get("b"); ok {
+		t.Fatal("Expected least recently used artifact b to be evicted")
+	}
+
+	for _, key := range []string{"a", "c"} {
+		if artifact, ok := cache.get(key); !ok || string(artifact) != key {
+			t.Fatalf("Expected artifact %s to remain cached", key)
+		}
+	}
+}
@@ -3,9 +3,13 @@ package nexnode
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"maps"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
 	controlapi "github.com/synadia-io/nex/control-api"
@@ -49,5 +53,117 @@ func LoadNodeConfiguration(configFilepath string) (*models.NodeConfiguration, er
 		config.Tags = make(map[string]string)
 	}
 
+	err = applyTelemetryEnv(&config, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
+
+// Standard OpenTelemetry environment variables, which override the telemetry settings of the
+// node configuration file
+const (
+	otelEndpointEnv             = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	otelHeadersEnv              = "OTEL_EXPORTER_OTLP_HEADERS"
+	otelTracesSamplerArgEnv     = "OTEL_TRACES_SAMPLER_ARG"
+	otelMetricExportIntervalEnv = "OTEL_METRIC_EXPORT_INTERVAL"
+	otelResourceAttributesEnv   = "OTEL_RESOURCE_ATTRIBUTES"
+)
+
+// Applies the OpenTelemetry environment variables found by lookup to the configuration. Headers
+// and resource attributes are merged with those of the configuration file, and an endpoint with
+// an https scheme enables TLS
+func applyTelemetryEnv(config *models.NodeConfiguration, lookup func(string) (string, bool)) error {
+	telemetry := config.Telemetry
+	if telemetry == nil {
+		telemetry = &models.TelemetryConfig{}
+	}
+	changed := false
+
+	if endpoint, ok := lookup(otelEndpointEnv); ok && endpoint != "" {
+		if strings.Contains(endpoint, "://") {
+			u, err := url.Parse(endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("invalid %s %q; expected an http(s) URL or host:port", otelEndpointEnv, endpoint)
+			}
+			endpoint = u.Host
+			telemetry.TLS = u.Scheme == "https"
+		}
+		telemetry.Endpoint = endpoint
+		changed = true
+	}
+
+	if headers, ok := lookup(otelHeadersEnv); ok && headers != "" {
+		pairs, err := parseOtelKeyValues(headers)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", otelHeadersEnv, err)
+		}
+		if telemetry.Headers == nil {
+			telemetry.Headers = make(map[string]string, len(pairs))
+		}
+		maps.Copy(telemetry.Headers, pairs)
+		changed = true
+	}
+
+	if arg, ok := lookup(otelTracesSamplerArgEnv); ok && arg != "" {
+		ratio, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", otelTracesSamplerArgEnv, arg, err)
+		}
+		telemetry.TraceSampleRatio = &ratio
+		changed = true
+	}
+
+	if interval, ok := lookup(otelMetricExportIntervalEnv); ok && interval != "" {
+		millis, err := strconv.Atoi(interval)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", otelMetricExportIntervalEnv, interval, err)
+		}
+		telemetry.MetricExportIntervalMillis = millis
+		changed = true
+	}
+
+	if attributes, ok := lookup(otelResourceAttributesEnv); ok && attributes != "" {
+		pairs, err := parseOtelKeyValues(attributes)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", otelResourceAttributesEnv, err)
+		}
+		if telemetry.ResourceAttributes == nil {
+			telemetry.ResourceAttributes = make(map[string]string, len(pairs))
+		}
+		maps.Copy(telemetry.ResourceAttributes, pairs)
+		changed = true
+	}
+
+	if changed {
+		config.Telemetry = telemetry
+	}
+
+	return nil
+}
+
+// Parses a comma separated list of key=value pairs with URL encoded values, the format of the
+// OpenTelemetry headers and resource attributes environment variables
+func parseOtelKeyValues(list string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, pair := range strings.Split(list, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+
+		value, err := url.QueryUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", key, err)
+		}
+		pairs[key] = value
+	}
+
+	return pairs, nil
+}
@@ -0,0 +1,251 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/events"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const nodeNameTag = "node_name"
+
+// Configuration settings, by JSON field name, that a running node applies on reload. Changes to
+// any other setting are reported as requiring a restart
+var reloadableConfigFields = []string{
+	"admin_keys",
+	"log_level",
+	"machine_pool_size",
+	"resources",
+	"tags",
+	"trigger_workers",
+	"valid_issuers",
+	"workload_pools",
+}
+
+// Re-reads the node's configuration file and applies the settings that are safe to change while
+// running. The file is validated in full before anything is applied, so an invalid file leaves the
+// running configuration untouched
+func (n *Node) ReloadConfig() (*controlapi.ReloadResponse, error) {
+	n.reloadMutex.Lock()
+	defer n.reloadMutex.Unlock()
+
+	next, err := n.loadReloadedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffNodeConfiguration(n.config, next)
+
+	response := &controlapi.ReloadResponse{
+		NodeId:  n.publicKey,
+		Changes: make([]controlapi.ConfigChange, 0),
+	}
+	for _, change := range diff {
+		if slices.Contains(reloadableConfigFields, change.Field) {
+			response.Changes = append(response.Changes, change)
+		} else {
+			response.RestartRequired = append(response.RestartRequired, change.Field)
+		}
+	}
+
+	if len(response.Changes) > 0 {
+		n.applyReloadedConfig(next)
+	}
+
+	if len(diff) > 0 {
+		_ = n.publishNodeConfigReloaded(response)
+	}
+
+	return response, nil
+}
+
+func (n *Node) reloadOnSignal() {
+	n.log.Info("Reloading node configuration", slog.String("path", n.nodeOpts.ConfigFilepath))
+
+	response, err := n.ReloadConfig()
+	if err != nil {
+		n.log.Error("Failed to reload node configuration", slog.Any("error", err))
+		return
+	}
+
+	for _, change := range response.Changes {
+		n.log.Info("Applied configuration change", slog.String("field", change.Field))
+	}
+	if len(response.RestartRequired) > 0 {
+		n.log.Warn("Configuration changes require a restart to take effect",
+			slog.String("fields", strings.Join(response.RestartRequired, ",")),
+		)
+	}
+}
+
+// Loads and validates the configuration file, carrying over the settings that the node derives
+// at startup so that they do not show up as changes
+func (n *Node) loadReloadedConfig() (*models.NodeConfiguration, error) {
+	next, err := LoadNodeConfiguration(n.nodeOpts.ConfigFilepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load node configuration: %s", err)
+	}
+
+	next.OtelMetrics = n.config.OtelMetrics
+	next.OtelMetricsExporter = n.config.OtelMetricsExporter
+	next.OtelMetricsPort = n.config.OtelMetricsPort
+	next.OtelTraces = n.config.OtelTraces
+	next.OtelTracesExporter = n.config.OtelTracesExporter
+	next.OtelLogs = n.config.OtelLogs
+	next.OtelLogsExporter = n.config.OtelLogsExporter
+	next.InternalNodePort = n.config.InternalNodePort
+
+	if n.config.Artifacts != nil && reflect.DeepEqual(n.config.Artifacts, next.Artifacts) {
+		next.KernelFilepath = n.config.KernelFilepath
+		next.RootFsFilepath = n.config.RootFsFilepath
+	}
+
+	if n.runtimeTags != nil {
+		n.runtimeTags.apply(next.Tags)
+	}
+
+	// a node name is generated at random unless the file sets one; keep the name the node started with
+	if !configFileSetsTag(n.nodeOpts.ConfigFilepath, nodeNameTag) {
+		if name, ok := n.config.Tags[nodeNameTag]; ok {
+			next.Tags[nodeNameTag] = name
+		} else {
+			delete(next.Tags, nodeNameTag)
+		}
+	}
+
+	// tags managed by the node itself
+	for _, tag := range []string{
+		controlapi.TagOS, controlapi.TagArch, controlapi.TagCPUs, controlapi.TagUnsafe,
+		controlapi.TagGPUs, controlapi.TagGPUModel, controlapi.TagCordoned, controlapi.TagLameDuck,
+		controlapi.TagSite,
+	} {
+		if value, ok := n.config.Tags[tag]; ok {
+			next.Tags[tag] = value
+		} else {
+			delete(next.Tags, tag)
+		}
+	}
+
+	if !next.Validate() {
+		return nil, fmt.Errorf("invalid node configuration: %v", next.Errors)
+	}
+
+	if n.manager != nil {
+		for pool := range n.manager.maxPoolSizes {
+			if pool != models.DefaultAgentPool && !slices.Contains(next.AgentPools(), pool) {
+				return nil, fmt.Errorf("workload pool %s cannot be removed without a restart", pool)
+			}
+		}
+
+		for _, pool := range next.AgentPools() {
+			maxSize, ok := n.manager.maxPoolSizes[pool]
+			if !ok {
+				return nil, fmt.Errorf("workload pool %s cannot be added without a restart", pool)
+			}
+
+			if next.AgentPoolSize(pool) > maxSize {
+				if pool == models.DefaultAgentPool {
+					return nil, fmt.Errorf("machine pool size cannot be raised above %d without a restart", maxSize)
+				}
+				return nil, fmt.Errorf("size of the %s workload pool cannot be raised above %d without a restart", pool, maxSize)
+			}
+		}
+	}
+
+	return next, nil
+}
+
+func (n *Node) applyReloadedConfig(next *models.NodeConfiguration) {
+	n.cordonMutex.Lock()
+	n.config.Tags = next.Tags
+	n.capabilities = n.computeCapabilities()
+	n.cordonMutex.Unlock()
+
+	n.config.MachinePoolSize = next.MachinePoolSize
+	n.config.WorkloadPools = next.WorkloadPools
+	n.config.Resources = next.Resources
+	n.config.ValidIssuers = next.ValidIssuers
+	n.config.AdminKeys = next.AdminKeys
+	n.config.TriggerWorkers = next.TriggerWorkers
+	n.config.LogLevel = next.LogLevel
+
+	n.applyLogLevel()
+}
+
+// Sets the level of the node's logger from the configuration, if both are present
+func (n *Node) applyLogLevel() {
+	if n.nodeOpts.LogLevel == nil || n.config.LogLevel == "" {
+		return
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(n.config.LogLevel)); err == nil {
+		n.nodeOpts.LogLevel.Set(level)
+	}
+}
+
+// Compares two configurations field by field, returning the settings that differ
+func diffNodeConfiguration(current, next *models.NodeConfiguration) []controlapi.ConfigChange {
+	changes := make([]controlapi.ConfigChange, 0)
+
+	cv := reflect.ValueOf(current).Elem()
+	nv := reflect.ValueOf(next).Elem()
+	for i := 0; i < cv.NumField(); i++ {
+		name, _, _ := strings.Cut(cv.Type().Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || name == "errors" {
+			continue
+		}
+
+		previous := cv.Field(i).Interface()
+		updated := nv.Field(i).Interface()
+		if !reflect.DeepEqual(previous, updated) {
+			changes = append(changes, controlapi.ConfigChange{
+				Field:    name,
+				Previous: previous,
+				Current:  updated,
+			})
+		}
+	}
+
+	return changes
+}
+
+func configFileSetsTag(path string, tag string) bool {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	var raw struct {
+		Tags map[string]string `json:"tags"`
+	}
+	if json.Unmarshal(bytes, &raw) != nil {
+		return false
+	}
+
+	_, ok := raw.Tags[tag]
+	return ok
+}
+
+func (n *Node) publishNodeConfigReloaded(response *controlapi.ReloadResponse) error {
+	if n.nc == nil {
+		return nil
+	}
+
+	evt := controlapi.NodeConfigReloadedEvent{
+		Id:              n.publicKey,
+		Changes:         response.Changes,
+		RestartRequired: response.RestartRequired,
+	}
+
+	cloudevent := events.NodeConfigReloaded(n.publicKey, evt)
+
+	return PublishCloudEvent(n.events(), systemNamespace, cloudevent, n.log)
+}
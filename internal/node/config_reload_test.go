@@ -0,0 +1,28 @@
+package nexnode
+
+import (
+	"testing"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestDiffNodeConfigurationReportsChangedFields(t *testing.T) {
+	current := models.DefaultNodeConfiguration()
+	next := current
+	next.Tags = map[string]string{"rack": "b"}
+	next.MachinePoolSize = current.MachinePoolSize + 1
+	next.Errors = []error{nil}
+
+	changes := diffNodeConfiguration(&current, &next)
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d: %v", len(changes), changes)
+	}
+
+	fields := map[string]bool{}
+	for _, change := range changes {
+		fields[change.Field] = true
+	}
+	if !fields["tags"] || !fields["machine_pool_size"] {
+		t.Fatalf("Expected tags and machine_pool_size to change, got %v", changes)
+	}
+}
@@ -2,6 +2,9 @@ package nexnode
 
 import (
 	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
 )
 
 func TestNodeConfigResolution(t *testing.T) {
@@ -27,3 +30,79 @@ func TestNodeConfigResolution(t *testing.T) {
 		t.Fatal("in custom config http service should be disabled")
 	}
 }
+
+func TestAgentPoolSizesLeaveSharedPoolEmptyWhenEveryTypeIsDedicated(t *testing.T) {
+	config := models.DefaultNodeConfiguration()
+	config.MachinePoolSize = 2
+	config.WorkloadTypes = []controlapi.NexWorkload{controlapi.NexWorkloadNative, controlapi.NexWorkloadV8}
+	config.WorkloadPools = map[controlapi.NexWorkload]models.WorkloadPoolConfig{
+		controlapi.NexWorkloadV8: {PoolSize: 3},
+	}
+
+	if size := config.AgentPoolSize(models.DefaultAgentPool); size != 2 {
+		t.Fatalf("Expected the shared pool to keep the machine pool size, got %d", size)
+	}
+	if size := config.AgentPoolSize(controlapi.NexWorkloadV8); size != 3 {
+		t.Fatalf("Expected the v8 pool to have 3 agents, got %d", size)
+	}
+
+	config.WorkloadPools[controlapi.NexWorkloadNative] = models.WorkloadPoolConfig{PoolSize: 1}
+	if size := config.AgentPoolSize(models.DefaultAgentPool); size != 0 {
+		t.Fatalf("Expected the shared pool to be empty, got %d", size)
+	}
+}
+
+func TestTelemetryEnvOverridesConfig(t *testing.T) {
+	config := models.DefaultNodeConfiguration()
+	config.Telemetry = &models.TelemetryConfig{
+		Headers:            map[string]string{"x-team": "edge"},
+		ResourceAttributes: map[string]string{"cloud.region": "us-east-1"},
+	}
+
+	env := map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT": "https://otlp.example.com:4317",
+		"OTEL_EXPORTER_OTLP_HEADERS":  "api-key=s3cr%3Dt",
+		"OTEL_TRACES_SAMPLER_ARG":     "0.25",
+		"OTEL_METRIC_EXPORT_INTERVAL": "15000",
+		"OTEL_RESOURCE_ATTRIBUTES":    "deployment.environment=prod, cloud.region=eu-west-1",
+	}
+	err := applyTelemetryEnv(&config, func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatalf("Expected the telemetry environment to apply: %s", err)
+	}
+
+	telemetry := config.Telemetry
+	if telemetry.Endpoint != "otlp.example.com:4317" || !telemetry.TLS {
+		t.Fatalf("Expected an https endpoint to enable TLS, got %q (tls %v)", telemetry.Endpoint, telemetry.TLS)
+	}
+	if telemetry.Headers["api-key"] != "s3cr=t" || telemetry.Headers["x-team"] != "edge" {
+		t.Fatalf("Expected the headers to be merged and unescaped, got %v", telemetry.Headers)
+	}
+	if telemetry.TraceSampleRatio == nil || *telemetry.TraceSampleRatio != 0.25 {
+		t.Fatalf("Expected a trace sample ratio of 0.25, got %v", telemetry.TraceSampleRatio)
+	}
+	if telemetry.MetricExportIntervalMillis != 15000 {
+		t.Fatalf("Expected a metric export interval of 15000ms, got %d", telemetry.MetricExportIntervalMillis)
+	}
+	if telemetry.ResourceAttributes["deployment.environment"] != "prod" || telemetry.ResourceAttributes["cloud.region"] != "eu-west-1" {
+		t.Fatalf("Expected the environment's resource attributes to win, got %v", telemetry.ResourceAttributes)
+	}
+
+	err = applyTelemetryEnv(&config, func(key string) (string, bool) {
+		return "not-a-pair", key == "OTEL_RESOURCE_ATTRIBUTES"
+	})
+	if err == nil {
+		t.Fatal("Expected malformed resource attributes to be rejected")
+	}
+}
+
+func TestTelemetryEnvLeavesConfigUntouchedWhenUnset(t *testing.T) {
+	config := models.DefaultNodeConfiguration()
+	err := applyTelemetryEnv(&config, func(string) (string, bool) { return "", false })
+	if err != nil || config.Telemetry != nil {
+		t.Fatalf("Expected no telemetry settings without environment variables, got %+v (%v)", config.Telemetry, err)
+	}
+}
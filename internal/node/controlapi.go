@@ -4,26 +4,45 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math/rand"
 	"runtime"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/nats-io/jwt/v2"
 	"github.com/nats-io/nats.go"
-	"github.com/nats-io/nkeys"
 	"github.com/pkg/errors"
 	controlapi "github.com/synadia-io/nex/control-api"
 	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/events"
+	"github.com/synadia-io/nex/internal/models"
 )
 
+// How long a node in aggregation mode waits for each node's info
+const nexusInfoTimeout = 2 * time.Second
+
 // The API listener is the command and control interface for the node server
 type ApiListener struct {
 	node  *Node
 	mgr   *WorkloadManager
 	log   *slog.Logger
 	start time.Time
-	xk    nkeys.KeyPair
+	xkeys *nodeXKeys
+
+	// Holds queueable deploy requests while no agent is available; nil when queueing is disabled
+	queue *deployQueue
+
+	// Decides which issuers may make namespaced requests; nil when no access policy is configured
+	policy *accessPolicy
+
+	// Namespace objects and their defaults; nil when namespaces are plain strings
+	namespaces *namespaceRegistry
+
+	// Declared resources served by this node; nil when the node does not manage them
+	resources *resourceStore
 
 	subz []*nats.Subscription
 }
@@ -39,31 +58,74 @@ func NewApiListener(log *slog.Logger, mgr *WorkloadManager, node *Node) *ApiList
 	if node.config.NoSandbox {
 		efftags[controlapi.TagUnsafe] = "true"
 	}
+	if site := nodeSite(config, node.nc); site != "" {
+		efftags[controlapi.TagSite] = site
+	}
+	if gpuCount, _ := mgr.gpus.counts(); gpuCount > 0 {
+		efftags[controlapi.TagGPUs] = strconv.Itoa(gpuCount)
+		if mgr.gpus.model != "" {
+			efftags[controlapi.TagGPUModel] = mgr.gpus.model
+		}
+	}
 
-	kp, err := nkeys.CreateCurveKeys()
-	if err != nil {
-		log.Error("Failed to create x509 curve key", slog.Any("err", err))
-		return nil
+	// a node keeps its xkey across restarts when it persists its keys, and across updates through
+	// its predecessor's handoff, so environments encrypted for it can still be decrypted
+	var xkeys *nodeXKeys
+	var err error
+	switch {
+	case node.keys != nil:
+		xkeys, err = newNodeXKeys(node.keys.XKeySeed, node.keys.PreviousXKeySeed, node.keys.PreviousXKeyUntil)
+	case node.handoff != nil && node.handoff.XKeySeed != "":
+		xkeys, err = newNodeXKeys(node.handoff.XKeySeed, node.handoff.PreviousXKeySeed, node.handoff.PreviousXKeyUntil)
+	default:
+		xkeys, err = newNodeXKeys("", "", nil)
 	}
-	xkPub, err := kp.PublicKey()
 	if err != nil {
-		log.Error("Failed to get public key from x509 curve key", slog.Any("err", err))
+		log.Error("Failed to create x509 curve key", slog.Any("err", err))
 		return nil
 	}
+	xkPub := xkeys.publicKey()
 
 	log.Info("Use this key as the recipient for encrypted run requests", slog.String("public_xkey", xkPub))
 
+	var queue *deployQueue
+	if config.DeployQueue != nil {
+		timeoutMillis := config.DeployQueue.TimeoutMillisecond
+		if timeoutMillis == 0 {
+			timeoutMillis = models.DefaultDeployQueueTimeoutMillisecond
+		}
+		queue = newDeployQueue(config.DeployQueue.MaxSize, time.Duration(timeoutMillis)*time.Millisecond)
+	}
+
+	var policy *accessPolicy
+	if config.AccessPolicy != nil {
+		policy = newAccessPolicy(config.AccessPolicy, log)
+	}
+
 	return &ApiListener{
-		mgr:   mgr,
-		log:   log,
-		xk:    kp,
-		start: time.Now().UTC(),
-		node:  node,
-		subz:  make([]*nats.Subscription, 0),
+		mgr:    mgr,
+		log:    log,
+		xkeys:  xkeys,
+		start:  time.Now().UTC(),
+		node:   node,
+		queue:  queue,
+		policy: policy,
+		subz:   make([]*nats.Subscription, 0),
+
+		namespaces: newNamespaceRegistry(config.Namespaces, log),
+		resources:  newResourceStore(config.DeclaredResources),
 	}
 }
 
 func (api *ApiListener) Drain() error {
+	if api.policy != nil {
+		api.policy.stop()
+	}
+
+	if api.namespaces != nil {
+		api.namespaces.stop()
+	}
+
 	for _, sub := range api.subz {
 		err := sub.Drain()
 		if err != nil {
@@ -88,430 +150,2719 @@ func (api *ApiListener) PublicKey() string {
 }
 
 func (api *ApiListener) PublicXKey() string {
-	pk, _ := api.xk.PublicKey()
-	return pk
+	return api.xkeys.publicKey()
 }
 
 func (api *ApiListener) Start() error {
 	var sub *nats.Subscription
 	var err error
 
-	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".AUCTION", api.handleAuction)
+	if api.policy != nil && api.node.config.AccessPolicy.Bucket != "" {
+		err = api.policy.watch(api.node.nc, api.node.config.AccessPolicy)
+		if err != nil {
+			api.log.Error("Failed to load access policy", slog.Any("err", err))
+			return err
+		}
+	}
+
+	if api.node.config.Audit != nil {
+		err = api.ensureAuditStream()
+		if err != nil {
+			api.log.Error("Failed to set up audit stream", slog.Any("err", err))
+			return err
+		}
+	}
+
+	if api.namespaces != nil {
+		err = api.namespaces.watch(api.node.nc, func(ns controlapi.Namespace) {
+			go api.namespaceDeleted(ns)
+		})
+		if err != nil {
+			api.log.Error("Failed to load namespaces", slog.Any("err", err))
+			return err
+		}
+
+		sub, err = api.node.nc.QueueSubscribe(controlapi.APIPrefix+".NAMESPACE.*", namespaceQueueGroup, api.audited(api.handleNamespace))
+		if err != nil {
+			api.log.Error("Failed to subscribe to namespace subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
+		}
+		api.subz = append(api.subz, sub)
+	}
+
+	if api.resources != nil {
+		err = api.resources.bind(api.node.nc)
+		if err != nil {
+			api.log.Error("Failed to bind to resources bucket", slog.Any("err", err))
+			return err
+		}
+
+		sub, err = api.node.nc.QueueSubscribe(controlapi.APIPrefix+".RESOURCES.*", resourceQueueGroup, api.audited(api.handleResource))
+		if err != nil {
+			api.log.Error("Failed to subscribe to resources subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
+		}
+		api.subz = append(api.subz, sub)
+	}
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".STATE.*", api.audited(api.handleNodeState))
+	if err != nil {
+		api.log.Error("Failed to subscribe to node state subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".AUCTION", api.audited(api.handleAuction))
 	if err != nil {
 		api.log.Error("Failed to subscribe to auction subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
 	}
 	api.subz = append(api.subz, sub)
 
-	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".PING", api.handlePing)
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".DISCOVER", api.audited(api.handleDiscover))
+	if err != nil {
+		api.log.Error("Failed to subscribe to discover subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".PING", api.audited(api.handlePing))
 	if err != nil {
 		api.log.Error("Failed to subscribe to ping subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
 	}
 	api.subz = append(api.subz, sub)
 
-	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".PING."+api.PublicKey(), api.handlePing)
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".PING."+api.PublicKey(), api.audited(api.handlePing))
 	if err != nil {
 		api.log.Error("Failed to subscribe to node-specific ping subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
 	}
 	api.subz = append(api.subz, sub)
 
-	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".WPING.>", api.handleWorkloadPing)
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".WPING.>", api.audited(api.handleWorkloadPing))
 	if err != nil {
 		api.log.Error("Failed to subscribe to workload ping subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
 	}
 	api.subz = append(api.subz, sub)
 
+	// Group subscriptions, the first * below is for the namespace and the second for the group
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".GPING.*.*", api.audited(api.handleGroupPing))
+	if err != nil {
+		api.log.Error("Failed to subscribe to group ping subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".GSTOP.*.*", api.audited(api.handleGroupStop))
+	if err != nil {
+		api.log.Error("Failed to subscribe to group stop subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".GRESTART.*.*", api.audited(api.handleGroupRestart))
+	if err != nil {
+		api.log.Error("Failed to subscribe to group restart subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
 	// Namespaced subscriptions, the * below is for the namespace
-	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".INFO.*."+api.PublicKey(), api.handleInfo)
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".INFO.*."+api.PublicKey(), api.audited(api.handleInfo))
 	if err != nil {
 		api.log.Error("Failed to subscribe to info subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
 	}
 	api.subz = append(api.subz, sub)
 
-	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".DEPLOY.*."+api.PublicKey(), api.handleDeploy)
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".HISTORY.*."+api.PublicKey(), api.audited(api.handleExecutionHistory))
+	if err != nil {
+		api.log.Error("Failed to subscribe to execution history subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".JOBSTATUS.*."+api.PublicKey(), api.audited(api.handleJobStatus))
+	if err != nil {
+		api.log.Error("Failed to subscribe to job status subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".SUBZ.*."+api.PublicKey(), api.audited(api.handleSubscriptions))
+	if err != nil {
+		api.log.Error("Failed to subscribe to trigger subscriptions subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".QUARANTINE.*."+api.PublicKey(), api.audited(api.handleQuarantine))
+	if err != nil {
+		api.log.Error("Failed to subscribe to quarantine subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".PAUSE.*."+api.PublicKey(), api.audited(api.handlePause))
+	if err != nil {
+		api.log.Error("Failed to subscribe to pause subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".RESUME.*."+api.PublicKey(), api.audited(api.handleResume))
+	if err != nil {
+		api.log.Error("Failed to subscribe to resume subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".VOLUMES.*."+api.PublicKey(), api.audited(api.handleVolumes))
+	if err != nil {
+		api.log.Error("Failed to subscribe to volumes subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".CHECKPOINT.*."+api.PublicKey(), api.audited(api.handleCheckpoint))
+	if err != nil {
+		api.log.Error("Failed to subscribe to checkpoint subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".CHECKPOINTS.*."+api.PublicKey(), api.audited(api.handleCheckpoints))
+	if err != nil {
+		api.log.Error("Failed to subscribe to checkpoints subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".RESTORE.*."+api.PublicKey(), api.audited(api.handleRestore))
+	if err != nil {
+		api.log.Error("Failed to subscribe to restore subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".USAGE.*."+api.PublicKey(), api.audited(api.handleUsage))
+	if err != nil {
+		api.log.Error("Failed to subscribe to usage subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".DEPLOY.*."+api.PublicKey(), api.audited(api.handleDeploy))
 	if err != nil {
 		api.log.Error("Failed to subscribe to run subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
 	}
 	api.subz = append(api.subz, sub)
 
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".CANCELDEPLOY.*."+api.PublicKey(), api.audited(api.handleCancelDeploy))
+	if err != nil {
+		api.log.Error("Failed to subscribe to cancel deploy subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	if api.queue != nil {
+		go api.dispatchQueuedDeploys()
+	}
+
 	// FIXME? per contract, this should probably be renamed from STOP to UNDEPLOY
-	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".STOP.*."+api.PublicKey(), api.handleStop)
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".STOP.*."+api.PublicKey(), api.audited(api.handleStop))
 	if err != nil {
 		api.log.Error("Failed to subscribe to stop subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
 	}
 	api.subz = append(api.subz, sub)
 
-	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".LAMEDUCK."+api.PublicKey(), api.handleLameDuck)
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".BULKSTOP.*", api.audited(api.handleBulkStop))
+	if err != nil {
+		api.log.Error("Failed to subscribe to bulk stop subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".CUTOVER.*."+api.PublicKey(), api.audited(api.handleCutover))
+	if err != nil {
+		api.log.Error("Failed to subscribe to cutover subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".HOTRELOAD.*."+api.PublicKey(), api.audited(api.handleHotReload))
+	if err != nil {
+		api.log.Error("Failed to subscribe to hot reload subject", slog.Any("err", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".LAMEDUCK."+api.PublicKey(), api.audited(api.handleLameDuck))
 	if err != nil {
 		api.log.Error("Failed to subscribe to lame duck subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
 	}
 	api.subz = append(api.subz, sub)
 
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".CORDON."+api.PublicKey(), api.audited(api.handleCordon))
+	if err != nil {
+		api.log.Error("Failed to subscribe to cordon subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".UNCORDON."+api.PublicKey(), api.audited(api.handleUncordon))
+	if err != nil {
+		api.log.Error("Failed to subscribe to uncordon subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".RELOAD."+api.PublicKey(), api.audited(api.handleReload))
+	if err != nil {
+		api.log.Error("Failed to subscribe to reload subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".TAGS."+api.PublicKey(), api.audited(api.handleTags))
+	if err != nil {
+		api.log.Error("Failed to subscribe to tags subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".UPDATE."+api.PublicKey(), api.audited(api.handleUpdate))
+	if err != nil {
+		api.log.Error("Failed to subscribe to update subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".ROOTFS."+api.PublicKey(), api.audited(api.handleRootfsRollout))
+	if err != nil {
+		api.log.Error("Failed to subscribe to rootfs rollout subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".ROOTFSSTATUS."+api.PublicKey(), api.audited(api.handleRootfsRolloutStatus))
+	if err != nil {
+		api.log.Error("Failed to subscribe to rootfs rollout status subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".ROOTFSABORT."+api.PublicKey(), api.audited(api.handleRootfsRolloutAbort))
+	if err != nil {
+		api.log.Error("Failed to subscribe to rootfs rollout abort subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".EVACUATE."+api.PublicKey(), api.audited(api.handleEvacuate))
+	if err != nil {
+		api.log.Error("Failed to subscribe to evacuate subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".ROTATEKEYS."+api.PublicKey(), api.audited(api.handleRotateKeys))
+	if err != nil {
+		api.log.Error("Failed to subscribe to rotate keys subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".DIAGNOSTICS."+api.PublicKey(), api.audited(api.handleDiagnostics))
+	if err != nil {
+		api.log.Error("Failed to subscribe to diagnostics subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	sub, err = api.node.nc.Subscribe(controlapi.APIPrefix+".SCHEMAS."+api.PublicKey(), api.audited(api.handleEventSchemas))
+	if err != nil {
+		api.log.Error("Failed to subscribe to event schemas subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	api.subscribeFaultInjection()
+
+	if api.node.config.NexusAggregator {
+		sub, err = controlapi.ServeNexusInfo(api.node.nc, api.PublicKey(), nexusInfoTimeout, controlapi.DefaultNexusDiscoveryWindow, api.log)
+		if err != nil {
+			api.log.Error("Failed to subscribe to nexus info subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+		}
+		api.subz = append(api.subz, sub)
+	}
+
 	api.log.Info("NATS execution engine awaiting commands", slog.String("id", api.PublicKey()), slog.String("version", VERSION))
 	return nil
 }
 
-func (api *ApiListener) handleAuction(m *nats.Msg) {
+func (api *ApiListener) handleAuction(m *apiRequest) {
 	now := time.Now().UTC()
 
-	filter := false
+	if api.node.IsCordoned() {
+		api.log.Debug("Cordoned node declining auction")
+		return
+	}
+
+	if api.node.UnderPressure() {
+		api.log.Debug("Node under pressure declining auction")
+		return
+	}
 
 	var req *controlapi.AuctionRequest
 	err := json.Unmarshal(m.Data, &req)
-	if err == nil {
-		// PING request was successfully parsed
-		if req.Arch != nil && !strings.EqualFold(api.node.config.Tags[controlapi.TagArch], *req.Arch) {
+	if err == nil && !api.matchesAuction(req) {
+		api.log.Debug("Node not viable for deploy request specified at auction")
+		return
+	}
+
+	machines, err := api.mgr.RunningWorkloads()
+	if err != nil {
+		api.log.Error("Failed to query running machines", slog.Any("error", err))
+		respondFail(controlapi.AuctionResponseType, m, "Failed to query running machines on node")
+		return
+	}
+
+	res := controlapi.NewEnvelope(controlapi.AuctionResponseType, controlapi.AuctionResponse{
+		NodeId:          api.PublicKey(),
+		Nexus:           api.node.nexus,
+		Version:         Version(),
+		TargetXkey:      api.PublicXKey(),
+		Uptime:          myUptime(now.Sub(api.start)),
+		RunningMachines: len(machines),
+		Tags:            api.node.config.Tags,
+		Capacity:        api.mgr.Capacity(),
+	}, nil)
+
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to marshal ping response", slog.Any("err", err))
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// Reports whether this node satisfies the filters of an auction or discovery request
+func (api *ApiListener) matchesAuction(req *controlapi.AuctionRequest) bool {
+	if req == nil {
+		return true
+	}
+
+	filter := false
+
+	if req.Arch != nil && !strings.EqualFold(api.node.config.Tags[controlapi.TagArch], *req.Arch) {
+		filter = true
+	}
+
+	if len(req.Archs) > 0 && !slices.ContainsFunc(req.Archs, func(arch string) bool {
+		return strings.EqualFold(api.node.config.Tags[controlapi.TagArch], arch)
+	}) {
+		filter = true
+	}
+
+	if req.OS != nil && !strings.EqualFold(api.node.config.Tags[controlapi.TagOS], *req.OS) {
+		filter = true
+	}
+
+	if len(req.Sites) > 0 && !controlapi.InSites(api.node.config.Tags[controlapi.TagSite], req.Sites) {
+		filter = true
+	}
+
+	if req.Sandboxed != nil && api.node.config.NoSandbox != !*req.Sandboxed {
+		filter = true
+	}
+
+	for tag := range req.Tags {
+		val, ok := api.node.config.Tags[tag]
+		if !ok {
+			filter = true
+		} else if !strings.EqualFold(val, req.Tags[tag]) {
 			filter = true
 		}
+	}
 
-		if req.OS != nil && !strings.EqualFold(api.node.config.Tags[controlapi.TagOS], *req.OS) {
+	for _, workloadType := range req.WorkloadTypes {
+		if !slices.Contains(api.node.config.WorkloadTypes, workloadType) {
 			filter = true
 		}
+	}
 
-		if req.Sandboxed != nil && api.node.config.NoSandbox != !*req.Sandboxed {
+	if req.GPUs > 0 {
+		if _, available := api.mgr.gpus.counts(); available < req.GPUs {
 			filter = true
 		}
+	}
 
-		for tag := range req.Tags {
-			val, ok := api.node.config.Tags[tag]
-			if !ok {
-				filter = true
-			} else if !strings.EqualFold(val, req.Tags[tag]) {
-				filter = true
-			}
+	if len(req.AntiAffinity) > 0 || req.Spread != nil {
+		machines, err := api.mgr.RunningWorkloads()
+		if err != nil {
+			api.log.Error("Failed to query running machines for auction constraints", slog.Any("error", err))
+			return false
 		}
 
-		for _, workloadType := range req.WorkloadTypes {
-			if !slices.Contains(api.node.config.WorkloadTypes, workloadType) {
-				filter = true
-			}
+		if !satisfiesPlacement(req, machines) {
+			filter = true
 		}
 	}
 
-	if filter {
-		api.log.Debug("Node not viable for deploy request specified at auction")
-		return
+	return !filter
+}
+
+// Evaluates an auction's anti-affinity and spread constraints against the node's running workloads
+func satisfiesPlacement(req *controlapi.AuctionRequest, machines []controlapi.MachineSummary) bool {
+	for _, term := range req.AntiAffinity {
+		if slices.ContainsFunc(machines, term.Matches) {
+			return false
+		}
 	}
 
-	machines, err := api.mgr.RunningWorkloads()
+	if req.Spread != nil && !req.Spread.Allows(machines) {
+		return false
+	}
+
+	return true
+}
+
+func (api *ApiListener) handleDeploy(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
 	if err != nil {
-		api.log.Error("Failed to query running machines", slog.Any("error", err))
-		respondFail(controlapi.AuctionResponseType, m, "Failed to query running machines on node")
+		api.log.Error("Invalid subject for workload deployment", slog.Any("err", err))
+		respondFail(controlapi.RunResponseType, m, "Invalid subject for workload deployment")
 		return
 	}
 
-	res := controlapi.NewEnvelope(controlapi.AuctionResponseType, controlapi.AuctionResponse{
-		NodeId:          api.PublicKey(),
-		Nexus:           api.node.nexus,
-		Version:         Version(),
-		TargetXkey:      api.PublicXKey(),
-		Uptime:          myUptime(now.Sub(api.start)),
-		RunningMachines: len(machines),
-		Tags:            api.node.config.Tags,
+	if api.node.IsLameDuck() {
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionNodeUnavailable, "Node is in lame duck mode. Workload deploy request rejected"))
+		return
+	}
+
+	// redeploys of the node's own workloads keep existing workloads running through a cordon
+	redeploy := verifyRedeploy(api.mgr.kp, m.Msg, time.Now())
+	if api.node.IsCordoned() && !redeploy {
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionNodeUnavailable, "Node is cordoned. Workload deploy request rejected"))
+		return
+	}
+
+	var request controlapi.DeployRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize deploy request", slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionValidationFailed, fmt.Sprintf("Unable to deserialize deploy request: %s", err)))
+		return
+	}
+
+	err = api.admitToNamespace(namespace, &request)
+	if err != nil {
+		api.log.Warn("Rejected deploy request by namespace", slog.String("namespace", namespace), slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionValidationFailed, fmt.Sprintf("Workload deploy request rejected: %s", err)))
+		return
+	}
+
+	if !slices.Contains(api.node.config.WorkloadTypes, request.WorkloadType) {
+		api.log.Error("This node does not support the given workload type", slog.String("workload_type", string(request.WorkloadType)))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionUnsupportedType, fmt.Sprintf("Unsupported workload type on this node: %s", string(request.WorkloadType))))
+		return
+	}
+
+	extension := api.node.config.ProviderExtension(request.WorkloadType)
+	if len(request.TriggerSubjects) > 0 && (request.WorkloadType != controlapi.NexWorkloadV8 &&
+		request.WorkloadType != controlapi.NexWorkloadWasm &&
+		request.WorkloadType != controlapi.NexWorkloadJVM &&
+		request.WorkloadType != controlapi.NexWorkloadNative &&
+		(extension == nil || !extension.SupportsTriggers)) { // FIXME -- workload type comparison
+		api.log.Error("Workload type does not support trigger subject registration", slog.String("trigger_subjects", string(request.WorkloadType)))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionUnsupportedType, fmt.Sprintf("Unsupported workload type for trigger subject registration: %s", string(request.WorkloadType))))
+		return
+	}
+
+	location := request.LocationForArch(api.node.config.Tags[controlapi.TagArch])
+	if location == nil {
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionUnsupportedType, fmt.Sprintf("No workload artifact provided for architecture %s", api.node.config.Tags[controlapi.TagArch])))
+		return
+	}
+	request.Location = location
+
+	if request.Input != nil && request.WorkloadType != controlapi.NexWorkloadNative && request.WorkloadType != controlapi.NexWorkloadJob {
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionUnsupportedType, fmt.Sprintf("Unsupported workload type for input payload: %s", string(request.WorkloadType))))
+		return
+	}
+
+	// without a sandbox, input paths and mounts would be written to the node's own filesystem
+	if request.Input != nil && request.Input.Path != "" && api.node.config.NoSandbox {
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionUnsupportedType, "Input paths are not supported in no sandbox mode"))
+		return
+	}
+
+	if request.GPUs > 0 && !api.node.config.NoSandbox {
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionUnsupportedType, "GPU workloads require a no sandbox node; Firecracker does not support GPU passthrough"))
+		return
+	}
+
+	if len(request.Mounts) > 0 && api.node.config.NoSandbox {
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionUnsupportedType, "Workload mounts are not supported in no sandbox mode"))
+		return
+	}
+
+	if request.Volume != nil && api.mgr.volumes == nil {
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionUnsupportedType, "Volumes are not enabled on this node"))
+		return
+	}
+
+	if request.Volume != nil && request.Volume.Path == "" && !api.node.config.NoSandbox {
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionValidationFailed, "A volume path is required to mount the volume in the sandbox"))
+		return
+	}
+
+	if request.Resources != nil && !api.node.config.NoSandbox && api.node.config.MachineTemplate.MemSizeMib != nil &&
+		request.Resources.MemoryMib > *api.node.config.MachineTemplate.MemSizeMib {
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionResourceExhausted, fmt.Sprintf("Requested memory limit of %d MiB exceeds machine memory of %d MiB",
+			request.Resources.MemoryMib, *api.node.config.MachineTemplate.MemSizeMib)))
+		return
+	}
+
+	err = api.xkeys.decrypt(&request)
+	if err != nil {
+		api.log.Error("Failed to decrypt environment for deploy request", slog.String("public_key", api.PublicXKey()), slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionValidationFailed, fmt.Sprintf("Failed to decrypt environment for deploy request: %s", err)))
+		return
+	}
+	api.applyNamespaceEnvironment(namespace, &request)
+
+	decodedClaims, err := request.Validate()
+	if err != nil {
+		api.log.Error("Invalid deploy request", slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionValidationFailed, fmt.Sprintf("Invalid deploy request: %s", err)))
+		return
+	}
+
+	request.DecodedClaims = *decodedClaims
+	if !validateIssuer(request.DecodedClaims.Issuer, api.node.config.ValidIssuers) {
+		err := fmt.Errorf("invalid workload issuer: %s", request.DecodedClaims.Issuer)
+		api.log.Error("Workload validation failed", slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionValidationFailed, fmt.Sprintf("%s", err)))
+		return
+	}
+
+	if authErr := api.authorize(m, request.DecodedClaims.Issuer, namespace, controlapi.OperationDeploy); authErr != nil {
+		respondUnauthorized(controlapi.RunResponseType, m, authErr)
+		return
+	}
+
+	queueable := api.queue != nil && request.Queueable != nil && *request.Queueable
+
+	// only redeploys verified as made by the node itself replace workloads already using its resources
+	if api.node.UnderPressure() && !redeploy {
+		if queueable {
+			api.enqueueDeploy(m, namespace, &request, "node is under pressure")
+			return
+		}
+
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionResourceExhausted, "Node is under pressure. Workload deploy request rejected"))
+		return
+	}
+
+	err = api.mgr.EnsureCapacity()
+	if err != nil {
+		if queueable {
+			api.enqueueDeploy(m, namespace, &request, fmt.Sprintf("node is at capacity (%s)", err))
+			return
+		}
+
+		api.log.Warn("Rejected deploy request exceeding node capacity", slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionResourceExhausted, fmt.Sprintf("Node is at capacity: %s", err)))
+		return
+	}
+
+	agentClient, err := api.mgr.ReserveAgent(request.WorkloadType, request.Resources)
+	if err != nil {
+		if queueable {
+			api.enqueueDeploy(m, namespace, &request, "no available agent client in pool")
+			return
+		}
+
+		api.log.Error("Failed to get agent client from pool", slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionResourceExhausted, fmt.Sprintf("Failed to get agent client from pool: %s", err)))
+		return
+	}
+
+	// the agent is reserved, so the deployment can proceed alongside those that follow
+	m.audit.hold()
+	go func() {
+		defer m.audit.release()
+		api.deployToAgent(m, namespace, &request, agentClient)
+	}()
+}
+
+// Submits a validated deploy request to the given agent and responds to the requester
+func (api *ApiListener) deployToAgent(m *apiRequest, namespace string, request *controlapi.DeployRequest, agentClient *agentapi.AgentClient) {
+	workloadID := agentClient.ID()
+
+	numBytes, workloadHash, err := api.mgr.CacheWorkload(workloadID, namespace, request)
+	if err != nil {
+		api.mgr.ReleaseAgent(agentClient)
+		api.log.Error("Failed to cache workload bytes", slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionFailed, fmt.Sprintf("Failed to cache workload bytes: %s", err)))
+		return
+	}
+
+	input, err := api.mgr.CacheWorkloadInput(workloadID, request)
+	if err != nil {
+		api.mgr.ReleaseAgent(agentClient)
+		api.log.Error("Failed to cache workload input", slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionFailed, fmt.Sprintf("Failed to cache workload input: %s", err)))
+		return
+	}
+
+	mounts, err := api.mgr.CacheWorkloadMounts(workloadID, request)
+	if err != nil {
+		api.mgr.ReleaseAgent(agentClient)
+		api.log.Error("Failed to cache workload mounts", slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionFailed, fmt.Sprintf("Failed to cache workload mounts: %s", err)))
+		return
+	}
+
+	gpuDevices, err := api.mgr.gpus.allocate(workloadID, request.GPUs)
+	if err != nil {
+		api.mgr.ReleaseAgent(agentClient)
+		api.log.Warn("Rejected deploy request exceeding gpu capacity", slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionResourceExhausted, fmt.Sprintf("Node is at capacity: %s", err)))
+		return
+	}
+
+	var volume *agentapi.WorkloadVolume
+	if request.Volume != nil {
+		hostPath, err := api.mgr.volumes.attach(workloadID, namespace, request.DecodedClaims.Issuer, request.Volume)
+		if err != nil {
+			api.mgr.gpus.release(workloadID)
+			api.mgr.ReleaseAgent(agentClient)
+			api.log.Warn("Failed to attach workload volume", slog.String("volume", request.Volume.Name), slog.Any("err", err))
+			respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionFailed, fmt.Sprintf("Failed to attach volume: %s", err)))
+			return
+		}
+		volume = &agentapi.WorkloadVolume{Name: request.Volume.Name, Path: request.Volume.Path, HostPath: hostPath}
+	}
+
+	deployRequest := &agentapi.DeployRequest{
+		Argv:                  request.Argv,
+		DecodedClaims:         request.DecodedClaims,
+		Description:           request.Description,
+		EncryptedEnvironment:  request.Environment,
+		Environment:           api.node.resolveWorkloadEnvironment(workloadID, namespace, request.WorkloadEnvironment),
+		Essential:             request.Essential,
+		Group:                 request.Group,
+		Hash:                  *workloadHash,
+		JsDomain:              request.JsDomain,
+		Location:              request.Location,
+		Namespace:             &namespace,
+		RetryCount:            request.RetryCount,
+		RetriedAt:             request.RetriedAt,
+		SenderPublicKey:       request.SenderPublicKey,
+		TargetNode:            request.TargetNode,
+		TotalBytes:            int64(numBytes),
+		HostServicesConfig:    request.HostServicesConfig,
+		Resources:             request.Resources,
+		HostServicesBudgets:   request.HostServicesBudgets,
+		StopGracePeriodMillis: request.StopGracePeriodMillis,
+		Labels:                request.Labels,
+		TriggerWorkers:        request.TriggerWorkers,
+		TriggerSubjects:       request.TriggerSubjects,
+		TTLMillis:             request.TTLMillis,
+		Input:                 input,
+		SourceInput:           request.Input,
+		Mounts:                mounts,
+		SourceMounts:          request.Mounts,
+		GPUDevices:            gpuDevices,
+		HotReload:             request.HotReload,
+		Volume:                volume,
+		SourceVolume:          request.Volume,
+		Service:               request.Service,
+		ResultCache:           request.ResultCache,
+		Init:                  request.Init,
+		WorkloadName:          &request.DecodedClaims.Subject,
+		WorkloadType:          request.WorkloadType, // FIXME-- audit all types for string -> *string, and validate...
+		WorkloadJwt:           request.WorkloadJwt,
+	}
+
+	if request.TTLMillis > 0 {
+		expiresAt := time.Now().UTC().Add(time.Duration(request.TTLMillis) * time.Millisecond)
+		deployRequest.ExpiresAt = &expiresAt
+	}
+
+	// no sandbox workloads share the host's devices, so they are told which GPUs are theirs
+	if len(gpuDevices) > 0 {
+		if deployRequest.Environment == nil {
+			deployRequest.Environment = make(map[string]string)
+		}
+		deployRequest.Environment[cudaVisibleDevicesEnv] = visibleDevices(gpuDevices)
+		deployRequest.Environment[nvidiaVisibleDevicesEnv] = visibleDevices(gpuDevices)
+	}
+
+	// likewise, no sandbox workloads are told where their volume is rather than having it mounted
+	if volume != nil && api.node.config.NoSandbox {
+		if deployRequest.Environment == nil {
+			deployRequest.Environment = make(map[string]string)
+		}
+		deployRequest.Environment[volumePathEnv] = volume.HostPath
+		deployRequest.Volume = nil
+	}
+
+	api.node.describeNativeWorkload(deployRequest)
+
+	api.log.
+		Info("Submitting workload to agent",
+			slog.String("namespace", namespace),
+			slog.String("workload", *deployRequest.WorkloadName),
+			slog.Uint64("workload_size", numBytes),
+			slog.String("workload_sha256", *workloadHash),
+			slog.String("type", string(request.WorkloadType)),
+		)
+
+	err = api.mgr.DeployWorkload(agentClient, deployRequest)
+	if err != nil {
+		api.mgr.gpus.release(workloadID)
+		api.mgr.volumes.release(workloadID)
+		api.log.Error("Failed to deploy workload",
+			slog.String("error", err.Error()),
+		)
+		var rejection *controlapi.DeployRejection
+		if errors.As(err, &rejection) {
+			respondRejected(m, controlapi.NewDeployRejection(rejection.Reason, fmt.Sprintf("Failed to deploy workload: %s", err)))
+			return
+		}
+		respondFail(controlapi.RunResponseType, m, fmt.Sprintf("Failed to deploy workload: %s", err))
+		return
+	}
+
+	workloadName := request.DecodedClaims.Subject
+
+	api.log.Info("Workload deployed", slog.String("workload", workloadName), slog.String("workload_id", workloadID))
+
+	res := controlapi.NewEnvelope(controlapi.RunResponseType, controlapi.RunResponse{
+		Started: true,
+		Name:    workloadName,
+		Issuer:  request.DecodedClaims.Issuer,
+		ID:      workloadID, // FIXME-- rename to match
+	}, nil)
+
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to marshal deploy response", slog.Any("err", err))
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// Places a deploy request in the deploy queue and sends the requester its initial queue position
+func (api *ApiListener) enqueueDeploy(m *apiRequest, namespace string, request *controlapi.DeployRequest, reason string) {
+	// the request is audited once it leaves the queue, and once its deployment has finished if dispatched
+	m.audit.hold()
+	queued, err := api.queue.enqueue(namespace, request, m)
+	if err != nil {
+		m.audit.release()
+		api.log.Warn("Failed to queue deploy request", slog.Any("err", err))
+		respondRejected(m, controlapi.NewDeployRejection(controlapi.DeployRejectionResourceExhausted, fmt.Sprintf("Deploy request could not be placed (%s) and %s", reason, err)))
+		return
+	}
+
+	api.log.Info("Queued deploy request until an agent becomes available",
+		slog.String("namespace", namespace),
+		slog.String("reason", reason),
+		slog.String("workload", request.DecodedClaims.Subject),
+		slog.String("queue_id", queued.QueueID),
+		slog.Int("position", queued.Position),
+	)
+
+	respondQueued(m, *queued)
+}
+
+// Runs for the lifetime of the node, handing queued deploy requests to agents as they become
+// ready and failing any requests that have outlived the queue TTL
+func (api *ApiListener) dispatchQueuedDeploys() {
+	ticker := time.NewTicker(deployQueueTickInterval)
+	defer ticker.Stop()
+
+	// agents that became ready while no queued request could be handed to them, retried every tick
+	ready := make([]string, 0)
+
+	for {
+		select {
+		case <-api.node.ctx.Done():
+			for _, entry := range api.queue.drain() {
+				respondRejected(entry.msg, controlapi.NewDeployRejection(controlapi.DeployRejectionNodeUnavailable, "Node is shutting down. Queued deploy request rejected"))
+				entry.msg.audit.release()
+			}
+			return
+		case <-ticker.C:
+			expired := api.queue.expire(time.Now().UTC())
+			for _, entry := range expired {
+				api.log.Warn("Queued deploy request expired before an agent became available",
+					slog.String("namespace", entry.namespace),
+					slog.String("queue_id", entry.id),
+				)
+				respondRejected(entry.msg, controlapi.NewDeployRejection(controlapi.DeployRejectionResourceExhausted, "Timed out waiting in deploy queue for an available agent"))
+				entry.msg.audit.release()
+			}
+			if len(expired) > 0 {
+				api.announceQueuePositions()
+			}
+
+			ready = api.dispatchToReadyAgents(ready)
+		case id := <-api.mgr.readyAgents:
+			api.queue.agentReady(time.Now().UTC())
+			ready = api.dispatchToReadyAgents(append(ready, id))
+		}
+	}
+}
+
+// Hands each of the given ready agents the oldest queued request of a workload type its pool
+// serves. Returns the agents that are still idle, including those nothing was queued for, so
+// that requests queued for them later, or held back by pressure, are dispatched on a later tick
+func (api *ApiListener) dispatchToReadyAgents(ready []string) []string {
+	idle := make([]string, 0, len(ready))
+
+	for i, id := range ready {
+		pool, ok := api.mgr.idleAgentPool(id)
+		if !ok {
+			// claimed by another deploy, or gone
+			continue
+		}
+		idle = append(idle, id)
+
+		entry := api.queue.next(func(entry *queuedDeploy) bool {
+			return api.node.config.AgentPool(entry.request.WorkloadType) == pool
+		})
+		if entry == nil {
+			continue
+		}
+
+		if api.node.IsLameDuck() {
+			if api.queue.take(entry) {
+				respondRejected(entry.msg, controlapi.NewDeployRejection(controlapi.DeployRejectionNodeUnavailable, "Node is in lame duck mode. Queued deploy request rejected"))
+				entry.msg.audit.release()
+				api.announceQueuePositions()
+			}
+			continue
+		}
+
+		if api.node.IsCordoned() {
+			if api.queue.take(entry) {
+				respondRejected(entry.msg, controlapi.NewDeployRejection(controlapi.DeployRejectionNodeUnavailable, "Node is cordoned. Queued deploy request rejected"))
+				entry.msg.audit.release()
+				api.announceQueuePositions()
+			}
+			continue
+		}
+
+		if api.node.UnderPressure() || api.mgr.EnsureCapacity() != nil {
+			// pressure subsides, and capacity is released as workloads stop and their agents are replaced
+			return append(idle, ready[i+1:]...)
+		}
+
+		agentClient, err := api.mgr.ReserveAgent(entry.request.WorkloadType, entry.request.Resources)
+		if err != nil {
+			// the agent was claimed by another deploy before we got to it
+			continue
+		}
+
+		if !api.queue.take(entry) {
+			// cancelled or expired while the agent was being reserved
+			api.mgr.ReleaseAgent(agentClient)
+			continue
+		}
+
+		api.log.Debug("Dispatching queued deploy request",
+			slog.String("queue_id", entry.id),
+			slog.Duration("queued_for", time.Since(entry.enqueuedAt)),
+		)
+
+		// the deployment downloads the workload's artifact, which must not hold up the rest of the queue
+		go func() {
+			defer entry.msg.audit.release()
+			api.deployToAgent(entry.msg, entry.namespace, entry.request, agentClient)
+		}()
+		api.announceQueuePositions()
+	}
+
+	return idle
+}
+
+// Sends every queued requester its current position and estimated wait
+func (api *ApiListener) announceQueuePositions() {
+	for entry, queued := range api.queue.positions() {
+		respondQueued(entry.msg, queued)
+	}
+}
+
+func (api *ApiListener) handleCancelDeploy(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for deploy cancellation", slog.Any("err", err))
+		respondFail(controlapi.CancelDeployResponseType, m, "Invalid subject for deploy cancellation")
+		return
+	}
+
+	if api.queue == nil {
+		respondFail(controlapi.CancelDeployResponseType, m, "Deploy queueing is not enabled on this node")
+		return
+	}
+
+	if authErr := api.authorizeIdentity(m, namespace, controlapi.OperationDeploy); authErr != nil {
+		respondUnauthorized(controlapi.CancelDeployResponseType, m, authErr)
+		return
+	}
+
+	var request controlapi.CancelDeployRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize cancel deploy request", slog.Any("err", err))
+		respondFail(controlapi.CancelDeployResponseType, m, fmt.Sprintf("Unable to deserialize cancel deploy request: %s", err))
+		return
+	}
+
+	entry := api.queue.find(namespace, request.QueueID)
+	if entry == nil {
+		respondFail(controlapi.CancelDeployResponseType, m, "No such queued deploy request")
+		return
+	}
+
+	issuer := requestIssuer(m, namespace)
+	if issuer == "" || (issuer != entry.issuer && !api.isAdmin(issuer)) {
+		respondUnauthorized(controlapi.CancelDeployResponseType, m,
+			controlapi.NewAuthorizationError("queued deploy requests may only be cancelled by their issuer or a node administrator"))
+		return
+	}
+
+	if !api.queue.take(entry) {
+		// dispatched or expired since it was found
+		respondFail(controlapi.CancelDeployResponseType, m, "No such queued deploy request")
+		return
+	}
+
+	api.log.Info("Queued deploy request cancelled",
+		slog.String("namespace", namespace),
+		slog.String("queue_id", request.QueueID),
+		slog.String("issuer", issuer),
+	)
+
+	respondFail(controlapi.RunResponseType, entry.msg, "Queued deploy request cancelled")
+	entry.msg.audit.release()
+	api.announceQueuePositions()
+
+	res := controlapi.NewEnvelope(controlapi.CancelDeployResponseType, controlapi.CancelDeployResponse{
+		Cancelled: true,
+		QueueID:   request.QueueID,
+	}, nil)
+
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to marshal cancel deploy response", slog.Any("err", err))
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+func (api *ApiListener) handleDiscover(m *apiRequest) {
+	var req controlapi.DiscoverRequest
+	if len(m.Data) > 0 {
+		err := json.Unmarshal(m.Data, &req)
+		if err != nil {
+			api.log.Warn("Failed to deserialize discover request", slog.Any("err", err))
+			return
+		}
+	}
+
+	if !api.matchesAuction(&req.AuctionRequest) {
+		return
+	}
+
+	if req.MaxJitterMillis <= 0 {
+		api.handlePing(m)
+		return
+	}
+
+	// spread replies across the jitter window without holding up the subscription
+	m.audit.hold()
+	go func() {
+		defer m.audit.release()
+		delay := time.Duration(rand.Intn(req.MaxJitterMillis)) * time.Millisecond
+		time.Sleep(delay)
+		api.respondPing(m, delay)
+	}()
+}
+
+func (api *ApiListener) handlePing(m *apiRequest) {
+	api.respondPing(m, 0)
+}
+
+// Replies to a ping, reporting how long the reply was held so that clients can tell the round
+// trip to the node apart from the delay
+func (api *ApiListener) respondPing(m *apiRequest, delay time.Duration) {
+	now := time.Now().UTC()
+
+	machines, err := api.mgr.RunningWorkloads()
+	if err != nil {
+		api.log.Error("Failed to query running machines", slog.Any("error", err))
+		respondFail(controlapi.PingResponseType, m, "Failed to query running machines on node")
+		return
+	}
+
+	res := controlapi.NewEnvelope(controlapi.PingResponseType, controlapi.PingResponse{
+		NodeId:          api.PublicKey(),
+		Nexus:           api.node.nexus,
+		Version:         Version(),
+		TargetXkey:      api.PublicXKey(),
+		Uptime:          myUptime(now.Sub(api.start)),
+		RunningMachines: len(machines),
+		Tags:            api.node.config.Tags,
+		Capacity:        api.mgr.Capacity(),
+
+		ReplyDelayMillis: delay.Milliseconds(),
+	}, nil)
+
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to marshal ping response", slog.Any("err", err))
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+func (api *ApiListener) handleStop(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for workload stop", slog.Any("err", err))
+		respondFail(controlapi.StopResponseType, m, "Invalid subject for workload stop")
+		return
+	}
+
+	var request controlapi.StopRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize stop request", slog.Any("err", err))
+		respondFail(controlapi.StopResponseType, m, fmt.Sprintf("Unable to deserialize stop request: %s", err))
+		return
+	}
+
+	deployRequest, _ := api.mgr.LookupWorkload(request.WorkloadId)
+	if deployRequest == nil {
+		api.log.Error("Stop request: no such workload", slog.String("workload_id", request.WorkloadId))
+		respondFail(controlapi.StopResponseType, m, "No such workload")
+		return
+	}
+
+	if *deployRequest.Namespace != namespace {
+		api.log.Error("Namespace mismatch on workload stop request",
+			slog.String("namespace", *deployRequest.Namespace),
+			slog.String("targetnamespace", namespace),
+		)
+
+		respondFail(controlapi.StopResponseType, m, "No such workload") // do not expose ID existence to avoid existence probes
+		return
+	}
+
+	err = request.Validate(&deployRequest.DecodedClaims, api.node.config.AdminKeys...)
+	if err != nil {
+		api.log.Warn("Rejected unauthorized stop request",
+			slog.String("workload_id", request.WorkloadId),
+			slog.Any("err", err),
+		)
+		var authErr *controlapi.AuthorizationError
+		if errors.As(err, &authErr) {
+			respondUnauthorized(controlapi.StopResponseType, m, authErr)
+		} else {
+			respondFail(controlapi.StopResponseType, m, fmt.Sprintf("Invalid stop request: %s", err))
+		}
+		return
+	}
+
+	if authErr := api.authorize(m, claimsIssuer(request.WorkloadJwt), namespace, controlapi.OperationStop); authErr != nil {
+		respondUnauthorized(controlapi.StopResponseType, m, authErr)
+		return
+	}
+
+	err = ignoreAlreadyStopped(api.mgr.StopWorkload(request.WorkloadId, true))
+	if errors.Is(err, ErrWorkloadNotFound) {
+		respondFail(controlapi.StopResponseType, m, "No such workload")
+		return
+	}
+	if err != nil {
+		api.log.Error("Failed to stop workload", slog.Any("err", err))
+		respondFail(controlapi.StopResponseType, m, fmt.Sprintf("Failed to stop workload: %s", err))
+		return
+	}
+
+	res := controlapi.NewEnvelope(controlapi.StopResponseType, controlapi.StopResponse{
+		Stopped: true,
+		Name:    deployRequest.DecodedClaims.Subject,
+		Issuer:  deployRequest.DecodedClaims.Issuer,
+		ID:      request.WorkloadId,
+	}, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to marshal run response", slog.Any("err", err))
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.HOTRELOAD.{namespace}.{node}
+func (api *ApiListener) handleHotReload(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for workload hot reload", slog.Any("err", err))
+		respondFail(controlapi.HotReloadResponseType, m, "Invalid subject for workload hot reload")
+		return
+	}
+
+	var request controlapi.HotReloadRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize hot reload request", slog.Any("err", err))
+		respondFail(controlapi.HotReloadResponseType, m, fmt.Sprintf("Unable to deserialize hot reload request: %s", err))
+		return
+	}
+
+	deployRequest, _ := api.mgr.LookupWorkload(request.WorkloadId)
+	if deployRequest == nil || *deployRequest.Namespace != namespace {
+		// do not expose ID existence to avoid existence probes
+		respondFail(controlapi.HotReloadResponseType, m, "No such workload")
+		return
+	}
+
+	err = request.Validate(&deployRequest.DecodedClaims)
+	if err != nil {
+		api.log.Warn("Rejected unauthorized hot reload request",
+			slog.String("workload_id", request.WorkloadId),
+			slog.Any("err", err),
+		)
+		var authErr *controlapi.AuthorizationError
+		if errors.As(err, &authErr) {
+			respondUnauthorized(controlapi.HotReloadResponseType, m, authErr)
+		} else {
+			respondFail(controlapi.HotReloadResponseType, m, fmt.Sprintf("Invalid hot reload request: %s", err))
+		}
+		return
+	}
+
+	if authErr := api.authorize(m, claimsIssuer(request.WorkloadJwt), namespace, controlapi.OperationDeploy); authErr != nil {
+		respondUnauthorized(controlapi.HotReloadResponseType, m, authErr)
+		return
+	}
+
+	hash, err := api.mgr.HotReloadWorkload(request.WorkloadId, &request)
+	if err != nil {
+		api.log.Error("Failed to hot reload workload", slog.String("workload_id", request.WorkloadId), slog.Any("err", err))
+		respondFail(controlapi.HotReloadResponseType, m, fmt.Sprintf("Failed to hot reload workload: %s", err))
+		return
+	}
+
+	res := controlapi.NewEnvelope(controlapi.HotReloadResponseType, controlapi.HotReloadResponse{
+		Reloaded: true,
+		ID:       request.WorkloadId,
+		Name:     deployRequest.DecodedClaims.Subject,
+		Hash:     hash,
+	}, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to marshal hot reload response", slog.Any("err", err))
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.HISTORY.{namespace}.{node}
+func (api *ApiListener) handleExecutionHistory(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for execution history", slog.Any("err", err))
+		respondFail(controlapi.ExecHistoryResponseType, m, "Invalid subject for execution history")
+		return
+	}
+
+	if authErr := api.authorizeIdentity(m, namespace, controlapi.OperationLogs); authErr != nil {
+		respondUnauthorized(controlapi.ExecHistoryResponseType, m, authErr)
+		return
+	}
+
+	var request controlapi.ExecutionHistoryRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize execution history request", slog.Any("err", err))
+		respondFail(controlapi.ExecHistoryResponseType, m, fmt.Sprintf("Unable to deserialize execution history request: %s", err))
+		return
+	}
+
+	deployRequest, _ := api.mgr.LookupWorkload(request.WorkloadId)
+	if deployRequest == nil || *deployRequest.Namespace != namespace {
+		// do not expose ID existence to avoid existence probes
+		respondFail(controlapi.ExecHistoryResponseType, m, "No such workload")
+		return
+	}
+
+	res := controlapi.NewEnvelope(controlapi.ExecHistoryResponseType, controlapi.ExecutionHistoryResponse{
+		NodeId:     api.PublicKey(),
+		WorkloadId: request.WorkloadId,
+		Executions: api.mgr.history.get(request.WorkloadId, request.Limit),
+	}, nil)
+
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.ExecHistoryResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.SUBZ.{namespace}.{node}
+func (api *ApiListener) handleSubscriptions(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for trigger subscriptions", slog.Any("err", err))
+		respondFail(controlapi.SubzResponseType, m, "Invalid subject for trigger subscriptions")
+		return
+	}
+
+	if authErr := api.authorizeIdentity(m, namespace, controlapi.OperationInfo); authErr != nil {
+		respondUnauthorized(controlapi.SubzResponseType, m, authErr)
+		return
+	}
+
+	res := controlapi.NewEnvelope(controlapi.SubzResponseType, controlapi.TriggerSubscriptionsResponse{
+		NodeId:    api.PublicKey(),
+		Workloads: api.mgr.TriggerSubscriptions(namespace),
+	}, nil)
+
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.SubzResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.QUARANTINE.{namespace}.{node}
+func (api *ApiListener) handleQuarantine(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for quarantine", slog.Any("err", err))
+		respondFail(controlapi.QuarantineResponseType, m, "Invalid subject for quarantine")
+		return
+	}
+
+	var request controlapi.QuarantineRequest
+	if len(m.Data) > 0 {
+		err = json.Unmarshal(m.Data, &request)
+		if err != nil {
+			api.log.Error("Failed to deserialize quarantine request", slog.Any("err", err))
+			respondFail(controlapi.QuarantineResponseType, m, fmt.Sprintf("Unable to deserialize quarantine request: %s", err))
+			return
+		}
+	}
+
+	var op controlapi.Operation
+	switch request.Action {
+	case "", controlapi.QuarantineActionList:
+		op = controlapi.OperationInfo
+	case controlapi.QuarantineActionResume:
+		op = controlapi.OperationDeploy
+	case controlapi.QuarantineActionStop:
+		op = controlapi.OperationStop
+	default:
+		respondFail(controlapi.QuarantineResponseType, m, fmt.Sprintf("Unknown quarantine action: %s", request.Action))
+		return
+	}
+
+	if op == controlapi.OperationInfo {
+		if authErr := api.authorizeIdentity(m, namespace, op); authErr != nil {
+			respondUnauthorized(controlapi.QuarantineResponseType, m, authErr)
+			return
+		}
+	} else {
+		deployRequest, ok := api.mgr.LookupQuarantined(namespace, request.WorkloadId)
+		if !ok {
+			respondFail(controlapi.QuarantineResponseType, m, "No such quarantined workload")
+			return
+		}
+
+		err = request.Validate(&deployRequest.DecodedClaims, api.node.config.AdminKeys...)
+		if err != nil {
+			api.log.Warn("Rejected unauthorized quarantine request",
+				slog.String("workload_id", request.WorkloadId),
+				slog.String("action", request.Action),
+				slog.Any("err", err),
+			)
+			var authErr *controlapi.AuthorizationError
+			if errors.As(err, &authErr) {
+				respondUnauthorized(controlapi.QuarantineResponseType, m, authErr)
+			} else {
+				respondFail(controlapi.QuarantineResponseType, m, fmt.Sprintf("Invalid quarantine request: %s", err))
+			}
+			return
+		}
+
+		if authErr := api.authorize(m, claimsIssuer(request.WorkloadJwt), namespace, op); authErr != nil {
+			respondUnauthorized(controlapi.QuarantineResponseType, m, authErr)
+			return
+		}
+	}
+
+	response := controlapi.QuarantineResponse{NodeId: api.PublicKey()}
+	switch op {
+	case controlapi.OperationInfo:
+		response.Workloads = api.mgr.QuarantinedWorkloads(namespace)
+	default:
+		var workload *controlapi.QuarantinedWorkload
+		if op == controlapi.OperationDeploy {
+			workload, err = api.mgr.ResumeQuarantined(namespace, request.WorkloadId)
+		} else {
+			workload, err = api.mgr.StopQuarantined(namespace, request.WorkloadId)
+		}
+		if err != nil {
+			api.log.Error("Failed to lift workload quarantine",
+				slog.String("workload_id", request.WorkloadId),
+				slog.String("action", request.Action),
+				slog.Any("err", err),
+			)
+			respondFail(controlapi.QuarantineResponseType, m, fmt.Sprintf("Failed to %s quarantined workload: %s", request.Action, err))
+			return
+		}
+		response.Workloads = []controlapi.QuarantinedWorkload{*workload}
+	}
+
+	res := controlapi.NewEnvelope(controlapi.QuarantineResponseType, response, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.QuarantineResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.USAGE.{namespace}.{node}
+func (api *ApiListener) handleUsage(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for usage", slog.Any("err", err))
+		respondFail(controlapi.UsageResponseType, m, "Invalid subject for usage")
+		return
+	}
+
+	if authErr := api.authorizeIdentity(m, namespace, controlapi.OperationInfo); authErr != nil {
+		respondUnauthorized(controlapi.UsageResponseType, m, authErr)
+		return
+	}
+
+	if api.mgr.metering == nil {
+		respondFail(controlapi.UsageResponseType, m, "Metering is not enabled on this node")
+		return
+	}
+
+	response := controlapi.UsageResponse{
+		NodeId: api.PublicKey(),
+		Since:  api.mgr.metering.since,
+		Usage:  api.mgr.metering.usage(namespace),
+	}
+
+	res := controlapi.NewEnvelope(controlapi.UsageResponseType, response, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.UsageResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.VOLUMES.{namespace}.{node}
+func (api *ApiListener) handleVolumes(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for volumes", slog.Any("err", err))
+		respondFail(controlapi.VolumeResponseType, m, "Invalid subject for volumes")
+		return
+	}
+
+	var request controlapi.VolumeRequest
+	if len(m.Data) > 0 {
+		err = json.Unmarshal(m.Data, &request)
+		if err != nil {
+			api.log.Error("Failed to deserialize volume request", slog.Any("err", err))
+			respondFail(controlapi.VolumeResponseType, m, fmt.Sprintf("Unable to deserialize volume request: %s", err))
+			return
+		}
+	}
+
+	var op controlapi.Operation
+	switch request.Action {
+	case "", controlapi.VolumeActionList:
+		request.Action = controlapi.VolumeActionList
+		op = controlapi.OperationInfo
+	case controlapi.VolumeActionDelete:
+		op = controlapi.OperationStop
+	default:
+		respondFail(controlapi.VolumeResponseType, m, fmt.Sprintf("Unknown volume action: %s", request.Action))
+		return
+	}
+
+	if op == controlapi.OperationInfo {
+		if authErr := api.authorizeIdentity(m, namespace, op); authErr != nil {
+			respondUnauthorized(controlapi.VolumeResponseType, m, authErr)
+			return
+		}
+	} else {
+		owner, err := api.mgr.volumes.owner(namespace, request.Name)
+		if err != nil {
+			respondFail(controlapi.VolumeResponseType, m, fmt.Sprintf("Failed to delete volume: %s", err))
+			return
+		}
+
+		err = request.Validate(owner, api.node.config.AdminKeys...)
+		if err != nil {
+			api.log.Warn("Rejected unauthorized volume deletion",
+				slog.String("volume", request.Name),
+				slog.Any("err", err),
+			)
+			var authErr *controlapi.AuthorizationError
+			if errors.As(err, &authErr) {
+				respondUnauthorized(controlapi.VolumeResponseType, m, authErr)
+			} else {
+				respondFail(controlapi.VolumeResponseType, m, fmt.Sprintf("Invalid volume request: %s", err))
+			}
+			return
+		}
+
+		if authErr := api.authorize(m, claimsIssuer(request.WorkloadJwt), namespace, op); authErr != nil {
+			respondUnauthorized(controlapi.VolumeResponseType, m, authErr)
+			return
+		}
+	}
+
+	response := controlapi.VolumeResponse{NodeId: api.PublicKey()}
+	if op == controlapi.OperationInfo {
+		response.Volumes, err = api.mgr.volumes.list(namespace)
+	} else {
+		var volume *controlapi.VolumeInfo
+		volume, err = api.mgr.volumes.remove(namespace, request.Name)
+		if err == nil {
+			response.Volumes = []controlapi.VolumeInfo{*volume}
+			api.log.Info("Deleted workload volume", slog.String("namespace", namespace), slog.String("volume", request.Name))
+		}
+	}
+	if err != nil {
+		api.log.Error("Failed to manage workload volumes",
+			slog.String("volume", request.Name),
+			slog.String("action", request.Action),
+			slog.Any("err", err),
+		)
+		respondFail(controlapi.VolumeResponseType, m, fmt.Sprintf("Failed to %s volumes: %s", request.Action, err))
+		return
+	}
+
+	res := controlapi.NewEnvelope(controlapi.VolumeResponseType, response, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.VolumeResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.CHECKPOINT.{namespace}.{node}
+func (api *ApiListener) handleCheckpoint(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for checkpoint", slog.Any("err", err))
+		respondFail(controlapi.CheckpointResponseType, m, "Invalid subject for checkpoint")
+		return
+	}
+
+	var request controlapi.CheckpointRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize checkpoint request", slog.Any("err", err))
+		respondFail(controlapi.CheckpointResponseType, m, fmt.Sprintf("Unable to deserialize checkpoint request: %s", err))
+		return
+	}
+
+	// do not expose ID existence across namespaces to avoid existence probes
+	deployRequest, _ := api.mgr.LookupWorkload(request.WorkloadId)
+	if deployRequest == nil || *deployRequest.Namespace != namespace {
+		respondFail(controlapi.CheckpointResponseType, m, "No such workload")
+		return
+	}
+
+	err = request.Validate(&deployRequest.DecodedClaims, api.node.config.AdminKeys...)
+	if err != nil {
+		api.log.Warn("Rejected unauthorized checkpoint request",
+			slog.String("workload_id", request.WorkloadId),
+			slog.Any("err", err),
+		)
+		var authErr *controlapi.AuthorizationError
+		if errors.As(err, &authErr) {
+			respondUnauthorized(controlapi.CheckpointResponseType, m, authErr)
+		} else {
+			respondFail(controlapi.CheckpointResponseType, m, fmt.Sprintf("Invalid checkpoint request: %s", err))
+		}
+		return
+	}
+
+	// a checkpoint that stops the workload is as much a stop as it is a deployment elsewhere
+	op := controlapi.OperationDeploy
+	if request.Stop {
+		op = controlapi.OperationStop
+	}
+	if authErr := api.authorize(m, claimsIssuer(request.WorkloadJwt), namespace, op); authErr != nil {
+		respondUnauthorized(controlapi.CheckpointResponseType, m, authErr)
+		return
+	}
+
+	if api.node.config.Checkpoints == nil {
+		respondFail(controlapi.CheckpointResponseType, m, "Checkpoints are not enabled on this node")
+		return
+	}
+
+	checkpoint, err := api.node.CheckpointWorkload(namespace, request.WorkloadId, request.Stop)
+	if err != nil {
+		api.log.Error("Failed to checkpoint workload", slog.String("workload_id", request.WorkloadId), slog.Any("err", err))
+		respondFail(controlapi.CheckpointResponseType, m, fmt.Sprintf("Failed to checkpoint workload: %s", err))
+		return
+	}
+
+	response := controlapi.CheckpointResponse{
+		NodeId:      api.PublicKey(),
+		Checkpoints: []controlapi.CheckpointInfo{*checkpoint},
+	}
+
+	res := controlapi.NewEnvelope(controlapi.CheckpointResponseType, response, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.CheckpointResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.CHECKPOINTS.{namespace}.{node}
+func (api *ApiListener) handleCheckpoints(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for checkpoints", slog.Any("err", err))
+		respondFail(controlapi.CheckpointResponseType, m, "Invalid subject for checkpoints")
+		return
+	}
+
+	var request controlapi.CheckpointListRequest
+	if len(m.Data) > 0 {
+		err = json.Unmarshal(m.Data, &request)
+		if err != nil {
+			api.log.Error("Failed to deserialize checkpoint list request", slog.Any("err", err))
+			respondFail(controlapi.CheckpointResponseType, m, fmt.Sprintf("Unable to deserialize checkpoint list request: %s", err))
+			return
+		}
+	}
+
+	if authErr := api.authorizeIdentity(m, namespace, controlapi.OperationInfo); authErr != nil {
+		respondUnauthorized(controlapi.CheckpointResponseType, m, authErr)
+		return
+	}
+
+	if api.node.config.Checkpoints == nil {
+		respondFail(controlapi.CheckpointResponseType, m, "Checkpoints are not enabled on this node")
+		return
+	}
+
+	checkpoints, err := api.node.ListCheckpoints(namespace, request.WorkloadId)
+	if err != nil {
+		api.log.Error("Failed to list checkpoints", slog.Any("err", err))
+		respondFail(controlapi.CheckpointResponseType, m, fmt.Sprintf("Failed to list checkpoints: %s", err))
+		return
+	}
+
+	response := controlapi.CheckpointResponse{
+		NodeId:      api.PublicKey(),
+		Checkpoints: checkpoints,
+	}
+
+	res := controlapi.NewEnvelope(controlapi.CheckpointResponseType, response, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.CheckpointResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.RESTORE.{namespace}.{node}
+func (api *ApiListener) handleRestore(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for restore", slog.Any("err", err))
+		respondFail(controlapi.RestoreResponseType, m, "Invalid subject for restore")
+		return
+	}
+
+	var request controlapi.RestoreRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize restore request", slog.Any("err", err))
+		respondFail(controlapi.RestoreResponseType, m, fmt.Sprintf("Unable to deserialize restore request: %s", err))
+		return
+	}
+
+	if authErr := api.authorize(m, claimsIssuer(request.WorkloadJwt), namespace, controlapi.OperationDeploy); authErr != nil {
+		respondUnauthorized(controlapi.RestoreResponseType, m, authErr)
+		return
+	}
+
+	if api.node.config.Checkpoints == nil {
+		respondFail(controlapi.RestoreResponseType, m, "Checkpoints are not enabled on this node")
+		return
+	}
+
+	if api.node.IsLameDuck() {
+		respondFail(controlapi.RestoreResponseType, m, "Node is in lame duck mode. Workload restore request rejected")
+		return
+	}
+
+	if api.node.IsCordoned() {
+		respondFail(controlapi.RestoreResponseType, m, "Node is cordoned. Workload restore request rejected")
+		return
+	}
+
+	checkpoint, err := api.node.RestoreWorkload(namespace, request.CheckpointId, func(claims *jwt.GenericClaims) error {
+		return request.Validate(claims, api.node.config.AdminKeys...)
+	})
+	var authErr *controlapi.AuthorizationError
+	if errors.As(err, &authErr) {
+		api.log.Warn("Rejected unauthorized restore request", slog.String("checkpoint_id", request.CheckpointId), slog.Any("err", err))
+		respondUnauthorized(controlapi.RestoreResponseType, m, authErr)
+		return
+	}
+	if err != nil {
+		api.log.Error("Failed to restore workload", slog.String("checkpoint_id", request.CheckpointId), slog.Any("err", err))
+		respondFail(controlapi.RestoreResponseType, m, fmt.Sprintf("Failed to restore workload: %s", err))
+		return
+	}
+
+	response := controlapi.RestoreResponse{
+		NodeId:     api.PublicKey(),
+		Checkpoint: *checkpoint,
+	}
+
+	res := controlapi.NewEnvelope(controlapi.RestoreResponseType, response, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.RestoreResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.NAMESPACE.{namespace}
+func (api *ApiListener) handleNamespace(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for namespace request", slog.Any("err", err))
+		respondFail(controlapi.NamespaceResponseType, m, "Invalid subject for namespace request")
+		return
+	}
+
+	var request controlapi.NamespaceRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize namespace request", slog.Any("err", err))
+		respondFail(controlapi.NamespaceResponseType, m, fmt.Sprintf("Unable to deserialize namespace request: %s", err))
+		return
+	}
+
+	err = request.Validate(namespace)
+	if err != nil {
+		respondFail(controlapi.NamespaceResponseType, m, fmt.Sprintf("Invalid namespace request: %s", err))
+		return
+	}
+
+	var authErr *controlapi.AuthorizationError
+	switch request.Action {
+	case controlapi.NamespaceActionCreate:
+		authErr = api.authorizeIdentity(m, namespace, controlapi.OperationDeploy)
+	case controlapi.NamespaceActionDelete:
+		authErr = api.authorizeNamespaceDeletion(m, namespace, request.DeletionPolicy)
+	default:
+		authErr = api.authorizeIdentity(m, namespace, controlapi.OperationInfo)
+	}
+	if authErr != nil {
+		respondUnauthorized(controlapi.NamespaceResponseType, m, authErr)
+		return
+	}
+
+	response := controlapi.NamespaceResponse{NodeId: api.PublicKey()}
+	switch request.Action {
+	case controlapi.NamespaceActionCreate:
+		ns, err := api.namespaces.create(*request.Namespace)
+		if err != nil {
+			respondFail(controlapi.NamespaceResponseType, m, fmt.Sprintf("Failed to create namespace: %s", err))
+			return
+		}
+
+		api.log.Info("Created namespace", slog.String("namespace", namespace))
+		api.publishNamespaceEvent(events.NamespaceCreated, *ns)
+		response.Namespaces = []controlapi.Namespace{*ns}
+	case controlapi.NamespaceActionDelete:
+		ns, err := api.namespaces.delete(namespace, request.DeletionPolicy)
+		if err != nil {
+			respondFail(controlapi.NamespaceResponseType, m, fmt.Sprintf("Failed to delete namespace: %s", err))
+			return
+		}
+
+		api.log.Info("Deleted namespace", slog.String("namespace", namespace), slog.String("deletion_policy", string(ns.DeletionPolicy)))
+		api.publishNamespaceEvent(events.NamespaceDeleted, *ns)
+		response.Namespaces = []controlapi.Namespace{*ns}
+	case controlapi.NamespaceActionGet:
+		ns, ok := api.namespaces.get(namespace)
+		if !ok || ns.DeletedAt != nil {
+			respondFail(controlapi.NamespaceResponseType, m, fmt.Sprintf("No such namespace: %s", namespace))
+			return
+		}
+		response.Namespaces = []controlapi.Namespace{ns}
+	case controlapi.NamespaceActionList:
+		response.Namespaces = api.namespaces.list()
+	}
+
+	res := controlapi.NewEnvelope(controlapi.NamespaceResponseType, response, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.NamespaceResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.RESOURCES.{namespace}
+func (api *ApiListener) handleResource(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for resource request", slog.Any("err", err))
+		respondFail(controlapi.ResourceResponseType, m, "Invalid subject for resource request")
+		return
+	}
+
+	var request controlapi.ResourceRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize resource request", slog.Any("err", err))
+		respondFail(controlapi.ResourceResponseType, m, fmt.Sprintf("Unable to deserialize resource request: %s", err))
+		return
+	}
+
+	err = request.Validate()
+	if err != nil {
+		respondFail(controlapi.ResourceResponseType, m, fmt.Sprintf("Invalid resource request: %s", err))
+		return
+	}
+
+	op := controlapi.OperationInfo
+	switch request.Action {
+	case controlapi.ResourceActionApply:
+		op = controlapi.OperationDeploy
+	case controlapi.ResourceActionDelete:
+		op = controlapi.OperationStop
+	}
+
+	if authErr := api.authorizeIdentity(m, namespace, op); authErr != nil {
+		respondUnauthorized(controlapi.ResourceResponseType, m, authErr)
+		return
+	}
+
+	response := controlapi.ResourceResponse{NodeId: api.PublicKey()}
+	switch request.Action {
+	case controlapi.ResourceActionApply:
+		resource, err := api.resources.apply(namespace, *request.Resource)
+		if err != nil {
+			respondFail(controlapi.ResourceResponseType, m, fmt.Sprintf("Failed to apply resource: %s", err))
+			return
+		}
+
+		api.log.Info("Applied resource",
+			slog.String("namespace", namespace),
+			slog.String("kind", string(resource.Kind)),
+			slog.String("id", resource.ID),
+			slog.Uint64("version", resource.Version),
+		)
+		response.Resources = []controlapi.Resource{*resource}
+	case controlapi.ResourceActionDelete:
+		resource, err := api.resources.delete(namespace, request.Kind, request.ID, request.Version)
+		if err != nil {
+			respondFail(controlapi.ResourceResponseType, m, fmt.Sprintf("Failed to delete resource: %s", err))
+			return
+		}
+
+		api.log.Info("Deleted resource", slog.String("namespace", namespace), slog.String("kind", string(resource.Kind)), slog.String("id", resource.ID))
+		response.Resources = []controlapi.Resource{*resource}
+	case controlapi.ResourceActionGet:
+		resource, err := api.resources.get(namespace, request.Kind, request.ID)
+		if err != nil {
+			respondFail(controlapi.ResourceResponseType, m, fmt.Sprintf("Failed to read resource: %s", err))
+			return
+		}
+		if resource == nil {
+			respondFail(controlapi.ResourceResponseType, m, fmt.Sprintf("No such resource: %s %s", request.Kind, request.ID))
+			return
+		}
+		response.Resources = []controlapi.Resource{*resource}
+	case controlapi.ResourceActionList:
+		response.Resources, err = api.resources.list(namespace, request.Kind)
+		if err != nil {
+			respondFail(controlapi.ResourceResponseType, m, fmt.Sprintf("Failed to list resources: %s", err))
+			return
+		}
+	}
+
+	res := controlapi.NewEnvelope(controlapi.ResourceResponseType, response, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.ResourceResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.STATE.{namespace}
+func (api *ApiListener) handleNodeState(m *apiRequest) {
+	// every node answers, so unauthorized requests are not answered at all
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil || api.authorizeIdentity(m, namespace, controlapi.OperationInfo) != nil {
+		return
+	}
+
+	state, err := api.nodeState(namespace)
+	if err != nil {
+		api.log.Error("Failed to describe node state", slog.Any("err", err))
+		return
+	}
+
+	raw, err := json.Marshal(controlapi.NewEnvelope(controlapi.NodeStateResponseType, state, nil))
+	if err != nil {
+		api.log.Error("Failed to marshal node state response", slog.Any("err", err))
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.PAUSE.{namespace}.{node}
+func (api *ApiListener) handlePause(m *apiRequest) {
+	var request controlapi.PauseRequest
+	err := json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize pause request", slog.Any("err", err))
+		respondFail(controlapi.PauseResponseType, m, fmt.Sprintf("Unable to deserialize pause request: %s", err))
+		return
+	}
+
+	if !api.authorizePauseRequest(m, request.WorkloadId, request.WorkloadJwt, request.Validate, controlapi.OperationStop) {
+		return
+	}
+
+	response, err := api.mgr.PauseWorkload(request.WorkloadId, request.PauseMachine)
+	if err != nil {
+		api.log.Error("Failed to pause workload", slog.String("workload_id", request.WorkloadId), slog.Any("err", err))
+		respondFail(controlapi.PauseResponseType, m, fmt.Sprintf("Failed to pause workload: %s", err))
+		return
+	}
+
+	respondPause(m, response, api.log)
+}
+
+// $NEX.RESUME.{namespace}.{node}
+func (api *ApiListener) handleResume(m *apiRequest) {
+	var request controlapi.ResumeRequest
+	err := json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize resume request", slog.Any("err", err))
+		respondFail(controlapi.PauseResponseType, m, fmt.Sprintf("Unable to deserialize resume request: %s", err))
+		return
+	}
+
+	if !api.authorizePauseRequest(m, request.WorkloadId, request.WorkloadJwt, request.Validate, controlapi.OperationDeploy) {
+		return
+	}
+
+	response, err := api.mgr.ResumeWorkload(request.WorkloadId)
+	if err != nil {
+		api.log.Error("Failed to resume workload", slog.String("workload_id", request.WorkloadId), slog.Any("err", err))
+		respondFail(controlapi.PauseResponseType, m, fmt.Sprintf("Failed to resume workload: %s", err))
+		return
+	}
+
+	respondPause(m, response, api.log)
+}
+
+// Checks that the workload to pause or resume runs in the namespace of the request's subject, that
+// the request is signed by the workload's issuer or an admin key, and that the signer may perform
+// the operation there, responding with the failure otherwise
+func (api *ApiListener) authorizePauseRequest(m *apiRequest, workloadID string, workloadJwt string, validate func(*jwt.GenericClaims, ...string) error, op controlapi.Operation) bool {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for pause or resume", slog.Any("err", err))
+		respondFail(controlapi.PauseResponseType, m, "Invalid subject for pause or resume")
+		return false
+	}
+
+	// do not expose ID existence across namespaces to avoid existence probes
+	deployRequest, _ := api.mgr.LookupWorkload(workloadID)
+	if deployRequest == nil || *deployRequest.Namespace != namespace {
+		respondFail(controlapi.PauseResponseType, m, "No such workload")
+		return false
+	}
+
+	err = validate(&deployRequest.DecodedClaims, api.node.config.AdminKeys...)
+	if err != nil {
+		api.log.Warn("Rejected unauthorized pause or resume request",
+			slog.String("workload_id", workloadID),
+			slog.Any("err", err),
+		)
+		var authErr *controlapi.AuthorizationError
+		if errors.As(err, &authErr) {
+			respondUnauthorized(controlapi.PauseResponseType, m, authErr)
+		} else {
+			respondFail(controlapi.PauseResponseType, m, fmt.Sprintf("Invalid pause or resume request: %s", err))
+		}
+		return false
+	}
+
+	if authErr := api.authorize(m, claimsIssuer(workloadJwt), namespace, op); authErr != nil {
+		respondUnauthorized(controlapi.PauseResponseType, m, authErr)
+		return false
+	}
+
+	return true
+}
+
+func respondPause(m *apiRequest, response *controlapi.PauseResponse, log *slog.Logger) {
+	res := controlapi.NewEnvelope(controlapi.PauseResponseType, response, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.PauseResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.JOBSTATUS.{namespace}.{node}
+func (api *ApiListener) handleJobStatus(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for job status", slog.Any("err", err))
+		respondFail(controlapi.JobStatusResponseType, m, "Invalid subject for job status")
+		return
+	}
+
+	if authErr := api.authorizeIdentity(m, namespace, controlapi.OperationLogs); authErr != nil {
+		respondUnauthorized(controlapi.JobStatusResponseType, m, authErr)
+		return
+	}
+
+	var request controlapi.JobStatusRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize job status request", slog.Any("err", err))
+		respondFail(controlapi.JobStatusResponseType, m, fmt.Sprintf("Unable to deserialize job status request: %s", err))
+		return
+	}
+
+	status, ok := api.mgr.jobs.get(request.WorkloadId, namespace)
+	if !ok {
+		respondFail(controlapi.JobStatusResponseType, m, "No such job")
+		return
+	}
+	status.NodeId = api.PublicKey()
+
+	res := controlapi.NewEnvelope(controlapi.JobStatusResponseType, status, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.JobStatusResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.BULKSTOP.{namespace}
+func (api *ApiListener) handleBulkStop(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for bulk workload stop", slog.Any("err", err))
+		respondFail(controlapi.BulkStopResponseType, m, "Invalid subject for bulk workload stop")
+		return
+	}
+
+	var request controlapi.BulkStopRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize bulk stop request", slog.Any("err", err))
+		respondFail(controlapi.BulkStopResponseType, m, fmt.Sprintf("Unable to deserialize bulk stop request: %s", err))
+		return
+	}
+
+	machines, err := api.mgr.RunningWorkloads()
+	if err != nil {
+		api.log.Error("Failed to query running machines", slog.Any("error", err))
+		respondFail(controlapi.BulkStopResponseType, m, "Failed to query running machines on node")
+		return
+	}
+
+	matching := make([]controlapi.MachineSummary, 0)
+	for _, machine := range machines {
+		if strings.EqualFold(machine.Namespace, namespace) && request.Matches(machine.Labels) {
+			matching = append(matching, machine)
+		}
+	}
+
+	if len(matching) == 0 {
+		// silence if there were no matching machines
+		return
+	}
+
+	if authErr := api.authorize(m, claimsIssuer(request.WorkloadJwt), namespace, controlapi.OperationStop); authErr != nil {
+		respondUnauthorized(controlapi.BulkStopResponseType, m, authErr)
+		return
+	}
+
+	results := make(map[string]controlapi.BulkStopResult, len(matching))
+	stopping := make([]controlapi.MachineSummary, 0, len(matching))
+
+	// validate up front so that only the stops themselves run concurrently
+	for _, machine := range matching {
+		result := controlapi.BulkStopResult{Name: machine.Workload.Name}
+
+		deployRequest, _ := api.mgr.LookupWorkload(machine.Id)
+		if deployRequest == nil {
+			result.Error = "No such workload"
+			results[machine.Id] = result
+			continue
+		}
+
+		err = request.Validate(namespace, &deployRequest.DecodedClaims)
+		if err != nil {
+			api.log.Error("Failed to validate bulk stop request", slog.String("workload_id", machine.Id), slog.Any("err", err))
+			result.Error = fmt.Sprintf("Invalid stop request: %s", err)
+			results[machine.Id] = result
+			continue
+		}
+
+		stopping = append(stopping, machine)
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, machine := range stopping {
+		wg.Add(1)
+		go func(machine controlapi.MachineSummary) {
+			defer wg.Done()
+
+			result := controlapi.BulkStopResult{Name: machine.Workload.Name, Stopped: true}
+			err := ignoreAlreadyStopped(api.mgr.StopWorkload(machine.Id, true))
+			if err != nil {
+				api.log.Error("Failed to stop workload", slog.String("workload_id", machine.Id), slog.Any("err", err))
+				result = controlapi.BulkStopResult{Name: machine.Workload.Name, Error: fmt.Sprintf("Failed to stop workload: %s", err)}
+			}
+
+			mutex.Lock()
+			results[machine.Id] = result
+			mutex.Unlock()
+		}(machine)
+	}
+	wg.Wait()
+
+	res := controlapi.NewEnvelope(controlapi.BulkStopResponseType, controlapi.BulkStopResponse{
+		NodeId:  api.PublicKey(),
+		Results: results,
+	}, nil)
+
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to marshal bulk stop response", slog.Any("err", err))
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+func (api *ApiListener) handleCutover(m *apiRequest) {
+	namespace, err := extractNamespace(m.Subject)
+	if err != nil {
+		api.log.Error("Invalid subject for trigger subject cutover", slog.Any("err", err))
+		respondFail(controlapi.CutoverResponseType, m, "Invalid subject for trigger subject cutover")
+		return
+	}
+
+	var request controlapi.CutoverRequest
+	err = json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize cutover request", slog.Any("err", err))
+		respondFail(controlapi.CutoverResponseType, m, fmt.Sprintf("Unable to deserialize cutover request: %s", err))
+		return
+	}
+
+	fromRequest, _ := api.mgr.LookupWorkload(request.FromWorkloadId)
+	toRequest, _ := api.mgr.LookupWorkload(request.ToWorkloadId)
+
+	// do not expose ID existence across namespaces to avoid existence probes
+	if fromRequest == nil || toRequest == nil || *fromRequest.Namespace != namespace || *toRequest.Namespace != namespace {
+		api.log.Error("Cutover request: no such workload",
+			slog.String("from_workload_id", request.FromWorkloadId),
+			slog.String("to_workload_id", request.ToWorkloadId),
+		)
+		respondFail(controlapi.CutoverResponseType, m, "No such workload")
+		return
+	}
+
+	if authErr := api.authorize(m, claimsIssuer(request.WorkloadJwt), namespace, controlapi.OperationDeploy); authErr != nil {
+		respondUnauthorized(controlapi.CutoverResponseType, m, authErr)
+		return
+	}
+
+	err = request.Validate(&fromRequest.DecodedClaims, &toRequest.DecodedClaims)
+	if err != nil {
+		api.log.Error("Failed to validate cutover request", slog.Any("err", err))
+		respondFail(controlapi.CutoverResponseType, m, fmt.Sprintf("Invalid cutover request: %s", err))
+		return
+	}
+
+	subjects, err := api.mgr.CutoverTriggers(request.FromWorkloadId, request.ToWorkloadId, request.TriggerSubjects)
+	if err != nil {
+		api.log.Error("Failed to cut over trigger subjects", slog.Any("err", err))
+		respondFail(controlapi.CutoverResponseType, m, fmt.Sprintf("Failed to cut over trigger subjects: %s", err))
+		return
+	}
+
+	res := controlapi.NewEnvelope(controlapi.CutoverResponseType, controlapi.CutoverResponse{
+		FromWorkloadId:  request.FromWorkloadId,
+		ToWorkloadId:    request.ToWorkloadId,
+		TriggerSubjects: subjects,
+	}, nil)
+
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to marshal cutover response", slog.Any("err", err))
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+// $NEX.WPING.{namespace}.{workloadId}
+func (api *ApiListener) handleWorkloadPing(m *apiRequest) {
+	// Note that this ping _only_ responds on success, all others are silent
+	// $NEX.WPING.{namespace}.{workloadId}
+	// result payload looks exactly like a node ping reply
+	tokens := strings.Split(m.Subject, ".")
+
+	namespace := ""
+	workloadId := ""
+
+	if len(tokens) > 2 {
+		namespace = tokens[2]
+	}
+	if len(tokens) > 3 {
+		workloadId = tokens[3]
+	}
+
+	machines, err := api.mgr.RunningWorkloads()
+	if err != nil {
+		api.log.Error("Failed to query running machines", slog.Any("error", err))
+		return
+	}
+
+	filter, err := workloadFilter(m)
+	if err != nil {
+		api.log.Warn("Ignoring workload ping with invalid filter", slog.Any("err", err))
+		return
+	}
+
+	summaries := summarizeMachinesForPing(filterMachines(machines, filter), namespace, workloadId)
+	if len(summaries) > 0 && api.authorizeIdentity(m, namespace, controlapi.OperationInfo) == nil {
+		now := time.Now().UTC()
+		res := controlapi.NewEnvelope(controlapi.PingResponseType, controlapi.WorkloadPingResponse{
+			NodeId:          api.PublicKey(),
+			TargetXkey:      api.PublicXKey(),
+			Version:         Version(),
+			Uptime:          myUptime(now.Sub(api.start)),
+			RunningMachines: summaries,
+			Tags:            api.node.config.Tags,
+		}, nil)
+
+		raw, err := json.Marshal(res)
+		if err != nil {
+			api.log.Error("Failed to marshal ping response", slog.Any("err", err))
+		} else {
+			_ = m.Respond(raw)
+		}
+	}
+
+	// silence if there were no matching machines
+}
+
+// $NEX.GPING.{namespace}.{group}
+func (api *ApiListener) handleGroupPing(m *apiRequest) {
+	// like workload ping, this only responds when the node hosts members of the group
+	namespace, group := extractGroup(m.Subject)
+
+	members, err := api.groupMembers(namespace, group)
+	if err != nil {
+		api.log.Error("Failed to query running machines", slog.Any("error", err))
+		return
+	}
+
+	if len(members) > 0 && api.authorizeIdentity(m, namespace, controlapi.OperationInfo) == nil {
+		api.respondGroup(m, group, members, nil)
+	}
+}
+
+// $NEX.GSTOP.{namespace}.{group}
+func (api *ApiListener) handleGroupStop(m *apiRequest) {
+	api.manageGroup(m, controlapi.OperationStop, func(id string, _ *agentapi.DeployRequest) (string, error) {
+		return id, ignoreAlreadyStopped(api.mgr.StopWorkload(id, true))
+	})
+}
+
+// $NEX.GRESTART.{namespace}.{group}
+func (api *ApiListener) handleGroupRestart(m *apiRequest) {
+	api.manageGroup(m, controlapi.OperationDeploy, func(id string, deployRequest *agentapi.DeployRequest) (string, error) {
+		err := ignoreAlreadyStopped(api.mgr.StopWorkload(id, true))
+		if err != nil {
+			return "", err
+		}
+
+		res, err := api.mgr.RedeployWorkload(deployRequest)
+		if err != nil {
+			return "", err
+		}
+
+		return res.ID, nil
+	})
+}
+
+// Validates a group stop or restart request and applies the given action to each member of the
+// group hosted by this node, responding with the workloads the actions returned
+func (api *ApiListener) manageGroup(m *apiRequest, op controlapi.Operation, action func(id string, deployRequest *agentapi.DeployRequest) (string, error)) {
+	namespace, group := extractGroup(m.Subject)
+
+	var request controlapi.GroupRequest
+	err := json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize group request", slog.Any("err", err))
+		respondFail(controlapi.GroupResponseType, m, fmt.Sprintf("Unable to deserialize group request: %s", err))
+		return
+	}
+
+	if request.Group != group {
+		respondFail(controlapi.GroupResponseType, m, "Group request does not match subject")
+		return
+	}
+
+	members, err := api.groupMembers(namespace, group)
+	if err != nil {
+		api.log.Error("Failed to query running machines", slog.Any("error", err))
+		respondFail(controlapi.GroupResponseType, m, "Failed to query running machines on node")
+		return
+	}
+
+	if len(members) == 0 {
+		// silence if there were no matching machines
+		return
+	}
+
+	if authErr := api.authorize(m, claimsIssuer(request.WorkloadJwt), namespace, op); authErr != nil {
+		respondUnauthorized(controlapi.GroupResponseType, m, authErr)
+		return
+	}
+
+	affected := make([]string, 0, len(members))
+	failures := make(map[string]string)
+	for _, member := range members {
+		deployRequest, _ := api.mgr.LookupWorkload(member.Id)
+		if deployRequest == nil {
+			failures[member.Id] = "No such workload"
+			continue
+		}
+
+		err = request.Validate(&deployRequest.DecodedClaims)
+		if err != nil {
+			api.log.Error("Failed to validate group request", slog.String("workload_id", member.Id), slog.Any("err", err))
+			failures[member.Id] = fmt.Sprintf("Invalid group request: %s", err)
+			continue
+		}
+
+		id, err := action(member.Id, deployRequest)
+		if err != nil {
+			api.log.Error("Failed to manage group member", slog.String("group", group), slog.String("workload_id", member.Id), slog.Any("err", err))
+			failures[member.Id] = err.Error()
+			continue
+		}
+		affected = append(affected, id)
+	}
+
+	running, err := api.mgr.RunningWorkloads()
+	if err != nil {
+		api.log.Error("Failed to query running machines", slog.Any("error", err))
+	}
+
+	// stopped members are no longer running, so fall back to their prior summaries
+	summaries := make([]controlapi.MachineSummary, 0, len(affected))
+	for _, id := range affected {
+		summary, ok := findMachine(running, id)
+		if !ok {
+			summary, ok = findMachine(members, id)
+		}
+		if !ok {
+			summary = controlapi.MachineSummary{Id: id, Namespace: namespace, Group: group}
+		}
+		summaries = append(summaries, summary)
+	}
+
+	api.respondGroup(m, group, summaries, failures)
+}
+
+func (api *ApiListener) groupMembers(namespace, group string) ([]controlapi.MachineSummary, error) {
+	machines, err := api.mgr.RunningWorkloads()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]controlapi.MachineSummary, 0)
+	for _, machine := range machines {
+		if machine.Group == group && strings.EqualFold(machine.Namespace, namespace) {
+			members = append(members, machine)
+		}
+	}
+
+	return members, nil
+}
+
+func (api *ApiListener) respondGroup(m *apiRequest, group string, workloads []controlapi.MachineSummary, failures map[string]string) {
+	if len(failures) == 0 {
+		failures = nil
+	}
+
+	res := controlapi.NewEnvelope(controlapi.GroupResponseType, controlapi.GroupResponse{
+		NodeId:    api.PublicKey(),
+		Group:     group,
+		Workloads: workloads,
+		Failures:  failures,
+	}, nil)
+
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to marshal group response", slog.Any("err", err))
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+func (api *ApiListener) handleLameDuck(m *apiRequest) {
+	err := api.node.EnterLameDuck()
+	if err != nil {
+		api.log.Error("Failed to enter lame duck mode", slog.Any("error", err))
+		respondFail(controlapi.LameDuckResponseType, m, "Failed to enter lame duck mode")
+		return
+	}
+	res := controlapi.NewEnvelope(controlapi.LameDuckResponseType, controlapi.LameDuckResponse{
+		Success: true,
+		NodeId:  api.PublicKey(),
+	}, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.LameDuckResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
+
+func (api *ApiListener) handleCordon(m *apiRequest) {
+	var request controlapi.CordonRequest
+	if len(m.Data) > 0 {
+		err := json.Unmarshal(m.Data, &request)
+		if err != nil {
+			api.log.Error("Failed to deserialize cordon request", slog.Any("err", err))
+			respondFail(controlapi.CordonResponseType, m, fmt.Sprintf("Unable to deserialize cordon request: %s", err))
+			return
+		}
+	}
+
+	status, err := api.node.Cordon(request.Reason, request.Until)
+	if err != nil {
+		api.log.Error("Failed to cordon node", slog.Any("err", err))
+		respondFail(controlapi.CordonResponseType, m, fmt.Sprintf("Failed to cordon node: %s", err))
+		return
+	}
+
+	api.respondCordon(m, status)
+}
+
+func (api *ApiListener) handleUncordon(m *apiRequest) {
+	_ = api.node.Uncordon()
+	api.respondCordon(m, nil)
+}
+
+func (api *ApiListener) respondCordon(m *apiRequest, status *controlapi.CordonStatus) {
+	res := controlapi.NewEnvelope(controlapi.CordonResponseType, controlapi.CordonResponse{
+		NodeId:   api.PublicKey(),
+		Cordoned: status != nil,
+		Cordon:   status,
 	}, nil)
 
 	raw, err := json.Marshal(res)
 	if err != nil {
-		api.log.Error("Failed to marshal ping response", slog.Any("err", err))
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.CordonResponseType, m, "Serialization failure")
 	} else {
 		_ = m.Respond(raw)
 	}
 }
 
-func (api *ApiListener) handleDeploy(m *nats.Msg) {
-	namespace, err := extractNamespace(m.Subject)
+func (api *ApiListener) handleReload(m *apiRequest) {
+	response, err := api.node.ReloadConfig()
 	if err != nil {
-		api.log.Error("Invalid subject for workload deployment", slog.Any("err", err))
-		respondFail(controlapi.RunResponseType, m, "Invalid subject for workload deployment")
+		api.log.Error("Failed to reload node configuration", slog.Any("err", err))
+		respondFail(controlapi.ReloadResponseType, m, fmt.Sprintf("Failed to reload node configuration: %s", err))
 		return
 	}
 
-	if api.node.IsLameDuck() {
-		respondFail(controlapi.RunResponseType, m, "Node is in lame duck mode. Workload deploy request rejected")
-		return
+	res := controlapi.NewEnvelope(controlapi.ReloadResponseType, response, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.ReloadResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
 	}
+}
 
-	var request controlapi.DeployRequest
-	err = json.Unmarshal(m.Data, &request)
+func (api *ApiListener) handleUpdate(m *apiRequest) {
+	var request controlapi.NodeUpdateRequest
+	err := json.Unmarshal(m.Data, &request)
 	if err != nil {
-		api.log.Error("Failed to deserialize deploy request", slog.Any("err", err))
-		respondFail(controlapi.RunResponseType, m, fmt.Sprintf("Unable to deserialize deploy request: %s", err))
+		api.log.Error("Failed to deserialize node update request", slog.Any("err", err))
+		respondFail(controlapi.NodeUpdateResponseType, m, fmt.Sprintf("Unable to deserialize node update request: %s", err))
 		return
 	}
 
-	if !slices.Contains(api.node.config.WorkloadTypes, request.WorkloadType) {
-		api.log.Error("This node does not support the given workload type", slog.String("workload_type", string(request.WorkloadType)))
-		respondFail(controlapi.RunResponseType, m, fmt.Sprintf("Unsupported workload type on this node: %s", string(request.WorkloadType)))
+	if authErr := api.authorizeIdentity(m, systemNamespace, controlapi.OperationUpdate); authErr != nil {
+		respondUnauthorized(controlapi.NodeUpdateResponseType, m, authErr)
 		return
 	}
 
-	if len(request.TriggerSubjects) > 0 && (request.WorkloadType != controlapi.NexWorkloadV8 &&
-		request.WorkloadType != controlapi.NexWorkloadWasm) { // FIXME -- workload type comparison
-		api.log.Error("Workload type does not support trigger subject registration", slog.String("trigger_subjects", string(request.WorkloadType)))
-		respondFail(controlapi.RunResponseType, m, fmt.Sprintf("Unsupported workload type for trigger subject registration: %s", string(request.WorkloadType)))
+	staged, err := api.node.StageUpdate(&request)
+	if err != nil {
+		api.log.Error("Failed to stage node update", slog.String("location", request.Location), slog.Any("err", err))
+		respondFail(controlapi.NodeUpdateResponseType, m, fmt.Sprintf("Failed to stage node update: %s", err))
 		return
 	}
 
-	err = request.DecryptRequestEnvironment(api.xk)
+	res := controlapi.NewEnvelope(controlapi.NodeUpdateResponseType, controlapi.NodeUpdateResponse{
+		NodeId:    api.PublicKey(),
+		Version:   staged.version,
+		Sha256:    staged.sha256,
+		SignedBy:  staged.signedBy,
+		Workloads: len(api.mgr.handoffWorkloads()),
+	}, nil)
+	raw, err := json.Marshal(res)
 	if err != nil {
-		publicKey, _ := api.xk.PublicKey()
-		api.log.Error("Failed to decrypt environment for deploy request", slog.String("public_key", publicKey), slog.Any("err", err))
-		respondFail(controlapi.RunResponseType, m, fmt.Sprintf("Failed to decrypt environment for deploy request: %s", err))
-		return
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.NodeUpdateResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
 	}
 
-	decodedClaims, err := request.Validate()
+	// the requester is answered before the node stops listening
+	go api.node.applyUpdate(staged)
+}
+
+func (api *ApiListener) handleRootfsRollout(m *apiRequest) {
+	var request controlapi.RootfsRolloutRequest
+	err := json.Unmarshal(m.Data, &request)
 	if err != nil {
-		api.log.Error("Invalid deploy request", slog.Any("err", err))
-		respondFail(controlapi.RunResponseType, m, fmt.Sprintf("Invalid deploy request: %s", err))
+		api.log.Error("Failed to deserialize rootfs rollout request", slog.Any("err", err))
+		respondFail(controlapi.RootfsRolloutResponseType, m, fmt.Sprintf("Unable to deserialize rootfs rollout request: %s", err))
 		return
 	}
 
-	request.DecodedClaims = *decodedClaims
-	if !validateIssuer(request.DecodedClaims.Issuer, api.node.config.ValidIssuers) {
-		err := fmt.Errorf("invalid workload issuer: %s", request.DecodedClaims.Issuer)
-		api.log.Error("Workload validation failed", slog.Any("err", err))
-		respondFail(controlapi.RunResponseType, m, fmt.Sprintf("%s", err))
+	status, err := api.node.StartRootfsRollout(&request)
+	if err != nil {
+		api.log.Error("Failed to start rootfs rollout", slog.String("location", request.Location), slog.Any("err", err))
+		respondFail(controlapi.RootfsRolloutResponseType, m, fmt.Sprintf("Failed to start rootfs rollout: %s", err))
 		return
 	}
 
-	agentClient, err := api.mgr.SelectRandomAgent()
+	api.respondRootfsRollout(m, status)
+}
+
+func (api *ApiListener) handleRootfsRolloutStatus(m *apiRequest) {
+	status, err := api.node.RootfsRolloutStatus()
 	if err != nil {
-		api.log.Error("Failed to get agent client from pool", slog.Any("err", err))
-		respondFail(controlapi.RunResponseType, m, fmt.Sprintf("Failed to get agent client from pool: %s", err))
+		respondFail(controlapi.RootfsRolloutResponseType, m, err.Error())
 		return
 	}
 
-	workloadID := agentClient.ID()
+	api.respondRootfsRollout(m, status)
+}
 
-	numBytes, workloadHash, err := api.mgr.CacheWorkload(workloadID, &request)
+func (api *ApiListener) handleRootfsRolloutAbort(m *apiRequest) {
+	status, err := api.node.AbortRootfsRollout()
 	if err != nil {
-		api.log.Error("Failed to cache workload bytes", slog.Any("err", err))
-		respondFail(controlapi.RunResponseType, m, fmt.Sprintf("Failed to cache workload bytes: %s", err))
+		respondFail(controlapi.RootfsRolloutResponseType, m, fmt.Sprintf("Failed to abort rootfs rollout: %s", err))
 		return
 	}
 
-	deployRequest := &agentapi.DeployRequest{
-		Argv:                 request.Argv,
-		DecodedClaims:        request.DecodedClaims,
-		Description:          request.Description,
-		EncryptedEnvironment: request.Environment,
-		Environment:          request.WorkloadEnvironment,
-		Essential:            request.Essential,
-		Hash:                 *workloadHash,
-		JsDomain:             request.JsDomain,
-		Location:             request.Location,
-		Namespace:            &namespace,
-		RetryCount:           request.RetryCount,
-		RetriedAt:            request.RetriedAt,
-		SenderPublicKey:      request.SenderPublicKey,
-		TargetNode:           request.TargetNode,
-		TotalBytes:           int64(numBytes),
-		HostServicesConfig:   request.HostServicesConfig,
-		TriggerSubjects:      request.TriggerSubjects,
-		WorkloadName:         &request.DecodedClaims.Subject,
-		WorkloadType:         request.WorkloadType, // FIXME-- audit all types for string -> *string, and validate...
-		WorkloadJwt:          request.WorkloadJwt,
-	}
-
-	api.log.
-		Info("Submitting workload to agent",
-			slog.String("namespace", namespace),
-			slog.String("workload", *deployRequest.WorkloadName),
-			slog.Uint64("workload_size", numBytes),
-			slog.String("workload_sha256", *workloadHash),
-			slog.String("type", string(request.WorkloadType)),
-		)
+	api.respondRootfsRollout(m, status)
+}
 
-	err = api.mgr.DeployWorkload(agentClient, deployRequest)
+func (api *ApiListener) respondRootfsRollout(m *apiRequest, status *controlapi.RootfsRolloutStatus) {
+	res := controlapi.NewEnvelope(controlapi.RootfsRolloutResponseType, status, nil)
+	raw, err := json.Marshal(res)
 	if err != nil {
-		api.log.Error("Failed to deploy workload",
-			slog.String("error", err.Error()),
-		)
-		respondFail(controlapi.RunResponseType, m, fmt.Sprintf("Failed to deploy workload: %s", err))
-		return
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.RootfsRolloutResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
 	}
+}
 
-	if _, ok := api.mgr.handshakes[workloadID]; !ok {
-		api.log.Error("Attempted to deploy workload into bad process (no handshake)",
-			slog.String("workload_id", workloadID),
-		)
-		respondFail(controlapi.RunResponseType, m, "Could not deploy workload, agent pool did not initialize properly")
+func (api *ApiListener) handleEvacuate(m *apiRequest) {
+	var request controlapi.EvacuateRequest
+	err := json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize evacuate request", slog.Any("err", err))
+		respondFail(controlapi.EvacuateResponseType, m, fmt.Sprintf("Unable to deserialize evacuate request: %s", err))
 		return
 	}
-	workloadName := request.DecodedClaims.Subject
 
-	api.log.Info("Workload deployed", slog.String("workload", workloadName), slog.String("workload_id", workloadID))
+	reason := request.Reason
+	if reason == "" {
+		reason = "requested through the control API"
+	}
 
-	res := controlapi.NewEnvelope(controlapi.RunResponseType, controlapi.RunResponse{
-		Started: true,
-		Name:    workloadName,
-		Issuer:  request.DecodedClaims.Issuer,
-		ID:      workloadID, // FIXME-- rename to match
-	}, nil)
+	status, err := api.node.Evacuate(reason, request.Deadline)
+	if err != nil {
+		api.log.Error("Failed to evacuate node", slog.Any("err", err))
+		respondFail(controlapi.EvacuateResponseType, m, fmt.Sprintf("Failed to evacuate node: %s", err))
+		return
+	}
 
+	res := controlapi.NewEnvelope(controlapi.EvacuateResponseType, status, nil)
 	raw, err := json.Marshal(res)
 	if err != nil {
-		api.log.Error("Failed to marshal deploy response", slog.Any("err", err))
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.EvacuateResponseType, m, "Serialization failure")
 	} else {
 		_ = m.Respond(raw)
 	}
 }
 
-func (api *ApiListener) handlePing(m *nats.Msg) {
-	now := time.Now().UTC()
+func (api *ApiListener) handleRotateKeys(m *apiRequest) {
+	var request controlapi.RotateKeysRequest
+	if len(m.Data) > 0 {
+		err := json.Unmarshal(m.Data, &request)
+		if err != nil {
+			api.log.Error("Failed to deserialize rotate keys request", slog.Any("err", err))
+			respondFail(controlapi.RotateKeysResponseType, m, fmt.Sprintf("Unable to deserialize rotate keys request: %s", err))
+			return
+		}
+	}
 
-	machines, err := api.mgr.RunningWorkloads()
-	if err != nil {
-		api.log.Error("Failed to query running machines", slog.Any("error", err))
-		respondFail(controlapi.PingResponseType, m, "Failed to query running machines on node")
+	if authErr := api.authorizeIdentity(m, systemNamespace, controlapi.OperationUpdate); authErr != nil {
+		respondUnauthorized(controlapi.RotateKeysResponseType, m, authErr)
 		return
 	}
 
-	res := controlapi.NewEnvelope(controlapi.PingResponseType, controlapi.PingResponse{
-		NodeId:          api.PublicKey(),
-		Nexus:           api.node.nexus,
-		Version:         Version(),
-		TargetXkey:      api.PublicXKey(),
-		Uptime:          myUptime(now.Sub(api.start)),
-		RunningMachines: len(machines),
-		Tags:            api.node.config.Tags,
-	}, nil)
+	response, err := api.node.RotateKeys(request.Grace)
+	if err != nil {
+		api.log.Error("Failed to rotate node keys", slog.Any("err", err))
+		respondFail(controlapi.RotateKeysResponseType, m, fmt.Sprintf("Failed to rotate node keys: %s", err))
+		return
+	}
 
+	res := controlapi.NewEnvelope(controlapi.RotateKeysResponseType, response, nil)
 	raw, err := json.Marshal(res)
 	if err != nil {
-		api.log.Error("Failed to marshal ping response", slog.Any("err", err))
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.RotateKeysResponseType, m, "Serialization failure")
 	} else {
 		_ = m.Respond(raw)
 	}
 }
 
-func (api *ApiListener) handleStop(m *nats.Msg) {
-	namespace, err := extractNamespace(m.Subject)
-	if err != nil {
-		api.log.Error("Invalid subject for workload stop", slog.Any("err", err))
-		respondFail(controlapi.StopResponseType, m, "Invalid subject for workload stop")
-		return
+// $NEX.DIAGNOSTICS.{node}
+func (api *ApiListener) handleDiagnostics(m *apiRequest) {
+	var request controlapi.DiagnosticsRequest
+	if len(m.Data) > 0 {
+		err := json.Unmarshal(m.Data, &request)
+		if err != nil {
+			api.log.Error("Failed to deserialize diagnostics request", slog.Any("err", err))
+			respondFail(controlapi.DiagnosticsResponseType, m, fmt.Sprintf("Unable to deserialize diagnostics request: %s", err))
+			return
+		}
 	}
 
-	var request controlapi.StopRequest
-	err = json.Unmarshal(m.Data, &request)
-	if err != nil {
-		api.log.Error("Failed to deserialize stop request", slog.Any("err", err))
-		respondFail(controlapi.StopResponseType, m, fmt.Sprintf("Unable to deserialize stop request: %s", err))
+	if authErr := api.authorizeIdentity(m, systemNamespace, controlapi.OperationInfo); authErr != nil {
+		respondUnauthorized(controlapi.DiagnosticsResponseType, m, authErr)
 		return
 	}
 
-	deployRequest, _ := api.mgr.LookupWorkload(request.WorkloadId)
-	if deployRequest == nil {
-		api.log.Error("Stop request: no such workload", slog.String("workload_id", request.WorkloadId))
-		respondFail(controlapi.StopResponseType, m, "No such workload")
+	if api.node.config.Diagnostics == nil {
+		respondFail(controlapi.DiagnosticsResponseType, m, "Diagnostics are not enabled on this node")
 		return
 	}
 
-	err = request.Validate(&deployRequest.DecodedClaims)
+	response, err := api.node.Diagnostics(&request)
 	if err != nil {
-		api.log.Error("Failed to validate stop request", slog.Any("err", err))
-		respondFail(controlapi.StopResponseType, m, fmt.Sprintf("Invalid stop request: %s", err))
+		respondFail(controlapi.DiagnosticsResponseType, m, fmt.Sprintf("Failed to collect diagnostics: %s", err))
 		return
 	}
 
-	if *deployRequest.Namespace != namespace {
-		api.log.Error("Namespace mismatch on workload stop request",
-			slog.String("namespace", *deployRequest.Namespace),
-			slog.String("targetnamespace", namespace),
-		)
-
-		respondFail(controlapi.StopResponseType, m, "No such workload") // do not expose ID existence to avoid existence probes
+	res := controlapi.NewEnvelope(controlapi.DiagnosticsResponseType, response, nil)
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.DiagnosticsResponseType, m, "Serialization failure")
 		return
 	}
 
-	err = api.mgr.StopWorkload(request.WorkloadId, true)
-	if err != nil {
-		api.log.Error("Failed to stop workload", slog.Any("err", err))
-		respondFail(controlapi.StopResponseType, m, fmt.Sprintf("Failed to stop workload: %s", err))
+	// profiles and goroutine dumps of a busy node can outgrow a single message
+	if int64(len(raw)) > api.node.nc.MaxPayload() {
+		respondFail(controlapi.DiagnosticsResponseType, m, fmt.Sprintf("Diagnostics of %d bytes exceed the maximum payload; request fewer profiles or use the diagnostics listener", len(raw)))
+		return
 	}
 
-	res := controlapi.NewEnvelope(controlapi.StopResponseType, controlapi.StopResponse{
-		Stopped: true,
-		Name:    deployRequest.DecodedClaims.Subject,
-		Issuer:  deployRequest.DecodedClaims.Issuer,
-		ID:      request.WorkloadId,
-	}, nil)
-	raw, err := json.Marshal(res)
-	if err != nil {
-		api.log.Error("Failed to marshal run response", slog.Any("err", err))
-	} else {
-		_ = m.Respond(raw)
-	}
+	_ = m.Respond(raw)
 }
 
-// $NEX.WPING.{namespace}.{workloadId}
-func (api *ApiListener) handleWorkloadPing(m *nats.Msg) {
-	// Note that this ping _only_ responds on success, all others are silent
-	// $NEX.WPING.{namespace}.{workloadId}
-	// result payload looks exactly like a node ping reply
-	tokens := strings.Split(m.Subject, ".")
-
-	namespace := ""
-	workloadId := ""
-
-	if len(tokens) > 2 {
-		namespace = tokens[2]
-	}
-	if len(tokens) > 3 {
-		workloadId = tokens[3]
+func (api *ApiListener) handleTags(m *apiRequest) {
+	var request controlapi.NodeTagsRequest
+	err := json.Unmarshal(m.Data, &request)
+	if err != nil {
+		api.log.Error("Failed to deserialize node tags request", slog.Any("err", err))
+		respondFail(controlapi.NodeTagsResponseType, m, fmt.Sprintf("Unable to deserialize node tags request: %s", err))
+		return
 	}
 
-	machines, err := api.mgr.RunningWorkloads()
+	tags, err := api.node.UpdateTags(request.Set, request.Remove)
 	if err != nil {
-		api.log.Error("Failed to query running machines", slog.Any("error", err))
+		api.log.Error("Failed to update node tags", slog.Any("err", err))
+		respondFail(controlapi.NodeTagsResponseType, m, fmt.Sprintf("Failed to update node tags: %s", err))
 		return
 	}
 
-	summaries := summarizeMachinesForPing(machines, namespace, workloadId)
-	if len(summaries) > 0 {
-		now := time.Now().UTC()
-		res := controlapi.NewEnvelope(controlapi.PingResponseType, controlapi.WorkloadPingResponse{
-			NodeId:          api.PublicKey(),
-			TargetXkey:      api.PublicXKey(),
-			Version:         Version(),
-			Uptime:          myUptime(now.Sub(api.start)),
-			RunningMachines: summaries,
-			Tags:            api.node.config.Tags,
-		}, nil)
+	res := controlapi.NewEnvelope(controlapi.NodeTagsResponseType, controlapi.NodeTagsResponse{
+		NodeId: api.PublicKey(),
+		Tags:   tags,
+	}, nil)
 
-		raw, err := json.Marshal(res)
-		if err != nil {
-			api.log.Error("Failed to marshal ping response", slog.Any("err", err))
-		} else {
-			_ = m.Respond(raw)
-		}
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.NodeTagsResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
 	}
-
-	// silence if there were no matching machines
 }
 
-func (api *ApiListener) handleLameDuck(m *nats.Msg) {
-	err := api.node.EnterLameDuck()
-	if err != nil {
-		api.log.Error("Failed to enter lame duck mode", slog.Any("error", err))
-		respondFail(controlapi.LameDuckResponseType, m, "Failed to enter lame duck mode")
-		return
-	}
-	res := controlapi.NewEnvelope(controlapi.LameDuckResponseType, controlapi.LameDuckResponse{
-		Success: true,
+// $NEX.SCHEMAS.{node}
+func (api *ApiListener) handleEventSchemas(m *apiRequest) {
+	res := controlapi.NewEnvelope(controlapi.SchemasResponseType, controlapi.EventSchemasResponse{
 		NodeId:  api.PublicKey(),
+		Version: VERSION,
+		Schemas: events.Schemas(),
 	}, nil)
+
 	raw, err := json.Marshal(res)
 	if err != nil {
 		api.log.Error("Failed to serialize response", slog.Any("error", err))
-		respondFail(controlapi.LameDuckResponseType, m, "Serialization failure")
+		respondFail(controlapi.SchemasResponseType, m, "Serialization failure")
 	} else {
 		_ = m.Respond(raw)
 	}
 }
 
-func (api *ApiListener) handleInfo(m *nats.Msg) {
+func (api *ApiListener) handleInfo(m *apiRequest) {
 	namespace, err := extractNamespace(m.Subject)
 	if err != nil {
 		api.log.Error("Failed to extract namespace for info request", slog.Any("err", err))
@@ -519,6 +2870,11 @@ func (api *ApiListener) handleInfo(m *nats.Msg) {
 		return
 	}
 
+	if authErr := api.authorizeIdentity(m, namespace, controlapi.OperationInfo); authErr != nil {
+		respondUnauthorized(controlapi.InfoResponseType, m, authErr)
+		return
+	}
+
 	machines, err := api.mgr.RunningWorkloads()
 	if err != nil {
 		api.log.Error("Failed to query running machines", slog.Any("error", err))
@@ -526,7 +2882,14 @@ func (api *ApiListener) handleInfo(m *nats.Msg) {
 		return
 	}
 
-	pubX, _ := api.xk.PublicKey()
+	filter, err := workloadFilter(m)
+	if err != nil {
+		respondFail(controlapi.InfoResponseType, m, fmt.Sprintf("Unable to deserialize workload filter: %s", err))
+		return
+	}
+	machines = filterMachines(machines, filter)
+
+	pubX := api.PublicXKey()
 	now := time.Now().UTC()
 	stats, _ := ReadMemoryStats()
 	res := controlapi.NewEnvelope(controlapi.InfoResponseType, controlapi.InfoResponse{
@@ -536,7 +2899,12 @@ func (api *ApiListener) handleInfo(m *nats.Msg) {
 		Tags:                   api.node.config.Tags,
 		SupportedWorkloadTypes: api.node.config.WorkloadTypes,
 		Machines:               summarizeMachines(machines, namespace), // filters by namespace
+		Agents:                 api.mgr.AgentSummaries(),
 		Memory:                 stats,
+		Capacity:               api.mgr.Capacity(),
+		Cordon:                 api.node.CordonStatus(),
+		Pressure:               api.node.PressureStatus(),
+		Preflight:              api.node.preflight,
 	}, nil)
 
 	raw, err := json.Marshal(res)
@@ -557,6 +2925,35 @@ func summarizeMachines(workloads []controlapi.MachineSummary, namespace string)
 	return machines
 }
 
+// Decodes the optional workload filter carried by a request
+func workloadFilter(m *apiRequest) (*controlapi.WorkloadFilter, error) {
+	if len(m.Data) == 0 {
+		return nil, nil
+	}
+
+	var filter controlapi.WorkloadFilter
+	err := json.Unmarshal(m.Data, &filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &filter, nil
+}
+
+func filterMachines(workloads []controlapi.MachineSummary, filter *controlapi.WorkloadFilter) []controlapi.MachineSummary {
+	if filter == nil || filter.Issuer == "" {
+		return workloads
+	}
+
+	machines := make([]controlapi.MachineSummary, 0)
+	for _, w := range workloads {
+		if filter.Matches(w.Issuer) {
+			machines = append(machines, w)
+		}
+	}
+	return machines
+}
+
 func summarizeMachinesForPing(workloads []controlapi.MachineSummary, namespace string, workloadId string) []controlapi.WorkloadPingMachineSummary {
 	machines := make([]controlapi.WorkloadPingMachineSummary, 0)
 	for _, w := range workloads {
@@ -566,6 +2963,7 @@ func summarizeMachinesForPing(workloads []controlapi.MachineSummary, namespace s
 				Namespace:    w.Namespace, // return the real namespace rather than the search criteria, which could be ""
 				Name:         w.Workload.Name,
 				WorkloadType: w.Workload.WorkloadType,
+				Issuer:       w.Issuer,
 			}
 			machines = append(machines, reply)
 		}
@@ -623,12 +3021,66 @@ func myUptime(d time.Duration) string {
 	return fmt.Sprintf("%ds", tsecs)
 }
 
-func respondFail(responseType string, m *nats.Msg, reason string) {
+func respondFail(responseType string, m *apiRequest, reason string) {
+	m.audit.recordOutcome(controlapi.AuditOutcomeFailed, reason)
+
 	env := controlapi.NewEnvelope(responseType, []byte{}, &reason)
 	jenv, _ := json.Marshal(env)
 	_ = m.Respond(jenv)
 }
 
+// Responds to a deploy request that was rejected with the reason for its rejection, both as the
+// envelope's error and in the run response
+func respondRejected(m *apiRequest, rejection *controlapi.DeployRejection) {
+	m.audit.recordOutcome(controlapi.AuditOutcomeFailed, rejection.Message)
+
+	env := controlapi.Envelope{
+		PayloadType: controlapi.RunResponseType,
+		Data:        controlapi.RunResponse{Rejection: rejection},
+		Error:       rejection,
+	}
+	jenv, _ := json.Marshal(env)
+	_ = m.Respond(jenv)
+}
+
+func respondUnauthorized(responseType string, m *apiRequest, authErr *controlapi.AuthorizationError) {
+	m.audit.recordOutcome(controlapi.AuditOutcomeDenied, authErr.Reason)
+
+	env := controlapi.Envelope{
+		PayloadType: responseType,
+		Data:        []byte{},
+		Error:       authErr,
+	}
+	jenv, _ := json.Marshal(env)
+	_ = m.Respond(jenv)
+}
+
+func respondQueued(m *apiRequest, queued controlapi.DeployQueuedResponse) {
+	m.audit.recordOutcome(controlapi.AuditOutcomeQueued, "")
+
+	env := controlapi.NewEnvelope(controlapi.DeployQueuedResponseType, queued, nil)
+	jenv, _ := json.Marshal(env)
+	_ = m.Respond(jenv)
+}
+
+// Extracts the namespace and group from a $NEX.{op}.{namespace}.{group} subject
+func extractGroup(subject string) (string, string) {
+	tokens := strings.Split(subject, ".")
+	if len(tokens) < 4 {
+		return "", ""
+	}
+	return tokens[2], tokens[3]
+}
+
+func findMachine(machines []controlapi.MachineSummary, id string) (controlapi.MachineSummary, bool) {
+	for _, machine := range machines {
+		if machine.Id == id {
+			return machine, true
+		}
+	}
+	return controlapi.MachineSummary{}, false
+}
+
 func extractNamespace(subject string) (string, error) {
 	tokens := strings.Split(subject, ".")
 	// we need at least $NEX.{op}.{namespace}
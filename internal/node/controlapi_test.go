@@ -1,10 +1,14 @@
 package nexnode
 
 import (
+	"errors"
 	"slices"
 	"testing"
 
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
 	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
 )
 
 func TestSummarizeMachinesForPing(t *testing.T) {
@@ -79,3 +83,170 @@ func TestSummarizeMachinesForPing(t *testing.T) {
 		t.Fatalf("Should've returned 0 results, got %d", len(results))
 	}
 }
+
+func TestFilterMachinesByIssuer(t *testing.T) {
+	workloads := []controlapi.MachineSummary{
+		{Id: "bob", Namespace: "default", Issuer: "ISSUER_A"},
+		{Id: "alice", Namespace: "default", Issuer: "ISSUER_B"},
+	}
+
+	if len(filterMachines(workloads, nil)) != 2 {
+		t.Fatal("Expected no filter to match every workload")
+	}
+
+	results := summarizeMachinesForPing(filterMachines(workloads, &controlapi.WorkloadFilter{Issuer: "ISSUER_B"}), "default", "")
+	if len(results) != 1 || results[0].Id != "alice" || results[0].Issuer != "ISSUER_B" {
+		t.Fatalf("Expected only the workload owned by ISSUER_B, got %+v", results)
+	}
+}
+
+func TestDiscoverRequestFiltersNodes(t *testing.T) {
+	api := &ApiListener{
+		node: &Node{
+			config: &models.NodeConfiguration{
+				Tags:          map[string]string{"region": "east", controlapi.TagSite: "edge-1"},
+				WorkloadTypes: []controlapi.NexWorkload{controlapi.NexWorkloadNative},
+			},
+		},
+	}
+
+	matching := controlapi.DiscoverRequest{
+		AuctionRequest: controlapi.AuctionRequest{
+			Tags:          map[string]string{"region": "EAST"},
+			WorkloadTypes: []controlapi.NexWorkload{controlapi.NexWorkloadNative},
+			Sites:         []string{"hub", "EDGE-1"},
+		},
+	}
+	if !api.matchesAuction(&matching.AuctionRequest) {
+		t.Fatal("Expected node to match discover request")
+	}
+
+	if !api.matchesAuction(&controlapi.AuctionRequest{}) {
+		t.Fatal("Expected node to match an unfiltered discover request")
+	}
+
+	mismatched := []controlapi.AuctionRequest{
+		{Tags: map[string]string{"region": "west"}},
+		{Tags: map[string]string{"zone": "a"}},
+		{WorkloadTypes: []controlapi.NexWorkload{controlapi.NexWorkloadV8}},
+		{Sites: []string{"edge-2"}},
+	}
+	for _, req := range mismatched {
+		if api.matchesAuction(&req) {
+			t.Fatalf("Expected node not to match discover request %+v", req)
+		}
+	}
+}
+
+func TestExtractGroup(t *testing.T) {
+	namespace, group := extractGroup("$NEX.GSTOP.default.billing")
+	if namespace != "default" || group != "billing" {
+		t.Fatalf("Expected namespace default and group billing, got %s and %s", namespace, group)
+	}
+
+	namespace, group = extractGroup("$NEX.GSTOP.default")
+	if namespace != "" || group != "" {
+		t.Fatalf("Expected no namespace or group for a truncated subject, got %s and %s", namespace, group)
+	}
+}
+
+func TestSatisfiesPlacementConstraints(t *testing.T) {
+	machines := []controlapi.MachineSummary{
+		{Id: "a", Namespace: "default", Labels: map[string]string{"app": "web"}, Workload: controlapi.WorkloadSummary{Name: "web"}},
+		{Id: "b", Namespace: "default", Labels: map[string]string{"app": "web"}, Workload: controlapi.WorkloadSummary{Name: "web"}},
+	}
+
+	if satisfiesPlacement(&controlapi.AuctionRequest{
+		AntiAffinity: []controlapi.AntiAffinityTerm{{WorkloadName: "web"}},
+	}, machines) {
+		t.Fatal("Expected a node running the named workload to decline")
+	}
+
+	if !satisfiesPlacement(&controlapi.AuctionRequest{
+		AntiAffinity: []controlapi.AntiAffinityTerm{{WorkloadName: "web", Namespace: "other"}},
+	}, machines) {
+		t.Fatal("Expected a term scoped to another namespace not to match")
+	}
+
+	spread := &controlapi.SpreadConstraint{
+		AntiAffinityTerm: controlapi.AntiAffinityTerm{Labels: map[string]string{"app": "web"}},
+		MaxPerNode:       2,
+	}
+	if satisfiesPlacement(&controlapi.AuctionRequest{Spread: spread}, machines) {
+		t.Fatal("Expected a node at the spread limit to decline")
+	}
+
+	spread.MaxPerNode = 3
+	if !satisfiesPlacement(&controlapi.AuctionRequest{Spread: spread}, machines) {
+		t.Fatal("Expected a node below the spread limit to bid")
+	}
+}
+
+func TestStopRequestRequiresOriginalIssuerOrAdminKey(t *testing.T) {
+	owner, _ := nkeys.CreateAccount()
+	other, _ := nkeys.CreateAccount()
+	admin, _ := nkeys.CreateOperator()
+	ownerPub, _ := owner.PublicKey()
+	adminPub, _ := admin.PublicKey()
+
+	original := jwt.NewGenericClaims("echo")
+	original.Issuer = ownerPub
+	original.ID = "original"
+
+	request, _ := controlapi.NewStopRequest("w1", "echo", "node", owner)
+	if err := request.Validate(original); err != nil {
+		t.Fatalf("Expected the original issuer to be allowed to stop the workload, got %s", err)
+	}
+
+	request, _ = controlapi.NewStopRequest("w1", "echo", "node", other)
+	var authErr *controlapi.AuthorizationError
+	if err := request.Validate(original, adminPub); !errors.As(err, &authErr) {
+		t.Fatalf("Expected an authorization error for another issuer, got %v", err)
+	}
+
+	request, _ = controlapi.NewStopRequest("w1", "echo", "node", admin)
+	if err := request.Validate(original, adminPub); err != nil {
+		t.Fatalf("Expected an admin key to be allowed to stop the workload, got %s", err)
+	}
+
+	request, _ = controlapi.NewStopRequest("w2", "echo", "node", owner)
+	request.WorkloadId = "w1"
+	if err := request.Validate(original); !errors.As(err, &authErr) {
+		t.Fatalf("Expected claims issued for another workload to be rejected, got %v", err)
+	}
+}
+
+func TestBulkStopRequestSelectsByLabelsAndIssuer(t *testing.T) {
+	owner, _ := nkeys.CreateAccount()
+	other, _ := nkeys.CreateAccount()
+	ownerPub, _ := owner.PublicKey()
+
+	original := jwt.NewGenericClaims("echo")
+	original.Issuer = ownerPub
+	original.ID = "original"
+
+	request, _ := controlapi.NewBulkStopRequest("default", map[string]string{"app": "echo", "tier": "web"}, owner)
+	if !request.Matches(map[string]string{"app": "echo", "tier": "web", "version": "2"}) {
+		t.Fatal("Expected a workload carrying every selected label to match")
+	}
+	if request.Matches(map[string]string{"app": "echo"}) || request.Matches(nil) {
+		t.Fatal("Expected a workload missing a selected label not to match")
+	}
+
+	everything, _ := controlapi.NewBulkStopRequest("default", nil, owner)
+	if !everything.Matches(nil) {
+		t.Fatal("Expected an empty selector to match every workload")
+	}
+
+	if err := request.Validate("default", original); err != nil {
+		t.Fatalf("Expected the original issuer to be allowed to stop its workloads, got %s", err)
+	}
+	if err := request.Validate("other", original); err == nil {
+		t.Fatal("Expected claims for another namespace to be rejected")
+	}
+
+	request, _ = controlapi.NewBulkStopRequest("default", nil, other)
+	if err := request.Validate("default", original); err == nil {
+		t.Fatal("Expected another issuer's bulk stop to be rejected")
+	}
+}
@@ -0,0 +1,128 @@
+package nexnode
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/events"
+)
+
+// Cordons the node so that it declines auctions and deploy requests while continuing to run its
+// existing workloads. If until is given, the cordon is lifted automatically at that time. Cordoning
+// an already cordoned node replaces its reason and window
+func (n *Node) Cordon(reason string, until *time.Time) (*controlapi.CordonStatus, error) {
+	now := time.Now().UTC()
+	if until != nil && !until.After(now) {
+		return nil, fmt.Errorf("cordon window must end in the future")
+	}
+
+	n.cordonMutex.Lock()
+	defer n.cordonMutex.Unlock()
+
+	since := now
+	if n.cordon != nil {
+		since = n.cordon.Since
+	}
+
+	if n.cordonTimer != nil {
+		n.cordonTimer.Stop()
+		n.cordonTimer = nil
+	}
+
+	n.cordon = &controlapi.CordonStatus{
+		Reason: reason,
+		Since:  since,
+		Until:  until,
+	}
+	n.config.Tags[controlapi.TagCordoned] = "true"
+
+	if until != nil {
+		status := n.cordon
+		n.cordonTimer = time.AfterFunc(until.Sub(now), func() {
+			n.cordonMutex.Lock()
+			defer n.cordonMutex.Unlock()
+
+			// a later cordon request may have replaced this one
+			if n.cordon == status {
+				n.log.Info("Maintenance window ended; lifting cordon")
+				n.uncordon()
+			}
+		})
+	}
+
+	n.log.Info("Node cordoned", slog.String("reason", reason), slog.Any("until", until))
+	_ = n.publishNodeCordonChanged(n.cordon)
+
+	status := *n.cordon
+	return &status, nil
+}
+
+// Lifts the cordon from the node, returning false if it was not cordoned
+func (n *Node) Uncordon() bool {
+	n.cordonMutex.Lock()
+	defer n.cordonMutex.Unlock()
+
+	if n.cordon == nil {
+		return false
+	}
+
+	if n.cordonTimer != nil {
+		n.cordonTimer.Stop()
+		n.cordonTimer = nil
+	}
+
+	n.log.Info("Node uncordoned")
+	n.uncordon()
+
+	return true
+}
+
+// callers must hold the cordon mutex
+func (n *Node) uncordon() {
+	n.cordon = nil
+	n.cordonTimer = nil
+	delete(n.config.Tags, controlapi.TagCordoned)
+
+	_ = n.publishNodeCordonChanged(nil)
+}
+
+func (n *Node) IsCordoned() bool {
+	n.cordonMutex.Lock()
+	defer n.cordonMutex.Unlock()
+
+	return n.cordon != nil
+}
+
+// Returns a copy of the node's current cordon, or nil if it is not cordoned
+func (n *Node) CordonStatus() *controlapi.CordonStatus {
+	n.cordonMutex.Lock()
+	defer n.cordonMutex.Unlock()
+
+	if n.cordon == nil {
+		return nil
+	}
+
+	status := *n.cordon
+	return &status
+}
+
+// Publishes a cordoned event for the given status, or an uncordoned event when it is nil
+func (n *Node) publishNodeCordonChanged(status *controlapi.CordonStatus) error {
+	if n.nc == nil {
+		return nil
+	}
+
+	evt := controlapi.NodeCordonEvent{
+		Id:     n.publicKey,
+		Cordon: status,
+	}
+
+	cloudevent := events.NodeUncordoned(n.publicKey, evt)
+	if status != nil {
+		cloudevent = events.NodeCordoned(n.publicKey, evt)
+	}
+
+	return PublishCloudEvent(n.events(), "system", cloudevent, n.log)
+}
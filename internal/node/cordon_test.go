@@ -0,0 +1,44 @@
+package nexnode
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestCordonLiftedAtEndOfWindow(t *testing.T) {
+	node := &Node{
+		log:    slog.Default(),
+		config: &models.NodeConfiguration{Tags: map[string]string{}},
+	}
+
+	past := time.Now().Add(-time.Minute)
+	if _, err := node.Cordon("maintenance", &past); err == nil {
+		t.Fatal("Expected cordon window ending in the past to be rejected")
+	}
+
+	until := time.Now().Add(50 * time.Millisecond)
+	status, err := node.Cordon("maintenance", &until)
+	if err != nil {
+		t.Fatalf("Expected cordon to succeed: %s", err)
+	}
+
+	if status.Reason != "maintenance" || !node.IsCordoned() || node.config.Tags[controlapi.TagCordoned] != "true" {
+		t.Fatalf("Expected node to be cordoned and tagged, got %+v", status)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for node.IsCordoned() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if node.IsCordoned() {
+		t.Fatal("Expected cordon to be lifted at the end of its window")
+	}
+	if _, ok := node.config.Tags[controlapi.TagCordoned]; ok {
+		t.Fatal("Expected cordon tag to be removed once uncordoned")
+	}
+}
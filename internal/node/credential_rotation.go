@@ -0,0 +1,96 @@
+package nexnode
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+// Periodically rotates the internal NATS credentials of every agent until the workload manager
+// stops, limiting how long a leaked agent key remains useful
+func (w *WorkloadManager) runCredentialRotation() {
+	if w.config.InternalNATS == nil || w.config.InternalNATS.CredentialRotationIntervalMillisecond <= 0 {
+		w.log.Debug("Agent credential rotation disabled")
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(w.config.InternalNATS.CredentialRotationIntervalMillisecond) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			if atomic.LoadUint32(&w.closing) > 0 {
+				return
+			}
+
+			w.rotateAgentCredentials()
+		}
+	}
+}
+
+// Rotates the credentials of every agent that has completed its handshake with a protocol
+// version supporting rotation. Returns the IDs of the rotated agents
+func (w *WorkloadManager) rotateAgentCredentials() []string {
+	w.poolMutex.Lock()
+	w.teardownMutex.Lock()
+	candidates := make(map[string]*agentapi.AgentClient)
+	for id, agentClient := range w.pendingAgents {
+		if _, handshook := w.handshakes[id]; handshook {
+			candidates[id] = agentClient
+		}
+	}
+	for id, agentClient := range w.activeAgents {
+		candidates[id] = agentClient
+	}
+	w.teardownMutex.Unlock()
+	w.poolMutex.Unlock()
+
+	rotated := make([]string, 0, len(candidates))
+	for id, agentClient := range candidates {
+		if agentClient.ProtocolVersion() < agentapi.CredentialRotationProtocolVersion {
+			continue
+		}
+
+		if w.rotateAgentCredential(id, agentClient) {
+			rotated = append(rotated, id)
+		}
+	}
+
+	return rotated
+}
+
+// Issues new credentials to a single agent and revokes its previous ones. The previous
+// credentials are revoked even when the agent fails to acknowledge the new ones, so that an
+// agent cannot hold on to a key by ignoring rotations; such an agent loses its connection and is
+// eventually reaped
+func (w *WorkloadManager) rotateAgentCredential(id string, agentClient *agentapi.AgentClient) bool {
+	kp, err := w.natsint.RotateCredentials(id)
+	if err != nil {
+		w.log.Warn("Failed to rotate agent credentials", slog.String("workload_id", id), slog.Any("err", err))
+		return false
+	}
+
+	seed, _ := kp.Seed()
+	err = agentClient.RotateCredentials(seed)
+	if err != nil {
+		w.log.Warn("Agent did not acknowledge rotated credentials", slog.String("workload_id", id), slog.Any("err", err))
+	}
+
+	revokeErr := w.natsint.RevokePreviousCredentials(id)
+	if revokeErr != nil {
+		w.log.Error("Failed to revoke previous agent credentials", slog.String("workload_id", id), slog.Any("err", revokeErr))
+		return false
+	}
+
+	if err != nil {
+		return false
+	}
+
+	w.log.Debug("Rotated agent credentials", slog.String("workload_id", id))
+	return true
+}
@@ -0,0 +1,185 @@
+package nexnode
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+const deployQueueTickInterval = 1 * time.Second
+
+// A deploy request waiting for an agent to become available
+type queuedDeploy struct {
+	id        string
+	namespace string
+	request   *controlapi.DeployRequest
+	msg       *apiRequest
+	// Issuer of the request, who alone may cancel it besides the node's administrators
+	issuer     string
+	enqueuedAt time.Time
+	expiresAt  time.Time
+}
+
+// Bounded FIFO of deploy requests that arrived while the agent pool was empty. Each agent that
+// becomes ready takes the oldest request its pool can serve. Entries expire after the configured
+// TTL and may be cancelled by their issuer at any time
+type deployQueue struct {
+	mutex   sync.Mutex
+	entries []*queuedDeploy
+	maxSize int
+	ttl     time.Duration
+
+	// smoothed interval between agents becoming ready, used to estimate wait times
+	lastReady     time.Time
+	readyInterval time.Duration
+}
+
+func newDeployQueue(maxSize int, ttl time.Duration) *deployQueue {
+	return &deployQueue{
+		entries: make([]*queuedDeploy, 0),
+		maxSize: maxSize,
+		ttl:     ttl,
+	}
+}
+
+// Adds a deploy request to the back of the queue, returning the interim response for the new entry
+func (q *deployQueue) enqueue(namespace string, request *controlapi.DeployRequest, m *apiRequest) (*controlapi.DeployQueuedResponse, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.entries) >= q.maxSize {
+		return nil, errors.New("deploy queue is full")
+	}
+
+	now := time.Now().UTC()
+	entry := &queuedDeploy{
+		id:         xid.New().String(),
+		namespace:  namespace,
+		request:    request,
+		msg:        m,
+		issuer:     request.DecodedClaims.Issuer,
+		enqueuedAt: now,
+		expiresAt:  now.Add(q.ttl),
+	}
+	q.entries = append(q.entries, entry)
+
+	queued := q.queuedResponse(entry, len(q.entries))
+	return &queued, nil
+}
+
+// Returns the first entry accepted by the given filter, e.g. those a ready agent's pool can
+// serve, leaving it queued. Returns nil if no entry is accepted
+func (q *deployQueue) next(accept func(*queuedDeploy) bool) *queuedDeploy {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, entry := range q.entries {
+		if accept(entry) {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+// Removes the given entry from the queue, reporting whether it was still queued. Entries may be
+// cancelled or expire while an agent is being reserved for them
+func (q *deployQueue) take(entry *queuedDeploy) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, queued := range q.entries {
+		if queued == entry {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the entry with the given ID within the given namespace, leaving it queued, or nil if
+// there is no such entry
+func (q *deployQueue) find(namespace string, id string) *queuedDeploy {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, entry := range q.entries {
+		if entry.id == id && entry.namespace == namespace {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+// Removes and returns every entry whose TTL has elapsed
+func (q *deployQueue) expire(now time.Time) []*queuedDeploy {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	expired := make([]*queuedDeploy, 0)
+	remaining := make([]*queuedDeploy, 0, len(q.entries))
+	for _, entry := range q.entries {
+		if now.After(entry.expiresAt) {
+			expired = append(expired, entry)
+		} else {
+			remaining = append(remaining, entry)
+		}
+	}
+	q.entries = remaining
+
+	return expired
+}
+
+// Removes and returns all entries
+func (q *deployQueue) drain() []*queuedDeploy {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	entries := q.entries
+	q.entries = make([]*queuedDeploy, 0)
+	return entries
+}
+
+// Records that an agent became ready so wait time estimates can track the pool's warm-up rate
+func (q *deployQueue) agentReady(now time.Time) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if !q.lastReady.IsZero() {
+		interval := now.Sub(q.lastReady)
+		if q.readyInterval == 0 {
+			q.readyInterval = interval
+		} else {
+			q.readyInterval = (q.readyInterval*3 + interval) / 4
+		}
+	}
+	q.lastReady = now
+}
+
+// Builds the interim progress responses for every queued entry, keyed by entry
+func (q *deployQueue) positions() map[*queuedDeploy]controlapi.DeployQueuedResponse {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	positions := make(map[*queuedDeploy]controlapi.DeployQueuedResponse, len(q.entries))
+	for i, entry := range q.entries {
+		positions[entry] = q.queuedResponse(entry, i+1)
+	}
+
+	return positions
+}
+
+// Callers must hold the queue mutex
+func (q *deployQueue) queuedResponse(entry *queuedDeploy, position int) controlapi.DeployQueuedResponse {
+	return controlapi.DeployQueuedResponse{
+		QueueID:             entry.id,
+		Position:            position,
+		EstimatedWaitMillis: (q.readyInterval * time.Duration(position)).Milliseconds(),
+		ExpiresAt:           entry.expiresAt,
+	}
+}
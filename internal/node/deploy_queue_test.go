@@ -0,0 +1,86 @@
+package nexnode
+
+import (
+	"testing"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+func TestDeployQueueOrderingAndLimits(t *testing.T) {
+	q := newDeployQueue(2, time.Minute)
+
+	first, err := q.enqueue("default", &controlapi.DeployRequest{}, nil)
+	if err != nil {
+		t.Fatalf("Expected first enqueue to succeed, got %s", err)
+	}
+	second, err := q.enqueue("default", &controlapi.DeployRequest{}, nil)
+	if err != nil {
+		t.Fatalf("Expected second enqueue to succeed, got %s", err)
+	}
+	if first.Position != 1 || second.Position != 2 {
+		t.Fatalf("Unexpected queue positions: %d and %d", first.Position, second.Position)
+	}
+
+	_, err = q.enqueue("default", &controlapi.DeployRequest{}, nil)
+	if err == nil {
+		t.Fatal("Expected enqueue beyond max size to fail")
+	}
+
+	// cancellation is scoped to the namespace the request was queued in
+	if q.find("other", first.QueueID) != nil {
+		t.Fatal("Should not be able to find an entry from another namespace")
+	}
+
+	entry := q.find("default", first.QueueID)
+	if entry == nil || entry.id != first.QueueID || !q.take(entry) {
+		t.Fatal("Expected to remove the first queued entry")
+	}
+
+	positions := q.positions()
+	if len(positions) != 1 {
+		t.Fatalf("Expected 1 remaining entry, got %d", len(positions))
+	}
+	for _, queued := range positions {
+		if queued.QueueID != second.QueueID || queued.Position != 1 {
+			t.Fatalf("Remaining entry should have moved to the front: %+v", queued)
+		}
+	}
+
+	expired := q.expire(time.Now().UTC().Add(2 * time.Minute))
+	if len(expired) != 1 || q.next(func(*queuedDeploy) bool { return true }) != nil {
+		t.Fatal("Expected the remaining entry to expire and leave the queue empty")
+	}
+}
+
+func TestDeployQueueServesEachPoolInOrder(t *testing.T) {
+	q := newDeployQueue(3, time.Minute)
+
+	_, _ = q.enqueue("default", &controlapi.DeployRequest{WorkloadType: controlapi.NexWorkloadNative}, nil)
+	v8First, _ := q.enqueue("default", &controlapi.DeployRequest{WorkloadType: controlapi.NexWorkloadV8}, nil)
+	_, _ = q.enqueue("default", &controlapi.DeployRequest{WorkloadType: controlapi.NexWorkloadV8}, nil)
+
+	v8 := func(entry *queuedDeploy) bool { return entry.request.WorkloadType == controlapi.NexWorkloadV8 }
+	entry := q.next(v8)
+	if entry == nil || entry.id != v8First.QueueID {
+		t.Fatalf("Expected the oldest v8 request behind the native one, got %+v", entry)
+	}
+
+	if !q.take(entry) {
+		t.Fatal("Expected the v8 request to be taken from the queue")
+	}
+	if q.take(entry) {
+		t.Fatal("Expected a request to be taken only once")
+	}
+
+	for queued, position := range q.positions() {
+		if queued.request.WorkloadType == controlapi.NexWorkloadNative && position.Position != 1 {
+			t.Fatalf("Expected the native request to keep its place at the front, got %d", position.Position)
+		}
+	}
+
+	oci := func(entry *queuedDeploy) bool { return entry.request.WorkloadType == controlapi.NexWorkloadOCI }
+	if q.next(oci) != nil {
+		t.Fatal("Expected no request for a pool nothing is queued for")
+	}
+}
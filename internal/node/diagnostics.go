@@ -0,0 +1,283 @@
+package nexnode
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const (
+	defaultDiagnosticsListen = "127.0.0.1:6061"
+
+	// CPU profiles taken through the listener run for this long unless the request asks otherwise
+	defaultCPUProfileDuration = 30 * time.Second
+	maxCPUProfileDuration     = 5 * time.Minute
+
+	diagnosticsShutdownTimeout = 5 * time.Second
+)
+
+// Starts the diagnostics listener. The pprof handlers are served from the runtime's profiles
+// rather than by importing net/http/pprof, which would also expose them, unauthenticated, on the
+// default mux served by the prometheus exporter
+func (n *Node) startDiagnostics() error {
+	token, err := diagnosticsToken(n.config.Diagnostics)
+	if err != nil {
+		return err
+	}
+
+	listen := n.config.Diagnostics.Listen
+	if listen == "" {
+		listen = defaultDiagnosticsListen
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to start diagnostics listener: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", serveProfile)
+	mux.HandleFunc("/debug/state", n.serveDiagnosticsState)
+
+	n.diagnostics = &http.Server{
+		Handler:           requireBearerToken(token, mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		err := n.diagnostics.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			n.log.Warn("Diagnostics listener stopped", slog.Any("err", err))
+		}
+	}()
+
+	n.log.Info("Diagnostics listener started", slog.String("address", listener.Addr().String()))
+	return nil
+}
+
+func (n *Node) stopDiagnostics() {
+	if n.diagnostics == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsShutdownTimeout)
+	defer cancel()
+
+	err := n.diagnostics.Shutdown(ctx)
+	if err != nil {
+		n.log.Warn("Failed to cleanly stop diagnostics listener", slog.Any("err", err))
+	}
+}
+
+// Takes a snapshot of the node's state, along with the requested profiles
+func (n *Node) Diagnostics(request *controlapi.DiagnosticsRequest) (*controlapi.DiagnosticsResponse, error) {
+	profiles, err := collectProfiles(request.Profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &controlapi.DiagnosticsResponse{
+		NodeId:   n.publicKey,
+		TakenAt:  time.Now().UTC(),
+		State:    n.diagnosticsState(),
+		Profiles: profiles,
+	}
+
+	if request.Goroutines {
+		var buf bytes.Buffer
+		_ = pprof.Lookup("goroutine").WriteTo(&buf, 2)
+		response.GoroutineDump = buf.String()
+	}
+
+	return response, nil
+}
+
+func (n *Node) diagnosticsState() controlapi.NodeDiagnostics {
+	state := n.manager.Diagnostics()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	state.Goroutines = runtime.NumGoroutine()
+	state.HeapAllocBytes = mem.HeapAlloc
+	state.HeapObjects = mem.HeapObjects
+	state.GCCycles = mem.NumGC
+
+	if n.nc != nil {
+		state.NATSSubscriptions = n.nc.NumSubscriptions()
+	}
+
+	return state
+}
+
+func (n *Node) serveDiagnosticsState(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(n.diagnosticsState())
+}
+
+// Lists the agents awaiting deployments and the workload table, ordered by ID
+func (w *WorkloadManager) Diagnostics() controlapi.NodeDiagnostics {
+	w.poolMutex.Lock()
+	w.teardownMutex.Lock()
+	defer w.teardownMutex.Unlock()
+	defer w.poolMutex.Unlock()
+
+	state := controlapi.NodeDiagnostics{
+		PendingAgents: make(map[controlapi.NexWorkload]int),
+		Workloads:     make([]controlapi.WorkloadDiagnostics, 0),
+	}
+
+	for id := range w.pendingAgents {
+		if _, ok := w.handshakes[id]; ok {
+			state.PendingAgents[w.agentPools[id]]++
+		}
+	}
+
+	for id, lifecycle := range w.states.snapshot() {
+		workload := controlapi.WorkloadDiagnostics{
+			Id:                   id,
+			State:                string(lifecycle.state),
+			Claimed:              lifecycle.claimed(),
+			TriggerSubscriptions: len(w.subz[id]),
+		}
+		if lifecycle.request != nil {
+			workload.WorkloadType = lifecycle.request.WorkloadType
+			if lifecycle.request.WorkloadName != nil {
+				workload.Name = *lifecycle.request.WorkloadName
+			}
+			if lifecycle.request.Namespace != nil {
+				workload.Namespace = *lifecycle.request.Namespace
+			}
+		}
+
+		state.Workloads = append(state.Workloads, workload)
+		state.TriggerSubscriptions += workload.TriggerSubscriptions
+	}
+
+	sort.Slice(state.Workloads, func(i, j int) bool {
+		return state.Workloads[i].Id < state.Workloads[j].Id
+	})
+
+	return state
+}
+
+// Reads the bearer token of the diagnostics listener from the configuration or its token file
+func diagnosticsToken(config *models.DiagnosticsConfig) (string, error) {
+	token := config.Token
+	if config.TokenFile != "" {
+		raw, err := os.ReadFile(config.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read diagnostics token file: %w", err)
+		}
+		token = strings.TrimSpace(string(raw))
+	}
+
+	if token == "" {
+		return "", errors.New("diagnostics token must not be empty")
+	}
+
+	return token, nil
+}
+
+// Rejects requests that do not carry the given bearer token
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="nex diagnostics"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serves /debug/pprof/{profile} in the manner of net/http/pprof: profiles are written in the
+// protobuf format unless debug is set, and /debug/pprof/profile takes a CPU profile for the
+// given number of seconds. The index lists the available profiles
+func serveProfile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/debug/pprof/")
+	debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+
+	switch name {
+	case "":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "profile")
+		for _, profile := range pprof.Profiles() {
+			fmt.Fprintf(w, "%s\t%d\n", profile.Name(), profile.Count())
+		}
+	case "profile":
+		duration := defaultCPUProfileDuration
+		if seconds, err := strconv.Atoi(r.URL.Query().Get("seconds")); err == nil && seconds > 0 {
+			duration = min(time.Duration(seconds)*time.Second, maxCPUProfileDuration)
+		}
+
+		var buf bytes.Buffer
+		if err := pprof.StartCPUProfile(&buf); err != nil {
+			http.Error(w, fmt.Sprintf("failed to start CPU profile: %s", err), http.StatusConflict)
+			return
+		}
+		select {
+		case <-time.After(duration):
+		case <-r.Context().Done():
+		}
+		pprof.StopCPUProfile()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(buf.Bytes())
+	default:
+		profile := pprof.Lookup(name)
+		if profile == nil {
+			http.Error(w, fmt.Sprintf("unknown profile %q", name), http.StatusNotFound)
+			return
+		}
+
+		if debug > 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		_ = profile.WriteTo(w, debug)
+	}
+}
+
+// Writes each named profile in the protobuf format read by go tool pprof
+func collectProfiles(names []string) (map[string][]byte, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	profiles := make(map[string][]byte, len(names))
+	for _, name := range names {
+		profile := pprof.Lookup(name)
+		if profile == nil {
+			return nil, fmt.Errorf("unknown profile %q", name)
+		}
+
+		var buf bytes.Buffer
+		err := profile.WriteTo(&buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s profile: %w", name, err)
+		}
+		profiles[name] = buf.Bytes()
+	}
+
+	return profiles, nil
+}
@@ -0,0 +1,71 @@
+package nexnode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestDiagnosticsRequireBearerToken(t *testing.T) {
+	handler := requireBearerToken("s3cret", http.HandlerFunc(serveProfile))
+
+	for token, status := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "s3cret": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/debug/pprof/heap?debug=1", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Fatalf("Expected status %d for token %q, got %d", status, token, rec.Code)
+		}
+	}
+}
+
+func TestCollectProfilesRejectsUnknownProfiles(t *testing.T) {
+	profiles, err := collectProfiles([]string{"heap", "goroutine"})
+	if err != nil {
+		t.Fatalf("Expected the runtime's profiles to be collected: %s", err)
+	}
+	if len(profiles["heap"]) == 0 || len(profiles["goroutine"]) == 0 {
+		t.Fatal("Expected both profiles to be written")
+	}
+
+	if _, err := collectProfiles([]string{"nope"}); err == nil {
+		t.Fatal("Expected an unknown profile to be rejected")
+	}
+}
+
+func TestWorkloadManagerDiagnosticsListsWorkloadTable(t *testing.T) {
+	w := newTestWorkloadManager(&models.NodeConfiguration{})
+
+	w.pendingAgents["pending"] = nil
+	w.handshakes["pending"] = "ok"
+	w.agentPools["pending"] = controlapi.NexWorkloadNative
+	w.states.track("pending")
+
+	name, namespace := "echo", "default"
+	w.states.track("wl")
+	request := &agentapi.DeployRequest{WorkloadName: &name, Namespace: &namespace, WorkloadType: controlapi.NexWorkloadNative}
+	if _, _, err := w.states.transition("wl", workloadStateDeploying, request); err != nil {
+		t.Fatalf("Expected the workload to start deploying: %s", err)
+	}
+
+	state := w.Diagnostics()
+	if state.PendingAgents[controlapi.NexWorkloadNative] != 1 {
+		t.Fatalf("Expected one pending native agent, got %v", state.PendingAgents)
+	}
+	if len(state.Workloads) != 2 {
+		t.Fatalf("Expected two workloads, got %d", len(state.Workloads))
+	}
+
+	deploying := state.Workloads[1]
+	if deploying.Id != "wl" || deploying.Name != "echo" || deploying.Namespace != "default" || deploying.State != "deploying" || !deploying.Claimed {
+		t.Fatalf("Unexpected workload diagnostics: %+v", deploying)
+	}
+}
@@ -0,0 +1,73 @@
+package nexnode
+
+import (
+	"sync"
+	"time"
+)
+
+// Tracks in-flight work so that shutdown can wait for it to complete. Once draining
+// has begun, no new work is admitted
+type drainBarrier struct {
+	mutex    sync.Mutex
+	active   int
+	draining bool
+	done     chan struct{}
+}
+
+func newDrainBarrier() *drainBarrier {
+	return &drainBarrier{
+		done: make(chan struct{}),
+	}
+}
+
+// Registers a unit of in-flight work, returning false if the barrier is draining
+func (b *drainBarrier) enter() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.draining {
+		return false
+	}
+
+	b.active++
+	return true
+}
+
+// Marks a unit of work previously admitted by enter as complete
+func (b *drainBarrier) exit() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.active--
+	if b.draining && b.active == 0 {
+		close(b.done)
+	}
+}
+
+// Stops admitting new work and waits up to the given timeout for in-flight work to complete,
+// returning false if the timeout elapsed first
+func (b *drainBarrier) drain(timeout time.Duration) bool {
+	b.mutex.Lock()
+	if !b.draining {
+		b.draining = true
+		if b.active == 0 {
+			close(b.done)
+		}
+	}
+	b.mutex.Unlock()
+
+	select {
+	case <-b.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Returns the number of units of work currently in flight
+func (b *drainBarrier) inFlight() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.active
+}
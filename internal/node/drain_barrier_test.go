@@ -0,0 +1,39 @@
+package nexnode
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDrainBarrierWaitsForInFlightWork(t *testing.T) {
+	b := newDrainBarrier()
+
+	if !b.enter() {
+		t.Fatal("Expected barrier to admit work before draining")
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		b.exit()
+	}()
+
+	if !b.drain(time.Second) {
+		t.Fatal("Expected drain to complete once in-flight work exited")
+	}
+
+	if b.enter() {
+		t.Fatal("Barrier should not admit work once drained")
+	}
+}
+
+func TestDrainBarrierTimesOut(t *testing.T) {
+	b := newDrainBarrier()
+	_ = b.enter()
+
+	if b.drain(10 * time.Millisecond) {
+		t.Fatal("Expected drain to time out with work still in flight")
+	}
+	if b.inFlight() != 1 {
+		t.Fatalf("Expected 1 unit of in-flight work, got %d", b.inFlight())
+	}
+}
@@ -0,0 +1,71 @@
+package nexnode
+
+import (
+	"regexp"
+	"strings"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/sdk"
+)
+
+// Placeholders that deploy environment values may contain, resolved by the node at deploy time
+const (
+	envTemplateNodeID     = "node.id"
+	envTemplateWorkloadID = "workload.id"
+	envTemplateNamespace  = "namespace"
+	envTemplateNexus      = "nexus"
+)
+
+var envTemplatePattern = regexp.MustCompile(`\{\{\s*([a-z.]+)\s*\}\}`)
+
+// Returns a copy of the environment with the node-provided placeholders in its values resolved,
+// e.g. "{{workload.id}}". Unrecognized placeholders are left as they are, so that values which
+// happen to contain braces are passed through untouched
+func resolveEnvironmentTemplates(env map[string]string, values map[string]string) map[string]string {
+	if env == nil {
+		return nil
+	}
+
+	resolved := make(map[string]string, len(env))
+	for key, value := range env {
+		if !strings.Contains(value, "{{") {
+			resolved[key] = value
+			continue
+		}
+
+		resolved[key] = envTemplatePattern.ReplaceAllStringFunc(value, func(placeholder string) string {
+			name := envTemplatePattern.FindStringSubmatch(placeholder)[1]
+			if v, ok := values[name]; ok {
+				return v
+			}
+			return placeholder
+		})
+	}
+
+	return resolved
+}
+
+// Resolves the placeholders of a workload's environment with the values of this node
+func (n *Node) resolveWorkloadEnvironment(workloadID string, namespace string, env map[string]string) map[string]string {
+	return resolveEnvironmentTemplates(env, map[string]string{
+		envTemplateNodeID:     n.publicKey,
+		envTemplateWorkloadID: workloadID,
+		envTemplateNamespace:  namespace,
+		envTemplateNexus:      n.nexus,
+	})
+}
+
+// Tells native workloads which node and nexus host them, for those built with the SDK. The agent
+// adds the variables describing the workload itself
+func (n *Node) describeNativeWorkload(request *agentapi.DeployRequest) {
+	if request.WorkloadType != controlapi.NexWorkloadNative {
+		return
+	}
+
+	if request.Environment == nil {
+		request.Environment = make(map[string]string)
+	}
+	request.Environment[sdk.EnvNodeID] = n.publicKey
+	request.Environment[sdk.EnvNexus] = n.nexus
+}
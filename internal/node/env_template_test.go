@@ -0,0 +1,34 @@
+package nexnode
+
+import "testing"
+
+func TestResolveEnvironmentTemplates(t *testing.T) {
+	env := map[string]string{
+		"SELF":     "{{workload.id}}@{{ node.id }}",
+		"NS":       "{{namespace}}",
+		"TEMPLATE": "{{.Name}} {{unknown.value}}",
+		"PLAIN":    "hello",
+	}
+
+	resolved := resolveEnvironmentTemplates(env, map[string]string{
+		envTemplateNodeID:     "NODE",
+		envTemplateWorkloadID: "WORKLOAD",
+		envTemplateNamespace:  "default",
+	})
+
+	expected := map[string]string{
+		"SELF":     "WORKLOAD@NODE",
+		"NS":       "default",
+		"TEMPLATE": "{{.Name}} {{unknown.value}}",
+		"PLAIN":    "hello",
+	}
+	for key, value := range expected {
+		if resolved[key] != value {
+			t.Fatalf("Expected %s to resolve to %q, got %q", key, value, resolved[key])
+		}
+	}
+
+	if env["SELF"] != "{{workload.id}}@{{ node.id }}" {
+		t.Fatal("Expected the original environment to be left unchanged")
+	}
+}
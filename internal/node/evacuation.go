@@ -0,0 +1,395 @@
+package nexnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/events"
+)
+
+const (
+	defaultEvacuationDeadline           = 90 * time.Second
+	defaultEvacuationNoticePollInterval = 5 * time.Second
+
+	// Workloads moved at the same time, so that a node running many of them beats its deadline
+	evacuationParallelism = 4
+
+	// Timeout of each auction and deploy request made to the node's peers
+	evacuationRequestTimeout = 2 * time.Second
+	evacuationProbeInterval  = 500 * time.Millisecond
+)
+
+// An evacuation moves a node's workloads to its peers before the node goes away
+type nodeEvacuation struct {
+	mu     sync.Mutex
+	status controlapi.EvacuationStatus
+
+	// Closed once the evacuation has ended and the node has entered lame duck mode
+	done chan struct{}
+}
+
+func (e *nodeEvacuation) snapshot() controlapi.EvacuationStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status := e.status
+	status.Workloads = append([]controlapi.EvacuatedWorkload(nil), e.status.Workloads...)
+	return status
+}
+
+func (e *nodeEvacuation) update(fn func(status *controlapi.EvacuationStatus)) controlapi.EvacuationStatus {
+	e.mu.Lock()
+	fn(&e.status)
+	e.mu.Unlock()
+
+	return e.snapshot()
+}
+
+// Starts evacuating the node: it is cordoned so that it declines new work, and its workloads
+// are moved to its peers in the background. A deadline of zero uses the configured deadline.
+// Evacuating a node that is already evacuating, or has been, returns the existing evacuation
+func (n *Node) Evacuate(reason string, deadline time.Duration) (*controlapi.EvacuationStatus, error) {
+	n.evacuationMutex.Lock()
+	defer n.evacuationMutex.Unlock()
+
+	if n.evacuation != nil {
+		status := n.evacuation.snapshot()
+		return &status, nil
+	}
+
+	if n.IsLameDuck() {
+		return nil, errors.New("node is in lame duck mode")
+	}
+
+	if deadline <= 0 {
+		deadline = defaultEvacuationDeadline
+		if n.config.Evacuation != nil && n.config.Evacuation.DeadlineMillis > 0 {
+			deadline = time.Duration(n.config.Evacuation.DeadlineMillis) * time.Millisecond
+		}
+	}
+
+	_, err := n.Cordon(fmt.Sprintf("evacuating: %s", reason), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	n.evacuation = &nodeEvacuation{
+		status: controlapi.EvacuationStatus{
+			NodeId:    n.publicKey,
+			State:     controlapi.EvacuationStateRunning,
+			Reason:    reason,
+			StartedAt: now,
+			Deadline:  now.Add(deadline),
+		},
+		done: make(chan struct{}),
+	}
+
+	n.log.Warn("Evacuating node", slog.String("reason", reason), slog.Duration("deadline", deadline))
+	go n.runEvacuation(n.evacuation)
+
+	status := n.evacuation.snapshot()
+	return &status, nil
+}
+
+// Returns the node's evacuation, or nil if it has not been evacuated
+func (n *Node) currentEvacuation() *nodeEvacuation {
+	n.evacuationMutex.Lock()
+	defer n.evacuationMutex.Unlock()
+
+	return n.evacuation
+}
+
+func (n *Node) runEvacuation(evacuation *nodeEvacuation) {
+	defer close(evacuation.done)
+
+	ctx, cancel := context.WithDeadline(n.ctx, evacuation.snapshot().Deadline)
+	defer cancel()
+
+	procs, err := n.manager.procMan.ListProcesses()
+	if err != nil {
+		n.log.Error("Failed to list workloads to evacuate", slog.Any("err", err))
+		procs = nil
+	}
+
+	targets := make(map[string]*agentapi.DeployRequest)
+	for _, proc := range procs {
+		if proc.DeployRequest == nil || proc.DeployRequest.Namespace == nil {
+			continue
+		}
+		targets[proc.ID] = proc.DeployRequest
+	}
+
+	status := evacuation.update(func(status *controlapi.EvacuationStatus) {
+		status.Total = len(targets)
+	})
+	_ = n.publishEvacuation(status, "")
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, evacuationParallelism)
+	for id, request := range targets {
+		wg.Add(1)
+		go func(id string, request *agentapi.DeployRequest) {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+			}
+
+			result := n.evacuateWorkload(ctx, id, request)
+			status := evacuation.update(func(status *controlapi.EvacuationStatus) {
+				if result.Error != "" {
+					status.Failed++
+				} else {
+					status.Evacuated++
+				}
+				status.Workloads = append(status.Workloads, result)
+			})
+			if result.Error != "" {
+				n.log.Warn("Failed to evacuate workload; it keeps running on this node",
+					slog.String("workload_id", id),
+					slog.String("error", result.Error),
+				)
+			}
+			_ = n.publishEvacuation(status, id)
+		}(id, request)
+	}
+	wg.Wait()
+
+	expired := ctx.Err() != nil
+	status = evacuation.update(func(status *controlapi.EvacuationStatus) {
+		status.State = controlapi.EvacuationStateCompleted
+		if expired {
+			status.State = controlapi.EvacuationStateExpired
+		}
+		completedAt := time.Now().UTC()
+		status.CompletedAt = &completedAt
+	})
+	_ = n.publishEvacuation(status, "")
+
+	n.log.Info("Node evacuation ended",
+		slog.String("state", status.State),
+		slog.Int("evacuated", status.Evacuated),
+		slog.Int("failed", status.Failed),
+	)
+
+	err = n.EnterLameDuck()
+	if err != nil {
+		n.log.Error("Failed to enter lame duck mode after evacuation", slog.Any("err", err))
+	}
+}
+
+// Moves a workload to a peer: the workload is re-auctioned and deployed from its original
+// request, and is only stopped here once its replacement has passed a health probe
+func (n *Node) evacuateWorkload(ctx context.Context, id string, deployRequest *agentapi.DeployRequest) controlapi.EvacuatedWorkload {
+	result := controlapi.EvacuatedWorkload{
+		WorkloadId: id,
+		Name:       *deployRequest.WorkloadName,
+		Namespace:  *deployRequest.Namespace,
+	}
+	fail := func(err error) controlapi.EvacuatedWorkload {
+		result.Error = err.Error()
+		return result
+	}
+
+	if ctx.Err() != nil {
+		return fail(errors.New("evacuation deadline passed"))
+	}
+
+	request, err := redeployRequest(deployRequest)
+	if err != nil {
+		return fail(err)
+	}
+
+	// the environment was sealed for this node, so it is sealed again for whichever peer wins
+	if request.Environment != nil && request.SenderPublicKey != nil {
+		err = n.api.xkeys.decrypt(request)
+		if err != nil {
+			return fail(fmt.Errorf("failed to decrypt workload environment: %s", err))
+		}
+	}
+	senderXkey := n.api.PublicXKey()
+
+	client := controlapi.NewApiClientWithNamespace(n.nc, evacuationRequestTimeout, result.Namespace, n.log)
+	scheduler := client.NewScheduler(controlapi.WithScoring(
+		controlapi.ScoreFreeMemory(1),
+		controlapi.ScoreLatency(1),
+	))
+
+	placement, err := scheduler.Schedule(ctx, n.evacuationAuction(deployRequest), func(candidate controlapi.Candidate) (*controlapi.DeployRequest, error) {
+		if candidate.NodeId == n.publicKey {
+			return nil, errors.New("evacuating node bid in its own auction")
+		}
+
+		placed := *request
+		env, err := controlapi.EncryptRequestEnvironment(n.api.xkeys.key(), candidate.TargetXkey, request.WorkloadEnvironment)
+		if err != nil {
+			return nil, err
+		}
+		placed.Environment = &env
+		placed.SenderPublicKey = &senderXkey
+		placed.TargetNode = &candidate.NodeId
+		return &placed, nil
+	})
+	if err != nil {
+		return fail(fmt.Errorf("failed to place workload on a peer: %s", err))
+	}
+
+	result.TargetNode = placement.Candidate.NodeId
+	result.ReplacementId = placement.Response.ID
+
+	err = awaitEvacuatedReady(ctx, client, result.TargetNode, result.ReplacementId)
+	if err != nil {
+		return fail(err)
+	}
+
+	err = ignoreAlreadyStopped(n.manager.StopWorkload(id, true))
+	if err != nil {
+		n.log.Warn("Failed to stop evacuated workload", slog.String("workload_id", id), slog.Any("err", err))
+	}
+
+	return result
+}
+
+// Auction for a peer able to run the workload the way it runs here. Its artifact was resolved
+// for this node's architecture, so peers must share it
+func (n *Node) evacuationAuction(deployRequest *agentapi.DeployRequest) *controlapi.AuctionRequest {
+	sandboxed := !n.config.NoSandbox
+	auction := &controlapi.AuctionRequest{
+		Sandboxed:     &sandboxed,
+		WorkloadTypes: []controlapi.NexWorkload{deployRequest.WorkloadType},
+		GPUs:          len(deployRequest.GPUDevices),
+	}
+	if arch := n.config.Tags[controlapi.TagArch]; arch != "" {
+		auction.Archs = []string{arch}
+	}
+
+	return auction
+}
+
+// Waits for the replacement of an evacuated workload to pass a health probe on its new node
+func awaitEvacuatedReady(ctx context.Context, client *controlapi.Client, nodeID string, workloadID string) error {
+	probe := time.NewTicker(evacuationProbeInterval)
+	defer probe.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("replacement %s on node %s did not become healthy before the deadline", workloadID, nodeID)
+		case <-probe.C:
+			info, err := client.NodeInfo(ctx, nodeID)
+			if err != nil {
+				continue
+			}
+			for _, machine := range info.Machines {
+				if machine.Id == workloadID && machine.Healthy {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// Evacuates the node in response to a shutdown signal, then shuts it down
+func (n *Node) evacuateAndShutdown(reason string) {
+	_, err := n.Evacuate(reason, 0)
+	if err != nil {
+		n.log.Error("Failed to evacuate node before shutting down", slog.Any("err", err))
+	} else {
+		<-n.currentEvacuation().done
+	}
+
+	n.shutdown()
+}
+
+// Polls the configured notice URL until it reports that the node is about to go away, e.g. a
+// spot instance interruption, and then evacuates the node
+func (n *Node) runEvacuationNotices() {
+	interval := time.Duration(n.config.Evacuation.NoticePollIntervalMillis) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultEvacuationNoticePollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	client := &http.Client{Timeout: interval}
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+			notice, err := n.pollEvacuationNotice(client)
+			if err != nil {
+				n.log.Debug("Failed to poll evacuation notice", slog.Any("err", err))
+				continue
+			}
+			if notice == "" {
+				continue
+			}
+
+			_, err = n.Evacuate(fmt.Sprintf("shutdown notice: %s", notice), 0)
+			if err != nil {
+				n.log.Error("Failed to evacuate node after shutdown notice", slog.Any("err", err))
+			}
+			return
+		}
+	}
+}
+
+// Returns the notice served at the notice URL, or an empty string when there is none
+func (n *Node) pollEvacuationNotice(client *http.Client) (string, error) {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodGet, n.config.Evacuation.NoticeUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	for name, value := range n.config.Evacuation.NoticeHeaders {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+
+	notice := strings.TrimSpace(string(raw))
+	if strings.EqualFold(notice, "false") {
+		return "", nil
+	}
+	if notice == "" {
+		notice = "notice received"
+	}
+
+	return notice, nil
+}
+
+func (n *Node) publishEvacuation(status controlapi.EvacuationStatus, workloadID string) error {
+	evt := controlapi.NodeEvacuationEvent{
+		EvacuationStatus: status,
+		WorkloadId:       workloadID,
+	}
+
+	cloudevent := events.NodeEvacuation(n.publicKey, evt)
+	return PublishCloudEvent(n.events(), systemNamespace, cloudevent, n.log)
+}
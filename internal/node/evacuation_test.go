@@ -0,0 +1,59 @@
+package nexnode
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestEvacuationNoticePolling(t *testing.T) {
+	notice := "FALSE"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if notice == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(notice))
+	}))
+	defer server.Close()
+
+	n := &Node{
+		ctx: context.Background(),
+		config: &models.NodeConfiguration{
+			Evacuation: &models.EvacuationConfig{
+				NoticeUrl:     server.URL,
+				NoticeHeaders: map[string]string{"Metadata-Flavor": "Google"},
+			},
+		},
+	}
+
+	got, err := n.pollEvacuationNotice(server.Client())
+	if err != nil || got != "" {
+		t.Fatalf("Expected FALSE not to be a notice, got %q, %v", got, err)
+	}
+
+	notice = ""
+	got, err = n.pollEvacuationNotice(server.Client())
+	if err != nil || got != "" {
+		t.Fatalf("Expected a 404 not to be a notice, got %q, %v", got, err)
+	}
+
+	notice = `{"action": "terminate", "time": "2026-10-16T08:22:00Z"}`
+	got, err = n.pollEvacuationNotice(server.Client())
+	if err != nil || got != notice {
+		t.Fatalf("Expected the response body to be the notice, got %q, %v", got, err)
+	}
+
+	n.config.Evacuation.NoticeHeaders = nil
+	got, err = n.pollEvacuationNotice(server.Client())
+	if err != nil || got != "" {
+		t.Fatalf("Expected a refused poll not to be a notice, got %q, %v", got, err)
+	}
+}
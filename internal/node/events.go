@@ -5,18 +5,20 @@ import (
 	"log/slog"
 
 	cloudevents "github.com/cloudevents/sdk-go"
-	"github.com/nats-io/nats.go"
 )
 
 // FIXME-- move this to types repo-- audit other places where it is redeclared (nex-cli)
 type emittedLog struct {
-	Text  string     `json:"text"`
-	Level slog.Level `json:"level"`
-	ID    string     `json:"id"`
+	Text   string                 `json:"text"`
+	Level  slog.Level             `json:"level"`
+	ID     string                 `json:"id"`
+	Source string                 `json:"source,omitempty"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
 }
 
-// publish the given $NEX event to an arbitrary namespace using the given NATS connection
-func PublishCloudEvent(nc *nats.Conn, namespace string, event cloudevents.Event, log *slog.Logger) error {
+// publish the given $NEX event to an arbitrary namespace using the given NATS connection, or the
+// outbox in front of it
+func PublishCloudEvent(nc eventPublisher, namespace string, event cloudevents.Event, log *slog.Logger) error {
 
 	raw, err := event.MarshalJSON()
 	if err != nil {
@@ -0,0 +1,60 @@
+package nexnode
+
+import (
+	"sync"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+// Retains the most recent trigger executions of each workload, dropping the oldest record once
+// a workload's history is full. A capacity of zero disables recording
+type executionHistory struct {
+	mutex    sync.Mutex
+	capacity int
+	records  map[string][]controlapi.ExecutionRecord
+}
+
+func newExecutionHistory(capacity int) *executionHistory {
+	return &executionHistory{
+		capacity: capacity,
+		records:  make(map[string][]controlapi.ExecutionRecord),
+	}
+}
+
+func (h *executionHistory) record(workloadID string, record controlapi.ExecutionRecord) {
+	if h.capacity <= 0 {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	records := h.records[workloadID]
+	if len(records) >= h.capacity {
+		records = records[len(records)-h.capacity+1:]
+	}
+	h.records[workloadID] = append(records, record)
+}
+
+// Returns a copy of up to limit of the workload's most recent records, oldest first. A limit of
+// zero returns all of them
+func (h *executionHistory) get(workloadID string, limit int) []controlapi.ExecutionRecord {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	records := h.records[workloadID]
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+
+	result := make([]controlapi.ExecutionRecord, len(records))
+	copy(result, records)
+	return result
+}
+
+func (h *executionHistory) remove(workloadID string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.records, workloadID)
+}
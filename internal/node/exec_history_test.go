@@ -0,0 +1,29 @@
+package nexnode
+
+import (
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+func TestExecutionHistoryRetainsMostRecentRecords(t *testing.T) {
+	history := newExecutionHistory(3)
+	for i := 1; i <= 5; i++ {
+		history.record("wl", controlapi.ExecutionRecord{PayloadBytes: i})
+	}
+
+	records := history.get("wl", 0)
+	if len(records) != 3 || records[0].PayloadBytes != 3 || records[2].PayloadBytes != 5 {
+		t.Fatalf("Expected the 3 most recent records, got %v", records)
+	}
+
+	records = history.get("wl", 2)
+	if len(records) != 2 || records[0].PayloadBytes != 4 {
+		t.Fatalf("Expected the 2 most recent records, got %v", records)
+	}
+
+	history.remove("wl")
+	if len(history.get("wl", 0)) != 0 {
+		t.Fatal("Expected history to be empty after removal")
+	}
+}
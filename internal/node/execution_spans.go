@@ -0,0 +1,85 @@
+package nexnode
+
+import (
+	"slices"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span contexts of the trigger executions in flight for each workload, used to correlate the logs
+// a function writes with the execution that triggered them
+type executionSpans struct {
+	mu       sync.Mutex
+	inflight map[string][]trace.SpanContext
+}
+
+func newExecutionSpans() *executionSpans {
+	return &executionSpans{
+		inflight: make(map[string][]trace.SpanContext),
+	}
+}
+
+// Records the start of an execution, returning a function that records its end
+func (e *executionSpans) begin(workloadID string, sc trace.SpanContext) func() {
+	if !sc.IsValid() {
+		return func() {}
+	}
+
+	e.mu.Lock()
+	e.inflight[workloadID] = append(e.inflight[workloadID], sc)
+	e.mu.Unlock()
+
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		spans := e.inflight[workloadID]
+		if idx := slices.IndexFunc(spans, sc.Equal); idx >= 0 {
+			spans = slices.Delete(spans, idx, idx+1)
+		}
+		if len(spans) == 0 {
+			delete(e.inflight, workloadID)
+		} else {
+			e.inflight[workloadID] = spans
+		}
+	}
+}
+
+// Returns the span context of the workload's execution in flight. Logs cannot be attributed when
+// several executions overlap, so nothing is returned unless exactly one is running
+func (e *executionSpans) current(workloadID string) (trace.SpanContext, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	spans := e.inflight[workloadID]
+	if len(spans) != 1 {
+		return trace.SpanContext{}, false
+	}
+
+	return spans[0], true
+}
+
+// Returns the span context named by the trace_id and span_id fields of a structured log entry,
+// as written by workloads that propagate the trigger's trace context into their own logging
+func spanContextFromFields(fields map[string]interface{}) (trace.SpanContext, bool) {
+	rawTraceID, _ := fields["trace_id"].(string)
+	rawSpanID, _ := fields["span_id"].(string)
+
+	traceID, err := trace.TraceIDFromHex(rawTraceID)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+
+	spanID, err := trace.SpanIDFromHex(rawSpanID)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}), true
+}
@@ -0,0 +1,52 @@
+package nexnode
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestExecutionSpansCorrelateOnlyUnambiguousExecutions(t *testing.T) {
+	first := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}})
+	second := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{2}, SpanID: trace.SpanID{2}})
+
+	spans := newExecutionSpans()
+	if _, ok := spans.current("workload"); ok {
+		t.Fatal("Expected no span without an execution in flight")
+	}
+
+	endFirst := spans.begin("workload", first)
+	if sc, ok := spans.current("workload"); !ok || !sc.Equal(first) {
+		t.Fatalf("Expected the span of the only execution in flight, got %v", sc)
+	}
+
+	endSecond := spans.begin("workload", second)
+	if _, ok := spans.current("workload"); ok {
+		t.Fatal("Expected no span while executions overlap")
+	}
+
+	endFirst()
+	if sc, ok := spans.current("workload"); !ok || !sc.Equal(second) {
+		t.Fatalf("Expected the span of the remaining execution, got %v", sc)
+	}
+
+	endSecond()
+	if len(spans.inflight) != 0 {
+		t.Fatalf("Expected completed executions to be forgotten, got %v", spans.inflight)
+	}
+}
+
+func TestSpanContextFromFields(t *testing.T) {
+	sc, ok := spanContextFromFields(map[string]interface{}{
+		"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id":  "00f067aa0ba902b7",
+	})
+	if !ok || sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("Expected the span context named by the fields, got %v", sc)
+	}
+
+	_, ok = spanContextFromFields(map[string]interface{}{"trace_id": "not-hex"})
+	if ok {
+		t.Fatal("Expected malformed trace fields to be ignored")
+	}
+}
@@ -0,0 +1,168 @@
+//go:build faults
+
+package nexnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+// Injects the faults requested on the $NEX.FAULTS.{node} subject into deploys and agent
+// handshakes. Only nodes built with the faults tag carry one, so that operators and tests can
+// exercise restart policies, reapers and failover deterministically
+type faultInjector struct {
+	mutex  sync.Mutex
+	faults controlapi.InjectedFaults
+}
+
+func newFaultInjector() *faultInjector {
+	return &faultInjector{}
+}
+
+// Replaces the injected faults, returning them
+func (f *faultInjector) set(faults controlapi.InjectedFaults) controlapi.InjectedFaults {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.faults = faults
+	return f.faults
+}
+
+func (f *faultInjector) current() controlapi.InjectedFaults {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.faults
+}
+
+// Reports whether the handshake of the given agent should be treated as failed, using up one
+// of the injected handshake failures
+func (f *faultInjector) failHandshake(_ string) bool {
+	if f == nil {
+		return false
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.faults.HandshakeFailures <= 0 {
+		return false
+	}
+
+	f.faults.HandshakeFailures--
+	return true
+}
+
+// Waits out the injected deploy delay, then returns the rejection standing in for the agent's
+// reply when one of the injected rejections remains, or nil to let the agent reply
+func (f *faultInjector) deployResponse(workloadID string) *agentapi.DeployResponse {
+	if f == nil {
+		return nil
+	}
+
+	f.mutex.Lock()
+	delay := time.Duration(f.faults.DeployDelayMillis) * time.Millisecond
+	f.mutex.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.faults.DeployRejections <= 0 {
+		return nil
+	}
+	f.faults.DeployRejections--
+
+	reason := f.faults.RejectionReason
+	if reason == "" {
+		reason = controlapi.DeployRejectionFailed
+	}
+
+	message := fmt.Sprintf("injected deploy rejection of workload %s", workloadID)
+	return &agentapi.DeployResponse{
+		Accepted: false,
+		Reason:   reason,
+		Message:  &message,
+	}
+}
+
+// Kills the agent processes of the given workloads without undeploying them, leaving the node to
+// notice as it would a crash. Returns the IDs of the workloads whose processes were killed
+func (w *WorkloadManager) crashWorkloads(ids []string) []string {
+	crashed := make([]string, 0, len(ids))
+	for _, id := range ids {
+		w.teardownMutex.Lock()
+		err := w.procMan.StopProcess(id)
+		w.teardownMutex.Unlock()
+		if err != nil {
+			w.log.Warn("Failed to crash workload", slog.String("workload_id", id), slog.Any("err", err))
+			continue
+		}
+
+		w.log.Warn("Crashed workload by injected fault", slog.String("workload_id", id))
+		crashed = append(crashed, id)
+	}
+
+	return crashed
+}
+
+func (api *ApiListener) subscribeFaultInjection() {
+	sub, err := api.node.nc.Subscribe(controlapi.APIPrefix+".FAULTS."+api.PublicKey(), api.audited(api.handleFaults))
+	if err != nil {
+		api.log.Error("Failed to subscribe to faults subject", slog.Any("error", err), slog.String("id", api.PublicKey()))
+	}
+	api.subz = append(api.subz, sub)
+
+	api.log.Warn("Fault injection enabled; this node was built for testing")
+}
+
+// $NEX.FAULTS.{node}
+func (api *ApiListener) handleFaults(m *apiRequest) {
+	var request controlapi.FaultInjectionRequest
+	if len(m.Data) > 0 {
+		err := json.Unmarshal(m.Data, &request)
+		if err != nil {
+			api.log.Error("Failed to deserialize fault injection request", slog.Any("err", err))
+			respondFail(controlapi.FaultsResponseType, m, fmt.Sprintf("Unable to deserialize fault injection request: %s", err))
+			return
+		}
+	}
+
+	if authErr := api.authorizeIdentity(m, systemNamespace, controlapi.OperationUpdate); authErr != nil {
+		respondUnauthorized(controlapi.FaultsResponseType, m, authErr)
+		return
+	}
+
+	api.mgr.faults.set(request.Faults)
+	api.log.Warn("Replaced injected faults",
+		slog.Int64("deploy_delay_ms", request.Faults.DeployDelayMillis),
+		slog.Int("handshake_failures", request.Faults.HandshakeFailures),
+		slog.Int("deploy_rejections", request.Faults.DeployRejections),
+		slog.Int("crash", len(request.Crash)),
+	)
+
+	crashed := api.mgr.crashWorkloads(request.Crash)
+
+	res := controlapi.NewEnvelope(controlapi.FaultsResponseType, controlapi.FaultInjectionResponse{
+		NodeId:  api.PublicKey(),
+		Faults:  api.mgr.faults.current(),
+		Crashed: crashed,
+	}, nil)
+
+	raw, err := json.Marshal(res)
+	if err != nil {
+		api.log.Error("Failed to serialize response", slog.Any("error", err))
+		respondFail(controlapi.FaultsResponseType, m, "Serialization failure")
+	} else {
+		_ = m.Respond(raw)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !faults
+
+package nexnode
+
+import agentapi "github.com/synadia-io/nex/internal/agent-api"
+
+// Nodes built without the faults tag inject no faults and don't answer fault injection requests
+type faultInjector struct{}
+
+func newFaultInjector() *faultInjector {
+	return nil
+}
+
+func (f *faultInjector) failHandshake(_ string) bool {
+	return false
+}
+
+func (f *faultInjector) deployResponse(_ string) *agentapi.DeployResponse {
+	return nil
+}
+
+func (api *ApiListener) subscribeFaultInjection() {}
@@ -0,0 +1,53 @@
+//go:build faults
+
+package nexnode
+
+import (
+	"testing"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+func TestFaultInjectorUsesUpCountedFaults(t *testing.T) {
+	f := newFaultInjector()
+	f.set(controlapi.InjectedFaults{HandshakeFailures: 1, DeployRejections: 1})
+
+	if !f.failHandshake("a") || f.failHandshake("b") {
+		t.Fatal("Expected exactly one handshake to fail")
+	}
+
+	resp := f.deployResponse("wl")
+	if resp == nil || resp.Accepted {
+		t.Fatal("Expected the first deploy to be rejected")
+	}
+	if rejection := agentRejection(resp); rejection.Reason != controlapi.DeployRejectionFailed {
+		t.Fatalf("Expected the rejection to default to deploy_failed, got %s", rejection.Reason)
+	}
+	if f.deployResponse("wl") != nil {
+		t.Fatal("Expected later deploys to reach the agent")
+	}
+
+	if remaining := f.current(); remaining.HandshakeFailures != 0 || remaining.DeployRejections != 0 {
+		t.Fatalf("Expected the counted faults to be used up, got %+v", remaining)
+	}
+}
+
+func TestFaultInjectorDelaysDeploys(t *testing.T) {
+	f := newFaultInjector()
+	f.set(controlapi.InjectedFaults{DeployDelayMillis: 50, DeployRejections: 1, RejectionReason: controlapi.DeployRejectionResourceExhausted})
+
+	start := time.Now()
+	resp := f.deployResponse("wl")
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("Expected the deploy to be delayed")
+	}
+	if resp == nil || resp.Reason != controlapi.DeployRejectionResourceExhausted {
+		t.Fatalf("Expected a resource_exhausted rejection, got %+v", resp)
+	}
+
+	f.set(controlapi.InjectedFaults{})
+	if f.deployResponse("wl") != nil {
+		t.Fatal("Expected cleared faults not to reject deploys")
+	}
+}
@@ -0,0 +1,143 @@
+package nexnode
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	nvidiaDriverInfoGlob = "/proc/driver/nvidia/gpus/*/information"
+
+	// Environment variables through which a no sandbox workload is told the GPUs assigned to it
+	cudaVisibleDevicesEnv   = "CUDA_VISIBLE_DEVICES"
+	nvidiaVisibleDevicesEnv = "NVIDIA_VISIBLE_DEVICES"
+)
+
+var nvidiaDevicePattern = regexp.MustCompile(`^nvidia(\d+)$`)
+
+// Returns the indexes of the NVIDIA GPUs present on the host, along with the model of the first
+// of them if the driver reports one
+func detectGPUs() ([]int, string) {
+	if !strings.EqualFold(runtime.GOOS, "linux") {
+		return nil, ""
+	}
+
+	entries, err := os.ReadDir("/dev")
+	if err != nil {
+		return nil, ""
+	}
+
+	devices := make([]int, 0)
+	for _, entry := range entries {
+		match := nvidiaDevicePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		index, err := strconv.Atoi(match[1])
+		if err == nil {
+			devices = append(devices, index)
+		}
+	}
+	sort.Ints(devices)
+
+	model := ""
+	if infos, _ := filepath.Glob(nvidiaDriverInfoGlob); len(infos) > 0 {
+		if info, err := os.ReadFile(infos[0]); err == nil {
+			for _, line := range strings.Split(string(info), "\n") {
+				if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "Model" {
+					model = strings.TrimSpace(value)
+					break
+				}
+			}
+		}
+	}
+
+	return devices, model
+}
+
+// Assigns the host's GPUs exclusively to the workloads requesting them
+type gpuAllocator struct {
+	mutex    sync.Mutex
+	devices  []int
+	model    string
+	assigned map[string][]int
+}
+
+func newGPUAllocator(devices []int, model string) *gpuAllocator {
+	return &gpuAllocator{
+		devices:  devices,
+		model:    model,
+		assigned: make(map[string][]int),
+	}
+}
+
+// Assigns count unassigned GPUs to the workload, lowest index first
+func (g *gpuAllocator) allocate(workloadID string, count int) ([]int, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	inUse := make(map[int]bool)
+	for _, devices := range g.assigned {
+		for _, device := range devices {
+			inUse[device] = true
+		}
+	}
+
+	allocated := make([]int, 0, count)
+	for _, device := range g.devices {
+		if len(allocated) == count {
+			break
+		}
+		if !inUse[device] {
+			allocated = append(allocated, device)
+		}
+	}
+
+	if len(allocated) < count {
+		return nil, fmt.Errorf("insufficient gpu capacity; %d required, %d available", count, len(allocated))
+	}
+
+	g.assigned[workloadID] = allocated
+	return allocated, nil
+}
+
+func (g *gpuAllocator) release(workloadID string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	delete(g.assigned, workloadID)
+}
+
+// Returns the number of GPUs on the node and the number not assigned to any workload
+func (g *gpuAllocator) counts() (int, int) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	assigned := 0
+	for _, devices := range g.assigned {
+		assigned += len(devices)
+	}
+
+	return len(g.devices), len(g.devices) - assigned
+}
+
+// Formats device indexes as the comma-separated list expected by the CUDA runtime
+func visibleDevices(devices []int) string {
+	indexes := make([]string, len(devices))
+	for i, device := range devices {
+		indexes[i] = strconv.Itoa(device)
+	}
+	return strings.Join(indexes, ",")
+}
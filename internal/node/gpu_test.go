@@ -0,0 +1,26 @@
+package nexnode
+
+import "testing"
+
+func TestGPUAllocatorAssignsDevicesExclusively(t *testing.T) {
+	gpus := newGPUAllocator([]int{0, 1, 2}, "")
+
+	first, err := gpus.allocate("wl1", 2)
+	if err != nil || visibleDevices(first) != "0,1" {
+		t.Fatalf("Expected devices 0,1, got %v (%v)", first, err)
+	}
+
+	if _, err := gpus.allocate("wl2", 2); err == nil {
+		t.Fatal("Expected allocation beyond the available gpus to fail")
+	}
+
+	gpus.release("wl1")
+	second, err := gpus.allocate("wl2", 3)
+	if err != nil || len(second) != 3 {
+		t.Fatalf("Expected all 3 devices after release, got %v (%v)", second, err)
+	}
+
+	if total, available := gpus.counts(); total != 3 || available != 0 {
+		t.Fatalf("Expected 3 gpus with none available, got %d/%d", total, available)
+	}
+}
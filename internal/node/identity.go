@@ -0,0 +1,321 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/events"
+)
+
+const defaultKeyRotationGrace = time.Hour
+
+// Keys persisted in the node's key file
+type nodeKeyFile struct {
+	NodeSeed          string     `json:"node_seed"`
+	XKeySeed          string     `json:"xkey_seed"`
+	PreviousXKeySeed  string     `json:"previous_xkey_seed,omitempty"`
+	PreviousXKeyUntil *time.Time `json:"previous_xkey_until,omitempty"`
+}
+
+// The node's xkey, and the key it replaced for as long as the rotation grace period lasts
+type nodeXKeys struct {
+	mu            sync.RWMutex
+	current       nkeys.KeyPair
+	previous      nkeys.KeyPair
+	previousUntil time.Time
+}
+
+// Creates the node's xkeys from their seeds; an empty seed generates a new xkey
+func newNodeXKeys(seed string, previousSeed string, previousUntil *time.Time) (*nodeXKeys, error) {
+	var current nkeys.KeyPair
+	var err error
+	if seed != "" {
+		current, err = nkeys.FromCurveSeed([]byte(seed))
+	} else {
+		current, err = nkeys.CreateCurveKeys()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := &nodeXKeys{current: current}
+	if previousSeed != "" && previousUntil != nil && time.Now().Before(*previousUntil) {
+		keys.previous, err = nkeys.FromCurveSeed([]byte(previousSeed))
+		if err != nil {
+			return nil, err
+		}
+		keys.previousUntil = *previousUntil
+	}
+
+	return keys, nil
+}
+
+func (k *nodeXKeys) key() nkeys.KeyPair {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.current
+}
+
+func (k *nodeXKeys) publicKey() string {
+	pk, _ := k.key().PublicKey()
+	return pk
+}
+
+// Returns the replaced xkey and the end of its grace period, or nil once the period has ended
+func (k *nodeXKeys) previousKey(now time.Time) (nkeys.KeyPair, time.Time) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.previous == nil || !now.Before(k.previousUntil) {
+		return nil, time.Time{}
+	}
+	return k.previous, k.previousUntil
+}
+
+// Replaces the current xkey, which is accepted until the given time, returning it
+func (k *nodeXKeys) rotate(next nkeys.KeyPair, until time.Time) nkeys.KeyPair {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	previous := k.current
+	k.current = next
+	k.previous = previous
+	k.previousUntil = until
+
+	return previous
+}
+
+// Decrypts the request's environment with the node's xkey or, during the grace period, the one
+// it replaced. An environment opened with the replaced key is sealed again for the current one,
+// so that the environment retained for redeploying the workload outlives the grace period
+func (k *nodeXKeys) decrypt(request *controlapi.DeployRequest) error {
+	current := k.key()
+	err := request.DecryptRequestEnvironment(current)
+	if err == nil {
+		return nil
+	}
+
+	previous, _ := k.previousKey(time.Now())
+	if previous == nil || request.DecryptRequestEnvironment(previous) != nil {
+		return err
+	}
+
+	publicKey, _ := current.PublicKey()
+	env, err := controlapi.EncryptRequestEnvironment(current, publicKey, request.WorkloadEnvironment)
+	if err != nil {
+		return err
+	}
+	request.Environment = &env
+	request.SenderPublicKey = &publicKey
+
+	return nil
+}
+
+// Reads the node's key file, or returns nil if it does not exist. The file holds the node's
+// secrets, so it is refused when other users may access it
+func readNodeKeyFile(path string) (*nodeKeyFile, error) {
+	finfo, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// file permissions are not enforced on windows
+	if runtime.GOOS != "windows" && finfo.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("key file %s must not be accessible to other users (mode %s)", path, finfo.Mode().Perm())
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys nodeKeyFile
+	err = json.Unmarshal(raw, &keys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %s", path, err)
+	}
+	if keys.NodeSeed == "" || keys.XKeySeed == "" {
+		return nil, fmt.Errorf("key file %s is missing the node's seeds", path)
+	}
+
+	return &keys, nil
+}
+
+// Writes the key file readable only by the node's user, replacing it atomically so that a crash
+// never leaves the node without its keys
+func writeNodeKeyFile(path string, keys *nodeKeyFile) error {
+	raw, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".nexkeys-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = tmp.Chmod(0600)
+	if err == nil {
+		_, err = tmp.Write(raw)
+	}
+	err = errors.Join(err, tmp.Close())
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Loads the node's keys from its key file, creating the file with the given keypair and a new
+// xkey on first start, and returns the persisted keypair
+func (n *Node) loadIdentity(keypair nkeys.KeyPair) (nkeys.KeyPair, error) {
+	path := n.config.Identity.KeyFile
+
+	keys, err := readNodeKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if keys == nil {
+		nodeSeed, err := keypair.Seed()
+		if err != nil {
+			return nil, err
+		}
+
+		xk, err := nkeys.CreateCurveKeys()
+		if err != nil {
+			return nil, err
+		}
+		xkeySeed, _ := xk.Seed()
+
+		keys = &nodeKeyFile{NodeSeed: string(nodeSeed), XKeySeed: string(xkeySeed)}
+		err = writeNodeKeyFile(path, keys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create key file %s: %s", path, err)
+		}
+		n.log.Info("Created node key file", slog.String("path", path))
+	}
+
+	kp, err := nkeys.FromSeed([]byte(keys.NodeSeed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid node seed in key file %s: %s", path, err)
+	}
+
+	n.keys = keys
+	return kp, nil
+}
+
+// Replaces the node's xkey. The replaced key keeps decrypting payloads until the grace period
+// ends, zero using the configured period, and the environments retained for redeploying the
+// node's workloads are sealed again for the new key. The new key is persisted before it is used,
+// when the node has a key file, and announced with an event
+func (n *Node) RotateKeys(grace time.Duration) (*controlapi.RotateKeysResponse, error) {
+	n.keysMutex.Lock()
+	defer n.keysMutex.Unlock()
+
+	if grace <= 0 {
+		grace = defaultKeyRotationGrace
+		if n.config.Identity != nil && n.config.Identity.RotationGraceMillis > 0 {
+			grace = time.Duration(n.config.Identity.RotationGraceMillis) * time.Millisecond
+		}
+	}
+	until := time.Now().UTC().Add(grace)
+
+	next, err := nkeys.CreateCurveKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	if n.keys != nil {
+		nextSeed, _ := next.Seed()
+		previousSeed, _ := n.api.xkeys.key().Seed()
+
+		keys := *n.keys
+		keys.XKeySeed = string(nextSeed)
+		keys.PreviousXKeySeed = string(previousSeed)
+		keys.PreviousXKeyUntil = &until
+
+		err = writeNodeKeyFile(n.config.Identity.KeyFile, &keys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to persist rotated keys: %s", err)
+		}
+		n.keys = &keys
+	}
+
+	previous := n.api.xkeys.rotate(next, until)
+	n.manager.resealEnvironments(previous, next)
+
+	previousPublicKey, _ := previous.PublicKey()
+	response := &controlapi.RotateKeysResponse{
+		NodeId:             n.publicKey,
+		PublicXKey:         n.api.PublicXKey(),
+		PreviousPublicXKey: previousPublicKey,
+		GraceUntil:         until,
+		Persisted:          n.keys != nil,
+	}
+
+	n.log.Info("Rotated node xkey",
+		slog.String("public_xkey", response.PublicXKey),
+		slog.String("previous_public_xkey", response.PreviousPublicXKey),
+		slog.Time("grace_until", until),
+	)
+
+	cloudevent := events.NodeKeysRotated(n.publicKey, controlapi.NodeKeysRotatedEvent{RotateKeysResponse: *response})
+	_ = PublishCloudEvent(n.events(), systemNamespace, cloudevent, n.log)
+
+	return response, nil
+}
+
+// Seals the environments retained for redeploying the node's workloads, which were sealed for
+// the replaced xkey, for the node's new xkey
+func (w *WorkloadManager) resealEnvironments(previous nkeys.KeyPair, next nkeys.KeyPair) {
+	procs, err := w.procMan.ListProcesses()
+	if err != nil {
+		w.log.Warn("Failed to list workloads to reseal their environments", slog.Any("err", err))
+		return
+	}
+
+	publicKey, _ := next.PublicKey()
+	for _, proc := range procs {
+		request := proc.DeployRequest
+		if request == nil || request.EncryptedEnvironment == nil || request.SenderPublicKey == nil {
+			continue
+		}
+
+		sealed := &controlapi.DeployRequest{
+			Environment:     request.EncryptedEnvironment,
+			SenderPublicKey: request.SenderPublicKey,
+		}
+		err := sealed.DecryptRequestEnvironment(previous)
+		if err != nil {
+			w.log.Warn("Failed to open workload environment sealed for the replaced xkey", slog.String("workload_id", proc.ID), slog.Any("err", err))
+			continue
+		}
+
+		env, err := controlapi.EncryptRequestEnvironment(next, publicKey, sealed.WorkloadEnvironment)
+		if err != nil {
+			continue
+		}
+		request.EncryptedEnvironment = &env
+		request.SenderPublicKey = &publicKey
+	}
+}
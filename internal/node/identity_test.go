@@ -0,0 +1,98 @@
+package nexnode
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+func TestNodeKeyFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "node.keys")
+	until := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+
+	keys := &nodeKeyFile{NodeSeed: "SNAseed", XKeySeed: "SXAseed", PreviousXKeySeed: "SXAprevious", PreviousXKeyUntil: &until}
+	err := writeNodeKeyFile(path, keys)
+	if err != nil {
+		t.Fatalf("Expected to write key file, got %s", err)
+	}
+
+	got, err := readNodeKeyFile(path)
+	if err != nil {
+		t.Fatalf("Expected to read key file, got %s", err)
+	}
+	if got.NodeSeed != keys.NodeSeed || got.XKeySeed != keys.XKeySeed || got.PreviousXKeySeed != keys.PreviousXKeySeed || !got.PreviousXKeyUntil.Equal(until) {
+		t.Fatalf("Expected key file to round trip, got %+v", got)
+	}
+
+	missing, err := readNodeKeyFile(filepath.Join(t.TempDir(), "missing.keys"))
+	if missing != nil || err != nil {
+		t.Fatalf("Expected a missing key file to be ignored, got %+v, %v", missing, err)
+	}
+}
+
+func TestNodeKeyFileRefusedWhenShared(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not enforced on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "node.keys")
+	err := writeNodeKeyFile(path, &nodeKeyFile{NodeSeed: "SNAseed", XKeySeed: "SXAseed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chmod(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = readNodeKeyFile(path)
+	if err == nil || !strings.Contains(err.Error(), "must not be accessible") {
+		t.Fatalf("Expected shared key file to be refused, got %v", err)
+	}
+}
+
+func TestNodeXKeysDecryptDuringGrace(t *testing.T) {
+	original, _ := nkeys.CreateCurveKeys()
+	seed, _ := original.Seed()
+	keys, err := newNodeXKeys(string(seed), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousPublicKey := keys.publicKey()
+
+	sender, _ := nkeys.CreateCurveKeys()
+	senderPublicKey, _ := sender.PublicKey()
+	env, _ := controlapi.EncryptRequestEnvironment(sender, previousPublicKey, map[string]string{"SECRET": "value"})
+
+	next, _ := nkeys.CreateCurveKeys()
+	keys.rotate(next, time.Now().Add(time.Hour))
+
+	request := &controlapi.DeployRequest{Environment: &env, SenderPublicKey: &senderPublicKey}
+	err = keys.decrypt(request)
+	if err != nil {
+		t.Fatalf("Expected environment sealed for the previous xkey to be decrypted, got %s", err)
+	}
+	if request.WorkloadEnvironment["SECRET"] != "value" {
+		t.Fatalf("Expected decrypted environment, got %+v", request.WorkloadEnvironment)
+	}
+
+	// the environment is sealed again for the current xkey, which outlives the grace period
+	resealed := &controlapi.DeployRequest{Environment: request.Environment, SenderPublicKey: request.SenderPublicKey}
+	err = resealed.DecryptRequestEnvironment(next)
+	if err != nil || resealed.WorkloadEnvironment["SECRET"] != "value" {
+		t.Fatalf("Expected environment to be sealed for the current xkey, got %v", err)
+	}
+
+	keys = &nodeXKeys{current: next, previous: original, previousUntil: time.Now().Add(-time.Second)}
+	expired := &controlapi.DeployRequest{Environment: &env, SenderPublicKey: &senderPublicKey}
+	if keys.decrypt(expired) == nil {
+		t.Fatal("Expected the replaced xkey to be refused once the grace period ends")
+	}
+}
@@ -2,6 +2,8 @@ package nexnode
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -65,9 +67,36 @@ func CmdPreflight(opts *nexmodels.Options, nodeopts *nexmodels.NodeOptions, ctx
 
 	config.ForceDepInstall = nodeopts.ForceDepInstall
 
-	err = CheckPrerequisites(config, false, log)
+	if !nodeopts.PreflightJSON {
+		err = CheckPrerequisites(config, false, log)
+		if err != nil {
+			return fmt.Errorf("preflight checks failed: %s", err)
+		}
+	}
+
+	nc, err := nexmodels.GenerateConnectionFromOpts(opts, log)
 	if err != nil {
-		return fmt.Errorf("preflight checks failed: %s", err)
+		log.Debug("Failed to connect to NATS for preflight", slog.Any("err", err))
+	} else {
+		defer nc.Close()
+	}
+
+	report := RunPreflightChecks(config, nc)
+
+	if nodeopts.PreflightJSON {
+		raw, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(raw))
+	} else {
+		for _, check := range report.Checks {
+			fmt.Printf("%s\t%s\t%s\n", check.Status, check.Name, check.Detail)
+		}
+	}
+
+	if !report.Passed {
+		return errors.New("preflight checks failed")
 	}
 
 	return nil
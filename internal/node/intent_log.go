@@ -0,0 +1,417 @@
+package nexnode
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+// Number of records appended to the intent log before it is compacted down to the records of
+// unresolved operations
+const intentLogCompactionThreshold = 1024
+
+type intentOperation string
+
+const (
+	intentDeployStarted        intentOperation = "deploy_started"
+	intentSubscriptionsCreated intentOperation = "subscriptions_created"
+	intentDeployCompleted      intentOperation = "deploy_completed"
+	intentStopStarted          intentOperation = "stop_started"
+	intentStopCompleted        intentOperation = "stop_completed"
+)
+
+// A single entry in the intent log, written before the step it describes takes effect
+type intentRecord struct {
+	Operation  intentOperation `json:"op"`
+	WorkloadID string          `json:"workload_id"`
+	Namespace  string          `json:"namespace,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Pid        int             `json:"pid,omitempty"`
+	Subjects   []string        `json:"subjects,omitempty"`
+	Time       time.Time       `json:"time"`
+}
+
+// The state of a workload whose deployment has begun but whose stop has not completed
+type workloadIntent struct {
+	WorkloadID string
+	Namespace  string
+	Name       string
+	Pid        int
+	Subjects   []string
+	Deployed   bool
+	Stopping   bool
+}
+
+// Write-ahead log of in-flight deploy and stop operations. After a crash, the workloads left in
+// the log are those whose agent processes may have outlived the node, which recovery terminates
+type intentLog struct {
+	mu       sync.Mutex
+	path     string
+	f        *os.File
+	records  int
+	inflight map[string]*workloadIntent
+}
+
+// Returns the path of the file holding the intent log
+func intentLogFilepath(config *models.NodeConfiguration) string {
+	if config.IntentLogFilepath != "" {
+		return config.IntentLogFilepath
+	}
+
+	if config.DefaultResourceDir != "" {
+		return filepath.Join(config.DefaultResourceDir, "intents.log")
+	}
+
+	return filepath.Join(os.TempDir(), "nex-intents.log")
+}
+
+// Opens the intent log at the given path, returning the operations left unresolved by the
+// previous run. Those operations remain in the log until the caller records their stop
+func openIntentLog(path string) (*intentLog, []*workloadIntent, error) {
+	unresolved, err := readIntents(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log := &intentLog{
+		path:     path,
+		inflight: make(map[string]*workloadIntent),
+	}
+	for _, intent := range unresolved {
+		clone := *intent
+		log.inflight[intent.WorkloadID] = &clone
+	}
+
+	err = log.compact()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return log, unresolved, nil
+}
+
+// Replays the intent log at the given path; a missing file yields no operations. A truncated
+// final record, as left by a crash mid-write, is ignored
+func readIntents(path string) ([]*workloadIntent, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	inflight := make(map[string]*workloadIntent)
+	order := make([]string, 0)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record intentRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+
+		if _, ok := inflight[record.WorkloadID]; !ok && record.Operation == intentDeployStarted {
+			order = append(order, record.WorkloadID)
+		}
+		applyIntent(inflight, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read intent log %s: %s", path, err)
+	}
+
+	unresolved := make([]*workloadIntent, 0, len(inflight))
+	for _, id := range order {
+		if intent, ok := inflight[id]; ok {
+			unresolved = append(unresolved, intent)
+			delete(inflight, id)
+		}
+	}
+
+	return unresolved, nil
+}
+
+// Folds a record into the state of the in-flight operations
+func applyIntent(inflight map[string]*workloadIntent, record intentRecord) {
+	if record.Operation == intentDeployStarted {
+		inflight[record.WorkloadID] = &workloadIntent{
+			WorkloadID: record.WorkloadID,
+			Namespace:  record.Namespace,
+			Name:       record.Name,
+			Pid:        record.Pid,
+		}
+		return
+	}
+
+	intent, ok := inflight[record.WorkloadID]
+	if !ok {
+		return
+	}
+
+	switch record.Operation {
+	case intentSubscriptionsCreated:
+		intent.Subjects = append(intent.Subjects, record.Subjects...)
+	case intentDeployCompleted:
+		intent.Deployed = true
+	case intentStopStarted:
+		intent.Stopping = true
+	case intentStopCompleted:
+		delete(inflight, record.WorkloadID)
+	}
+}
+
+// Durably appends a record to the log. Failures are returned but leave the log usable
+func (l *intentLog) record(record intentRecord) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if record.Operation != intentDeployStarted {
+		if _, ok := l.inflight[record.WorkloadID]; !ok {
+			// stops of agents that never received a workload need no recovery
+			return nil
+		}
+	}
+
+	record.Time = time.Now().UTC()
+	applyIntent(l.inflight, record)
+
+	err := l.append(record)
+	if err != nil {
+		return err
+	}
+
+	l.records++
+	if l.records >= intentLogCompactionThreshold {
+		return l.compact()
+	}
+
+	return nil
+}
+
+func (l *intentLog) append(record intentRecord) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = l.f.Write(append(raw, '\n'))
+	if err != nil {
+		return err
+	}
+
+	return l.f.Sync()
+}
+
+// Rewrites the log with only the records needed to describe the in-flight operations
+func (l *intentLog) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), ".intents-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	records := 0
+	w := bufio.NewWriter(tmp)
+	for _, intent := range l.inflight {
+		for _, record := range intent.records() {
+			raw, _ := json.Marshal(record)
+			_, _ = w.Write(append(raw, '\n'))
+			records++
+		}
+	}
+
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), l.path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if l.f != nil {
+		_ = l.f.Close()
+	}
+	l.f = f
+	l.records = records
+	return nil
+}
+
+func (l *intentLog) close() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_ = l.f.Close()
+}
+
+// Reproduces the records from which this state was folded
+func (i *workloadIntent) records() []intentRecord {
+	now := time.Now().UTC()
+	records := []intentRecord{{
+		Operation:  intentDeployStarted,
+		WorkloadID: i.WorkloadID,
+		Namespace:  i.Namespace,
+		Name:       i.Name,
+		Pid:        i.Pid,
+		Time:       now,
+	}}
+
+	if len(i.Subjects) > 0 {
+		records = append(records, intentRecord{Operation: intentSubscriptionsCreated, WorkloadID: i.WorkloadID, Subjects: i.Subjects, Time: now})
+	}
+	if i.Deployed {
+		records = append(records, intentRecord{Operation: intentDeployCompleted, WorkloadID: i.WorkloadID, Time: now})
+	}
+	if i.Stopping {
+		records = append(records, intentRecord{Operation: intentStopStarted, WorkloadID: i.WorkloadID, Time: now})
+	}
+
+	return records
+}
+
+// Records a step of a deploy or stop in the intent log. Failing to record it only weakens crash
+// recovery, so the operation proceeds regardless
+func (w *WorkloadManager) recordIntent(record intentRecord) {
+	err := w.intents.record(record)
+	if err != nil {
+		w.log.Warn("Failed to record intent",
+			slog.String("workload_id", record.WorkloadID),
+			slog.String("operation", string(record.Operation)),
+			slog.Any("err", err),
+		)
+	}
+}
+
+// Returns the process ID of the given workload's agent, or 0 if unknown
+func (w *WorkloadManager) agentPid(workloadID string) int {
+	procs, err := w.procMan.ListProcesses()
+	if err != nil {
+		return 0
+	}
+
+	for _, proc := range procs {
+		if proc.ID == workloadID {
+			return proc.Pid
+		}
+	}
+
+	return 0
+}
+
+// Terminates the agent processes of operations left in flight by a crash of the previous run.
+// A node cannot adopt the agents of its predecessor, so interrupted deploys are rolled back,
+// interrupted stops are completed, and workloads that were running are stopped alike. Trigger
+// subscriptions are held by the node's own connections and were released when the crashed node
+// lost them; they are logged so operators can account for any messages missed meanwhile
+func (w *WorkloadManager) recoverIntents(unresolved []*workloadIntent) {
+	for _, intent := range unresolved {
+		w.recoverIntent(intent)
+
+		// whether or not a process was found, there is nothing further to recover
+		_ = w.intents.record(intentRecord{Operation: intentStopCompleted, WorkloadID: intent.WorkloadID})
+	}
+}
+
+func (w *WorkloadManager) recoverIntent(intent *workloadIntent) {
+	action := "rolling back interrupted deploy"
+	if intent.Stopping {
+		action = "completing interrupted stop"
+	} else if intent.Deployed {
+		action = "stopping workload orphaned by crash"
+	}
+
+	w.log.Warn("Recovering operation left in flight by previous node run",
+		slog.String("workload_id", intent.WorkloadID),
+		slog.String("namespace", intent.Namespace),
+		slog.String("workload_name", intent.Name),
+		slog.String("action", action),
+	)
+
+	if len(intent.Subjects) > 0 {
+		w.log.Warn("Trigger subscriptions of recovered workload were released with the previous node's connection",
+			slog.String("workload_id", intent.WorkloadID),
+			slog.String("subjects", strings.Join(intent.Subjects, ",")),
+		)
+	}
+
+	if intent.Pid <= 0 {
+		return
+	}
+
+	terminated, err := terminateOrphanedAgent(intent.Pid)
+	if err != nil {
+		w.log.Warn("Failed to terminate orphaned agent process",
+			slog.String("workload_id", intent.WorkloadID),
+			slog.Int("pid", intent.Pid),
+			slog.Any("err", err),
+		)
+		return
+	}
+
+	if terminated {
+		w.log.Info("Terminated orphaned agent process",
+			slog.String("workload_id", intent.WorkloadID),
+			slog.Int("pid", intent.Pid),
+		)
+	}
+}
+
+// Kills the given process if it is still an agent process, reporting whether it was killed.
+// Process IDs are reused, so the command line is checked first; processes that cannot be
+// verified, including on platforms without procfs, are left alone
+func terminateOrphanedAgent(pid int) (bool, error) {
+	cmdline, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to verify process: %s", err)
+	}
+
+	command := filepath.Base(strings.SplitN(string(cmdline), "\x00", 2)[0])
+	if command != "firecracker" && command != "jailer" && command != "nex-agent" {
+		return false, nil
+	}
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false, err
+	}
+
+	return true, proc.Kill()
+}
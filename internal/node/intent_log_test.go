@@ -0,0 +1,68 @@
+package nexnode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIntentLogReplaysUnresolvedOperations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intents.log")
+
+	log, unresolved, err := openIntentLog(path)
+	if err != nil {
+		t.Fatalf("Failed to open intent log: %s", err)
+	}
+	if len(unresolved) != 0 {
+		t.Fatalf("Expected a new log to have no unresolved operations, got %d", len(unresolved))
+	}
+
+	records := []intentRecord{
+		{Operation: intentDeployStarted, WorkloadID: "stopped", Namespace: "default", Pid: 10},
+		{Operation: intentDeployCompleted, WorkloadID: "stopped"},
+		{Operation: intentStopStarted, WorkloadID: "stopped"},
+		{Operation: intentStopCompleted, WorkloadID: "stopped"},
+		{Operation: intentDeployStarted, WorkloadID: "interrupted", Namespace: "default", Pid: 11},
+		{Operation: intentSubscriptionsCreated, WorkloadID: "interrupted", Subjects: []string{"orders.>"}},
+		{Operation: intentStopStarted, WorkloadID: "pending-agent"},
+	}
+	for _, record := range records {
+		err = log.record(record)
+		if err != nil {
+			t.Fatalf("Failed to record intent: %s", err)
+		}
+	}
+	log.close()
+
+	// a crash mid-write leaves a partial final record
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	_, _ = f.WriteString(`{"op":"deploy_comp`)
+	f.Close()
+
+	log, unresolved, err = openIntentLog(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen intent log: %s", err)
+	}
+	defer log.close()
+
+	if len(unresolved) != 1 {
+		t.Fatalf("Expected exactly one unresolved operation, got %d", len(unresolved))
+	}
+
+	intent := unresolved[0]
+	if intent.WorkloadID != "interrupted" || intent.Pid != 11 || intent.Deployed || len(intent.Subjects) != 1 {
+		t.Fatalf("Unexpected unresolved operation: %+v", intent)
+	}
+
+	// the operation stays in the log until its recovery is recorded
+	replayed, err := readIntents(path)
+	if err != nil || len(replayed) != 1 {
+		t.Fatalf("Expected the unresolved operation to be retained, got %d (%v)", len(replayed), err)
+	}
+
+	_ = log.record(intentRecord{Operation: intentStopCompleted, WorkloadID: "interrupted"})
+	replayed, _ = readIntents(path)
+	if len(replayed) != 0 {
+		t.Fatalf("Expected no unresolved operations after recovery, got %d", len(replayed))
+	}
+}
@@ -10,12 +10,21 @@ type internalServerData struct {
 	Credentials       map[string]*credentials
 	NexHostUserPublic string
 	NexHostUserSeed   string
+	Limits            Limits
 }
 
 type credentials struct {
 	ID         string
 	NkeySeed   string
 	NkeyPublic string
+
+	// Agent key replaced by a rotation, accepted until it is revoked
+	PreviousNkeyPublic string
+
+	// Key with which the node itself logs in to the agent's account, so that the node never
+	// shares the agent's key
+	HostNkeySeed   string
+	HostNkeyPublic string
 }
 
 /*
@@ -56,6 +65,8 @@ accounts: {
 		jetstream: true
 		users: [
 			{nkey: "{{ .NkeyPublic }}"}
+			{{ if .PreviousNkeyPublic }}{nkey: "{{ .PreviousNkeyPublic }}"}{{ end }}
+			{{ if .HostNkeyPublic }}{nkey: "{{ .HostNkeyPublic }}"}{{ end }}
 		]
 		exports: [
 			{
@@ -70,6 +81,12 @@ accounts: {
 				service: {account: nexhost, subject: hostint.{{ .ID }}.>}
 			}
 		]
+		{{ if or $.Limits.MaxConnectionsPerAgent $.Limits.MaxSubscriptionsPerAgent }}
+		limits: {
+			{{ if $.Limits.MaxConnectionsPerAgent }}max_connections: {{ $.Limits.MaxConnectionsPerAgent }}{{ end }}
+			{{ if $.Limits.MaxSubscriptionsPerAgent }}max_subscriptions: {{ $.Limits.MaxSubscriptionsPerAgent }}{{ end }}
+		}
+		{{ end }}
 
 	},
 	{{ end }}
@@ -77,6 +94,9 @@ accounts: {
 no_sys_acc: true
 debug: false
 trace: false
+{{ if .Limits.MaxPayload }}max_payload: {{ .Limits.MaxPayload }}{{ end }}
+{{ if .Limits.MaxConnections }}max_connections: {{ .Limits.MaxConnections }}{{ end }}
+{{ if .Limits.WriteDeadline }}write_deadline: "{{ .Limits.WriteDeadline }}"{{ end }}
 `
 )
 
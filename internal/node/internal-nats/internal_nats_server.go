@@ -3,11 +3,15 @@ package internalnats
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net/url"
 	"os"
 	"path"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats-server/v2/server"
@@ -21,17 +25,49 @@ const (
 	defaultInternalNatsStoreDir   = "pnats"
 	workloadCacheBucketName       = "NEXCACHE"
 	workloadCacheFileKey          = "workload"
+
+	// Time allowed for streaming an object into the workload cache bucket
+	streamObjectTimeout = 5 * time.Minute
 )
 
+// Limits enforced by the internal NATS server, protecting the node's control plane from a
+// misbehaving agent. Every agent logs in to its own account, to which the per-agent limits
+// apply. Zero values leave the server's defaults in place
+type Limits struct {
+	MaxPayload               int32
+	MaxConnections           int
+	WriteDeadline            time.Duration
+	MaxConnectionsPerAgent   int
+	MaxSubscriptionsPerAgent int
+}
+
+// Usage of the internal NATS server by the connections logged in to an agent's account
+type AgentConnectionStats struct {
+	Connections   int
+	Subscriptions int
+	PendingBytes  int
+	InMsgs        int64
+	OutMsgs       int64
+	InBytes       int64
+	OutBytes      int64
+}
+
 type InternalNatsServer struct {
 	ncInternal       *nats.Conn
 	log              *slog.Logger
 	lastOpts         *server.Options
 	server           *server.Server
 	serverConfigData internalServerData
+
+	credsMutex sync.Mutex
 }
 
 func NewInternalNatsServer(log *slog.Logger) (*InternalNatsServer, error) {
+	return NewInternalNatsServerWithLimits(log, Limits{})
+}
+
+// Starts an internal NATS server enforcing the given limits
+func NewInternalNatsServerWithLimits(log *slog.Logger, limits Limits) (*InternalNatsServer, error) {
 	opts := &server.Options{
 		JetStream: true,
 		StoreDir:  path.Join(os.TempDir(), defaultInternalNatsStoreDir),
@@ -43,6 +79,7 @@ func NewInternalNatsServer(log *slog.Logger) (*InternalNatsServer, error) {
 
 	data := internalServerData{
 		Credentials: map[string]*credentials{},
+		Limits:      limits,
 	}
 
 	hostUser, _ := nkeys.CreateUser()
@@ -109,32 +146,45 @@ func (s *InternalNatsServer) CreateCredentials(id string) (nkeys.KeyPair, error)
 		return nil, err
 	}
 
-	pk, _ := kp.PublicKey()
-	seed, _ := kp.Seed()
+	return s.addCredentials(id, kp)
+}
 
-	creds := &credentials{
-		NkeySeed:   string(seed),
-		NkeyPublic: pk,
-		ID:         id,
+// Lets an agent log into the internal server with the user seed it was given by another node,
+// e.g. once its machine has been restored there from a checkpoint
+func (s *InternalNatsServer) RestoreCredentials(id string, seed []byte) (nkeys.KeyPair, error) {
+	kp, err := nkeys.FromSeed(seed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent nkey seed: %s", err)
 	}
-	s.serverConfigData.Credentials[id] = creds
 
-	opts := &server.Options{
-		ConfigFile: s.lastOpts.ConfigFile,
-		JetStream:  true,
-		Port:       s.lastOpts.Port,
-		StoreDir:   s.lastOpts.StoreDir,
-	}
+	return s.addCredentials(id, kp)
+}
 
-	updated, err := updateNatsOptions(opts, s.log, s.serverConfigData)
+func (s *InternalNatsServer) addCredentials(id string, kp nkeys.KeyPair) (nkeys.KeyPair, error) {
+	hostKp, err := nkeys.CreateUser()
 	if err != nil {
-		s.log.Error("Failed to update NATS options in internal server", slog.Any("error", err))
+		s.log.Error("Failed to create nkey user", slog.Any("error", err))
 		return nil, err
 	}
 
-	err = s.server.ReloadOptions(updated)
+	pk, _ := kp.PublicKey()
+	seed, _ := kp.Seed()
+	hostPk, _ := hostKp.PublicKey()
+	hostSeed, _ := hostKp.Seed()
+
+	creds := &credentials{
+		NkeySeed:       string(seed),
+		NkeyPublic:     pk,
+		HostNkeySeed:   string(hostSeed),
+		HostNkeyPublic: hostPk,
+		ID:             id,
+	}
+
+	s.credsMutex.Lock()
+	s.serverConfigData.Credentials[id] = creds
+	err = s.reload()
+	s.credsMutex.Unlock()
 	if err != nil {
-		s.log.Error("Failed to reload NATS internal server options", slog.Any("error", err))
 		return nil, err
 	}
 
@@ -143,6 +193,7 @@ func (s *InternalNatsServer) CreateCredentials(id string) (nkeys.KeyPair, error)
 		s.log.Error("Failed to obtain connection for given credentials", slog.Any("error", err))
 		return nil, err
 	}
+	defer nc.Close()
 
 	_, err = ensureWorkloadObjectStore(nc)
 	if err != nil {
@@ -157,8 +208,73 @@ func (s *InternalNatsServer) CreateCredentials(id string) (nkeys.KeyPair, error)
 
 // Destroy previously-created credentials
 func (s *InternalNatsServer) DestroyCredentials(id string) error {
+	s.credsMutex.Lock()
+	defer s.credsMutex.Unlock()
+
 	delete(s.serverConfigData.Credentials, id)
+	return s.reload()
+}
+
+// Replaces the key with which the given agent logs in to its account and returns the new user
+// keypair. The previous key remains valid until RevokePreviousCredentials is called, giving the
+// agent a chance to switch over without losing its connection
+func (s *InternalNatsServer) RotateCredentials(id string) (nkeys.KeyPair, error) {
+	kp, err := nkeys.CreateUser()
+	if err != nil {
+		s.log.Error("Failed to create nkey user", slog.Any("error", err))
+		return nil, err
+	}
+
+	pk, _ := kp.PublicKey()
+	seed, _ := kp.Seed()
+
+	s.credsMutex.Lock()
+	defer s.credsMutex.Unlock()
+
+	existing, ok := s.serverConfigData.Credentials[id]
+	if !ok {
+		return nil, errors.New("No such workload")
+	}
+
+	rotated := *existing
+	rotated.PreviousNkeyPublic = existing.NkeyPublic
+	rotated.NkeyPublic = pk
+	rotated.NkeySeed = string(seed)
+	s.serverConfigData.Credentials[id] = &rotated
+
+	err = s.reload()
+	if err != nil {
+		s.serverConfigData.Credentials[id] = existing
+		return nil, err
+	}
+
+	return kp, nil
+}
+
+// Revokes the key replaced by the most recent rotation of the given agent's credentials. Any
+// connection still logged in with that key is closed by the server
+func (s *InternalNatsServer) RevokePreviousCredentials(id string) error {
+	s.credsMutex.Lock()
+	defer s.credsMutex.Unlock()
 
+	existing, ok := s.serverConfigData.Credentials[id]
+	if !ok {
+		return errors.New("No such workload")
+	}
+	if existing.PreviousNkeyPublic == "" {
+		return nil
+	}
+
+	revoked := *existing
+	revoked.PreviousNkeyPublic = ""
+	s.serverConfigData.Credentials[id] = &revoked
+
+	return s.reload()
+}
+
+// Regenerates the server configuration from the current credentials and reloads it. Callers
+// must hold credsMutex
+func (s *InternalNatsServer) reload() error {
 	updated, err := updateNatsOptions(&server.Options{
 		ConfigFile: s.lastOpts.ConfigFile,
 		JetStream:  true,
@@ -179,6 +295,30 @@ func (s *InternalNatsServer) DestroyCredentials(id string) error {
 	return nil
 }
 
+// Summarizes the connections logged in to the account of the given agent
+func (s *InternalNatsServer) AgentConnectionStats(id string) (*AgentConnectionStats, error) {
+	connz, err := s.server.Connz(&server.ConnzOptions{
+		Account: id,
+		Limit:   math.MaxInt32,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &AgentConnectionStats{}
+	for _, conn := range connz.Conns {
+		stats.Connections++
+		stats.Subscriptions += int(conn.NumSubs)
+		stats.PendingBytes += conn.Pending
+		stats.InMsgs += conn.InMsgs
+		stats.OutMsgs += conn.OutMsgs
+		stats.InBytes += conn.InBytes
+		stats.OutBytes += conn.OutBytes
+	}
+
+	return stats, nil
+}
+
 func (s *InternalNatsServer) ClientURL() string {
 	return s.ncInternal.ConnectedUrl()
 }
@@ -193,6 +333,16 @@ func (s *InternalNatsServer) Shutdown() {
 }
 
 func (s *InternalNatsServer) StoreFileForID(id string, bytes []byte) error {
+	return s.StoreObjectForID(id, workloadCacheFileKey, bytes)
+}
+
+// Streams the workload file of the given workload into its cache bucket
+func (s *InternalNatsServer) StreamFileForID(id string, r io.Reader) error {
+	return s.StreamObjectForID(id, workloadCacheFileKey, r)
+}
+
+// Stores an object under the given key in the workload cache bucket of the given workload
+func (s *InternalNatsServer) StoreObjectForID(id string, key string, bytes []byte) error {
 	ctx, cancelF := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancelF()
 
@@ -205,16 +355,62 @@ func (s *InternalNatsServer) StoreFileForID(id string, bytes []byte) error {
 	if err != nil {
 		return err
 	}
+	defer nc.Close()
 
 	bucket, err := ensureWorkloadObjectStore(nc)
 	if err != nil {
 		return err
 	}
 
-	_, err = bucket.PutBytes(ctx, workloadCacheFileKey, bytes)
+	_, err = bucket.PutBytes(ctx, key, bytes)
 	return err
 }
 
+// Streams an object from the reader into the workload cache bucket of the given workload, so that
+// large artifacts need not be held in memory first
+func (s *InternalNatsServer) StreamObjectForID(id string, key string, r io.Reader) error {
+	ctx, cancelF := context.WithTimeout(context.Background(), streamObjectTimeout)
+	defer cancelF()
+
+	creds, err := s.FindCredentials(id)
+	if err != nil {
+		return err
+	}
+
+	nc, err := s.ConnectionWithCredentials(creds)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	bucket, err := ensureWorkloadObjectStore(nc)
+	if err != nil {
+		return err
+	}
+
+	_, err = bucket.Put(ctx, jetstream.ObjectMeta{Name: key}, r)
+	return err
+}
+
+// Retrieves the object stored under the given key in the workload cache bucket of the given workload
+func (s *InternalNatsServer) GetObjectForID(id string, key string) ([]byte, error) {
+	ctx, cancelF := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelF()
+
+	nc, err := s.ConnectionWithID(id)
+	if err != nil {
+		return nil, err
+	}
+	defer nc.Close()
+
+	bucket, err := ensureWorkloadObjectStore(nc)
+	if err != nil {
+		return nil, err
+	}
+
+	return bucket.GetBytes(ctx, key)
+}
+
 func (s *InternalNatsServer) ConnectionWithID(id string) (*nats.Conn, error) {
 	creds, err := s.FindCredentials(id)
 	if err != nil {
@@ -224,18 +420,25 @@ func (s *InternalNatsServer) ConnectionWithID(id string) (*nats.Conn, error) {
 	return s.ConnectionWithCredentials(creds)
 }
 
+// Connects to the account of the given credentials. The node logs in with its own key for the
+// account where one exists, so that its connections survive rotations of the agent's key
 func (s *InternalNatsServer) ConnectionWithCredentials(creds *credentials) (*nats.Conn, error) {
-	pair, err := nkeys.FromSeed([]byte(creds.NkeySeed))
+	seed, public := creds.NkeySeed, creds.NkeyPublic
+	if creds.HostNkeySeed != "" {
+		seed, public = creds.HostNkeySeed, creds.HostNkeyPublic
+	}
+
+	pair, err := nkeys.FromSeed([]byte(seed))
 	if err != nil {
 		return nil, err
 	}
 
-	nc, err := nats.Connect(s.server.ClientURL(), nats.Nkey(creds.NkeyPublic, func(b []byte) ([]byte, error) {
-		s.log.Debug("Attempting to sign NATS server nonce for internal connection", slog.String("public_key", creds.NkeyPublic))
+	nc, err := nats.Connect(s.server.ClientURL(), nats.Nkey(public, func(b []byte) ([]byte, error) {
+		s.log.Debug("Attempting to sign NATS server nonce for internal connection", slog.String("public_key", public))
 		return pair.Sign(b)
 	}))
 	if err != nil {
-		s.log.Warn("Failed to sign NATS server nonce for internal connection", slog.String("public_key", creds.NkeyPublic))
+		s.log.Warn("Failed to sign NATS server nonce for internal connection", slog.String("public_key", public))
 		return nil, err
 	}
 
@@ -243,6 +446,9 @@ func (s *InternalNatsServer) ConnectionWithCredentials(creds *credentials) (*nat
 }
 
 func (s *InternalNatsServer) FindCredentials(id string) (*credentials, error) {
+	s.credsMutex.Lock()
+	defer s.credsMutex.Unlock()
+
 	if creds, ok := s.serverConfigData.Credentials[id]; ok {
 		return creds, nil
 	}
@@ -7,9 +7,11 @@ import (
 	"net/url"
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nkeys"
 	"github.com/nats-io/nuid"
 )
 
@@ -105,3 +107,142 @@ func TestInternalNatsServerFileCache(t *testing.T) {
 		t.Fatalf("File bytes did not round trip properly, got %+v", workload)
 	}
 }
+
+func TestInternalNatsServerLimitsAgentConnections(t *testing.T) {
+	server, err := NewInternalNatsServerWithLimits(slog.Default(), Limits{MaxConnectionsPerAgent: 2})
+	if err != nil {
+		t.Fatalf("Failed to create internal nats server: %s", err)
+	}
+	defer server.Shutdown()
+
+	workloadId := nuid.Next()
+	_, err = server.CreateCredentials(workloadId)
+	if err != nil {
+		t.Fatalf("Should have been able to add a workload user but couldn't: %s", err)
+	}
+
+	// the server releases the connection used while creating the credentials asynchronously
+	for i := 0; i < 20; i++ {
+		stats, err := server.AgentConnectionStats(workloadId)
+		if err == nil && stats.Connections == 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	for i := 0; i < 2; i++ {
+		nc, err := server.ConnectionWithID(workloadId)
+		if err != nil {
+			t.Fatalf("Expected connection %d within the agent's limit to succeed: %s", i+1, err)
+		}
+		defer nc.Close()
+	}
+
+	stats, err := server.AgentConnectionStats(workloadId)
+	if err != nil {
+		t.Fatalf("Failed to collect agent connection stats: %s", err)
+	}
+	if stats.Connections != 2 {
+		t.Fatalf("Expected 2 agent connections, got %d", stats.Connections)
+	}
+
+	nc, err := server.ConnectionWithID(workloadId)
+	if err == nil {
+		nc.Close()
+		t.Fatal("Expected connection beyond the agent's limit to be rejected")
+	}
+}
+
+func TestInternalNatsServerCredentialRotation(t *testing.T) {
+	server, err := NewInternalNatsServer(slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to create internal nats server: %s", err)
+	}
+	defer server.Shutdown()
+
+	workloadId := nuid.Next()
+	original, err := server.CreateCredentials(workloadId)
+	if err != nil {
+		t.Fatalf("Should have been able to add a workload user but couldn't: %s", err)
+	}
+
+	connect := func(kp nkeys.KeyPair) (*nats.Conn, error) {
+		pk, _ := kp.PublicKey()
+		return nats.Connect(server.Connection().Servers()[0], nats.NoReconnect(), nats.Nkey(pk, kp.Sign))
+	}
+
+	ncOriginal, err := connect(original)
+	if err != nil {
+		t.Fatalf("Couldn't connect to the internal server as a workload: %s", err)
+	}
+	defer ncOriginal.Close()
+
+	rotated, err := server.RotateCredentials(workloadId)
+	if err != nil {
+		t.Fatalf("Should have been able to rotate the workload credentials but couldn't: %s", err)
+	}
+
+	ncRotated, err := connect(rotated)
+	if err != nil {
+		t.Fatalf("Couldn't connect to the internal server with rotated credentials: %s", err)
+	}
+	defer ncRotated.Close()
+
+	if !ncOriginal.IsConnected() {
+		t.Fatal("Expected the previous credentials to remain valid until revoked")
+	}
+
+	err = server.RevokePreviousCredentials(workloadId)
+	if err != nil {
+		t.Fatalf("Should have been able to revoke the previous credentials but couldn't: %s", err)
+	}
+
+	_, err = connect(original)
+	if err == nil {
+		t.Fatal("Expected revoked credentials to be refused")
+	}
+
+	nc, err := server.ConnectionWithID(workloadId)
+	if err != nil {
+		t.Fatalf("Expected the node to connect to the rotated account: %s", err)
+	}
+	nc.Close()
+}
+
+func TestInternalNatsServerRestoredCredentials(t *testing.T) {
+	original, err := NewInternalNatsServer(slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to create internal nats server: %s", err)
+	}
+	defer original.Shutdown()
+
+	restoring, err := NewInternalNatsServer(slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to create internal nats server: %s", err)
+	}
+	defer restoring.Shutdown()
+
+	workloadId := nuid.Next()
+	kp, err := original.CreateCredentials(workloadId)
+	if err != nil {
+		t.Fatalf("Should have been able to add a workload user but couldn't: %s", err)
+	}
+	pk, _ := kp.PublicKey()
+	seed, _ := kp.Seed()
+
+	_, err = nats.Connect(restoring.Connection().Servers()[0], nats.NoReconnect(), nats.Nkey(pk, kp.Sign))
+	if err == nil {
+		t.Fatal("Expected credentials created by another server to be refused")
+	}
+
+	_, err = restoring.RestoreCredentials(workloadId, seed)
+	if err != nil {
+		t.Fatalf("Should have been able to restore the workload credentials but couldn't: %s", err)
+	}
+
+	nc, err := nats.Connect(restoring.Connection().Servers()[0], nats.NoReconnect(), nats.Nkey(pk, kp.Sign))
+	if err != nil {
+		t.Fatalf("Couldn't connect to the internal server with restored credentials: %s", err)
+	}
+	nc.Close()
+}
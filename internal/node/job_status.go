@@ -0,0 +1,124 @@
+package nexnode
+
+import (
+	"sync"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+// Number of job statuses retained by a node, the oldest being dropped first
+const jobStatusRetention = 1000
+
+type jobStatusEntry struct {
+	namespace string
+	status    controlapi.JobStatus
+}
+
+// Tracks the status of job workloads, retaining it after a job completes and its agent has been
+// recycled
+type jobStatuses struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  map[string]*jobStatusEntry
+	order    []string
+}
+
+func newJobStatuses(capacity int) *jobStatuses {
+	return &jobStatuses{
+		capacity: capacity,
+		entries:  make(map[string]*jobStatusEntry),
+		order:    make([]string, 0),
+	}
+}
+
+// Records a deployed job as running. A job can complete before its deployment is acknowledged,
+// so this leaves an existing status untouched
+func (j *jobStatuses) started(workloadID, namespace, name string, startedAt time.Time) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if _, ok := j.entries[workloadID]; ok {
+		return
+	}
+
+	j.add(workloadID, &jobStatusEntry{
+		namespace: namespace,
+		status: controlapi.JobStatus{
+			WorkloadId: workloadID,
+			Name:       name,
+			State:      controlapi.JobStateRunning,
+			StartedAt:  startedAt,
+		},
+	})
+}
+
+func (j *jobStatuses) completed(workloadID, namespace string, evt agentapi.JobCompletedEvent) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	state := controlapi.JobStateSucceeded
+	if evt.ExitCode != 0 {
+		state = controlapi.JobStateFailed
+	}
+
+	exitCode := evt.ExitCode
+	completedAt := evt.CompletedAt
+	status := controlapi.JobStatus{
+		WorkloadId:  workloadID,
+		Name:        evt.WorkloadName,
+		State:       state,
+		ExitCode:    &exitCode,
+		Output:      evt.Output,
+		StartedAt:   evt.StartedAt,
+		CompletedAt: &completedAt,
+	}
+
+	if entry, ok := j.entries[workloadID]; ok {
+		entry.status = status
+		return
+	}
+
+	j.add(workloadID, &jobStatusEntry{namespace: namespace, status: status})
+}
+
+// Marks a job that is still running as stopped, e.g. when it is undeployed before completing
+func (j *jobStatuses) stopped(workloadID string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	entry, ok := j.entries[workloadID]
+	if !ok || entry.status.State != controlapi.JobStateRunning {
+		return
+	}
+
+	stoppedAt := time.Now().UTC()
+	entry.status.State = controlapi.JobStateStopped
+	entry.status.CompletedAt = &stoppedAt
+}
+
+// Returns a copy of the job's status if it is known to the node within the given namespace
+func (j *jobStatuses) get(workloadID, namespace string) (*controlapi.JobStatus, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	entry, ok := j.entries[workloadID]
+	if !ok || entry.namespace != namespace {
+		return nil, false
+	}
+
+	status := entry.status
+	return &status, true
+}
+
+// Must be called with the mutex held
+func (j *jobStatuses) add(workloadID string, entry *jobStatusEntry) {
+	if j.capacity > 0 && len(j.order) >= j.capacity {
+		delete(j.entries, j.order[0])
+		j.order = j.order[1:]
+	}
+
+	j.entries[workloadID] = entry
+	j.order = append(j.order, workloadID)
+}
@@ -0,0 +1,44 @@
+package nexnode
+
+import (
+	"testing"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+func TestJobStatusesTrackCompletion(t *testing.T) {
+	jobs := newJobStatuses(2)
+	jobs.started("job1", "default", "echo", time.Now())
+	jobs.completed("job1", "default", agentapi.JobCompletedEvent{WorkloadName: "echo", ExitCode: 3, Output: "boom"})
+
+	// the agent's undeploy follows completion and must not overwrite the result
+	jobs.stopped("job1")
+
+	status, ok := jobs.get("job1", "default")
+	if !ok || status.State != controlapi.JobStateFailed || status.ExitCode == nil || *status.ExitCode != 3 || status.Output != "boom" {
+		t.Fatalf("Expected failed job with exit code 3, got %+v", status)
+	}
+
+	if _, ok := jobs.get("job1", "other"); ok {
+		t.Fatal("Expected job to be hidden from other namespaces")
+	}
+
+	// a completion that arrives before the deployment is acknowledged is kept
+	jobs.completed("job2", "default", agentapi.JobCompletedEvent{WorkloadName: "echo"})
+	jobs.started("job2", "default", "echo", time.Now())
+	if status, _ := jobs.get("job2", "default"); status.State != controlapi.JobStateSucceeded {
+		t.Fatalf("Expected succeeded job, got %s", status.State)
+	}
+
+	jobs.started("job3", "default", "echo", time.Now())
+	if _, ok := jobs.get("job1", "default"); ok {
+		t.Fatal("Expected oldest job status to be dropped")
+	}
+
+	jobs.stopped("job3")
+	if status, _ := jobs.get("job3", "default"); status.State != controlapi.JobStateStopped {
+		t.Fatalf("Expected stopped job, got %s", status.State)
+	}
+}
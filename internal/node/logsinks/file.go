@@ -0,0 +1,112 @@
+package logsinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const (
+	defaultFileMaxSizeMib = 100
+	defaultFileMaxBackups = 5
+)
+
+// Writes records as JSON lines, renaming the file to path.1 (and earlier backups to path.2 and
+// so on) once it reaches its maximum size
+type fileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	f    *os.File
+	size int64
+}
+
+func newFileSink(config *models.FileLogSinkConfig) (*fileSink, error) {
+	s := &fileSink{
+		path:       config.Path,
+		maxBytes:   defaultFileMaxSizeMib * 1024 * 1024,
+		maxBackups: defaultFileMaxBackups,
+	}
+	if config.MaxSizeMib > 0 {
+		s.maxBytes = int64(config.MaxSizeMib) * 1024 * 1024
+	}
+	if config.MaxBackups > 0 {
+		s.maxBackups = config.MaxBackups
+	}
+
+	err := os.MkdirAll(filepath.Dir(s.path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, s.open()
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.f = f
+	s.size = info.Size()
+	return nil
+}
+
+func (s *fileSink) Write(records []Record) error {
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+		line = append(line, '\n')
+
+		if s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+			err = s.rotate()
+			if err != nil {
+				return err
+			}
+		}
+
+		n, err := s.f.Write(line)
+		s.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *fileSink) rotate() error {
+	err := s.f.Close()
+	if err != nil {
+		return err
+	}
+
+	_ = os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxBackups))
+	for i := s.maxBackups - 1; i > 0; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+
+	err = os.Rename(s.path, fmt.Sprintf("%s.1", s.path))
+	if err != nil {
+		return err
+	}
+
+	return s.open()
+}
+
+func (s *fileSink) Close() error {
+	return s.f.Close()
+}
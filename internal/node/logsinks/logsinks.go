@@ -0,0 +1,229 @@
+package logsinks
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = 1 * time.Second
+
+	// S3 objects are costly per request, so batches are larger and written less often
+	defaultS3BatchSize     = 1000
+	defaultS3FlushInterval = 60 * time.Second
+
+	// Records buffered per sink, beyond which new records are dropped rather than delaying agents
+	bufferedRecords = 4096
+)
+
+// A workload log entry as forwarded to sinks
+type Record struct {
+	Time         time.Time              `json:"time"`
+	Level        slog.Level             `json:"level"`
+	NodeId       string                 `json:"node_id"`
+	Namespace    string                 `json:"namespace"`
+	WorkloadId   string                 `json:"workload_id"`
+	WorkloadName string                 `json:"workload_name,omitempty"`
+	Source       string                 `json:"source,omitempty"`
+	Text         string                 `json:"text"`
+	Fields       map[string]interface{} `json:"fields,omitempty"`
+}
+
+// A destination to which batches of workload log records are written
+type Sink interface {
+	Write(records []Record) error
+	Close() error
+}
+
+// Routes workload log records to every configured sink whose rules match them. Each sink
+// writes in the background, so a slow or unavailable sink never blocks the node
+type Router struct {
+	mu     sync.RWMutex
+	closed bool
+	routes []*route
+	log    *slog.Logger
+}
+
+type route struct {
+	name       string
+	namespaces []string
+	minLevel   slog.Level
+	sink       Sink
+
+	batchSize     int
+	flushInterval time.Duration
+	records       chan Record
+	dropped       atomic.Uint64
+	done          chan struct{}
+}
+
+// Opens the configured sinks and starts writing to them. Failing to open any sink is an error,
+// as a sink is typically the system of record for the logs routed to it
+func NewRouter(configs []models.LogSinkConfig, nodeId string, log *slog.Logger) (*Router, error) {
+	r := &Router{
+		routes: make([]*route, 0, len(configs)),
+		log:    log,
+	}
+
+	for _, config := range configs {
+		sink, err := openSink(config, nodeId)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("failed to open log sink %s: %s", config.Name, err)
+		}
+
+		rt := &route{
+			name:          config.Name,
+			namespaces:    config.Namespaces,
+			sink:          sink,
+			batchSize:     defaultBatchSize,
+			flushInterval: defaultFlushInterval,
+			records:       make(chan Record, bufferedRecords),
+			done:          make(chan struct{}),
+		}
+		if config.Type == models.LogSinkTypeS3 {
+			rt.batchSize = defaultS3BatchSize
+			rt.flushInterval = defaultS3FlushInterval
+		}
+		if config.BatchSize > 0 {
+			rt.batchSize = config.BatchSize
+		}
+		if config.FlushIntervalMillisecond > 0 {
+			rt.flushInterval = time.Duration(config.FlushIntervalMillisecond) * time.Millisecond
+		}
+		if config.MinLevel != "" {
+			_ = rt.minLevel.UnmarshalText([]byte(config.MinLevel))
+		} else {
+			rt.minLevel = slog.LevelDebug
+		}
+
+		r.routes = append(r.routes, rt)
+		go rt.run(log.With(slog.String("log_sink", config.Name)))
+	}
+
+	return r, nil
+}
+
+func openSink(config models.LogSinkConfig, nodeId string) (Sink, error) {
+	switch config.Type {
+	case models.LogSinkTypeFile:
+		return newFileSink(config.File)
+	case models.LogSinkTypeSyslog:
+		return newSyslogSink(config.Syslog)
+	case models.LogSinkTypeLoki:
+		return newLokiSink(config.Loki)
+	case models.LogSinkTypeS3:
+		return newS3Sink(config.S3, nodeId)
+	}
+
+	return nil, fmt.Errorf("unknown log sink type %q", config.Type)
+}
+
+// Queues the record on every matching sink without blocking
+func (r *Router) Route(record Record) {
+	if r == nil {
+		return
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+
+	for _, rt := range r.routes {
+		if !rt.matches(record) {
+			continue
+		}
+
+		select {
+		case rt.records <- record:
+		default:
+			if rt.dropped.Add(1) == 1 {
+				r.log.Warn("Log sink is not keeping up; dropping records", slog.String("log_sink", rt.name))
+			}
+		}
+	}
+}
+
+// Flushes the records queued on every sink and closes them
+func (r *Router) Close() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	for _, rt := range r.routes {
+		close(rt.records)
+	}
+	r.mu.Unlock()
+
+	for _, rt := range r.routes {
+		<-rt.done
+	}
+}
+
+func (rt *route) matches(record Record) bool {
+	if record.Level < rt.minLevel {
+		return false
+	}
+
+	return len(rt.namespaces) == 0 || slices.Contains(rt.namespaces, "*") || slices.Contains(rt.namespaces, record.Namespace)
+}
+
+func (rt *route) run(log *slog.Logger) {
+	defer close(rt.done)
+
+	ticker := time.NewTicker(rt.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, rt.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		err := rt.sink.Write(batch)
+		if err != nil {
+			log.Warn("Failed to write to log sink", slog.Int("records", len(batch)), slog.Any("err", err))
+		}
+		batch = make([]Record, 0, rt.batchSize)
+
+		if dropped := rt.dropped.Swap(0); dropped > 0 {
+			log.Warn("Dropped records while log sink was not keeping up", slog.Uint64("dropped", dropped))
+		}
+	}
+
+	for {
+		select {
+		case record, ok := <-rt.records:
+			if !ok {
+				flush()
+				err := rt.sink.Close()
+				if err != nil {
+					log.Warn("Failed to close log sink", slog.Any("err", err))
+				}
+				return
+			}
+
+			batch = append(batch, record)
+			if len(batch) >= rt.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
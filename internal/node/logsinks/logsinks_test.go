@@ -0,0 +1,88 @@
+package logsinks
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestRouterWritesMatchingRecordsToRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "workloads.log")
+
+	r, err := NewRouter([]models.LogSinkConfig{{
+		Name:       "audit",
+		Type:       models.LogSinkTypeFile,
+		Namespaces: []string{"payments"},
+		MinLevel:   "INFO",
+		File:       &models.FileLogSinkConfig{Path: path, MaxSizeMib: 1, MaxBackups: 1},
+	}}, "NODE", slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open log sinks: %s", err)
+	}
+
+	r.Route(Record{Time: time.Now(), Level: slog.LevelInfo, Namespace: "payments", WorkloadId: "a", Text: "kept"})
+	r.Route(Record{Time: time.Now(), Level: slog.LevelDebug, Namespace: "payments", WorkloadId: "a", Text: "below minimum level"})
+	r.Route(Record{Time: time.Now(), Level: slog.LevelError, Namespace: "orders", WorkloadId: "b", Text: "other namespace"})
+	r.Close()
+
+	// routing after close is ignored rather than panicking
+	r.Route(Record{Level: slog.LevelInfo, Namespace: "payments"})
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open sink file: %s", err)
+	}
+	defer f.Close()
+
+	records := make([]Record, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Sink wrote an invalid line: %s", err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 1 || records[0].Text != "kept" {
+		t.Fatalf("Expected only the matching record to be written, got %+v", records)
+	}
+}
+
+func TestFileSinkRotatesAtMaximumSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workloads.log")
+
+	s, err := newFileSink(&models.FileLogSinkConfig{Path: path, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("Failed to open file sink: %s", err)
+	}
+	s.maxBytes = 200
+
+	for i := 0; i < 10; i++ {
+		err = s.Write([]Record{{Namespace: "default", WorkloadId: "a", Text: "a log line long enough to fill the file"}})
+		if err != nil {
+			t.Fatalf("Failed to write: %s", err)
+		}
+	}
+	_ = s.Close()
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Expected %s to exist: %s", name, err)
+		}
+		if info.Size() > 200 {
+			t.Fatalf("Expected %s to be at most 200 bytes, got %d", name, info.Size())
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatal("Expected backups beyond the maximum to be removed")
+	}
+}
@@ -0,0 +1,121 @@
+package logsinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const lokiPushTimeout = 10 * time.Second
+
+// Pushes records to Loki, one stream per node, namespace, workload and level. Each line is the
+// JSON encoded record
+type lokiSink struct {
+	config   *models.LokiLogSinkConfig
+	password string
+	client   *http.Client
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func newLokiSink(config *models.LokiLogSinkConfig) (*lokiSink, error) {
+	s := &lokiSink{
+		config: config,
+		client: &http.Client{Timeout: lokiPushTimeout},
+	}
+
+	if config.PasswordEnv != "" {
+		s.password = os.Getenv(config.PasswordEnv)
+		if s.password == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", config.PasswordEnv)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *lokiSink) Write(records []Record) error {
+	streams := make(map[string]*lokiStream)
+	order := make([]string, 0)
+
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+
+		key := record.NodeId + "\x00" + record.Namespace + "\x00" + record.WorkloadName + "\x00" + record.Level.String()
+		stream, ok := streams[key]
+		if !ok {
+			labels := map[string]string{
+				"node_id":       record.NodeId,
+				"namespace":     record.Namespace,
+				"workload_name": record.WorkloadName,
+				"level":         record.Level.String(),
+			}
+			for k, v := range s.config.Labels {
+				labels[k] = v
+			}
+
+			stream = &lokiStream{Stream: labels, Values: make([][2]string, 0)}
+			streams[key] = stream
+			order = append(order, key)
+		}
+
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(record.Time.UnixNano(), 10), string(line)})
+	}
+
+	push := lokiPush{Streams: make([]lokiStream, 0, len(order))}
+	for _, key := range order {
+		push.Streams = append(push.Streams, *streams[key])
+	}
+
+	body, err := json.Marshal(push)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.config.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.TenantId != "" {
+		req.Header.Set("X-Scope-OrgID", s.config.TenantId)
+	}
+	if s.config.Username != "" {
+		req.SetBasicAuth(s.config.Username, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("loki push failed with status %d: %s", resp.StatusCode, string(msg))
+	}
+
+	return nil
+}
+
+func (s *lokiSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,169 @@
+package logsinks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const s3PutTimeout = 30 * time.Second
+
+// Uploads each batch as a JSON lines object keyed by node and time, signing requests with AWS
+// signature version 4
+type s3Sink struct {
+	config *models.S3LogSinkConfig
+	nodeId string
+	client *http.Client
+
+	accessKeyId     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+func newS3Sink(config *models.S3LogSinkConfig, nodeId string) (*s3Sink, error) {
+	s := &s3Sink{
+		config:          config,
+		nodeId:          nodeId,
+		client:          &http.Client{Timeout: s3PutTimeout},
+		accessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if s.accessKeyId == "" || s.secretAccessKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	return s, nil
+}
+
+func (s *s3Sink) Write(records []Record) error {
+	var body bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+
+	now := time.Now().UTC()
+	key := path.Join(s.config.Prefix, now.Format("2006/01/02"), fmt.Sprintf("%s-%d.jsonl", s.nodeId, now.UnixNano()))
+
+	req, err := http.NewRequest(http.MethodPut, s.objectUrl(key), bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	s.sign(req, body.Bytes(), now)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 upload of %s failed with status %d: %s", key, resp.StatusCode, string(msg))
+	}
+
+	return nil
+}
+
+func (s *s3Sink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// Returns the virtual-hosted URL of the object on AWS, or its path-style URL on a custom endpoint
+func (s *s3Sink) objectUrl(key string) string {
+	if s.config.Endpoint != "" {
+		return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s.config.Endpoint, "/"), s.config.Bucket, awsEscapePath(key))
+	}
+
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.config.Bucket, s.config.Region, awsEscapePath(key))
+}
+
+// Adds an AWS signature version 4 authorization header to the request
+func (s *s3Sink) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	signedHeaders := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	if s.sessionToken != "" {
+		signedHeaders = append(signedHeaders, "x-amz-security-token")
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.config.Region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSha256([]byte("AWS4"+s.secretAccessKey), date)
+	key = hmacSha256(key, s.config.Region)
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyId, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// Escapes each segment of an object key as AWS expects, leaving only unreserved characters
+func awsEscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.QueryEscape(segment), "+", "%20")
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
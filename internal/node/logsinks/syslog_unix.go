@@ -0,0 +1,62 @@
+//go:build !windows
+
+package logsinks
+
+import (
+	"fmt"
+	"log/slog"
+	"log/syslog"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const defaultSyslogTag = "nex"
+
+// Writes each record to syslog at the severity matching its level
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func newSyslogSink(config *models.SyslogLogSinkConfig) (*syslogSink, error) {
+	network, address, tag := "", "", defaultSyslogTag
+	if config != nil {
+		network, address = config.Network, config.Address
+		if config.Tag != "" {
+			tag = config.Tag
+		}
+	}
+
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Write(records []Record) error {
+	for _, record := range records {
+		msg := fmt.Sprintf("namespace=%s workload=%s workload_id=%s %s", record.Namespace, record.WorkloadName, record.WorkloadId, record.Text)
+
+		var err error
+		switch {
+		case record.Level >= slog.LevelError:
+			err = s.w.Err(msg)
+		case record.Level >= slog.LevelWarn:
+			err = s.w.Warning(msg)
+		case record.Level >= slog.LevelInfo:
+			err = s.w.Info(msg)
+		default:
+			err = s.w.Debug(msg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
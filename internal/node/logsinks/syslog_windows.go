@@ -0,0 +1,13 @@
+//go:build windows
+
+package logsinks
+
+import (
+	"errors"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func newSyslogSink(_ *models.SyslogLogSinkConfig) (Sink, error) {
+	return nil, errors.New("syslog log sinks are not supported on windows")
+}
@@ -0,0 +1,270 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+	"github.com/synadia-io/nex/internal/node/processmanager"
+)
+
+const (
+	defaultMeteringSampleInterval = 5 * time.Second
+	defaultMeteringReportInterval = time.Minute
+)
+
+// Counters of a workload's machine at its last sample
+type meteredWorkload struct {
+	namespace string
+	sampledAt time.Time
+	cpuMillis int64
+	txBytes   int64
+}
+
+// Accumulates the resources used by each namespace's workloads, both over the current reporting
+// period and since the node started. A nil meter disables metering
+type usageMeter struct {
+	mutex sync.Mutex
+
+	subject        string
+	sampleInterval time.Duration
+	reportInterval time.Duration
+
+	since       time.Time
+	periodStart time.Time
+	sequence    uint64
+
+	period    map[string]*controlapi.NamespaceUsage
+	totals    map[string]*controlapi.NamespaceUsage
+	workloads map[string]*meteredWorkload
+}
+
+func newUsageMeter(config *models.NodeConfiguration, now time.Time) *usageMeter {
+	if config.Metering == nil {
+		return nil
+	}
+
+	m := &usageMeter{
+		subject:        config.Metering.Subject,
+		sampleInterval: time.Duration(config.Metering.SampleIntervalMillis) * time.Millisecond,
+		reportInterval: time.Duration(config.Metering.ReportIntervalMillis) * time.Millisecond,
+		since:          now,
+		periodStart:    now,
+		period:         make(map[string]*controlapi.NamespaceUsage),
+		totals:         make(map[string]*controlapi.NamespaceUsage),
+		workloads:      make(map[string]*meteredWorkload),
+	}
+	if m.subject == "" {
+		m.subject = controlapi.DefaultMeteringSubject
+	}
+	if m.sampleInterval <= 0 {
+		m.sampleInterval = defaultMeteringSampleInterval
+	}
+	if m.reportInterval <= 0 {
+		m.reportInterval = defaultMeteringReportInterval
+	}
+
+	return m
+}
+
+// Applies a change to the namespace's usage for the current period and since the node started
+func (m *usageMeter) add(namespace string, apply func(usage *controlapi.NamespaceUsage)) {
+	for _, usages := range []map[string]*controlapi.NamespaceUsage{m.period, m.totals} {
+		usage, ok := usages[namespace]
+		if !ok {
+			usage = &controlapi.NamespaceUsage{Namespace: namespace}
+			usages[namespace] = usage
+		}
+		apply(usage)
+	}
+}
+
+// Charges the workload's namespace with the CPU time and egress its machine used since the
+// previous sample, and with its resident memory over the time elapsed. The first sample of a
+// workload only sets the baseline, so that the time its machine spent in the warm pool is not
+// charged to it. Counters that went backwards were reset, and are charged in full
+func (m *usageMeter) observe(workloadID string, namespace string, stats *processmanager.MachineStats, now time.Time) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	previous, ok := m.workloads[workloadID]
+	m.workloads[workloadID] = &meteredWorkload{
+		namespace: namespace,
+		sampledAt: now,
+		cpuMillis: stats.CPUTimeMillis,
+		txBytes:   stats.NetTxBytes,
+	}
+	if !ok {
+		return
+	}
+
+	cpuMillis := stats.CPUTimeMillis - previous.cpuMillis
+	if cpuMillis < 0 {
+		cpuMillis = stats.CPUTimeMillis
+	}
+	txBytes := stats.NetTxBytes - previous.txBytes
+	if txBytes < 0 {
+		txBytes = stats.NetTxBytes
+	}
+	elapsed := now.Sub(previous.sampledAt).Seconds()
+
+	m.add(namespace, func(usage *controlapi.NamespaceUsage) {
+		usage.CPUSeconds += float64(cpuMillis) / 1000
+		usage.MemoryByteSeconds += float64(stats.MemoryRSSBytes) * elapsed
+		usage.EgressBytes += txBytes
+	})
+}
+
+// Charges the namespace with a function invocation and the bytes of its response
+func (m *usageMeter) invocation(namespace string, responseBytes int) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.add(namespace, func(usage *controlapi.NamespaceUsage) {
+		usage.Invocations++
+		usage.EgressBytes += int64(responseBytes)
+	})
+}
+
+// Stops sampling a workload that was stopped
+func (m *usageMeter) forget(workloadID string) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.workloads, workloadID)
+}
+
+// Returns the usage of the namespace since the node started
+func (m *usageMeter) usage(namespace string) controlapi.NamespaceUsage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if usage, ok := m.totals[namespace]; ok {
+		return *usage
+	}
+	return controlapi.NamespaceUsage{Namespace: namespace}
+}
+
+// Closes the current reporting period, returning the usage accumulated over it
+func (m *usageMeter) report(nodeID string, now time.Time) *controlapi.UsageReport {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.sequence++
+	report := &controlapi.UsageReport{
+		NodeId:      nodeID,
+		Sequence:    m.sequence,
+		PeriodStart: m.periodStart,
+		PeriodEnd:   now,
+		Namespaces:  make([]controlapi.NamespaceUsage, 0, len(m.period)),
+	}
+	for _, usage := range m.period {
+		report.Namespaces = append(report.Namespaces, *usage)
+	}
+	sort.Slice(report.Namespaces, func(i, j int) bool {
+		return report.Namespaces[i].Namespace < report.Namespaces[j].Namespace
+	})
+
+	m.period = make(map[string]*controlapi.NamespaceUsage)
+	m.periodStart = now
+
+	return report
+}
+
+// Samples the machines of deployed workloads and publishes a usage report every reporting
+// period until the node stops
+func (w *WorkloadManager) runMetering() {
+	if w.metering == nil {
+		return
+	}
+
+	sampler := time.NewTicker(w.metering.sampleInterval)
+	defer sampler.Stop()
+	reporter := time.NewTicker(w.metering.reportInterval)
+	defer reporter.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-sampler.C:
+			if atomic.LoadUint32(&w.closing) > 0 {
+				return
+			}
+
+			w.sampleUsage(time.Now())
+		case <-reporter.C:
+			if atomic.LoadUint32(&w.closing) > 0 {
+				return
+			}
+
+			now := time.Now()
+			w.sampleUsage(now)
+
+			err := w.publishUsageReport(w.metering.report(w.publicKey, now.UTC()))
+			if err != nil {
+				w.log.Warn("Failed to publish usage report", slog.Any("err", err))
+			}
+		}
+	}
+}
+
+func (w *WorkloadManager) sampleUsage(now time.Time) {
+	procs, err := w.procMan.ListProcesses()
+	if err != nil {
+		return
+	}
+
+	for _, p := range procs {
+		w.sampleWorkloadUsage(p.ID, p.Namespace, now)
+	}
+}
+
+// Samples the machine of a single workload, e.g. once it is deployed to set the baseline of its
+// usage, and just before it is stopped to charge its last moments
+func (w *WorkloadManager) sampleWorkloadUsage(workloadID string, namespace string, now time.Time) {
+	if w.metering == nil {
+		return
+	}
+
+	stats, err := w.procMan.MachineStats(workloadID)
+	if err != nil {
+		return
+	}
+
+	w.metering.observe(workloadID, namespace, stats, now)
+}
+
+// Signs the report with the node's key and publishes it on the metering subject. Reports are
+// published through the outbox, when there is one, so that none are lost while disconnected
+func (w *WorkloadManager) publishUsageReport(report *controlapi.UsageReport) error {
+	signed, err := controlapi.SignUsageReport(w.kp, report)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(signed)
+	if err != nil {
+		return err
+	}
+
+	return w.events().Publish(fmt.Sprintf("%s.%s", w.metering.subject, w.publicKey), raw)
+}
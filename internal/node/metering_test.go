@@ -0,0 +1,47 @@
+package nexnode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/synadia-io/nex/internal/models"
+	"github.com/synadia-io/nex/internal/node/processmanager"
+)
+
+func TestUsageMeterChargesNamespaces(t *testing.T) {
+	start := time.Now()
+	meter := newUsageMeter(&models.NodeConfiguration{Metering: &models.MeteringConfig{}}, start)
+
+	// the first sample only sets the baseline of the workload's machine
+	meter.observe("workload1", "default", &processmanager.MachineStats{CPUTimeMillis: 5000, NetTxBytes: 100}, start)
+	meter.observe("workload1", "default", &processmanager.MachineStats{CPUTimeMillis: 6500, MemoryRSSBytes: 1000, NetTxBytes: 300}, start.Add(10*time.Second))
+	meter.invocation("default", 50)
+	meter.invocation("other", 0)
+
+	report := meter.report("Nnode", start.Add(time.Minute))
+	if report.Sequence != 1 || len(report.Namespaces) != 2 {
+		t.Fatalf("Expected the first report to cover two namespaces, got %+v", report)
+	}
+
+	usage := report.Namespaces[0]
+	if usage.Namespace != "default" || usage.CPUSeconds != 1.5 || usage.MemoryByteSeconds != 10000 || usage.Invocations != 1 || usage.EgressBytes != 250 {
+		t.Fatalf("Expected the default namespace to be charged for its workload, got %+v", usage)
+	}
+
+	meter.observe("workload1", "default", &processmanager.MachineStats{CPUTimeMillis: 7500, NetTxBytes: 300}, start.Add(20*time.Second))
+	report = meter.report("Nnode", start.Add(2*time.Minute))
+	if report.Sequence != 2 || len(report.Namespaces) != 1 || report.Namespaces[0].CPUSeconds != 1 {
+		t.Fatalf("Expected the second report to only cover the second period, got %+v", report)
+	}
+
+	total := meter.usage("default")
+	if total.CPUSeconds != 2.5 || total.Invocations != 1 {
+		t.Fatalf("Expected the namespace's usage since the node started, got %+v", total)
+	}
+
+	meter.forget("workload1")
+	meter.observe("workload1", "default", &processmanager.MachineStats{CPUTimeMillis: 9000}, start.Add(30*time.Second))
+	if meter.usage("default").CPUSeconds != 2.5 {
+		t.Fatalf("Expected a forgotten workload to be sampled afresh")
+	}
+}
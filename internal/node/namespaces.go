@@ -0,0 +1,409 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+	"github.com/synadia-io/nex/internal/node/processmanager"
+)
+
+const (
+	defaultNamespacesBucket = "NEX_NAMESPACES"
+
+	// Namespace requests are served by a single node of those managing namespaces
+	namespaceQueueGroup = "nex-namespaces"
+)
+
+// Namespace objects stored in a key-value bucket, mirrored locally by watching the bucket. A
+// deleted namespace is kept as a tombstone so that nodes can still refuse deploys into it and
+// stop its workloads even if they only learn of the deletion late
+type namespaceRegistry struct {
+	mu         sync.RWMutex
+	namespaces map[string]controlapi.Namespace
+
+	config  *models.NamespacesConfig
+	kv      nats.KeyValue
+	watcher nats.KeyWatcher
+	log     *slog.Logger
+}
+
+func newNamespaceRegistry(config *models.NamespacesConfig, log *slog.Logger) *namespaceRegistry {
+	if config == nil {
+		return nil
+	}
+
+	return &namespaceRegistry{
+		namespaces: make(map[string]controlapi.Namespace),
+		config:     config,
+		log:        log,
+	}
+}
+
+// Binds to the bucket, creating it if needed, and keeps the local view up to date. The deleted
+// callback is invoked whenever a namespace is marked deleted
+func (r *namespaceRegistry) watch(nc *nats.Conn, deleted func(controlapi.Namespace)) error {
+	js, err := nc.JetStream()
+	if err != nil {
+		return err
+	}
+
+	bucket := r.config.Bucket
+	if bucket == "" {
+		bucket = defaultNamespacesBucket
+	}
+
+	r.kv, err = js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		r.kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Nex namespaces",
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to bind to namespaces bucket %s: %s", bucket, err)
+	}
+
+	r.watcher, err = r.kv.WatchAll()
+	if err != nil {
+		return fmt.Errorf("failed to watch namespaces bucket %s: %s", bucket, err)
+	}
+
+	go func() {
+		for entry := range r.watcher.Updates() {
+			if entry == nil {
+				// marks the end of the initial values
+				continue
+			}
+
+			if entry.Operation() != nats.KeyValuePut {
+				r.mu.Lock()
+				delete(r.namespaces, entry.Key())
+				r.mu.Unlock()
+				continue
+			}
+
+			var ns controlapi.Namespace
+			err := json.Unmarshal(entry.Value(), &ns)
+			if err != nil {
+				r.log.Error("Ignoring invalid namespace entry", slog.String("key", entry.Key()), slog.Any("err", err))
+				continue
+			}
+
+			r.mu.Lock()
+			previous, existed := r.namespaces[ns.Name]
+			r.namespaces[ns.Name] = ns
+			r.mu.Unlock()
+
+			if ns.DeletedAt != nil && (!existed || previous.DeletedAt == nil) {
+				deleted(ns)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (r *namespaceRegistry) stop() {
+	if r.watcher != nil {
+		_ = r.watcher.Stop()
+	}
+}
+
+// Returns the namespace, which may have been deleted
+func (r *namespaceRegistry) get(name string) (controlapi.Namespace, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ns, ok := r.namespaces[name]
+	return ns, ok
+}
+
+// Returns every namespace that has not been deleted, ordered by name
+func (r *namespaceRegistry) list() []controlapi.Namespace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	namespaces := make([]controlapi.Namespace, 0, len(r.namespaces))
+	for _, ns := range r.namespaces {
+		if ns.DeletedAt == nil {
+			namespaces = append(namespaces, ns)
+		}
+	}
+
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+
+	return namespaces
+}
+
+// Stores a new namespace, replacing the tombstone of a deleted namespace of the same name
+func (r *namespaceRegistry) create(ns controlapi.Namespace) (*controlapi.Namespace, error) {
+	ns.CreatedAt = time.Now().UTC()
+	ns.DeletedAt = nil
+
+	raw, err := json.Marshal(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, revision, err := r.load(ns.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case existing == nil:
+		_, err = r.kv.Create(ns.Name, raw)
+	case existing.DeletedAt != nil:
+		_, err = r.kv.Update(ns.Name, raw, revision)
+	default:
+		return nil, fmt.Errorf("namespace %s already exists", ns.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store namespace %s: %s", ns.Name, err)
+	}
+
+	return &ns, nil
+}
+
+// Marks a namespace deleted, recording the deletion policy that nodes apply to its workloads
+func (r *namespaceRegistry) delete(name string, policy controlapi.NamespaceDeletionPolicy) (*controlapi.Namespace, error) {
+	ns, revision, err := r.load(name)
+	if err != nil {
+		return nil, err
+	}
+	if ns == nil || ns.DeletedAt != nil {
+		return nil, fmt.Errorf("no such namespace: %s", name)
+	}
+
+	if policy != "" {
+		ns.DeletionPolicy = policy
+	} else if ns.DeletionPolicy == "" {
+		ns.DeletionPolicy = controlapi.NamespaceDeletionStop
+	}
+
+	deletedAt := time.Now().UTC()
+	ns.DeletedAt = &deletedAt
+
+	raw, err := json.Marshal(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.kv.Update(name, raw, revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete namespace %s: %s", name, err)
+	}
+
+	return ns, nil
+}
+
+// Reads a namespace straight from the bucket, returning nil if it has never been created
+func (r *namespaceRegistry) load(name string) (*controlapi.Namespace, uint64, error) {
+	entry, err := r.kv.Get(name)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read namespace %s: %s", name, err)
+	}
+
+	var ns controlapi.Namespace
+	err = json.Unmarshal(entry.Value(), &ns)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode namespace %s: %s", name, err)
+	}
+
+	return &ns, entry.Revision(), nil
+}
+
+// Checks a deploy request against the namespace it is deployed into, applying the namespace's
+// defaults to the request. Requests are only refused for unknown namespaces when the node
+// requires namespaces to exist
+func (api *ApiListener) admitToNamespace(namespace string, request *controlapi.DeployRequest) error {
+	if api.namespaces == nil {
+		return nil
+	}
+
+	ns, ok := api.namespaces.get(namespace)
+	if !ok {
+		if api.namespaces.config.Require {
+			return fmt.Errorf("namespace %s does not exist", namespace)
+		}
+		return nil
+	}
+	if ns.DeletedAt != nil {
+		return fmt.Errorf("namespace %s has been deleted", namespace)
+	}
+
+	applyNamespaceDefaults(ns, request)
+
+	if ns.Quota == nil {
+		return nil
+	}
+
+	procs, err := api.mgr.procMan.ListProcesses()
+	if err != nil {
+		return fmt.Errorf("failed to list running workloads: %s", err)
+	}
+
+	return checkNamespaceQuota(ns, procs, request)
+}
+
+func applyNamespaceDefaults(ns controlapi.Namespace, request *controlapi.DeployRequest) {
+	if request.Resources == nil && ns.DefaultResources != nil {
+		resources := *ns.DefaultResources
+		request.Resources = &resources
+	}
+
+	for service, budget := range ns.DefaultHostServicesBudgets {
+		if _, ok := request.HostServicesBudgets[service]; ok {
+			continue
+		}
+		if request.HostServicesBudgets == nil {
+			request.HostServicesBudgets = make(map[string]controlapi.HostServiceBudget)
+		}
+		request.HostServicesBudgets[service] = budget
+	}
+}
+
+// Merges the namespace's default environment and config into the decrypted environment of a
+// deploy request. Unlike the other defaults this waits for the request's environment to be
+// decrypted, and so is applied separately from admission
+func (api *ApiListener) applyNamespaceEnvironment(namespace string, request *controlapi.DeployRequest) {
+	if api.namespaces == nil {
+		return
+	}
+
+	ns, ok := api.namespaces.get(namespace)
+	if !ok {
+		return
+	}
+
+	request.WorkloadEnvironment = mergeNamespaceEnvironment(ns, request.WorkloadEnvironment)
+}
+
+// Returns the environment with the namespace's defaults filled in. Variables set by the workload
+// win, and a config object set by the workload keeps its own values for the keys of the default
+func mergeNamespaceEnvironment(ns controlapi.Namespace, env map[string]string) map[string]string {
+	if len(ns.DefaultEnvironment) == 0 && len(ns.DefaultConfig) == 0 {
+		return env
+	}
+
+	merged := make(map[string]string, len(ns.DefaultEnvironment)+len(env)+1)
+	for k, v := range ns.DefaultEnvironment {
+		merged[k] = v
+	}
+	if len(ns.DefaultConfig) > 0 {
+		merged[controlapi.NamespaceConfigEnvVar] = mergeNamespaceConfig(ns.DefaultConfig, env[controlapi.NamespaceConfigEnvVar])
+	}
+	for k, v := range env {
+		if k == controlapi.NamespaceConfigEnvVar && len(ns.DefaultConfig) > 0 {
+			continue
+		}
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// Merges a workload's config over the namespace's default, key by key. A workload config that is
+// not a JSON object replaces the default outright
+func mergeNamespaceConfig(defaults json.RawMessage, override string) string {
+	if override == "" {
+		return string(defaults)
+	}
+
+	var config, own map[string]json.RawMessage
+	if json.Unmarshal(defaults, &config) != nil || json.Unmarshal([]byte(override), &own) != nil || own == nil {
+		return override
+	}
+
+	for k, v := range own {
+		config[k] = v
+	}
+
+	raw, _ := json.Marshal(config)
+	return string(raw)
+}
+
+// Checks that the workloads running in the namespace on this node leave room for the request.
+// Memory is counted from the limits workloads declared
+func checkNamespaceQuota(ns controlapi.Namespace, procs []processmanager.ProcessInfo, request *controlapi.DeployRequest) error {
+	workloads := 0
+	memoryMib := 0
+	if request.Resources != nil {
+		memoryMib = request.Resources.MemoryMib
+	}
+
+	for _, proc := range procs {
+		if proc.Namespace != ns.Name {
+			continue
+		}
+
+		workloads++
+		if proc.DeployRequest != nil && proc.DeployRequest.Resources != nil {
+			memoryMib += proc.DeployRequest.Resources.MemoryMib
+		}
+	}
+
+	if ns.Quota.MaxWorkloads > 0 && workloads >= ns.Quota.MaxWorkloads {
+		return fmt.Errorf("namespace %s is limited to %d workloads per node", ns.Name, ns.Quota.MaxWorkloads)
+	}
+	if ns.Quota.MaxMemoryMib > 0 && memoryMib > ns.Quota.MaxMemoryMib {
+		return fmt.Errorf("namespace %s is limited to %d MiB of memory per node", ns.Name, ns.Quota.MaxMemoryMib)
+	}
+
+	return nil
+}
+
+// Applies the deletion policy of a deleted namespace to the workloads this node runs in it
+func (api *ApiListener) namespaceDeleted(ns controlapi.Namespace) {
+	if ns.DeletionPolicy == controlapi.NamespaceDeletionOrphan {
+		api.log.Info("Namespace deleted; orphaning its workloads", slog.String("namespace", ns.Name))
+		return
+	}
+
+	procs, err := api.mgr.procMan.ListProcesses()
+	if err != nil {
+		api.log.Error("Failed to list workloads of deleted namespace", slog.String("namespace", ns.Name), slog.Any("err", err))
+		return
+	}
+
+	for _, proc := range procs {
+		if proc.Namespace != ns.Name {
+			continue
+		}
+
+		err := ignoreAlreadyStopped(api.mgr.StopWorkload(proc.ID, true))
+		if err != nil {
+			api.log.Warn("Failed to stop workload of deleted namespace",
+				slog.String("namespace", ns.Name),
+				slog.String("workload_id", proc.ID),
+				slog.Any("err", err),
+			)
+			continue
+		}
+
+		api.log.Info("Stopped workload of deleted namespace", slog.String("namespace", ns.Name), slog.String("workload_id", proc.ID))
+	}
+}
+
+func (api *ApiListener) publishNamespaceEvent(newEvent func(string, controlapi.NamespaceEvent) cloudevents.Event, ns controlapi.Namespace) {
+	cloudevent := newEvent(api.PublicKey(), controlapi.NamespaceEvent{
+		NodeId:    api.PublicKey(),
+		Namespace: ns,
+	})
+
+	_ = PublishCloudEvent(api.node.events(), ns.Name, cloudevent, api.log)
+}
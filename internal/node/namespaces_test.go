@@ -0,0 +1,95 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/node/processmanager"
+)
+
+func TestNamespaceDefaultsAndQuota(t *testing.T) {
+	ns := controlapi.Namespace{
+		Name:             "payments",
+		Quota:            &controlapi.NamespaceQuota{MaxWorkloads: 2, MaxMemoryMib: 512},
+		DefaultResources: &controlapi.WorkloadResources{MemoryMib: 256},
+		DefaultHostServicesBudgets: map[string]controlapi.HostServiceBudget{
+			"kv":   {RequestsPerSecond: 10},
+			"http": {RequestsPerSecond: 5},
+		},
+	}
+
+	request := &controlapi.DeployRequest{
+		HostServicesBudgets: map[string]controlapi.HostServiceBudget{"kv": {RequestsPerSecond: 100}},
+	}
+	applyNamespaceDefaults(ns, request)
+
+	if request.Resources == nil || request.Resources.MemoryMib != 256 {
+		t.Fatalf("Expected default resources to be applied, got %+v", request.Resources)
+	}
+	if request.HostServicesBudgets["kv"].RequestsPerSecond != 100 || request.HostServicesBudgets["http"].RequestsPerSecond != 5 {
+		t.Fatalf("Expected default budgets to fill in without overriding, got %+v", request.HostServicesBudgets)
+	}
+
+	procs := []processmanager.ProcessInfo{
+		{ID: "a", Namespace: "payments", DeployRequest: &agentapi.DeployRequest{Resources: &controlapi.WorkloadResources{MemoryMib: 128}}},
+		{ID: "b", Namespace: "default", DeployRequest: &agentapi.DeployRequest{Resources: &controlapi.WorkloadResources{MemoryMib: 1024}}},
+	}
+	if err := checkNamespaceQuota(ns, procs, request); err != nil {
+		t.Fatalf("Expected request to fit the namespace quota: %s", err)
+	}
+
+	procs = append(procs, processmanager.ProcessInfo{ID: "c", Namespace: "payments", DeployRequest: &agentapi.DeployRequest{}})
+	if err := checkNamespaceQuota(ns, procs, request); err == nil {
+		t.Fatal("Expected request exceeding the namespace's workload quota to be rejected")
+	}
+
+	ns.Quota.MaxWorkloads = 0
+	request.Resources.MemoryMib = 400
+	if err := checkNamespaceQuota(ns, procs, request); err == nil {
+		t.Fatal("Expected request exceeding the namespace's memory quota to be rejected")
+	}
+}
+
+func TestNamespaceEnvironmentDefaults(t *testing.T) {
+	ns := controlapi.Namespace{
+		Name: "payments",
+		DefaultEnvironment: map[string]string{
+			"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317",
+			"LOG_LEVEL":                   "info",
+		},
+		DefaultConfig: json.RawMessage(`{"region":"us-east","retries":3}`),
+	}
+
+	env := mergeNamespaceEnvironment(ns, map[string]string{
+		"LOG_LEVEL":                      "debug",
+		controlapi.NamespaceConfigEnvVar: `{"retries":5}`,
+	})
+	if env["OTEL_EXPORTER_OTLP_ENDPOINT"] != "http://collector:4317" || env["LOG_LEVEL"] != "debug" {
+		t.Fatalf("Expected default environment to fill in without overriding, got %+v", env)
+	}
+
+	var config map[string]any
+	if err := json.Unmarshal([]byte(env[controlapi.NamespaceConfigEnvVar]), &config); err != nil {
+		t.Fatalf("Expected merged config to be a JSON object: %s", err)
+	}
+	if config["region"] != "us-east" || config["retries"] != float64(5) {
+		t.Fatalf("Expected workload config to win over the default, got %+v", config)
+	}
+
+	env = mergeNamespaceEnvironment(ns, map[string]string{controlapi.NamespaceConfigEnvVar: "plain"})
+	if env[controlapi.NamespaceConfigEnvVar] != "plain" {
+		t.Fatalf("Expected a workload config that is not an object to replace the default, got %s", env[controlapi.NamespaceConfigEnvVar])
+	}
+
+	env = mergeNamespaceEnvironment(ns, nil)
+	if env[controlapi.NamespaceConfigEnvVar] != string(ns.DefaultConfig) {
+		t.Fatalf("Expected the default config to be given as is, got %s", env[controlapi.NamespaceConfigEnvVar])
+	}
+
+	ns.DefaultConfig = json.RawMessage(`[1, 2]`)
+	if err := ns.Validate(); err == nil {
+		t.Fatal("Expected a default config that is not an object to be invalid")
+	}
+}
@@ -0,0 +1,177 @@
+package nexnode
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+// Version of the NATS services registered for workloads that do not declare one
+const defaultNatsServiceVersion = "0.0.0"
+
+// Answers the trigger messages of a function workload, received either on one of its trigger
+// subscriptions or on an endpoint of its NATS service
+type triggerResponder interface {
+	// Replies with the function's response
+	respond(reply *nats.Msg) error
+
+	// Answers a trigger the node rejected for the given reason, one of the TriggerError* values
+	reject(reason string)
+
+	// Answers a trigger whose execution failed
+	fail(err error)
+}
+
+// Answers a message received on a trigger subscription. Failed executions go unanswered, and
+// rejections are only answered when the node is configured to reject with an error
+type msgResponder struct {
+	msg             *nats.Msg
+	rejectWithError bool
+}
+
+func (r *msgResponder) respond(reply *nats.Msg) error {
+	return r.msg.RespondMsg(reply)
+}
+
+func (r *msgResponder) reject(reason string) {
+	if !r.rejectWithError || r.msg.Reply == "" {
+		return
+	}
+
+	reply := nats.NewMsg(r.msg.Reply)
+	reply.Header.Set(controlapi.TriggerErrorHeader, reason)
+	_ = r.msg.RespondMsg(reply)
+}
+
+func (r *msgResponder) fail(error) {}
+
+// Answers a request received on a NATS service endpoint. Service callers expect a reply to every
+// request, so rejections and failures are answered with service errors
+type serviceResponder struct {
+	request micro.Request
+}
+
+func (r *serviceResponder) respond(reply *nats.Msg) error {
+	return r.request.Respond(reply.Data, micro.WithHeaders(micro.Headers(reply.Header)))
+}
+
+func (r *serviceResponder) reject(reason string) {
+	_ = r.request.Error("503", reason, nil)
+}
+
+func (r *serviceResponder) fail(err error) {
+	_ = r.request.Error("500", err.Error(), nil)
+}
+
+// Registers the NATS service declared by a function workload on its host services connection.
+// Requests to each endpoint are executed by the workload's trigger pool like trigger messages
+func (w *WorkloadManager) startNatsService(nc *nats.Conn, agentClient *agentapi.AgentClient, request *agentapi.DeployRequest, pool *triggerPool) (micro.Service, error) {
+	workloadID := agentClient.ID()
+	declared := request.Service
+
+	version := declared.Version
+	if version == "" {
+		version = defaultNatsServiceVersion
+	}
+
+	queueGroup := declared.QueueGroup
+	if queueGroup == "" {
+		queueGroup = controlapi.DefaultNatsServiceQueueGroup
+	}
+
+	metadata := map[string]string{
+		"nex.workload_id":   workloadID,
+		"nex.workload_name": *request.WorkloadName,
+		"nex.namespace":     *request.Namespace,
+	}
+	for k, v := range declared.Metadata {
+		metadata[k] = v
+	}
+
+	svc, err := micro.AddService(nc, micro.Config{
+		Name:        declared.Name,
+		Version:     version,
+		Description: declared.Description,
+		Metadata:    metadata,
+		QueueGroup:  queueGroup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register nats service %s: %w", declared.Name, err)
+	}
+
+	for _, endpoint := range declared.Endpoints {
+		subject := endpoint.Subject
+		if subject == "" {
+			subject = endpoint.Name
+		}
+
+		opts := []micro.EndpointOpt{micro.WithEndpointSubject(subject)}
+		if endpoint.QueueGroup != "" {
+			opts = append(opts, micro.WithEndpointQueueGroup(endpoint.QueueGroup))
+		}
+		if len(endpoint.Metadata) > 0 {
+			opts = append(opts, micro.WithEndpointMetadata(endpoint.Metadata))
+		}
+
+		err = svc.AddEndpoint(endpoint.Name, w.generateServiceHandler(agentClient, subject, request, pool), opts...)
+		if err != nil {
+			_ = svc.Stop()
+			return nil, fmt.Errorf("failed to add endpoint %s to nats service %s: %w", endpoint.Name, declared.Name, err)
+		}
+
+		w.log.Info("Added nats service endpoint for deployed workload",
+			slog.String("workload_id", workloadID),
+			slog.String("service", declared.Name),
+			slog.String("endpoint", endpoint.Name),
+			slog.String("subject", subject),
+		)
+	}
+
+	return svc, nil
+}
+
+// Generates the handler of a NATS service endpoint, which queues each request on the workload's
+// trigger pool. Paused workloads answer requests with a service error, as their endpoints stay
+// registered while trigger subscriptions are drained
+func (w *WorkloadManager) generateServiceHandler(agentClient *agentapi.AgentClient, subject string, request *agentapi.DeployRequest, pool *triggerPool) micro.HandlerFunc {
+	workloadID := agentClient.ID()
+	dispatch := w.triggerDispatcher(agentClient, subject, request, pool)
+
+	return func(req micro.Request) {
+		if w.isPaused(workloadID) {
+			_ = req.Error("503", "workload is paused", nil)
+			return
+		}
+
+		dispatch(&nats.Msg{
+			Subject: req.Subject(),
+			Header:  nats.Header(req.Headers()),
+			Data:    req.Data(),
+		}, &serviceResponder{request: req})
+	}
+}
+
+// Stops the NATS service of a workload, if it has one, draining its endpoint subscriptions
+func (w *WorkloadManager) stopNatsService(workloadID string) {
+	w.poolMutex.Lock()
+	svc, ok := w.services[workloadID]
+	delete(w.services, workloadID)
+	w.poolMutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	err := svc.Stop()
+	if err != nil {
+		w.log.Warn("Failed to stop nats service of workload",
+			slog.String("workload_id", workloadID),
+			slog.String("service", svc.Info().Name),
+			slog.String("err", err.Error()),
+		)
+	}
+}
@@ -0,0 +1,111 @@
+package nexnode
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+func TestServiceResponderAnswersEveryRequest(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Port: -1})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %s", err)
+	}
+	go s.Start()
+	defer s.Shutdown()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err)
+	}
+	defer nc.Close()
+
+	svc, err := micro.AddService(nc, micro.Config{Name: "orders", Version: defaultNatsServiceVersion})
+	if err != nil {
+		t.Fatalf("Failed to add service: %s", err)
+	}
+	defer func() { _ = svc.Stop() }()
+
+	handlers := map[string]func(triggerResponder){
+		"ok": func(r triggerResponder) {
+			reply := nats.NewMsg("")
+			reply.Header.Set("X-Order", "42")
+			reply.Data = []byte("created")
+			_ = r.respond(reply)
+		},
+		"rejected": func(r triggerResponder) { r.reject(controlapi.TriggerErrorCircuitOpen) },
+		"failed":   func(r triggerResponder) { r.fail(errors.New("function threw")) },
+	}
+	for name, handler := range handlers {
+		handler := handler
+		err = svc.AddEndpoint(name, micro.HandlerFunc(func(req micro.Request) {
+			handler(&serviceResponder{request: req})
+		}))
+		if err != nil {
+			t.Fatalf("Failed to add endpoint: %s", err)
+		}
+	}
+
+	resp, err := nc.Request("ok", nil, time.Second)
+	if err != nil {
+		t.Fatalf("Expected a reply: %s", err)
+	}
+	if string(resp.Data) != "created" || resp.Header.Get("X-Order") != "42" {
+		t.Fatalf("Expected the function's response and headers, got %q %v", resp.Data, resp.Header)
+	}
+
+	expected := map[string]string{"rejected": "503", "failed": "500"}
+	for subject, code := range expected {
+		resp, err := nc.Request(subject, nil, time.Second)
+		if err != nil {
+			t.Fatalf("Expected a reply to the %s request: %s", subject, err)
+		}
+		if resp.Header.Get(micro.ErrorCodeHeader) != code {
+			t.Fatalf("Expected the %s request to be answered with a %s service error, got %v", subject, code, resp.Header)
+		}
+	}
+}
+
+func TestMsgResponderOnlyRejectsWithErrorWhenConfigured(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Port: -1})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %s", err)
+	}
+	go s.Start()
+	defer s.Shutdown()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err)
+	}
+	defer nc.Close()
+
+	for _, rejectWithError := range []bool{false, true} {
+		sub, _ := nc.Subscribe("orders", func(msg *nats.Msg) {
+			responder := &msgResponder{msg: msg, rejectWithError: rejectWithError}
+			responder.fail(errors.New("function threw"))
+			responder.reject(controlapi.TriggerErrorCircuitOpen)
+		})
+
+		resp, err := nc.Request("orders", nil, 250*time.Millisecond)
+		if !rejectWithError && err == nil {
+			t.Fatal("Expected the trigger to go unanswered")
+		}
+		if rejectWithError && (err != nil || resp.Header.Get(controlapi.TriggerErrorHeader) != controlapi.TriggerErrorCircuitOpen) {
+			t.Fatalf("Expected the trigger to be rejected with an error header, got %v %v", resp, err)
+		}
+
+		_ = sub.Unsubscribe()
+	}
+}
@@ -0,0 +1,139 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/events"
+)
+
+// Entries outlive a couple of missed heartbeats before they expire
+const defaultNexusRegistryTTL = 3 * heartbeatInterval
+
+// The node's entry in the nexus registry
+type nexusRegistration struct {
+	kv     nats.KeyValue
+	member controlapi.NexusMember
+}
+
+// Binds to the nexus registry bucket, creating it if needed, registers the node and announces
+// that it joined the nexus
+func (n *Node) joinNexus() error {
+	js, err := n.nc.JetStream()
+	if err != nil {
+		return err
+	}
+
+	bucket := n.config.NexusRegistry.Bucket
+	if bucket == "" {
+		bucket = controlapi.DefaultNexusRegistryBucket
+	}
+
+	ttl := defaultNexusRegistryTTL
+	if n.config.NexusRegistry.TTLMillis > 0 {
+		ttl = time.Duration(n.config.NexusRegistry.TTLMillis) * time.Millisecond
+	}
+	if ttl <= heartbeatInterval {
+		n.log.Warn("Nexus registry TTL does not outlast the heartbeat interval; the node's entry will expire between heartbeats",
+			slog.Duration("ttl", ttl),
+			slog.Duration("heartbeat_interval", heartbeatInterval),
+		)
+	}
+
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Nex nexus members",
+			History:     1,
+			TTL:         ttl,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to bind to nexus registry bucket %s: %s", bucket, err)
+	}
+
+	now := time.Now().UTC()
+	registration := &nexusRegistration{
+		kv: kv,
+		member: controlapi.NexusMember{
+			NodeId:     n.publicKey,
+			Nexus:      n.nexus,
+			Version:    Version(),
+			PublicXKey: n.api.PublicXKey(),
+			Tags:       n.config.Tags,
+			JoinedAt:   now,
+			LastSeen:   now,
+		},
+	}
+
+	err = registration.put()
+	if err != nil {
+		return fmt.Errorf("failed to register in nexus registry bucket %s: %s", bucket, err)
+	}
+	n.registrationMutex.Lock()
+	n.registration = registration
+	n.registrationMutex.Unlock()
+
+	n.log.Info("Joined nexus", slog.String("nexus", n.nexus), slog.String("bucket", bucket))
+
+	cloudevent := events.NodeJoinedNexus(n.publicKey, controlapi.NexusMembershipEvent{NexusMember: registration.member})
+	return PublishCloudEvent(n.events(), systemNamespace, cloudevent, n.log)
+}
+
+// Refreshes the node's entry with the state reported by its heartbeat, which also picks up tag
+// changes and a rotated xkey
+func (n *Node) refreshNexusMembership(heartbeat controlapi.HeartbeatEvent) {
+	n.registrationMutex.Lock()
+	defer n.registrationMutex.Unlock()
+
+	if n.registration == nil {
+		return
+	}
+
+	n.registration.member.Version = heartbeat.Version
+	n.registration.member.Tags = heartbeat.Tags
+	n.registration.member.RunningMachines = heartbeat.RunningMachines
+	n.registration.member.PublicXKey = n.api.PublicXKey()
+	n.registration.member.LastSeen = time.Now().UTC()
+
+	err := n.registration.put()
+	if err != nil {
+		n.log.Warn("Failed to refresh nexus registry entry", slog.Any("err", err))
+	}
+}
+
+// Removes the node's entry from the nexus registry and announces that it left the nexus
+func (n *Node) leaveNexus() {
+	n.registrationMutex.Lock()
+	defer n.registrationMutex.Unlock()
+
+	if n.registration == nil {
+		return
+	}
+
+	err := n.registration.kv.Delete(n.publicKey)
+	if err != nil {
+		n.log.Warn("Failed to remove nexus registry entry", slog.Any("err", err))
+	}
+
+	cloudevent := events.NodeLeftNexus(n.publicKey, controlapi.NexusMembershipEvent{NexusMember: n.registration.member})
+	_ = PublishCloudEvent(n.events(), systemNamespace, cloudevent, n.log)
+
+	n.registration = nil
+}
+
+func (r *nexusRegistration) put() error {
+	raw, err := json.Marshal(r.member)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.kv.Put(r.member.NodeId, raw)
+	return err
+}
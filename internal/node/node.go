@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -16,13 +17,12 @@ import (
 	"syscall"
 	"time"
 
-	cloudevents "github.com/cloudevents/sdk-go"
-	"github.com/google/uuid"
 	"github.com/nats-io/nats-server/v2/server"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nkeys"
 	controlapi "github.com/synadia-io/nex/control-api"
 	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/events"
 	"github.com/synadia-io/nex/internal/models"
 	"github.com/synadia-io/nex/internal/node/observability"
 )
@@ -46,6 +46,51 @@ type Node struct {
 	ctx      context.Context
 	sigs     chan os.Signal
 
+	// Set while the node is cordoned; the timer lifts the cordon at the end of its window
+	cordonMutex sync.Mutex
+	cordon      *controlapi.CordonStatus
+	cordonTimer *time.Timer
+
+	// Set while the host is under pressure; calm counts consecutive samples below the thresholds
+	pressureMutex sync.Mutex
+	pressure      *controlapi.PressureStatus
+	pressureCalm  int
+
+	// Serializes configuration reloads requested by signal or through the control API
+	reloadMutex sync.Mutex
+
+	// Tag changes made through the control API, applied over the configured tags
+	runtimeTags *runtimeTags
+
+	// Set while an update is staged or being applied; the run loop restarts into updates sent on restart
+	updating uint32
+	restart  chan *stagedUpdate
+
+	// Handed off by the node process that exec'd this one during an update; nil otherwise
+	handoff *updateHandoff
+
+	// The node's current or most recent rootfs rollout
+	rootfsMutex sync.Mutex
+	rootfs      *rootfsRollout
+
+	// The node's evacuation, once it has been asked to move its workloads to its peers
+	evacuationMutex sync.Mutex
+	evacuation      *nodeEvacuation
+
+	// Keys read from the node's key file; nil when the node does not persist its keys
+	keys      *nodeKeyFile
+	keysMutex sync.Mutex
+
+	// Set once the node has registered in the nexus registry; nil after it leaves
+	registration      *nexusRegistration
+	registrationMutex sync.Mutex
+
+	// Serves pprof profiles and state snapshots; nil unless diagnostics are enabled
+	diagnostics *http.Server
+
+	// Held while a workload is checkpointed, so that checkpoints don't pause the same machine
+	checkpointMutex sync.Mutex
+
 	log *slog.Logger
 
 	config      *models.NodeConfiguration
@@ -68,6 +113,9 @@ type Node struct {
 	telemetry *observability.Telemetry
 
 	capabilities controlapi.NodeCapabilities
+
+	// Results of the checks run against the host while the node initialized
+	preflight *controlapi.PreflightReport
 }
 
 func NewNode(
@@ -84,6 +132,7 @@ func NewNode(
 		log:      log,
 		nodeOpts: nodeOpts,
 		opts:     opts,
+		restart:  make(chan *stagedUpdate, 1),
 	}
 
 	err := node.validateConfig()
@@ -91,6 +140,15 @@ func NewNode(
 		return nil, fmt.Errorf("failed to create node: %s", err.Error())
 	}
 
+	if node.config.Identity != nil {
+		keypair, err = node.loadIdentity(keypair)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create node: %s", err.Error())
+		}
+	}
+
+	keypair = node.adoptHandoff(keypair)
+
 	err = node.createPid()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create node: %s", err.Error())
@@ -106,10 +164,19 @@ func NewNode(
 	node.issuerKeypair, _ = nkeys.CreateAccount()
 
 	node.nexus = nodeOpts.NexusName
-	node.capabilities = *models.GetNodeCapabilities(node.config.Tags)
+	node.capabilities = node.computeCapabilities()
+	node.applyLogLevel()
 	return node, nil
 }
 
+func (n *Node) computeCapabilities() controlapi.NodeCapabilities {
+	capabilities := *models.GetNodeCapabilities(n.config.Tags)
+	for _, extension := range n.config.ProviderExtensions {
+		capabilities.SupportedProviders = append(capabilities.SupportedProviders, extension.WorkloadType)
+	}
+	return capabilities
+}
+
 func (n *Node) PublicKey() (*string, error) {
 	pubkey, err := n.keypair.PublicKey()
 	if err != nil {
@@ -132,6 +199,13 @@ func (n *Node) Start() {
 	n.startedAt = time.Now()
 	_ = n.publishNodeStarted()
 
+	if n.config.NexusRegistry != nil {
+		err = n.joinNexus()
+		if err != nil {
+			n.log.Error("Failed to join nexus registry", slog.Any("err", err))
+		}
+	}
+
 	timer := time.NewTicker(runloopTickInterval)
 	defer timer.Stop()
 
@@ -144,8 +218,19 @@ func (n *Node) Start() {
 			// TODO: check NATS subscription statuses, machine manager, telemetry etc.
 		case <-heartbeat.C:
 			_ = n.publishHeartbeat()
+		case staged := <-n.restart:
+			n.restartInto(staged)
 		case sig := <-n.sigs:
 			n.log.Debug("received signal", slog.Any("signal", sig))
+			if sig == syscall.SIGHUP {
+				n.reloadOnSignal()
+				continue
+			}
+			// a second SIGTERM while evacuating shuts the node down at once
+			if sig == syscall.SIGTERM && n.config.Evacuation != nil && n.config.Evacuation.OnSigterm && n.currentEvacuation() == nil {
+				go n.evacuateAndShutdown("received SIGTERM")
+				continue
+			}
 			n.shutdown()
 		case <-n.ctx.Done():
 			n.shutdown()
@@ -239,7 +324,7 @@ func (n *Node) init() error {
 			n.log.Error("Failed to initialize telemetry", slog.Any("err", _err))
 			err = errors.Join(err, _err)
 		} else {
-			n.log.Info("Telemetry status", slog.Bool("metrics", n.config.OtelMetrics), slog.Bool("traces", n.config.OtelTraces))
+			n.log.Info("Telemetry status", slog.Bool("metrics", n.config.OtelMetrics), slog.Bool("traces", n.config.OtelTraces), slog.Bool("logs", n.config.OtelLogs))
 		}
 
 		if !n.config.NoSandbox {
@@ -271,6 +356,8 @@ func (n *Node) init() error {
 			n.log.Info("Established node NATS connection", slog.String("servers", n.opts.Servers))
 		}
 
+		n.runPreflight()
+
 		n.manager, _err = NewWorkloadManager(
 			n.ctx,
 			n.cancelF,
@@ -303,6 +390,30 @@ func (n *Node) init() error {
 			go n.handleAutostarts()
 		}
 
+		if err == nil && n.config.Pressure != nil {
+			go n.runPressureMonitor()
+		}
+
+		if err == nil && n.config.Evacuation != nil && n.config.Evacuation.NoticeUrl != "" {
+			go n.runEvacuationNotices()
+		}
+
+		if err == nil && n.config.Checkpoints != nil && n.config.Checkpoints.IntervalMillis > 0 {
+			go n.runCheckpoints()
+		}
+
+		if err == nil && n.handoff != nil {
+			go n.redeployHandoff()
+		}
+
+		if err == nil && n.config.Diagnostics != nil {
+			_err = n.startDiagnostics()
+			if _err != nil {
+				n.log.Error("Failed to start diagnostics listener", slog.Any("err", _err))
+				err = errors.Join(err, _err)
+			}
+		}
+
 		n.installSignalHandlers()
 	})
 
@@ -338,7 +449,7 @@ func (n *Node) handleAutostarts() {
 		var err error
 
 		for agentClient == nil {
-			agentClient, err = n.manager.SelectRandomAgent()
+			agentClient, err = n.manager.ReserveAgent(autostart.WorkloadType, nil)
 			if err != nil {
 				n.log.Warn("Failed to resolve agent for autostart", slog.String("error", err.Error()))
 				time.Sleep(25 * time.Millisecond)
@@ -351,15 +462,17 @@ func (n *Node) handleAutostarts() {
 			controlapi.Environment(autostart.Environment),
 			controlapi.Essential(false), // avoid startup flapping, also not supported for funcs
 			controlapi.Issuer(n.issuerKeypair),
-			controlapi.SenderXKey(n.api.xk),
+			controlapi.SenderXKey(n.api.xkeys.key()),
 			controlapi.TargetNode(n.publicKey),
 			controlapi.TargetPublicXKey(n.api.PublicXKey()),
 			controlapi.WorkloadName(autostart.Name),
 			controlapi.WorkloadType(autostart.WorkloadType),
 			controlapi.TriggerSubjects(autostart.TriggerSubjects),
 			controlapi.WorkloadDescription(*autostart.Description),
+			controlapi.Group(autostart.Group),
 		)
 		if err != nil {
+			n.manager.ReleaseAgent(agentClient)
 			n.log.Error("Failed to create deployment request for autostart workload",
 				slog.Any("error", err),
 			)
@@ -368,6 +481,7 @@ func (n *Node) handleAutostarts() {
 
 		_, err = request.Validate()
 		if err != nil {
+			n.manager.ReleaseAgent(agentClient)
 			n.log.Error("Failed to validate autostart deployment request",
 				slog.Any("error", err),
 			)
@@ -379,8 +493,9 @@ func (n *Node) handleAutostarts() {
 			DecodedClaims:        request.DecodedClaims,
 			Description:          request.Description,
 			EncryptedEnvironment: request.Environment,
-			Environment:          request.WorkloadEnvironment,
+			Environment:          n.resolveWorkloadEnvironment(agentClient.ID(), autostart.Namespace, request.WorkloadEnvironment),
 			Essential:            request.Essential,
+			Group:                request.Group,
 			JsDomain:             request.JsDomain,
 			Location:             request.Location,
 			Namespace:            &autostart.Namespace,
@@ -394,8 +509,9 @@ func (n *Node) handleAutostarts() {
 			WorkloadJwt:          request.WorkloadJwt,
 		}
 
-		numBytes, workloadHash, err := n.api.mgr.CacheWorkload(agentClient.ID(), request)
+		numBytes, workloadHash, err := n.api.mgr.CacheWorkload(agentClient.ID(), autostart.Namespace, request)
 		if err != nil {
+			n.manager.ReleaseAgent(agentClient)
 			n.api.log.Error("Failed to cache auto-start workload bytes",
 				slog.Any("err", err),
 				slog.String("name", autostart.Name),
@@ -406,6 +522,7 @@ func (n *Node) handleAutostarts() {
 		}
 		agentDeployRequest.TotalBytes = int64(numBytes)
 		agentDeployRequest.Hash = *workloadHash
+		n.describeNativeWorkload(agentDeployRequest)
 
 		err = n.api.mgr.DeployWorkload(agentClient, agentDeployRequest)
 		if err != nil {
@@ -438,9 +555,25 @@ func (n *Node) loadNodeConfig() error {
 		n.config.OtelMetricsExporter = n.nodeOpts.OtelMetricsExporter
 		n.config.OtelMetricsPort = n.nodeOpts.OtelMetricsPort
 		n.config.OtelTraces = n.nodeOpts.OtelTraces
+		n.config.OtelLogs = n.nodeOpts.OtelLogs
+		n.config.OtelLogsExporter = n.nodeOpts.OtelLogsExporter
 		n.config.OtelTracesExporter = n.nodeOpts.OtelTracesExporter
 	}
 
+	if n.runtimeTags == nil {
+		var err error
+
+		n.runtimeTags, err = loadRuntimeTags(runtimeTagsFilepath(n.config))
+		if err != nil {
+			return err
+		}
+
+		if n.config.Tags == nil {
+			n.config.Tags = make(map[string]string)
+		}
+		n.runtimeTags.apply(n.config.Tags)
+	}
+
 	return nil
 }
 
@@ -450,16 +583,11 @@ func (n *Node) publishNodeLameDuckEntered() error {
 		Id:      n.publicKey,
 	}
 
-	cloudevent := cloudevents.NewEvent()
-	cloudevent.SetSource(n.publicKey)
-	cloudevent.SetID(uuid.NewString())
+	cloudevent := events.LameDuckEntered(n.publicKey, nodeLameDuck)
 	cloudevent.SetTime(n.startedAt)
-	cloudevent.SetType(controlapi.LameDuckEnteredEventType)
-	cloudevent.SetDataContentType(cloudevents.ApplicationJSON)
-	_ = cloudevent.SetData(nodeLameDuck)
 
 	n.log.Info("Publishing node lame duck entered event")
-	return PublishCloudEvent(n.nc, "system", cloudevent, n.log)
+	return PublishCloudEvent(n.events(), "system", cloudevent, n.log)
 }
 
 func (n *Node) publishHeartbeat() error {
@@ -480,15 +608,12 @@ func (n *Node) publishHeartbeat() error {
 		Tags:            n.config.Tags,
 	}
 
-	cloudevent := cloudevents.NewEvent()
-	cloudevent.SetSource(n.publicKey)
-	cloudevent.SetID(uuid.NewString())
+	n.refreshNexusMembership(evt)
+
+	cloudevent := events.Heartbeat(n.publicKey, evt)
 	cloudevent.SetTime(now)
-	cloudevent.SetType(controlapi.HeartbeatEventType)
-	cloudevent.SetDataContentType(cloudevents.ApplicationJSON)
-	_ = cloudevent.SetData(evt)
 
-	return PublishCloudEvent(n.nc, systemNamespace, cloudevent, n.log)
+	return PublishCloudEvent(n.events(), systemNamespace, cloudevent, n.log)
 }
 
 func (n *Node) publishNodeStarted() error {
@@ -498,16 +623,11 @@ func (n *Node) publishNodeStarted() error {
 		Tags:    n.config.Tags,
 	}
 
-	cloudevent := cloudevents.NewEvent()
-	cloudevent.SetSource(n.publicKey)
-	cloudevent.SetID(uuid.NewString())
+	cloudevent := events.NodeStarted(n.publicKey, nodeStart)
 	cloudevent.SetTime(n.startedAt)
-	cloudevent.SetType(controlapi.NodeStartedEventType)
-	cloudevent.SetDataContentType(cloudevents.ApplicationJSON)
-	_ = cloudevent.SetData(nodeStart)
 
 	n.log.Info("Publishing node started event")
-	return PublishCloudEvent(n.nc, "system", cloudevent, n.log)
+	return PublishCloudEvent(n.events(), "system", cloudevent, n.log)
 }
 
 func (n *Node) publishNodeStopped() error {
@@ -516,16 +636,10 @@ func (n *Node) publishNodeStopped() error {
 		Graceful: true,
 	}
 
-	cloudevent := cloudevents.NewEvent()
-	cloudevent.SetSource(n.publicKey)
-	cloudevent.SetID(uuid.NewString())
-	cloudevent.SetTime(time.Now().UTC())
-	cloudevent.SetType(controlapi.NodeStoppedEventType)
-	cloudevent.SetDataContentType(cloudevents.ApplicationJSON)
-	_ = cloudevent.SetData(evt)
+	cloudevent := events.NodeStopped(n.publicKey, evt)
 
 	n.log.Info("Publishing node stopped event")
-	return PublishCloudEvent(n.nc, "system", cloudevent, n.log)
+	return PublishCloudEvent(n.events(), "system", cloudevent, n.log)
 }
 
 func (n *Node) validateConfig() error {
@@ -536,6 +650,17 @@ func (n *Node) validateConfig() error {
 		}
 	}
 
+	if n.config.Artifacts != nil {
+		artifacts := newArtifactManager(n.config, n.log, func() (*nats.Conn, error) {
+			return models.GenerateConnectionFromOpts(n.opts, n.log)
+		})
+
+		err := artifacts.resolve(n.config)
+		if err != nil {
+			return fmt.Errorf("failed to resolve kernel and rootfs artifacts: %s", err)
+		}
+	}
+
 	return CheckPrerequisites(n.config, true, n.log)
 }
 
@@ -551,6 +676,9 @@ func (n *Node) shutdown() {
 			_ = n.manager.Stop()
 		}
 
+		n.leaveNexus()
+		n.stopDiagnostics()
+
 		if !n.startedAt.IsZero() {
 			_ = n.publishNodeStopped()
 		}
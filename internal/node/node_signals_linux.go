@@ -13,5 +13,5 @@ func (n *Node) installSignalHandlers() {
 	// both firecracker and the embedded NATS server(s) register signal handlers... wipe those so ours are the ones being used
 	signal.Reset(syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP)
 	n.sigs = make(chan os.Signal, 1)
-	signal.Notify(n.sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	signal.Notify(n.sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGHUP)
 }
@@ -0,0 +1,202 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/events"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+// Prefix reserved for tags that the node manages itself
+const reservedTagPrefix = "nex."
+
+// Tag changes made through the control API, layered over the tags in the configuration file.
+// Removals are recorded so that a tag removed at runtime stays removed even when the
+// configuration file still sets it
+type runtimeTags struct {
+	Set     map[string]string `json:"set,omitempty"`
+	Removed []string          `json:"removed,omitempty"`
+}
+
+// Returns the path of the file in which runtime tag changes are persisted
+func runtimeTagsFilepath(config *models.NodeConfiguration) string {
+	if config.TagsFilepath != "" {
+		return config.TagsFilepath
+	}
+
+	if config.DefaultResourceDir != "" {
+		return filepath.Join(config.DefaultResourceDir, "runtime_tags.json")
+	}
+
+	return filepath.Join(os.TempDir(), "nex-runtime-tags.json")
+}
+
+// Reads persisted runtime tag changes; a missing file yields no changes
+func loadRuntimeTags(path string) (*runtimeTags, error) {
+	tags := &runtimeTags{Set: make(map[string]string)}
+
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tags, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(bytes, tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse runtime tags file %s: %s", path, err)
+	}
+
+	if tags.Set == nil {
+		tags.Set = make(map[string]string)
+	}
+
+	return tags, nil
+}
+
+// Records the given changes, later changes to a key replacing earlier ones
+func (r *runtimeTags) update(set map[string]string, remove []string) {
+	for _, key := range remove {
+		delete(r.Set, key)
+		if !slices.Contains(r.Removed, key) {
+			r.Removed = append(r.Removed, key)
+		}
+	}
+
+	for key, value := range set {
+		r.Set[key] = value
+		r.Removed = slices.DeleteFunc(r.Removed, func(removed string) bool { return removed == key })
+	}
+}
+
+// Applies the recorded changes to the given tags
+func (r *runtimeTags) apply(tags map[string]string) {
+	for _, key := range r.Removed {
+		delete(tags, key)
+	}
+
+	for key, value := range r.Set {
+		tags[key] = value
+	}
+}
+
+func (r *runtimeTags) save(path string) error {
+	bytes, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tags-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(bytes)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (r *runtimeTags) clone() *runtimeTags {
+	clone := &runtimeTags{
+		Set:     make(map[string]string, len(r.Set)),
+		Removed: slices.Clone(r.Removed),
+	}
+	for key, value := range r.Set {
+		clone.Set[key] = value
+	}
+
+	return clone
+}
+
+func validateTagChanges(set map[string]string, remove []string) error {
+	if len(set) == 0 && len(remove) == 0 {
+		return errors.New("no tag changes requested")
+	}
+
+	keys := slices.Clone(remove)
+	for key := range set {
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		if strings.TrimSpace(key) == "" {
+			return errors.New("tag names cannot be empty")
+		}
+		if strings.HasPrefix(key, reservedTagPrefix) {
+			return fmt.Errorf("tag %s is managed by the node and cannot be changed", key)
+		}
+		if key == nodeNameTag {
+			return fmt.Errorf("tag %s can only be set in the node configuration file", key)
+		}
+	}
+
+	return nil
+}
+
+// Sets and removes node tags at runtime. Changes are persisted before they are applied so that
+// they survive restarts, and take effect in subsequent pings and auctions
+func (n *Node) UpdateTags(set map[string]string, remove []string) (map[string]string, error) {
+	err := validateTagChanges(set, remove)
+	if err != nil {
+		return nil, err
+	}
+
+	n.reloadMutex.Lock()
+	defer n.reloadMutex.Unlock()
+
+	next := n.runtimeTags.clone()
+	next.update(set, remove)
+
+	err = next.save(runtimeTagsFilepath(n.config))
+	if err != nil {
+		return nil, fmt.Errorf("failed to persist node tags: %s", err)
+	}
+	n.runtimeTags = next
+
+	n.cordonMutex.Lock()
+	tags := make(map[string]string, len(n.config.Tags))
+	for key, value := range n.config.Tags {
+		tags[key] = value
+	}
+	next.apply(tags)
+	n.config.Tags = tags
+	n.capabilities = n.computeCapabilities()
+	n.cordonMutex.Unlock()
+
+	_ = n.publishNodeTagsChanged(tags)
+
+	return tags, nil
+}
+
+func (n *Node) publishNodeTagsChanged(tags map[string]string) error {
+	if n.nc == nil {
+		return nil
+	}
+
+	evt := controlapi.NodeTagsChangedEvent{
+		Id:   n.publicKey,
+		Tags: tags,
+	}
+
+	cloudevent := events.NodeTagsChanged(n.publicKey, evt)
+
+	return PublishCloudEvent(n.events(), systemNamespace, cloudevent, n.log)
+}
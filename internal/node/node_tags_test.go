@@ -0,0 +1,52 @@
+package nexnode
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRuntimeTagsPersistAndOverrideConfiguredTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tags.json")
+
+	tags, err := loadRuntimeTags(path)
+	if err != nil {
+		t.Fatalf("Expected a missing file to load as no changes, got %s", err)
+	}
+
+	tags.update(map[string]string{"zone": "eu-1"}, []string{"tier"})
+	err = tags.save(path)
+	if err != nil {
+		t.Fatalf("Failed to save runtime tags: %s", err)
+	}
+
+	loaded, err := loadRuntimeTags(path)
+	if err != nil {
+		t.Fatalf("Failed to load runtime tags: %s", err)
+	}
+
+	configured := map[string]string{"zone": "us-1", "tier": "spot", "rack": "a"}
+	loaded.apply(configured)
+	if configured["zone"] != "eu-1" || configured["rack"] != "a" {
+		t.Fatalf("Expected runtime tags to override configured tags, got %v", configured)
+	}
+	if _, ok := configured["tier"]; ok {
+		t.Fatalf("Expected removed tag to stay removed, got %v", configured)
+	}
+
+	loaded.update(map[string]string{"tier": "reserved"}, nil)
+	if len(loaded.Removed) != 0 {
+		t.Fatalf("Expected setting a removed tag to clear its removal, got %v", loaded.Removed)
+	}
+}
+
+func TestValidateTagChangesRejectsReservedTags(t *testing.T) {
+	if validateTagChanges(map[string]string{"nex.arch": "arm64"}, nil) == nil {
+		t.Fatal("Expected node-managed tags to be rejected")
+	}
+	if validateTagChanges(nil, []string{""}) == nil {
+		t.Fatal("Expected empty tag names to be rejected")
+	}
+	if validateTagChanges(map[string]string{"zone": "eu-1"}, nil) != nil {
+		t.Fatal("Expected operator tags to be accepted")
+	}
+}
@@ -0,0 +1,68 @@
+package observability
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Usage of the internal NATS server by the connections of a single agent
+type AgentConnectionSample struct {
+	AgentID       string
+	Connections   int64
+	Subscriptions int64
+	PendingBytes  int64
+	InBytes       int64
+	OutBytes      int64
+}
+
+// Registers a callback that samples the internal NATS connections of every agent each time
+// metrics are collected
+func (t *Telemetry) ObserveAgentConnections(sample func() []AgentConnectionSample) error {
+	var e, err error
+
+	connections, e := t.meter.Int64ObservableGauge("nex-agent-internal-connections",
+		metric.WithDescription("Number of internal NATS connections logged in to an agent's account"),
+	)
+	err = errors.Join(err, e)
+
+	subscriptions, e := t.meter.Int64ObservableGauge("nex-agent-internal-subscriptions",
+		metric.WithDescription("Number of internal NATS subscriptions held by an agent's connections"),
+	)
+	err = errors.Join(err, e)
+
+	pending, e := t.meter.Int64ObservableGauge("nex-agent-internal-pending-bytes",
+		metric.WithDescription("Bytes waiting to be written to an agent's internal NATS connections"),
+	)
+	err = errors.Join(err, e)
+
+	inBytes, e := t.meter.Int64ObservableCounter("nex-agent-internal-in-bytes",
+		metric.WithDescription("Total bytes received by the internal NATS server from an agent's connections"),
+	)
+	err = errors.Join(err, e)
+
+	outBytes, e := t.meter.Int64ObservableCounter("nex-agent-internal-out-bytes",
+		metric.WithDescription("Total bytes sent by the internal NATS server to an agent's connections"),
+	)
+	err = errors.Join(err, e)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = t.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, s := range sample() {
+			attrs := metric.WithAttributes(attribute.String("workload_id", s.AgentID))
+			o.ObserveInt64(connections, s.Connections, attrs)
+			o.ObserveInt64(subscriptions, s.Subscriptions, attrs)
+			o.ObserveInt64(pending, s.PendingBytes, attrs)
+			o.ObserveInt64(inBytes, s.InBytes, attrs)
+			o.ObserveInt64(outBytes, s.OutBytes, attrs)
+		}
+		return nil
+	}, connections, subscriptions, pending, inBytes, outBytes)
+
+	return err
+}
@@ -0,0 +1,96 @@
+package observability
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+func (t *Telemetry) initLogs() error {
+	if !t.logsEnabled {
+		return nil
+	}
+
+	res, err := t.newResource(t.ctx)
+	if err != nil {
+		return err
+	}
+
+	var exporter sdklog.Exporter
+
+	t.log.Debug("Logs enabled", slog.String("exporter", t.logsExporter))
+	switch t.logsExporter {
+	case "http":
+		opts := []otlploghttp.Option{otlploghttp.WithEndpoint(t.otelExporterUrl), otlploghttp.WithHeaders(t.exporterHeaders)}
+		if !t.exporterTLS {
+			opts = append(opts, otlploghttp.WithInsecure())
+		}
+		exporter, err = otlploghttp.New(t.ctx, opts...)
+		if err != nil {
+			return err
+		}
+		t.log.Info("Initialized OTLP log exporter", slog.String("url", t.otelExporterUrl))
+	default:
+		f, err := os.Create("logs.log")
+		if err != nil {
+			return err
+		}
+		exporter, err = stdoutlog.New(stdoutlog.WithWriter(f))
+		if err != nil {
+			return err
+		}
+		t.log.Info("Initialized OTLP log exporter", slog.String("file", "logs.log"))
+	}
+
+	loggerProvider := sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+	)
+
+	t.loggerProvider = loggerProvider
+	t.logger = loggerProvider.Logger(t.serviceName)
+	return nil
+}
+
+// Reports whether log records passed to EmitLog are exported
+func (t *Telemetry) LogsEnabled() bool {
+	return t.logger != nil
+}
+
+// Emits a log record through the OTel logs pipeline. The record carries the trace and span IDs of
+// the span context in ctx, if any, so backends can correlate it with the trace it was written in
+func (t *Telemetry) EmitLog(ctx context.Context, at time.Time, level slog.Level, body string, attrs ...otellog.KeyValue) {
+	if t.logger == nil {
+		return
+	}
+
+	var record otellog.Record
+	record.SetTimestamp(at)
+	record.SetObservedTimestamp(time.Now())
+	record.SetSeverity(severityOf(level))
+	record.SetSeverityText(level.String())
+	record.SetBody(otellog.StringValue(body))
+	record.AddAttributes(attrs...)
+
+	t.logger.Emit(ctx, record)
+}
+
+// Maps a slog level onto the OTel severity number of the same name
+func severityOf(level slog.Level) otellog.Severity {
+	switch {
+	case level >= slog.LevelError:
+		return otellog.SeverityError
+	case level >= slog.LevelWarn:
+		return otellog.SeverityWarn
+	case level >= slog.LevelInfo:
+		return otellog.SeverityInfo
+	default:
+		return otellog.SeverityDebug
+	}
+}
@@ -0,0 +1,82 @@
+package observability
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Resource usage of the agent process or VM running a single workload
+type MachineStatsSample struct {
+	WorkloadID      string
+	Namespace       string
+	WorkloadName    string
+	CPUTimeMillis   int64
+	MemoryRSSBytes  int64
+	BlockReadBytes  int64
+	BlockWriteBytes int64
+	NetRxBytes      int64
+	NetTxBytes      int64
+}
+
+// Registers a callback that samples the resource usage of every running workload each time
+// metrics are collected
+func (t *Telemetry) ObserveMachineStats(sample func() []MachineStatsSample) error {
+	var e, err error
+
+	cpuTime, e := t.meter.Int64ObservableCounter("nex-workload-cpu-time",
+		metric.WithDescription("CPU time consumed by the machine running a workload"),
+		metric.WithUnit("ms"),
+	)
+	err = errors.Join(err, e)
+
+	memory, e := t.meter.Int64ObservableGauge("nex-workload-memory-rss-bytes",
+		metric.WithDescription("Resident memory of the machine running a workload"),
+	)
+	err = errors.Join(err, e)
+
+	blockRead, e := t.meter.Int64ObservableCounter("nex-workload-block-read-bytes",
+		metric.WithDescription("Total bytes read from block devices by a workload's machine"),
+	)
+	err = errors.Join(err, e)
+
+	blockWrite, e := t.meter.Int64ObservableCounter("nex-workload-block-write-bytes",
+		metric.WithDescription("Total bytes written to block devices by a workload's machine"),
+	)
+	err = errors.Join(err, e)
+
+	netRx, e := t.meter.Int64ObservableCounter("nex-workload-net-rx-bytes",
+		metric.WithDescription("Total bytes received over the network by a workload's machine"),
+	)
+	err = errors.Join(err, e)
+
+	netTx, e := t.meter.Int64ObservableCounter("nex-workload-net-tx-bytes",
+		metric.WithDescription("Total bytes sent over the network by a workload's machine"),
+	)
+	err = errors.Join(err, e)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = t.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, s := range sample() {
+			attrs := metric.WithAttributes(
+				attribute.String("workload_id", s.WorkloadID),
+				attribute.String("namespace", s.Namespace),
+				attribute.String("workload_name", s.WorkloadName),
+			)
+			o.ObserveInt64(cpuTime, s.CPUTimeMillis, attrs)
+			o.ObserveInt64(memory, s.MemoryRSSBytes, attrs)
+			o.ObserveInt64(blockRead, s.BlockReadBytes, attrs)
+			o.ObserveInt64(blockWrite, s.BlockWriteBytes, attrs)
+			o.ObserveInt64(netRx, s.NetRxBytes, attrs)
+			o.ObserveInt64(netTx, s.NetTxBytes, attrs)
+		}
+		return nil
+	}, cpuTime, memory, blockRead, blockWrite, netRx, netTx)
+
+	return err
+}
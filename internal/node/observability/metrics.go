@@ -6,7 +6,6 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
-	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -77,6 +76,69 @@ func (t *Telemetry) initMetrics() error {
 	if e != nil {
 		err = errors.Join(err, e)
 	}
+	t.FunctionShedTriggers, e = t.meter.
+		Int64Counter("nex-function-shed-trigger",
+			metric.WithDescription("Total number of function triggers dropped because the workload's trigger queue was full"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+	t.FunctionTriggerQueueDepth, e = t.meter.
+		Int64UpDownCounter("nex-function-trigger-queue-depth",
+			metric.WithDescription("Number of function triggers waiting for a worker"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+	t.FunctionTriggerBreakerOpened, e = t.meter.
+		Int64Counter("nex-function-trigger-breaker-open",
+			metric.WithDescription("Total number of times a trigger subject's circuit breaker opened"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+	t.FunctionTriggerBreakerRejected, e = t.meter.
+		Int64Counter("nex-function-trigger-breaker-rejected",
+			metric.WithDescription("Total number of function triggers rejected by an open circuit breaker"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+	t.FunctionResultCacheHits, e = t.meter.
+		Int64Counter("nex-function-result-cache-hit",
+			metric.WithDescription("Total number of function triggers answered from the result cache without running the function"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+	t.FunctionResultCacheMisses, e = t.meter.
+		Int64Counter("nex-function-result-cache-miss",
+			metric.WithDescription("Total number of cacheable function triggers that missed the result cache"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+	t.BalloonReclaimedMib, e = t.meter.
+		Int64UpDownCounter("nex-balloon-reclaimed-mib",
+			metric.WithDescription("Memory in MiB currently reclaimed from idle workloads by their balloon devices"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+	t.BalloonInflations, e = t.meter.
+		Int64Counter("nex-balloon-inflation",
+			metric.WithDescription("Total number of times an idle workload's balloon was inflated"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+	t.BalloonDeflations, e = t.meter.
+		Int64Counter("nex-balloon-deflation",
+			metric.WithDescription("Total number of times a workload's balloon was deflated on trigger activity"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
 	t.FunctionRunTimeNano, e = t.meter.
 		Int64Counter("nex-function-runtime-nanosec",
 			metric.WithDescription("Total run time in nanoseconds for function"),
@@ -85,6 +147,44 @@ func (t *Telemetry) initMetrics() error {
 		err = errors.Join(err, e)
 	}
 
+	t.FunctionCompileTimeNano, e = t.meter.
+		Int64Counter("nex-function-compile-time-nanosec",
+			metric.WithDescription("Total time in nanoseconds spent compiling functions ahead of execution"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+	t.FunctionCompileCacheHits, e = t.meter.
+		Int64Counter("nex-function-compile-cache-hit",
+			metric.WithDescription("Total number of function compilations served from the compiled module cache"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+	t.FunctionCompileCacheMisses, e = t.meter.
+		Int64Counter("nex-function-compile-cache-miss",
+			metric.WithDescription("Total number of function compilations that missed the compiled module cache"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+
+	t.AgentsReaped, e = t.meter.
+		Int64Counter("nex-agents-reaped",
+			metric.WithDescription("Total number of pending agents terminated for failing their handshake or becoming unresponsive"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+
+	t.HostServicesThrottled, e = t.meter.
+		Int64Counter("nex-host-services-throttled",
+			metric.WithDescription("Total number of host service calls rejected for exceeding a workload's budget"),
+		)
+	if e != nil {
+		err = errors.Join(err, e)
+	}
+
 	return err
 }
 
@@ -96,12 +196,16 @@ func (t *Telemetry) initMeterProvider() error {
 			return errors.New("failed to initialize meter provider; no version resolved in context")
 		}
 
+		attrs := []attribute.KeyValue{
+			semconv.ServiceName(t.serviceName),
+			semconv.ServiceVersion(*t.version),
+			attribute.String("node_pub_key", t.nodePubKey),
+		}
+
 		resource, err := resource.Merge(resource.Default(),
 			resource.NewWithAttributes(
 				semconv.SchemaURL,
-				semconv.ServiceName(t.serviceName),
-				semconv.ServiceVersion(*t.version),
-				attribute.String("node_pub_key", t.nodePubKey),
+				append(attrs, t.resourceAttributes...)...,
 			))
 
 		if err != nil {
@@ -161,7 +265,7 @@ func (t *Telemetry) serveMetrics() (metricsdk.Reader, error) {
 
 		return metricsdk.NewPeriodicReader(
 			reader,
-			metricsdk.WithInterval(3*time.Second), // FIXME-- make configurable!
+			metricsdk.WithInterval(t.metricsExportInterval),
 		), nil
 	}
 }
@@ -2,19 +2,30 @@ package observability
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/synadia-io/nex/internal/models"
 
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/metric/noop"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	metricsdk "go.opentelemetry.io/otel/sdk/metric"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
 	tnoop "go.opentelemetry.io/otel/trace/noop"
 )
 
-const defaultServiceName = "nex-node"
+const (
+	defaultServiceName          = "nex-node"
+	defaultMetricExportInterval = 3 * time.Second
+	shutdownTimeout             = 5 * time.Second
+)
 
 type Telemetry struct {
 	ctx     context.Context
@@ -22,20 +33,33 @@ type Telemetry struct {
 	version *string
 
 	otelExporterUrl string
-
-	metricsEnabled  bool
-	metricsExporter string
-	metricsPort     int
-	meter           metric.Meter
-	meterProvider   metric.MeterProvider
-	traceProvider   trace.TracerProvider
-
-	tracesEnabled  bool
-	tracesExporter string
-	traceExporter  tracesdk.SpanExporter
-
-	serviceName string
-	nodePubKey  string
+	exporterTLS     bool
+	exporterHeaders map[string]string
+
+	metricsEnabled        bool
+	metricsExporter       string
+	metricsPort           int
+	metricsExportInterval time.Duration
+	meter                 metric.Meter
+	meterProvider         metric.MeterProvider
+	traceProvider         trace.TracerProvider
+
+	tracesEnabled    bool
+	tracesExporter   string
+	traceExporter    tracesdk.SpanExporter
+	traceSampleRatio *float64
+
+	logsEnabled    bool
+	logsExporter   string
+	loggerProvider *sdklog.LoggerProvider
+	logger         otellog.Logger
+
+	serviceName        string
+	nodePubKey         string
+	resourceAttributes []attribute.KeyValue
+
+	shutdownOnce sync.Once
+	shutdownErr  error
 
 	AllocatedMemoryCounter metric.Int64UpDownCounter
 	AllocatedVCPUCounter   metric.Int64UpDownCounter
@@ -48,24 +72,61 @@ type Telemetry struct {
 	FunctionFailedTriggers metric.Int64Counter
 	FunctionRunTimeNano    metric.Int64Counter
 
+	FunctionShedTriggers      metric.Int64Counter
+	FunctionTriggerQueueDepth metric.Int64UpDownCounter
+
+	FunctionTriggerBreakerOpened   metric.Int64Counter
+	FunctionTriggerBreakerRejected metric.Int64Counter
+
+	FunctionResultCacheHits   metric.Int64Counter
+	FunctionResultCacheMisses metric.Int64Counter
+
+	BalloonReclaimedMib metric.Int64UpDownCounter
+	BalloonInflations   metric.Int64Counter
+	BalloonDeflations   metric.Int64Counter
+
+	FunctionCompileTimeNano    metric.Int64Counter
+	FunctionCompileCacheHits   metric.Int64Counter
+	FunctionCompileCacheMisses metric.Int64Counter
+
+	HostServicesThrottled metric.Int64Counter
+
+	AgentsReaped metric.Int64Counter
+
 	Tracer trace.Tracer
 }
 
 func NewTelemetry(ctx context.Context, log *slog.Logger, config *models.NodeConfiguration, nodePubKey string) (*Telemetry, error) {
 	t := &Telemetry{
-		ctx:             ctx,
-		log:             log,
-		meter:           nil,
-		otelExporterUrl: config.OtlpExporterUrl,
-		metricsEnabled:  config.OtelMetrics,
-		metricsExporter: config.OtelMetricsExporter,
-		metricsPort:     config.OtelMetricsPort,
-		tracesEnabled:   config.OtelTraces,
-		tracesExporter:  config.OtelTracesExporter,
-		serviceName:     defaultServiceName,
-		nodePubKey:      nodePubKey,
-		meterProvider:   noop.NewMeterProvider(),
-		traceProvider:   tnoop.NewTracerProvider(),
+		ctx:                   ctx,
+		log:                   log,
+		meter:                 nil,
+		otelExporterUrl:       config.OtlpExporterUrl,
+		metricsEnabled:        config.OtelMetrics,
+		metricsExporter:       config.OtelMetricsExporter,
+		metricsPort:           config.OtelMetricsPort,
+		metricsExportInterval: defaultMetricExportInterval,
+		tracesEnabled:         config.OtelTraces,
+		tracesExporter:        config.OtelTracesExporter,
+		logsEnabled:           config.OtelLogs,
+		logsExporter:          config.OtelLogsExporter,
+		serviceName:           defaultServiceName,
+		nodePubKey:            nodePubKey,
+		meterProvider:         noop.NewMeterProvider(),
+		traceProvider:         tnoop.NewTracerProvider(),
+	}
+
+	if config.Telemetry != nil {
+		if config.Telemetry.Endpoint != "" {
+			t.otelExporterUrl = config.Telemetry.Endpoint
+		}
+		if config.Telemetry.MetricExportIntervalMillis > 0 {
+			t.metricsExportInterval = time.Duration(config.Telemetry.MetricExportIntervalMillis) * time.Millisecond
+		}
+		t.exporterTLS = config.Telemetry.TLS
+		t.exporterHeaders = config.Telemetry.Headers
+		t.traceSampleRatio = config.Telemetry.TraceSampleRatio
+		t.resourceAttributes = resourceAttributes(config.Telemetry.ResourceAttributes)
 	}
 
 	if buildData, ok := t.ctx.Value("build_data").(map[string]string); ok {
@@ -84,12 +145,77 @@ func NewTelemetry(ctx context.Context, log *slog.Logger, config *models.NodeConf
 		return nil, err
 	}
 
+	err = t.initLogs()
+	if err != nil {
+		return nil, err
+	}
+
 	return t, nil
 }
 
-func (t *Telemetry) Shutdown() error {
-	if _, ok := t.meterProvider.(*metricsdk.MeterProvider); ok {
-		return t.meterProvider.(*metricsdk.MeterProvider).Shutdown(t.ctx)
+// Converts the configured resource attributes, ordered by key so every provider's resource is
+// the same
+func resourceAttributes(attrs map[string]string) []attribute.KeyValue {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
 	}
-	return nil
+	sort.Strings(keys)
+
+	kvs := make([]attribute.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		kvs = append(kvs, attribute.String(key, attrs[key]))
+	}
+
+	return kvs
+}
+
+// Exports any buffered metrics, spans and log records without shutting down the underlying providers
+func (t *Telemetry) Flush(ctx context.Context) error {
+	var err error
+
+	if mp, ok := t.meterProvider.(*metricsdk.MeterProvider); ok {
+		err = errors.Join(err, mp.ForceFlush(ctx))
+	}
+
+	if tp, ok := t.traceProvider.(*tracesdk.TracerProvider); ok {
+		err = errors.Join(err, tp.ForceFlush(ctx))
+	}
+
+	if t.loggerProvider != nil {
+		err = errors.Join(err, t.loggerProvider.ForceFlush(ctx))
+	}
+
+	return err
+}
+
+// Flushes and shuts down the meter, tracer and logger providers. This is safe to call more than once and
+// from multiple goroutines; only the first call does any work. Note that the node context is typically
+// already cancelled by the time this is called, so a fresh timeout is used for the final export
+func (t *Telemetry) Shutdown() error {
+	t.shutdownOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		err := t.Flush(ctx)
+
+		if mp, ok := t.meterProvider.(*metricsdk.MeterProvider); ok {
+			err = errors.Join(err, mp.Shutdown(ctx))
+		}
+
+		if tp, ok := t.traceProvider.(*tracesdk.TracerProvider); ok {
+			err = errors.Join(err, tp.Shutdown(ctx))
+		}
+
+		if t.loggerProvider != nil {
+			err = errors.Join(err, t.loggerProvider.Shutdown(ctx))
+		}
+
+		if err != nil {
+			t.log.Warn("Failed to cleanly shut down telemetry", slog.Any("err", err))
+		}
+		t.shutdownErr = err
+	})
+
+	return t.shutdownErr
 }
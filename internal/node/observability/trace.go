@@ -2,6 +2,7 @@ package observability
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"log/slog"
 	"os"
@@ -16,6 +17,7 @@ import (
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
@@ -30,18 +32,26 @@ func (t *Telemetry) initTrace() error {
 		switch t.tracesExporter {
 		case "grpc":
 			t.log.Debug("GRPC exporter", slog.String("url", t.otelExporterUrl))
-			conn, err := grpc.DialContext(t.ctx, t.otelExporterUrl, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+			creds := insecure.NewCredentials()
+			if t.exporterTLS {
+				creds = credentials.NewTLS(&tls.Config{})
+			}
+			conn, err := grpc.DialContext(t.ctx, t.otelExporterUrl, grpc.WithTransportCredentials(creds), grpc.WithBlock())
 			if err != nil {
 				return err
 			}
-			t.traceExporter, err = otlptracegrpc.New(t.ctx, otlptracegrpc.WithGRPCConn(conn), otlptracegrpc.WithInsecure(), otlptracegrpc.WithEndpoint(t.otelExporterUrl))
+			t.traceExporter, err = otlptracegrpc.New(t.ctx, otlptracegrpc.WithGRPCConn(conn), otlptracegrpc.WithEndpoint(t.otelExporterUrl), otlptracegrpc.WithHeaders(t.exporterHeaders))
 			if err != nil {
 				return err
 			}
 			t.log.Info("Initialized OTLP exporter", slog.String("url", t.otelExporterUrl))
 		case "http":
 			t.log.Debug("HTTP exporter", slog.String("url", t.otelExporterUrl))
-			t.traceExporter, err = otlptracehttp.New(t.ctx, otlptracehttp.WithEndpoint(t.otelExporterUrl), otlptracehttp.WithInsecure())
+			opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(t.otelExporterUrl), otlptracehttp.WithHeaders(t.exporterHeaders)}
+			if !t.exporterTLS {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+			t.traceExporter, err = otlptracehttp.New(t.ctx, opts...)
 			if err != nil {
 				return err
 			}
@@ -61,7 +71,7 @@ func (t *Telemetry) initTrace() error {
 
 	batchSpanProcessor := tracesdk.NewBatchSpanProcessor(t.traceExporter)
 	tracerProvider := tracesdk.NewTracerProvider(
-		tracesdk.WithSampler(tracesdk.AlwaysSample()),
+		tracesdk.WithSampler(t.sampler()),
 		tracesdk.WithResource(res),
 		tracesdk.WithSpanProcessor(batchSpanProcessor),
 	)
@@ -71,6 +81,7 @@ func (t *Telemetry) initTrace() error {
 	))
 
 	otel.SetTracerProvider(tracerProvider)
+	t.traceProvider = tracerProvider
 	t.Tracer = otel.Tracer(t.serviceName)
 	if t.Tracer == nil {
 		return errors.New("failed to initialize telemetry instance: nil tracer")
@@ -79,13 +90,25 @@ func (t *Telemetry) initTrace() error {
 	return nil
 }
 
+// Samples every trace unless a sample ratio is configured, in which case root spans are sampled
+// at that ratio and child spans follow their parent
+func (t *Telemetry) sampler() tracesdk.Sampler {
+	if t.traceSampleRatio == nil {
+		return tracesdk.AlwaysSample()
+	}
+
+	return tracesdk.ParentBased(tracesdk.TraceIDRatioBased(*t.traceSampleRatio))
+}
+
 func (t *Telemetry) newResource(ctx context.Context) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(t.serviceName),
+		semconv.ServiceVersion(*t.version),
+		attribute.String("node_pub_key", t.nodePubKey),
+		attribute.String("application", t.serviceName),
+	}
+
 	return resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(t.serviceName),
-			semconv.ServiceVersion(*t.version),
-			attribute.String("node_pub_key", t.nodePubKey),
-			attribute.String("application", t.serviceName),
-		),
+		resource.WithAttributes(append(attrs, t.resourceAttributes...)...),
 	)
 }
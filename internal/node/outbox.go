@@ -0,0 +1,308 @@
+package nexnode
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+// Size the outbox file may grow to when the configuration does not set one
+const defaultOutboxMaxBytes = 64 * 1024 * 1024
+
+// Publishes the events and logs emitted by the node and its workloads. Satisfied by the node's
+// NATS connection and by the outbox spooling to disk in front of it
+type eventPublisher interface {
+	Publish(subject string, data []byte) error
+	Flush() error
+}
+
+// A message held in the outbox until the node reconnects
+type outboxRecord struct {
+	Subject string `json:"subject"`
+	Data    []byte `json:"data"`
+}
+
+// Store-and-forward queue in front of the node's NATS connection. While connected, messages are
+// published straight through; while disconnected, or while earlier messages are still waiting to
+// be replayed, they are appended to a file, so that nothing emitted during an outage is lost to
+// the NATS client's bounded in-memory buffer or to a restart of the node. Messages are replayed
+// in the order they were emitted once the connection is re-established
+type outbox struct {
+	mu       sync.Mutex
+	nc       *nats.Conn
+	log      *slog.Logger
+	path     string
+	maxBytes int64
+
+	f       *os.File
+	size    int64
+	dropped int
+}
+
+// Publisher of the events and logs emitted by the workload manager and its workloads
+func (w *WorkloadManager) events() eventPublisher {
+	if w.outbox != nil {
+		return w.outbox
+	}
+	return w.nc
+}
+
+// Publisher of the events emitted by the node
+func (n *Node) events() eventPublisher {
+	if n.manager != nil {
+		return n.manager.events()
+	}
+	return n.nc
+}
+
+// Returns the path of the file holding the outbox
+func outboxFilepath(config *models.NodeConfiguration) string {
+	dir := config.Outbox.Directory
+	if dir == "" {
+		if config.DefaultResourceDir != "" {
+			dir = filepath.Join(config.DefaultResourceDir, "outbox")
+		} else {
+			dir = filepath.Join(os.TempDir(), "nex-outbox")
+		}
+	}
+
+	return filepath.Join(dir, "outbox.jsonl")
+}
+
+// Opens the outbox configured for the node, picking up any messages left in it by a previous
+// run, and hooks it to the connection's disconnect and reconnect notifications. Returns nil
+// when no outbox is configured
+func newOutbox(nc *nats.Conn, config *models.NodeConfiguration, log *slog.Logger) (*outbox, error) {
+	if config.Outbox == nil || nc == nil {
+		return nil, nil
+	}
+
+	path := outboxFilepath(config)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	o := &outbox{
+		nc:       nc,
+		log:      log,
+		path:     path,
+		maxBytes: config.Outbox.MaxBytes,
+		f:        f,
+		size:     info.Size(),
+	}
+	if o.maxBytes == 0 {
+		o.maxBytes = defaultOutboxMaxBytes
+	}
+
+	nc.SetDisconnectErrHandler(func(_ *nats.Conn, err error) {
+		o.log.Warn("Disconnected from NATS; buffering events and logs in the outbox", slog.Any("err", err), slog.String("path", o.path))
+	})
+	nc.SetReconnectHandler(func(_ *nats.Conn) {
+		go o.replay()
+	})
+
+	if o.size > 0 && nc.IsConnected() {
+		go o.replay()
+	}
+
+	return o, nil
+}
+
+// Publishes the message, or appends it to the outbox while the node is disconnected or older
+// messages are waiting to be replayed. Messages that do not fit are dropped and counted
+func (o *outbox) Publish(subject string, data []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.size == 0 && o.nc.IsConnected() {
+		return o.nc.Publish(subject, data)
+	}
+
+	return o.spool(subject, data)
+}
+
+// Flushes the connection unless messages are being held in the outbox, which are flushed once
+// they are replayed
+func (o *outbox) Flush() error {
+	o.mu.Lock()
+	spooling := o.size > 0
+	o.mu.Unlock()
+
+	if spooling || !o.nc.IsConnected() {
+		return nil
+	}
+
+	return o.nc.Flush()
+}
+
+func (o *outbox) spool(subject string, data []byte) error {
+	raw, err := json.Marshal(&outboxRecord{Subject: subject, Data: data})
+	if err != nil {
+		return err
+	}
+	raw = append(raw, '\n')
+
+	if o.size+int64(len(raw)) > o.maxBytes {
+		if o.dropped == 0 {
+			o.log.Warn("Outbox is full; dropping events and logs until the node reconnects",
+				slog.String("path", o.path),
+				slog.Int64("max_bytes", o.maxBytes),
+			)
+		}
+		o.dropped++
+		return nil
+	}
+
+	n, err := o.f.Write(raw)
+	o.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to append to outbox %s: %s", o.path, err)
+	}
+
+	return nil
+}
+
+// Publishes the messages held in the outbox, oldest first. Messages emitted meanwhile are
+// appended behind them, so the order is kept. If the connection is lost again part way
+// through, the messages not yet published are kept for the next reconnect
+func (o *outbox) replay() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.size == 0 {
+		return
+	}
+
+	records, err := o.read()
+	if err != nil {
+		o.log.Error("Failed to read outbox; its messages will not be replayed", slog.Any("err", err), slog.String("path", o.path))
+		return
+	}
+
+	published := 0
+	for _, record := range records {
+		if !o.nc.IsConnected() {
+			break
+		}
+
+		err = o.nc.Publish(record.Subject, record.Data)
+		if err != nil {
+			// the connection is up, so the message itself was refused and retrying cannot help
+			o.log.Warn("Discarding buffered message", slog.String("subject", record.Subject), slog.Any("err", err))
+		}
+		published++
+	}
+
+	err = o.rewrite(records[published:])
+	if err != nil {
+		o.log.Error("Failed to rewrite outbox", slog.Any("err", err), slog.String("path", o.path))
+	}
+
+	if published > 0 {
+		_ = o.nc.Flush()
+	}
+
+	o.log.Info("Replayed events and logs buffered while disconnected",
+		slog.Int("published", published),
+		slog.Int("remaining", len(records)-published),
+		slog.Int("dropped", o.dropped),
+	)
+	if len(records) == published {
+		o.dropped = 0
+	}
+}
+
+// Reads the records held in the outbox file. A truncated final record, as left by a crash
+// mid-write, is ignored
+func (o *outbox) read() ([]outboxRecord, error) {
+	f, err := os.Open(o.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := make([]outboxRecord, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), int(o.maxBytes)+1)
+	for scanner.Scan() {
+		var record outboxRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// Replaces the contents of the outbox file with the given records
+func (o *outbox) rewrite(records []outboxRecord) error {
+	tmp := o.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	w := bufio.NewWriter(f)
+	for _, record := range records {
+		raw, err := json.Marshal(&record)
+		if err != nil {
+			continue
+		}
+		n, _ := w.Write(append(raw, '\n'))
+		size += int64(n)
+	}
+
+	err = errors.Join(w.Flush(), f.Sync(), f.Close())
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	err = os.Rename(tmp, o.path)
+	if err != nil {
+		return err
+	}
+
+	_ = o.f.Close()
+	o.f, err = os.OpenFile(o.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	o.size = size
+
+	return nil
+}
+
+// Closes the outbox file; messages still held in it are replayed when the node next starts
+func (o *outbox) close() {
+	if o == nil {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	_ = o.f.Close()
+}
@@ -0,0 +1,78 @@
+package nexnode
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestOutboxReplaysInOrderOnReconnect(t *testing.T) {
+	nc := startTestNats(t)
+
+	disconnected, err := nats.Connect(nc.ConnectedUrl())
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err)
+	}
+	disconnected.Close()
+
+	config := &models.NodeConfiguration{Outbox: &models.OutboxConfig{Directory: t.TempDir(), MaxBytes: 100}}
+	o, err := newOutbox(disconnected, config, slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open outbox: %s", err)
+	}
+	defer o.close()
+
+	for i := 1; i <= 3; i++ {
+		err = o.Publish(fmt.Sprintf("events.%d", i), []byte(fmt.Sprint(i)))
+		if err != nil {
+			t.Fatalf("Expected the event to be spooled: %s", err)
+		}
+	}
+	if o.size == 0 || o.dropped != 1 {
+		t.Fatalf("Expected two events to be spooled and the third dropped, got %d bytes and %d dropped", o.size, o.dropped)
+	}
+
+	// a node restarting during the outage picks up the spooled events
+	reopened, err := newOutbox(disconnected, config, slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to reopen outbox: %s", err)
+	}
+	defer reopened.close()
+	if reopened.size != o.size {
+		t.Fatalf("Expected the reopened outbox to hold %d bytes, got %d", o.size, reopened.size)
+	}
+
+	sub, err := nc.SubscribeSync("events.>")
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %s", err)
+	}
+
+	o.nc = nc
+	o.replay()
+
+	for _, expected := range []string{"events.1", "events.2"} {
+		msg, err := sub.NextMsg(time.Second)
+		if err != nil {
+			t.Fatalf("Expected %s to be replayed: %s", expected, err)
+		}
+		if msg.Subject != expected {
+			t.Fatalf("Expected %s to be replayed next, got %s", expected, msg.Subject)
+		}
+	}
+	if o.size != 0 || o.dropped != 0 {
+		t.Fatalf("Expected the outbox to be empty once replayed, got %d bytes", o.size)
+	}
+
+	err = o.Publish("events.4", []byte("4"))
+	if err != nil {
+		t.Fatalf("Failed to publish: %s", err)
+	}
+	msg, err := sub.NextMsg(time.Second)
+	if err != nil || msg.Subject != "events.4" {
+		t.Fatalf("Expected events to be published straight through once connected, got %v, %v", msg, err)
+	}
+}
@@ -0,0 +1,252 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/events"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const defaultAccessPolicyKey = "policy"
+
+// Decides which issuers may perform which operations in each namespace. Rules come from the
+// node configuration or, when a bucket is configured, from a watched key-value entry
+type accessPolicy struct {
+	mu    sync.RWMutex
+	rules []models.AccessRule
+
+	watcher nats.KeyWatcher
+	log     *slog.Logger
+}
+
+func newAccessPolicy(config *models.AccessPolicyConfig, log *slog.Logger) *accessPolicy {
+	return &accessPolicy{
+		rules: config.Rules,
+		log:   log,
+	}
+}
+
+// Loads the rules from the configured bucket and keeps them up to date. Until the entry exists,
+// every request is denied
+func (p *accessPolicy) watch(nc *nats.Conn, config *models.AccessPolicyConfig) error {
+	js, err := nc.JetStream()
+	if err != nil {
+		return err
+	}
+
+	kv, err := js.KeyValue(config.Bucket)
+	if err != nil {
+		return fmt.Errorf("failed to bind to access policy bucket %s: %s", config.Bucket, err)
+	}
+
+	key := config.Key
+	if key == "" {
+		key = defaultAccessPolicyKey
+	}
+
+	p.setRules(nil)
+	p.watcher, err = kv.Watch(key)
+	if err != nil {
+		return fmt.Errorf("failed to watch access policy key %s: %s", key, err)
+	}
+
+	go func() {
+		for entry := range p.watcher.Updates() {
+			if entry == nil {
+				// marks the end of the initial values
+				continue
+			}
+
+			if entry.Operation() != nats.KeyValuePut {
+				p.log.Warn("Access policy entry removed; denying all requests", slog.String("key", key))
+				p.setRules(nil)
+				continue
+			}
+
+			var rules []models.AccessRule
+			err := json.Unmarshal(entry.Value(), &rules)
+			if err == nil {
+				for _, rule := range rules {
+					if err = rule.Validate(); err != nil {
+						break
+					}
+				}
+			}
+			if err != nil {
+				p.log.Error("Ignoring invalid access policy update", slog.String("key", key), slog.Any("err", err))
+				continue
+			}
+
+			p.log.Info("Access policy updated", slog.String("key", key), slog.Int("rules", len(rules)))
+			p.setRules(rules)
+		}
+	}()
+
+	return nil
+}
+
+func (p *accessPolicy) stop() {
+	if p.watcher != nil {
+		_ = p.watcher.Stop()
+	}
+}
+
+func (p *accessPolicy) setRules(rules []models.AccessRule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rules = rules
+}
+
+// Reports whether any rule grants the issuer the operation in the namespace
+func (p *accessPolicy) allows(issuer string, namespace string, op controlapi.Operation) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, rule := range p.rules {
+		if (rule.Issuer == "*" || rule.Issuer == issuer) &&
+			(rule.Namespace == "*" || rule.Namespace == namespace) &&
+			slices.Contains(rule.Operations, op) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks the node's access policy for a request, recording the decision as an audit event.
+// Without a policy every request is authorized. Either way, the request's audit record is
+// attributed to the issuer
+func (api *ApiListener) authorize(m *apiRequest, issuer string, namespace string, op controlapi.Operation) *controlapi.AuthorizationError {
+	m.audit.attribute(issuer, namespace, op)
+
+	if api.policy == nil {
+		return nil
+	}
+
+	var authErr *controlapi.AuthorizationError
+	if issuer == "" {
+		authErr = controlapi.NewAuthorizationError("request does not identify its issuer")
+	} else if !api.policy.allows(issuer, namespace, op) {
+		authErr = controlapi.NewAuthorizationError(fmt.Sprintf("issuer is not allowed to %s in namespace %s", op, namespace))
+	}
+
+	api.publishPolicyDecision(issuer, namespace, op, authErr)
+	return authErr
+}
+
+// Checks the node's access policy for a request that identifies its issuer through an identity
+// token header rather than signed claims. Without a policy, a token that verifies only serves to
+// attribute the request
+func (api *ApiListener) authorizeIdentity(m *apiRequest, namespace string, op controlapi.Operation) *controlapi.AuthorizationError {
+	var issuer string
+	var err error
+	if token := m.Header.Get(controlapi.IdentityHeader); token != "" {
+		issuer, err = controlapi.VerifyIdentityToken(token, namespace)
+	}
+
+	if err != nil && api.policy != nil {
+		authErr := controlapi.NewAuthorizationError(err.Error())
+		if verifyErr, ok := err.(*controlapi.AuthorizationError); ok {
+			authErr = verifyErr
+		}
+
+		m.audit.attribute("", namespace, op)
+		api.publishPolicyDecision("", namespace, op, authErr)
+		return authErr
+	}
+
+	return api.authorize(m, issuer, namespace, op)
+}
+
+// Returns the issuer identified by a request's identity token, or an empty string when the
+// request carries no token that verifies for the namespace
+func requestIssuer(m *apiRequest, namespace string) string {
+	token := m.Header.Get(controlapi.IdentityHeader)
+	if token == "" {
+		return ""
+	}
+
+	issuer, err := controlapi.VerifyIdentityToken(token, namespace)
+	if err != nil {
+		return ""
+	}
+
+	return issuer
+}
+
+// Reports whether the issuer administers the node, i.e. is one of its admin keys or is granted
+// update in the system namespace by the access policy
+func (api *ApiListener) isAdmin(issuer string) bool {
+	if issuer == "" {
+		return false
+	}
+	if slices.Contains(api.node.config.AdminKeys, issuer) {
+		return true
+	}
+
+	return api.policy != nil && api.policy.allows(issuer, systemNamespace, controlapi.OperationUpdate)
+}
+
+// Deleting a namespace can stop its workloads on every node, so only administrators identified by
+// the request's identity token may do it. Nodes without an access policy also let anyone delete a
+// namespace as long as its workloads are orphaned rather than stopped
+func (api *ApiListener) authorizeNamespaceDeletion(m *apiRequest, namespace string, policy controlapi.NamespaceDeletionPolicy) *controlapi.AuthorizationError {
+	issuer := requestIssuer(m, namespace)
+	m.audit.attribute(issuer, namespace, controlapi.OperationStop)
+
+	var authErr *controlapi.AuthorizationError
+	switch {
+	case api.isAdmin(issuer):
+	case api.policy == nil && policy == controlapi.NamespaceDeletionOrphan:
+	case api.policy == nil:
+		authErr = controlapi.NewAuthorizationError("only node administrators may delete a namespace without orphaning its workloads")
+	default:
+		authErr = controlapi.NewAuthorizationError("only node administrators may delete a namespace")
+	}
+
+	if api.policy != nil || authErr != nil {
+		api.publishPolicyDecision(issuer, namespace, controlapi.OperationStop, authErr)
+	}
+	return authErr
+}
+
+func (api *ApiListener) publishPolicyDecision(issuer string, namespace string, op controlapi.Operation, authErr *controlapi.AuthorizationError) {
+	evt := controlapi.PolicyDecisionEvent{
+		NodeId:    api.PublicKey(),
+		Issuer:    issuer,
+		Namespace: namespace,
+		Operation: op,
+		Allowed:   authErr == nil,
+	}
+	if authErr != nil {
+		evt.Reason = authErr.Reason
+		api.log.Warn("Access policy denied request",
+			slog.String("issuer", issuer),
+			slog.String("namespace", namespace),
+			slog.String("operation", string(op)),
+			slog.String("reason", authErr.Reason),
+		)
+	}
+
+	cloudevent := events.PolicyDecision(api.PublicKey(), evt)
+
+	_ = PublishCloudEvent(api.node.events(), systemNamespace, cloudevent, api.log)
+}
+
+// Returns the issuer of a signed claims token, or an empty string if its signature does not verify
+func claimsIssuer(token string) string {
+	claims, err := jwt.DecodeGeneric(token)
+	if err != nil {
+		return ""
+	}
+
+	return claims.Issuer
+}
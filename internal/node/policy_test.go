@@ -0,0 +1,133 @@
+package nexnode
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestAccessPolicyGrantsOperationsPerNamespace(t *testing.T) {
+	policy := newAccessPolicy(&models.AccessPolicyConfig{
+		Rules: []models.AccessRule{
+			{Issuer: "ALICE", Namespace: "team-a", Operations: []controlapi.Operation{controlapi.OperationDeploy, controlapi.OperationStop}},
+			{Issuer: "*", Namespace: "*", Operations: []controlapi.Operation{controlapi.OperationInfo}},
+		},
+	}, nil)
+
+	if !policy.allows("ALICE", "team-a", controlapi.OperationDeploy) {
+		t.Fatal("Expected issuer to be allowed to deploy in its namespace")
+	}
+	if policy.allows("ALICE", "team-b", controlapi.OperationDeploy) {
+		t.Fatal("Expected issuer not to be allowed to deploy in another namespace")
+	}
+	if policy.allows("BOB", "team-a", controlapi.OperationStop) {
+		t.Fatal("Expected an issuer without a rule not to be allowed to stop")
+	}
+	if !policy.allows("BOB", "team-b", controlapi.OperationInfo) {
+		t.Fatal("Expected wildcard rule to allow info in any namespace")
+	}
+	if policy.allows("ALICE", "team-a", controlapi.OperationLogs) {
+		t.Fatal("Expected operations not granted by any rule to be denied")
+	}
+}
+
+func TestRequestIssuerAndAdministrators(t *testing.T) {
+	issuer, _ := nkeys.CreateAccount()
+	issuerKey, _ := issuer.PublicKey()
+	token, _ := controlapi.NewIdentityToken("team-a", issuer)
+
+	msg := nats.NewMsg("$NEX.CANCELDEPLOY.team-a.NODE")
+	msg.Header.Set(controlapi.IdentityHeader, token)
+	if got := requestIssuer(&apiRequest{Msg: msg}, "team-a"); got != issuerKey {
+		t.Fatalf("Expected the token's issuer %s, got %q", issuerKey, got)
+	}
+	if got := requestIssuer(&apiRequest{Msg: msg}, "team-b"); got != "" {
+		t.Fatalf("Expected a token for another namespace not to identify its issuer, got %q", got)
+	}
+	if got := requestIssuer(&apiRequest{Msg: nats.NewMsg("$NEX.CANCELDEPLOY.team-a.NODE")}, "team-a"); got != "" {
+		t.Fatalf("Expected a request without a token not to identify an issuer, got %q", got)
+	}
+
+	api := &ApiListener{node: &Node{config: &models.NodeConfiguration{}}}
+	if api.isAdmin(issuerKey) {
+		t.Fatal("Expected nodes without an access policy or admin keys to have no administrators")
+	}
+
+	api.node.config.AdminKeys = []string{issuerKey}
+	if !api.isAdmin(issuerKey) || api.isAdmin("OTHER") {
+		t.Fatal("Expected the node's admin keys to be administrators")
+	}
+	api.node.config.AdminKeys = nil
+
+	api.policy = newAccessPolicy(&models.AccessPolicyConfig{
+		Rules: []models.AccessRule{
+			{Issuer: issuerKey, Namespace: systemNamespace, Operations: []controlapi.Operation{controlapi.OperationUpdate}},
+		},
+	}, nil)
+	if !api.isAdmin(issuerKey) || api.isAdmin("OTHER") {
+		t.Fatal("Expected only issuers granted update in the system namespace to be administrators")
+	}
+}
+
+func TestOnlyAdministratorsDeleteNamespacesStoppingWorkloads(t *testing.T) {
+	admin, _ := nkeys.CreateOperator()
+	adminKey, _ := admin.PublicKey()
+	member, _ := nkeys.CreateAccount()
+	memberKey, _ := member.PublicKey()
+
+	request := func(issuer nkeys.KeyPair) *apiRequest {
+		msg := nats.NewMsg("$NEX.NAMESPACE.team-a")
+		if issuer != nil {
+			token, _ := controlapi.NewIdentityToken("team-a", issuer)
+			msg.Header.Set(controlapi.IdentityHeader, token)
+		}
+		return &apiRequest{Msg: msg}
+	}
+
+	api := &ApiListener{node: &Node{config: &models.NodeConfiguration{AdminKeys: []string{adminKey}}}, log: slog.Default()}
+
+	for _, tc := range []struct {
+		name    string
+		policy  *models.AccessPolicyConfig
+		issuer  nkeys.KeyPair
+		orphan  bool
+		allowed bool
+	}{
+		{name: "anonymous stop without a policy", allowed: false},
+		{name: "anonymous orphan without a policy", orphan: true, allowed: true},
+		{name: "admin key stop without a policy", issuer: admin, allowed: true},
+		{name: "member stop under a policy", policy: &models.AccessPolicyConfig{
+			Rules: []models.AccessRule{{Issuer: memberKey, Namespace: "team-a", Operations: []controlapi.Operation{controlapi.OperationStop}}},
+		}, issuer: member, allowed: false},
+		{name: "member orphan under a policy", policy: &models.AccessPolicyConfig{
+			Rules: []models.AccessRule{{Issuer: memberKey, Namespace: "team-a", Operations: []controlapi.Operation{controlapi.OperationStop}}},
+		}, issuer: member, orphan: true, allowed: false},
+		{name: "policy administrator stop", policy: &models.AccessPolicyConfig{
+			Rules: []models.AccessRule{{Issuer: memberKey, Namespace: systemNamespace, Operations: []controlapi.Operation{controlapi.OperationUpdate}}},
+		}, issuer: member, allowed: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api.policy = nil
+			if tc.policy != nil {
+				api.policy = newAccessPolicy(tc.policy, nil)
+			}
+
+			policy := controlapi.NamespaceDeletionStop
+			if tc.orphan {
+				policy = controlapi.NamespaceDeletionOrphan
+			}
+
+			authErr := api.authorizeNamespaceDeletion(request(tc.issuer), "team-a", policy)
+			if tc.allowed && authErr != nil {
+				t.Fatalf("Expected the deletion to be allowed: %s", authErr)
+			}
+			if !tc.allowed && authErr == nil {
+				t.Fatal("Expected the deletion to be refused")
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package nexnode
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const (
+	// Firecracker release installed by preflight and exercised by the node
+	supportedFirecrackerVersion = "v1.5.0"
+
+	firecrackerVersionTimeout = 5 * time.Second
+	cgroupRoot                = "/sys/fs/cgroup"
+)
+
+type preflightCheckFunc func(config *models.NodeConfiguration, nc *nats.Conn) (controlapi.PreflightStatus, string)
+
+// Checks making up a preflight report, in the order they are reported
+var preflightChecks = []struct {
+	name  string
+	check preflightCheckFunc
+}{
+	{"kvm", checkKVM},
+	{"cni_plugins", checkCNIPlugins},
+	{"kernel", checkKernel},
+	{"rootfs", checkRootFs},
+	{"firecracker", checkFirecracker},
+	{"cgroups", checkCgroups},
+	{"nats", checkNATS},
+}
+
+// Runs every preflight check against the host and the given node configuration. Unlike
+// CheckPrerequisites, this never installs anything; the NATS check fails when nc is nil
+func RunPreflightChecks(config *models.NodeConfiguration, nc *nats.Conn) *controlapi.PreflightReport {
+	report := &controlapi.PreflightReport{
+		Passed:    true,
+		CheckedAt: time.Now().UTC(),
+		Checks:    make([]controlapi.PreflightCheck, 0, len(preflightChecks)),
+	}
+
+	for _, c := range preflightChecks {
+		status, detail := c.check(config, nc)
+		if status == controlapi.PreflightFail {
+			report.Passed = false
+		}
+
+		report.Checks = append(report.Checks, controlapi.PreflightCheck{
+			Name:   c.name,
+			Status: status,
+			Detail: detail,
+		})
+	}
+
+	return report
+}
+
+func checkKVM(config *models.NodeConfiguration, _ *nats.Conn) (controlapi.PreflightStatus, string) {
+	if config.NoSandbox {
+		return controlapi.PreflightSkip, "not required in no sandbox mode"
+	}
+
+	f, err := os.OpenFile("/dev/kvm", os.O_RDWR, 0)
+	if err != nil {
+		return controlapi.PreflightFail, fmt.Sprintf("unable to open /dev/kvm: %s", err)
+	}
+	_ = f.Close()
+
+	return controlapi.PreflightPass, "/dev/kvm is accessible"
+}
+
+func checkCNIPlugins(config *models.NodeConfiguration, _ *nats.Conn) (controlapi.PreflightStatus, string) {
+	if config.NoSandbox {
+		return controlapi.PreflightSkip, "not required in no sandbox mode"
+	}
+
+	missing := make([]string, 0)
+	for _, plugin := range []string{"bridge", "host-local", "ptp", "tc-redirect-tap"} {
+		if findInPaths(config.CNI.BinPath, plugin) == "" {
+			missing = append(missing, plugin)
+		}
+	}
+
+	if len(missing) > 0 {
+		return controlapi.PreflightFail, fmt.Sprintf("missing from %s: %s", strings.Join(config.CNI.BinPath, ":"), strings.Join(missing, ", "))
+	}
+
+	return controlapi.PreflightPass, ""
+}
+
+func checkKernel(config *models.NodeConfiguration, _ *nats.Conn) (controlapi.PreflightStatus, string) {
+	var pinned *models.ArtifactSpec
+	if config.Artifacts != nil {
+		pinned = config.Artifacts.Kernel
+	}
+
+	return checkImageFile(config, config.KernelFilepath, pinned)
+}
+
+func checkRootFs(config *models.NodeConfiguration, _ *nats.Conn) (controlapi.PreflightStatus, string) {
+	var pinned *models.ArtifactSpec
+	if config.Artifacts != nil {
+		pinned = config.Artifacts.RootFs
+	}
+
+	return checkImageFile(config, config.RootFsFilepath, pinned)
+}
+
+// Hashes a kernel or rootfs image, verifying it against its pinned artifact if there is one
+func checkImageFile(config *models.NodeConfiguration, path string, pinned *models.ArtifactSpec) (controlapi.PreflightStatus, string) {
+	if config.NoSandbox {
+		return controlapi.PreflightSkip, "not required in no sandbox mode"
+	}
+
+	digest, err := fileSha256(path)
+	if err != nil {
+		return controlapi.PreflightFail, fmt.Sprintf("unable to read %s: %s", path, err)
+	}
+
+	if pinned != nil && !strings.EqualFold(pinned.Sha256, digest) {
+		return controlapi.PreflightFail, fmt.Sprintf("%s has sha256 %s; expected %s", path, digest, pinned.Sha256)
+	}
+
+	return controlapi.PreflightPass, fmt.Sprintf("%s sha256 %s", path, digest)
+}
+
+func checkFirecracker(config *models.NodeConfiguration, _ *nats.Conn) (controlapi.PreflightStatus, string) {
+	if config.NoSandbox {
+		return controlapi.PreflightSkip, "not required in no sandbox mode"
+	}
+
+	binary, err := exec.LookPath("firecracker")
+	if err != nil {
+		binary = findInPaths(config.BinPath, "firecracker")
+		if binary == "" {
+			return controlapi.PreflightFail, "firecracker binary not found"
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), firecrackerVersionTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, binary, "--version").Output()
+	if err != nil {
+		return controlapi.PreflightFail, fmt.Sprintf("failed to query %s version: %s", binary, err)
+	}
+
+	// the first line reads "Firecracker v1.5.0"
+	line, _, _ := strings.Cut(string(out), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return controlapi.PreflightWarn, fmt.Sprintf("unrecognized version output from %s: %q", binary, line)
+	}
+
+	version := fields[1]
+	if version != supportedFirecrackerVersion {
+		return controlapi.PreflightWarn, fmt.Sprintf("%s is %s; nex is tested against %s", binary, version, supportedFirecrackerVersion)
+	}
+
+	return controlapi.PreflightPass, fmt.Sprintf("%s %s", binary, version)
+}
+
+func checkCgroups(config *models.NodeConfiguration, _ *nats.Conn) (controlapi.PreflightStatus, string) {
+	if !strings.EqualFold(runtime.GOOS, "linux") {
+		return controlapi.PreflightSkip, "cgroups are only available on linux"
+	}
+
+	version := ""
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		version = "2"
+	} else if _, err := os.Stat(filepath.Join(cgroupRoot, "cpu")); err == nil {
+		version = "1"
+	}
+
+	// only the jailer places agents in cgroups, so their absence is otherwise not fatal
+	failure := controlapi.PreflightWarn
+	if config.Jailer != nil && !config.NoSandbox {
+		failure = controlapi.PreflightFail
+	}
+
+	if version == "" {
+		return failure, fmt.Sprintf("no cgroup hierarchy mounted at %s", cgroupRoot)
+	}
+
+	if config.Jailer != nil {
+		required := config.Jailer.CgroupVersion
+		if required == "" {
+			required = models.DefaultJailerCgroupVersion
+		}
+		if required != version {
+			return failure, fmt.Sprintf("jailer is configured for cgroup v%s but the host mounts cgroup v%s", required, version)
+		}
+	}
+
+	return controlapi.PreflightPass, fmt.Sprintf("cgroup v%s", version)
+}
+
+func checkNATS(_ *models.NodeConfiguration, nc *nats.Conn) (controlapi.PreflightStatus, string) {
+	if nc == nil {
+		return controlapi.PreflightFail, "no NATS connection"
+	}
+
+	if !nc.IsConnected() {
+		return controlapi.PreflightFail, fmt.Sprintf("NATS connection is %s", nc.Status())
+	}
+
+	rtt, err := nc.RTT()
+	if err != nil {
+		return controlapi.PreflightFail, fmt.Sprintf("NATS server did not respond: %s", err)
+	}
+
+	return controlapi.PreflightPass, fmt.Sprintf("%s rtt %s", nc.ConnectedUrlRedacted(), rtt)
+}
+
+func (n *Node) runPreflight() {
+	n.preflight = RunPreflightChecks(n.config, n.nc)
+
+	for _, check := range n.preflight.Checks {
+		switch check.Status {
+		case controlapi.PreflightFail:
+			n.log.Error("Preflight check failed", slog.String("check", check.Name), slog.String("detail", check.Detail))
+		case controlapi.PreflightWarn:
+			n.log.Warn("Preflight check raised a warning", slog.String("check", check.Name), slog.String("detail", check.Detail))
+		default:
+			n.log.Debug("Preflight check completed", slog.String("check", check.Name), slog.String("status", string(check.Status)))
+		}
+	}
+}
+
+// Returns the path of the first of the given directories containing the named file
+func findInPaths(dirs []string, name string) string {
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,37 @@
+package nexnode
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestPreflightImageCheckVerifiesPinnedDigest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vmlinux")
+	err := os.WriteFile(path, []byte("kernel"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := models.DefaultNodeConfiguration()
+
+	status, detail := checkImageFile(&config, path, &models.ArtifactSpec{Sha256: strings.Repeat("0", 64)})
+	if status != controlapi.PreflightFail {
+		t.Fatalf("Expected mismatched digest to fail the check, got %s: %s", status, detail)
+	}
+
+	digest, _ := fileSha256(path)
+	status, detail = checkImageFile(&config, path, &models.ArtifactSpec{Sha256: strings.ToUpper(digest)})
+	if status != controlapi.PreflightPass {
+		t.Fatalf("Expected matching digest to pass the check, got %s: %s", status, detail)
+	}
+
+	config.NoSandbox = true
+	if status, _ := checkImageFile(&config, path, nil); status != controlapi.PreflightSkip {
+		t.Fatalf("Expected check to be skipped in no sandbox mode, got %s", status)
+	}
+}
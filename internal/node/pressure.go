@@ -0,0 +1,241 @@
+package nexnode
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/events"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const (
+	defaultPressureInterval        = 5 * time.Second
+	defaultPressureRecoverySamples = 3
+
+	pressureReasonCpu             = "cpu"
+	pressureReasonMemory          = "memory"
+	pressureReasonMemoryAvailable = "memory_available"
+)
+
+// Samples PSI stall averages and available memory. Like ReadMemoryStats this only works on Linux;
+// PSI additionally requires a kernel built with CONFIG_PSI
+func readPressureSample() (controlapi.PressureSample, error) {
+	var sample controlapi.PressureSample
+
+	for path, avg10 := range map[string]*float64{
+		"/proc/pressure/cpu":    &sample.CpuSomeAvg10,
+		"/proc/pressure/memory": &sample.MemorySomeAvg10,
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return sample, err
+		}
+
+		*avg10, err = parsePSIAvg10(string(data))
+		if err != nil {
+			return sample, fmt.Errorf("failed to parse %s: %s", path, err)
+		}
+	}
+
+	stats, err := ReadMemoryStats()
+	if err != nil {
+		return sample, err
+	}
+	if stats.MemTotal > 0 {
+		sample.MemoryAvailablePercent = float64(stats.MemAvailable) * 100 / float64(stats.MemTotal)
+	}
+
+	return sample, nil
+}
+
+// Returns the avg10 value of the "some" line of a PSI file, e.g.
+// some avg10=1.53 avg60=0.87 avg300=0.40 total=1234567
+func parsePSIAvg10(data string) (float64, error) {
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			if value, ok := strings.CutPrefix(field, "avg10="); ok {
+				return strconv.ParseFloat(value, 64)
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("no some avg10 value found")
+}
+
+// Returns the thresholds exceeded by the sample
+func pressureReasons(config *models.PressureConfig, sample controlapi.PressureSample) []string {
+	reasons := make([]string, 0)
+	if config.CpuSomeAvg10 > 0 && sample.CpuSomeAvg10 >= config.CpuSomeAvg10 {
+		reasons = append(reasons, pressureReasonCpu)
+	}
+	if config.MemorySomeAvg10 > 0 && sample.MemorySomeAvg10 >= config.MemorySomeAvg10 {
+		reasons = append(reasons, pressureReasonMemory)
+	}
+	if config.MinMemoryAvailablePercent > 0 && sample.MemoryAvailablePercent < config.MinMemoryAvailablePercent {
+		reasons = append(reasons, pressureReasonMemoryAvailable)
+	}
+
+	return reasons
+}
+
+// Samples host pressure until the node stops, entering and leaving the pressure state as the
+// samples cross the configured thresholds
+func (n *Node) runPressureMonitor() {
+	interval := time.Duration(n.config.Pressure.IntervalMillis) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultPressureInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+			sample, err := readPressureSample()
+			if err != nil {
+				n.log.Warn("Failed to sample host pressure; pressure monitoring stopped", slog.Any("err", err))
+				return
+			}
+
+			n.evaluatePressure(sample)
+		}
+	}
+}
+
+func (n *Node) evaluatePressure(sample controlapi.PressureSample) {
+	reasons := pressureReasons(n.config.Pressure, sample)
+
+	n.pressureMutex.Lock()
+	defer n.pressureMutex.Unlock()
+
+	if len(reasons) > 0 {
+		n.pressureCalm = 0
+		if n.pressure != nil {
+			n.pressure.Reasons = reasons
+			n.pressure.Sample = sample
+			return
+		}
+
+		n.pressure = &controlapi.PressureStatus{
+			Since:   time.Now().UTC(),
+			Reasons: reasons,
+			Sample:  sample,
+		}
+		n.config.Tags[controlapi.TagUnderPressure] = "true"
+
+		n.log.Warn("Node is under pressure; declining new work",
+			slog.Any("reasons", reasons),
+			slog.Float64("cpu_some_avg10", sample.CpuSomeAvg10),
+			slog.Float64("memory_some_avg10", sample.MemorySomeAvg10),
+			slog.Float64("memory_available_percent", sample.MemoryAvailablePercent),
+		)
+
+		if n.config.Pressure.PauseLowPriority {
+			n.pressure.PausedWorkloads = n.pauseLowPriorityWorkloads()
+		}
+
+		n.publishNodePressureChanged(n.pressure, sample)
+		return
+	}
+
+	if n.pressure == nil {
+		return
+	}
+
+	recovery := n.config.Pressure.RecoverySamples
+	if recovery <= 0 {
+		recovery = defaultPressureRecoverySamples
+	}
+
+	n.pressureCalm++
+	if n.pressureCalm < recovery {
+		return
+	}
+
+	for _, id := range n.pressure.PausedWorkloads {
+		_, err := n.manager.ResumeWorkload(id)
+		if err != nil {
+			n.log.Warn("Failed to resume workload paused under pressure", slog.String("workload_id", id), slog.Any("err", err))
+		}
+	}
+
+	n.pressure = nil
+	n.pressureCalm = 0
+	delete(n.config.Tags, controlapi.TagUnderPressure)
+
+	n.log.Info("Node recovered from pressure")
+	n.publishNodePressureChanged(nil, sample)
+}
+
+// Pauses the running workloads labelled as low priority, returning the IDs of those paused
+func (n *Node) pauseLowPriorityWorkloads() []string {
+	procs, err := n.manager.procMan.ListProcesses()
+	if err != nil {
+		n.log.Warn("Failed to list workloads to pause under pressure", slog.Any("err", err))
+		return nil
+	}
+
+	paused := make([]string, 0)
+	for _, proc := range procs {
+		if proc.DeployRequest == nil || proc.DeployRequest.Labels[controlapi.LabelPriority] != controlapi.PriorityLow {
+			continue
+		}
+
+		_, err := n.manager.PauseWorkload(proc.ID, n.config.Pressure.PauseMachines)
+		if err != nil {
+			n.log.Warn("Failed to pause low-priority workload under pressure", slog.String("workload_id", proc.ID), slog.Any("err", err))
+			continue
+		}
+		paused = append(paused, proc.ID)
+	}
+
+	return paused
+}
+
+func (n *Node) UnderPressure() bool {
+	n.pressureMutex.Lock()
+	defer n.pressureMutex.Unlock()
+
+	return n.pressure != nil
+}
+
+// Returns a copy of the node's pressure status, or nil if it is not under pressure
+func (n *Node) PressureStatus() *controlapi.PressureStatus {
+	n.pressureMutex.Lock()
+	defer n.pressureMutex.Unlock()
+
+	if n.pressure == nil {
+		return nil
+	}
+
+	status := *n.pressure
+	return &status
+}
+
+func (n *Node) publishNodePressureChanged(status *controlapi.PressureStatus, sample controlapi.PressureSample) {
+	if n.nc == nil {
+		return
+	}
+
+	evt := controlapi.NodePressureEvent{
+		Id:       n.publicKey,
+		Pressure: status,
+		Sample:   sample,
+	}
+
+	cloudevent := events.NodePressureChanged(n.publicKey, evt)
+	_ = PublishCloudEvent(n.events(), systemNamespace, cloudevent, n.log)
+}
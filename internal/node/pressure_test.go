@@ -0,0 +1,63 @@
+package nexnode
+
+import (
+	"log/slog"
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestParsePSIAvg10(t *testing.T) {
+	data := "some avg10=12.50 avg60=3.10 avg300=0.90 total=123456\nfull avg10=4.00 avg60=1.00 avg300=0.20 total=6789\n"
+
+	avg10, err := parsePSIAvg10(data)
+	if err != nil {
+		t.Fatalf("Expected PSI data to parse: %s", err)
+	}
+	if avg10 != 12.5 {
+		t.Fatalf("Expected some avg10 of 12.5, got %f", avg10)
+	}
+
+	if _, err := parsePSIAvg10("full avg10=4.00 avg60=1.00 avg300=0.20 total=6789\n"); err == nil {
+		t.Fatal("Expected PSI data without a some line to be rejected")
+	}
+}
+
+func TestPressureEnteredAndRecovered(t *testing.T) {
+	node := &Node{
+		log: slog.Default(),
+		config: &models.NodeConfiguration{
+			Tags: map[string]string{},
+			Pressure: &models.PressureConfig{
+				CpuSomeAvg10:              50,
+				MinMemoryAvailablePercent: 10,
+				RecoverySamples:           2,
+			},
+		},
+	}
+
+	node.evaluatePressure(controlapi.PressureSample{CpuSomeAvg10: 75, MemoryAvailablePercent: 5})
+
+	status := node.PressureStatus()
+	if status == nil || node.config.Tags[controlapi.TagUnderPressure] != "true" {
+		t.Fatal("Expected node to be under pressure and tagged")
+	}
+	if len(status.Reasons) != 2 || status.Reasons[0] != pressureReasonCpu || status.Reasons[1] != pressureReasonMemoryAvailable {
+		t.Fatalf("Expected cpu and memory_available reasons, got %v", status.Reasons)
+	}
+
+	calm := controlapi.PressureSample{CpuSomeAvg10: 10, MemoryAvailablePercent: 40}
+	node.evaluatePressure(calm)
+	if !node.UnderPressure() {
+		t.Fatal("Expected node to remain under pressure until enough calm samples were taken")
+	}
+
+	node.evaluatePressure(calm)
+	if node.UnderPressure() {
+		t.Fatal("Expected node to recover from pressure")
+	}
+	if _, ok := node.config.Tags[controlapi.TagUnderPressure]; ok {
+		t.Fatal("Expected pressure tag to be removed on recovery")
+	}
+}
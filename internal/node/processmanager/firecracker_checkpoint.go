@@ -0,0 +1,103 @@
+//go:build linux
+
+package processmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Pauses the workload's VM and writes a snapshot of its memory and devices, along with a copy
+// of its rootfs, into the given directory. The VM is left paused
+func (f *FirecrackerProcessManager) CheckpointProcess(workloadID string, dir string) (*MachineCheckpoint, error) {
+	vm, ok := f.allVMs[workloadID]
+	if !ok || vm.machine == nil || vm.deployRequest == nil {
+		return nil, fmt.Errorf("no VM for workload %s", workloadID)
+	}
+
+	if f.config.Jailer != nil {
+		return nil, errors.New("checkpoints cannot be taken of jailed VMs")
+	}
+
+	if vm.deployRequest.Volume != nil {
+		return nil, errors.New("checkpoints cannot be taken of workloads with a volume")
+	}
+
+	mutex := f.stopMutex[workloadID]
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	err := vm.machine.PauseVM(vm.vmmCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pause VM: %s", err)
+	}
+
+	err = vm.machine.CreateSnapshot(vm.vmmCtx, filepath.Join(dir, checkpointMemoryFile), filepath.Join(dir, checkpointStateFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot VM: %s", err)
+	}
+
+	// the rootfs is copied while the VM is paused, so that it matches the snapshotted memory
+	err = copy(getRootFsPath(vm.vmmID, f.config), filepath.Join(dir, checkpointRootfsFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy rootfs: %s", err)
+	}
+
+	f.log.Info("Checkpointed VM", slog.String("workload_id", workloadID), slog.String("dir", dir))
+
+	return &MachineCheckpoint{
+		GuestIP:    vm.ip.String(),
+		VcpuCount:  int(*vm.machine.Cfg.MachineCfg.VcpuCount),
+		MemSizeMib: int(*vm.machine.Cfg.MachineCfg.MemSizeMib),
+	}, nil
+}
+
+// Starts a VM for the workload from the snapshot in the given directory. The VM keeps the ID of
+// the workload, which names its rootfs and sockets, and the guest IP it was snapshotted with
+func (f *FirecrackerProcessManager) RestoreProcess(workloadID string, request *agentapi.DeployRequest, dir string, checkpoint *MachineCheckpoint) error {
+	if f.config.Jailer != nil {
+		return errors.New("checkpoints cannot be restored into jailed VMs")
+	}
+
+	if _, ok := f.allVMs[workloadID]; ok {
+		return fmt.Errorf("workload %s is already running on this node", workloadID)
+	}
+
+	if checkpoint.VcpuCount != *f.config.MachineTemplate.VcpuCount || checkpoint.MemSizeMib != *f.config.MachineTemplate.MemSizeMib {
+		return fmt.Errorf("checkpoint of a %d vCPU, %d MiB machine does not match this node's machine template", checkpoint.VcpuCount, checkpoint.MemSizeMib)
+	}
+
+	vm, err := restoreVM(context.TODO(), f.config, f.log, workloadID, dir, checkpoint)
+	if err != nil {
+		return err
+	}
+
+	vm.deployRequest = request
+	vm.namespace = *request.Namespace
+	vm.pool = f.config.AgentPool(request.WorkloadType)
+	vm.workloadStarted = time.Now().UTC()
+
+	f.allVMs[workloadID] = vm
+	f.stopMutex[workloadID] = &sync.Mutex{}
+
+	f.requestsMutex.Lock()
+	f.deployRequests[workloadID] = request
+	f.requestsMutex.Unlock()
+
+	f.t.VmCounter.Add(f.ctx, 1)
+	f.t.AllocatedVCPUCounter.Add(f.ctx, *vm.machine.Cfg.MachineCfg.VcpuCount)
+	f.t.AllocatedVCPUCounter.Add(f.ctx, *vm.machine.Cfg.MachineCfg.VcpuCount, metric.WithAttributes(attribute.String("namespace", vm.namespace)))
+	f.t.AllocatedMemoryCounter.Add(f.ctx, *vm.machine.Cfg.MachineCfg.MemSizeMib)
+	f.t.AllocatedMemoryCounter.Add(f.ctx, *vm.machine.Cfg.MachineCfg.MemSizeMib, metric.WithAttributes(attribute.String("namespace", vm.namespace)))
+
+	return nil
+}
@@ -30,11 +30,14 @@ type FirecrackerProcessManager struct {
 	stopMutex map[string]*sync.Mutex
 	t         *observability.Telemetry
 
-	allVMs  map[string]*runningFirecracker
-	warmVMs chan *runningFirecracker
+	allVMs    map[string]*runningFirecracker
+	warmVMs   *warmPools[*runningFirecracker]
+	jailSlots *jailSlots
 
 	delegate       ProcessDelegate
 	deployRequests map[string]*agentapi.DeployRequest
+	// Guards deployRequests, which concurrent deployments prepare while workloads stop
+	requestsMutex sync.RWMutex
 
 	intNats    *internalnats.InternalNatsServer
 	nameserver *string
@@ -57,7 +60,8 @@ func NewFirecrackerProcessManager(
 		t:          telemetry,
 
 		allVMs:         make(map[string]*runningFirecracker),
-		warmVMs:        make(chan *runningFirecracker, config.MachinePoolSize),
+		jailSlots:      newJailSlots(),
+		warmVMs:        newWarmPools(config, func(vm *runningFirecracker) string { return vm.vmmID }),
 		stopMutex:      make(map[string]*sync.Mutex),
 		deployRequests: make(map[string]*agentapi.DeployRequest),
 	}, nil
@@ -75,6 +79,9 @@ func (f *FirecrackerProcessManager) ListProcesses() ([]ProcessInfo, error) {
 				Namespace:     *vm.deployRequest.Namespace,
 				DeployRequest: vm.deployRequest,
 			}
+			if vm.machine != nil {
+				pinfo.Pid, _ = vm.machine.PID()
+			}
 			pinfos = append(pinfos, pinfo)
 		}
 	}
@@ -84,6 +91,9 @@ func (f *FirecrackerProcessManager) ListProcesses() ([]ProcessInfo, error) {
 
 func (f *FirecrackerProcessManager) EnterLameDuck() error {
 	nope := false
+	f.requestsMutex.RLock()
+	defer f.requestsMutex.RUnlock()
+
 	for _, req := range f.deployRequests {
 		req.Essential = &nope
 	}
@@ -91,18 +101,28 @@ func (f *FirecrackerProcessManager) EnterLameDuck() error {
 	return nil
 }
 
-// Preparing a workload reads from the warmVMs channel
+// Preparing a workload takes the workload's VM from the warm pool serving its workload type
 func (f *FirecrackerProcessManager) PrepareWorkload(workloadId string, deployRequest *agentapi.DeployRequest) error {
-	vm := <-f.warmVMs
-	if vm == nil {
-		return fmt.Errorf("could not prepare workload, no available firecracker VM")
+	pool := f.config.AgentPool(deployRequest.WorkloadType)
+	vm, ok := f.warmVMs.take(pool, workloadId)
+	if !ok || vm == nil {
+		return fmt.Errorf("could not prepare workload, firecracker VM %s is not available in the %s agent pool", workloadId, poolName(pool))
+	}
+
+	if deployRequest.Volume != nil {
+		err := vm.attachVolume(deployRequest.Volume)
+		if err != nil {
+			return err
+		}
 	}
 
 	vm.deployRequest = deployRequest
 	vm.namespace = *deployRequest.Namespace
 	vm.workloadStarted = time.Now().UTC()
 
+	f.requestsMutex.Lock()
 	f.deployRequests[vm.vmmID] = deployRequest
+	f.requestsMutex.Unlock()
 
 	f.t.AllocatedVCPUCounter.Add(f.ctx, *vm.machine.Cfg.MachineCfg.VcpuCount)
 	f.t.AllocatedVCPUCounter.Add(f.ctx, *vm.machine.Cfg.MachineCfg.VcpuCount, metric.WithAttributes(attribute.String("namespace", vm.namespace)))
@@ -115,7 +135,7 @@ func (f *FirecrackerProcessManager) PrepareWorkload(workloadId string, deployReq
 func (f *FirecrackerProcessManager) Stop() error {
 	if atomic.AddUint32(&f.closing, 1) == 1 {
 		f.log.Info("Firecracker process manager stopping")
-		close(f.warmVMs)
+		f.warmVMs.close()
 
 		for vmID := range f.allVMs {
 			err := f.StopProcess(vmID)
@@ -152,14 +172,23 @@ func (f *FirecrackerProcessManager) Start(delegate ProcessDelegate) error {
 		case <-f.ctx.Done():
 			return nil
 		default:
-			if len(f.warmVMs) == f.config.MachinePoolSize {
+			pool, ok := f.warmVMs.nextToFill()
+			if !ok {
 				time.Sleep(runloopSleepInterval)
 				continue
 			}
 
-			vm, err := createAndStartVM(context.TODO(), f.config, f.log)
+			jailSlot := -1
+			if f.config.Jailer != nil {
+				jailSlot = f.jailSlots.acquire()
+			}
+
+			vm, err := createAndStartVM(context.TODO(), f.config, f.log, jailSlot, f.config.AgentPoolRootFs(pool))
 			if err != nil {
-				f.log.Warn("Failed to create VMM for warming pool.", slog.Any("err", err))
+				if jailSlot >= 0 {
+					f.jailSlots.release(jailSlot)
+				}
+				f.log.Warn("Failed to create VMM for warming pool.", slog.String("pool", poolName(pool)), slog.Any("err", err))
 				continue
 			}
 
@@ -176,15 +205,18 @@ func (f *FirecrackerProcessManager) Start(delegate ProcessDelegate) error {
 				continue
 			}
 
+			vm.pool = pool
 			f.allVMs[vm.vmmID] = vm
 			f.stopMutex[vm.vmmID] = &sync.Mutex{}
 
 			f.t.VmCounter.Add(f.ctx, 1)
 
-			go f.delegate.OnProcessStarted(vm.vmmID)
+			f.log.Info("Adding new VM to warm pool", slog.Any("ip", vm.ip), slog.String("vmid", vm.vmmID), slog.String("pool", poolName(pool)))
+
+			// the VM is pooled before it can be reserved, so preparing it always finds it
+			f.warmVMs.add(pool, vm)
 
-			f.log.Info("Adding new VM to warm pool", slog.Any("ip", vm.ip), slog.String("vmid", vm.vmmID))
-			f.warmVMs <- vm // If the pool is full, this line will block until a slot is available.
+			go f.delegate.OnProcessStarted(vm.vmmID, pool)
 		}
 	}
 
@@ -197,7 +229,9 @@ func (f *FirecrackerProcessManager) StopProcess(workloadID string) error {
 		return fmt.Errorf("failed to stop machine %s", workloadID)
 	}
 
+	f.requestsMutex.Lock()
 	delete(f.deployRequests, workloadID)
+	f.requestsMutex.Unlock()
 
 	mutex := f.stopMutex[workloadID]
 	mutex.Lock()
@@ -206,6 +240,10 @@ func (f *FirecrackerProcessManager) StopProcess(workloadID string) error {
 	f.log.Debug("Attempting to stop virtual machine", slog.String("workload_id", workloadID))
 	vm.shutdown()
 
+	if vm.jailSlot >= 0 {
+		f.jailSlots.release(vm.jailSlot)
+	}
+
 	delete(f.allVMs, workloadID)
 	delete(f.stopMutex, workloadID)
 
@@ -222,10 +260,18 @@ func (f *FirecrackerProcessManager) StopProcess(workloadID string) error {
 	f.t.AllocatedMemoryCounter.Add(f.ctx, *vm.machine.Cfg.MachineCfg.MemSizeMib*-1)
 	f.t.AllocatedMemoryCounter.Add(f.ctx, *vm.machine.Cfg.MachineCfg.MemSizeMib*-1, metric.WithAttributes(attribute.String("namespace", vm.namespace)))
 
+	if vm.deployRequest == nil && !f.stopping() {
+		// removing a stopped, unprepared VM from its warm pool lets the run loop replace it
+		f.warmVMs.take(vm.pool, workloadID)
+	}
+
 	return nil
 }
 
 func (f *FirecrackerProcessManager) Lookup(workloadID string) (*agentapi.DeployRequest, error) {
+	f.requestsMutex.RLock()
+	defer f.requestsMutex.RUnlock()
+
 	if request, ok := f.deployRequests[workloadID]; ok {
 		return request, nil
 	}
@@ -234,6 +280,59 @@ func (f *FirecrackerProcessManager) Lookup(workloadID string) (*agentapi.DeployR
 	return nil, nil
 }
 
+// Samples the resource usage of the VM running the given workload
+func (f *FirecrackerProcessManager) MachineStats(workloadID string) (*MachineStats, error) {
+	vm, ok := f.allVMs[workloadID]
+	if !ok || vm.machine == nil {
+		return nil, fmt.Errorf("no VM for workload %s", workloadID)
+	}
+
+	return vm.stats()
+}
+
+// Pauses the workload's VM through the Firecracker API; its memory and devices are kept intact
+func (f *FirecrackerProcessManager) PauseProcess(workloadID string) error {
+	vm, ok := f.allVMs[workloadID]
+	if !ok || vm.machine == nil {
+		return fmt.Errorf("no VM for workload %s", workloadID)
+	}
+
+	err := vm.machine.PauseVM(vm.vmmCtx)
+	if err != nil {
+		return fmt.Errorf("failed to pause VM: %s", err)
+	}
+
+	return nil
+}
+
+func (f *FirecrackerProcessManager) ResumeProcess(workloadID string) error {
+	vm, ok := f.allVMs[workloadID]
+	if !ok || vm.machine == nil {
+		return fmt.Errorf("no VM for workload %s", workloadID)
+	}
+
+	err := vm.machine.ResumeVM(vm.vmmCtx)
+	if err != nil {
+		return fmt.Errorf("failed to resume VM: %s", err)
+	}
+
+	return nil
+}
+
+func (f *FirecrackerProcessManager) ResizeBalloon(workloadID string, amountMib int64) error {
+	vm, ok := f.allVMs[workloadID]
+	if !ok || vm.machine == nil {
+		return fmt.Errorf("no VM for workload %s", workloadID)
+	}
+
+	err := vm.machine.UpdateBalloon(vm.vmmCtx, amountMib)
+	if err != nil {
+		return fmt.Errorf("failed to resize balloon: %s", err)
+	}
+
+	return nil
+}
+
 func (f *FirecrackerProcessManager) resetCNI() error {
 	f.log.Info("Resetting network")
 
@@ -0,0 +1,271 @@
+package processmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/synadia-io/nex/agent/agenttest"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/models"
+	internalnats "github.com/synadia-io/nex/internal/node/internal-nats"
+	"github.com/synadia-io/nex/internal/node/observability"
+)
+
+// A process manager whose agents run inside the node, as goroutines speaking the agent protocol
+// over the internal NATS server. Its agents accept every deployment and echo trigger payloads
+// without running workloads, so nodes can be tested without agent binaries, root privileges
+// or Firecracker
+type InMemoryProcessManager struct {
+	closing uint32
+	config  *models.NodeConfiguration
+	ctx     context.Context
+	t       *observability.Telemetry
+
+	liveAgents map[string]*inMemoryAgent
+	warmAgents *warmPools[*inMemoryAgent]
+	intNats    *internalnats.InternalNatsServer
+
+	delegate ProcessDelegate
+	// Guards liveAgents and the deploy requests attached to them
+	mutex sync.RWMutex
+
+	log *slog.Logger
+}
+
+type inMemoryAgent struct {
+	agent         *agenttest.Agent
+	deployRequest *agentapi.DeployRequest
+	pool          controlapi.NexWorkload
+}
+
+func NewInMemoryProcessManager(
+	ctx context.Context,
+	config *models.NodeConfiguration,
+	intNats *internalnats.InternalNatsServer,
+	log *slog.Logger,
+	telemetry *observability.Telemetry,
+) (*InMemoryProcessManager, error) {
+	return &InMemoryProcessManager{
+		config:  config,
+		t:       telemetry,
+		log:     log,
+		ctx:     ctx,
+		intNats: intNats,
+
+		liveAgents: make(map[string]*inMemoryAgent),
+		warmAgents: newWarmPools(config, func(agent *inMemoryAgent) string { return agent.agent.ID() }),
+	}, nil
+}
+
+// Returns the list of in-memory agents that have been associated with a workload via deploy request
+func (m *InMemoryProcessManager) ListProcesses() ([]ProcessInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	pinfos := make([]ProcessInfo, 0)
+	for workloadID, agent := range m.liveAgents {
+		if agent.deployRequest != nil {
+			pinfos = append(pinfos, ProcessInfo{
+				ID:            workloadID,
+				Name:          *agent.deployRequest.WorkloadName,
+				Namespace:     *agent.deployRequest.Namespace,
+				DeployRequest: agent.deployRequest,
+			})
+		}
+	}
+
+	return pinfos, nil
+}
+
+func (m *InMemoryProcessManager) EnterLameDuck() error {
+	nope := false
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, agent := range m.liveAgents {
+		if agent.deployRequest != nil {
+			agent.deployRequest.Essential = &nope
+		}
+	}
+
+	return nil
+}
+
+// Attaches a deployment request to a warm in-memory agent, taking it from the pool serving the
+// request's workload type
+func (m *InMemoryProcessManager) PrepareWorkload(workloadID string, deployRequest *agentapi.DeployRequest) error {
+	pool := m.config.AgentPool(deployRequest.WorkloadType)
+	agent, ok := m.warmAgents.take(pool, workloadID)
+	if !ok || agent == nil {
+		return fmt.Errorf("could not prepare workload, in-memory agent %s is not available in the %s agent pool", workloadID, poolName(pool))
+	}
+
+	m.mutex.Lock()
+	agent.deployRequest = deployRequest
+	m.mutex.Unlock()
+
+	return nil
+}
+
+// Stops the entire process manager and every in-memory agent
+func (m *InMemoryProcessManager) Stop() error {
+	if atomic.AddUint32(&m.closing, 1) == 1 {
+		m.log.Info("In-memory process manager stopping")
+
+		m.mutex.RLock()
+		workloadIDs := make([]string, 0, len(m.liveAgents))
+		for workloadID := range m.liveAgents {
+			workloadIDs = append(workloadIDs, workloadID)
+		}
+		m.mutex.RUnlock()
+
+		for _, workloadID := range workloadIDs {
+			err := m.StopProcess(workloadID)
+			if err != nil {
+				m.log.Warn("Failed to stop in-memory agent",
+					slog.String("workload_id", workloadID),
+					slog.String("error", err.Error()),
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Starts the process manager and fills the warm pools with in-memory agents
+func (m *InMemoryProcessManager) Start(delegate ProcessDelegate) error {
+	m.delegate = delegate
+	m.log.Info("In-memory process manager starting")
+
+	for !m.stopping() {
+		select {
+		case <-m.ctx.Done():
+			return nil
+		default:
+			pool, ok := m.warmAgents.nextToFill()
+			if !ok {
+				time.Sleep(runloopSleepInterval)
+				continue
+			}
+
+			workloadID := xid.New().String()
+			kp, err := m.intNats.CreateCredentials(workloadID)
+			if err != nil {
+				m.log.Error("Failed to create in-memory agent credentials", slog.String("pool", poolName(pool)), slog.Any("error", err))
+				time.Sleep(runloopSleepInterval)
+				continue
+			}
+			seed, _ := kp.Seed()
+
+			agent := &inMemoryAgent{
+				agent: agenttest.New(workloadID, agenttest.Options{}),
+				pool:  pool,
+			}
+
+			m.mutex.Lock()
+			m.liveAgents[workloadID] = agent
+			m.mutex.Unlock()
+
+			m.log.Info("Adding new in-memory agent to warm pool",
+				slog.String("workload_id", workloadID),
+				slog.String("pool", poolName(pool)))
+
+			m.warmAgents.add(pool, agent)
+
+			go func() {
+				// the node listens for the agent's handshake once it knows the agent started
+				m.delegate.OnProcessStarted(workloadID, pool)
+
+				err := agent.agent.Start(m.intNats.ClientURL(), string(seed))
+				if err != nil {
+					// the node reaps agents that never complete their handshake
+					m.log.Warn("In-memory agent failed to start", slog.String("workload_id", workloadID), slog.Any("error", err))
+				}
+			}()
+		}
+	}
+
+	return nil
+}
+
+// Stops a single in-memory agent
+func (m *InMemoryProcessManager) StopProcess(workloadID string) error {
+	m.mutex.Lock()
+	agent, exists := m.liveAgents[workloadID]
+	delete(m.liveAgents, workloadID)
+	m.mutex.Unlock()
+
+	if !exists {
+		return fmt.Errorf("failed to stop process %s. No such process", workloadID)
+	}
+
+	m.log.Debug("Attempting to stop in-memory agent", slog.String("workload_id", workloadID))
+	err := agent.agent.Stop()
+	if err != nil {
+		m.log.Debug("In-memory agent did not drain cleanly", slog.String("workload_id", workloadID), slog.Any("error", err))
+	}
+
+	if agent.deployRequest == nil && !m.stopping() {
+		// removing a stopped, unprepared agent from its warm pool lets the start loop replace it
+		m.warmAgents.take(agent.pool, workloadID)
+	}
+
+	return nil
+}
+
+// Looks up an in-memory agent. A non-existent or unprepared agent returns (nil, nil), not an error
+func (m *InMemoryProcessManager) Lookup(workloadID string) (*agentapi.DeployRequest, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if agent, ok := m.liveAgents[workloadID]; ok {
+		return agent.deployRequest, nil
+	}
+
+	return nil, nil
+}
+
+// In-memory agents share the node's process, so they report no usage of their own
+func (m *InMemoryProcessManager) MachineStats(workloadID string) (*MachineStats, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if _, ok := m.liveAgents[workloadID]; !ok {
+		return nil, fmt.Errorf("no in-memory agent for workload %s", workloadID)
+	}
+
+	return &MachineStats{}, nil
+}
+
+func (m *InMemoryProcessManager) PauseProcess(workloadID string) error {
+	return errors.New("pausing agent processes requires a sandboxed node")
+}
+
+func (m *InMemoryProcessManager) ResumeProcess(workloadID string) error {
+	return errors.New("pausing agent processes requires a sandboxed node")
+}
+
+func (m *InMemoryProcessManager) ResizeBalloon(workloadID string, amountMib int64) error {
+	return errors.New("balloon memory reclaim requires a sandboxed node")
+}
+
+func (m *InMemoryProcessManager) CheckpointProcess(workloadID string, dir string) (*MachineCheckpoint, error) {
+	return nil, errors.New("checkpoints require a sandboxed node")
+}
+
+func (m *InMemoryProcessManager) RestoreProcess(workloadID string, request *agentapi.DeployRequest, dir string, checkpoint *MachineCheckpoint) error {
+	return errors.New("checkpoints require a sandboxed node")
+}
+
+// Checks if the process manager is stopping
+func (m *InMemoryProcessManager) stopping() bool {
+	return (atomic.LoadUint32(&m.closing) > 0)
+}
@@ -0,0 +1,114 @@
+//go:build linux
+
+package processmanager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk"
+
+	nexmodels "github.com/synadia-io/nex/internal/models"
+)
+
+// Hands out the offsets used to derive each jailed VM's dedicated UID and GID, reusing
+// offsets released by stopped VMs so that IDs remain within a compact range
+type jailSlots struct {
+	mutex sync.Mutex
+	inUse map[int]bool
+}
+
+func newJailSlots() *jailSlots {
+	return &jailSlots{
+		inUse: make(map[int]bool),
+	}
+}
+
+func (s *jailSlots) acquire() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	slot := 0
+	for s.inUse[slot] {
+		slot++
+	}
+	s.inUse[slot] = true
+
+	return slot
+}
+
+func (s *jailSlots) release(slot int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.inUse, slot)
+}
+
+// Returns the UID and GID assigned to the jailed VM occupying the given slot
+func jailerIdentity(config *nexmodels.JailerConfig, slot int) (int, int) {
+	uidStart := config.UidRangeStart
+	if uidStart == 0 {
+		uidStart = nexmodels.DefaultJailerIdRangeStart
+	}
+
+	gidStart := config.GidRangeStart
+	if gidStart == 0 {
+		gidStart = nexmodels.DefaultJailerIdRangeStart
+	}
+
+	return uidStart + slot, gidStart + slot
+}
+
+func jailerChrootBaseDir(config *nexmodels.JailerConfig) string {
+	if config.ChrootBaseDir == "" {
+		return nexmodels.DefaultJailerChrootBaseDir
+	}
+
+	return config.ChrootBaseDir
+}
+
+// The jailer creates each VM's chroot at <base>/<exec file name>/<id>
+func jailerChrootDir(config *nexmodels.JailerConfig, firecrackerBinary string, vmmID string) string {
+	return filepath.Join(jailerChrootBaseDir(config), filepath.Base(firecrackerBinary), vmmID)
+}
+
+func generateJailerConfig(vmmID string, config *nexmodels.NodeConfiguration, firecrackerBinary string, slot int) (*firecracker.JailerConfig, error) {
+	jailerBinary := config.Jailer.JailerBinary
+	if jailerBinary == "" {
+		var err error
+		jailerBinary, err = exec.LookPath("jailer")
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate jailer binary: %s", err)
+		}
+	}
+
+	cgroupVersion := config.Jailer.CgroupVersion
+	if cgroupVersion == "" {
+		cgroupVersion = nexmodels.DefaultJailerCgroupVersion
+	}
+
+	numaNode := 0
+	if config.Jailer.NumaNode != nil {
+		numaNode = *config.Jailer.NumaNode
+	}
+
+	uid, gid := jailerIdentity(config.Jailer, slot)
+
+	return &firecracker.JailerConfig{
+		ID:             vmmID,
+		UID:            firecracker.Int(uid),
+		GID:            firecracker.Int(gid),
+		NumaNode:       firecracker.Int(numaNode),
+		ExecFile:       firecrackerBinary,
+		JailerBinary:   jailerBinary,
+		ChrootBaseDir:  jailerChrootBaseDir(config.Jailer),
+		ChrootStrategy: firecracker.NewNaiveChrootStrategy(config.KernelFilepath),
+		CgroupVersion:  cgroupVersion,
+		CgroupArgs:     config.Jailer.Cgroups,
+		Stdout:         os.Stdout,
+		Stderr:         os.Stderr,
+	}, nil
+}
@@ -0,0 +1,47 @@
+//go:build linux
+
+package processmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Clock ticks per second in which procfs reports CPU time (USER_HZ), fixed at 100 on Linux
+const procClockTicks = 100
+
+// Reads the CPU time and resident memory of the given process from procfs
+func readProcStats(pid int) (*MachineStats, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("process id unknown")
+	}
+
+	raw, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	// the command name may contain spaces, so fields are counted from the end of it
+	stat := string(raw)
+	end := strings.LastIndexByte(stat, ')')
+	if end < 0 {
+		return nil, fmt.Errorf("malformed stat for process %d", pid)
+	}
+
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("malformed stat for process %d", pid)
+	}
+
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	rss, _ := strconv.ParseInt(fields[21], 10, 64)
+
+	return &MachineStats{
+		CPUTimeMillis:  (utime + stime) * 1000 / procClockTicks,
+		MemoryRSSBytes: rss * int64(os.Getpagesize()),
+	}, nil
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package processmanager
+
+import "errors"
+
+func readProcStats(pid int) (*MachineStats, error) {
+	return nil, errors.New("process stats are not supported on windows")
+}
@@ -3,6 +3,7 @@ package processmanager
 import (
 	"time"
 
+	controlapi "github.com/synadia-io/nex/control-api"
 	agentapi "github.com/synadia-io/nex/internal/agent-api"
 )
 
@@ -14,13 +15,47 @@ type ProcessInfo struct {
 	ID            string
 	Name          string
 	Namespace     string
+
+	// Operating system process ID of the agent process, or 0 if unknown
+	Pid int
+}
+
+// Resource usage of an agent process, or of the VM hosting it. Counters are totals since the
+// process started; block and network I/O are only known for VMs
+type MachineStats struct {
+	CPUTimeMillis   int64
+	MemoryRSSBytes  int64
+	BlockReadBytes  int64
+	BlockWriteBytes int64
+	NetRxBytes      int64
+	NetTxBytes      int64
+}
+
+// Names of the files written into a checkpoint directory by CheckpointProcess
+const (
+	checkpointMemoryFile = "memory"
+	checkpointStateFile  = "vmstate"
+	checkpointRootfsFile = "rootfs.ext4"
+)
+
+// Files making up a machine checkpoint, all of which RestoreProcess expects in its directory
+var CheckpointFiles = []string{checkpointMemoryFile, checkpointStateFile, checkpointRootfsFile}
+
+// Describes a snapshot of the machine running a workload, written by CheckpointProcess. A
+// machine can only be restored on a node with the same machine template, and keeps the guest
+// IP it had, so the node restoring it must be able to assign that address
+type MachineCheckpoint struct {
+	GuestIP    string `json:"guest_ip"`
+	VcpuCount  int    `json:"vcpu_count"`
+	MemSizeMib int    `json:"mem_size_mib"`
 }
 
 // A process delegate is any struct that wishes to be notified when the configured agent process
 // manager has successfully started an agent
 type ProcessDelegate interface {
-	// Indicates that an agent process with the given id has been started and is ready for workload deployment
-	OnProcessStarted(id string)
+	// Indicates that an agent process with the given id has been started in the given warm pool
+	// and is ready for deployment of workloads served by that pool
+	OnProcessStarted(id string, pool controlapi.NexWorkload)
 
 	// Indicates that an agent process with the given id should exit
 	// OnProcessExit(id string) error
@@ -39,11 +74,12 @@ type ProcessManager interface {
 	Lookup(id string) (*agentapi.DeployRequest, error)
 
 	// Associate a deploy request with the given workload id, and perform any
-	// just in time initialization of resources if necessary
+	// just in time initialization of resources if necessary. The agent process must
+	// belong to the warm pool serving the request's workload type
 	PrepareWorkload(id string, request *agentapi.DeployRequest) error
 
-	// Start the process manager and allocate a pool of agents based on an implementation-specific
-	// strategy, delegating callbacks to the given delegate
+	// Start the process manager and allocate the configured warm pools of agents based on an
+	// implementation-specific strategy, delegating callbacks to the given delegate
 	Start(delegate ProcessDelegate) error
 
 	// Stop the process manager and gracefully shutdown all agents in the pool
@@ -55,4 +91,25 @@ type ProcessManager interface {
 	// Notifies the process manager that the node is in lame duck mode, so that the processes
 	// can be treated differerently (if applicable)
 	EnterLameDuck() error
+
+	// Samples the resource usage of the agent process running the given workload
+	MachineStats(id string) (*MachineStats, error)
+
+	// Suspends the agent process running the given workload, preserving its state until resumed
+	PauseProcess(id string) error
+
+	// Resumes an agent process suspended by PauseProcess
+	ResumeProcess(id string) error
+
+	// Inflates or deflates the balloon of the machine running the given workload to the given
+	// size, reclaiming that much of the machine's memory for the host
+	ResizeBalloon(id string, amountMib int64) error
+
+	// Snapshots the memory and disk of the machine running the given workload into the given
+	// directory. The machine is left paused, for the caller to resume or stop
+	CheckpointProcess(id string, dir string) (*MachineCheckpoint, error)
+
+	// Starts a machine for the given workload from a snapshot that CheckpointProcess wrote into
+	// the given directory, possibly on another node. The machine resumes where it was paused
+	RestoreProcess(id string, request *agentapi.DeployRequest, dir string, checkpoint *MachineCheckpoint) error
 }
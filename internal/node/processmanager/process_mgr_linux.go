@@ -20,6 +20,11 @@ func NewProcessManager(
 	nameserver *string,
 	telemetry *observability.Telemetry,
 ) (ProcessManager, error) {
+	if config.InMemoryAgents {
+		log.Warn("⚠️  Agents are running in memory! Workloads are accepted but never run")
+		return NewInMemoryProcessManager(ctx, config, intNats, log, telemetry)
+	}
+
 	if config.NoSandbox {
 		log.Warn("⚠️  Sandboxing has been disabled! Workloads are spawned directly by agents")
 		log.Warn("⚠️  Do not run untrusted workloads in this mode!")
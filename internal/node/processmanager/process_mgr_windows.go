@@ -20,5 +20,10 @@ func NewProcessManager(
 	_ *string,
 	telemetry *observability.Telemetry,
 ) (ProcessManager, error) {
+	if config.InMemoryAgents {
+		log.Warn("⚠️  Agents are running in memory! Workloads are accepted but never run")
+		return NewInMemoryProcessManager(ctx, config, intnats, log, telemetry)
+	}
+
 	return NewSpawningProcessManager(ctx, config, intnats, log, telemetry)
 }
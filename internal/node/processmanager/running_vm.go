@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -21,25 +22,48 @@ import (
 	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
 	"github.com/rs/xid"
 
+	controlapi "github.com/synadia-io/nex/control-api"
 	agentapi "github.com/synadia-io/nex/internal/agent-api"
 	nexmodels "github.com/synadia-io/nex/internal/models"
 )
 
+const (
+	// ID of the drive through which a persistent volume is attached to a VM, and the device it
+	// appears as in the guest
+	volumeDriveID = "2"
+	volumeDevice  = "/dev/vdb"
+
+	// Size of the empty image backing the volume drive until a volume is attached; drives
+	// cannot be added once a VM has booted, only pointed at another image
+	volumePlaceholderBytes = 1024 * 1024
+)
+
 // Represents an instance of a single firecracker VM containing the nex agent.
 type runningFirecracker struct {
 	vmmCtx    context.Context
 	vmmCancel context.CancelFunc
 	vmmID     string
 
-	closing         uint32
-	config          *nexmodels.NodeConfiguration
-	deployRequest   *agentapi.DeployRequest
-	ip              net.IP
-	log             *slog.Logger
-	machine         *firecracker.Machine
-	machineStarted  time.Time
-	namespace       string
-	workloadStarted time.Time
+	closing           uint32
+	config            *nexmodels.NodeConfiguration
+	deployRequest     *agentapi.DeployRequest
+	firecrackerBinary string
+	ip                net.IP
+	log               *slog.Logger
+	machine           *firecracker.Machine
+	machineStarted    time.Time
+	namespace         string
+	pool              controlapi.NexWorkload
+	workloadStarted   time.Time
+
+	// offset of the dedicated UID/GID assigned to a jailed VM; -1 when the jailer is not in use
+	jailSlot int
+
+	// I/O counters summed from the flushes read so far, and the metrics file offset up to which
+	// they were read
+	metricsMutex  sync.Mutex
+	metricsOffset int64
+	ioTotals      MachineStats
 }
 
 func (vm *runningFirecracker) setMetadata(metadata *agentapi.MachineMetadata) error {
@@ -52,6 +76,21 @@ func (vm *runningFirecracker) setMetadata(metadata *agentapi.MachineMetadata) er
 	return nil
 }
 
+// Points the VM's volume drive at the image of the given volume, which the agent then mounts
+func (vm *runningFirecracker) attachVolume(volume *agentapi.WorkloadVolume) error {
+	if vm.config.Volumes == nil {
+		return errors.New("volumes are not enabled on this node")
+	}
+
+	err := vm.machine.UpdateGuestDrive(vm.vmmCtx, volumeDriveID, volume.HostPath)
+	if err != nil {
+		return fmt.Errorf("failed to attach volume %s: %s", volume.Name, err)
+	}
+
+	volume.Device = volumeDevice
+	return nil
+}
+
 func (vm *runningFirecracker) shutdown() {
 	if atomic.AddUint32(&vm.closing, 1) == 1 {
 		vm.log.Info("Machine stopping",
@@ -64,6 +103,14 @@ func (vm *runningFirecracker) shutdown() {
 			vm.log.Error("Failed to stop firecracker VM", slog.Any("err", err))
 		}
 
+		if vm.config.Jailer != nil {
+			// the socket, log and hard-linked kernel and rootfs all live inside the chroot
+			err = os.RemoveAll(jailerChrootDir(vm.config.Jailer, vm.firecrackerBinary, vm.vmmID))
+			if err != nil {
+				vm.log.Warn("Failed to remove VM chroot", slog.Any("err", err))
+			}
+		}
+
 		err = os.Remove(getSocketPath(vm.vmmID))
 		if err != nil {
 			if !errors.Is(err, fs.ErrNotExist) {
@@ -78,18 +125,34 @@ func (vm *runningFirecracker) shutdown() {
 			}
 		}
 
-		rootFsPath := getRootFsPath(vm.vmmID)
+		err = os.Remove(getMetricsPath(vm.vmmID))
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				vm.log.Error("Failed to remove VM metrics", slog.Any("err", err))
+			}
+		}
+
+		rootFsPath := getRootFsPath(vm.vmmID, vm.config)
 		err = os.Remove(rootFsPath)
 		if err != nil {
 			if !errors.Is(err, fs.ErrNotExist) {
 				vm.log.Warn("Failed to delete VM rootfs", slog.Any("err", err))
 			}
 		}
+
+		// an attached volume's image is kept by the node; only the placeholder goes with the VM
+		if vm.config.Volumes != nil {
+			err = os.Remove(getVolumePlaceholderPath(vm.vmmID))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				vm.log.Warn("Failed to delete VM volume placeholder", slog.Any("err", err))
+			}
+		}
 	}
 }
 
-// Create a VMM with a given set of options and start the VM
-func createAndStartVM(ctx context.Context, config *nexmodels.NodeConfiguration, log *slog.Logger) (*runningFirecracker, error) {
+// Create a VMM with a given set of options and start the VM booting the given rootfs. When the
+// jailer is configured, the VM runs under the UID/GID derived from the given jail slot
+func createAndStartVM(ctx context.Context, config *nexmodels.NodeConfiguration, log *slog.Logger, jailSlot int, rootfs string) (*runningFirecracker, error) {
 	vmmID := xid.New().String()
 
 	fcCfg, err := generateFirecrackerConfig(vmmID, config)
@@ -98,35 +161,52 @@ func createAndStartVM(ctx context.Context, config *nexmodels.NodeConfiguration,
 		return nil, err
 	}
 
-	err = copy(config.RootFsFilepath, *fcCfg.Drives[0].PathOnHost)
+	if config.Jailer != nil {
+		err = os.MkdirAll(jailerChrootBaseDir(config.Jailer), 0755)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create jailer chroot base directory: %s", err)
+		}
+	}
+
+	err = copy(rootfs, *fcCfg.Drives[0].PathOnHost)
 
 	if err != nil {
 		log.Error("Failed to copy rootfs to temp location", slog.Any("err", err))
 		return nil, err
 	}
 
+	if config.Volumes != nil {
+		err = createVolumePlaceholder(getVolumePlaceholderPath(vmmID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create volume placeholder: %s", err)
+		}
+	}
+
 	// TODO: can we please not use logrus here amazon?
 	machineOpts := []firecracker.Opt{
 		firecracker.WithLogger(log.With(slog.Bool("firecracker", true), slog.String("vmmid", vmmID))),
 	}
 
-	firecrackerBinary, err := exec.LookPath("firecracker")
+	firecrackerBinary, err := findFirecracker()
 	if err != nil {
 		return nil, err
 	}
 
-	finfo, err := os.Stat(firecrackerBinary)
-	if os.IsNotExist(err) {
-		return nil, fmt.Errorf("binary %q does not exist: %v", firecrackerBinary, err)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat binary, %q: %v", firecrackerBinary, err)
-	}
+	if config.Jailer != nil {
+		fcCfg.JailerCfg, err = generateJailerConfig(vmmID, config, firecrackerBinary, jailSlot)
+		if err != nil {
+			return nil, err
+		}
 
-	if finfo.IsDir() {
-		return nil, fmt.Errorf("binary, %q, is a directory", firecrackerBinary)
-	} else if finfo.Mode()&0111 == 0 {
-		return nil, fmt.Errorf("binary, %q, is not executable. Check permissions of binary", firecrackerBinary)
+		// firecracker logs to the jailer's stderr, as a log file outside the chroot is unreachable
+		fcCfg.LogPath = ""
+		fcCfg.MetricsPath = ""
+
+		uid, gid := jailerIdentity(config.Jailer, jailSlot)
+		err = os.Chown(*fcCfg.Drives[0].PathOnHost, uid, gid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to assign rootfs ownership to jailed VM: %s", err)
+		}
 	}
 
 	if fcCfg.JailerCfg == nil {
@@ -147,6 +227,13 @@ func createAndStartVM(ctx context.Context, config *nexmodels.NodeConfiguration,
 		return nil, fmt.Errorf("failed creating machine: %s", err)
 	}
 
+	// the balloon starts deflated; it is only inflated once the workload deployed to it idles
+	if config.Balloon != nil {
+		m.Handlers.FcInit = m.Handlers.FcInit.Append(
+			firecracker.NewCreateBalloonHandler(0, config.Balloon.DeflateOnOom, int64(config.Balloon.StatsIntervalSeconds)),
+		)
+	}
+
 	if err := m.Start(vmmCtx); err != nil {
 		vmmCancel()
 		return nil, fmt.Errorf("failed to start machine: %v", err)
@@ -168,17 +255,137 @@ func createAndStartVM(ctx context.Context, config *nexmodels.NodeConfiguration,
 	)
 
 	return &runningFirecracker{
-		config:         config,
-		ip:             ip,
-		log:            log,
-		machine:        m,
-		machineStarted: time.Now().UTC(),
-		vmmCancel:      vmmCancel,
-		vmmCtx:         vmmCtx,
-		vmmID:          vmmID,
+		config:            config,
+		firecrackerBinary: firecrackerBinary,
+		ip:                ip,
+		jailSlot:          jailSlot,
+		log:               log,
+		machine:           m,
+		machineStarted:    time.Now().UTC(),
+		vmmCancel:         vmmCancel,
+		vmmCtx:            vmmCtx,
+		vmmID:             vmmID,
 	}, nil
 }
 
+// Creates a VMM for the given workload and loads into it the machine snapshot written to the
+// given directory by a checkpoint, resuming the machine. The snapshot refers to the VM's ID,
+// rootfs path and guest IP, so the VM is given the same ones it had when it was snapshotted
+func restoreVM(ctx context.Context, config *nexmodels.NodeConfiguration, log *slog.Logger, vmmID string, dir string, checkpoint *MachineCheckpoint) (*runningFirecracker, error) {
+	fcCfg, err := generateFirecrackerConfig(vmmID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// host-local IPAM assigns the address passed in the IP argument; plugins not knowing the
+	// argument would otherwise refuse it
+	fcCfg.NetworkInterfaces[0].CNIConfiguration.Args = [][2]string{
+		{"IgnoreUnknown", "1"},
+		{"IP", checkpoint.GuestIP},
+	}
+
+	err = os.Rename(filepath.Join(dir, checkpointRootfsFile), *fcCfg.Drives[0].PathOnHost)
+	if err != nil {
+		err = copy(filepath.Join(dir, checkpointRootfsFile), *fcCfg.Drives[0].PathOnHost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to place restored rootfs: %s", err)
+		}
+	}
+
+	if config.Volumes != nil {
+		err = createVolumePlaceholder(getVolumePlaceholderPath(vmmID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create volume placeholder: %s", err)
+		}
+	}
+
+	firecrackerBinary, err := findFirecracker()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := firecracker.VMCommandBuilder{}.
+		WithBin(firecrackerBinary).
+		WithSocketPath(fcCfg.SocketPath).
+		WithStderr(os.Stderr).
+		Build(ctx)
+
+	machineOpts := []firecracker.Opt{
+		firecracker.WithLogger(log.With(slog.Bool("firecracker", true), slog.String("vmmid", vmmID))),
+		firecracker.WithProcessRunner(cmd),
+		firecracker.WithSnapshot(
+			filepath.Join(dir, checkpointMemoryFile),
+			filepath.Join(dir, checkpointStateFile),
+			func(snapshot *firecracker.SnapshotConfig) {
+				snapshot.ResumeVM = true
+			},
+		),
+	}
+
+	vmmCtx, vmmCancel := context.WithCancel(ctx)
+
+	m, err := firecracker.NewMachine(vmmCtx, fcCfg, machineOpts...)
+	if err != nil {
+		vmmCancel()
+		return nil, fmt.Errorf("failed creating machine: %s", err)
+	}
+
+	if err := m.Start(vmmCtx); err != nil {
+		vmmCancel()
+		return nil, fmt.Errorf("failed to restore machine: %v", err)
+	}
+
+	vm := &runningFirecracker{
+		config:            config,
+		firecrackerBinary: firecrackerBinary,
+		ip:                m.Cfg.NetworkInterfaces[0].StaticConfiguration.IPConfiguration.IPAddr.IP,
+		jailSlot:          -1,
+		log:               log,
+		machine:           m,
+		machineStarted:    time.Now().UTC(),
+		vmmCancel:         vmmCancel,
+		vmmCtx:            vmmCtx,
+		vmmID:             vmmID,
+	}
+
+	if vm.ip.String() != checkpoint.GuestIP {
+		vm.shutdown()
+		return nil, fmt.Errorf("restored machine was assigned %s instead of its guest IP %s", vm.ip, checkpoint.GuestIP)
+	}
+
+	log.Info("Machine restored",
+		slog.String("vmid", vmmID),
+		slog.Any("ip", vm.ip),
+		slog.String("hosttap", m.Cfg.NetworkInterfaces[0].StaticConfiguration.HostDevName),
+	)
+
+	return vm, nil
+}
+
+// Locates the firecracker binary on the path
+func findFirecracker() (string, error) {
+	firecrackerBinary, err := exec.LookPath("firecracker")
+	if err != nil {
+		return "", err
+	}
+
+	finfo, err := os.Stat(firecrackerBinary)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("binary %q does not exist: %v", firecrackerBinary, err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to stat binary, %q: %v", firecrackerBinary, err)
+	}
+
+	if finfo.IsDir() {
+		return "", fmt.Errorf("binary, %q, is a directory", firecrackerBinary)
+	} else if finfo.Mode()&0111 == 0 {
+		return "", fmt.Errorf("binary, %q, is not executable. Check permissions of binary", firecrackerBinary)
+	}
+
+	return firecrackerBinary, nil
+}
+
 func copy(src string, dst string) error {
 	data, err := os.ReadFile(src)
 	if err != nil {
@@ -188,11 +395,20 @@ func copy(src string, dst string) error {
 	return err
 }
 
+func createVolumePlaceholder(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(f.Truncate(volumePlaceholderBytes), f.Close())
+}
+
 func generateFirecrackerConfig(id string, config *nexmodels.NodeConfiguration) (firecracker.Config, error) {
 	socket := getSocketPath(id)
-	rootPath := getRootFsPath(id)
+	rootPath := getRootFsPath(id, config)
 
-	return firecracker.Config{
+	fcCfg := firecracker.Config{
 		Drives: []models.Drive{{
 			DriveID:      firecracker.String("1"),
 			PathOnHost:   &rootPath,
@@ -215,6 +431,7 @@ func generateFirecrackerConfig(id string, config *nexmodels.NodeConfiguration) (
 		ForwardSignals:  make([]os.Signal, 0),
 		KernelImagePath: config.KernelFilepath,
 		LogPath:         fmt.Sprintf("%s.log", socket),
+		MetricsPath:     getMetricsPath(id),
 		NetworkInterfaces: []firecracker.NetworkInterface{{
 			AllowMMDS: true,
 			// Use CNI to get dynamic IP
@@ -232,7 +449,19 @@ func generateFirecrackerConfig(id string, config *nexmodels.NodeConfiguration) (
 		},
 		MmdsVersion: firecracker.MMDSv2,
 		SocketPath:  socket,
-	}, nil
+	}
+
+	if config.Volumes != nil {
+		placeholder := getVolumePlaceholderPath(id)
+		fcCfg.Drives = append(fcCfg.Drives, models.Drive{
+			DriveID:      firecracker.String(volumeDriveID),
+			PathOnHost:   &placeholder,
+			IsRootDevice: firecracker.Bool(false),
+			IsReadOnly:   firecracker.Bool(false),
+		})
+	}
+
+	return fcCfg, nil
 }
 
 func getLogPath(vmmID string) string {
@@ -248,13 +477,35 @@ func getLogPath(vmmID string) string {
 	return filepath.Join(dir, filename)
 }
 
-func getRootFsPath(vmmID string) string {
+func getMetricsPath(vmmID string) string {
+	filename := strings.Join([]string{
+		".firecracker.sock",
+		strconv.Itoa(os.Getpid()),
+		fmt.Sprintf("%s.metrics", vmmID),
+	},
+		"-",
+	)
+	dir := os.TempDir()
+
+	return filepath.Join(dir, filename)
+}
+
+func getRootFsPath(vmmID string, config *nexmodels.NodeConfiguration) string {
 	filename := fmt.Sprintf("rootfs-%s.ext4", vmmID)
 	dir := os.TempDir()
 
+	// jailed rootfs copies are hard linked into the chroot, so they must share its filesystem
+	if config.Jailer != nil {
+		dir = jailerChrootBaseDir(config.Jailer)
+	}
+
 	return filepath.Join(dir, filename)
 }
 
+func getVolumePlaceholderPath(vmmID string) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("volume-%s.img", vmmID))
+}
+
 func getSocketPath(vmmID string) string {
 	filename := strings.Join([]string{
 		".firecracker.sock",
@@ -0,0 +1,160 @@
+//go:build linux
+
+package processmanager
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const (
+	cgroupRoot          = "/sys/fs/cgroup"
+	cgroupCPUPeriod     = 100000
+	cgroupRemoveRetries = 10
+)
+
+// A cgroup v2 directory holding a single spawned agent and any workload processes it starts,
+// limited to the CPU and memory of the node's machine template
+type agentCgroup struct {
+	path string
+}
+
+// Creates the cgroup for the given workload beneath the configured parent, enabling the cpu and
+// memory controllers along the way and applying the machine template's limits
+func newAgentCgroup(config *models.NodeConfiguration, workloadID string) (*agentCgroup, error) {
+	_, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	if err != nil {
+		return nil, errors.New("cgroup v2 is not mounted")
+	}
+
+	parent := config.CgroupParent
+	if parent == "" {
+		parent = models.DefaultCgroupParent
+	}
+	if !strings.HasPrefix(filepath.Clean(parent), cgroupRoot+"/") {
+		return nil, fmt.Errorf("cgroup parent %s is not beneath %s", parent, cgroupRoot)
+	}
+
+	err = os.MkdirAll(parent, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cgroup parent: %s", err)
+	}
+
+	// controllers must be delegated by every ancestor for the agent's cgroup to use them
+	dir := cgroupRoot
+	for _, name := range strings.Split(strings.TrimPrefix(filepath.Clean(parent), cgroupRoot+"/"), "/") {
+		err = writeCgroupFile(dir, "cgroup.subtree_control", "+cpu +memory")
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(dir, name)
+	}
+	err = writeCgroupFile(parent, "cgroup.subtree_control", "+cpu +memory")
+	if err != nil {
+		return nil, err
+	}
+
+	cg := &agentCgroup{path: filepath.Join(parent, fmt.Sprintf("agent-%s", workloadID))}
+	err = os.Mkdir(cg.path, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create agent cgroup: %s", err)
+	}
+
+	if config.MachineTemplate.MemSizeMib != nil {
+		err = writeCgroupFile(cg.path, "memory.max", strconv.Itoa(*config.MachineTemplate.MemSizeMib*1024*1024))
+		if err != nil {
+			_ = cg.remove()
+			return nil, err
+		}
+	}
+
+	if config.MachineTemplate.VcpuCount != nil {
+		err = writeCgroupFile(cg.path, "cpu.max", fmt.Sprintf("%d %d", *config.MachineTemplate.VcpuCount*cgroupCPUPeriod, cgroupCPUPeriod))
+		if err != nil {
+			_ = cg.remove()
+			return nil, err
+		}
+	}
+
+	return cg, nil
+}
+
+// Opens the cgroup directory so a child process can be started directly inside it
+func (c *agentCgroup) open() (*os.File, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	return os.Open(c.path)
+}
+
+// Reads the CPU time and memory charged to every process in the cgroup
+func (c *agentCgroup) stats() (*MachineStats, error) {
+	if c == nil {
+		return nil, errors.New("agent is not in a cgroup")
+	}
+
+	f, err := os.Open(filepath.Join(c.path, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stats := &MachineStats{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			usec, _ := strconv.ParseInt(fields[1], 10, 64)
+			stats.CPUTimeMillis = usec / 1000
+			break
+		}
+	}
+
+	raw, err := os.ReadFile(filepath.Join(c.path, "memory.current"))
+	if err != nil {
+		return nil, err
+	}
+	stats.MemoryRSSBytes, _ = strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+
+	return stats, nil
+}
+
+// Kills any processes left in the cgroup and removes it. The kernel releases an emptied cgroup
+// asynchronously, so removal is retried briefly
+func (c *agentCgroup) remove() error {
+	if c == nil {
+		return nil
+	}
+
+	// cgroup.kill is unavailable before Linux 5.14, in which case stragglers keep the cgroup busy
+	_ = writeCgroupFile(c.path, "cgroup.kill", "1")
+
+	var err error
+	for i := 0; i < cgroupRemoveRetries; i++ {
+		err = os.Remove(c.path)
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		time.Sleep(runloopSleepInterval)
+	}
+
+	return fmt.Errorf("failed to remove agent cgroup: %s", err)
+}
+
+func writeCgroupFile(dir string, name string, value string) error {
+	err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write %s in %s: %s", name, dir, err)
+	}
+
+	return nil
+}
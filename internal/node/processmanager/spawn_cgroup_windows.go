@@ -0,0 +1,29 @@
+//go:build windows
+
+package processmanager
+
+import (
+	"errors"
+	"os"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+// Agents are not placed in cgroups on windows
+type agentCgroup struct{}
+
+func newAgentCgroup(_ *models.NodeConfiguration, _ string) (*agentCgroup, error) {
+	return nil, errors.New("cgroups are not supported on windows")
+}
+
+func (c *agentCgroup) open() (*os.File, error) {
+	return nil, nil
+}
+
+func (c *agentCgroup) stats() (*MachineStats, error) {
+	return nil, errors.New("agent is not in a cgroup")
+}
+
+func (c *agentCgroup) remove() error {
+	return nil
+}
@@ -2,6 +2,7 @@ package processmanager
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/rs/xid"
+	controlapi "github.com/synadia-io/nex/control-api"
 	agentapi "github.com/synadia-io/nex/internal/agent-api"
 	"github.com/synadia-io/nex/internal/models"
 	internalnats "github.com/synadia-io/nex/internal/node/internal-nats"
@@ -32,18 +34,24 @@ type SpawningProcessManager struct {
 	t           *observability.Telemetry
 
 	liveProcs map[string]*spawnedProcess
-	warmProcs chan *spawnedProcess
+	warmProcs *warmPools[*spawnedProcess]
 	intNats   *internalnats.InternalNatsServer
 
 	delegate       ProcessDelegate
 	deployRequests map[string]*agentapi.DeployRequest
+	// Guards deployRequests, which concurrent deployments prepare while workloads stop
+	requestsMutex sync.RWMutex
+
+	cgroupWarning sync.Once
 
 	log *slog.Logger
 }
 
 type spawnedProcess struct {
+	cgroup          *agentCgroup
 	cmd             *exec.Cmd
 	deployRequest   *agentapi.DeployRequest
+	pool            controlapi.NexWorkload
 	workloadStarted time.Time
 
 	ID string
@@ -73,7 +81,7 @@ func NewSpawningProcessManager(
 
 		deployRequests: make(map[string]*agentapi.DeployRequest),
 		liveProcs:      make(map[string]*spawnedProcess),
-		warmProcs:      make(chan *spawnedProcess, config.MachinePoolSize),
+		warmProcs:      newWarmPools(config, func(proc *spawnedProcess) string { return proc.ID }),
 	}, nil
 }
 
@@ -90,6 +98,9 @@ func (s *SpawningProcessManager) ListProcesses() ([]ProcessInfo, error) {
 				Namespace:     *proc.deployRequest.Namespace,
 				DeployRequest: proc.deployRequest,
 			}
+			if proc.cmd.Process != nil {
+				pinfo.Pid = proc.cmd.Process.Pid
+			}
 			pinfos = append(pinfos, pinfo)
 		}
 	}
@@ -99,6 +110,9 @@ func (s *SpawningProcessManager) ListProcesses() ([]ProcessInfo, error) {
 
 func (s *SpawningProcessManager) EnterLameDuck() error {
 	nope := false
+	s.requestsMutex.RLock()
+	defer s.requestsMutex.RUnlock()
+
 	for _, req := range s.deployRequests {
 		req.Essential = &nope
 	}
@@ -106,21 +120,22 @@ func (s *SpawningProcessManager) EnterLameDuck() error {
 	return nil
 }
 
-// Attaches a deployment request to a running process. Until a process is prepared, it's just an empty agent
+// Attaches a deployment request to a running process, taking it from the warm pool serving the
+// request's workload type. Until a process is prepared, it's just an empty agent
 func (s *SpawningProcessManager) PrepareWorkload(workloadID string, deployRequest *agentapi.DeployRequest) error {
-	select {
-	case proc := <-s.warmProcs:
-		if proc == nil {
-			return fmt.Errorf("could not prepare workload, no agent process")
-		}
-		proc.deployRequest = deployRequest
-		proc.workloadStarted = time.Now().UTC()
-
-		s.deployRequests[proc.ID] = deployRequest
-	case <-time.After(500 * time.Millisecond):
-		return fmt.Errorf("timed out waiting for available agent process")
+	pool := s.config.AgentPool(deployRequest.WorkloadType)
+	proc, ok := s.warmProcs.take(pool, workloadID)
+	if !ok || proc == nil {
+		return fmt.Errorf("could not prepare workload, agent process %s is not available in the %s agent pool", workloadID, poolName(pool))
 	}
 
+	proc.deployRequest = deployRequest
+	proc.workloadStarted = time.Now().UTC()
+
+	s.requestsMutex.Lock()
+	s.deployRequests[proc.ID] = deployRequest
+	s.requestsMutex.Unlock()
+
 	return nil
 }
 
@@ -153,27 +168,31 @@ func (s *SpawningProcessManager) Start(delegate ProcessDelegate) error {
 		case <-s.ctx.Done():
 			return nil
 		default:
-			if len(s.warmProcs) == s.config.MachinePoolSize {
+			pool, ok := s.warmProcs.nextToFill()
+			if !ok {
 				time.Sleep(runloopSleepInterval)
 				continue
 			}
 
 			p, err := s.spawn()
 			if err != nil {
-				s.log.Error("Failed to spawn nex-agent for pool", slog.Any("error", err))
+				s.log.Error("Failed to spawn nex-agent for pool", slog.String("pool", poolName(pool)), slog.Any("error", err))
 				time.Sleep(runloopSleepInterval)
 				continue
 			}
 
+			p.pool = pool
 			s.liveProcs[p.ID] = p
 			s.stopMutexes[p.ID] = &sync.Mutex{}
 
-			go s.delegate.OnProcessStarted(p.ID)
-
 			s.log.Info("Adding new agent process to warm pool",
-				slog.String("workload_id", p.ID))
+				slog.String("workload_id", p.ID),
+				slog.String("pool", poolName(pool)))
 
-			s.warmProcs <- p // If the pool is full, this line will block until a slot is available.
+			// the process is pooled before it can be reserved, so preparing it always finds it
+			s.warmProcs.add(pool, p)
+
+			go s.delegate.OnProcessStarted(p.ID, pool)
 		}
 	}
 
@@ -187,7 +206,9 @@ func (s *SpawningProcessManager) StopProcess(workloadID string) error {
 		return fmt.Errorf("failed to stop process %s. No such process", workloadID)
 	}
 
+	s.requestsMutex.Lock()
 	delete(s.deployRequests, workloadID)
+	s.requestsMutex.Unlock()
 
 	mutex := s.stopMutexes[workloadID]
 	mutex.Lock()
@@ -203,12 +224,20 @@ func (s *SpawningProcessManager) StopProcess(workloadID string) error {
 	delete(s.liveProcs, workloadID)
 	delete(s.stopMutexes, workloadID)
 
+	if proc.deployRequest == nil && !s.stopping() {
+		// removing a stopped, unprepared process from its warm pool lets the spawn loop replace it
+		s.warmProcs.take(proc.pool, workloadID)
+	}
+
 	return nil
 }
 
 // Looks up an agent process. A non-existent agent process returns (nil, nil), not
 // an error
 func (s *SpawningProcessManager) Lookup(workloadID string) (*agentapi.DeployRequest, error) {
+	s.requestsMutex.RLock()
+	defer s.requestsMutex.RUnlock()
+
 	if request, ok := s.deployRequests[workloadID]; ok {
 		return request, nil
 	}
@@ -217,6 +246,43 @@ func (s *SpawningProcessManager) Lookup(workloadID string) (*agentapi.DeployRequ
 	return nil, nil
 }
 
+// Samples the CPU time and memory of a spawned agent process, including any workload processes
+// it started when it runs in its own cgroup. Its I/O is not separable from that of the node, so
+// it is not reported
+func (s *SpawningProcessManager) MachineStats(workloadID string) (*MachineStats, error) {
+	proc, ok := s.liveProcs[workloadID]
+	if !ok || proc.cmd.Process == nil {
+		return nil, fmt.Errorf("no agent process for workload %s", workloadID)
+	}
+
+	if proc.cgroup != nil {
+		return proc.cgroup.stats()
+	}
+
+	return readProcStats(proc.cmd.Process.Pid)
+}
+
+// Spawned agent processes cannot be paused; only their workload's trigger subscriptions can
+func (s *SpawningProcessManager) PauseProcess(workloadID string) error {
+	return errors.New("pausing agent processes requires a sandboxed node")
+}
+
+func (s *SpawningProcessManager) ResumeProcess(workloadID string) error {
+	return errors.New("pausing agent processes requires a sandboxed node")
+}
+
+func (s *SpawningProcessManager) ResizeBalloon(workloadID string, amountMib int64) error {
+	return errors.New("balloon memory reclaim requires a sandboxed node")
+}
+
+func (s *SpawningProcessManager) CheckpointProcess(workloadID string, dir string) (*MachineCheckpoint, error) {
+	return nil, errors.New("checkpoints require a sandboxed node")
+}
+
+func (s *SpawningProcessManager) RestoreProcess(workloadID string, request *agentapi.DeployRequest, dir string, checkpoint *MachineCheckpoint) error {
+	return errors.New("checkpoints require a sandboxed node")
+}
+
 // Checks if the process manager is stopping
 func (s *SpawningProcessManager) stopping() bool {
 	return (atomic.LoadUint32(&s.closing) > 0)
@@ -245,27 +311,54 @@ func (s *SpawningProcessManager) spawn() (*spawnedProcess, error) {
 
 	cmd.Stderr = &procLogEmitter{workloadID: workloadID, log: s.log.WithGroup(workloadID), stderr: true}
 	cmd.Stdout = &procLogEmitter{workloadID: workloadID, log: s.log.WithGroup(workloadID), stderr: false}
-	cmd.SysProcAttr = s.sysProcAttr()
+
+	// accounting and limits are best effort, as the node may lack permission to manage cgroups
+	cgroup, err := newAgentCgroup(s.config, workloadID)
+	if err != nil {
+		s.cgroupWarning.Do(func() {
+			s.log.Warn("Spawned agents will not be placed in cgroups; their resource usage will be neither limited nor fully accounted for", slog.Any("err", err))
+		})
+	}
+
+	cgroupDir, err := cgroup.open()
+	if err != nil {
+		_ = cgroup.remove()
+		return nil, fmt.Errorf("failed to open agent cgroup: %s", err)
+	}
+	cmd.SysProcAttr = s.sysProcAttr(cgroupDir)
 
 	newProc := &spawnedProcess{
-		ID:   workloadID,
-		cmd:  cmd,
-		log:  s.log,
-		Fail: make(chan bool),
-		Run:  make(chan bool),
-		Exit: make(chan int),
+		ID:     workloadID,
+		cgroup: cgroup,
+		cmd:    cmd,
+		log:    s.log,
+		Fail:   make(chan bool),
+		Run:    make(chan bool),
+		Exit:   make(chan int),
 	}
 
 	err = cmd.Start()
+	if cgroupDir != nil {
+		_ = cgroupDir.Close()
+	}
 	if err != nil {
+		_ = cgroup.remove()
 		s.log.Warn("Agent command failed to start", slog.Any("error", err))
 		return nil, err
 	} else if cmd.Process == nil {
+		_ = cgroup.remove()
 		s.log.Warn("Agent command failed to start")
 		return nil, fmt.Errorf("agent command failed to start")
 	}
 
 	go func() {
+		defer func() {
+			err := cgroup.remove()
+			if err != nil {
+				s.log.Warn("Failed to remove agent cgroup", slog.String("workload_id", workloadID), slog.Any("err", err))
+			}
+		}()
+
 		if err = cmd.Wait(); err != nil { // blocking until exit
 			s.log.Info("Agent command exited", slog.Int("pid", cmd.Process.Pid), slog.Any("error", err))
 			return
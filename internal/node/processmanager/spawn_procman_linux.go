@@ -25,6 +25,15 @@ func (s *SpawningProcessManager) kill(proc *spawnedProcess) error {
 	return nil
 }
 
-func (s *SpawningProcessManager) sysProcAttr() *syscall.SysProcAttr {
-	return &syscall.SysProcAttr{}
+// Returns the attributes of a spawned agent process, which is started directly inside the
+// given cgroup when one is open
+func (s *SpawningProcessManager) sysProcAttr(cgroup *os.File) *syscall.SysProcAttr {
+	if cgroup == nil {
+		return &syscall.SysProcAttr{}
+	}
+
+	return &syscall.SysProcAttr{
+		UseCgroupFD: true,
+		CgroupFD:    int(cgroup.Fd()),
+	}
 }
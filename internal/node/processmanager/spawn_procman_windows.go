@@ -37,7 +37,7 @@ func (s *SpawningProcessManager) kill(proc *spawnedProcess) error {
 	return nil
 }
 
-func (s *SpawningProcessManager) sysProcAttr() *syscall.SysProcAttr {
+func (s *SpawningProcessManager) sysProcAttr(_ *os.File) *syscall.SysProcAttr {
 	return &windows.SysProcAttr{
 		CreationFlags: windows.CREATE_NEW_PROCESS_GROUP,
 	}
@@ -0,0 +1,141 @@
+//go:build linux
+
+package processmanager
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+const flushMetricsTimeout = 2 * time.Second
+
+// The I/O counters of a single metrics flush. Firecracker reports each counter as the delta
+// since the previous flush
+type firecrackerMetrics struct {
+	Block struct {
+		ReadBytes  int64 `json:"read_bytes"`
+		WriteBytes int64 `json:"write_bytes"`
+	} `json:"block"`
+	Net struct {
+		RxBytes int64 `json:"rx_bytes_count"`
+		TxBytes int64 `json:"tx_bytes_count"`
+	} `json:"net"`
+}
+
+// Samples the resource usage of the VM: CPU time and memory of the firecracker process from
+// procfs, and block and network I/O from the metrics firecracker writes on request
+func (vm *runningFirecracker) stats() (*MachineStats, error) {
+	pid, err := vm.machine.PID()
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := readProcStats(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	if vm.machine.Cfg.MetricsPath == "" {
+		// jailed VMs write metrics inside their chroot, where they are not collected
+		return stats, nil
+	}
+
+	vm.metricsMutex.Lock()
+	defer vm.metricsMutex.Unlock()
+
+	err = vm.flushMetrics()
+	if err != nil {
+		vm.log.Debug("Failed to flush VM metrics", slog.String("vmid", vm.vmmID), slog.Any("err", err))
+	}
+
+	err = vm.accumulateMetrics(vm.machine.Cfg.MetricsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.BlockReadBytes = vm.ioTotals.BlockReadBytes
+	stats.BlockWriteBytes = vm.ioTotals.BlockWriteBytes
+	stats.NetRxBytes = vm.ioTotals.NetRxBytes
+	stats.NetTxBytes = vm.ioTotals.NetTxBytes
+	return stats, nil
+}
+
+// Asks firecracker, through its API socket, to write its current metrics to the metrics file
+func (vm *runningFirecracker) flushMetrics() error {
+	client := &http.Client{
+		Timeout: flushMetricsTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", vm.machine.Cfg.SocketPath)
+			},
+		},
+	}
+
+	req, err := http.NewRequestWithContext(vm.vmmCtx, http.MethodPut, "http://localhost/actions", bytes.NewBufferString(`{"action_type":"FlushMetrics"}`))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// Adds the counters of every flush written since the last call to the VM's running totals. A
+// partially written final line is left for the next call
+func (vm *runningFirecracker) accumulateMetrics(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Seek(vm.metricsOffset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		vm.metricsOffset += int64(len(line))
+
+		var metrics firecrackerMetrics
+		if json.Unmarshal(line, &metrics) != nil {
+			continue
+		}
+
+		vm.ioTotals.BlockReadBytes += metrics.Block.ReadBytes
+		vm.ioTotals.BlockWriteBytes += metrics.Block.WriteBytes
+		vm.ioTotals.NetRxBytes += metrics.Net.RxBytes
+		vm.ioTotals.NetTxBytes += metrics.Net.TxBytes
+	}
+}
@@ -0,0 +1,106 @@
+package processmanager
+
+import (
+	"sync"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+// How often adding an agent to a full pool checks for a free slot
+const warmPoolRetryInterval = 100 * time.Millisecond
+
+// Agents awaiting deployment, held in one channel per agent pool. Each channel is sized for its
+// pool as configured when the process manager was created, so reloads may only shrink a pool
+type warmPools[T any] struct {
+	config *models.NodeConfiguration
+	pools  map[controlapi.NexWorkload]chan T
+	id     func(T) string
+
+	// Held while an agent is taken, so that concurrent deployments don't see each other's
+	// agents out of their pool and agents being added don't fill the slots a take rotates through
+	mu sync.Mutex
+}
+
+func newWarmPools[T any](config *models.NodeConfiguration, id func(T) string) *warmPools[T] {
+	pools := make(map[controlapi.NexWorkload]chan T)
+	for _, pool := range config.AgentPools() {
+		pools[pool] = make(chan T, config.AgentPoolSize(pool))
+	}
+
+	return &warmPools[T]{
+		config: config,
+		pools:  pools,
+		id:     id,
+	}
+}
+
+// Returns the first pool holding fewer warm agents than its configured size
+func (w *warmPools[T]) nextToFill() (controlapi.NexWorkload, bool) {
+	for _, pool := range w.config.AgentPools() {
+		warm, ok := w.pools[pool]
+		if ok && len(warm) < min(w.config.AgentPoolSize(pool), cap(warm)) {
+			return pool, true
+		}
+	}
+
+	return "", false
+}
+
+// Adds a warm agent to its pool. If the pool is full, this blocks until a slot is available
+func (w *warmPools[T]) add(pool controlapi.NexWorkload, agent T) {
+	for {
+		w.mu.Lock()
+		select {
+		case w.pools[pool] <- agent:
+			w.mu.Unlock()
+			return
+		default:
+		}
+		w.mu.Unlock()
+
+		time.Sleep(warmPoolRetryInterval)
+	}
+}
+
+// Removes the warm agent with the given ID from the pool, returning false if the pool does not hold it
+func (w *warmPools[T]) take(pool controlapi.NexWorkload, id string) (T, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var taken T
+	found := false
+
+	warm := w.pools[pool]
+	for i := len(warm); i > 0; i-- {
+		select {
+		case agent := <-warm:
+			if !found && w.id(agent) == id {
+				taken = agent
+				found = true
+				continue
+			}
+			warm <- agent
+		default:
+			return taken, found
+		}
+	}
+
+	return taken, found
+}
+
+func (w *warmPools[T]) close() {
+	for _, warm := range w.pools {
+		close(warm)
+	}
+}
+
+// Names the given pool in logs and errors
+func poolName(pool controlapi.NexWorkload) string {
+	if pool == models.DefaultAgentPool {
+		return "machine"
+	}
+
+	return string(pool)
+}
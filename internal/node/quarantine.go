@@ -0,0 +1,353 @@
+package nexnode
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/events"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const (
+	defaultQuarantineMinExecutions = 10
+	defaultQuarantineWindow        = time.Minute
+
+	quarantineReasonTriggerFailures = "trigger_failures"
+	quarantineReasonCrashes         = "crashes"
+)
+
+type triggerOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+type quarantinedWorkload struct {
+	status controlapi.QuarantinedWorkload
+
+	// Retained so that lifting the quarantine can be checked against the claims that started the
+	// workload, and so that resuming a workload quarantined after crashing can redeploy it
+	request *agentapi.DeployRequest
+}
+
+// Tracks the recent trigger outcomes of each workload against the configured thresholds, and
+// the workloads quarantined for exceeding them. A nil quarantine disables the feature
+type quarantine struct {
+	mutex     sync.Mutex
+	config    *models.QuarantineConfig
+	outcomes  map[string][]triggerOutcome
+	workloads map[string]*quarantinedWorkload
+}
+
+func newQuarantine(config *models.QuarantineConfig) *quarantine {
+	if config == nil {
+		return nil
+	}
+
+	return &quarantine{
+		config:    config,
+		outcomes:  make(map[string][]triggerOutcome),
+		workloads: make(map[string]*quarantinedWorkload),
+	}
+}
+
+func (q *quarantine) window() time.Duration {
+	if q.config.WindowMillis > 0 {
+		return time.Duration(q.config.WindowMillis) * time.Millisecond
+	}
+	return defaultQuarantineWindow
+}
+
+func (q *quarantine) minExecutions() int {
+	if q.config.MinExecutions > 0 {
+		return q.config.MinExecutions
+	}
+	return defaultQuarantineMinExecutions
+}
+
+// Records a trigger execution, returning the executions and failures within the window and
+// whether they trip the failure rate threshold. A workload that trips it starts a fresh window,
+// and executions of workloads already quarantined are ignored
+func (q *quarantine) recordTrigger(workloadID string, failed bool, now time.Time) (int, int, bool) {
+	if q.config.FailureRateThreshold <= 0 {
+		return 0, 0, false
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if _, ok := q.workloads[workloadID]; ok {
+		return 0, 0, false
+	}
+
+	cutoff := now.Add(-q.window())
+	outcomes := q.outcomes[workloadID]
+	for len(outcomes) > 0 && outcomes[0].at.Before(cutoff) {
+		outcomes = outcomes[1:]
+	}
+	outcomes = append(outcomes, triggerOutcome{at: now, failed: failed})
+
+	failures := 0
+	for _, outcome := range outcomes {
+		if outcome.failed {
+			failures++
+		}
+	}
+
+	executions := len(outcomes)
+	if executions >= q.minExecutions() && float64(failures)/float64(executions) >= q.config.FailureRateThreshold {
+		delete(q.outcomes, workloadID)
+		return executions, failures, true
+	}
+
+	q.outcomes[workloadID] = outcomes
+	return executions, failures, false
+}
+
+// Reports whether an essential workload has crashed more often than allowed
+func (q *quarantine) crashedTooOften(request *agentapi.DeployRequest) bool {
+	return q.config.MaxCrashes > 0 && request.RetryCount != nil && *request.RetryCount > q.config.MaxCrashes
+}
+
+func (q *quarantine) add(workloadID string, workload *quarantinedWorkload) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.workloads[workloadID] = workload
+}
+
+// Returns the deploy request of the quarantined workload, provided it belongs to the given namespace
+func (q *quarantine) lookup(namespace string, workloadID string) (*agentapi.DeployRequest, bool) {
+	if q == nil {
+		return nil, false
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	workload, ok := q.workloads[workloadID]
+	if !ok || workload.status.Namespace != namespace {
+		return nil, false
+	}
+
+	return workload.request, true
+}
+
+// Removes and returns the quarantined workload, provided it belongs to the given namespace
+func (q *quarantine) take(namespace string, workloadID string) (*quarantinedWorkload, bool) {
+	if q == nil {
+		return nil, false
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	workload, ok := q.workloads[workloadID]
+	if !ok || workload.status.Namespace != namespace {
+		return nil, false
+	}
+
+	delete(q.workloads, workloadID)
+	return workload, true
+}
+
+func (q *quarantine) list(namespace string) []controlapi.QuarantinedWorkload {
+	workloads := make([]controlapi.QuarantinedWorkload, 0)
+	if q == nil {
+		return workloads
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, workload := range q.workloads {
+		if workload.status.Namespace == namespace {
+			workloads = append(workloads, workload.status)
+		}
+	}
+
+	sort.Slice(workloads, func(i, j int) bool {
+		return workloads[i].QuarantinedAt.Before(workloads[j].QuarantinedAt)
+	})
+
+	return workloads
+}
+
+// Forgets a stopped workload. Workloads quarantined after crashing were already stopped and
+// remain quarantined until resumed or stopped through the control API
+func (q *quarantine) stopped(workloadID string) {
+	if q == nil {
+		return
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	delete(q.outcomes, workloadID)
+	if workload, ok := q.workloads[workloadID]; ok && workload.status.Running {
+		delete(q.workloads, workloadID)
+	}
+}
+
+// Records the outcome of a trigger execution, quarantining the workload if its failure rate
+// exceeds the configured threshold
+func (w *WorkloadManager) recordTriggerOutcome(workloadID string, request *agentapi.DeployRequest, failed bool) {
+	if w.quarantine == nil {
+		return
+	}
+
+	executions, failures, trip := w.quarantine.recordTrigger(workloadID, failed, time.Now().UTC())
+	if trip {
+		w.quarantineTriggers(workloadID, request, executions, failures)
+	}
+}
+
+// Pauses a running workload's trigger subscriptions. The workload itself keeps running so that
+// it can be inspected, and its trigger worker pool is kept for when it is resumed
+func (w *WorkloadManager) quarantineTriggers(workloadID string, request *agentapi.DeployRequest, executions, failures int) {
+	w.poolMutex.Lock()
+	if _, ok := w.activeAgents[workloadID]; !ok {
+		w.poolMutex.Unlock()
+		return
+	}
+
+	subz := w.subz[workloadID]
+	delete(w.subz, workloadID)
+
+	subjects := make([]string, 0, len(subz))
+	for _, sub := range subz {
+		subjects = append(subjects, sub.Subject)
+		w.drainTriggerSubscription(workloadID, sub)
+	}
+	w.poolMutex.Unlock()
+
+	status := newQuarantinedWorkload(workloadID, request, quarantineReasonTriggerFailures)
+	status.Running = true
+	status.Executions = executions
+	status.Failures = failures
+	status.PausedSubjects = subjects
+
+	w.quarantine.add(workloadID, &quarantinedWorkload{status: status, request: request})
+	w.publishWorkloadQuarantined(status)
+}
+
+// Quarantines an essential workload that has crashed more often than allowed instead of
+// restarting it, returning whether it was quarantined
+func (w *WorkloadManager) quarantineCrashed(workloadID string, request *agentapi.DeployRequest) bool {
+	if w.quarantine == nil || !w.quarantine.crashedTooOften(request) {
+		return false
+	}
+
+	status := newQuarantinedWorkload(workloadID, request, quarantineReasonCrashes)
+	status.Crashes = *request.RetryCount
+
+	w.quarantine.add(workloadID, &quarantinedWorkload{status: status, request: request})
+	w.publishWorkloadQuarantined(status)
+	return true
+}
+
+// Returns the deploy request of a workload quarantined in the given namespace
+func (w *WorkloadManager) LookupQuarantined(namespace string, workloadID string) (*agentapi.DeployRequest, bool) {
+	return w.quarantine.lookup(namespace, workloadID)
+}
+
+// Lists the workloads quarantined in the given namespace
+func (w *WorkloadManager) QuarantinedWorkloads(namespace string) []controlapi.QuarantinedWorkload {
+	return w.quarantine.list(namespace)
+}
+
+// Lifts a workload's quarantine. A running workload is resubscribed to its paused trigger
+// subjects; a workload quarantined after crashing is redeployed with its crash count reset
+func (w *WorkloadManager) ResumeQuarantined(namespace string, workloadID string) (*controlapi.QuarantinedWorkload, error) {
+	workload, ok := w.quarantine.take(namespace, workloadID)
+	if !ok {
+		return nil, errors.New("no such quarantined workload")
+	}
+
+	if !workload.status.Running {
+		retryCount := uint(0)
+		workload.request.RetryCount = &retryCount
+		workload.request.RetriedAt = nil
+
+		_, err := w.RedeployWorkload(workload.request)
+		if err != nil {
+			w.quarantine.add(workloadID, workload)
+			return nil, fmt.Errorf("failed to redeploy quarantined workload: %s", err)
+		}
+
+		w.log.Info("Redeployed quarantined workload", slog.String("workload_id", workloadID))
+		return &workload.status, nil
+	}
+
+	err := w.resumeTriggers(workloadID, workload.status.PausedSubjects)
+	if err != nil {
+		w.quarantine.add(workloadID, workload)
+		return nil, err
+	}
+
+	w.log.Info("Resumed quarantined workload",
+		slog.String("workload_id", workloadID),
+		slog.Any("trigger_subjects", workload.status.PausedSubjects),
+	)
+	return &workload.status, nil
+}
+
+// Stops a quarantined workload, or discards one quarantined after crashing
+func (w *WorkloadManager) StopQuarantined(namespace string, workloadID string) (*controlapi.QuarantinedWorkload, error) {
+	workload, ok := w.quarantine.take(namespace, workloadID)
+	if !ok {
+		return nil, errors.New("no such quarantined workload")
+	}
+
+	if workload.status.Running {
+		err := ignoreAlreadyStopped(w.StopWorkload(workloadID, true))
+		if err != nil {
+			return nil, fmt.Errorf("failed to stop quarantined workload: %s", err)
+		}
+	}
+
+	w.log.Info("Stopped quarantined workload", slog.String("workload_id", workloadID))
+	return &workload.status, nil
+}
+
+func (w *WorkloadManager) resumeTriggers(workloadID string, subjects []string) error {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	return w.subscribeTriggers(workloadID, subjects)
+}
+
+func newQuarantinedWorkload(workloadID string, request *agentapi.DeployRequest, reason string) controlapi.QuarantinedWorkload {
+	status := controlapi.QuarantinedWorkload{
+		WorkloadId:    workloadID,
+		Reason:        reason,
+		QuarantinedAt: time.Now().UTC(),
+	}
+	if request.WorkloadName != nil {
+		status.Name = *request.WorkloadName
+	}
+	if request.Namespace != nil {
+		status.Namespace = *request.Namespace
+	}
+
+	return status
+}
+
+func (w *WorkloadManager) publishWorkloadQuarantined(status controlapi.QuarantinedWorkload) {
+	w.log.Warn("Quarantined workload",
+		slog.String("workload_id", status.WorkloadId),
+		slog.String("namespace", status.Namespace),
+		slog.String("reason", status.Reason),
+		slog.Int("executions", status.Executions),
+		slog.Int("failures", status.Failures),
+		slog.Uint64("crashes", uint64(status.Crashes)),
+	)
+
+	cloudevent := events.WorkloadQuarantined(w.publicKey, status)
+	_ = PublishCloudEvent(w.events(), status.Namespace, cloudevent, w.log)
+}
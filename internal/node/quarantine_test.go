@@ -0,0 +1,67 @@
+package nexnode
+
+import (
+	"testing"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestQuarantineTripsOnFailureRateWithinWindow(t *testing.T) {
+	q := newQuarantine(&models.QuarantineConfig{
+		FailureRateThreshold: 0.5,
+		MinExecutions:        4,
+		WindowMillis:         1000,
+	})
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, _, trip := q.recordTrigger("wl", true, now); trip {
+			t.Fatal("Expected no quarantine before the minimum number of executions")
+		}
+	}
+
+	// the failures above fall out of the window
+	later := now.Add(2 * time.Second)
+	for i := 0; i < 3; i++ {
+		if _, _, trip := q.recordTrigger("wl", false, later); trip {
+			t.Fatal("Expected successful executions not to trip the quarantine")
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, _, trip := q.recordTrigger("wl", true, later); trip {
+			t.Fatal("Expected a failure rate below the threshold not to trip the quarantine")
+		}
+	}
+
+	executions, failures, trip := q.recordTrigger("wl", true, later)
+	if !trip || executions != 6 || failures != 3 {
+		t.Fatalf("Expected 3 of 6 failures to trip the quarantine, got %d of %d (trip %v)", failures, executions, trip)
+	}
+
+	q.add("wl", &quarantinedWorkload{})
+	if _, _, trip := q.recordTrigger("wl", true, later); trip {
+		t.Fatal("Expected executions of a quarantined workload to be ignored")
+	}
+}
+
+func TestQuarantinedRequestsAreScopedToTheirNamespace(t *testing.T) {
+	q := newQuarantine(&models.QuarantineConfig{})
+
+	namespace := "team-a"
+	request := &agentapi.DeployRequest{Namespace: &namespace}
+	q.add("wl", &quarantinedWorkload{status: controlapi.QuarantinedWorkload{WorkloadId: "wl", Namespace: namespace}, request: request})
+
+	if found, ok := q.lookup("team-a", "wl"); !ok || found != request {
+		t.Fatal("Expected the quarantined workload's deploy request to be found in its namespace")
+	}
+	if _, ok := q.lookup("team-b", "wl"); ok {
+		t.Fatal("Expected the quarantined workload not to be found in another namespace")
+	}
+	if _, ok := q.lookup("team-a", "other"); ok {
+		t.Fatal("Expected an unknown workload not to be found")
+	}
+}
@@ -0,0 +1,231 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const (
+	defaultResourcesBucket = "NEX_RESOURCES"
+
+	// Resource requests are served by a single node of those managing resources
+	resourceQueueGroup = "nex-resources"
+)
+
+// Declared resources stored in a key-value bucket under {namespace}.{kind}.{id}. Every request
+// reads the bucket directly, so that versions are always those of the stored resources
+type resourceStore struct {
+	config *models.DeclaredResourcesConfig
+	kv     nats.KeyValue
+}
+
+func newResourceStore(config *models.DeclaredResourcesConfig) *resourceStore {
+	if config == nil {
+		return nil
+	}
+
+	return &resourceStore{config: config}
+}
+
+// Binds to the bucket, creating it if needed
+func (r *resourceStore) bind(nc *nats.Conn) error {
+	js, err := nc.JetStream()
+	if err != nil {
+		return err
+	}
+
+	bucket := r.config.Bucket
+	if bucket == "" {
+		bucket = defaultResourcesBucket
+	}
+
+	r.kv, err = js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		r.kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Nex declared resources",
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to bind to resources bucket %s: %s", bucket, err)
+	}
+
+	return nil
+}
+
+func resourceKey(namespace string, kind controlapi.ResourceKind, id string) string {
+	return fmt.Sprintf("%s.%s.%s", namespace, kind, id)
+}
+
+// Creates the resource when its version is zero, or replaces the stored resource when it is at
+// the resource's version, returning the resource at its new version
+func (r *resourceStore) apply(namespace string, resource controlapi.Resource) (*controlapi.Resource, error) {
+	key := resourceKey(namespace, resource.Kind, resource.ID)
+
+	existing, err := r.load(key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	switch {
+	case resource.Version == 0 && existing != nil:
+		return nil, fmt.Errorf("resource %s %s already exists at version %d", resource.Kind, resource.ID, existing.Version)
+	case resource.Version != 0 && existing == nil:
+		return nil, fmt.Errorf("no such resource: %s %s", resource.Kind, resource.ID)
+	case resource.Version != 0 && resource.Version != existing.Version:
+		return nil, fmt.Errorf("resource %s %s is at version %d, not %d", resource.Kind, resource.ID, existing.Version, resource.Version)
+	case existing != nil:
+		resource.CreatedAt = existing.CreatedAt
+	default:
+		resource.CreatedAt = now
+	}
+	resource.UpdatedAt = now
+
+	expected := resource.Version
+	resource.Version = 0 // assigned by the bucket
+
+	raw, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing == nil {
+		resource.Version, err = r.kv.Create(key, raw)
+	} else {
+		resource.Version, err = r.kv.Update(key, raw, expected)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to store resource %s %s: %s", resource.Kind, resource.ID, err)
+	}
+
+	return &resource, nil
+}
+
+// Returns the resource, or nil if it does not exist
+func (r *resourceStore) get(namespace string, kind controlapi.ResourceKind, id string) (*controlapi.Resource, error) {
+	return r.load(resourceKey(namespace, kind, id))
+}
+
+// Returns the resources of the namespace, only those of the given kind unless it is empty,
+// ordered by kind and id
+func (r *resourceStore) list(namespace string, kind controlapi.ResourceKind) ([]controlapi.Resource, error) {
+	keys, err := r.kv.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return []controlapi.Resource{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resources: %s", err)
+	}
+
+	prefix := namespace + "."
+	if kind != "" {
+		prefix = fmt.Sprintf("%s.%s.", namespace, kind)
+	}
+
+	resources := make([]controlapi.Resource, 0)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		resource, err := r.load(key)
+		if err != nil {
+			return nil, err
+		}
+		if resource != nil {
+			resources = append(resources, *resource)
+		}
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Kind != resources[j].Kind {
+			return resources[i].Kind < resources[j].Kind
+		}
+		return resources[i].ID < resources[j].ID
+	})
+
+	return resources, nil
+}
+
+// Deletes the resource, provided it is at the given version unless that is zero
+func (r *resourceStore) delete(namespace string, kind controlapi.ResourceKind, id string, version uint64) (*controlapi.Resource, error) {
+	key := resourceKey(namespace, kind, id)
+
+	resource, err := r.load(key)
+	if err != nil {
+		return nil, err
+	}
+	if resource == nil {
+		return nil, fmt.Errorf("no such resource: %s %s", kind, id)
+	}
+	if version != 0 && version != resource.Version {
+		return nil, fmt.Errorf("resource %s %s is at version %d, not %d", kind, id, resource.Version, version)
+	}
+
+	err = r.kv.Delete(key, nats.LastRevision(resource.Version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete resource %s %s: %s", kind, id, err)
+	}
+
+	return resource, nil
+}
+
+// Reads a resource straight from the bucket, returning nil if it does not exist
+func (r *resourceStore) load(key string) (*controlapi.Resource, error) {
+	entry, err := r.kv.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource %s: %s", key, err)
+	}
+
+	var resource controlapi.Resource
+	err = json.Unmarshal(entry.Value(), &resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode resource %s: %s", key, err)
+	}
+	resource.Version = entry.Revision()
+
+	return &resource, nil
+}
+
+// Describes this node and the workloads it runs in the given namespace, for comparison with
+// declared resources
+func (api *ApiListener) nodeState(namespace string) (*controlapi.NodeState, error) {
+	machines, err := api.mgr.RunningWorkloads()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &controlapi.NodeState{
+		NodeId:    api.PublicKey(),
+		Tags:      api.node.config.Tags,
+		Cordoned:  api.node.IsCordoned(),
+		Workloads: make([]controlapi.WorkloadState, 0),
+	}
+
+	for _, machine := range machines {
+		if machine.Namespace != namespace {
+			continue
+		}
+
+		state.Workloads = append(state.Workloads, controlapi.WorkloadState{
+			Id:           machine.Id,
+			Name:         machine.Workload.Name,
+			WorkloadType: machine.Workload.WorkloadType,
+			Hash:         machine.Workload.Hash,
+		})
+	}
+
+	return state, nil
+}
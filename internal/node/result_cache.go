@@ -0,0 +1,180 @@
+package nexnode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const defaultResultCacheMaxAge = time.Hour
+
+// A function response held in the result cache
+type cachedResult struct {
+	Header       nats.Header `json:"header,omitempty"`
+	Data         []byte      `json:"data"`
+	RuntimeNanos int64       `json:"runtime_ns"`
+}
+
+// Caches the responses of functions deployed with a result cache policy in a key-value bucket
+// shared with the other nodes using it. The bucket is bound on first use, and again after a
+// failure, so the node starts while JetStream is unavailable. A nil cache caches nothing
+type resultCache struct {
+	config *models.ResultCacheConfig
+	nc     *nats.Conn
+	log    *slog.Logger
+
+	mutex sync.Mutex
+	kv    nats.KeyValue
+}
+
+func newResultCache(config *models.ResultCacheConfig, nc *nats.Conn, log *slog.Logger) *resultCache {
+	if config == nil {
+		return nil
+	}
+
+	return &resultCache{
+		config: config,
+		nc:     nc,
+		log:    log,
+	}
+}
+
+// Binds to the result cache bucket, creating it if needed
+func (c *resultCache) bucket() (nats.KeyValue, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.kv != nil {
+		return c.kv, nil
+	}
+
+	js, err := c.nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := c.config.Bucket
+	if bucket == "" {
+		bucket = controlapi.DefaultResultCacheBucket
+	}
+
+	maxAge := defaultResultCacheMaxAge
+	if c.config.MaxAgeMillis > 0 {
+		maxAge = time.Duration(c.config.MaxAgeMillis) * time.Millisecond
+	}
+
+	maxBytes := int64(-1)
+	if c.config.MaxBytes > 0 {
+		maxBytes = c.config.MaxBytes
+	}
+
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "Nex function results",
+			History:     1,
+			TTL:         maxAge,
+			MaxBytes:    maxBytes,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind to result cache bucket %s: %s", bucket, err)
+	}
+
+	c.kv = kv
+	return kv, nil
+}
+
+// Reports whether the workload's responses to messages on the given subject are cached
+func (c *resultCache) caches(request *agentapi.DeployRequest, subject string) bool {
+	if c == nil || request.ResultCache == nil {
+		return false
+	}
+
+	if len(request.ResultCache.Subjects) == 0 {
+		return true
+	}
+
+	for _, pattern := range request.ResultCache.Subjects {
+		if subjectMatches(pattern, subject) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the cached response of the workload to the message, if there is one that has not
+// outlived the workload's cache TTL
+func (c *resultCache) lookup(request *agentapi.DeployRequest, msg *nats.Msg) (*cachedResult, bool) {
+	kv, err := c.bucket()
+	if err != nil {
+		c.log.Warn("Result cache is unavailable", slog.Any("err", err))
+		return nil, false
+	}
+
+	entry, err := kv.Get(resultCacheKey(request, msg))
+	if err != nil {
+		if !errors.Is(err, nats.ErrKeyNotFound) {
+			c.log.Warn("Failed to read result cache", slog.Any("err", err))
+		}
+		return nil, false
+	}
+
+	ttl := time.Duration(request.ResultCache.TTLMillis) * time.Millisecond
+	if time.Since(entry.Created()) > ttl {
+		return nil, false
+	}
+
+	var result cachedResult
+	err = json.Unmarshal(entry.Value(), &result)
+	if err != nil {
+		c.log.Warn("Discarding unreadable result cache entry", slog.String("key", entry.Key()), slog.Any("err", err))
+		return nil, false
+	}
+
+	return &result, true
+}
+
+// Caches the workload's response to the message
+func (c *resultCache) store(request *agentapi.DeployRequest, msg *nats.Msg, result *cachedResult) {
+	kv, err := c.bucket()
+	if err != nil {
+		c.log.Warn("Result cache is unavailable", slog.Any("err", err))
+		return
+	}
+
+	raw, _ := json.Marshal(result)
+	_, err = kv.Put(resultCacheKey(request, msg), raw)
+	if err != nil {
+		c.log.Warn("Failed to cache function result",
+			slog.String("workload_name", *request.WorkloadName),
+			slog.String("subject", msg.Subject),
+			slog.Any("err", err),
+		)
+	}
+}
+
+// Keys a function's response by its namespace, name and artifact, and by the subject and payload
+// of the message it answered
+func resultCacheKey(request *agentapi.DeployRequest, msg *nats.Msg) string {
+	hash := sha256.New()
+	for _, part := range []string{*request.Namespace, *request.WorkloadName, request.Hash, msg.Subject} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(msg.Data)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,120 @@
+package nexnode
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func testCachedFunction(subjects ...string) *agentapi.DeployRequest {
+	namespace := "default"
+	name := "echo"
+	return &agentapi.DeployRequest{
+		Namespace:    &namespace,
+		WorkloadName: &name,
+		Hash:         "abc123",
+		ResultCache:  &controlapi.ResultCachePolicy{TTLMillis: time.Minute.Milliseconds(), Subjects: subjects},
+	}
+}
+
+func TestResultCacheOnlyCachesPolicySubjects(t *testing.T) {
+	cache := newResultCache(&models.ResultCacheConfig{}, nil, slog.Default())
+
+	if !cache.caches(testCachedFunction(), "orders.created") {
+		t.Fatal("Expected every subject to be cached when the policy names none")
+	}
+
+	request := testCachedFunction("orders.*")
+	if !cache.caches(request, "orders.created") || cache.caches(request, "payments.created") {
+		t.Fatal("Expected only subjects matching the policy to be cached")
+	}
+
+	request.ResultCache = nil
+	if cache.caches(request, "orders.created") {
+		t.Fatal("Expected functions without a policy not to be cached")
+	}
+
+	var disabled *resultCache
+	if disabled.caches(testCachedFunction(), "orders.created") {
+		t.Fatal("Expected nothing to be cached by a node without a result cache")
+	}
+}
+
+func TestResultCacheKeyCoversArtifactSubjectAndPayload(t *testing.T) {
+	request := testCachedFunction()
+	msg := &nats.Msg{Subject: "orders.created", Data: []byte("42")}
+	key := resultCacheKey(request, msg)
+
+	if resultCacheKey(request, &nats.Msg{Subject: "orders.created", Data: []byte("42")}) != key {
+		t.Fatal("Expected the same message to have the same key")
+	}
+
+	if resultCacheKey(request, &nats.Msg{Subject: "orders.updated", Data: []byte("42")}) == key {
+		t.Fatal("Expected another subject to have another key")
+	}
+
+	if resultCacheKey(request, &nats.Msg{Subject: "orders.created", Data: []byte("43")}) == key {
+		t.Fatal("Expected another payload to have another key")
+	}
+
+	redeployed := testCachedFunction()
+	redeployed.Hash = "def456"
+	if resultCacheKey(redeployed, msg) == key {
+		t.Fatal("Expected another artifact to have another key")
+	}
+}
+
+func TestResultCacheServesStoredResultsWithinTTL(t *testing.T) {
+	ns, err := server.NewServer(&server.Options{Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %s", err)
+	}
+	ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not become ready")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect to NATS: %s", err)
+	}
+	t.Cleanup(nc.Close)
+
+	cache := newResultCache(&models.ResultCacheConfig{}, nc, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	request := testCachedFunction()
+	msg := &nats.Msg{Subject: "orders.created", Data: []byte("42")}
+
+	if _, hit := cache.lookup(request, msg); hit {
+		t.Fatal("Expected an empty cache to miss")
+	}
+
+	cache.store(request, msg, &cachedResult{
+		Header:       nats.Header{"Content-Type": []string{"text/plain"}},
+		Data:         []byte("forty-two"),
+		RuntimeNanos: 1000,
+	})
+
+	// another node sharing the bucket serves the result
+	peer := newResultCache(&models.ResultCacheConfig{}, nc, slog.Default())
+	result, hit := peer.lookup(request, msg)
+	if !hit {
+		t.Fatal("Expected the stored result to be served")
+	}
+	if string(result.Data) != "forty-two" || result.Header.Get("Content-Type") != "text/plain" || result.RuntimeNanos != 1000 {
+		t.Fatalf("Expected the stored result, got %+v", result)
+	}
+
+	request.ResultCache.TTLMillis = 1
+	time.Sleep(5 * time.Millisecond)
+	if _, hit := peer.lookup(request, msg); hit {
+		t.Fatal("Expected a result older than the workload's TTL to miss")
+	}
+}
@@ -0,0 +1,360 @@
+package nexnode
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rs/xid"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/events"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+const (
+	defaultRootfsRecycleInterval = 5 * time.Second
+
+	// Replacements are deployed onto the pool while it is being rebuilt on the new image, so
+	// each deploy is retried until a fresh agent is available
+	rootfsRedeployAttempts = 10
+	rootfsRedeployBackoff  = time.Second
+
+	agentReapReasonRootfsRollout = "rootfs_rollout"
+)
+
+// A rootfs rollout moves a node's agents onto a new image: the pending agents are recycled first
+// so the pool is rebuilt from the new image, then the running workloads selected by their
+// restart policy are replaced one at a time
+type rootfsRollout struct {
+	mu     sync.Mutex
+	status controlapi.RootfsRolloutStatus
+
+	previousRootfs      string
+	interval            time.Duration
+	recycleNonEssential bool
+
+	abort     chan struct{}
+	abortOnce sync.Once
+}
+
+func (r *rootfsRollout) snapshot() controlapi.RootfsRolloutStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.status
+}
+
+func (r *rootfsRollout) update(fn func(status *controlapi.RootfsRolloutStatus)) controlapi.RootfsRolloutStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fn(&r.status)
+	return r.status
+}
+
+func (r *rootfsRollout) aborted() bool {
+	select {
+	case <-r.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+// Reports whether a rootfs rollout replaces the given running workload. Jobs run to completion
+// on the image they started on and essential workloads are always replaced, since the node
+// would restart them anyway; other workloads are only replaced when the rollout asks for it
+func rootfsRecycles(request *agentapi.DeployRequest, recycleNonEssential bool) bool {
+	if request.WorkloadType == controlapi.NexWorkloadJob {
+		return false
+	}
+
+	return request.IsEssential() || recycleNonEssential
+}
+
+// Fetches and verifies the image described by the request, points the node at it and starts
+// recycling agents in the background. Only one rollout may run at a time
+func (n *Node) StartRootfsRollout(request *controlapi.RootfsRolloutRequest) (*controlapi.RootfsRolloutStatus, error) {
+	if n.config.NoSandbox {
+		return nil, errors.New("rootfs rollouts require a sandboxed node")
+	}
+
+	spec := models.ArtifactSpec{
+		Location: request.Location,
+		Sha256:   request.Sha256,
+		Version:  request.Version,
+	}
+	err := spec.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	n.rootfsMutex.Lock()
+	defer n.rootfsMutex.Unlock()
+
+	if n.rootfs != nil && n.rootfs.snapshot().State == controlapi.RolloutStateRunning {
+		return nil, errors.New("a rootfs rollout is already in progress")
+	}
+
+	artifacts := newArtifactManager(n.config, n.log, func() (*nats.Conn, error) {
+		return n.nc, nil
+	})
+
+	err = os.MkdirAll(artifacts.cacheDir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create artifact cache directory: %s", err)
+	}
+
+	path, err := artifacts.fetch(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rootfs: %s", err)
+	}
+
+	interval := request.RecycleInterval
+	if interval <= 0 {
+		interval = defaultRootfsRecycleInterval
+	}
+
+	rollout := &rootfsRollout{
+		status: controlapi.RootfsRolloutStatus{
+			Id:        xid.New().String(),
+			NodeId:    n.publicKey,
+			State:     controlapi.RolloutStateRunning,
+			Version:   request.Version,
+			Sha256:    spec.Sha256,
+			StartedAt: time.Now().UTC(),
+		},
+		previousRootfs:      n.config.RootFsFilepath,
+		interval:            interval,
+		recycleNonEssential: request.RecycleNonEssential,
+		abort:               make(chan struct{}),
+	}
+	n.rootfs = rollout
+
+	// agents created from here on are built from the new image
+	n.config.RootFsFilepath = path
+
+	n.log.Info("Starting rootfs rollout",
+		slog.String("rollout_id", rollout.status.Id),
+		slog.String("path", path),
+		slog.String("version", request.Version),
+	)
+
+	go n.runRootfsRollout(rollout)
+
+	status := rollout.snapshot()
+	return &status, nil
+}
+
+// Returns the status of the current or most recent rootfs rollout, if there has been one
+func (n *Node) RootfsRolloutStatus() (*controlapi.RootfsRolloutStatus, error) {
+	n.rootfsMutex.Lock()
+	defer n.rootfsMutex.Unlock()
+
+	if n.rootfs == nil {
+		return nil, errors.New("no rootfs rollout has been started on this node")
+	}
+
+	status := n.rootfs.snapshot()
+	return &status, nil
+}
+
+// Stops the running rootfs rollout from recycling further workloads and points the node back
+// at the previous image. Workloads already replaced keep running on the new image
+func (n *Node) AbortRootfsRollout() (*controlapi.RootfsRolloutStatus, error) {
+	n.rootfsMutex.Lock()
+	defer n.rootfsMutex.Unlock()
+
+	if n.rootfs == nil || n.rootfs.snapshot().State != controlapi.RolloutStateRunning {
+		return nil, errors.New("no rootfs rollout is in progress")
+	}
+
+	n.rootfs.abortOnce.Do(func() {
+		close(n.rootfs.abort)
+	})
+
+	status := n.rootfs.snapshot()
+	return &status, nil
+}
+
+func (n *Node) runRootfsRollout(rollout *rootfsRollout) {
+	recycled := n.manager.recyclePendingAgents()
+	status := rollout.update(func(status *controlapi.RootfsRolloutStatus) {
+		status.AgentsRecycled = recycled
+	})
+	_ = n.publishRootfsRollout(status, "", "")
+
+	procs, err := n.manager.procMan.ListProcesses()
+	if err != nil {
+		n.finishRootfsRollout(rollout, fmt.Sprintf("failed to list running workloads: %s", err))
+		return
+	}
+
+	candidates := make([]*agentapi.DeployRequest, 0)
+	ids := make([]string, 0)
+	for _, proc := range procs {
+		if proc.DeployRequest == nil || proc.DeployRequest.Namespace == nil {
+			continue
+		}
+
+		candidates = append(candidates, proc.DeployRequest)
+		ids = append(ids, proc.ID)
+	}
+
+	rollout.update(func(status *controlapi.RootfsRolloutStatus) {
+		status.Total = len(candidates)
+	})
+
+	for i, request := range candidates {
+		if rollout.aborted() || n.shuttingDown() {
+			break
+		}
+
+		if !rootfsRecycles(request, rollout.recycleNonEssential) {
+			rollout.update(func(status *controlapi.RootfsRolloutStatus) {
+				status.Retained++
+			})
+			continue
+		}
+
+		replacement, err := n.recycleWorkload(ids[i], request)
+		status := rollout.update(func(status *controlapi.RootfsRolloutStatus) {
+			if err != nil {
+				status.Failed++
+				status.LastError = err.Error()
+			} else {
+				status.Recycled++
+			}
+		})
+		if err != nil {
+			n.log.Warn("Failed to recycle workload onto new rootfs",
+				slog.String("rollout_id", status.Id),
+				slog.String("workload_id", ids[i]),
+				slog.Any("err", err),
+			)
+		}
+		_ = n.publishRootfsRollout(status, ids[i], replacement)
+
+		select {
+		case <-rollout.abort:
+		case <-n.ctx.Done():
+		case <-time.After(rollout.interval):
+		}
+	}
+
+	n.finishRootfsRollout(rollout, "")
+}
+
+// Replaces a running workload with one deployed from the same request, returning the ID of the
+// replacement. The original is only stopped once its replacement has been deployed, unless it
+// has a volume, which can only be attached to one of them at a time
+func (n *Node) recycleWorkload(id string, deployRequest *agentapi.DeployRequest) (string, error) {
+	request, err := redeployRequest(deployRequest)
+	if err != nil {
+		return "", err
+	}
+
+	stopFirst := deployRequest.SourceVolume != nil
+	if stopFirst {
+		err = ignoreAlreadyStopped(n.manager.StopWorkload(id, true))
+		if err != nil {
+			return "", fmt.Errorf("failed to stop recycled workload: %s", err)
+		}
+	}
+
+	var response *controlapi.RunResponse
+	for attempt := 0; attempt < rootfsRedeployAttempts && !n.shuttingDown(); attempt++ {
+		response, err = n.manager.submitRedeploy(*deployRequest.Namespace, request)
+		if err == nil {
+			break
+		}
+
+		time.Sleep(rootfsRedeployBackoff)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to deploy replacement: %s", err)
+	}
+	if response == nil {
+		return "", errors.New("node is shutting down")
+	}
+
+	if !stopFirst {
+		err = ignoreAlreadyStopped(n.manager.StopWorkload(id, true))
+		if err != nil {
+			return response.ID, fmt.Errorf("failed to stop recycled workload: %s", err)
+		}
+	}
+
+	return response.ID, nil
+}
+
+// Records the end of a rollout. An aborted rollout points the node back at the previous image
+// and recycles the pending agents built from the new one
+func (n *Node) finishRootfsRollout(rollout *rootfsRollout, failure string) {
+	aborted := rollout.aborted()
+	if aborted {
+		n.rootfsMutex.Lock()
+		n.config.RootFsFilepath = rollout.previousRootfs
+		n.rootfsMutex.Unlock()
+
+		_ = n.manager.recyclePendingAgents()
+	}
+
+	status := rollout.update(func(status *controlapi.RootfsRolloutStatus) {
+		switch {
+		case aborted:
+			status.State = controlapi.RolloutStateAborted
+		case failure != "":
+			status.State = controlapi.RolloutStateAborted
+			status.LastError = failure
+		default:
+			status.State = controlapi.RolloutStateCompleted
+			status.Retained += status.Total - status.Recycled - status.Failed - status.Retained
+		}
+
+		completedAt := time.Now().UTC()
+		status.CompletedAt = &completedAt
+	})
+
+	n.log.Info("Finished rootfs rollout",
+		slog.String("rollout_id", status.Id),
+		slog.String("state", string(status.State)),
+		slog.Int("recycled", status.Recycled),
+		slog.Int("failed", status.Failed),
+		slog.Int("retained", status.Retained),
+	)
+
+	_ = n.publishRootfsRollout(status, "", "")
+}
+
+func (n *Node) publishRootfsRollout(status controlapi.RootfsRolloutStatus, workloadID, replacementID string) error {
+	evt := controlapi.RootfsRolloutEvent{
+		RootfsRolloutStatus: status,
+		WorkloadId:          workloadID,
+		ReplacementId:       replacementID,
+	}
+
+	cloudevent := events.RootfsRollout(n.publicKey, evt)
+	return PublishCloudEvent(n.events(), systemNamespace, cloudevent, n.log)
+}
+
+// Terminates every unclaimed pending agent so the process manager replaces it, returning the
+// number of agents recycled. Agents claimed by a deployment in progress are left alone
+func (w *WorkloadManager) recyclePendingAgents() int {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	recycled := 0
+	for id := range w.pendingAgents {
+		if w.reapAgent(id, agentReapReasonRootfsRollout) {
+			recycled++
+		}
+	}
+
+	return recycled
+}
@@ -0,0 +1,30 @@
+package nexnode
+
+import (
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+func TestRootfsRolloutFollowsRestartPolicy(t *testing.T) {
+	essential := true
+
+	cases := []struct {
+		name                string
+		request             *agentapi.DeployRequest
+		recycleNonEssential bool
+		expected            bool
+	}{
+		{"essential service", &agentapi.DeployRequest{WorkloadType: controlapi.NexWorkloadNative, Essential: &essential}, false, true},
+		{"non-essential service", &agentapi.DeployRequest{WorkloadType: controlapi.NexWorkloadNative}, false, false},
+		{"non-essential service when requested", &agentapi.DeployRequest{WorkloadType: controlapi.NexWorkloadNative}, true, true},
+		{"job", &agentapi.DeployRequest{WorkloadType: controlapi.NexWorkloadJob, Essential: &essential}, true, false},
+	}
+
+	for _, tc := range cases {
+		if rootfsRecycles(tc.request, tc.recycleNonEssential) != tc.expected {
+			t.Errorf("%s: expected recycle to be %v", tc.name, tc.expected)
+		}
+	}
+}
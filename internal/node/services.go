@@ -15,6 +15,7 @@ const hostServiceHTTP = "http"
 const hostServiceKeyValue = "kv"
 const hostServiceMessaging = "messaging"
 const hostServiceObjectStore = "objectstore"
+const hostServiceCredentials = "credentials"
 
 // Host services server implements select functionality which is
 // exposed to workloads by way of the agent which makes RPC calls
@@ -89,6 +90,7 @@ func (h *HostServices) init() error {
 			} else {
 				h.log.Debug("initialized messaging host service")
 			}
+			messaging.SetLocalConnection(h.ncint, localTriggerSubjectPrefix)
 
 			err = h.server.AddService(hostServiceMessaging, messaging, messagingConfig.Configuration)
 			if err != nil {
@@ -114,6 +116,23 @@ func (h *HostServices) init() error {
 		}
 	}
 
+	if credentialsConfig, ok := h.config.Services[hostServiceCredentials]; ok {
+		if credentialsConfig.Enabled {
+			credentials, err := builtins.NewCredentialsService(h.log)
+			if err != nil {
+				h.log.Error(fmt.Sprintf("failed to initialize credentials host service: %s", err.Error()))
+				return err
+			} else {
+				h.log.Debug("initialized credentials host service")
+			}
+
+			err = h.server.AddService(hostServiceCredentials, credentials, credentialsConfig.Configuration)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	h.log.Info("Host services configured", slog.Any("services", h.server.Services()))
 	return h.server.Start()
 }
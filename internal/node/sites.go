@@ -0,0 +1,23 @@
+package nexnode
+
+import (
+	"github.com/nats-io/nats.go"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+// Returns the site the node runs in. Unless configured, nodes of an edge site are told apart by
+// the NATS server they connect to, which for a site joined to the nexus through a leaf node
+// connection is the site's own leaf node server or cluster
+func nodeSite(config *models.NodeConfiguration, nc *nats.Conn) string {
+	if config.Site != "" {
+		return config.Site
+	}
+	if nc == nil || !nc.IsConnected() {
+		return ""
+	}
+
+	if cluster := nc.ConnectedClusterName(); cluster != "" {
+		return cluster
+	}
+	return nc.ConnectedServerName()
+}
@@ -0,0 +1,157 @@
+package nexnode
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+const (
+	subscriptionDiscrepancyOrphaned = "orphaned"
+	subscriptionDiscrepancyInvalid  = "invalid"
+	subscriptionDiscrepancyDropping = "dropping"
+)
+
+// A trigger subscription whose state did not match the workloads running on the node
+type subscriptionDiscrepancy struct {
+	WorkloadID string
+	Subject    string
+	Reason     string
+}
+
+// Periodically reconciles trigger subscriptions against running workloads until the workload
+// manager stops
+func (w *WorkloadManager) runSubscriptionJanitor() {
+	interval := time.Duration(w.config.SubscriptionJanitorMillisecond) * time.Millisecond
+	if interval <= 0 {
+		w.log.Debug("Subscription janitor disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	dropped := make(map[*nats.Subscription]int)
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			if atomic.LoadUint32(&w.closing) > 0 {
+				return
+			}
+
+			w.reconcileSubscriptions(dropped)
+		}
+	}
+}
+
+// Drains the trigger subscriptions of workloads that are no longer running and forgets those the
+// server or connection has already closed, logging each discrepancy. Subscriptions that dropped
+// messages since the last pass, as reported by the client, are logged but kept. Dropped counts
+// are carried between passes in the given map
+func (w *WorkloadManager) reconcileSubscriptions(dropped map[*nats.Subscription]int) []subscriptionDiscrepancy {
+	w.poolMutex.Lock()
+	w.teardownMutex.Lock()
+	defer w.teardownMutex.Unlock()
+	defer w.poolMutex.Unlock()
+
+	discrepancies := make([]subscriptionDiscrepancy, 0)
+	seen := make(map[*nats.Subscription]struct{})
+
+	for id, subz := range w.subz {
+		if _, active := w.activeAgents[id]; !active {
+			for _, sub := range subz {
+				discrepancies = append(discrepancies, subscriptionDiscrepancy{WorkloadID: id, Subject: sub.Subject, Reason: subscriptionDiscrepancyOrphaned})
+				if sub.IsValid() {
+					w.drainTriggerSubscription(id, sub)
+				}
+			}
+
+			delete(w.subz, id)
+			if pool, ok := w.triggerPools[id]; ok {
+				delete(w.triggerPools, id)
+				go pool.stop()
+			}
+			continue
+		}
+
+		retained := make([]*nats.Subscription, 0, len(subz))
+		for _, sub := range subz {
+			if !sub.IsValid() {
+				discrepancies = append(discrepancies, subscriptionDiscrepancy{WorkloadID: id, Subject: sub.Subject, Reason: subscriptionDiscrepancyInvalid})
+				continue
+			}
+
+			seen[sub] = struct{}{}
+			retained = append(retained, sub)
+
+			count, err := sub.Dropped()
+			if err == nil && count > dropped[sub] {
+				discrepancies = append(discrepancies, subscriptionDiscrepancy{WorkloadID: id, Subject: sub.Subject, Reason: subscriptionDiscrepancyDropping})
+				dropped[sub] = count
+			}
+		}
+		w.subz[id] = retained
+	}
+
+	for sub := range dropped {
+		if _, ok := seen[sub]; !ok {
+			delete(dropped, sub)
+		}
+	}
+
+	for _, d := range discrepancies {
+		w.log.Warn("Reconciled trigger subscription discrepancy",
+			slog.String("workload_id", d.WorkloadID),
+			slog.String("subject", d.Subject),
+			slog.String("reason", d.Reason),
+		)
+	}
+
+	return discrepancies
+}
+
+// Lists the trigger subscriptions held on behalf of the running workloads in the given namespace
+func (w *WorkloadManager) TriggerSubscriptions(namespace string) []controlapi.WorkloadTriggerSubscriptions {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	workloads := make([]controlapi.WorkloadTriggerSubscriptions, 0)
+	for id, subz := range w.subz {
+		request, err := w.procMan.Lookup(id)
+		if err != nil || request == nil || request.Namespace == nil || *request.Namespace != namespace {
+			continue
+		}
+
+		summary := controlapi.WorkloadTriggerSubscriptions{
+			WorkloadId:    id,
+			Subscriptions: make([]controlapi.TriggerSubscription, 0, len(subz)),
+		}
+		if request.WorkloadName != nil {
+			summary.WorkloadName = *request.WorkloadName
+		}
+
+		for _, sub := range subz {
+			info := controlapi.TriggerSubscription{
+				Subject: sub.Subject,
+				Queue:   sub.Queue,
+				Valid:   sub.IsValid(),
+			}
+			if info.Valid {
+				info.PendingMessages, _, _ = sub.Pending()
+				info.Delivered, _ = sub.Delivered()
+				info.Dropped, _ = sub.Dropped()
+			}
+
+			summary.Subscriptions = append(summary.Subscriptions, info)
+		}
+
+		workloads = append(workloads, summary)
+	}
+
+	return workloads
+}
@@ -0,0 +1,68 @@
+package nexnode
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+func TestReconcileSubscriptionsDrainsOrphans(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Port: -1})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %s", err)
+	}
+	go s.Start()
+	defer s.Shutdown()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err)
+	}
+	defer nc.Close()
+
+	noop := func(*nats.Msg) {}
+	running, _ := nc.QueueSubscribe("orders", triggerQueueGroup("running"), noop)
+	closed, _ := nc.QueueSubscribe("payments", triggerQueueGroup("running"), noop)
+	orphan, _ := nc.QueueSubscribe("orders", triggerQueueGroup("stopped"), noop)
+	_ = closed.Unsubscribe()
+
+	w := &WorkloadManager{
+		log:          slog.Default(),
+		poolMutex:    &sync.Mutex{},
+		activeAgents: map[string]*agentapi.AgentClient{"running": {}},
+		triggerPools: make(map[string]*triggerPool),
+		subz: map[string][]*nats.Subscription{
+			"running": {running, closed},
+			"stopped": {orphan},
+		},
+	}
+
+	discrepancies := w.reconcileSubscriptions(make(map[*nats.Subscription]int))
+	if len(discrepancies) != 2 {
+		t.Fatalf("Expected an orphaned and an invalid subscription, got %+v", discrepancies)
+	}
+
+	if _, ok := w.subz["stopped"]; ok {
+		t.Fatal("Expected subscriptions of a stopped workload to be forgotten")
+	}
+	if len(w.subz["running"]) != 1 || w.subz["running"][0] != running {
+		t.Fatalf("Expected only the valid subscription of the running workload to be kept, got %v", w.subz["running"])
+	}
+
+	_ = nc.Flush()
+	deadline := time.Now().Add(2 * time.Second)
+	for orphan.IsValid() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if orphan.IsValid() {
+		t.Fatal("Expected the orphaned subscription to be drained")
+	}
+}
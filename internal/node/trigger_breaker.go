@@ -0,0 +1,233 @@
+package nexnode
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/models"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const defaultTriggerBreakerCooldown = 30 * time.Second
+
+// Circuit breaker guarding a single trigger subject of a workload. A nil breaker allows every
+// trigger
+type triggerBreaker struct {
+	mutex     sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	state    controlapi.BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	probedAt time.Time
+	rejected int64
+}
+
+// Reports whether a trigger may be forwarded to the workload. Once an open breaker's cool-down
+// has elapsed it half-opens and allows a single probe; further triggers are rejected until the
+// probe's outcome is recorded, or for another cool-down period should the probe never complete
+func (b *triggerBreaker) allow(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case controlapi.BreakerStateOpen:
+		if now.Before(b.openedAt.Add(b.cooldown)) {
+			b.rejected++
+			return false
+		}
+		b.state = controlapi.BreakerStateHalfOpen
+		b.probing = true
+		b.probedAt = now
+		return true
+	case controlapi.BreakerStateHalfOpen:
+		if b.probing && now.Before(b.probedAt.Add(b.cooldown)) {
+			b.rejected++
+			return false
+		}
+		b.probing = true
+		b.probedAt = now
+		return true
+	}
+
+	return true
+}
+
+// Records the outcome of a forwarded trigger, returning whether the failure opened the breaker.
+// Any success closes it, including that of a trigger forwarded before the breaker opened
+func (b *triggerBreaker) record(failed bool, now time.Time) bool {
+	if b == nil {
+		return false
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !failed {
+		b.state = controlapi.BreakerStateClosed
+		b.failures = 0
+		b.probing = false
+		return false
+	}
+
+	b.failures++
+
+	switch b.state {
+	case controlapi.BreakerStateHalfOpen:
+		b.probing = false
+	case controlapi.BreakerStateClosed:
+		if b.failures < b.threshold {
+			return false
+		}
+	default:
+		return false
+	}
+
+	b.state = controlapi.BreakerStateOpen
+	b.openedAt = now
+	return true
+}
+
+func (b *triggerBreaker) status(subject string) controlapi.TriggerBreakerStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	status := controlapi.TriggerBreakerStatus{
+		Subject:             subject,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		Rejected:            b.rejected,
+	}
+	if b.state != controlapi.BreakerStateClosed {
+		openedAt := b.openedAt.UTC()
+		retryAt := openedAt.Add(b.cooldown)
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+
+	return status
+}
+
+// The circuit breakers of every workload's trigger subjects, keyed by workload ID and then by
+// subject. A nil set disables circuit breaking
+type triggerBreakers struct {
+	mutex    sync.Mutex
+	config   *models.TriggerBreakerConfig
+	breakers map[string]map[string]*triggerBreaker
+}
+
+func newTriggerBreakers(config *models.TriggerBreakerConfig) *triggerBreakers {
+	if config == nil {
+		return nil
+	}
+
+	return &triggerBreakers{
+		config:   config,
+		breakers: make(map[string]map[string]*triggerBreaker),
+	}
+}
+
+// Returns the breaker of a workload's trigger subject, creating a closed one if needed
+func (t *triggerBreakers) get(workloadID string, subject string) *triggerBreaker {
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	subjects, ok := t.breakers[workloadID]
+	if !ok {
+		subjects = make(map[string]*triggerBreaker)
+		t.breakers[workloadID] = subjects
+	}
+
+	breaker, ok := subjects[subject]
+	if !ok {
+		cooldown := defaultTriggerBreakerCooldown
+		if t.config.CooldownMillis > 0 {
+			cooldown = time.Duration(t.config.CooldownMillis) * time.Millisecond
+		}
+
+		breaker = &triggerBreaker{
+			threshold: t.config.ConsecutiveFailures,
+			cooldown:  cooldown,
+			state:     controlapi.BreakerStateClosed,
+		}
+		subjects[subject] = breaker
+	}
+
+	return breaker
+}
+
+func (t *triggerBreakers) statuses(workloadID string) []controlapi.TriggerBreakerStatus {
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	statuses := make([]controlapi.TriggerBreakerStatus, 0, len(t.breakers[workloadID]))
+	for subject, breaker := range t.breakers[workloadID] {
+		statuses = append(statuses, breaker.status(subject))
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Subject < statuses[j].Subject
+	})
+
+	return statuses
+}
+
+func (t *triggerBreakers) remove(workloadID string) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.breakers, workloadID)
+}
+
+// Rejects a trigger held back by an open breaker, answering it with an error header if the node
+// is configured to, or with a service error if it was a service request
+func (w *WorkloadManager) rejectTrigger(workloadID string, tsub string, responder triggerResponder, attrs metric.MeasurementOption) {
+	w.t.FunctionTriggerBreakerRejected.Add(w.ctx, 1, attrs)
+
+	w.log.Debug("Rejected trigger execution; circuit breaker is open",
+		slog.String("workload_id", workloadID),
+		slog.String("trigger_subject", tsub),
+	)
+
+	responder.reject(controlapi.TriggerErrorCircuitOpen)
+}
+
+// Records the outcome of a trigger execution against the breaker of its trigger subject
+func (w *WorkloadManager) recordBreakerOutcome(workloadID string, tsub string, request *agentapi.DeployRequest, failed bool) {
+	if !w.breakers.get(workloadID, tsub).record(failed, time.Now()) {
+		return
+	}
+
+	w.t.FunctionTriggerBreakerOpened.Add(w.ctx, 1, metric.WithAttributes(
+		attribute.String("namespace", *request.Namespace),
+		attribute.String("workload_name", *request.WorkloadName),
+	))
+
+	w.log.Warn("Opened trigger subject circuit breaker",
+		slog.String("workload_id", workloadID),
+		slog.String("trigger_subject", tsub),
+	)
+}
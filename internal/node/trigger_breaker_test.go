@@ -0,0 +1,53 @@
+package nexnode
+
+import (
+	"testing"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestTriggerBreakerOpensAndHalfOpens(t *testing.T) {
+	breakers := newTriggerBreakers(&models.TriggerBreakerConfig{ConsecutiveFailures: 3, CooldownMillis: 1000})
+	breaker := breakers.get("wl", "foo.>")
+
+	now := time.Now()
+	breaker.record(true, now)
+	breaker.record(false, now)
+	breaker.record(true, now)
+	breaker.record(true, now)
+	if !breaker.allow(now) {
+		t.Fatal("Expected a success to reset the consecutive failures")
+	}
+
+	if !breaker.record(true, now) {
+		t.Fatal("Expected the third consecutive failure to open the breaker")
+	}
+	if breaker.allow(now.Add(500 * time.Millisecond)) {
+		t.Fatal("Expected an open breaker to reject triggers during its cool-down")
+	}
+
+	later := now.Add(1500 * time.Millisecond)
+	if !breaker.allow(later) {
+		t.Fatal("Expected the breaker to let a probe through after its cool-down")
+	}
+	if breaker.allow(later) {
+		t.Fatal("Expected a half-open breaker to allow a single probe")
+	}
+
+	if !breaker.record(true, later) {
+		t.Fatal("Expected a failed probe to reopen the breaker")
+	}
+
+	status := breakers.statuses("wl")
+	if len(status) != 1 || status[0].State != controlapi.BreakerStateOpen || status[0].Rejected != 2 {
+		t.Fatalf("Unexpected breaker status: %v", status)
+	}
+
+	breaker.allow(later.Add(2 * time.Second))
+	breaker.record(false, later.Add(2*time.Second))
+	if breakers.statuses("wl")[0].State != controlapi.BreakerStateClosed {
+		t.Fatal("Expected a successful probe to close the breaker")
+	}
+}
@@ -0,0 +1,172 @@
+package nexnode
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+const triggerCutoverConfirmTimeout = 2 * time.Second
+
+// Trigger subject subscriptions are queue subscriptions so that another workload can join the
+// group during a cutover; with a single member a queue group receives every message, exactly like
+// a plain subscription
+func triggerQueueGroup(workloadID string) string {
+	return fmt.Sprintf("nex-trigger-%s", workloadID)
+}
+
+// Moves the given trigger subject subscriptions (or all of them, when none are given) from one
+// deployed workload to another. The target workload joins the queue group of each subscription
+// being moved, so every message is delivered to exactly one of the two workloads while both are
+// subscribed. The source subscriptions are drained only once the server has confirmed the new
+// ones, allowing in-flight executions on the source workload to complete. The pool lock is not
+// held while waiting for that confirmation, so the subscriptions are swapped only if both
+// workloads still hold them afterwards
+func (w *WorkloadManager) CutoverTriggers(fromID string, toID string, subjects []string) ([]string, error) {
+	if fromID == toID {
+		return nil, fmt.Errorf("cannot cut over trigger subjects from a workload to itself")
+	}
+
+	w.poolMutex.Lock()
+	toAgent, toRequest, nc, pool, moving, err := w.prepareTriggerCutover(fromID, toID, subjects)
+	w.poolMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	added := make([]*nats.Subscription, 0, len(moving))
+	rollback := func(subz []*nats.Subscription) {
+		for _, sub := range subz {
+			_ = sub.Unsubscribe()
+		}
+	}
+
+	for _, sub := range moving {
+		newSub, err := nc.QueueSubscribe(sub.Subject, sub.Queue, w.generateTriggerHandler(toAgent, sub.Subject, toRequest, pool))
+		if err != nil {
+			rollback(added)
+			return nil, fmt.Errorf("failed to subscribe workload %s to trigger subject %s: %s", toID, sub.Subject, err)
+		}
+		added = append(added, newSub)
+	}
+
+	err = nc.FlushTimeout(triggerCutoverConfirmTimeout)
+	if err != nil {
+		rollback(added)
+		return nil, fmt.Errorf("failed to confirm trigger subject subscriptions for workload %s: %s", toID, err)
+	}
+
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	if w.activeAgents[toID] != toAgent {
+		rollback(added)
+		return nil, fmt.Errorf("workload %s stopped during the trigger subject cutover", toID)
+	}
+
+	// the source may have stopped, been paused or cut over elsewhere in the meantime, in which
+	// case the subscriptions it no longer holds are not moved
+	swapped := make([]*nats.Subscription, 0, len(moving))
+	joined := make([]*nats.Subscription, 0, len(added))
+	moved := make([]string, 0, len(moving))
+	for i, sub := range moving {
+		if !slices.Contains(w.subz[fromID], sub) {
+			rollback(added[i : i+1])
+			continue
+		}
+		swapped = append(swapped, sub)
+		joined = append(joined, added[i])
+		moved = append(moved, sub.Subject)
+	}
+
+	if len(swapped) == 0 {
+		return nil, fmt.Errorf("workload %s no longer holds the trigger subject subscriptions being cut over", fromID)
+	}
+
+	// any subscriptions the target already held on these subjects are superseded by the ones joined above
+	retained := make([]*nats.Subscription, 0, len(w.subz[toID]))
+	for _, sub := range w.subz[toID] {
+		if slices.Contains(moved, sub.Subject) {
+			w.drainTriggerSubscription(toID, sub)
+			continue
+		}
+		retained = append(retained, sub)
+	}
+	w.subz[toID] = append(retained, joined...)
+
+	w.subz[fromID] = slices.DeleteFunc(w.subz[fromID], func(sub *nats.Subscription) bool {
+		return slices.Contains(swapped, sub)
+	})
+	for _, sub := range swapped {
+		w.drainTriggerSubscription(fromID, sub)
+	}
+
+	w.log.Info("Cut over trigger subject subscriptions",
+		slog.String("from_workload_id", fromID),
+		slog.String("to_workload_id", toID),
+		slog.Any("trigger_subjects", moved),
+	)
+
+	return moved, nil
+}
+
+// Looks up what a trigger cutover needs: the target's agent, deploy request, host services
+// connection and trigger pool, and the source subscriptions being moved. Must be called with the
+// pool lock held
+func (w *WorkloadManager) prepareTriggerCutover(fromID string, toID string, subjects []string) (*agentapi.AgentClient, *agentapi.DeployRequest, *nats.Conn, *triggerPool, []*nats.Subscription, error) {
+	if _, ok := w.activeAgents[fromID]; !ok {
+		return nil, nil, nil, nil, nil, fmt.Errorf("no such workload: %s", fromID)
+	}
+	toAgent, ok := w.activeAgents[toID]
+	if !ok {
+		return nil, nil, nil, nil, nil, fmt.Errorf("no such workload: %s", toID)
+	}
+
+	toRequest, err := w.procMan.Lookup(toID)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	if toRequest.WorkloadType == controlapi.NexWorkloadOCI || toRequest.WorkloadType == controlapi.NexWorkloadJob {
+		return nil, nil, nil, nil, nil, fmt.Errorf("workload type %s does not support trigger subjects", toRequest.WorkloadType)
+	}
+
+	nc, ok := w.hostServices.server.HostServicesConnection(toID)
+	if !ok {
+		return nil, nil, nil, nil, nil, fmt.Errorf("workload %s has no host services connection", toID)
+	}
+
+	pool, ok := w.triggerPools[toID]
+	if !ok {
+		return nil, nil, nil, nil, nil, fmt.Errorf("workload %s has no trigger worker pool", toID)
+	}
+
+	moving := make([]*nats.Subscription, 0)
+	for _, sub := range w.subz[fromID] {
+		if len(subjects) == 0 || slices.Contains(subjects, sub.Subject) {
+			moving = append(moving, sub)
+		}
+	}
+
+	if len(moving) == 0 {
+		return nil, nil, nil, nil, nil, fmt.Errorf("workload %s has no matching trigger subject subscriptions", fromID)
+	}
+
+	return toAgent, toRequest, nc, pool, moving, nil
+}
+
+func (w *WorkloadManager) drainTriggerSubscription(workloadID string, sub *nats.Subscription) {
+	err := sub.Drain()
+	if err != nil {
+		w.log.Warn("failed to drain trigger subject subscription",
+			slog.String("subject", sub.Subject),
+			slog.String("workload_id", workloadID),
+			slog.String("err", err.Error()),
+		)
+	}
+}
@@ -0,0 +1,99 @@
+package nexnode
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+// Prefix of the subjects on the internal NATS server through which workloads keep triggering
+// the functions deployed on the same node while the node is disconnected from NATS. The
+// messaging host service falls back to publishing there when a workload's host services
+// connection is down
+const localTriggerSubjectPrefix = "$NEX.local.triggers"
+
+// Subscribes to the trigger messages sent through the internal NATS server
+func (w *WorkloadManager) startLocalTriggers() error {
+	_, err := w.ncint.Subscribe(localTriggerSubjectPrefix+".>", w.handleLocalTrigger)
+	return err
+}
+
+// Delivers a trigger message sent through the internal NATS server to the local function
+// workloads subscribed to its subject, as if it had arrived on their trigger subscriptions.
+// Paused, quarantined and cut over workloads are skipped, as their subscriptions are
+func (w *WorkloadManager) handleLocalTrigger(m *nats.Msg) {
+	subject := strings.TrimPrefix(m.Subject, localTriggerSubjectPrefix+".")
+
+	type localTarget struct {
+		workloadID  string
+		tsub        string
+		agentClient *agentapi.AgentClient
+		pool        *triggerPool
+	}
+	targets := make([]localTarget, 0)
+
+	w.poolMutex.Lock()
+	for workloadID, subz := range w.subz {
+		agentClient, ok := w.activeAgents[workloadID]
+		if !ok {
+			continue
+		}
+
+		pool, ok := w.triggerPools[workloadID]
+		if !ok {
+			continue
+		}
+
+		// each workload's trigger subscriptions share a queue group, so it runs once
+		for _, sub := range subz {
+			if subjectMatches(sub.Subject, subject) {
+				targets = append(targets, localTarget{workloadID, sub.Subject, agentClient, pool})
+				break
+			}
+		}
+	}
+	w.poolMutex.Unlock()
+
+	handlers := make([]nats.MsgHandler, 0, len(targets))
+	for _, target := range targets {
+		request, err := w.procMan.Lookup(target.workloadID)
+		if err != nil || request == nil {
+			continue
+		}
+		handlers = append(handlers, w.generateTriggerHandler(target.agentClient, target.tsub, request, target.pool))
+	}
+
+	if len(handlers) == 0 {
+		w.log.Debug("No local function workload is triggered by subject", slog.String("subject", subject))
+		return
+	}
+
+	for _, handler := range handlers {
+		handler(&nats.Msg{
+			Subject: subject,
+			Reply:   m.Reply,
+			Header:  m.Header,
+			Data:    m.Data,
+			Sub:     m.Sub,
+		})
+	}
+}
+
+// Reports whether the subject matches the pattern, which may contain the * and > wildcards
+func subjectMatches(pattern string, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || (token != "*" && token != subjectTokens[i]) {
+			return false
+		}
+	}
+
+	return len(patternTokens) == len(subjectTokens)
+}
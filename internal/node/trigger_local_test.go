@@ -0,0 +1,25 @@
+package nexnode
+
+import "testing"
+
+func TestSubjectMatches(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		subject  string
+		expected bool
+	}{
+		{"orders.new", "orders.new", true},
+		{"orders.*", "orders.new", true},
+		{"orders.*", "orders.new.eu", false},
+		{"orders.>", "orders.new.eu", true},
+		{"orders.>", "orders", false},
+		{"orders.new", "orders.old", false},
+		{"*.new", "orders.new", true},
+	}
+
+	for _, c := range cases {
+		if subjectMatches(c.pattern, c.subject) != c.expected {
+			t.Fatalf("Expected %s matching %s to be %t", c.pattern, c.subject, c.expected)
+		}
+	}
+}
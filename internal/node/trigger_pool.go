@@ -0,0 +1,95 @@
+package nexnode
+
+import (
+	"sync"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+// Bounded pool of workers executing a workload's trigger messages, so that NATS subscription
+// callbacks only enqueue work. When the queue is full, callbacks either wait for room, pushing
+// back on the subscription, or shed the message
+type triggerPool struct {
+	jobs chan func()
+	shed bool
+
+	// held for reading while submitting, so the jobs channel is never closed under a sender
+	mutex   sync.RWMutex
+	stopped bool
+}
+
+func newTriggerPool(workers int, queueSize int, shed bool) *triggerPool {
+	p := &triggerPool{
+		jobs: make(chan func(), queueSize),
+		shed: shed,
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+
+	return p
+}
+
+// Creates the trigger pool for a workload, applying the workload's overrides to the node's
+// defaults
+func newWorkloadTriggerPool(defaults *controlapi.TriggerWorkerPool, override *controlapi.TriggerWorkerPool) *triggerPool {
+	workers := models.DefaultTriggerWorkers
+	queueSize := models.DefaultTriggerQueueSize
+	shed := false
+
+	for _, pool := range []*controlapi.TriggerWorkerPool{defaults, override} {
+		if pool == nil {
+			continue
+		}
+		if pool.Workers > 0 {
+			workers = pool.Workers
+		}
+		if pool.QueueSize > 0 {
+			queueSize = pool.QueueSize
+		}
+		if pool.ShedWhenFull != nil {
+			shed = *pool.ShedWhenFull
+		}
+	}
+
+	return newTriggerPool(workers, queueSize, shed)
+}
+
+// Queues a job for execution, returning false if the job was shed or the pool has stopped
+func (p *triggerPool) submit(job func()) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.stopped {
+		return false
+	}
+
+	if p.shed {
+		select {
+		case p.jobs <- job:
+			return true
+		default:
+			return false
+		}
+	}
+
+	p.jobs <- job
+	return true
+}
+
+// Stops admitting jobs. Jobs already queued are still executed, after which the workers exit
+func (p *triggerPool) stop() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.stopped {
+		p.stopped = true
+		close(p.jobs)
+	}
+}
@@ -0,0 +1,37 @@
+package nexnode
+
+import (
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+func TestTriggerPoolShedsWhenQueueIsFull(t *testing.T) {
+	shed := true
+	pool := newWorkloadTriggerPool(
+		&controlapi.TriggerWorkerPool{Workers: 4, QueueSize: 8},
+		&controlapi.TriggerWorkerPool{Workers: 1, QueueSize: 1, ShedWhenFull: &shed},
+	)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	if !pool.submit(func() { close(started); <-release }) {
+		t.Fatal("Expected first job to be accepted")
+	}
+	<-started
+
+	if !pool.submit(func() {}) {
+		t.Fatal("Expected second job to be queued while the only worker is busy")
+	}
+
+	if pool.submit(func() {}) {
+		t.Fatal("Expected job arriving at a full queue to be shed")
+	}
+
+	close(release)
+	pool.stop()
+
+	if pool.submit(func() {}) {
+		t.Fatal("Expected stopped pool to reject jobs")
+	}
+}
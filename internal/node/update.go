@@ -0,0 +1,446 @@
+package nexnode
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/events"
+	"github.com/synadia-io/nex/internal/models"
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// A handoff older than this was not written for the process reading it, e.g. one left behind
+	// by an update whose exec failed, and is discarded
+	updateHandoffMaxAge = 10 * time.Minute
+
+	handoffRedeployAttempts = 10
+	handoffRedeployBackoff  = time.Second
+)
+
+// A verified binary waiting to be exec'd by the node
+type stagedUpdate struct {
+	path     string
+	sha256   string
+	signedBy string
+	version  string
+}
+
+// State handed by an updating node to the binary it execs. The seeds let the new process keep
+// the node's identity and decrypt the environments of the workloads it redeploys
+type updateHandoff struct {
+	NodeSeed  string            `json:"node_seed"`
+	XKeySeed  string            `json:"xkey_seed"`
+	Version   string            `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	Workloads []handoffWorkload `json:"workloads"`
+
+	// The xkey replaced by a rotation whose grace period had not ended
+	PreviousXKeySeed  string     `json:"previous_xkey_seed,omitempty"`
+	PreviousXKeyUntil *time.Time `json:"previous_xkey_until,omitempty"`
+}
+
+type handoffWorkload struct {
+	Namespace string                    `json:"namespace"`
+	Request   *controlapi.DeployRequest `json:"request"`
+}
+
+// Returns the path of the file through which a node hands off to the binary it execs
+func updateHandoffFilepath(config *models.NodeConfiguration) string {
+	if config.Update != nil && config.Update.HandoffFilepath != "" {
+		return config.Update.HandoffFilepath
+	}
+
+	if config.DefaultResourceDir != "" {
+		return filepath.Join(config.DefaultResourceDir, "update-handoff.json")
+	}
+
+	return filepath.Join(os.TempDir(), "nex-update-handoff.json")
+}
+
+// Returns the directory in which update binaries are staged
+func updateStagingDir(config *models.NodeConfiguration) string {
+	if config.Update != nil && config.Update.StagingDir != "" {
+		return config.Update.StagingDir
+	}
+
+	if config.DefaultResourceDir != "" {
+		return filepath.Join(config.DefaultResourceDir, "updates")
+	}
+
+	return filepath.Join(os.TempDir(), "nex-updates")
+}
+
+// Writes the handoff readable only by the node's user, as it holds the node's seeds
+func writeUpdateHandoff(path string, handoff *updateHandoff) error {
+	raw, err := json.Marshal(handoff)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".handoff-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(raw)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Reads and removes the handoff left by an updating node; a missing or stale handoff yields nil
+func takeUpdateHandoff(path string) (*updateHandoff, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume update handoff: %s", err)
+	}
+
+	var handoff updateHandoff
+	err = json.Unmarshal(raw, &handoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse update handoff: %s", err)
+	}
+
+	if time.Since(handoff.CreatedAt) > updateHandoffMaxAge {
+		return nil, nil
+	}
+
+	return &handoff, nil
+}
+
+// Fetches and verifies the binary described by an update request. Only one update may be in
+// progress at a time; a successfully staged update remains in progress until it is applied
+func (n *Node) StageUpdate(request *controlapi.NodeUpdateRequest) (*stagedUpdate, error) {
+	if n.config.Update == nil {
+		return nil, errors.New("updates are not enabled on this node")
+	}
+
+	if request.Location == "" || request.Signature == "" {
+		return nil, errors.New("update requests require a location and a signature")
+	}
+
+	err := checkUpdateVersion(request.Version, VERSION)
+	if err != nil {
+		return nil, err
+	}
+
+	if !atomic.CompareAndSwapUint32(&n.updating, 0, 1) {
+		return nil, errors.New("an update is already in progress")
+	}
+
+	staged, err := n.fetchUpdate(request)
+	if err != nil {
+		atomic.StoreUint32(&n.updating, 0)
+		return nil, err
+	}
+
+	n.log.Info("Staged node update",
+		slog.String("path", staged.path),
+		slog.String("sha256", staged.sha256),
+		slog.String("signed_by", staged.signedBy),
+		slog.String("version", staged.version),
+	)
+
+	return staged, nil
+}
+
+// Requires the target of an update to be a semantic version newer than the running one, so that
+// a validly signed older binary cannot be replayed to downgrade a node. Development builds have no
+// version to compare against and accept any semantic version
+func checkUpdateVersion(target string, running string) error {
+	target = canonicalVersion(target)
+	if target == "" {
+		return errors.New("update requests require the semantic version of the new binary")
+	}
+
+	running = canonicalVersion(running)
+	if running != "" && semver.Compare(target, running) <= 0 {
+		return fmt.Errorf("update version %s is not newer than the running version %s", target, running)
+	}
+
+	return nil
+}
+
+// Returns the version in the canonical vMAJOR.MINOR.PATCH form, or an empty string if it is not a
+// semantic version
+func canonicalVersion(version string) string {
+	if version != "" && !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+
+	return semver.Canonical(version)
+}
+
+func (n *Node) fetchUpdate(request *controlapi.NodeUpdateRequest) (*stagedUpdate, error) {
+	dir := updateStagingDir(n.config)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create update staging directory: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(n.ctx, artifactFetchTimeout)
+	defer cancel()
+
+	body, err := n.openUpdate(ctx, request.Location)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(dir, ".update-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), body)
+	tmp.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %s", request.Location, err)
+	}
+
+	digest := hash.Sum(nil)
+	checksum := hex.EncodeToString(digest)
+	if request.Sha256 != "" && !strings.EqualFold(request.Sha256, checksum) {
+		return nil, fmt.Errorf("checksum mismatch for %s; expected %s, got %s", request.Location, request.Sha256, checksum)
+	}
+
+	signedBy, err := controlapi.VerifyNodeBinarySignature(request.Version, digest, request.Signature, n.config.Update.TrustedKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("nex-%s", checksum))
+	err = os.Chmod(tmp.Name(), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stagedUpdate{
+		path:     path,
+		sha256:   checksum,
+		signedBy: signedBy,
+		version:  request.Version,
+	}, nil
+}
+
+// Opens an update binary at an http(s) URL or in a NATS object store (nats://<bucket>/<object>)
+func (n *Node) openUpdate(ctx context.Context, location string) (io.ReadCloser, error) {
+	switch {
+	case strings.HasPrefix(location, "nats://"):
+		objects := &artifactManager{nc: n.nc, log: n.log}
+		return objects.openObject(strings.TrimPrefix(location, "nats://"))
+	case strings.HasPrefix(location, "https://"), strings.HasPrefix(location, "http://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to download %s: %s", location, resp.Status)
+		}
+
+		return resp.Body, nil
+	default:
+		return nil, fmt.Errorf("unsupported update location: %s", location)
+	}
+}
+
+// Enters lame duck mode, hands the node's identity and running workloads off to the staged
+// binary and asks the run loop to restart into it
+func (n *Node) applyUpdate(staged *stagedUpdate) {
+	err := n.EnterLameDuck()
+	if err != nil {
+		n.log.Warn("Failed to enter lame duck mode before update", slog.Any("err", err))
+	}
+
+	nodeSeed, _ := n.keypair.Seed()
+	xkeySeed, _ := n.api.xkeys.key().Seed()
+
+	handoff := &updateHandoff{
+		NodeSeed:  string(nodeSeed),
+		XKeySeed:  string(xkeySeed),
+		Version:   VERSION,
+		CreatedAt: time.Now().UTC(),
+		Workloads: n.manager.handoffWorkloads(),
+	}
+	if previous, until := n.api.xkeys.previousKey(time.Now()); previous != nil {
+		previousSeed, _ := previous.Seed()
+		handoff.PreviousXKeySeed = string(previousSeed)
+		handoff.PreviousXKeyUntil = &until
+	}
+
+	err = writeUpdateHandoff(updateHandoffFilepath(n.config), handoff)
+	if err != nil {
+		n.log.Error("Failed to write update handoff; update aborted", slog.Any("err", err))
+		atomic.StoreUint32(&n.updating, 0)
+		return
+	}
+
+	_ = n.publishNodeUpdating(staged, len(handoff.Workloads))
+
+	n.restart <- staged
+}
+
+// Stops the node and replaces the process with the staged binary, keeping its arguments and
+// environment. Only returns if the exec fails, leaving the handoff for a supervisor restart
+func (n *Node) restartInto(staged *stagedUpdate) {
+	n.shutdown()
+
+	n.log.Info("Restarting into updated node binary", slog.String("path", staged.path), slog.String("version", staged.version))
+	err := execBinary(staged.path)
+	if err != nil {
+		n.log.Error("Failed to exec updated node binary", slog.String("path", staged.path), slog.Any("err", err))
+	}
+}
+
+// Adopts the identity handed off by the node process that exec'd this one
+func (n *Node) adoptHandoff(keypair nkeys.KeyPair) nkeys.KeyPair {
+	handoff, err := takeUpdateHandoff(updateHandoffFilepath(n.config))
+	if err != nil {
+		n.log.Warn("Ignoring unreadable update handoff", slog.Any("err", err))
+		return keypair
+	}
+	if handoff == nil {
+		return keypair
+	}
+
+	kp, err := nkeys.FromSeed([]byte(handoff.NodeSeed))
+	if err != nil {
+		n.log.Warn("Ignoring update handoff with invalid node seed", slog.Any("err", err))
+		return keypair
+	}
+
+	n.handoff = handoff
+	n.log.Info("Resuming from node update",
+		slog.String("previous_version", handoff.Version),
+		slog.String("version", VERSION),
+		slog.Int("workloads", len(handoff.Workloads)),
+	)
+
+	return kp
+}
+
+// Redeploys the workloads that were running when the previous process exec'd this one. Agents
+// may still be warming up, so each deploy is retried for a while
+func (n *Node) redeployHandoff() {
+	for _, workload := range n.handoff.Workloads {
+		var err error
+		for attempt := 0; attempt < handoffRedeployAttempts && !n.shuttingDown(); attempt++ {
+			var response *controlapi.RunResponse
+			response, err = n.manager.submitRedeploy(workload.Namespace, workload.Request)
+			if err == nil {
+				n.log.Info("Redeployed workload after update",
+					slog.String("namespace", workload.Namespace),
+					slog.String("workload_name", response.Name),
+					slog.String("workload_id", response.ID),
+				)
+				break
+			}
+
+			time.Sleep(handoffRedeployBackoff)
+		}
+
+		if err != nil {
+			n.log.Error("Failed to redeploy workload after update",
+				slog.String("namespace", workload.Namespace),
+				slog.Any("err", err),
+			)
+		}
+	}
+
+	n.handoff = nil
+}
+
+func (n *Node) publishNodeUpdating(staged *stagedUpdate, workloads int) error {
+	evt := controlapi.NodeUpdatingEvent{
+		Id:              n.publicKey,
+		Version:         VERSION,
+		TargetVersion:   staged.version,
+		Sha256:          staged.sha256,
+		SignedBy:        staged.signedBy,
+		PendingRedeploy: workloads,
+	}
+
+	cloudevent := events.NodeUpdating(n.publicKey, evt)
+
+	n.log.Info("Publishing node updating event")
+	return PublishCloudEvent(n.events(), systemNamespace, cloudevent, n.log)
+}
+
+// Reconstructs the deploy requests of the running workloads so a new node process can redeploy
+// them. Expired workloads are left out
+func (w *WorkloadManager) handoffWorkloads() []handoffWorkload {
+	workloads := make([]handoffWorkload, 0)
+
+	procs, err := w.procMan.ListProcesses()
+	if err != nil {
+		w.log.Warn("Failed to list workloads for update handoff", slog.Any("err", err))
+		return workloads
+	}
+
+	for _, proc := range procs {
+		if proc.DeployRequest == nil || proc.DeployRequest.Namespace == nil {
+			continue
+		}
+
+		request, err := redeployRequest(proc.DeployRequest)
+		if err != nil {
+			continue
+		}
+
+		workloads = append(workloads, handoffWorkload{
+			Namespace: *proc.DeployRequest.Namespace,
+			Request:   request,
+		})
+	}
+
+	return workloads
+}
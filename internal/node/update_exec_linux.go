@@ -0,0 +1,14 @@
+//go:build linux
+
+package nexnode
+
+import (
+	"os"
+	"syscall"
+)
+
+// Replaces the current process with the given binary, passing along the node's arguments and
+// environment
+func execBinary(path string) error {
+	return syscall.Exec(path, append([]string{path}, os.Args[1:]...), os.Environ())
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package nexnode
+
+import "errors"
+
+// Windows cannot replace a running process image, so updates are not supported
+func execBinary(path string) error {
+	return errors.New("self-update is not supported on windows")
+}
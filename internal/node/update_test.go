@@ -0,0 +1,127 @@
+package nexnode
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestStageUpdateVerifiesSignature(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho updated\n")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(binary)
+	}))
+	defer server.Close()
+
+	trusted, _ := nkeys.CreateAccount()
+	untrusted, _ := nkeys.CreateAccount()
+	trustedKey, _ := trusted.PublicKey()
+
+	n := &Node{
+		ctx: context.Background(),
+		log: slog.Default(),
+		config: &models.NodeConfiguration{
+			Update: &models.UpdateConfig{
+				TrustedKeys: []string{trustedKey},
+				StagingDir:  t.TempDir(),
+			},
+		},
+	}
+
+	_, signature, _ := controlapi.SignNodeBinary(untrusted, "1.0.0", bytes.NewReader(binary))
+	_, err := n.StageUpdate(&controlapi.NodeUpdateRequest{Location: server.URL, Signature: signature, Version: "1.0.0"})
+	if err == nil {
+		t.Fatal("Expected a binary signed by an untrusted key to be rejected")
+	}
+
+	digest, signature, _ := controlapi.SignNodeBinary(trusted, "1.0.0", bytes.NewReader(binary))
+	_, err = n.StageUpdate(&controlapi.NodeUpdateRequest{Location: server.URL, Signature: signature, Sha256: digest, Version: "1.0.1"})
+	if err == nil {
+		t.Fatal("Expected a binary signed for another version to be rejected")
+	}
+
+	staged, err := n.StageUpdate(&controlapi.NodeUpdateRequest{Location: server.URL, Signature: signature, Sha256: digest, Version: "1.0.0"})
+	if err != nil {
+		t.Fatalf("Expected a binary signed by a trusted key to be staged: %s", err)
+	}
+	if staged.signedBy != trustedKey || staged.sha256 != digest {
+		t.Fatalf("Unexpected staged update: %+v", staged)
+	}
+
+	info, err := os.Stat(staged.path)
+	if err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Fatalf("Expected the staged binary to be executable: %v", err)
+	}
+
+	_, err = n.StageUpdate(&controlapi.NodeUpdateRequest{Location: server.URL, Signature: signature, Version: "1.0.0"})
+	if err == nil {
+		t.Fatal("Expected a second update to be rejected while one is in progress")
+	}
+}
+
+func TestCheckUpdateVersionRejectsDowngrades(t *testing.T) {
+	cases := []struct {
+		target  string
+		running string
+		ok      bool
+	}{
+		{"1.2.0", "1.1.9", true},
+		{"v1.2.0", "1.1.9", true},
+		{"1.1.9", "1.1.9", false},
+		{"1.1.0", "1.1.9", false},
+		{"", "1.1.9", false},
+		{"latest", "1.1.9", false},
+		{"0.1.0", "development", true},
+	}
+
+	for _, c := range cases {
+		err := checkUpdateVersion(c.target, c.running)
+		if (err == nil) != c.ok {
+			t.Errorf("Update from %q to %q: expected ok=%t, got %v", c.running, c.target, c.ok, err)
+		}
+	}
+}
+
+func TestUpdateHandoffIsConsumedOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "handoff.json")
+
+	err := writeUpdateHandoff(path, &updateHandoff{
+		NodeSeed:  "seed",
+		CreatedAt: time.Now().UTC(),
+		Workloads: []handoffWorkload{{Namespace: "default", Request: &controlapi.DeployRequest{}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to write handoff: %s", err)
+	}
+
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected the handoff to be private to the node's user, got %v", info.Mode().Perm())
+	}
+
+	handoff, err := takeUpdateHandoff(path)
+	if err != nil || handoff == nil || handoff.NodeSeed != "seed" || len(handoff.Workloads) != 1 {
+		t.Fatalf("Expected the written handoff, got %+v (%v)", handoff, err)
+	}
+
+	handoff, err = takeUpdateHandoff(path)
+	if err != nil || handoff != nil {
+		t.Fatalf("Expected the handoff to be consumed, got %+v (%v)", handoff, err)
+	}
+
+	_ = writeUpdateHandoff(path, &updateHandoff{CreatedAt: time.Now().Add(-2 * updateHandoffMaxAge)})
+	handoff, _ = takeUpdateHandoff(path)
+	if handoff != nil {
+		t.Fatal("Expected a stale handoff to be discarded")
+	}
+}
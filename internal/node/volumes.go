@@ -0,0 +1,377 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+	"github.com/synadia-io/nex/sdk"
+)
+
+const (
+	// Size of volumes requested without one, unless the node configures another
+	defaultVolumeSizeMib = 1024
+
+	// Environment variable telling a workload running without a sandbox where its volume is
+	volumePathEnv = sdk.EnvVolumePath
+
+	volumeMetadataSuffix = ".json"
+	volumeImageSuffix    = ".ext4"
+)
+
+// Metadata kept alongside each volume
+type volumeMetadata struct {
+	SizeMib   int       `json:"size_mib"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Issuer of the workload that created the volume. Volumes created before owners were
+	// recorded have none, and can only be deleted by admin keys
+	Issuer string `json:"issuer,omitempty"`
+}
+
+// Persistent volumes of the node's workloads, kept per namespace in the volumes directory. A
+// volume is attached to at most one workload at a time, and outlives it until deleted
+type volumeStore struct {
+	mutex     sync.Mutex
+	dir       string
+	sandboxed bool
+
+	defaultSizeMib int
+	maxSizeMib     int
+
+	// Workload each attached volume is attached to, keyed by namespace and volume name
+	attached map[string]string
+}
+
+// Returns the node's volume store, or nil when volumes are not enabled
+func newVolumeStore(config *models.NodeConfiguration) *volumeStore {
+	if config.Volumes == nil {
+		return nil
+	}
+
+	dir := config.Volumes.Directory
+	if dir == "" {
+		if config.DefaultResourceDir != "" {
+			dir = filepath.Join(config.DefaultResourceDir, "volumes")
+		} else {
+			dir = filepath.Join(os.TempDir(), "nex-volumes")
+		}
+	}
+
+	defaultSizeMib := config.Volumes.DefaultSizeMib
+	if defaultSizeMib == 0 {
+		defaultSizeMib = defaultVolumeSizeMib
+		if config.Volumes.MaxSizeMib > 0 && config.Volumes.MaxSizeMib < defaultSizeMib {
+			defaultSizeMib = config.Volumes.MaxSizeMib
+		}
+	}
+
+	return &volumeStore{
+		dir:            dir,
+		sandboxed:      !config.NoSandbox,
+		defaultSizeMib: defaultSizeMib,
+		maxSizeMib:     config.Volumes.MaxSizeMib,
+		attached:       make(map[string]string),
+	}
+}
+
+func volumeKey(namespace string, name string) string {
+	return namespace + "/" + name
+}
+
+// Path of the image or directory holding the volume
+func (v *volumeStore) volumePath(namespace string, name string) string {
+	if v.sandboxed {
+		return filepath.Join(v.dir, namespace, name+volumeImageSuffix)
+	}
+	return filepath.Join(v.dir, namespace, name)
+}
+
+func (v *volumeStore) metadataPath(namespace string, name string) string {
+	return filepath.Join(v.dir, namespace, name+volumeMetadataSuffix)
+}
+
+// Attaches the requested volume to the workload, creating it on behalf of the workload's issuer if
+// the node does not have it yet, and returns the path of its image or directory
+func (v *volumeStore) attach(workloadID string, namespace string, issuer string, volume *controlapi.WorkloadVolume) (string, error) {
+	if v == nil {
+		return "", errors.New("volumes are not enabled on this node")
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	key := volumeKey(namespace, volume.Name)
+	if owner, ok := v.attached[key]; ok && owner != workloadID {
+		return "", fmt.Errorf("volume %s is attached to workload %s", volume.Name, owner)
+	}
+
+	metadata, err := v.readMetadata(namespace, volume.Name)
+	if errors.Is(err, fs.ErrNotExist) {
+		metadata, err = v.create(namespace, issuer, volume)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if volume.SizeMib > metadata.SizeMib {
+		return "", fmt.Errorf("volume %s was created with %d MiB and cannot be resized to %d MiB", volume.Name, metadata.SizeMib, volume.SizeMib)
+	}
+
+	path := v.volumePath(namespace, volume.Name)
+
+	// a directory cannot be capped, so one that outgrew its size is refused instead
+	if !v.sandboxed {
+		used, err := volumeUsage(path, false)
+		if err != nil {
+			return "", err
+		}
+		if used > int64(metadata.SizeMib)*1024*1024 {
+			return "", fmt.Errorf("volume %s uses %d bytes, exceeding its size of %d MiB", volume.Name, used, metadata.SizeMib)
+		}
+	}
+
+	v.attached[key] = workloadID
+	return path, nil
+}
+
+// Detaches the volume attached to the workload, if any, keeping its contents
+func (v *volumeStore) release(workloadID string) {
+	if v == nil {
+		return
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	for key, owner := range v.attached {
+		if owner == workloadID {
+			delete(v.attached, key)
+		}
+	}
+}
+
+// Lists the volumes of the namespace, with the space their contents use
+func (v *volumeStore) list(namespace string) ([]controlapi.VolumeInfo, error) {
+	if v == nil {
+		return []controlapi.VolumeInfo{}, nil
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	entries, err := os.ReadDir(filepath.Join(v.dir, namespace))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []controlapi.VolumeInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make([]controlapi.VolumeInfo, 0)
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), volumeMetadataSuffix)
+		if !ok {
+			continue
+		}
+
+		metadata, err := v.readMetadata(namespace, name)
+		if err != nil {
+			continue
+		}
+
+		used, _ := volumeUsage(v.volumePath(namespace, name), v.sandboxed)
+		volumes = append(volumes, controlapi.VolumeInfo{
+			Name:       name,
+			Namespace:  namespace,
+			SizeMib:    metadata.SizeMib,
+			UsedBytes:  used,
+			CreatedAt:  metadata.CreatedAt,
+			WorkloadId: v.attached[volumeKey(namespace, name)],
+			Issuer:     metadata.Issuer,
+		})
+	}
+
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+
+	return volumes, nil
+}
+
+// Returns the issuer owning the volume, which is empty for volumes created before owners were
+// recorded
+func (v *volumeStore) owner(namespace string, name string) (string, error) {
+	if v == nil {
+		return "", errors.New("volumes are not enabled on this node")
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	metadata, err := v.readMetadata(namespace, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("no such volume: %s", name)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return metadata.Issuer, nil
+}
+
+// Deletes a volume that is not attached to a workload, along with its contents
+func (v *volumeStore) remove(namespace string, name string) (*controlapi.VolumeInfo, error) {
+	if v == nil {
+		return nil, errors.New("volumes are not enabled on this node")
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if owner, ok := v.attached[volumeKey(namespace, name)]; ok {
+		return nil, fmt.Errorf("volume %s is attached to workload %s", name, owner)
+	}
+
+	metadata, err := v.readMetadata(namespace, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no such volume: %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.RemoveAll(v.volumePath(namespace, name))
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Remove(v.metadataPath(namespace, name))
+	if err != nil {
+		return nil, err
+	}
+
+	return &controlapi.VolumeInfo{
+		Name:      name,
+		Namespace: namespace,
+		SizeMib:   metadata.SizeMib,
+		CreatedAt: metadata.CreatedAt,
+		Issuer:    metadata.Issuer,
+	}, nil
+}
+
+func (v *volumeStore) readMetadata(namespace string, name string) (*volumeMetadata, error) {
+	raw, err := os.ReadFile(v.metadataPath(namespace, name))
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata volumeMetadata
+	err = json.Unmarshal(raw, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata for volume %s: %s", name, err)
+	}
+
+	return &metadata, nil
+}
+
+// Creates the volume's image or directory. The metadata is written last, so that a volume
+// whose creation was interrupted is created afresh when next requested
+func (v *volumeStore) create(namespace string, issuer string, volume *controlapi.WorkloadVolume) (*volumeMetadata, error) {
+	sizeMib := volume.SizeMib
+	if sizeMib == 0 {
+		sizeMib = v.defaultSizeMib
+	}
+	if v.maxSizeMib > 0 && sizeMib > v.maxSizeMib {
+		return nil, fmt.Errorf("volume size of %d MiB exceeds the node's max volume size of %d MiB", sizeMib, v.maxSizeMib)
+	}
+
+	err := os.MkdirAll(filepath.Join(v.dir, namespace), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	path := v.volumePath(namespace, volume.Name)
+	if v.sandboxed {
+		err = createVolumeImage(path, sizeMib)
+	} else {
+		err = os.MkdirAll(path, 0755)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume %s: %s", volume.Name, err)
+	}
+
+	metadata := &volumeMetadata{SizeMib: sizeMib, CreatedAt: time.Now().UTC(), Issuer: issuer}
+	raw, _ := json.Marshal(metadata)
+	err = os.WriteFile(v.metadataPath(namespace, volume.Name), raw, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+// Creates a sparse image of the given size holding an empty ext4 filesystem
+func createVolumeImage(path string, sizeMib int) error {
+	mkfs, err := exec.LookPath("mkfs.ext4")
+	if err != nil {
+		return errors.New("'mkfs.ext4' not found in $PATH")
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = errors.Join(f.Truncate(int64(sizeMib)*1024*1024), f.Close())
+	if err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+
+	out, err := exec.Command(mkfs, "-q", "-F", path).CombinedOutput()
+	if err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// Returns the space used by the volume's contents. For an image, this is the space allocated to
+// the sparse file, which grows as the workload writes to it
+func volumeUsage(path string, image bool) (int64, error) {
+	if image {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		return allocatedBytes(info), nil
+	}
+
+	var used int64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err == nil {
+				used += info.Size()
+			}
+		}
+		return nil
+	})
+
+	return used, err
+}
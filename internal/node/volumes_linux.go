@@ -0,0 +1,16 @@
+//go:build linux
+
+package nexnode
+
+import (
+	"os"
+	"syscall"
+)
+
+// Returns the space allocated on disk to the file, which for a sparse file is less than its size
+func allocatedBytes(info os.FileInfo) int64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Blocks * 512
+	}
+	return info.Size()
+}
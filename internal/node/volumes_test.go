@@ -0,0 +1,81 @@
+package nexnode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestVolumesOutliveWorkloads(t *testing.T) {
+	config := &models.NodeConfiguration{
+		NoSandbox: true,
+		Volumes:   &models.VolumesConfig{Directory: t.TempDir(), MaxSizeMib: 10},
+	}
+	volumes := newVolumeStore(config)
+
+	volume := &controlapi.WorkloadVolume{Name: "data", SizeMib: 1}
+	path, err := volumes.attach("workload1", "default", "ISSUER", volume)
+	if err != nil {
+		t.Fatalf("Expected the volume to be created and attached: %s", err)
+	}
+
+	_, err = volumes.attach("workload2", "default", "ISSUER", volume)
+	if err == nil {
+		t.Fatalf("Expected a volume attached to one workload to be refused to another")
+	}
+
+	err = os.WriteFile(filepath.Join(path, "state"), []byte("hello"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write to volume: %s", err)
+	}
+
+	_, err = volumes.remove("default", "data")
+	if err == nil {
+		t.Fatalf("Expected an attached volume not to be deleted")
+	}
+
+	volumes.release("workload1")
+
+	list, err := volumes.list("default")
+	if err != nil {
+		t.Fatalf("Failed to list volumes: %s", err)
+	}
+	if len(list) != 1 || list[0].UsedBytes != 5 || list[0].WorkloadId != "" || list[0].Issuer != "ISSUER" {
+		t.Fatalf("Expected one detached volume created by ISSUER using 5 bytes, got %+v", list)
+	}
+
+	if owner, err := volumes.owner("default", "data"); err != nil || owner != "ISSUER" {
+		t.Fatalf("Expected the volume to be owned by the issuer that created it, got %q, %v", owner, err)
+	}
+
+	_, err = volumes.attach("workload2", "default", "ISSUER", &controlapi.WorkloadVolume{Name: "data", SizeMib: 2})
+	if err == nil {
+		t.Fatalf("Expected a volume not to be resized")
+	}
+
+	path, err = volumes.attach("workload2", "default", "ISSUER", volume)
+	if err != nil {
+		t.Fatalf("Expected the released volume to be attached to another workload: %s", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(path, "state"))
+	if err != nil || string(raw) != "hello" {
+		t.Fatalf("Expected the volume's contents to be kept, got %q, %v", raw, err)
+	}
+
+	_, err = volumes.attach("workload3", "other", "ISSUER", &controlapi.WorkloadVolume{Name: "big", SizeMib: 11})
+	if err == nil {
+		t.Fatalf("Expected a volume larger than the node's max size to be refused")
+	}
+
+	volumes.release("workload2")
+	_, err = volumes.remove("default", "data")
+	if err != nil {
+		t.Fatalf("Expected the detached volume to be deleted: %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected the volume's contents to be deleted")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package nexnode
+
+import "os"
+
+func allocatedBytes(info os.FileInfo) int64 {
+	return info.Size()
+}
@@ -0,0 +1,70 @@
+package nexnode
+
+import (
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/events"
+)
+
+// Arranges for a workload deployed with a TTL to be stopped once the TTL elapses. Stopping the
+// workload beforehand cancels the timer
+func (w *WorkloadManager) scheduleExpiry(workloadID string, request *agentapi.DeployRequest) {
+	if request.ExpiresAt == nil {
+		return
+	}
+
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	w.expiryTimers[workloadID] = time.AfterFunc(time.Until(*request.ExpiresAt), func() {
+		w.expireWorkload(workloadID)
+	})
+}
+
+func (w *WorkloadManager) expireWorkload(workloadID string) {
+	w.poolMutex.Lock()
+	_, scheduled := w.expiryTimers[workloadID]
+	delete(w.expiryTimers, workloadID)
+	w.poolMutex.Unlock()
+
+	// the workload was stopped while the timer fired, or is about to be stopped along with the node
+	if !scheduled || atomic.LoadUint32(&w.closing) > 0 {
+		return
+	}
+
+	deployRequest, err := w.LookupWorkload(workloadID)
+	if err != nil || deployRequest == nil {
+		return
+	}
+
+	w.log.Info("Workload TTL elapsed; stopping workload",
+		slog.String("workload_id", workloadID),
+		slog.String("namespace", *deployRequest.Namespace),
+		slog.Int64("ttl_ms", deployRequest.TTLMillis),
+	)
+
+	_ = w.publishWorkloadExpired(workloadID, deployRequest)
+
+	err = ignoreAlreadyStopped(w.StopWorkload(workloadID, true))
+	if err != nil {
+		w.log.Warn("Failed to stop expired workload", slog.String("workload_id", workloadID), slog.String("error", err.Error()))
+	}
+}
+
+func (w *WorkloadManager) publishWorkloadExpired(workloadID string, deployRequest *agentapi.DeployRequest) error {
+	workloadExpired := controlapi.WorkloadExpiredEvent{
+		Name:      strings.TrimSpace(deployRequest.DecodedClaims.Subject),
+		VmId:      workloadID,
+		TTLMillis: deployRequest.TTLMillis,
+		ExpiredAt: time.Now().UTC(),
+	}
+
+	cloudevent := events.WorkloadExpired(w.publicKey, workloadExpired)
+
+	return PublishCloudEvent(w.events(), *deployRequest.Namespace, cloudevent, w.log)
+}
@@ -0,0 +1,120 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go"
+	"github.com/nats-io/jwt/v2"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestScheduleExpiryOnlyForWorkloadsWithTTL(t *testing.T) {
+	w := newTestWorkloadManager(&models.NodeConfiguration{})
+	w.expiryTimers = make(map[string]*time.Timer)
+
+	w.scheduleExpiry("forever", &agentapi.DeployRequest{})
+	if len(w.expiryTimers) != 0 {
+		t.Fatal("Expected no expiry to be scheduled for a workload without a TTL")
+	}
+
+	// the node is closing, so the expired workload is left for shutdown to stop
+	atomic.StoreUint32(&w.closing, 1)
+
+	expiresAt := time.Now().Add(20 * time.Millisecond)
+	w.scheduleExpiry("ephemeral", &agentapi.DeployRequest{TTLMillis: 20, ExpiresAt: &expiresAt})
+
+	w.poolMutex.Lock()
+	_, scheduled := w.expiryTimers["ephemeral"]
+	w.poolMutex.Unlock()
+	if !scheduled {
+		t.Fatal("Expected an expiry to be scheduled for a workload with a TTL")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w.poolMutex.Lock()
+		remaining := len(w.expiryTimers)
+		w.poolMutex.Unlock()
+
+		if remaining == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the expiry to fire once the TTL elapsed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExpireWorkloadIgnoresStoppedWorkloads(t *testing.T) {
+	nc := startTestNats(t)
+	w := newTestWorkloadManager(&models.NodeConfiguration{})
+	w.expiryTimers = make(map[string]*time.Timer)
+	w.nc = nc
+
+	sub, _ := nc.SubscribeSync(fmt.Sprintf("%s.*.%s", EventSubjectPrefix, controlapi.WorkloadExpiredEventType))
+
+	// stopping the workload removes its timer before the timer's callback runs
+	w.expireWorkload("stopped")
+
+	_, err := sub.NextMsg(100 * time.Millisecond)
+	if err == nil {
+		t.Fatal("Expected no expiry event for a workload that was already stopped")
+	}
+}
+
+func TestPublishWorkloadExpired(t *testing.T) {
+	nc := startTestNats(t)
+	w := newTestWorkloadManager(&models.NodeConfiguration{})
+	w.nc = nc
+	w.publicKey = "node1"
+
+	sub, _ := nc.SubscribeSync(fmt.Sprintf("%s.default.%s", EventSubjectPrefix, controlapi.WorkloadExpiredEventType))
+
+	namespace := "default"
+	err := w.publishWorkloadExpired("w1", &agentapi.DeployRequest{
+		DecodedClaims: jwt.GenericClaims{ClaimsData: jwt.ClaimsData{Subject: "echo"}},
+		Namespace:     &namespace,
+		TTLMillis:     500,
+	})
+	if err != nil {
+		t.Fatalf("Failed to publish expiry: %s", err)
+	}
+
+	m, err := sub.NextMsg(time.Second)
+	if err != nil {
+		t.Fatalf("Expected an expiry event: %s", err)
+	}
+
+	event := cloudevents.NewEvent()
+	_ = json.Unmarshal(m.Data, &event)
+
+	var expired controlapi.WorkloadExpiredEvent
+	_ = event.DataAs(&expired)
+	if event.Source() != "node1" || expired.Name != "echo" || expired.VmId != "w1" || expired.TTLMillis != 500 {
+		t.Fatalf("Unexpected expiry event from %s: %+v", event.Source(), expired)
+	}
+}
+
+func TestRedeployCarriesRemainingTTL(t *testing.T) {
+	expiresAt := time.Now().Add(time.Minute)
+	request, err := redeployRequest(&agentapi.DeployRequest{TTLMillis: 3600000, ExpiresAt: &expiresAt})
+	if err != nil {
+		t.Fatalf("Failed to reconstruct deploy request: %s", err)
+	}
+	if request.TTLMillis <= 0 || request.TTLMillis > time.Minute.Milliseconds() {
+		t.Fatalf("Expected the replacement to run for the remaining minute, got %dms", request.TTLMillis)
+	}
+
+	expiredAt := time.Now().Add(-time.Second)
+	_, err = redeployRequest(&agentapi.DeployRequest{TTLMillis: 1000, ExpiresAt: &expiredAt})
+	if err == nil {
+		t.Fatal("Expected a workload whose TTL has elapsed not to be redeployed")
+	}
+}
@@ -0,0 +1,93 @@
+package nexnode
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+// Swaps the artifact of a running workload deployed with hot reload enabled. The new artifact is
+// verified against the requested hash, scanned and cached for the agent, which recompiles it in
+// place. The workload's deploy request then refers to the new artifact, so redeployments use it
+func (w *WorkloadManager) HotReloadWorkload(workloadID string, request *controlapi.HotReloadRequest) (string, error) {
+	deployRequest, err := w.procMan.Lookup(workloadID)
+	if err != nil {
+		return "", err
+	}
+	if deployRequest == nil {
+		return "", fmt.Errorf("no such workload: %s", workloadID)
+	}
+	if !deployRequest.HotReload {
+		return "", errors.New("workload was not deployed with hot reload enabled")
+	}
+
+	w.poolMutex.Lock()
+	agentClient, ok := w.activeAgents[workloadID]
+	w.poolMutex.Unlock()
+	if !ok {
+		return "", fmt.Errorf("no such workload: %s", workloadID)
+	}
+
+	if request.Location == nil || request.Location.Scheme != "nats" {
+		return "", fmt.Errorf("hot reload artifact location ('%s') must be a nats://BUCKET/key object store reference", request.Location)
+	}
+
+	artifact, err := w.fetchObject(request.Location, request.JsDomain, w.config.MaxArtifactBytes, nil)
+	if err != nil {
+		return "", err
+	}
+	defer artifact.remove()
+
+	if !strings.EqualFold(artifact.sha256, request.Hash) {
+		return "", fmt.Errorf("artifact has sha256 %s; expected %s", artifact.sha256, request.Hash)
+	}
+
+	workload, err := artifact.bytes()
+	if err != nil {
+		return "", err
+	}
+
+	err = w.scanWorkload(*deployRequest.Namespace, &controlapi.DeployRequest{
+		WorkloadType:  deployRequest.WorkloadType,
+		Location:      request.Location,
+		JsDomain:      request.JsDomain,
+		DecodedClaims: deployRequest.DecodedClaims,
+	}, workload, artifact.sha256)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := agentClient.Cipher().Seal(workload)
+	if err != nil {
+		return "", fmt.Errorf("failed to seal workload bytes: %s", err)
+	}
+
+	err = w.natsint.StoreFileForID(workloadID, sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to cache workload bytes: %s", err)
+	}
+
+	err = agentClient.HotReload(artifact.sha256)
+	if err != nil {
+		return "", err
+	}
+
+	deployRequest.Hash = artifact.sha256
+	deployRequest.Location = request.Location
+	deployRequest.TotalBytes = artifact.size
+	if request.JsDomain != nil {
+		deployRequest.JsDomain = request.JsDomain
+	}
+
+	w.log.Info("Hot reloaded workload",
+		slog.String("workload_id", workloadID),
+		slog.String("name", deployRequest.DecodedClaims.Subject),
+		slog.String("sha256", artifact.sha256),
+		slog.Int64("bytes", artifact.size),
+	)
+
+	return artifact.sha256, nil
+}
@@ -0,0 +1,77 @@
+package nexnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultWorkloadInitTimeout = 30 * time.Second
+
+// Triggers a function its agent has just accepted with the init payload of its deploy request,
+// before it is subscribed to any trigger. Functions signal a failed execution by not replying,
+// so the init fails unless the function replies within the init's timeout
+func (w *WorkloadManager) initializeWorkload(agentClient *agentapi.AgentClient, request *agentapi.DeployRequest) error {
+	workloadID := agentClient.ID()
+
+	subject := request.Init.Subject
+	if subject == "" {
+		subject = controlapi.DefaultWorkloadInitSubject
+	}
+
+	timeout := defaultWorkloadInitTimeout
+	if request.Init.TimeoutMillis > 0 {
+		timeout = time.Duration(request.Init.TimeoutMillis) * time.Millisecond
+	}
+
+	ctx, cancel := context.WithTimeout(w.ctx, timeout)
+	defer cancel()
+
+	ctx, span := w.t.Tracer.Start(ctx, "workload-init",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("name", *request.WorkloadName),
+			attribute.String("namespace", *request.Namespace),
+			attribute.String("init-subject", subject),
+		))
+	defer span.End()
+
+	w.log.Debug("Initializing workload",
+		slog.String("workload_id", workloadID),
+		slog.String("init_subject", subject),
+		slog.Duration("timeout", timeout),
+	)
+
+	msg := nats.NewMsg(subject)
+	msg.Data = request.Init.Payload
+
+	started := time.Now()
+	_, err := agentClient.RunTrigger(ctx, w.t.Tracer, msg)
+	if err != nil {
+		span.SetStatus(codes.Error, "Workload init failed")
+		span.RecordError(err)
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("function did not complete its init within %s", timeout)
+		}
+		return err
+	}
+
+	span.SetStatus(codes.Ok, "Workload initialized")
+	w.log.Info("Initialized workload",
+		slog.String("workload_id", workloadID),
+		slog.String("workload_name", *request.WorkloadName),
+		slog.Duration("init_time", time.Since(started)),
+	)
+
+	return nil
+}
@@ -3,10 +3,14 @@ package nexnode
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/url"
 	"os"
 	"path"
 	"strconv"
@@ -16,11 +20,13 @@ import (
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
 	"github.com/nats-io/nkeys"
 	controlapi "github.com/synadia-io/nex/control-api"
 	agentapi "github.com/synadia-io/nex/internal/agent-api"
 	"github.com/synadia-io/nex/internal/models"
 	internalnats "github.com/synadia-io/nex/internal/node/internal-nats"
+	"github.com/synadia-io/nex/internal/node/logsinks"
 	"github.com/synadia-io/nex/internal/node/observability"
 	"github.com/synadia-io/nex/internal/node/processmanager"
 
@@ -32,7 +38,9 @@ import (
 
 const (
 	defaultInternalNatsStoreDir = "pnats"
+	triggerDrainTimeout         = 10 * time.Second
 
+	AuditSubjectPrefix      = "$NEX.audit"
 	EventSubjectPrefix      = "$NEX.events"
 	LogSubjectPrefix        = "$NEX.logs"
 	WorkloadCacheBucketName = "NEXCACHE"
@@ -58,25 +66,107 @@ type WorkloadManager struct {
 
 	procMan processmanager.ProcessManager
 
-	// Any agent client in this map is one that has successfully acknowledged a deployment
+	// Any agent client in this map is one that has successfully acknowledged a deployment.
+	// Guarded by poolMutex, like pendingAgents and agentPools
 	activeAgents map[string]*agentapi.AgentClient
 
 	// Agent clients in this slice are attached to processes that have not yet received a deployment AND have
 	// successfully performed a handshake. Handshake failures are immediately removed
 	pendingAgents map[string]*agentapi.AgentClient
 
+	// Lifecycle state of the workload of every started agent, including whether a pending agent
+	// has been claimed by a deployment, which makes it unavailable to other deployments and to
+	// the reaper
+	states *workloadStates
+
+	// Warm pool each pending agent was started in; an agent only receives workloads of the types its pool serves
+	agentPools map[string]controlapi.NexWorkload
+
 	handshakes       map[string]string
 	handshakeTimeout time.Duration
 	pingTimeout      time.Duration
 
+	// Receives the ID of each agent as it completes its handshake, used to wake up queued deploys
+	readyAgents chan string
+
+	// Pool sizes the node started with; the agent channels are sized for them, so reloads may only shrink the pools
+	maxPoolSizes map[controlapi.NexWorkload]int
+
 	hostServices *HostServices
 
+	// Faults injected into deploys and agent handshakes; nil unless built with the faults tag
+	faults *faultInjector
+
+	// Compiled workload artifacts reported by agents, seeded into later deployments
+	compiled *compiledArtifactCache
+
 	poolMutex *sync.Mutex
-	stopMutex map[string]*sync.Mutex
+
+	// Serializes the bookkeeping done while stopping workloads, so that several workloads can
+	// be stopped concurrently while each waits out its undeploy grace period. The agent maps are
+	// guarded by poolMutex, which is taken first when both are held
+	teardownMutex sync.Mutex
 
 	// Subscriptions created on behalf of functions that cannot subscribe internallly
 	subz map[string][]*nats.Subscription
 
+	// Workers executing the trigger messages received by those subscriptions, keyed by workload ID
+	triggerPools map[string]*triggerPool
+
+	// NATS services registered on behalf of functions, keyed by workload ID
+	services map[string]micro.Service
+
+	// Timers stopping workloads deployed with a TTL once it elapses, keyed by workload ID
+	expiryTimers map[string]*time.Timer
+
+	// Recent trigger executions of each function workload, for debugging slow or failing functions
+	history *executionHistory
+
+	// Spans of the trigger executions in flight, attached to the logs they write when exported via OTel
+	executions *executionSpans
+
+	// Scans workload artifacts before they are cached for deployment; nil when scanning is disabled
+	scanner *artifactScanner
+
+	// Workloads quarantined for failing triggers or crashing too often; nil when quarantine is disabled
+	quarantine *quarantine
+
+	// Circuit breakers of the function workloads' trigger subjects; nil when they are disabled
+	breakers *triggerBreakers
+
+	// Responses of the functions deployed with a result cache policy; nil when caching is disabled
+	results *resultCache
+
+	// Balloons of the workloads whose memory may be reclaimed while idle; nil when reclaim is disabled
+	balloons *balloonReclaimer
+
+	// Workloads paused through the control API, keyed by workload ID and guarded by the pool mutex
+	paused map[string]*pausedWorkload
+
+	// Status of job workloads, retained after they complete
+	jobs *jobStatuses
+
+	// GPUs available for assignment to workloads; empty unless running without a sandbox
+	gpus *gpuAllocator
+
+	// Persistent volumes of the node's service workloads; nil when volumes are not enabled
+	volumes *volumeStore
+
+	// Resources used by each namespace's workloads; nil when metering is not enabled
+	metering *usageMeter
+
+	// Trigger handlers currently executing; Stop waits on this so their metrics and spans are recorded
+	triggers *drainBarrier
+
+	// Write-ahead log of in-flight deploys and stops, replayed after a crash; nil if it could not be opened
+	intents *intentLog
+
+	// Sinks to which workload logs are forwarded alongside $NEX.logs; nil when none are configured
+	logSinks *logsinks.Router
+
+	// Holds the events and logs emitted while the node is disconnected; nil when not configured
+	outbox *outbox
+
 	publicKey string
 }
 
@@ -97,6 +187,13 @@ func NewWorkloadManager(
 		return nil, fmt.Errorf("failed to create new workload manager; invalid node config; %v", config.Errors)
 	}
 
+	maxPoolSizes := make(map[controlapi.NexWorkload]int)
+	totalPoolSize := 0
+	for _, pool := range config.AgentPools() {
+		maxPoolSizes[pool] = config.AgentPoolSize(pool)
+		totalPoolSize += maxPoolSizes[pool]
+	}
+
 	w := &WorkloadManager{
 		config:           config,
 		cancel:           cancel,
@@ -110,17 +207,60 @@ func NewWorkloadManager(
 		poolMutex:        &sync.Mutex{},
 		pingTimeout:      time.Duration(config.AgentPingTimeoutMillisecond) * time.Millisecond,
 		publicKey:        publicKey,
+		maxPoolSizes:     maxPoolSizes,
+		readyAgents:      make(chan string, totalPoolSize),
 		t:                telemetry,
 
 		pendingAgents: make(map[string]*agentapi.AgentClient),
 		activeAgents:  make(map[string]*agentapi.AgentClient),
+		agentPools:    make(map[string]controlapi.NexWorkload),
+		states:        newWorkloadStates(),
+		faults:        newFaultInjector(),
+
+		compiled: newCompiledArtifactCache(compiledArtifactCacheMaxEntries),
+		history:  newExecutionHistory(config.ExecutionHistorySize),
+		jobs:     newJobStatuses(jobStatusRetention),
+		subz:     make(map[string][]*nats.Subscription),
+		triggers: newDrainBarrier(),
+
+		triggerPools: make(map[string]*triggerPool),
+		services:     make(map[string]micro.Service),
+		expiryTimers: make(map[string]*time.Timer),
+		executions:   newExecutionSpans(),
+		scanner:      newArtifactScanner(config.ArtifactScan, nc, log),
+		quarantine:   newQuarantine(config.Quarantine),
+		breakers:     newTriggerBreakers(config.TriggerBreaker),
+		results:      newResultCache(config.ResultCache, nc, log),
+		paused:       make(map[string]*pausedWorkload),
+		balloons:     newBalloonReclaimer(config),
+		volumes:      newVolumeStore(config),
+		metering:     newUsageMeter(config, time.Now().UTC()),
+	}
 
-		stopMutex: make(map[string]*sync.Mutex),
-		subz:      make(map[string][]*nats.Subscription),
+	gpuDevices, gpuModel := detectGPUs()
+	if len(gpuDevices) > 0 && !config.NoSandbox {
+		log.Warn("GPUs detected but unavailable to workloads; Firecracker does not support GPU passthrough",
+			slog.Int("gpu_count", len(gpuDevices)),
+		)
+		gpuDevices = nil
 	}
+	w.gpus = newGPUAllocator(gpuDevices, gpuModel)
 
 	var err error
 
+	if len(config.LogSinks) > 0 {
+		w.logSinks, err = logsinks.NewRouter(config.LogSinks, publicKey, w.log)
+		if err != nil {
+			w.log.Error("Failed to open log sinks", slog.Any("err", err))
+			return nil, err
+		}
+	}
+
+	w.outbox, err = newOutbox(nc, config, w.log)
+	if err != nil {
+		w.log.Warn("Failed to open outbox; events and logs emitted while disconnected may be lost", slog.Any("err", err))
+	}
+
 	// start internal NATS server
 	err = w.startInternalNATS()
 	if err != nil {
@@ -130,7 +270,18 @@ func NewWorkloadManager(
 		w.log.Info("Internal NATS server started", slog.String("client_url", w.natsint.ClientURL()))
 	}
 
+	err = w.t.ObserveAgentConnections(w.agentConnectionSamples)
+	if err != nil {
+		w.log.Warn("Failed to register agent connection metrics", slog.Any("err", err))
+	}
+
+	err = w.t.ObserveMachineStats(w.machineStatsSamples)
+	if err != nil {
+		w.log.Warn("Failed to register machine stats metrics", slog.Any("err", err))
+	}
+
 	w.hostServices = NewHostServices(w.ncint, config.HostServicesConfiguration, w.log, w.t.Tracer)
+	w.hostServices.server.SetThrottleCounter(w.t.HostServicesThrottled)
 	err = w.hostServices.init()
 	if err != nil {
 		w.log.Warn("Failed to initialize host services", slog.Any("err", err))
@@ -155,134 +306,405 @@ func NewWorkloadManager(
 func (w *WorkloadManager) Start() {
 	w.log.Info("Workload manager starting")
 
-	err := w.procMan.Start(w)
+	intents, unresolved, err := openIntentLog(intentLogFilepath(w.config))
+	if err != nil {
+		w.log.Warn("Failed to open intent log; in-flight operations will not be recovered after a crash", slog.Any("err", err))
+	} else {
+		w.intents = intents
+		w.recoverIntents(unresolved)
+	}
+
+	go w.runAgentReaper()
+	go w.runCredentialRotation()
+	go w.runSubscriptionJanitor()
+	go w.runBalloonReclaimer()
+	go w.runMetering()
+
+	err = w.startLocalTriggers()
+	if err != nil {
+		w.log.Warn("Failed to subscribe to local trigger messages; functions will not be triggered while disconnected", slog.Any("err", err))
+	}
+
+	err = w.procMan.Start(w)
 	if err != nil {
 		w.log.Error("Agent process manager failed to start", slog.Any("error", err))
 		w.cancel()
 	}
 }
 
-func (m *WorkloadManager) CacheWorkload(workloadID string, request *controlapi.DeployRequest) (uint64, *string, error) {
-	bucket := request.Location.Host
-	key := strings.Trim(request.Location.Path, "/")
+func (m *WorkloadManager) CacheWorkload(workloadID string, namespace string, request *controlapi.DeployRequest) (uint64, *string, error) {
+	artifact, err := m.fetchObject(request.Location, request.JsDomain, m.config.MaxArtifactBytes, func(transferred, total int64) {
+		m.publishArtifactTransfer(workloadID, namespace, request.Location, transferred, total)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	defer artifact.remove()
+
+	workloadHashString := artifact.sha256
+	cipher := m.agentCipher(workloadID)
+
+	if m.scanner == nil && cipher == nil {
+		// nothing needs the artifact in one piece, so it is streamed from disk into the cache
+		_, err = artifact.Seek(0, io.SeekStart)
+		if err == nil {
+			err = m.natsint.StreamFileForID(workloadID, io.LimitReader(artifact.File, artifact.size))
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to store bytes of %s in cache: %s", strings.Trim(request.Location.Path, "/"), err)
+		}
+	} else {
+		// scanning and sealing operate on the whole artifact
+		workload, err := artifact.bytes()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		err = m.scanWorkload(namespace, request, workload, workloadHashString)
+		if err != nil {
+			return 0, nil, err
+		}
 
-	m.log.Info("Attempting object store download", slog.String("bucket", bucket), slog.String("key", key))
+		sealed, err := cipher.Seal(workload)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to seal workload bytes: %s", err)
+		}
 
-	opts := []nats.JSOpt{}
-	if request.JsDomain != nil {
-		opts = append(opts, nats.Domain(*request.JsDomain))
-		opts = append(opts, nats.APIPrefix(*request.JsDomain))
+		err = m.natsint.StoreFileForID(workloadID, sealed)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to store bytes of %s in cache: %s", strings.Trim(request.Location.Path, "/"), err)
+		}
 	}
 
-	js, err := m.nc.JetStream(opts...)
-	if err != nil {
-		return 0, nil, err
+	if request.WorkloadType == controlapi.NexWorkloadWasm {
+		compiledKey := agentapi.CompiledWorkloadCacheKey(workloadHashString)
+		if compiled, ok := m.compiled.get(compiledKey); ok {
+			err = m.natsint.StoreObjectForID(workloadID, compiledKey, compiled)
+			if err != nil {
+				m.log.Warn("Failed to seed compiled workload in cache", slog.Any("err", err), slog.String("key", compiledKey))
+			}
+		}
 	}
 
-	store, err := js.ObjectStore(bucket)
-	if err != nil {
-		m.log.Error("Failed to bind to source object store", slog.Any("err", err), slog.String("bucket", bucket))
-		return 0, nil, err
+	m.log.Info("Successfully stored workload in internal object store",
+		slog.String("name", request.DecodedClaims.Subject),
+		slog.Int64("bytes", artifact.size))
+
+	return uint64(artifact.size), &workloadHashString, nil
+}
+
+// Returns the cipher sealing payloads for the given agent, or nil if the agent is unknown or
+// does not support payload encryption
+func (m *WorkloadManager) agentCipher(workloadID string) *agentapi.PayloadCipher {
+	m.poolMutex.Lock()
+	m.teardownMutex.Lock()
+	defer m.teardownMutex.Unlock()
+	defer m.poolMutex.Unlock()
+
+	if agentClient, ok := m.pendingAgents[workloadID]; ok {
+		return agentClient.Cipher()
+	}
+	if agentClient, ok := m.activeAgents[workloadID]; ok {
+		return agentClient.Cipher()
 	}
 
-	_, err = store.GetInfo(key)
-	if err != nil {
-		m.log.Error("Failed to locate workload binary in source object store", slog.Any("err", err), slog.String("key", key), slog.String("bucket", bucket))
-		return 0, nil, err
+	return nil
+}
+
+// Caches the request's input payload, if any, in the agent's cache bucket, downloading it first
+// when it references an object store
+func (m *WorkloadManager) CacheWorkloadInput(workloadID string, request *controlapi.DeployRequest) (*agentapi.WorkloadInput, error) {
+	if request.Input == nil {
+		return nil, nil
+	}
+
+	data := request.Input.Data
+	if request.Input.Location != nil {
+		var err error
+		data, err = m.downloadObject(request.Input.Location, request.JsDomain)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	workload, err := store.GetBytes(key)
+	sealed, err := m.agentCipher(workloadID).Seal(data)
 	if err != nil {
-		m.log.Error("Failed to download bytes from source object store", slog.Any("err", err), slog.String("key", key))
-		return 0, nil, err
+		return nil, fmt.Errorf("failed to seal workload input: %s", err)
 	}
 
-	err = m.natsint.StoreFileForID(workloadID, workload)
+	err = m.natsint.StoreObjectForID(workloadID, agentapi.WorkloadInputCacheKey, sealed)
 	if err != nil {
-		m.log.Error("Failed to store bytes from source object store in cache", slog.Any("err", err), slog.String("key", key))
+		return nil, err
 	}
 
-	workloadHash := sha256.New()
-	workloadHash.Write(workload)
-	workloadHashString := hex.EncodeToString(workloadHash.Sum(nil))
+	return &agentapi.WorkloadInput{
+		Path:  request.Input.Path,
+		Bytes: int64(len(data)),
+	}, nil
+}
 
-	m.log.Info("Successfully stored workload in internal object store",
-		slog.String("name", request.DecodedClaims.Subject),
-		slog.Int("bytes", len(workload)))
+// Downloads the objects the request mounts into the sandbox, verifying each against its expected
+// digest, and caches them in the agent's cache bucket
+func (m *WorkloadManager) CacheWorkloadMounts(workloadID string, request *controlapi.DeployRequest) ([]agentapi.WorkloadMount, error) {
+	mounts := make([]agentapi.WorkloadMount, 0, len(request.Mounts))
+	for i, mount := range request.Mounts {
+		data, err := m.downloadObject(mount.Location, request.JsDomain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download mount %s: %s", mount.Location, err)
+		}
 
-	return uint64(len(workload)), &workloadHashString, nil
+		digest := sha256.Sum256(data)
+		digestString := hex.EncodeToString(digest[:])
+		if mount.Sha256 != "" && !strings.EqualFold(mount.Sha256, digestString) {
+			return nil, fmt.Errorf("mount %s has sha256 %s; expected %s", mount.Location, digestString, mount.Sha256)
+		}
+
+		sealed, err := m.agentCipher(workloadID).Seal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to seal mount %s: %s", mount.Location, err)
+		}
+
+		key := agentapi.WorkloadMountCacheKey(i)
+		err = m.natsint.StoreObjectForID(workloadID, key, sealed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to cache mount %s: %s", mount.Location, err)
+		}
+
+		mounts = append(mounts, agentapi.WorkloadMount{
+			Key:    key,
+			Path:   mount.Path,
+			Sha256: digestString,
+		})
+	}
+
+	return mounts, nil
 }
 
-// Deploy a workload as specified by the given deploy request to an available
-// agent in the configured pool
+// Downloads an object given by a nats://BUCKET/key reference from the node's NATS connection
+func (m *WorkloadManager) downloadObject(location *url.URL, jsDomain *string) ([]byte, error) {
+	object, err := m.fetchObject(location, jsDomain, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer object.remove()
+
+	return object.bytes()
+}
+
+// Deploy a workload as specified by the given deploy request to an agent previously claimed
+// with ReserveAgent. The pool mutex is only held while the agent changes state, so deployments
+// to different agents proceed concurrently
 func (w *WorkloadManager) DeployWorkload(agentClient *agentapi.AgentClient, request *agentapi.DeployRequest) error {
+	workloadID := agentClient.ID()
+
 	w.poolMutex.Lock()
-	defer w.poolMutex.Unlock()
+	if !w.states.reserved(workloadID) {
+		w.poolMutex.Unlock()
+		return fmt.Errorf("agent %s was not reserved for deployment", workloadID)
+	}
 
-	workloadID := agentClient.ID()
+	if _, handshook := w.handshakes[workloadID]; !handshook {
+		w.states.release(workloadID)
+		w.poolMutex.Unlock()
+		return fmt.Errorf("agent %s has not completed its handshake", workloadID)
+	}
+
+	if !agentClient.Compatible() {
+		w.states.release(workloadID)
+		w.poolMutex.Unlock()
+		return fmt.Errorf("agent %s speaks unsupported protocol version %d", workloadID, agentClient.ProtocolVersion())
+	}
+
+	if err := agentClient.Supports(request.WorkloadType, request.Resources); err != nil {
+		w.states.release(workloadID)
+		w.poolMutex.Unlock()
+		return controlapi.NewDeployRejection(controlapi.DeployRejectionUnsupportedType, fmt.Sprintf("agent %s cannot run the workload: %s", workloadID, err))
+	}
+	w.poolMutex.Unlock()
+
+	// the agent is claimed by this deployment, so its process is prepared outside of the pool
+	// mutex and other deployments don't wait on it
 	err := w.procMan.PrepareWorkload(workloadID, request)
 	if err != nil {
+		w.states.release(workloadID)
 		return fmt.Errorf("failed to prepare agent process for workload deployment: %s", err)
 	}
 
+	// the agent may have been stopped while its process was prepared
+	err = w.transitionWorkload(workloadID, workloadStateDeploying, request)
+	if err != nil {
+		return fmt.Errorf("failed to deploy workload: %s", err)
+	}
+
+	w.recordIntent(intentRecord{
+		Operation:  intentDeployStarted,
+		WorkloadID: workloadID,
+		Namespace:  *request.Namespace,
+		Name:       *request.WorkloadName,
+		Pid:        w.agentPid(workloadID),
+	})
+
+	// the workload is charged for its machine from here on, not for its time in the warm pool
+	w.sampleWorkloadUsage(workloadID, *request.Namespace, time.Now())
+
 	status := w.ncint.Status()
 
 	w.log.Debug("Workload manager deploying workload",
 		slog.String("workload_id", workloadID),
 		slog.String("conn_status", status.String()))
 
-	deployResponse, err := agentClient.DeployWorkload(request)
-	if err != nil {
-		return fmt.Errorf("failed to submit request for workload deployment: %s", err)
+	// nodes built with the faults tag may reject the deploy in place of the agent
+	deployResponse := w.faults.deployResponse(workloadID)
+	if deployResponse == nil {
+		deployResponse, err = agentClient.DeployWorkload(request)
+		if err != nil {
+			// the agent's process has already been prepared for this workload and can't be reused
+			_ = w.StopWorkload(workloadID, false)
+			return fmt.Errorf("failed to submit request for workload deployment: %s", err)
+		}
 	}
 
-	if deployResponse.Accepted {
-		// move the client from active to pending
-		w.activeAgents[workloadID] = agentClient
-		delete(w.pendingAgents, workloadID)
+	if deployResponse.Accepted && request.Init != nil {
+		// the workload is undeployed rather than left running uninitialized
+		err = w.initializeWorkload(agentClient, request)
+		if err != nil {
+			_ = w.StopWorkload(workloadID, true)
+			return controlapi.NewDeployRejection(controlapi.DeployRejectionFailed, fmt.Sprintf("workload init failed: %s", err))
+		}
+	}
 
-		ncHostServices, err := w.createHostServicesConnection(request)
+	if deployResponse.Accepted {
+		err = w.activateWorkload(workloadID, agentClient, request)
 		if err != nil {
-			w.log.Error("Failed to establish host services connection for workload",
-				slog.Any("error", err),
-			)
 			return err
 		}
+	} else {
+		_ = w.StopWorkload(workloadID, false)
+		return agentRejection(deployResponse)
+	}
 
-		w.hostServices.server.SetHostServicesConnection(workloadID, ncHostServices)
-
-		if request.SupportsTriggerSubjects() {
-			for _, tsub := range request.TriggerSubjects {
-				sub, err := ncHostServices.Subscribe(tsub, w.generateTriggerHandler(workloadID, tsub, request))
-				if err != nil {
-					w.log.Error("Failed to create trigger subject subscription for deployed workload",
-						slog.String("workload_id", workloadID),
-						slog.String("trigger_subject", tsub),
-						slog.String("workload_type", string(request.WorkloadType)),
-						slog.Any("err", err),
-					)
-					_ = w.StopWorkload(workloadID, true)
-					return err
-				}
+	w.recordIntent(intentRecord{Operation: intentDeployCompleted, WorkloadID: workloadID})
+
+	w.t.WorkloadCounter.Add(w.ctx, 1, metric.WithAttributes(attribute.String("workload_type", string(request.WorkloadType))))
+	w.t.WorkloadCounter.Add(w.ctx, 1, metric.WithAttributes(attribute.String("namespace", *request.Namespace)), metric.WithAttributes(attribute.String("workload_type", string(request.WorkloadType))))
+	w.t.DeployedByteCounter.Add(w.ctx, request.TotalBytes)
+	w.t.DeployedByteCounter.Add(w.ctx, request.TotalBytes, metric.WithAttributes(attribute.String("namespace", *request.Namespace)))
 
-				w.log.Info("Created trigger subject subscription for deployed workload",
+	return nil
+}
+
+// Describes an agent's rejection of a workload. Agents predating rejection reasons are taken to
+// have failed to deploy it
+func agentRejection(deployResponse *agentapi.DeployResponse) *controlapi.DeployRejection {
+	reason := deployResponse.Reason
+	if reason == "" {
+		reason = controlapi.DeployRejectionFailed
+	}
+
+	message := "no reason given"
+	if deployResponse.Message != nil {
+		message = *deployResponse.Message
+	}
+
+	return controlapi.NewDeployRejection(reason, fmt.Sprintf("workload rejected by agent: %s", message))
+}
+
+// Moves the agent of a workload that its agent accepted, or that was restored, to the active
+// agents and wires the workload up: its host services connection, trigger subscriptions and
+// expiry. The workload is stopped if any of these fails
+func (w *WorkloadManager) activateWorkload(workloadID string, agentClient *agentapi.AgentClient, request *agentapi.DeployRequest) error {
+	// a workload being stopped, e.g. because its agent went away, is not activated
+	err := w.transitionWorkload(workloadID, workloadStateRunning, request)
+	if err != nil {
+		return err
+	}
+
+	w.promoteAgent(workloadID, agentClient)
+
+	ncHostServices, err := w.createHostServicesConnection(request)
+	if err != nil {
+		w.log.Error("Failed to establish host services connection for workload",
+			slog.Any("error", err),
+		)
+		_ = w.StopWorkload(workloadID, true)
+		return err
+	}
+
+	w.hostServices.server.SetHostServicesConnection(workloadID, ncHostServices)
+	w.hostServices.server.SetWorkloadBudgets(workloadID, request.HostServicesBudgets)
+
+	var pool *triggerPool
+	if request.SupportsTriggerSubjects() || request.Service != nil {
+		pool = newWorkloadTriggerPool(w.config.TriggerWorkers, request.TriggerWorkers)
+		w.poolMutex.Lock()
+		w.triggerPools[workloadID] = pool
+		w.poolMutex.Unlock()
+	}
+
+	if request.SupportsTriggerSubjects() {
+		w.recordIntent(intentRecord{
+			Operation:  intentSubscriptionsCreated,
+			WorkloadID: workloadID,
+			Subjects:   request.TriggerSubjects,
+		})
+
+		subz := make([]*nats.Subscription, 0, len(request.TriggerSubjects))
+		for _, tsub := range request.TriggerSubjects {
+			sub, err := ncHostServices.QueueSubscribe(tsub, triggerQueueGroup(workloadID), w.generateTriggerHandler(agentClient, tsub, request, pool))
+			if err != nil {
+				w.log.Error("Failed to create trigger subject subscription for deployed workload",
 					slog.String("workload_id", workloadID),
 					slog.String("trigger_subject", tsub),
 					slog.String("workload_type", string(request.WorkloadType)),
+					slog.Any("err", err),
 				)
-
-				w.subz[workloadID] = append(w.subz[workloadID], sub)
+				for _, sub := range subz {
+					_ = sub.Unsubscribe()
+				}
+				_ = w.StopWorkload(workloadID, true)
+				return err
 			}
+
+			w.log.Info("Created trigger subject subscription for deployed workload",
+				slog.String("workload_id", workloadID),
+				slog.String("trigger_subject", tsub),
+				slog.String("workload_type", string(request.WorkloadType)),
+			)
+
+			subz = append(subz, sub)
 		}
-	} else {
-		_ = w.StopWorkload(workloadID, false)
-		return fmt.Errorf("workload rejected by agent: %s", *deployResponse.Message)
+
+		w.poolMutex.Lock()
+		w.subz[workloadID] = append(w.subz[workloadID], subz...)
+		w.poolMutex.Unlock()
 	}
 
-	w.t.WorkloadCounter.Add(w.ctx, 1, metric.WithAttributes(attribute.String("workload_type", string(request.WorkloadType))))
-	w.t.WorkloadCounter.Add(w.ctx, 1, metric.WithAttributes(attribute.String("namespace", *request.Namespace)), metric.WithAttributes(attribute.String("workload_type", string(request.WorkloadType))))
-	w.t.DeployedByteCounter.Add(w.ctx, request.TotalBytes)
-	w.t.DeployedByteCounter.Add(w.ctx, request.TotalBytes, metric.WithAttributes(attribute.String("namespace", *request.Namespace)))
+	if request.Service != nil {
+		svc, err := w.startNatsService(ncHostServices, agentClient, request, pool)
+		if err != nil {
+			w.log.Error("Failed to register nats service for deployed workload",
+				slog.String("workload_id", workloadID),
+				slog.String("service", request.Service.Name),
+				slog.Any("err", err),
+			)
+			_ = w.StopWorkload(workloadID, true)
+			return err
+		}
+
+		w.poolMutex.Lock()
+		w.services[workloadID] = svc
+		w.poolMutex.Unlock()
+	}
+
+	if request.TTLMillis > 0 {
+		w.scheduleExpiry(workloadID, request)
+	}
+
+	if request.WorkloadType == controlapi.NexWorkloadJob {
+		w.jobs.started(workloadID, *request.Namespace, *request.WorkloadName, time.Now().UTC())
+	}
+
+	w.balloons.track(workloadID, string(request.WorkloadType), time.Now())
 
 	return nil
 }
@@ -305,8 +727,12 @@ func (w *WorkloadManager) RunningWorkloads() ([]controlapi.MachineSummary, error
 	for i, p := range procs {
 		uptimeFriendly := "unknown"
 		runtimeFriendly := "unknown"
+		healthy := false
+		w.poolMutex.Lock()
 		agentClient, ok := w.activeAgents[p.ID]
+		w.poolMutex.Unlock()
 		if ok {
+			healthy = agentClient.Healthy()
 			uptimeFriendly = myUptime(agentClient.UptimeMillis())
 			if p.DeployRequest.WorkloadType == controlapi.NexWorkloadV8 || p.DeployRequest.WorkloadType == controlapi.NexWorkloadWasm {
 				nanoTime := fmt.Sprintf("%dns", agentClient.ExecTimeNanos())
@@ -327,11 +753,20 @@ func (w *WorkloadManager) RunningWorkloads() ([]controlapi.MachineSummary, error
 			}
 		}
 
+		group := ""
+		if p.DeployRequest.Group != nil {
+			group = *p.DeployRequest.Group
+		}
+
 		summaries[i] = controlapi.MachineSummary{
 			Id:        p.ID,
-			Healthy:   true,
+			Healthy:   healthy,
 			Uptime:    uptimeFriendly,
 			Namespace: p.Namespace,
+			Group:     group,
+			Labels:    p.DeployRequest.Labels,
+			Issuer:    p.DeployRequest.DecodedClaims.Issuer,
+			ExpiresAt: p.DeployRequest.ExpiresAt,
 			Workload: controlapi.WorkloadSummary{
 				Name:         p.Name,
 				Description:  *p.DeployRequest.Description,
@@ -340,6 +775,21 @@ func (w *WorkloadManager) RunningWorkloads() ([]controlapi.MachineSummary, error
 				Hash:         p.DeployRequest.Hash,
 			},
 		}
+
+		stats, err := w.procMan.MachineStats(p.ID)
+		if err == nil {
+			summaries[i].Resources = &controlapi.MachineResources{
+				CPUTimeMillis:   stats.CPUTimeMillis,
+				MemoryRSSBytes:  stats.MemoryRSSBytes,
+				BlockReadBytes:  stats.BlockReadBytes,
+				BlockWriteBytes: stats.BlockWriteBytes,
+				NetRxBytes:      stats.NetRxBytes,
+				NetTxBytes:      stats.NetTxBytes,
+			}
+		}
+
+		summaries[i].TriggerBreakers = w.breakers.statuses(p.ID)
+		summaries[i].Paused = w.isPaused(p.ID)
 	}
 
 	return summaries, nil
@@ -351,11 +801,29 @@ func (w *WorkloadManager) Stop() error {
 	if atomic.AddUint32(&w.closing, 1) == 1 {
 		w.log.Info("Workload manager stopping")
 
-		for id := range w.pendingAgents {
-			_ = w.pendingAgents[id].Stop()
+		if !w.triggers.drain(triggerDrainTimeout) {
+			w.log.Warn("Timed out waiting for in-flight trigger executions to complete",
+				slog.Int("in_flight", w.triggers.inFlight()),
+			)
 		}
 
+		w.poolMutex.Lock()
+		pending := make([]*agentapi.AgentClient, 0, len(w.pendingAgents))
+		for _, agentClient := range w.pendingAgents {
+			pending = append(pending, agentClient)
+		}
+		active := make([]string, 0, len(w.activeAgents))
 		for id := range w.activeAgents {
+			active = append(active, id)
+		}
+		w.poolMutex.Unlock()
+
+		for _, agentClient := range pending {
+			_ = agentClient.Stop()
+		}
+
+		// stopping a workload takes the pool mutex itself
+		for _, id := range active {
 			err := w.StopWorkload(id, true)
 			if err != nil {
 				w.log.Warn("Failed to stop agent", slog.String("workload_id", id), slog.String("error", err.Error()))
@@ -374,38 +842,71 @@ func (w *WorkloadManager) Stop() error {
 		}
 
 		w.natsint.Shutdown()
+		w.intents.close()
+		w.logSinks.Close()
+		w.outbox.close()
 		_ = os.Remove(path.Join(os.TempDir(), defaultInternalNatsStoreDir))
 	}
 
 	return nil
 }
 
-// Stop a workload, optionally attempting a graceful undeploy prior to termination
+// Stop a workload, optionally attempting a graceful undeploy prior to termination. Only the
+// first of several concurrent requests to stop the same workload stops it; the others return
+// ErrWorkloadAlreadyStopped, as do requests to stop a workload that was recently stopped.
+// Workloads this node does not know of return ErrWorkloadNotFound. Workloads whose deployment
+// had not completed, and which may not have an agent or deploy request, are torn down as far as
+// they were set up
 func (w *WorkloadManager) StopWorkload(id string, undeploy bool) error {
-	defer func() {
-		delete(w.activeAgents, id)
-		delete(w.pendingAgents, id)
-		delete(w.stopMutex, id)
-		w.hostServices.server.RemoveHostServicesConnection(id)
-
-		_ = w.publishWorkloadStopped(id)
-	}()
-
-	deployRequest, err := w.procMan.Lookup(id)
+	err := w.transitionWorkload(id, workloadStateStopping, nil)
 	if err != nil {
-		w.log.Warn("request to undeploy workload failed", slog.String("workload_id", id), slog.String("error", err.Error()))
+		w.log.Debug("Not stopping workload", slog.String("workload_id", id), slog.String("reason", err.Error()))
 		return err
 	}
 
-	mutex := w.stopMutex[id]
-	if mutex != nil {
-		mutex.Lock()
-		defer mutex.Unlock()
+	w.teardownMutex.Lock()
+	deployRequest, err := w.procMan.Lookup(id)
+	w.teardownMutex.Unlock()
+
+	agentClient := w.workloadAgent(id)
+
+	if err != nil {
+		// the workload's process is still stopped below, if the process manager knows of it
+		w.log.Warn("Failed to look up workload being stopped", slog.String("workload_id", id), slog.String("error", err.Error()))
 	}
 
+	defer func() {
+		w.forgetAgent(id)
+
+		w.teardownMutex.Lock()
+		defer w.teardownMutex.Unlock()
+
+		w.hostServices.server.RemoveWorkload(id)
+		w.history.remove(id)
+		w.jobs.stopped(id)
+		w.gpus.release(id)
+		w.volumes.release(id)
+		w.quarantine.stopped(id)
+		w.breakers.remove(id)
+		w.releaseBalloon(id)
+		w.metering.forget(id)
+
+		_ = w.publishWorkloadStopped(id, deployRequest)
+		w.recordIntent(intentRecord{Operation: intentStopCompleted, WorkloadID: id})
+		_ = w.transitionWorkload(id, workloadStateStopped, nil)
+	}()
+
 	w.log.Debug("Attempting to stop workload", slog.String("workload_id", id), slog.Bool("undeploy", undeploy))
+	w.recordIntent(intentRecord{Operation: intentStopStarted, WorkloadID: id})
+
+	w.poolMutex.Lock()
+	subz := w.subz[id]
+	delete(w.subz, id)
+	w.poolMutex.Unlock()
+
+	w.unpauseForStop(id, agentClient)
 
-	for _, sub := range w.subz[id] {
+	for _, sub := range subz {
 		err := sub.Drain()
 		if err != nil {
 			w.log.Warn("failed to drain subscription to subject associated with workload",
@@ -421,22 +922,58 @@ func (w *WorkloadManager) StopWorkload(id string, undeploy bool) error {
 		)
 	}
 
-	if deployRequest != nil && undeploy {
-		agentClient := w.activeAgents[id]
+	w.stopNatsService(id)
+
+	w.poolMutex.Lock()
+	pool, ok := w.triggerPools[id]
+	delete(w.triggerPools, id)
+	if timer, scheduled := w.expiryTimers[id]; scheduled {
+		timer.Stop()
+		delete(w.expiryTimers, id)
+	}
+	w.poolMutex.Unlock()
+
+	if ok {
+		pool.stop()
+	}
+
+	if deployRequest != nil && agentClient != nil && undeploy {
 		defer func() {
 			_ = agentClient.Drain()
 		}()
 
-		err := agentClient.Undeploy()
+		gracePeriod := time.Duration(deployRequest.StopGracePeriodMillis) * time.Millisecond
+		_ = w.publishWorkloadStopping(id, deployRequest, controlapi.WorkloadStopPhaseSignaled)
+
+		exited, err := agentClient.UndeployWithGracePeriod(gracePeriod)
 		if err != nil {
 			w.log.Warn("request to undeploy workload via internal NATS connection failed", slog.String("workload_id", id), slog.String("error", err.Error()))
 		}
 
+		// whether or not the workload exited, its process is terminated below
+		if exited {
+			_ = w.publishWorkloadStopping(id, deployRequest, controlapi.WorkloadStopPhaseExited)
+		} else {
+			w.log.Debug("Workload did not exit within its grace period; escalating to kill",
+				slog.String("workload_id", id),
+				slog.Duration("grace_period", gracePeriod),
+			)
+			_ = w.publishWorkloadStopping(id, deployRequest, controlapi.WorkloadStopPhaseKilled)
+		}
+
 		// FIXME-- this should probably just live in workload manager
+		w.teardownMutex.Lock()
 		_ = w.natsint.DestroyCredentials(id)
+		w.teardownMutex.Unlock()
+	}
+
+	if deployRequest != nil {
+		w.sampleWorkloadUsage(id, *deployRequest.Namespace, time.Now())
 	}
 
+	w.teardownMutex.Lock()
 	err = w.procMan.StopProcess(id)
+	w.teardownMutex.Unlock()
 	if err != nil {
 		w.log.Warn("failed to stop workload process", slog.String("workload_id", id), slog.String("error", err.Error()))
 		return err
@@ -445,10 +982,191 @@ func (w *WorkloadManager) StopWorkload(id string, undeploy bool) error {
 	return nil
 }
 
-// Called by the agent process manager when an agent has been warmed and is ready
-// to receive workload deployment instructions
-func (w *WorkloadManager) OnProcessStarted(id string) {
-	w.log.Debug("Process started", slog.String("workload_id", id))
+// Returns the warm pool of a pending agent that has not been claimed by a deployment
+func (w *WorkloadManager) idleAgentPool(id string) (controlapi.NexWorkload, bool) {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	if _, ok := w.pendingAgents[id]; !ok || w.states.claimed(id) {
+		return "", false
+	}
+
+	return w.agentPools[id], true
+}
+
+// Returns the agent of a workload, which is still pending if its deployment has not completed
+func (w *WorkloadManager) workloadAgent(id string) *agentapi.AgentClient {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	if agentClient, ok := w.activeAgents[id]; ok {
+		return agentClient
+	}
+	return w.pendingAgents[id]
+}
+
+// Moves the agent of a workload whose deployment completed from the pending to the active agents
+func (w *WorkloadManager) promoteAgent(id string, agentClient *agentapi.AgentClient) {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	w.activeAgents[id] = agentClient
+	delete(w.pendingAgents, id)
+	delete(w.agentPools, id)
+}
+
+// Removes the agent of a stopped workload from the pools
+func (w *WorkloadManager) forgetAgent(id string) {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	delete(w.activeAgents, id)
+	delete(w.pendingAgents, id)
+	delete(w.agentPools, id)
+}
+
+// Mark deploy requests made by the node itself to replace one of its existing workloads. The
+// request is signed with the node's key along with the time it was made, as anyone able to
+// deploy to the node could otherwise claim to be the node and skip its cordon and pressure checks
+const (
+	redeployHeader     = "x-nex-redeploy"
+	redeployTimeHeader = "x-nex-redeploy-time"
+)
+
+// How long after it was signed a redeploy request is accepted, bounding replays of captured requests
+const redeploySignatureWindow = 30 * time.Second
+
+func redeploySigningPayload(subject string, signedAt string, data []byte) []byte {
+	payload := make([]byte, 0, len(subject)+len(signedAt)+len(data)+2)
+	payload = append(payload, subject...)
+	payload = append(payload, 0)
+	payload = append(payload, signedAt...)
+	payload = append(payload, 0)
+	return append(payload, data...)
+}
+
+// Signs a deploy request made by the node with the node's key
+func signRedeploy(kp nkeys.KeyPair, msg *nats.Msg, now time.Time) error {
+	signedAt := now.UTC().Format(time.RFC3339Nano)
+	sig, err := kp.Sign(redeploySigningPayload(msg.Subject, signedAt, msg.Data))
+	if err != nil {
+		return err
+	}
+
+	msg.Header.Set(redeployHeader, base64.StdEncoding.EncodeToString(sig))
+	msg.Header.Set(redeployTimeHeader, signedAt)
+	return nil
+}
+
+// Reports whether a deploy request was made by the node holding the given key, recently enough
+// not to be a replay
+func verifyRedeploy(kp nkeys.KeyPair, msg *nats.Msg, now time.Time) bool {
+	if kp == nil || msg.Header == nil || msg.Header.Get(redeployHeader) == "" {
+		return false
+	}
+
+	signedAt := msg.Header.Get(redeployTimeHeader)
+	at, err := time.Parse(time.RFC3339Nano, signedAt)
+	if err != nil || now.Sub(at) > redeploySignatureWindow || at.Sub(now) > redeploySignatureWindow {
+		return false
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(msg.Header.Get(redeployHeader))
+	if err != nil {
+		return false
+	}
+
+	return kp.Verify(redeploySigningPayload(msg.Subject, signedAt, msg.Data), sig) == nil
+}
+
+// Submits a previously deployed workload's original deploy request back to this node's
+// control API, returning the response describing the replacement workload
+func (w *WorkloadManager) RedeployWorkload(deployRequest *agentapi.DeployRequest) (*controlapi.RunResponse, error) {
+	request, err := redeployRequest(deployRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.submitRedeploy(*deployRequest.Namespace, request)
+}
+
+// Reconstructs the deploy request from which a running workload was deployed
+func redeployRequest(deployRequest *agentapi.DeployRequest) (*controlapi.DeployRequest, error) {
+	// the replacement only runs for whatever remains of the original workload's TTL
+	ttlMillis := int64(0)
+	if deployRequest.ExpiresAt != nil {
+		ttlMillis = time.Until(*deployRequest.ExpiresAt).Milliseconds()
+		if ttlMillis <= 0 {
+			return nil, errors.New("workload has expired")
+		}
+	}
+
+	return &controlapi.DeployRequest{
+		Argv:                  deployRequest.Argv,
+		Description:           deployRequest.Description,
+		WorkloadType:          deployRequest.WorkloadType,
+		Location:              deployRequest.Location,
+		WorkloadJwt:           deployRequest.WorkloadJwt,
+		Environment:           deployRequest.EncryptedEnvironment,
+		Essential:             deployRequest.Essential,
+		Group:                 deployRequest.Group,
+		RetriedAt:             deployRequest.RetriedAt,
+		StopGracePeriodMillis: deployRequest.StopGracePeriodMillis,
+		Labels:                deployRequest.Labels,
+		TriggerWorkers:        deployRequest.TriggerWorkers,
+		TTLMillis:             ttlMillis,
+		Input:                 deployRequest.SourceInput,
+		Mounts:                deployRequest.SourceMounts,
+		GPUs:                  len(deployRequest.GPUDevices),
+		HotReload:             deployRequest.HotReload,
+		Volume:                deployRequest.SourceVolume,
+		Service:               deployRequest.Service,
+		ResultCache:           deployRequest.ResultCache,
+		Init:                  deployRequest.Init,
+		RetryCount:            deployRequest.RetryCount,
+		SenderPublicKey:       deployRequest.SenderPublicKey,
+		TargetNode:            deployRequest.TargetNode,
+		TriggerSubjects:       deployRequest.TriggerSubjects,
+		JsDomain:              deployRequest.JsDomain,
+	}, nil
+}
+
+// Submits a deploy request to this node's control API on behalf of the node itself
+func (w *WorkloadManager) submitRedeploy(namespace string, request *controlapi.DeployRequest) (*controlapi.RunResponse, error) {
+	req, _ := json.Marshal(request)
+
+	msg := nats.NewMsg(fmt.Sprintf("%s.DEPLOY.%s.%s", controlapi.APIPrefix, namespace, w.publicKey))
+	msg.Data = req
+	err := signRedeploy(w.kp, msg, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := w.nc.RequestMsg(msg, time.Millisecond*2500)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope struct {
+		Data  *controlapi.RunResponse `json:"data,omitempty"`
+		Error interface{}             `json:"error,omitempty"`
+	}
+	err = json.Unmarshal(res.Data, &envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	if envelope.Error != nil || envelope.Data == nil {
+		return nil, fmt.Errorf("node rejected redeploy: %v", envelope.Error)
+	}
+
+	return envelope.Data, nil
+}
+
+// Called by the agent process manager when an agent has been warmed in the given pool and is
+// ready to receive workload deployment instructions
+func (w *WorkloadManager) OnProcessStarted(id string, pool controlapi.NexWorkload) {
+	w.log.Debug("Process started", slog.String("workload_id", id), slog.String("pool", string(pool)))
 	w.poolMutex.Lock()
 	defer w.poolMutex.Unlock()
 
@@ -477,7 +1195,8 @@ func (w *WorkloadManager) OnProcessStarted(id string) {
 	}
 
 	w.pendingAgents[id] = agentClient
-	w.stopMutex[id] = &sync.Mutex{}
+	w.agentPools[id] = pool
+	w.states.track(id)
 }
 
 func (w *WorkloadManager) agentHandshakeTimedOut(id string) {
@@ -485,7 +1204,7 @@ func (w *WorkloadManager) agentHandshakeTimedOut(id string) {
 	defer w.poolMutex.Unlock()
 
 	w.log.Error("Did not receive NATS handshake from agent within timeout.", slog.String("workload_id", id))
-	delete(w.pendingAgents, id)
+	w.reapAgent(id, agentReapReasonHandshakeTimeout)
 
 	if len(w.handshakes) == 0 {
 		w.log.Error("First handshake failed, shutting down to avoid inconsistent behavior")
@@ -494,8 +1213,37 @@ func (w *WorkloadManager) agentHandshakeTimedOut(id string) {
 }
 
 func (w *WorkloadManager) agentHandshakeSucceeded(workloadID string) {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	if w.faults.failHandshake(workloadID) {
+		w.reapAgent(workloadID, agentReapReasonInjectedFault)
+		return
+	}
+
 	now := time.Now().UTC()
 	w.handshakes[workloadID] = now.Format(time.RFC3339)
+
+	if agentClient, ok := w.pendingAgents[workloadID]; ok {
+		if !agentClient.Compatible() {
+			// incompatible agents stay in the pool for diagnostics but are never handed a deployment
+			return
+		}
+
+		if w.config.RequirePayloadEncryption && agentClient.Cipher() == nil {
+			w.log.Error("Agent does not support payload encryption, which this node requires; it will not receive workload deployments",
+				slog.String("workload_id", workloadID),
+				slog.String("agent_version", agentClient.AgentVersion()),
+			)
+			return
+		}
+	}
+
+	select {
+	case w.readyAgents <- workloadID:
+	default:
+		// nobody is waiting on ready agents; don't block the handshake
+	}
 }
 
 func (w *WorkloadManager) agentContactLost(workloadID string) {
@@ -503,90 +1251,221 @@ func (w *WorkloadManager) agentContactLost(workloadID string) {
 	_ = w.StopWorkload(workloadID, false)
 }
 
-// Generate a NATS subscriber function that is used to trigger function-type workloads
-func (w *WorkloadManager) generateTriggerHandler(workloadID string, tsub string, request *agentapi.DeployRequest) func(msg *nats.Msg) {
-	agentClient, ok := w.activeAgents[workloadID]
-	if !ok {
-		w.log.Error("Attempted to generate trigger handler for non-existent agent client")
-		return nil
-	}
+// Generate a NATS subscriber function that is used to trigger function-type workloads. The
+// subscriber only queues each message on the workload's trigger pool, whose workers execute it
+func (w *WorkloadManager) generateTriggerHandler(agentClient *agentapi.AgentClient, tsub string, request *agentapi.DeployRequest, pool *triggerPool) func(msg *nats.Msg) {
+	dispatch := w.triggerDispatcher(agentClient, tsub, request, pool)
+	rejectWithError := w.breakers != nil && w.breakers.config.RejectWithError
 
 	return func(msg *nats.Msg) {
-		ctx, parentSpan := w.t.Tracer.Start(
-			w.ctx,
-			"workload-trigger",
-			trace.WithNewRoot(),
-			trace.WithSpanKind(trace.SpanKindServer),
-			trace.WithAttributes(
-				attribute.String("name", *request.WorkloadName),
-				attribute.String("namespace", *request.Namespace),
-				attribute.String("trigger-subject", msg.Subject),
-			))
+		dispatch(msg, &msgResponder{msg: msg, rejectWithError: rejectWithError})
+	}
+}
+
+// Returns a function queueing the trigger messages received on the given subject, or on an
+// endpoint of the workload's NATS service, for execution by the workload's trigger pool
+func (w *WorkloadManager) triggerDispatcher(agentClient *agentapi.AgentClient, tsub string, request *agentapi.DeployRequest, pool *triggerPool) func(msg *nats.Msg, responder triggerResponder) {
+	workloadID := agentClient.ID()
 
-		defer parentSpan.End()
+	workloadAttrs := metric.WithAttributes(
+		attribute.String("namespace", *request.Namespace),
+		attribute.String("workload_name", *request.WorkloadName),
+	)
 
-		resp, err := agentClient.RunTrigger(ctx, w.t.Tracer, msg.Subject, msg.Data)
+	breaker := w.breakers.get(workloadID, tsub)
 
-		parentSpan.AddEvent("Completed internal request")
-		if err != nil {
-			parentSpan.SetStatus(codes.Error, "Internal trigger request failed")
-			parentSpan.RecordError(err)
-			w.log.Error("Failed to request agent execution via internal trigger subject",
-				slog.Any("err", err),
+	return func(msg *nats.Msg, responder triggerResponder) {
+		// the workload's subscriptions are drained as it stops, which may still deliver messages
+		if w.states.state(workloadID) != workloadStateRunning {
+			w.log.Debug("Rejecting trigger execution for workload that is not running",
+				slog.String("workload_id", workloadID),
 				slog.String("trigger_subject", tsub),
-				slog.String("workload_type", string(request.WorkloadType)),
+			)
+			return
+		}
+
+		if !breaker.allow(time.Now()) {
+			w.rejectTrigger(workloadID, tsub, responder, workloadAttrs)
+			return
+		}
+
+		if !w.triggers.enter() {
+			w.log.Debug("Rejecting trigger execution during shutdown",
 				slog.String("workload_id", workloadID),
+				slog.String("trigger_subject", tsub),
 			)
+			return
+		}
+
+		w.t.FunctionTriggerQueueDepth.Add(w.ctx, 1, workloadAttrs)
+		queued := pool.submit(func() {
+			defer w.triggers.exit()
+			w.t.FunctionTriggerQueueDepth.Add(w.ctx, -1, workloadAttrs)
 
-			w.t.FunctionFailedTriggers.Add(w.ctx, 1)
-			w.t.FunctionFailedTriggers.Add(w.ctx, 1, metric.WithAttributes(attribute.String("namespace", *request.Namespace)))
-			w.t.FunctionFailedTriggers.Add(w.ctx, 1, metric.WithAttributes(attribute.String("workload_name", *request.WorkloadName)))
-			_ = w.publishFunctionExecFailed(workloadID, *request.WorkloadName, *request.Namespace, tsub, err)
-		} else if resp != nil {
-			parentSpan.SetStatus(codes.Ok, "Trigger succeeded")
-			runtimeNs := resp.Header.Get(agentapi.NexRuntimeNs)
-			w.log.Debug("Received response from execution via trigger subject",
+			w.wakeBalloon(workloadID)
+
+			w.executeTrigger(agentClient, workloadID, tsub, request, msg, responder)
+		})
+
+		if !queued {
+			w.triggers.exit()
+			w.t.FunctionTriggerQueueDepth.Add(w.ctx, -1, workloadAttrs)
+			w.t.FunctionShedTriggers.Add(w.ctx, 1, workloadAttrs)
+
+			w.log.Warn("Shed trigger execution; workload trigger queue is full",
 				slog.String("workload_id", workloadID),
 				slog.String("trigger_subject", tsub),
-				slog.String("workload_type", string(request.WorkloadType)),
-				slog.String("function_run_time_nanosec", runtimeNs),
-				slog.Int("payload_size", len(resp.Data)),
 			)
+		}
+	}
+}
 
-			runTimeNs64, err := strconv.ParseInt(runtimeNs, 10, 64)
-			if err != nil {
-				w.log.Warn("failed to log function runtime", slog.Any("err", err))
-			}
-			_ = w.publishFunctionExecSucceeded(workloadID, tsub, runTimeNs64)
-			agentClient.RecordExecTime(runTimeNs64)
-			parentSpan.AddEvent("published success event")
+// Runs a single trigger message against the workload's agent and replies with its result
+func (w *WorkloadManager) executeTrigger(agentClient *agentapi.AgentClient, workloadID string, tsub string, request *agentapi.DeployRequest, msg *nats.Msg, responder triggerResponder) {
+	ctx, parentSpan := w.t.Tracer.Start(
+		w.ctx,
+		"workload-trigger",
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("name", *request.WorkloadName),
+			attribute.String("namespace", *request.Namespace),
+			attribute.String("trigger-subject", msg.Subject),
+		))
+
+	defer parentSpan.End()
+	defer w.executions.begin(workloadID, parentSpan.SpanContext())()
+
+	record := controlapi.ExecutionRecord{
+		StartedAt:      time.Now().UTC(),
+		TriggerSubject: msg.Subject,
+		PayloadBytes:   len(msg.Data),
+	}
+	defer func() {
+		record.DurationNanos = time.Since(record.StartedAt).Nanoseconds()
+		w.history.record(workloadID, record)
+	}()
 
-			w.t.FunctionTriggers.Add(w.ctx, 1)
-			w.t.FunctionTriggers.Add(w.ctx, 1, metric.WithAttributes(attribute.String("namespace", *request.Namespace)))
-			w.t.FunctionTriggers.Add(w.ctx, 1, metric.WithAttributes(attribute.String("workload_name", *request.WorkloadName)))
-			w.t.FunctionRunTimeNano.Add(w.ctx, runTimeNs64)
-			w.t.FunctionRunTimeNano.Add(w.ctx, runTimeNs64, metric.WithAttributes(attribute.String("namespace", *request.Namespace)))
-			w.t.FunctionRunTimeNano.Add(w.ctx, runTimeNs64, metric.WithAttributes(attribute.String("workload_name", *request.WorkloadName)))
+	cacheable := w.results.caches(request, msg.Subject)
+	if cacheable {
+		if cached, hit := w.results.lookup(request, msg); hit {
+			w.t.FunctionResultCacheHits.Add(w.ctx, 1)
+			w.t.FunctionResultCacheHits.Add(w.ctx, 1, metric.WithAttributes(attribute.String("namespace", *request.Namespace)))
+			w.t.FunctionResultCacheHits.Add(w.ctx, 1, metric.WithAttributes(attribute.String("workload_name", *request.WorkloadName)))
 
-			err = msg.Respond(resp.Data)
+			parentSpan.AddEvent("Served from result cache")
+			record.Success = true
+			record.Cached = true
+			record.RuntimeNanos = cached.RuntimeNanos
+			record.ResponseBytes = len(cached.Data)
 
+			err := responder.respond(&nats.Msg{Data: cached.Data, Header: cached.Header})
 			if err != nil {
-				parentSpan.SetStatus(codes.Error, "Failed to respond to trigger subject")
-				parentSpan.RecordError(err)
-				w.log.Error("Failed to respond to trigger subject subscription request for deployed workload",
+				w.log.Error("Failed to respond to trigger subject with cached result",
 					slog.String("workload_id", workloadID),
 					slog.String("trigger_subject", tsub),
-					slog.String("workload_type", string(request.WorkloadType)),
 					slog.Any("err", err),
 				)
 			}
+			return
+		}
+
+		w.t.FunctionResultCacheMisses.Add(w.ctx, 1)
+		w.t.FunctionResultCacheMisses.Add(w.ctx, 1, metric.WithAttributes(attribute.String("namespace", *request.Namespace)))
+		w.t.FunctionResultCacheMisses.Add(w.ctx, 1, metric.WithAttributes(attribute.String("workload_name", *request.WorkloadName)))
+	}
+
+	resp, err := agentClient.RunTrigger(ctx, w.t.Tracer, msg)
+
+	parentSpan.AddEvent("Completed internal request")
+	if err != nil {
+		parentSpan.SetStatus(codes.Error, "Internal trigger request failed")
+		parentSpan.RecordError(err)
+		w.log.Error("Failed to request agent execution via internal trigger subject",
+			slog.Any("err", err),
+			slog.String("trigger_subject", tsub),
+			slog.String("workload_type", string(request.WorkloadType)),
+			slog.String("workload_id", workloadID),
+		)
+
+		w.t.FunctionFailedTriggers.Add(w.ctx, 1)
+		w.t.FunctionFailedTriggers.Add(w.ctx, 1, metric.WithAttributes(attribute.String("namespace", *request.Namespace)))
+		w.t.FunctionFailedTriggers.Add(w.ctx, 1, metric.WithAttributes(attribute.String("workload_name", *request.WorkloadName)))
+		_ = w.publishFunctionExecFailed(workloadID, *request.WorkloadName, *request.Namespace, tsub, err)
+		record.Error = err.Error()
+		w.metering.invocation(*request.Namespace, 0)
+		w.recordTriggerOutcome(workloadID, request, true)
+		w.recordBreakerOutcome(workloadID, tsub, request, true)
+		responder.fail(err)
+	} else if resp != nil {
+		parentSpan.SetStatus(codes.Ok, "Trigger succeeded")
+		runtimeNs := resp.Header.Get(agentapi.NexRuntimeNs)
+		w.log.Debug("Received response from execution via trigger subject",
+			slog.String("workload_id", workloadID),
+			slog.String("trigger_subject", tsub),
+			slog.String("workload_type", string(request.WorkloadType)),
+			slog.String("function_run_time_nanosec", runtimeNs),
+			slog.Int("payload_size", len(resp.Data)),
+		)
+
+		runTimeNs64, err := strconv.ParseInt(runtimeNs, 10, 64)
+		if err != nil {
+			w.log.Warn("failed to log function runtime", slog.Any("err", err))
+		}
+		_ = w.publishFunctionExecSucceeded(workloadID, tsub, runTimeNs64)
+		record.Success = true
+		record.RuntimeNanos = runTimeNs64
+		record.ResponseBytes = len(resp.Data)
+		agentClient.RecordExecTime(runTimeNs64)
+		w.metering.invocation(*request.Namespace, len(resp.Data))
+		parentSpan.AddEvent("published success event")
+		w.recordTriggerOutcome(workloadID, request, false)
+		w.recordBreakerOutcome(workloadID, tsub, request, false)
+
+		w.t.FunctionTriggers.Add(w.ctx, 1)
+		w.t.FunctionTriggers.Add(w.ctx, 1, metric.WithAttributes(attribute.String("namespace", *request.Namespace)))
+		w.t.FunctionTriggers.Add(w.ctx, 1, metric.WithAttributes(attribute.String("workload_name", *request.WorkloadName)))
+		w.t.FunctionRunTimeNano.Add(w.ctx, runTimeNs64)
+		w.t.FunctionRunTimeNano.Add(w.ctx, runTimeNs64, metric.WithAttributes(attribute.String("namespace", *request.Namespace)))
+		w.t.FunctionRunTimeNano.Add(w.ctx, runTimeNs64, metric.WithAttributes(attribute.String("workload_name", *request.WorkloadName)))
+
+		header := agentapi.ResponseHeaders(resp)
+		err = responder.respond(&nats.Msg{
+			Data:   resp.Data,
+			Header: header,
+		})
+
+		if cacheable {
+			w.results.store(request, msg, &cachedResult{Header: header, Data: resp.Data, RuntimeNanos: runTimeNs64})
+		}
+
+		if err != nil {
+			parentSpan.SetStatus(codes.Error, "Failed to respond to trigger subject")
+			parentSpan.RecordError(err)
+			w.log.Error("Failed to respond to trigger subject subscription request for deployed workload",
+				slog.String("workload_id", workloadID),
+				slog.String("trigger_subject", tsub),
+				slog.String("workload_type", string(request.WorkloadType)),
+				slog.Any("err", err),
+			)
 		}
 	}
 }
 
 func (w *WorkloadManager) startInternalNATS() error {
 	var err error
-	w.natsint, err = internalnats.NewInternalNatsServer(w.log)
+	limits := internalnats.Limits{}
+	if w.config.InternalNATS != nil {
+		limits = internalnats.Limits{
+			MaxPayload:               w.config.InternalNATS.MaxPayloadBytes,
+			MaxConnections:           w.config.InternalNATS.MaxConnections,
+			WriteDeadline:            time.Duration(w.config.InternalNATS.WriteDeadlineMillisecond) * time.Millisecond,
+			MaxConnectionsPerAgent:   w.config.InternalNATS.MaxConnectionsPerAgent,
+			MaxSubscriptionsPerAgent: w.config.InternalNATS.MaxSubscriptionsPerAgent,
+		}
+	}
+
+	w.natsint, err = internalnats.NewInternalNatsServerWithLimits(w.log, limits)
 	if err != nil {
 		return err
 	}
@@ -659,17 +1538,195 @@ func (w *WorkloadManager) createHostServicesConnection(request *agentapi.DeployR
 
 }
 
-// Picks a pending agent from the pool that will receive the next deployment
-func (w *WorkloadManager) SelectRandomAgent() (*agentapi.AgentClient, error) {
+// Claims an idle pending agent from the warm pool serving the given workload type to receive
+// the next deployment. The agent must either be passed to DeployWorkload or handed back with
+// ReleaseAgent. Only agents that have completed their handshake are selected, and never those
+// speaking an incompatible protocol version, those sending payloads in the clear when the node
+// requires payload encryption, or those whose reported capabilities cannot run a workload of
+// the given type and resources
+func (w *WorkloadManager) ReserveAgent(workloadType controlapi.NexWorkload, resources *controlapi.WorkloadResources) (*agentapi.AgentClient, error) {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
 	if len(w.pendingAgents) == 0 {
 		return nil, errors.New("no available agent client in pool")
 	}
 
+	pool := w.config.AgentPool(workloadType)
+	pooled := 0
+	awaiting := 0
+	incompatible := 0
+	plaintext := 0
+	unsupported := 0
+	var unsupportedReason error
+
 	// there might be a slightly faster version of this, but this effectively
 	// gives us a random pick among the map elements
-	for _, v := range w.pendingAgents {
+	for id, v := range w.pendingAgents {
+		if w.agentPools[id] != pool {
+			continue
+		}
+		pooled++
+
+		if w.states.claimed(id) {
+			continue
+		}
+
+		if _, handshook := w.handshakes[id]; !handshook {
+			awaiting++
+			continue
+		}
+
+		if !v.Compatible() {
+			incompatible++
+			continue
+		}
+
+		if w.config.RequirePayloadEncryption && v.Cipher() == nil {
+			plaintext++
+			continue
+		}
+
+		if err := v.Supports(workloadType, resources); err != nil {
+			unsupported++
+			unsupportedReason = err
+			continue
+		}
+
+		// an agent being stopped can't be reserved
+		if !w.states.reserve(id) {
+			continue
+		}
 		return v, nil
 	}
 
-	return nil, nil
+	if pooled == 0 {
+		return nil, fmt.Errorf("no available agent client in the pool serving %s workloads", workloadType)
+	}
+
+	if unsupported > 0 {
+		return nil, fmt.Errorf("no agent client in pool can run the workload; %d agents are unsuitable (%s)", unsupported, unsupportedReason)
+	}
+
+	if incompatible > 0 {
+		return nil, fmt.Errorf("no compatible agent client in pool; %d agents speak an unsupported protocol version", incompatible)
+	}
+
+	if plaintext > 0 {
+		return nil, fmt.Errorf("no agent client in pool supports payload encryption, which this node requires; %d agents would receive payloads in the clear", plaintext)
+	}
+
+	if awaiting > 0 {
+		return nil, fmt.Errorf("no available agent client in pool; %d agents have not completed their handshake", awaiting)
+	}
+
+	return nil, errors.New("no available agent client in pool; all agents are claimed by deployments in progress")
+}
+
+// Returns a reserved agent that will not receive a deployment to the pool
+func (w *WorkloadManager) ReleaseAgent(agentClient *agentapi.AgentClient) {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	w.states.release(agentClient.ID())
+}
+
+// Samples the internal NATS connections of every pending and active agent
+func (w *WorkloadManager) agentConnectionSamples() []observability.AgentConnectionSample {
+	w.poolMutex.Lock()
+	w.teardownMutex.Lock()
+	ids := make([]string, 0, len(w.pendingAgents)+len(w.activeAgents))
+	for id := range w.pendingAgents {
+		ids = append(ids, id)
+	}
+	for id := range w.activeAgents {
+		ids = append(ids, id)
+	}
+	w.teardownMutex.Unlock()
+	w.poolMutex.Unlock()
+
+	samples := make([]observability.AgentConnectionSample, 0, len(ids))
+	for _, id := range ids {
+		stats, err := w.natsint.AgentConnectionStats(id)
+		if err != nil {
+			continue
+		}
+
+		samples = append(samples, observability.AgentConnectionSample{
+			AgentID:       id,
+			Connections:   int64(stats.Connections),
+			Subscriptions: int64(stats.Subscriptions),
+			PendingBytes:  int64(stats.PendingBytes),
+			InBytes:       stats.InBytes,
+			OutBytes:      stats.OutBytes,
+		})
+	}
+
+	return samples
+}
+
+// Samples the resource usage of the machine running each deployed workload
+func (w *WorkloadManager) machineStatsSamples() []observability.MachineStatsSample {
+	if w.procMan == nil {
+		return nil
+	}
+
+	procs, err := w.procMan.ListProcesses()
+	if err != nil {
+		return nil
+	}
+
+	samples := make([]observability.MachineStatsSample, 0, len(procs))
+	for _, p := range procs {
+		stats, err := w.procMan.MachineStats(p.ID)
+		if err != nil {
+			continue
+		}
+
+		samples = append(samples, observability.MachineStatsSample{
+			WorkloadID:      p.ID,
+			Namespace:       p.Namespace,
+			WorkloadName:    p.Name,
+			CPUTimeMillis:   stats.CPUTimeMillis,
+			MemoryRSSBytes:  stats.MemoryRSSBytes,
+			BlockReadBytes:  stats.BlockReadBytes,
+			BlockWriteBytes: stats.BlockWriteBytes,
+			NetRxBytes:      stats.NetRxBytes,
+			NetTxBytes:      stats.NetTxBytes,
+		})
+	}
+
+	return samples
+}
+
+// Summarizes the version of every agent that has completed its handshake
+func (w *WorkloadManager) AgentSummaries() []controlapi.AgentSummary {
+	w.poolMutex.Lock()
+	w.teardownMutex.Lock()
+	defer w.teardownMutex.Unlock()
+	defer w.poolMutex.Unlock()
+
+	summaries := make([]controlapi.AgentSummary, 0)
+
+	summarize := func(agents map[string]*agentapi.AgentClient, deployed bool) {
+		for id, agentClient := range agents {
+			if _, ok := w.handshakes[id]; !ok {
+				continue
+			}
+
+			summaries = append(summaries, controlapi.AgentSummary{
+				Id:              id,
+				Version:         agentClient.AgentVersion(),
+				ProtocolVersion: agentClient.ProtocolVersion(),
+				Compatible:      agentClient.Compatible(),
+				Deployed:        deployed,
+				Capabilities:    agentClient.Capabilities(),
+			})
+		}
+	}
+
+	summarize(w.activeAgents, true)
+	summarize(w.pendingAgents, false)
+
+	return summaries
 }
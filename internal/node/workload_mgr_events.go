@@ -1,17 +1,22 @@
 package nexnode
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go"
-	"github.com/google/uuid"
 	controlapi "github.com/synadia-io/nex/control-api"
 	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/events"
+	"github.com/synadia-io/nex/internal/node/logsinks"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func (w *WorkloadManager) agentEvent(agentId string, evt cloudevents.Event) {
@@ -24,12 +29,22 @@ func (w *WorkloadManager) agentEvent(agentId string, evt cloudevents.Event) {
 	evt.SetSource(fmt.Sprintf("%s-%s", *deployRequest.TargetNode, agentId))
 	evt.SetExtension(controlapi.EventExtensionNamespace, *deployRequest.Namespace)
 
-	err := PublishCloudEvent(w.nc, *deployRequest.Namespace, evt, w.log)
+	err := PublishCloudEvent(w.events(), *deployRequest.Namespace, evt, w.log)
 	if err != nil {
 		w.log.Error("Failed to publish cloudevent", slog.Any("err", err))
 		return
 	}
 
+	if evt.Type() == agentapi.WorkloadCompiledEventType {
+		w.workloadCompiled(agentId, deployRequest, evt)
+		return
+	}
+
+	if evt.Type() == agentapi.JobCompletedEventType {
+		w.jobCompleted(agentId, deployRequest, evt)
+		return
+	}
+
 	if evt.Type() == agentapi.WorkloadUndeployedEventType {
 		_ = w.StopWorkload(agentId, false)
 
@@ -63,25 +78,11 @@ func (w *WorkloadManager) agentEvent(agentId string, evt cloudevents.Event) {
 			retriedAt := time.Now().UTC()
 			deployRequest.RetriedAt = &retriedAt
 
-			req, _ := json.Marshal(&controlapi.DeployRequest{
-				Argv:            deployRequest.Argv,
-				Description:     deployRequest.Description,
-				WorkloadType:    deployRequest.WorkloadType,
-				Location:        deployRequest.Location,
-				WorkloadJwt:     deployRequest.WorkloadJwt,
-				Environment:     deployRequest.EncryptedEnvironment,
-				Essential:       deployRequest.Essential,
-				RetriedAt:       deployRequest.RetriedAt,
-				RetryCount:      deployRequest.RetryCount,
-				SenderPublicKey: deployRequest.SenderPublicKey,
-				TargetNode:      deployRequest.TargetNode,
-				TriggerSubjects: deployRequest.TriggerSubjects,
-				JsDomain:        deployRequest.JsDomain,
-			})
-
-			nodeID := w.publicKey
-			subject := fmt.Sprintf("%s.DEPLOY.%s.%s", controlapi.APIPrefix, *deployRequest.Namespace, nodeID)
-			_, err = w.nc.Request(subject, req, time.Millisecond*2500)
+			if w.quarantineCrashed(agentId, deployRequest) {
+				return
+			}
+
+			_, err = w.RedeployWorkload(deployRequest)
 			if err != nil {
 				w.log.Error("Failed to redeploy essential workload", slog.Any("err", err))
 			}
@@ -89,6 +90,71 @@ func (w *WorkloadManager) agentEvent(agentId string, evt cloudevents.Event) {
 	}
 }
 
+// Records compile time and cache metrics for a workload compiled by its agent, retaining freshly
+// compiled artifacts so later deployments of the same workload can skip compilation
+func (w *WorkloadManager) workloadCompiled(agentId string, deployRequest *agentapi.DeployRequest, evt cloudevents.Event) {
+	var compiled agentapi.WorkloadCompiledEvent
+	err := json.Unmarshal(evt.Data(), &compiled)
+	if err != nil {
+		w.log.Error("Failed to unmarshal workload compiled event", slog.Any("err", err))
+		return
+	}
+
+	namespace := attribute.String("namespace", *deployRequest.Namespace)
+	workloadName := attribute.String("workload_name", *deployRequest.WorkloadName)
+
+	w.t.FunctionCompileTimeNano.Add(w.ctx, compiled.CompileTimeNanos)
+	w.t.FunctionCompileTimeNano.Add(w.ctx, compiled.CompileTimeNanos, metric.WithAttributes(namespace))
+	w.t.FunctionCompileTimeNano.Add(w.ctx, compiled.CompileTimeNanos, metric.WithAttributes(workloadName))
+
+	cacheCounter := w.t.FunctionCompileCacheMisses
+	if compiled.CacheHit {
+		cacheCounter = w.t.FunctionCompileCacheHits
+	}
+	cacheCounter.Add(w.ctx, 1)
+	cacheCounter.Add(w.ctx, 1, metric.WithAttributes(namespace))
+	cacheCounter.Add(w.ctx, 1, metric.WithAttributes(workloadName))
+
+	w.log.Debug("Workload compiled",
+		slog.String("vmid", agentId),
+		slog.String("workload_name", *deployRequest.WorkloadName),
+		slog.Bool("cache_hit", compiled.CacheHit),
+		slog.Duration("compile_time", time.Duration(compiled.CompileTimeNanos)),
+	)
+
+	if compiled.CacheHit || compiled.CacheKey == "" {
+		return
+	}
+
+	artifact, err := w.natsint.GetObjectForID(agentId, compiled.CacheKey)
+	if err != nil {
+		w.log.Warn("Failed to retrieve compiled workload from agent cache", slog.Any("err", err), slog.String("key", compiled.CacheKey))
+		return
+	}
+
+	w.compiled.put(compiled.CacheKey, artifact)
+}
+
+// Records the exit status of a completed job. The agent follows this event with an undeployed
+// event, upon which the node recycles the agent
+func (w *WorkloadManager) jobCompleted(agentId string, deployRequest *agentapi.DeployRequest, evt cloudevents.Event) {
+	var completed agentapi.JobCompletedEvent
+	err := json.Unmarshal(evt.Data(), &completed)
+	if err != nil {
+		w.log.Error("Failed to unmarshal job completed event", slog.Any("err", err))
+		return
+	}
+
+	w.jobs.completed(agentId, *deployRequest.Namespace, completed)
+
+	w.log.Info("Job completed",
+		slog.String("vmid", agentId),
+		slog.String("workload_name", completed.WorkloadName),
+		slog.Int("exit_code", completed.ExitCode),
+		slog.Duration("runtime", completed.CompletedAt.Sub(completed.StartedAt)),
+	)
+}
+
 func (w *WorkloadManager) agentLog(workloadId string, entry agentapi.LogEntry) {
 	deployRequest, _ := w.procMan.Lookup(workloadId)
 	if deployRequest == nil {
@@ -98,9 +164,11 @@ func (w *WorkloadManager) agentLog(workloadId string, entry agentapi.LogEntry) {
 	}
 
 	bytes, err := json.Marshal(&emittedLog{
-		Text:  entry.Text,
-		Level: slog.Level(entry.Level),
-		ID:    workloadId,
+		Text:   entry.Text,
+		Level:  entry.Level.SlogLevel(),
+		ID:     workloadId,
+		Source: entry.Source,
+		Fields: entry.Fields,
 	})
 	if err != nil {
 		w.log.Error("Failed to marshal our own log entry", slog.Any("err", err))
@@ -108,31 +176,86 @@ func (w *WorkloadManager) agentLog(workloadId string, entry agentapi.LogEntry) {
 	}
 
 	subject := logPublishSubject(*deployRequest.Namespace, w.publicKey, workloadId, deployRequest.WorkloadName)
-	_ = w.nc.Publish(subject, bytes)
+	_ = w.events().Publish(subject, bytes)
+
+	record := logsinks.Record{
+		Time:       time.Now().UTC(),
+		Level:      entry.Level.SlogLevel(),
+		NodeId:     w.publicKey,
+		Namespace:  *deployRequest.Namespace,
+		WorkloadId: workloadId,
+		Source:     entry.Source,
+		Text:       entry.Text,
+		Fields:     entry.Fields,
+	}
+	if deployRequest.WorkloadName != nil {
+		record.WorkloadName = *deployRequest.WorkloadName
+	}
+	w.logSinks.Route(record)
+
+	w.emitOtelLog(record)
+}
+
+// Emits a workload log record through the OTel logs pipeline. The record is correlated with the
+// trace named in its fields or, failing that, with the trigger execution in flight when it arrived
+func (w *WorkloadManager) emitOtelLog(record logsinks.Record) {
+	if w.t == nil || !w.t.LogsEnabled() {
+		return
+	}
+
+	ctx := context.Background()
+	sc, ok := spanContextFromFields(record.Fields)
+	if !ok {
+		sc, ok = w.executions.current(record.WorkloadId)
+	}
+	if ok {
+		ctx = trace.ContextWithSpanContext(ctx, sc)
+	}
+
+	attrs := []otellog.KeyValue{
+		otellog.String("node_id", record.NodeId),
+		otellog.String("namespace", record.Namespace),
+		otellog.String("workload_id", record.WorkloadId),
+		otellog.String("workload_name", record.WorkloadName),
+		otellog.String("source", record.Source),
+	}
+	for key, value := range record.Fields {
+		if key == "trace_id" || key == "span_id" {
+			continue
+		}
+		attrs = append(attrs, otelLogAttribute(key, value))
+	}
+
+	w.t.EmitLog(ctx, record.Time, record.Level, record.Text, attrs...)
+}
+
+// Converts a structured log field to a log attribute, encoding values without a scalar
+// representation as JSON
+func otelLogAttribute(key string, value interface{}) otellog.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return otellog.String(key, v)
+	case float64:
+		return otellog.Float64(key, v)
+	case bool:
+		return otellog.Bool(key, v)
+	default:
+		raw, _ := json.Marshal(v)
+		return otellog.String(key, string(raw))
+	}
 }
 
 func (w *WorkloadManager) publishFunctionExecFailed(workloadId string, workloadName string, namespace string, tsub string, origErr error) error {
-	functionExecFailed := struct {
-		Name      string `json:"workload_name"`
-		Subject   string `json:"trigger_subject"`
-		Namespace string `json:"namespace"`
-		Error     string `json:"error"`
-	}{
+	functionExecFailed := controlapi.FunctionExecFailedEvent{
 		Name:      workloadName,
 		Namespace: namespace,
 		Subject:   tsub,
 		Error:     origErr.Error(),
 	}
 
-	cloudevent := cloudevents.NewEvent()
-	cloudevent.SetSource(w.publicKey)
-	cloudevent.SetID(uuid.NewString())
-	cloudevent.SetTime(time.Now().UTC())
-	cloudevent.SetType(agentapi.FunctionExecutionFailedType)
-	cloudevent.SetDataContentType(cloudevents.ApplicationJSON)
-	_ = cloudevent.SetData(functionExecFailed)
+	cloudevent := events.FunctionExecFailed(w.publicKey, functionExecFailed)
 
-	err := PublishCloudEvent(w.nc, namespace, cloudevent, w.log)
+	err := PublishCloudEvent(w.events(), namespace, cloudevent, w.log)
 	if err != nil {
 		return err
 	}
@@ -145,12 +268,12 @@ func (w *WorkloadManager) publishFunctionExecFailed(workloadId string, workloadN
 	logBytes, _ := json.Marshal(emitLog)
 
 	subject := fmt.Sprintf("%s.%s.%s.%s.%s", LogSubjectPrefix, namespace, w.publicKey, workloadName, workloadId)
-	err = w.nc.Publish(subject, logBytes)
+	err = w.events().Publish(subject, logBytes)
 	if err != nil {
 		w.log.Error("Failed to publish function exec failed log", slog.Any("err", err))
 	}
 
-	return w.nc.Flush()
+	return w.events().Flush()
 }
 
 func (w *WorkloadManager) publishFunctionExecSucceeded(workloadId string, tsub string, elapsedNanos int64) error {
@@ -160,27 +283,16 @@ func (w *WorkloadManager) publishFunctionExecSucceeded(workloadId string, tsub s
 		return nil
 	}
 
-	functionExecPassed := struct {
-		Name      string `json:"workload_name"`
-		Subject   string `json:"trigger_subject"`
-		Elapsed   int64  `json:"elapsed_nanos"`
-		Namespace string `json:"namespace"`
-	}{
+	functionExecPassed := controlapi.FunctionExecSucceededEvent{
 		Name:      *deployRequest.WorkloadName,
 		Subject:   tsub,
 		Elapsed:   elapsedNanos,
 		Namespace: *deployRequest.Namespace,
 	}
 
-	cloudevent := cloudevents.NewEvent()
-	cloudevent.SetSource(w.publicKey)
-	cloudevent.SetID(uuid.NewString())
-	cloudevent.SetTime(time.Now().UTC())
-	cloudevent.SetType(agentapi.FunctionExecutionSucceededType)
-	cloudevent.SetDataContentType(cloudevents.ApplicationJSON)
-	_ = cloudevent.SetData(functionExecPassed)
+	cloudevent := events.FunctionExecSucceeded(w.publicKey, functionExecPassed)
 
-	err = PublishCloudEvent(w.nc, *deployRequest.Namespace, cloudevent, w.log)
+	err = PublishCloudEvent(w.events(), *deployRequest.Namespace, cloudevent, w.log)
 	if err != nil {
 		return err
 	}
@@ -193,47 +305,33 @@ func (w *WorkloadManager) publishFunctionExecSucceeded(workloadId string, tsub s
 	logBytes, _ := json.Marshal(emitLog)
 
 	subject := fmt.Sprintf("%s.%s.%s.%s.%s", LogSubjectPrefix, *deployRequest.Namespace, w.publicKey, *deployRequest.WorkloadName, workloadId)
-	err = w.nc.Publish(subject, logBytes)
+	err = w.events().Publish(subject, logBytes)
 	if err != nil {
 		w.log.Error("Failed to publish function exec passed log", slog.Any("err", err))
 	}
 
-	return w.nc.Flush()
+	return w.events().Flush()
 }
 
-// publishWorkloadStopped writes a workload stopped event for the provided workload
-func (w *WorkloadManager) publishWorkloadStopped(workloadId string) error {
-	deployRequest, err := w.procMan.Lookup(workloadId)
-	if err != nil {
-		w.log.Error("Failed to look up workload", slog.String("workload_id", workloadId), slog.Any("error", err))
-		return errors.New("workload stopped event was not published")
-	}
+// publishWorkloadStopped writes a workload stopped event for the provided workload. Agents that
+// never received a workload have nothing to report
+func (w *WorkloadManager) publishWorkloadStopped(workloadId string, deployRequest *agentapi.DeployRequest) error {
 	if deployRequest == nil {
-		w.log.Warn("Tried to publish stopped event for non-existent workload", slog.String("workload_id", workloadId))
-		return errors.New("workload stopped event was not published")
+		w.log.Debug("No workload stopped event for agent without a workload", slog.String("workload_id", workloadId))
+		return nil
 	}
 
 	workloadName := strings.TrimSpace(deployRequest.DecodedClaims.Subject)
 	if len(workloadName) > 0 {
-		workloadStopped := struct {
-			Name   string `json:"name"`
-			Reason string `json:"reason,omitempty"`
-			VmId   string `json:"vmid"`
-		}{
+		workloadStopped := controlapi.WorkloadStoppedEvent{
 			Name:   workloadName,
 			Reason: "Workload shutdown requested",
 			VmId:   workloadId,
 		}
 
-		cloudevent := cloudevents.NewEvent()
-		cloudevent.SetSource(w.publicKey)
-		cloudevent.SetID(uuid.NewString())
-		cloudevent.SetTime(time.Now().UTC())
-		cloudevent.SetType(agentapi.WorkloadUndeployedEventType)
-		cloudevent.SetDataContentType(cloudevents.ApplicationJSON)
-		_ = cloudevent.SetData(workloadStopped)
+		cloudevent := events.WorkloadStopped(w.publicKey, workloadStopped)
 
-		err := PublishCloudEvent(w.nc, *deployRequest.Namespace, cloudevent, w.log)
+		err := PublishCloudEvent(w.events(), *deployRequest.Namespace, cloudevent, w.log)
 		if err != nil {
 			return err
 		}
@@ -246,17 +344,44 @@ func (w *WorkloadManager) publishWorkloadStopped(workloadId string) error {
 		logBytes, _ := json.Marshal(emitLog)
 
 		subject := fmt.Sprintf("%s.%s.%s.%s.%s", LogSubjectPrefix, *deployRequest.Namespace, w.publicKey, workloadName, workloadId)
-		err = w.nc.Publish(subject, logBytes)
+		err = w.events().Publish(subject, logBytes)
 		if err != nil {
 			w.log.Error("Failed to publish machine stopped event", slog.Any("err", err))
 		}
 
-		return w.nc.Flush()
+		return w.events().Flush()
 	}
 
 	return nil
 }
 
+// Publishes an event for a phase of stopping the given workload
+func (w *WorkloadManager) publishWorkloadStopping(workloadId string, deployRequest *agentapi.DeployRequest, phase string) error {
+	workloadStopping := controlapi.WorkloadStoppingEvent{
+		Name:              strings.TrimSpace(deployRequest.DecodedClaims.Subject),
+		VmId:              workloadId,
+		Phase:             phase,
+		GracePeriodMillis: deployRequest.StopGracePeriodMillis,
+	}
+
+	cloudevent := events.WorkloadStopping(w.publicKey, workloadStopping)
+
+	return PublishCloudEvent(w.events(), *deployRequest.Namespace, cloudevent, w.log)
+}
+
+func (w *WorkloadManager) publishWorkloadStateChanged(workloadId string, deployRequest *agentapi.DeployRequest, from workloadState, to workloadState) error {
+	stateChanged := controlapi.WorkloadStateChangedEvent{
+		Name: strings.TrimSpace(deployRequest.DecodedClaims.Subject),
+		VmId: workloadId,
+		From: string(from),
+		To:   string(to),
+	}
+
+	cloudevent := events.WorkloadStateChanged(w.publicKey, stateChanged)
+
+	return PublishCloudEvent(w.events(), *deployRequest.Namespace, cloudevent, w.log)
+}
+
 func logPublishSubject(namespace string, node string, vm string, workload *string) string {
 	// $NEX.logs.{namespace}.{node}.{vm}[.{workload name}]
 	subject := fmt.Sprintf("%s.%s.%s.%s", LogSubjectPrefix, namespace, node, vm)
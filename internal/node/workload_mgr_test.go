@@ -0,0 +1,288 @@
+package nexnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestReserveAgentClaimsEachAgentOnce(t *testing.T) {
+	nc := startTestNats(t)
+	w := newTestWorkloadManager(&models.NodeConfiguration{})
+	idle := handshakenAgent(t, nc, w, "idle", true)
+
+	agentClient, err := w.ReserveAgent(controlapi.NexWorkloadNative, nil)
+	if err != nil {
+		t.Fatalf("Expected idle agent to be reserved: %s", err)
+	}
+
+	if _, err := w.ReserveAgent(controlapi.NexWorkloadNative, nil); err == nil {
+		t.Fatal("Expected reservation to fail while the only agent is claimed")
+	}
+
+	if _, _, err := w.states.transition(idle.ID(), workloadStateDeploying, nil); err != nil {
+		t.Fatalf("Expected the reserved agent to start deploying: %s", err)
+	}
+	w.ReleaseAgent(agentClient)
+	if !w.states.claimed(idle.ID()) {
+		t.Fatal("Expected release to leave an agent with a deployment in progress claimed")
+	}
+}
+
+func TestReserveAgentSelectsFromWorkloadTypePool(t *testing.T) {
+	config := &models.NodeConfiguration{
+		WorkloadTypes: []controlapi.NexWorkload{controlapi.NexWorkloadNative, controlapi.NexWorkloadV8},
+		WorkloadPools: map[controlapi.NexWorkload]models.WorkloadPoolConfig{
+			controlapi.NexWorkloadV8: {PoolSize: 1},
+		},
+	}
+
+	nc := startTestNats(t)
+	w := newTestWorkloadManager(config)
+	shared := handshakenAgent(t, nc, w, "shared", true)
+	w.agentPools[shared.ID()] = models.DefaultAgentPool
+
+	if _, err := w.ReserveAgent(controlapi.NexWorkloadV8, nil); err == nil {
+		t.Fatal("Expected a v8 workload not to be handed an agent from the shared pool")
+	}
+
+	if _, err := w.ReserveAgent(controlapi.NexWorkloadNative, nil); err != nil {
+		t.Fatalf("Expected a native workload to be handed an agent from the shared pool: %s", err)
+	}
+}
+
+func TestReserveAgentSkipsAgentsAwaitingHandshake(t *testing.T) {
+	w := newTestWorkloadManager(&models.NodeConfiguration{})
+	w.pendingAgents["starting"] = &agentapi.AgentClient{}
+
+	if _, err := w.ReserveAgent(controlapi.NexWorkloadNative, nil); err == nil {
+		t.Fatal("Expected an agent that has not completed its handshake not to be reserved")
+	}
+}
+
+func TestReserveAgentRefusesPlaintextAgentsWhenEncryptionRequired(t *testing.T) {
+	nc := startTestNats(t)
+	w := newTestWorkloadManager(&models.NodeConfiguration{RequirePayloadEncryption: true})
+	handshakenAgent(t, nc, w, "plaintext", false)
+
+	if _, err := w.ReserveAgent(controlapi.NexWorkloadNative, nil); err == nil {
+		t.Fatal("Expected an agent without payload encryption not to be reserved")
+	}
+
+	encrypted := handshakenAgent(t, nc, w, "encrypted", true)
+	agentClient, err := w.ReserveAgent(controlapi.NexWorkloadNative, nil)
+	if err != nil {
+		t.Fatalf("Expected the agent with payload encryption to be reserved: %s", err)
+	}
+	if agentClient.ID() != encrypted.ID() {
+		t.Fatalf("Expected agent %s to be reserved, got %s", encrypted.ID(), agentClient.ID())
+	}
+}
+
+// Deploys, stops and reaps agents at the same time. Run with -race to check that the agent maps
+// are only touched under the pool mutex
+func TestAgentBookkeepingDuringConcurrentDeployStopAndReap(t *testing.T) {
+	nc := startTestNats(t)
+	w := newTestWorkloadManager(&models.NodeConfiguration{})
+
+	ids := make([]string, 0, 6)
+	for i := 0; i < 6; i++ {
+		ids = append(ids, handshakenAgent(t, nc, w, fmt.Sprintf("agent-%d", i), true).ID())
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+		for range ids {
+			agentClient, err := w.ReserveAgent(controlapi.NexWorkloadNative, nil)
+			if err != nil {
+				continue
+			}
+
+			id := agentClient.ID()
+			if _, _, err := w.states.transition(id, workloadStateDeploying, nil); err != nil {
+				w.ReleaseAgent(agentClient)
+				continue
+			}
+			if _, _, err := w.states.transition(id, workloadStateRunning, nil); err == nil {
+				w.promoteAgent(id, agentClient)
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for _, id := range ids {
+			if _, _, err := w.states.transition(id, workloadStateStopping, nil); err != nil {
+				continue
+			}
+			_ = w.workloadAgent(id)
+			w.forgetAgent(id)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		// none of the agents answer pings, but a single pass never reaches the reap threshold
+		for range ids {
+			w.reapPendingAgents(make(map[string]int))
+		}
+	}()
+
+	wg.Wait()
+
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+	if len(w.pendingAgents) != 0 {
+		t.Fatalf("Expected every stopped agent to have left the pending agents, got %d", len(w.pendingAgents))
+	}
+}
+
+func TestRedeploysAreSignedByTheNode(t *testing.T) {
+	kp, _ := nkeys.CreateServer()
+	now := time.Now()
+
+	msg := nats.NewMsg("$NEX.DEPLOY.default.NODE")
+	msg.Data = []byte(`{"type":"native"}`)
+	if err := signRedeploy(kp, msg, now); err != nil {
+		t.Fatalf("Failed to sign redeploy: %s", err)
+	}
+	if !verifyRedeploy(kp, msg, now) {
+		t.Fatal("Expected a redeploy signed by the node to be verified")
+	}
+
+	if verifyRedeploy(kp, msg, now.Add(2*redeploySignatureWindow)) {
+		t.Fatal("Expected a redeploy signed outside the window not to be verified")
+	}
+
+	other, _ := nkeys.CreateServer()
+	if verifyRedeploy(other, msg, now) {
+		t.Fatal("Expected a redeploy signed by another node not to be verified")
+	}
+
+	msg.Data = []byte(`{"type":"oci"}`)
+	if verifyRedeploy(kp, msg, now) {
+		t.Fatal("Expected a tampered redeploy not to be verified")
+	}
+
+	spoofed := nats.NewMsg("$NEX.DEPLOY.default.NODE")
+	spoofed.Header.Set(redeployHeader, "true")
+	if verifyRedeploy(kp, spoofed, now) {
+		t.Fatal("Expected an unsigned redeploy header not to be trusted")
+	}
+}
+
+func TestAgentRejectionCarriesReason(t *testing.T) {
+	message := "unsupported wasm binary"
+	rejection := agentRejection(&agentapi.DeployResponse{Message: &message, Reason: controlapi.DeployRejectionValidationFailed})
+	if rejection.Reason != controlapi.DeployRejectionValidationFailed || rejection.RetryElsewhere() {
+		t.Fatalf("Expected the agent's validation failure, got %+v", rejection)
+	}
+
+	rejection = agentRejection(&agentapi.DeployResponse{Message: &message})
+	if rejection.Reason != controlapi.DeployRejectionFailed || !rejection.RetryElsewhere() {
+		t.Fatalf("Expected a rejection without a reason to be a retryable failure, got %+v", rejection)
+	}
+}
+
+func newTestWorkloadManager(config *models.NodeConfiguration) *WorkloadManager {
+	return &WorkloadManager{
+		config:        config,
+		log:           slog.Default(),
+		poolMutex:     &sync.Mutex{},
+		states:        newWorkloadStates(),
+		agentPools:    make(map[string]controlapi.NexWorkload),
+		handshakes:    make(map[string]string),
+		pendingAgents: make(map[string]*agentapi.AgentClient),
+		activeAgents:  make(map[string]*agentapi.AgentClient),
+	}
+}
+
+func startTestNats(t *testing.T) *nats.Conn {
+	s, err := server.NewServer(&server.Options{Port: -1})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %s", err)
+	}
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err)
+	}
+	t.Cleanup(nc.Close)
+
+	return nc
+}
+
+// Adds a pending agent to the workload manager and completes its handshake the way an agent
+// would, optionally offering an xkey for payload encryption
+func handshakenAgent(t *testing.T, nc *nats.Conn, w *WorkloadManager, id string, encrypted bool) *agentapi.AgentClient {
+	noop := func(string) {}
+	agentClient := agentapi.NewAgentClient(nc, slog.Default(), time.Minute, time.Second, noop, w.agentHandshakeSucceeded, noop, nil, nil)
+
+	w.poolMutex.Lock()
+	w.pendingAgents[id] = agentClient
+	w.poolMutex.Unlock()
+	w.states.track(id)
+
+	err := agentClient.Start(id)
+	if err != nil {
+		t.Fatalf("Failed to start agent client: %s", err)
+	}
+	t.Cleanup(func() { _ = agentClient.Stop() })
+
+	message := "ready"
+	req := agentapi.HandshakeRequest{
+		ID:              &id,
+		StartTime:       time.Now().UTC(),
+		Message:         &message,
+		ProtocolVersion: agentapi.AgentProtocolVersion,
+	}
+	if encrypted {
+		kp, _ := nkeys.CreateCurveKeys()
+		req.XKey, _ = kp.PublicKey()
+	}
+
+	raw, _ := json.Marshal(req)
+	resp, err := nc.Request(fmt.Sprintf("hostint.%s.handshake", id), raw, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to complete agent handshake: %s", err)
+	}
+
+	var handshakeResponse agentapi.HandshakeResponse
+	_ = json.Unmarshal(resp.Data, &handshakeResponse)
+	if !handshakeResponse.Compatible {
+		t.Fatalf("Expected agent %s to be compatible", id)
+	}
+
+	// the handshake is recorded by the node after it replies
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		w.poolMutex.Lock()
+		_, handshook := w.handshakes[id]
+		w.poolMutex.Unlock()
+		if handshook {
+			return agentClient
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Agent %s handshake was not recorded", id)
+	return nil
+}
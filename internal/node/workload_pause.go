@@ -0,0 +1,224 @@
+package nexnode
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+	"github.com/synadia-io/nex/internal/events"
+)
+
+// A workload suspended without being undeployed, with what is needed to resume it
+type pausedWorkload struct {
+	subjects      []string
+	machinePaused bool
+}
+
+// Drains a running workload's trigger subscriptions and, if asked, pauses its machine. The agent
+// and its workload stay deployed, so resuming picks up exactly where the workload left off
+func (w *WorkloadManager) PauseWorkload(workloadID string, pauseMachine bool) (*controlapi.PauseResponse, error) {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	agentClient, ok := w.activeAgents[workloadID]
+	if !ok {
+		return nil, fmt.Errorf("no such workload: %s", workloadID)
+	}
+
+	if _, paused := w.paused[workloadID]; paused {
+		return nil, errors.New("workload is already paused")
+	}
+
+	subz := w.subz[workloadID]
+	delete(w.subz, workloadID)
+
+	subjects := make([]string, 0, len(subz))
+	for _, sub := range subz {
+		subjects = append(subjects, sub.Subject)
+		w.drainTriggerSubscription(workloadID, sub)
+	}
+
+	if pauseMachine {
+		// the agent cannot answer health checks while its machine is paused
+		agentClient.SetPaused(true)
+
+		err := w.procMan.PauseProcess(workloadID)
+		if err != nil {
+			agentClient.SetPaused(false)
+			if subErr := w.subscribeTriggers(workloadID, subjects); subErr != nil {
+				w.log.Error("Failed to restore trigger subscriptions of workload that could not be paused",
+					slog.String("workload_id", workloadID),
+					slog.Any("err", subErr),
+				)
+			}
+			return nil, err
+		}
+	}
+
+	w.paused[workloadID] = &pausedWorkload{
+		subjects:      subjects,
+		machinePaused: pauseMachine,
+	}
+
+	w.log.Info("Paused workload",
+		slog.String("workload_id", workloadID),
+		slog.Bool("machine_paused", pauseMachine),
+		slog.Any("trigger_subjects", subjects),
+	)
+	w.publishWorkloadPause(workloadID, pauseMachine, subjects, true)
+
+	return &controlapi.PauseResponse{
+		WorkloadId:      workloadID,
+		Paused:          true,
+		MachinePaused:   pauseMachine,
+		TriggerSubjects: subjects,
+	}, nil
+}
+
+// Resumes a paused workload's machine, if it was paused, and resubscribes its trigger subjects
+func (w *WorkloadManager) ResumeWorkload(workloadID string) (*controlapi.PauseResponse, error) {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	paused, ok := w.paused[workloadID]
+	if !ok {
+		return nil, errors.New("workload is not paused")
+	}
+
+	if paused.machinePaused {
+		err := w.procMan.ResumeProcess(workloadID)
+		if err != nil {
+			return nil, err
+		}
+
+		paused.machinePaused = false
+		if agentClient, ok := w.activeAgents[workloadID]; ok {
+			agentClient.SetPaused(false)
+		}
+	}
+
+	err := w.subscribeTriggers(workloadID, paused.subjects)
+	if err != nil {
+		return nil, err
+	}
+	delete(w.paused, workloadID)
+
+	w.log.Info("Resumed workload",
+		slog.String("workload_id", workloadID),
+		slog.Any("trigger_subjects", paused.subjects),
+	)
+	w.publishWorkloadPause(workloadID, false, paused.subjects, false)
+
+	return &controlapi.PauseResponse{
+		WorkloadId:      workloadID,
+		TriggerSubjects: paused.subjects,
+	}, nil
+}
+
+func (w *WorkloadManager) isPaused(workloadID string) bool {
+	w.poolMutex.Lock()
+	defer w.poolMutex.Unlock()
+
+	_, paused := w.paused[workloadID]
+	return paused
+}
+
+// Forgets a workload's pause ahead of stopping it, resuming its machine so that it can be
+// undeployed gracefully
+func (w *WorkloadManager) unpauseForStop(workloadID string, agentClient *agentapi.AgentClient) {
+	w.poolMutex.Lock()
+	paused, ok := w.paused[workloadID]
+	delete(w.paused, workloadID)
+	w.poolMutex.Unlock()
+
+	if !ok || !paused.machinePaused {
+		return
+	}
+
+	err := w.procMan.ResumeProcess(workloadID)
+	if err != nil {
+		w.log.Warn("Failed to resume paused machine of stopping workload",
+			slog.String("workload_id", workloadID),
+			slog.Any("err", err),
+		)
+		return
+	}
+
+	if agentClient != nil {
+		agentClient.SetPaused(false)
+	}
+}
+
+// Subscribes a running workload to the given trigger subjects. A paused workload instead has the
+// subjects added to those it resubscribes when resumed. The caller must hold the pool mutex
+func (w *WorkloadManager) subscribeTriggers(workloadID string, subjects []string) error {
+	if len(subjects) == 0 {
+		return nil
+	}
+
+	if paused, ok := w.paused[workloadID]; ok {
+		paused.subjects = append(paused.subjects, subjects...)
+		return nil
+	}
+
+	agentClient, ok := w.activeAgents[workloadID]
+	if !ok {
+		return fmt.Errorf("no such workload: %s", workloadID)
+	}
+
+	request, err := w.procMan.Lookup(workloadID)
+	if err != nil {
+		return err
+	}
+
+	nc, ok := w.hostServices.server.HostServicesConnection(workloadID)
+	if !ok {
+		return fmt.Errorf("workload %s has no host services connection", workloadID)
+	}
+
+	pool, ok := w.triggerPools[workloadID]
+	if !ok {
+		return fmt.Errorf("workload %s has no trigger worker pool", workloadID)
+	}
+
+	subz := make([]*nats.Subscription, 0, len(subjects))
+	for _, subject := range subjects {
+		sub, err := nc.QueueSubscribe(subject, triggerQueueGroup(workloadID), w.generateTriggerHandler(agentClient, subject, request, pool))
+		if err != nil {
+			for _, sub := range subz {
+				_ = sub.Unsubscribe()
+			}
+			return fmt.Errorf("failed to resubscribe workload %s to trigger subject %s: %s", workloadID, subject, err)
+		}
+		subz = append(subz, sub)
+	}
+
+	w.subz[workloadID] = append(w.subz[workloadID], subz...)
+	return nil
+}
+
+func (w *WorkloadManager) publishWorkloadPause(workloadID string, machinePaused bool, subjects []string, paused bool) {
+	request, err := w.procMan.Lookup(workloadID)
+	if err != nil || request == nil || request.Namespace == nil {
+		return
+	}
+
+	evt := controlapi.WorkloadPauseEvent{
+		WorkloadId:      workloadID,
+		Namespace:       *request.Namespace,
+		MachinePaused:   machinePaused,
+		TriggerSubjects: subjects,
+	}
+	if request.WorkloadName != nil {
+		evt.Name = *request.WorkloadName
+	}
+
+	cloudevent := events.WorkloadResumed(w.publicKey, evt)
+	if paused {
+		cloudevent = events.WorkloadPaused(w.publicKey, evt)
+	}
+	_ = PublishCloudEvent(w.events(), evt.Namespace, cloudevent, w.log)
+}
@@ -0,0 +1,268 @@
+package nexnode
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	agentapi "github.com/synadia-io/nex/internal/agent-api"
+)
+
+// Lifecycle state of a workload on this node, from the moment its agent's process starts
+type workloadState string
+
+const (
+	// The agent's process has started and awaits a deployment, possibly reserved for one
+	workloadStatePending workloadState = "pending"
+
+	// Prepared by the process manager and awaiting the agent's reply to the deployment
+	workloadStateDeploying workloadState = "deploying"
+
+	// Accepted by the agent, or restored from a checkpoint, and receiving triggers
+	workloadStateRunning workloadState = "running"
+
+	// Being undeployed and torn down; no further deploy or stop may start
+	workloadStateStopping workloadState = "stopping"
+
+	// Torn down; workloads are no longer tracked once they reach this state
+	workloadStateStopped workloadState = "stopped"
+)
+
+var (
+	// Returned when stopping a workload that this node does not know of
+	ErrWorkloadNotFound = errors.New("workload not found")
+
+	// Returned when stopping a workload that is being stopped, or has recently been stopped
+	ErrWorkloadAlreadyStopped = errors.New("workload already stopped")
+)
+
+// Number of recently stopped workloads remembered, so that repeated requests to stop them are
+// reported as already stopped rather than not found
+const stoppedWorkloadHistory = 1024
+
+// States each state may move to. Stopping is reachable from every live state so that a
+// workload can always be torn down, but only once
+var workloadTransitions = map[workloadState][]workloadState{
+	workloadStatePending:   {workloadStateDeploying, workloadStateStopping},
+	workloadStateDeploying: {workloadStateRunning, workloadStateStopping},
+	workloadStateRunning:   {workloadStateStopping},
+	workloadStateStopping:  {workloadStateStopped},
+}
+
+type workloadLifecycle struct {
+	state workloadState
+
+	// Claimed by a deployment that has not yet been submitted to the agent
+	reserved bool
+
+	// Deploy request of the workload, known from the moment it starts deploying
+	request *agentapi.DeployRequest
+}
+
+// Tracks the lifecycle state of each workload, serializing deploys, stops and triggers on the
+// same workload. Invalid transitions, such as stopping a workload twice or deploying to an agent
+// that is being stopped, are rejected
+type workloadStates struct {
+	mutex     sync.Mutex
+	workloads map[string]*workloadLifecycle
+
+	// Recently stopped workloads, oldest first
+	stopped      map[string]struct{}
+	stoppedOrder []string
+}
+
+func newWorkloadStates() *workloadStates {
+	return &workloadStates{
+		workloads: make(map[string]*workloadLifecycle),
+		stopped:   make(map[string]struct{}),
+	}
+}
+
+// Starts tracking the workload of a newly started agent process as pending
+func (s *workloadStates) track(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.workloads[id] = &workloadLifecycle{state: workloadStatePending}
+	delete(s.stopped, id)
+}
+
+// Returns the workload's current state, which is stopped for workloads that aren't tracked
+func (s *workloadStates) state(id string) workloadState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lifecycle, ok := s.workloads[id]
+	if !ok {
+		return workloadStateStopped
+	}
+
+	return lifecycle.state
+}
+
+// Returns a copy of the lifecycle of every tracked workload, keyed by ID
+func (s *workloadStates) snapshot() map[string]workloadLifecycle {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lifecycles := make(map[string]workloadLifecycle, len(s.workloads))
+	for id, lifecycle := range s.workloads {
+		lifecycles[id] = *lifecycle
+	}
+
+	return lifecycles
+}
+
+// Claims a pending, unclaimed workload's agent for a deployment
+func (s *workloadStates) reserve(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lifecycle, ok := s.workloads[id]
+	if !ok || lifecycle.state != workloadStatePending || lifecycle.reserved {
+		return false
+	}
+
+	lifecycle.reserved = true
+	return true
+}
+
+// Reports whether a pending workload's agent is reserved for a deployment
+func (s *workloadStates) reserved(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lifecycle, ok := s.workloads[id]
+	return ok && lifecycle.reserved
+}
+
+// Returns a reserved agent that will not receive its deployment to the pool
+func (s *workloadStates) release(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if lifecycle, ok := s.workloads[id]; ok && lifecycle.state == workloadStatePending {
+		lifecycle.reserved = false
+	}
+}
+
+// Reports whether the workload's agent is claimed by a deployment in progress, which makes it
+// unavailable to other deployments and to the reaper
+func (s *workloadStates) claimed(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lifecycle, ok := s.workloads[id]
+	return ok && lifecycle.claimed()
+}
+
+// Returns the number of deployments in progress
+func (s *workloadStates) claimedCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	count := 0
+	for _, lifecycle := range s.workloads {
+		if lifecycle.claimed() {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Moves the workload to the given state, returning the state it left along with the workload's
+// deploy request, which is recorded when given. A workload that reaches the stopped state is no
+// longer tracked
+func (s *workloadStates) transition(id string, to workloadState, request *agentapi.DeployRequest) (workloadState, *agentapi.DeployRequest, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lifecycle, ok := s.workloads[id]
+	if !ok {
+		if _, stopped := s.stopped[id]; stopped {
+			return workloadStateStopped, nil, fmt.Errorf("%w: %s", ErrWorkloadAlreadyStopped, id)
+		}
+		return workloadStateStopped, nil, fmt.Errorf("%w: %s", ErrWorkloadNotFound, id)
+	}
+
+	from := lifecycle.state
+	if from == workloadStateStopping && to == workloadStateStopping {
+		return from, lifecycle.request, fmt.Errorf("%w: %s is stopping", ErrWorkloadAlreadyStopped, id)
+	}
+	if !validWorkloadTransition(from, to) {
+		return from, lifecycle.request, fmt.Errorf("workload %s cannot move from %s to %s", id, from, to)
+	}
+
+	if request != nil {
+		lifecycle.request = request
+	}
+	lifecycle.state = to
+	lifecycle.reserved = false
+
+	if to == workloadStateStopped {
+		delete(s.workloads, id)
+		s.rememberStopped(id)
+	}
+
+	return from, lifecycle.request, nil
+}
+
+// Remembers a stopped workload, forgetting the oldest once the history is full. The caller must
+// hold the mutex
+func (s *workloadStates) rememberStopped(id string) {
+	if len(s.stoppedOrder) >= stoppedWorkloadHistory {
+		delete(s.stopped, s.stoppedOrder[0])
+		s.stoppedOrder = s.stoppedOrder[1:]
+	}
+
+	s.stopped[id] = struct{}{}
+	s.stoppedOrder = append(s.stoppedOrder, id)
+}
+
+func (l *workloadLifecycle) claimed() bool {
+	return l.reserved || l.state == workloadStateDeploying
+}
+
+// Treats stopping a workload that is already stopped, or being stopped, as success, for callers
+// that only need the workload to be gone
+func ignoreAlreadyStopped(err error) error {
+	if errors.Is(err, ErrWorkloadAlreadyStopped) {
+		return nil
+	}
+
+	return err
+}
+
+func validWorkloadTransition(from workloadState, to workloadState) bool {
+	for _, next := range workloadTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Moves the workload to the given state, publishing the change once the workload's deploy
+// request, and with it its namespace, is known
+func (w *WorkloadManager) transitionWorkload(id string, to workloadState, request *agentapi.DeployRequest) error {
+	from, request, err := w.states.transition(id, to, request)
+	if err != nil {
+		return err
+	}
+
+	w.log.Debug("Workload changed state",
+		slog.String("workload_id", id),
+		slog.String("from", string(from)),
+		slog.String("to", string(to)),
+	)
+
+	// agents that never received a workload have no namespace to publish to
+	if request != nil && request.Namespace != nil {
+		_ = w.publishWorkloadStateChanged(id, request, from, to)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package nexnode
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestWorkloadStatesFollowLifecycle(t *testing.T) {
+	states := newWorkloadStates()
+	states.track("wl")
+
+	if !states.reserve("wl") {
+		t.Fatal("Expected a pending workload to be reserved")
+	}
+	if states.reserve("wl") {
+		t.Fatal("Expected a reserved workload not to be reserved again")
+	}
+	if states.claimedCount() != 1 {
+		t.Fatalf("Expected one claimed workload, got %d", states.claimedCount())
+	}
+
+	for _, to := range []workloadState{workloadStateDeploying, workloadStateRunning, workloadStateStopping} {
+		if _, _, err := states.transition("wl", to, nil); err != nil {
+			t.Fatalf("Expected the workload to move to %s: %s", to, err)
+		}
+	}
+
+	if _, _, err := states.transition("wl", workloadStateRunning, nil); err == nil {
+		t.Fatal("Expected a stopping workload not to move back to running")
+	}
+	if states.reserve("wl") {
+		t.Fatal("Expected a stopping workload not to be reserved")
+	}
+
+	from, _, err := states.transition("wl", workloadStateStopped, nil)
+	if err != nil || from != workloadStateStopping {
+		t.Fatalf("Expected the workload to stop from stopping, got %s: %v", from, err)
+	}
+	if states.state("wl") != workloadStateStopped {
+		t.Fatal("Expected an untracked workload to report as stopped")
+	}
+	if _, _, err := states.transition("wl", workloadStateStopping, nil); !errors.Is(err, ErrWorkloadAlreadyStopped) {
+		t.Fatalf("Expected a stopped workload to be reported as already stopped, got %v", err)
+	}
+}
+
+func TestWorkloadStatesStopOnce(t *testing.T) {
+	states := newWorkloadStates()
+	states.track("wl")
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	stops := 0
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := states.transition("wl", workloadStateStopping, nil); err == nil {
+				mutex.Lock()
+				stops++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stops != 1 {
+		t.Fatalf("Expected exactly one of the concurrent stops to proceed, got %d", stops)
+	}
+}
+
+func TestWorkloadStatesRelease(t *testing.T) {
+	states := newWorkloadStates()
+	states.track("wl")
+	states.reserve("wl")
+	states.release("wl")
+
+	if states.claimed("wl") {
+		t.Fatal("Expected a released workload to be unclaimed")
+	}
+	if _, _, err := states.transition("wl", workloadStateRunning, nil); err == nil {
+		t.Fatal("Expected a pending workload not to run without deploying")
+	}
+}
+
+func TestStopWorkloadReportsUnknownAndRepeatedStops(t *testing.T) {
+	w := newTestWorkloadManager(&models.NodeConfiguration{})
+
+	if err := w.StopWorkload("missing", true); !errors.Is(err, ErrWorkloadNotFound) {
+		t.Fatalf("Expected an unknown workload not to be found, got %v", err)
+	}
+
+	w.states.track("wl")
+	if _, _, err := w.states.transition("wl", workloadStateStopping, nil); err != nil {
+		t.Fatalf("Expected the workload to start stopping: %s", err)
+	}
+	if err := w.StopWorkload("wl", true); !errors.Is(err, ErrWorkloadAlreadyStopped) {
+		t.Fatalf("Expected a workload being stopped to be reported as already stopped, got %v", err)
+	}
+	if ignoreAlreadyStopped(w.StopWorkload("wl", false)) != nil {
+		t.Fatal("Expected a repeated stop to be ignorable")
+	}
+}
@@ -0,0 +1,257 @@
+package operator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	serviceAccountDir       = "/var/run/secrets/kubernetes.io/serviceaccount"
+	serviceAccountTokenFile = serviceAccountDir + "/token"
+	serviceAccountCAFile    = serviceAccountDir + "/ca.crt"
+)
+
+// How the operator reaches the Kubernetes API server. With no server, the in-cluster service
+// account is used; a plain http server such as the one started by `kubectl proxy` needs no token
+type KubeConfig struct {
+	Server    string
+	TokenFile string
+	CAFile    string
+	Insecure  bool
+}
+
+// A minimal client of the Kubernetes REST API, covering just the list, watch and patch calls the
+// operator makes on its own custom resources
+type kubeClient struct {
+	server    *url.URL
+	tokenFile string
+	http      *http.Client
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Returned by a watch whose resource version is too old, after which the caller must list again
+var errWatchExpired = errors.New("watch expired")
+
+func newKubeClient(config KubeConfig) (*kubeClient, error) {
+	if config.Server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("not running in a Kubernetes cluster; a Kubernetes API server is required")
+		}
+		config.Server = "https://" + net.JoinHostPort(host, port)
+		if config.TokenFile == "" {
+			config.TokenFile = serviceAccountTokenFile
+		}
+		if config.CAFile == "" {
+			config.CAFile = serviceAccountCAFile
+		}
+	}
+
+	server, err := url.Parse(config.Server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kubernetes API server %s: %s", config.Server, err)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.Insecure}
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Kubernetes CA: %s", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &kubeClient{
+		server:    server,
+		tokenFile: config.TokenFile,
+		// no timeout, as watches are long lived; requests are bounded by their context instead
+		http: &http.Client{Transport: transport},
+	}, nil
+}
+
+// Path of a custom resource collection or, when a name is given, of a single resource. An empty
+// namespace addresses cluster scoped resources, or every namespace when listing
+func resourcePath(resource string, namespace string, name string) string {
+	path := fmt.Sprintf("/apis/%s/%s", Group, Version)
+	if namespace != "" {
+		path = fmt.Sprintf("%s/namespaces/%s", path, namespace)
+	}
+	path = fmt.Sprintf("%s/%s", path, resource)
+	if name != "" {
+		path = fmt.Sprintf("%s/%s", path, name)
+	}
+	return path
+}
+
+func (k *kubeClient) newRequest(ctx context.Context, method string, path string, query url.Values, body []byte, contentType string) (*http.Request, error) {
+	target := *k.server
+	target.Path = strings.TrimSuffix(target.Path, "/") + path
+	target.RawQuery = query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	// service account tokens are rotated, so the file is read for every request
+	if k.tokenFile != "" {
+		token, err := os.ReadFile(k.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Kubernetes token: %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	return req, nil
+}
+
+func (k *kubeClient) do(req *http.Request, out interface{}) error {
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return kubeError(resp.StatusCode, body)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// Reports the message of a Kubernetes Status object when the body holds one
+func kubeError(statusCode int, body []byte) error {
+	var status struct {
+		Message string `json:"message"`
+		Reason  string `json:"reason"`
+	}
+	if json.Unmarshal(body, &status) == nil && status.Message != "" {
+		return fmt.Errorf("kubernetes API returned %d (%s): %s", statusCode, status.Reason, status.Message)
+	}
+	return fmt.Errorf("kubernetes API returned %d", statusCode)
+}
+
+// Lists every resource of the given kind across all namespaces into out
+func (k *kubeClient) list(ctx context.Context, resource string, out interface{}) error {
+	req, err := k.newRequest(ctx, http.MethodGet, resourcePath(resource, "", ""), nil, nil, "")
+	if err != nil {
+		return err
+	}
+	return k.do(req, out)
+}
+
+// Applies a JSON merge patch to a resource, or to its status subresource
+func (k *kubeClient) patch(ctx context.Context, resource string, namespace string, name string, status bool, patch interface{}) error {
+	raw, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	path := resourcePath(resource, namespace, name)
+	if status {
+		path += "/status"
+	}
+
+	req, err := k.newRequest(ctx, http.MethodPatch, path, nil, raw, "application/merge-patch+json")
+	if err != nil {
+		return err
+	}
+	return k.do(req, nil)
+}
+
+// Watches resources of the given kind across all namespaces from the given resource version,
+// calling handle for every change until the server ends the watch or the context is done
+func (k *kubeClient) watch(ctx context.Context, resource string, resourceVersion string, handle func(watchEvent)) error {
+	query := url.Values{}
+	query.Set("watch", "true")
+	if resourceVersion != "" {
+		query.Set("resourceVersion", resourceVersion)
+	}
+
+	req, err := k.newRequest(ctx, http.MethodGet, resourcePath(resource, "", ""), query, nil, "")
+	if err != nil {
+		return err
+	}
+
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusGone {
+		return errWatchExpired
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return kubeError(resp.StatusCode, body)
+	}
+
+	decoder := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var event watchEvent
+		err := decoder.Decode(&event)
+		if errors.Is(err, io.EOF) || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if event.Type == "ERROR" {
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				return errWatchExpired
+			}
+			return fmt.Errorf("watch failed: %s", status.Message)
+		}
+
+		handle(event)
+	}
+}
@@ -0,0 +1,115 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKubeClientListAndPatch(t *testing.T) {
+	var patched map[string]interface{}
+	var patchPath, auth string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/apis/nex.synadia.io/v1alpha1/nexworkloads":
+			_, _ = fmt.Fprint(w, `{"metadata":{"resourceVersion":"42"},"items":[{"metadata":{"name":"echo","namespace":"apps","uid":"u1"},"spec":{"name":"echo","type":"native","replicas":2}}]}`)
+		case r.Method == http.MethodPatch:
+			patchPath = r.URL.Path
+			if r.Header.Get("Content-Type") != "application/merge-patch+json" {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(body, &patched)
+			_, _ = fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"kind":"Status","reason":"NotFound","message":"not found"}`)
+		}
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	_ = os.WriteFile(tokenFile, []byte("secret\n"), 0600)
+
+	kube, err := newKubeClient(KubeConfig{Server: server.URL, TokenFile: tokenFile})
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err)
+	}
+
+	var list nexWorkloadList
+	err = kube.list(context.Background(), workloadsResource, &list)
+	if err != nil {
+		t.Fatalf("Failed to list workloads: %s", err)
+	}
+	if list.Metadata.ResourceVersion != "42" || len(list.Items) != 1 || list.Items[0].NexNamespace() != "apps" || list.Items[0].Spec.Replicas != 2 {
+		t.Fatalf("Unexpected list %+v", list)
+	}
+	if auth != "Bearer secret" {
+		t.Fatalf("Expected bearer token from the token file, got %q", auth)
+	}
+
+	err = kube.patch(context.Background(), workloadsResource, "apps", "echo", true, map[string]interface{}{"status": NexWorkloadStatus{Phase: PhaseReady}})
+	if err != nil {
+		t.Fatalf("Failed to patch status: %s", err)
+	}
+	if patchPath != "/apis/nex.synadia.io/v1alpha1/namespaces/apps/nexworkloads/echo/status" {
+		t.Fatalf("Unexpected patch path %s", patchPath)
+	}
+	if status, _ := patched["status"].(map[string]interface{}); status["phase"] != PhaseReady {
+		t.Fatalf("Unexpected patch %v", patched)
+	}
+
+	err = kube.list(context.Background(), nodePoolsResource, &nexNodePoolList{})
+	if err == nil {
+		t.Fatal("Expected listing an unknown resource to fail")
+	}
+}
+
+func TestKubeClientWatch(t *testing.T) {
+	expired := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("watch") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if expired {
+			_, _ = fmt.Fprint(w, `{"type":"ERROR","object":{"kind":"Status","code":410,"message":"too old resource version"}}`+"\n")
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"type":"ADDED","object":{"metadata":{"name":"a"}}}`+"\n")
+		_, _ = fmt.Fprint(w, `{"type":"DELETED","object":{"metadata":{"name":"a"}}}`+"\n")
+	}))
+	defer server.Close()
+
+	kube, err := newKubeClient(KubeConfig{Server: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err)
+	}
+
+	events := make([]string, 0)
+	err = kube.watch(context.Background(), workloadsResource, "42", func(event watchEvent) {
+		events = append(events, event.Type)
+	})
+	if err != nil {
+		t.Fatalf("Expected watch to end cleanly: %s", err)
+	}
+	if len(events) != 2 || events[0] != "ADDED" || events[1] != "DELETED" {
+		t.Fatalf("Unexpected events %v", events)
+	}
+
+	expired = true
+	err = kube.watch(context.Background(), workloadsResource, "1", func(watchEvent) {})
+	if !errors.Is(err, errWatchExpired) {
+		t.Fatalf("Expected an expired watch, got %v", err)
+	}
+}
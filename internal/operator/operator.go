@@ -0,0 +1,448 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+const (
+	defaultResyncInterval = 30 * time.Second
+	watchRetryDelay       = 5 * time.Second
+)
+
+type Options struct {
+	Kube KubeConfig
+
+	// Signs the workloads the operator deploys and stops; only workloads issued by this key are
+	// ever managed
+	Issuer nkeys.KeyPair
+	// Encrypts the environment of the workloads the operator deploys
+	Xkey nkeys.KeyPair
+
+	JsDomain string
+	Timeout  time.Duration
+
+	// How often every resource is reconciled in the absence of changes, so that drift in the
+	// nexus, such as a node going away, is repaired
+	ResyncInterval time.Duration
+}
+
+// Reconciles NexWorkload and NexNodePool resources of a Kubernetes cluster against a nexus.
+// Reconciliation is level based: every pass compares each resource with what the nexus reports
+// and makes the changes needed, so that missed events cost no more than a delay
+type Operator struct {
+	kube    *kubeClient
+	nc      *nats.Conn
+	options Options
+	issuer  string
+	log     *slog.Logger
+
+	trigger chan struct{}
+}
+
+func NewOperator(nc *nats.Conn, options Options, log *slog.Logger) (*Operator, error) {
+	if options.Issuer == nil || options.Xkey == nil {
+		return nil, errors.New("an issuer and an xkey are required")
+	}
+	if options.ResyncInterval <= 0 {
+		options.ResyncInterval = defaultResyncInterval
+	}
+
+	issuer, err := options.Issuer.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	kube, err := newKubeClient(options.Kube)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Operator{
+		kube:    kube,
+		nc:      nc,
+		options: options,
+		issuer:  issuer,
+		log:     log,
+		trigger: make(chan struct{}, 1),
+	}, nil
+}
+
+// Watches the custom resources and reconciles them until the context is done
+func (o *Operator) Run(ctx context.Context) error {
+	go o.watchResources(ctx, workloadsResource)
+	go o.watchResources(ctx, nodePoolsResource)
+
+	ticker := time.NewTicker(o.options.ResyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-o.trigger:
+		case <-ticker.C:
+		}
+
+		err := o.reconcile(ctx)
+		if err != nil && ctx.Err() == nil {
+			o.log.Error("Failed to reconcile", slog.Any("err", err))
+		}
+	}
+}
+
+// Schedules a reconciliation pass, unless one is already scheduled
+func (o *Operator) notify() {
+	select {
+	case o.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Triggers a reconciliation pass whenever resources of the given kind change, listing them again
+// whenever the watch ends
+func (o *Operator) watchResources(ctx context.Context, resource string) {
+	for ctx.Err() == nil {
+		var list struct {
+			Metadata listMeta `json:"metadata"`
+		}
+		err := o.kube.list(ctx, resource, &list)
+		if err == nil {
+			o.notify()
+			err = o.kube.watch(ctx, resource, list.Metadata.ResourceVersion, func(watchEvent) {
+				o.notify()
+			})
+		}
+		if err == nil || errors.Is(err, errWatchExpired) {
+			continue
+		}
+
+		o.log.Warn("Watch of Kubernetes resources failed", slog.String("resource", resource), slog.Any("err", err))
+		select {
+		case <-ctx.Done():
+		case <-time.After(watchRetryDelay):
+		}
+	}
+}
+
+// Reconciles every node pool, then every workload
+func (o *Operator) reconcile(ctx context.Context) error {
+	var pools nexNodePoolList
+	err := o.kube.list(ctx, nodePoolsResource, &pools)
+	if err != nil {
+		return fmt.Errorf("failed to list node pools: %s", err)
+	}
+
+	var workloads nexWorkloadList
+	err = o.kube.list(ctx, workloadsResource, &workloads)
+	if err != nil {
+		return fmt.Errorf("failed to list workloads: %s", err)
+	}
+
+	client := controlapi.NewApiClient(o.nc, o.options.Timeout, o.log)
+	nodes, err := client.PingNodes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to discover nodes: %s", err)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeId < nodes[j].NodeId
+	})
+
+	poolsByName := make(map[string]*NexNodePool)
+	for i := range pools.Items {
+		pool := &pools.Items[i]
+		poolsByName[pool.Metadata.Name] = pool
+		o.reconcilePool(ctx, client, pool, nodes)
+	}
+
+	for i := range workloads.Items {
+		o.reconcileWorkload(ctx, &workloads.Items[i], poolsByName, nodes)
+	}
+
+	return nil
+}
+
+// Cordons or uncordons the nodes of the pool, and reports them in its status. Only nodes the pool
+// itself cordoned are uncordoned, and deleting a pool leaves its nodes as they are
+func (o *Operator) reconcilePool(ctx context.Context, client *controlapi.Client, pool *NexNodePool, nodes []controlapi.PingResponse) {
+	reason := poolCordonReason + pool.Metadata.Name
+	status := NexNodePoolStatus{ObservedGeneration: pool.Metadata.Generation, Nodes: make([]string, 0)}
+
+	var errs error
+	for _, node := range nodes {
+		if !pool.Spec.Matches(node.Tags) {
+			continue
+		}
+		status.Nodes = append(status.Nodes, node.NodeId)
+
+		info, err := client.NodeInfo(ctx, node.NodeId)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("node %s: %s", node.NodeId, err))
+			continue
+		}
+
+		switch {
+		case pool.Spec.Cordoned && info.Cordon == nil:
+			_, err = client.CordonNode(ctx, node.NodeId, &controlapi.CordonRequest{Reason: reason})
+		case !pool.Spec.Cordoned && info.Cordon != nil && info.Cordon.Reason == reason:
+			_, err = client.UncordonNode(ctx, node.NodeId)
+		}
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("node %s: %s", node.NodeId, err))
+		}
+	}
+	if errs != nil {
+		status.Message = errs.Error()
+	}
+
+	err := o.kube.patch(ctx, nodePoolsResource, "", pool.Metadata.Name, true, map[string]interface{}{"status": status})
+	if err != nil {
+		o.log.Warn("Failed to update node pool status", slog.String("pool", pool.Metadata.Name), slog.Any("err", err))
+	}
+}
+
+// Deploys and stops the workloads of a NexWorkload until they match its spec, stopping all of
+// them once the resource is being deleted
+func (o *Operator) reconcileWorkload(ctx context.Context, workload *NexWorkload, pools map[string]*NexNodePool, nodes []controlapi.PingResponse) {
+	log := o.log.With(slog.String("namespace", workload.Metadata.Namespace), slog.String("name", workload.Metadata.Name))
+	client := controlapi.NewApiClientWithNamespace(o.nc, o.options.Timeout, workload.NexNamespace(), o.log)
+
+	running, err := o.runningWorkloads(ctx, client, workload)
+	if err != nil {
+		o.updateWorkloadStatus(ctx, workload, NexWorkloadStatus{Phase: PhaseFailed, Message: err.Error()})
+		return
+	}
+
+	if workload.Metadata.DeletionTimestamp != nil {
+		o.finalizeWorkload(ctx, client, workload, running, log)
+		return
+	}
+
+	if !slices.Contains(workload.Metadata.Finalizers, workloadFinalizer) {
+		err := o.setFinalizers(ctx, workload, append(slices.Clone(workload.Metadata.Finalizers), workloadFinalizer))
+		if err != nil {
+			log.Warn("Failed to add finalizer", slog.Any("err", err))
+			return
+		}
+	}
+
+	candidates := nodes
+	if workload.Spec.NodePool != "" {
+		pool, ok := pools[workload.Spec.NodePool]
+		if !ok {
+			o.updateWorkloadStatus(ctx, workload, NexWorkloadStatus{Phase: PhaseFailed, Message: fmt.Sprintf("no such NexNodePool: %s", workload.Spec.NodePool)})
+			return
+		}
+		if pool.Spec.Cordoned {
+			candidates = nil
+		} else {
+			candidates = make([]controlapi.PingResponse, 0)
+			for _, node := range nodes {
+				if pool.Spec.Matches(node.Tags) {
+					candidates = append(candidates, node)
+				}
+			}
+		}
+	}
+
+	specHash := workload.Spec.hash()
+	plan := planWorkload(specHash, workload.Spec.Replicas, candidates, running)
+
+	var errs error
+	if len(plan.Keep)+len(plan.Deploy) < workload.Spec.Replicas {
+		errs = errors.Join(errs, fmt.Errorf("only %d of %d replicas can be placed", len(plan.Keep)+len(plan.Deploy), workload.Spec.Replicas))
+	}
+
+	status := NexWorkloadStatus{
+		ObservedGeneration: workload.Metadata.Generation,
+		Placements:         make([]WorkloadPlacement, 0),
+	}
+	for _, kept := range plan.Keep {
+		status.Placements = append(status.Placements, WorkloadPlacement{NodeId: kept.NodeId, WorkloadId: kept.WorkloadId, Healthy: kept.Healthy})
+		if kept.Healthy {
+			status.ReadyReplicas++
+		}
+	}
+
+	xkeys := make(map[string]string)
+	for _, node := range candidates {
+		xkeys[node.NodeId] = node.TargetXkey
+	}
+	for _, nodeId := range plan.Deploy {
+		workloadId, err := o.deploy(ctx, client, workload, specHash, nodeId, xkeys[nodeId])
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("deploy to node %s: %s", nodeId, err))
+			continue
+		}
+
+		log.Info("Deployed workload", slog.String("node_id", nodeId), slog.String("workload_id", workloadId))
+		status.Placements = append(status.Placements, WorkloadPlacement{NodeId: nodeId, WorkloadId: workloadId})
+	}
+	status.Replicas = len(status.Placements)
+
+	errs = errors.Join(errs, o.stop(ctx, client, workload, plan.Stop, log))
+
+	switch {
+	case errs != nil:
+		status.Phase = PhaseFailed
+		status.Message = errs.Error()
+	case status.ReadyReplicas == workload.Spec.Replicas && len(plan.Pending) == 0 && len(plan.Deploy) == 0:
+		status.Phase = PhaseReady
+	default:
+		status.Phase = PhaseProgressing
+	}
+
+	o.updateWorkloadStatus(ctx, workload, status)
+}
+
+// Lists the workloads deployed for the given NexWorkload, identified by their owner label
+func (o *Operator) runningWorkloads(ctx context.Context, client *controlapi.Client, workload *NexWorkload) ([]runningWorkload, error) {
+	pings, err := client.PingWorkloadsOwnedBy(ctx, workload.Spec.Name, o.issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find running workloads: %s", err)
+	}
+
+	running := make([]runningWorkload, 0)
+	for _, ping := range pings {
+		info, err := client.NodeInfoOwnedBy(ctx, ping.NodeId, o.issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get info of node %s: %s", ping.NodeId, err)
+		}
+
+		for _, machine := range info.Machines {
+			if machine.Labels[OwnerLabel] != workload.Metadata.UID {
+				continue
+			}
+			running = append(running, runningWorkload{
+				NodeId:     ping.NodeId,
+				WorkloadId: machine.Id,
+				SpecHash:   machine.Labels[SpecHashLabel],
+				Healthy:    machine.Healthy,
+			})
+		}
+	}
+
+	return running, nil
+}
+
+func (o *Operator) deploy(ctx context.Context, client *controlapi.Client, workload *NexWorkload, specHash string, nodeId string, xkey string) (string, error) {
+	labels := maps.Clone(workload.Spec.Labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[ManagedByLabel] = managedByValue
+	labels[OwnerLabel] = workload.Metadata.UID
+	labels[SpecHashLabel] = specHash
+
+	env := workload.Spec.Env
+	if env == nil {
+		env = make(map[string]string)
+	}
+
+	request, err := controlapi.NewDeployRequest(
+		controlapi.Argv(workload.Spec.Argv),
+		controlapi.Location(workload.Spec.Location),
+		controlapi.Environment(env),
+		controlapi.Essential(workload.Spec.Essential),
+		controlapi.Issuer(o.options.Issuer),
+		controlapi.SenderXKey(o.options.Xkey),
+		controlapi.TargetNode(nodeId),
+		controlapi.TargetPublicXKey(xkey),
+		controlapi.WorkloadName(workload.Spec.Name),
+		controlapi.WorkloadDescription(workload.Spec.Description),
+		controlapi.WorkloadType(workload.Spec.Type),
+		controlapi.TriggerSubjects(workload.Spec.TriggerSubjects),
+		controlapi.Checksum(workload.Spec.Sha256),
+		controlapi.Resources(controlapi.WorkloadResources{MemoryMib: workload.Spec.MemoryMib}),
+		controlapi.JsDomain(o.options.JsDomain),
+		controlapi.Labels(labels),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := client.StartWorkload(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	if !response.Started {
+		return "", errors.New("workload was not started")
+	}
+
+	return response.ID, nil
+}
+
+func (o *Operator) stop(ctx context.Context, client *controlapi.Client, workload *NexWorkload, targets []runningWorkload, log *slog.Logger) error {
+	var errs error
+	for _, target := range targets {
+		request, err := controlapi.NewStopRequest(target.WorkloadId, workload.Spec.Name, target.NodeId, o.options.Issuer)
+		if err == nil {
+			_, err = client.StopWorkload(ctx, request)
+		}
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stop workload %s on node %s: %s", target.WorkloadId, target.NodeId, err))
+			continue
+		}
+
+		log.Info("Stopped workload", slog.String("node_id", target.NodeId), slog.String("workload_id", target.WorkloadId))
+	}
+
+	return errs
+}
+
+// Stops every workload of a NexWorkload being deleted, then releases it to Kubernetes
+func (o *Operator) finalizeWorkload(ctx context.Context, client *controlapi.Client, workload *NexWorkload, running []runningWorkload, log *slog.Logger) {
+	if !slices.Contains(workload.Metadata.Finalizers, workloadFinalizer) {
+		return
+	}
+
+	err := o.stop(ctx, client, workload, running, log)
+	if err != nil {
+		o.updateWorkloadStatus(ctx, workload, NexWorkloadStatus{Phase: PhaseTerminating, Message: err.Error()})
+		return
+	}
+
+	finalizers := slices.DeleteFunc(slices.Clone(workload.Metadata.Finalizers), func(finalizer string) bool {
+		return finalizer == workloadFinalizer
+	})
+	err = o.setFinalizers(ctx, workload, finalizers)
+	if err != nil {
+		log.Warn("Failed to remove finalizer", slog.Any("err", err))
+	}
+}
+
+// Replaces the finalizers of the workload, failing if it changed since it was read so that
+// finalizers added by others are not lost
+func (o *Operator) setFinalizers(ctx context.Context, workload *NexWorkload, finalizers []string) error {
+	return o.kube.patch(ctx, workloadsResource, workload.Metadata.Namespace, workload.Metadata.Name, false, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": workload.Metadata.ResourceVersion,
+			"finalizers":      finalizers,
+		},
+	})
+}
+
+func (o *Operator) updateWorkloadStatus(ctx context.Context, workload *NexWorkload, status NexWorkloadStatus) {
+	if status.ObservedGeneration == 0 {
+		status.ObservedGeneration = workload.Metadata.Generation
+	}
+
+	err := o.kube.patch(ctx, workloadsResource, workload.Metadata.Namespace, workload.Metadata.Name, true, map[string]interface{}{"status": status})
+	if err != nil {
+		o.log.Warn("Failed to update workload status",
+			slog.String("namespace", workload.Metadata.Namespace),
+			slog.String("name", workload.Metadata.Name),
+			slog.Any("err", err),
+		)
+	}
+}
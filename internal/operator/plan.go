@@ -0,0 +1,96 @@
+package operator
+
+import (
+	"sort"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+// A workload deployed by the operator for a NexWorkload
+type runningWorkload struct {
+	NodeId     string
+	WorkloadId string
+	SpecHash   string
+	Healthy    bool
+}
+
+// The changes that bring the workloads of a NexWorkload in line with its spec
+type workloadPlan struct {
+	// Workloads already matching the spec that are kept running
+	Keep []runningWorkload
+	// Nodes to deploy a new workload to, one entry per workload
+	Deploy []string
+	// Workloads to stop, either surplus or deployed from an older spec
+	Stop []runningWorkload
+	// Workloads deployed from an older spec, left running until their replacements are healthy
+	Pending []runningWorkload
+}
+
+// Plans the deploys and stops that leave the given number of replicas of the spec revision
+// running on the candidate nodes. New workloads are spread over the candidates running the fewest
+// replicas, then the fewest workloads. Workloads of an older spec revision, or on nodes that are
+// no longer candidates, are only stopped once the replicas replacing them are all healthy
+func planWorkload(specHash string, replicas int, candidates []controlapi.PingResponse, running []runningWorkload) workloadPlan {
+	var plan workloadPlan
+
+	eligible := make(map[string]bool)
+	for _, node := range candidates {
+		eligible[node.NodeId] = true
+	}
+
+	current := make([]runningWorkload, 0)
+	stale := make([]runningWorkload, 0)
+	for _, workload := range running {
+		if workload.SpecHash == specHash && eligible[workload.NodeId] {
+			current = append(current, workload)
+		} else {
+			stale = append(stale, workload)
+		}
+	}
+
+	// unhealthy workloads are the first to go when there are too many
+	sort.SliceStable(current, func(i, j int) bool {
+		return current[i].Healthy && !current[j].Healthy
+	})
+	if len(current) > replicas {
+		plan.Stop = append(plan.Stop, current[replicas:]...)
+		current = current[:replicas]
+	}
+	plan.Keep = current
+
+	perNode := make(map[string]int)
+	for _, workload := range current {
+		perNode[workload.NodeId]++
+	}
+
+	nodes := make([]controlapi.PingResponse, len(candidates))
+	copy(nodes, candidates)
+	for i := len(current); i < replicas && len(nodes) > 0; i++ {
+		sort.SliceStable(nodes, func(a, b int) bool {
+			if perNode[nodes[a].NodeId] != perNode[nodes[b].NodeId] {
+				return perNode[nodes[a].NodeId] < perNode[nodes[b].NodeId]
+			}
+			if nodes[a].RunningMachines != nodes[b].RunningMachines {
+				return nodes[a].RunningMachines < nodes[b].RunningMachines
+			}
+			return nodes[a].NodeId < nodes[b].NodeId
+		})
+
+		plan.Deploy = append(plan.Deploy, nodes[0].NodeId)
+		perNode[nodes[0].NodeId]++
+	}
+
+	healthy := 0
+	for _, workload := range current {
+		if workload.Healthy {
+			healthy++
+		}
+	}
+	if healthy >= replicas {
+		plan.Stop = append(plan.Stop, stale...)
+	} else {
+		plan.Pending = stale
+	}
+
+	return plan
+}
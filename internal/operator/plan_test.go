@@ -0,0 +1,93 @@
+package operator
+
+import (
+	"testing"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+func TestPlanSpreadsNewReplicas(t *testing.T) {
+	candidates := []controlapi.PingResponse{
+		{NodeId: "NA", RunningMachines: 4},
+		{NodeId: "NB", RunningMachines: 0},
+		{NodeId: "NC", RunningMachines: 1},
+	}
+	running := []runningWorkload{
+		{NodeId: "NB", WorkloadId: "w1", SpecHash: "v1", Healthy: true},
+	}
+
+	plan := planWorkload("v1", 3, candidates, running)
+	if len(plan.Keep) != 1 || len(plan.Stop) != 0 {
+		t.Fatalf("Expected the running replica to be kept, got %+v", plan)
+	}
+	if len(plan.Deploy) != 2 || plan.Deploy[0] != "NC" || plan.Deploy[1] != "NA" {
+		t.Fatalf("Expected new replicas on the nodes without one, least loaded first, got %v", plan.Deploy)
+	}
+}
+
+func TestPlanStopsSurplusUnhealthyFirst(t *testing.T) {
+	candidates := []controlapi.PingResponse{{NodeId: "NA"}, {NodeId: "NB"}}
+	running := []runningWorkload{
+		{NodeId: "NA", WorkloadId: "w1", SpecHash: "v1", Healthy: false},
+		{NodeId: "NB", WorkloadId: "w2", SpecHash: "v1", Healthy: true},
+	}
+
+	plan := planWorkload("v1", 1, candidates, running)
+	if len(plan.Deploy) != 0 {
+		t.Fatalf("Expected no deploys, got %v", plan.Deploy)
+	}
+	if len(plan.Stop) != 1 || plan.Stop[0].WorkloadId != "w1" {
+		t.Fatalf("Expected the unhealthy replica to be stopped, got %+v", plan.Stop)
+	}
+}
+
+func TestPlanReplacesStaleOnceHealthy(t *testing.T) {
+	candidates := []controlapi.PingResponse{{NodeId: "NA"}, {NodeId: "NB"}}
+	running := []runningWorkload{
+		{NodeId: "NA", WorkloadId: "old", SpecHash: "v1", Healthy: true},
+	}
+
+	plan := planWorkload("v2", 1, candidates, running)
+	if len(plan.Deploy) != 1 || len(plan.Stop) != 0 || len(plan.Pending) != 1 {
+		t.Fatalf("Expected the old replica to run until its replacement is healthy, got %+v", plan)
+	}
+
+	running = append(running, runningWorkload{NodeId: "NB", WorkloadId: "new", SpecHash: "v2", Healthy: true})
+	plan = planWorkload("v2", 1, candidates, running)
+	if len(plan.Deploy) != 0 || len(plan.Stop) != 1 || plan.Stop[0].WorkloadId != "old" {
+		t.Fatalf("Expected the old replica to be stopped once replaced, got %+v", plan)
+	}
+
+	// nodes leaving the pool count as stale too
+	plan = planWorkload("v2", 1, candidates[:1], running[1:])
+	if len(plan.Deploy) != 1 || plan.Deploy[0] != "NA" || len(plan.Pending) != 1 {
+		t.Fatalf("Expected a replica outside the candidates to be replaced, got %+v", plan)
+	}
+}
+
+func TestPlanScaleToZero(t *testing.T) {
+	running := []runningWorkload{
+		{NodeId: "NA", WorkloadId: "w1", SpecHash: "v1", Healthy: true},
+		{NodeId: "NA", WorkloadId: "w2", SpecHash: "v0", Healthy: true},
+	}
+
+	plan := planWorkload("v1", 0, []controlapi.PingResponse{{NodeId: "NA"}}, running)
+	if len(plan.Stop) != 2 || len(plan.Deploy) != 0 {
+		t.Fatalf("Expected every replica to be stopped, got %+v", plan)
+	}
+}
+
+func TestSpecHashIgnoresReplicas(t *testing.T) {
+	spec := NexWorkloadSpec{Name: "echo", Type: controlapi.NexWorkloadNative, Location: "nats://bucket/echo", Replicas: 1}
+	scaled := spec
+	scaled.Replicas = 5
+	if spec.hash() != scaled.hash() {
+		t.Fatal("Expected scaling not to change the spec hash")
+	}
+
+	changed := spec
+	changed.Location = "nats://bucket/echo2"
+	if spec.hash() == changed.hash() {
+		t.Fatal("Expected a new location to change the spec hash")
+	}
+}
@@ -0,0 +1,157 @@
+package operator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+const (
+	// API group and version of the custom resources watched by the operator
+	Group   = "nex.synadia.io"
+	Version = "v1alpha1"
+
+	// Plural resource names, as they appear in API paths
+	workloadsResource = "nexworkloads"
+	nodePoolsResource = "nexnodepools"
+
+	// Added to every NexWorkload so that its workloads are stopped before Kubernetes forgets it
+	workloadFinalizer = Group + "/workloads"
+
+	// Labels the operator puts on the workloads it deploys, identifying the NexWorkload that owns
+	// them and the revision of its spec they were deployed from
+	ManagedByLabel = Group + "/managed-by"
+	OwnerLabel     = Group + "/owner"
+	SpecHashLabel  = Group + "/spec-hash"
+
+	managedByValue = "nex-operator"
+
+	// Prefix of the cordon reason given to nodes cordoned by a NexNodePool, so that the operator
+	// only ever uncordons the nodes it cordoned itself
+	poolCordonReason = "cordoned by NexNodePool "
+)
+
+// The subset of Kubernetes object metadata the operator reads and writes
+type ObjectMeta struct {
+	Name              string     `json:"name"`
+	Namespace         string     `json:"namespace,omitempty"`
+	UID               string     `json:"uid,omitempty"`
+	ResourceVersion   string     `json:"resourceVersion,omitempty"`
+	Generation        int64      `json:"generation,omitempty"`
+	DeletionTimestamp *time.Time `json:"deletionTimestamp,omitempty"`
+	Finalizers        []string   `json:"finalizers,omitempty"`
+}
+
+type listMeta struct {
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// A workload the operator keeps running on a number of nodes of the nexus. It is deployed in the
+// Nex namespace named by its spec, or in the Kubernetes namespace of the resource when none is
+// given
+type NexWorkload struct {
+	Metadata ObjectMeta        `json:"metadata"`
+	Spec     NexWorkloadSpec   `json:"spec"`
+	Status   NexWorkloadStatus `json:"status,omitempty"`
+}
+
+type NexWorkloadSpec struct {
+	Namespace       string                 `json:"namespace,omitempty"`
+	Name            string                 `json:"name"`
+	Description     string                 `json:"description,omitempty"`
+	Type            controlapi.NexWorkload `json:"type"`
+	Location        string                 `json:"location"`
+	Sha256          string                 `json:"sha256,omitempty"`
+	Replicas        int                    `json:"replicas"`
+	NodePool        string                 `json:"nodePool,omitempty"`
+	Argv            []string               `json:"argv,omitempty"`
+	Env             map[string]string      `json:"env,omitempty"`
+	TriggerSubjects []string               `json:"triggerSubjects,omitempty"`
+	Essential       bool                   `json:"essential,omitempty"`
+	MemoryMib       int                    `json:"memoryMib,omitempty"`
+	Labels          map[string]string      `json:"labels,omitempty"`
+}
+
+// Reports how far the nexus is from the workload's spec
+type NexWorkloadStatus struct {
+	ObservedGeneration int64               `json:"observedGeneration,omitempty"`
+	Phase              string              `json:"phase,omitempty"`
+	Message            string              `json:"message,omitempty"`
+	Replicas           int                 `json:"replicas"`
+	ReadyReplicas      int                 `json:"readyReplicas"`
+	Placements         []WorkloadPlacement `json:"placements,omitempty"`
+}
+
+type WorkloadPlacement struct {
+	NodeId     string `json:"nodeId"`
+	WorkloadId string `json:"workloadId"`
+	Healthy    bool   `json:"healthy"`
+}
+
+// A set of nodes selected by their tags, on which NexWorkloads naming the pool are placed. A pool
+// may also keep its nodes cordoned. Pools are cluster scoped, as nodes belong to no namespace
+type NexNodePool struct {
+	Metadata ObjectMeta        `json:"metadata"`
+	Spec     NexNodePoolSpec   `json:"spec"`
+	Status   NexNodePoolStatus `json:"status,omitempty"`
+}
+
+type NexNodePoolSpec struct {
+	Selector map[string]string `json:"selector,omitempty"`
+	Cordoned bool              `json:"cordoned,omitempty"`
+}
+
+type NexNodePoolStatus struct {
+	ObservedGeneration int64    `json:"observedGeneration,omitempty"`
+	Nodes              []string `json:"nodes,omitempty"`
+	Message            string   `json:"message,omitempty"`
+}
+
+// Workload and pool phases reported in statuses
+const (
+	PhaseReady       = "Ready"
+	PhaseProgressing = "Progressing"
+	PhaseFailed      = "Failed"
+	PhaseTerminating = "Terminating"
+)
+
+type nexWorkloadList struct {
+	Metadata listMeta      `json:"metadata"`
+	Items    []NexWorkload `json:"items"`
+}
+
+type nexNodePoolList struct {
+	Metadata listMeta      `json:"metadata"`
+	Items    []NexNodePool `json:"items"`
+}
+
+// The Nex namespace the workload is deployed in
+func (w *NexWorkload) NexNamespace() string {
+	if w.Spec.Namespace != "" {
+		return w.Spec.Namespace
+	}
+	return w.Metadata.Namespace
+}
+
+// Identifies the revision of the spec that deployed workloads are compared against, so that
+// changing anything other than the replica count replaces them
+func (s NexWorkloadSpec) hash() string {
+	s.Replicas = 0
+	raw, _ := json.Marshal(s)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Reports whether a node with the given tags belongs to the pool. A pool without a selector
+// holds every node
+func (s NexNodePoolSpec) Matches(tags map[string]string) bool {
+	for key, value := range s.Selector {
+		if tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/choria-io/fisk"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+	"github.com/synadia-io/nex/nex/tui/picker"
+	"golang.org/x/term"
+)
+
+// Time given to nodes to answer the discovery made while completing a node ID
+const completionQuietPeriod = 250 * time.Millisecond
+
+// Fish has no equivalent of the bash completion that fisk generates, so it asks nex for the
+// completions of the command line the same way the bash and zsh scripts do
+const fishCompletionScript = `function __nex_complete
+    set -l tokens (commandline -opc)
+    nex --completion-bash $tokens[2..-1] (commandline -ct)
+end
+
+complete -c nex -f -a '(__nex_complete)'
+`
+
+// Prints the completion script for the given shell
+func CompletionScript(shell string) {
+	switch shell {
+	case "fish":
+		fmt.Print(fishCompletionScript)
+	default:
+		// fisk prints its own bash and zsh scripts, which call back into nex for completions
+		_, _ = ncli.Parse([]string{"--completion-script-" + shell})
+	}
+}
+
+func completionClient() (*controlapi.Client, func(), error) {
+	// completions and pickers run while the command line is parsed, before main applies the context
+	err := applyCliContext()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log), nc.Close, nil
+}
+
+// Lists the nodes that respond to discovery, sorted by ID
+func discoverNodeChoices(ctx context.Context, nodeClient *controlapi.Client) ([]controlapi.PingResponse, error) {
+	discovered, err := nodeClient.DiscoverNodes(ctx, nil, controlapi.WithQuietPeriod(completionQuietPeriod))
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]controlapi.PingResponse, 0)
+	for node := range discovered {
+		if inTargetNexus(node) {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeId < nodes[j].NodeId })
+
+	return nodes, nil
+}
+
+// Completes node IDs with the nodes that respond to discovery. Completion stays silent when
+// nodes cannot be reached
+func completeNodeIds() []string {
+	nodeClient, closeConn, err := completionClient()
+	if err != nil {
+		return nil
+	}
+	defer closeConn()
+
+	nodes, err := discoverNodeChoices(context.Background(), nodeClient)
+	if err != nil {
+		return nil
+	}
+
+	ids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.NodeId)
+	}
+
+	return ids
+}
+
+// Completes workload IDs with the workloads in the namespace, only those running on the given
+// node once one has been entered
+func completeWorkloadIds(nodeId *string) fisk.HintAction {
+	return func() []string {
+		nodeClient, closeConn, err := completionClient()
+		if err != nil {
+			return nil
+		}
+		defer closeConn()
+
+		nodes, err := nodeClient.PingWorkloads(context.Background(), "")
+		if err != nil {
+			return nil
+		}
+
+		ids := make([]string, 0)
+		for _, node := range nodes {
+			if *nodeId != "" && node.NodeId != *nodeId {
+				continue
+			}
+			for _, machine := range node.RunningMachines {
+				ids = append(ids, machine.Id)
+			}
+		}
+		sort.Strings(ids)
+
+		return ids
+	}
+}
+
+// Completes namespaces with those managed by the nodes, if any node manages them
+func completeNamespaces() []string {
+	nodeClient, closeConn, err := completionClient()
+	if err != nil {
+		return nil
+	}
+	defer closeConn()
+
+	list, err := nodeClient.ListNamespaces(context.Background())
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(list))
+	for _, ns := range list {
+		names = append(names, ns.Name)
+	}
+
+	return names
+}
+
+// Whether the user can be asked to pick a target that was omitted from the command line
+func interactive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// Asks the user a yes or no question on the terminal, which defaults to no. Outside of a terminal
+// nothing can be confirmed
+func confirm(question string) (bool, error) {
+	if !interactive() {
+		return false, errors.New("confirmation requires a terminal")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// Lets the user pick the node to target when the node ID argument was omitted. Outside of a
+// terminal the argument remains required
+func pickNodeAction(name string, nodeId *string) fisk.Action {
+	return func(*fisk.ParseContext) error {
+		if *nodeId != "" {
+			return nil
+		}
+		if !interactive() {
+			return fmt.Errorf("required argument '%s' not provided", name)
+		}
+
+		nodeClient, closeConn, err := completionClient()
+		if err != nil {
+			return err
+		}
+		defer closeConn()
+
+		nodes, err := discoverNodeChoices(context.Background(), nodeClient)
+		if err != nil {
+			return err
+		}
+
+		items := make([]picker.Item, 0, len(nodes))
+		for _, node := range nodes {
+			name, ok := node.Tags["node_name"]
+			if !ok {
+				name = "no-name-provided"
+			}
+			items = append(items, picker.Item{
+				Value: node.NodeId,
+				Label: fmt.Sprintf("%s  %s  v%s  %d workload(s)", node.NodeId, name, node.Version, node.RunningMachines),
+			})
+		}
+
+		*nodeId, err = picker.Pick("Select a node", items)
+		return err
+	}
+}
+
+// Lets the user pick the node and then the workload to target when their arguments were
+// omitted. Outside of a terminal both arguments remain required
+func pickWorkloadAction(nodeId *string, workloadId *string) fisk.Action {
+	pickNode := pickNodeAction("id", nodeId)
+
+	return func(pc *fisk.ParseContext) error {
+		if *workloadId != "" {
+			return nil
+		}
+		if !interactive() {
+			return errors.New("required argument 'workload_id' not provided")
+		}
+
+		err := pickNode(pc)
+		if err != nil {
+			return err
+		}
+
+		nodeClient, closeConn, err := completionClient()
+		if err != nil {
+			return err
+		}
+		defer closeConn()
+
+		info, err := nodeClient.NodeInfo(context.Background(), *nodeId)
+		if err != nil {
+			return err
+		}
+
+		items := make([]picker.Item, 0, len(info.Machines))
+		for _, machine := range info.Machines {
+			items = append(items, picker.Item{
+				Value: machine.Id,
+				Label: fmt.Sprintf("%s  %s  (%s)", machine.Id, machine.Workload.Name, machine.Workload.WorkloadType),
+			})
+		}
+
+		*workloadId, err = picker.Pick("Select a workload", items)
+		return err
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+const (
+	contextsDirName    = "contexts"
+	contextFileSuffix  = ".json"
+	currentContextFile = "context.txt"
+	contextFileMode    = os.FileMode(0600) // contexts may hold passwords
+	defaultNamespace   = "default"
+)
+
+var (
+	validContextName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+	applyContextOnce sync.Once
+)
+
+// A named set of connection details and defaults, selected with `nex context use` so that
+// they don't have to be passed as flags on every invocation. Flags and their environment
+// variables always take precedence over the selected context
+type cliContext struct {
+	Description string `json:"description,omitempty"`
+
+	Servers     string `json:"servers,omitempty"`
+	NatsContext string `json:"nats_context,omitempty"`
+	Creds       string `json:"creds,omitempty"`
+	Nkey        string `json:"nkey,omitempty"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	TlsCert     string `json:"tls_cert,omitempty"`
+	TlsKey      string `json:"tls_key,omitempty"`
+	TlsCA       string `json:"tls_ca,omitempty"`
+
+	Namespace string `json:"namespace,omitempty"`
+	Nexus     string `json:"nexus,omitempty"`
+}
+
+func contextsDir() string {
+	return path.Join(nexDir, contextsDirName)
+}
+
+func contextPath(name string) string {
+	return path.Join(contextsDir(), name+contextFileSuffix)
+}
+
+func loadCliContext(name string) (*cliContext, error) {
+	raw, err := os.ReadFile(contextPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unknown context %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var nctx cliContext
+	err = json.Unmarshal(raw, &nctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read context %q: %s", name, err)
+	}
+
+	return &nctx, nil
+}
+
+func saveCliContext(name string, nctx *cliContext) error {
+	err := os.MkdirAll(contextsDir(), defaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(nctx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(contextPath(name), raw, contextFileMode)
+}
+
+// Names of the stored contexts, sorted
+func listCliContexts() ([]string, error) {
+	entries, err := os.ReadDir(contextsDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), contextFileSuffix) {
+			names = append(names, strings.TrimSuffix(entry.Name(), contextFileSuffix))
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Name of the context in effect: the one given by --nex-context or NEX_CONTEXT, otherwise the
+// one selected with `nex context use`, if any
+func currentCliContext() string {
+	if Opts.CliContext != "" {
+		return Opts.CliContext
+	}
+
+	raw, err := os.ReadFile(path.Join(nexDir, currentContextFile))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(raw))
+}
+
+// Fills in the options that were not given as flags from the context in effect, and then
+// applies the defaults. Safe to call more than once
+func applyCliContext() error {
+	var err error
+	applyContextOnce.Do(func() {
+		err = applyCliContextOnce()
+	})
+
+	return err
+}
+
+func applyCliContextOnce() error {
+	defer func() {
+		if Opts.Servers == "" {
+			Opts.Servers = nats.DefaultURL
+		}
+		if Opts.Namespace == "" {
+			Opts.Namespace = defaultNamespace
+		}
+	}()
+
+	name := currentCliContext()
+	if name == "" {
+		return nil
+	}
+
+	nctx, err := loadCliContext(name)
+	if err != nil {
+		return err
+	}
+
+	fill := func(opt *string, value string) {
+		if *opt == "" {
+			*opt = value
+		}
+	}
+
+	fill(&Opts.Servers, nctx.Servers)
+	fill(&Opts.ConfigurationContext, nctx.NatsContext)
+	fill(&Opts.Creds, nctx.Creds)
+	fill(&Opts.Nkey, nctx.Nkey)
+	fill(&Opts.Username, nctx.Username)
+	fill(&Opts.Password, nctx.Password)
+	fill(&Opts.TlsCert, nctx.TlsCert)
+	fill(&Opts.TlsKey, nctx.TlsKey)
+	fill(&Opts.TlsCA, nctx.TlsCA)
+	fill(&Opts.Namespace, nctx.Namespace)
+	fill(&Opts.Nexus, nctx.Nexus)
+
+	return nil
+}
+
+// Creates the named context or updates it with the connection flags and defaults given on
+// the command line, or by their environment variables. Settings that were not given are kept
+func SetCliContext(name string, description string) error {
+	if !validContextName.MatchString(name) {
+		return fmt.Errorf("invalid context name %q; names may only contain letters, digits, '.', '-' and '_'", name)
+	}
+
+	nctx, err := loadCliContext(name)
+	if err != nil {
+		nctx = &cliContext{}
+	}
+
+	set := func(setting *string, value string) {
+		if value != "" {
+			*setting = value
+		}
+	}
+
+	set(&nctx.Description, description)
+	set(&nctx.Servers, Opts.Servers)
+	set(&nctx.NatsContext, Opts.ConfigurationContext)
+	set(&nctx.Creds, Opts.Creds)
+	set(&nctx.Nkey, Opts.Nkey)
+	set(&nctx.Username, Opts.Username)
+	set(&nctx.Password, Opts.Password)
+	set(&nctx.TlsCert, Opts.TlsCert)
+	set(&nctx.TlsKey, Opts.TlsKey)
+	set(&nctx.TlsCA, Opts.TlsCA)
+	set(&nctx.Namespace, Opts.Namespace)
+	set(&nctx.Nexus, Opts.Nexus)
+
+	err = saveCliContext(name, nctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Context %s saved\n", name)
+	return nil
+}
+
+// Selects the named context for subsequent invocations
+func UseCliContext(name string) error {
+	_, err := loadCliContext(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path.Join(nexDir, currentContextFile), []byte(name), contextFileMode)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Using context %s\n", name)
+	return nil
+}
+
+// Deletes the named context, deselecting it if it was selected
+func RemoveCliContext(name string) error {
+	_, err := loadCliContext(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(contextPath(name))
+	if err != nil {
+		return err
+	}
+
+	if currentCliContext() == name {
+		err = os.Remove(path.Join(nexDir, currentContextFile))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	fmt.Printf("Context %s removed\n", name)
+	return nil
+}
+
+func ListCliContexts() error {
+	names, err := listCliContexts()
+	if err != nil {
+		return err
+	}
+
+	current := currentCliContext()
+	stored := make(map[string]*cliContext, len(names))
+	for _, name := range names {
+		nctx, err := loadCliContext(name)
+		if err != nil {
+			return err
+		}
+		stored[name] = nctx
+	}
+
+	if structuredOutput() {
+		// credentials are left out so that listings can be shared safely
+		for _, nctx := range stored {
+			nctx.Password = ""
+		}
+		return renderStructured(stored)
+	}
+
+	if len(names) == 0 {
+		fmt.Println("No contexts; create one with `nex context set`")
+		return nil
+	}
+
+	tbl := newTableWriter("Contexts")
+	tbl.AddHeaders("Name", "Current", "Servers", "NATS Context", "Namespace", "Nexus", "Description")
+	for _, name := range names {
+		nctx := stored[name]
+		var selected string
+		if name == current {
+			selected = "*"
+		}
+		tbl.AddRow(name, selected, nctx.Servers, nctx.NatsContext, nctx.Namespace, nctx.Nexus, nctx.Description)
+	}
+	fmt.Println(tbl.Render())
+
+	return nil
+}
+
+// Whether the node belongs to the nexus given by --nexus or the context, if either names one
+func inTargetNexus(node controlapi.PingResponse) bool {
+	return Opts.Nexus == "" || node.Nexus == Opts.Nexus
+}
+
+// Completes context names with the stored contexts
+func completeCliContexts() []string {
+	names, _ := listCliContexts()
+	return names
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/synadia-io/nex/internal/models"
+)
+
+func TestContextFillsOptionsNotGivenAsFlags(t *testing.T) {
+	nexDir = t.TempDir()
+	Opts = &models.Options{Namespace: "from-flag"}
+
+	err := saveCliContext("prod", &cliContext{
+		Servers:   "nats://prod:4222",
+		Namespace: "payments",
+		Nexus:     "east",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Opts.CliContext = "prod"
+	err = applyCliContextOnce()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if Opts.Servers != "nats://prod:4222" || Opts.Nexus != "east" {
+		t.Fatalf("Expected the context's servers and nexus, got %s and %s", Opts.Servers, Opts.Nexus)
+	}
+	if Opts.Namespace != "from-flag" {
+		t.Fatalf("Expected the namespace flag to take precedence, got %s", Opts.Namespace)
+	}
+}
+
+func TestDefaultsApplyWithoutContext(t *testing.T) {
+	nexDir = t.TempDir()
+	Opts = &models.Options{}
+
+	err := applyCliContextOnce()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if Opts.Namespace != defaultNamespace || Opts.Servers == "" {
+		t.Fatalf("Expected the default namespace and servers, got %q and %q", Opts.Namespace, Opts.Servers)
+	}
+}
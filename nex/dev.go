@@ -0,0 +1,288 @@
+//go:build linux || windows
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/choria-io/fisk"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
+	nexnode "github.com/synadia-io/nex/internal/node"
+)
+
+const (
+	devboxNodeName     = "devbox"
+	devboxStartTimeout = 30 * time.Second
+)
+
+func addDevboxFlags(cmd *fisk.CmdClause) {
+	cmd.Arg("file", "Workload file to deploy and redeploy whenever the watched directory changes").Required().ExistingFileVar(&DevRunOpts.Filename)
+	cmd.Arg("env", "Environment variables to pass to workload").StringMapVar(&RunOpts.Env)
+	cmd.Flag("watch", "Directory to watch for changes; defaults to the directory holding the workload file").ExistingDirVar(&DevboxOpts.WatchDir)
+	cmd.Flag("build", "Command run in the watched directory before each deploy, e.g. \"npm run build\"").StringVar(&DevboxOpts.BuildCommand)
+	cmd.Flag("type", "Type of workload; inferred from the file extension by default: v8 for .js, wasm for .wasm and native otherwise").StringVar(&DevboxOpts.WorkloadType)
+	cmd.Flag("trigger_subject", "Trigger subjects to register for subsequent workload execution, if supported by the workload type").StringsVar(&RunOpts.TriggerSubjects)
+	cmd.Flag("argv", "Arguments to pass to the workload, if applicable").StringVar(&RunOpts.Argv)
+	cmd.Flag("memory", "Memory limit for the workload in MiB, if supported by the workload type").IntVar(&RunOpts.MemoryMib)
+	cmd.Flag("port", "Port of the embedded NATS server; a random free port by default").IntVar(&DevboxOpts.Port)
+	cmd.Flag("interval", "How often the watched directory is checked for changes").Default("500ms").DurationVar(&DevboxOpts.PollInterval)
+	cmd.Flag("hot-reload", "For v8 and wasm workloads, swap the script or module of the running workload on each change instead of redeploying it").Default("true").BoolVar(&DevRunOpts.HotReload)
+}
+
+// Runs a self-contained development environment: an embedded NATS server, a no sandbox node
+// connected to it, and a watcher that rebuilds and redeploys the workload whenever a file in the
+// watched directory changes. Everything is torn down when the node exits, e.g. on Ctrl+C
+func RunDevbox(ctx context.Context, logger *slog.Logger, keypair nkeys.KeyPair) error {
+	RunOpts.WorkloadType = controlapi.NexWorkload(DevboxOpts.WorkloadType)
+	if RunOpts.WorkloadType == "" {
+		RunOpts.WorkloadType = inferWorkloadType(DevRunOpts.Filename)
+	}
+	if DevboxOpts.WatchDir == "" {
+		DevboxOpts.WatchDir = filepath.Dir(DevRunOpts.Filename)
+	}
+	DevRunOpts.AutoStop = true
+
+	workDir, err := os.MkdirTemp("", "nex-devbox-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	ns, err := startDevboxServer(workDir)
+	if err != nil {
+		return err
+	}
+	defer ns.Shutdown()
+
+	// the devbox only ever talks to its own server, whatever the context or flags name
+	Opts.Servers = ns.ClientURL()
+	Opts.ConfigurationContext = ""
+	Opts.Creds, Opts.Nkey, Opts.Username, Opts.Password = "", "", "", ""
+	Opts.TlsCert, Opts.TlsKey, Opts.TlsCA = "", "", ""
+	Opts.Nexus = ""
+
+	NodeOpts.ConfigFilepath, err = writeDevboxNodeConfig(workDir)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(newContext(ctx))
+	err = nexnode.CmdUp(Opts, NodeOpts, ctx, cancel, keypair, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Devbox started", slog.String("nats_url", ns.ClientURL()), slog.String("watching", DevboxOpts.WatchDir))
+
+	err = waitForDevboxNode(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	watchAndRedeploy(ctx, logger)
+	return nil
+}
+
+func startDevboxServer(workDir string) (*server.Server, error) {
+	port := DevboxOpts.Port
+	if port == 0 {
+		port = server.RANDOM_PORT
+	}
+
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      port,
+		JetStream: true,
+		StoreDir:  filepath.Join(workDir, "jetstream"),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedded NATS server: %s", err)
+	}
+
+	ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		ns.Shutdown()
+		return nil, errors.New("embedded NATS server did not become ready")
+	}
+
+	return ns, nil
+}
+
+// Writes the configuration of a no sandbox node keeping all of its resources in the work directory
+func writeDevboxNodeConfig(workDir string) (string, error) {
+	config := map[string]any{
+		"default_resource_dir": workDir,
+		"machine_pool_size":    1,
+		"no_sandbox":           true,
+		"workload_types":       []controlapi.NexWorkload{controlapi.NexWorkloadNative, controlapi.NexWorkloadV8, controlapi.NexWorkloadWasm},
+		"tags":                 map[string]string{"node_name": devboxNodeName},
+	}
+
+	raw, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	configPath := filepath.Join(workDir, "config.json")
+	err = os.WriteFile(configPath, raw, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	return configPath, nil
+}
+
+// Waits for the node to answer pings, so that the first deploy has somewhere to go
+func waitForDevboxNode(ctx context.Context) error {
+	nodeClient, closeConn, err := completionClient()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+
+	deadline := time.Now().Add(devboxStartTimeout)
+	for time.Now().Before(deadline) {
+		nodes, _ := nodeClient.PingNodes(ctx)
+		if len(nodes) > 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.New("devbox node exited before it was ready")
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+
+	return errors.New("timed out waiting for the devbox node to start")
+}
+
+// Deploys the workload and then redeploys it each time the watched directory settles after a
+// change, until the context is done
+func watchAndRedeploy(ctx context.Context, logger *slog.Logger) {
+	fingerprint := buildAndDeploy(ctx, logger)
+
+	ticker := time.NewTicker(DevboxOpts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := watchFingerprint(DevboxOpts.WatchDir)
+		if err != nil || current == fingerprint {
+			continue
+		}
+
+		// editors often write a file in several steps; wait for the directory to settle
+		for {
+			time.Sleep(DevboxOpts.PollInterval)
+			settled, err := watchFingerprint(DevboxOpts.WatchDir)
+			if err != nil || settled == current {
+				break
+			}
+			current = settled
+		}
+
+		logger.Info("Change detected, redeploying", slog.String("file", DevRunOpts.Filename))
+		fingerprint = buildAndDeploy(ctx, logger)
+	}
+}
+
+// Builds and deploys the workload, returning the fingerprint of the watched directory afterwards
+// so that files written by the build do not trigger another deploy
+func buildAndDeploy(ctx context.Context, logger *slog.Logger) string {
+	err := runDevboxBuild(ctx)
+	if err != nil {
+		logger.Error("Build failed; waiting for the next change", slog.Any("err", err))
+	} else {
+		err = RunDevWorkload(ctx, logger)
+		if err != nil {
+			logger.Error("Failed to deploy workload; waiting for the next change", slog.Any("err", err))
+		}
+	}
+
+	fingerprint, _ := watchFingerprint(DevboxOpts.WatchDir)
+	return fingerprint
+}
+
+func runDevboxBuild(ctx context.Context) error {
+	if DevboxOpts.BuildCommand == "" {
+		return nil
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", DevboxOpts.BuildCommand)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", DevboxOpts.BuildCommand)
+	}
+	cmd.Dir = DevboxOpts.WatchDir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// Summarizes the names, sizes and modification times of the files under the directory, skipping
+// hidden files and directories such as .git
+func watchFingerprint(dir string) (string, error) {
+	hash := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s|%d|%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+func inferWorkloadType(filename string) controlapi.NexWorkload {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".js":
+		return controlapi.NexWorkloadV8
+	case ".wasm":
+		return controlapi.NexWorkloadWasm
+	default:
+		return controlapi.NexWorkloadNative
+	}
+}
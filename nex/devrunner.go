@@ -1,15 +1,20 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"math/rand"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -57,6 +62,27 @@ func RunDevWorkload(ctx context.Context, logger *slog.Logger) error {
 			logger.Error("failed to validate binary", slog.Any("err", err))
 		}
 		logger.Debug("Binary validation complete", slog.String("os", os), slog.String("arch", arch))
+
+		for fileArch, filename := range DevRunOpts.ArchFiles {
+			_, binaryArch, err := validateBinary(filename)
+			if err != nil {
+				logger.Error("failed to validate binary", slog.Any("err", err), slog.String("file", filename))
+			} else if binaryArch != fileArch {
+				return fmt.Errorf("%s is built for %s, not %s", filename, binaryArch, fileArch)
+			}
+		}
+	}
+
+	// with builds for other architectures, any node matching one of them can be chosen
+	var archs []string
+	if len(DevRunOpts.ArchFiles) > 0 {
+		if arch != "" {
+			archs = append(archs, arch)
+		}
+		for fileArch := range DevRunOpts.ArchFiles {
+			archs = append(archs, fileArch)
+		}
+		arch = ""
 	}
 
 	nc, err := models.GenerateConnectionFromOpts(Opts, logger)
@@ -67,12 +93,22 @@ func RunDevWorkload(ctx context.Context, logger *slog.Logger) error {
 	// node "nearby"
 	nodeClient := controlapi.NewApiClientWithNamespace(nc, 750*time.Millisecond, Opts.Namespace, logger)
 
-	target, err := randomNode(nodeClient, arch, os, RunOpts.WorkloadType)
+	hotReload := DevRunOpts.HotReload && (RunOpts.WorkloadType == controlapi.NexWorkloadV8 || RunOpts.WorkloadType == controlapi.NexWorkloadWasm)
+	if hotReload {
+		reloaded, err := hotReloadDevWorkload(ctx, nc, nodeClient)
+		if err != nil {
+			logger.Warn("Failed to hot reload workload; redeploying it instead", slog.Any("err", err))
+		} else if reloaded {
+			return nil
+		}
+	}
+
+	target, err := randomNode(ctx, nodeClient, arch, archs, os, RunOpts.WorkloadType)
 	if err != nil {
 		return err
 	}
 
-	info, err := nodeClient.NodeInfo(target.NodeId)
+	info, err := nodeClient.NodeInfo(ctx, target.NodeId)
 	if err != nil {
 		return fmt.Errorf("failed to get node info for potential execution target: %s", err)
 	}
@@ -92,6 +128,11 @@ func RunDevWorkload(ctx context.Context, logger *slog.Logger) error {
 		return err
 	}
 
+	archUrls, err := uploadArchFiles(nc, *DevRunOpts, workloadName)
+	if err != nil {
+		return err
+	}
+
 	if RunOpts.WorkloadType == "v8" && len(RunOpts.TriggerSubjects) == 0 {
 		return errors.New("cannot start a function-type workload without specifying at least one trigger subject")
 	}
@@ -104,7 +145,7 @@ func RunDevWorkload(ctx context.Context, logger *slog.Logger) error {
 				if err != nil {
 					return err
 				}
-				stopResp, err := nodeClient.StopWorkload(stopRequest)
+				stopResp, err := nodeClient.StopWorkload(ctx, stopRequest)
 				if err != nil {
 					return err
 				}
@@ -121,7 +162,7 @@ func RunDevWorkload(ctx context.Context, logger *slog.Logger) error {
 		argv = strings.Split(RunOpts.Argv, " ")
 	}
 
-	request, err := controlapi.NewDeployRequest(
+	opts := []controlapi.RequestOption{
 		controlapi.Argv(argv),
 		controlapi.Location(workloadUrl),
 		controlapi.Environment(RunOpts.Env),
@@ -135,12 +176,21 @@ func RunDevWorkload(ctx context.Context, logger *slog.Logger) error {
 		controlapi.WorkloadType(RunOpts.WorkloadType),
 		controlapi.Checksum("abc12345TODOmakethisreal"),
 		controlapi.WorkloadDescription("Workload published in devmode"),
-	)
+		controlapi.Resources(controlapi.WorkloadResources{MemoryMib: RunOpts.MemoryMib}),
+		controlapi.GPUs(RunOpts.GPUs),
+		controlapi.Group(RunOpts.Group),
+		controlapi.HotReload(hotReload),
+	}
+	for fileArch, archUrl := range archUrls {
+		opts = append(opts, controlapi.ArchLocation(fileArch, archUrl))
+	}
+
+	request, err := controlapi.NewDeployRequest(opts...)
 	if err != nil {
 		return err
 	}
 
-	runResponse, err := nodeClient.StartWorkload(request)
+	runResponse, err := nodeClient.StartWorkload(ctx, request)
 	if err != nil {
 		return err
 	}
@@ -149,16 +199,93 @@ func RunDevWorkload(ctx context.Context, logger *slog.Logger) error {
 	return nil
 }
 
-func randomNode(nodeClient *controlapi.Client, arch, os string, workloadType controlapi.NexWorkload) (*controlapi.AuctionResponse, error) {
-	candidates, err := auction(nodeClient, os, arch, workloadType)
+// Uploads the workload file and swaps it into every running workload of the same name deployed by
+// this issuer, returning false when there are none. Workloads deployed without hot reload enabled
+// refuse the request, which is reported as an error
+func hotReloadDevWorkload(ctx context.Context, nc *nats.Conn, nodeClient *controlapi.Client) (bool, error) {
+	issuerKp, err := readOrGenerateIssuer()
+	if err != nil {
+		return false, err
+	}
+	issuer, _ := issuerKp.PublicKey()
+
+	workloadUrl, workloadName, err := uploadWorkload(nc, *DevRunOpts)
+	if err != nil {
+		return false, err
+	}
+
+	hash, err := fileSha256(DevRunOpts.Filename)
+	if err != nil {
+		return false, err
+	}
+
+	responses, err := nodeClient.PingWorkloadsOwnedBy(ctx, workloadName, issuer)
+	if err != nil {
+		return false, err
+	}
+
+	reloaded := false
+	for _, response := range responses {
+		for _, machine := range response.RunningMachines {
+			if machine.Name != workloadName {
+				continue
+			}
+
+			request, err := controlapi.NewHotReloadRequest(machine.Id, workloadName, response.NodeId, workloadUrl, hash, issuerKp)
+			if err != nil {
+				return false, err
+			}
+
+			reloadResponse, err := nodeClient.HotReloadWorkload(ctx, request)
+			if err != nil {
+				return false, err
+			}
+			if !reloadResponse.Reloaded {
+				return false, fmt.Errorf("target node failed to hot reload workload %s", machine.Id)
+			}
+
+			fmt.Printf("Hot reloaded workload %s (%s) on node %s\n", workloadName, machine.Id, response.NodeId)
+			reloaded = true
+		}
+	}
+
+	return reloaded, nil
+}
+
+// Returns the hex encoded SHA-256 digest of the file's contents
+func fileSha256(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	digest := sha256.New()
+	_, err = io.Copy(digest, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+func randomNode(ctx context.Context, nodeClient *controlapi.Client, arch string, archs []string, os string, workloadType controlapi.NexWorkload) (*controlapi.AuctionResponse, error) {
+	candidates, err := auction(ctx, nodeClient, os, arch, archs, workloadType)
 	if err != nil {
 		return nil, err
 	}
 
+	if DevRunOpts.Nearest {
+		nearest := slices.MinFunc(candidates, func(a, b controlapi.AuctionResponse) int {
+			return cmp.Compare(a.RoundTrip, b.RoundTrip)
+		})
+		return &nearest, nil
+	}
+
 	return &candidates[rand.Intn(len(candidates))], nil
 }
 
-func auction(nodeClient *controlapi.Client, os, arch string, workloadType controlapi.NexWorkload) ([]controlapi.AuctionResponse, error) {
+func auction(ctx context.Context, nodeClient *controlapi.Client, os, arch string, archs []string, workloadType controlapi.NexWorkload) ([]controlapi.AuctionResponse, error) {
 	var _os, _arch *string
 	if os != "" {
 		_os = &os
@@ -167,15 +294,31 @@ func auction(nodeClient *controlapi.Client, os, arch string, workloadType contro
 		_arch = &arch
 	}
 
-	candidates, err := nodeClient.Auction(&controlapi.AuctionRequest{
+	antiAffinity := make([]controlapi.AntiAffinityTerm, len(DevRunOpts.Avoid))
+	for i, name := range DevRunOpts.Avoid {
+		antiAffinity[i] = controlapi.AntiAffinityTerm{WorkloadName: name}
+	}
+
+	candidates, err := nodeClient.Auction(ctx, &controlapi.AuctionRequest{
 		Arch:          _arch,
+		Archs:         archs,
 		OS:            _os,
 		WorkloadTypes: []controlapi.NexWorkload{workloadType},
+		GPUs:          RunOpts.GPUs,
+		AntiAffinity:  antiAffinity,
+		Sites:         DevRunOpts.Sites,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	// older nodes ignore the site filter
+	if len(DevRunOpts.Sites) > 0 {
+		candidates = slices.DeleteFunc(candidates, func(candidate controlapi.AuctionResponse) bool {
+			return !controlapi.InSites(candidate.Tags[controlapi.TagSite], DevRunOpts.Sites)
+		})
+	}
+
 	if len(candidates) == 0 {
 		return nil, errors.New("unable to locate candidate node - no nodes discovered")
 	}
@@ -184,6 +327,46 @@ func auction(nodeClient *controlapi.Client, os, arch string, workloadType contro
 }
 
 func uploadWorkload(nc *nats.Conn, devOpts models.DevRunOptions) (string, string, error) {
+	bucket, err := devBucket(nc, devOpts)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := filepath.Base(devOpts.Filename)
+	key = strings.ReplaceAll(key, ".", "")
+
+	workloadUrl, err := uploadFile(bucket, devOpts.Filename, key, devOpts.ChunkBytes)
+	if err != nil {
+		return "", "", err
+	}
+
+	return workloadUrl, key, nil
+}
+
+// Uploads the per-architecture builds of the workload, returning their URLs keyed by architecture
+func uploadArchFiles(nc *nats.Conn, devOpts models.DevRunOptions, workloadName string) (map[string]string, error) {
+	archUrls := make(map[string]string)
+	if len(devOpts.ArchFiles) == 0 {
+		return archUrls, nil
+	}
+
+	bucket, err := devBucket(nc, devOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	for arch, filename := range devOpts.ArchFiles {
+		archUrl, err := uploadFile(bucket, filename, fmt.Sprintf("%s-%s", workloadName, arch), devOpts.ChunkBytes)
+		if err != nil {
+			return nil, err
+		}
+		archUrls[arch] = archUrl
+	}
+
+	return archUrls, nil
+}
+
+func devBucket(nc *nats.Conn, devOpts models.DevRunOptions) (nats.ObjectStore, error) {
 	js, err := nc.JetStream()
 	if err != nil {
 		panic(err)
@@ -201,22 +384,11 @@ func uploadWorkload(nc *nats.Conn, devOpts models.DevRunOptions) (string, string
 			MaxBytes:    int64(maxBytes),
 		})
 		if err != nil {
-			return "", "", err
+			return nil, err
 		}
 	}
-	bytes, err := os.ReadFile(devOpts.Filename)
-	if err != nil {
-		return "", "", err
-	}
-	key := filepath.Base(devOpts.Filename)
-	key = strings.ReplaceAll(key, ".", "")
-
-	_, err = bucket.PutBytes(key, bytes)
-	if err != nil {
-		return "", "", err
-	}
 
-	return fmt.Sprintf("nats://%s/%s", objectStoreName, key), key, nil
+	return bucket, nil
 }
 
 func readOrGenerateIssuer() (nkeys.KeyPair, error) {
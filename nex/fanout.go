@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/choria-io/fisk"
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+// Outcome of an operation fanned out to a single node
+type nodeResult struct {
+	NodeId  string
+	Message string
+	Err     error
+}
+
+func addFanOutFlags(cmd *fisk.CmdClause) {
+	cmd.Flag("all-nodes", "Apply the command to every node that responds to discovery").UnNegatableBoolVar(&FanOutOpts.AllNodes)
+	cmd.Flag("selector", "Apply the command to every node whose tags include the given tag, e.g. region=east. May be repeated").StringMapVar(&FanOutOpts.Selector)
+}
+
+// Discovers the nodes matching the fan-out flags, collecting replies until the request timeout
+func discoverFanOutNodes(ctx context.Context, nodeClient *controlapi.Client) ([]string, error) {
+	request := &controlapi.DiscoverRequest{}
+	if len(FanOutOpts.Selector) > 0 {
+		request.Tags = FanOutOpts.Selector
+	}
+
+	discovered, err := nodeClient.DiscoverNodes(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeIds := make([]string, 0)
+	for node := range discovered {
+		if inTargetNexus(node) {
+			nodeIds = append(nodeIds, node.NodeId)
+		}
+	}
+	sort.Strings(nodeIds)
+
+	return nodeIds, nil
+}
+
+// Runs the operation against every given node concurrently. Results are returned in the order of
+// the node IDs
+func fanOut(ctx context.Context, nodeIds []string, op func(ctx context.Context, nodeId string) (string, error)) []nodeResult {
+	results := make([]nodeResult, len(nodeIds))
+
+	var wg sync.WaitGroup
+	for i, nodeId := range nodeIds {
+		wg.Add(1)
+		go func(i int, nodeId string) {
+			defer wg.Done()
+			message, err := op(ctx, nodeId)
+			results[i] = nodeResult{NodeId: nodeId, Message: message, Err: err}
+		}(i, nodeId)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Prints the outcome for each node, returning an error if the operation failed on any of them
+func reportNodeResults(results []nodeResult) error {
+	if len(results) == 0 {
+		fmt.Println("⛔ No nodes matched")
+		return errors.New("no nodes matched")
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("⛔ %s: %s\n", result.NodeId, result.Err)
+		} else {
+			fmt.Printf("✅ %s: %s\n", result.NodeId, result.Message)
+		}
+	}
+
+	return nodeResultsError(results)
+}
+
+// Returns an error if the operation failed on any node
+func nodeResultsError(results []nodeResult) error {
+	if len(results) == 0 {
+		return errors.New("no nodes matched")
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("operation failed on %d of %d nodes", failed, len(results))
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Wraps a handler so that records are filtered against a level that may change at runtime
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func newLevelHandler(handler slog.Handler, level slog.Leveler) *levelHandler {
+	return &levelHandler{Handler: handler, level: level}
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return newLevelHandler(h.Handler.WithAttrs(attrs), h.level)
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return newLevelHandler(h.Handler.WithGroup(name), h.level)
+}
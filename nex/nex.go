@@ -37,36 +37,213 @@ var (
 	_    = ncli.HelpFlag.Short('h')
 	_    = ncli.WithCheats().CheatCommand.Hidden()
 
-	tui     = ncli.Command("tui", "Start the Nex TUI [BETA]").Alias("ui")
-	nodes   = ncli.Command("node", "Interact with execution engine nodes").Alias("nodes")
-	run     = ncli.Command("run", "Run a workload on a target node")
-	yeet    = ncli.Command("devrun", "Run a workload locating reasonable defaults (developer mode)").Alias("yeet")
-	stop    = ncli.Command("stop", "Stop a running workload")
-	logs    = ncli.Command("logs", "Live monitor workload log emissions")
-	evts    = ncli.Command("events", "Live monitor events from nex nodes")
-	rootfs  = ncli.Command("rootfs", "Build custom rootfs").Alias("fs")
-	lame    = ncli.Command("lameduck", "Command a node to enter lame duck mode")
-	upgrade = ncli.Command("upgrade", "Upgrade the NEX CLI to the latest version")
+	tui        = ncli.Command("tui", "Start the Nex TUI [BETA]").Alias("ui")
+	nodes      = ncli.Command("node", "Interact with execution engine nodes").Alias("nodes")
+	run        = ncli.Command("run", "Run a workload on a target node")
+	yeet       = ncli.Command("devrun", "Run a workload locating reasonable defaults (developer mode)").Alias("yeet")
+	stop       = ncli.Command("stop", "Stop a running workload")
+	logs       = ncli.Command("logs", "Live monitor workload log emissions")
+	evts       = ncli.Command("events", "Live monitor events from nex nodes")
+	audit      = ncli.Command("audit", "Live monitor the control API requests audited by nex nodes")
+	rootfs     = ncli.Command("rootfs", "Build custom rootfs").Alias("fs")
+	lame       = ncli.Command("lameduck", "Command a node to enter lame duck mode")
+	history    = ncli.Command("history", "Show the recent trigger executions of a function workload")
+	job        = ncli.Command("job", "Show the status of a job workload, including its exit code and final output")
+	quarantine = ncli.Command("quarantine", "Inspect workloads quarantined for failing triggers or crashing, and resume or stop them")
+	usage      = ncli.Command("usage", "Show the resources the namespace's workloads have used on a node, as metered for chargeback")
+	volumes    = ncli.Command("volumes", "Inspect and delete the persistent volumes kept by a node for a namespace's workloads").Alias("vol")
+	checkpoint = ncli.Command("checkpoint", "Snapshot service workloads into a checkpoint bucket, and restore them on any node sharing it").Alias("cp")
+	pause      = ncli.Command("pause", "Pause a workload without undeploying it, suspending its triggers and optionally its machine")
+	resume     = ncli.Command("resume", "Resume a paused workload")
+	rollout    = ncli.Command("rollout", "Replace a workload across the nodes running it, a few nodes at a time")
+	namespaces = ncli.Command("namespaces", "Manage namespaces, their defaults and quotas").Alias("ns")
+	upgrade    = ncli.Command("upgrade", "Upgrade the NEX CLI to the latest version")
+	contexts   = ncli.Command("context", "Manage named contexts holding connection details, a default namespace and a target nexus").Alias("ctx")
+	operator   = ncli.Command("operator", "Reconcile NexWorkload and NexNodePool Kubernetes resources against the nexus")
+	completion = ncli.Command("completion", "Print a shell completion script that completes commands, node IDs, workload IDs and namespaces, e.g. source <(nex completion bash)")
+
+	quarantineLs     = quarantine.Command("ls", "List the workloads quarantined on a node")
+	quarantineResume = quarantine.Command("resume", "Resume a quarantined workload's triggers, or redeploy it if it was quarantined after crashing")
+	quarantineStop   = quarantine.Command("stop", "Stop a quarantined workload")
+
+	volumesLs = volumes.Command("ls", "List the volumes a node keeps for the namespace")
+	volumesRm = volumes.Command("rm", "Delete a volume and its contents from a node; volumes attached to a running workload cannot be deleted")
+
+	checkpointCreate  = checkpoint.Command("create", "Checkpoint a running service workload, optionally stopping it so that it can be moved by restoring it elsewhere")
+	checkpointLs      = checkpoint.Command("ls", "List the checkpoints of the namespace's workloads in a node's checkpoint bucket")
+	checkpointRestore = checkpoint.Command("restore", "Restore a workload from a checkpoint onto a node; the workload resumes where it was checkpointed")
+
+	rolloutStart  = rollout.Command("start", "Replace the workload named by --name with the one given, coordinating the rollout until it finishes")
+	rolloutStatus = rollout.Command("status", "Show the progress of a rollout")
+	rolloutPause  = rollout.Command("pause", "Pause a rollout; node updates in progress are allowed to finish")
+	rolloutResume = rollout.Command("resume", "Resume a paused rollout")
+	rolloutAbort  = rollout.Command("abort", "Abort a rollout; node updates in progress are not reverted")
+
+	namespacesCreate = namespaces.Command("create", "Create the namespace given by --namespace")
+	namespacesInfo   = namespaces.Command("info", "Show the namespace given by --namespace")
+	namespacesLs     = namespaces.Command("ls", "List namespaces")
+	namespacesRm     = namespaces.Command("rm", "Delete the namespace given by --namespace, stopping or orphaning its workloads")
 
 	nodesLs   = nodes.Command("ls", "List nodes")
 	nodesInfo = nodes.Command("info", "Get information for an engine node")
 
 	nodesProbe = nodes.Command("probe", "Probe nodes for matching workloads")
 
-	// These two commands are GOOS/GOARCH dependent
+	nodesNexus     = nodes.Command("nexus", "Show a merged view of every node in the nexus, as gathered by an aggregator")
+	nodesAggregate = nodes.Command("aggregate", "Run a dedicated aggregator answering nexus info requests")
+	nodesMembers   = nodes.Command("members", "List the nodes registered in the nexus registry")
+
+	nodesCordon       = nodes.Command("cordon", "Stop a node from accepting new workloads while its existing workloads keep running")
+	nodesUncordon     = nodes.Command("uncordon", "Allow a cordoned node to accept new workloads again")
+	nodesReload       = nodes.Command("reload", "Reload a node's configuration file without restarting it")
+	nodesTag          = nodes.Command("tag", "Add or replace tags on a running node; changes persist across restarts")
+	nodesUntag        = nodes.Command("untag", "Remove tags from a running node; changes persist across restarts")
+	nodesUpdate       = nodes.Command("update", "Update a node to a signed nex binary; the node restarts into it and redeploys its workloads")
+	nodesRootfs       = nodes.Command("rootfs", "Roll a new agent rootfs image across a node, recycling its pending agents and then its workloads")
+	nodesRootfsStatus = nodes.Command("rootfs-status", "Show the progress of a node's rootfs rollout")
+	nodesRootfsAbort  = nodes.Command("rootfs-abort", "Abort a node's rootfs rollout and return to the previous image for new agents")
+	nodesEvacuate     = nodes.Command("evacuate", "Move a node's workloads to its peers, then put it in lame duck mode, e.g. ahead of a spot interruption")
+	nodesRotateKeys   = nodes.Command("rotate-keys", "Replace a node's xkey; the node keeps its ID and accepts the previous xkey until the grace period ends")
+	nodesDiagnostics  = nodes.Command("diagnostics", "Show a snapshot of a node's agent pools, workloads and subscriptions, optionally saving pprof profiles")
+
+	// These commands are GOOS/GOARCH dependent
 	nodeUp        *fisk.CmdClause
 	nodePreflight *fisk.CmdClause
-
-	node_info_id_arg = nodesInfo.Arg("id", "Public key of the node you're interested in").Required().String()
-
-	Opts       = &models.Options{}
-	GuiOpts    = &models.UiOptions{}
-	RunOpts    = &models.RunOptions{Env: make(map[string]string)}
-	DevRunOpts = &models.DevRunOptions{}
-	StopOpts   = &models.StopOptions{}
-	WatchOpts  = &models.WatchOptions{}
-	NodeOpts   = &models.NodeOptions{}
-	RootfsOpts = &models.RootfsOptions{}
+	devbox        *fisk.CmdClause
+
+	node_info_id_arg     = nodesInfo.Arg("id", "Public key of the node you're interested in; omit with --all-nodes or --selector, or to pick one interactively").HintAction(completeNodeIds).String()
+	node_cordon_id_arg   = nodesCordon.Arg("id", "Public key of the node to cordon; omit to pick one interactively").HintAction(completeNodeIds).String()
+	node_uncordon_id_arg = nodesUncordon.Arg("id", "Public key of the node to uncordon; omit to pick one interactively").HintAction(completeNodeIds).String()
+	node_reload_id_arg   = nodesReload.Arg("id", "Public key of the node to reload; omit to pick one interactively").HintAction(completeNodeIds).String()
+	node_tag_id_arg      = nodesTag.Arg("id", "Public key of the node to tag").Required().String()
+	node_tag_args        = nodesTag.Arg("tags", "Tags to set, in the form name=value").Required().Strings()
+	node_untag_id_arg    = nodesUntag.Arg("id", "Public key of the node to untag").Required().String()
+	node_untag_args      = nodesUntag.Arg("tags", "Names of the tags to remove").Required().Strings()
+
+	node_update_id_arg      = nodesUpdate.Arg("id", "Public key of the node to update; omit to pick one interactively").HintAction(completeNodeIds).String()
+	node_update_location    = nodesUpdate.Flag("location", "URL or nats://{bucket}/{object} from which the node fetches the new binary").Required().String()
+	node_update_signature   = nodesUpdate.Flag("signature", "Signature of the binary by a key the node trusts for updates").String()
+	node_update_binary      = nodesUpdate.Flag("binary", "Local copy of the binary to sign with --signing_key in place of --signature").ExistingFile()
+	node_update_signing_key = nodesUpdate.Flag("signing_key", "File containing the seed of a key the node trusts for updates").ExistingFile()
+	node_update_version     = nodesUpdate.Flag("target_version", "Semantic version of the new binary, covered by its signature; must be newer than the node's version").Required().String()
+	node_update_identity    = nodesUpdate.Flag("identity", "File containing the seed of the issuer identifying the request, for nodes enforcing an access policy; defaults to --signing_key").ExistingFile()
+	node_update_timeout     = nodesUpdate.Flag("update_timeout", "Time to wait for the node to fetch and verify the binary").Default("5m").Duration()
+
+	node_rootfs_id_arg        = nodesRootfs.Arg("id", "Public key of the node to roll the rootfs across; omit to pick one interactively").HintAction(completeNodeIds).String()
+	node_rootfs_location      = nodesRootfs.Flag("location", "oci:// or nats://{bucket}/{object} location of the new rootfs image").Required().String()
+	node_rootfs_sha256        = nodesRootfs.Flag("sha256", "Hex encoded SHA-256 digest of the new rootfs image").Required().String()
+	node_rootfs_version       = nodesRootfs.Flag("target_version", "Version of the new rootfs image, reported in rollout events").String()
+	node_rootfs_interval      = nodesRootfs.Flag("recycle_interval", "Time to wait between recycling running workloads").Default("5s").Duration()
+	node_rootfs_recycle_all   = nodesRootfs.Flag("recycle_non_essential", "Also recycle running workloads that are not essential").Default("false").Bool()
+	node_rootfs_timeout       = nodesRootfs.Flag("fetch_timeout", "Time to wait for the node to fetch and verify the image").Default("5m").Duration()
+	node_rootfs_status_id_arg = nodesRootfsStatus.Arg("id", "Public key of the node rolling out a rootfs; omit to pick one interactively").HintAction(completeNodeIds).String()
+	node_rootfs_abort_id_arg  = nodesRootfsAbort.Arg("id", "Public key of the node rolling out a rootfs; omit to pick one interactively").HintAction(completeNodeIds).String()
+
+	node_evacuate_id_arg   = nodesEvacuate.Arg("id", "Public key of the node to evacuate; omit to pick one interactively").HintAction(completeNodeIds).String()
+	node_evacuate_reason   = nodesEvacuate.Flag("reason", "Reason for evacuating the node, reported in evacuation events").String()
+	node_evacuate_deadline = nodesEvacuate.Flag("deadline", "Time allowed to move the workloads; defaults to the node's configured deadline").Duration()
+
+	node_rotate_keys_id_arg = nodesRotateKeys.Arg("id", "Public key of the node whose xkey to rotate; omit to pick one interactively").HintAction(completeNodeIds).String()
+	node_rotate_keys_grace  = nodesRotateKeys.Flag("grace", "Time the previous xkey is still accepted; defaults to the node's configured grace period").Duration()
+
+	node_diagnostics_id_arg     = nodesDiagnostics.Arg("id", "Public key of the node to diagnose; omit to pick one interactively").HintAction(completeNodeIds).String()
+	node_diagnostics_profiles   = nodesDiagnostics.Flag("profile", "Name of a pprof profile to save, e.g. heap, allocs or goroutine; may be repeated").Strings()
+	node_diagnostics_goroutines = nodesDiagnostics.Flag("goroutines", "Save a dump of every goroutine's stack").Default("false").Bool()
+	node_diagnostics_output     = nodesDiagnostics.Flag("output", "Directory in which profiles and goroutine dumps are saved").Default(".").ExistingDir()
+
+	nexus_info_timeout       = nodesNexus.Flag("nexus_timeout", "Time to wait for an aggregator to gather the nexus").Default("10s").Duration()
+	aggregate_node_timeout   = nodesAggregate.Flag("node_timeout", "Time to wait for each node's info").Default("2s").Duration()
+	aggregate_discovery_wait = nodesAggregate.Flag("discovery_window", "Time to wait for nodes to answer the aggregator's ping").Default("1s").Duration()
+	nexus_members_bucket     = nodesMembers.Flag("bucket", "Key-value bucket in which the nodes register").Default(controlapi.DefaultNexusRegistryBucket).String()
+
+	operator_kube_server   = operator.Flag("kube-server", "URL of the Kubernetes API server, e.g. http://127.0.0.1:8001 for kubectl proxy; defaults to the in-cluster service account").String()
+	operator_kube_token    = operator.Flag("kube-token", "Path to a file holding the bearer token for the Kubernetes API server").ExistingFile()
+	operator_kube_ca       = operator.Flag("kube-ca", "Path to the CA certificate of the Kubernetes API server").ExistingFile()
+	operator_kube_insecure = operator.Flag("kube-insecure", "Skip verification of the Kubernetes API server's certificate").Bool()
+	operator_issuer        = operator.Flag("issuer", "Path to a seed key signing the workloads the operator deploys and stops").Required().ExistingFile()
+	operator_xkey          = operator.Flag("xkey", "Path to the publisher Xkey encrypting the environment of the workloads the operator deploys").Required().ExistingFile()
+	operator_resync        = operator.Flag("resync", "How often every resource is reconciled in the absence of changes").Default("30s").Duration()
+
+	history_node_arg     = history.Arg("id", "Public key of the node running the workload; omit to pick one interactively").HintAction(completeNodeIds).String()
+	history_workload_arg = history.Arg("workload_id", "Unique ID of the workload; omit to pick one interactively").HintAction(completeWorkloadIds(history_node_arg)).String()
+	history_limit        = history.Flag("limit", "Maximum number of executions to show, most recent last").Default("25").Int()
+
+	job_node_arg     = job.Arg("id", "Public key of the node the job was deployed to; omit to pick one interactively").HintAction(completeNodeIds).String()
+	job_workload_arg = job.Arg("workload_id", "Unique ID of the job workload; omit to pick one interactively").HintAction(completeWorkloadIds(job_node_arg)).String()
+
+	quarantine_ls_node_arg         = quarantineLs.Arg("id", "Public key of the node; omit to pick one interactively").HintAction(completeNodeIds).String()
+	quarantine_resume_node_arg     = quarantineResume.Arg("id", "Public key of the node running the workload; omit to pick one interactively").HintAction(completeNodeIds).String()
+	quarantine_resume_workload_arg = quarantineResume.Arg("workload_id", "Unique ID of the quarantined workload; omit to pick one interactively").HintAction(completeWorkloadIds(quarantine_resume_node_arg)).String()
+	quarantine_resume_issuer       = quarantineResume.Flag("issuer", "Path to the issuer seed key originally used to start the workload, or to a node admin key").Required().ExistingFile()
+	quarantine_stop_node_arg       = quarantineStop.Arg("id", "Public key of the node running the workload; omit to pick one interactively").HintAction(completeNodeIds).String()
+	quarantine_stop_workload_arg   = quarantineStop.Arg("workload_id", "Unique ID of the quarantined workload; omit to pick one interactively").HintAction(completeWorkloadIds(quarantine_stop_node_arg)).String()
+	quarantine_stop_issuer         = quarantineStop.Flag("issuer", "Path to the issuer seed key originally used to start the workload, or to a node admin key").Required().ExistingFile()
+
+	usage_node_arg = usage.Arg("id", "Public key of the node; omit to pick one interactively").HintAction(completeNodeIds).String()
+
+	volumes_ls_node_arg = volumesLs.Arg("id", "Public key of the node; omit to pick one interactively").HintAction(completeNodeIds).String()
+	volumes_rm_node_arg = volumesRm.Arg("id", "Public key of the node keeping the volume").Required().HintAction(completeNodeIds).String()
+	volumes_rm_name_arg = volumesRm.Arg("name", "Name of the volume").Required().String()
+	volumes_rm_issuer   = volumesRm.Flag("issuer", "Path to the seed key of the issuer whose workload created the volume, or to a node admin key").Required().ExistingFile()
+
+	checkpoint_create_node_arg     = checkpointCreate.Arg("id", "Public key of the node running the workload; omit to pick one interactively").HintAction(completeNodeIds).String()
+	checkpoint_create_workload_arg = checkpointCreate.Arg("workload_id", "Unique ID of the workload; omit to pick one interactively").HintAction(completeWorkloadIds(checkpoint_create_node_arg)).String()
+	checkpoint_create_stop         = checkpointCreate.Flag("stop", "Stop the workload once its checkpoint is stored").Default("false").Bool()
+	checkpoint_create_issuer       = checkpointCreate.Flag("issuer", "Path to the issuer seed key originally used to start the workload, or to a node admin key").Required().ExistingFile()
+	checkpoint_ls_node_arg         = checkpointLs.Arg("id", "Public key of the node; omit to pick one interactively").HintAction(completeNodeIds).String()
+	checkpoint_ls_workload         = checkpointLs.Flag("workload", "Only list the checkpoints of the given workload").String()
+	checkpoint_restore_node_arg    = checkpointRestore.Arg("id", "Public key of the node to restore the workload on").Required().HintAction(completeNodeIds).String()
+	checkpoint_restore_id_arg      = checkpointRestore.Arg("checkpoint_id", "ID of the checkpoint").Required().String()
+	checkpoint_restore_issuer      = checkpointRestore.Flag("issuer", "Path to the issuer seed key originally used to start the workload, or to a node admin key").Required().ExistingFile()
+
+	pause_node_arg      = pause.Arg("id", "Public key of the node running the workload; omit to pick one interactively").HintAction(completeNodeIds).String()
+	pause_workload_arg  = pause.Arg("workload_id", "Unique ID of the workload; omit to pick one interactively").HintAction(completeWorkloadIds(pause_node_arg)).String()
+	pause_machine       = pause.Flag("machine", "Also pause the workload's VM, preserving its memory until resumed").Default("false").Bool()
+	pause_issuer        = pause.Flag("issuer", "Path to the issuer seed key originally used to start the workload, or to a node admin key").Required().ExistingFile()
+	resume_node_arg     = resume.Arg("id", "Public key of the node running the workload; omit to pick one interactively").HintAction(completeNodeIds).String()
+	resume_workload_arg = resume.Arg("workload_id", "Unique ID of the paused workload; omit to pick one interactively").HintAction(completeWorkloadIds(resume_node_arg)).String()
+	resume_issuer       = resume.Flag("issuer", "Path to the issuer seed key originally used to start the workload, or to a node admin key").Required().ExistingFile()
+
+	rollout_status_id_arg = rolloutStatus.Arg("rollout_id", "ID of the rollout").Required().String()
+	rollout_pause_id_arg  = rolloutPause.Arg("rollout_id", "ID of the rollout").Required().String()
+	rollout_resume_id_arg = rolloutResume.Arg("rollout_id", "ID of the rollout").Required().String()
+	rollout_abort_id_arg  = rolloutAbort.Arg("rollout_id", "ID of the rollout").Required().String()
+
+	contextSet  = contexts.Command("set", "Create or update a context from the connection flags given, e.g. nex context set prod -s nats://prod:4222 --creds prod.creds --namespace payments")
+	contextUse  = contexts.Command("use", "Select the context used by subsequent invocations")
+	contextList = contexts.Command("ls", "List contexts").Alias("list")
+	contextRm   = contexts.Command("rm", "Delete a context")
+
+	context_set_name_arg    = contextSet.Arg("name", "Name of the context").Required().String()
+	context_set_description = contextSet.Flag("description", "Description of the context").String()
+	context_use_name_arg    = contextUse.Arg("name", "Name of the context").Required().HintAction(completeCliContexts).String()
+	context_rm_name_arg     = contextRm.Arg("name", "Name of the context").Required().HintAction(completeCliContexts).String()
+
+	completion_shell_arg = completion.Arg("shell", "Shell to complete for").Required().Enum("bash", "zsh", "fish")
+
+	namespaces_description     = namespacesCreate.Flag("description", "Description of the namespace").String()
+	namespaces_metadata        = namespacesCreate.Flag("meta", "Metadata of the namespace, e.g. owner=payments. May be repeated").StringMap()
+	namespaces_max_workloads   = namespacesCreate.Flag("max-workloads", "Maximum number of workloads each node runs in the namespace").Int()
+	namespaces_max_memory      = namespacesCreate.Flag("max-memory", "Maximum memory in MiB declared by the workloads each node runs in the namespace").Int()
+	namespaces_default_memory  = namespacesCreate.Flag("default-memory", "Memory limit in MiB applied to workloads deployed without one").Int()
+	namespaces_default_env     = namespacesCreate.Flag("default-env", "Environment variable given to workloads deployed into the namespace unless they set it, e.g. LOG_LEVEL=info. May be repeated").StringMap()
+	namespaces_default_config  = namespacesCreate.Flag("default-config", "Path to a JSON file holding an object given to workloads deployed into the namespace in NEX_NAMESPACE_CONFIG").ExistingFile()
+	namespaces_deletion_policy = namespacesCreate.Flag("deletion-policy", "What happens to the namespace's workloads when it is deleted").Default("stop").Enum("stop", "orphan")
+	namespaces_rm_policy       = namespacesRm.Flag("policy", "Overrides the namespace's deletion policy").Enum("stop", "orphan")
+	namespaces_rm_identity     = namespacesRm.Flag("identity", "File containing the seed of a node administrator; required unless the workloads are orphaned on nodes without an access policy").ExistingFile()
+
+	Opts        = &models.Options{}
+	GuiOpts     = &models.UiOptions{}
+	RunOpts     = &models.RunOptions{Env: make(map[string]string), Labels: make(map[string]string), Mounts: make(map[string]string), MountDigests: make(map[string]string), ArchUrls: make(map[string]string)}
+	DevRunOpts  = &models.DevRunOptions{ArchFiles: make(map[string]string)}
+	DevboxOpts  = &models.DevboxOptions{}
+	StopOpts    = &models.StopOptions{}
+	RolloutOpts = &models.RolloutOptions{}
+	FanOutOpts  = &models.FanOutOptions{Selector: make(map[string]string)}
+	OutputOpts  = &models.OutputOptions{Format: outputTable}
+	WatchOpts   = &models.WatchOptions{}
+	NodeOpts    = &models.NodeOptions{}
+	RootfsOpts  = &models.RootfsOptions{}
 
 	workloadType string
 )
@@ -74,7 +251,7 @@ var (
 func init() {
 	updatable, _ = versionCheck()
 
-	ncli.Flag("server", "NATS server urls").Short('s').Envar("NATS_URL").Default(nats.DefaultURL).StringVar(&Opts.Servers)
+	ncli.Flag("server", "NATS server urls; defaults to the context's servers, or "+nats.DefaultURL).Short('s').Envar("NATS_URL").StringVar(&Opts.Servers)
 	ncli.Flag("user", "Username or Token").Envar("NATS_USER").PlaceHolder("USER").StringVar(&Opts.Username)
 	ncli.Flag("password", "Password").Envar("NATS_PASSWORD").PlaceHolder("PASSWORD").StringVar(&Opts.Password)
 	ncli.Flag("creds", "User credentials file (JWT authentication)").Envar("NATS_CREDS").PlaceHolder("FILE").StringVar(&Opts.Creds)
@@ -85,13 +262,15 @@ func init() {
 	ncli.Flag("tlsfirst", "Perform TLS handshake before expecting the server greeting").BoolVar(&Opts.TlsFirst)
 	ncli.Flag("timeout", "Time to wait on responses from NATS").Default("2s").Envar("NATS_TIMEOUT").PlaceHolder("DURATION").DurationVar(&Opts.Timeout)
 	ncli.Flag("jsdomain", "Jetsteam domain to use in nats connection").PlaceHolder("nex").StringVar(&Opts.JsDomain)
-	ncli.Flag("namespace", "Scoping namespace for applicable operations").Default("default").Envar("NEX_NAMESPACE").StringVar(&Opts.Namespace)
+	ncli.Flag("namespace", "Scoping namespace for applicable operations; defaults to the context's namespace, or default").Envar("NEX_NAMESPACE").HintAction(completeNamespaces).StringVar(&Opts.Namespace)
 	ncli.Flag("logger", "How to log").Default("std").Envar("NEX_LOGGER").StringsVar(&Opts.Logger) // Valid options: "std", "file", "nats"
 	ncli.Flag("loglevel", "Log level").Default("info").Envar("NEX_LOGLEVEL").EnumVar(&Opts.LogLevel, "debug", "info", "warn", "error")
 	ncli.Flag("logjson", "Log JSON").Default("false").Envar("NEX_LOGJSON").UnNegatableBoolVar(&Opts.LogJSON)
 	ncli.Flag("logcolor", "Prints text logs with color").Envar("NEX_LOG_COLORIZED").Default("false").UnNegatableBoolVar(&Opts.LogsColorized)
 	ncli.Flag("timeformat", "How time is formatted in logger").Envar("NEX_LOG_TIMEFORMAT").Default("DateTime").EnumVar(&Opts.LogTimeFormat, "DateOnly", "DateTime", "Stamp", "RFC822", "RFC3339")
 	ncli.Flag("context", "Configuration context").Envar("NATS_CONTEXT").PlaceHolder("NAME").StringVar(&Opts.ConfigurationContext)
+	ncli.Flag("nex-context", "nex CLI context to use in place of the one selected with `nex context use`").Envar("NEX_CONTEXT").PlaceHolder("NAME").HintAction(completeCliContexts).StringVar(&Opts.CliContext)
+	ncli.Flag("nexus", "Only target nodes in the given nexus; defaults to the context's nexus").Envar("NEX_NEXUS").StringVar(&Opts.Nexus)
 	ncli.Flag("conn-name", "Name of NATS connection").Default(func() string {
 		if VERSION != "development" {
 			return "nex-" + VERSION
@@ -100,40 +279,102 @@ func init() {
 	}()).StringVar(&Opts.ConnectionName)
 
 	run.Arg("url", "URL pointing to the file to run").Required().URLVar(&RunOpts.WorkloadUrl)
+	run.Flag("arch-url", "URL of the workload built for a specific architecture, e.g. arm64=nats://BUCKET/key, deployed in place of url on nodes of that architecture. May be repeated").StringMapVar(&RunOpts.ArchUrls)
 	run.Arg("id", "Public key of the target node to run the workload").Required().StringVar(&RunOpts.TargetNode)
 	run.Flag("xkey", "Path to publisher's Xkey required to encrypt environment").Required().ExistingFileVar(&RunOpts.PublisherXkeyFile)
 	run.Flag("issuer", "Path to a seed key to sign the workload JWT as the issuer").Required().ExistingFileVar(&RunOpts.ClaimsIssuerFile)
 	run.Arg("env", "Environment variables to pass to workload").StringMapVar(&RunOpts.Env)
 	run.Flag("name", "Name of the workload. Must be alphabetic (lowercase)").Required().StringVar(&RunOpts.Name)
-	run.Flag("type", "Type of workload").Default("native").EnumVar(&workloadType, "native", "v8", "wasm")
+	run.Flag("type", "Type of workload; native, v8, wasm, jvm, job or a type provided by an agent extension").Default("native").StringVar(&workloadType)
+	run.Flag("memory", "Memory limit for the workload in MiB, if supported by the workload type (e.g. the JVM heap is derived from it)").IntVar(&RunOpts.MemoryMib)
+	run.Flag("gpus", "Number of GPUs to assign to the workload; requires a no sandbox node with GPUs").IntVar(&RunOpts.GPUs)
 	run.Flag("description", "Description of the workload").StringVar(&RunOpts.Description)
 	run.Flag("argv", "Arguments to pass to the workload, if applicable").StringVar(&RunOpts.Argv)
+	run.Flag("group", "Group the workload belongs to, so related workloads can be stopped, restarted or queried together").StringVar(&RunOpts.Group)
+	run.Flag("label", "Label identifying the workload, e.g. tier=web. May be repeated").StringMapVar(&RunOpts.Labels)
+	run.Flag("stop-grace-period", "How long the workload is given to exit cleanly when stopped before it is killed").DurationVar(&RunOpts.StopGracePeriod)
+	run.Flag("ttl", "Automatically stop the workload once it has been running for this long, e.g. for batch jobs and demos").DurationVar(&RunOpts.TTL)
+	run.Flag("input", "Path to a local file delivered to a native or job workload as its stdin, or at --input-path").ExistingFileVar(&RunOpts.InputFile)
+	run.Flag("input-url", "Object store reference, nats://BUCKET/key, to a payload delivered like --input").StringVar(&RunOpts.InputUrl)
+	run.Flag("input-path", "Absolute path inside the sandbox at which the input is written instead of being piped to stdin").StringVar(&RunOpts.InputPath)
+	run.Flag("volume", "Name of a persistent volume kept by the node across restarts and redeploys of the workload; created if it does not exist").StringVar(&RunOpts.VolumeName)
+	run.Flag("volume-path", "Absolute path inside the sandbox at which the volume is mounted; required on sandboxed nodes").StringVar(&RunOpts.VolumePath)
+	run.Flag("volume-size", "Size of the volume in MiB when it is created. Defaults to the node's setting").IntVar(&RunOpts.VolumeSizeMib)
+	run.Flag("mount", "Object store object written read-only inside the sandbox, e.g. /etc/app/config.json=nats://BUCKET/key. May be repeated").StringMapVar(&RunOpts.Mounts)
+	run.Flag("mount-sha256", "Expected SHA-256 digest of a mounted object, keyed by its path, e.g. /etc/app/config.json=<digest>. May be repeated").StringMapVar(&RunOpts.MountDigests)
 	run.Flag("essential", "When true, workload is redeployed if it exits with a non-zero status").BoolVar(&RunOpts.Essential)
 	run.Flag("trigger_subject", "Trigger subjects to register for subsequent workload execution, if supported by the workload type").StringsVar(&RunOpts.TriggerSubjects)
+	run.Flag("trigger-workers", "Number of trigger messages the node executes concurrently for the workload. Defaults to the node's setting").IntVar(&RunOpts.TriggerWorkers)
+	run.Flag("trigger-queue-size", "Number of trigger messages the node holds while every trigger worker is busy. Defaults to the node's setting").IntVar(&RunOpts.TriggerQueueSize)
+	run.Flag("trigger-shed", "Drop trigger messages arriving while the trigger queue is full instead of waiting for room").BoolVar(&RunOpts.TriggerShed)
+	run.Flag("service", "Path to a JSON file declaring a NATS service whose endpoints the node serves with a v8 or wasm function").ExistingFileVar(&RunOpts.ServiceFile)
+	run.Flag("cache-results", "Answer repeated triggers with the same subject and payload from the nodes' result cache for this long. Only for functions whose response depends on nothing else").DurationVar(&RunOpts.CacheTTL)
+	run.Flag("cache-subject", "Trigger subject whose responses are cached; all subjects unless given. May be repeated").StringsVar(&RunOpts.CacheSubjects)
+	run.Flag("init", "Trigger the function once on $NEX.init before it receives other triggers; the deployment is rolled back unless it replies").BoolVar(&RunOpts.Init)
+	run.Flag("init-payload", "Path to a local file whose contents are the payload of the init trigger").ExistingFileVar(&RunOpts.InitPayloadFile)
+	run.Flag("init-timeout", "How long the function is given to reply to its init trigger. Defaults to 30 seconds").DurationVar(&RunOpts.InitTimeout)
 	run.Flag("hs_url", "Override the URL used for host services for this workload").StringVar(&RunOpts.HsUrl)
 	run.Flag("hs_jwt", "Set the user JWT for override host services connection").StringVar(&RunOpts.HsUserJwt)
 	run.Flag("hs_seed", "Set the user seed for override host services connection").StringVar(&RunOpts.HsUserSeed)
+	run.Flag("wait", "Block until the workload has been deployed and has passed its first health probe").BoolVar(&RunOpts.WaitForReady)
+	run.Flag("wait-timeout", "Maximum amount of time to wait for the workload to become ready").Default("30s").DurationVar(&RunOpts.WaitTimeout)
+	run.Flag("rollback-on-failure", "Stop the newly deployed workload if it fails to become ready (implies --wait)").BoolVar(&RunOpts.RollbackOnFailure)
 
 	yeet.Arg("file", "File to run").Required().ExistingFileVar(&DevRunOpts.Filename)
+	yeet.Flag("arch-file", "File of the workload built for another architecture, e.g. arm64=./app-arm64, so nodes of either architecture can be chosen. May be repeated").StringMapVar(&DevRunOpts.ArchFiles)
 	yeet.Arg("env", "Environment variables to pass to workload").StringMapVar(&RunOpts.Env)
 	yeet.Flag("argv", "Arguments to pass to the workload, if applicable").StringVar(&RunOpts.Argv)
+	yeet.Flag("group", "Group the workload belongs to, so related workloads can be stopped, restarted or queried together").StringVar(&RunOpts.Group)
 	yeet.Flag("essential", "When true, workload is redeployed if it exits with a non-zero status").BoolVar(&RunOpts.Essential)
 	yeet.Flag("trigger_subject", "Trigger subjects to register for subsequent workload execution, if supported by the workload type").StringsVar(&RunOpts.TriggerSubjects)
 	yeet.Flag("stop", "Indicates whether to stop pre-existing workloads during launch. Disable with caution").Default("true").BoolVar(&DevRunOpts.AutoStop)
 	yeet.Flag("bucketmaxbytes", "Overrides the default max bytes if the dev object store bucket is created").UintVar(&DevRunOpts.DevBucketMaxBytes)
-	yeet.Flag("type", "Type of workload").Default("native").EnumVar(&workloadType, "native", "v8", "wasm")
-
-	stop.Arg("id", "Public key of the target node on which to stop the workload").Required().StringVar(&StopOpts.TargetNode)
-	stop.Arg("workload_id", "Unique ID of the workload to be stopped").Required().StringVar(&StopOpts.WorkloadId)
+	yeet.Flag("chunkbytes", "Files larger than this are uploaded in chunks of this many bytes; an interrupted upload resumes from its first missing chunk").Default("16777216").UintVar(&DevRunOpts.ChunkBytes)
+	yeet.Flag("type", "Type of workload; native, v8, wasm, jvm, job or a type provided by an agent extension").Default("native").StringVar(&workloadType)
+	yeet.Flag("memory", "Memory limit for the workload in MiB, if supported by the workload type (e.g. the JVM heap is derived from it)").IntVar(&RunOpts.MemoryMib)
+	yeet.Flag("avoid", "Only select a node that is not already running a workload with this name. May be repeated").StringsVar(&DevRunOpts.Avoid)
+	yeet.Flag("gpus", "Number of GPUs to assign to the workload; only nodes with enough free GPUs are selected").IntVar(&RunOpts.GPUs)
+	yeet.Flag("hot-reload", "For v8 and wasm workloads, swap the script or module of a running workload of the same name instead of redeploying it. Meant for development only").BoolVar(&DevRunOpts.HotReload)
+	yeet.Flag("site", "Only select a node in this site, as given by its nex.site tag. May be repeated").StringsVar(&DevRunOpts.Sites)
+	yeet.Flag("nearest", "Select the candidate node with the shortest round trip instead of one at random").UnNegatableBoolVar(&DevRunOpts.Nearest)
+
+	rolloutStart.Arg("url", "URL pointing to the file to run").Required().URLVar(&RunOpts.WorkloadUrl)
+	rolloutStart.Arg("env", "Environment variables to pass to workload").StringMapVar(&RunOpts.Env)
+	rolloutStart.Flag("name", "Name of the workload being replaced, which the new workload takes over").Required().StringVar(&RunOpts.Name)
+	rolloutStart.Flag("xkey", "Path to publisher's Xkey required to encrypt environment").Required().ExistingFileVar(&RunOpts.PublisherXkeyFile)
+	rolloutStart.Flag("issuer", "Path to a seed key to sign the workload JWT as the issuer, which must also have issued the workloads being replaced").Required().ExistingFileVar(&RunOpts.ClaimsIssuerFile)
+	rolloutStart.Flag("type", "Type of workload; native, v8, wasm, jvm, job or a type provided by an agent extension").Default("native").StringVar(&workloadType)
+	rolloutStart.Flag("description", "Description of the workload").StringVar(&RunOpts.Description)
+	rolloutStart.Flag("argv", "Arguments to pass to the workload, if applicable").StringVar(&RunOpts.Argv)
+	rolloutStart.Flag("memory", "Memory limit for the workload in MiB, if supported by the workload type").IntVar(&RunOpts.MemoryMib)
+	rolloutStart.Flag("essential", "When true, workload is redeployed if it exits with a non-zero status").BoolVar(&RunOpts.Essential)
+	rolloutStart.Flag("trigger_subject", "Trigger subjects to register for subsequent workload execution, if supported by the workload type").StringsVar(&RunOpts.TriggerSubjects)
+	rolloutStart.Flag("node", "Public key of a node not yet running the workload to deploy it to as well. May be repeated").HintAction(completeNodeIds).StringsVar(&RolloutOpts.Nodes)
+	rolloutStart.Flag("max-surge", "Number of nodes that may run both the old and the new workload while the new one becomes ready").Default("1").IntVar(&RolloutOpts.MaxSurge)
+	rolloutStart.Flag("max-unavailable", "Number of nodes that may have their old workload stopped before the new one is ready").Default("0").IntVar(&RolloutOpts.MaxUnavailable)
+	rolloutStart.Flag("failure-threshold", "Pause the rollout once this many nodes have failed to update; 0 never pauses").Default("1").IntVar(&RolloutOpts.FailureThreshold)
+	rolloutStart.Flag("ready-timeout", "Maximum amount of time each new workload is given to become ready before it is stopped").Default("30s").DurationVar(&RolloutOpts.ReadyTimeout)
+
+	stop.Arg("id", "Public key of the target node on which to stop the workload; omit to stop the workload by name wherever it runs").StringVar(&StopOpts.TargetNode)
+	stop.Arg("workload_id", "Unique ID of the workload to be stopped; omit to stop the workload by name").StringVar(&StopOpts.WorkloadId)
 	stop.Flag("name", "Name of the workload to stop").Required().StringVar(&StopOpts.WorkloadName)
 	stop.Flag("issuer", "Path to the issuer seed key originally used to start the workload").Required().ExistingFileVar(&StopOpts.ClaimsIssuerFile)
+	stop.Flag("all", "Stop every workload with the name when stopping by name finds more than one").UnNegatableBoolVar(&StopOpts.All)
+	stop.Flag("force", "Stop workloads found by name without asking for confirmation").UnNegatableBoolVar(&StopOpts.Force)
+
+	addFanOutFlags(stop)
 
-	lame.Arg("id", "Public key of the target node to enter lame duck mode").Required().StringVar(&RunOpts.TargetNode)
+	lame.Arg("id", "Public key of the target node to enter lame duck mode; omit with --all-nodes or --selector").StringVar(&RunOpts.TargetNode)
+	addFanOutFlags(lame)
 
 	logs.Flag("node", "Public key of the nex node to filter on").Default("*").StringVar(&WatchOpts.NodeId)
 	logs.Flag("workload_name", "Name of the workload to filter on").Default("*").StringVar(&WatchOpts.WorkloadName)
 	logs.Flag("workload_id", "ID of the workload machine to filter on").Default("*").StringVar(&WatchOpts.WorkloadId)
 	logs.Flag("level", "Log level filter").Default("debug").StringVar(&WatchOpts.LogLevel)
+	logs.Flag("site", "Only show logs of the nodes in this site, such as an edge site joined through a leaf node connection. May be repeated").StringsVar(&WatchOpts.Sites)
+	evts.Flag("site", "Only show events of the nodes in this site, such as an edge site joined through a leaf node connection. May be repeated").StringsVar(&WatchOpts.Sites)
+
+	audit.Flag("node", "Public key of the nex node to filter on").Default("*").StringVar(&WatchOpts.NodeId)
 
 	rootfs.Flag("output", "Output name").Short('o').Default("rootfs.ext4.gz").StringVar(&RootfsOpts.OutName)
 	rootfs.Flag("script", "Additional boot script ran during initialization").PlaceHolder("script.sh").StringVar(&RootfsOpts.BuildScriptPath)
@@ -141,16 +382,75 @@ func init() {
 	rootfs.Flag("agent", "Path to agent binary").PlaceHolder("../path/to/nex-agent").Required().StringVar(&RootfsOpts.AgentBinaryPath)
 	rootfs.Flag("size", "Size of rootfs filesystem").Default(strconv.Itoa(1024 * 1024 * 150)).IntVar(&RootfsOpts.RootFSSize) // 150MB default
 
-	nodesLs.Flag("full", "List more detailed table").Default("false").UnNegatableBoolVar(&NodeOpts.ListFull)
+	nodesLs.Flag("full", "List more detailed table; same as --output wide").Default("false").UnNegatableBoolVar(&NodeOpts.ListFull)
+	nodesLs.Flag("quiet", "Stop listing once no node has responded for this long").Default("500ms").DurationVar(&NodeOpts.ListQuiet)
+	nodesLs.Flag("site", "Only list nodes in this site, as given by their nex.site tag. May be repeated").StringsVar(&NodeOpts.ListSites)
+
+	addFanOutFlags(nodesInfo)
+	nodesInfo.Flag("issuer", "Only show workloads deployed by the given issuer public key").StringVar(&NodeOpts.Issuer)
+
+	nodesCordon.Flag("reason", "Reason for cordoning the node, e.g. a maintenance ticket").StringVar(&NodeOpts.CordonReason)
+	nodesCordon.Flag("for", "Length of the maintenance window, after which the node uncordons itself").DurationVar(&NodeOpts.CordonFor)
 
 	// one day when we refactor, let's get rid of all of these global structs. Such ugly
 	nodesProbe.Flag("workload", "Only query nodes currently running the given workload (id or name)").StringVar(&RunOpts.Name)
+	nodesProbe.Flag("issuer", "Only query workloads deployed by the given issuer public key").StringVar(&NodeOpts.Issuer)
+
+	nodesInfo.PreAction(func(pc *fisk.ParseContext) error {
+		if FanOutOpts.Enabled() {
+			return nil
+		}
+		return pickNodeAction("id", node_info_id_arg)(pc)
+	})
+	nodesCordon.PreAction(pickNodeAction("id", node_cordon_id_arg))
+	nodesUncordon.PreAction(pickNodeAction("id", node_uncordon_id_arg))
+	nodesReload.PreAction(pickNodeAction("id", node_reload_id_arg))
+	nodesUpdate.PreAction(pickNodeAction("id", node_update_id_arg))
+	nodesRootfs.PreAction(pickNodeAction("id", node_rootfs_id_arg))
+	nodesRootfsStatus.PreAction(pickNodeAction("id", node_rootfs_status_id_arg))
+	nodesRootfsAbort.PreAction(pickNodeAction("id", node_rootfs_abort_id_arg))
+	nodesEvacuate.PreAction(pickNodeAction("id", node_evacuate_id_arg))
+	nodesRotateKeys.PreAction(pickNodeAction("id", node_rotate_keys_id_arg))
+	nodesDiagnostics.PreAction(pickNodeAction("id", node_diagnostics_id_arg))
+	quarantineLs.PreAction(pickNodeAction("id", quarantine_ls_node_arg))
+	volumesLs.PreAction(pickNodeAction("id", volumes_ls_node_arg))
+	usage.PreAction(pickNodeAction("id", usage_node_arg))
+	checkpointLs.PreAction(pickNodeAction("id", checkpoint_ls_node_arg))
+	checkpointCreate.PreAction(pickWorkloadAction(checkpoint_create_node_arg, checkpoint_create_workload_arg))
+	quarantineResume.PreAction(pickWorkloadAction(quarantine_resume_node_arg, quarantine_resume_workload_arg))
+	quarantineStop.PreAction(pickWorkloadAction(quarantine_stop_node_arg, quarantine_stop_workload_arg))
+	history.PreAction(pickWorkloadAction(history_node_arg, history_workload_arg))
+	job.PreAction(pickWorkloadAction(job_node_arg, job_workload_arg))
+	pause.PreAction(pickWorkloadAction(pause_node_arg, pause_workload_arg))
+	resume.PreAction(pickWorkloadAction(resume_node_arg, resume_workload_arg))
+
+	for _, cmd := range []*fisk.CmdClause{nodesLs, nodesInfo, nodesProbe, nodesNexus, nodesMembers, nodesRootfsStatus, history, job, quarantineLs, volumesLs, checkpointLs, usage, rolloutStatus, namespacesInfo, namespacesLs, contextList} {
+		addOutputFlag(cmd)
+	}
 }
 
 func main() {
+	// deferred first so that it runs after every other deferred cleanup in main
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	setConditionalCommands()
 	cmd := fisk.MustParse(ncli.Parse(os.Args[1:]))
 
+	// a context is saved from the flags given, so it must not be filled in from another one
+	if cmd != contextSet.FullCommand() {
+		err := applyCliContext()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "nex: error: %s\n", err)
+			exitCode = 1
+			return
+		}
+	}
+
 	switch workloadType {
 	case "native":
 		RunOpts.WorkloadType = controlapi.NexWorkloadNative
@@ -160,21 +460,32 @@ func main() {
 		RunOpts.WorkloadType = controlapi.NexWorkloadOCI
 	case "wasm":
 		RunOpts.WorkloadType = controlapi.NexWorkloadWasm
+	case "jvm":
+		RunOpts.WorkloadType = controlapi.NexWorkloadJVM
+	case "job":
+		RunOpts.WorkloadType = controlapi.NexWorkloadJob
+	default:
+		RunOpts.WorkloadType = controlapi.NexWorkload(workloadType)
 	}
 
 	ctx := context.Background()
 
-	var handlerOpts []shandler.HandlerOption
+	// the level is enforced by levelHandler so that a running node can change it on reload
+	logLevel := new(slog.LevelVar)
 	switch Opts.LogLevel {
 	case "debug":
-		handlerOpts = append(handlerOpts, shandler.WithLogLevel(slog.LevelDebug))
+		logLevel.Set(slog.LevelDebug)
 	case "info":
-		handlerOpts = append(handlerOpts, shandler.WithLogLevel(slog.LevelInfo))
+		logLevel.Set(slog.LevelInfo)
 	case "warn":
-		handlerOpts = append(handlerOpts, shandler.WithLogLevel(slog.LevelWarn))
+		logLevel.Set(slog.LevelWarn)
 	default:
-		handlerOpts = append(handlerOpts, shandler.WithLogLevel(slog.LevelError))
+		logLevel.Set(slog.LevelError)
 	}
+	NodeOpts.LogLevel = logLevel
+
+	var handlerOpts []shandler.HandlerOption
+	handlerOpts = append(handlerOpts, shandler.WithLogLevel(slog.LevelDebug))
 
 	switch Opts.LogTimeFormat {
 	case "DateOnly":
@@ -198,7 +509,7 @@ func main() {
 
 	handlerOpts = append(handlerOpts, shandler.WithShortLevels())
 
-	logger := slog.New(shandler.NewHandler(handlerOpts...))
+	logger := slog.New(newLevelHandler(shandler.NewHandler(handlerOpts...), logLevel))
 	keypair, err := nkeys.CreateServer()
 	if err != nil {
 		panic(err)
@@ -243,7 +554,7 @@ func main() {
 	handlerOpts = append(handlerOpts, shandler.WithStdOut(stdoutWriters...))
 	handlerOpts = append(handlerOpts, shandler.WithStdErr(stderrWriters...))
 
-	logger = slog.New(shandler.NewHandler(handlerOpts...))
+	logger = slog.New(newLevelHandler(shandler.NewHandler(handlerOpts...), logLevel))
 
 	switch cmd {
 	case tui.FullCommand():
@@ -256,6 +567,11 @@ func main() {
 		if err != nil {
 			logger.Error("Failed to list nodes", slog.Any("err", err))
 		}
+	case nodesMembers.FullCommand():
+		err := ListNexusMembers(ctx)
+		if err != nil {
+			logger.Error("Failed to list nexus members", slog.Any("err", err))
+		}
 	case nodesProbe.FullCommand():
 		err := ListWorkloads(ctx)
 		if err != nil {
@@ -265,6 +581,85 @@ func main() {
 		err := NodeInfo(ctx, *node_info_id_arg)
 		if err != nil {
 			logger.Error("Failed to get node info", slog.Any("err", err))
+			exitCode = 1
+		}
+	case nodesNexus.FullCommand():
+		err := NexusInfo(ctx)
+		if err != nil {
+			logger.Error("Failed to get nexus info", slog.Any("err", err))
+			exitCode = 1
+		}
+	case nodesAggregate.FullCommand():
+		err := RunNexusAggregator(ctx, logger)
+		if err != nil {
+			logger.Error("Failed to run nexus aggregator", slog.Any("err", err))
+			exitCode = 1
+		}
+	case operator.FullCommand():
+		err := RunOperator(ctx, logger)
+		if err != nil {
+			logger.Error("Failed to run operator", slog.Any("err", err))
+			exitCode = 1
+		}
+	case nodesCordon.FullCommand():
+		err := CordonNode(ctx, *node_cordon_id_arg)
+		if err != nil {
+			logger.Error("Failed to cordon node", slog.Any("err", err))
+		}
+	case nodesUncordon.FullCommand():
+		err := UncordonNode(ctx, *node_uncordon_id_arg)
+		if err != nil {
+			logger.Error("Failed to uncordon node", slog.Any("err", err))
+		}
+	case nodesReload.FullCommand():
+		err := ReloadNode(ctx, *node_reload_id_arg)
+		if err != nil {
+			logger.Error("Failed to reload node configuration", slog.Any("err", err))
+		}
+	case nodesTag.FullCommand():
+		err := TagNode(ctx, *node_tag_id_arg, *node_tag_args)
+		if err != nil {
+			logger.Error("Failed to tag node", slog.Any("err", err))
+		}
+	case nodesUntag.FullCommand():
+		err := UntagNode(ctx, *node_untag_id_arg, *node_untag_args)
+		if err != nil {
+			logger.Error("Failed to untag node", slog.Any("err", err))
+		}
+	case nodesUpdate.FullCommand():
+		err := UpdateNode(ctx, *node_update_id_arg)
+		if err != nil {
+			logger.Error("Failed to update node", slog.Any("err", err))
+		}
+	case nodesRootfs.FullCommand():
+		err := RolloutRootfs(ctx, *node_rootfs_id_arg)
+		if err != nil {
+			logger.Error("Failed to start rootfs rollout", slog.Any("err", err))
+		}
+	case nodesRootfsStatus.FullCommand():
+		err := RootfsRolloutStatus(ctx, *node_rootfs_status_id_arg)
+		if err != nil {
+			logger.Error("Failed to get rootfs rollout status", slog.Any("err", err))
+		}
+	case nodesRootfsAbort.FullCommand():
+		err := AbortRootfsRollout(ctx, *node_rootfs_abort_id_arg)
+		if err != nil {
+			logger.Error("Failed to abort rootfs rollout", slog.Any("err", err))
+		}
+	case nodesEvacuate.FullCommand():
+		err := EvacuateNode(ctx, *node_evacuate_id_arg)
+		if err != nil {
+			logger.Error("Failed to evacuate node", slog.Any("err", err))
+		}
+	case nodesRotateKeys.FullCommand():
+		err := RotateNodeKeys(ctx, *node_rotate_keys_id_arg)
+		if err != nil {
+			logger.Error("Failed to rotate node keys", slog.Any("err", err))
+		}
+	case nodesDiagnostics.FullCommand():
+		err := DiagnoseNode(ctx, *node_diagnostics_id_arg)
+		if err != nil {
+			logger.Error("Failed to diagnose node", slog.Any("err", err))
 		}
 	case run.FullCommand():
 		err := RunWorkload(ctx, logger)
@@ -280,6 +675,7 @@ func main() {
 		err := StopWorkload(ctx, logger)
 		if err != nil {
 			logger.Error("failed to stop workload", slog.Any("err", err))
+			exitCode = 1
 		}
 	case logs.FullCommand():
 		err := WatchLogs(ctx, logger)
@@ -291,15 +687,181 @@ func main() {
 		if err != nil {
 			logger.Error("failed to start event watcher", slog.Any("err", err))
 		}
+	case audit.FullCommand():
+		err := WatchAudit(ctx, logger)
+		if err != nil {
+			logger.Error("failed to start audit watcher", slog.Any("err", err))
+		}
 	case nodeUp.FullCommand():
 		err := RunNodeUp(ctx, logger, keypair)
 		if err != nil {
 			logger.Error("failed to start node", slog.Any("err", err))
 		}
+	case devbox.FullCommand():
+		err := RunDevbox(ctx, logger, keypair)
+		if err != nil {
+			logger.Error("failed to run devbox", slog.Any("err", err))
+			exitCode = 1
+		}
+	case history.FullCommand():
+		err := ExecutionHistory(ctx, *history_node_arg, *history_workload_arg, *history_limit)
+		if err != nil {
+			logger.Error("failed to retrieve execution history", slog.Any("err", err))
+		}
+	case job.FullCommand():
+		err := JobStatus(ctx, *job_node_arg, *job_workload_arg)
+		if err != nil {
+			logger.Error("failed to retrieve job status", slog.Any("err", err))
+			exitCode = 1
+		}
+	case pause.FullCommand():
+		err := PauseWorkload(ctx, *pause_node_arg, *pause_workload_arg, *pause_machine, *pause_issuer)
+		if err != nil {
+			logger.Error("failed to pause workload", slog.Any("err", err))
+			exitCode = 1
+		}
+	case resume.FullCommand():
+		err := ResumeWorkload(ctx, *resume_node_arg, *resume_workload_arg, *resume_issuer)
+		if err != nil {
+			logger.Error("failed to resume workload", slog.Any("err", err))
+			exitCode = 1
+		}
+	case rolloutStart.FullCommand():
+		err := RolloutWorkload(ctx, logger)
+		if err != nil {
+			logger.Error("failed to roll out workload", slog.Any("err", err))
+			exitCode = 1
+		}
+	case rolloutStatus.FullCommand():
+		err := ControlRollout(ctx, logger, "status", *rollout_status_id_arg)
+		if err != nil {
+			logger.Error("failed to get rollout status", slog.Any("err", err))
+			exitCode = 1
+		}
+	case rolloutPause.FullCommand():
+		err := ControlRollout(ctx, logger, "pause", *rollout_pause_id_arg)
+		if err != nil {
+			logger.Error("failed to pause rollout", slog.Any("err", err))
+			exitCode = 1
+		}
+	case rolloutResume.FullCommand():
+		err := ControlRollout(ctx, logger, "resume", *rollout_resume_id_arg)
+		if err != nil {
+			logger.Error("failed to resume rollout", slog.Any("err", err))
+			exitCode = 1
+		}
+	case rolloutAbort.FullCommand():
+		err := ControlRollout(ctx, logger, "abort", *rollout_abort_id_arg)
+		if err != nil {
+			logger.Error("failed to abort rollout", slog.Any("err", err))
+			exitCode = 1
+		}
+	case namespacesCreate.FullCommand():
+		ns := &controlapi.Namespace{
+			Name:               Opts.Namespace,
+			Description:        *namespaces_description,
+			Metadata:           *namespaces_metadata,
+			DefaultEnvironment: *namespaces_default_env,
+			DeletionPolicy:     controlapi.NamespaceDeletionPolicy(*namespaces_deletion_policy),
+		}
+		if *namespaces_max_workloads > 0 || *namespaces_max_memory > 0 {
+			ns.Quota = &controlapi.NamespaceQuota{MaxWorkloads: *namespaces_max_workloads, MaxMemoryMib: *namespaces_max_memory}
+		}
+		if *namespaces_default_memory > 0 {
+			ns.DefaultResources = &controlapi.WorkloadResources{MemoryMib: *namespaces_default_memory}
+		}
+		if *namespaces_default_config != "" {
+			raw, err := os.ReadFile(*namespaces_default_config)
+			if err != nil {
+				logger.Error("failed to read namespace default config", slog.Any("err", err))
+				exitCode = 1
+				break
+			}
+			ns.DefaultConfig = raw
+		}
+
+		err := CreateNamespace(ctx, ns)
+		if err != nil {
+			logger.Error("failed to create namespace", slog.Any("err", err))
+			exitCode = 1
+		}
+	case namespacesInfo.FullCommand():
+		err := NamespaceInfo(ctx)
+		if err != nil {
+			logger.Error("failed to get namespace", slog.Any("err", err))
+			exitCode = 1
+		}
+	case namespacesLs.FullCommand():
+		err := ListNamespaces(ctx)
+		if err != nil {
+			logger.Error("failed to list namespaces", slog.Any("err", err))
+			exitCode = 1
+		}
+	case namespacesRm.FullCommand():
+		err := DeleteNamespace(ctx, controlapi.NamespaceDeletionPolicy(*namespaces_rm_policy), *namespaces_rm_identity)
+		if err != nil {
+			logger.Error("failed to delete namespace", slog.Any("err", err))
+			exitCode = 1
+		}
+	case quarantineLs.FullCommand():
+		err := Quarantine(ctx, controlapi.QuarantineActionList, *quarantine_ls_node_arg, "", "")
+		if err != nil {
+			logger.Error("failed to list quarantined workloads", slog.Any("err", err))
+			exitCode = 1
+		}
+	case usage.FullCommand():
+		err := Usage(ctx, *usage_node_arg)
+		if err != nil {
+			logger.Error("failed to get namespace usage", slog.Any("err", err))
+			exitCode = 1
+		}
+	case volumesLs.FullCommand():
+		err := Volumes(ctx, controlapi.VolumeActionList, *volumes_ls_node_arg, "", "")
+		if err != nil {
+			logger.Error("failed to list volumes", slog.Any("err", err))
+			exitCode = 1
+		}
+	case volumesRm.FullCommand():
+		err := Volumes(ctx, controlapi.VolumeActionDelete, *volumes_rm_node_arg, *volumes_rm_name_arg, *volumes_rm_issuer)
+		if err != nil {
+			logger.Error("failed to delete volume", slog.Any("err", err))
+			exitCode = 1
+		}
+	case checkpointCreate.FullCommand():
+		err := CheckpointWorkload(ctx, *checkpoint_create_node_arg, *checkpoint_create_workload_arg, *checkpoint_create_stop, *checkpoint_create_issuer)
+		if err != nil {
+			logger.Error("failed to checkpoint workload", slog.Any("err", err))
+			exitCode = 1
+		}
+	case checkpointLs.FullCommand():
+		err := ListCheckpoints(ctx, *checkpoint_ls_node_arg, *checkpoint_ls_workload)
+		if err != nil {
+			logger.Error("failed to list checkpoints", slog.Any("err", err))
+			exitCode = 1
+		}
+	case checkpointRestore.FullCommand():
+		err := RestoreWorkload(ctx, *checkpoint_restore_node_arg, *checkpoint_restore_id_arg, *checkpoint_restore_issuer)
+		if err != nil {
+			logger.Error("failed to restore workload", slog.Any("err", err))
+			exitCode = 1
+		}
+	case quarantineResume.FullCommand():
+		err := Quarantine(ctx, controlapi.QuarantineActionResume, *quarantine_resume_node_arg, *quarantine_resume_workload_arg, *quarantine_resume_issuer)
+		if err != nil {
+			logger.Error("failed to resume quarantined workload", slog.Any("err", err))
+			exitCode = 1
+		}
+	case quarantineStop.FullCommand():
+		err := Quarantine(ctx, controlapi.QuarantineActionStop, *quarantine_stop_node_arg, *quarantine_stop_workload_arg, *quarantine_stop_issuer)
+		if err != nil {
+			logger.Error("failed to stop quarantined workload", slog.Any("err", err))
+			exitCode = 1
+		}
 	case lame.FullCommand():
 		err := LameDuck(ctx, logger)
 		if err != nil {
 			logger.Error("failed to command node to enter lame duck mode", slog.Any("err", err))
+			exitCode = 1
 		}
 	case nodePreflight.FullCommand():
 		err := RunNodePreflight(ctx, logger)
@@ -311,6 +873,32 @@ func main() {
 		if err != nil {
 			logger.Error("failed to build rootfs", slog.Any("err", err))
 		}
+	case contextSet.FullCommand():
+		err := SetCliContext(*context_set_name_arg, *context_set_description)
+		if err != nil {
+			logger.Error("Failed to save context", slog.Any("err", err))
+			exitCode = 1
+		}
+	case contextUse.FullCommand():
+		err := UseCliContext(*context_use_name_arg)
+		if err != nil {
+			logger.Error("Failed to select context", slog.Any("err", err))
+			exitCode = 1
+		}
+	case contextList.FullCommand():
+		err := ListCliContexts()
+		if err != nil {
+			logger.Error("Failed to list contexts", slog.Any("err", err))
+			exitCode = 1
+		}
+	case contextRm.FullCommand():
+		err := RemoveCliContext(*context_rm_name_arg)
+		if err != nil {
+			logger.Error("Failed to remove context", slog.Any("err", err))
+			exitCode = 1
+		}
+	case completion.FullCommand():
+		CompletionScript(*completion_shell_arg)
 	case upgrade.FullCommand():
 		if updatable != "" {
 			_, err := UpgradeNex(ctx, logger, updatable)
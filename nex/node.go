@@ -16,6 +16,8 @@ func setConditionalCommands() {
 	nodeUp.Flag("metrics", "enable open telemetry metrics endpoint").Default("false").UnNegatableBoolVar(&NodeOpts.OtelMetrics)
 	nodeUp.Flag("metrics_port", "enable open telemetry metrics endpoint").Default("8085").IntVar(&NodeOpts.OtelMetricsPort)
 	nodeUp.Flag("otel_metrics_exporter", "OTel exporter for metrics").Default("file").EnumVar(&NodeOpts.OtelMetricsExporter, "file", "prometheus")
+	nodeUp.Flag("logs", "enable open telemetry logs for workloads").Default("false").UnNegatableBoolVar(&NodeOpts.OtelLogs)
+	nodeUp.Flag("otel_logs_exporter", "OTel exporter for workload logs").Default("file").EnumVar(&NodeOpts.OtelLogsExporter, "file", "http")
 	nodeUp.Flag("traces", "enable open telemetry traces").Default("false").UnNegatableBoolVar(&NodeOpts.OtelTraces)
 	nodeUp.Flag("otel_traces_exporter", "OTel exporter for traces").Default("file").EnumVar(&NodeOpts.OtelTracesExporter, "file", "grpc", "http")
 	nodeUp.Flag("nexus", "Name for cluster of nex nodes").Default("nexus").StringVar(&NodeOpts.NexusName)
@@ -24,6 +26,10 @@ func setConditionalCommands() {
 	nodePreflight.Flag("force", "installs missing dependencies without prompt").Default("false").BoolVar(&NodeOpts.ForceDepInstall)
 	nodePreflight.Flag("config", "configuration file for the node").Default("./config.json").StringVar(&NodeOpts.ConfigFilepath)
 	nodePreflight.Flag("init", "creates the configuration file if it does not exist").EnumVar(&NodeOpts.PreflightInit, "sandbox", "nosandbox")
+	nodePreflight.Flag("json", "runs the checks without installing anything and prints a JSON report").Default("false").UnNegatableBoolVar(&NodeOpts.PreflightJSON)
+
+	devbox = ncli.Command("dev", "Run a local development environment: an embedded NATS server, a no sandbox node, and a redeploy of the workload on every change")
+	addDevboxFlags(devbox)
 }
 
 func RunNodeUp(ctx context.Context, logger *slog.Logger, keypair nkeys.KeyPair) error {
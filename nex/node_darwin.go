@@ -12,6 +12,7 @@ import (
 func setConditionalCommands() {
 	nodeUp = nodes.Command("up", "Starts a Nex node").Hidden()
 	nodePreflight = nodes.Command("preflight", "Checks system for node requirements and installs missing").Hidden()
+	devbox = ncli.Command("dev", "Run a local development environment").Hidden()
 }
 
 func RunNodeUp(ctx context.Context, logger *slog.Logger, keypair nkeys.KeyPair) error {
@@ -21,3 +22,7 @@ func RunNodeUp(ctx context.Context, logger *slog.Logger, keypair nkeys.KeyPair)
 func RunNodePreflight(ctx context.Context, logger *slog.Logger) error {
 	return nil
 }
+
+func RunDevbox(ctx context.Context, logger *slog.Logger, keypair nkeys.KeyPair) error {
+	return nil
+}
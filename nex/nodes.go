@@ -1,21 +1,29 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/nats-io/natscli/columns"
+	"github.com/nats-io/nkeys"
 	controlapi "github.com/synadia-io/nex/control-api"
 	"github.com/synadia-io/nex/internal/models"
 )
 
-// Uses a control API client to ping all nodes in NATS environment
+// Uses a control API client to discover all nodes in NATS environment, listing them once
+// no further node has responded within the quiet period
 func PingNodes(ctx context.Context) error {
 	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
 	nc, err := models.GenerateConnectionFromOpts(Opts, log)
@@ -24,11 +32,28 @@ func PingNodes(ctx context.Context) error {
 	}
 
 	nodeClient := controlapi.NewApiClient(nc, Opts.Timeout, log)
-	nodes, err := nodeClient.PingNodes()
+	discovered, err := nodeClient.DiscoverNodes(ctx, &controlapi.DiscoverRequest{
+		AuctionRequest: controlapi.AuctionRequest{Sites: NodeOpts.ListSites},
+	}, controlapi.WithQuietPeriod(NodeOpts.ListQuiet))
 	if err != nil {
 		return err
 	}
-	renderNodeList(nodes, NodeOpts.ListFull)
+
+	nodes := make([]controlapi.PingResponse, 0)
+	for node := range discovered {
+		// older nodes ignore the site filter
+		if len(NodeOpts.ListSites) > 0 && !controlapi.InSites(node.Site(), NodeOpts.ListSites) {
+			continue
+		}
+		if inTargetNexus(node) {
+			nodes = append(nodes, node)
+		}
+	}
+
+	if structuredOutput() {
+		return renderStructured(nodes)
+	}
+	renderNodeList(nodes, NodeOpts.ListFull || wideOutput())
 
 	return nil
 
@@ -41,11 +66,18 @@ func ListWorkloads(ctx context.Context) error {
 		return err
 	}
 	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log)
-	nodes, err := nodeClient.PingWorkloads(strings.TrimSpace(RunOpts.Name))
+	nodes, err := nodeClient.PingWorkloadsOwnedBy(ctx, strings.TrimSpace(RunOpts.Name), NodeOpts.Issuer)
 	if err != nil {
 		return err
 	}
-	renderWorkloadPingList(nodes)
+
+	if structuredOutput() {
+		if nodes == nil {
+			nodes = []controlapi.WorkloadPingResponse{}
+		}
+		return renderStructured(nodes)
+	}
+	renderWorkloadPingList(nodes, wideOutput())
 
 	return nil
 }
@@ -58,7 +90,31 @@ func LameDuck(ctx context.Context, logger *slog.Logger) error {
 		return err
 	}
 	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log)
-	_, err = nodeClient.EnterLameDuck(nodeId)
+
+	if FanOutOpts.Enabled() {
+		if nodeId != "" {
+			return errors.New("a node id cannot be combined with --all-nodes or --selector")
+		}
+
+		nodeIds, err := discoverFanOutNodes(ctx, nodeClient)
+		if err != nil {
+			return err
+		}
+
+		return reportNodeResults(fanOut(ctx, nodeIds, func(ctx context.Context, nodeId string) (string, error) {
+			_, err := nodeClient.EnterLameDuck(ctx, nodeId)
+			if err != nil {
+				return "", err
+			}
+			return "entering lame duck mode", nil
+		}))
+	}
+
+	if nodeId == "" {
+		return errors.New("a node id, --all-nodes or --selector is required")
+	}
+
+	_, err = nodeClient.EnterLameDuck(ctx, nodeId)
 	if err != nil {
 		fmt.Printf("Failed to issue lame duck command: %s\n", err)
 		return nil
@@ -68,6 +124,412 @@ func LameDuck(ctx context.Context, logger *slog.Logger) error {
 	return nil
 }
 
+// Uses a control API client to cordon a single node, optionally for a fixed maintenance window
+func CordonNode(ctx context.Context, nodeid string) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	request := &controlapi.CordonRequest{Reason: NodeOpts.CordonReason}
+	if NodeOpts.CordonFor > 0 {
+		until := time.Now().UTC().Add(NodeOpts.CordonFor)
+		request.Until = &until
+	}
+
+	nodeClient := controlapi.NewApiClient(nc, Opts.Timeout, log)
+	resp, err := nodeClient.CordonNode(ctx, nodeid, request)
+	if err != nil {
+		return err
+	}
+
+	if resp.Cordon != nil && resp.Cordon.Until != nil {
+		fmt.Printf("Node %s cordoned until %s\n", nodeid, resp.Cordon.Until.Local().Format(time.RFC1123))
+	} else {
+		fmt.Printf("Node %s cordoned\n", nodeid)
+	}
+
+	return nil
+}
+
+// Uses a control API client to lift the cordon from a single node
+func UncordonNode(ctx context.Context, nodeid string) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClient(nc, Opts.Timeout, log)
+	_, err = nodeClient.UncordonNode(ctx, nodeid)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Node %s uncordoned\n", nodeid)
+
+	return nil
+}
+
+// Uses a control API client to have a single node reload its configuration file
+func ReloadNode(ctx context.Context, nodeid string) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClient(nc, Opts.Timeout, log)
+	resp, err := nodeClient.ReloadNodeConfig(ctx, nodeid)
+	if err != nil {
+		return err
+	}
+
+	if len(resp.Changes) == 0 {
+		fmt.Printf("Node %s reloaded; no changes applied\n", nodeid)
+	} else {
+		fmt.Printf("Node %s reloaded; applied changes to: ", nodeid)
+		fields := make([]string, len(resp.Changes))
+		for i, change := range resp.Changes {
+			fields[i] = change.Field
+		}
+		fmt.Println(strings.Join(fields, ", "))
+	}
+
+	if len(resp.RestartRequired) > 0 {
+		fmt.Printf("Restart required to apply: %s\n", strings.Join(resp.RestartRequired, ", "))
+	}
+
+	return nil
+}
+
+// Asks a node to update itself to a signed binary. The signature is either given or made here
+// from a local copy of the binary and a signing key seed
+func UpdateNode(ctx context.Context, nodeid string) error {
+	request := &controlapi.NodeUpdateRequest{
+		Location:  *node_update_location,
+		Signature: *node_update_signature,
+		Version:   *node_update_version,
+	}
+
+	var signingKey nkeys.KeyPair
+	if *node_update_signing_key != "" {
+		kp, err := readSeedFile(*node_update_signing_key)
+		if err != nil {
+			return fmt.Errorf("invalid signing key: %s", err)
+		}
+		signingKey = kp
+	}
+
+	if *node_update_binary != "" {
+		if signingKey == nil {
+			return errors.New("signing a binary requires --signing_key")
+		}
+
+		f, err := os.Open(*node_update_binary)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		request.Sha256, request.Signature, err = controlapi.SignNodeBinary(signingKey, request.Version, f)
+		if err != nil {
+			return fmt.Errorf("failed to sign binary: %s", err)
+		}
+	}
+
+	if request.Signature == "" {
+		return errors.New("an update requires --signature, or --binary and --signing_key")
+	}
+
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	callOpts := []controlapi.CallOption{controlapi.WithRequestTimeout(*node_update_timeout)}
+
+	identity := signingKey
+	if *node_update_identity != "" {
+		identity, err = readSeedFile(*node_update_identity)
+		if err != nil {
+			return fmt.Errorf("invalid identity: %s", err)
+		}
+	}
+	if identity != nil {
+		callOpts = append(callOpts, controlapi.WithIdentity(identity))
+	}
+
+	// updates are authorized in the system namespace, which identity tokens are issued for
+	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, "system", log)
+	resp, err := nodeClient.UpdateNode(ctx, nodeid, request, callOpts...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Node %s staged binary %s signed by %s; restarting with %d workload(s) to redeploy\n", nodeid, resp.Sha256, resp.SignedBy, resp.Workloads)
+	return nil
+}
+
+func readSeedFile(path string) (nkeys.KeyPair, error) {
+	seed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return nkeys.FromSeed(bytes.TrimSpace(seed))
+}
+
+// Starts rolling a new rootfs image across a node
+func RolloutRootfs(ctx context.Context, nodeid string) error {
+	request := &controlapi.RootfsRolloutRequest{
+		Location:            *node_rootfs_location,
+		Sha256:              *node_rootfs_sha256,
+		Version:             *node_rootfs_version,
+		RecycleInterval:     *node_rootfs_interval,
+		RecycleNonEssential: *node_rootfs_recycle_all,
+	}
+
+	nodeClient, err := rootfsClient()
+	if err != nil {
+		return err
+	}
+
+	status, err := nodeClient.RolloutRootfs(ctx, nodeid, request, controlapi.WithRequestTimeout(*node_rootfs_timeout))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Node %s started rootfs rollout %s\n", nodeid, status.Id)
+	return nil
+}
+
+func RootfsRolloutStatus(ctx context.Context, nodeid string) error {
+	nodeClient, err := rootfsClient()
+	if err != nil {
+		return err
+	}
+
+	status, err := nodeClient.RootfsRolloutStatus(ctx, nodeid)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return renderStructured(status)
+	}
+	printRootfsRollout(status)
+	return nil
+}
+
+func AbortRootfsRollout(ctx context.Context, nodeid string) error {
+	nodeClient, err := rootfsClient()
+	if err != nil {
+		return err
+	}
+
+	status, err := nodeClient.AbortRootfsRollout(ctx, nodeid)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Node %s is aborting rootfs rollout %s\n", nodeid, status.Id)
+	return nil
+}
+
+func rootfsClient() (*controlapi.Client, error) {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return controlapi.NewApiClient(nc, Opts.Timeout, log), nil
+}
+
+func printRootfsRollout(status *controlapi.RootfsRolloutStatus) {
+	fmt.Printf("Rollout:         %s\n", status.Id)
+	fmt.Printf("State:           %s\n", status.State)
+	fmt.Printf("Image:           %s %s\n", status.Sha256, status.Version)
+	fmt.Printf("Agents recycled: %d\n", status.AgentsRecycled)
+	fmt.Printf("Workloads:       %d recycled, %d failed, %d retained of %d\n", status.Recycled, status.Failed, status.Retained, status.Total)
+	if status.LastError != "" {
+		fmt.Printf("Last error:      %s\n", status.LastError)
+	}
+}
+
+// Asks a node to move its workloads to its peers and then enter lame duck mode
+func EvacuateNode(ctx context.Context, nodeid string) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClient(nc, Opts.Timeout, log)
+	status, err := nodeClient.EvacuateNode(ctx, nodeid, &controlapi.EvacuateRequest{
+		Reason:   *node_evacuate_reason,
+		Deadline: *node_evacuate_deadline,
+	})
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return renderStructured(status)
+	}
+
+	fmt.Printf("Node:      %s\n", status.NodeId)
+	fmt.Printf("State:     %s\n", status.State)
+	fmt.Printf("Reason:    %s\n", status.Reason)
+	fmt.Printf("Deadline:  %s\n", status.Deadline.Local().Format(time.RFC1123))
+	fmt.Printf("Workloads: %d evacuated, %d failed of %d\n", status.Evacuated, status.Failed, status.Total)
+	for _, workload := range status.Workloads {
+		if workload.Error != "" {
+			fmt.Printf("  %s (%s): %s\n", workload.Name, workload.WorkloadId, workload.Error)
+		} else {
+			fmt.Printf("  %s (%s) -> %s on %s\n", workload.Name, workload.WorkloadId, workload.ReplacementId, workload.TargetNode)
+		}
+	}
+	return nil
+}
+
+// Replaces a node's xkey, reporting the previous xkey and how long it is still accepted
+func RotateNodeKeys(ctx context.Context, nodeid string) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClient(nc, Opts.Timeout, log)
+	resp, err := nodeClient.RotateNodeKeys(ctx, nodeid, &controlapi.RotateKeysRequest{
+		Grace: *node_rotate_keys_grace,
+	})
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return renderStructured(resp)
+	}
+
+	fmt.Printf("Node:           %s\n", resp.NodeId)
+	fmt.Printf("XKey:           %s\n", resp.PublicXKey)
+	fmt.Printf("Previous XKey:  %s\n", resp.PreviousPublicXKey)
+	fmt.Printf("Accepted Until: %s\n", resp.GraceUntil.Local().Format(time.RFC1123))
+	fmt.Printf("Persisted:      %t\n", resp.Persisted)
+	return nil
+}
+
+// Shows a snapshot of a node's internal state, saving any requested profiles and goroutine dump
+// as files named after the node
+func DiagnoseNode(ctx context.Context, nodeid string) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClient(nc, Opts.Timeout, log)
+	resp, err := nodeClient.Diagnostics(ctx, nodeid, &controlapi.DiagnosticsRequest{
+		Profiles:   *node_diagnostics_profiles,
+		Goroutines: *node_diagnostics_goroutines,
+	})
+	if err != nil {
+		return err
+	}
+
+	for name, profile := range resp.Profiles {
+		err = os.WriteFile(filepath.Join(*node_diagnostics_output, fmt.Sprintf("%s.%s.pprof", resp.NodeId, name)), profile, 0600)
+		if err != nil {
+			return err
+		}
+	}
+	if resp.GoroutineDump != "" {
+		err = os.WriteFile(filepath.Join(*node_diagnostics_output, fmt.Sprintf("%s.goroutines.txt", resp.NodeId)), []byte(resp.GoroutineDump), 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	if structuredOutput() {
+		resp.Profiles = nil
+		resp.GoroutineDump = ""
+		return renderStructured(resp)
+	}
+
+	state := resp.State
+	fmt.Printf("Node:                  %s\n", resp.NodeId)
+	fmt.Printf("Taken:                 %s\n", resp.TakenAt.Local().Format(time.RFC1123))
+	fmt.Printf("Goroutines:            %d\n", state.Goroutines)
+	fmt.Printf("Heap:                  %d bytes in %d objects\n", state.HeapAllocBytes, state.HeapObjects)
+	fmt.Printf("GC Cycles:             %d\n", state.GCCycles)
+	fmt.Printf("NATS Subscriptions:    %d\n", state.NATSSubscriptions)
+	fmt.Printf("Trigger Subscriptions: %d\n", state.TriggerSubscriptions)
+	for pool, count := range state.PendingAgents {
+		fmt.Printf("Pending %-14s %d\n", string(pool)+":", count)
+	}
+
+	if len(state.Workloads) > 0 {
+		tbl := newTableWriter("Workloads")
+		tbl.AddHeaders("ID", "Name", "Namespace", "Type", "State", "Claimed", "Subscriptions")
+		for _, workload := range state.Workloads {
+			tbl.AddRow(workload.Id, workload.Name, workload.Namespace, workload.WorkloadType, workload.State, workload.Claimed, workload.TriggerSubscriptions)
+		}
+		fmt.Println(tbl.Render())
+	}
+
+	return nil
+}
+
+// Sets tags, given as name=value pairs, on a running node
+func TagNode(ctx context.Context, nodeid string, pairs []string) error {
+	set := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid tag %q; expected name=value", pair)
+		}
+		set[name] = value
+	}
+
+	return updateNodeTags(ctx, nodeid, &controlapi.NodeTagsRequest{Set: set})
+}
+
+// Removes the named tags from a running node
+func UntagNode(ctx context.Context, nodeid string, names []string) error {
+	return updateNodeTags(ctx, nodeid, &controlapi.NodeTagsRequest{Remove: names})
+}
+
+func updateNodeTags(ctx context.Context, nodeid string, request *controlapi.NodeTagsRequest) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClient(nc, Opts.Timeout, log)
+	resp, err := nodeClient.UpdateNodeTags(ctx, nodeid, request)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(resp.Tags))
+	for name := range resp.Tags {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	fmt.Printf("Node %s tags:\n", nodeid)
+	for _, name := range names {
+		fmt.Printf("  %s=%s\n", name, resp.Tags[name])
+	}
+
+	return nil
+}
+
 // Uses a control API client to retrieve info on a single node
 func NodeInfo(ctx context.Context, nodeid string) error {
 	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
@@ -76,10 +538,67 @@ func NodeInfo(ctx context.Context, nodeid string) error {
 		return err
 	}
 	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log)
-	nodeInfo, err := nodeClient.NodeInfo(nodeid)
+
+	if FanOutOpts.Enabled() {
+		if nodeid != "" {
+			return errors.New("a node id cannot be combined with --all-nodes or --selector")
+		}
+
+		nodeIds, err := discoverFanOutNodes(ctx, nodeClient)
+		if err != nil {
+			return err
+		}
+
+		infos := make(map[string]*controlapi.InfoResponse)
+		var mutex sync.Mutex
+		results := fanOut(ctx, nodeIds, func(ctx context.Context, nodeId string) (string, error) {
+			info, err := nodeClient.NodeInfoOwnedBy(ctx, nodeId, NodeOpts.Issuer)
+			if err != nil {
+				return "", err
+			}
+
+			mutex.Lock()
+			infos[nodeId] = info
+			mutex.Unlock()
+			return fmt.Sprintf("%d machine(s) running", len(info.Machines)), nil
+		})
+
+		if structuredOutput() {
+			// failures go to stderr so that stdout stays parseable
+			for _, result := range results {
+				if result.Err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %s\n", result.NodeId, result.Err)
+				}
+			}
+
+			err := renderStructured(infos)
+			if err != nil {
+				return err
+			}
+			return nodeResultsError(results)
+		}
+
+		for _, nodeId := range nodeIds {
+			if info, ok := infos[nodeId]; ok {
+				renderNodeInfo(info, nodeId)
+			}
+		}
+
+		return reportNodeResults(results)
+	}
+
+	if nodeid == "" {
+		return errors.New("a node id, --all-nodes or --selector is required")
+	}
+
+	nodeInfo, err := nodeClient.NodeInfoOwnedBy(ctx, nodeid, NodeOpts.Issuer)
 	if err != nil {
 		return err
 	}
+
+	if structuredOutput() {
+		return renderStructured(nodeInfo)
+	}
 	renderNodeInfo(nodeInfo, nodeid)
 
 	return nil
@@ -115,6 +634,76 @@ func renderNodeInfo(info *controlapi.InfoResponse, id string) {
 		cols.Indent(0)
 	}
 
+	if info.Cordon != nil {
+		cols.AddSectionTitle("Cordon")
+		cols.Indent(2)
+
+		cols.Println()
+		cols.AddRow("Reason", info.Cordon.Reason)
+		cols.AddRow("Since", info.Cordon.Since.Local().Format(time.RFC1123))
+		if info.Cordon.Until != nil {
+			cols.AddRow("Until", info.Cordon.Until.Local().Format(time.RFC1123))
+		}
+
+		cols.Indent(0)
+	}
+
+	if info.Pressure != nil {
+		cols.AddSectionTitle("Pressure")
+		cols.Indent(2)
+
+		cols.Println()
+		cols.AddRow("Reasons", strings.Join(info.Pressure.Reasons, ", "))
+		cols.AddRow("Since", info.Pressure.Since.Local().Format(time.RFC1123))
+		cols.AddRowf("CPU Stall (avg10)", "%.2f%%", info.Pressure.Sample.CpuSomeAvg10)
+		cols.AddRowf("Memory Stall (avg10)", "%.2f%%", info.Pressure.Sample.MemorySomeAvg10)
+		cols.AddRowf("Memory Available", "%.1f%%", info.Pressure.Sample.MemoryAvailablePercent)
+		if len(info.Pressure.PausedWorkloads) > 0 {
+			cols.AddRow("Paused Workloads", strings.Join(info.Pressure.PausedWorkloads, ", "))
+		}
+
+		cols.Indent(0)
+	}
+
+	if info.Capacity != nil {
+		cols.AddSectionTitle("Capacity")
+		cols.Indent(2)
+
+		cols.Println()
+		cols.AddRowf("Memory (MiB)", "%d of %d available", info.Capacity.AvailableMemoryMib, info.Capacity.MemoryMib)
+		cols.AddRowf("vCPUs", "%d of %d available", info.Capacity.AvailableVcpuCount, info.Capacity.VcpuCount)
+		cols.AddRow("Overcommit Ratio", info.Capacity.OvercommitRatio)
+		if info.Capacity.GPUCount > 0 {
+			cols.AddRowf("GPUs", "%d of %d available", info.Capacity.AvailableGPUCount, info.Capacity.GPUCount)
+		}
+
+		cols.Indent(0)
+	}
+
+	if len(info.Agents) > 0 {
+		cols.AddSectionTitle("Agents")
+		cols.Indent(2)
+		for _, a := range info.Agents {
+			cols.Println()
+			cols.AddRow("Id", a.Id)
+			cols.AddRow("Version", a.Version)
+			cols.AddRow("Protocol Version", a.ProtocolVersion)
+			cols.AddRow("Compatible", a.Compatible)
+			cols.AddRow("Deployed", a.Deployed)
+			if a.Capabilities != nil {
+				providers := make([]string, 0, len(a.Capabilities.Providers))
+				for _, p := range a.Capabilities.Providers {
+					providers = append(providers, string(p.WorkloadType))
+				}
+				cols.AddRow("Providers", strings.Join(providers, ", "))
+				if a.Capabilities.MemoryAvailableBytes > 0 {
+					cols.AddRowf("Memory (MiB)", "%d of %d available", a.Capabilities.MemoryAvailableBytes/1024/1024, a.Capabilities.MemoryTotalBytes/1024/1024)
+				}
+			}
+		}
+		cols.Indent(0)
+	}
+
 	if len(info.Machines) > 0 {
 		cols.AddSectionTitle("Workloads")
 		cols.Indent(2)
@@ -125,11 +714,168 @@ func renderNodeInfo(info *controlapi.InfoResponse, id string) {
 			cols.AddRow("Runtime", m.Workload.Runtime)
 			cols.AddRow("Name", m.Workload.Name)
 			cols.AddRow("Description", m.Workload.Description)
+			if m.Issuer != "" {
+				cols.AddRow("Owner", m.Issuer)
+			}
+			if m.Group != "" {
+				cols.AddRow("Group", m.Group)
+			}
+			if m.Paused {
+				cols.AddRow("Paused", m.Paused)
+			}
+			if m.Resources != nil {
+				cols.AddRow("CPU Time", (time.Duration(m.Resources.CPUTimeMillis) * time.Millisecond).String())
+				cols.AddRow("Memory (RSS)", fmt.Sprintf("%d bytes", m.Resources.MemoryRSSBytes))
+				if m.Resources.BlockReadBytes > 0 || m.Resources.BlockWriteBytes > 0 {
+					cols.AddRow("Block I/O", fmt.Sprintf("%d read / %d written bytes", m.Resources.BlockReadBytes, m.Resources.BlockWriteBytes))
+				}
+				if m.Resources.NetRxBytes > 0 || m.Resources.NetTxBytes > 0 {
+					cols.AddRow("Network I/O", fmt.Sprintf("%d received / %d sent bytes", m.Resources.NetRxBytes, m.Resources.NetTxBytes))
+				}
+			}
+			for _, b := range m.TriggerBreakers {
+				if b.State == controlapi.BreakerStateClosed {
+					continue
+				}
+				cols.AddRow(fmt.Sprintf("Breaker %s", b.Subject), fmt.Sprintf("%s after %d failures; %d rejected; retry at %s", b.State, b.ConsecutiveFailures, b.Rejected, b.RetryAt.Local().Format(time.Stamp)))
+			}
 		}
 		cols.Indent(0)
 	}
 }
 
+// Shows the merged view of the nexus gathered by whichever aggregator answers
+func NexusInfo(ctx context.Context) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log)
+	info, err := nodeClient.NexusInfo(ctx, controlapi.WithRequestTimeout(*nexus_info_timeout))
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return renderStructured(info)
+	}
+	renderNexusInfo(info)
+	return nil
+}
+
+// Lists the nodes registered in the nexus registry, limited to the target nexus if one is set
+func ListNexusMembers(ctx context.Context) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClient(nc, Opts.Timeout, log)
+	members, err := nodeClient.ListNexusNodes(ctx, *nexus_members_bucket)
+	if err != nil {
+		return err
+	}
+
+	filtered := make([]controlapi.NexusMember, 0, len(members))
+	for _, member := range members {
+		if Opts.Nexus == "" || member.Nexus == Opts.Nexus {
+			filtered = append(filtered, member)
+		}
+	}
+
+	if structuredOutput() {
+		return renderStructured(filtered)
+	}
+	renderNexusMembers(filtered)
+	return nil
+}
+
+func renderNexusMembers(members []controlapi.NexusMember) {
+	if len(members) == 0 {
+		fmt.Println("No nodes registered")
+		return
+	}
+
+	tbl := newTableWriter("Nexus Members")
+	tbl.AddHeaders("Nexus", "ID", "Name", "Version", "Workloads", "Joined", "Last Seen")
+
+	for _, member := range members {
+		nodeName, ok := member.Tags["node_name"]
+		if !ok {
+			nodeName = "no-name"
+		}
+
+		tbl.AddRow(member.Nexus, member.NodeId, nodeName, member.Version, member.RunningMachines,
+			member.JoinedAt.Local().Format(time.RFC1123), member.LastSeen.Local().Format(time.RFC1123))
+	}
+	fmt.Println(tbl.Render())
+}
+
+// Answers nexus info requests until interrupted
+func RunNexusAggregator(ctx context.Context, logger *slog.Logger) error {
+	nc, err := models.GenerateConnectionFromOpts(Opts, logger)
+	if err != nil {
+		return err
+	}
+
+	id := fmt.Sprintf("aggregator-%s", nc.ConnectedServerId())
+	sub, err := controlapi.ServeNexusInfo(nc, id, *aggregate_node_timeout, *aggregate_discovery_wait, logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = sub.Unsubscribe()
+	}()
+
+	logger.Info("Answering nexus info requests", slog.String("id", id))
+	<-ctx.Done()
+	return nil
+}
+
+func renderNexusInfo(info *controlapi.NexusInfoResponse) {
+	tbl := newTableWriter(fmt.Sprintf("Nexus (namespace %s)", info.Namespace))
+	tbl.AddHeaders("ID", "Name", "Version", "Workloads", "Memory (MiB)", "vCPUs", "Uptime")
+
+	for _, node := range info.Nodes {
+		nodeName, ok := node.Tags["node_name"]
+		if !ok {
+			nodeName = "no-name"
+		}
+
+		memory, vcpus := "-", "-"
+		if node.Capacity != nil {
+			memory = fmt.Sprintf("%d/%d", node.Capacity.AllocatedMemoryMib, node.Capacity.MemoryMib)
+			vcpus = fmt.Sprintf("%d/%d", node.Capacity.AllocatedVcpuCount, node.Capacity.VcpuCount)
+		}
+
+		tbl.AddRow(node.NodeId, nodeName, node.Version, len(node.Machines), memory, vcpus, node.Uptime)
+	}
+	fmt.Println(tbl.Render())
+
+	cols := newColumns("Summary")
+	defer render(cols)
+
+	cols.AddRow("Nodes", len(info.Nodes))
+	cols.AddRow("Workloads", info.TotalWorkloads)
+	cols.AddRow("Memory (MiB)", fmt.Sprintf("%d allocated of %d", info.Capacity.AllocatedMemoryMib, info.Capacity.MemoryMib))
+	cols.AddRow("vCPUs", fmt.Sprintf("%d allocated of %d", info.Capacity.AllocatedVcpuCount, info.Capacity.VcpuCount))
+
+	versions := make([]string, 0, len(info.Versions))
+	for version, count := range info.Versions {
+		versions = append(versions, fmt.Sprintf("%s (%d)", version, count))
+	}
+	slices.Sort(versions)
+	cols.AddRow("Versions", strings.Join(versions, ", "))
+	cols.AddRow("Aggregated By", info.AggregatedBy)
+
+	for nodeId, reason := range info.Errors {
+		cols.AddRow(fmt.Sprintf("Failed %s", nodeId), reason)
+	}
+}
+
 func renderNodeList(nodes []controlapi.PingResponse, listFull bool) {
 	if len(nodes) == 0 {
 		fmt.Println("No nodes discovered")
@@ -140,7 +886,7 @@ func renderNodeList(nodes []controlapi.PingResponse, listFull bool) {
 	if !listFull {
 		tbl.AddHeaders("ID (* = Lameduck Mode)", "Name", "Version", "Workloads")
 	} else {
-		tbl.AddHeaders("Nexus", "ID (* = Lameduck Mode)", "Name", "Version", "Workloads", "Uptime", "Sandboxed", "OS", "Arch")
+		tbl.AddHeaders("Nexus", "ID (* = Lameduck Mode)", "Name", "Version", "Workloads", "Uptime", "Sandboxed", "OS", "Arch", "Site", "RTT")
 	}
 
 	for _, node := range nodes {
@@ -188,7 +934,12 @@ func renderNodeList(nodes []controlapi.PingResponse, listFull bool) {
 				nodeNexus = ""
 			}
 
-			row = append(row, node.Uptime, !nUnsafe, nodeOS, nodeArch)
+			site := node.Site()
+			if site == "" {
+				site = "-"
+			}
+
+			row = append(row, node.Uptime, !nUnsafe, nodeOS, nodeArch, site, node.RoundTrip.Round(time.Millisecond))
 			row = append([]any{nodeNexus}, row...)
 		}
 
@@ -202,14 +953,18 @@ func renderNodeList(nodes []controlapi.PingResponse, listFull bool) {
 	fmt.Println(tbl.Render())
 }
 
-func renderWorkloadPingList(nodes []controlapi.WorkloadPingResponse) {
+func renderWorkloadPingList(nodes []controlapi.WorkloadPingResponse, wide bool) {
 	if len(nodes) == 0 {
 		fmt.Println("No workloads matched")
 		return
 	}
 
 	table := newTableWriter("Discovered Workloads")
-	table.AddHeaders("ID", "Name", "Type", "Namespace", "Node Name")
+	if !wide {
+		table.AddHeaders("ID", "Name", "Type", "Namespace", "Owner", "Node Name")
+	} else {
+		table.AddHeaders("ID", "Name", "Type", "Namespace", "Owner", "Node Name", "Node ID", "Node Version", "Node Uptime")
+	}
 
 	for _, node := range nodes {
 		nodeName, ok := node.Tags["node_name"]
@@ -217,7 +972,11 @@ func renderWorkloadPingList(nodes []controlapi.WorkloadPingResponse) {
 			nodeName = "no-name"
 		}
 		for _, work := range node.RunningMachines {
-			table.AddRow(work.Id, work.Name, work.WorkloadType, work.Namespace, nodeName)
+			row := []any{work.Id, work.Name, work.WorkloadType, work.Namespace, work.Issuer, nodeName}
+			if wide {
+				row = append(row, node.NodeId, node.Version, node.Uptime)
+			}
+			table.AddRow(row...)
 		}
 	}
 
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nats-io/nkeys"
+	"github.com/synadia-io/nex/internal/models"
+	nexoperator "github.com/synadia-io/nex/internal/operator"
+)
+
+// Reconciles the NexWorkload and NexNodePool resources of a Kubernetes cluster against the
+// nexus until interrupted
+func RunOperator(ctx context.Context, logger *slog.Logger) error {
+	nc, err := models.GenerateConnectionFromOpts(Opts, logger)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	issuerSeed, err := os.ReadFile(*operator_issuer)
+	if err != nil {
+		return err
+	}
+	issuerKp, err := nkeys.FromSeed(issuerSeed)
+	if err != nil {
+		return err
+	}
+	xkeyRaw, err := os.ReadFile(*operator_xkey)
+	if err != nil {
+		return err
+	}
+	xkey, err := nkeys.FromCurveSeed(xkeyRaw)
+	if err != nil {
+		return err
+	}
+
+	op, err := nexoperator.NewOperator(nc, nexoperator.Options{
+		Kube: nexoperator.KubeConfig{
+			Server:    *operator_kube_server,
+			TokenFile: *operator_kube_token,
+			CAFile:    *operator_kube_ca,
+			Insecure:  *operator_kube_insecure,
+		},
+		Issuer:         issuerKp,
+		Xkey:           xkey,
+		JsDomain:       Opts.JsDomain,
+		Timeout:        Opts.Timeout,
+		ResyncInterval: *operator_resync,
+	}, logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("Reconciling Kubernetes resources against the nexus", slog.Duration("resync", *operator_resync))
+	return op.Run(ctx)
+}
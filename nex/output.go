@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/choria-io/fisk"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats in which listing and info commands print their results. The structured formats print
+// the control API types themselves under their JSON field names, so that scripts can rely on them
+const (
+	outputTable = "table"
+	outputWide  = "wide"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+func addOutputFlag(cmd *fisk.CmdClause) {
+	cmd.Flag("output", "Output format: table, wide, or json or yaml using the control API's field names").
+		Default(outputTable).
+		EnumVar(&OutputOpts.Format, outputTable, outputWide, outputJSON, outputYAML)
+}
+
+// Whether results are printed as JSON or YAML rather than as tables
+func structuredOutput() bool {
+	return OutputOpts.Format == outputJSON || OutputOpts.Format == outputYAML
+}
+
+// Whether tables include their additional columns
+func wideOutput() bool {
+	return OutputOpts.Format == outputWide
+}
+
+// Prints the value as JSON or YAML, according to the output format
+func renderStructured(v any) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if OutputOpts.Format == outputJSON {
+		fmt.Println(string(raw))
+		return nil
+	}
+
+	// converting through JSON keeps the field names and omissions of the control API types
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var generic any
+	err = decoder.Decode(&generic)
+	if err != nil {
+		return err
+	}
+
+	out, err := yaml.Marshal(yamlNumbers(generic))
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(out))
+	return nil
+}
+
+// Replaces the JSON numbers in a decoded value with integers or floats, which YAML would
+// otherwise quote as strings
+func yamlNumbers(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, item := range value {
+			value[k] = yamlNumbers(item)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = yamlNumbers(item)
+		}
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	}
+
+	return v
+}
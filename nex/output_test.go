@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestYamlNumbersKeepsIntegersAndFloats(t *testing.T) {
+	v := yamlNumbers(map[string]any{
+		"uptime":  json.Number("42"),
+		"load":    json.Number("0.5"),
+		"entries": []any{json.Number("7")},
+	}).(map[string]any)
+
+	if v["uptime"] != int64(42) {
+		t.Fatalf("Expected an integer, got %#v", v["uptime"])
+	}
+	if v["load"] != 0.5 {
+		t.Fatalf("Expected a float, got %#v", v["load"])
+	}
+	if v["entries"].([]any)[0] != int64(7) {
+		t.Fatalf("Expected nested integers to be converted, got %#v", v["entries"])
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+// Replaces the workload named by --name on every node running it, and on any node given with
+// --node, with the workload described by the run flags. The rollout is coordinated by this
+// process, which answers `nex rollout status|pause|resume|abort` until it finishes
+func RolloutWorkload(ctx context.Context, logger *slog.Logger) error {
+	nc, err := models.GenerateConnectionFromOpts(Opts, logger)
+	if err != nil {
+		return err
+	}
+	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, logger)
+
+	issuerSeed, err := os.ReadFile(RunOpts.ClaimsIssuerFile)
+	if err != nil {
+		return err
+	}
+	issuerKp, err := nkeys.FromSeed(issuerSeed)
+	if err != nil {
+		return err
+	}
+	xkeyRaw, err := os.ReadFile(RunOpts.PublisherXkeyFile)
+	if err != nil {
+		return err
+	}
+	xkey, err := nkeys.FromCurveSeed(xkeyRaw)
+	if err != nil {
+		return err
+	}
+
+	opts, err := runRequestOptions(issuerKp, xkey)
+	if err != nil {
+		return err
+	}
+
+	targets, err := rolloutTargets(ctx, nodeClient)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no nodes are running a workload named '%s'; name nodes to deploy to with --node", RunOpts.Name)
+	}
+
+	deployFactory := func(nodeId string) (*controlapi.DeployRequest, error) {
+		// the environment is encrypted for each node's own xkey
+		info, err := nodeClient.NodeInfo(ctx, nodeId)
+		if err != nil {
+			return nil, err
+		}
+
+		return controlapi.NewDeployRequest(append(slices.Clone(opts),
+			controlapi.TargetNode(nodeId),
+			controlapi.TargetPublicXKey(info.PublicXKey),
+		)...)
+	}
+	stopFactory := func(target controlapi.RolloutTarget) (*controlapi.StopRequest, error) {
+		return controlapi.NewStopRequest(target.PreviousWorkloadId, RunOpts.Name, target.NodeId, issuerKp)
+	}
+
+	workloadRollout, err := nodeClient.NewRollout(targets, controlapi.RolloutStrategy{
+		MaxSurge:         RolloutOpts.MaxSurge,
+		MaxUnavailable:   RolloutOpts.MaxUnavailable,
+		FailureThreshold: RolloutOpts.FailureThreshold,
+		ReadyTimeout:     RolloutOpts.ReadyTimeout,
+	}, deployFactory, stopFactory)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🚀 Rolling out '%s' across %d node(s) as rollout %s\n", RunOpts.Name, len(targets), workloadRollout.ID())
+
+	status, err := workloadRollout.Run(ctx)
+	if status != nil {
+		renderRolloutStatus(status)
+	}
+	if err != nil {
+		return err
+	}
+	if status.Failed > 0 {
+		return fmt.Errorf("rollout failed on %d of %d nodes", status.Failed, status.Total)
+	}
+
+	return nil
+}
+
+// Lists a target for each workload named --name, followed by the nodes given with --node that
+// are not already running it
+func rolloutTargets(ctx context.Context, nodeClient *controlapi.Client) ([]controlapi.RolloutTarget, error) {
+	if RunOpts.Name == "" {
+		return nil, errors.New("a workload name is required")
+	}
+
+	responses, err := nodeClient.PingWorkloads(ctx, RunOpts.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]controlapi.RolloutTarget, 0)
+	running := make(map[string]bool)
+	for _, response := range responses {
+		for _, machine := range response.RunningMachines {
+			if machine.Name != RunOpts.Name {
+				continue
+			}
+			targets = append(targets, controlapi.RolloutTarget{NodeId: response.NodeId, PreviousWorkloadId: machine.Id})
+			running[response.NodeId] = true
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].NodeId < targets[j].NodeId
+	})
+
+	for _, nodeId := range RolloutOpts.Nodes {
+		if !running[nodeId] {
+			targets = append(targets, controlapi.RolloutTarget{NodeId: nodeId})
+			running[nodeId] = true
+		}
+	}
+
+	return targets, nil
+}
+
+// Sends a status, pause, resume or abort request to the process coordinating a rollout
+func ControlRollout(ctx context.Context, logger *slog.Logger, op string, rolloutID string) error {
+	nc, err := models.GenerateConnectionFromOpts(Opts, logger)
+	if err != nil {
+		return err
+	}
+	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, logger)
+
+	var status *controlapi.RolloutStatus
+	switch op {
+	case "pause":
+		status, err = nodeClient.PauseRollout(ctx, rolloutID)
+	case "resume":
+		status, err = nodeClient.ResumeRollout(ctx, rolloutID)
+	case "abort":
+		status, err = nodeClient.AbortRollout(ctx, rolloutID)
+	default:
+		status, err = nodeClient.RolloutStatus(ctx, rolloutID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return renderStructured(status)
+	}
+
+	renderRolloutStatus(status)
+	return nil
+}
+
+func renderRolloutStatus(status *controlapi.RolloutStatus) {
+	fmt.Printf("Rollout %s is %s: %d of %d node(s) updated, %d failed, %d in progress\n",
+		status.ID, status.State, status.Completed, status.Total, status.Failed, status.InProgress)
+	if status.LastError != "" {
+		fmt.Printf("⛔ Last error: %s\n", status.LastError)
+	}
+}
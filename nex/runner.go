@@ -2,17 +2,24 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nats-io/nkeys"
 	controlapi "github.com/synadia-io/nex/control-api"
 	"github.com/synadia-io/nex/internal/models"
 )
 
+const readyProbeInterval = 500 * time.Millisecond
+
 // Issues a request to stop a running workload
 func StopWorkload(ctx context.Context, logger *slog.Logger) error {
 	nc, err := models.GenerateConnectionFromOpts(Opts, logger)
@@ -31,12 +38,25 @@ func StopWorkload(ctx context.Context, logger *slog.Logger) error {
 	if err != nil {
 		return err
 	}
+
+	if FanOutOpts.Enabled() {
+		return stopWorkloadOnNodes(ctx, nodeClient, issuerKp)
+	}
+
+	if StopOpts.TargetNode == "" && StopOpts.WorkloadId == "" {
+		return stopWorkloadByName(ctx, nodeClient, issuerKp)
+	}
+
+	if StopOpts.TargetNode == "" || StopOpts.WorkloadId == "" {
+		return errors.New("both a node id and a workload id are required; omit both to stop the workload by name")
+	}
+
 	stopRequest, err := controlapi.NewStopRequest(StopOpts.WorkloadId, StopOpts.WorkloadName, StopOpts.TargetNode, issuerKp)
 	if err != nil {
 		fmt.Printf("⛔ Failed to create workload request: %s\n", err)
 		return err
 	}
-	resp, err := nodeClient.StopWorkload(stopRequest)
+	resp, err := nodeClient.StopWorkload(ctx, stopRequest)
 	if err != nil {
 		fmt.Printf("⛔ Workload stop request failed: %s\n", err)
 		return err
@@ -46,6 +66,118 @@ func StopWorkload(ctx context.Context, logger *slog.Logger) error {
 	return nil
 }
 
+// Stops every workload with the requested name on each node matching the fan-out flags
+func stopWorkloadOnNodes(ctx context.Context, nodeClient *controlapi.Client, issuerKp nkeys.KeyPair) error {
+	if StopOpts.TargetNode != "" {
+		return errors.New("node and workload ids cannot be combined with --all-nodes or --selector; workloads are stopped by name")
+	}
+
+	nodeIds, err := discoverFanOutNodes(ctx, nodeClient)
+	if err != nil {
+		return err
+	}
+
+	return reportNodeResults(fanOut(ctx, nodeIds, func(ctx context.Context, nodeId string) (string, error) {
+		info, err := nodeClient.NodeInfo(ctx, nodeId)
+		if err != nil {
+			return "", err
+		}
+
+		stopped := 0
+		for _, machine := range info.Machines {
+			if machine.Workload.Name != StopOpts.WorkloadName {
+				continue
+			}
+
+			stopRequest, err := controlapi.NewStopRequest(machine.Id, StopOpts.WorkloadName, nodeId, issuerKp)
+			if err != nil {
+				return "", err
+			}
+
+			resp, err := nodeClient.StopWorkload(ctx, stopRequest)
+			if err != nil {
+				return "", fmt.Errorf("failed to stop workload %s: %s", machine.Id, err)
+			}
+			if !resp.Stopped {
+				return "", fmt.Errorf("workload %s failed to stop", machine.Id)
+			}
+			stopped++
+		}
+
+		return fmt.Sprintf("stopped %d workload(s) named '%s'", stopped, StopOpts.WorkloadName), nil
+	}))
+}
+
+// Stops the workloads with the requested name in the namespace wherever they run, as found by a
+// workload ping. Each workload is stopped with a request to its own node, so a node that cannot
+// be reached does not keep the others from stopping. More than one match is only stopped with
+// --all, and the matches are confirmed unless --force is given
+func stopWorkloadByName(ctx context.Context, nodeClient *controlapi.Client, issuerKp nkeys.KeyPair) error {
+	issuer, err := issuerKp.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	found, err := nodeClient.FindWorkloadsByName(ctx, StopOpts.WorkloadName, issuer)
+	if err != nil {
+		return err
+	}
+
+	if len(found) == 0 {
+		return fmt.Errorf("no workload named '%s' found in namespace %s", StopOpts.WorkloadName, Opts.Namespace)
+	}
+
+	for _, workload := range found {
+		fmt.Printf("  %s on %s\n", workload.Id, workload.NodeId)
+	}
+
+	if len(found) > 1 && !StopOpts.All {
+		return fmt.Errorf("%d workloads named '%s' found; use --all to stop them all, or give a node and workload id", len(found), StopOpts.WorkloadName)
+	}
+
+	if !StopOpts.Force {
+		ok, err := confirm(fmt.Sprintf("Stop %d workload(s) named '%s' in namespace %s?", len(found), StopOpts.WorkloadName, Opts.Namespace))
+		if err != nil {
+			return fmt.Errorf("%s; use --force to stop without confirmation", err)
+		}
+		if !ok {
+			fmt.Println("Not stopping any workloads")
+			return nil
+		}
+	}
+
+	results := make([]nodeResult, len(found))
+
+	var wg sync.WaitGroup
+	for i, workload := range found {
+		wg.Add(1)
+		go func(i int, workload controlapi.WorkloadLocation) {
+			defer wg.Done()
+			results[i] = nodeResult{NodeId: workload.NodeId}
+
+			stopRequest, err := controlapi.NewStopRequest(workload.Id, StopOpts.WorkloadName, workload.NodeId, issuerKp)
+			if err != nil {
+				results[i].Err = err
+				return
+			}
+
+			resp, err := nodeClient.StopWorkload(ctx, stopRequest)
+			if err != nil {
+				results[i].Err = fmt.Errorf("failed to stop workload %s: %s", workload.Id, err)
+				return
+			}
+			if !resp.Stopped {
+				results[i].Err = fmt.Errorf("workload %s failed to stop", workload.Id)
+				return
+			}
+			results[i].Message = fmt.Sprintf("stopped workload %s", workload.Id)
+		}(i, workload)
+	}
+	wg.Wait()
+
+	return reportNodeResults(results)
+}
+
 // Submits a run request for the given workload to the specified node
 func RunWorkload(ctx context.Context, logger *slog.Logger) error {
 	nc, err := models.GenerateConnectionFromOpts(Opts, logger)
@@ -55,7 +187,7 @@ func RunWorkload(ctx context.Context, logger *slog.Logger) error {
 	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, logger)
 
 	// Get node info so we can get public xkey from the target for env encryption
-	nodeInfo, err := nodeClient.NodeInfo(RunOpts.TargetNode)
+	nodeInfo, err := nodeClient.NodeInfo(ctx, RunOpts.TargetNode)
 	if err != nil {
 		return err
 	}
@@ -80,8 +212,61 @@ func RunWorkload(ctx context.Context, logger *slog.Logger) error {
 		return err
 	}
 
-	if RunOpts.WorkloadType == "v8" && len(RunOpts.TriggerSubjects) == 0 {
-		return errors.New("cannot start a function-type workload without specifying at least one trigger subject")
+	opts, err := runRequestOptions(issuerKp, xkey)
+	if err != nil {
+		return err
+	}
+	opts = append(opts,
+		controlapi.TargetNode(RunOpts.TargetNode),
+		controlapi.TargetPublicXKey(targetPublicXkey),
+	)
+
+	request, err := controlapi.NewDeployRequest(opts...)
+	if err != nil {
+		return nil
+	}
+
+	wait := RunOpts.WaitForReady || RunOpts.RollbackOnFailure
+
+	// subscribe before submitting the request so we can't miss the deployment events
+	var events chan controlapi.EmittedEvent
+	if wait {
+		events, err = nodeClient.MonitorEvents(Opts.Namespace, "*", 100)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := nodeClient.StartWorkload(ctx, request)
+	if err != nil {
+		fmt.Printf("⛔ Workload run request failed to submit: %s\n", err)
+		return err
+	}
+
+	renderRunResponse(RunOpts.TargetNode, resp)
+	if !wait || !resp.Started {
+		return nil
+	}
+
+	fmt.Println()
+	err = awaitWorkloadReady(ctx, nodeClient, RunOpts.TargetNode, resp.ID, events, RunOpts.WaitTimeout)
+	if err != nil {
+		fmt.Printf("⛔ Workload '%s' failed to become ready: %s\n", resp.Name, err)
+
+		if RunOpts.RollbackOnFailure {
+			rollbackWorkload(ctx, nodeClient, resp, issuerKp)
+		}
+		return err
+	}
+
+	fmt.Printf("✅ Workload '%s' is ready.\n", resp.Name)
+	return nil
+}
+
+// Builds the deploy request options given by the run flags, leaving out the target node
+func runRequestOptions(issuerKp nkeys.KeyPair, xkey nkeys.KeyPair) ([]controlapi.RequestOption, error) {
+	if RunOpts.WorkloadType == "v8" && len(RunOpts.TriggerSubjects) == 0 && RunOpts.ServiceFile == "" {
+		return nil, errors.New("cannot start a function-type workload without specifying at least one trigger subject or a service")
 	}
 
 	argv := []string{}
@@ -89,34 +274,176 @@ func RunWorkload(ctx context.Context, logger *slog.Logger) error {
 		argv = strings.Split(RunOpts.Argv, " ")
 	}
 
-	request, err := controlapi.NewDeployRequest(
+	triggerWorkers := controlapi.TriggerWorkerPool{
+		Workers:   RunOpts.TriggerWorkers,
+		QueueSize: RunOpts.TriggerQueueSize,
+	}
+	if RunOpts.TriggerShed {
+		triggerWorkers.ShedWhenFull = &RunOpts.TriggerShed
+	}
+
+	opts := []controlapi.RequestOption{
 		controlapi.Argv(argv),
 		controlapi.Location(RunOpts.WorkloadUrl.String()),
 		controlapi.Environment(RunOpts.Env),
 		controlapi.Essential(RunOpts.Essential),
 		controlapi.Issuer(issuerKp),
 		controlapi.SenderXKey(xkey),
-		controlapi.TargetNode(RunOpts.TargetNode),
-		controlapi.TargetPublicXKey(targetPublicXkey),
 		controlapi.WorkloadName(RunOpts.Name),
 		controlapi.JsDomain(Opts.JsDomain),
 		controlapi.WorkloadType(RunOpts.WorkloadType),
 		controlapi.TriggerSubjects(RunOpts.TriggerSubjects),
 		controlapi.Checksum("abc12345TODOmakethisreal"),
 		controlapi.WorkloadDescription(RunOpts.Description),
-	)
+		controlapi.Resources(controlapi.WorkloadResources{MemoryMib: RunOpts.MemoryMib}),
+		controlapi.GPUs(RunOpts.GPUs),
+		controlapi.Group(RunOpts.Group),
+		controlapi.StopGracePeriod(RunOpts.StopGracePeriod),
+		controlapi.TTL(RunOpts.TTL),
+		controlapi.Labels(RunOpts.Labels),
+		controlapi.TriggerWorkers(triggerWorkers),
+	}
+
+	for arch, archUrl := range RunOpts.ArchUrls {
+		opts = append(opts, controlapi.ArchLocation(arch, archUrl))
+	}
+
+	if RunOpts.VolumeName != "" {
+		opts = append(opts, controlapi.Volume(RunOpts.VolumeName, RunOpts.VolumePath, RunOpts.VolumeSizeMib))
+	} else if RunOpts.VolumePath != "" || RunOpts.VolumeSizeMib != 0 {
+		return nil, errors.New("--volume-path and --volume-size require --volume")
+	}
+
+	if RunOpts.ServiceFile != "" {
+		raw, err := os.ReadFile(RunOpts.ServiceFile)
+		if err != nil {
+			return nil, err
+		}
+
+		var service controlapi.NatsService
+		err = json.Unmarshal(raw, &service)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse service declaration: %w", err)
+		}
+		opts = append(opts, controlapi.Service(service))
+	}
+
+	if RunOpts.CacheTTL > 0 {
+		opts = append(opts, controlapi.CacheResults(RunOpts.CacheTTL, RunOpts.CacheSubjects...))
+	} else if len(RunOpts.CacheSubjects) > 0 {
+		return nil, errors.New("--cache-subject requires --cache-results")
+	}
+
+	if RunOpts.Init {
+		var payload []byte
+		if RunOpts.InitPayloadFile != "" {
+			var err error
+			payload, err = os.ReadFile(RunOpts.InitPayloadFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		opts = append(opts, controlapi.Init(payload, RunOpts.InitTimeout))
+	} else if RunOpts.InitPayloadFile != "" || RunOpts.InitTimeout != 0 {
+		return nil, errors.New("--init-payload and --init-timeout require --init")
+	}
+
+	for mountPath, mountUrl := range RunOpts.Mounts {
+		opts = append(opts, controlapi.Mount(mountUrl, mountPath, RunOpts.MountDigests[mountPath]))
+	}
+	for mountPath := range RunOpts.MountDigests {
+		if _, ok := RunOpts.Mounts[mountPath]; !ok {
+			return nil, fmt.Errorf("--mount-sha256 given for %s, which is not mounted", mountPath)
+		}
+	}
+
+	switch {
+	case RunOpts.InputFile != "" && RunOpts.InputUrl != "":
+		return nil, errors.New("only one of --input and --input-url may be given")
+	case RunOpts.InputFile != "":
+		input, err := os.ReadFile(RunOpts.InputFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, controlapi.InputData(input, RunOpts.InputPath))
+	case RunOpts.InputUrl != "":
+		opts = append(opts, controlapi.InputLocation(RunOpts.InputUrl, RunOpts.InputPath))
+	case RunOpts.InputPath != "":
+		return nil, errors.New("--input-path requires --input or --input-url")
+	}
+
+	return opts, nil
+}
+
+// Blocks until the given workload has emitted its deployed event and passed a health probe of the
+// node hosting it, returning the failure cause reported by events if the workload is undeployed first
+func awaitWorkloadReady(ctx context.Context, nodeClient *controlapi.Client, nodeId string, workloadID string, events chan controlapi.EmittedEvent, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// events are sourced as {node}-{workload id}
+	sourceSuffix := fmt.Sprintf("-%s", workloadID)
+
+	probe := time.NewTicker(readyProbeInterval)
+	defer probe.Stop()
+
+	deployed := false
+	for {
+		select {
+		case <-ctx.Done():
+			if !deployed {
+				return errors.New("timed out waiting for workload_deployed event")
+			}
+			return errors.New("timed out waiting for workload to pass its first health probe")
+		case emittedEvent := <-events:
+			if !strings.HasSuffix(emittedEvent.Event.Source(), sourceSuffix) {
+				continue
+			}
+
+			switch emittedEvent.EventType {
+			case controlapi.WorkloadDeployedEventType:
+				deployed = true
+			case controlapi.WorkloadUndeployedEventType:
+				evt := &controlapi.WorkloadUndeployedEvent{}
+				if err := emittedEvent.Event.DataAs(evt); err != nil {
+					return errors.New("workload was undeployed before becoming ready")
+				}
+				return fmt.Errorf("workload was undeployed before becoming ready (code %d): %s", evt.Code, evt.Message)
+			}
+		case <-probe.C:
+			if !deployed {
+				continue
+			}
+
+			info, err := nodeClient.NodeInfo(ctx, nodeId)
+			if err != nil {
+				continue
+			}
+			for _, machine := range info.Machines {
+				if machine.Id == workloadID && machine.Healthy {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// Stops a workload that failed to become ready after deployment
+func rollbackWorkload(ctx context.Context, nodeClient *controlapi.Client, resp *controlapi.RunResponse, issuerKp nkeys.KeyPair) {
+	stopRequest, err := controlapi.NewStopRequest(resp.ID, resp.Name, RunOpts.TargetNode, issuerKp)
 	if err != nil {
-		return nil
+		fmt.Printf("⛔ Failed to create rollback stop request: %s\n", err)
+		return
 	}
 
-	resp, err := nodeClient.StartWorkload(request)
+	stopResp, err := nodeClient.StopWorkload(ctx, stopRequest)
 	if err != nil {
-		fmt.Printf("⛔ Workload run request failed to submit: %s\n", err)
-		return err
+		fmt.Printf("⛔ Workload rollback failed: %s\n", err)
+		return
 	}
 
-	renderRunResponse(RunOpts.TargetNode, resp)
-	return nil
+	fmt.Print("↩️  Rolled back: ")
+	renderStopResponse(stopResp)
 }
 
 func renderRunResponse(targetNode string, resp *controlapi.RunResponse) {
@@ -134,3 +461,553 @@ func renderStopResponse(resp *controlapi.StopResponse) {
 		fmt.Println("⛔ Workload failed to stop")
 	}
 }
+
+// Retrieves and renders the recent trigger executions of a function workload
+func ExecutionHistory(ctx context.Context, nodeId string, workloadId string, limit int) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log)
+	resp, err := nodeClient.ExecutionHistory(ctx, nodeId, &controlapi.ExecutionHistoryRequest{
+		WorkloadId: workloadId,
+		Limit:      limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return renderStructured(resp)
+	}
+
+	if len(resp.Executions) == 0 {
+		fmt.Println("No executions recorded")
+		return nil
+	}
+
+	tbl := newTableWriter(fmt.Sprintf("Executions of %s", workloadId))
+	tbl.AddHeaders("Started", "Subject", "Duration", "Runtime", "Payload", "Response", "Result")
+	for _, e := range resp.Executions {
+		result := "ok"
+		if !e.Success {
+			result = e.Error
+		}
+		tbl.AddRow(
+			e.StartedAt.Local().Format(time.StampMilli),
+			e.TriggerSubject,
+			time.Duration(e.DurationNanos),
+			time.Duration(e.RuntimeNanos),
+			e.PayloadBytes,
+			e.ResponseBytes,
+			result,
+		)
+	}
+	fmt.Println(tbl.Render())
+
+	return nil
+}
+
+// Pauses a workload without undeploying it, signed by the seed in the issuer file
+func PauseWorkload(ctx context.Context, nodeId string, workloadId string, pauseMachine bool, issuerFile string) error {
+	issuerKp, err := readSeedFile(issuerFile)
+	if err != nil {
+		return fmt.Errorf("invalid issuer: %s", err)
+	}
+
+	request, err := controlapi.NewPauseRequest(workloadId, pauseMachine, issuerKp)
+	if err != nil {
+		return err
+	}
+
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log)
+	resp, err := nodeClient.PauseWorkload(ctx, nodeId, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Paused workload %s", workloadId)
+	if resp.MachinePaused {
+		fmt.Print(" and its machine")
+	}
+	if len(resp.TriggerSubjects) > 0 {
+		fmt.Printf("; suspended triggers: %s", strings.Join(resp.TriggerSubjects, ", "))
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// Resumes a paused workload, signed by the seed in the issuer file
+func ResumeWorkload(ctx context.Context, nodeId string, workloadId string, issuerFile string) error {
+	issuerKp, err := readSeedFile(issuerFile)
+	if err != nil {
+		return fmt.Errorf("invalid issuer: %s", err)
+	}
+
+	request, err := controlapi.NewResumeRequest(workloadId, issuerKp)
+	if err != nil {
+		return err
+	}
+
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log)
+	_, err = nodeClient.ResumeWorkload(ctx, nodeId, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Resumed workload %s\n", workloadId)
+	return nil
+}
+
+// Lists the workloads quarantined on a node, or resumes or stops one of them with claims signed by
+// the seed in the issuer file
+func Quarantine(ctx context.Context, action string, nodeId string, workloadId string, issuerFile string) error {
+	var issuerKp nkeys.KeyPair
+	if action != controlapi.QuarantineActionList {
+		var err error
+		issuerKp, err = readSeedFile(issuerFile)
+		if err != nil {
+			return fmt.Errorf("invalid issuer: %s", err)
+		}
+	}
+
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log)
+
+	var resp *controlapi.QuarantineResponse
+	switch action {
+	case controlapi.QuarantineActionResume:
+		resp, err = nodeClient.ResumeQuarantined(ctx, nodeId, workloadId, issuerKp)
+	case controlapi.QuarantineActionStop:
+		resp, err = nodeClient.StopQuarantined(ctx, nodeId, workloadId, issuerKp)
+	default:
+		resp, err = nodeClient.QuarantinedWorkloads(ctx, nodeId)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case controlapi.QuarantineActionResume:
+		fmt.Printf("Resumed quarantined workload %s\n", workloadId)
+		return nil
+	case controlapi.QuarantineActionStop:
+		fmt.Printf("Stopped quarantined workload %s\n", workloadId)
+		return nil
+	}
+
+	if structuredOutput() {
+		return renderStructured(resp)
+	}
+
+	if len(resp.Workloads) == 0 {
+		fmt.Println("No quarantined workloads")
+		return nil
+	}
+
+	tbl := newTableWriter(fmt.Sprintf("Quarantined workloads on %s", nodeId))
+	if !wideOutput() {
+		tbl.AddHeaders("ID", "Name", "Reason", "Since", "Running", "Failures", "Crashes", "Paused Subjects")
+	} else {
+		tbl.AddHeaders("ID", "Name", "Namespace", "Reason", "Since", "Running", "Failures", "Crashes", "Paused Subjects")
+	}
+	for _, w := range resp.Workloads {
+		row := []any{w.WorkloadId, w.Name}
+		if wideOutput() {
+			row = append(row, w.Namespace)
+		}
+		row = append(row,
+			w.Reason,
+			w.QuarantinedAt.Local().Format(time.Stamp),
+			w.Running,
+			fmt.Sprintf("%d/%d", w.Failures, w.Executions),
+			w.Crashes,
+			strings.Join(w.PausedSubjects, ", "),
+		)
+		tbl.AddRow(row...)
+	}
+	fmt.Println(tbl.Render())
+
+	return nil
+}
+
+// Shows the resources the namespace's workloads have used on a node since it started
+func Usage(ctx context.Context, nodeId string) error {
+	nodeClient, err := namespaceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := nodeClient.Usage(ctx, nodeId)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return renderStructured(resp)
+	}
+
+	cols := newColumns("Usage of namespace %s on %s since %s", resp.Usage.Namespace, nodeId, resp.Since.Local().Format(time.RFC1123))
+	cols.AddRowf("CPU", "%.1f seconds", resp.Usage.CPUSeconds)
+	cols.AddRowf("Memory", "%.1f GiB-hours", resp.Usage.MemoryByteSeconds/(1024*1024*1024)/3600)
+	cols.AddRow("Invocations", resp.Usage.Invocations)
+	cols.AddRowf("Egress", "%.1f MiB", float64(resp.Usage.EgressBytes)/1024/1024)
+	render(cols)
+
+	return nil
+}
+
+// Lists the volumes a node keeps for the namespace, or deletes one of them with claims signed by
+// the seed in the issuer file
+func Volumes(ctx context.Context, action string, nodeId string, name string, issuerFile string) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log)
+
+	if action == controlapi.VolumeActionDelete {
+		issuerKp, err := readSeedFile(issuerFile)
+		if err != nil {
+			return fmt.Errorf("invalid issuer: %s", err)
+		}
+
+		request, err := controlapi.NewVolumeDeleteRequest(name, issuerKp)
+		if err != nil {
+			return err
+		}
+
+		_, err = nodeClient.DeleteVolume(ctx, nodeId, request)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted volume %s\n", name)
+		return nil
+	}
+
+	resp, err := nodeClient.Volumes(ctx, nodeId)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return renderStructured(resp)
+	}
+
+	if len(resp.Volumes) == 0 {
+		fmt.Println("No volumes")
+		return nil
+	}
+
+	tbl := newTableWriter(fmt.Sprintf("Volumes on %s", nodeId))
+	tbl.AddHeaders("Name", "Size (MiB)", "Used (MiB)", "Created", "Workload")
+	for _, v := range resp.Volumes {
+		tbl.AddRow(v.Name, v.SizeMib, fmt.Sprintf("%.1f", float64(v.UsedBytes)/1024/1024), v.CreatedAt.Local().Format(time.Stamp), v.WorkloadId)
+	}
+	fmt.Println(tbl.Render())
+
+	return nil
+}
+
+func CheckpointWorkload(ctx context.Context, nodeId string, workloadId string, stop bool, issuerFile string) error {
+	issuerKp, err := readSeedFile(issuerFile)
+	if err != nil {
+		return fmt.Errorf("invalid issuer: %s", err)
+	}
+
+	request, err := controlapi.NewCheckpointRequest(workloadId, stop, issuerKp)
+	if err != nil {
+		return err
+	}
+
+	nodeClient, err := namespaceClient()
+	if err != nil {
+		return err
+	}
+
+	checkpoint, err := nodeClient.CheckpointWorkload(ctx, nodeId, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Checkpointed workload %s as %s (%.1f MiB)\n", workloadId, checkpoint.Id, float64(checkpoint.SizeBytes)/1024/1024)
+	if checkpoint.Stopped {
+		fmt.Println("The workload was stopped; restore the checkpoint to bring it back")
+	}
+	return nil
+}
+
+func ListCheckpoints(ctx context.Context, nodeId string, workloadId string) error {
+	nodeClient, err := namespaceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := nodeClient.ListCheckpoints(ctx, nodeId, &controlapi.CheckpointListRequest{WorkloadId: workloadId})
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return renderStructured(resp)
+	}
+
+	if len(resp.Checkpoints) == 0 {
+		fmt.Println("No checkpoints")
+		return nil
+	}
+
+	tbl := newTableWriter(fmt.Sprintf("Checkpoints in bucket %s", resp.Checkpoints[0].Bucket))
+	tbl.AddHeaders("ID", "Workload", "Name", "Type", "Size (MiB)", "Created", "Node", "Stopped")
+	for _, c := range resp.Checkpoints {
+		tbl.AddRow(c.Id, c.WorkloadId, c.Name, c.WorkloadType, fmt.Sprintf("%.1f", float64(c.SizeBytes)/1024/1024), c.CreatedAt.Local().Format(time.Stamp), c.NodeId, c.Stopped)
+	}
+	fmt.Println(tbl.Render())
+
+	return nil
+}
+
+func RestoreWorkload(ctx context.Context, nodeId string, checkpointId string, issuerFile string) error {
+	issuerKp, err := readSeedFile(issuerFile)
+	if err != nil {
+		return fmt.Errorf("invalid issuer: %s", err)
+	}
+
+	request, err := controlapi.NewRestoreRequest(checkpointId, issuerKp)
+	if err != nil {
+		return err
+	}
+
+	nodeClient, err := namespaceClient()
+	if err != nil {
+		return err
+	}
+
+	resp, err := nodeClient.RestoreWorkload(ctx, nodeId, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored workload %s on %s from checkpoint %s\n", resp.Checkpoint.WorkloadId, resp.NodeId, checkpointId)
+	return nil
+}
+
+func namespaceClient() (*controlapi.Client, error) {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log), nil
+}
+
+func CreateNamespace(ctx context.Context, ns *controlapi.Namespace) error {
+	nodeClient, err := namespaceClient()
+	if err != nil {
+		return err
+	}
+
+	created, err := nodeClient.CreateNamespace(ctx, ns)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created namespace %s\n", created.Name)
+	return nil
+}
+
+func NamespaceInfo(ctx context.Context) error {
+	nodeClient, err := namespaceClient()
+	if err != nil {
+		return err
+	}
+
+	ns, err := nodeClient.GetNamespace(ctx)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return renderStructured(ns)
+	}
+
+	cols := newColumns("Namespace %s", ns.Name)
+	cols.AddRow("Description", ns.Description)
+	cols.AddRow("Created", ns.CreatedAt.Local().Format(time.RFC1123))
+	cols.AddRow("Deletion Policy", string(ns.DeletionPolicy))
+	if ns.Quota != nil {
+		cols.AddRow("Max Workloads per Node", ns.Quota.MaxWorkloads)
+		cols.AddRow("Max Memory per Node (MiB)", ns.Quota.MaxMemoryMib)
+	}
+	if ns.DefaultResources != nil {
+		cols.AddRow("Default Memory (MiB)", ns.DefaultResources.MemoryMib)
+	}
+	if len(ns.DefaultEnvironment) > 0 {
+		names := make([]string, 0, len(ns.DefaultEnvironment))
+		for name := range ns.DefaultEnvironment {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		cols.AddRow("Default Environment", strings.Join(names, ", "))
+	}
+	if len(ns.DefaultConfig) > 0 {
+		cols.AddRow("Default Config", string(ns.DefaultConfig))
+	}
+	keys := make([]string, 0, len(ns.Metadata))
+	for key := range ns.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cols.AddRow(key, ns.Metadata[key])
+	}
+	fmt.Println(cols.Render())
+
+	return nil
+}
+
+func ListNamespaces(ctx context.Context) error {
+	nodeClient, err := namespaceClient()
+	if err != nil {
+		return err
+	}
+
+	list, err := nodeClient.ListNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		if list == nil {
+			list = []controlapi.Namespace{}
+		}
+		return renderStructured(list)
+	}
+
+	if len(list) == 0 {
+		fmt.Println("No namespaces")
+		return nil
+	}
+
+	tbl := newTableWriter("Namespaces")
+	if !wideOutput() {
+		tbl.AddHeaders("Name", "Description", "Created", "Max Workloads", "Max Memory (MiB)", "Deletion Policy")
+	} else {
+		tbl.AddHeaders("Name", "Description", "Created", "Max Workloads", "Max Memory (MiB)", "Deletion Policy", "Default Memory (MiB)", "Metadata")
+	}
+	for _, ns := range list {
+		var maxWorkloads, maxMemory int
+		if ns.Quota != nil {
+			maxWorkloads, maxMemory = ns.Quota.MaxWorkloads, ns.Quota.MaxMemoryMib
+		}
+		row := []any{ns.Name, ns.Description, ns.CreatedAt.Local().Format(time.Stamp), maxWorkloads, maxMemory, ns.DeletionPolicy}
+		if wideOutput() {
+			var defaultMemory int
+			if ns.DefaultResources != nil {
+				defaultMemory = ns.DefaultResources.MemoryMib
+			}
+			metadata := make([]string, 0, len(ns.Metadata))
+			for key, value := range ns.Metadata {
+				metadata = append(metadata, fmt.Sprintf("%s=%s", key, value))
+			}
+			sort.Strings(metadata)
+			row = append(row, defaultMemory, strings.Join(metadata, ", "))
+		}
+		tbl.AddRow(row...)
+	}
+	fmt.Println(tbl.Render())
+
+	return nil
+}
+
+// Deletes the namespace. Nodes only let administrators, identified by the seed in the identity
+// file, delete a namespace unless its workloads are orphaned and no access policy is enforced
+func DeleteNamespace(ctx context.Context, policy controlapi.NamespaceDeletionPolicy, identityFile string) error {
+	nodeClient, err := namespaceClient()
+	if err != nil {
+		return err
+	}
+
+	var callOpts []controlapi.CallOption
+	if identityFile != "" {
+		identity, err := readSeedFile(identityFile)
+		if err != nil {
+			return fmt.Errorf("invalid identity: %s", err)
+		}
+		callOpts = append(callOpts, controlapi.WithIdentity(identity))
+	}
+
+	ns, err := nodeClient.DeleteNamespace(ctx, policy, callOpts...)
+	if err != nil {
+		return err
+	}
+
+	if ns.DeletionPolicy == controlapi.NamespaceDeletionOrphan {
+		fmt.Printf("Deleted namespace %s; its workloads are left running\n", ns.Name)
+	} else {
+		fmt.Printf("Deleted namespace %s; its workloads are being stopped\n", ns.Name)
+	}
+	return nil
+}
+
+// Displays the status of a job workload and, once it has completed, its exit code and the tail
+// of its output
+func JobStatus(ctx context.Context, nodeId string, workloadId string) error {
+	log := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	nc, err := models.GenerateConnectionFromOpts(Opts, log)
+	if err != nil {
+		return err
+	}
+
+	nodeClient := controlapi.NewApiClientWithNamespace(nc, Opts.Timeout, Opts.Namespace, log)
+	status, err := nodeClient.JobStatus(ctx, nodeId, workloadId)
+	if err != nil {
+		return err
+	}
+
+	if structuredOutput() {
+		return renderStructured(status)
+	}
+
+	tbl := newTableWriter(fmt.Sprintf("Job %s", workloadId))
+	tbl.AddRow("Name", status.Name)
+	tbl.AddRow("State", status.State)
+	tbl.AddRow("Started", status.StartedAt.Local().Format(time.StampMilli))
+	if status.CompletedAt != nil {
+		tbl.AddRow("Completed", status.CompletedAt.Local().Format(time.StampMilli))
+		tbl.AddRow("Runtime", status.CompletedAt.Sub(status.StartedAt))
+	}
+	if status.ExitCode != nil {
+		tbl.AddRow("Exit Code", *status.ExitCode)
+	}
+	fmt.Println(tbl.Render())
+
+	if status.Output != "" {
+		fmt.Println(status.Output)
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	controlapi "github.com/synadia-io/nex/control-api"
+	"github.com/synadia-io/nex/internal/models"
+)
+
+// Answers workload pings and stop requests in the default namespace as the given nodes would,
+// recording which workloads were asked to stop
+type fakeNodes struct {
+	sync.Mutex
+	machines map[string][]controlapi.WorkloadPingMachineSummary
+	stopped  []string
+}
+
+func (f *fakeNodes) respond(m *nats.Msg, responseType string, data interface{}) {
+	raw, _ := json.Marshal(controlapi.NewEnvelope(responseType, data, nil))
+	_ = m.Respond(raw)
+}
+
+func (f *fakeNodes) handlePing(m *nats.Msg) {
+	var filter controlapi.WorkloadFilter
+	if len(m.Data) > 0 {
+		_ = json.Unmarshal(m.Data, &filter)
+	}
+
+	for node, machines := range f.machines {
+		matching := make([]controlapi.WorkloadPingMachineSummary, 0)
+		for _, machine := range machines {
+			if filter.Matches(machine.Issuer) {
+				matching = append(matching, machine)
+			}
+		}
+		f.respond(m, controlapi.PingResponseType, controlapi.WorkloadPingResponse{NodeId: node, RunningMachines: matching})
+	}
+}
+
+func (f *fakeNodes) handleStop(m *nats.Msg) {
+	var request controlapi.StopRequest
+	_ = json.Unmarshal(m.Data, &request)
+
+	f.Lock()
+	f.stopped = append(f.stopped, request.TargetNode+"/"+request.WorkloadId)
+	f.Unlock()
+
+	f.respond(m, controlapi.StopResponseType, controlapi.StopResponse{Stopped: true, ID: request.WorkloadId})
+}
+
+func (f *fakeNodes) stoppedWorkloads() []string {
+	f.Lock()
+	defer f.Unlock()
+
+	stopped := slices.Clone(f.stopped)
+	slices.Sort(stopped)
+	return stopped
+}
+
+func startFakeNodes(t *testing.T, machines map[string][]controlapi.WorkloadPingMachineSummary) (*nats.Conn, *fakeNodes) {
+	ns, err := server.NewServer(&server.Options{Port: -1})
+	if err != nil {
+		t.Fatalf("Failed to create NATS server: %s", err)
+	}
+	ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready for connections")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	nc, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect to NATS: %s", err)
+	}
+	t.Cleanup(nc.Close)
+
+	f := &fakeNodes{machines: machines}
+	if _, err := nc.Subscribe("$NEX.WPING.default", f.handlePing); err != nil {
+		t.Fatalf("Failed to subscribe to workload pings: %s", err)
+	}
+	if _, err := nc.Subscribe("$NEX.STOP.default.*", f.handleStop); err != nil {
+		t.Fatalf("Failed to subscribe to stop requests: %s", err)
+	}
+
+	return nc, f
+}
+
+func TestStopWorkloadByName(t *testing.T) {
+	issuerKp, _ := nkeys.CreateAccount()
+	issuer, _ := issuerKp.PublicKey()
+
+	previousOpts, previousStopOpts := Opts, StopOpts
+	t.Cleanup(func() {
+		Opts, StopOpts = previousOpts, previousStopOpts
+	})
+	Opts = &models.Options{Namespace: "default"}
+
+	for _, tc := range []struct {
+		name      string
+		stopOpts  models.StopOptions
+		wantErr   string
+		wantStops []string
+	}{
+		{
+			name:      "single match",
+			stopOpts:  models.StopOptions{WorkloadName: "solo", Force: true},
+			wantStops: []string{"node-a/a3"},
+		},
+		{
+			name:     "several matches without --all",
+			stopOpts: models.StopOptions{WorkloadName: "echo", Force: true},
+			wantErr:  "use --all",
+		},
+		{
+			name:      "several matches with --all",
+			stopOpts:  models.StopOptions{WorkloadName: "echo", All: true, Force: true},
+			wantStops: []string{"node-a/a1", "node-b/b1"},
+		},
+		{
+			name:     "no match",
+			stopOpts: models.StopOptions{WorkloadName: "missing", Force: true},
+			wantErr:  "no workload named 'missing'",
+		},
+		{
+			name:     "confirmation without --force",
+			stopOpts: models.StopOptions{WorkloadName: "solo"},
+			wantErr:  "use --force",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nc, nodes := startFakeNodes(t, map[string][]controlapi.WorkloadPingMachineSummary{
+				"node-a": {
+					{Id: "a1", Namespace: "default", Name: "echo", Issuer: issuer},
+					{Id: "a2", Namespace: "staging", Name: "echo", Issuer: issuer},
+					{Id: "a3", Namespace: "default", Name: "solo", Issuer: issuer},
+					{Id: "a4", Namespace: "default", Name: "solo", Issuer: "SOMEONE_ELSE"},
+				},
+				"node-b": {
+					{Id: "b1", Namespace: "default", Name: "echo", Issuer: issuer},
+				},
+			})
+			nodeClient := controlapi.NewApiClientWithNamespace(nc, 100*time.Millisecond, "default", slog.Default())
+
+			stopOpts := tc.stopOpts
+			StopOpts = &stopOpts
+
+			err := stopWorkloadByName(context.Background(), nodeClient, issuerKp)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Expected an error containing %q, got %v", tc.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected the workloads to stop: %s", err)
+			}
+
+			stopped := nodes.stoppedWorkloads()
+			if !slices.Equal(stopped, tc.wantStops) {
+				t.Fatalf("Expected %v to be stopped, got %v", tc.wantStops, stopped)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package home
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -52,10 +53,10 @@ func NewHomeModel(h, w int, nc *nats.Conn) HomeModel {
 	if nc != nil {
 		api := controlapi.NewApiClient(nc, time.Second, slog.Default())
 		nodes := []list.Item{}
-		ns, _ := api.PingNodes()
+		ns, _ := api.PingNodes(context.Background())
 		for _, n := range ns {
 			workloads := []list.Item{}
-			info, _ := api.NodeInfo(n.NodeId)
+			info, _ := api.NodeInfo(context.Background(), n.NodeId)
 			for _, w := range info.Machines {
 				workloads = append(workloads, workload(w))
 			}
@@ -224,10 +225,10 @@ func (m HomeModel) refreshNodeData() tea.Model {
 	if m.nc != nil {
 		api := controlapi.NewApiClient(m.nc, time.Second, slog.Default())
 		nodes := []list.Item{}
-		ns, _ := api.PingNodes()
+		ns, _ := api.PingNodes(context.Background())
 		for _, n := range ns {
 			workloads := []list.Item{}
-			info, _ := api.NodeInfo(n.NodeId)
+			info, _ := api.NodeInfo(context.Background(), n.NodeId)
 			for _, w := range info.Machines {
 				workloads = append(workloads, workload(w))
 			}
@@ -0,0 +1,116 @@
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	ErrCancelled = errors.New("selection cancelled")
+
+	titleStyle    = lipgloss.NewStyle().Bold(true)
+	selectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
+	helpStyle     = lipgloss.NewStyle().Faint(true)
+)
+
+// An entry offered by the picker. The value is returned when it is chosen
+type Item struct {
+	Value string
+	Label string
+}
+
+type PickerModel struct {
+	title  string
+	items  []Item
+	cursor int
+
+	chosen    bool
+	cancelled bool
+}
+
+func NewPickerModel(title string, items []Item) PickerModel {
+	return PickerModel{
+		title: title,
+		items: items,
+	}
+}
+
+func (m PickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m PickerModel) Update(message tea.Msg) (tea.Model, tea.Cmd) {
+	msg, ok := message.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.items)-1 {
+			m.cursor++
+		}
+	case "enter":
+		m.chosen = true
+		return m, tea.Quit
+	case "esc", "q", "ctrl+c":
+		m.cancelled = true
+		return m, tea.Quit
+	}
+
+	return m, nil
+}
+
+func (m PickerModel) View() string {
+	if m.chosen || m.cancelled {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(m.title) + "\n\n")
+	for i, item := range m.items {
+		label := item.Label
+		if label == "" {
+			label = item.Value
+		}
+
+		if i == m.cursor {
+			b.WriteString(selectedStyle.Render("> "+label) + "\n")
+		} else {
+			b.WriteString("  " + label + "\n")
+		}
+	}
+	b.WriteString("\n" + helpStyle.Render("↑/↓ to move, enter to select, esc to cancel") + "\n")
+
+	return b.String()
+}
+
+// Lets the user choose one of the items, rendering the picker on stderr so that the output of
+// the command it precedes is left untouched
+func Pick(title string, items []Item) (string, error) {
+	if len(items) == 0 {
+		return "", fmt.Errorf("nothing to choose from: %s", strings.ToLower(title))
+	}
+
+	p := tea.NewProgram(NewPickerModel(title, items), tea.WithOutput(os.Stderr))
+	final, err := p.Run()
+	if err != nil {
+		return "", err
+	}
+
+	m := final.(PickerModel)
+	if !m.chosen {
+		return "", ErrCancelled
+	}
+
+	return m.items[m.cursor].Value, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nats-io/nats.go"
+	controlapi "github.com/synadia-io/nex/control-api"
+)
+
+// Uploads a file to the object store, streaming it rather than reading it into memory. Files
+// larger than chunkBytes are uploaded as a series of chunks followed by a manifest, skipping any
+// chunk already uploaded by an earlier, interrupted attempt
+func uploadFile(bucket nats.ObjectStore, filename string, key string, chunkBytes uint) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	if chunkBytes == 0 || stat.Size() <= int64(chunkBytes) {
+		_, err = bucket.Put(&nats.ObjectMeta{Name: key}, &uploadProgress{reader: f, total: stat.Size()})
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+	} else {
+		err = uploadChunks(bucket, f, stat.Size(), key, int64(chunkBytes))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("nats://%s/%s", objectStoreName, key), nil
+}
+
+func uploadChunks(bucket nats.ObjectStore, f *os.File, size int64, key string, chunkBytes int64) error {
+	digest := sha256.New()
+	_, err := io.Copy(digest, f)
+	if err != nil {
+		return err
+	}
+
+	manifest := controlapi.ArtifactManifest{
+		Size:   size,
+		Sha256: hex.EncodeToString(digest.Sum(nil)),
+	}
+
+	// chunk keys include the file's digest, so chunks of another version of the file are never reused
+	skipped := 0
+	for offset := int64(0); offset < size; offset += chunkBytes {
+		length := min(chunkBytes, size-offset)
+
+		chunkDigest := sha256.New()
+		_, err = io.Copy(chunkDigest, io.NewSectionReader(f, offset, length))
+		if err != nil {
+			return err
+		}
+
+		chunk := controlapi.ArtifactChunk{
+			Key:    fmt.Sprintf("%s.chunks/%s/%05d", key, manifest.Sha256[:16], len(manifest.Chunks)),
+			Size:   length,
+			Sha256: hex.EncodeToString(chunkDigest.Sum(nil)),
+		}
+		manifest.Chunks = append(manifest.Chunks, chunk)
+
+		info, err := bucket.GetInfo(chunk.Key)
+		if err == nil && !info.Deleted && info.Metadata[controlapi.ArtifactChunkDigestMetadata] == chunk.Sha256 {
+			skipped++
+			continue
+		}
+
+		_, err = bucket.Put(&nats.ObjectMeta{
+			Name:     chunk.Key,
+			Metadata: map[string]string{controlapi.ArtifactChunkDigestMetadata: chunk.Sha256},
+		}, io.NewSectionReader(f, offset, length))
+		if err != nil {
+			return fmt.Errorf("failed to upload chunk %d of %s: %s", len(manifest.Chunks), key, err)
+		}
+
+		fmt.Printf("\rUploaded %d of %d bytes", offset+length, size)
+	}
+	fmt.Println()
+
+	if skipped > 0 {
+		fmt.Printf("Resumed upload; %d of %d chunks were already uploaded\n", skipped, len(manifest.Chunks))
+	}
+
+	previous := previousManifest(bucket, key)
+
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	_, err = bucket.Put(&nats.ObjectMeta{
+		Name:     key,
+		Metadata: map[string]string{controlapi.ArtifactManifestMetadata: "true"},
+	}, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	// chunks of the version of the file being replaced are no longer referenced
+	if previous != nil && previous.Sha256 != manifest.Sha256 {
+		for _, chunk := range previous.Chunks {
+			_ = bucket.Delete(chunk.Key)
+		}
+	}
+
+	return nil
+}
+
+// Returns the manifest currently stored under the key, if the object there is one
+func previousManifest(bucket nats.ObjectStore, key string) *controlapi.ArtifactManifest {
+	info, err := bucket.GetInfo(key)
+	if err != nil || info.Metadata[controlapi.ArtifactManifestMetadata] == "" {
+		return nil
+	}
+
+	raw, err := bucket.GetBytes(key)
+	if err != nil {
+		return nil
+	}
+
+	var manifest controlapi.ArtifactManifest
+	if json.Unmarshal(raw, &manifest) != nil {
+		return nil
+	}
+
+	return &manifest
+}
+
+// Reports the progress of a streamed upload as the object store reads it
+type uploadProgress struct {
+	reader io.Reader
+	total  int64
+	read   int64
+}
+
+func (u *uploadProgress) Read(p []byte) (int, error) {
+	n, err := u.reader.Read(p)
+	u.read += int64(n)
+	fmt.Printf("\rUploaded %d of %d bytes", u.read, u.total)
+	return n, err
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 	"time"
 
@@ -32,15 +33,20 @@ func WatchEvents(ctx context.Context, logger *slog.Logger) error {
 	logger.Info("Starting event watcher", slog.String("namespace_filter", namespaceFilter))
 
 	apiClient := controlapi.NewApiClient(nc, 1*time.Second, logger)
-	eventChannel, err := apiClient.MonitorEvents(namespaceFilter, "*", 0)
+	siteNodes, err := watchedSiteNodes(ctx, apiClient)
 	if err != nil {
 		return err
 	}
 
-	for {
-		event := <-eventChannel
+	eventChannel, err := apiClient.WatchEvents(ctx, controlapi.WatchFilter{Namespace: namespaceFilter, NodeIds: siteNodes})
+	if err != nil {
+		return err
+	}
+
+	for event := range eventChannel {
 		handleEventEntry(logger, event)
 	}
+	return nil
 }
 
 func WatchLogs(ctx context.Context, logger *slog.Logger) error {
@@ -76,14 +82,89 @@ func WatchLogs(ctx context.Context, logger *slog.Logger) error {
 	)
 
 	apiClient := controlapi.NewApiClient(nc, 1*time.Second, logger)
-	ch, err := apiClient.MonitorLogs(namespaceFilter, nodeFilter, workloadNameFilter, vmFilter, 0)
+	siteNodes, err := watchedSiteNodes(ctx, apiClient)
 	if err != nil {
 		return err
 	}
-	for {
-		logEntry := <-ch
+
+	ch, err := apiClient.WatchLogs(ctx, controlapi.WatchFilter{
+		Namespace:    namespaceFilter,
+		NodeId:       nodeFilter,
+		WorkloadName: workloadNameFilter,
+		WorkloadId:   vmFilter,
+		NodeIds:      siteNodes,
+	})
+	if err != nil {
+		return err
+	}
+
+	for logEntry := range ch {
 		handleLogEntry(logger, logEntry)
 	}
+	return nil
+}
+
+// Resolves the nodes of the sites given with --site, whose logs and events reach this client through
+// the leaf node connections joining their site to the nexus. Nodes joining a site afterwards are not
+// watched. Returns nil when no site is given
+func watchedSiteNodes(ctx context.Context, apiClient *controlapi.Client) ([]string, error) {
+	if len(WatchOpts.Sites) == 0 {
+		return nil, nil
+	}
+
+	nodes, err := apiClient.SiteNodes(ctx, WatchOpts.Sites)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no nodes discovered in site(s) %s", strings.Join(WatchOpts.Sites, ", "))
+	}
+
+	nodeIds := make([]string, len(nodes))
+	for i, node := range nodes {
+		nodeIds[i] = node.NodeId
+	}
+	return nodeIds, nil
+}
+
+// Watches the audit records of the scoping namespace. Requests that are not scoped to a namespace,
+// such as pings and cordons, are recorded in the system namespace
+func WatchAudit(ctx context.Context, logger *slog.Logger) error {
+	nc, err := models.GenerateConnectionFromOpts(Opts, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Starting audit watcher",
+		slog.String("namespace_filter", Opts.Namespace),
+		slog.String("node_filter", WatchOpts.NodeId),
+	)
+
+	apiClient := controlapi.NewApiClient(nc, 1*time.Second, logger)
+	records, err := apiClient.WatchAudit(ctx, controlapi.WatchFilter{
+		Namespace: strings.TrimSpace(Opts.Namespace),
+		NodeId:    strings.TrimSpace(WatchOpts.NodeId),
+	})
+	if err != nil {
+		return err
+	}
+
+	for record := range records {
+		attrs := []slog.Attr{
+			slog.String("node", record.NodeId),
+			slog.String("namespace", record.Namespace),
+			slog.String("request", record.Request),
+			slog.String("issuer", record.Issuer),
+			slog.String("outcome", string(record.Outcome)),
+			slog.Int64("latency_ms", record.LatencyMillis),
+		}
+		if record.Error != "" {
+			attrs = append(attrs, slog.String("error", record.Error))
+		}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, record.ReceivedAt.Format(time.RFC3339), attrs...)
+	}
+	return nil
 }
 
 func handleEventEntry(log *slog.Logger, emittedEvent controlapi.EmittedEvent) {
@@ -124,6 +205,13 @@ func handleEventEntry(log *slog.Logger, emittedEvent controlapi.EmittedEvent) {
 		} else {
 			attrs = append(attrs, slog.String("message", evt.Message), slog.Int("code", evt.Code), slog.String("workload_name", evt.Name))
 		}
+	case controlapi.JobCompletedEventType:
+		evt := &controlapi.JobCompletedEvent{}
+		if err := event.DataAs(evt); err != nil {
+			attrs = append(attrs, slog.Any("err", err))
+		} else {
+			attrs = append(attrs, slog.String("workload_name", evt.Name), slog.Int("exit_code", evt.ExitCode), slog.Duration("runtime", evt.CompletedAt.Sub(evt.StartedAt)))
+		}
 	case controlapi.NodeStartedEventType:
 		evt := &controlapi.NodeStartedEvent{}
 		if err := event.DataAs(evt); err != nil {
@@ -148,13 +236,26 @@ func handleEventEntry(log *slog.Logger, emittedEvent controlapi.EmittedEvent) {
 }
 
 func handleLogEntry(log *slog.Logger, entry controlapi.EmittedLog) {
-	log.Log(
-		context.Background(),
-		slog.LevelDebug,
-		entry.Text,
+	attrs := []slog.Attr{
 		slog.String("namespace", entry.Namespace),
 		slog.String("node", entry.NodeId),
 		slog.String("workload", entry.Workload),
 		slog.String("vmid", entry.Workload),
-	)
+	}
+
+	if entry.Source != "" {
+		attrs = append(attrs, slog.String("source", entry.Source))
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		attrs = append(attrs, slog.Any(k, entry.Fields[k]))
+	}
+
+	log.LogAttrs(context.Background(), entry.Level, entry.Text, attrs...)
 }
@@ -0,0 +1,161 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Names of the built-in host services and the metadata their methods read
+const (
+	ServiceKeyValue    = "kv"
+	ServiceMessaging   = "messaging"
+	ServiceObjectStore = "objectstore"
+
+	keyValueKeyHeader      = "x-keyvalue-key"
+	messagingSubjectHeader = "x-subject"
+	objectNameHeader       = "x-object-name"
+)
+
+// Returned when a host service answers with a code other than 200
+type ServiceError struct {
+	Service string
+	Method  string
+	Code    uint
+	Message string
+
+	// Set when the call was throttled, i.e. with code 429
+	RetryAfter time.Duration
+}
+
+func (e *ServiceError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("host service %s.%s failed: %s (%d); retry after %s", e.Service, e.Method, e.Message, e.Code, e.RetryAfter)
+	}
+	return fmt.Sprintf("host service %s.%s failed: %s (%d)", e.Service, e.Method, e.Message, e.Code)
+}
+
+// Calls the host services enabled on the workload's node, relayed by the agent
+type HostServices struct {
+	w *Workload
+}
+
+// Calls a method of a host service, returning the data of its result
+func (h *HostServices) Call(ctx context.Context, service string, method string, payload []byte, metadata map[string]string) ([]byte, error) {
+	resp, err := h.w.request(ctx, &Message{
+		Kind:     KindHostService,
+		Service:  service,
+		Method:   method,
+		Metadata: metadata,
+		Payload:  payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Code != 200 {
+		return nil, &ServiceError{
+			Service:    service,
+			Method:     method,
+			Code:       resp.Code,
+			Message:    resp.Error,
+			RetryAfter: time.Duration(resp.RetryAfterMillis) * time.Millisecond,
+		}
+	}
+
+	return resp.Payload, nil
+}
+
+// Reads the value of a key in the workload's key-value bucket
+func (h *HostServices) KeyValueGet(ctx context.Context, key string) ([]byte, error) {
+	return h.Call(ctx, ServiceKeyValue, "get", nil, map[string]string{keyValueKeyHeader: key})
+}
+
+// Sets the value of a key in the workload's key-value bucket
+func (h *HostServices) KeyValueSet(ctx context.Context, key string, value []byte) error {
+	data, err := h.Call(ctx, ServiceKeyValue, "set", value, map[string]string{keyValueKeyHeader: key})
+	if err != nil {
+		return err
+	}
+	return resultErrors(data)
+}
+
+// Deletes a key from the workload's key-value bucket
+func (h *HostServices) KeyValueDelete(ctx context.Context, key string) error {
+	data, err := h.Call(ctx, ServiceKeyValue, "delete", nil, map[string]string{keyValueKeyHeader: key})
+	if err != nil {
+		return err
+	}
+	return resultErrors(data)
+}
+
+// Lists the keys of the workload's key-value bucket
+func (h *HostServices) KeyValueKeys(ctx context.Context) ([]string, error) {
+	data, err := h.Call(ctx, ServiceKeyValue, "keys", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	err = json.Unmarshal(data, &keys)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Publishes a message on the workload's NATS connection
+func (h *HostServices) Publish(ctx context.Context, subject string, payload []byte) error {
+	data, err := h.Call(ctx, ServiceMessaging, "publish", payload, map[string]string{messagingSubjectHeader: subject})
+	if err != nil {
+		return err
+	}
+	return resultErrors(data)
+}
+
+// Sends a request on the workload's NATS connection and returns the reply
+func (h *HostServices) Request(ctx context.Context, subject string, payload []byte) ([]byte, error) {
+	return h.Call(ctx, ServiceMessaging, "request", payload, map[string]string{messagingSubjectHeader: subject})
+}
+
+// Reads an object from the workload's object store bucket
+func (h *HostServices) ObjectGet(ctx context.Context, name string) ([]byte, error) {
+	return h.Call(ctx, ServiceObjectStore, "get", nil, map[string]string{objectNameHeader: name})
+}
+
+// Writes an object to the workload's object store bucket
+func (h *HostServices) ObjectPut(ctx context.Context, name string, data []byte) error {
+	_, err := h.Call(ctx, ServiceObjectStore, "put", data, map[string]string{objectNameHeader: name})
+	return err
+}
+
+// Deletes an object from the workload's object store bucket
+func (h *HostServices) ObjectDelete(ctx context.Context, name string) error {
+	data, err := h.Call(ctx, ServiceObjectStore, "delete", nil, map[string]string{objectNameHeader: name})
+	if err != nil {
+		return err
+	}
+	return resultErrors(data)
+}
+
+// Host services that change state answer with whether they succeeded and why not
+func resultErrors(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var result struct {
+		Errors []string `json:"errors,omitempty"`
+	}
+	if json.Unmarshal(data, &result) != nil || len(result.Errors) == 0 {
+		return nil
+	}
+
+	errs := make([]error, 0, len(result.Errors))
+	for _, e := range result.Errors {
+		errs = append(errs, errors.New(e))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,65 @@
+package sdk
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// Environment variables through which nex describes a native workload to itself
+const (
+	EnvWorkloadID      = "NEX_WORKLOAD_ID"
+	EnvWorkloadName    = "NEX_WORKLOAD_NAME"
+	EnvNamespace       = "NEX_NAMESPACE"
+	EnvNodeID          = "NEX_NODE_ID"
+	EnvNexus           = "NEX_NEXUS"
+	EnvTriggerSubjects = "NEX_TRIGGER_SUBJECTS"
+	EnvVolumePath      = "NEX_VOLUME_PATH"
+	EnvChannel         = "NEX_SDK_CHANNEL"
+)
+
+// Returned when the process was not started by nex, e.g. when a workload is run by hand
+var ErrNotInNex = errors.New("workload metadata not found; the process was not started by nex")
+
+// Describes the running workload and the node hosting it
+type Metadata struct {
+	WorkloadID   string
+	WorkloadName string
+	Namespace    string
+	NodeID       string
+	Nexus        string
+
+	// Subjects the workload's function is triggered by; empty for services
+	TriggerSubjects []string
+
+	// Directory holding the workload's persistent volume when the node runs without a sandbox;
+	// sandboxed workloads find their volume at the mount point they requested
+	VolumePath string
+}
+
+// Reads the workload's metadata from its environment
+func LoadMetadata() (*Metadata, error) {
+	md := &Metadata{
+		WorkloadID:   os.Getenv(EnvWorkloadID),
+		WorkloadName: os.Getenv(EnvWorkloadName),
+		Namespace:    os.Getenv(EnvNamespace),
+		NodeID:       os.Getenv(EnvNodeID),
+		Nexus:        os.Getenv(EnvNexus),
+		VolumePath:   os.Getenv(EnvVolumePath),
+	}
+
+	if md.WorkloadID == "" || md.WorkloadName == "" || md.Namespace == "" {
+		return nil, ErrNotInNex
+	}
+
+	if subjects := os.Getenv(EnvTriggerSubjects); subjects != "" {
+		md.TriggerSubjects = strings.Split(subjects, ",")
+	}
+
+	return md, nil
+}
+
+// Whether the workload was deployed as a function with trigger subjects
+func (md *Metadata) IsFunction() bool {
+	return len(md.TriggerSubjects) > 0
+}
@@ -0,0 +1,46 @@
+package sdk
+
+// The agent and a native workload exchange messages over a pair of pipes handed to the workload
+// as extra file descriptors, named by the channel environment variable as "{read},{write}" from
+// the workload's point of view. Each message is a JSON object on its own line. Either side may
+// send requests, numbered independently by each side, and answers each with exactly one result
+// bearing the request's ID. Kinds are:
+//
+//   - trigger: sent by the agent with a payload received on one of the workload's trigger subjects
+//   - trigger_result: the function's reply, or the error it failed with
+//   - host_service: sent by the workload to call a method of a host service
+//   - host_service_result: the host service's code, message and data
+const (
+	KindTrigger           = "trigger"
+	KindTriggerResult     = "trigger_result"
+	KindHostService       = "host_service"
+	KindHostServiceResult = "host_service_result"
+)
+
+// Message exchanged over the channel between the agent and a native workload
+type Message struct {
+	ID   uint64 `json:"id"`
+	Kind string `json:"kind"`
+
+	// Trigger subject the payload was received on, with the reply subject, message ID and headers
+	// of the triggering message. Trigger results carry the headers the function set on its reply
+	Subject string              `json:"subject,omitempty"`
+	Reply   string              `json:"reply,omitempty"`
+	MsgID   string              `json:"msg_id,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+
+	// Host service and method called, with the call's metadata
+	Service  string            `json:"service,omitempty"`
+	Method   string            `json:"method,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	Payload []byte `json:"payload,omitempty"`
+
+	// Host service result code, e.g. 200 on success or 429 when the call was throttled
+	Code uint `json:"code,omitempty"`
+
+	// Time to wait before calling a throttled host service again
+	RetryAfterMillis int64 `json:"retry_after_ms,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,255 @@
+// Code generated by witgen from nex-hostservices.wit. DO NOT EDIT.
+
+//! Guest bindings for the host services nex offers to wasm workloads, imported from the
+//! `nex:hostservices` module. Every function takes a timeout in milliseconds, where zero uses the
+//! agent's default.
+
+use std::fmt;
+
+/// Returned when a call fails
+#[derive(Debug, Clone)]
+pub struct Error {
+    /// 429 when the workload's budget is exhausted, 504 when the timeout passed
+    pub code: u32,
+    pub message: String,
+    /// Time to wait before calling again, when the call was throttled
+    pub retry_after_ms: u32,
+}
+
+impl fmt::Display for Error {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "host service call failed: {} ({})", self.message, self.code)
+    }
+}
+
+impl std::error::Error for Error {}
+
+fn check(code: u32) -> Result<Vec<u8>, Error> {
+    let len = unsafe { abi::result_len() };
+    let mut data = vec![0u8; len as usize];
+    if len > 0 {
+        unsafe { abi::result_read(data.as_mut_ptr(), len) };
+    }
+
+    if code == 200 {
+        return Ok(data);
+    }
+
+    Err(Error {
+        code,
+        message: String::from_utf8_lossy(&data).into_owned(),
+        retry_after_ms: if code == 429 { unsafe { abi::result_retry_after_ms() } } else { 0 },
+    })
+}
+
+#[allow(dead_code)]
+struct Decoder<'a> {
+    data: &'a [u8],
+}
+
+#[allow(dead_code)]
+impl<'a> Decoder<'a> {
+    fn read_u32(&mut self) -> u32 {
+        if self.data.len() < 4 {
+            self.data = &[];
+            return 0;
+        }
+        let (head, rest) = self.data.split_at(4);
+        self.data = rest;
+        u32::from_le_bytes([head[0], head[1], head[2], head[3]])
+    }
+
+    fn read_bytes(&mut self) -> Vec<u8> {
+        let len = (self.read_u32() as usize).min(self.data.len());
+        let (head, rest) = self.data.split_at(len);
+        self.data = rest;
+        head.to_vec()
+    }
+
+    fn read_string(&mut self) -> String {
+        String::from_utf8_lossy(&self.read_bytes()).into_owned()
+    }
+
+    fn read_strings(&mut self) -> Vec<String> {
+        let count = self.read_u32();
+        (0..count).map(|_| self.read_string()).collect()
+    }
+}
+
+/// Metadata of the message that triggered the function; headers are formatted as "name: value"
+#[derive(Debug, Clone)]
+pub struct TriggerMetadata {
+    pub subject: String,
+    pub reply: String,
+    pub msg_id: String,
+    pub headers: Vec<String>,
+}
+
+fn decode_trigger_metadata(d: &mut Decoder) -> TriggerMetadata {
+    TriggerMetadata {
+        subject: d.read_string(),
+        reply: d.read_string(),
+        msg_id: d.read_string(),
+        headers: d.read_strings(),
+    }
+}
+
+/// Response to an HTTP request; headers are formatted as "name: value"
+#[derive(Debug, Clone)]
+pub struct HttpResponse {
+    pub status: u32,
+    pub headers: Vec<String>,
+    pub body: Vec<u8>,
+}
+
+fn decode_http_response(d: &mut Decoder) -> HttpResponse {
+    HttpResponse {
+        status: d.read_u32(),
+        headers: d.read_strings(),
+        body: d.read_bytes(),
+    }
+}
+
+mod abi {
+    #[link(wasm_import_module = "nex:hostservices")]
+    extern "C" {
+        #[link_name = "result.len"]
+        pub fn result_len() -> u32;
+        #[link_name = "result.read"]
+        pub fn result_read(ptr: *mut u8, len: u32) -> u32;
+        #[link_name = "result.retry-after-ms"]
+        pub fn result_retry_after_ms() -> u32;
+        #[link_name = "trigger.get-metadata"]
+        pub fn trigger_get_metadata() -> u32;
+        #[link_name = "trigger.set-response-header"]
+        pub fn trigger_set_response_header(name_ptr: *const u8, name_len: u32, value_ptr: *const u8, value_len: u32) -> u32;
+        #[link_name = "key-value.get"]
+        pub fn key_value_get(key_ptr: *const u8, key_len: u32, timeout_ms: u32) -> u32;
+        #[link_name = "key-value.set"]
+        pub fn key_value_set(key_ptr: *const u8, key_len: u32, value_ptr: *const u8, value_len: u32, timeout_ms: u32) -> u32;
+        #[link_name = "key-value.delete"]
+        pub fn key_value_delete(key_ptr: *const u8, key_len: u32, timeout_ms: u32) -> u32;
+        #[link_name = "key-value.keys"]
+        pub fn key_value_keys(timeout_ms: u32) -> u32;
+        #[link_name = "messaging.publish"]
+        pub fn messaging_publish(subject_ptr: *const u8, subject_len: u32, payload_ptr: *const u8, payload_len: u32, timeout_ms: u32) -> u32;
+        #[link_name = "messaging.request"]
+        pub fn messaging_request(subject_ptr: *const u8, subject_len: u32, payload_ptr: *const u8, payload_len: u32, timeout_ms: u32) -> u32;
+        #[link_name = "object-store.get"]
+        pub fn object_store_get(name_ptr: *const u8, name_len: u32, timeout_ms: u32) -> u32;
+        #[link_name = "object-store.put"]
+        pub fn object_store_put(name_ptr: *const u8, name_len: u32, data_ptr: *const u8, data_len: u32, timeout_ms: u32) -> u32;
+        #[link_name = "object-store.delete"]
+        pub fn object_store_delete(name_ptr: *const u8, name_len: u32, timeout_ms: u32) -> u32;
+        #[link_name = "object-store.list"]
+        pub fn object_store_list(timeout_ms: u32) -> u32;
+        #[link_name = "http.request"]
+        pub fn http_request(method_ptr: *const u8, method_len: u32, url_ptr: *const u8, url_len: u32, body_ptr: *const u8, body_len: u32, timeout_ms: u32) -> u32;
+    }
+}
+
+pub mod trigger {
+    use super::*;
+
+    /// Returns the subject, reply subject, message ID and headers of the triggering message
+    pub fn get_metadata() -> Result<TriggerMetadata, Error> {
+        let code = unsafe { abi::trigger_get_metadata() };
+        let data = check(code)?;
+        let d = &mut Decoder { data: &data };
+        Ok(decode_trigger_metadata(d))
+    }
+
+    /// Sets a header on the function's reply, replacing any previous value
+    pub fn set_response_header(name: &str, value: &str) -> Result<(), Error> {
+        let code = unsafe { abi::trigger_set_response_header(name.as_ptr(), name.len() as u32, value.as_ptr(), value.len() as u32) };
+        check(code).map(|_| ())
+    }
+}
+
+pub mod key_value {
+    use super::*;
+
+    /// Reads the value of a key in the workload's bucket
+    pub fn get(key: &str, timeout_ms: u32) -> Result<Vec<u8>, Error> {
+        let code = unsafe { abi::key_value_get(key.as_ptr(), key.len() as u32, timeout_ms) };
+        check(code)
+    }
+
+    /// Sets the value of a key in the workload's bucket
+    pub fn set(key: &str, value: &[u8], timeout_ms: u32) -> Result<(), Error> {
+        let code = unsafe { abi::key_value_set(key.as_ptr(), key.len() as u32, value.as_ptr(), value.len() as u32, timeout_ms) };
+        check(code).map(|_| ())
+    }
+
+    /// Deletes a key from the workload's bucket
+    pub fn delete(key: &str, timeout_ms: u32) -> Result<(), Error> {
+        let code = unsafe { abi::key_value_delete(key.as_ptr(), key.len() as u32, timeout_ms) };
+        check(code).map(|_| ())
+    }
+
+    /// Lists the keys of the workload's bucket
+    pub fn keys(timeout_ms: u32) -> Result<Vec<String>, Error> {
+        let code = unsafe { abi::key_value_keys(timeout_ms) };
+        let data = check(code)?;
+        let d = &mut Decoder { data: &data };
+        Ok(d.read_strings())
+    }
+}
+
+pub mod messaging {
+    use super::*;
+
+    /// Publishes a message
+    pub fn publish(subject: &str, payload: &[u8], timeout_ms: u32) -> Result<(), Error> {
+        let code = unsafe { abi::messaging_publish(subject.as_ptr(), subject.len() as u32, payload.as_ptr(), payload.len() as u32, timeout_ms) };
+        check(code).map(|_| ())
+    }
+
+    /// Sends a request and returns the reply
+    pub fn request(subject: &str, payload: &[u8], timeout_ms: u32) -> Result<Vec<u8>, Error> {
+        let code = unsafe { abi::messaging_request(subject.as_ptr(), subject.len() as u32, payload.as_ptr(), payload.len() as u32, timeout_ms) };
+        check(code)
+    }
+}
+
+pub mod object_store {
+    use super::*;
+
+    /// Reads an object from the workload's object store
+    pub fn get(name: &str, timeout_ms: u32) -> Result<Vec<u8>, Error> {
+        let code = unsafe { abi::object_store_get(name.as_ptr(), name.len() as u32, timeout_ms) };
+        check(code)
+    }
+
+    /// Writes an object to the workload's object store
+    pub fn put(name: &str, data: &[u8], timeout_ms: u32) -> Result<(), Error> {
+        let code = unsafe { abi::object_store_put(name.as_ptr(), name.len() as u32, data.as_ptr(), data.len() as u32, timeout_ms) };
+        check(code).map(|_| ())
+    }
+
+    /// Deletes an object from the workload's object store
+    pub fn delete(name: &str, timeout_ms: u32) -> Result<(), Error> {
+        let code = unsafe { abi::object_store_delete(name.as_ptr(), name.len() as u32, timeout_ms) };
+        check(code).map(|_| ())
+    }
+
+    /// Lists the names of the objects in the workload's object store
+    pub fn list(timeout_ms: u32) -> Result<Vec<String>, Error> {
+        let code = unsafe { abi::object_store_list(timeout_ms) };
+        let data = check(code)?;
+        let d = &mut Decoder { data: &data };
+        Ok(d.read_strings())
+    }
+}
+
+pub mod http {
+    use super::*;
+
+    /// Sends an HTTP request with the given method, e.g. "get" or "post"
+    pub fn request(method: &str, url: &str, body: &[u8], timeout_ms: u32) -> Result<HttpResponse, Error> {
+        let code = unsafe { abi::http_request(method.as_ptr(), method.len() as u32, url.as_ptr(), url.len() as u32, body.as_ptr(), body.len() as u32, timeout_ms) };
+        let data = check(code)?;
+        let d = &mut Decoder { data: &data };
+        Ok(decode_http_response(d))
+    }
+}
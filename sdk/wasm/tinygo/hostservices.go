@@ -0,0 +1,258 @@
+//go:build tinygo || wasip1
+
+// Code generated by witgen from nex-hostservices.wit. DO NOT EDIT.
+
+// Package hostservices calls the host services nex offers to wasm workloads, imported from the
+// nex:hostservices module. Every function takes a timeout in milliseconds, where zero uses the agent's
+// default
+package hostservices
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+)
+
+// Returned when a call fails
+type Error struct {
+	// 429 when the workload's budget is exhausted, 504 when the timeout passed
+	Code    uint32
+	Message string
+
+	// Time to wait before calling again, when the call was throttled
+	RetryAfterMs uint32
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("host service call failed: %s (%d)", e.Message, e.Code)
+}
+
+//go:wasmimport nex:hostservices result.len
+func resultLen() uint32
+
+//go:wasmimport nex:hostservices result.read
+func resultRead(ptr unsafe.Pointer, size uint32) uint32
+
+//go:wasmimport nex:hostservices result.retry-after-ms
+func resultRetryAfterMs() uint32
+
+func check(code uint32) ([]byte, error) {
+	size := resultLen()
+	data := make([]byte, size)
+	if size > 0 {
+		resultRead(unsafe.Pointer(&data[0]), size)
+	}
+
+	if code == 200 {
+		return data, nil
+	}
+
+	err := &Error{Code: code, Message: string(data)}
+	if code == 429 {
+		err.RetryAfterMs = resultRetryAfterMs()
+	}
+	return nil, err
+}
+
+type decoder struct {
+	data []byte
+}
+
+func (d *decoder) readU32() uint32 {
+	if len(d.data) < 4 {
+		d.data = nil
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(d.data)
+	d.data = d.data[4:]
+	return v
+}
+
+func (d *decoder) readBytes() []byte {
+	size := int(d.readU32())
+	if size > len(d.data) {
+		size = len(d.data)
+	}
+	v := append([]byte(nil), d.data[:size]...)
+	d.data = d.data[size:]
+	return v
+}
+
+func (d *decoder) readString() string {
+	return string(d.readBytes())
+}
+
+func (d *decoder) readStrings() []string {
+	count := d.readU32()
+	v := make([]string, 0, count)
+	for i := uint32(0); i < count; i++ {
+		v = append(v, d.readString())
+	}
+	return v
+}
+
+// Metadata of the message that triggered the function; headers are formatted as "name: value"
+type TriggerMetadata struct {
+	Subject string
+	Reply   string
+	MsgId   string
+	Headers []string
+}
+
+func decodeTriggerMetadata(d *decoder) *TriggerMetadata {
+	return &TriggerMetadata{
+		Subject: d.readString(),
+		Reply:   d.readString(),
+		MsgId:   d.readString(),
+		Headers: d.readStrings(),
+	}
+}
+
+// Response to an HTTP request; headers are formatted as "name: value"
+type HttpResponse struct {
+	Status  uint32
+	Headers []string
+	Body    []byte
+}
+
+func decodeHttpResponse(d *decoder) *HttpResponse {
+	return &HttpResponse{
+		Status:  d.readU32(),
+		Headers: d.readStrings(),
+		Body:    d.readBytes(),
+	}
+}
+
+//go:wasmimport nex:hostservices trigger.get-metadata
+func triggerGetMetadata() uint32
+
+// Returns the subject, reply subject, message ID and headers of the triggering message
+func TriggerGetMetadata() (*TriggerMetadata, error) {
+	data, err := check(triggerGetMetadata())
+	if err != nil {
+		return nil, err
+	}
+
+	d := &decoder{data: data}
+	return decodeTriggerMetadata(d), nil
+}
+
+//go:wasmimport nex:hostservices trigger.set-response-header
+func triggerSetResponseHeader(namePtr unsafe.Pointer, nameLen uint32, valuePtr unsafe.Pointer, valueLen uint32) uint32
+
+// Sets a header on the function's reply, replacing any previous value
+func TriggerSetResponseHeader(name string, value string) error {
+	_, err := check(triggerSetResponseHeader(unsafe.Pointer(unsafe.StringData(name)), uint32(len(name)), unsafe.Pointer(unsafe.StringData(value)), uint32(len(value))))
+	return err
+}
+
+//go:wasmimport nex:hostservices key-value.get
+func keyValueGet(keyPtr unsafe.Pointer, keyLen uint32, timeoutMs uint32) uint32
+
+// Reads the value of a key in the workload's bucket
+func KeyValueGet(key string, timeoutMs uint32) ([]byte, error) {
+	return check(keyValueGet(unsafe.Pointer(unsafe.StringData(key)), uint32(len(key)), timeoutMs))
+}
+
+//go:wasmimport nex:hostservices key-value.set
+func keyValueSet(keyPtr unsafe.Pointer, keyLen uint32, valuePtr unsafe.Pointer, valueLen uint32, timeoutMs uint32) uint32
+
+// Sets the value of a key in the workload's bucket
+func KeyValueSet(key string, value []byte, timeoutMs uint32) error {
+	_, err := check(keyValueSet(unsafe.Pointer(unsafe.StringData(key)), uint32(len(key)), unsafe.Pointer(unsafe.SliceData(value)), uint32(len(value)), timeoutMs))
+	return err
+}
+
+//go:wasmimport nex:hostservices key-value.delete
+func keyValueDelete(keyPtr unsafe.Pointer, keyLen uint32, timeoutMs uint32) uint32
+
+// Deletes a key from the workload's bucket
+func KeyValueDelete(key string, timeoutMs uint32) error {
+	_, err := check(keyValueDelete(unsafe.Pointer(unsafe.StringData(key)), uint32(len(key)), timeoutMs))
+	return err
+}
+
+//go:wasmimport nex:hostservices key-value.keys
+func keyValueKeys(timeoutMs uint32) uint32
+
+// Lists the keys of the workload's bucket
+func KeyValueKeys(timeoutMs uint32) ([]string, error) {
+	data, err := check(keyValueKeys(timeoutMs))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &decoder{data: data}
+	return d.readStrings(), nil
+}
+
+//go:wasmimport nex:hostservices messaging.publish
+func messagingPublish(subjectPtr unsafe.Pointer, subjectLen uint32, payloadPtr unsafe.Pointer, payloadLen uint32, timeoutMs uint32) uint32
+
+// Publishes a message
+func MessagingPublish(subject string, payload []byte, timeoutMs uint32) error {
+	_, err := check(messagingPublish(unsafe.Pointer(unsafe.StringData(subject)), uint32(len(subject)), unsafe.Pointer(unsafe.SliceData(payload)), uint32(len(payload)), timeoutMs))
+	return err
+}
+
+//go:wasmimport nex:hostservices messaging.request
+func messagingRequest(subjectPtr unsafe.Pointer, subjectLen uint32, payloadPtr unsafe.Pointer, payloadLen uint32, timeoutMs uint32) uint32
+
+// Sends a request and returns the reply
+func MessagingRequest(subject string, payload []byte, timeoutMs uint32) ([]byte, error) {
+	return check(messagingRequest(unsafe.Pointer(unsafe.StringData(subject)), uint32(len(subject)), unsafe.Pointer(unsafe.SliceData(payload)), uint32(len(payload)), timeoutMs))
+}
+
+//go:wasmimport nex:hostservices object-store.get
+func objectStoreGet(namePtr unsafe.Pointer, nameLen uint32, timeoutMs uint32) uint32
+
+// Reads an object from the workload's object store
+func ObjectStoreGet(name string, timeoutMs uint32) ([]byte, error) {
+	return check(objectStoreGet(unsafe.Pointer(unsafe.StringData(name)), uint32(len(name)), timeoutMs))
+}
+
+//go:wasmimport nex:hostservices object-store.put
+func objectStorePut(namePtr unsafe.Pointer, nameLen uint32, dataPtr unsafe.Pointer, dataLen uint32, timeoutMs uint32) uint32
+
+// Writes an object to the workload's object store
+func ObjectStorePut(name string, data []byte, timeoutMs uint32) error {
+	_, err := check(objectStorePut(unsafe.Pointer(unsafe.StringData(name)), uint32(len(name)), unsafe.Pointer(unsafe.SliceData(data)), uint32(len(data)), timeoutMs))
+	return err
+}
+
+//go:wasmimport nex:hostservices object-store.delete
+func objectStoreDelete(namePtr unsafe.Pointer, nameLen uint32, timeoutMs uint32) uint32
+
+// Deletes an object from the workload's object store
+func ObjectStoreDelete(name string, timeoutMs uint32) error {
+	_, err := check(objectStoreDelete(unsafe.Pointer(unsafe.StringData(name)), uint32(len(name)), timeoutMs))
+	return err
+}
+
+//go:wasmimport nex:hostservices object-store.list
+func objectStoreList(timeoutMs uint32) uint32
+
+// Lists the names of the objects in the workload's object store
+func ObjectStoreList(timeoutMs uint32) ([]string, error) {
+	data, err := check(objectStoreList(timeoutMs))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &decoder{data: data}
+	return d.readStrings(), nil
+}
+
+//go:wasmimport nex:hostservices http.request
+func httpRequest(methodPtr unsafe.Pointer, methodLen uint32, urlPtr unsafe.Pointer, urlLen uint32, bodyPtr unsafe.Pointer, bodyLen uint32, timeoutMs uint32) uint32
+
+// Sends an HTTP request with the given method, e.g. "get" or "post"
+func HttpRequest(method string, url string, body []byte, timeoutMs uint32) (*HttpResponse, error) {
+	data, err := check(httpRequest(unsafe.Pointer(unsafe.StringData(method)), uint32(len(method)), unsafe.Pointer(unsafe.StringData(url)), uint32(len(url)), unsafe.Pointer(unsafe.SliceData(body)), uint32(len(body)), timeoutMs))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &decoder{data: data}
+	return decodeHttpResponse(d), nil
+}
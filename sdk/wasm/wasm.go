@@ -0,0 +1,15 @@
+// Package wasm holds the WIT definition of the host services offered to wasm workloads, from
+// which the Rust and TinyGo guest SDKs beneath it are generated
+package wasm
+
+import _ "embed"
+
+//go:generate go run ./witgen -wit nex-hostservices.wit -rust rust/src/lib.rs -tinygo tinygo/hostservices.go
+
+// Import module from which wasm workloads import the host service functions
+const ImportModule = "nex:hostservices"
+
+// WIT definition of the host services
+//
+//go:embed nex-hostservices.wit
+var WIT string
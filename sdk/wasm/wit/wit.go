@@ -0,0 +1,409 @@
+// Package wit parses the subset of the WebAssembly Interface Type language used to define the
+// host services offered to wasm workloads: interfaces of records and functions, and worlds
+// importing them. Resources, variants, enums and flags are not supported
+package wit
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Parsed WIT package
+type Package struct {
+	Name       string
+	Interfaces []*Interface
+	Worlds     []*World
+}
+
+type Interface struct {
+	Name    string
+	Records []*Record
+	Funcs   []*Func
+}
+
+type Record struct {
+	Name   string
+	Doc    string
+	Fields []*Field
+}
+
+type Field struct {
+	Name string
+	Type *Type
+}
+
+type Func struct {
+	Name   string
+	Doc    string
+	Params []*Field
+
+	// nil when the function returns nothing
+	Result *Type
+}
+
+type World struct {
+	Name    string
+	Imports []string
+}
+
+// Type reference, e.g. "u32", "list<u8>", "result<_, error>" or the name of a record. Name is
+// "_" for the unit type
+type Type struct {
+	Name   string
+	Params []*Type
+}
+
+func (t *Type) String() string {
+	if len(t.Params) == 0 {
+		return t.Name
+	}
+
+	params := make([]string, 0, len(t.Params))
+	for _, p := range t.Params {
+		params = append(params, p.String())
+	}
+	return fmt.Sprintf("%s<%s>", t.Name, strings.Join(params, ", "))
+}
+
+// Returns whether the type is list<elem>
+func (t *Type) IsList(elem string) bool {
+	return t.Name == "list" && len(t.Params) == 1 && t.Params[0].Name == elem
+}
+
+// Returns the record of the given name from any of the package's interfaces
+func (p *Package) Record(name string) *Record {
+	for _, iface := range p.Interfaces {
+		for _, record := range iface.Records {
+			if record.Name == name {
+				return record
+			}
+		}
+	}
+	return nil
+}
+
+// Parses a WIT package
+func Parse(src string) (*Package, error) {
+	p := &parser{tokens: tokenize(src)}
+
+	pkg, err := p.parsePackage()
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %s", p.line(), err)
+	}
+	return pkg, nil
+}
+
+type token struct {
+	text string
+	doc  bool
+	line int
+}
+
+// Splits the source into identifiers, punctuation and doc comments, dropping other comments
+func tokenize(src string) []token {
+	tokens := []token{}
+
+	for i, line := range strings.Split(src, "\n") {
+		for line != "" {
+			trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+			if trimmed == "" {
+				break
+			}
+			line = trimmed
+
+			switch {
+			case strings.HasPrefix(line, "///"):
+				tokens = append(tokens, token{text: strings.TrimSpace(line[3:]), doc: true, line: i + 1})
+				line = ""
+			case strings.HasPrefix(line, "//"):
+				line = ""
+			case strings.HasPrefix(line, "->"):
+				tokens = append(tokens, token{text: "->", line: i + 1})
+				line = line[2:]
+			case strings.ContainsRune("{}()<>,;:=.", rune(line[0])):
+				tokens = append(tokens, token{text: line[:1], line: i + 1})
+				line = line[1:]
+			default:
+				end := strings.IndexFunc(line, func(r rune) bool {
+					return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '@')
+				})
+				if end == 0 {
+					// unknown character, kept as its own token so the parser reports it
+					end = 1
+				} else if end < 0 {
+					end = len(line)
+				}
+				tokens = append(tokens, token{text: line[:end], line: i + 1})
+				line = line[end:]
+			}
+		}
+	}
+
+	return tokens
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	docs   []string
+}
+
+func (p *parser) line() int {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].line
+	}
+	if len(p.tokens) > 0 {
+		return p.tokens[len(p.tokens)-1].line
+	}
+	return 0
+}
+
+// Returns the next token that is not a doc comment, collecting doc comments on the way
+func (p *parser) peek() string {
+	for p.pos < len(p.tokens) && p.tokens[p.pos].doc {
+		p.docs = append(p.docs, p.tokens[p.pos].text)
+		p.pos++
+	}
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+func (p *parser) next() string {
+	text := p.peek()
+	if text != "" {
+		p.pos++
+	}
+	return text
+}
+
+func (p *parser) expect(text string) error {
+	if got := p.next(); got != text {
+		return fmt.Errorf("expected %q, found %q", text, got)
+	}
+	return nil
+}
+
+// Returns the doc comments collected since the last call
+func (p *parser) takeDoc() string {
+	doc := strings.Join(p.docs, " ")
+	p.docs = nil
+	return doc
+}
+
+func (p *parser) parsePackage() (*Package, error) {
+	pkg := &Package{}
+
+	for p.peek() != "" {
+		p.takeDoc()
+
+		switch keyword := p.next(); keyword {
+		case "package":
+			var name strings.Builder
+			for p.peek() != ";" && p.peek() != "" {
+				name.WriteString(p.next())
+			}
+			pkg.Name = name.String()
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		case "interface":
+			iface, err := p.parseInterface()
+			if err != nil {
+				return nil, err
+			}
+			pkg.Interfaces = append(pkg.Interfaces, iface)
+		case "world":
+			world, err := p.parseWorld()
+			if err != nil {
+				return nil, err
+			}
+			pkg.Worlds = append(pkg.Worlds, world)
+		default:
+			return nil, fmt.Errorf("unexpected %q", keyword)
+		}
+	}
+
+	if pkg.Name == "" {
+		return nil, fmt.Errorf("missing package declaration")
+	}
+
+	return pkg, nil
+}
+
+func (p *parser) parseInterface() (*Interface, error) {
+	iface := &Interface{Name: p.next()}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	for p.peek() != "}" {
+		switch p.peek() {
+		case "":
+			return nil, fmt.Errorf("unterminated interface %s", iface.Name)
+		case "use":
+			// types are resolved by name across the package
+			for p.next() != ";" {
+				if p.peek() == "" {
+					return nil, fmt.Errorf("unterminated use in interface %s", iface.Name)
+				}
+			}
+			p.takeDoc()
+		case "record":
+			p.next()
+			record, err := p.parseRecord()
+			if err != nil {
+				return nil, err
+			}
+			iface.Records = append(iface.Records, record)
+		default:
+			fn, err := p.parseFunc()
+			if err != nil {
+				return nil, err
+			}
+			iface.Funcs = append(iface.Funcs, fn)
+		}
+	}
+	p.next()
+
+	return iface, nil
+}
+
+func (p *parser) parseRecord() (*Record, error) {
+	record := &Record{Doc: p.takeDoc(), Name: p.next()}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	for p.peek() != "}" {
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		record.Fields = append(record.Fields, field)
+
+		if p.peek() == "," {
+			p.next()
+		}
+	}
+	p.next()
+	p.takeDoc()
+
+	return record, nil
+}
+
+func (p *parser) parseFunc() (*Func, error) {
+	fn := &Func{Doc: p.takeDoc(), Name: p.next()}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if err := p.expect("func"); err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	for p.peek() != ")" {
+		param, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fn.Params = append(fn.Params, param)
+
+		if p.peek() == "," {
+			p.next()
+		}
+	}
+	p.next()
+
+	if p.peek() == "->" {
+		p.next()
+		result, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		fn.Result = result
+	}
+
+	if err := p.expect(";"); err != nil {
+		return nil, err
+	}
+
+	return fn, nil
+}
+
+func (p *parser) parseField() (*Field, error) {
+	name := p.next()
+	if name == "" {
+		return nil, fmt.Errorf("unexpected end of input")
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+
+	typ, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Field{Name: name, Type: typ}, nil
+}
+
+func (p *parser) parseType() (*Type, error) {
+	name := p.next()
+	if name == "" || strings.ContainsAny(name, "{}()<>,;:=.") {
+		return nil, fmt.Errorf("expected a type, found %q", name)
+	}
+
+	typ := &Type{Name: name}
+	if p.peek() != "<" {
+		return typ, nil
+	}
+	p.next()
+
+	for {
+		param, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		typ.Params = append(typ.Params, param)
+
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+
+	if err := p.expect(">"); err != nil {
+		return nil, err
+	}
+
+	return typ, nil
+}
+
+func (p *parser) parseWorld() (*World, error) {
+	world := &World{Name: p.next()}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	for p.peek() != "}" {
+		switch keyword := p.next(); keyword {
+		case "import":
+			world.Imports = append(world.Imports, p.next())
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		case "":
+			return nil, fmt.Errorf("unterminated world %s", world.Name)
+		default:
+			return nil, fmt.Errorf("unsupported %q in world %s", keyword, world.Name)
+		}
+	}
+	p.next()
+
+	return world, nil
+}
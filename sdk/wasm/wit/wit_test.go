@@ -0,0 +1,66 @@
+package wit
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	pkg, err := Parse(`
+// comment
+package nex:test@0.1.0;
+
+interface types {
+  /// A record
+  record pair {
+    left: string,
+    right: list<u8>,
+  }
+}
+
+interface store {
+  use types.{pair};
+
+  /// Reads a pair
+  get: func(key: string, timeout-ms: u32) -> result<pair, error>;
+  reset: func();
+}
+
+world workload {
+  import store;
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pkg.Name != "nex:test@0.1.0" {
+		t.Fatalf("Unexpected package name: %s", pkg.Name)
+	}
+
+	record := pkg.Record("pair")
+	if record == nil || record.Doc != "A record" || len(record.Fields) != 2 || !record.Fields[1].Type.IsList("u8") {
+		t.Fatalf("Unexpected record: %+v", record)
+	}
+
+	store := pkg.Interfaces[1]
+	if store.Name != "store" || len(store.Funcs) != 2 {
+		t.Fatalf("Unexpected interface: %+v", store)
+	}
+
+	get := store.Funcs[0]
+	if get.Doc != "Reads a pair" || len(get.Params) != 2 || get.Params[1].Name != "timeout-ms" || get.Result.String() != "result<pair, error>" {
+		t.Fatalf("Unexpected function: %+v", get)
+	}
+	if store.Funcs[1].Result != nil {
+		t.Fatal("Expected reset to return nothing")
+	}
+
+	if len(pkg.Worlds) != 1 || pkg.Worlds[0].Imports[0] != "store" {
+		t.Fatalf("Unexpected worlds: %+v", pkg.Worlds)
+	}
+}
+
+func TestParseReportsLine(t *testing.T) {
+	_, err := Parse("package a:b;\n\ninterface x {\n  get: func(key string);\n}\n")
+	if err == nil || err.Error()[:6] != "line 4" {
+		t.Fatalf("Expected an error on line 4, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+// Generates the Rust and TinyGo guest SDKs for the host services offered to wasm workloads from
+// their WIT definition
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/synadia-io/nex/sdk/wasm/wit"
+)
+
+// Import module of the host services, as implemented by the agent
+const importModule = "nex:hostservices"
+
+func main() {
+	witPath := flag.String("wit", "", "WIT definition to generate from")
+	rustPath := flag.String("rust", "", "Rust source file to write")
+	tinygoPath := flag.String("tinygo", "", "TinyGo source file to write")
+	flag.Parse()
+
+	if *witPath == "" || (*rustPath == "" && *tinygoPath == "") {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	err := run(*witPath, *rustPath, *tinygoPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "witgen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(witPath, rustPath, tinygoPath string) error {
+	src, err := os.ReadFile(witPath)
+	if err != nil {
+		return err
+	}
+
+	pkg, err := wit.Parse(string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %s", witPath, err)
+	}
+
+	source := filepath.Base(witPath)
+
+	if rustPath != "" {
+		out, err := generateRust(pkg, source)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(rustPath, []byte(out), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	if tinygoPath != "" {
+		out, err := generateTinyGo(pkg, source)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(tinygoPath, []byte(out), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the functions of the interfaces imported by the package's worlds, in import order
+func importedFuncs(pkg *wit.Package) ([]*wit.Interface, error) {
+	interfaces := []*wit.Interface{}
+	seen := make(map[string]bool)
+
+	for _, world := range pkg.Worlds {
+		for _, name := range world.Imports {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			var iface *wit.Interface
+			for _, candidate := range pkg.Interfaces {
+				if candidate.Name == name {
+					iface = candidate
+				}
+			}
+			if iface == nil {
+				return nil, fmt.Errorf("world %s imports unknown interface %s", world.Name, name)
+			}
+
+			for _, fn := range iface.Funcs {
+				err := checkFunc(pkg, iface, fn)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			interfaces = append(interfaces, iface)
+		}
+	}
+
+	return interfaces, nil
+}
+
+// Ensures the function only uses types the core ABI lowers
+func checkFunc(pkg *wit.Package, iface *wit.Interface, fn *wit.Func) error {
+	for _, param := range fn.Params {
+		if param.Type.Name != "string" && param.Type.Name != "u32" && !param.Type.IsList("u8") {
+			return fmt.Errorf("%s.%s: unsupported parameter type %s", iface.Name, fn.Name, param.Type)
+		}
+	}
+
+	if fn.Result == nil || fn.Result.Name != "result" || len(fn.Result.Params) != 2 || fn.Result.Params[1].Name != "error" {
+		return fmt.Errorf("%s.%s: functions must return result<_, error>", iface.Name, fn.Name)
+	}
+
+	return checkValueType(pkg, fn.Result.Params[0], fmt.Sprintf("%s.%s", iface.Name, fn.Name))
+}
+
+func checkValueType(pkg *wit.Package, t *wit.Type, where string) error {
+	switch {
+	case t.Name == "_", t.Name == "string", t.Name == "u32", t.IsList("u8"), t.IsList("string"):
+		return nil
+	}
+
+	record := pkg.Record(t.Name)
+	if record == nil || len(t.Params) > 0 {
+		return fmt.Errorf("%s: unsupported type %s", where, t)
+	}
+
+	for _, field := range record.Fields {
+		if field.Type.Name == "_" {
+			return fmt.Errorf("%s: unsupported field type %s", where, field.Type)
+		}
+		err := checkValueType(pkg, field.Type, where)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Records used by the results of the imported functions, in order of first use
+func usedRecords(pkg *wit.Package, interfaces []*wit.Interface) []*wit.Record {
+	records := []*wit.Record{}
+	seen := make(map[string]bool)
+
+	var visit func(t *wit.Type)
+	visit = func(t *wit.Type) {
+		record := pkg.Record(t.Name)
+		if record == nil || seen[record.Name] {
+			return
+		}
+		seen[record.Name] = true
+		records = append(records, record)
+
+		for _, field := range record.Fields {
+			visit(field.Type)
+		}
+	}
+
+	for _, iface := range interfaces {
+		for _, fn := range iface.Funcs {
+			visit(fn.Result.Params[0])
+		}
+	}
+
+	return records
+}
+
+// Name under which a function is imported from the host services module
+func importName(iface *wit.Interface, fn *wit.Func) string {
+	return iface.Name + "." + fn.Name
+}
+
+// Converts a kebab-case WIT name to snake_case
+func snake(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// Converts a kebab-case WIT name to PascalCase
+func pascal(name string) string {
+	parts := strings.Split(name, "-")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// Converts a kebab-case WIT name to camelCase
+func camel(name string) string {
+	p := pascal(name)
+	if p == "" {
+		return p
+	}
+	return strings.ToLower(p[:1]) + p[1:]
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/synadia-io/nex/sdk/wasm/wit"
+)
+
+const rustPrelude = `//! Guest bindings for the host services nex offers to wasm workloads, imported from the
+//! ` + "`%s`" + ` module. Every function takes a timeout in milliseconds, where zero uses the
+//! agent's default.
+
+use std::fmt;
+
+/// Returned when a call fails
+#[derive(Debug, Clone)]
+pub struct Error {
+    /// 429 when the workload's budget is exhausted, 504 when the timeout passed
+    pub code: u32,
+    pub message: String,
+    /// Time to wait before calling again, when the call was throttled
+    pub retry_after_ms: u32,
+}
+
+impl fmt::Display for Error {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "host service call failed: {} ({})", self.message, self.code)
+    }
+}
+
+impl std::error::Error for Error {}
+
+fn check(code: u32) -> Result<Vec<u8>, Error> {
+    let len = unsafe { abi::result_len() };
+    let mut data = vec![0u8; len as usize];
+    if len > 0 {
+        unsafe { abi::result_read(data.as_mut_ptr(), len) };
+    }
+
+    if code == 200 {
+        return Ok(data);
+    }
+
+    Err(Error {
+        code,
+        message: String::from_utf8_lossy(&data).into_owned(),
+        retry_after_ms: if code == 429 { unsafe { abi::result_retry_after_ms() } } else { 0 },
+    })
+}
+
+#[allow(dead_code)]
+struct Decoder<'a> {
+    data: &'a [u8],
+}
+
+#[allow(dead_code)]
+impl<'a> Decoder<'a> {
+    fn read_u32(&mut self) -> u32 {
+        if self.data.len() < 4 {
+            self.data = &[];
+            return 0;
+        }
+        let (head, rest) = self.data.split_at(4);
+        self.data = rest;
+        u32::from_le_bytes([head[0], head[1], head[2], head[3]])
+    }
+
+    fn read_bytes(&mut self) -> Vec<u8> {
+        let len = (self.read_u32() as usize).min(self.data.len());
+        let (head, rest) = self.data.split_at(len);
+        self.data = rest;
+        head.to_vec()
+    }
+
+    fn read_string(&mut self) -> String {
+        String::from_utf8_lossy(&self.read_bytes()).into_owned()
+    }
+
+    fn read_strings(&mut self) -> Vec<String> {
+        let count = self.read_u32();
+        (0..count).map(|_| self.read_string()).collect()
+    }
+}
+`
+
+func generateRust(pkg *wit.Package, source string) (string, error) {
+	interfaces, err := importedFuncs(pkg)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Code generated by witgen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&sb, rustPrelude, importModule)
+
+	for _, record := range usedRecords(pkg, interfaces) {
+		sb.WriteString("\n")
+		if record.Doc != "" {
+			fmt.Fprintf(&sb, "/// %s\n", record.Doc)
+		}
+		fmt.Fprintf(&sb, "#[derive(Debug, Clone)]\npub struct %s {\n", pascal(record.Name))
+		for _, field := range record.Fields {
+			fmt.Fprintf(&sb, "    pub %s: %s,\n", snake(field.Name), rustType(field.Type))
+		}
+		sb.WriteString("}\n\n")
+
+		fmt.Fprintf(&sb, "fn decode_%s(d: &mut Decoder) -> %s {\n", snake(record.Name), pascal(record.Name))
+		fmt.Fprintf(&sb, "    %s {\n", pascal(record.Name))
+		for _, field := range record.Fields {
+			fmt.Fprintf(&sb, "        %s: %s,\n", snake(field.Name), rustDecode(field.Type))
+		}
+		sb.WriteString("    }\n}\n")
+	}
+
+	sb.WriteString("\nmod abi {\n")
+	fmt.Fprintf(&sb, "    #[link(wasm_import_module = %q)]\n", importModule)
+	sb.WriteString("    extern \"C\" {\n")
+	sb.WriteString("        #[link_name = \"result.len\"]\n        pub fn result_len() -> u32;\n")
+	sb.WriteString("        #[link_name = \"result.read\"]\n        pub fn result_read(ptr: *mut u8, len: u32) -> u32;\n")
+	sb.WriteString("        #[link_name = \"result.retry-after-ms\"]\n        pub fn result_retry_after_ms() -> u32;\n")
+	for _, iface := range interfaces {
+		for _, fn := range iface.Funcs {
+			params := []string{}
+			for _, param := range fn.Params {
+				name := snake(param.Name)
+				if param.Type.Name == "u32" {
+					params = append(params, name+": u32")
+				} else {
+					params = append(params, name+"_ptr: *const u8", name+"_len: u32")
+				}
+			}
+
+			fmt.Fprintf(&sb, "        #[link_name = %q]\n", importName(iface, fn))
+			fmt.Fprintf(&sb, "        pub fn %s_%s(%s) -> u32;\n", snake(iface.Name), snake(fn.Name), strings.Join(params, ", "))
+		}
+	}
+	sb.WriteString("    }\n}\n")
+
+	for _, iface := range interfaces {
+		fmt.Fprintf(&sb, "\npub mod %s {\n    use super::*;\n", snake(iface.Name))
+
+		for _, fn := range iface.Funcs {
+			params := []string{}
+			args := []string{}
+			for _, param := range fn.Params {
+				name := snake(param.Name)
+				switch param.Type.Name {
+				case "u32":
+					params = append(params, name+": u32")
+					args = append(args, name)
+				case "string":
+					params = append(params, name+": &str")
+					args = append(args, name+".as_ptr()", name+".len() as u32")
+				default:
+					params = append(params, name+": &[u8]")
+					args = append(args, name+".as_ptr()", name+".len() as u32")
+				}
+			}
+
+			value := fn.Result.Params[0]
+
+			sb.WriteString("\n")
+			if fn.Doc != "" {
+				fmt.Fprintf(&sb, "    /// %s\n", fn.Doc)
+			}
+			fmt.Fprintf(&sb, "    pub fn %s(%s) -> Result<%s, Error> {\n", snake(fn.Name), strings.Join(params, ", "), rustType(value))
+			fmt.Fprintf(&sb, "        let code = unsafe { abi::%s_%s(%s) };\n", snake(iface.Name), snake(fn.Name), strings.Join(args, ", "))
+
+			switch {
+			case value.Name == "_":
+				sb.WriteString("        check(code).map(|_| ())\n")
+			case value.IsList("u8"):
+				sb.WriteString("        check(code)\n")
+			case value.Name == "string":
+				sb.WriteString("        check(code).map(|data| String::from_utf8_lossy(&data).into_owned())\n")
+			default:
+				sb.WriteString("        let data = check(code)?;\n")
+				sb.WriteString("        let d = &mut Decoder { data: &data };\n")
+				fmt.Fprintf(&sb, "        Ok(%s)\n", rustDecode(value))
+			}
+			sb.WriteString("    }\n")
+		}
+
+		sb.WriteString("}\n")
+	}
+
+	return sb.String(), nil
+}
+
+func rustType(t *wit.Type) string {
+	switch {
+	case t.Name == "_":
+		return "()"
+	case t.Name == "u32":
+		return "u32"
+	case t.Name == "string":
+		return "String"
+	case t.IsList("u8"):
+		return "Vec<u8>"
+	case t.IsList("string"):
+		return "Vec<String>"
+	default:
+		return pascal(t.Name)
+	}
+}
+
+// Expression decoding a length-prefixed value of the given type from the decoder d
+func rustDecode(t *wit.Type) string {
+	switch {
+	case t.Name == "u32":
+		return "d.read_u32()"
+	case t.Name == "string":
+		return "d.read_string()"
+	case t.IsList("u8"):
+		return "d.read_bytes()"
+	case t.IsList("string"):
+		return "d.read_strings()"
+	default:
+		return fmt.Sprintf("decode_%s(d)", snake(t.Name))
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/synadia-io/nex/sdk/wasm/wit"
+)
+
+const tinygoPrelude = `//go:build tinygo || wasip1
+
+// Code generated by witgen from %s. DO NOT EDIT.
+
+// Package hostservices calls the host services nex offers to wasm workloads, imported from the
+// %s module. Every function takes a timeout in milliseconds, where zero uses the agent's
+// default
+package hostservices
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+)
+
+// Returned when a call fails
+type Error struct {
+	// 429 when the workload's budget is exhausted, 504 when the timeout passed
+	Code    uint32
+	Message string
+
+	// Time to wait before calling again, when the call was throttled
+	RetryAfterMs uint32
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("host service call failed: %%s (%%d)", e.Message, e.Code)
+}
+
+//go:wasmimport %[2]s result.len
+func resultLen() uint32
+
+//go:wasmimport %[2]s result.read
+func resultRead(ptr unsafe.Pointer, size uint32) uint32
+
+//go:wasmimport %[2]s result.retry-after-ms
+func resultRetryAfterMs() uint32
+
+func check(code uint32) ([]byte, error) {
+	size := resultLen()
+	data := make([]byte, size)
+	if size > 0 {
+		resultRead(unsafe.Pointer(&data[0]), size)
+	}
+
+	if code == 200 {
+		return data, nil
+	}
+
+	err := &Error{Code: code, Message: string(data)}
+	if code == 429 {
+		err.RetryAfterMs = resultRetryAfterMs()
+	}
+	return nil, err
+}
+
+type decoder struct {
+	data []byte
+}
+
+func (d *decoder) readU32() uint32 {
+	if len(d.data) < 4 {
+		d.data = nil
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(d.data)
+	d.data = d.data[4:]
+	return v
+}
+
+func (d *decoder) readBytes() []byte {
+	size := int(d.readU32())
+	if size > len(d.data) {
+		size = len(d.data)
+	}
+	v := append([]byte(nil), d.data[:size]...)
+	d.data = d.data[size:]
+	return v
+}
+
+func (d *decoder) readString() string {
+	return string(d.readBytes())
+}
+
+func (d *decoder) readStrings() []string {
+	count := d.readU32()
+	v := make([]string, 0, count)
+	for i := uint32(0); i < count; i++ {
+		v = append(v, d.readString())
+	}
+	return v
+}
+`
+
+func generateTinyGo(pkg *wit.Package, source string) (string, error) {
+	interfaces, err := importedFuncs(pkg)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, tinygoPrelude, source, importModule)
+
+	for _, record := range usedRecords(pkg, interfaces) {
+		sb.WriteString("\n")
+		if record.Doc != "" {
+			fmt.Fprintf(&sb, "// %s\n", record.Doc)
+		}
+		fmt.Fprintf(&sb, "type %s struct {\n", pascal(record.Name))
+		for _, field := range record.Fields {
+			fmt.Fprintf(&sb, "%s %s\n", pascal(field.Name), tinygoType(field.Type))
+		}
+		sb.WriteString("}\n\n")
+
+		fmt.Fprintf(&sb, "func decode%s(d *decoder) *%s {\n", pascal(record.Name), pascal(record.Name))
+		fmt.Fprintf(&sb, "return &%s{\n", pascal(record.Name))
+		for _, field := range record.Fields {
+			fmt.Fprintf(&sb, "%s: %s,\n", pascal(field.Name), tinygoDecode(field.Type))
+		}
+		sb.WriteString("}\n}\n")
+	}
+
+	for _, iface := range interfaces {
+		for _, fn := range iface.Funcs {
+			lowered := []string{}
+			params := []string{}
+			args := []string{}
+			for _, param := range fn.Params {
+				name := camel(param.Name)
+				switch param.Type.Name {
+				case "u32":
+					lowered = append(lowered, name+" uint32")
+					params = append(params, name+" uint32")
+					args = append(args, name)
+				case "string":
+					lowered = append(lowered, name+"Ptr unsafe.Pointer", name+"Len uint32")
+					params = append(params, name+" string")
+					args = append(args, fmt.Sprintf("unsafe.Pointer(unsafe.StringData(%s)), uint32(len(%[1]s))", name))
+				default:
+					lowered = append(lowered, name+"Ptr unsafe.Pointer", name+"Len uint32")
+					params = append(params, name+" []byte")
+					args = append(args, fmt.Sprintf("unsafe.Pointer(unsafe.SliceData(%s)), uint32(len(%[1]s))", name))
+				}
+			}
+
+			imported := camel(iface.Name) + pascal(fn.Name)
+			exported := pascal(iface.Name) + pascal(fn.Name)
+			value := fn.Result.Params[0]
+
+			fmt.Fprintf(&sb, "\n//go:wasmimport %s %s\n", importModule, importName(iface, fn))
+			fmt.Fprintf(&sb, "func %s(%s) uint32\n\n", imported, strings.Join(lowered, ", "))
+
+			if fn.Doc != "" {
+				fmt.Fprintf(&sb, "// %s\n", fn.Doc)
+			}
+
+			call := fmt.Sprintf("%s(%s)", imported, strings.Join(args, ", "))
+			switch {
+			case value.Name == "_":
+				fmt.Fprintf(&sb, "func %s(%s) error {\n", exported, strings.Join(params, ", "))
+				fmt.Fprintf(&sb, "_, err := check(%s)\nreturn err\n}\n", call)
+			case value.IsList("u8"):
+				fmt.Fprintf(&sb, "func %s(%s) ([]byte, error) {\n", exported, strings.Join(params, ", "))
+				fmt.Fprintf(&sb, "return check(%s)\n}\n", call)
+			case value.Name == "string":
+				fmt.Fprintf(&sb, "func %s(%s) (string, error) {\n", exported, strings.Join(params, ", "))
+				fmt.Fprintf(&sb, "data, err := check(%s)\nreturn string(data), err\n}\n", call)
+			default:
+				typ := tinygoType(value)
+				fmt.Fprintf(&sb, "func %s(%s) (%s, error) {\n", exported, strings.Join(params, ", "), typ)
+				fmt.Fprintf(&sb, "data, err := check(%s)\nif err != nil {\nreturn %s, err\n}\n\n", call, tinygoZero(value))
+				fmt.Fprintf(&sb, "d := &decoder{data: data}\nreturn %s, nil\n}\n", tinygoDecode(value))
+			}
+		}
+	}
+
+	out, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return "", fmt.Errorf("failed to format generated TinyGo source: %s", err)
+	}
+	return string(out), nil
+}
+
+func tinygoType(t *wit.Type) string {
+	switch {
+	case t.Name == "u32":
+		return "uint32"
+	case t.Name == "string":
+		return "string"
+	case t.IsList("u8"):
+		return "[]byte"
+	case t.IsList("string"):
+		return "[]string"
+	default:
+		return "*" + pascal(t.Name)
+	}
+}
+
+func tinygoZero(t *wit.Type) string {
+	switch t.Name {
+	case "u32":
+		return "0"
+	case "string":
+		return `""`
+	default:
+		return "nil"
+	}
+}
+
+// Expression decoding a length-prefixed value of the given type from the decoder d
+func tinygoDecode(t *wit.Type) string {
+	switch {
+	case t.Name == "u32":
+		return "d.readU32()"
+	case t.Name == "string":
+		return "d.readString()"
+	case t.IsList("u8"):
+		return "d.readBytes()"
+	case t.IsList("string"):
+		return "d.readStrings()"
+	default:
+		return fmt.Sprintf("decode%s(d)", pascal(t.Name))
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/synadia-io/nex/sdk/wasm/wit"
+)
+
+func TestGeneratedSDKsAreCurrent(t *testing.T) {
+	src, err := os.ReadFile("../nex-hostservices.wit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg, err := wit.Parse(string(src))
+	if err != nil {
+		t.Fatalf("Failed to parse WIT definition: %s", err)
+	}
+
+	rust, err := generateRust(pkg, "nex-hostservices.wit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tinygo, err := generateTinyGo(pkg, "nex-hostservices.wit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, generated := range map[string]string{
+		"../rust/src/lib.rs":        rust,
+		"../tinygo/hostservices.go": tinygo,
+	} {
+		committed, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(committed) != generated {
+			t.Fatalf("%s is out of date; run go generate ./sdk/wasm", path)
+		}
+	}
+}
+
+func TestUnsupportedTypesAreRejected(t *testing.T) {
+	pkg, err := wit.Parse(`
+package test:test;
+
+interface things {
+  get: func(ids: list<u32>) -> result<_, error>;
+}
+
+world w {
+  import things;
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = generateTinyGo(pkg, "test.wit")
+	if err == nil {
+		t.Fatal("Expected list<u32> parameters to be rejected")
+	}
+}
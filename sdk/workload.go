@@ -0,0 +1,302 @@
+// Package sdk is used by native Go workloads to run under nex: to read the metadata nex injects,
+// to receive the payloads of their trigger subjects, to call host services and to emit
+// structured logs. It has no dependencies outside the standard library
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Returned by calls that need the channel to the agent when the workload has none, e.g. on nodes
+// where the agent cannot hand workloads extra file descriptors
+var ErrNoChannel = errors.New("no channel to the nex agent")
+
+// Payload received on one of a function's trigger subjects
+type Trigger struct {
+	Subject string
+	Payload []byte
+
+	// Reply subject, message ID and headers of the triggering message
+	Reply   string
+	MsgID   string
+	Headers map[string][]string
+
+	responseHeaders map[string][]string
+}
+
+// Sets a header on the reply sent to the trigger's requester, replacing any previous values
+func (t Trigger) SetResponseHeader(name string, values ...string) {
+	if t.responseHeaders != nil {
+		t.responseHeaders[name] = values
+	}
+}
+
+// Handles a trigger, returning the reply sent to the trigger's requester, if any
+type TriggerHandler func(ctx context.Context, trigger Trigger) ([]byte, error)
+
+// A native workload running under nex
+type Workload struct {
+	Metadata *Metadata
+
+	log *slog.Logger
+
+	in  *bufio.Reader
+	out io.WriteCloser
+
+	writeMutex sync.Mutex
+
+	mutex   sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *Message
+	handler TriggerHandler
+
+	closed chan struct{}
+}
+
+// Connects the workload to nex, reading its metadata and opening its channel to the agent
+func New() (*Workload, error) {
+	md, err := LoadMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	channel := os.Getenv(EnvChannel)
+	if channel == "" {
+		return newWorkload(md, nil, nil), nil
+	}
+
+	in, out, err := openChannel(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWorkload(md, in, out), nil
+}
+
+func newWorkload(md *Metadata, in io.Reader, out io.WriteCloser) *Workload {
+	w := &Workload{
+		Metadata: md,
+		pending:  make(map[uint64]chan *Message),
+		closed:   make(chan struct{}),
+		log: slog.New(slog.NewJSONHandler(os.Stderr, nil)).With(
+			slog.String("workload_id", md.WorkloadID),
+			slog.String("workload_name", md.WorkloadName),
+			slog.String("namespace", md.Namespace),
+		),
+	}
+
+	if in == nil || out == nil {
+		close(w.closed)
+		return w
+	}
+
+	w.in = bufio.NewReader(in)
+	w.out = out
+	go w.read()
+
+	return w
+}
+
+// Opens the pipes named by the channel environment variable
+func openChannel(channel string) (io.Reader, io.WriteCloser, error) {
+	read, write, ok := strings.Cut(channel, ",")
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid %s: %q", EnvChannel, channel)
+	}
+
+	readFd, err := strconv.Atoi(read)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %q", EnvChannel, channel)
+	}
+	writeFd, err := strconv.Atoi(write)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %q", EnvChannel, channel)
+	}
+
+	return os.NewFile(uintptr(readFd), "nex-sdk-in"), os.NewFile(uintptr(writeFd), "nex-sdk-out"), nil
+}
+
+// Logger writing JSON records to the workload's stderr, which the agent captures as the
+// workload's logs, with the workload's ID, name and namespace on every record
+func (w *Workload) Logger() *slog.Logger {
+	return w.log
+}
+
+// Sets the handler of the function's triggers. Triggers received before a handler is set are
+// answered with an error
+func (w *Workload) HandleTriggers(handler TriggerHandler) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.handler = handler
+}
+
+// Host services available to the workload
+func (w *Workload) HostServices() *HostServices {
+	return &HostServices{w: w}
+}
+
+// Closed once the agent closes the channel, typically as the workload is undeployed
+func (w *Workload) Done() <-chan struct{} {
+	return w.closed
+}
+
+// Blocks until the context is done or the agent closes the channel. Functions call this once
+// they have set their trigger handler
+func (w *Workload) Run(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.closed:
+		return nil
+	}
+}
+
+// Closes the workload's end of the channel
+func (w *Workload) Close() error {
+	if w.out == nil {
+		return nil
+	}
+	return w.out.Close()
+}
+
+func (w *Workload) read() {
+	defer func() {
+		w.mutex.Lock()
+		for _, result := range w.pending {
+			close(result)
+		}
+		w.pending = nil
+		w.mutex.Unlock()
+
+		close(w.closed)
+	}()
+
+	for {
+		line, err := w.in.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+
+		var msg Message
+		err = json.Unmarshal(line, &msg)
+		if err != nil {
+			w.log.Warn("Ignoring invalid message from the nex agent", slog.Any("err", err))
+			continue
+		}
+
+		switch msg.Kind {
+		case KindTrigger:
+			go w.handleTrigger(&msg)
+		case KindHostServiceResult:
+			w.mutex.Lock()
+			result, ok := w.pending[msg.ID]
+			delete(w.pending, msg.ID)
+			w.mutex.Unlock()
+
+			if ok {
+				result <- &msg
+			}
+		default:
+			w.log.Warn("Ignoring unexpected message from the nex agent", slog.String("kind", msg.Kind))
+		}
+	}
+}
+
+func (w *Workload) handleTrigger(msg *Message) {
+	w.mutex.Lock()
+	handler := w.handler
+	w.mutex.Unlock()
+
+	result := &Message{ID: msg.ID, Kind: KindTriggerResult}
+	if handler == nil {
+		result.Error = "no trigger handler"
+	} else {
+		trigger := Trigger{
+			Subject:         msg.Subject,
+			Payload:         msg.Payload,
+			Reply:           msg.Reply,
+			MsgID:           msg.MsgID,
+			Headers:         msg.Headers,
+			responseHeaders: make(map[string][]string),
+		}
+
+		payload, err := handler(context.Background(), trigger)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Payload = payload
+			if len(trigger.responseHeaders) > 0 {
+				result.Headers = trigger.responseHeaders
+			}
+		}
+	}
+
+	err := w.write(result)
+	if err != nil {
+		w.log.Error("Failed to send trigger result to the nex agent", slog.Any("err", err))
+	}
+}
+
+// Sends a request to the agent and waits for its result
+func (w *Workload) request(ctx context.Context, msg *Message) (*Message, error) {
+	if w.out == nil {
+		return nil, ErrNoChannel
+	}
+
+	result := make(chan *Message, 1)
+
+	w.mutex.Lock()
+	if w.pending == nil {
+		w.mutex.Unlock()
+		return nil, ErrNoChannel
+	}
+	w.nextID++
+	msg.ID = w.nextID
+	w.pending[msg.ID] = result
+	w.mutex.Unlock()
+
+	err := w.write(msg)
+	if err != nil {
+		w.mutex.Lock()
+		delete(w.pending, msg.ID)
+		w.mutex.Unlock()
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-result:
+		if !ok {
+			return nil, ErrNoChannel
+		}
+		return resp, nil
+	case <-ctx.Done():
+		w.mutex.Lock()
+		delete(w.pending, msg.ID)
+		w.mutex.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (w *Workload) write(msg *Message) error {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	w.writeMutex.Lock()
+	defer w.writeMutex.Unlock()
+
+	_, err = w.out.Write(append(raw, '\n'))
+	return err
+}
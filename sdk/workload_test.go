@@ -0,0 +1,182 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Agent side of a channel to a workload under test
+type testAgent struct {
+	in  *bufio.Reader
+	out io.WriteCloser
+}
+
+func newTestWorkload(t *testing.T) (*Workload, *testAgent) {
+	t.Helper()
+
+	toWorkload, agentOut := io.Pipe()
+	agentIn, fromWorkload := io.Pipe()
+
+	w := newWorkload(&Metadata{WorkloadID: "abc", WorkloadName: "echo", Namespace: "default"}, toWorkload, fromWorkload)
+	t.Cleanup(func() {
+		_ = agentOut.Close()
+		_ = w.Close()
+	})
+
+	return w, &testAgent{in: bufio.NewReader(agentIn), out: agentOut}
+}
+
+func (a *testAgent) send(t *testing.T, msg *Message) {
+	t.Helper()
+
+	raw, _ := json.Marshal(msg)
+	_, err := a.out.Write(append(raw, '\n'))
+	if err != nil {
+		t.Fatalf("Failed to write to workload: %s", err)
+	}
+}
+
+func (a *testAgent) receive(t *testing.T) *Message {
+	t.Helper()
+
+	line, err := a.in.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("Failed to read from workload: %s", err)
+	}
+
+	var msg Message
+	err = json.Unmarshal(line, &msg)
+	if err != nil {
+		t.Fatalf("Workload sent an invalid message: %s", err)
+	}
+	return &msg
+}
+
+func TestTriggerIsAnsweredByHandler(t *testing.T) {
+	w, agent := newTestWorkload(t)
+	w.HandleTriggers(func(ctx context.Context, trigger Trigger) ([]byte, error) {
+		if trigger.Subject == "fail" {
+			return nil, errors.New("nope")
+		}
+		return []byte(strings.ToUpper(string(trigger.Payload))), nil
+	})
+
+	agent.send(t, &Message{ID: 1, Kind: KindTrigger, Subject: "echo", Payload: []byte("hello")})
+	result := agent.receive(t)
+	if result.ID != 1 || result.Kind != KindTriggerResult || string(result.Payload) != "HELLO" {
+		t.Fatalf("Unexpected trigger result: %+v", result)
+	}
+
+	agent.send(t, &Message{ID: 2, Kind: KindTrigger, Subject: "fail"})
+	result = agent.receive(t)
+	if result.ID != 2 || result.Error != "nope" {
+		t.Fatalf("Expected the handler's error, got %+v", result)
+	}
+}
+
+func TestTriggerMetadataAndResponseHeaders(t *testing.T) {
+	w, agent := newTestWorkload(t)
+	w.HandleTriggers(func(ctx context.Context, trigger Trigger) ([]byte, error) {
+		trigger.SetResponseHeader("Correlation-Id", trigger.Headers["Correlation-Id"]...)
+		trigger.SetResponseHeader("Reply", trigger.Reply)
+		return nil, nil
+	})
+
+	agent.send(t, &Message{
+		ID:      1,
+		Kind:    KindTrigger,
+		Subject: "echo",
+		Reply:   "_INBOX.abc",
+		Headers: map[string][]string{"Correlation-Id": {"42"}},
+	})
+	result := agent.receive(t)
+	if len(result.Headers) != 2 || result.Headers["Correlation-Id"][0] != "42" || result.Headers["Reply"][0] != "_INBOX.abc" {
+		t.Fatalf("Expected the handler's response headers, got %+v", result.Headers)
+	}
+}
+
+func TestHostServiceCallReturnsResult(t *testing.T) {
+	w, agent := newTestWorkload(t)
+
+	type outcome struct {
+		data []byte
+		err  error
+	}
+	done := make(chan outcome, 1)
+
+	go func() {
+		data, err := w.HostServices().KeyValueGet(context.Background(), "greeting")
+		done <- outcome{data, err}
+	}()
+
+	call := agent.receive(t)
+	if call.Kind != KindHostService || call.Service != ServiceKeyValue || call.Method != "get" || call.Metadata[keyValueKeyHeader] != "greeting" {
+		t.Fatalf("Unexpected host service call: %+v", call)
+	}
+	agent.send(t, &Message{ID: call.ID, Kind: KindHostServiceResult, Code: 200, Payload: []byte("hi")})
+
+	got := <-done
+	if got.err != nil || string(got.data) != "hi" {
+		t.Fatalf("Expected the key's value, got %q (%v)", got.data, got.err)
+	}
+
+	go func() {
+		err := w.HostServices().Publish(context.Background(), "events", []byte("x"))
+		done <- outcome{nil, err}
+	}()
+
+	call = agent.receive(t)
+	agent.send(t, &Message{ID: call.ID, Kind: KindHostServiceResult, Code: 429, Error: "budget exceeded", RetryAfterMillis: 1500})
+
+	got = <-done
+	var serviceErr *ServiceError
+	if !errors.As(got.err, &serviceErr) {
+		t.Fatalf("Expected a service error, got %v", got.err)
+	}
+	if serviceErr.Code != 429 || serviceErr.RetryAfter != 1500*time.Millisecond {
+		t.Fatalf("Unexpected service error: %+v", serviceErr)
+	}
+}
+
+func TestWorkloadIsDoneWhenAgentClosesChannel(t *testing.T) {
+	w, agent := newTestWorkload(t)
+	_ = agent.out.Close()
+
+	select {
+	case <-w.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Workload was not done after the agent closed the channel")
+	}
+
+	_, err := w.HostServices().KeyValueKeys(context.Background())
+	if !errors.Is(err, ErrNoChannel) {
+		t.Fatalf("Expected ErrNoChannel, got %v", err)
+	}
+}
+
+func TestLoadMetadata(t *testing.T) {
+	t.Setenv(EnvWorkloadID, "")
+	_, err := LoadMetadata()
+	if !errors.Is(err, ErrNotInNex) {
+		t.Fatalf("Expected ErrNotInNex, got %v", err)
+	}
+
+	t.Setenv(EnvWorkloadID, "abc")
+	t.Setenv(EnvWorkloadName, "echo")
+	t.Setenv(EnvNamespace, "default")
+	t.Setenv(EnvTriggerSubjects, "a.b,c.d")
+
+	md, err := LoadMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !md.IsFunction() || len(md.TriggerSubjects) != 2 || md.TriggerSubjects[1] != "c.d" {
+		t.Fatalf("Unexpected metadata: %+v", md)
+	}
+}
@@ -429,7 +429,7 @@ var _ = Describe("nex node", func() {
 									Expect(err).To(BeNil())
 
 									nodeClient := controlapi.NewApiClientWithNamespace(_fixtures.natsConn, time.Millisecond*1000, "default", log)
-									_, err = nodeClient.StartWorkload(deployRequest)
+									_, err = nodeClient.StartWorkload(context.Background(), deployRequest)
 
 									time.Sleep(time.Millisecond * 1000)
 								})
@@ -494,7 +494,7 @@ var _ = Describe("nex node", func() {
 											Expect(err).To(BeNil())
 
 											nodeClient := controlapi.NewApiClientWithNamespace(_fixtures.natsConn, time.Millisecond*1000, "default", log)
-											_, err = nodeClient.StartWorkload(deployRequest)
+											_, err = nodeClient.StartWorkload(context.Background(), deployRequest)
 											Expect(err).To(BeNil())
 
 											time.Sleep(time.Millisecond * 1000)
@@ -537,7 +537,7 @@ var _ = Describe("nex node", func() {
 												Expect(err).To(BeNil())
 
 												nodeClient := controlapi.NewApiClientWithNamespace(_fixtures.natsConn, time.Millisecond*1000, "default", log)
-												_, err = nodeClient.StartWorkload(deployRequest)
+												_, err = nodeClient.StartWorkload(context.Background(), deployRequest)
 												Expect(err).To(BeNil())
 
 												time.Sleep(time.Millisecond * 1000)
@@ -581,7 +581,7 @@ var _ = Describe("nex node", func() {
 												Expect(err).To(BeNil())
 
 												nodeClient := controlapi.NewApiClientWithNamespace(_fixtures.natsConn, time.Millisecond*1000, "default", log)
-												_, err = nodeClient.StartWorkload(deployRequest)
+												_, err = nodeClient.StartWorkload(context.Background(), deployRequest)
 												Expect(err).To(BeNil())
 
 												time.Sleep(time.Millisecond * 1000)
@@ -687,7 +687,7 @@ var _ = Describe("nex node", func() {
 												Expect(err).To(BeNil())
 
 												nodeClient := controlapi.NewApiClientWithNamespace(_fixtures.natsConn, time.Millisecond*3000, "default", log)
-												_, err = nodeClient.StartWorkload(deployRequest)
+												_, err = nodeClient.StartWorkload(context.Background(), deployRequest)
 												Expect(err).To(BeNil())
 
 												os.Remove(tmpfilePath)
@@ -799,7 +799,7 @@ var _ = Describe("nex node", func() {
 												Expect(err).To(BeNil())
 
 												nodeClient := controlapi.NewApiClientWithNamespace(_fixtures.natsConn, time.Millisecond*1000, "default", log)
-												_, err = nodeClient.StartWorkload(deployRequest)
+												_, err = nodeClient.StartWorkload(context.Background(), deployRequest)
 												Expect(err).To(BeNil())
 
 												time.Sleep(time.Millisecond * 1000)
@@ -848,7 +848,7 @@ var _ = Describe("nex node", func() {
 									Expect(err).To(BeNil())
 
 									nodeClient := controlapi.NewApiClientWithNamespace(_fixtures.natsConn, time.Millisecond*1000, "default", log)
-									_, err = nodeClient.StartWorkload(deployRequest)
+									_, err = nodeClient.StartWorkload(context.Background(), deployRequest)
 
 									time.Sleep(time.Millisecond * 1000)
 								})
@@ -924,7 +924,7 @@ func resolveNodeTargetPublicXKey(nodeID string, log *slog.Logger) (*string, erro
 
 	var info *controlapi.InfoResponse
 	for info == nil {
-		info, _ = nodeClient.NodeInfo(nodeID)
+		info, _ = nodeClient.NodeInfo(context.Background(), nodeID)
 		time.Sleep(time.Millisecond * 25)
 	}
 
@@ -342,7 +342,7 @@ var _ = Describe("nex node", func() {
 									Expect(err).To(BeNil())
 
 									nodeClient := controlapi.NewApiClientWithNamespace(_fixtures.natsConn, time.Millisecond*1000, "default", log)
-									_, err = nodeClient.StartWorkload(deployRequest)
+									_, err = nodeClient.StartWorkload(context.Background(), deployRequest)
 
 									time.Sleep(time.Millisecond * 1000)
 								})
@@ -387,7 +387,7 @@ var _ = Describe("nex node", func() {
 											Expect(err).To(BeNil())
 
 											nodeClient := controlapi.NewApiClientWithNamespace(_fixtures.natsConn, time.Millisecond*1000, "default", log)
-											_, err = nodeClient.StartWorkload(deployRequest)
+											_, err = nodeClient.StartWorkload(context.Background(), deployRequest)
 
 											time.Sleep(time.Millisecond * 1000)
 										})
@@ -411,7 +411,7 @@ var _ = Describe("nex node", func() {
 									Expect(err).To(BeNil())
 
 									nodeClient := controlapi.NewApiClientWithNamespace(_fixtures.natsConn, time.Millisecond*1000, "default", log)
-									_, err = nodeClient.StartWorkload(deployRequest)
+									_, err = nodeClient.StartWorkload(context.Background(), deployRequest)
 
 									time.Sleep(time.Millisecond * 1000)
 								})
@@ -480,7 +480,7 @@ func resolveNodeTargetPublicXKey(nodeID string, log *slog.Logger) (*string, erro
 
 	var info *controlapi.InfoResponse
 	for info == nil {
-		info, _ = nodeClient.NodeInfo(nodeID)
+		info, _ = nodeClient.NodeInfo(context.Background(), nodeID)
 		time.Sleep(time.Millisecond * 25)
 	}
 
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/nats-io/jwt/v2"
 	"github.com/nats-io/nkeys"
 	. "github.com/synadia-io/nex/control-api"
 )
@@ -58,4 +59,28 @@ func TestStopValidation(t *testing.T) {
 	if err == nil {
 		t.Fatalf("Expected to get an error validating bad subject, but got none")
 	}
+
+	// claims without an expiry or without the workload they were issued for are rejected
+	unboundedClaims := jwt.NewGenericClaims("testworkload")
+	unboundedClaims.Data["workload_id"] = "1234"
+	unboundedJwt, _ := unboundedClaims.Encode(issuerAccount)
+	err = (&StopRequest{WorkloadId: "1234", TargetNode: "Nx", WorkloadJwt: unboundedJwt}).Validate(&originalClaims)
+	if err == nil {
+		t.Fatalf("Expected to get an error validating stop claims without an expiry, but got none")
+	}
+
+	unscopedClaims := jwt.NewGenericClaims("testworkload")
+	unscopedClaims.Expires = time.Now().Add(StopClaimsLifetime).Unix()
+	unscopedJwt, _ := unscopedClaims.Encode(issuerAccount)
+	err = (&StopRequest{WorkloadId: "1234", TargetNode: "Nx", WorkloadJwt: unscopedJwt}).Validate(&originalClaims)
+	if err == nil {
+		t.Fatalf("Expected to get an error validating stop claims without a workload id, but got none")
+	}
+
+	otherWorkloadRequest, _ := NewStopRequest("5678", "testworkload", "Nx", issuerAccount)
+	otherWorkloadRequest.WorkloadId = "1234"
+	err = otherWorkloadRequest.Validate(&originalClaims)
+	if err == nil {
+		t.Fatalf("Expected to get an error validating stop claims issued for another workload, but got none")
+	}
 }